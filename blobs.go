@@ -35,6 +35,10 @@ var (
 type ErrBlobInvalidDigest struct {
 	Digest digest.Digest
 	Reason error
+	// Computed is the digest of the received content, if known.
+	Computed digest.Digest
+	// Size is the number of received bytes, if known.
+	Size int64
 }
 
 func (err ErrBlobInvalidDigest) Error() string {
@@ -42,6 +46,19 @@ func (err ErrBlobInvalidDigest) Error() string {
 		err.Digest, err.Reason)
 }
 
+// ErrBlobUploadIncomplete returned when the parts of a blob upload written
+// out of order do not add up to contiguous content on commit.
+type ErrBlobUploadIncomplete struct {
+	// Offset is the offset at which the content is missing or overlapping.
+	Offset int64
+	Reason error
+}
+
+func (err ErrBlobUploadIncomplete) Error() string {
+	return fmt.Sprintf("blob upload incomplete at offset %d: %v",
+		err.Offset, err.Reason)
+}
+
 // ErrBlobMounted returned when a blob is mounted from another repository
 // instead of initiating an upload session.
 type ErrBlobMounted struct {
@@ -275,6 +292,23 @@ type BlobWriter interface {
 	Cancel(ctx context.Context) error
 }
 
+// BlobPartWriter is an optional interface implemented by BlobWriters that
+// accept content out of order. Parts are stored apart from the content written
+// sequentially and are assembled on Commit, which fails with
+// ErrBlobUploadIncomplete if the sequential content and the parts do not form
+// a contiguous range. Parts may be written concurrently.
+type BlobPartWriter interface {
+	// WritePart writes the content of r as the part of the blob starting at
+	// offset, replacing any part previously written at the same offset. It
+	// returns the number of bytes written.
+	WritePart(ctx context.Context, offset int64, r io.Reader) (int64, error)
+
+	// ContiguousSize returns the size of the content received so far that
+	// forms a contiguous range from the start of the blob, including parts
+	// written out of order.
+	ContiguousSize(ctx context.Context) (int64, error)
+}
+
 // BlobService combines the operations to access, read and write blobs. This
 // can be used to describe remote blob services.
 type BlobService interface {
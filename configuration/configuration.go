@@ -79,6 +79,9 @@ type Configuration struct {
 	// Profiling configures external profiling services.
 	Profiling Profiling `yaml:"profiling,omitempty"`
 
+	// Tracing configures distributed tracing with OpenTelemetry.
+	Tracing Tracing `yaml:"tracing,omitempty"`
+
 	// HTTP contains configuration parameters for the registry's http
 	// interface.
 	HTTP struct {
@@ -105,6 +108,11 @@ type Configuration struct {
 		// receives a stop signal
 		DrainTimeout time.Duration `yaml:"draintimeout,omitempty"`
 
+		// MaxRequestDuration is the maximum amount of time allowed to serve a request. Storage driver operations made
+		// on behalf of a request are aborted once it is exceeded, or once the client disconnects. Zero or not specified
+		// means no limit.
+		MaxRequestDuration time.Duration `yaml:"maxrequestduration,omitempty"`
+
 		// TLS instructs the http server to listen with a TLS configuration.
 		// This only support simple tls configuration with a cert and key.
 		// Mostly, this is useful for testing situations or simple deployments
@@ -127,6 +135,15 @@ type Configuration struct {
 			// Specifies the lowest TLS version allowed
 			MinimumTLS string `yaml:"minimumtls,omitempty"`
 
+			// CipherSuites specifies the cipher suites allowed for TLS 1.2
+			// connections, by their IANA names. TLS 1.3 cipher suites are not
+			// configurable. Defaults to a set of ECDHE cipher suites.
+			CipherSuites []string `yaml:"ciphersuites,omitempty"`
+
+			// CurvePreferences specifies the elliptic curves allowed in ECDHE
+			// handshakes, in order of preference. Defaults to the Go defaults.
+			CurvePreferences []string `yaml:"curvepreferences,omitempty"`
+
 			// LetsEncrypt is used to configuration setting up TLS through
 			// Let's Encrypt instead of manually specifying certificate and
 			// key. If a TLS certificate is specified, the Let's Encrypt
@@ -151,6 +168,22 @@ type Configuration struct {
 		// the values are the associated header payloads.
 		Headers http.Header `yaml:"headers,omitempty"`
 
+		// CORS configures Cross-Origin Resource Sharing (CORS) for the API, allowing browser-based clients served
+		// from other origins to call the registry directly. Disabled unless AllowedOrigins is set.
+		CORS CORS `yaml:"cors,omitempty"`
+
+		// Clients restricts access to the registry based on the IP address and user agent of clients. Requests from
+		// clients that are not allowed are rejected before any other processing. Disabled by default.
+		Clients Clients `yaml:"clients,omitempty"`
+
+		// Limits protects the registry against clients that send oversized request bodies or that transfer blob
+		// uploads too slowly, holding server resources. Disabled by default.
+		Limits Limits `yaml:"limits,omitempty"`
+
+		// Compression configures the gzip compression of JSON API responses, such as catalog, tag list and manifest
+		// responses, for clients that accept it. Disabled by default.
+		Compression Compression `yaml:"compression,omitempty"`
+
 		// Debug configures the http debug interface, if specified. This can
 		// include services such as pprof, expvar and other data that should
 		// not be exposed externally. Left disabled by default.
@@ -166,6 +199,15 @@ type Configuration struct {
 			Pprof struct {
 				Enabled bool `yaml:"enabled,omitempty"`
 			} `yaml:"pprof,omitempty"`
+			// Expvar configures an expvar endpoint, which serves runtime and registry variables at `/debug/vars`.
+			Expvar struct {
+				Enabled bool `yaml:"enabled,omitempty"`
+			} `yaml:"expvar,omitempty"`
+			// Auth restricts access to the pprof and expvar endpoints. Other endpoints, such as health checks and
+			// metrics, are not affected.
+			Auth DebugAuth `yaml:"auth,omitempty"`
+			// TLS configures TLS for the debug server. Connections are not encrypted if no certificate is set.
+			TLS DebugTLS `yaml:"tls,omitempty"`
 		} `yaml:"debug,omitempty"`
 
 		// HTTP2 configuration options
@@ -180,6 +222,27 @@ type Configuration struct {
 	// registry events are dispatched.
 	Notifications Notifications `yaml:"notifications,omitempty"`
 
+	// Audit configures the audit log, a record of all write operations performed against the registry.
+	Audit Audit `yaml:"audit,omitempty"`
+
+	// Replication configures the asynchronous replication of pushed content to a secondary registry.
+	Replication Replication `yaml:"replication,omitempty"`
+
+	// DataMover configures the online relocation of repositories to a different storage backend.
+	DataMover DataMover `yaml:"datamover,omitempty"`
+
+	// Retention configures the enforcement of per-repository tag retention policies.
+	Retention Retention `yaml:"retention,omitempty"`
+
+	// Scrubber configures the background verification of the integrity of stored blobs.
+	Scrubber Scrubber `yaml:"scrubber,omitempty"`
+
+	// Admin configures the administrative APIs served on separate listeners.
+	Admin Admin `yaml:"admin,omitempty"`
+
+	// Runtime tunes the Go runtime at startup.
+	Runtime Runtime `yaml:"runtime,omitempty"`
+
 	// Redis configures the redis pool available to the registry webapp.
 	Redis struct {
 		// Addr specifies the redis instance available to the application. For Sentinel it should be a list of
@@ -217,6 +280,51 @@ type Configuration struct {
 			// IdleTimeout sets the amount time to wait before closing inactive connections.
 			IdleTimeout time.Duration `yaml:"idletimeout,omitempty"`
 		} `yaml:"pool,omitempty"`
+
+		// CircuitBreaker configures the blob descriptor cache to be skipped while redis is unavailable.
+		CircuitBreaker struct {
+			// Disabled disables the circuit breaker, in which case every cache operation is attempted against redis.
+			Disabled bool `yaml:"disabled,omitempty"`
+			// Threshold is the number of consecutive connectivity failures after which the cache is skipped. Defaults
+			// to 5.
+			Threshold int `yaml:"threshold,omitempty"`
+			// Cooldown is the time in between probes to check whether redis recovered while the cache is skipped.
+			// Defaults to 30s.
+			Cooldown time.Duration `yaml:"cooldown,omitempty"`
+		} `yaml:"circuitbreaker,omitempty"`
+
+		// UploadState configures the persistence of blob upload session states in redis, allowing any registry
+		// instance to resume an upload, regardless of which one handled the previous request.
+		UploadState struct {
+			// Enabled enables the persistence of upload states.
+			Enabled bool `yaml:"enabled,omitempty"`
+			// TTL is the time for which the state of an idle upload is retained. Defaults to 24h.
+			TTL time.Duration `yaml:"ttl,omitempty"`
+		} `yaml:"uploadstate,omitempty"`
+
+		// TagLocks configures distributed locks in redis, serializing concurrent writes to the same tag across registry
+		// instances.
+		TagLocks struct {
+			// Enabled enables tag locks.
+			Enabled bool `yaml:"enabled,omitempty"`
+			// TTL is the lease of locks, which are refreshed while held. It bounds the time for which a tag remains
+			// locked if the instance holding the lock dies. Defaults to 30s.
+			TTL time.Duration `yaml:"ttl,omitempty"`
+			// Timeout is the maximum time to wait for a lock held by a concurrent request, after which the request
+			// fails with a 503 Service Unavailable response. Defaults to 10s.
+			Timeout time.Duration `yaml:"timeout,omitempty"`
+		} `yaml:"taglocks,omitempty"`
+
+		// ManifestCache configures a cache of manifests in redis, shared by all registry instances. Manifests read
+		// from the metadata database by tag or digest are served from the cache until invalidated or expired.
+		ManifestCache struct {
+			// Enabled enables the shared manifest cache.
+			Enabled bool `yaml:"enabled,omitempty"`
+			// TTL is the time for which manifests are cached. It bounds the time for which a stale manifest may be
+			// served if a tag is changed without going through the API, for example by a retention policy. Defaults
+			// to 10m.
+			TTL time.Duration `yaml:"ttl,omitempty"`
+		} `yaml:"manifestcache,omitempty"`
 	} `yaml:"redis,omitempty"`
 
 	Health Health `yaml:"health,omitempty"`
@@ -241,9 +349,68 @@ type Configuration struct {
 				// that URLs in pushed manifests must not match.
 				Deny []string `yaml:"deny,omitempty"`
 			} `yaml:"urls,omitempty"`
+			// AllowedMediaTypes is the list of media types that pushed manifests, and the configuration of pushed
+			// image manifests, must have. Empty means unrestricted.
+			AllowedMediaTypes []string `yaml:"allowedmediatypes,omitempty"`
+			// Async configures asynchronous validation of manifests pushed by trusted subjects.
+			Async AsyncManifestValidation `yaml:"async,omitempty"`
+			// PayloadSizeLimit is the maximum size in bytes of pushed manifest payloads. Defaults to 4 MiB. Enforced
+			// even if validation is disabled.
+			PayloadSizeLimit int64 `yaml:"payloadsizelimit,omitempty"`
+			// Strict rejects pushed manifests with top-level fields not defined by the specification of their media
+			// type, which would otherwise be silently ignored.
+			Strict bool `yaml:"strict,omitempty"`
+			// VerifyPlatforms rejects pushed manifest lists that declare a platform for an image manifest which does
+			// not match the OS, architecture and variant of its image configuration.
+			VerifyPlatforms bool `yaml:"verifyplatforms,omitempty"`
 		} `yaml:"manifests,omitempty"`
+		// Repositories configures validation of repository names on write operations.
+		Repositories struct {
+			// MaxPathComponents is the maximum number of path components (separated by `/`) allowed in a repository
+			// name. Defaults to 0 (unlimited).
+			MaxPathComponents int `yaml:"maxpathcomponents,omitempty"`
+			// MaxLength is the maximum number of characters allowed in a repository name. Defaults to 0 (unlimited).
+			MaxLength int `yaml:"maxlength,omitempty"`
+			// AllowedPattern is a regular expression (https://godoc.org/regexp/syntax) that repository names must
+			// fully match.
+			AllowedPattern string `yaml:"allowedpattern,omitempty"`
+		} `yaml:"repositories,omitempty"`
+		// Uploads configures validation of blob upload chunks.
+		Uploads struct {
+			// StrictChunkOrdering rejects chunks sent at an offset other than the current upload offset, either
+			// through the Content-Range header or the upload location of a previous chunk, with a 416 Requested Range
+			// Not Satisfiable response. The upload is left intact and can be resumed. When disabled, an upload whose
+			// location is out of date is canceled.
+			StrictChunkOrdering bool `yaml:"strictchunkordering,omitempty"`
+			// ParallelChunks accepts chunks sent ahead of the current upload offset through the Content-Range header,
+			// allowing clients to upload the chunks of a blob out of order and concurrently. Chunks are assembled when
+			// the upload is completed, which fails if they do not add up to contiguous content.
+			ParallelChunks bool `yaml:"parallelchunks,omitempty"`
+			// MinChunkLength is the minimum length in bytes of upload chunks, other than the last one, that clients
+			// should send. It is advertised through the OCI-Chunk-Min-Length header of upload responses but not
+			// enforced. Not advertised if zero.
+			MinChunkLength int64 `yaml:"minchunklength,omitempty"`
+		} `yaml:"uploads,omitempty"`
 	} `yaml:"validation,omitempty"`
 
+	// Compatibility configures how the registry handles legacy content and clients.
+	Compatibility struct {
+		// Schema1 configures the handling of the deprecated Docker schema 1 manifest format, which can no longer be
+		// pushed or pulled.
+		Schema1 struct {
+			// MigrationURL is the URL of documentation explaining how to migrate schema 1 images. When set, it is
+			// included in the errors returned to clients that push or pull schema 1 manifests.
+			MigrationURL string `yaml:"migrationurl,omitempty"`
+		} `yaml:"schema1,omitempty"`
+		// ManifestLists configures how manifest lists are served to clients that do not support them.
+		ManifestLists struct {
+			// DefaultPlatforms is the list of platforms, in the os/arch[/variant] format and in order of preference,
+			// used to select the manifest returned in place of a manifest list fetched by tag by a client that does
+			// not support manifest lists. Defaults to linux/amd64.
+			DefaultPlatforms []string `yaml:"defaultplatforms,omitempty"`
+		} `yaml:"manifestlists,omitempty"`
+	} `yaml:"compatibility,omitempty"`
+
 	// Policy configures registry policy options.
 	Policy struct {
 		// Repository configures policies for repositories
@@ -254,12 +421,95 @@ type Configuration struct {
 			// manifests. When non-empty, the registry will enforce
 			// the class in authorized resources.
 			Classes []string `yaml:"classes"`
+			// MaxTags is the maximum number of tags allowed per repository. Pushes that would create a new tag
+			// beyond this limit are rejected. Only enforced with the metadata database. Defaults to 0 (unlimited).
+			MaxTags int `yaml:"maxtags,omitempty"`
+			// MaxManifests is the maximum number of manifests allowed per repository. Pushes that would create a
+			// new manifest beyond this limit are rejected. Only enforced with the metadata database. Defaults to 0
+			// (unlimited).
+			MaxManifests int `yaml:"maxmanifests,omitempty"`
+			// DisableAutoCreate stops repositories from being created implicitly by starting a blob upload or
+			// pushing a manifest. Repositories must then be created explicitly through the GitLab v1 API. Only
+			// enforced with the metadata database.
+			DisableAutoCreate bool `yaml:"disableautocreate,omitempty"`
 		} `yaml:"repository,omitempty"`
+		// Uploads configures limits on in-flight blob upload sessions.
+		Uploads struct {
+			// MaxConcurrent is the maximum number of in-flight blob upload sessions across all repositories. Only
+			// enforced with the metadata database. Defaults to 0 (unlimited).
+			MaxConcurrent int `yaml:"maxconcurrent,omitempty"`
+			// MaxConcurrentPerRepository is the maximum number of in-flight blob upload sessions per repository.
+			// Only enforced with the metadata database. Defaults to 0 (unlimited).
+			MaxConcurrentPerRepository int `yaml:"maxconcurrentperrepository,omitempty"`
+		} `yaml:"uploads,omitempty"`
 	} `yaml:"policy,omitempty"`
 
 	GC GC `yaml:"gc,omitempty"`
 }
 
+// CORS configures Cross-Origin Resource Sharing (CORS) for the API.
+type CORS struct {
+	// AllowedOrigins is the list of origins allowed to make cross-origin requests. Use `*` to allow any origin. CORS
+	// is disabled if empty.
+	AllowedOrigins []string `yaml:"allowedorigins,omitempty"`
+	// AllowedMethods is the list of methods allowed in cross-origin requests. Defaults to `GET`, `HEAD` and `OPTIONS`.
+	AllowedMethods []string `yaml:"allowedmethods,omitempty"`
+	// AllowedHeaders is the list of request headers allowed in cross-origin requests, in addition to the CORS
+	// safelisted request headers.
+	AllowedHeaders []string `yaml:"allowedheaders,omitempty"`
+	// ExposedHeaders is the list of response headers exposed to cross-origin clients. Defaults to the registry API
+	// specific headers, such as `Docker-Content-Digest` and `Link`.
+	ExposedHeaders []string `yaml:"exposedheaders,omitempty"`
+	// MaxAge is how long the result of a preflight request can be cached by clients. Browsers may cap this value.
+	MaxAge time.Duration `yaml:"maxage,omitempty"`
+	// AllowCredentials allows cross-origin requests to include credentials, such as cookies or authorization headers.
+	AllowCredentials bool `yaml:"allowcredentials,omitempty"`
+}
+
+// Clients restricts access to the registry based on the IP address and user agent of clients.
+type Clients struct {
+	// AllowedIPs is the list of IP addresses or CIDR ranges allowed to access the registry. Any client IP is allowed if
+	// empty.
+	AllowedIPs []string `yaml:"allowedips,omitempty"`
+	// DeniedIPs is the list of IP addresses or CIDR ranges denied access to the registry. Takes precedence over
+	// AllowedIPs.
+	DeniedIPs []string `yaml:"deniedips,omitempty"`
+	// DeniedUserAgents is the list of regular expressions matching the user agent of clients denied access to the
+	// registry.
+	DeniedUserAgents []string `yaml:"denieduseragents,omitempty"`
+	// TrustedProxies is the list of IP addresses or CIDR ranges of the proxies in front of the registry. The client IP
+	// is only read from the X-Forwarded-For and X-Real-Ip headers of requests from these addresses. Otherwise, the
+	// address of the connection is used.
+	TrustedProxies []string `yaml:"trustedproxies,omitempty"`
+}
+
+// Limits configures request body size limits and slow client protection.
+type Limits struct {
+	// MaxBodySize is the maximum size, in bytes, of the request body of all endpoints except blob uploads. Zero or not
+	// specified means no limit.
+	MaxBodySize int64 `yaml:"maxbodysize,omitempty"`
+	// MinUploadRate is the minimum rate, in bytes per second, at which clients must transfer the body of blob upload
+	// requests. Uploads transferred below this rate over a MinUploadRatePeriod are aborted. Zero or not specified means
+	// no limit.
+	MinUploadRate int64 `yaml:"minuploadrate,omitempty"`
+	// MinUploadRatePeriod is the period over which the transfer rate of blob uploads is measured. Defaults to 30s.
+	MinUploadRatePeriod time.Duration `yaml:"minuploadrateperiod,omitempty"`
+}
+
+// Compression configures the compression of API responses.
+type Compression struct {
+	// Enabled enables the gzip compression of responses for clients that send a matching Accept-Encoding header.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// MinSize is the minimum size, in bytes, of a response body for it to be compressed. Defaults to 1024.
+	MinSize int `yaml:"minsize,omitempty"`
+	// Level is the gzip compression level, from 1 (best speed) to 9 (best compression). Defaults to 6.
+	Level int `yaml:"level,omitempty"`
+	// ContentTypes is the list of response media types eligible for compression. An entry starting with `+` matches
+	// media types with that structured syntax suffix, such as `+json`. Defaults to `application/json` and `+json`,
+	// which covers all manifest media types.
+	ContentTypes []string `yaml:"contenttypes,omitempty"`
+}
+
 // GC configures online Garbage Collection.
 type GC struct {
 	// Disabled disables the online GC workers.
@@ -302,6 +552,24 @@ type GCManifests struct {
 	Interval time.Duration `yaml:"interval,omitempty"`
 }
 
+// AsyncManifestValidation configures asynchronous manifest validation. When enabled, manifests pushed by trusted
+// subjects are accepted after structural checks only, while the verification of their references, configuration and
+// platforms is performed in the background. Tags pointing to manifests that fail validation are removed.
+type AsyncManifestValidation struct {
+	// Enabled enables asynchronous validation for trusted subjects.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// TrustedSubjects is the list of authenticated token subjects (usernames) whose manifest pushes are validated
+	// asynchronously. All other subjects are subject to synchronous validation.
+	TrustedSubjects []string `yaml:"trustedsubjects,omitempty"`
+	// Concurrency is the number of manifests validated concurrently. Defaults to 5.
+	Concurrency int `yaml:"concurrency,omitempty"`
+	// QueueSize is the maximum number of manifests pending validation. Once full, pushes from trusted subjects are
+	// validated synchronously. Defaults to 1000.
+	QueueSize int `yaml:"queuesize,omitempty"`
+	// Timeout is the maximum amount of time allowed to validate a single manifest. Defaults to 1 minute.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
 // Profiling configures external profiling services.
 type Profiling struct {
 	Stackdriver StackdriverProfiler `yaml:"stackdriver,omitempty"`
@@ -326,6 +594,23 @@ type StackdriverProfiler struct {
 	KeyFile string `yaml:"keyfile,omitempty"`
 }
 
+// Tracing configures distributed tracing with OpenTelemetry. When enabled, a span is recorded for each HTTP request,
+// with child spans for database queries and storage driver operations, and exported to an OpenTelemetry collector
+// using the OTLP gRPC protocol. Incoming W3C Trace Context (`traceparent`) headers are honored.
+type Tracing struct {
+	// Enabled can be set to `true` to enable tracing.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Endpoint is the address (host:port) of the OTLP gRPC collector. Defaults to `localhost:55680`.
+	Endpoint string `yaml:"endpoint,omitempty"`
+	// Insecure disables TLS for the connection with the collector.
+	Insecure bool `yaml:"insecure,omitempty"`
+	// ServiceName is the name of the service under which spans are recorded. Defaults to `container-registry`.
+	ServiceName string `yaml:"servicename,omitempty"`
+	// SamplingRatio is the fraction of traces to sample, between 0 and 1. Traces propagated by callers follow the
+	// sampling decision of the caller. Defaults to 1 (sample all traces).
+	SamplingRatio float64 `yaml:"samplingratio,omitempty"`
+}
+
 // Database is the configuration for the registry's metadata database
 type Database struct {
 	// Enabled can be used to enable or bypass the metadata database
@@ -361,12 +646,49 @@ type Database struct {
 		// lazily before reuse. Defaults to 0 (unlimited).
 		MaxLifetime time.Duration `yaml:"maxlifetime,omitempty"`
 	} `yaml:"pool,omitempty"`
+	// Retry configures retries with exponential backoff for failed connections and read-only queries, so that brief
+	// database failovers are not noticed by clients.
+	Retry struct {
+		// MaxRetries is the maximum number of retries for a single connection attempt or read-only query. Defaults to
+		// 0 (retries disabled).
+		MaxRetries int `yaml:"maxretries,omitempty"`
+		// InitialInterval is the time to wait before the first retry. Defaults to 100ms.
+		InitialInterval time.Duration `yaml:"initialinterval,omitempty"`
+		// MaxInterval caps the exponentially growing time to wait between retries. Defaults to 2s.
+		MaxInterval time.Duration `yaml:"maxinterval,omitempty"`
+	} `yaml:"retry,omitempty"`
 	// Maximum time to wait for a connection. Zero or not specified means waiting indefinitely.
 	ConnectTimeout time.Duration `yaml:"connecttimeout,omitempty"`
 	// DrainTimeout time to wait to drain all connections on shutdown. Zero or not specified means waiting indefinitely.
 	DrainTimeout time.Duration `yaml:"draintimeout,omitempty"`
 	// PreparedStatements can be used to enable prepared statements. Defaults to false.
 	PreparedStatements bool `yaml:"preparedstatements,omitempty"`
+	// ManifestCache configures an in-process cache of manifests read from the database by digest.
+	ManifestCache struct {
+		// Enabled can be used to enable the manifest cache. Defaults to false.
+		Enabled bool `yaml:"enabled,omitempty"`
+		// MaxSize is the maximum size of the cache in bytes. Defaults to 64 MiB.
+		MaxSize int64 `yaml:"maxsize,omitempty"`
+		// TTL is the amount of time for which a cached manifest is served for a repository before checking again
+		// that the manifest still exists in that repository. Defaults to 5 minutes.
+		TTL time.Duration `yaml:"ttl,omitempty"`
+	} `yaml:"manifestcache,omitempty"`
+	// RepositoryCache configures an in-process cache of repositories looked up in the database by path.
+	RepositoryCache struct {
+		// Enabled can be used to enable the repository cache. Defaults to false.
+		Enabled bool `yaml:"enabled,omitempty"`
+		// MaxEntries is the maximum number of repositories in the cache. Defaults to 10000.
+		MaxEntries int `yaml:"maxentries,omitempty"`
+		// TTL is the amount of time for which a cached repository is used before looking it up in the database again.
+		// Defaults to 30 seconds.
+		TTL time.Duration `yaml:"ttl,omitempty"`
+	} `yaml:"repositorycache,omitempty"`
+	// Mirror configures the registry as a read-only mirror of another registry, serving pull requests from a read
+	// replica of its metadata database and the same storage backend.
+	Mirror struct {
+		// Enabled can be used to enable the read-only mirror mode. All write requests are rejected. Defaults to false.
+		Enabled bool `yaml:"enabled,omitempty"`
+	} `yaml:"mirror,omitempty"`
 }
 
 // Regexp wraps regexp.Regexp to implement the encoding.TextMarshaler interface.
@@ -411,6 +733,18 @@ type Migration struct {
 	// /<root-directory>/docker/registry/v2 Once the migration is complete, the
 	// storage driver configuration must be updated to use this root directory.
 	RootDirectory string `yaml:"rootdirectory,omitempty"`
+	// Backfill configures the asynchronous import of manifests read from the filesystem into the database, for
+	// repositories that were not migrated yet.
+	Backfill MigrationBackfill `yaml:"backfill,omitempty"`
+}
+
+// MigrationBackfill configures the asynchronous backfill of the database on filesystem reads during migration.
+type MigrationBackfill struct {
+	// Enabled enables backfilling the database on each successful manifest read from the filesystem.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// MaxConcurrency is the maximum number of manifests backfilled concurrently. Reads exceeding this limit are not
+	// backfilled. Defaults to 5.
+	MaxConcurrency int `yaml:"maxconcurrency,omitempty"`
 }
 
 // MailOptions provides the configuration sections to user, for specific handler.
@@ -496,6 +830,34 @@ type Health struct {
 		// unhealthy state
 		Threshold int `yaml:"threshold,omitempty"`
 	} `yaml:"storagedriver,omitempty"`
+	// Database configures the health check on the metadata database. This
+	// check is always registered when the metadata database is enabled.
+	Database struct {
+		// Interval is the duration in between checks
+		Interval time.Duration `yaml:"interval,omitempty"`
+		// Threshold is the number of times a check must fail to trigger an
+		// unhealthy state
+		Threshold int `yaml:"threshold,omitempty"`
+		// Timeout is the maximum duration of each check
+		Timeout time.Duration `yaml:"timeout,omitempty"`
+		// MaxReplicationLag is the maximum tolerated replication lag of
+		// replicas attached to the database server. Zero disables the
+		// replication lag check.
+		MaxReplicationLag time.Duration `yaml:"maxreplicationlag,omitempty"`
+	} `yaml:"database,omitempty"`
+	// Redis configures the health check on the Redis server, if one is
+	// configured (see Configuration.Redis)
+	Redis struct {
+		// Enabled turns on the health check for the Redis server
+		Enabled bool `yaml:"enabled,omitempty"`
+		// Interval is the duration in between checks
+		Interval time.Duration `yaml:"interval,omitempty"`
+		// Threshold is the number of times a check must fail to trigger an
+		// unhealthy state
+		Threshold int `yaml:"threshold,omitempty"`
+		// Timeout is the maximum duration of each check
+		Timeout time.Duration `yaml:"timeout,omitempty"`
+	} `yaml:"redis,omitempty"`
 }
 
 // v0_1Configuration is a Version 0.1 Configuration struct
@@ -885,6 +1247,19 @@ type Notifications struct {
 	// respond to webhook notifications. In the future, we may allow other
 	// kinds of endpoints, such as external queues.
 	Endpoints []Endpoint `yaml:"endpoints,omitempty"`
+	// GCEvents configures the delete events emitted for artifacts removed by garbage collection.
+	GCEvents GCEvents `yaml:"gcevents,omitempty"`
+}
+
+// GCEvents configures the delete events emitted for artifacts removed by online and offline garbage collection. These
+// events are batched before being sent to endpoints, to avoid flooding them when a large number of artifacts is removed.
+type GCEvents struct {
+	// Enabled enables GC delete events.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// MaxBatchSize is the maximum number of events sent to endpoints at once. Defaults to 100.
+	MaxBatchSize int `yaml:"maxbatchsize,omitempty"`
+	// FlushInterval is the maximum time for which events are held before being sent to endpoints. Defaults to 1s.
+	FlushInterval time.Duration `yaml:"flushinterval,omitempty"`
 }
 
 // Endpoint describes the configuration of an http webhook notification
@@ -899,6 +1274,59 @@ type Endpoint struct {
 	Backoff           time.Duration `yaml:"backoff"`           // backoff duration
 	IgnoredMediaTypes []string      `yaml:"ignoredmediatypes"` // target media types to ignore
 	Ignore            Ignore        `yaml:"ignore"`            // ignore event types
+	// MaxBackoff enables exponential backoff. The backoff duration doubles with every consecutive failure past the
+	// threshold, up to this value. Defaults to 0, which uses a fixed backoff.
+	MaxBackoff time.Duration `yaml:"maxbackoff,omitempty"`
+	// MaxRetries is the maximum number of times the delivery of events is retried before giving up on them. Defaults
+	// to 0, which retries indefinitely.
+	MaxRetries int `yaml:"maxretries,omitempty"`
+	// QueueSize is the maximum number of events pending delivery. Defaults to 0, which does not limit the queue size.
+	QueueSize int `yaml:"queuesize,omitempty"`
+	// QueuePolicy determines which events are dropped when the queue is full. Defaults to `dropoldest`.
+	QueuePolicy QueuePolicy `yaml:"queuepolicy,omitempty"`
+	// DeadLetter configures where events that could not be delivered are sent.
+	DeadLetter DeadLetter `yaml:"deadletter,omitempty"`
+}
+
+// QueuePolicy determines which events are dropped when a notification endpoint queue is full.
+type QueuePolicy string
+
+const (
+	// QueuePolicyDropOldest drops the events that have been queued the longest to make room for new ones.
+	QueuePolicyDropOldest QueuePolicy = "dropoldest"
+	// QueuePolicyDropNewest drops new events, keeping the ones already queued.
+	QueuePolicyDropNewest QueuePolicy = "dropnewest"
+)
+
+var queuePolicies = []QueuePolicy{QueuePolicyDropOldest, QueuePolicyDropNewest}
+
+// UnmarshalYAML implements the yaml.Umarshaler interface for QueuePolicy, parsing it and validating that it represents
+// a valid queue policy.
+func (p *QueuePolicy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var val string
+	if err := unmarshal(&val); err != nil {
+		return err
+	}
+
+	qp := QueuePolicy(strings.ToLower(val))
+	for _, v := range queuePolicies {
+		if qp == v {
+			*p = qp
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid queue policy %q, must be one of %q", val, queuePolicies)
+}
+
+// DeadLetter configures a sink for notification events that could not be delivered to an endpoint, either because the
+// maximum number of retries was exceeded or because they were dropped from a full queue. If both File and URL are set,
+// File takes precedence.
+type DeadLetter struct {
+	File    string        `yaml:"file,omitempty"`    // path of a file to which events are appended, one envelope per line
+	URL     string        `yaml:"url,omitempty"`     // post url for the dead letter endpoint
+	Headers http.Header   `yaml:"headers,omitempty"` // static headers that should be added to all requests
+	Timeout time.Duration `yaml:"timeout,omitempty"` // HTTP timeout
 }
 
 // Events configures notification events.
@@ -912,6 +1340,161 @@ type Ignore struct {
 	Actions    []string `yaml:"actions"`    // ignore action types
 }
 
+// Audit configures the audit log. Audit events are emitted as structured JSON to a dedicated sink, independently of the
+// application and access logs.
+type Audit struct {
+	// Enabled enables the audit log.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// File is the path of a file to which audit events are appended, one JSON object per line.
+	File string `yaml:"file,omitempty"`
+	// HTTP configures an HTTP endpoint to which audit events are posted.
+	HTTP AuditHTTP `yaml:"http,omitempty"`
+	// Database persists audit events to the metadata database, making them available through the repository events
+	// API. Requires the metadata database to be enabled.
+	Database bool `yaml:"database,omitempty"`
+	// QueueSize is the maximum number of events waiting to be written to the sink. Defaults to 1000.
+	QueueSize int `yaml:"queuesize,omitempty"`
+}
+
+// AuditHTTP configures an HTTP endpoint sink for the audit log.
+type AuditHTTP struct {
+	URL     string        `yaml:"url,omitempty"`     // post url for the endpoint.
+	Headers http.Header   `yaml:"headers,omitempty"` // static headers that should be added to all requests
+	Timeout time.Duration `yaml:"timeout,omitempty"` // HTTP timeout
+}
+
+// Replication configures the asynchronous replication of pushed blobs and manifests to a secondary registry. Pushed
+// content is recorded in a queue persisted on the metadata database and pushed to the secondary registry in the
+// background. Requires the metadata database to be enabled.
+type Replication struct {
+	// Enabled enables replication.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// RemoteURL is the base URL of the secondary registry.
+	RemoteURL string `yaml:"remoteurl,omitempty"`
+	// Username is the username used to authenticate against the secondary registry.
+	Username string `yaml:"username,omitempty"`
+	// Password is the password used to authenticate against the secondary registry.
+	Password string `yaml:"password,omitempty"`
+	// Interval is the initial sleep interval between each worker run. Defaults to 5s.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// Timeout is the maximum amount of time allowed to replicate a single blob or manifest. Tasks that exceed it
+	// are retried. Defaults to 10m.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// MaxBackoff is the maximum exponential backoff duration used to postpone the retry of failed tasks. Defaults to
+	// 24h.
+	MaxBackoff time.Duration `yaml:"maxbackoff,omitempty"`
+}
+
+// DataMover configures the online relocation of repositories from the main storage backend to a target one. Moves are
+// requested per repository through the API and processed in the background, while the repository remains available.
+// Requires the metadata database to be enabled.
+type DataMover struct {
+	// Enabled enables the data mover.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Storage is the configuration of the target storage driver, in the same format as the main storage section.
+	Storage Storage `yaml:"storage,omitempty"`
+	// Interval is the initial sleep interval between each worker run. Defaults to 5s.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// Timeout is the maximum amount of time allowed to process a single repository move step. Steps that exceed it
+	// are retried. Defaults to 1h.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// MaxBackoff is the maximum exponential backoff duration used to postpone the retry of failed moves. Defaults to
+	// 24h.
+	MaxBackoff time.Duration `yaml:"maxbackoff,omitempty"`
+}
+
+// Retention configures the enforcement of tag retention policies. Policies are set per repository through the API and
+// evaluated periodically in the background, deleting the tags that they do not retain. Requires the metadata database
+// to be enabled.
+type Retention struct {
+	// Enabled enables the enforcement of retention policies.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Interval is the initial sleep interval between each worker run. Defaults to 5s.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// Period is the amount of time between consecutive evaluations of each policy. Defaults to 24h.
+	Period time.Duration `yaml:"period,omitempty"`
+	// Timeout is the maximum amount of time allowed to evaluate and enforce a single policy. Policies that exceed it
+	// are retried. Defaults to 10m.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// MaxBackoff is the maximum exponential backoff duration used to postpone the retry of failed evaluations.
+	// Defaults to 24h.
+	MaxBackoff time.Duration `yaml:"maxbackoff,omitempty"`
+}
+
+// Scrubber configures the background verification of the integrity of stored blobs. On each run, a random sample of
+// blobs is streamed from the storage backend and their digests recomputed to detect corruption.
+type Scrubber struct {
+	// Enabled enables the scrubber.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Interval is the amount of time to wait after each run before starting the next one. Defaults to 24h.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// SampleRatio is the ratio, greater than 0 and up to 1, of blobs verified on each run. Defaults to 0.1.
+	SampleRatio float64 `yaml:"sampleratio,omitempty"`
+	// MaxBytesPerSecond is the maximum rate at which blobs are read from the storage backend. Defaults to 10 MiB/s.
+	MaxBytesPerSecond int64 `yaml:"maxbytespersecond,omitempty"`
+}
+
+// Admin configures the administrative APIs, served on separate listeners from the main HTTP API.
+type Admin struct {
+	// GRPC configures the gRPC admin API. Requires the metadata database to be enabled.
+	GRPC AdminGRPC `yaml:"grpc,omitempty"`
+}
+
+// AdminGRPC configures the gRPC admin API.
+type AdminGRPC struct {
+	// Addr specifies the bind address for the gRPC admin server. The server is disabled if empty.
+	Addr string `yaml:"addr,omitempty"`
+	// Token is the bearer token that clients must provide in the authorization metadata of each call. Either a token
+	// or TLS.ClientCAs must be set.
+	Token string `yaml:"token,omitempty"`
+	// TLS configures TLS for the gRPC admin server. Connections are not encrypted if no certificate is set.
+	TLS struct {
+		// Certificate specifies the path to an x509 certificate file to be used for TLS.
+		Certificate string `yaml:"certificate,omitempty"`
+		// Key specifies the path to the x509 key file, which should contain the private portion for the file
+		// specified in Certificate.
+		Key string `yaml:"key,omitempty"`
+		// ClientCAs specifies the CA certs used to verify client certificates. If set, clients must present a
+		// certificate signed by one of them. A file may contain multiple CA certificates encoded as PEM.
+		ClientCAs []string `yaml:"clientcas,omitempty"`
+	} `yaml:"tls,omitempty"`
+}
+
+// DebugAuth configures the authentication of requests to the pprof and expvar endpoints of the debug server. If both
+// basic auth credentials and client certificate authentication are configured, either grants access. Requests are not
+// authenticated if neither is configured, in which case the debug server must only be reachable from trusted networks.
+type DebugAuth struct {
+	// Username is the basic auth username that clients must provide. Basic auth is disabled if empty.
+	Username string `yaml:"username,omitempty"`
+	// Password is the basic auth password that clients must provide. Required if Username is set.
+	Password string `yaml:"password,omitempty"`
+	// ClientCertificate requires clients to present a certificate signed by one of the CAs in TLS.ClientCAs.
+	ClientCertificate bool `yaml:"clientcertificate,omitempty"`
+}
+
+// DebugTLS configures TLS for the debug server.
+type DebugTLS struct {
+	// Certificate specifies the path to an x509 certificate file to be used for TLS.
+	Certificate string `yaml:"certificate,omitempty"`
+	// Key specifies the path to the x509 key file, which should contain the private portion for the file specified in
+	// Certificate.
+	Key string `yaml:"key,omitempty"`
+	// ClientCAs specifies the CA certs used to verify client certificates. A file may contain multiple CA certificates
+	// encoded as PEM. Client certificates are optional at the TLS level, so that health checks keep working without
+	// one, and only required by the endpoints protected by Auth.ClientCertificate.
+	ClientCAs []string `yaml:"clientcas,omitempty"`
+}
+
+// Runtime tunes the Go runtime at startup.
+type Runtime struct {
+	// MaxProcs sets the maximum number of CPUs that can execute simultaneously, overriding the GOMAXPROCS environment
+	// variable. Left to the Go default if 0.
+	MaxProcs int `yaml:"maxprocs,omitempty"`
+	// GCPercent sets the garbage collection target percentage, overriding the GOGC environment variable. A negative
+	// value disables the garbage collector. Left to the Go default if 0.
+	GCPercent int `yaml:"gcpercent,omitempty"`
+}
+
 // Reporting defines error reporting methods.
 type Reporting struct {
 	// Sentry configures error reporting for Sentry (sentry.io).
@@ -948,6 +1531,17 @@ type Proxy struct {
 
 	// Password of the hub user
 	Password string `yaml:"password"`
+
+	// OAuth2 exchanges the username and password for a refresh token using the OAuth2 password grant, which is then
+	// used to obtain bearer tokens for each repository scope.
+	OAuth2 bool `yaml:"oauth2,omitempty"`
+
+	// RefreshToken is an OAuth2 refresh (identity) token used to obtain bearer tokens for each repository scope
+	// instead of the username and password.
+	RefreshToken string `yaml:"refreshtoken,omitempty"`
+
+	// ClientID is the OAuth2 client ID sent to the upstream token server.
+	ClientID string `yaml:"clientid,omitempty"`
 }
 
 type parseOpts struct {
@@ -1001,6 +1595,11 @@ func Parse(rd io.Reader, opts ...ParseOption) (*Configuration, error) {
 							return nil, errors.New("no storage configuration provided")
 						}
 					}
+					// an empty password would be accepted, and a password without a username would silently disable
+					// basic auth of the debug server
+					if (v0_1.HTTP.Debug.Auth.Username == "") != (v0_1.HTTP.Debug.Auth.Password == "") {
+						return nil, errors.New("http.debug.auth requires both a username and a password")
+					}
 					return (*Configuration)(v0_1), nil
 				}
 				return nil, fmt.Errorf("expected *v0_1Configuration, received %#v", c)
@@ -98,26 +98,33 @@ var configStruct = Configuration{
 		},
 	},
 	HTTP: struct {
-		Addr         string        `yaml:"addr,omitempty"`
-		Net          string        `yaml:"net,omitempty"`
-		Host         string        `yaml:"host,omitempty"`
-		Prefix       string        `yaml:"prefix,omitempty"`
-		Secret       string        `yaml:"secret,omitempty"`
-		RelativeURLs bool          `yaml:"relativeurls,omitempty"`
-		DrainTimeout time.Duration `yaml:"draintimeout,omitempty"`
-		TLS          struct {
-			Certificate string   `yaml:"certificate,omitempty"`
-			Key         string   `yaml:"key,omitempty"`
-			ClientCAs   []string `yaml:"clientcas,omitempty"`
-			MinimumTLS  string   `yaml:"minimumtls,omitempty"`
-			LetsEncrypt struct {
+		Addr               string        `yaml:"addr,omitempty"`
+		Net                string        `yaml:"net,omitempty"`
+		Host               string        `yaml:"host,omitempty"`
+		Prefix             string        `yaml:"prefix,omitempty"`
+		Secret             string        `yaml:"secret,omitempty"`
+		RelativeURLs       bool          `yaml:"relativeurls,omitempty"`
+		DrainTimeout       time.Duration `yaml:"draintimeout,omitempty"`
+		MaxRequestDuration time.Duration `yaml:"maxrequestduration,omitempty"`
+		TLS                struct {
+			Certificate      string   `yaml:"certificate,omitempty"`
+			Key              string   `yaml:"key,omitempty"`
+			ClientCAs        []string `yaml:"clientcas,omitempty"`
+			MinimumTLS       string   `yaml:"minimumtls,omitempty"`
+			CipherSuites     []string `yaml:"ciphersuites,omitempty"`
+			CurvePreferences []string `yaml:"curvepreferences,omitempty"`
+			LetsEncrypt      struct {
 				CacheFile string   `yaml:"cachefile,omitempty"`
 				Email     string   `yaml:"email,omitempty"`
 				Hosts     []string `yaml:"hosts,omitempty"`
 			} `yaml:"letsencrypt,omitempty"`
 		} `yaml:"tls,omitempty"`
-		Headers http.Header `yaml:"headers,omitempty"`
-		Debug   struct {
+		Headers     http.Header `yaml:"headers,omitempty"`
+		CORS        CORS        `yaml:"cors,omitempty"`
+		Clients     Clients     `yaml:"clients,omitempty"`
+		Limits      Limits      `yaml:"limits,omitempty"`
+		Compression Compression `yaml:"compression,omitempty"`
+		Debug       struct {
 			Addr       string `yaml:"addr,omitempty"`
 			Prometheus struct {
 				Enabled bool   `yaml:"enabled,omitempty"`
@@ -126,17 +133,24 @@ var configStruct = Configuration{
 			Pprof struct {
 				Enabled bool `yaml:"enabled,omitempty"`
 			} `yaml:"pprof,omitempty"`
+			Expvar struct {
+				Enabled bool `yaml:"enabled,omitempty"`
+			} `yaml:"expvar,omitempty"`
+			Auth DebugAuth `yaml:"auth,omitempty"`
+			TLS  DebugTLS  `yaml:"tls,omitempty"`
 		} `yaml:"debug,omitempty"`
 		HTTP2 struct {
 			Disabled bool `yaml:"disabled,omitempty"`
 		} `yaml:"http2,omitempty"`
 	}{
 		TLS: struct {
-			Certificate string   `yaml:"certificate,omitempty"`
-			Key         string   `yaml:"key,omitempty"`
-			ClientCAs   []string `yaml:"clientcas,omitempty"`
-			MinimumTLS  string   `yaml:"minimumtls,omitempty"`
-			LetsEncrypt struct {
+			Certificate      string   `yaml:"certificate,omitempty"`
+			Key              string   `yaml:"key,omitempty"`
+			ClientCAs        []string `yaml:"clientcas,omitempty"`
+			MinimumTLS       string   `yaml:"minimumtls,omitempty"`
+			CipherSuites     []string `yaml:"ciphersuites,omitempty"`
+			CurvePreferences []string `yaml:"curvepreferences,omitempty"`
+			LetsEncrypt      struct {
 				CacheFile string   `yaml:"cachefile,omitempty"`
 				Email     string   `yaml:"email,omitempty"`
 				Hosts     []string `yaml:"hosts,omitempty"`
@@ -1103,6 +1117,236 @@ redis:
 	testParameter(t, yml, "REGISTRY_REDIS_POOL_IDLETIMEOUT", tt, validator)
 }
 
+func TestParseRedisCircuitBreaker_Disabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  circuitbreaker:
+    disabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Redis.CircuitBreaker.Disabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CIRCUITBREAKER_DISABLED", tt, validator)
+}
+
+func TestParseRedisCircuitBreaker_Threshold(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  circuitbreaker:
+    threshold: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10",
+			want:  10,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.CircuitBreaker.Threshold)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CIRCUITBREAKER_THRESHOLD", tt, validator)
+}
+
+func TestParseRedisCircuitBreaker_Cooldown(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  circuitbreaker:
+    cooldown: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1m",
+			want:  time.Minute,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.CircuitBreaker.Cooldown)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CIRCUITBREAKER_COOLDOWN", tt, validator)
+}
+
+func TestParseRedisUploadState_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  uploadstate:
+    enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Redis.UploadState.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_UPLOADSTATE_ENABLED", tt, validator)
+}
+
+func TestParseRedisUploadState_TTL(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  uploadstate:
+    ttl: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "12h",
+			want:  12 * time.Hour,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.UploadState.TTL)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_UPLOADSTATE_TTL", tt, validator)
+}
+
+func TestParseRedisTagLocks_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  taglocks:
+    enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Redis.TagLocks.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_TAGLOCKS_ENABLED", tt, validator)
+}
+
+func TestParseRedisTagLocks_TTL(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  taglocks:
+    ttl: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1m",
+			want:  time.Minute,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.TagLocks.TTL)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_TAGLOCKS_TTL", tt, validator)
+}
+
+func TestParseRedisTagLocks_Timeout(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  taglocks:
+    timeout: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "5s",
+			want:  5 * time.Second,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.TagLocks.Timeout)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_TAGLOCKS_TIMEOUT", tt, validator)
+}
+
+func TestParseRedisManifestCache_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  manifestcache:
+    enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Redis.ManifestCache.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_MANIFESTCACHE_ENABLED", tt, validator)
+}
+
+func TestParseRedisManifestCache_TTL(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  manifestcache:
+    ttl: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1h",
+			want:  time.Hour,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.ManifestCache.TTL)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_MANIFESTCACHE_TTL", tt, validator)
+}
+
 func TestDatabase_SSLMode(t *testing.T) {
 	yml := `
 version: 0.1
@@ -1249,6 +1493,32 @@ database:
 	testParameter(t, yml, "REGISTRY_DATABASE_DRAINTIMEOUT", tt, validator)
 }
 
+func TestParseHTTP_MaxRequestDuration(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  maxrequestduration: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1h",
+			want:  time.Hour,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.HTTP.MaxRequestDuration)
+	}
+
+	testParameter(t, yml, "REGISTRY_HTTP_MAXREQUESTDURATION", tt, validator)
+}
+
 func TestParseDatabasePool_MaxIdle(t *testing.T) {
 	yml := `
 version: 0.1
@@ -1330,198 +1600,2532 @@ database:
 	testParameter(t, yml, "REGISTRY_DATABASE_POOL_MAXLIFETIME", tt, validator)
 }
 
-func TestParseReportingSentry_Enabled(t *testing.T) {
+func TestParseDatabaseRetry_MaxRetries(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
-reporting:
-  sentry:
-    enabled: %s
+database:
+  retry:
+    maxretries: %s
 `
-	tt := boolParameterTests(false)
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "3",
+			want:  3,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, strconv.FormatBool(got.Reporting.Sentry.Enabled))
+		require.Equal(t, want, got.Database.Retry.MaxRetries)
 	}
 
-	testParameter(t, yml, "REGISTRY_REPORTING_SENTRY_ENABLED", tt, validator)
+	testParameter(t, yml, "REGISTRY_DATABASE_RETRY_MAXRETRIES", tt, validator)
 }
 
-func TestParseReportingSentry_DSN(t *testing.T) {
+func TestParseDatabaseRetry_InitialInterval(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
-reporting:
-  sentry:
-    dsn: %s
+database:
+  retry:
+    initialinterval: %s
 `
 	tt := []parameterTest{
 		{
 			name:  "sample",
-			value: "https://examplePublicKey@o0.ingest.sentry.io/0",
-			want:  "https://examplePublicKey@o0.ingest.sentry.io/0",
+			value: "50ms",
+			want:  50 * time.Millisecond,
 		},
 		{
 			name: "default",
-			want: "",
+			want: time.Duration(0),
 		},
 	}
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, got.Reporting.Sentry.DSN)
+		require.Equal(t, want, got.Database.Retry.InitialInterval)
 	}
 
-	testParameter(t, yml, "REGISTRY_REPORTING_SENTRY_DSN", tt, validator)
+	testParameter(t, yml, "REGISTRY_DATABASE_RETRY_INITIALINTERVAL", tt, validator)
 }
 
-func TestParseReportingSentry_Environment(t *testing.T) {
+func TestParseDatabaseRetry_MaxInterval(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
-reporting:
-  sentry:
-    environment: %s
+database:
+  retry:
+    maxinterval: %s
 `
 	tt := []parameterTest{
 		{
 			name:  "sample",
-			value: "development",
-			want:  "development",
+			value: "5s",
+			want:  5 * time.Second,
 		},
 		{
 			name: "default",
-			want: "",
+			want: time.Duration(0),
 		},
 	}
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, got.Reporting.Sentry.Environment)
+		require.Equal(t, want, got.Database.Retry.MaxInterval)
 	}
 
-	testParameter(t, yml, "REGISTRY_REPORTING_SENTRY_ENVIRONMENT", tt, validator)
+	testParameter(t, yml, "REGISTRY_DATABASE_RETRY_MAXINTERVAL", tt, validator)
 }
 
-func TestParseMigration_Enabled(t *testing.T) {
+func TestParseDatabaseManifestCache_Enabled(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
-migration:
-  enabled: %s
+database:
+  manifestcache:
+    enabled: %s
 `
 	tt := boolParameterTests(false)
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, strconv.FormatBool(got.Migration.Enabled))
+		require.Equal(t, want, strconv.FormatBool(got.Database.ManifestCache.Enabled))
 	}
 
-	testParameter(t, yml, "REGISTRY_MIGRATION_ENABLED", tt, validator)
+	testParameter(t, yml, "REGISTRY_DATABASE_MANIFESTCACHE_ENABLED", tt, validator)
 }
 
-func TestParseMigration_RootDirectory(t *testing.T) {
+func TestParseDatabaseManifestCache_MaxSize(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
-migration:
-  rootdirectory: %s
+database:
+  manifestcache:
+    maxsize: %s
 `
-
 	tt := []parameterTest{
 		{
-			name:  "empty",
-			value: "",
-			want:  "",
+			name:  "sample",
+			value: "1048576",
+			want:  int64(1048576),
 		},
 		{
-			name:  "custom",
-			value: "migration/root",
-			want:  "migration/root",
+			name: "default",
+			want: int64(0),
 		},
 	}
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, got.Migration.RootDirectory)
+		require.Equal(t, want, got.Database.ManifestCache.MaxSize)
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_MANIFESTCACHE_MAXSIZE", tt, validator)
+}
+
+func TestParseDatabaseManifestCache_TTL(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  manifestcache:
+    ttl: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10m",
+			want:  10 * time.Minute,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Database.ManifestCache.TTL)
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_MANIFESTCACHE_TTL", tt, validator)
+}
+
+func TestParseDatabaseRepositoryCache_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  repositorycache:
+    enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Database.RepositoryCache.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_REPOSITORYCACHE_ENABLED", tt, validator)
+}
+
+func TestParseDatabaseRepositoryCache_MaxEntries(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  repositorycache:
+    maxentries: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "500",
+			want:  500,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Database.RepositoryCache.MaxEntries)
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_REPOSITORYCACHE_MAXENTRIES", tt, validator)
+}
+
+func TestParseDatabaseRepositoryCache_TTL(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  repositorycache:
+    ttl: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1m",
+			want:  time.Minute,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Database.RepositoryCache.TTL)
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_REPOSITORYCACHE_TTL", tt, validator)
+}
+
+func TestParseDatabaseMirror_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  mirror:
+    enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Database.Mirror.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_MIRROR_ENABLED", tt, validator)
+}
+
+func TestParseHealthDatabase_Timeout(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+health:
+  database:
+    timeout: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "5s",
+			want:  5 * time.Second,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Health.Database.Timeout)
+	}
+
+	testParameter(t, yml, "REGISTRY_HEALTH_DATABASE_TIMEOUT", tt, validator)
+}
+
+func TestParseHealthRedis_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+health:
+  redis:
+    enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Health.Redis.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_HEALTH_REDIS_ENABLED", tt, validator)
+}
+
+func TestParseHealthRedis_Threshold(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+health:
+  redis:
+    threshold: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "3",
+			want:  3,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Health.Redis.Threshold)
+	}
+
+	testParameter(t, yml, "REGISTRY_HEALTH_REDIS_THRESHOLD", tt, validator)
+}
+
+func TestParseHealthRedis_Timeout(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+health:
+  redis:
+    timeout: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "5s",
+			want:  5 * time.Second,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Health.Redis.Timeout)
+	}
+
+	testParameter(t, yml, "REGISTRY_HEALTH_REDIS_TIMEOUT", tt, validator)
+}
+
+func TestParseHealthDatabase_MaxReplicationLag(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+health:
+  database:
+    maxreplicationlag: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "30s",
+			want:  30 * time.Second,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Health.Database.MaxReplicationLag)
+	}
+
+	testParameter(t, yml, "REGISTRY_HEALTH_DATABASE_MAXREPLICATIONLAG", tt, validator)
+}
+
+func TestParseReportingSentry_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+reporting:
+  sentry:
+    enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Reporting.Sentry.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_REPORTING_SENTRY_ENABLED", tt, validator)
+}
+
+func TestParseReportingSentry_DSN(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+reporting:
+  sentry:
+    dsn: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "https://examplePublicKey@o0.ingest.sentry.io/0",
+			want:  "https://examplePublicKey@o0.ingest.sentry.io/0",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Reporting.Sentry.DSN)
+	}
+
+	testParameter(t, yml, "REGISTRY_REPORTING_SENTRY_DSN", tt, validator)
+}
+
+func TestParseReportingSentry_Environment(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+reporting:
+  sentry:
+    environment: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "development",
+			want:  "development",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Reporting.Sentry.Environment)
+	}
+
+	testParameter(t, yml, "REGISTRY_REPORTING_SENTRY_ENVIRONMENT", tt, validator)
+}
+
+func TestParseMigration_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+migration:
+  enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Migration.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_MIGRATION_ENABLED", tt, validator)
+}
+
+func TestParseMigration_RootDirectory(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+migration:
+  rootdirectory: %s
+`
+
+	tt := []parameterTest{
+		{
+			name:  "empty",
+			value: "",
+			want:  "",
+		},
+		{
+			name:  "custom",
+			value: "migration/root",
+			want:  "migration/root",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Migration.RootDirectory)
 	}
 
 	testParameter(t, yml, "REGISTRY_MIGRATION_ROOTDIRECTORY", tt, validator)
 }
 
-func checkStructs(c *C, t reflect.Type, structsChecked map[string]struct{}) {
-	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Map || t.Kind() == reflect.Slice {
-		t = t.Elem()
+func TestParseMigration_BackfillEnabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+migration:
+  backfill:
+    enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Migration.Backfill.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_MIGRATION_BACKFILL_ENABLED", tt, validator)
+}
+
+func TestParseMigration_BackfillMaxConcurrency(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+migration:
+  backfill:
+    maxconcurrency: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10",
+			want:  10,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Migration.Backfill.MaxConcurrency)
+	}
+
+	testParameter(t, yml, "REGISTRY_MIGRATION_BACKFILL_MAXCONCURRENCY", tt, validator)
+}
+
+func TestParseValidationRepositories_MaxPathComponents(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  repositories:
+    maxpathcomponents: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "3",
+			want:  3,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Validation.Repositories.MaxPathComponents)
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_REPOSITORIES_MAXPATHCOMPONENTS", tt, validator)
+}
+
+func TestParseValidationRepositories_MaxLength(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  repositories:
+    maxlength: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "255",
+			want:  255,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Validation.Repositories.MaxLength)
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_REPOSITORIES_MAXLENGTH", tt, validator)
+}
+
+func TestParseValidationRepositories_AllowedPattern(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  repositories:
+    allowedpattern: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "gitlab-org/.+",
+			want:  "gitlab-org/.+",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Validation.Repositories.AllowedPattern)
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_REPOSITORIES_ALLOWEDPATTERN", tt, validator)
+}
+
+func TestParseValidationManifests_AllowedMediaTypes(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  manifests:
+    allowedmediatypes: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "[application/vnd.oci.image.manifest.v1+json, application/vnd.oci.image.config.v1+json]",
+			want:  []string{"application/vnd.oci.image.manifest.v1+json", "application/vnd.oci.image.config.v1+json"},
+		},
+		{
+			name: "default",
+			want: []string(nil),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Validation.Manifests.AllowedMediaTypes)
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_MANIFESTS_ALLOWEDMEDIATYPES", tt, validator)
+}
+
+func TestParseValidationManifests_PayloadSizeLimit(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  manifests:
+    payloadsizelimit: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1048576",
+			want:  int64(1048576),
+		},
+		{
+			name: "default",
+			want: int64(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Validation.Manifests.PayloadSizeLimit)
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_MANIFESTS_PAYLOADSIZELIMIT", tt, validator)
+}
+
+func TestParseValidationManifests_Strict(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  manifests:
+    strict: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Validation.Manifests.Strict))
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_MANIFESTS_STRICT", tt, validator)
+}
+
+func TestParseValidationManifests_VerifyPlatforms(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  manifests:
+    verifyplatforms: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Validation.Manifests.VerifyPlatforms))
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_MANIFESTS_VERIFYPLATFORMS", tt, validator)
+}
+
+func TestParseValidationManifestsAsync_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  manifests:
+    async:
+      enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Validation.Manifests.Async.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_MANIFESTS_ASYNC_ENABLED", tt, validator)
+}
+
+func TestParseValidationManifestsAsync_TrustedSubjects(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  manifests:
+    async:
+      trustedsubjects: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "[ci-builder, mirror-bot]",
+			want:  []string{"ci-builder", "mirror-bot"},
+		},
+		{
+			name: "default",
+			want: []string(nil),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Validation.Manifests.Async.TrustedSubjects)
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_MANIFESTS_ASYNC_TRUSTEDSUBJECTS", tt, validator)
+}
+
+func TestParseValidationManifestsAsync_Concurrency(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  manifests:
+    async:
+      concurrency: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10",
+			want:  10,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Validation.Manifests.Async.Concurrency)
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_MANIFESTS_ASYNC_CONCURRENCY", tt, validator)
+}
+
+func TestParseValidationManifestsAsync_QueueSize(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  manifests:
+    async:
+      queuesize: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "50",
+			want:  50,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Validation.Manifests.Async.QueueSize)
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_MANIFESTS_ASYNC_QUEUESIZE", tt, validator)
+}
+
+func TestParseValidationManifestsAsync_Timeout(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  manifests:
+    async:
+      timeout: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "30s",
+			want:  30 * time.Second,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Validation.Manifests.Async.Timeout)
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_MANIFESTS_ASYNC_TIMEOUT", tt, validator)
+}
+
+func TestParseValidationUploads_StrictChunkOrdering(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  uploads:
+    strictchunkordering: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "true",
+			value: "true",
+			want:  true,
+		},
+		{
+			name:  "false",
+			value: "false",
+			want:  false,
+		},
+		{
+			name: "default",
+			want: false,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Validation.Uploads.StrictChunkOrdering)
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_UPLOADS_STRICTCHUNKORDERING", tt, validator)
+}
+
+func TestParseValidationUploads_ParallelChunks(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  uploads:
+    parallelchunks: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "true",
+			value: "true",
+			want:  true,
+		},
+		{
+			name:  "false",
+			value: "false",
+			want:  false,
+		},
+		{
+			name: "default",
+			want: false,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Validation.Uploads.ParallelChunks)
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_UPLOADS_PARALLELCHUNKS", tt, validator)
+}
+
+func TestParseValidationUploads_MinChunkLength(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  uploads:
+    minchunklength: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "5242880",
+			want:  int64(5242880),
+		},
+		{
+			name: "default",
+			want: int64(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Validation.Uploads.MinChunkLength)
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_UPLOADS_MINCHUNKLENGTH", tt, validator)
+}
+
+func TestParseAudit_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+audit:
+  enabled: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "true",
+			value: "true",
+			want:  true,
+		},
+		{
+			name:  "false",
+			value: "false",
+			want:  false,
+		},
+		{
+			name: "default",
+			want: false,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Audit.Enabled)
+	}
+
+	testParameter(t, yml, "REGISTRY_AUDIT_ENABLED", tt, validator)
+}
+
+func TestParseAudit_File(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+audit:
+  file: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "/var/log/registry/audit.log",
+			want:  "/var/log/registry/audit.log",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Audit.File)
+	}
+
+	testParameter(t, yml, "REGISTRY_AUDIT_FILE", tt, validator)
+}
+
+func TestParseAudit_HTTPURL(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+audit:
+  http:
+    url: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "https://audit.example.com/events",
+			want:  "https://audit.example.com/events",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Audit.HTTP.URL)
+	}
+
+	testParameter(t, yml, "REGISTRY_AUDIT_HTTP_URL", tt, validator)
+}
+
+func TestParseAudit_Database(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+audit:
+  database: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Audit.Database))
+	}
+
+	testParameter(t, yml, "REGISTRY_AUDIT_DATABASE", tt, validator)
+}
+
+func TestParseReplication_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+replication:
+  enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Replication.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_REPLICATION_ENABLED", tt, validator)
+}
+
+func TestParseReplication_RemoteURL(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+replication:
+  remoteurl: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "https://registry.secondary.example.com",
+			want:  "https://registry.secondary.example.com",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Replication.RemoteURL)
+	}
+
+	testParameter(t, yml, "REGISTRY_REPLICATION_REMOTEURL", tt, validator)
+}
+
+func TestParseReplication_Username(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+replication:
+  username: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "replicator",
+			want:  "replicator",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Replication.Username)
+	}
+
+	testParameter(t, yml, "REGISTRY_REPLICATION_USERNAME", tt, validator)
+}
+
+func TestParseReplication_Password(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+replication:
+  password: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "secret",
+			want:  "secret",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Replication.Password)
+	}
+
+	testParameter(t, yml, "REGISTRY_REPLICATION_PASSWORD", tt, validator)
+}
+
+func TestParseReplication_Interval(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+replication:
+  interval: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10s",
+			want:  10 * time.Second,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Replication.Interval)
+	}
+
+	testParameter(t, yml, "REGISTRY_REPLICATION_INTERVAL", tt, validator)
+}
+
+func TestParseReplication_Timeout(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+replication:
+  timeout: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "30m",
+			want:  30 * time.Minute,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Replication.Timeout)
+	}
+
+	testParameter(t, yml, "REGISTRY_REPLICATION_TIMEOUT", tt, validator)
+}
+
+func TestParseReplication_MaxBackoff(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+replication:
+  maxbackoff: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1h",
+			want:  time.Hour,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Replication.MaxBackoff)
+	}
+
+	testParameter(t, yml, "REGISTRY_REPLICATION_MAXBACKOFF", tt, validator)
+}
+
+func TestParseDataMover_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+datamover:
+  enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.DataMover.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_DATAMOVER_ENABLED", tt, validator)
+}
+
+func TestParseDataMover_Storage(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+datamover:
+  storage:
+    filesystem:
+      rootdirectory: /var/lib/registry-target
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+	require.Equal(t, "filesystem", config.DataMover.Storage.Type())
+	require.Equal(t, Parameters{"rootdirectory": "/var/lib/registry-target"}, config.DataMover.Storage.Parameters())
+}
+
+func TestParseDataMover_Interval(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+datamover:
+  interval: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10s",
+			want:  10 * time.Second,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.DataMover.Interval)
+	}
+
+	testParameter(t, yml, "REGISTRY_DATAMOVER_INTERVAL", tt, validator)
+}
+
+func TestParseDataMover_Timeout(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+datamover:
+  timeout: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "2h",
+			want:  2 * time.Hour,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.DataMover.Timeout)
+	}
+
+	testParameter(t, yml, "REGISTRY_DATAMOVER_TIMEOUT", tt, validator)
+}
+
+func TestParseDataMover_MaxBackoff(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+datamover:
+  maxbackoff: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1h",
+			want:  time.Hour,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.DataMover.MaxBackoff)
+	}
+
+	testParameter(t, yml, "REGISTRY_DATAMOVER_MAXBACKOFF", tt, validator)
+}
+
+func TestParseRetention_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+retention:
+  enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Retention.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_RETENTION_ENABLED", tt, validator)
+}
+
+func TestParseRetention_Period(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+retention:
+  period: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "12h",
+			want:  12 * time.Hour,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Retention.Period)
+	}
+
+	testParameter(t, yml, "REGISTRY_RETENTION_PERIOD", tt, validator)
+}
+
+func TestParseRetention_Timeout(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+retention:
+  timeout: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "30m",
+			want:  30 * time.Minute,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Retention.Timeout)
+	}
+
+	testParameter(t, yml, "REGISTRY_RETENTION_TIMEOUT", tt, validator)
+}
+
+func TestParseScrubber_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+scrubber:
+  enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Scrubber.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_SCRUBBER_ENABLED", tt, validator)
+}
+
+func TestParseScrubber_SampleRatio(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+scrubber:
+  sampleratio: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "0.25",
+			want:  0.25,
+		},
+		{
+			name: "default",
+			want: float64(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Scrubber.SampleRatio)
+	}
+
+	testParameter(t, yml, "REGISTRY_SCRUBBER_SAMPLERATIO", tt, validator)
+}
+
+func TestParseScrubber_MaxBytesPerSecond(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+scrubber:
+  maxbytespersecond: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1048576",
+			want:  int64(1048576),
+		},
+		{
+			name: "default",
+			want: int64(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Scrubber.MaxBytesPerSecond)
+	}
+
+	testParameter(t, yml, "REGISTRY_SCRUBBER_MAXBYTESPERSECOND", tt, validator)
+}
+
+func TestParseAdminGRPC_Addr(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+admin:
+  grpc:
+    addr: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "localhost:5002",
+			want:  "localhost:5002",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Admin.GRPC.Addr)
+	}
+
+	testParameter(t, yml, "REGISTRY_ADMIN_GRPC_ADDR", tt, validator)
+}
+
+func TestParseAdminGRPC_Token(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+admin:
+  grpc:
+    token: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "secret",
+			want:  "secret",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Admin.GRPC.Token)
+	}
+
+	testParameter(t, yml, "REGISTRY_ADMIN_GRPC_TOKEN", tt, validator)
+}
+
+func TestParseHTTPDebug_Expvar(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  debug:
+    expvar:
+      enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.HTTP.Debug.Expvar.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_HTTP_DEBUG_EXPVAR_ENABLED", tt, validator)
+}
+
+func TestParseHTTPDebugAuth_Username(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  debug:
+    auth:
+      username: %s
+      password: secret
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "admin",
+			want:  "admin",
+		},
+		{
+			name:    "default",
+			want:    "",
+			wantErr: true,
+			err:     "http.debug.auth requires both a username and a password",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.HTTP.Debug.Auth.Username)
+	}
+
+	testParameter(t, yml, "REGISTRY_HTTP_DEBUG_AUTH_USERNAME", tt, validator)
+}
+
+func TestParseHTTPDebugAuth_Incomplete(t *testing.T) {
+	tcs := map[string]string{
+		"username without password": "username: admin",
+		"password without username": "password: secret",
+	}
+
+	for name, auth := range tcs {
+		t.Run(name, func(t *testing.T) {
+			yml := fmt.Sprintf(`
+version: 0.1
+storage: inmemory
+http:
+  debug:
+    auth:
+      %s
+`, auth)
+
+			_, err := Parse(bytes.NewReader([]byte(yml)))
+			require.EqualError(t, err, "http.debug.auth requires both a username and a password")
+		})
+	}
+}
+
+func TestParseRuntime_MaxProcs(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+runtime:
+  maxprocs: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "4",
+			want:  4,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Runtime.MaxProcs)
+	}
+
+	testParameter(t, yml, "REGISTRY_RUNTIME_MAXPROCS", tt, validator)
+}
+
+func TestParseRuntime_GCPercent(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+runtime:
+  gcpercent: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "200",
+			want:  200,
+		},
+		{
+			name:  "disabled",
+			value: "-1",
+			want:  -1,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Runtime.GCPercent)
+	}
+
+	testParameter(t, yml, "REGISTRY_RUNTIME_GCPERCENT", tt, validator)
+}
+
+func TestParseTracing_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+tracing:
+  enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Tracing.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_TRACING_ENABLED", tt, validator)
+}
+
+func TestParseTracing_Endpoint(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+tracing:
+  endpoint: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "otel-collector:4317",
+			want:  "otel-collector:4317",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Tracing.Endpoint)
+	}
+
+	testParameter(t, yml, "REGISTRY_TRACING_ENDPOINT", tt, validator)
+}
+
+func TestParseTracing_Insecure(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+tracing:
+  insecure: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Tracing.Insecure))
+	}
+
+	testParameter(t, yml, "REGISTRY_TRACING_INSECURE", tt, validator)
+}
+
+func TestParseTracing_ServiceName(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+tracing:
+  servicename: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "registry-us-east",
+			want:  "registry-us-east",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Tracing.ServiceName)
+	}
+
+	testParameter(t, yml, "REGISTRY_TRACING_SERVICENAME", tt, validator)
+}
+
+func TestParseTracing_SamplingRatio(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+tracing:
+  samplingratio: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "0.25",
+			want:  0.25,
+		},
+		{
+			name: "default",
+			want: float64(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Tracing.SamplingRatio)
+	}
+
+	testParameter(t, yml, "REGISTRY_TRACING_SAMPLINGRATIO", tt, validator)
+}
+
+func TestParseNotificationsGCEvents_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+notifications:
+  gcevents:
+    enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Notifications.GCEvents.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_NOTIFICATIONS_GCEVENTS_ENABLED", tt, validator)
+}
+
+func TestParseNotificationsGCEvents_MaxBatchSize(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+notifications:
+  gcevents:
+    maxbatchsize: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "500",
+			want:  500,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Notifications.GCEvents.MaxBatchSize)
+	}
+
+	testParameter(t, yml, "REGISTRY_NOTIFICATIONS_GCEVENTS_MAXBATCHSIZE", tt, validator)
+}
+
+func TestParseNotificationsGCEvents_FlushInterval(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+notifications:
+  gcevents:
+    flushinterval: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "5s",
+			want:  5 * time.Second,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Notifications.GCEvents.FlushInterval)
+	}
+
+	testParameter(t, yml, "REGISTRY_NOTIFICATIONS_GCEVENTS_FLUSHINTERVAL", tt, validator)
+}
+
+func TestParseNotificationsEndpoint_Delivery(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+notifications:
+  endpoints:
+    - name: endpoint-1
+      url: http://example.com
+      backoff: 1s
+      maxbackoff: 1m
+      maxretries: 5
+      queuesize: 1000
+      queuepolicy: dropnewest
+      deadletter:
+        file: /var/log/registry/events.log
+`
+	got, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+	require.Len(t, got.Notifications.Endpoints, 1)
+
+	e := got.Notifications.Endpoints[0]
+	require.Equal(t, time.Minute, e.MaxBackoff)
+	require.Equal(t, 5, e.MaxRetries)
+	require.Equal(t, 1000, e.QueueSize)
+	require.Equal(t, QueuePolicyDropNewest, e.QueuePolicy)
+	require.Equal(t, DeadLetter{File: "/var/log/registry/events.log"}, e.DeadLetter)
+}
+
+func TestParseNotificationsEndpoint_InvalidQueuePolicy(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+notifications:
+  endpoints:
+    - name: endpoint-1
+      url: http://example.com
+      queuepolicy: foo
+`
+	_, err := Parse(bytes.NewReader([]byte(yml)))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `invalid queue policy "foo"`)
+}
+
+func checkStructs(c *C, t reflect.Type, structsChecked map[string]struct{}) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Map || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	if _, present := structsChecked[t.String()]; present {
+		// Already checked this type
+		return
+	}
+
+	structsChecked[t.String()] = struct{}{}
+
+	byUpperCase := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		// Check that the yaml tag does not contain an _.
+		yamlTag := sf.Tag.Get("yaml")
+		if strings.Contains(yamlTag, "_") {
+			c.Fatalf("yaml field name includes _ character: %s", yamlTag)
+		}
+		upper := strings.ToUpper(sf.Name)
+		if _, present := byUpperCase[upper]; present {
+			c.Fatalf("field name collision in configuration object: %s", sf.Name)
+		}
+		byUpperCase[upper] = i
+
+		checkStructs(c, sf.Type, structsChecked)
+	}
+}
+
+// TestValidateConfigStruct makes sure that the config struct has no members
+// with yaml tags that would be ambiguous to the environment variable parser.
+func (suite *ConfigSuite) TestValidateConfigStruct(c *C) {
+	structsChecked := make(map[string]struct{})
+	checkStructs(c, reflect.TypeOf(Configuration{}), structsChecked)
+}
+
+func copyConfig(config Configuration) *Configuration {
+	configCopy := new(Configuration)
+
+	configCopy.Version = MajorMinorVersion(config.Version.Major(), config.Version.Minor())
+	configCopy.Loglevel = config.Loglevel
+	configCopy.Log = config.Log
+	configCopy.Log.Fields = make(map[string]interface{}, len(config.Log.Fields))
+	for k, v := range config.Log.Fields {
+		configCopy.Log.Fields[k] = v
+	}
+
+	configCopy.Storage = Storage{config.Storage.Type(): Parameters{}}
+	for k, v := range config.Storage.Parameters() {
+		configCopy.Storage.setParameter(k, v)
+	}
+
+	configCopy.Database = config.Database
+
+	configCopy.Reporting = Reporting{
+		Sentry: SentryReporting{config.Reporting.Sentry.Enabled, config.Reporting.Sentry.DSN, config.Reporting.Sentry.Environment},
+	}
+
+	configCopy.Auth = Auth{config.Auth.Type(): Parameters{}}
+	for k, v := range config.Auth.Parameters() {
+		configCopy.Auth.setParameter(k, v)
+	}
+
+	configCopy.Notifications = Notifications{Endpoints: []Endpoint{}, GCEvents: config.Notifications.GCEvents}
+	for _, v := range config.Notifications.Endpoints {
+		configCopy.Notifications.Endpoints = append(configCopy.Notifications.Endpoints, v)
+	}
+
+	configCopy.HTTP.Headers = make(http.Header)
+	for k, v := range config.HTTP.Headers {
+		configCopy.HTTP.Headers[k] = v
+	}
+
+	return configCopy
+}
+
+func TestParsePolicyRepository_MaxTags(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+policy:
+  repository:
+    maxtags: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1000",
+			want:  1000,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Policy.Repository.MaxTags)
+	}
+
+	testParameter(t, yml, "REGISTRY_POLICY_REPOSITORY_MAXTAGS", tt, validator)
+}
+
+func TestParsePolicyRepository_MaxManifests(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+policy:
+  repository:
+    maxmanifests: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "5000",
+			want:  5000,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Policy.Repository.MaxManifests)
+	}
+
+	testParameter(t, yml, "REGISTRY_POLICY_REPOSITORY_MAXMANIFESTS", tt, validator)
+}
+
+func TestParsePolicyRepository_DisableAutoCreate(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+policy:
+  repository:
+    disableautocreate: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "true",
+			value: "true",
+			want:  true,
+		},
+		{
+			name:  "false",
+			value: "false",
+			want:  false,
+		},
+		{
+			name: "default",
+			want: false,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Policy.Repository.DisableAutoCreate)
+	}
+
+	testParameter(t, yml, "REGISTRY_POLICY_REPOSITORY_DISABLEAUTOCREATE", tt, validator)
+}
+
+func TestParsePolicyUploads_MaxConcurrent(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+policy:
+  uploads:
+    maxconcurrent: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10000",
+			want:  10000,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Policy.Uploads.MaxConcurrent)
+	}
+
+	testParameter(t, yml, "REGISTRY_POLICY_UPLOADS_MAXCONCURRENT", tt, validator)
+}
+
+func TestParsePolicyUploads_MaxConcurrentPerRepository(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+policy:
+  uploads:
+    maxconcurrentperrepository: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "100",
+			want:  100,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Policy.Uploads.MaxConcurrentPerRepository)
+	}
+
+	testParameter(t, yml, "REGISTRY_POLICY_UPLOADS_MAXCONCURRENTPERREPOSITORY", tt, validator)
+}
+
+func TestParseHTTPTLS_CipherSuites(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  tls:
+    ciphersuites: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "[TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384]",
+			want:  []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+		},
+		{
+			name: "default",
+			want: []string(nil),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.HTTP.TLS.CipherSuites)
+	}
+
+	testParameter(t, yml, "REGISTRY_HTTP_TLS_CIPHERSUITES", tt, validator)
+}
+
+func TestParseHTTPTLS_CurvePreferences(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  tls:
+    curvepreferences: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "[x25519, p256]",
+			want:  []string{"x25519", "p256"},
+		},
+		{
+			name: "default",
+			want: []string(nil),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.HTTP.TLS.CurvePreferences)
+	}
+
+	testParameter(t, yml, "REGISTRY_HTTP_TLS_CURVEPREFERENCES", tt, validator)
+}
+
+func TestParseHTTPCORS_AllowedOrigins(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  cors:
+    allowedorigins: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "[https://a.example.com, https://b.example.com]",
+			want:  []string{"https://a.example.com", "https://b.example.com"},
+		},
+		{
+			name:  "any",
+			value: `["*"]`,
+			want:  []string{"*"},
+		},
+		{
+			name: "default",
+			want: []string(nil),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.HTTP.CORS.AllowedOrigins)
+	}
+
+	testParameter(t, yml, "REGISTRY_HTTP_CORS_ALLOWEDORIGINS", tt, validator)
+}
+
+func TestParseHTTPCORS_MaxAge(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  cors:
+    maxage: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10m",
+			want:  10 * time.Minute,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.HTTP.CORS.MaxAge)
+	}
+
+	testParameter(t, yml, "REGISTRY_HTTP_CORS_MAXAGE", tt, validator)
+}
+
+func TestParseHTTPCORS_AllowCredentials(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  cors:
+    allowcredentials: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.HTTP.CORS.AllowCredentials))
+	}
+
+	testParameter(t, yml, "REGISTRY_HTTP_CORS_ALLOWCREDENTIALS", tt, validator)
+}
+
+func TestParseHTTPClients_AllowedIPs(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  clients:
+    allowedips: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "[10.0.0.0/8, 192.168.1.10]",
+			want:  []string{"10.0.0.0/8", "192.168.1.10"},
+		},
+		{
+			name: "default",
+			want: []string(nil),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.HTTP.Clients.AllowedIPs)
+	}
+
+	testParameter(t, yml, "REGISTRY_HTTP_CLIENTS_ALLOWEDIPS", tt, validator)
+}
+
+func TestParseHTTPClients_DeniedIPs(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  clients:
+    deniedips: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "[10.1.0.0/16, 2001:db8::/32]",
+			want:  []string{"10.1.0.0/16", "2001:db8::/32"},
+		},
+		{
+			name: "default",
+			want: []string(nil),
+		},
 	}
 
-	if t.Kind() != reflect.Struct {
-		return
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.HTTP.Clients.DeniedIPs)
 	}
-	if _, present := structsChecked[t.String()]; present {
-		// Already checked this type
-		return
+
+	testParameter(t, yml, "REGISTRY_HTTP_CLIENTS_DENIEDIPS", tt, validator)
+}
+
+func TestParseHTTPClients_DeniedUserAgents(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  clients:
+    denieduseragents: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: `["^docker/1\\.[0-9]\\.", "^curl/"]`,
+			want:  []string{`^docker/1\.[0-9]\.`, "^curl/"},
+		},
+		{
+			name: "default",
+			want: []string(nil),
+		},
 	}
 
-	structsChecked[t.String()] = struct{}{}
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.HTTP.Clients.DeniedUserAgents)
+	}
 
-	byUpperCase := make(map[string]int)
-	for i := 0; i < t.NumField(); i++ {
-		sf := t.Field(i)
+	testParameter(t, yml, "REGISTRY_HTTP_CLIENTS_DENIEDUSERAGENTS", tt, validator)
+}
 
-		// Check that the yaml tag does not contain an _.
-		yamlTag := sf.Tag.Get("yaml")
-		if strings.Contains(yamlTag, "_") {
-			c.Fatalf("yaml field name includes _ character: %s", yamlTag)
-		}
-		upper := strings.ToUpper(sf.Name)
-		if _, present := byUpperCase[upper]; present {
-			c.Fatalf("field name collision in configuration object: %s", sf.Name)
-		}
-		byUpperCase[upper] = i
+func TestParseHTTPClients_TrustedProxies(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  clients:
+    trustedproxies: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "[10.0.0.0/8, 192.168.1.1]",
+			want:  []string{"10.0.0.0/8", "192.168.1.1"},
+		},
+		{
+			name: "default",
+			want: []string(nil),
+		},
+	}
 
-		checkStructs(c, sf.Type, structsChecked)
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.HTTP.Clients.TrustedProxies)
 	}
+
+	testParameter(t, yml, "REGISTRY_HTTP_CLIENTS_TRUSTEDPROXIES", tt, validator)
 }
 
-// TestValidateConfigStruct makes sure that the config struct has no members
-// with yaml tags that would be ambiguous to the environment variable parser.
-func (suite *ConfigSuite) TestValidateConfigStruct(c *C) {
-	structsChecked := make(map[string]struct{})
-	checkStructs(c, reflect.TypeOf(Configuration{}), structsChecked)
+func TestParseHTTPLimits_MaxBodySize(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  limits:
+    maxbodysize: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1048576",
+			want:  int64(1048576),
+		},
+		{
+			name: "default",
+			want: int64(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.HTTP.Limits.MaxBodySize)
+	}
+
+	testParameter(t, yml, "REGISTRY_HTTP_LIMITS_MAXBODYSIZE", tt, validator)
 }
 
-func copyConfig(config Configuration) *Configuration {
-	configCopy := new(Configuration)
+func TestParseHTTPLimits_MinUploadRate(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  limits:
+    minuploadrate: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10240",
+			want:  int64(10240),
+		},
+		{
+			name: "default",
+			want: int64(0),
+		},
+	}
 
-	configCopy.Version = MajorMinorVersion(config.Version.Major(), config.Version.Minor())
-	configCopy.Loglevel = config.Loglevel
-	configCopy.Log = config.Log
-	configCopy.Log.Fields = make(map[string]interface{}, len(config.Log.Fields))
-	for k, v := range config.Log.Fields {
-		configCopy.Log.Fields[k] = v
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.HTTP.Limits.MinUploadRate)
 	}
 
-	configCopy.Storage = Storage{config.Storage.Type(): Parameters{}}
-	for k, v := range config.Storage.Parameters() {
-		configCopy.Storage.setParameter(k, v)
+	testParameter(t, yml, "REGISTRY_HTTP_LIMITS_MINUPLOADRATE", tt, validator)
+}
+
+func TestParseHTTPLimits_MinUploadRatePeriod(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  limits:
+    minuploadrateperiod: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1m",
+			want:  time.Minute,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
 	}
 
-	configCopy.Database = config.Database
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.HTTP.Limits.MinUploadRatePeriod)
+	}
 
-	configCopy.Reporting = Reporting{
-		Sentry: SentryReporting{config.Reporting.Sentry.Enabled, config.Reporting.Sentry.DSN, config.Reporting.Sentry.Environment},
+	testParameter(t, yml, "REGISTRY_HTTP_LIMITS_MINUPLOADRATEPERIOD", tt, validator)
+}
+
+func TestParseHTTPCompression_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  compression:
+    enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.HTTP.Compression.Enabled))
 	}
 
-	configCopy.Auth = Auth{config.Auth.Type(): Parameters{}}
-	for k, v := range config.Auth.Parameters() {
-		configCopy.Auth.setParameter(k, v)
+	testParameter(t, yml, "REGISTRY_HTTP_COMPRESSION_ENABLED", tt, validator)
+}
+
+func TestParseHTTPCompression_MinSize(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  compression:
+    minsize: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "4096",
+			want:  4096,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
 	}
 
-	configCopy.Notifications = Notifications{Endpoints: []Endpoint{}}
-	for _, v := range config.Notifications.Endpoints {
-		configCopy.Notifications.Endpoints = append(configCopy.Notifications.Endpoints, v)
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.HTTP.Compression.MinSize)
 	}
 
-	configCopy.HTTP.Headers = make(http.Header)
-	for k, v := range config.HTTP.Headers {
-		configCopy.HTTP.Headers[k] = v
+	testParameter(t, yml, "REGISTRY_HTTP_COMPRESSION_MINSIZE", tt, validator)
+}
+
+func TestParseHTTPCompression_Level(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  compression:
+    level: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "9",
+			want:  9,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
 	}
 
-	return configCopy
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.HTTP.Compression.Level)
+	}
+
+	testParameter(t, yml, "REGISTRY_HTTP_COMPRESSION_LEVEL", tt, validator)
+}
+
+func TestParseHTTPCompression_ContentTypes(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  compression:
+    contenttypes: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: `["application/json", "+json"]`,
+			want:  []string{"application/json", "+json"},
+		},
+		{
+			name: "default",
+			want: []string(nil),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.HTTP.Compression.ContentTypes)
+	}
+
+	testParameter(t, yml, "REGISTRY_HTTP_COMPRESSION_CONTENTTYPES", tt, validator)
+}
+
+func TestParseCompatibilitySchema1_MigrationURL(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+compatibility:
+  schema1:
+    migrationurl: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "https://docs.example.com/schema1",
+			want:  "https://docs.example.com/schema1",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Compatibility.Schema1.MigrationURL)
+	}
+
+	testParameter(t, yml, "REGISTRY_COMPATIBILITY_SCHEMA1_MIGRATIONURL", tt, validator)
+}
+
+func TestParseCompatibilityManifestLists_DefaultPlatforms(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+compatibility:
+  manifestlists:
+    defaultplatforms: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "[linux/arm64/v8, linux/amd64]",
+			want:  []string{"linux/arm64/v8", "linux/amd64"},
+		},
+		{
+			name: "default",
+			want: []string(nil),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Compatibility.ManifestLists.DefaultPlatforms)
+	}
+
+	testParameter(t, yml, "REGISTRY_COMPATIBILITY_MANIFESTLISTS_DEFAULTPLATFORMS", tt, validator)
 }
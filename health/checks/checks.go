@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -71,3 +72,60 @@ func TCPChecker(addr string, timeout time.Duration) health.Checker {
 		return nil
 	})
 }
+
+// Database is the subset of database operations required by DBChecker.
+type Database interface {
+	PingContext(ctx context.Context) error
+	ReplicationLag(ctx context.Context) (time.Duration, error)
+}
+
+// DBChecker pings a database and, if maxReplicationLag is positive, verifies
+// that the replication lag of its replicas does not exceed
+// maxReplicationLag. Each check is bounded by timeout, if positive.
+func DBChecker(db Database, timeout, maxReplicationLag time.Duration) health.Checker {
+	return health.CheckFunc(func() error {
+		ctx := context.Background()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		if err := db.PingContext(ctx); err != nil {
+			return fmt.Errorf("database ping failed: %w", err)
+		}
+
+		if maxReplicationLag <= 0 {
+			return nil
+		}
+
+		lag, err := db.ReplicationLag(ctx)
+		if err != nil {
+			return fmt.Errorf("database replication lag check failed: %w", err)
+		}
+		if lag > maxReplicationLag {
+			return fmt.Errorf("database replication lag %v exceeds maximum of %v", lag, maxReplicationLag)
+		}
+
+		return nil
+	})
+}
+
+// RedisChecker runs ping to verify that a Redis server is reachable. Each
+// check is bounded by timeout, if positive.
+func RedisChecker(ping func(ctx context.Context) error, timeout time.Duration) health.Checker {
+	return health.CheckFunc(func() error {
+		ctx := context.Background()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		if err := ping(ctx); err != nil {
+			return fmt.Errorf("redis ping failed: %w", err)
+		}
+
+		return nil
+	})
+}
@@ -1,7 +1,10 @@
 package checks
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 )
 
 func TestFileChecker(t *testing.T) {
@@ -23,3 +26,65 @@ func TestHTTPChecker(t *testing.T) {
 		t.Errorf("Google at Portugal was expected as exists, error:%v", err)
 	}
 }
+
+type fakeDB struct {
+	pingErr error
+	lag     time.Duration
+	lagErr  error
+}
+
+func (db *fakeDB) PingContext(ctx context.Context) error {
+	return db.pingErr
+}
+
+func (db *fakeDB) ReplicationLag(ctx context.Context) (time.Duration, error) {
+	return db.lag, db.lagErr
+}
+
+func TestDBChecker(t *testing.T) {
+	tests := []struct {
+		name              string
+		db                *fakeDB
+		maxReplicationLag time.Duration
+		wantErr           bool
+	}{
+		{name: "healthy", db: &fakeDB{}},
+		{name: "ping error", db: &fakeDB{pingErr: errors.New("foo")}, wantErr: true},
+		{name: "lag ignored when disabled", db: &fakeDB{lag: time.Hour}},
+		{name: "lag below maximum", db: &fakeDB{lag: time.Second}, maxReplicationLag: time.Minute},
+		{name: "lag above maximum", db: &fakeDB{lag: time.Hour}, maxReplicationLag: time.Minute, wantErr: true},
+		{name: "lag error", db: &fakeDB{lagErr: errors.New("foo")}, maxReplicationLag: time.Minute, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := DBChecker(test.db, time.Second, test.maxReplicationLag).Check()
+			if test.wantErr && err == nil {
+				t.Error("expected error, got nil")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestRedisChecker(t *testing.T) {
+	if err := RedisChecker(func(ctx context.Context) error { return nil }, time.Second).Check(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err := RedisChecker(func(ctx context.Context) error { return errors.New("foo") }, time.Second).Check()
+	if err == nil || err.Error() != "redis ping failed: foo" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// the ping is bounded by the timeout
+	err = RedisChecker(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, 10*time.Millisecond).Check()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -174,6 +174,35 @@ func (registry *Registry) CheckStatus() map[string]string { // TODO(stevvooe) th
 	return statusKeys
 }
 
+// Check statuses reported in a Result.
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// Result is the current status of a health check.
+type Result struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Results returns a map with the current status of all health checks,
+// including the ones that are passing.
+func (registry *Registry) Results() map[string]Result {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	results := make(map[string]Result, len(registry.registeredChecks))
+	for k, v := range registry.registeredChecks {
+		if err := v.Check(); err != nil {
+			results[k] = Result{Status: StatusError, Error: err.Error()}
+			continue
+		}
+		results[k] = Result{Status: StatusOK}
+	}
+
+	return results
+}
+
 // CheckStatus returns a map with all the current health check errors from the
 // default registry.
 func CheckStatus() map[string]string {
@@ -255,6 +284,63 @@ func StatusHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// LivenessHandler reports whether the process is up and serving requests.
+// Always returns 200, regardless of the health checks, so that failing
+// dependencies do not cause the process to be restarted.
+func LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(`{"status":"ok"}`)); err != nil {
+		context.GetLogger(context.Background()).Errorf("error writing liveness response body: %v", err)
+	}
+}
+
+// readinessResponse describes the readiness of the service and the status of
+// each of its dependencies.
+type readinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// ReadinessHandler returns a JSON blob with the overall readiness of the
+// service and the status of all the currently registered Health Checks.
+// Returns 503 if any check is failing, 200 otherwise.
+func ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.NotFound(w, r)
+		return
+	}
+
+	resp := readinessResponse{Status: StatusOK, Checks: DefaultRegistry.Results()}
+	status := http.StatusOK
+	for _, res := range resp.Checks {
+		if res.Status != StatusOK {
+			resp.Status = StatusError
+			status = http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	p, err := json.Marshal(resp)
+	if err != nil {
+		context.GetLogger(context.Background()).Errorf("error serializing readiness status: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", fmt.Sprint(len(p)))
+	w.WriteHeader(status)
+	if _, err := w.Write(p); err != nil {
+		context.GetLogger(context.Background()).Errorf("error writing readiness status response body: %v", err)
+	}
+}
+
 // Handler returns a handler that will return 503 response code if the health
 // checks have failed. If everything is okay with the health checks, the
 // handler will pass through to the provided handler. Use this handler to
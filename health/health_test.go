@@ -105,3 +105,55 @@ func TestHealthHandler(t *testing.T) {
 	updater.Update(nil)
 	checkUp(t, "when server is back up") // now we should be back up.
 }
+
+// TestLivenessHandler ensures that the liveness endpoint reports the service
+// as up regardless of the health checks.
+func TestLivenessHandler(t *testing.T) {
+	DefaultRegistry = NewRegistry()
+	Register("failing_check", CheckFunc(func() error {
+		return errors.New("This Check did not succeed")
+	}))
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "https://fakeurl.com/healthz", nil)
+	LivenessHandler(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("unexpected response code: %d != %d", recorder.Code, http.StatusOK)
+	}
+	if body := recorder.Body.String(); body != `{"status":"ok"}` {
+		t.Errorf("unexpected response body: %s", body)
+	}
+}
+
+// TestReadinessHandler ensures that the readiness endpoint reports the status
+// of each check, and 503 if any is failing.
+func TestReadinessHandler(t *testing.T) {
+	DefaultRegistry = NewRegistry()
+	updater := NewStatusUpdater()
+	Register("database", updater)
+	Register("redis", CheckFunc(func() error { return nil }))
+
+	check := func(t *testing.T, wantCode int, wantBody string) {
+		t.Helper()
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "https://fakeurl.com/readyz", nil)
+		ReadinessHandler(recorder, req)
+
+		if recorder.Code != wantCode {
+			t.Errorf("unexpected response code: %d != %d", recorder.Code, wantCode)
+		}
+		if body := recorder.Body.String(); body != wantBody {
+			t.Errorf("unexpected response body: %s != %s", body, wantBody)
+		}
+	}
+
+	check(t, http.StatusOK, `{"status":"ok","checks":{"database":{"status":"ok"},"redis":{"status":"ok"}}}`)
+
+	updater.Update(errors.New("database ping failed"))
+	check(t, http.StatusServiceUnavailable, `{"status":"error","checks":{"database":{"status":"error","error":"database ping failed"},"redis":{"status":"ok"}}}`)
+
+	updater.Update(nil)
+	check(t, http.StatusOK, `{"status":"ok","checks":{"database":{"status":"ok"},"redis":{"status":"ok"}}}`)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/manifest"
@@ -20,6 +21,21 @@ var (
 	}
 )
 
+// Media types of layers encrypted with ocicrypt. These are the media types of the plain layers with an "+encrypted"
+// suffix. The encryption metadata needed to decrypt them is stored in the layer descriptor annotations.
+const (
+	MediaTypeImageLayerEncrypted                     = v1.MediaTypeImageLayer + "+encrypted"
+	MediaTypeImageLayerGzipEncrypted                 = v1.MediaTypeImageLayerGzip + "+encrypted"
+	MediaTypeImageLayerZstdEncrypted                 = v1.MediaTypeImageLayer + "+zstd+encrypted"
+	MediaTypeImageLayerNonDistributableEncrypted     = v1.MediaTypeImageLayerNonDistributable + "+encrypted"
+	MediaTypeImageLayerNonDistributableGzipEncrypted = v1.MediaTypeImageLayerNonDistributableGzip + "+encrypted"
+)
+
+// IsEncryptedLayer returns whether mediaType is the media type of a layer encrypted with ocicrypt.
+func IsEncryptedLayer(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "application/vnd.oci.image.layer.") && strings.HasSuffix(mediaType, "+encrypted")
+}
+
 func init() {
 	ocischemaFunc := func(b []byte) (distribution.Manifest, distribution.Descriptor, error) {
 		m := new(DeserializedManifest)
@@ -60,6 +76,16 @@ func (m Manifest) References() []distribution.Descriptor {
 	return references
 }
 
+// Encrypted returns whether any of the layers of this manifest is encrypted.
+func (m Manifest) Encrypted() bool {
+	for _, l := range m.Layers {
+		if IsEncryptedLayer(l.MediaType) {
+			return true
+		}
+	}
+	return false
+}
+
 // Target returns the target of this manifest.
 func (m Manifest) Target() distribution.Descriptor {
 	return m.Config
@@ -182,3 +182,45 @@ func TestMediaTypes(t *testing.T) {
 	mediaTypeTest(t, v1.MediaTypeImageManifest, false)
 	mediaTypeTest(t, v1.MediaTypeImageManifest+"XXX", true)
 }
+
+func TestEncrypted(t *testing.T) {
+	m := Manifest{
+		Layers: []distribution.Descriptor{
+			{MediaType: v1.MediaTypeImageLayerGzip},
+		},
+	}
+	if m.Encrypted() {
+		t.Fatal("expected manifest not to be encrypted")
+	}
+
+	m.Layers = append(m.Layers, distribution.Descriptor{MediaType: MediaTypeImageLayerGzipEncrypted})
+	if !m.Encrypted() {
+		t.Fatal("expected manifest to be encrypted")
+	}
+}
+
+func TestIsEncryptedLayer(t *testing.T) {
+	for _, mt := range []string{
+		MediaTypeImageLayerEncrypted,
+		MediaTypeImageLayerGzipEncrypted,
+		MediaTypeImageLayerZstdEncrypted,
+		MediaTypeImageLayerNonDistributableEncrypted,
+		MediaTypeImageLayerNonDistributableGzipEncrypted,
+	} {
+		if !IsEncryptedLayer(mt) {
+			t.Errorf("expected %q to be an encrypted layer media type", mt)
+		}
+	}
+
+	for _, mt := range []string{
+		v1.MediaTypeImageLayer,
+		v1.MediaTypeImageLayerGzip,
+		v1.MediaTypeImageLayerNonDistributableGzip,
+		v1.MediaTypeImageConfig,
+		"application/vnd.example+encrypted",
+	} {
+		if IsEncryptedLayer(mt) {
+			t.Errorf("expected %q not to be an encrypted layer media type", mt)
+		}
+	}
+}
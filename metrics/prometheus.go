@@ -13,4 +13,10 @@ var (
 
 	// NotificationsNamespace is the prometheus namespace of notification related metrics
 	NotificationsNamespace = metrics.NewNamespace(NamespacePrefix, "notifications", nil)
+
+	// QuotaNamespace is the prometheus namespace of repository quota related metrics
+	QuotaNamespace = metrics.NewNamespace(NamespacePrefix, "quota", nil)
+
+	// ClientsNamespace is the prometheus namespace of client access restrictions related metrics
+	ClientsNamespace = metrics.NewNamespace(NamespacePrefix, "clients", nil)
 )
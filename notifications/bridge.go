@@ -6,6 +6,7 @@ import (
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/manifest/manifestlist"
 	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/uuid"
 	"github.com/opencontainers/go-digest"
@@ -110,10 +111,12 @@ func (b *bridge) BlobDeleted(repo reference.Named, dgst digest.Digest) error {
 	return b.createBlobDeleteEventAndWrite(EventActionDelete, repo, dgst)
 }
 
-func (b *bridge) TagDeleted(repo reference.Named, tag string) error {
+func (b *bridge) TagDeleted(repo reference.Named, tag string, desc distribution.Descriptor) error {
 	event := b.createEvent(EventActionDelete)
 	event.Target.Repository = repo.Name()
 	event.Target.Tag = tag
+	event.Target.Digest = desc.Digest
+	event.Target.MediaType = desc.MediaType
 
 	return b.sink.Write(*event)
 }
@@ -125,6 +128,14 @@ func (b *bridge) RepoDeleted(repo reference.Named) error {
 	return b.sink.Write(*event)
 }
 
+func (b *bridge) RepoRenamed(repo reference.Named, fromRepo reference.Named) error {
+	event := b.createEvent(EventActionRename)
+	event.Target.Repository = repo.Name()
+	event.Target.FromRepository = fromRepo.Name()
+
+	return b.sink.Write(*event)
+}
+
 func (b *bridge) createManifestEventAndWrite(action string, repo reference.Named, sm distribution.Manifest) error {
 	manifestEvent, err := b.createManifestEvent(action, repo, sm)
 	if err != nil {
@@ -164,6 +175,12 @@ func (b *bridge) createManifestEvent(action string, repo reference.Named, sm dis
 	if b.includeReferences {
 		event.Target.References = append(event.Target.References, manifest.References()...)
 	}
+	// Listeners such as vulnerability scanners need the child manifests of pushed manifest lists, which would
+	// otherwise require fetching the manifest list again. These are included regardless of includeReferences, as
+	// references lack the platform of each manifest.
+	if ml, ok := manifest.(*manifestlist.DeserializedManifestList); ok && action == EventActionPush {
+		event.Target.Manifests = append(event.Target.Manifests, ml.Manifests...)
+	}
 
 	ref, err := reference.WithDigest(repo, event.Target.Digest)
 	if err != nil {
@@ -1,10 +1,13 @@
 package notifications
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
 	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/manifest/schema2"
 	"github.com/docker/distribution/reference"
 	v2 "github.com/docker/distribution/registry/api/v2"
 	"github.com/docker/distribution/uuid"
@@ -124,11 +127,18 @@ func TestEventBridgeTagDeleted(t *testing.T) {
 		if events[0].Target.Tag != m.Tag {
 			t.Fatalf("unexpected tag on event target: %q != %q", events[0].Target.Tag, m.Tag)
 		}
+		if events[0].Target.Digest != dgst {
+			t.Fatalf("unexpected digest on event target: %q != %q", events[0].Target.Digest, dgst)
+		}
+		if events[0].Target.MediaType != schema1.MediaTypeSignedManifest {
+			t.Fatalf("unexpected media type on event target: %q != %q", events[0].Target.MediaType, schema1.MediaTypeSignedManifest)
+		}
 		return nil
 	}))
 
 	repoRef, _ := reference.WithName(repo)
-	if err := l.TagDeleted(repoRef, m.Tag); err != nil {
+	desc := distribution.Descriptor{Digest: dgst, MediaType: schema1.MediaTypeSignedManifest}
+	if err := l.TagDeleted(repoRef, m.Tag, desc); err != nil {
 		t.Fatalf("unexpected error notifying tag deletion: %v", err)
 	}
 }
@@ -145,6 +155,129 @@ func TestEventBridgeRepoDeleted(t *testing.T) {
 	}
 }
 
+func TestEventBridgeRepoRenamed(t *testing.T) {
+	l := createTestEnv(t, testSinkFn(func(events ...Event) error {
+		if len(events) != 1 {
+			t.Fatalf("unexpected number of events: %v != 1", len(events))
+		}
+		event := events[0]
+		if event.Action != EventActionRename {
+			t.Fatalf("unexpected event action: %q != %q", event.Action, EventActionRename)
+		}
+		if event.Target.Repository != repo+"-renamed" {
+			t.Fatalf("unexpected repository: %q != %q", event.Target.Repository, repo+"-renamed")
+		}
+		if event.Target.FromRepository != repo {
+			t.Fatalf("unexpected from repository: %q != %q", event.Target.FromRepository, repo)
+		}
+		if event.Source != source {
+			t.Fatalf("source not equal: %#v != %#v", event.Source, source)
+		}
+		if event.Actor != actor {
+			t.Fatalf("actor not equal: %#v != %#v", event.Actor, actor)
+		}
+		return nil
+	}))
+
+	fromRef, _ := reference.WithName(repo)
+	repoRef, _ := reference.WithName(repo + "-renamed")
+	if err := l.RepoRenamed(repoRef, fromRef); err != nil {
+		t.Fatalf("unexpected error notifying repo rename: %v", err)
+	}
+}
+
+func TestEventBridgeManifestListPushed(t *testing.T) {
+	descriptors := []manifestlist.ManifestDescriptor{
+		{
+			Descriptor: distribution.Descriptor{
+				MediaType: schema2.MediaTypeManifest,
+				Digest:    digest.FromString("amd64"),
+				Size:      528,
+			},
+			Platform: manifestlist.PlatformSpec{Architecture: "amd64", OS: "linux"},
+		},
+		{
+			Descriptor: distribution.Descriptor{
+				MediaType: schema2.MediaTypeManifest,
+				Digest:    digest.FromString("arm64"),
+				Size:      530,
+			},
+			Platform: manifestlist.PlatformSpec{Architecture: "arm64", OS: "linux", Variant: "v8"},
+		},
+	}
+	ml, err := manifestlist.FromDescriptors(descriptors)
+	if err != nil {
+		t.Fatalf("error creating manifest list: %v", err)
+	}
+
+	var events []Event
+	l := NewBridge(ub, source, actor, request, testSinkFn(func(ee ...Event) error {
+		events = append(events, ee...)
+		return nil
+	}), false)
+
+	repoRef, _ := reference.WithName(repo)
+	if err := l.ManifestPushed(repoRef, ml); err != nil {
+		t.Fatalf("unexpected error notifying manifest list push: %v", err)
+	}
+	if err := l.ManifestPulled(repoRef, ml); err != nil {
+		t.Fatalf("unexpected error notifying manifest list pull: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("unexpected number of events: %v != 2", len(events))
+	}
+
+	pushed := events[0]
+	if pushed.Target.MediaType != manifestlist.MediaTypeManifestList {
+		t.Fatalf("unexpected media type on event target: %q != %q", pushed.Target.MediaType, manifestlist.MediaTypeManifestList)
+	}
+	if !reflect.DeepEqual(pushed.Target.Manifests, descriptors) {
+		t.Fatalf("unexpected manifests on event target: %#v != %#v", pushed.Target.Manifests, descriptors)
+	}
+	if len(pushed.Target.References) != 0 {
+		t.Fatalf("unexpected references on event target: %#v", pushed.Target.References)
+	}
+
+	// child manifests are only listed for pushes
+	if pulled := events[1]; len(pulled.Target.Manifests) != 0 {
+		t.Fatalf("unexpected manifests on pull event target: %#v", pulled.Target.Manifests)
+	}
+}
+
+func TestEventBridgeBlobPushed(t *testing.T) {
+	desc := distribution.Descriptor{
+		MediaType: "application/octet-stream",
+		Digest:    digest.FromString("blob"),
+		Size:      4,
+	}
+
+	l := createTestEnv(t, testSinkFn(func(events ...Event) error {
+		if len(events) != 1 {
+			t.Fatalf("unexpected number of events: %v != 1", len(events))
+		}
+		event := events[0]
+		if event.Action != EventActionPush {
+			t.Fatalf("unexpected event action: %q != %q", event.Action, EventActionPush)
+		}
+		if event.Target.Repository != repo {
+			t.Fatalf("unexpected repository: %q != %q", event.Target.Repository, repo)
+		}
+		if event.Target.Digest != desc.Digest {
+			t.Fatalf("unexpected digest on event target: %q != %q", event.Target.Digest, desc.Digest)
+		}
+		if event.Target.Size != desc.Size || event.Target.Length != desc.Size {
+			t.Fatalf("unexpected size on event target: %v/%v != %v", event.Target.Size, event.Target.Length, desc.Size)
+		}
+
+		return nil
+	}))
+
+	repoRef, _ := reference.WithName(repo)
+	if err := l.BlobPushed(repoRef, desc); err != nil {
+		t.Fatalf("unexpected error notifying blob push: %v", err)
+	}
+}
+
 func createTestEnv(t *testing.T, fn testSinkFn) Listener {
 	pk, err := libtrust.GenerateECP256PrivateKey()
 	if err != nil {
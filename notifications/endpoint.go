@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/docker/distribution/configuration"
+	"github.com/sirupsen/logrus"
 )
 
 // EndpointConfig covers the optional configuration parameters for an active
@@ -14,9 +15,15 @@ type EndpointConfig struct {
 	Timeout           time.Duration
 	Threshold         int
 	Backoff           time.Duration
+	MaxBackoff        time.Duration
+	MaxRetries        int
+	QueueSize         int
+	QueuePolicy       configuration.QueuePolicy
 	IgnoredMediaTypes []string
 	Transport         *http.Transport `json:"-"`
 	Ignore            configuration.Ignore
+	// DeadLetter receives the events that could not be delivered, if not nil.
+	DeadLetter Sink `json:"-"`
 }
 
 // defaults set any zero-valued fields to a reasonable default.
@@ -33,6 +40,10 @@ func (ec *EndpointConfig) defaults() {
 		ec.Backoff = time.Second
 	}
 
+	if ec.QueuePolicy == "" {
+		ec.QueuePolicy = configuration.QueuePolicyDropOldest
+	}
+
 	if ec.Transport == nil {
 		ec.Transport = http.DefaultTransport.(*http.Transport)
 	}
@@ -64,8 +75,12 @@ func NewEndpoint(name, url string, config EndpointConfig) *Endpoint {
 	endpoint.Sink = newHTTPSink(
 		endpoint.url, endpoint.Timeout, endpoint.Headers,
 		endpoint.Transport, endpoint.metrics.httpStatusListener())
-	endpoint.Sink = newRetryingSink(endpoint.Sink, endpoint.Threshold, endpoint.Backoff)
-	endpoint.Sink = newEventQueue(endpoint.Sink, endpoint.metrics.eventQueueListener())
+	endpoint.Sink = newRetryingSink(endpoint.Sink, endpoint.Threshold, endpoint.Backoff, endpoint.MaxBackoff, endpoint.MaxRetries)
+	if endpoint.DeadLetter != nil {
+		endpoint.Sink = newDeadLetterSink(endpoint.Sink, endpoint.DeadLetter)
+	}
+	endpoint.Sink = newBoundedEventQueue(endpoint.Sink, endpoint.QueueSize, endpoint.QueuePolicy,
+		endpoint.DeadLetter, endpoint.metrics.eventQueueListener())
 	mediaTypes := append(config.Ignore.MediaTypes, config.IgnoredMediaTypes...)
 	endpoint.Sink = newIgnoredSink(endpoint.Sink, mediaTypes, config.Ignore.Actions)
 
@@ -73,6 +88,59 @@ func NewEndpoint(name, url string, config EndpointConfig) *Endpoint {
 	return &endpoint
 }
 
+// NewEndpointSinks returns a running endpoint for each enabled endpoint configuration.
+func NewEndpointSinks(endpoints []configuration.Endpoint) []Sink {
+	var sinks []Sink
+	for _, endpoint := range endpoints {
+		if endpoint.Disabled {
+			logrus.Infof("endpoint %s disabled, skipping", endpoint.Name)
+			continue
+		}
+
+		logrus.Infof("configuring endpoint %v (%v), timeout=%s, headers=%v", endpoint.Name, endpoint.URL, endpoint.Timeout, endpoint.Headers)
+		sinks = append(sinks, NewEndpoint(endpoint.Name, endpoint.URL, EndpointConfig{
+			Timeout:           endpoint.Timeout,
+			Threshold:         endpoint.Threshold,
+			Backoff:           endpoint.Backoff,
+			MaxBackoff:        endpoint.MaxBackoff,
+			MaxRetries:        endpoint.MaxRetries,
+			QueueSize:         endpoint.QueueSize,
+			QueuePolicy:       endpoint.QueuePolicy,
+			Headers:           endpoint.Headers,
+			IgnoredMediaTypes: endpoint.IgnoredMediaTypes,
+			Ignore:            endpoint.Ignore,
+			DeadLetter:        newDeadLetter(endpoint.Name, endpoint.DeadLetter),
+		}))
+	}
+
+	return sinks
+}
+
+// newDeadLetter returns the dead letter sink of an endpoint, or nil if none is
+// configured or it could not be opened, in which case undelivered events are
+// lost.
+func newDeadLetter(name string, config configuration.DeadLetter) Sink {
+	switch {
+	case config.File != "":
+		s, err := newFileSink(config.File)
+		if err != nil {
+			logrus.WithError(err).Errorf("unable to configure dead letter file for endpoint %s, undelivered events will be lost", name)
+			return nil
+		}
+		logrus.Infof("configuring dead letter file %q for endpoint %s", config.File, name)
+		return s
+	case config.URL != "":
+		logrus.Infof("configuring dead letter endpoint %v for endpoint %s, timeout=%s", config.URL, name, config.Timeout)
+		timeout := config.Timeout
+		if timeout <= 0 {
+			timeout = time.Second
+		}
+		return newHTTPSink(config.URL, timeout, config.Headers, nil)
+	default:
+		return nil
+	}
+}
+
 // Name returns the name of the endpoint, generally used for debugging.
 func (e *Endpoint) Name() string {
 	return e.name
@@ -83,6 +151,13 @@ func (e *Endpoint) URL() string {
 	return e.url
 }
 
+// Close closes the endpoint, flushing queued events, and stops tracking its
+// metrics.
+func (e *Endpoint) Close() error {
+	unregister(e)
+	return e.Sink.Close()
+}
+
 // ReadMetrics populates em with metrics from the endpoint.
 func (e *Endpoint) ReadMetrics(em *EndpointMetrics) {
 	e.metrics.Lock()
@@ -5,14 +5,29 @@ import (
 	"time"
 
 	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
 )
 
 // EventAction constants used in action field of Event.
 const (
-	EventActionPull   = "pull"
-	EventActionPush   = "push"
-	EventActionMount  = "mount"
-	EventActionDelete = "delete"
+	EventActionPull    = "pull"
+	EventActionPush    = "push"
+	EventActionMount   = "mount"
+	EventActionDelete  = "delete"
+	EventActionRename  = "rename"
+	EventActionCorrupt = "corrupt"
+)
+
+// EventReason constants used in reason field of Event.
+const (
+	// EventReasonGC identifies events for artifacts removed by garbage collection.
+	EventReasonGC = "gc"
+	// EventReasonQuarantine identifies events for tags removed after their
+	// manifest failed asynchronous validation.
+	EventReasonQuarantine = "quarantine"
+	// EventReasonScrub identifies events for blobs found to be corrupt by the
+	// scrubber.
+	EventReasonScrub = "scrub"
 )
 
 const (
@@ -49,6 +64,10 @@ type Event struct {
 	// Action indicates what action encompasses the provided event.
 	Action string `json:"action,omitempty"`
 
+	// Reason indicates why the action was performed, when not directly
+	// requested by a client, such as EventReasonGC.
+	Reason string `json:"reason,omitempty"`
+
 	// Target uniquely describes the target of the event.
 	Target struct {
 		// TODO(stevvooe): Use http.DetectContentType for layers, maybe.
@@ -74,6 +93,10 @@ type Event struct {
 
 		// References provides the references descriptors.
 		References []distribution.Descriptor `json:"references,omitempty"`
+
+		// Manifests lists the manifests referenced by a pushed manifest list
+		// or OCI image index, along with their platform.
+		Manifests []manifestlist.ManifestDescriptor `json:"manifests,omitempty"`
 	} `json:"target,omitempty"`
 
 	// Request covers the request that generated the event.
@@ -0,0 +1,46 @@
+package notifications
+
+import (
+	"github.com/opencontainers/go-digest"
+)
+
+type gcBridge struct {
+	source SourceRecord
+	sink   Sink
+}
+
+var _ GCListener = &gcBridge{}
+
+// NewGCBridge returns a GCListener that writes delete events, with reason EventReasonGC, to sink. Garbage collection
+// may remove a large number of artifacts at once, so sink should preferably be a BatchingSink.
+func NewGCBridge(source SourceRecord, sink Sink) GCListener {
+	return &gcBridge{
+		source: source,
+		sink:   sink,
+	}
+}
+
+// BlobDeleted implements GCListener.
+func (b *gcBridge) BlobDeleted(dgst digest.Digest) error {
+	event := b.createEvent()
+	event.Target.Digest = dgst
+
+	return b.sink.Write(*event)
+}
+
+// ManifestDeleted implements GCListener.
+func (b *gcBridge) ManifestDeleted(repo string, dgst digest.Digest) error {
+	event := b.createEvent()
+	event.Target.Repository = repo
+	event.Target.Digest = dgst
+
+	return b.sink.Write(*event)
+}
+
+func (b *gcBridge) createEvent() *Event {
+	event := createEvent(EventActionDelete)
+	event.Reason = EventReasonGC
+	event.Source = b.source
+
+	return event
+}
@@ -0,0 +1,63 @@
+package notifications
+
+import (
+	"testing"
+)
+
+func TestGCBridgeBlobDeleted(t *testing.T) {
+	l := NewGCBridge(source, testSinkFn(func(events ...Event) error {
+		checkGCDeleted(t, events...)
+		if events[0].Target.Digest != dgst {
+			t.Fatalf("unexpected digest on event target: %q != %q", events[0].Target.Digest, dgst)
+		}
+		if events[0].Target.Repository != "" {
+			t.Fatalf("unexpected repository on event target: %q", events[0].Target.Repository)
+		}
+		return nil
+	}))
+
+	if err := l.BlobDeleted(dgst); err != nil {
+		t.Fatalf("unexpected error notifying blob deletion: %v", err)
+	}
+}
+
+func TestGCBridgeManifestDeleted(t *testing.T) {
+	l := NewGCBridge(source, testSinkFn(func(events ...Event) error {
+		checkGCDeleted(t, events...)
+		if events[0].Target.Digest != dgst {
+			t.Fatalf("unexpected digest on event target: %q != %q", events[0].Target.Digest, dgst)
+		}
+		if events[0].Target.Repository != repo {
+			t.Fatalf("unexpected repository on event target: %q != %q", events[0].Target.Repository, repo)
+		}
+		return nil
+	}))
+
+	if err := l.ManifestDeleted(repo, dgst); err != nil {
+		t.Fatalf("unexpected error notifying manifest deletion: %v", err)
+	}
+}
+
+func checkGCDeleted(t *testing.T, events ...Event) {
+	if len(events) != 1 {
+		t.Fatalf("unexpected number of events: %v != 1", len(events))
+	}
+
+	event := events[0]
+
+	if event.Action != EventActionDelete {
+		t.Fatalf("unexpected event action: %q != %q", event.Action, EventActionDelete)
+	}
+	if event.Reason != EventReasonGC {
+		t.Fatalf("unexpected event reason: %q != %q", event.Reason, EventReasonGC)
+	}
+	if event.Source != source {
+		t.Fatalf("source not equal: %#v != %#v", event.Source, source)
+	}
+	if event.ID == "" {
+		t.Fatalf("event id not set")
+	}
+	if event.Timestamp.IsZero() {
+		t.Fatalf("event timestamp not set")
+	}
+}
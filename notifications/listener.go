@@ -2,6 +2,7 @@ package notifications
 
 import (
 	"context"
+	"io"
 	"net/http"
 
 	"github.com/docker/distribution"
@@ -28,8 +29,32 @@ type BlobListener interface {
 
 // RepoListener provides repository methods that respond to repository lifecycle
 type RepoListener interface {
-	TagDeleted(repo reference.Named, tag string) error
+	// TagDeleted is called after a tag was deleted. desc describes the manifest that the tag pointed to, if known.
+	TagDeleted(repo reference.Named, tag string, desc distribution.Descriptor) error
 	RepoDeleted(repo reference.Named) error
+	// RepoRenamed is called after a repository was renamed from fromRepo to repo.
+	RepoRenamed(repo reference.Named, fromRepo reference.Named) error
+}
+
+// GCListener describes a listener that can respond to the removal of artifacts by garbage collection. Contrary to other
+// listeners, artifacts are not necessarily scoped to a repository.
+type GCListener interface {
+	// BlobDeleted is called after a blob was removed from the storage backend.
+	BlobDeleted(dgst digest.Digest) error
+	// ManifestDeleted is called after a manifest was removed from a repository.
+	ManifestDeleted(repo string, dgst digest.Digest) error
+}
+
+// QuarantineListener describes a listener that can respond to tags being
+// quarantined (removed) after their manifest failed asynchronous validation.
+type QuarantineListener interface {
+	TagQuarantined(repo string, tag string, dgst digest.Digest) error
+}
+
+// ScrubListener describes a listener that can respond to stored blobs found to
+// be corrupt by the scrubber.
+type ScrubListener interface {
+	BlobCorrupted(desc distribution.Descriptor) error
 }
 
 // Listener combines all repository events into a single interface.
@@ -239,6 +264,28 @@ func (bwl *blobWriterListener) Commit(ctx context.Context, desc distribution.Des
 	return committed, err
 }
 
+// WritePart writes a part of the blob out of order. Returns distribution.ErrUnsupported if the underlying writer does
+// not implement distribution.BlobPartWriter.
+func (bwl *blobWriterListener) WritePart(ctx context.Context, offset int64, r io.Reader) (int64, error) {
+	pw, ok := bwl.BlobWriter.(distribution.BlobPartWriter)
+	if !ok {
+		return 0, distribution.ErrUnsupported
+	}
+
+	return pw.WritePart(ctx, offset, r)
+}
+
+// ContiguousSize returns the size of the contiguous content received so far. Returns distribution.ErrUnsupported if
+// the underlying writer does not implement distribution.BlobPartWriter.
+func (bwl *blobWriterListener) ContiguousSize(ctx context.Context) (int64, error) {
+	pw, ok := bwl.BlobWriter.(distribution.BlobPartWriter)
+	if !ok {
+		return 0, distribution.ErrUnsupported
+	}
+
+	return pw.ContiguousSize(ctx)
+}
+
 type tagServiceListener struct {
 	distribution.TagService
 	parent *repositoryListener
@@ -251,11 +298,41 @@ func (rl *repositoryListener) Tags(ctx context.Context) distribution.TagService
 	}
 }
 
+// resolve returns the descriptor of the manifest that tag points to. This is done on a best effort basis, the digest
+// and/or media type are left empty if they can not be determined.
+func (tagSL *tagServiceListener) resolve(ctx context.Context, tag string) distribution.Descriptor {
+	desc, err := tagSL.TagService.Get(ctx, tag)
+	if err != nil {
+		// the tag may not exist, in which case the untag will fail as well
+		return distribution.Descriptor{}
+	}
+
+	manifests, err := tagSL.parent.Repository.Manifests(ctx)
+	if err != nil {
+		dcontext.GetLogger(ctx).WithError(err).Warn("error resolving media type of tagged manifest")
+		return desc
+	}
+	m, err := manifests.Get(ctx, desc.Digest)
+	if err != nil {
+		dcontext.GetLogger(ctx).WithError(err).Warn("error resolving media type of tagged manifest")
+		return desc
+	}
+	if desc.MediaType, _, err = m.Payload(); err != nil {
+		dcontext.GetLogger(ctx).WithError(err).Warn("error resolving media type of tagged manifest")
+		desc.MediaType = ""
+	}
+
+	return desc
+}
+
 func (tagSL *tagServiceListener) Untag(ctx context.Context, tag string) error {
+	// the tagged manifest must be resolved before deleting the tag, so that it can be included in the event
+	desc := tagSL.resolve(ctx, tag)
+
 	if err := tagSL.TagService.Untag(ctx, tag); err != nil {
 		return err
 	}
-	if err := tagSL.parent.listener.TagDeleted(tagSL.parent.Repository.Named(), tag); err != nil {
+	if err := tagSL.parent.listener.TagDeleted(tagSL.parent.Repository.Named(), tag, desc); err != nil {
 		dcontext.GetLogger(ctx).Errorf("error dispatching tag deleted to listener: %v", err)
 		return err
 	}
@@ -9,6 +9,7 @@ import (
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/manifest"
 	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/manifest/schema2"
 	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/storage"
 	"github.com/docker/distribution/registry/storage/cache/memory"
@@ -103,7 +104,7 @@ func (tl *testListener) BlobDeleted(repo reference.Named, d digest.Digest) error
 	return nil
 }
 
-func (tl *testListener) TagDeleted(repo reference.Named, tag string) error {
+func (tl *testListener) TagDeleted(repo reference.Named, tag string, desc distribution.Descriptor) error {
 	tl.ops["tag:delete"]++
 	return nil
 }
@@ -113,6 +114,11 @@ func (tl *testListener) RepoDeleted(repo reference.Named) error {
 	return nil
 }
 
+func (tl *testListener) RepoRenamed(repo reference.Named, fromRepo reference.Named) error {
+	tl.ops["repo:rename"]++
+	return nil
+}
+
 // checkExerciseRegistry takes the registry through all of its operations,
 // carrying out generic checks.
 func checkExerciseRepository(t *testing.T, repository distribution.Repository, remover distribution.RepositoryRemover) {
@@ -232,3 +238,69 @@ func checkExerciseRepository(t *testing.T, repository distribution.Repository, r
 		t.Fatalf("unexpected error deleting repo: %v", err)
 	}
 }
+
+// tagDeleteListener records the descriptors of all tag delete notifications.
+type tagDeleteListener struct {
+	*testListener
+	descs []distribution.Descriptor
+}
+
+func (tl *tagDeleteListener) TagDeleted(repo reference.Named, tag string, desc distribution.Descriptor) error {
+	tl.descs = append(tl.descs, desc)
+	return nil
+}
+
+func TestListener_TagDeleted(t *testing.T) {
+	ctx := context.Background()
+	registry, err := storage.NewRegistry(ctx, inmemory.New())
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+
+	repoRef, _ := reference.WithName("foo/bar")
+	repository, err := registry.Repository(ctx, repoRef)
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+	tl := &tagDeleteListener{testListener: &testListener{ops: make(map[string]int)}}
+	repository, _ = Listen(repository, nil, tl)
+
+	blobs := repository.Blobs(ctx)
+	config, err := blobs.Put(ctx, schema2.MediaTypeImageConfig, []byte(`{}`))
+	if err != nil {
+		t.Fatalf("unexpected error putting config: %v", err)
+	}
+	layer, err := blobs.Put(ctx, schema2.MediaTypeLayer, []byte("layer"))
+	if err != nil {
+		t.Fatalf("unexpected error putting layer: %v", err)
+	}
+
+	m, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    config,
+		Layers:    []distribution.Descriptor{layer},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error building manifest: %v", err)
+	}
+	manifests, err := repository.Manifests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dgst, err := manifests.Put(ctx, m)
+	if err != nil {
+		t.Fatalf("unexpected error putting the manifest: %v", err)
+	}
+	if err := repository.Tags(ctx).Tag(ctx, "latest", distribution.Descriptor{Digest: dgst}); err != nil {
+		t.Fatalf("unexpected error tagging manifest: %v", err)
+	}
+
+	if err := repository.Tags(ctx).Untag(ctx, "latest"); err != nil {
+		t.Fatalf("unexpected error deleting tag: %v", err)
+	}
+
+	expected := []distribution.Descriptor{{Digest: dgst, MediaType: schema2.MediaTypeManifest}}
+	if !reflect.DeepEqual(tl.descs, expected) {
+		t.Fatalf("unexpected tag delete descriptors:\n%v\n !=\n%v", tl.descs, expected)
+	}
+}
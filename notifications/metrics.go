@@ -28,6 +28,7 @@ type EndpointMetrics struct {
 	Successes int            // total events written successfully
 	Failures  int            // total events failed
 	Errors    int            // total events errored
+	Dropped   int            // total events dropped from a full queue
 	Statuses  map[string]int // status code histogram, per call event
 }
 
@@ -120,6 +121,16 @@ func (eqc *endpointMetricsEventQueueListener) egress(events ...Event) {
 	pendingGauge.Dec(1)
 }
 
+func (eqc *endpointMetricsEventQueueListener) dropped(events ...Event) {
+	eqc.Lock()
+	defer eqc.Unlock()
+	eqc.Pending -= len(events)
+	eqc.Dropped += len(events)
+
+	eventsCounter.WithValues("Dropped").Inc(1)
+	pendingGauge.Dec(1)
+}
+
 // endpoints is global registry of endpoints used to report metrics to expvar
 var endpoints struct {
 	registered []*Endpoint
@@ -134,6 +145,19 @@ func register(e *Endpoint) {
 	endpoints.registered = append(endpoints.registered, e)
 }
 
+// unregister removes the endpoint from expvar, once it is no longer in use.
+func unregister(e *Endpoint) {
+	endpoints.mu.Lock()
+	defer endpoints.mu.Unlock()
+
+	for i, v := range endpoints.registered {
+		if v == e {
+			endpoints.registered = append(endpoints.registered[:i], endpoints.registered[i+1:]...)
+			return
+		}
+	}
+}
+
 func init() {
 	// NOTE(stevvooe): Setup registry metrics structure to report to expvar.
 	// Ideally, we do more metrics through logging but we need some nice
@@ -0,0 +1,33 @@
+package notifications
+
+import (
+	"github.com/opencontainers/go-digest"
+)
+
+type quarantineBridge struct {
+	source SourceRecord
+	sink   Sink
+}
+
+var _ QuarantineListener = &quarantineBridge{}
+
+// NewQuarantineBridge returns a QuarantineListener that writes delete events, with reason EventReasonQuarantine, to
+// sink.
+func NewQuarantineBridge(source SourceRecord, sink Sink) QuarantineListener {
+	return &quarantineBridge{
+		source: source,
+		sink:   sink,
+	}
+}
+
+// TagQuarantined implements QuarantineListener.
+func (b *quarantineBridge) TagQuarantined(repo string, tag string, dgst digest.Digest) error {
+	event := createEvent(EventActionDelete)
+	event.Reason = EventReasonQuarantine
+	event.Source = b.source
+	event.Target.Repository = repo
+	event.Target.Tag = tag
+	event.Target.Digest = dgst
+
+	return b.sink.Write(*event)
+}
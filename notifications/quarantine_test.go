@@ -0,0 +1,38 @@
+package notifications
+
+import (
+	"testing"
+)
+
+func TestQuarantineBridgeTagQuarantined(t *testing.T) {
+	l := NewQuarantineBridge(source, testSinkFn(func(events ...Event) error {
+		if len(events) != 1 {
+			t.Fatalf("unexpected number of events: %v != 1", len(events))
+		}
+
+		event := events[0]
+		if event.Action != EventActionDelete {
+			t.Fatalf("unexpected event action: %q != %q", event.Action, EventActionDelete)
+		}
+		if event.Reason != EventReasonQuarantine {
+			t.Fatalf("unexpected event reason: %q != %q", event.Reason, EventReasonQuarantine)
+		}
+		if event.Source != source {
+			t.Fatalf("source not equal: %#v != %#v", event.Source, source)
+		}
+		if event.Target.Repository != repo {
+			t.Fatalf("unexpected repository on event target: %q != %q", event.Target.Repository, repo)
+		}
+		if event.Target.Tag != m.Tag {
+			t.Fatalf("unexpected tag on event target: %q != %q", event.Target.Tag, m.Tag)
+		}
+		if event.Target.Digest != dgst {
+			t.Fatalf("unexpected digest on event target: %q != %q", event.Target.Digest, dgst)
+		}
+		return nil
+	}))
+
+	if err := l.TagQuarantined(repo, m.Tag, dgst); err != nil {
+		t.Fatalf("unexpected error notifying tag quarantine: %v", err)
+	}
+}
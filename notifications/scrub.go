@@ -0,0 +1,34 @@
+package notifications
+
+import (
+	"github.com/docker/distribution"
+)
+
+type scrubBridge struct {
+	source SourceRecord
+	sink   Sink
+}
+
+var _ ScrubListener = &scrubBridge{}
+
+// NewScrubBridge returns a ScrubListener that writes corrupt events, with reason EventReasonScrub, to sink.
+func NewScrubBridge(source SourceRecord, sink Sink) ScrubListener {
+	return &scrubBridge{
+		source: source,
+		sink:   sink,
+	}
+}
+
+// BlobCorrupted implements ScrubListener. Blobs are not bound to a repository in storage, so the event target only
+// identifies the blob.
+func (b *scrubBridge) BlobCorrupted(desc distribution.Descriptor) error {
+	event := createEvent(EventActionCorrupt)
+	event.Reason = EventReasonScrub
+	event.Source = b.source
+	event.Target.MediaType = desc.MediaType
+	event.Target.Digest = desc.Digest
+	event.Target.Size = desc.Size
+	event.Target.Length = desc.Size
+
+	return b.sink.Write(*event)
+}
@@ -0,0 +1,46 @@
+package notifications
+
+import (
+	"testing"
+
+	"github.com/docker/distribution"
+)
+
+func TestScrubBridgeBlobCorrupted(t *testing.T) {
+	desc := distribution.Descriptor{
+		MediaType: "application/octet-stream",
+		Digest:    dgst,
+		Size:      512,
+	}
+
+	l := NewScrubBridge(source, testSinkFn(func(events ...Event) error {
+		if len(events) != 1 {
+			t.Fatalf("unexpected number of events: %v != 1", len(events))
+		}
+
+		event := events[0]
+		if event.Action != EventActionCorrupt {
+			t.Fatalf("unexpected event action: %q != %q", event.Action, EventActionCorrupt)
+		}
+		if event.Reason != EventReasonScrub {
+			t.Fatalf("unexpected event reason: %q != %q", event.Reason, EventReasonScrub)
+		}
+		if event.Source != source {
+			t.Fatalf("source not equal: %#v != %#v", event.Source, source)
+		}
+		if event.Target.Repository != "" {
+			t.Fatalf("unexpected repository on event target: %q", event.Target.Repository)
+		}
+		if event.Target.Digest != desc.Digest {
+			t.Fatalf("unexpected digest on event target: %q != %q", event.Target.Digest, desc.Digest)
+		}
+		if event.Target.Size != desc.Size {
+			t.Fatalf("unexpected size on event target: %d != %d", event.Target.Size, desc.Size)
+		}
+		return nil
+	}))
+
+	if err := l.BlobCorrupted(desc); err != nil {
+		t.Fatalf("unexpected error notifying corrupt blob: %v", err)
+	}
+}
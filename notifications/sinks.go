@@ -2,10 +2,13 @@ package notifications
 
 import (
 	"container/list"
+	"encoding/json"
 	"fmt"
+	"os"
 	"sync"
 	"time"
 
+	"github.com/docker/distribution/configuration"
 	"github.com/sirupsen/logrus"
 )
 
@@ -101,30 +104,52 @@ func (b *Broadcaster) run() {
 }
 
 // eventQueue accepts all messages into a queue for asynchronous consumption
-// by a sink. It is unbounded and thread safe but the sink must be reliable or
-// events will be dropped.
+// by a sink. It is thread safe but the sink must be reliable or events will be
+// dropped. The queue is unbounded unless a maximum size is set, in which case
+// events are dropped according to the queue policy once it is full.
 type eventQueue struct {
-	sink      Sink
-	events    *list.List
-	listeners []eventQueueListener
-	cond      *sync.Cond
-	mu        sync.Mutex
-	closed    bool
+	sink       Sink
+	events     *list.List
+	size       int // number of queued events
+	maxSize    int
+	policy     configuration.QueuePolicy
+	deadLetter Sink
+	listeners  []eventQueueListener
+	cond       *sync.Cond
+	mu         sync.Mutex
+	closed     bool
 }
 
 // eventQueueListener is called when various events happen on the queue.
 type eventQueueListener interface {
 	ingress(events ...Event)
 	egress(events ...Event)
+	dropped(events ...Event)
 }
 
-// newEventQueue returns a queue to the provided sink. If the updater is non-
-// nil, it will be called to update pending metrics on ingress and egress.
+// newEventQueue returns an unbounded queue to the provided sink. If the
+// updater is non-nil, it will be called to update pending metrics on ingress
+// and egress.
 func newEventQueue(sink Sink, listeners ...eventQueueListener) *eventQueue {
+	return newBoundedEventQueue(sink, 0, "", nil, listeners...)
+}
+
+// newBoundedEventQueue returns a queue to the provided sink holding up to
+// maxSize events, or unbounded if maxSize is not positive. Once the queue is
+// full, events are dropped according to policy, defaulting to dropping the
+// oldest ones. Dropped events are written to deadLetter, if not nil.
+func newBoundedEventQueue(sink Sink, maxSize int, policy configuration.QueuePolicy, deadLetter Sink, listeners ...eventQueueListener) *eventQueue {
+	if policy == "" {
+		policy = configuration.QueuePolicyDropOldest
+	}
+
 	eq := eventQueue{
-		sink:      sink,
-		events:    list.New(),
-		listeners: listeners,
+		sink:       sink,
+		events:     list.New(),
+		maxSize:    maxSize,
+		policy:     policy,
+		deadLetter: deadLetter,
+		listeners:  listeners,
 	}
 
 	eq.cond = sync.NewCond(&eq.mu)
@@ -133,22 +158,66 @@ func newEventQueue(sink Sink, listeners ...eventQueueListener) *eventQueue {
 }
 
 // Write accepts the events into the queue, only failing if the queue has
-// beend closed.
+// beend closed. If the queue is full, events are dropped and written to the
+// dead letter sink, if any.
 func (eq *eventQueue) Write(events ...Event) error {
+	dropped, err := eq.enqueue(events)
+	if err != nil {
+		return err
+	}
+
+	if len(dropped) > 0 {
+		if eq.deadLetter == nil {
+			logrus.Warnf("eventqueue: queue for %v is full, %d events dropped", eq.sink, len(dropped))
+		} else if err := eq.deadLetter.Write(dropped...); err != nil {
+			logrus.Errorf("eventqueue: error writing dropped events to %v, these events will be lost: %v", eq.deadLetter, err)
+		}
+	}
+
+	return nil
+}
+
+// enqueue adds the events to the queue, returning the events dropped to keep
+// the queue within its maximum size, if any. When dropping the oldest events,
+// a block larger than the maximum size is accepted once the queue is empty.
+func (eq *eventQueue) enqueue(events []Event) ([]Event, error) {
 	eq.mu.Lock()
 	defer eq.mu.Unlock()
 
 	if eq.closed {
-		return ErrSinkClosed
+		return nil, ErrSinkClosed
 	}
 
 	for _, listener := range eq.listeners {
 		listener.ingress(events...)
 	}
+
+	var dropped []Event
+	if eq.maxSize > 0 && eq.size+len(events) > eq.maxSize {
+		if eq.policy == configuration.QueuePolicyDropNewest {
+			for _, listener := range eq.listeners {
+				listener.dropped(events...)
+			}
+			return events, nil
+		}
+
+		for eq.size+len(events) > eq.maxSize && eq.events.Len() > 0 {
+			front := eq.events.Front()
+			block := front.Value.([]Event)
+			eq.events.Remove(front)
+			eq.size -= len(block)
+			for _, listener := range eq.listeners {
+				listener.dropped(block...)
+			}
+			dropped = append(dropped, block...)
+		}
+	}
+
 	eq.events.PushBack(events)
+	eq.size += len(events)
 	eq.cond.Signal() // signal waiters
 
-	return nil
+	return dropped, nil
 }
 
 // Close shuts down the event queue, flushing
@@ -206,6 +275,7 @@ func (eq *eventQueue) next() []Event {
 	front := eq.events.Front()
 	block := front.Value.([]Event)
 	eq.events.Remove(front)
+	eq.size -= len(block)
 
 	return block
 }
@@ -265,22 +335,25 @@ func (imts *ignoredSink) Write(events ...Event) error {
 	return imts.Sink.Write(results...)
 }
 
-// retryingSink retries the write until success or an ErrSinkClosed is
-// returned. Underlying sink must have p > 0 of succeeding or the sink will
+// retryingSink retries the write until success, an ErrSinkClosed is returned
+// or the maximum number of retries is exceeded. Without a maximum number of
+// retries, the underlying sink must have p > 0 of succeeding or the sink will
 // block. Internally, it is a circuit breaker retries to manage reset.
 // Concurrent calls to a retrying sink are serialized through the sink,
 // meaning that if one is in-flight, another will not proceed.
 type retryingSink struct {
-	mu     sync.Mutex
-	sink   Sink
-	closed bool
+	mu         sync.Mutex
+	sink       Sink
+	closed     bool
+	maxRetries int
 
 	// circuit breaker heuristics
 	failures struct {
-		threshold int
-		recent    int
-		last      time.Time
-		backoff   time.Duration // time after which we retry after failure.
+		threshold  int
+		recent     int
+		last       time.Time
+		backoff    time.Duration // time after which we retry after failure.
+		maxBackoff time.Duration // upper bound of the exponential backoff, if greater than backoff.
 	}
 }
 
@@ -289,29 +362,32 @@ type retryingSinkListener interface {
 	retry(events ...Event)
 }
 
-// TODO(stevvooe): We are using circuit break here, which actually doesn't
-// make a whole lot of sense for this use case, since we always retry. Move
-// this to use bounded exponential backoff.
-
 // newRetryingSink returns a sink that will retry writes to a sink, backing
-// off on failure. Parameters threshold and backoff adjust the behavior of the
-// circuit breaker.
-func newRetryingSink(sink Sink, threshold int, backoff time.Duration) *retryingSink {
+// off on failure. Parameters threshold, backoff and maxBackoff adjust the
+// behavior of the circuit breaker: once threshold consecutive failures are
+// reached, the backoff doubles with every failure up to maxBackoff, or stays
+// fixed if maxBackoff is not greater than backoff. If maxRetries is positive,
+// the sink gives up on the events after as many retries.
+func newRetryingSink(sink Sink, threshold int, backoff, maxBackoff time.Duration, maxRetries int) *retryingSink {
 	rs := &retryingSink{
-		sink: sink,
+		sink:       sink,
+		maxRetries: maxRetries,
 	}
 	rs.failures.threshold = threshold
 	rs.failures.backoff = backoff
+	rs.failures.maxBackoff = maxBackoff
 
 	return rs
 }
 
-// Write attempts to flush the events to the downstream sink until it succeeds
-// or the sink is closed.
+// Write attempts to flush the events to the downstream sink until it
+// succeeds, the sink is closed or the maximum number of retries is exceeded.
 func (rs *retryingSink) Write(events ...Event) error {
 	rs.mu.Lock()
 	defer rs.mu.Unlock()
 
+	var retries int
+
 retry:
 
 	if rs.closed {
@@ -319,8 +395,9 @@ retry:
 	}
 
 	if !rs.proceed() {
-		logrus.Warnf("%v encountered too many errors, backing off", rs.sink)
-		rs.wait(rs.failures.backoff)
+		backoff := rs.backoff()
+		logrus.Warnf("%v encountered too many errors, backing off for %s", rs.sink, backoff)
+		rs.wait(backoff)
 		goto retry
 	}
 
@@ -330,6 +407,11 @@ retry:
 			return err
 		}
 
+		if rs.maxRetries > 0 && retries >= rs.maxRetries {
+			return fmt.Errorf("retryingsink: giving up after %d retries: %w", retries, err)
+		}
+		retries++
+
 		logrus.Errorf("retryingsink: error writing events: %v, retrying", err)
 		goto retry
 	}
@@ -388,5 +470,264 @@ func (rs *retryingSink) failure() {
 // heuristics.
 func (rs *retryingSink) proceed() bool {
 	return rs.failures.recent < rs.failures.threshold ||
-		time.Now().UTC().After(rs.failures.last.Add(rs.failures.backoff))
+		time.Now().UTC().After(rs.failures.last.Add(rs.backoff()))
+}
+
+// backoff returns the time to wait after the last failure before retrying.
+// It doubles with every failure past the threshold, up to maxBackoff.
+func (rs *retryingSink) backoff() time.Duration {
+	backoff := rs.failures.backoff
+	for i := rs.failures.threshold; i < rs.failures.recent && backoff < rs.failures.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if rs.failures.maxBackoff > rs.failures.backoff && backoff > rs.failures.maxBackoff {
+		backoff = rs.failures.maxBackoff
+	}
+
+	return backoff
+}
+
+// deadLetterSink writes events that the underlying sink failed to write to a
+// dead letter sink, so that they are not lost.
+type deadLetterSink struct {
+	Sink
+	deadLetter Sink
+}
+
+func newDeadLetterSink(sink, deadLetter Sink) *deadLetterSink {
+	return &deadLetterSink{
+		Sink:       sink,
+		deadLetter: deadLetter,
+	}
+}
+
+// Write writes the events to the underlying sink, falling back to the dead
+// letter sink on failure. An error is only returned if both fail or if the
+// sink is closed.
+func (dls *deadLetterSink) Write(events ...Event) error {
+	err := dls.Sink.Write(events...)
+	if err == nil || err == ErrSinkClosed {
+		return err
+	}
+
+	logrus.Warnf("deadlettersink: error writing events to %v, writing them to %v: %v", dls.Sink, dls.deadLetter, err)
+	if dlErr := dls.deadLetter.Write(events...); dlErr != nil {
+		return fmt.Errorf("deadlettersink: error writing events to %v: %v, after: %w", dls.deadLetter, dlErr, err)
+	}
+
+	return nil
+}
+
+// Close closes the underlying and dead letter sinks.
+func (dls *deadLetterSink) Close() error {
+	err := dls.Sink.Close()
+	if dlErr := dls.deadLetter.Close(); dlErr != nil && err == nil {
+		err = dlErr
+	}
+
+	return err
+}
+
+// fileSink appends events to a file, one envelope per line. It is meant to be
+// used as a dead letter sink, from which events can be replayed manually.
+type fileSink struct {
+	mu   sync.Mutex
+	f    *os.File
+	path string
+}
+
+// newFileSink opens (or creates) the file at path for appending events.
+func newFileSink(path string) (*fileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("opening events file: %w", err)
+	}
+
+	return &fileSink{f: f, path: path}, nil
+}
+
+// Write appends the events to the file as a single envelope.
+func (fs *fileSink) Write(events ...Event) error {
+	p, err := json.Marshal(Envelope{Events: events})
+	if err != nil {
+		return fmt.Errorf("%v: error marshaling event envelope: %w", fs, err)
+	}
+	p = append(p, '\n')
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if _, err := fs.f.Write(p); err != nil {
+		return fmt.Errorf("%v: error writing events: %w", fs, err)
+	}
+
+	return nil
+}
+
+// Close closes the file.
+func (fs *fileSink) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.f.Close()
+}
+
+func (fs *fileSink) String() string {
+	return fmt.Sprintf("fileSink{%s}", fs.path)
+}
+
+// BatchingSink accumulates events and writes them to the underlying sink in
+// blocks, either once maxBatchSize events are pending or after flushInterval
+// elapses since the first pending event, whichever comes first. This is
+// useful to avoid flooding endpoints with one request per event when a large
+// number of events is generated at once, such as during garbage collection.
+type BatchingSink struct {
+	mu            sync.Mutex
+	sink          Sink
+	pending       []Event
+	maxBatchSize  int
+	flushInterval time.Duration
+	timer         *time.Timer
+	closed        bool
+}
+
+const (
+	defaultBatchingSinkMaxBatchSize  = 100
+	defaultBatchingSinkFlushInterval = time.Second
+)
+
+// NewBatchingSink returns a BatchingSink that writes to sink. Non-positive
+// values of maxBatchSize and flushInterval are replaced by a default of 100
+// events and 1 second, respectively.
+func NewBatchingSink(sink Sink, maxBatchSize int, flushInterval time.Duration) *BatchingSink {
+	if maxBatchSize <= 0 {
+		maxBatchSize = defaultBatchingSinkMaxBatchSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = defaultBatchingSinkFlushInterval
+	}
+
+	return &BatchingSink{
+		sink:          sink,
+		maxBatchSize:  maxBatchSize,
+		flushInterval: flushInterval,
+	}
+}
+
+// Write appends events to the pending batch, flushing it to the underlying
+// sink if full.
+func (bs *BatchingSink) Write(events ...Event) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if bs.closed {
+		return ErrSinkClosed
+	}
+
+	for _, e := range events {
+		bs.pending = append(bs.pending, e)
+		if len(bs.pending) >= bs.maxBatchSize {
+			if err := bs.flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(bs.pending) > 0 && bs.timer == nil {
+		bs.timer = time.AfterFunc(bs.flushInterval, bs.flushOnTimer)
+	}
+
+	return nil
+}
+
+// Flush writes all pending events to the underlying sink.
+func (bs *BatchingSink) Flush() error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	return bs.flush()
+}
+
+// Close flushes all pending events and closes the underlying sink.
+func (bs *BatchingSink) Close() error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if bs.closed {
+		return fmt.Errorf("batchingsink: already closed")
+	}
+	bs.closed = true
+
+	if err := bs.flush(); err != nil {
+		logrus.Errorf("batchingsink: error flushing events on close, these events will be lost: %v", err)
+	}
+
+	return bs.sink.Close()
+}
+
+func (bs *BatchingSink) flushOnTimer() {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if err := bs.flush(); err != nil {
+		logrus.Errorf("batchingsink: error writing events to %v, these events will be lost: %v", bs.sink, err)
+	}
+}
+
+// flush writes all pending events to the underlying sink. Must be called with
+// the mutex held.
+func (bs *BatchingSink) flush() error {
+	if bs.timer != nil {
+		bs.timer.Stop()
+		bs.timer = nil
+	}
+	if len(bs.pending) == 0 {
+		return nil
+	}
+
+	block := bs.pending
+	bs.pending = nil
+
+	return bs.sink.Write(block...)
+}
+
+// SwappableSink forwards events to a sink that can be replaced at runtime,
+// allowing endpoints to be reconfigured without restarting the registry.
+type SwappableSink struct {
+	mu   sync.RWMutex
+	sink Sink
+}
+
+// NewSwappableSink returns a SwappableSink that initially writes to sink.
+func NewSwappableSink(sink Sink) *SwappableSink {
+	return &SwappableSink{sink: sink}
+}
+
+// Write writes events to the current sink.
+func (ss *SwappableSink) Write(events ...Event) error {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	return ss.sink.Write(events...)
+}
+
+// Swap replaces the current sink with sink, returning the previous one. Swap
+// waits for in-flight writes to the previous sink to complete, but does not
+// close it, which is left to the caller.
+func (ss *SwappableSink) Swap(sink Sink) Sink {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	old := ss.sink
+	ss.sink = sink
+
+	return old
+}
+
+// Close closes the current sink.
+func (ss *SwappableSink) Close() error {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	return ss.sink.Close()
 }
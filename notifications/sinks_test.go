@@ -1,12 +1,17 @@
 package notifications
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sync"
 	"time"
 
+	"github.com/docker/distribution/configuration"
 	"github.com/sirupsen/logrus"
 
 	"testing"
@@ -118,6 +123,54 @@ func TestEventQueue(t *testing.T) {
 	}
 }
 
+func TestEventQueueBounded(t *testing.T) {
+	for _, c := range []struct {
+		policy       configuration.QueuePolicy
+		delivered    []string
+		deadLettered []string
+	}{
+		{configuration.QueuePolicyDropOldest, []string{"e0", "e2", "e3"}, []string{"e1"}},
+		{configuration.QueuePolicyDropNewest, []string{"e0", "e1", "e2"}, []string{"e3"}},
+	} {
+		t.Run(string(c.policy), func(t *testing.T) {
+			var ts, dl testSink
+			metrics := newSafeMetrics()
+			bs := &blockingSink{Sink: &ts, started: make(chan struct{}, 10), release: make(chan struct{})}
+			eq := newBoundedEventQueue(bs, 2, c.policy, &dl, metrics.eventQueueListener())
+
+			if err := eq.Write(createTestEvent("push", "e0", "blob")); err != nil {
+				t.Fatalf("unexpected error writing event: %v", err)
+			}
+			// wait for the first event to be in-flight, so that it no longer counts towards the queue size
+			<-bs.started
+			for _, repo := range []string{"e1", "e2", "e3"} {
+				if err := eq.Write(createTestEvent("push", repo, "blob")); err != nil {
+					t.Fatalf("unexpected error writing event: %v", err)
+				}
+			}
+
+			close(bs.release)
+			checkClose(t, eq)
+
+			if got := testSinkRepositories(&ts); !reflect.DeepEqual(got, c.delivered) {
+				t.Fatalf("unexpected delivered events: %v != %v", got, c.delivered)
+			}
+			if got := testSinkRepositories(&dl); !reflect.DeepEqual(got, c.deadLettered) {
+				t.Fatalf("unexpected dead lettered events: %v != %v", got, c.deadLettered)
+			}
+
+			metrics.Lock()
+			defer metrics.Unlock()
+			if metrics.Dropped != 1 {
+				t.Fatalf("unexpected dropped count: %d != %d", metrics.Dropped, 1)
+			}
+			if metrics.Pending != 0 {
+				t.Fatalf("unexpected pending count: %d != %d", metrics.Pending, 0)
+			}
+		})
+	}
+}
+
 func TestIgnoredSink(t *testing.T) {
 	blob := createTestEvent("push", "library/test", "blob")
 	manifest := createTestEvent("pull", "library/test", "manifest")
@@ -162,7 +215,7 @@ func TestRetryingSink(t *testing.T) {
 		rate: 1.0, // start out always failing.
 		Sink: &ts,
 	}
-	s := newRetryingSink(flaky, 3, 10*time.Millisecond)
+	s := newRetryingSink(flaky, 3, 10*time.Millisecond, 0, 0)
 
 	var wg sync.WaitGroup
 	var block []Event
@@ -203,6 +256,227 @@ func TestRetryingSink(t *testing.T) {
 	}
 }
 
+func TestRetryingSinkMaxRetries(t *testing.T) {
+	var ts testSink
+	flaky := &flakySink{
+		rate: 1.0, // always failing
+		Sink: &ts,
+	}
+	counting := &countingSink{Sink: flaky}
+	s := newRetryingSink(counting, 1, time.Millisecond, 0, 3)
+
+	if err := s.Write(createTestEvent("push", "library/test", "blob")); err == nil {
+		t.Fatalf("expected an error after exceeding the maximum number of retries")
+	}
+	if counting.writes != 4 {
+		t.Fatalf("unexpected number of writes: %d != %d", counting.writes, 4)
+	}
+
+	// the next write succeeds without retrying the events given up on
+	flaky.rate = 0
+	if err := s.Write(createTestEvent("push", "library/test", "blob")); err != nil {
+		t.Fatalf("unexpected error writing event: %v", err)
+	}
+	checkClose(t, s)
+
+	if len(ts.events) != 1 {
+		t.Fatalf("unexpected number of events written: %d != %d", len(ts.events), 1)
+	}
+}
+
+func TestRetryingSinkBackoff(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		maxBackoff time.Duration
+		recent     int
+		expected   time.Duration
+	}{
+		{"below threshold", 0, 1, time.Second},
+		{"fixed", 0, 10, time.Second},
+		{"fixed when max backoff is lower", time.Millisecond, 10, time.Second},
+		{"at threshold", time.Minute, 3, time.Second},
+		{"past threshold", time.Minute, 5, 4 * time.Second},
+		{"capped", time.Minute, 20, time.Minute},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s := newRetryingSink(&testSink{}, 3, time.Second, c.maxBackoff, 0)
+			s.failures.recent = c.recent
+
+			if got := s.backoff(); got != c.expected {
+				t.Fatalf("unexpected backoff: %s != %s", got, c.expected)
+			}
+		})
+	}
+}
+
+func TestDeadLetterSink(t *testing.T) {
+	var ts, dl testSink
+	flaky := &flakySink{
+		rate: 1.0, // always failing
+		Sink: &ts,
+	}
+	s := newDeadLetterSink(flaky, &dl)
+
+	if err := s.Write(createTestEvent("push", "e0", "blob")); err != nil {
+		t.Fatalf("unexpected error writing event: %v", err)
+	}
+	flaky.rate = 0
+	if err := s.Write(createTestEvent("push", "e1", "blob")); err != nil {
+		t.Fatalf("unexpected error writing event: %v", err)
+	}
+
+	if got := testSinkRepositories(&ts); !reflect.DeepEqual(got, []string{"e1"}) {
+		t.Fatalf("unexpected delivered events: %v", got)
+	}
+	if got := testSinkRepositories(&dl); !reflect.DeepEqual(got, []string{"e0"}) {
+		t.Fatalf("unexpected dead lettered events: %v", got)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+	if !ts.closed || !dl.closed {
+		t.Fatalf("sinks should have been closed")
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	s, err := newFileSink(path)
+	if err != nil {
+		t.Fatalf("unexpected error creating file sink: %v", err)
+	}
+
+	blocks := [][]Event{
+		{createTestEvent("push", "e0", "blob")},
+		{createTestEvent("push", "e1", "blob"), createTestEvent("push", "e2", "blob")},
+	}
+	for _, block := range blocks {
+		if err := s.Write(block...); err != nil {
+			t.Fatalf("unexpected error writing events: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error opening file: %v", err)
+	}
+	defer f.Close()
+
+	var lines int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var envelope Envelope
+		if err := json.Unmarshal(scanner.Bytes(), &envelope); err != nil {
+			t.Fatalf("unexpected error decoding envelope: %v", err)
+		}
+		if len(envelope.Events) != len(blocks[lines]) {
+			t.Fatalf("unexpected number of events in envelope %d: %d != %d", lines, len(envelope.Events), len(blocks[lines]))
+		}
+		lines++
+	}
+	if lines != len(blocks) {
+		t.Fatalf("unexpected number of envelopes: %d != %d", lines, len(blocks))
+	}
+}
+
+func TestBatchingSink(t *testing.T) {
+	ts := &testSink{}
+	// a long flush interval, so that only the batch size triggers writes
+	bs := NewBatchingSink(ts, 10, time.Hour)
+
+	for i := 0; i < 25; i++ {
+		if err := bs.Write(createTestEvent("delete", "library/test", "blob")); err != nil {
+			t.Fatalf("unexpected error writing event: %v", err)
+		}
+	}
+
+	ts.mu.Lock()
+	if len(ts.events) != 20 {
+		t.Fatalf("unexpected number of events written before close: %d != %d", len(ts.events), 20)
+	}
+	ts.mu.Unlock()
+
+	checkClose(t, bs)
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if len(ts.events) != 25 {
+		t.Fatalf("pending events not flushed on close: %d != %d", len(ts.events), 25)
+	}
+	if !ts.closed {
+		t.Fatalf("sink should have been closed")
+	}
+}
+
+func TestBatchingSinkFlushInterval(t *testing.T) {
+	ts := &testSink{}
+	bs := NewBatchingSink(ts, 100, 10*time.Millisecond)
+	defer bs.Close()
+
+	if err := bs.Write(createTestEvent("delete", "library/test", "blob")); err != nil {
+		t.Fatalf("unexpected error writing event: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ts.mu.Lock()
+		n := len(ts.events)
+		ts.mu.Unlock()
+
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pending event not flushed after interval")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBatchingSinkDefaults(t *testing.T) {
+	bs := NewBatchingSink(&testSink{}, 0, 0)
+
+	if bs.maxBatchSize != defaultBatchingSinkMaxBatchSize {
+		t.Fatalf("unexpected max batch size: %d != %d", bs.maxBatchSize, defaultBatchingSinkMaxBatchSize)
+	}
+	if bs.flushInterval != defaultBatchingSinkFlushInterval {
+		t.Fatalf("unexpected flush interval: %s != %s", bs.flushInterval, defaultBatchingSinkFlushInterval)
+	}
+}
+
+func TestSwappableSink(t *testing.T) {
+	first, second := &testSink{}, &testSink{}
+	ss := NewSwappableSink(first)
+
+	if err := ss.Write(createTestEvent("push", "library/test", "blob")); err != nil {
+		t.Fatalf("unexpected error writing event: %v", err)
+	}
+
+	if old := ss.Swap(second); old != first {
+		t.Fatalf("unexpected sink returned by swap: %v", old)
+	}
+
+	if err := ss.Write(createTestEvent("push", "library/test", "blob"), createTestEvent("push", "library/test", "blob")); err != nil {
+		t.Fatalf("unexpected error writing events: %v", err)
+	}
+
+	if err := ss.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	if len(first.events) != 1 || first.closed {
+		t.Fatalf("previous sink should have received 1 event and be left open: %d, %v", len(first.events), first.closed)
+	}
+	if len(second.events) != 2 || !second.closed {
+		t.Fatalf("current sink should have received 2 events and be closed: %d, %v", len(second.events), second.closed)
+	}
+}
+
 type testSink struct {
 	events []Event
 	mu     sync.Mutex
@@ -248,6 +522,43 @@ func (fs *flakySink) Write(events ...Event) error {
 	return fs.Sink.Write(events...)
 }
 
+// blockingSink signals each write on started and blocks it until release is
+// closed.
+type blockingSink struct {
+	Sink
+	started chan struct{}
+	release chan struct{}
+}
+
+func (bs *blockingSink) Write(events ...Event) error {
+	bs.started <- struct{}{}
+	<-bs.release
+	return bs.Sink.Write(events...)
+}
+
+type countingSink struct {
+	Sink
+	writes int
+}
+
+func (cs *countingSink) Write(events ...Event) error {
+	cs.writes++
+	return cs.Sink.Write(events...)
+}
+
+// testSinkRepositories returns the target repository of the events written to
+// ts, used to identify them.
+func testSinkRepositories(ts *testSink) []string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	var repos []string
+	for _, e := range ts.events {
+		repos = append(repos, e.Target.Repository)
+	}
+	return repos
+}
+
 func checkClose(t *testing.T, sink Sink) {
 	if err := sink.Close(); err != nil {
 		t.Fatalf("unexpected error closing: %v", err)
@@ -0,0 +1,121 @@
+package registry
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"strings"
+
+	"github.com/docker/distribution/configuration"
+	dcontext "github.com/docker/distribution/context"
+	adminv1 "github.com/docker/distribution/registry/api/admin/v1"
+	"github.com/docker/distribution/registry/handlers"
+	"gitlab.com/gitlab-org/labkit/correlation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// newAdminServer creates the gRPC admin server, serving the admin API of app. Calls are authenticated with the
+// configured bearer token and/or client certificates, at least one of which is required.
+func newAdminServer(ctx context.Context, config *configuration.Configuration, app *handlers.App) (*grpc.Server, error) {
+	cfg := config.Admin.GRPC
+	if !config.Database.Enabled {
+		return nil, errors.New("the admin gRPC API requires the metadata database to be enabled")
+	}
+	if cfg.Token == "" && len(cfg.TLS.ClientCAs) == 0 {
+		return nil, errors.New("the admin gRPC API requires a token or client certificate authentication")
+	}
+	if len(cfg.TLS.ClientCAs) > 0 && cfg.TLS.Certificate == "" {
+		return nil, errors.New("admin gRPC API client certificate authentication requires a TLS certificate")
+	}
+
+	a := &adminAuthenticator{ctx: ctx, token: cfg.Token}
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(a.unaryInterceptor),
+		grpc.StreamInterceptor(a.streamInterceptor),
+	}
+	if cfg.TLS.Certificate != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.Certificate, cfg.TLS.Key)
+		if err != nil {
+			return nil, err
+		}
+		tlsConf := &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+		if len(cfg.TLS.ClientCAs) > 0 {
+			pool, err := loadCertPool(cfg.TLS.ClientCAs)
+			if err != nil {
+				return nil, err
+			}
+			tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+			tlsConf.ClientCAs = pool
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConf)))
+	}
+
+	s := grpc.NewServer(opts...)
+	adminv1.RegisterAdminServer(s, app.AdminServer())
+
+	return s, nil
+}
+
+// adminAuthenticator verifies the bearer token of admin calls and injects a logger with a correlation ID in the
+// context of each call.
+type adminAuthenticator struct {
+	ctx   context.Context
+	token string
+}
+
+func (a *adminAuthenticator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	id := correlation.SafeRandomID()
+	log := dcontext.GetLogger(a.ctx).WithField("correlation_id", id).WithField("grpc_method", method)
+	ctx = dcontext.WithLogger(correlation.ContextWithCorrelation(ctx, id), log)
+
+	if a.token == "" {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		token := strings.TrimPrefix(v, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1 {
+			return ctx, nil
+		}
+	}
+
+	log.Warn("unauthenticated admin API call")
+	return nil, status.Error(codes.Unauthenticated, "invalid or missing bearer token")
+}
+
+func (a *adminAuthenticator) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (a *adminAuthenticator) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &adminServerStream{ServerStream: ss, ctx: ctx})
+}
+
+// adminServerStream overrides the context of a grpc.ServerStream.
+type adminServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *adminServerStream) Context() context.Context {
+	return s.ctx
+}
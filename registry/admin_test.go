@@ -0,0 +1,84 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAdminAuthenticator(t *testing.T) {
+	tcs := []struct {
+		name     string
+		token    string
+		header   []string
+		wantCode codes.Code
+	}{
+		{
+			name:     "no token configured",
+			wantCode: codes.OK,
+		},
+		{
+			name:     "valid token",
+			token:    "secret",
+			header:   []string{"Bearer secret"},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "invalid token",
+			token:    "secret",
+			header:   []string{"Bearer foo"},
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "missing token",
+			token:    "secret",
+			wantCode: codes.Unauthenticated,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			a := &adminAuthenticator{ctx: context.Background(), token: tc.token}
+
+			ctx := context.Background()
+			if tc.header != nil {
+				ctx = metadata.NewIncomingContext(ctx, metadata.MD{"authorization": tc.header})
+			}
+
+			_, err := a.authenticate(ctx, "/registry.admin.v1.Admin/GetGCQueues")
+			require.Equal(t, tc.wantCode, status.Code(err))
+		})
+	}
+}
+
+func TestNewAdminServer_RequiresDatabase(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.Admin.GRPC.Addr = "localhost:0"
+
+	_, err := newAdminServer(context.Background(), config, nil)
+	require.Error(t, err)
+}
+
+func TestNewAdminServer_RequiresAuthentication(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.Database.Enabled = true
+	config.Admin.GRPC.Addr = "localhost:0"
+
+	_, err := newAdminServer(context.Background(), config, nil)
+	require.EqualError(t, err, "the admin gRPC API requires a token or client certificate authentication")
+}
+
+func TestNewAdminServer_ClientCAsRequireCertificate(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.Database.Enabled = true
+	config.Admin.GRPC.Addr = "localhost:0"
+	config.Admin.GRPC.TLS.ClientCAs = []string{"/path/to/ca.pem"}
+
+	_, err := newAdminServer(context.Background(), config, nil)
+	require.EqualError(t, err, "admin gRPC API client certificate authentication requires a TLS certificate")
+}
@@ -0,0 +1,314 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.13.0
+// source: admin.proto
+
+package v1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// AdminClient is the client API for Admin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AdminClient interface {
+	// ListRepositories streams repositories sorted by path.
+	ListRepositories(ctx context.Context, in *ListRepositoriesRequest, opts ...grpc.CallOption) (Admin_ListRepositoriesClient, error)
+	// ListTags streams the tags of a repository sorted by name.
+	ListTags(ctx context.Context, in *ListTagsRequest, opts ...grpc.CallOption) (Admin_ListTagsClient, error)
+	// DeleteTag deletes a tag from a repository. The manifest it pointed to is left for the online garbage collector.
+	DeleteTag(ctx context.Context, in *DeleteTagRequest, opts ...grpc.CallOption) (*DeleteTagResponse, error)
+	// GetGCQueues returns the size of the online garbage collection review queues.
+	GetGCQueues(ctx context.Context, in *GetGCQueuesRequest, opts ...grpc.CallOption) (*GCQueues, error)
+	// GetImportProgress returns the progress of the migration of repositories to the metadata database.
+	GetImportProgress(ctx context.Context, in *GetImportProgressRequest, opts ...grpc.CallOption) (*ImportProgress, error)
+}
+
+type adminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminClient(cc grpc.ClientConnInterface) AdminClient {
+	return &adminClient{cc}
+}
+
+func (c *adminClient) ListRepositories(ctx context.Context, in *ListRepositoriesRequest, opts ...grpc.CallOption) (Admin_ListRepositoriesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Admin_ServiceDesc.Streams[0], "/registry.admin.v1.Admin/ListRepositories", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &adminListRepositoriesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Admin_ListRepositoriesClient interface {
+	Recv() (*Repository, error)
+	grpc.ClientStream
+}
+
+type adminListRepositoriesClient struct {
+	grpc.ClientStream
+}
+
+func (x *adminListRepositoriesClient) Recv() (*Repository, error) {
+	m := new(Repository)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *adminClient) ListTags(ctx context.Context, in *ListTagsRequest, opts ...grpc.CallOption) (Admin_ListTagsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Admin_ServiceDesc.Streams[1], "/registry.admin.v1.Admin/ListTags", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &adminListTagsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Admin_ListTagsClient interface {
+	Recv() (*Tag, error)
+	grpc.ClientStream
+}
+
+type adminListTagsClient struct {
+	grpc.ClientStream
+}
+
+func (x *adminListTagsClient) Recv() (*Tag, error) {
+	m := new(Tag)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *adminClient) DeleteTag(ctx context.Context, in *DeleteTagRequest, opts ...grpc.CallOption) (*DeleteTagResponse, error) {
+	out := new(DeleteTagResponse)
+	err := c.cc.Invoke(ctx, "/registry.admin.v1.Admin/DeleteTag", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetGCQueues(ctx context.Context, in *GetGCQueuesRequest, opts ...grpc.CallOption) (*GCQueues, error) {
+	out := new(GCQueues)
+	err := c.cc.Invoke(ctx, "/registry.admin.v1.Admin/GetGCQueues", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetImportProgress(ctx context.Context, in *GetImportProgressRequest, opts ...grpc.CallOption) (*ImportProgress, error) {
+	out := new(ImportProgress)
+	err := c.cc.Invoke(ctx, "/registry.admin.v1.Admin/GetImportProgress", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServer is the server API for Admin service.
+// All implementations must embed UnimplementedAdminServer
+// for forward compatibility
+type AdminServer interface {
+	// ListRepositories streams repositories sorted by path.
+	ListRepositories(*ListRepositoriesRequest, Admin_ListRepositoriesServer) error
+	// ListTags streams the tags of a repository sorted by name.
+	ListTags(*ListTagsRequest, Admin_ListTagsServer) error
+	// DeleteTag deletes a tag from a repository. The manifest it pointed to is left for the online garbage collector.
+	DeleteTag(context.Context, *DeleteTagRequest) (*DeleteTagResponse, error)
+	// GetGCQueues returns the size of the online garbage collection review queues.
+	GetGCQueues(context.Context, *GetGCQueuesRequest) (*GCQueues, error)
+	// GetImportProgress returns the progress of the migration of repositories to the metadata database.
+	GetImportProgress(context.Context, *GetImportProgressRequest) (*ImportProgress, error)
+	mustEmbedUnimplementedAdminServer()
+}
+
+// UnimplementedAdminServer must be embedded to have forward compatible implementations.
+type UnimplementedAdminServer struct {
+}
+
+func (UnimplementedAdminServer) ListRepositories(*ListRepositoriesRequest, Admin_ListRepositoriesServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListRepositories not implemented")
+}
+func (UnimplementedAdminServer) ListTags(*ListTagsRequest, Admin_ListTagsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListTags not implemented")
+}
+func (UnimplementedAdminServer) DeleteTag(context.Context, *DeleteTagRequest) (*DeleteTagResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteTag not implemented")
+}
+func (UnimplementedAdminServer) GetGCQueues(context.Context, *GetGCQueuesRequest) (*GCQueues, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGCQueues not implemented")
+}
+func (UnimplementedAdminServer) GetImportProgress(context.Context, *GetImportProgressRequest) (*ImportProgress, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetImportProgress not implemented")
+}
+func (UnimplementedAdminServer) mustEmbedUnimplementedAdminServer() {}
+
+// UnsafeAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServer will
+// result in compilation errors.
+type UnsafeAdminServer interface {
+	mustEmbedUnimplementedAdminServer()
+}
+
+func RegisterAdminServer(s grpc.ServiceRegistrar, srv AdminServer) {
+	s.RegisterService(&Admin_ServiceDesc, srv)
+}
+
+func _Admin_ListRepositories_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListRepositoriesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AdminServer).ListRepositories(m, &adminListRepositoriesServer{stream})
+}
+
+type Admin_ListRepositoriesServer interface {
+	Send(*Repository) error
+	grpc.ServerStream
+}
+
+type adminListRepositoriesServer struct {
+	grpc.ServerStream
+}
+
+func (x *adminListRepositoriesServer) Send(m *Repository) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Admin_ListTags_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListTagsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AdminServer).ListTags(m, &adminListTagsServer{stream})
+}
+
+type Admin_ListTagsServer interface {
+	Send(*Tag) error
+	grpc.ServerStream
+}
+
+type adminListTagsServer struct {
+	grpc.ServerStream
+}
+
+func (x *adminListTagsServer) Send(m *Tag) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Admin_DeleteTag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteTagRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).DeleteTag(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/registry.admin.v1.Admin/DeleteTag",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).DeleteTag(ctx, req.(*DeleteTagRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_GetGCQueues_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetGCQueuesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetGCQueues(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/registry.admin.v1.Admin/GetGCQueues",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetGCQueues(ctx, req.(*GetGCQueuesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_GetImportProgress_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetImportProgressRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetImportProgress(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/registry.admin.v1.Admin/GetImportProgress",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetImportProgress(ctx, req.(*GetImportProgressRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Admin_ServiceDesc is the grpc.ServiceDesc for Admin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Admin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "registry.admin.v1.Admin",
+	HandlerType: (*AdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "DeleteTag",
+			Handler:    _Admin_DeleteTag_Handler,
+		},
+		{
+			MethodName: "GetGCQueues",
+			Handler:    _Admin_GetGCQueues_Handler,
+		},
+		{
+			MethodName: "GetImportProgress",
+			Handler:    _Admin_GetImportProgress_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListRepositories",
+			Handler:       _Admin_ListRepositories_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ListTags",
+			Handler:       _Admin_ListTags_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "admin.proto",
+}
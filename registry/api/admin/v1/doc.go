@@ -0,0 +1,4 @@
+// Package v1 defines the gRPC admin API, an optional set of administrative operations served on a separate listener.
+package v1
+
+//go:generate protoc --go_out=paths=source_relative:. --go-grpc_out=paths=source_relative:. admin.proto
@@ -73,6 +73,26 @@ var (
 		service too many times`,
 		HTTPStatusCode: http.StatusTooManyRequests,
 	})
+
+	// ErrorCodeRequestTooLarge is returned if the body of a request exceeds
+	// the configured maximum size.
+	ErrorCodeRequestTooLarge = Register("errcode", ErrorDescriptor{
+		Value:   "REQUESTTOOLARGE",
+		Message: "request body too large",
+		Description: `Returned when the body of a request exceeds the
+		maximum size allowed by the registry.`,
+		HTTPStatusCode: http.StatusRequestEntityTooLarge,
+	})
+
+	// ErrorCodeRequestTimeout is returned if a client transfers the body of
+	// a request too slowly.
+	ErrorCodeRequestTimeout = Register("errcode", ErrorDescriptor{
+		Value:   "REQUESTTIMEOUT",
+		Message: "request body transferred too slowly",
+		Description: `Returned when a client transfers the body of a
+		request below the minimum rate required by the registry.`,
+		HTTPStatusCode: http.StatusRequestTimeout,
+	})
 )
 
 var nextCode = 1000
@@ -0,0 +1,76 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/docker/distribution/registry/api/errcode"
+)
+
+const errGroup = "gitlab.api.v1"
+
+var (
+	// ErrorCodeInvalidQueryParamValue is returned when the value of a query parameter is invalid.
+	ErrorCodeInvalidQueryParamValue = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:          "INVALID_QUERY_PARAMETER_VALUE",
+		Message:        "the value of a query parameter is invalid",
+		Description:    `The value of a request query parameter is invalid. The error detail identifies the offending parameter and value.`,
+		HTTPStatusCode: http.StatusBadRequest,
+	})
+
+	// ErrorCodeRepositoryArchived is returned when attempting to write to an archived repository.
+	ErrorCodeRepositoryArchived = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:          "REPOSITORY_ARCHIVED",
+		Message:        "repository is archived",
+		Description:    `The repository is archived and therefore read-only. It must be unarchived before any content can be pushed to or deleted from it.`,
+		HTTPStatusCode: http.StatusForbidden,
+	})
+
+	// ErrorCodeRepositoryExists is returned when attempting to rename a repository to the path of an existing one.
+	ErrorCodeRepositoryExists = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:          "REPOSITORY_EXISTS",
+		Message:        "repository already exists",
+		Description:    `A repository already exists at the requested destination path.`,
+		HTTPStatusCode: http.StatusConflict,
+	})
+
+	// ErrorCodeStorageMoveExists is returned when attempting to schedule the storage move of a repository that was
+	// already scheduled.
+	ErrorCodeStorageMoveExists = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:          "STORAGE_MOVE_EXISTS",
+		Message:        "storage move already exists",
+		Description:    `A storage move was already scheduled for the repository.`,
+		HTTPStatusCode: http.StatusConflict,
+	})
+
+	// ErrorCodeStorageMoveUnknown is returned when the storage move of a repository is not found.
+	ErrorCodeStorageMoveUnknown = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:          "STORAGE_MOVE_UNKNOWN",
+		Message:        "storage move unknown",
+		Description:    `No storage move was scheduled for the repository.`,
+		HTTPStatusCode: http.StatusNotFound,
+	})
+
+	// ErrorCodeRetentionPolicyInvalid is returned when a retention policy is invalid.
+	ErrorCodeRetentionPolicyInvalid = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:          "RETENTION_POLICY_INVALID",
+		Message:        "invalid retention policy",
+		Description:    `The retention policy in the request body is malformed or invalid. The error detail describes the problem.`,
+		HTTPStatusCode: http.StatusBadRequest,
+	})
+
+	// ErrorCodeRetentionPolicyUnknown is returned when a repository has no retention policy.
+	ErrorCodeRetentionPolicyUnknown = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:          "RETENTION_POLICY_UNKNOWN",
+		Message:        "retention policy unknown",
+		Description:    `No retention policy was set for the repository.`,
+		HTTPStatusCode: http.StatusNotFound,
+	})
+
+	// ErrorCodeRepositoryStatisticsUnknown is returned when the statistics of a repository were never calculated.
+	ErrorCodeRepositoryStatisticsUnknown = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:          "REPOSITORY_STATISTICS_UNKNOWN",
+		Message:        "repository statistics unknown",
+		Description:    `The statistics of the repository were never calculated, and can not be calculated by a read-only registry.`,
+		HTTPStatusCode: http.StatusNotFound,
+	})
+)
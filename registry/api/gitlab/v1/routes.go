@@ -0,0 +1,190 @@
+// Package v1 defines the routes and errors of the GitLab v1 API, a set of registry extensions that are not part of
+// the OCI Distribution specification.
+package v1
+
+import (
+	"github.com/docker/distribution/reference"
+	"github.com/gorilla/mux"
+	"github.com/opencontainers/go-digest"
+)
+
+// The following are definitions of the name under which all GitLab v1 routes are registered. These symbols can be
+// used to look up a route based on the name.
+const (
+	RouteNameRepositoryEvents                = "gitlab-v1-repository-events"
+	RouteNameManifestTags                    = "gitlab-v1-manifest-tags"
+	RouteNameRepositoryArchive               = "gitlab-v1-repository-archive"
+	RouteNameRepositoryRename                = "gitlab-v1-repository-rename"
+	RouteNameRepositoryStorageMove           = "gitlab-v1-repository-storage-move"
+	RouteNameRepositoryCopy                  = "gitlab-v1-repository-copy"
+	RouteNameGroupRepositories               = "gitlab-v1-group-repositories"
+	RouteNameRepositoryUploads               = "gitlab-v1-repository-uploads"
+	RouteNameRepositoryTags                  = "gitlab-v1-repository-tags"
+	RouteNameRepositoryTag                   = "gitlab-v1-repository-tag"
+	RouteNameRetentionPolicy                 = "gitlab-v1-repository-retention-policy"
+	RouteNameRepositoryCreate                = "gitlab-v1-repository-create"
+	RouteNameRepositoryManifests             = "gitlab-v1-repository-manifests"
+	RouteNameRepositoryBlobsExists           = "gitlab-v1-repository-blobs-exists"
+	RouteNameNamespaceDeduplication          = "gitlab-v1-namespace-deduplication"
+	RouteNameRepositoryStatistics            = "gitlab-v1-repository-statistics"
+	RouteNameRepositoryStatisticsRecalculate = "gitlab-v1-repository-statistics-recalculate"
+
+	RoutePathBase                            = "/gitlab/v1/"
+	RoutePathRepositoryEvents                = "/gitlab/v1/repositories/{name}/events"
+	RoutePathManifestTags                    = "/gitlab/v1/repositories/{name}/manifests/{digest}/tags"
+	RoutePathRepositoryArchive               = "/gitlab/v1/repositories/{name}/archive"
+	RoutePathRepositoryRename                = "/gitlab/v1/repositories/{name}/rename"
+	RoutePathRepositoryStorageMove           = "/gitlab/v1/repositories/{name}/storage-move"
+	RoutePathRepositoryCopy                  = "/gitlab/v1/repositories/{name}/copy"
+	RoutePathGroupRepositories               = "/gitlab/v1/groups/{name}/repositories"
+	RoutePathRepositoryUploads               = "/gitlab/v1/repositories/{name}/uploads"
+	RoutePathRepositoryTags                  = "/gitlab/v1/repositories/{name}/tags/list"
+	RoutePathRepositoryTag                   = "/gitlab/v1/repositories/{name}/tags/{tag}"
+	RoutePathRetentionPolicy                 = "/gitlab/v1/repositories/{name}/retention-policy"
+	RoutePathRepositoryCreate                = "/gitlab/v1/repositories/{name}/create"
+	RoutePathRepositoryManifests             = "/gitlab/v1/repositories/{name}/manifests"
+	RoutePathRepositoryBlobsExists           = "/gitlab/v1/repositories/{name}/blobs/exists"
+	RoutePathNamespaceDeduplication          = "/gitlab/v1/namespaces/{name}/deduplication"
+	RoutePathRepositoryStatistics            = "/gitlab/v1/repositories/{name}/statistics"
+	RoutePathRepositoryStatisticsRecalculate = "/gitlab/v1/repositories/{name}/statistics/recalculate"
+)
+
+// RoutePath returns the route path template for a given route name, or an empty string if the route is unknown.
+func RoutePath(routeName string) string {
+	switch routeName {
+	case RouteNameRepositoryEvents:
+		return RoutePathRepositoryEvents
+	case RouteNameManifestTags:
+		return RoutePathManifestTags
+	case RouteNameRepositoryArchive:
+		return RoutePathRepositoryArchive
+	case RouteNameRepositoryRename:
+		return RoutePathRepositoryRename
+	case RouteNameRepositoryStorageMove:
+		return RoutePathRepositoryStorageMove
+	case RouteNameRepositoryCopy:
+		return RoutePathRepositoryCopy
+	case RouteNameGroupRepositories:
+		return RoutePathGroupRepositories
+	case RouteNameRepositoryUploads:
+		return RoutePathRepositoryUploads
+	case RouteNameRepositoryTags:
+		return RoutePathRepositoryTags
+	case RouteNameRepositoryTag:
+		return RoutePathRepositoryTag
+	case RouteNameRetentionPolicy:
+		return RoutePathRetentionPolicy
+	case RouteNameRepositoryCreate:
+		return RoutePathRepositoryCreate
+	case RouteNameRepositoryManifests:
+		return RoutePathRepositoryManifests
+	case RouteNameRepositoryBlobsExists:
+		return RoutePathRepositoryBlobsExists
+	case RouteNameNamespaceDeduplication:
+		return RoutePathNamespaceDeduplication
+	case RouteNameRepositoryStatistics:
+		return RoutePathRepositoryStatistics
+	case RouteNameRepositoryStatisticsRecalculate:
+		return RoutePathRepositoryStatisticsRecalculate
+	default:
+		return ""
+	}
+}
+
+var routes = []struct {
+	name string
+	path string
+}{
+	{
+		name: RouteNameRepositoryEvents,
+		path: "/gitlab/v1/repositories/{name:" + reference.NameRegexp.String() + "}/events",
+	},
+	{
+		name: RouteNameManifestTags,
+		path: "/gitlab/v1/repositories/{name:" + reference.NameRegexp.String() + "}/manifests/{digest:" + digest.DigestRegexp.String() + "}/tags",
+	},
+	{
+		name: RouteNameRepositoryArchive,
+		path: "/gitlab/v1/repositories/{name:" + reference.NameRegexp.String() + "}/archive",
+	},
+	{
+		name: RouteNameRepositoryRename,
+		path: "/gitlab/v1/repositories/{name:" + reference.NameRegexp.String() + "}/rename",
+	},
+	{
+		name: RouteNameRepositoryStorageMove,
+		path: "/gitlab/v1/repositories/{name:" + reference.NameRegexp.String() + "}/storage-move",
+	},
+	{
+		name: RouteNameRepositoryCopy,
+		path: "/gitlab/v1/repositories/{name:" + reference.NameRegexp.String() + "}/copy",
+	},
+	{
+		name: RouteNameGroupRepositories,
+		path: "/gitlab/v1/groups/{name:" + reference.NameRegexp.String() + "}/repositories",
+	},
+	{
+		name: RouteNameRepositoryUploads,
+		path: "/gitlab/v1/repositories/{name:" + reference.NameRegexp.String() + "}/uploads",
+	},
+	{
+		name: RouteNameRepositoryTags,
+		path: "/gitlab/v1/repositories/{name:" + reference.NameRegexp.String() + "}/tags/list",
+	},
+	{
+		// must be registered after RouteNameRepositoryTags, so that a tag named "list" does not shadow it
+		name: RouteNameRepositoryTag,
+		path: "/gitlab/v1/repositories/{name:" + reference.NameRegexp.String() + "}/tags/{tag:" + reference.TagRegexp.String() + "}",
+	},
+	{
+		name: RouteNameRetentionPolicy,
+		path: "/gitlab/v1/repositories/{name:" + reference.NameRegexp.String() + "}/retention-policy",
+	},
+	{
+		name: RouteNameRepositoryCreate,
+		path: "/gitlab/v1/repositories/{name:" + reference.NameRegexp.String() + "}/create",
+	},
+	{
+		name: RouteNameRepositoryManifests,
+		path: "/gitlab/v1/repositories/{name:" + reference.NameRegexp.String() + "}/manifests",
+	},
+	{
+		name: RouteNameRepositoryBlobsExists,
+		path: "/gitlab/v1/repositories/{name:" + reference.NameRegexp.String() + "}/blobs/exists",
+	},
+	{
+		name: RouteNameNamespaceDeduplication,
+		path: "/gitlab/v1/namespaces/{name:" + reference.NameRegexp.String() + "}/deduplication",
+	},
+	{
+		name: RouteNameRepositoryStatistics,
+		path: "/gitlab/v1/repositories/{name:" + reference.NameRegexp.String() + "}/statistics",
+	},
+	{
+		name: RouteNameRepositoryStatisticsRecalculate,
+		path: "/gitlab/v1/repositories/{name:" + reference.NameRegexp.String() + "}/statistics/recalculate",
+	},
+}
+
+// Router builds a gorilla router with named routes for the GitLab v1 API.
+func Router() *mux.Router {
+	return RouterWithPrefix("")
+}
+
+// RouterWithPrefix builds a gorilla router with named routes for the GitLab v1 API, with a configured prefix on all
+// routes.
+func RouterWithPrefix(prefix string) *mux.Router {
+	rootRouter := mux.NewRouter()
+	router := rootRouter
+	if prefix != "" {
+		router = router.PathPrefix(prefix).Subrouter()
+	}
+
+	router.StrictSlash(true)
+
+	for _, r := range routes {
+		router.Path(r.path).Name(r.name)
+	}
+
+	return rootRouter
+}
@@ -0,0 +1,176 @@
+package v1_test
+
+import (
+	"net/http"
+	"testing"
+
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouterWithPrefix(t *testing.T) {
+	tests := []struct {
+		name      string
+		prefix    string
+		path      string
+		wantRoute string
+		wantName  string
+	}{
+		{
+			name:      "repository events",
+			path:      "/gitlab/v1/repositories/foo/bar/events",
+			wantRoute: v1.RouteNameRepositoryEvents,
+			wantName:  "foo/bar",
+		},
+		{
+			name:      "repository events with prefix",
+			prefix:    "/registry",
+			path:      "/registry/gitlab/v1/repositories/foo/events",
+			wantRoute: v1.RouteNameRepositoryEvents,
+			wantName:  "foo",
+		},
+		{
+			name:      "manifest tags",
+			path:      "/gitlab/v1/repositories/foo/bar/manifests/sha256:4f6a4e4f6a4e4f6a4e4f6a4e4f6a4e4f6a4e4f6a4e4f6a4e4f6a4e4f6a4e4f6a/tags",
+			wantRoute: v1.RouteNameManifestTags,
+			wantName:  "foo/bar",
+		},
+		{
+			name:      "repository archive",
+			path:      "/gitlab/v1/repositories/foo/bar/archive",
+			wantRoute: v1.RouteNameRepositoryArchive,
+			wantName:  "foo/bar",
+		},
+		{
+			name:      "repository rename",
+			path:      "/gitlab/v1/repositories/foo/bar/rename",
+			wantRoute: v1.RouteNameRepositoryRename,
+			wantName:  "foo/bar",
+		},
+		{
+			name:      "repository storage move",
+			path:      "/gitlab/v1/repositories/foo/bar/storage-move",
+			wantRoute: v1.RouteNameRepositoryStorageMove,
+			wantName:  "foo/bar",
+		},
+		{
+			name:      "repository copy",
+			path:      "/gitlab/v1/repositories/foo/bar/copy",
+			wantRoute: v1.RouteNameRepositoryCopy,
+			wantName:  "foo/bar",
+		},
+		{
+			name:      "group repositories",
+			path:      "/gitlab/v1/groups/foo/bar/repositories",
+			wantRoute: v1.RouteNameGroupRepositories,
+			wantName:  "foo/bar",
+		},
+		{
+			name:      "repository uploads",
+			path:      "/gitlab/v1/repositories/foo/bar/uploads",
+			wantRoute: v1.RouteNameRepositoryUploads,
+			wantName:  "foo/bar",
+		},
+		{
+			name:      "repository tags",
+			path:      "/gitlab/v1/repositories/foo/bar/tags/list",
+			wantRoute: v1.RouteNameRepositoryTags,
+			wantName:  "foo/bar",
+		},
+		{
+			name:      "repository tag",
+			path:      "/gitlab/v1/repositories/foo/bar/tags/latest",
+			wantRoute: v1.RouteNameRepositoryTag,
+			wantName:  "foo/bar",
+		},
+		{
+			name:      "repository tag in repository named tags",
+			path:      "/gitlab/v1/repositories/foo/tags/tags/v1.0",
+			wantRoute: v1.RouteNameRepositoryTag,
+			wantName:  "foo/tags",
+		},
+		{
+			name:      "repository retention policy",
+			path:      "/gitlab/v1/repositories/foo/bar/retention-policy",
+			wantRoute: v1.RouteNameRetentionPolicy,
+			wantName:  "foo/bar",
+		},
+		{
+			name:      "repository create",
+			path:      "/gitlab/v1/repositories/foo/bar/create",
+			wantRoute: v1.RouteNameRepositoryCreate,
+			wantName:  "foo/bar",
+		},
+		{
+			name:      "repository manifests",
+			path:      "/gitlab/v1/repositories/foo/bar/manifests",
+			wantRoute: v1.RouteNameRepositoryManifests,
+			wantName:  "foo/bar",
+		},
+		{
+			name:      "repository blobs exists",
+			path:      "/gitlab/v1/repositories/foo/bar/blobs/exists",
+			wantRoute: v1.RouteNameRepositoryBlobsExists,
+			wantName:  "foo/bar",
+		},
+		{
+			name:      "namespace deduplication",
+			path:      "/gitlab/v1/namespaces/foo/deduplication",
+			wantRoute: v1.RouteNameNamespaceDeduplication,
+			wantName:  "foo",
+		},
+		{
+			name:      "repository statistics",
+			path:      "/gitlab/v1/repositories/foo/bar/statistics",
+			wantRoute: v1.RouteNameRepositoryStatistics,
+			wantName:  "foo/bar",
+		},
+		{
+			name:      "repository statistics recalculate",
+			path:      "/gitlab/v1/repositories/foo/bar/statistics/recalculate",
+			wantRoute: v1.RouteNameRepositoryStatisticsRecalculate,
+			wantName:  "foo/bar",
+		},
+		{
+			name:      "repository statistics in repository named statistics",
+			path:      "/gitlab/v1/repositories/foo/statistics/statistics",
+			wantRoute: v1.RouteNameRepositoryStatistics,
+			wantName:  "foo/statistics",
+		},
+		{
+			name: "manifest tags with invalid digest",
+			path: "/gitlab/v1/repositories/foo/bar/manifests/latest/tags",
+		},
+		{
+			name: "repository tag with invalid tag",
+			path: "/gitlab/v1/repositories/foo/bar/tags/-latest",
+		},
+		{
+			name: "invalid repository name",
+			path: "/gitlab/v1/repositories/Foo/events",
+		},
+		{
+			name: "unknown route",
+			path: "/gitlab/v1/foo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := v1.RouterWithPrefix(tt.prefix)
+			req, err := http.NewRequest(http.MethodGet, "http://localhost"+tt.path, nil)
+			require.NoError(t, err)
+
+			var match mux.RouteMatch
+			if tt.wantRoute == "" {
+				require.False(t, router.Match(req, &match))
+				return
+			}
+
+			require.True(t, router.Match(req, &match))
+			require.Equal(t, tt.wantRoute, match.Route.GetName())
+			require.Equal(t, tt.wantName, match.Vars["name"])
+		})
+	}
+}
@@ -204,6 +204,19 @@ var (
 			errcode.ErrorCodeTooManyRequests,
 		},
 	}
+
+	tooManyUploadsDescriptor = ResponseDescriptor{
+		Name:        "Too Many Uploads",
+		StatusCode:  http.StatusTooManyRequests,
+		Description: "The maximum number of in-flight blob uploads for the repository or the registry has been reached.",
+		Body: BodyDescriptor{
+			ContentType: "application/json",
+			Format:      errorsBody,
+		},
+		ErrorCodes: []errcode.ErrorCode{
+			ErrorCodeTooManyUploads,
+		},
+	}
 )
 
 const (
@@ -414,6 +427,12 @@ var routeDescriptors = []RouteDescriptor{
 										Description: "A list of features supported by the GitLab Container Registry API.",
 										Format:      "<comma separated list of features>",
 									},
+									{
+										Name:        "OCI-Distribution-Spec-Version",
+										Type:        "string",
+										Description: "The version of the OCI Distribution specification implemented by the registry.",
+										Format:      "<version>",
+									},
 								},
 							},
 						},
@@ -591,6 +610,92 @@ var routeDescriptors = []RouteDescriptor{
 			},
 		},
 	},
+	{
+		Name:        RouteNameTagsBatch,
+		Path:        "/v2/{name:" + reference.NameRegexp.String() + "}/tags/reference",
+		Entity:      "Tags Batch",
+		Description: "Delete multiple tags at once.",
+		Methods: []MethodDescriptor{
+			{
+				Method:      "DELETE",
+				Description: "Delete the tags identified by `name` and listed in the request body. Tags are deleted in a single operation and the result for each tag is returned. This method never deletes a manifest the tags reference.",
+				Requests: []RequestDescriptor{
+					{
+						Headers: []ParameterDescriptor{
+							hostHeader,
+							authHeader,
+						},
+						PathParameters: []ParameterDescriptor{
+							nameParameterDescriptor,
+						},
+						Body: BodyDescriptor{
+							ContentType: "application/json",
+							Format: `{
+    "tags": [
+        <tag>,
+        ...
+    ]
+}`,
+						},
+						Successes: []ResponseDescriptor{
+							{
+								StatusCode:  http.StatusOK,
+								Description: "The batch was processed. The result for each tag is either `deleted` or `not_found`.",
+								Headers: []ParameterDescriptor{
+									{
+										Name:        "Content-Type",
+										Type:        "string",
+										Format:      "application/json",
+										Description: "The response body is a json object.",
+									},
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json",
+									Format: `{
+    "name": <name>,
+    "results": [
+        {
+            "tag": <tag>,
+            "status": "deleted" | "not_found"
+        },
+        ...
+    ]
+}`,
+								},
+							},
+						},
+						Failures: []ResponseDescriptor{
+							{
+								Name:        "Invalid Name or Tags",
+								Description: "The specified `name` or any of the listed tags were invalid, or the number of tags exceeds the limit, and the delete was unable to proceed.",
+								StatusCode:  http.StatusBadRequest,
+								ErrorCodes: []errcode.ErrorCode{
+									ErrorCodeNameInvalid,
+									ErrorCodeTagInvalid,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      errorsBody,
+								},
+							},
+							unauthorizedResponseDescriptor,
+							repositoryNotFoundResponseDescriptor,
+							deniedResponseDescriptor,
+							tooManyRequestsDescriptor,
+							{
+								Name:        "Not allowed",
+								Description: "Tag delete is not allowed because the registry is configured as a pull-through cache or `delete` has been disabled.",
+								StatusCode:  http.StatusMethodNotAllowed,
+								ErrorCodes: []errcode.ErrorCode{
+									errcode.ErrorCodeUnsupported,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	},
 	{
 		Name:        RouteNameManifest,
 		Path:        "/v2/{name:" + reference.NameRegexp.String() + "}/manifests/{reference:" + reference.TagRegexp.String() + "|" + digest.DigestRegexp.String() + "}",
@@ -652,6 +757,12 @@ var routeDescriptors = []RouteDescriptor{
 						Headers: []ParameterDescriptor{
 							hostHeader,
 							authHeader,
+							{
+								Name:        "If-Match",
+								Type:        "string",
+								Format:      "<digest>",
+								Description: "Only applies when `reference` is a tag. The tag is only moved to the uploaded manifest if it currently points to the manifest with the given digest, such as the `ETag` of a previous manifest fetch. Useful to prevent concurrent pushes from silently overwriting each other.",
+							},
 						},
 						PathParameters: []ParameterDescriptor{
 							nameParameterDescriptor,
@@ -720,6 +831,30 @@ var routeDescriptors = []RouteDescriptor{
 }`,
 								},
 							},
+							{
+								Name:        "Quota Exceeded",
+								Description: "The repository already holds the maximum number of manifests or tags allowed by the registry configuration.",
+								StatusCode:  http.StatusForbidden,
+								ErrorCodes: []errcode.ErrorCode{
+									ErrorCodeQuotaExceeded,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json",
+									Format:      errorsBody,
+								},
+							},
+							{
+								Name:        "Precondition Failed",
+								Description: "The `If-Match` header was provided but the tag does not exist or does not point to the given digest. The tag was not moved.",
+								StatusCode:  http.StatusPreconditionFailed,
+								ErrorCodes: []errcode.ErrorCode{
+									ErrorCodePreconditionFailed,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json",
+									Format:      errorsBody,
+								},
+							},
 							{
 								Name:        "Not allowed",
 								Description: "Manifest put is not allowed because the registry is configured as a pull-through cache or for some other reason",
@@ -1116,6 +1251,7 @@ var routeDescriptors = []RouteDescriptor{
 							repositoryNotFoundResponseDescriptor,
 							deniedResponseDescriptor,
 							tooManyRequestsDescriptor,
+							tooManyUploadsDescriptor,
 						},
 					},
 					{
@@ -1163,6 +1299,7 @@ var routeDescriptors = []RouteDescriptor{
 							repositoryNotFoundResponseDescriptor,
 							deniedResponseDescriptor,
 							tooManyRequestsDescriptor,
+							tooManyUploadsDescriptor,
 						},
 					},
 					{
@@ -1457,6 +1594,13 @@ var routeDescriptors = []RouteDescriptor{
 							{
 								Description: "The `Content-Range` specification cannot be accepted, either because it does not overlap with the current progress or it is invalid.",
 								StatusCode:  http.StatusRequestedRangeNotSatisfiable,
+								ErrorCodes: []errcode.ErrorCode{
+									ErrorCodeRangeInvalid,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json",
+									Format:      errorsBody,
+								},
 							},
 							unauthorizedResponseDescriptor,
 							repositoryNotFoundResponseDescriptor,
@@ -1481,6 +1625,12 @@ var routeDescriptors = []RouteDescriptor{
 								Format:      "<length of data>",
 								Description: "Length of the data being uploaded, corresponding to the length of the request body. May be zero if no data is provided.",
 							},
+							{
+								Name:        "Content-Range",
+								Type:        "header",
+								Format:      "<start of range>-<end of range, inclusive>",
+								Description: "Range of bytes identifying the final chunk of content represented by the body. Optional. If provided, start must match the end offset retrieved via status check plus one. Note that this is a non-standard use of the `Content-Range` header.",
+							},
 						},
 						PathParameters: []ParameterDescriptor{
 							nameParameterDescriptor,
@@ -1549,6 +1699,17 @@ var routeDescriptors = []RouteDescriptor{
 									Format:      errorsBody,
 								},
 							},
+							{
+								Description: "The `Content-Range` specification cannot be accepted, either because it does not overlap with the current progress or it is invalid.",
+								StatusCode:  http.StatusRequestedRangeNotSatisfiable,
+								ErrorCodes: []errcode.ErrorCode{
+									ErrorCodeRangeInvalid,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json",
+									Format:      errorsBody,
+								},
+							},
 							unauthorizedResponseDescriptor,
 							repositoryNotFoundResponseDescriptor,
 							deniedResponseDescriptor,
@@ -134,6 +134,17 @@ var (
 		HTTPStatusCode: http.StatusNotFound,
 	})
 
+	// ErrorCodeRangeInvalid is returned when uploading a blob if the provided
+	// content range does not match the current upload progress or is invalid.
+	ErrorCodeRangeInvalid = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "RANGE_INVALID",
+		Message: "invalid content range",
+		Description: `When a blob chunk is uploaded, the provided content range
+		must start at the current upload offset and match the length of the
+		request body. If it does not, this error will be returned.`,
+		HTTPStatusCode: http.StatusRequestedRangeNotSatisfiable,
+	})
+
 	// ErrorCodeManifestReferencedInList is returned when attempting to delete a manifest that is still referenced by at
 	// least one manifest list.
 	ErrorCodeManifestReferencedInList = errcode.Register(errGroup, errcode.ErrorDescriptor{
@@ -143,4 +154,34 @@ var (
 		proceed.`,
 		HTTPStatusCode: http.StatusConflict,
 	})
+
+	// ErrorCodeQuotaExceeded is returned when a manifest or tag push would exceed one of the per-repository quotas.
+	ErrorCodeQuotaExceeded = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "QUOTA_EXCEEDED",
+		Message: "repository quota exceeded",
+		Description: `The repository already holds the maximum number of manifests or tags allowed by the registry
+		configuration. Existing content must be deleted before new content can be pushed.`,
+		HTTPStatusCode: http.StatusForbidden,
+	})
+
+	// ErrorCodePreconditionFailed is returned when a manifest is pushed by tag with an If-Match precondition and the tag
+	// does not currently point to the given digest.
+	ErrorCodePreconditionFailed = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "PRECONDITION_FAILED",
+		Message: "tag does not point to the expected manifest",
+		Description: `The manifest was pushed with an If-Match precondition, but the tag does not exist or no longer
+		points to the manifest with the given digest, likely because it was moved by a concurrent push. The client
+		should fetch the current state of the tag and decide whether to retry.`,
+		HTTPStatusCode: http.StatusPreconditionFailed,
+	})
+
+	// ErrorCodeTooManyUploads is returned when starting a blob upload would exceed the maximum number of in-flight
+	// upload sessions allowed per repository or for the whole registry.
+	ErrorCodeTooManyUploads = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "TOOMANYUPLOADS",
+		Message: "too many blob uploads in progress",
+		Description: `The maximum number of in-flight blob upload sessions allowed by the registry configuration has
+		been reached. Uploads must be completed or canceled before new ones can be started.`,
+		HTTPStatusCode: http.StatusTooManyRequests,
+	})
 )
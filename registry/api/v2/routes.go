@@ -9,6 +9,7 @@ const (
 	RouteNameManifest        = "manifest"
 	RouteNameTags            = "tags"
 	RouteNameTag             = "tag"
+	RouteNameTagsBatch       = "tags-batch"
 	RouteNameBlob            = "blob"
 	RouteNameBlobUpload      = "blob-upload"
 	RouteNameBlobUploadChunk = "blob-upload-chunk"
@@ -18,6 +19,7 @@ const (
 	RoutePathManifest        = "/v2/{name}/manifests/{reference}"
 	RoutePathTags            = "/v2/{name}/tags/list"
 	RoutePathTag             = "/v2/{name}/tags/reference/{tag}"
+	RoutePathTagsBatch       = "/v2/{name}/tags/reference"
 	RoutePathBlob            = "/v2/{name}/blobs/{digest}"
 	RoutePathBlobUpload      = "/v2/{name}/blobs/uploads/"
 	RoutePathBlobUploadChunk = "/v2/{name}/blobs/uploads/{uuid}"
@@ -34,6 +36,8 @@ func RoutePath(routeName string) string {
 		return RoutePathTags
 	case RouteNameTag:
 		return RoutePathTag
+	case RouteNameTagsBatch:
+		return RoutePathTagsBatch
 	case RouteNameBlob:
 		return RoutePathBlob
 	case RouteNameBlobUpload:
@@ -127,6 +127,20 @@ func TestRouter(t *testing.T) {
 				"tag":  "tags",
 			},
 		},
+		{
+			RouteName:  RouteNameTagsBatch,
+			RequestURI: "/v2/foo/bar/tags/reference",
+			Vars: map[string]string{
+				"name": "foo/bar",
+			},
+		},
+		{
+			RouteName:  RouteNameTagsBatch,
+			RequestURI: "/v2/tags/reference/tags/reference",
+			Vars: map[string]string{
+				"name": "tags/reference",
+			},
+		},
 		{
 			RouteName:  RouteNameBlob,
 			RequestURI: "/v2/foo/bar/blobs/sha256:abcdef0919234",
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/docker/distribution/reference"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
 	"github.com/gorilla/mux"
 )
 
@@ -18,17 +19,27 @@ import (
 // under "/foo/v2/...". Most application will only provide a schema, host and
 // port, such as "https://localhost:5000/".
 type URLBuilder struct {
-	root     *url.URL // url root (ie http://localhost/)
-	router   *mux.Router
-	relative bool
+	root         *url.URL // url root (ie http://localhost/)
+	router       *mux.Router
+	gitlabRouter *mux.Router
+	relative     bool
 }
 
 // NewURLBuilder creates a URLBuilder with provided root url object.
 func NewURLBuilder(root *url.URL, relative bool) *URLBuilder {
+	return NewURLBuilderWithPrefix(root, "", relative)
+}
+
+// NewURLBuilderWithPrefix creates a URLBuilder with provided root url object,
+// for a registry served under prefix (e.g. "/registry/"). The prefix is part
+// of all generated urls, including relative ones, and is applied after the
+// root path.
+func NewURLBuilderWithPrefix(root *url.URL, prefix string, relative bool) *URLBuilder {
 	return &URLBuilder{
-		root:     root,
-		router:   Router(),
-		relative: relative,
+		root:         root,
+		router:       RouterWithPrefix(prefix),
+		gitlabRouter: v1.RouterWithPrefix(prefix),
+		relative:     relative,
 	}
 }
 
@@ -47,6 +58,32 @@ func NewURLBuilderFromString(root string, relative bool) (*URLBuilder, error) {
 // NewURLBuilderFromRequest uses information from an *http.Request to
 // construct the root url.
 func NewURLBuilderFromRequest(r *http.Request, relative bool) *URLBuilder {
+	u := requestRoot(r)
+
+	basePath := routeDescriptorsMap[RouteNameBase].Path
+
+	requestPath := r.URL.Path
+	index := strings.Index(requestPath, basePath)
+
+	if index > 0 {
+		// N.B. index+1 is important because we want to include the trailing /
+		u.Path = requestPath[0 : index+1]
+	}
+
+	return NewURLBuilder(u, relative)
+}
+
+// NewURLBuilderFromRequestWithPrefix works like NewURLBuilderFromRequest, but
+// for a registry served under prefix. Instead of being derived from the
+// request path, which only works for requests to the /v2/ API, the root path
+// is set to prefix.
+func NewURLBuilderFromRequestWithPrefix(r *http.Request, prefix string, relative bool) *URLBuilder {
+	return NewURLBuilderWithPrefix(requestRoot(r), prefix, relative)
+}
+
+// requestRoot returns the root url (scheme and host) under which the registry
+// was reached by r, taking forwarded headers into account.
+func requestRoot(r *http.Request) *url.URL {
 	var (
 		scheme = "http"
 		host   = r.Host
@@ -85,22 +122,10 @@ func NewURLBuilderFromRequest(r *http.Request, relative bool) *URLBuilder {
 		}
 	}
 
-	basePath := routeDescriptorsMap[RouteNameBase].Path
-
-	requestPath := r.URL.Path
-	index := strings.Index(requestPath, basePath)
-
-	u := &url.URL{
+	return &url.URL{
 		Scheme: scheme,
 		Host:   host,
 	}
-
-	if index > 0 {
-		// N.B. index+1 is important because we want to include the trailing /
-		u.Path = requestPath[0 : index+1]
-	}
-
-	return NewURLBuilder(u, relative)
 }
 
 // BuildBaseURL constructs a base url for the API, typically just "/v2/".
@@ -151,6 +176,18 @@ func (ub *URLBuilder) BuildTagURL(ref reference.NamedTagged) (string, error) {
 	return tagURL.String(), nil
 }
 
+// BuildTagsBatchURL constructs an url to operate on multiple tags of the given name at once.
+func (ub *URLBuilder) BuildTagsBatchURL(name reference.Named) (string, error) {
+	route := ub.cloneRoute(RouteNameTagsBatch)
+
+	tagsBatchURL, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return tagsBatchURL.String(), nil
+}
+
 // BuildManifestURL constructs a url for the manifest identified by name and
 // reference. The argument reference may be either a tag or digest.
 func (ub *URLBuilder) BuildManifestURL(ref reference.Named) (string, error) {
@@ -214,13 +251,162 @@ func (ub *URLBuilder) BuildBlobUploadChunkURL(name reference.Named, uuid string,
 	return appendValuesURL(uploadURL, values...).String(), nil
 }
 
+// BuildGitLabRepositoryEventsURL constructs a url for the GitLab v1
+// repository events API of the named repository.
+func (ub *URLBuilder) BuildGitLabRepositoryEventsURL(name reference.Named, values ...url.Values) (string, error) {
+	route := ub.cloneRoute(v1.RouteNameRepositoryEvents)
+
+	eventsURL, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(eventsURL, values...).String(), nil
+}
+
+// BuildGitLabGroupRepositoriesURL constructs a url to list the repositories under a group path.
+func (ub *URLBuilder) BuildGitLabGroupRepositoriesURL(name reference.Named, values ...url.Values) (string, error) {
+	route := ub.cloneRoute(v1.RouteNameGroupRepositories)
+
+	reposURL, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(reposURL, values...).String(), nil
+}
+
+// BuildGitLabRepositoryUploadsURL constructs a url to list the in-progress blob uploads of a repository.
+func (ub *URLBuilder) BuildGitLabRepositoryUploadsURL(name reference.Named, values ...url.Values) (string, error) {
+	route := ub.cloneRoute(v1.RouteNameRepositoryUploads)
+
+	uploadsURL, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(uploadsURL, values...).String(), nil
+}
+
+// BuildGitLabRepositoryManifestsURL constructs a url to list the manifests of a repository, along with their details.
+func (ub *URLBuilder) BuildGitLabRepositoryManifestsURL(name reference.Named, values ...url.Values) (string, error) {
+	route := ub.cloneRoute(v1.RouteNameRepositoryManifests)
+
+	manifestsURL, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(manifestsURL, values...).String(), nil
+}
+
+// BuildGitLabRepositoryBlobsExistsURL constructs a url to check the existence of multiple blobs in a repository.
+func (ub *URLBuilder) BuildGitLabRepositoryBlobsExistsURL(name reference.Named) (string, error) {
+	route := ub.cloneRoute(v1.RouteNameRepositoryBlobsExists)
+
+	existsURL, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return existsURL.String(), nil
+}
+
+// BuildGitLabNamespaceDeduplicationURL constructs a url to report the storage deduplication of a namespace.
+func (ub *URLBuilder) BuildGitLabNamespaceDeduplicationURL(name reference.Named) (string, error) {
+	route := ub.cloneRoute(v1.RouteNameNamespaceDeduplication)
+
+	dedupURL, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return dedupURL.String(), nil
+}
+
+// BuildGitLabRepositoryStatisticsURL constructs a url for the statistics of a repository.
+func (ub *URLBuilder) BuildGitLabRepositoryStatisticsURL(name reference.Named) (string, error) {
+	route := ub.cloneRoute(v1.RouteNameRepositoryStatistics)
+
+	statsURL, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return statsURL.String(), nil
+}
+
+// BuildGitLabRepositoryStatisticsRecalculateURL constructs a url to recalculate the statistics of a repository.
+func (ub *URLBuilder) BuildGitLabRepositoryStatisticsRecalculateURL(name reference.Named) (string, error) {
+	route := ub.cloneRoute(v1.RouteNameRepositoryStatisticsRecalculate)
+
+	recalculateURL, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return recalculateURL.String(), nil
+}
+
+// BuildGitLabRepositoryTagsURL constructs a url to list the tags of a repository, along with their details.
+func (ub *URLBuilder) BuildGitLabRepositoryTagsURL(name reference.Named, values ...url.Values) (string, error) {
+	route := ub.cloneRoute(v1.RouteNameRepositoryTags)
+
+	tagsURL, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(tagsURL, values...).String(), nil
+}
+
+// BuildGitLabRepositoryTagURL constructs a url to tag a manifest of a repository.
+func (ub *URLBuilder) BuildGitLabRepositoryTagURL(name reference.Named, tag string) (string, error) {
+	route := ub.cloneRoute(v1.RouteNameRepositoryTag)
+
+	tagURL, err := route.URL("name", name.Name(), "tag", tag)
+	if err != nil {
+		return "", err
+	}
+
+	return tagURL.String(), nil
+}
+
+// BuildGitLabRetentionPolicyURL constructs a url for the retention policy of a repository.
+func (ub *URLBuilder) BuildGitLabRetentionPolicyURL(name reference.Named) (string, error) {
+	route := ub.cloneRoute(v1.RouteNameRetentionPolicy)
+
+	policyURL, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return policyURL.String(), nil
+}
+
+// BuildGitLabRepositoryCreateURL constructs a url to create a repository.
+func (ub *URLBuilder) BuildGitLabRepositoryCreateURL(name reference.Named) (string, error) {
+	route := ub.cloneRoute(v1.RouteNameRepositoryCreate)
+
+	createURL, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return createURL.String(), nil
+}
+
 // clondedRoute returns a clone of the named route from the router. Routes
 // must be cloned to avoid modifying them during url generation.
 func (ub *URLBuilder) cloneRoute(name string) clonedRoute {
 	route := new(mux.Route)
 	root := new(url.URL)
 
-	*route = *ub.router.GetRoute(name) // clone the route
+	r := ub.router.GetRoute(name)
+	if r == nil {
+		r = ub.gitlabRouter.GetRoute(name)
+	}
+	*route = *r // clone the route
 	*root = *ub.root
 
 	return clonedRoute{Route: route, root: root, relative: ub.relative}
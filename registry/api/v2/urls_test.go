@@ -65,6 +65,14 @@ func makeURLBuilderTestCases(urlBuilder *URLBuilder) []urlBuilderTestCase {
 				})
 			},
 		},
+		{
+			description:  "test tags batch url",
+			expectedPath: "/v2/foo/bar/tags/reference",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return urlBuilder.BuildTagsBatchURL(fooBarRef)
+			},
+		},
 		{
 			description:  "test manifest url tagged ref",
 			expectedPath: "/v2/foo/bar/manifests/tag",
@@ -616,3 +624,142 @@ func TestBuilderFromRequestWithPrefix(t *testing.T) {
 		}
 	}
 }
+
+func TestBuilderWithPrefix(t *testing.T) {
+	root, err := url.Parse("https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fooBarRef, _ := reference.WithName("foo/bar")
+
+	// requests outside of the /v2/ API must not prevent the prefix from being detected
+	u, err := url.Parse("http://example.com/prefix/gitlab/v1/repositories/foo/bar/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := &http.Request{URL: u, Host: u.Host}
+
+	testBuilders := []struct {
+		name    string
+		builder *URLBuilder
+		base    string
+	}{
+		{
+			name:    "absolute",
+			builder: NewURLBuilderWithPrefix(root, "/prefix/", false),
+			base:    "https://example.com",
+		},
+		{
+			name:    "relative",
+			builder: NewURLBuilderWithPrefix(root, "/prefix/", true),
+		},
+		{
+			name:    "absolute from request",
+			builder: NewURLBuilderFromRequestWithPrefix(request, "/prefix/", false),
+			base:    "http://example.com",
+		},
+		{
+			name:    "relative from request",
+			builder: NewURLBuilderFromRequestWithPrefix(request, "/prefix/", true),
+		},
+	}
+
+	for _, tb := range testBuilders {
+		t.Run(tb.name, func(t *testing.T) {
+			testCases := append(makeURLBuilderTestCases(tb.builder),
+				urlBuilderTestCase{
+					description:  "build gitlab repository events url",
+					expectedPath: "/gitlab/v1/repositories/foo/bar/events?n=10",
+					build: func() (string, error) {
+						return tb.builder.BuildGitLabRepositoryEventsURL(fooBarRef, url.Values{"n": []string{"10"}})
+					},
+				},
+				urlBuilderTestCase{
+					description:  "build gitlab group repositories url",
+					expectedPath: "/gitlab/v1/groups/foo/bar/repositories?last=foo%2Fbar%2Fa&n=10",
+					build: func() (string, error) {
+						return tb.builder.BuildGitLabGroupRepositoriesURL(fooBarRef, url.Values{"n": []string{"10"}, "last": []string{"foo/bar/a"}})
+					},
+				},
+				urlBuilderTestCase{
+					description:  "build gitlab repository uploads url",
+					expectedPath: "/gitlab/v1/repositories/foo/bar/uploads",
+					build: func() (string, error) {
+						return tb.builder.BuildGitLabRepositoryUploadsURL(fooBarRef)
+					},
+				},
+				urlBuilderTestCase{
+					description:  "build gitlab repository blobs exists url",
+					expectedPath: "/gitlab/v1/repositories/foo/bar/blobs/exists",
+					build: func() (string, error) {
+						return tb.builder.BuildGitLabRepositoryBlobsExistsURL(fooBarRef)
+					},
+				},
+				urlBuilderTestCase{
+					description:  "build gitlab namespace deduplication url",
+					expectedPath: "/gitlab/v1/namespaces/foo/bar/deduplication",
+					build: func() (string, error) {
+						return tb.builder.BuildGitLabNamespaceDeduplicationURL(fooBarRef)
+					},
+				},
+				urlBuilderTestCase{
+					description:  "build gitlab repository statistics url",
+					expectedPath: "/gitlab/v1/repositories/foo/bar/statistics",
+					build: func() (string, error) {
+						return tb.builder.BuildGitLabRepositoryStatisticsURL(fooBarRef)
+					},
+				},
+				urlBuilderTestCase{
+					description:  "build gitlab repository statistics recalculate url",
+					expectedPath: "/gitlab/v1/repositories/foo/bar/statistics/recalculate",
+					build: func() (string, error) {
+						return tb.builder.BuildGitLabRepositoryStatisticsRecalculateURL(fooBarRef)
+					},
+				},
+				urlBuilderTestCase{
+					description:  "build gitlab repository tags url",
+					expectedPath: "/gitlab/v1/repositories/foo/bar/tags/list?last=a&n=10",
+					build: func() (string, error) {
+						return tb.builder.BuildGitLabRepositoryTagsURL(fooBarRef, url.Values{"n": []string{"10"}, "last": []string{"a"}})
+					},
+				},
+				urlBuilderTestCase{
+					description:  "build gitlab repository tag url",
+					expectedPath: "/gitlab/v1/repositories/foo/bar/tags/latest",
+					build: func() (string, error) {
+						return tb.builder.BuildGitLabRepositoryTagURL(fooBarRef, "latest")
+					},
+				},
+				urlBuilderTestCase{
+					description:  "build gitlab retention policy url",
+					expectedPath: "/gitlab/v1/repositories/foo/bar/retention-policy",
+					build: func() (string, error) {
+						return tb.builder.BuildGitLabRetentionPolicyURL(fooBarRef)
+					},
+				},
+				urlBuilderTestCase{
+					description:  "build gitlab repository create url",
+					expectedPath: "/gitlab/v1/repositories/foo/bar/create",
+					build: func() (string, error) {
+						return tb.builder.BuildGitLabRepositoryCreateURL(fooBarRef)
+					},
+				},
+			)
+
+			for _, testCase := range testCases {
+				buildURL, err := testCase.build()
+				if !reflect.DeepEqual(testCase.expectedErr, err) {
+					t.Fatalf("%s: Expecting %v but got error %v", testCase.description, testCase.expectedErr, err)
+				}
+				if testCase.expectedErr != nil {
+					continue
+				}
+
+				expectedURL := tb.base + "/prefix" + testCase.expectedPath
+				if buildURL != expectedURL {
+					t.Errorf("%s: %q != %q", testCase.description, buildURL, expectedURL)
+				}
+			}
+		})
+	}
+}
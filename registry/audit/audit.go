@@ -0,0 +1,153 @@
+// Package audit implements an audit log for write operations performed against the registry. Audit events are
+// serialized as structured JSON and written to a dedicated sink, independently of the application and access logs.
+package audit
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultQueueSize = 1000
+
+// Action identifies the kind of write operation recorded by an audit Event.
+type Action string
+
+const (
+	// ActionBlobPush is recorded when a blob upload is completed.
+	ActionBlobPush Action = "blob_push"
+	// ActionBlobMount is recorded when a blob is mounted from another repository.
+	ActionBlobMount Action = "blob_mount"
+	// ActionBlobDelete is recorded when a blob is deleted from a repository.
+	ActionBlobDelete Action = "blob_delete"
+	// ActionManifestPush is recorded when a manifest is pushed.
+	ActionManifestPush Action = "manifest_push"
+	// ActionManifestDelete is recorded when a manifest is deleted.
+	ActionManifestDelete Action = "manifest_delete"
+	// ActionTagOverwrite is recorded instead of ActionManifestPush when a manifest is pushed by tag and the tag
+	// already pointed to a different manifest.
+	ActionTagOverwrite Action = "tag_overwrite"
+	// ActionTagDelete is recorded when a tag is deleted.
+	ActionTagDelete Action = "tag_delete"
+	// ActionUploadCancel is recorded when a blob upload is canceled.
+	ActionUploadCancel Action = "upload_cancel"
+	// ActionRetentionTagDelete is recorded when a tag is deleted by the enforcement of a retention policy.
+	ActionRetentionTagDelete Action = "retention_tag_delete"
+	// ActionRetentionTagDeleteDryRun is recorded instead of ActionRetentionTagDelete for tags that a retention policy
+	// in dry run mode would have deleted.
+	ActionRetentionTagDeleteDryRun Action = "retention_tag_delete_dry_run"
+)
+
+// Event is a single audit log entry. PreviousDigest is only set for ActionTagOverwrite events and UploadUUID is only
+// set for blob upload related events.
+type Event struct {
+	Timestamp      time.Time     `json:"timestamp"`
+	Action         Action        `json:"action"`
+	Actor          string        `json:"actor,omitempty"`
+	Repository     string        `json:"repository"`
+	Digest         digest.Digest `json:"digest,omitempty"`
+	Tag            string        `json:"tag,omitempty"`
+	PreviousDigest digest.Digest `json:"previous_digest,omitempty"`
+	UploadUUID     string        `json:"upload_uuid,omitempty"`
+	RequestID      string        `json:"request_id,omitempty"`
+}
+
+// Sink accepts and sends audit events.
+type Sink interface {
+	// Write writes an event to the sink.
+	Write(event Event) error
+	// Close the sink, possibly waiting for pending events to flush.
+	Close() error
+}
+
+// ErrLoggerClosed is returned if a write is issued to a logger that has been closed.
+var ErrLoggerClosed = errors.New("audit: logger closed")
+
+// Logger dispatches audit events to a sink in the background, so that slow sinks do not add latency to API requests.
+// Events are buffered in a bounded queue. When the queue is full, events are dropped and an error is logged.
+type Logger struct {
+	sink   Sink
+	events chan Event
+	done   chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewLogger creates a new Logger writing to sink. If queueSize is not positive, a default of 1000 is used.
+func NewLogger(sink Sink, queueSize int) *Logger {
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+
+	l := &Logger{
+		sink:   sink,
+		events: make(chan Event, queueSize),
+		done:   make(chan struct{}),
+	}
+	go l.run()
+
+	return l
+}
+
+// Log queues an event to be written to the sink. The event timestamp is set to the current time if not set.
+func (l *Logger) Log(event Event) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.closed {
+		logrus.WithFields(logrus.Fields{
+			"action":     event.Action,
+			"repository": event.Repository,
+			"request_id": event.RequestID,
+		}).Error("audit: logger closed, dropping event")
+		return
+	}
+
+	select {
+	case l.events <- event:
+	default:
+		logrus.WithFields(logrus.Fields{
+			"action":     event.Action,
+			"repository": event.Repository,
+			"request_id": event.RequestID,
+		}).Error("audit: queue is full, dropping event")
+	}
+}
+
+// Close stops accepting events, waits for all queued events to be written and closes the underlying sink.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return ErrLoggerClosed
+	}
+	l.closed = true
+	close(l.events)
+	l.mu.Unlock()
+
+	<-l.done
+
+	return l.sink.Close()
+}
+
+func (l *Logger) run() {
+	defer close(l.done)
+
+	for event := range l.events {
+		if err := l.sink.Write(event); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"action":     event.Action,
+				"repository": event.Repository,
+				"request_id": event.RequestID,
+			}).Error("audit: error writing event")
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package audit_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/audit"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger_FileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	sink, err := audit.NewFileSink(path)
+	require.NoError(t, err)
+
+	l := audit.NewLogger(sink, 0)
+
+	dgst := digest.FromString("foo")
+	events := []audit.Event{
+		{Action: audit.ActionManifestPush, Actor: "john", Repository: "foo/bar", Digest: dgst, Tag: "latest", RequestID: "1"},
+		{Action: audit.ActionTagOverwrite, Actor: "john", Repository: "foo/bar", Digest: dgst, Tag: "latest", PreviousDigest: digest.FromString("bar"), RequestID: "2"},
+		{Action: audit.ActionUploadCancel, Actor: "jane", Repository: "foo/bar", UploadUUID: "abc", RequestID: "3"},
+	}
+	for _, e := range events {
+		l.Log(e)
+	}
+	require.NoError(t, l.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var got []audit.Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e audit.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		require.False(t, e.Timestamp.IsZero())
+		e.Timestamp = time.Time{}
+		got = append(got, e)
+	}
+	require.NoError(t, scanner.Err())
+	require.Equal(t, events, got)
+}
+
+func TestLogger_HTTPSink(t *testing.T) {
+	var mu sync.Mutex
+	var got []audit.Event
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, audit.EventMediaType, r.Header.Get("Content-Type"))
+		require.Equal(t, "secret", r.Header.Get("Authorization"))
+
+		var e audit.Event
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+
+		mu.Lock()
+		got = append(got, e)
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+
+	sink := audit.NewHTTPSink(s.URL, time.Second, http.Header{"Authorization": []string{"secret"}})
+	l := audit.NewLogger(sink, 0)
+
+	l.Log(audit.Event{Action: audit.ActionTagDelete, Repository: "foo/bar", Tag: "latest"})
+	l.Log(audit.Event{Action: audit.ActionBlobDelete, Repository: "foo/bar", Digest: digest.FromString("foo")})
+	require.NoError(t, l.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, got, 2)
+	require.Equal(t, audit.ActionTagDelete, got[0].Action)
+	require.Equal(t, audit.ActionBlobDelete, got[1].Action)
+}
+
+func TestHTTPSink_Write_UnacceptedStatus(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer s.Close()
+
+	sink := audit.NewHTTPSink(s.URL, time.Second, nil)
+	require.Error(t, sink.Write(audit.Event{Action: audit.ActionManifestDelete}))
+}
+
+type blockingSink struct {
+	unblock chan struct{}
+	mu      sync.Mutex
+	written int
+}
+
+func (s *blockingSink) Write(audit.Event) error {
+	<-s.unblock
+	s.mu.Lock()
+	s.written++
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *blockingSink) Close() error {
+	return nil
+}
+
+func TestLogger_Log_DropsEventsWhenQueueIsFull(t *testing.T) {
+	sink := &blockingSink{unblock: make(chan struct{})}
+	l := audit.NewLogger(sink, 1)
+
+	// Logging never blocks, regardless of how slow the sink is. At most one event is being written and one is queued,
+	// the remaining are dropped.
+	for i := 0; i < 10; i++ {
+		l.Log(audit.Event{Action: audit.ActionBlobPush})
+	}
+
+	close(sink.unblock)
+	require.NoError(t, l.Close())
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	require.GreaterOrEqual(t, sink.written, 1)
+	require.LessOrEqual(t, sink.written, 2)
+}
+
+func TestLogger_Close(t *testing.T) {
+	l := audit.NewLogger(&blockingSink{unblock: make(chan struct{})}, 0)
+
+	require.NoError(t, l.Close())
+	require.True(t, errors.Is(l.Close(), audit.ErrLoggerClosed))
+
+	// logging after close must not panic
+	l.Log(audit.Event{Action: audit.ActionBlobPush})
+}
+
+func TestNewFileSink_InvalidPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = audit.NewFileSink(filepath.Join(dir, "missing", "audit.log"))
+	require.Error(t, err)
+}
+
+type recordingSink struct {
+	mu     sync.Mutex
+	events []audit.Event
+	err    error
+	closed bool
+}
+
+func (s *recordingSink) Write(event audit.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, event)
+	return s.err
+}
+
+func (s *recordingSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	return s.err
+}
+
+func TestMultiSink(t *testing.T) {
+	fooErr := errors.New("foo")
+	failing := &recordingSink{err: fooErr}
+	ok := &recordingSink{}
+	sink := audit.NewMultiSink(failing, ok)
+
+	// a failing sink does not prevent writing to the remaining ones
+	e := audit.Event{Action: audit.ActionBlobPush, Repository: "foo/bar"}
+	require.True(t, errors.Is(sink.Write(e), fooErr))
+	require.Equal(t, []audit.Event{e}, failing.events)
+	require.Equal(t, []audit.Event{e}, ok.events)
+
+	require.True(t, errors.Is(sink.Close(), fooErr))
+	require.True(t, failing.closed)
+	require.True(t, ok.closed)
+}
@@ -0,0 +1,150 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// EventMediaType is the media type of audit events posted to an HTTP sink.
+const EventMediaType = "application/vnd.gitlab.container-registry.audit.v1+json"
+
+// FileSink appends audit events to a file, one JSON object per line.
+type FileSink struct {
+	mu   sync.Mutex
+	f    *os.File
+	path string
+}
+
+// NewFileSink opens (or creates) the file at path for appending audit events.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log file: %w", err)
+	}
+
+	return &FileSink{f: f, path: path}, nil
+}
+
+// Write implements Sink.
+func (s *FileSink) Write(event Event) error {
+	p, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%v: error marshaling event: %w", s, err)
+	}
+	p = append(p, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.f.Write(p); err != nil {
+		return fmt.Errorf("%v: error writing event: %w", s, err)
+	}
+
+	return nil
+}
+
+// Close implements Sink.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.f.Close()
+}
+
+func (s *FileSink) String() string {
+	return fmt.Sprintf("auditFileSink{%s}", s.path)
+}
+
+// HTTPSink posts each audit event to an HTTP endpoint. Any 2xx or 3xx response is considered a success.
+type HTTPSink struct {
+	url     string
+	headers http.Header
+	client  *http.Client
+}
+
+// NewHTTPSink creates a new HTTPSink posting events to url. Static headers are added to all requests.
+func NewHTTPSink(url string, timeout time.Duration, headers http.Header) *HTTPSink {
+	return &HTTPSink{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Write implements Sink.
+func (s *HTTPSink) Write(event Event) error {
+	p, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%v: error marshaling event: %w", s, err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(p))
+	if err != nil {
+		return fmt.Errorf("%v: error creating request: %w", s, err)
+	}
+	for k, v := range s.headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", EventMediaType)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%v: error posting: %w", s, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("%v: response status %v unaccepted", s, resp.Status)
+	}
+
+	return nil
+}
+
+// Close implements Sink.
+func (s *HTTPSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+func (s *HTTPSink) String() string {
+	return fmt.Sprintf("auditHTTPSink{%s}", s.url)
+}
+
+// MultiSink writes audit events to multiple sinks. Events are written to all sinks, regardless of write failures.
+type MultiSink struct {
+	sinks []Sink
+}
+
+// NewMultiSink creates a new MultiSink writing to all sinks.
+func NewMultiSink(sinks ...Sink) *MultiSink {
+	return &MultiSink{sinks: sinks}
+}
+
+// Write implements Sink. If writing to one or more sinks fails, the first error is returned.
+func (s *MultiSink) Write(event Event) error {
+	var firstErr error
+	for _, sink := range s.sinks {
+		if err := sink.Write(event); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// Close implements Sink. All sinks are closed, and the first error is returned, if any.
+func (s *MultiSink) Close() error {
+	var firstErr error
+	for _, sink := range s.sinks {
+		if err := sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
 
 	dcontext "github.com/docker/distribution/context"
@@ -56,13 +57,27 @@ func (s accessSet) contains(access auth.Access) bool {
 
 // scopeParam returns a collection of scopes which can
 // be used for a WWW-Authenticate challenge parameter.
+// Scopes are listed in the order their resources were first
+// requested in, with sorted actions, so that all scopes
+// required by a request, such as pull access to the source
+// and push access to the target of a cross repository blob
+// mount, are advertised consistently.
 // See https://tools.ietf.org/html/rfc6750#section-3
-func (s accessSet) scopeParam() string {
+func scopeParam(accessItems ...auth.Access) string {
+	s := newAccessSet(accessItems...)
 	scopes := make([]string, 0, len(s))
 
-	for resource, actionSet := range s {
-		actions := strings.Join(actionSet.keys(), ",")
-		scopes = append(scopes, fmt.Sprintf("%s:%s:%s", resource.Type, resource.Name, actions))
+	for _, access := range accessItems {
+		actionSet, ok := s[access.Resource]
+		if !ok {
+			// already listed
+			continue
+		}
+		delete(s, access.Resource)
+
+		actions := actionSet.keys()
+		sort.Strings(actions)
+		scopes = append(scopes, fmt.Sprintf("%s:%s:%s", access.Type, access.Name, strings.Join(actions, ",")))
 	}
 
 	return strings.Join(scopes, " ")
@@ -80,7 +95,7 @@ type authChallenge struct {
 	realm        string
 	autoRedirect bool
 	service      string
-	accessSet    accessSet
+	scope        string
 }
 
 var _ auth.Challenge = authChallenge{}
@@ -107,8 +122,8 @@ func (ac authChallenge) challengeParams(r *http.Request) string {
 	}
 	str := fmt.Sprintf("Bearer realm=%q,service=%q", realm, ac.service)
 
-	if scope := ac.accessSet.scopeParam(); scope != "" {
-		str = fmt.Sprintf("%s,scope=%q", str, scope)
+	if ac.scope != "" {
+		str = fmt.Sprintf("%s,scope=%q", str, ac.scope)
 	}
 
 	if ac.err == ErrInvalidToken || ac.err == ErrMalformedToken {
@@ -133,6 +148,7 @@ type accessController struct {
 	service      string
 	rootCerts    *x509.CertPool
 	trustedKeys  map[string]libtrust.PublicKey
+	cache        *verificationCache
 }
 
 // tokenAccessOptions is a convenience type for handling
@@ -143,6 +159,9 @@ type tokenAccessOptions struct {
 	issuer         string
 	service        string
 	rootCertBundle string
+
+	verificationCache     bool
+	verificationCacheSize int
 }
 
 // checkOptions gathers the necessary options
@@ -171,6 +190,23 @@ func checkOptions(options map[string]interface{}) (tokenAccessOptions, error) {
 		opts.autoRedirect = autoRedirect
 	}
 
+	if val, ok := options["verificationcache"]; ok {
+		verificationCache, ok := val.(bool)
+		if !ok {
+			return opts, fmt.Errorf("token auth requires a valid option bool: verificationcache")
+		}
+		opts.verificationCache = verificationCache
+	}
+
+	opts.verificationCacheSize = defaultVerificationCacheSize
+	if val, ok := options["verificationcachesize"]; ok {
+		size, ok := val.(int)
+		if !ok || size <= 0 {
+			return opts, fmt.Errorf("token auth requires a valid option positive int: verificationcachesize")
+		}
+		opts.verificationCacheSize = size
+	}
+
 	return opts, nil
 }
 
@@ -222,14 +258,19 @@ func newAccessController(options map[string]interface{}) (auth.AccessController,
 		trustedKeys[pubKey.KeyID()] = pubKey
 	}
 
-	return &accessController{
+	ac := &accessController{
 		realm:        config.realm,
 		autoRedirect: config.autoRedirect,
 		issuer:       config.issuer,
 		service:      config.service,
 		rootCerts:    rootPool,
 		trustedKeys:  trustedKeys,
-	}, nil
+	}
+	if config.verificationCache {
+		ac.cache = newVerificationCache(config.verificationCacheSize)
+	}
+
+	return ac, nil
 }
 
 // Authorized handles checking whether the given request is authorized
@@ -239,7 +280,7 @@ func (ac *accessController) Authorized(ctx context.Context, accessItems ...auth.
 		realm:        ac.realm,
 		autoRedirect: ac.autoRedirect,
 		service:      ac.service,
-		accessSet:    newAccessSet(accessItems...),
+		scope:        scopeParam(accessItems...),
 	}
 
 	req, err := dcontext.GetRequest(ctx)
@@ -256,12 +297,38 @@ func (ac *accessController) Authorized(ctx context.Context, accessItems ...auth.
 
 	rawToken := parts[1]
 
-	token, err := NewToken(rawToken)
+	token, err := ac.verifiedToken(rawToken)
 	if err != nil {
 		challenge.err = err
 		return nil, challenge
 	}
 
+	accessSet := token.accessSet()
+	for _, access := range accessItems {
+		if !accessSet.contains(access) {
+			challenge.err = ErrInsufficientScope
+			return nil, challenge
+		}
+	}
+
+	ctx = auth.WithResources(ctx, token.resources())
+
+	return auth.WithUser(ctx, auth.UserInfo{Name: token.Claims.Subject}), nil
+}
+
+// verifiedToken parses and verifies rawToken, or returns it from the verification cache if it was already verified.
+func (ac *accessController) verifiedToken(rawToken string) (*Token, error) {
+	if ac.cache != nil {
+		if token := ac.cache.get(rawToken); token != nil {
+			return token, nil
+		}
+	}
+
+	token, err := NewToken(rawToken)
+	if err != nil {
+		return nil, err
+	}
+
 	verifyOpts := VerifyOptions{
 		TrustedIssuers:    []string{ac.issuer},
 		AcceptedAudiences: []string{ac.service},
@@ -270,21 +337,14 @@ func (ac *accessController) Authorized(ctx context.Context, accessItems ...auth.
 	}
 
 	if err = token.Verify(verifyOpts); err != nil {
-		challenge.err = err
-		return nil, challenge
+		return nil, err
 	}
 
-	accessSet := token.accessSet()
-	for _, access := range accessItems {
-		if !accessSet.contains(access) {
-			challenge.err = ErrInsufficientScope
-			return nil, challenge
-		}
+	if ac.cache != nil {
+		ac.cache.add(rawToken, token)
 	}
 
-	ctx = auth.WithResources(ctx, token.resources())
-
-	return auth.WithUser(ctx, auth.UserInfo{Name: token.Claims.Subject}), nil
+	return token, nil
 }
 
 // init handles registering the token auth backend.
@@ -0,0 +1,76 @@
+package token
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// defaultVerificationCacheSize is the default maximum number of verified tokens kept in the verification cache.
+const defaultVerificationCacheSize = 10000
+
+type cachedToken struct {
+	token     *Token
+	expiresAt time.Time
+}
+
+// verificationCache keeps the tokens that were successfully verified, keyed by the SHA-256 hash of the raw token,
+// until they expire. This spares the signature verification of tokens that are used repeatedly, such as during a
+// push, which involves many requests with the same token. Tokens are only accepted by the access controller that
+// verified them, so the cache must not be shared across access controllers.
+type verificationCache struct {
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]cachedToken
+}
+
+func newVerificationCache(maxEntries int) *verificationCache {
+	return &verificationCache{
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    make(map[[sha256.Size]byte]cachedToken),
+	}
+}
+
+// get returns the verified token matching rawToken, or nil if there is none or it expired.
+func (c *verificationCache) get(rawToken string) *Token {
+	key := sha256.Sum256([]byte(rawToken))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	if !c.now().Before(e.expiresAt) {
+		delete(c.entries, key)
+		return nil
+	}
+
+	return e.token
+}
+
+// add caches token, which was parsed from rawToken and successfully verified, until it expires. If the cache is full,
+// a random entry is evicted.
+func (c *verificationCache) add(rawToken string, token *Token) {
+	// tokens are accepted up to the leeway after their expiration, as done by Token.Verify
+	expiresAt := time.Unix(token.Claims.Expiration, 0).Add(Leeway)
+	if !c.now().Before(expiresAt) {
+		return
+	}
+	key := sha256.Sum256([]byte(rawToken))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = cachedToken{token: token, expiresAt: expiresAt}
+}
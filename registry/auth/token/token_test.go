@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
@@ -478,6 +479,94 @@ func TestAccessController(t *testing.T) {
 	}
 }
 
+// TestAccessControllerCrossRepositoryMount tests that cross repository blob
+// mounts require pull access to the source repository and push access to the
+// target repository, and that challenges advertise both scopes.
+func TestAccessControllerCrossRepositoryMount(t *testing.T) {
+	rootKeys, err := makeRootKeys(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCertBundleFilename, err := writeTempRootCerts(rootKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(rootCertBundleFilename)
+
+	issuer := "test-issuer.example.com"
+	service := "test-service.example.com"
+
+	accessController, err := newAccessController(map[string]interface{}{
+		"realm":          "https://auth.example.com/token/",
+		"issuer":         issuer,
+		"service":        service,
+		"rootcertbundle": rootCertBundleFilename,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := auth.Resource{Type: "repository", Name: "foo/target"}
+	source := auth.Resource{Type: "repository", Name: "foo/source"}
+	accessItems := []auth.Access{
+		{Resource: target, Action: "push"},
+		{Resource: target, Action: "pull"},
+		{Resource: source, Action: "pull"},
+	}
+	expectedScope := `scope="repository:foo/target:pull,push repository:foo/source:pull"`
+
+	req, err := http.NewRequest("POST", "http://example.com/v2/foo/target/blobs/uploads/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithRequest(context.Background(), req)
+
+	// 1. A token granting access to the target repository only.
+	token, err := makeTestToken(
+		issuer, service,
+		[]*ResourceActions{{Type: target.Type, Name: target.Name, Actions: []string{"pull", "push"}}},
+		rootKeys[0], 1, time.Now(), time.Now().Add(5*time.Minute),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token.compactRaw()))
+
+	_, err = accessController.Authorized(ctx, accessItems...)
+	challenge, ok := err.(auth.Challenge)
+	if !ok {
+		t.Fatal("accessController did not return a challenge")
+	}
+	if challenge.Error() != ErrInsufficientScope.Error() {
+		t.Fatalf("accessController did not get expected error - got %s - expected %s", challenge, ErrInsufficientScope)
+	}
+
+	w := httptest.NewRecorder()
+	challenge.SetHeaders(req, w)
+	if header := w.Header().Get("WWW-Authenticate"); !strings.Contains(header, expectedScope) {
+		t.Fatalf("expected challenge %q to contain %s", header, expectedScope)
+	}
+
+	// 2. A token granting access to both repositories.
+	token, err = makeTestToken(
+		issuer, service,
+		[]*ResourceActions{
+			{Type: target.Type, Name: target.Name, Actions: []string{"pull", "push"}},
+			{Type: source.Type, Name: source.Name, Actions: []string{"pull"}},
+		},
+		rootKeys[0], 1, time.Now(), time.Now().Add(5*time.Minute),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token.compactRaw()))
+
+	if _, err := accessController.Authorized(ctx, accessItems...); err != nil {
+		t.Fatalf("accessController returned unexpected error: %s", err)
+	}
+}
+
 // This tests that newAccessController can handle PEM blocks in the certificate
 // file other than certificates, for example a private key.
 func TestNewAccessControllerPemBlock(t *testing.T) {
@@ -531,3 +620,152 @@ func TestNewAccessControllerPemBlock(t *testing.T) {
 		t.Fatal("accessController has the wrong number of certificates")
 	}
 }
+
+func TestAccessControllerVerificationCache(t *testing.T) {
+	rootKeys, err := makeRootKeys(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCertBundleFilename, err := writeTempRootCerts(rootKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(rootCertBundleFilename)
+
+	issuer := "test-issuer.example.com"
+	service := "test-service.example.com"
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":                 "https://auth.example.com/token/",
+		"issuer":                issuer,
+		"service":               service,
+		"rootcertbundle":        rootCertBundleFilename,
+		"verificationcache":     true,
+		"verificationcachesize": 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := ac.(*accessController).cache
+	if cache == nil {
+		t.Fatal("expected verification cache to be enabled")
+	}
+
+	testAccess := auth.Access{
+		Resource: auth.Resource{Type: "repository", Name: "foo/bar"},
+		Action:   "pull",
+	}
+	otherAccess := auth.Access{
+		Resource: auth.Resource{Type: "repository", Name: "foo/baz"},
+		Action:   "pull",
+	}
+
+	req, err := http.NewRequest("GET", "http://example.com/v2/foo/bar/manifests/latest", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithRequest(context.Background(), req)
+
+	now := time.Now()
+	token, err := makeTestToken(
+		issuer, service,
+		[]*ResourceActions{{Type: testAccess.Type, Name: testAccess.Name, Actions: []string{testAccess.Action}}},
+		rootKeys[0], 1, now, now.Add(5*time.Minute),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawToken := token.compactRaw()
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", rawToken))
+
+	// 1. The token is cached once verified.
+	if _, err := ac.Authorized(ctx, testAccess); err != nil {
+		t.Fatalf("accessController returned unexpected error: %s", err)
+	}
+	cached := cache.get(rawToken)
+	if cached == nil {
+		t.Fatal("expected verified token to be cached")
+	}
+
+	// 2. The cached token is reused, and its scope is still enforced.
+	if _, err := ac.Authorized(ctx, testAccess); err != nil {
+		t.Fatalf("accessController returned unexpected error: %s", err)
+	}
+	if cache.get(rawToken) != cached {
+		t.Fatal("expected cached token to be reused")
+	}
+	_, err = ac.Authorized(ctx, otherAccess)
+	challenge, ok := err.(auth.Challenge)
+	if !ok {
+		t.Fatal("accessController did not return a challenge")
+	}
+	if challenge.Error() != ErrInsufficientScope.Error() {
+		t.Fatalf("accessController did not get expected error - got %s - expected %s", challenge, ErrInsufficientScope)
+	}
+
+	// 3. The token is no longer served from the cache once expired.
+	cache.now = func() time.Time { return now.Add(5*time.Minute + Leeway) }
+	if cache.get(rawToken) != nil {
+		t.Fatal("expected expired token not to be served from the cache")
+	}
+	cache.now = time.Now
+
+	// 4. The cache is bounded.
+	if _, err := ac.Authorized(ctx, testAccess); err != nil {
+		t.Fatalf("accessController returned unexpected error: %s", err)
+	}
+	otherToken, err := makeTestToken(
+		issuer, service,
+		[]*ResourceActions{{Type: otherAccess.Type, Name: otherAccess.Name, Actions: []string{otherAccess.Action}}},
+		rootKeys[0], 1, now, now.Add(5*time.Minute),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", otherToken.compactRaw()))
+	if _, err := ac.Authorized(ctx, otherAccess); err != nil {
+		t.Fatalf("accessController returned unexpected error: %s", err)
+	}
+	if len(cache.entries) != 1 {
+		t.Fatalf("expected 1 cached token, got %d", len(cache.entries))
+	}
+	if cache.get(rawToken) != nil {
+		t.Fatal("expected first token to be evicted")
+	}
+}
+
+func TestAccessControllerVerificationCacheOptions(t *testing.T) {
+	tt := []map[string]interface{}{
+		{"verificationcache": "yes"},
+		{"verificationcache": true, "verificationcachesize": 0},
+		{"verificationcache": true, "verificationcachesize": "10"},
+	}
+
+	for _, options := range tt {
+		options["realm"] = "https://auth.example.com/token/"
+		options["issuer"] = "test-issuer.example.com"
+		options["service"] = "test-service.example.com"
+		options["rootcertbundle"] = "/dev/null"
+
+		if _, err := checkOptions(options); err == nil {
+			t.Fatalf("expected error for options %v", options)
+		}
+	}
+
+	opts, err := checkOptions(map[string]interface{}{
+		"realm":          "https://auth.example.com/token/",
+		"issuer":         "test-issuer.example.com",
+		"service":        "test-service.example.com",
+		"rootcertbundle": "/dev/null",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.verificationCache {
+		t.Fatal("expected verification cache to be disabled by default")
+	}
+	if opts.verificationCacheSize != defaultVerificationCacheSize {
+		t.Fatalf("expected default verification cache size %d, got %d", defaultVerificationCacheSize, opts.verificationCacheSize)
+	}
+}
@@ -0,0 +1,288 @@
+package datamover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/distribution"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/internal/background"
+	"github.com/docker/distribution/registry/storage"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/hashicorp/go-multierror"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	componentKey = "component"
+	name         = "registry.datamover.Mover"
+)
+
+var (
+	defaultInterval   = 5 * time.Second
+	defaultTimeout    = time.Hour
+	defaultMaxBackoff = 24 * time.Hour
+	// leaseMargin is added to the timeout when claiming moves, so that a move is not handed out to another mover while
+	// still being processed.
+	leaseMargin = time.Minute
+
+	// for test purposes (mocking)
+	moveStoreConstructor       = datastore.NewStorageMoveStore
+	repositoryStoreConstructor = func(db datastore.Queryer) datastore.RepositoryStore { return datastore.NewRepositoryStore(db) }
+)
+
+// Mover processes repository storage moves, relocating the blobs of each repository from the source to the target
+// storage. A move goes through the following states:
+//
+//  1. scheduled: the move was requested but not picked up yet;
+//  2. copying: all blobs linked to the repository are copied to the target storage and their digest verified. The
+//     repository is still served from the source storage;
+//  3. cleanup: the repository is served from the target storage. Blobs linked to the repository while the previous
+//     step was running are copied, and then blobs no longer needed by any repository served from the source storage
+//     are deleted from it;
+//  4. finished: the move is complete.
+//
+// Failed steps are retried with an exponential back off.
+type Mover struct {
+	db     datastore.Handler
+	source storagedriver.StorageDriver
+	target storagedriver.StorageDriver
+	cfg    background.Config
+}
+
+// Option provides functional options for New.
+type Option func(*Mover)
+
+// WithLogger sets the logger.
+func WithLogger(l dcontext.Logger) Option {
+	return func(m *Mover) {
+		m.cfg.Logger = l
+	}
+}
+
+// WithInterval sets the interval between runs when there are no moves to be processed or the last run failed.
+// Defaults to 5 seconds.
+func WithInterval(d time.Duration) Option {
+	return func(m *Mover) {
+		m.cfg.Interval = d
+	}
+}
+
+// WithTimeout sets the maximum amount of time allowed to process a single move. Defaults to 1 hour.
+func WithTimeout(d time.Duration) Option {
+	return func(m *Mover) {
+		m.cfg.Timeout = d
+	}
+}
+
+// WithMaxBackoff sets the maximum exponential back off duration used to postpone the retry of failed moves. Defaults
+// to 24 hours.
+func WithMaxBackoff(d time.Duration) Option {
+	return func(m *Mover) {
+		m.cfg.MaxBackoff = d
+	}
+}
+
+// New creates a new Mover. Blobs are relocated from the source to the target storage driver.
+func New(db datastore.Handler, source, target storagedriver.StorageDriver, opts ...Option) *Mover {
+	m := &Mover{
+		db:     db,
+		source: source,
+		target: target,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.cfg.ApplyDefaults(background.Config{
+		Interval:   defaultInterval,
+		Timeout:    defaultTimeout,
+		MaxBackoff: defaultMaxBackoff,
+	})
+	m.cfg.Logger = m.cfg.Logger.WithField(componentKey, name)
+
+	return m
+}
+
+// Start starts the Mover. This is a blocking call that processes moves in a loop until the provided context is
+// canceled. Moves are processed back to back while available, otherwise the loop sleeps for the configured interval
+// before trying again. The same applies after a failed run.
+func (m *Mover) Start(ctx context.Context) error {
+	m.cfg.Logger.WithField("interval_s", m.cfg.Interval.Seconds()).Info("starting data mover")
+
+	return m.cfg.Loop(ctx, m.Run)
+}
+
+// Run processes the next available storage move, stepping through all states until finished. A bool is returned to
+// indicate whether there was a move available or not, regardless if processing it succeeded or not. Progress is
+// persisted after each step, so a failed move is postponed and later resumed from the step that failed.
+func (m *Mover) Run(ctx context.Context) (bool, error) {
+	ctx = m.cfg.InjectCorrelationID(ctx)
+	log := dcontext.GetLogger(ctx)
+
+	s := moveStoreConstructor(m.db)
+	sm, err := s.Next(ctx, m.cfg.Timeout+leaseMargin)
+	if err != nil {
+		return false, err
+	}
+	if sm == nil {
+		log.Debug("no move available")
+		return false, nil
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"repository":   sm.RepositoryPath,
+		"state":        sm.State,
+		"review_count": sm.ReviewCount,
+	})
+	log.Info("processing storage move")
+
+	ctx2, cancel := context.WithTimeout(ctx, m.cfg.Timeout)
+	err = m.move(ctx2, s, sm)
+	cancel()
+
+	if err != nil {
+		d := m.cfg.RetryBackoff(sm.ReviewCount)
+		log.WithError(err).WithFields(logrus.Fields{
+			"state":            sm.State,
+			"backoff_duration": d.String(),
+		}).Warn("failed to move repository, postponing")
+		if innerErr := s.Postpone(ctx, sm, d, err.Error()); innerErr != nil {
+			return true, multierror.Append(err, innerErr)
+		}
+		return true, err
+	}
+
+	log.Info("repository moved")
+	return true, nil
+}
+
+// move steps through the remaining states of sm.
+func (m *Mover) move(ctx context.Context, s datastore.StorageMoveStore, sm *models.StorageMove) error {
+	log := dcontext.GetLogger(ctx)
+	repo := &models.Repository{ID: sm.RepositoryID, NamespaceID: sm.NamespaceID, Path: sm.RepositoryPath}
+
+	for {
+		var next models.StorageMoveState
+
+		switch sm.State {
+		case models.StorageMoveStateScheduled:
+			next = models.StorageMoveStateCopying
+		case models.StorageMoveStateCopying:
+			if err := m.copyBlobs(ctx, repo); err != nil {
+				return err
+			}
+			next = models.StorageMoveStateCleanup
+		case models.StorageMoveStateCleanup:
+			// blobs may have been pushed to the source storage after the copy and before routing the repository
+			if err := m.copyBlobs(ctx, repo); err != nil {
+				return err
+			}
+			if err := m.cleanupSource(ctx, s, repo); err != nil {
+				return err
+			}
+			next = models.StorageMoveStateFinished
+		default:
+			return nil
+		}
+
+		log.WithFields(logrus.Fields{"from_state": sm.State, "to_state": next}).Info("advancing storage move")
+		if err := s.Advance(ctx, sm, next); err != nil {
+			return err
+		}
+	}
+}
+
+// copyBlobs copies all blobs linked to repo from the source to the target storage, verifying their digest.
+func (m *Mover) copyBlobs(ctx context.Context, repo *models.Repository) error {
+	log := dcontext.GetLogger(ctx)
+
+	bb, err := repositoryStoreConstructor(m.db).Blobs(ctx, repo)
+	if err != nil {
+		return fmt.Errorf("finding repository blobs: %w", err)
+	}
+
+	bts, err := storage.NewBlobTransferService(m.source, m.target)
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, b := range bb {
+		if err := bts.Transfer(ctx, b.Digest); err != nil {
+			skip, innerErr := m.unlinkedSinceListing(ctx, repo, b.Digest, err)
+			if innerErr != nil {
+				return innerErr
+			}
+			if skip {
+				log.WithField("digest", b.Digest).Warn("blob no longer linked to repository, skipping")
+				continue
+			}
+			return fmt.Errorf("copying blob %s: %w", b.Digest, err)
+		}
+		if err := bts.Verify(ctx, b.Digest); err != nil {
+			return fmt.Errorf("verifying blob %s: %w", b.Digest, err)
+		}
+		size += b.Size
+	}
+
+	log.WithFields(logrus.Fields{"blob_count": len(bb), "size_bytes": size}).Info("repository blobs copied")
+
+	return nil
+}
+
+// unlinkedSinceListing determines whether a failed blob transfer was caused by the blob having been unlinked from the
+// repository, and possibly garbage collected, after listing the repository blobs, in which case it can be skipped.
+func (m *Mover) unlinkedSinceListing(ctx context.Context, repo *models.Repository, d digest.Digest, err error) (bool, error) {
+	var tErr distribution.ErrBlobTransferFailed
+	if !errors.As(err, &tErr) || !errors.As(tErr.Reason, &storagedriver.PathNotFoundError{}) {
+		return false, nil
+	}
+
+	linked, err := repositoryStoreConstructor(m.db).ExistsBlob(ctx, repo, d)
+	if err != nil {
+		return false, fmt.Errorf("checking blob link: %w", err)
+	}
+
+	return !linked, nil
+}
+
+// cleanupSource deletes the blobs of repo from the source storage, unless still needed by another repository served
+// from it.
+func (m *Mover) cleanupSource(ctx context.Context, s datastore.StorageMoveStore, repo *models.Repository) error {
+	log := dcontext.GetLogger(ctx)
+
+	bb, err := repositoryStoreConstructor(m.db).Blobs(ctx, repo)
+	if err != nil {
+		return fmt.Errorf("finding repository blobs: %w", err)
+	}
+
+	vacuum := storage.NewVacuum(m.source)
+	var deleted int
+	for _, b := range bb {
+		referenced, err := s.ReferencedFromSource(ctx, b.Digest)
+		if err != nil {
+			return err
+		}
+		if referenced {
+			continue
+		}
+		if err := vacuum.RemoveBlob(ctx, b.Digest); err != nil {
+			if errors.As(err, &storagedriver.PathNotFoundError{}) {
+				continue
+			}
+			return fmt.Errorf("deleting blob %s from source storage: %w", b.Digest, err)
+		}
+		deleted++
+	}
+
+	log.WithFields(logrus.Fields{"blob_count": len(bb), "deleted_count": deleted}).Info("source storage cleaned up")
+
+	return nil
+}
@@ -0,0 +1,248 @@
+package datamover
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/internal/background"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMoveStore is an in-memory datastore.StorageMoveStore.
+type fakeMoveStore struct {
+	moves      []*models.StorageMove
+	advanced   []models.StorageMoveState
+	postponed  []time.Duration
+	referenced map[digest.Digest]bool
+	err        error
+}
+
+func (s *fakeMoveStore) Create(_ context.Context, m *models.StorageMove) error {
+	m.State = models.StorageMoveStateScheduled
+	s.moves = append(s.moves, m)
+	return nil
+}
+
+func (s *fakeMoveStore) FindByRepository(_ context.Context, r *models.Repository) (*models.StorageMove, error) {
+	for _, m := range s.moves {
+		if m.RepositoryID == r.ID {
+			return m, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeMoveStore) Next(_ context.Context, _ time.Duration) (*models.StorageMove, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	for _, m := range s.moves {
+		if m.State != models.StorageMoveStateFinished {
+			return m, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeMoveStore) Advance(_ context.Context, m *models.StorageMove, state models.StorageMoveState) error {
+	s.advanced = append(s.advanced, state)
+	m.State = state
+	m.ReviewCount = 0
+	m.Error = ""
+	return nil
+}
+
+func (s *fakeMoveStore) Postpone(_ context.Context, m *models.StorageMove, d time.Duration, reason string) error {
+	s.postponed = append(s.postponed, d)
+	m.ReviewCount++
+	m.Error = reason
+	return nil
+}
+
+func (s *fakeMoveStore) ReferencedFromSource(_ context.Context, d digest.Digest) (bool, error) {
+	return s.referenced[d], nil
+}
+
+func stubMoveStore(tb testing.TB, s datastore.StorageMoveStore) {
+	tb.Helper()
+
+	bkp := moveStoreConstructor
+	moveStoreConstructor = func(db datastore.Queryer) datastore.StorageMoveStore { return s }
+
+	tb.Cleanup(func() { moveStoreConstructor = bkp })
+}
+
+// fakeRepositoryStore is a minimal datastore.RepositoryStore stub. Calling any method other than Blobs and ExistsBlob
+// panics.
+type fakeRepositoryStore struct {
+	datastore.RepositoryStore
+	blobs models.Blobs
+}
+
+// Blobs implements datastore.RepositoryStore.
+func (s *fakeRepositoryStore) Blobs(_ context.Context, _ *models.Repository) (models.Blobs, error) {
+	return s.blobs, nil
+}
+
+// ExistsBlob implements datastore.RepositoryStore.
+func (s *fakeRepositoryStore) ExistsBlob(_ context.Context, _ *models.Repository, d digest.Digest) (bool, error) {
+	for _, b := range s.blobs {
+		if b.Digest == d {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func stubRepositoryStore(tb testing.TB, rs datastore.RepositoryStore) {
+	tb.Helper()
+
+	bkp := repositoryStoreConstructor
+	repositoryStoreConstructor = func(db datastore.Queryer) datastore.RepositoryStore { return rs }
+
+	tb.Cleanup(func() { repositoryStoreConstructor = bkp })
+}
+
+func blobDataPath(d digest.Digest) string {
+	return "/docker/registry/v2/blobs/" + d.Algorithm().String() + "/" + d.Hex()[0:2] + "/" + d.Hex() + "/data"
+}
+
+func putBlob(t *testing.T, d storagedriver.StorageDriver, content []byte) *models.Blob {
+	t.Helper()
+
+	dgst := digest.FromBytes(content)
+	require.NoError(t, d.PutContent(context.Background(), blobDataPath(dgst), content))
+
+	return &models.Blob{Digest: dgst, Size: int64(len(content))}
+}
+
+func TestMover_Run_NoMove(t *testing.T) {
+	stubMoveStore(t, &fakeMoveStore{})
+
+	m := New(nil, inmemory.New(), inmemory.New())
+	found, err := m.Run(context.Background())
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestMover_Run_NextError(t *testing.T) {
+	stubMoveStore(t, &fakeMoveStore{err: errors.New("foo")})
+
+	m := New(nil, inmemory.New(), inmemory.New())
+	found, err := m.Run(context.Background())
+	require.EqualError(t, err, "foo")
+	require.False(t, found)
+}
+
+func TestMover_Run(t *testing.T) {
+	ctx := context.Background()
+	source, target := inmemory.New(), inmemory.New()
+
+	shared := putBlob(t, source, []byte("shared"))
+	exclusive := putBlob(t, source, []byte("exclusive"))
+	stubRepositoryStore(t, &fakeRepositoryStore{blobs: models.Blobs{shared, exclusive}})
+
+	s := &fakeMoveStore{referenced: map[digest.Digest]bool{shared.Digest: true}}
+	require.NoError(t, s.Create(ctx, &models.StorageMove{RepositoryID: 1, RepositoryPath: "foo/bar"}))
+	stubMoveStore(t, s)
+
+	m := New(nil, source, target)
+	found, err := m.Run(ctx)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	require.Equal(t, []models.StorageMoveState{
+		models.StorageMoveStateCopying,
+		models.StorageMoveStateCleanup,
+		models.StorageMoveStateFinished,
+	}, s.advanced)
+	require.Empty(t, s.postponed)
+
+	// all blobs were copied to the target storage
+	for _, b := range []*models.Blob{shared, exclusive} {
+		_, err := target.Stat(ctx, blobDataPath(b.Digest))
+		require.NoError(t, err)
+	}
+
+	// only blobs not referenced by other repositories served from the source storage were deleted from it
+	_, err = source.Stat(ctx, blobDataPath(shared.Digest))
+	require.NoError(t, err)
+	_, err = source.Stat(ctx, blobDataPath(exclusive.Digest))
+	require.True(t, errors.As(err, &storagedriver.PathNotFoundError{}))
+
+	// there is nothing left to process
+	found, err = m.Run(ctx)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestMover_Run_SkipsUnlinkedBlob(t *testing.T) {
+	ctx := context.Background()
+	source, target := inmemory.New(), inmemory.New()
+
+	// the blob was listed as linked to the repository, but was unlinked and deleted from the source storage before
+	// being copied
+	b := &models.Blob{Digest: digest.FromString("foo"), Size: 3}
+	stubRepositoryStore(t, &staleRepositoryStore{fakeRepositoryStore: &fakeRepositoryStore{}, listed: models.Blobs{b}})
+
+	s := &fakeMoveStore{}
+	require.NoError(t, s.Create(ctx, &models.StorageMove{RepositoryID: 1, RepositoryPath: "foo/bar"}))
+	stubMoveStore(t, s)
+
+	found, err := New(nil, source, target).Run(ctx)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, models.StorageMoveStateFinished, s.moves[0].State)
+}
+
+// staleRepositoryStore lists a fixed set of blobs, while reporting links based on the wrapped fakeRepositoryStore.
+type staleRepositoryStore struct {
+	*fakeRepositoryStore
+	listed models.Blobs
+}
+
+// Blobs implements datastore.RepositoryStore.
+func (s *staleRepositoryStore) Blobs(_ context.Context, _ *models.Repository) (models.Blobs, error) {
+	return s.listed, nil
+}
+
+func TestMover_Run_Postpones(t *testing.T) {
+	ctx := context.Background()
+	source, target := inmemory.New(), inmemory.New()
+
+	// the blob is linked to the repository but missing from the source storage
+	b := &models.Blob{Digest: digest.FromString("foo"), Size: 3}
+	stubRepositoryStore(t, &fakeRepositoryStore{blobs: models.Blobs{b}})
+
+	s := &fakeMoveStore{}
+	require.NoError(t, s.Create(ctx, &models.StorageMove{RepositoryID: 1, RepositoryPath: "foo/bar"}))
+	stubMoveStore(t, s)
+
+	found, err := New(nil, source, target).Run(ctx)
+	require.Error(t, err)
+	require.True(t, found)
+
+	// the move is resumed from the step that failed
+	require.Equal(t, models.StorageMoveStateCopying, s.moves[0].State)
+	require.Equal(t, []time.Duration{background.BaseRetryBackoff}, s.postponed)
+	require.Equal(t, 1, s.moves[0].ReviewCount)
+	require.Contains(t, s.moves[0].Error, b.Digest.String())
+}
+
+func TestMover_RetryBackoff(t *testing.T) {
+	m := New(nil, inmemory.New(), inmemory.New(), WithMaxBackoff(10*time.Minute))
+
+	require.Equal(t, 30*time.Second, m.cfg.RetryBackoff(-1))
+	require.Equal(t, 30*time.Second, m.cfg.RetryBackoff(0))
+	require.Equal(t, time.Minute, m.cfg.RetryBackoff(1))
+	require.Equal(t, 8*time.Minute, m.cfg.RetryBackoff(4))
+	require.Equal(t, 10*time.Minute, m.cfg.RetryBackoff(5))
+	require.Equal(t, 10*time.Minute, m.cfg.RetryBackoff(64))
+}
@@ -24,6 +24,7 @@ type BlobWriter interface {
 	Create(ctx context.Context, b *models.Blob) error
 	CreateOrFind(ctx context.Context, b *models.Blob) error
 	Delete(ctx context.Context, d digest.Digest) error
+	MarkVerified(ctx context.Context, b *models.Blob) error
 }
 
 // BlobStore is the interface that a blob store should conform to.
@@ -46,7 +47,7 @@ func scanFullBlob(row *sql.Row) (*models.Blob, error) {
 	var dgst Digest
 	b := new(models.Blob)
 
-	if err := row.Scan(&b.MediaType, &dgst, &b.Size, &b.CreatedAt); err != nil {
+	if err := row.Scan(&b.MediaType, &dgst, &b.Size, &b.CreatedAt, &b.VerifiedAt); err != nil {
 		if err != sql.ErrNoRows {
 			return nil, fmt.Errorf("scanning blob: %w", err)
 		}
@@ -70,7 +71,7 @@ func scanFullBlobs(rows *sql.Rows) (models.Blobs, error) {
 		var dgst Digest
 		b := new(models.Blob)
 
-		err := rows.Scan(&b.MediaType, &dgst, &b.Size, &b.CreatedAt)
+		err := rows.Scan(&b.MediaType, &dgst, &b.Size, &b.CreatedAt, &b.VerifiedAt)
 		if err != nil {
 			return nil, fmt.Errorf("scanning blob: %w", err)
 		}
@@ -97,7 +98,8 @@ func (s *blobStore) FindByDigest(ctx context.Context, d digest.Digest) (*models.
 			mt.media_type,
 			encode(b.digest, 'hex') as digest,
 			b.size,
-			b.created_at
+			b.created_at,
+			b.verified_at
 		FROM
 			blobs AS b
 			JOIN media_types AS mt ON b.media_type_id = mt.id
@@ -120,7 +122,8 @@ func (s *blobStore) FindAll(ctx context.Context) (models.Blobs, error) {
 			mt.media_type,
 			encode(b.digest, 'hex') as digest,
 			b.size,
-			b.created_at
+			b.created_at,
+			b.verified_at
 		FROM
 			blobs AS b
 			JOIN media_types AS mt ON b.media_type_id = mt.id`
@@ -226,7 +229,33 @@ func (s *blobStore) Delete(ctx context.Context, d digest.Digest) error {
 		return fmt.Errorf("deleting blob: %w", err)
 	}
 	if n == 0 {
-		return ErrNotFound
+		return ErrBlobNotFound
+	}
+
+	return nil
+}
+
+// MarkVerified records that the content of blob b was verified against its digest at the current time.
+func (s *blobStore) MarkVerified(ctx context.Context, b *models.Blob) error {
+	defer metrics.InstrumentQuery("blob_mark_verified")()
+	q := `UPDATE
+			blobs
+		SET
+			verified_at = now()
+		WHERE
+			digest = decode($1, 'hex')
+		RETURNING
+			verified_at`
+
+	dgst, err := NewDigest(b.Digest)
+	if err != nil {
+		return err
+	}
+	if err := s.db.QueryRowContext(ctx, q, dgst).Scan(&b.VerifiedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrBlobNotFound
+		}
+		return fmt.Errorf("marking blob as verified: %w", err)
 	}
 
 	return nil
@@ -223,5 +223,30 @@ func TestBlobStore_Delete(t *testing.T) {
 func TestBlobStore_Delete_NotFound(t *testing.T) {
 	s := datastore.NewBlobStore(suite.db)
 	err := s.Delete(suite.ctx, "sha256:b9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9")
-	require.EqualError(t, err, datastore.ErrNotFound.Error())
+	require.ErrorIs(t, err, datastore.ErrBlobNotFound)
+}
+
+func TestBlobStore_MarkVerified(t *testing.T) {
+	reloadBlobFixtures(t)
+
+	dgst := digest.Digest("sha256:c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9")
+	s := datastore.NewBlobStore(suite.db)
+	b, err := s.FindByDigest(suite.ctx, dgst)
+	require.NoError(t, err)
+	require.False(t, b.VerifiedAt.Valid)
+
+	err = s.MarkVerified(suite.ctx, b)
+	require.NoError(t, err)
+	require.True(t, b.VerifiedAt.Valid)
+	require.False(t, b.VerifiedAt.Time.IsZero())
+
+	b2, err := s.FindByDigest(suite.ctx, dgst)
+	require.NoError(t, err)
+	require.Equal(t, b, b2)
+}
+
+func TestBlobStore_MarkVerified_NotFound(t *testing.T) {
+	s := datastore.NewBlobStore(suite.db)
+	err := s.MarkVerified(suite.ctx, &models.Blob{Digest: "sha256:b9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9"})
+	require.ErrorIs(t, err, datastore.ErrBlobNotFound)
 }
@@ -0,0 +1,148 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// BlobUploadStore is the interface that a blob upload store should conform to.
+type BlobUploadStore interface {
+	Create(ctx context.Context, u *models.BlobUpload) error
+	FindStartedBefore(ctx context.Context, date time.Time, limit int) ([]*models.BlobUpload, error)
+	Count(ctx context.Context) (int, error)
+	CountByRepositoryPath(ctx context.Context, path string) (int, error)
+	Delete(ctx context.Context, id string) error
+	DeleteMany(ctx context.Context, ids []string) error
+}
+
+type blobUploadStore struct {
+	db Queryer
+}
+
+// NewBlobUploadStore builds a new blobUploadStore.
+func NewBlobUploadStore(db Queryer) BlobUploadStore {
+	return &blobUploadStore{db: db}
+}
+
+// Create records a new blob upload session. Recording the same session more than once has no effect.
+func (s *blobUploadStore) Create(ctx context.Context, u *models.BlobUpload) error {
+	defer metrics.InstrumentQuery("blob_upload_create")()
+	q := `INSERT INTO blob_uploads (id, repository_path, started_at)
+			VALUES ($1, $2, $3)
+		ON CONFLICT (id)
+			DO NOTHING`
+
+	if _, err := s.db.ExecContext(ctx, q, u.ID, u.RepositoryPath, u.StartedAt); err != nil {
+		return fmt.Errorf("creating blob upload: %w", err)
+	}
+
+	return nil
+}
+
+// FindStartedBefore finds up to limit blob upload sessions started before date, oldest first.
+func (s *blobUploadStore) FindStartedBefore(ctx context.Context, date time.Time, limit int) ([]*models.BlobUpload, error) {
+	defer metrics.InstrumentQuery("blob_upload_find_started_before")()
+	q := `SELECT
+			id,
+			repository_path,
+			started_at
+		FROM
+			blob_uploads
+		WHERE
+			started_at < $1
+		ORDER BY
+			started_at
+		LIMIT $2`
+
+	rows, err := s.db.QueryContext(ctx, q, date, limit)
+	if err != nil {
+		return nil, fmt.Errorf("finding blob uploads: %w", err)
+	}
+	defer rows.Close()
+
+	uu := make([]*models.BlobUpload, 0)
+	for rows.Next() {
+		u := new(models.BlobUpload)
+		if err := rows.Scan(&u.ID, &u.RepositoryPath, &u.StartedAt); err != nil {
+			return nil, fmt.Errorf("scanning blob upload: %w", err)
+		}
+		uu = append(uu, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning blob uploads: %w", err)
+	}
+
+	return uu, nil
+}
+
+// Count counts all in-flight blob upload sessions.
+func (s *blobUploadStore) Count(ctx context.Context) (int, error) {
+	defer metrics.InstrumentQuery("blob_upload_count")()
+	q := "SELECT COUNT(*) FROM blob_uploads"
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, q).Scan(&count); err != nil {
+		return 0, fmt.Errorf("counting blob uploads: %w", err)
+	}
+
+	return count, nil
+}
+
+// CountByRepositoryPath counts the in-flight blob upload sessions of the repository with the given path.
+func (s *blobUploadStore) CountByRepositoryPath(ctx context.Context, path string) (int, error) {
+	defer metrics.InstrumentQuery("blob_upload_count_by_repository_path")()
+	q := "SELECT COUNT(*) FROM blob_uploads WHERE repository_path = $1"
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, q, path).Scan(&count); err != nil {
+		return 0, fmt.Errorf("counting blob uploads: %w", err)
+	}
+
+	return count, nil
+}
+
+// Delete removes a blob upload session. ErrBlobUploadNotFound is returned if it does not exist.
+func (s *blobUploadStore) Delete(ctx context.Context, id string) error {
+	defer metrics.InstrumentQuery("blob_upload_delete")()
+	q := "DELETE FROM blob_uploads WHERE id = $1 RETURNING id"
+
+	if err := s.db.QueryRowContext(ctx, q, id).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrBlobUploadNotFound
+		}
+		return fmt.Errorf("deleting blob upload: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteMany removes multiple blob upload sessions. Sessions that do not exist are ignored.
+func (s *blobUploadStore) DeleteMany(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	defer metrics.InstrumentQuery("blob_upload_delete_many")()
+	q := "DELETE FROM blob_uploads WHERE id IN (%s)"
+
+	params := make([]string, 0, len(ids))
+	args := make([]interface{}, 0, len(ids))
+	for i, id := range ids {
+		params = append(params, fmt.Sprintf("$%d", i+1))
+		args = append(args, id)
+	}
+	q = fmt.Sprintf(q, strings.Join(params, ","))
+
+	if _, err := s.db.ExecContext(ctx, q, args...); err != nil {
+		return fmt.Errorf("deleting blob uploads: %w", err)
+	}
+
+	return nil
+}
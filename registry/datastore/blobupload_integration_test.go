@@ -0,0 +1,166 @@
+// +build integration
+
+package datastore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func reloadBlobUploadFixtures(tb testing.TB) {
+	testutil.ReloadFixtures(tb, suite.db, suite.basePath, testutil.BlobUploadsTable)
+}
+
+func unloadBlobUploadFixtures(tb testing.TB) {
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.BlobUploadsTable))
+}
+
+func blobUploadIDs(uu []*models.BlobUpload) []string {
+	ids := make([]string, 0, len(uu))
+	for _, u := range uu {
+		ids = append(ids, u.ID)
+	}
+	return ids
+}
+
+func TestBlobUploadStore_FindStartedBefore(t *testing.T) {
+	reloadBlobUploadFixtures(t)
+
+	s := datastore.NewBlobUploadStore(suite.db)
+	uu, err := s.FindStartedBefore(suite.ctx, time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, uu, 2)
+
+	// see testdata/fixtures/blob_uploads.sql
+	expected := []*models.BlobUpload{
+		{
+			ID:             "7e5b9a1c-3f0a-4d0e-9c34-1f2a6b8d0e01",
+			RepositoryPath: "gitlab-org/gitlab-test",
+			StartedAt:      testutil.ParseTimestamp(t, "2020-03-02 17:50:26.461745", uu[0].StartedAt.Location()),
+		},
+		{
+			ID:             "a3c1f2d4-8b6e-4a5f-b1d2-9e7c6f5a4b02",
+			RepositoryPath: "gitlab-org/gitlab-test/backend",
+			StartedAt:      testutil.ParseTimestamp(t, "2020-03-02 17:57:43.283783", uu[1].StartedAt.Location()),
+		},
+	}
+	require.Equal(t, expected, uu)
+}
+
+func TestBlobUploadStore_FindStartedBefore_Limit(t *testing.T) {
+	reloadBlobUploadFixtures(t)
+
+	s := datastore.NewBlobUploadStore(suite.db)
+	uu, err := s.FindStartedBefore(suite.ctx, time.Now(), 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"7e5b9a1c-3f0a-4d0e-9c34-1f2a6b8d0e01"}, blobUploadIDs(uu))
+}
+
+func TestBlobUploadStore_FindStartedBefore_None(t *testing.T) {
+	unloadBlobUploadFixtures(t)
+
+	s := datastore.NewBlobUploadStore(suite.db)
+	uu, err := s.FindStartedBefore(suite.ctx, time.Now(), 10)
+	require.NoError(t, err)
+	require.Empty(t, uu)
+}
+
+func TestBlobUploadStore_Count(t *testing.T) {
+	reloadBlobUploadFixtures(t)
+
+	count, err := datastore.NewBlobUploadStore(suite.db).Count(suite.ctx)
+	require.NoError(t, err)
+	// see testdata/fixtures/blob_uploads.sql
+	require.Equal(t, 3, count)
+}
+
+func TestBlobUploadStore_Count_None(t *testing.T) {
+	unloadBlobUploadFixtures(t)
+
+	count, err := datastore.NewBlobUploadStore(suite.db).Count(suite.ctx)
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+func TestBlobUploadStore_CountByRepositoryPath(t *testing.T) {
+	reloadBlobUploadFixtures(t)
+
+	s := datastore.NewBlobUploadStore(suite.db)
+	count, err := s.CountByRepositoryPath(suite.ctx, "gitlab-org/gitlab-test")
+	require.NoError(t, err)
+	// see testdata/fixtures/blob_uploads.sql
+	require.Equal(t, 1, count)
+
+	count, err = s.CountByRepositoryPath(suite.ctx, "foo/bar")
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+func TestBlobUploadStore_Create(t *testing.T) {
+	unloadBlobUploadFixtures(t)
+
+	s := datastore.NewBlobUploadStore(suite.db)
+	u := &models.BlobUpload{
+		ID:             "5f0e1d2c-3b4a-4958-8776-a5b4c3d2e1f0",
+		RepositoryPath: "foo/bar",
+		StartedAt:      time.Now().Add(-time.Hour),
+	}
+	require.NoError(t, s.Create(suite.ctx, u))
+	// recording the same session again is a no-op
+	require.NoError(t, s.Create(suite.ctx, u))
+
+	uu, err := s.FindStartedBefore(suite.ctx, time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, uu, 1)
+	require.Equal(t, u.ID, uu[0].ID)
+	require.Equal(t, u.RepositoryPath, uu[0].RepositoryPath)
+	require.WithinDuration(t, u.StartedAt, uu[0].StartedAt, time.Millisecond)
+}
+
+func TestBlobUploadStore_Delete(t *testing.T) {
+	reloadBlobUploadFixtures(t)
+
+	s := datastore.NewBlobUploadStore(suite.db)
+	require.NoError(t, s.Delete(suite.ctx, "7e5b9a1c-3f0a-4d0e-9c34-1f2a6b8d0e01"))
+
+	uu, err := s.FindStartedBefore(suite.ctx, time.Now(), 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a3c1f2d4-8b6e-4a5f-b1d2-9e7c6f5a4b02"}, blobUploadIDs(uu))
+}
+
+func TestBlobUploadStore_Delete_NotFound(t *testing.T) {
+	unloadBlobUploadFixtures(t)
+
+	s := datastore.NewBlobUploadStore(suite.db)
+	err := s.Delete(suite.ctx, "7e5b9a1c-3f0a-4d0e-9c34-1f2a6b8d0e01")
+	require.ErrorIs(t, err, datastore.ErrBlobUploadNotFound)
+}
+
+func TestBlobUploadStore_DeleteMany(t *testing.T) {
+	reloadBlobUploadFixtures(t)
+
+	s := datastore.NewBlobUploadStore(suite.db)
+	err := s.DeleteMany(suite.ctx, []string{
+		"7e5b9a1c-3f0a-4d0e-9c34-1f2a6b8d0e01",
+		"c9d8e7f6-5a4b-4c3d-8e2f-1a0b9c8d7e03",
+		// unknown sessions are ignored
+		"00000000-0000-4000-8000-000000000000",
+	})
+	require.NoError(t, err)
+
+	uu, err := s.FindStartedBefore(suite.ctx, time.Date(10000, time.January, 1, 0, 0, 0, 0, time.UTC), 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a3c1f2d4-8b6e-4a5f-b1d2-9e7c6f5a4b02"}, blobUploadIDs(uu))
+}
+
+func TestBlobUploadStore_DeleteMany_Empty(t *testing.T) {
+	reloadBlobUploadFixtures(t)
+
+	s := datastore.NewBlobUploadStore(suite.db)
+	require.NoError(t, s.DeleteMany(suite.ctx, nil))
+}
@@ -14,9 +14,12 @@ import (
 	"time"
 
 	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/registry/internal/tracing"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/stdlib"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const driverName = "pgx"
@@ -47,14 +50,17 @@ type Transactor interface {
 // DB implements Handler.
 type DB struct {
 	*sql.DB
-	dsn *DSN
+	dsn             *DSN
+	retryConfig     *RetryConfig
+	logger          *logrus.Entry
+	repositoryCache *RepositoryCache
 }
 
 // BeginTx wraps sql.Tx from the innner sql.DB within a datastore.Tx.
 func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (Transactor, error) {
 	tx, err := db.DB.BeginTx(ctx, opts)
 
-	return &Tx{tx}, err
+	return &Tx{Tx: tx, repositoryCache: db.repositoryCache}, err
 }
 
 // Begin wraps sql.Tx from the inner sql.DB within a datastore.Tx.
@@ -62,9 +68,84 @@ func (db *DB) Begin() (Transactor, error) {
 	return db.BeginTx(context.Background(), nil)
 }
 
+// ExecContext wraps sql.DB.ExecContext, recording a tracing span for the query.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	res, err := db.DB.ExecContext(ctx, query, args...)
+	tracing.EndSpan(span, err)
+
+	return res, err
+}
+
+// ReplicationLag returns the maximum replay lag across all replicas attached to the database server, as reported by
+// pg_stat_replication. Zero is returned if there are no replicas or if the database user is not allowed to see their
+// lag (requires the pg_monitor role or superuser privileges).
+func (db *DB) ReplicationLag(ctx context.Context) (time.Duration, error) {
+	q := "SELECT COALESCE(EXTRACT(EPOCH FROM MAX(replay_lag)), 0) FROM pg_stat_replication"
+
+	var seconds float64
+	if err := db.QueryRowContext(ctx, q).Scan(&seconds); err != nil {
+		return 0, fmt.Errorf("querying replication lag: %w", err)
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
 // Tx implements Transactor.
 type Tx struct {
 	*sql.Tx
+	repositoryCache *RepositoryCache
+	afterCommit     []func()
+}
+
+// Commit wraps sql.Tx.Commit, running the functions registered with onCommit once the transaction is committed.
+func (tx *Tx) Commit() error {
+	if err := tx.Tx.Commit(); err != nil {
+		return err
+	}
+	for _, fn := range tx.afterCommit {
+		fn()
+	}
+
+	return nil
+}
+
+// onCommit registers fn to be run once the transaction is committed.
+func (tx *Tx) onCommit(fn func()) {
+	tx.afterCommit = append(tx.afterCommit, fn)
+}
+
+// QueryContext wraps sql.Tx.QueryContext, recording a tracing span for the query.
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	rows, err := tx.Tx.QueryContext(ctx, query, args...)
+	tracing.EndSpan(span, err)
+
+	return rows, err
+}
+
+// QueryRowContext wraps sql.Tx.QueryRowContext, recording a tracing span for the query.
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuerySpan(ctx, query)
+	row := tx.Tx.QueryRowContext(ctx, query, args...)
+	tracing.EndSpan(span, row.Err())
+
+	return row
+}
+
+// ExecContext wraps sql.Tx.ExecContext, recording a tracing span for the query.
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	res, err := tx.Tx.ExecContext(ctx, query, args...)
+	tracing.EndSpan(span, err)
+
+	return res, err
+}
+
+// startQuerySpan starts a tracing span for a database query. Queries are parameterized, so the statement is recorded
+// as is. The span must be ended with tracing.EndSpan.
+func startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	return tracing.StartSpan(ctx, "db.query", semconv.DBSystemPostgres, semconv.DBStatementKey.String(query))
 }
 
 // DSN represents the Data Source Name parameters for a DB connection.
@@ -79,6 +160,9 @@ type DSN struct {
 	SSLKey         string
 	SSLRootCert    string
 	ConnectTimeout time.Duration
+	// ReadOnly makes all transactions of the session read-only by default, so that any write is rejected by the
+	// database server.
+	ReadOnly bool
 }
 
 // String builds the string representation of a DSN.
@@ -93,6 +177,10 @@ func (dsn *DSN) String() string {
 	if dsn.ConnectTimeout > 0 {
 		connectTimeout = fmt.Sprintf("%.0f", dsn.ConnectTimeout.Seconds())
 	}
+	readOnly := ""
+	if dsn.ReadOnly {
+		readOnly = "on"
+	}
 
 	for _, param := range []struct{ k, v string }{
 		{"host", dsn.Host},
@@ -105,6 +193,7 @@ func (dsn *DSN) String() string {
 		{"sslkey", dsn.SSLKey},
 		{"sslrootcert", dsn.SSLRootCert},
 		{"connect_timeout", connectTimeout},
+		{"default_transaction_read_only", readOnly},
 	} {
 		if len(param.v) == 0 {
 			continue
@@ -128,7 +217,9 @@ type openOpts struct {
 	logger               *logrus.Entry
 	logLevel             pgx.LogLevel
 	pool                 *PoolConfig
+	retry                *RetryConfig
 	preferSimpleProtocol bool
+	repositoryCache      *RepositoryCache
 }
 
 type PoolConfig struct {
@@ -184,6 +275,21 @@ func WithPreparedStatements(b bool) OpenOption {
 	}
 }
 
+// WithRetryConfig configures the retry behavior for failed database connections and read-only queries.
+func WithRetryConfig(c *RetryConfig) OpenOption {
+	return func(opts *openOpts) {
+		opts.retry = c
+	}
+}
+
+// WithRepositoryCache configures an in-process cache of repositories looked up by path, shared by all repository
+// stores created for the database handle.
+func WithRepositoryCache(c *RepositoryCache) OpenOption {
+	return func(opts *openOpts) {
+		opts.repositoryCache = c
+	}
+}
+
 func applyOptions(opts []OpenOption) openOpts {
 	log := logrus.New()
 	log.SetOutput(ioutil.Discard)
@@ -191,6 +297,7 @@ func applyOptions(opts []OpenOption) openOpts {
 	config := openOpts{
 		logger: logrus.NewEntry(log),
 		pool:   &PoolConfig{},
+		retry:  &RetryConfig{},
 	}
 
 	for _, v := range opts {
@@ -277,8 +384,24 @@ func Open(dsn *DSN, opts ...OpenOption) (*DB, error) {
 	db.SetMaxIdleConns(config.pool.MaxIdle)
 	db.SetConnMaxLifetime(config.pool.MaxLifetime)
 
-	if err := db.Ping(); err != nil {
+	d := &DB{
+		DB:              db,
+		dsn:             dsn,
+		retryConfig:     config.retry,
+		logger:          config.logger,
+		repositoryCache: config.repositoryCache,
+	}
+
+	// retry the initial connection as well, so that the registry can start while the database is failing over
+	ping := func() error { return db.Ping() }
+	if d.retryConfig.enabled() {
+		err = d.retry(context.Background(), ping)
+	} else {
+		err = ping()
+	}
+	if err != nil {
 		return nil, err
 	}
-	return &DB{db, dsn}, nil
+
+	return d, nil
 }
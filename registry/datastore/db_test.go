@@ -33,6 +33,14 @@ func TestDSN_String(t *testing.T) {
 			},
 			out: "host=127.0.0.1 port=5432 user=registry password=secret dbname=registry_production sslmode=require sslcert=/path/to/client.crt sslkey=/path/to/client.key sslrootcert=/path/to/root.crt connect_timeout=5",
 		},
+		{
+			name: "read-only",
+			arg: datastore.DSN{
+				Host:     "127.0.0.1",
+				ReadOnly: true,
+			},
+			out: "host=127.0.0.1 default_transaction_read_only=on",
+		},
 		{
 			name: "with zero port",
 			arg: datastore.DSN{
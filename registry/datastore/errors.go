@@ -6,12 +6,43 @@ import (
 )
 
 var (
-	// ErrNotFound is returned when a row is not found on the metadata database.
+	// ErrNotFound is returned when a row is not found on the metadata database. All other not found errors wrap it, so
+	// callers that do not care about the kind of row can check for it with errors.Is.
 	ErrNotFound = errors.New("not found")
+	// ErrRepositoryNotFound is returned when a repository is not found on the metadata database.
+	ErrRepositoryNotFound = fmt.Errorf("repository %w", ErrNotFound)
+	// ErrBlobNotFound is returned when a blob is not found on the metadata database.
+	ErrBlobNotFound = fmt.Errorf("blob %w", ErrNotFound)
+	// ErrGCBlobTaskNotFound is returned when a GC blob review task is not found on the metadata database.
+	ErrGCBlobTaskNotFound = fmt.Errorf("GC blob task %w", ErrNotFound)
+	// ErrGCManifestTaskNotFound is returned when a GC manifest review task is not found on the metadata database.
+	ErrGCManifestTaskNotFound = fmt.Errorf("GC manifest task %w", ErrNotFound)
+	// ErrReplicationTaskNotFound is returned when a replication task is not found on the metadata database.
+	ErrReplicationTaskNotFound = fmt.Errorf("replication task %w", ErrNotFound)
 	// ErrManifestNotFound is returned when a manifest is not found on the metadata database.
 	ErrManifestNotFound = fmt.Errorf("manifest %w", ErrNotFound)
+	// ErrBlobUploadNotFound is returned when a blob upload session is not found on the metadata database.
+	ErrBlobUploadNotFound = fmt.Errorf("blob upload %w", ErrNotFound)
+	// ErrStorageMoveNotFound is returned when a repository storage move is not found on the metadata database.
+	ErrStorageMoveNotFound = fmt.Errorf("storage move %w", ErrNotFound)
+	// ErrRetentionPolicyNotFound is returned when a repository retention policy is not found on the metadata database.
+	ErrRetentionPolicyNotFound = fmt.Errorf("retention policy %w", ErrNotFound)
 	// ErrRefManifestNotFound is returned when a manifest referenced by a list/index is not found on the metadata database.
 	ErrRefManifestNotFound = fmt.Errorf("referenced %w", ErrManifestNotFound)
+	// ErrRepositoryExists is returned when attempting to rename a repository to the path of an existing one.
+	ErrRepositoryExists = errors.New("repository already exists")
+	// ErrRepositoryRenameNamespace is returned when attempting to rename a repository to a path within a different
+	// top-level namespace.
+	ErrRepositoryRenameNamespace = errors.New("repository can not be renamed across top-level namespaces")
+	// ErrRepositoryRenameDescendant is returned when attempting to rename a repository to its own path or to a path
+	// within its own hierarchy.
+	ErrRepositoryRenameDescendant = errors.New("repository can not be renamed to itself or one of its descendants")
+	// ErrStorageMoveExists is returned when attempting to schedule the storage move of a repository that was already
+	// scheduled.
+	ErrStorageMoveExists = errors.New("storage move already exists")
 	// ErrManifestReferencedInList is returned when attempting to delete a manifest referenced in at least one list.
 	ErrManifestReferencedInList = errors.New("manifest referenced by manifest list")
+	// ErrTagManifestMismatch is returned when a conditional tag update finds that the tag does not exist or does not
+	// point to the expected manifest.
+	ErrTagManifestMismatch = errors.New("tag does not point to the expected manifest")
 )
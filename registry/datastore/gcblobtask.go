@@ -172,7 +172,7 @@ func (s *gcBlobTaskStore) Postpone(ctx context.Context, b *models.GCBlobTask, d
 		return fmt.Errorf("postponing GC blob task: %w", err)
 	}
 	if count == 0 {
-		return fmt.Errorf("GC blob task not found")
+		return ErrGCBlobTaskNotFound
 	}
 
 	b.ReviewAfter = ra
@@ -199,7 +199,7 @@ func (s *gcBlobTaskStore) Delete(ctx context.Context, b *models.GCBlobTask) erro
 		return fmt.Errorf("deleting GC blob task: %w", err)
 	}
 	if count == 0 {
-		return fmt.Errorf("GC blob task not found")
+		return ErrGCBlobTaskNotFound
 	}
 
 	return nil
@@ -175,7 +175,7 @@ func TestGcBlobTaskStore_Postpone_NotFound(t *testing.T) {
 
 	s := datastore.NewGCBlobTaskStore(tx)
 	err = s.Postpone(suite.ctx, &models.GCBlobTask{Digest: randomDigest(t)}, 0)
-	require.EqualError(t, err, "GC blob task not found")
+	require.ErrorIs(t, err, datastore.ErrGCBlobTaskNotFound)
 }
 
 func existsGCBlobTaskByDigest(t *testing.T, db datastore.Queryer, d digest.Digest) bool {
@@ -272,7 +272,7 @@ func TestGcBlobTaskStore_Delete_NotFound(t *testing.T) {
 
 	s := datastore.NewGCBlobTaskStore(tx)
 	err = s.Delete(suite.ctx, &models.GCBlobTask{Digest: randomDigest(t)})
-	require.EqualError(t, err, "GC blob task not found")
+	require.ErrorIs(t, err, datastore.ErrGCBlobTaskNotFound)
 }
 
 func TestGcBlobTaskStore_IsDangling_Yes(t *testing.T) {
@@ -219,7 +219,7 @@ func (s *gcManifestTaskStore) Postpone(ctx context.Context, m *models.GCManifest
 		return fmt.Errorf("postponing GC manifest task: %w", err)
 	}
 	if count == 0 {
-		return fmt.Errorf("GC manifest task not found")
+		return ErrGCManifestTaskNotFound
 	}
 
 	m.ReviewAfter = ra
@@ -228,7 +228,8 @@ func (s *gcManifestTaskStore) Postpone(ctx context.Context, m *models.GCManifest
 	return nil
 }
 
-// IsDangling determines if the manifest referenced by the GC manifest task is eligible for deletion or not.
+// IsDangling determines if the manifest referenced by the GC manifest task is eligible for deletion or not. Manifests
+// of archived repositories are never eligible, as archived repositories are read-only.
 func (s *gcManifestTaskStore) IsDangling(ctx context.Context, m *models.GCManifestTask) (bool, error) {
 	defer metrics.InstrumentQuery("gc_manifest_task_is_dangling")()
 	q := `SELECT
@@ -249,7 +250,16 @@ func (s *gcManifestTaskStore) IsDangling(ctx context.Context, m *models.GCManife
 				 WHERE
 					 top_level_namespace_id = $1
 					 AND repository_id = $2
-					 AND child_id = $3)`
+					 AND child_id = $3
+				 UNION ALL
+				 SELECT
+					 1
+				 FROM
+					 repositories
+				 WHERE
+					 top_level_namespace_id = $1
+					 AND id = $2
+					 AND archived)`
 
 	var referenced bool
 	if err := s.db.QueryRowContext(ctx, q, m.NamespaceID, m.RepositoryID, m.ManifestID).Scan(&referenced); err != nil {
@@ -276,7 +286,7 @@ func (s *gcManifestTaskStore) Delete(ctx context.Context, m *models.GCManifestTa
 		return fmt.Errorf("deleting GC manifest task: %w", err)
 	}
 	if count == 0 {
-		return fmt.Errorf("GC manifest task not found")
+		return ErrGCManifestTaskNotFound
 	}
 
 	return nil
@@ -368,7 +368,7 @@ func TestGcManifestTaskStore_Postpone_NotFound(t *testing.T) {
 
 	s := datastore.NewGCManifestTaskStore(tx)
 	err = s.Postpone(suite.ctx, &models.GCManifestTask{RepositoryID: 1, ManifestID: 3}, 0)
-	require.EqualError(t, err, "GC manifest task not found")
+	require.ErrorIs(t, err, datastore.ErrGCManifestTaskNotFound)
 }
 
 func existsGCManifestTask(t *testing.T, db datastore.Queryer, repositoryID, manifestID int64) bool {
@@ -466,7 +466,7 @@ func TestGcManifestTaskStore_Delete_NotFound(t *testing.T) {
 
 	s := datastore.NewGCManifestTaskStore(tx)
 	err = s.Delete(suite.ctx, &models.GCManifestTask{RepositoryID: 6, ManifestID: 2})
-	require.EqualError(t, err, "GC manifest task not found")
+	require.ErrorIs(t, err, datastore.ErrGCManifestTaskNotFound)
 }
 
 func TestGcManifestTaskStore_IsDangling_Yes(t *testing.T) {
@@ -570,6 +570,35 @@ func (imp *Importer) preImportManifest(ctx context.Context, fsRepo distribution.
 	return nil
 }
 
+// PreImportManifest populates the database with a single manifest of a repository, along with its configuration and
+// layers, without any tag information. The repository is created if it does not exist yet. This is a no-op if the
+// manifest was already imported.
+func (imp *Importer) PreImportManifest(ctx context.Context, path string, dgst digest.Digest) error {
+	named, err := reference.WithName(path)
+	if err != nil {
+		return fmt.Errorf("parsing repository name: %w", err)
+	}
+	fsRepo, err := imp.registry.Repository(ctx, named)
+	if err != nil {
+		return fmt.Errorf("constructing repository: %w", err)
+	}
+
+	dbRepo, err := imp.repositoryStore.CreateOrFindByPath(ctx, path)
+	if err != nil {
+		return fmt.Errorf("importing repository: %w", err)
+	}
+
+	dbManifest, err := imp.repositoryStore.FindManifestByDigest(ctx, dbRepo, dgst)
+	if err != nil {
+		return fmt.Errorf("checking for existence of manifest: %w", err)
+	}
+	if dbManifest != nil {
+		return nil
+	}
+
+	return imp.preImportManifest(ctx, fsRepo, dbRepo, dgst)
+}
+
 func (imp *Importer) countRows(ctx context.Context) (map[string]int, error) {
 	numRepositories, err := imp.repositoryStore.Count(ctx)
 	if err != nil {
@@ -370,3 +370,29 @@ func TestImporter_PreImport_AbortsIfDatabaseIsNotEmpty(t *testing.T) {
 	err := imp.PreImport(suite.ctx, "a-simple")
 	require.EqualError(t, err, "non-empty database")
 }
+
+func TestImporter_PreImportManifest(t *testing.T) {
+	require.NoError(t, testutil.TruncateAllTables(suite.db))
+
+	dgst := digest.Digest("sha256:a2490cec4484ee6c1068ba3a05f89934010c85242f736280b35343483b2264b6")
+
+	imp := newImporter(t, suite.db)
+	require.NoError(t, imp.PreImportManifest(suite.ctx, "a-simple", dgst))
+
+	rs := datastore.NewRepositoryStore(suite.db)
+	r, err := rs.FindByPath(suite.ctx, "a-simple")
+	require.NoError(t, err)
+	require.NotNil(t, r)
+
+	m, err := rs.FindManifestByDigest(suite.ctx, r, dgst)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+
+	// tags are not imported
+	tt, err := rs.Tags(suite.ctx, r)
+	require.NoError(t, err)
+	require.Empty(t, tt)
+
+	// pre-importing the same manifest again is a no-op
+	require.NoError(t, imp.PreImportManifest(suite.ctx, "a-simple", dgst))
+}
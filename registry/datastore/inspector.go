@@ -0,0 +1,286 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/jackc/pgx/v4"
+	"github.com/opencontainers/go-digest"
+)
+
+// Inspector is the interface that defines read-only operations to inspect the contents of the metadata database. These
+// are meant for troubleshooting and may scan entire tables.
+type Inspector interface {
+	TableStats(ctx context.Context, exact bool) (models.TableStatsList, error)
+	CountUnlinkedBlobs(ctx context.Context) (int, error)
+	FindUnlinkedBlobs(ctx context.Context, limit int) (models.Blobs, error)
+	CountManifestsWithoutRepository(ctx context.Context) (int, error)
+	FindManifestsWithoutRepository(ctx context.Context, limit int) (models.Manifests, error)
+	FindRepositoriesLinkingBlob(ctx context.Context, d digest.Digest) (models.Repositories, error)
+	FindManifestsByDigest(ctx context.Context, d digest.Digest) (models.Manifests, error)
+}
+
+// inspector is the concrete implementation of an Inspector.
+type inspector struct {
+	// db can be either a *sql.DB or *sql.Tx
+	db Queryer
+}
+
+// NewInspector builds a new inspector.
+func NewInspector(db Queryer) Inspector {
+	return &inspector{db: db}
+}
+
+// TableStats returns the number of rows and disk usage of all tables in the public schema, sorted by name. Partitioned
+// tables are reported as a whole, with the sum of their partitions. Row counts are estimated from the database
+// statistics unless exact is true, in which case each table is fully counted.
+func (s *inspector) TableStats(ctx context.Context, exact bool) (models.TableStatsList, error) {
+	defer metrics.InstrumentQuery("inspector_table_stats")()
+	q := `SELECT
+			t.relname,
+			(
+				SELECT
+					coalesce(sum(greatest (c.reltuples, 0)), 0)::bigint
+				FROM
+					pg_partition_tree(t.oid) AS p
+					JOIN pg_class AS c ON c.oid = p.relid
+				WHERE
+					p.isleaf) AS rows,
+			(
+				SELECT
+					coalesce(sum(pg_total_relation_size(p.relid)), 0)::bigint
+				FROM
+					pg_partition_tree(t.oid) AS p) AS size
+		FROM
+			pg_class AS t
+			JOIN pg_namespace AS n ON n.oid = t.relnamespace
+		WHERE
+			n.nspname = 'public'
+			AND t.relkind IN ('r', 'p')
+			AND NOT t.relispartition
+		ORDER BY
+			t.relname`
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("finding table stats: %w", err)
+	}
+	defer rows.Close()
+
+	tt := make(models.TableStatsList, 0)
+	for rows.Next() {
+		t := new(models.TableStats)
+		if err := rows.Scan(&t.Name, &t.Rows, &t.Size); err != nil {
+			return nil, fmt.Errorf("scanning table stats: %w", err)
+		}
+		tt = append(tt, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning table stats: %w", err)
+	}
+
+	if exact {
+		for _, t := range tt {
+			q := "SELECT COUNT(*) FROM public." + pgx.Identifier{t.Name}.Sanitize()
+			if err := s.db.QueryRowContext(ctx, q).Scan(&t.Rows); err != nil {
+				return nil, fmt.Errorf("counting rows of table %q: %w", t.Name, err)
+			}
+		}
+	}
+
+	return tt, nil
+}
+
+// unlinkedBlobsCondition matches blobs that are not linked to any repository and are not queued for review by the
+// online garbage collector. These are never going to be deleted.
+const unlinkedBlobsCondition = `NOT EXISTS (
+			SELECT
+				1
+			FROM
+				repository_blobs AS rb
+			WHERE
+				rb.blob_digest = b.digest)
+		AND NOT EXISTS (
+			SELECT
+				1
+			FROM
+				gc_blob_review_queue AS gc
+			WHERE
+				gc.digest = b.digest)`
+
+// CountUnlinkedBlobs counts the blobs that are not linked to any repository and are not queued for review by the
+// online garbage collector.
+func (s *inspector) CountUnlinkedBlobs(ctx context.Context) (int, error) {
+	defer metrics.InstrumentQuery("inspector_count_unlinked_blobs")()
+	q := `SELECT
+			COUNT(*)
+		FROM
+			blobs AS b
+		WHERE
+			` + unlinkedBlobsCondition
+	var count int
+
+	if err := s.db.QueryRowContext(ctx, q).Scan(&count); err != nil {
+		return count, fmt.Errorf("counting unlinked blobs: %w", err)
+	}
+
+	return count, nil
+}
+
+// FindUnlinkedBlobs finds up to limit blobs that are not linked to any repository and are not queued for review by the
+// online garbage collector, sorted by creation time.
+func (s *inspector) FindUnlinkedBlobs(ctx context.Context, limit int) (models.Blobs, error) {
+	defer metrics.InstrumentQuery("inspector_find_unlinked_blobs")()
+	q := `SELECT
+			mt.media_type,
+			encode(b.digest, 'hex') as digest,
+			b.size,
+			b.created_at,
+			b.verified_at
+		FROM
+			blobs AS b
+			JOIN media_types AS mt ON b.media_type_id = mt.id
+		WHERE
+			` + unlinkedBlobsCondition + `
+		ORDER BY
+			b.created_at
+		LIMIT $1`
+	rows, err := s.db.QueryContext(ctx, q, limit)
+	if err != nil {
+		return nil, fmt.Errorf("finding unlinked blobs: %w", err)
+	}
+
+	return scanFullBlobs(rows)
+}
+
+// manifestsWithoutRepositoryCondition matches manifests whose repository does not exist.
+const manifestsWithoutRepositoryCondition = `NOT EXISTS (
+			SELECT
+				1
+			FROM
+				repositories AS r
+			WHERE
+				r.top_level_namespace_id = m.top_level_namespace_id
+				AND r.id = m.repository_id)`
+
+// CountManifestsWithoutRepository counts the manifests whose repository does not exist. These are not expected to
+// exist, as repositories cascade deletes to their manifests.
+func (s *inspector) CountManifestsWithoutRepository(ctx context.Context) (int, error) {
+	defer metrics.InstrumentQuery("inspector_count_manifests_without_repository")()
+	q := `SELECT
+			COUNT(*)
+		FROM
+			manifests AS m
+		WHERE
+			` + manifestsWithoutRepositoryCondition
+	var count int
+
+	if err := s.db.QueryRowContext(ctx, q).Scan(&count); err != nil {
+		return count, fmt.Errorf("counting manifests without repository: %w", err)
+	}
+
+	return count, nil
+}
+
+// FindManifestsWithoutRepository finds up to limit manifests whose repository does not exist, sorted by creation time.
+func (s *inspector) FindManifestsWithoutRepository(ctx context.Context, limit int) (models.Manifests, error) {
+	defer metrics.InstrumentQuery("inspector_find_manifests_without_repository")()
+	q := `SELECT
+			m.id,
+			m.top_level_namespace_id,
+			m.repository_id,
+			m.schema_version,
+			mt.media_type,
+			encode(m.digest, 'hex') as digest,
+			m.payload,
+			mtc.media_type as configuration_media_type,
+			encode(m.configuration_blob_digest, 'hex') as configuration_blob_digest,
+			m.configuration_payload,
+			m.created_at
+		FROM
+			manifests AS m
+			JOIN media_types AS mt ON mt.id = m.media_type_id
+			LEFT JOIN media_types AS mtc ON mtc.id = m.configuration_media_type_id
+		WHERE
+			` + manifestsWithoutRepositoryCondition + `
+		ORDER BY
+			m.created_at
+		LIMIT $1`
+	rows, err := s.db.QueryContext(ctx, q, limit)
+	if err != nil {
+		return nil, fmt.Errorf("finding manifests without repository: %w", err)
+	}
+
+	return scanFullManifests(rows)
+}
+
+// FindRepositoriesLinkingBlob finds all repositories to which the blob with digest d is linked, sorted by path.
+func (s *inspector) FindRepositoriesLinkingBlob(ctx context.Context, d digest.Digest) (models.Repositories, error) {
+	defer metrics.InstrumentQuery("inspector_find_repositories_linking_blob")()
+	q := `SELECT
+			r.id,
+			r.top_level_namespace_id,
+			r.name,
+			r.path,
+			r.parent_id,
+			r.created_at,
+			r.updated_at,
+			r.archived
+		FROM
+			repositories AS r
+			JOIN repository_blobs AS rb ON rb.top_level_namespace_id = r.top_level_namespace_id
+				AND rb.repository_id = r.id
+		WHERE
+			rb.blob_digest = decode($1, 'hex')
+		ORDER BY
+			r.path`
+
+	dgst, err := NewDigest(d)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, q, dgst)
+	if err != nil {
+		return nil, fmt.Errorf("finding repositories linking blob: %w", err)
+	}
+
+	return scanFullRepositories(rows)
+}
+
+// FindManifestsByDigest finds all manifests with digest d across all repositories, sorted by repository ID. Manifests
+// are only indexed by digest within each repository, so this scans all manifests.
+func (s *inspector) FindManifestsByDigest(ctx context.Context, d digest.Digest) (models.Manifests, error) {
+	defer metrics.InstrumentQuery("inspector_find_manifests_by_digest")()
+	q := `SELECT
+			m.id,
+			m.top_level_namespace_id,
+			m.repository_id,
+			m.schema_version,
+			mt.media_type,
+			encode(m.digest, 'hex') as digest,
+			m.payload,
+			mtc.media_type as configuration_media_type,
+			encode(m.configuration_blob_digest, 'hex') as configuration_blob_digest,
+			m.configuration_payload,
+			m.created_at
+		FROM
+			manifests AS m
+			JOIN media_types AS mt ON mt.id = m.media_type_id
+			LEFT JOIN media_types AS mtc ON mtc.id = m.configuration_media_type_id
+		WHERE
+			m.digest = decode($1, 'hex')
+		ORDER BY
+			m.repository_id`
+
+	dgst, err := NewDigest(d)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, q, dgst)
+	if err != nil {
+		return nil, fmt.Errorf("finding manifests by digest: %w", err)
+	}
+
+	return scanFullManifests(rows)
+}
@@ -0,0 +1,160 @@
+// +build integration
+
+package datastore_test
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func reloadInspectorFixtures(tb testing.TB) {
+	testutil.ReloadFixtures(tb, suite.db, suite.basePath,
+		testutil.NamespacesTable, testutil.RepositoriesTable, testutil.BlobsTable, testutil.RepositoryBlobsTable,
+		testutil.ManifestsTable)
+
+	// blobs are automatically queued for review by the online GC once created
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.GCBlobReviewQueueTable))
+}
+
+func TestInspector_Implements(t *testing.T) {
+	require.Implements(t, (*datastore.Inspector)(nil), datastore.NewInspector(suite.db))
+}
+
+func TestInspector_TableStats(t *testing.T) {
+	reloadInspectorFixtures(t)
+
+	s := datastore.NewInspector(suite.db)
+	tt, err := s.TableStats(suite.ctx, true)
+	require.NoError(t, err)
+
+	rows := make(map[string]int64, len(tt))
+	for _, ts := range tt {
+		require.Positive(t, ts.Size, ts.Name)
+		rows[ts.Name] = ts.Rows
+	}
+
+	// see testdata/fixtures/*.sql
+	require.Equal(t, int64(10), rows["blobs"])
+	require.Equal(t, int64(7), rows["repositories"])
+	require.Equal(t, int64(15), rows["repository_blobs"])
+	require.Equal(t, int64(11), rows["manifests"])
+	require.Zero(t, rows["gc_blob_review_queue"])
+	// partitions are reported as part of their parent table
+	require.NotContains(t, rows, "blobs_p_0")
+}
+
+func TestInspector_CountUnlinkedBlobs(t *testing.T) {
+	reloadInspectorFixtures(t)
+
+	s := datastore.NewInspector(suite.db)
+	count, err := s.CountUnlinkedBlobs(suite.ctx)
+	require.NoError(t, err)
+
+	// see testdata/fixtures/blobs.sql and testdata/fixtures/repository_blobs.sql
+	require.Equal(t, 3, count)
+}
+
+func TestInspector_CountUnlinkedBlobs_IgnoresQueuedBlobs(t *testing.T) {
+	reloadInspectorFixtures(t)
+	// queues all blobs for review
+	testutil.ReloadFixtures(t, suite.db, suite.basePath, testutil.BlobsTable)
+
+	s := datastore.NewInspector(suite.db)
+	count, err := s.CountUnlinkedBlobs(suite.ctx)
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+func TestInspector_FindUnlinkedBlobs(t *testing.T) {
+	reloadInspectorFixtures(t)
+
+	s := datastore.NewInspector(suite.db)
+	bb, err := s.FindUnlinkedBlobs(suite.ctx, 100)
+	require.NoError(t, err)
+
+	// see testdata/fixtures/blobs.sql and testdata/fixtures/repository_blobs.sql
+	var dd []digest.Digest
+	for _, b := range bb {
+		dd = append(dd, b.Digest)
+	}
+	require.ElementsMatch(t, []digest.Digest{
+		"sha256:ea8a54fd13889d3649d0a4e45735116474b8a650815a2cda4940f652158579b9",
+		"sha256:9ead3a93fc9c9dd8f35221b1f22b155a513815b7b00425d6645b34d98e83b073",
+		"sha256:33f3ef3322b28ecfc368872e621ab715a04865471c47ca7426f3e93846157780",
+	}, dd)
+}
+
+func TestInspector_FindUnlinkedBlobs_Limit(t *testing.T) {
+	reloadInspectorFixtures(t)
+
+	s := datastore.NewInspector(suite.db)
+	bb, err := s.FindUnlinkedBlobs(suite.ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, bb, 2)
+}
+
+func TestInspector_CountManifestsWithoutRepository(t *testing.T) {
+	reloadInspectorFixtures(t)
+
+	s := datastore.NewInspector(suite.db)
+	count, err := s.CountManifestsWithoutRepository(suite.ctx)
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+func TestInspector_FindManifestsWithoutRepository(t *testing.T) {
+	reloadInspectorFixtures(t)
+
+	s := datastore.NewInspector(suite.db)
+	mm, err := s.FindManifestsWithoutRepository(suite.ctx, 100)
+	require.NoError(t, err)
+	require.Empty(t, mm)
+}
+
+func TestInspector_FindRepositoriesLinkingBlob(t *testing.T) {
+	reloadInspectorFixtures(t)
+
+	s := datastore.NewInspector(suite.db)
+	rr, err := s.FindRepositoriesLinkingBlob(suite.ctx, "sha256:f01256086224ded321e042e74135d72d5f108089a1cda03ab4820dfc442807c1")
+	require.NoError(t, err)
+
+	// see testdata/fixtures/repository_blobs.sql
+	var paths []string
+	for _, r := range rr {
+		paths = append(paths, r.Path)
+	}
+	require.Equal(t, []string{
+		"a-test-group/bar",
+		"gitlab-org/gitlab-test/backend",
+		"gitlab-org/gitlab-test/frontend",
+	}, paths)
+}
+
+func TestInspector_FindRepositoriesLinkingBlob_None(t *testing.T) {
+	reloadInspectorFixtures(t)
+
+	s := datastore.NewInspector(suite.db)
+	rr, err := s.FindRepositoriesLinkingBlob(suite.ctx, "sha256:ea8a54fd13889d3649d0a4e45735116474b8a650815a2cda4940f652158579b9")
+	require.NoError(t, err)
+	require.Empty(t, rr)
+}
+
+func TestInspector_FindManifestsByDigest(t *testing.T) {
+	reloadInspectorFixtures(t)
+
+	s := datastore.NewInspector(suite.db)
+	mm, err := s.FindManifestsByDigest(suite.ctx, "sha256:bd165db4bd480656a539e8e00db265377d162d6b98eebbfe5805d0fbd5144155")
+	require.NoError(t, err)
+
+	// see testdata/fixtures/manifests.sql
+	var ids []int64
+	for _, m := range mm {
+		require.Equal(t, digest.Digest("sha256:bd165db4bd480656a539e8e00db265377d162d6b98eebbfe5805d0fbd5144155"), m.Digest)
+		ids = append(ids, m.RepositoryID)
+	}
+	require.Equal(t, []int64{3, 6, 7}, ids)
+}
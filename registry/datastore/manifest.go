@@ -7,6 +7,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/docker/distribution/registry/datastore/metrics"
 	"github.com/docker/distribution/registry/datastore/models"
@@ -128,6 +129,41 @@ func scanFullManifests(rows *sql.Rows) (models.Manifests, error) {
 	return mm, nil
 }
 
+func scanManifestDetails(rows *sql.Rows) (models.ManifestDetails, error) {
+	mm := make(models.ManifestDetails, 0)
+	defer rows.Close()
+
+	for rows.Next() {
+		var dgst Digest
+		var tags, cfgMediaType sql.NullString
+		var cfgPayload *models.Payload
+		m := new(models.ManifestDetail)
+		if err := rows.Scan(&dgst, &m.MediaType, &cfgMediaType, &cfgPayload, &m.Size, &tags, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning manifest detail: %w", err)
+		}
+		d, err := dgst.Parse()
+		if err != nil {
+			return nil, err
+		}
+		m.Digest = d
+		m.ConfigMediaType = cfgMediaType.String
+		if cfgPayload != nil {
+			m.ConfigPayload = *cfgPayload
+		}
+		// tag names can't contain commas
+		m.Tags = make([]string, 0)
+		if tags.Valid {
+			m.Tags = strings.Split(tags.String, ",")
+		}
+		mm = append(mm, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning manifest details: %w", err)
+	}
+
+	return mm, nil
+}
+
 // FindAll finds all manifests.
 func (s *manifestStore) FindAll(ctx context.Context) (models.Manifests, error) {
 	defer metrics.InstrumentQuery("manifest_find_all")()
@@ -178,7 +214,8 @@ func (s *manifestStore) LayerBlobs(ctx context.Context, m *models.Manifest) (mod
 			mt.media_type,
 			encode(b.digest, 'hex') as digest,
 			b.size,
-			b.created_at
+			b.created_at,
+			b.verified_at
 		FROM
 			blobs AS b
 			JOIN layers AS l ON l.digest = b.digest
@@ -0,0 +1,224 @@
+package datastore
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// DefaultManifestCacheMaxSize is the default maximum size in bytes of a ManifestCache (64 MiB).
+	DefaultManifestCacheMaxSize = 64 << 20
+	// DefaultManifestCacheTTL is the default amount of time for which the association between a cached manifest and a
+	// repository is trusted without checking the database again.
+	DefaultManifestCacheTTL = 5 * time.Minute
+)
+
+// ManifestCache is an in-process, size-bounded LRU cache of manifest payloads and descriptors keyed by digest.
+//
+// Manifests are immutable, so a single cache entry is shared across all repositories that contain the same manifest.
+// However, a manifest must only be served from the cache for a repository if it was previously found in that same
+// repository in the database. For this reason, each entry keeps track of the repositories where the manifest was
+// found and when. These associations expire after the configured TTL, after which the database is checked again.
+//
+// Associations must be dropped with Remove or RemoveRepository whenever a manifest is deleted from a repository or the
+// repository is renamed. As this is only possible for changes made by the current process, each association is also
+// bound to a generation of the repository, provided by the caller (e.g. from a cache shared across processes) and
+// incremented by every change. An association is not served once the generation of its repository changed.
+type ManifestCache struct {
+	mu      sync.Mutex
+	maxSize int64
+	ttl     time.Duration
+	size    int64
+	ll      *list.List
+	items   map[digest.Digest]*list.Element
+	now     func() time.Time // for test purposes only
+}
+
+type manifestCacheEntry struct {
+	manifest     *models.Manifest
+	repositories map[string]manifestCacheAssociation
+}
+
+// manifestCacheAssociation records when and at which generation of a repository a manifest was found in it.
+type manifestCacheAssociation struct {
+	addedAt    time.Time
+	generation int64
+}
+
+func (e *manifestCacheEntry) size() int64 {
+	size := int64(len(e.manifest.Payload))
+	for path := range e.repositories {
+		size += int64(len(path))
+	}
+	return size
+}
+
+// NewManifestCache creates a new ManifestCache bounded to maxSize bytes, where repository associations expire after
+// ttl. If maxSize or ttl are not positive, DefaultManifestCacheMaxSize and DefaultManifestCacheTTL are used,
+// respectively.
+func NewManifestCache(maxSize int64, ttl time.Duration) *ManifestCache {
+	if maxSize <= 0 {
+		maxSize = DefaultManifestCacheMaxSize
+	}
+	if ttl <= 0 {
+		ttl = DefaultManifestCacheTTL
+	}
+
+	return &ManifestCache{
+		maxSize: maxSize,
+		ttl:     ttl,
+		ll:      list.New(),
+		items:   make(map[digest.Digest]*list.Element),
+		now:     time.Now,
+	}
+}
+
+// Get returns the cached manifest with digest dgst, as long as it was previously found in the repository with path
+// repoPath at generation gen and that association has not expired. The returned manifest is a copy and only includes
+// content fields (digest, media type, schema version, payload and configuration), database identifiers are not set.
+func (c *ManifestCache) Get(repoPath string, dgst digest.Digest, gen int64) (*models.Manifest, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[dgst]
+	if !ok {
+		metrics.ManifestCacheMiss()
+		return nil, false
+	}
+
+	entry := el.Value.(*manifestCacheEntry)
+	a, ok := entry.repositories[repoPath]
+	if !ok || a.generation != gen || c.now().Sub(a.addedAt) > c.ttl {
+		metrics.ManifestCacheMiss()
+		return nil, false
+	}
+
+	c.ll.MoveToFront(el)
+	metrics.ManifestCacheHit()
+
+	return copyManifestContent(entry.manifest), true
+}
+
+// Add caches manifest m as found in the repository with path repoPath at generation gen, which must have been read
+// before looking up m. Manifests larger than the cache maximum size are not cached. Least recently used entries are
+// evicted as needed to stay within the maximum size.
+func (c *ManifestCache) Add(repoPath string, m *models.Manifest, gen int64) {
+	if m == nil || int64(len(m.Payload)+len(repoPath)) > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[m.Digest]; ok {
+		entry := el.Value.(*manifestCacheEntry)
+		if _, ok := entry.repositories[repoPath]; !ok {
+			c.size += int64(len(repoPath))
+		}
+		entry.repositories[repoPath] = manifestCacheAssociation{addedAt: c.now(), generation: gen}
+		c.ll.MoveToFront(el)
+	} else {
+		entry := &manifestCacheEntry{
+			manifest:     copyManifestContent(m),
+			repositories: map[string]manifestCacheAssociation{repoPath: {addedAt: c.now(), generation: gen}},
+		}
+		c.items[m.Digest] = c.ll.PushFront(entry)
+		c.size += entry.size()
+	}
+
+	for c.size > c.maxSize {
+		c.removeElement(c.ll.Back())
+		metrics.ManifestCacheEviction()
+	}
+
+	metrics.ManifestCacheSize(c.size, c.ll.Len())
+}
+
+// Remove drops the association between the manifest with digest dgst and the repository with path repoPath. This must
+// be called whenever a manifest is deleted from a repository. The manifest itself remains cached for other
+// repositories.
+func (c *ManifestCache) Remove(repoPath string, dgst digest.Digest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[dgst]
+	if !ok {
+		return
+	}
+
+	c.removeAssociation(el, repoPath)
+
+	metrics.ManifestCacheSize(c.size, c.ll.Len())
+}
+
+// RemoveRepository drops the associations between all manifests and the repository with path repoPath. This must be
+// called whenever a repository is renamed.
+func (c *ManifestCache) RemoveRepository(repoPath string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for el := c.ll.Front(); el != nil; {
+		next := el.Next()
+		c.removeAssociation(el, repoPath)
+		el = next
+	}
+
+	metrics.ManifestCacheSize(c.size, c.ll.Len())
+}
+
+// Len returns the number of manifests in the cache.
+func (c *ManifestCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ll.Len()
+}
+
+// Size returns the current size of the cache in bytes.
+func (c *ManifestCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.size
+}
+
+// removeAssociation drops the association between the manifest of el and the repository with path repoPath, if any.
+// The manifest is removed once no longer associated with any repository.
+func (c *ManifestCache) removeAssociation(el *list.Element, repoPath string) {
+	entry := el.Value.(*manifestCacheEntry)
+	if _, ok := entry.repositories[repoPath]; !ok {
+		return
+	}
+	delete(entry.repositories, repoPath)
+	c.size -= int64(len(repoPath))
+
+	if len(entry.repositories) == 0 {
+		c.removeElement(el)
+	}
+}
+
+func (c *ManifestCache) removeElement(el *list.Element) {
+	entry := c.ll.Remove(el).(*manifestCacheEntry)
+	delete(c.items, entry.manifest.Digest)
+	c.size -= entry.size()
+}
+
+func copyManifestContent(m *models.Manifest) *models.Manifest {
+	cp := &models.Manifest{
+		SchemaVersion: m.SchemaVersion,
+		MediaType:     m.MediaType,
+		Digest:        m.Digest,
+		Payload:       m.Payload,
+	}
+	if m.Configuration != nil {
+		cfg := *m.Configuration
+		cp.Configuration = &cfg
+	}
+
+	return cp
+}
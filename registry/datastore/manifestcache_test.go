@@ -0,0 +1,175 @@
+package datastore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func newCacheTestManifest(payload string) *models.Manifest {
+	return &models.Manifest{
+		ID:            1,
+		NamespaceID:   2,
+		RepositoryID:  3,
+		SchemaVersion: 2,
+		MediaType:     "application/vnd.docker.distribution.manifest.v2+json",
+		Digest:        digest.FromString(payload),
+		Payload:       models.Payload(payload),
+	}
+}
+
+func TestManifestCache_GetAdd(t *testing.T) {
+	c := datastore.NewManifestCache(0, 0)
+	m := newCacheTestManifest(`{"foo":"bar"}`)
+
+	_, ok := c.Get("a/b", m.Digest, 0)
+	require.False(t, ok)
+
+	c.Add("a/b", m, 0)
+	require.Equal(t, 1, c.Len())
+	require.Equal(t, int64(len(m.Payload)+len("a/b")), c.Size())
+
+	got, ok := c.Get("a/b", m.Digest, 0)
+	require.True(t, ok)
+	require.Equal(t, m.Digest, got.Digest)
+	require.Equal(t, m.MediaType, got.MediaType)
+	require.Equal(t, m.SchemaVersion, got.SchemaVersion)
+	require.Equal(t, m.Payload, got.Payload)
+	// database identifiers are repository specific and must not be cached
+	require.Zero(t, got.ID)
+	require.Zero(t, got.NamespaceID)
+	require.Zero(t, got.RepositoryID)
+}
+
+func TestManifestCache_Get_OtherRepository(t *testing.T) {
+	c := datastore.NewManifestCache(0, 0)
+	m := newCacheTestManifest(`{"foo":"bar"}`)
+
+	c.Add("a/b", m, 0)
+
+	// the manifest was never found in c/d, so it must not be served from the cache for it
+	_, ok := c.Get("c/d", m.Digest, 0)
+	require.False(t, ok)
+
+	// the same entry is shared once the manifest is found in c/d
+	c.Add("c/d", m, 0)
+	require.Equal(t, 1, c.Len())
+	_, ok = c.Get("c/d", m.Digest, 0)
+	require.True(t, ok)
+}
+
+func TestManifestCache_Get_Expired(t *testing.T) {
+	c := datastore.NewManifestCache(0, time.Millisecond)
+	m := newCacheTestManifest(`{"foo":"bar"}`)
+
+	c.Add("a/b", m, 0)
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := c.Get("a/b", m.Digest, 0)
+	require.False(t, ok)
+
+	// refreshing the association makes the entry available again
+	c.Add("a/b", m, 0)
+	_, ok = c.Get("a/b", m.Digest, 0)
+	require.True(t, ok)
+}
+
+func TestManifestCache_Add_EvictsLeastRecentlyUsed(t *testing.T) {
+	m1 := newCacheTestManifest(`{"a":"1"}`)
+	m2 := newCacheTestManifest(`{"b":"2"}`)
+	m3 := newCacheTestManifest(`{"c":"3"}`)
+
+	// room for exactly two entries
+	c := datastore.NewManifestCache(int64(2*(len(m1.Payload)+len("a/b"))), 0)
+
+	c.Add("a/b", m1, 0)
+	c.Add("a/b", m2, 0)
+
+	// use m1 so that m2 becomes the least recently used
+	_, ok := c.Get("a/b", m1.Digest, 0)
+	require.True(t, ok)
+
+	c.Add("a/b", m3, 0)
+	require.Equal(t, 2, c.Len())
+
+	_, ok = c.Get("a/b", m1.Digest, 0)
+	require.True(t, ok)
+	_, ok = c.Get("a/b", m2.Digest, 0)
+	require.False(t, ok)
+	_, ok = c.Get("a/b", m3.Digest, 0)
+	require.True(t, ok)
+}
+
+func TestManifestCache_Add_TooLarge(t *testing.T) {
+	m := newCacheTestManifest(`{"foo":"bar"}`)
+	c := datastore.NewManifestCache(int64(len(m.Payload)), 0)
+
+	c.Add("a/b", m, 0)
+	require.Zero(t, c.Len())
+	require.Zero(t, c.Size())
+}
+
+func TestManifestCache_Remove(t *testing.T) {
+	c := datastore.NewManifestCache(0, 0)
+	m := newCacheTestManifest(`{"foo":"bar"}`)
+
+	c.Add("a/b", m, 0)
+	c.Add("c/d", m, 0)
+
+	c.Remove("a/b", m.Digest)
+	_, ok := c.Get("a/b", m.Digest, 0)
+	require.False(t, ok)
+	_, ok = c.Get("c/d", m.Digest, 0)
+	require.True(t, ok)
+	require.Equal(t, int64(len(m.Payload)+len("c/d")), c.Size())
+
+	// the entry is dropped once no repository references it
+	c.Remove("c/d", m.Digest)
+	require.Zero(t, c.Len())
+	require.Zero(t, c.Size())
+
+	// removing unknown entries is a no-op
+	c.Remove("c/d", m.Digest)
+}
+
+func TestManifestCache_RemoveRepository(t *testing.T) {
+	c := datastore.NewManifestCache(0, 0)
+	m1 := newCacheTestManifest(`{"foo":"bar"}`)
+	m2 := newCacheTestManifest(`{"bar":"baz"}`)
+
+	c.Add("a/b", m1, 0)
+	c.Add("a/b", m2, 0)
+	c.Add("c/d", m1, 0)
+
+	c.RemoveRepository("a/b")
+	_, ok := c.Get("a/b", m1.Digest, 0)
+	require.False(t, ok)
+	_, ok = c.Get("a/b", m2.Digest, 0)
+	require.False(t, ok)
+	_, ok = c.Get("c/d", m1.Digest, 0)
+	require.True(t, ok)
+	require.Equal(t, 1, c.Len())
+	require.Equal(t, int64(len(m1.Payload)+len("c/d")), c.Size())
+}
+
+func TestManifestCache_Get_GenerationChanged(t *testing.T) {
+	c := datastore.NewManifestCache(0, 0)
+	m := newCacheTestManifest(`{"foo":"bar"}`)
+
+	c.Add("a/b", m, 1)
+	_, ok := c.Get("a/b", m.Digest, 1)
+	require.True(t, ok)
+
+	// the repository was changed by another process since the manifest was cached
+	_, ok = c.Get("a/b", m.Digest, 2)
+	require.False(t, ok)
+
+	c.Add("a/b", m, 2)
+	_, ok = c.Get("a/b", m.Digest, 2)
+	require.True(t, ok)
+	require.Equal(t, int64(len(m.Payload)+len("a/b")), c.Size())
+}
@@ -11,6 +11,15 @@ var (
 	queryDurationHist *prometheus.HistogramVec
 	queryTotal        *prometheus.CounterVec
 	timeSince         = time.Since // for test purposes only
+
+	manifestCacheRequests  *prometheus.CounterVec
+	manifestCacheEvictions prometheus.Counter
+	manifestCacheBytes     prometheus.Gauge
+	manifestCacheEntries   prometheus.Gauge
+
+	repositoryCacheRequests  *prometheus.CounterVec
+	repositoryCacheEvictions prometheus.Counter
+	repositoryCacheEntries   prometheus.Gauge
 )
 
 const (
@@ -22,6 +31,26 @@ const (
 
 	queryTotalName = "queries_total"
 	queryTotalDesc = "A counter for database queries."
+
+	cacheResultLabel = "result"
+	cacheResultHit   = "hit"
+	cacheResultMiss  = "miss"
+
+	manifestCacheRequestsName  = "manifest_cache_requests_total"
+	manifestCacheRequestsDesc  = "A counter for manifest cache lookups, partitioned by result (hit or miss)."
+	manifestCacheEvictionsName = "manifest_cache_evictions_total"
+	manifestCacheEvictionsDesc = "A counter for manifests evicted from the manifest cache."
+	manifestCacheBytesName     = "manifest_cache_size_bytes"
+	manifestCacheBytesDesc     = "The current size in bytes of the manifest cache."
+	manifestCacheEntriesName   = "manifest_cache_entries"
+	manifestCacheEntriesDesc   = "The current number of manifests in the manifest cache."
+
+	repositoryCacheRequestsName  = "repository_cache_requests_total"
+	repositoryCacheRequestsDesc  = "A counter for repository cache lookups, partitioned by result (hit or miss)."
+	repositoryCacheEvictionsName = "repository_cache_evictions_total"
+	repositoryCacheEvictionsDesc = "A counter for repositories evicted from the repository cache."
+	repositoryCacheEntriesName   = "repository_cache_entries"
+	repositoryCacheEntriesDesc   = "The current number of repositories in the repository cache."
 )
 
 func init() {
@@ -46,8 +75,80 @@ func init() {
 		[]string{queryNameLabel},
 	)
 
+	manifestCacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      manifestCacheRequestsName,
+			Help:      manifestCacheRequestsDesc,
+		},
+		[]string{cacheResultLabel},
+	)
+
+	manifestCacheEvictions = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      manifestCacheEvictionsName,
+			Help:      manifestCacheEvictionsDesc,
+		},
+	)
+
+	manifestCacheBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      manifestCacheBytesName,
+			Help:      manifestCacheBytesDesc,
+		},
+	)
+
+	manifestCacheEntries = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      manifestCacheEntriesName,
+			Help:      manifestCacheEntriesDesc,
+		},
+	)
+
+	repositoryCacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      repositoryCacheRequestsName,
+			Help:      repositoryCacheRequestsDesc,
+		},
+		[]string{cacheResultLabel},
+	)
+
+	repositoryCacheEvictions = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      repositoryCacheEvictionsName,
+			Help:      repositoryCacheEvictionsDesc,
+		},
+	)
+
+	repositoryCacheEntries = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      repositoryCacheEntriesName,
+			Help:      repositoryCacheEntriesDesc,
+		},
+	)
+
 	prometheus.MustRegister(queryDurationHist)
 	prometheus.MustRegister(queryTotal)
+	prometheus.MustRegister(manifestCacheRequests)
+	prometheus.MustRegister(manifestCacheEvictions)
+	prometheus.MustRegister(manifestCacheBytes)
+	prometheus.MustRegister(manifestCacheEntries)
+	prometheus.MustRegister(repositoryCacheRequests)
+	prometheus.MustRegister(repositoryCacheEvictions)
+	prometheus.MustRegister(repositoryCacheEntries)
 }
 
 func InstrumentQuery(name string) func() {
@@ -57,3 +158,44 @@ func InstrumentQuery(name string) func() {
 		queryDurationHist.WithLabelValues(name).Observe(timeSince(start).Seconds())
 	}
 }
+
+// ManifestCacheHit increments the manifest cache lookup counter for hits.
+func ManifestCacheHit() {
+	manifestCacheRequests.WithLabelValues(cacheResultHit).Inc()
+}
+
+// ManifestCacheMiss increments the manifest cache lookup counter for misses.
+func ManifestCacheMiss() {
+	manifestCacheRequests.WithLabelValues(cacheResultMiss).Inc()
+}
+
+// ManifestCacheEviction increments the manifest cache eviction counter.
+func ManifestCacheEviction() {
+	manifestCacheEvictions.Inc()
+}
+
+// ManifestCacheSize reports the current size in bytes and number of entries of the manifest cache.
+func ManifestCacheSize(bytes int64, entries int) {
+	manifestCacheBytes.Set(float64(bytes))
+	manifestCacheEntries.Set(float64(entries))
+}
+
+// RepositoryCacheHit increments the repository cache lookup counter for hits.
+func RepositoryCacheHit() {
+	repositoryCacheRequests.WithLabelValues(cacheResultHit).Inc()
+}
+
+// RepositoryCacheMiss increments the repository cache lookup counter for misses.
+func RepositoryCacheMiss() {
+	repositoryCacheRequests.WithLabelValues(cacheResultMiss).Inc()
+}
+
+// RepositoryCacheEviction increments the repository cache eviction counter.
+func RepositoryCacheEviction() {
+	repositoryCacheEvictions.Inc()
+}
+
+// RepositoryCacheSize reports the current number of entries of the repository cache.
+func RepositoryCacheSize(entries int) {
+	repositoryCacheEntries.Set(float64(entries))
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20210615091042_create_repository_events_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS repository_events (
+					id bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+					top_level_namespace_id bigint NOT NULL,
+					repository_id bigint NOT NULL,
+					created_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					action text NOT NULL,
+					actor text,
+					digest text,
+					tag text,
+					previous_digest text,
+					upload_uuid text,
+					request_id text,
+					CONSTRAINT pk_repository_events PRIMARY KEY (top_level_namespace_id, repository_id, id),
+					CONSTRAINT fk_repository_events_top_lvl_nmspc_id_and_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES repositories (top_level_namespace_id, id) ON DELETE CASCADE,
+					CONSTRAINT check_repository_events_action_length CHECK ((char_length(action) <= 255)),
+					CONSTRAINT check_repository_events_tag_length CHECK ((char_length(tag) <= 255))
+				)`,
+				"CREATE INDEX IF NOT EXISTS index_repository_events_on_top_lvl_nmspc_id_rpstry_id_created_at ON repository_events USING btree (top_level_namespace_id, repository_id, created_at)",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS index_repository_events_on_top_lvl_nmspc_id_rpstry_id_created_at CASCADE",
+				"DROP TABLE IF EXISTS repository_events CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20210622104512_create_replication_queue_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS replication_queue (
+					id bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+					top_level_namespace_id bigint NOT NULL,
+					repository_id bigint NOT NULL,
+					created_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					review_after timestamp WITH time zone NOT NULL DEFAULT now(),
+					review_count integer NOT NULL DEFAULT 0,
+					artifact text NOT NULL,
+					digest bytea NOT NULL,
+					tag text,
+					CONSTRAINT pk_replication_queue PRIMARY KEY (top_level_namespace_id, repository_id, id),
+					CONSTRAINT fk_replication_queue_top_lvl_nmspc_id_and_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES repositories (top_level_namespace_id, id) ON DELETE CASCADE,
+					CONSTRAINT check_replication_queue_artifact_length CHECK ((char_length(artifact) <= 255)),
+					CONSTRAINT check_replication_queue_tag_length CHECK ((char_length(tag) <= 255))
+				)`,
+				"CREATE INDEX IF NOT EXISTS index_replication_queue_on_review_after ON replication_queue USING btree (review_after)",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS index_replication_queue_on_review_after CASCADE",
+				"DROP TABLE IF EXISTS replication_queue CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
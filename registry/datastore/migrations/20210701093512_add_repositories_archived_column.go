@@ -0,0 +1,20 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20210701093512_add_repositories_archived_column",
+			Up: []string{
+				"ALTER TABLE repositories ADD COLUMN IF NOT EXISTS archived boolean NOT NULL DEFAULT FALSE",
+			},
+			Down: []string{
+				"ALTER TABLE repositories DROP COLUMN IF EXISTS archived",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
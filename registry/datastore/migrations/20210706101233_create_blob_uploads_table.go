@@ -0,0 +1,28 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20210706101233_create_blob_uploads_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS blob_uploads (
+					id uuid NOT NULL,
+					started_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					repository_path text NOT NULL,
+					CONSTRAINT pk_blob_uploads PRIMARY KEY (id),
+					CONSTRAINT check_blob_uploads_repository_path_length CHECK ((char_length(repository_path) <= 255))
+				)`,
+				"CREATE INDEX IF NOT EXISTS index_blob_uploads_on_started_at ON blob_uploads USING btree (started_at)",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS index_blob_uploads_on_started_at CASCADE",
+				"DROP TABLE IF EXISTS blob_uploads CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20210713134510_create_repository_storage_moves_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS repository_storage_moves (
+					top_level_namespace_id bigint NOT NULL,
+					repository_id bigint NOT NULL,
+					created_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					updated_at timestamp WITH time zone,
+					review_after timestamp WITH time zone NOT NULL DEFAULT now(),
+					review_count integer NOT NULL DEFAULT 0,
+					state text NOT NULL DEFAULT 'scheduled',
+					error text,
+					CONSTRAINT pk_repository_storage_moves PRIMARY KEY (top_level_namespace_id, repository_id),
+					CONSTRAINT fk_repository_storage_moves_tp_lvl_nmspc_id_rpstry_id_rpstrs FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES repositories (top_level_namespace_id, id) ON DELETE CASCADE,
+					CONSTRAINT check_repository_storage_moves_state CHECK (state IN ('scheduled', 'copying', 'cleanup', 'finished')),
+					CONSTRAINT check_repository_storage_moves_error_length CHECK ((char_length(error) <= 1024))
+				)`,
+				"CREATE INDEX IF NOT EXISTS index_repository_storage_moves_on_review_after ON repository_storage_moves USING btree (review_after) WHERE state <> 'finished'",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS index_repository_storage_moves_on_review_after CASCADE",
+				"DROP TABLE IF EXISTS repository_storage_moves CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20210719092105_add_blob_uploads_repository_path_index",
+			Up: []string{
+				"CREATE INDEX IF NOT EXISTS index_blob_uploads_on_repository_path ON blob_uploads USING btree (repository_path)",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS index_blob_uploads_on_repository_path CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20210722103015_create_touch_repository_updated_at_function",
+			Up: []string{
+				`CREATE OR REPLACE FUNCTION touch_repository_updated_at ()
+					RETURNS TRIGGER
+					AS $$
+				BEGIN
+					UPDATE
+						repositories
+					SET
+						updated_at = now()
+					WHERE
+						top_level_namespace_id = NEW.top_level_namespace_id
+						AND id = NEW.repository_id;
+					RETURN NULL;
+				END;
+				$$
+				LANGUAGE plpgsql`,
+			},
+			Down: []string{
+				"DROP FUNCTION IF EXISTS touch_repository_updated_at CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20210722103121_create_touch_repository_updated_at_triggers",
+			Up: []string{
+				`DO $$
+				BEGIN
+					IF NOT EXISTS (
+						SELECT
+							1
+						FROM
+							pg_trigger
+						WHERE
+							tgname = 'touch_repository_updated_at_manifests_trigger') THEN
+						CREATE TRIGGER touch_repository_updated_at_manifests_trigger
+							AFTER INSERT ON manifests
+							FOR EACH ROW
+							EXECUTE PROCEDURE touch_repository_updated_at ();
+					END IF;
+				END
+				$$`,
+				`DO $$
+				BEGIN
+					IF NOT EXISTS (
+						SELECT
+							1
+						FROM
+							pg_trigger
+						WHERE
+							tgname = 'touch_repository_updated_at_tags_trigger') THEN
+						CREATE TRIGGER touch_repository_updated_at_tags_trigger
+							AFTER INSERT OR UPDATE ON tags
+							FOR EACH ROW
+							EXECUTE PROCEDURE touch_repository_updated_at ();
+					END IF;
+				END
+				$$`,
+			},
+			Down: []string{
+				"DROP TRIGGER IF EXISTS touch_repository_updated_at_tags_trigger ON tags CASCADE",
+				"DROP TRIGGER IF EXISTS touch_repository_updated_at_manifests_trigger ON manifests CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
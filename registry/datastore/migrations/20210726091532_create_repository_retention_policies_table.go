@@ -0,0 +1,42 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20210726091532_create_repository_retention_policies_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS repository_retention_policies (
+					top_level_namespace_id bigint NOT NULL,
+					repository_id bigint NOT NULL,
+					created_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					updated_at timestamp WITH time zone,
+					review_after timestamp WITH time zone NOT NULL DEFAULT now(),
+					review_count integer NOT NULL DEFAULT 0,
+					keep_n integer NOT NULL DEFAULT 0,
+					keep_regex text,
+					older_than_seconds bigint NOT NULL DEFAULT 0,
+					dry_run boolean NOT NULL DEFAULT FALSE,
+					last_run_at timestamp WITH time zone,
+					last_run_deleted integer NOT NULL DEFAULT 0,
+					error text,
+					CONSTRAINT pk_repository_retention_policies PRIMARY KEY (top_level_namespace_id, repository_id),
+					CONSTRAINT fk_rpstry_rtntn_plcs_tp_lvl_nmspc_id_rpstry_id_rpstrs FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES repositories (top_level_namespace_id, id) ON DELETE CASCADE,
+					CONSTRAINT check_repository_retention_policies_keep_n CHECK (keep_n >= 0),
+					CONSTRAINT check_repository_retention_policies_older_than_seconds CHECK (older_than_seconds >= 0),
+					CONSTRAINT check_repository_retention_policies_keep_regex_length CHECK ((char_length(keep_regex) <= 255)),
+					CONSTRAINT check_repository_retention_policies_error_length CHECK ((char_length(error) <= 1024))
+				)`,
+				"CREATE INDEX IF NOT EXISTS index_repository_retention_policies_on_review_after ON repository_retention_policies USING btree (review_after)",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS index_repository_retention_policies_on_review_after CASCADE",
+				"DROP TABLE IF EXISTS repository_retention_policies CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
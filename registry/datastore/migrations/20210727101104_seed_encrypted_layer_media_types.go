@@ -0,0 +1,77 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20210727101104_seed_encrypted_layer_media_types",
+			// Layer media types of images encrypted with ocicrypt. See 20210503150438_seed_media_types_table for the
+			// reason why we do not use `ON CONFLICT DO NOTHING`.
+			Up: []string{
+				`INSERT INTO media_types (media_type)
+					SELECT
+						'application/vnd.oci.image.layer.v1.tar+encrypted'
+					WHERE
+						NOT EXISTS (
+							SELECT
+								1
+							FROM
+								media_types
+							WHERE (media_type = 'application/vnd.oci.image.layer.v1.tar+encrypted'))`,
+				`INSERT INTO media_types (media_type)
+					SELECT
+						'application/vnd.oci.image.layer.v1.tar+gzip+encrypted'
+					WHERE
+						NOT EXISTS (
+							SELECT
+								1
+							FROM
+								media_types
+							WHERE (media_type = 'application/vnd.oci.image.layer.v1.tar+gzip+encrypted'))`,
+				`INSERT INTO media_types (media_type)
+					SELECT
+						'application/vnd.oci.image.layer.v1.tar+zstd+encrypted'
+					WHERE
+						NOT EXISTS (
+							SELECT
+								1
+							FROM
+								media_types
+							WHERE (media_type = 'application/vnd.oci.image.layer.v1.tar+zstd+encrypted'))`,
+				`INSERT INTO media_types (media_type)
+					SELECT
+						'application/vnd.oci.image.layer.nondistributable.v1.tar+encrypted'
+					WHERE
+						NOT EXISTS (
+							SELECT
+								1
+							FROM
+								media_types
+							WHERE (media_type = 'application/vnd.oci.image.layer.nondistributable.v1.tar+encrypted'))`,
+				`INSERT INTO media_types (media_type)
+					SELECT
+						'application/vnd.oci.image.layer.nondistributable.v1.tar+gzip+encrypted'
+					WHERE
+						NOT EXISTS (
+							SELECT
+								1
+							FROM
+								media_types
+							WHERE (media_type = 'application/vnd.oci.image.layer.nondistributable.v1.tar+gzip+encrypted'))`,
+			},
+			Down: []string{
+				`DELETE FROM media_types
+					WHERE media_type IN (
+						'application/vnd.oci.image.layer.v1.tar+encrypted',
+						'application/vnd.oci.image.layer.v1.tar+gzip+encrypted',
+						'application/vnd.oci.image.layer.v1.tar+zstd+encrypted',
+						'application/vnd.oci.image.layer.nondistributable.v1.tar+encrypted',
+						'application/vnd.oci.image.layer.nondistributable.v1.tar+gzip+encrypted'
+					)`,
+			},
+		},
+	}
+
+	allMigrations = append(allMigrations, m)
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20210728083512_add_repositories_path_pattern_index",
+			Up: []string{
+				"CREATE INDEX IF NOT EXISTS index_repositories_on_path_text_pattern_ops ON repositories USING btree (path text_pattern_ops)",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS index_repositories_on_path_text_pattern_ops CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
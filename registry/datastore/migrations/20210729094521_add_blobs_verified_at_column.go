@@ -0,0 +1,20 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20210729094521_add_blobs_verified_at_column",
+			Up: []string{
+				"ALTER TABLE blobs ADD COLUMN IF NOT EXISTS verified_at timestamp WITH time zone",
+			},
+			Down: []string{
+				"ALTER TABLE blobs DROP COLUMN IF EXISTS verified_at",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
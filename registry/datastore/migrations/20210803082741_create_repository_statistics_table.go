@@ -0,0 +1,30 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20210803082741_create_repository_statistics_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS repository_statistics (
+					top_level_namespace_id bigint NOT NULL,
+					repository_id bigint NOT NULL,
+					tags_count bigint NOT NULL DEFAULT 0,
+					manifests_count bigint NOT NULL DEFAULT 0,
+					size bigint NOT NULL DEFAULT 0,
+					last_published_at timestamp WITH time zone,
+					calculated_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					CONSTRAINT pk_repository_statistics PRIMARY KEY (top_level_namespace_id, repository_id),
+					CONSTRAINT fk_repository_statistics_tp_lvl_nmspc_id_rpstry_id_rpstrs FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES repositories (top_level_namespace_id, id) ON DELETE CASCADE
+				)`,
+			},
+			Down: []string{
+				"DROP TABLE IF EXISTS repository_statistics CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
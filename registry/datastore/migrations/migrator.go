@@ -2,6 +2,8 @@ package migrations
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"time"
 
 	migrate "github.com/rubenv/sql-migrate"
@@ -16,11 +18,16 @@ func init() {
 	migrate.SetTable(migrationTableName)
 }
 
+// ErrPendingPreDeployment is returned when attempting to apply post deployment migrations only while there are pending
+// pre deployment migrations, which post deployment migrations may depend on.
+var ErrPendingPreDeployment = errors.New("there are pending pre deployment migrations")
+
 type migrator struct {
 	db         *sql.DB
 	migrations []*Migration
 
 	skipPostDeployment bool
+	postDeploymentOnly bool
 }
 
 func NewMigrator(db *sql.DB, opts ...MigratorOption) *migrator {
@@ -53,6 +60,13 @@ func SkipPostDeployment(m *migrator) {
 	m.skipPostDeployment = true
 }
 
+// PostDeploymentOnly configures the migrator to only apply post deployment migrations. Up migrations fail with
+// ErrPendingPreDeployment if any pre deployment migration is pending, so that post deployment migrations are only
+// applied once the new version of the registry has been deployed with its pre deployment migrations.
+func PostDeploymentOnly(m *migrator) {
+	m.postDeploymentOnly = true
+}
+
 // Version returns the current applied migration version (if any).
 func (m *migrator) Version() (string, error) {
 	records, err := migrate.GetMigrationRecords(m.db, dialect)
@@ -80,6 +94,9 @@ func (m *migrator) LatestVersion() (string, error) {
 }
 
 func (m *migrator) migrate(direction migrate.MigrationDirection, limit int) (int, error) {
+	if err := m.checkPostDeploymentOnly(direction); err != nil {
+		return 0, err
+	}
 	src, err := m.eligibleMigrationSource()
 	if err != nil {
 		return 0, err
@@ -183,6 +200,9 @@ func (m *migrator) HasPending() (bool, error) {
 }
 
 func (m *migrator) plan(direction migrate.MigrationDirection, limit int) ([]string, error) {
+	if err := m.checkPostDeploymentOnly(direction); err != nil {
+		return nil, err
+	}
 	src, err := m.eligibleMigrationSource()
 	if err != nil {
 		return nil, err
@@ -247,6 +267,26 @@ func (m *migrator) eligibleMigrationSource() (*migrate.MemoryMigrationSource, er
 	return src, nil
 }
 
+// checkPostDeploymentOnly ensures that there are no pending pre deployment migrations when the migrator is configured to
+// only apply post deployment migrations. If so, all pending migrations are post deployment ones.
+func (m *migrator) checkPostDeploymentOnly(direction migrate.MigrationDirection) error {
+	if !m.postDeploymentOnly || direction != migrate.Up {
+		return nil
+	}
+
+	records, err := migrate.GetMigrationRecords(m.db, dialect)
+	if err != nil {
+		return err
+	}
+	for _, migration := range m.migrations {
+		if !migration.PostDeployment && !migrationApplied(records, migration.Id) {
+			return fmt.Errorf("%w: %s", ErrPendingPreDeployment, migration.Id)
+		}
+	}
+
+	return nil
+}
+
 func migrationApplied(records []*migrate.MigrationRecord, id string) bool {
 	for _, r := range records {
 		if r.Id == id {
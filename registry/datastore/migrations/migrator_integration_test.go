@@ -170,6 +170,68 @@ func TestMigrator_Up_SkipPostDeployment(t *testing.T) {
 	require.Equal(t, v, currentVersion)
 }
 
+func TestMigrator_Up_PostDeploymentOnly(t *testing.T) {
+	db, err := testutil.NewDBFromEnv()
+	require.NoError(t, err)
+	defer cleanupDB(t, db)
+
+	m := migrations.NewMigrator(
+		db.DB,
+		migrations.Source(testmigrations.All()),
+		migrations.SkipPostDeployment,
+	)
+
+	migs := testmigrations.NonPostDeployment()
+
+	count, err := m.Up()
+	require.NoError(t, err)
+	require.Equal(t, len(migs), count)
+
+	// Apply post deployment migrations only, after all others.
+	m = migrations.NewMigrator(
+		db.DB,
+		migrations.Source(testmigrations.All()),
+		migrations.PostDeploymentOnly,
+	)
+
+	all := testmigrations.All()
+
+	plan, err := m.UpNPlan(0)
+	require.NoError(t, err)
+	require.Len(t, plan, len(all)-len(migs))
+
+	count, err = m.Up()
+	require.NoError(t, err)
+	require.Equal(t, len(all)-len(migs), count)
+
+	pending, err := m.HasPending()
+	require.NoError(t, err)
+	require.False(t, pending)
+}
+
+func TestMigrator_Up_PostDeploymentOnly_PendingPreDeployment(t *testing.T) {
+	db, err := testutil.NewDBFromEnv()
+	require.NoError(t, err)
+	defer cleanupDB(t, db)
+
+	m := migrations.NewMigrator(
+		db.DB,
+		migrations.Source(testmigrations.All()),
+		migrations.PostDeploymentOnly,
+	)
+
+	_, err = m.UpNPlan(0)
+	require.ErrorIs(t, err, migrations.ErrPendingPreDeployment)
+
+	count, err := m.Up()
+	require.ErrorIs(t, err, migrations.ErrPendingPreDeployment)
+	require.Zero(t, count)
+
+	v, err := m.Version()
+	require.NoError(t, err)
+	require.Empty(t, v)
+}
+
 func TestMigrator_UpN(t *testing.T) {
 	db, err := testutil.NewDBFromEnv()
 	require.NoError(t, err)
@@ -176,11 +176,28 @@ BEGIN
 END;
 $$;
 
+CREATE FUNCTION public.touch_repository_updated_at ()
+    RETURNS TRIGGER
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    UPDATE
+        repositories
+    SET
+        updated_at = now()
+    WHERE
+        top_level_namespace_id = NEW.top_level_namespace_id
+        AND id = NEW.repository_id;
+    RETURN NULL;
+END;
+$$;
+
 CREATE TABLE public.blobs (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 )
 PARTITION BY HASH (digest);
 
@@ -188,7 +205,8 @@ CREATE TABLE partitions.blobs_p_0 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_0
@@ -198,7 +216,8 @@ CREATE TABLE partitions.blobs_p_1 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_1
@@ -208,7 +227,8 @@ CREATE TABLE partitions.blobs_p_10 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_10
@@ -218,7 +238,8 @@ CREATE TABLE partitions.blobs_p_11 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_11
@@ -228,7 +249,8 @@ CREATE TABLE partitions.blobs_p_12 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_12
@@ -238,7 +260,8 @@ CREATE TABLE partitions.blobs_p_13 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_13
@@ -248,7 +271,8 @@ CREATE TABLE partitions.blobs_p_14 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_14
@@ -258,7 +282,8 @@ CREATE TABLE partitions.blobs_p_15 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_15
@@ -268,7 +293,8 @@ CREATE TABLE partitions.blobs_p_16 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_16
@@ -278,7 +304,8 @@ CREATE TABLE partitions.blobs_p_17 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_17
@@ -288,7 +315,8 @@ CREATE TABLE partitions.blobs_p_18 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_18
@@ -298,7 +326,8 @@ CREATE TABLE partitions.blobs_p_19 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_19
@@ -308,7 +337,8 @@ CREATE TABLE partitions.blobs_p_2 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_2
@@ -318,7 +348,8 @@ CREATE TABLE partitions.blobs_p_20 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_20
@@ -328,7 +359,8 @@ CREATE TABLE partitions.blobs_p_21 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_21
@@ -338,7 +370,8 @@ CREATE TABLE partitions.blobs_p_22 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_22
@@ -348,7 +381,8 @@ CREATE TABLE partitions.blobs_p_23 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_23
@@ -358,7 +392,8 @@ CREATE TABLE partitions.blobs_p_24 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_24
@@ -368,7 +403,8 @@ CREATE TABLE partitions.blobs_p_25 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_25
@@ -378,7 +414,8 @@ CREATE TABLE partitions.blobs_p_26 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_26
@@ -388,7 +425,8 @@ CREATE TABLE partitions.blobs_p_27 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_27
@@ -398,7 +436,8 @@ CREATE TABLE partitions.blobs_p_28 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_28
@@ -408,7 +447,8 @@ CREATE TABLE partitions.blobs_p_29 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_29
@@ -418,7 +458,8 @@ CREATE TABLE partitions.blobs_p_3 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_3
@@ -428,7 +469,8 @@ CREATE TABLE partitions.blobs_p_30 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_30
@@ -438,7 +480,8 @@ CREATE TABLE partitions.blobs_p_31 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_31
@@ -448,7 +491,8 @@ CREATE TABLE partitions.blobs_p_32 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_32
@@ -458,7 +502,8 @@ CREATE TABLE partitions.blobs_p_33 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_33
@@ -468,7 +513,8 @@ CREATE TABLE partitions.blobs_p_34 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_34
@@ -478,7 +524,8 @@ CREATE TABLE partitions.blobs_p_35 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_35
@@ -488,7 +535,8 @@ CREATE TABLE partitions.blobs_p_36 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_36
@@ -498,7 +546,8 @@ CREATE TABLE partitions.blobs_p_37 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_37
@@ -508,7 +557,8 @@ CREATE TABLE partitions.blobs_p_38 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_38
@@ -518,7 +568,8 @@ CREATE TABLE partitions.blobs_p_39 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_39
@@ -528,7 +579,8 @@ CREATE TABLE partitions.blobs_p_4 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_4
@@ -538,7 +590,8 @@ CREATE TABLE partitions.blobs_p_40 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_40
@@ -548,7 +601,8 @@ CREATE TABLE partitions.blobs_p_41 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_41
@@ -558,7 +612,8 @@ CREATE TABLE partitions.blobs_p_42 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_42
@@ -568,7 +623,8 @@ CREATE TABLE partitions.blobs_p_43 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_43
@@ -578,7 +634,8 @@ CREATE TABLE partitions.blobs_p_44 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_44
@@ -588,7 +645,8 @@ CREATE TABLE partitions.blobs_p_45 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_45
@@ -598,7 +656,8 @@ CREATE TABLE partitions.blobs_p_46 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_46
@@ -608,7 +667,8 @@ CREATE TABLE partitions.blobs_p_47 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_47
@@ -618,7 +678,8 @@ CREATE TABLE partitions.blobs_p_48 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_48
@@ -628,7 +689,8 @@ CREATE TABLE partitions.blobs_p_49 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_49
@@ -638,7 +700,8 @@ CREATE TABLE partitions.blobs_p_5 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_5
@@ -648,7 +711,8 @@ CREATE TABLE partitions.blobs_p_50 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_50
@@ -658,7 +722,8 @@ CREATE TABLE partitions.blobs_p_51 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_51
@@ -668,7 +733,8 @@ CREATE TABLE partitions.blobs_p_52 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_52
@@ -678,7 +744,8 @@ CREATE TABLE partitions.blobs_p_53 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_53
@@ -688,7 +755,8 @@ CREATE TABLE partitions.blobs_p_54 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_54
@@ -698,7 +766,8 @@ CREATE TABLE partitions.blobs_p_55 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_55
@@ -708,7 +777,8 @@ CREATE TABLE partitions.blobs_p_56 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_56
@@ -718,7 +788,8 @@ CREATE TABLE partitions.blobs_p_57 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_57
@@ -728,7 +799,8 @@ CREATE TABLE partitions.blobs_p_58 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_58
@@ -738,7 +810,8 @@ CREATE TABLE partitions.blobs_p_59 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_59
@@ -748,7 +821,8 @@ CREATE TABLE partitions.blobs_p_6 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_6
@@ -758,7 +832,8 @@ CREATE TABLE partitions.blobs_p_60 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_60
@@ -768,7 +843,8 @@ CREATE TABLE partitions.blobs_p_61 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_61
@@ -778,7 +854,8 @@ CREATE TABLE partitions.blobs_p_62 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_62
@@ -788,7 +865,8 @@ CREATE TABLE partitions.blobs_p_63 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_63
@@ -798,7 +876,8 @@ CREATE TABLE partitions.blobs_p_7 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_7
@@ -808,7 +887,8 @@ CREATE TABLE partitions.blobs_p_8 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_8
@@ -818,7 +898,8 @@ CREATE TABLE partitions.blobs_p_9 (
     size bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     media_type_id smallint NOT NULL,
-    digest bytea NOT NULL
+    digest bytea NOT NULL,
+    verified_at timestamp with time zone
 );
 
 ALTER TABLE ONLY public.blobs ATTACH PARTITION partitions.blobs_p_9
@@ -6725,6 +6806,13 @@ CREATE TABLE partitions.tags_p_9 (
 ALTER TABLE ONLY public.tags ATTACH PARTITION partitions.tags_p_9
 FOR VALUES WITH (MODULUS 64, REMAINDER 9);
 
+CREATE TABLE public.blob_uploads (
+    id uuid NOT NULL,
+    started_at timestamp with time zone DEFAULT now() NOT NULL,
+    repository_path text NOT NULL,
+    CONSTRAINT check_blob_uploads_repository_path_length CHECK ((char_length(repository_path) <= 255))
+);
+
 CREATE TABLE public.gc_blob_review_queue (
     review_after timestamp with time zone DEFAULT (now() + '1 day'::interval) NOT NULL,
     review_count integer DEFAULT 0 NOT NULL,
@@ -6805,6 +6893,28 @@ ALTER TABLE public.media_types
         NO MAXVALUE
         CACHE 1);
 
+CREATE TABLE public.replication_queue (
+    id bigint NOT NULL,
+    top_level_namespace_id bigint NOT NULL,
+    repository_id bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    review_after timestamp with time zone DEFAULT now() NOT NULL,
+    review_count integer DEFAULT 0 NOT NULL,
+    artifact text NOT NULL,
+    digest bytea NOT NULL,
+    tag text,
+    CONSTRAINT check_replication_queue_artifact_length CHECK ((char_length(artifact) <= 255)),
+    CONSTRAINT check_replication_queue_tag_length CHECK ((char_length(tag) <= 255))
+);
+
+ALTER TABLE public.replication_queue
+    ALTER COLUMN id
+    ADD GENERATED BY DEFAULT AS IDENTITY (SEQUENCE NAME
+        public.replication_queue_id_seq START WITH 1 INCREMENT BY 1
+        NO MINVALUE
+        NO MAXVALUE
+        CACHE 1);
+
 CREATE TABLE public.repositories (
     id bigint NOT NULL,
     top_level_namespace_id bigint NOT NULL,
@@ -6813,6 +6923,7 @@ CREATE TABLE public.repositories (
     updated_at timestamp with time zone,
     name text NOT NULL,
     path text NOT NULL,
+    archived boolean DEFAULT false NOT NULL,
     CONSTRAINT check_repositories_name_length CHECK ((char_length(name) <= 255)),
     CONSTRAINT check_repositories_path_length CHECK ((char_length(path) <= 255))
 );
@@ -6833,6 +6944,73 @@ ALTER TABLE public.repository_blobs
         NO MAXVALUE
         CACHE 1);
 
+CREATE TABLE public.repository_events (
+    id bigint NOT NULL,
+    top_level_namespace_id bigint NOT NULL,
+    repository_id bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    action text NOT NULL,
+    actor text,
+    digest text,
+    tag text,
+    previous_digest text,
+    upload_uuid text,
+    request_id text,
+    CONSTRAINT check_repository_events_action_length CHECK ((char_length(action) <= 255)),
+    CONSTRAINT check_repository_events_tag_length CHECK ((char_length(tag) <= 255))
+);
+
+ALTER TABLE public.repository_events
+    ALTER COLUMN id
+    ADD GENERATED BY DEFAULT AS IDENTITY (SEQUENCE NAME
+        public.repository_events_id_seq START WITH 1 INCREMENT BY 1
+        NO MINVALUE
+        NO MAXVALUE
+        CACHE 1);
+
+CREATE TABLE public.repository_retention_policies (
+    top_level_namespace_id bigint NOT NULL,
+    repository_id bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone,
+    review_after timestamp with time zone DEFAULT now() NOT NULL,
+    review_count integer DEFAULT 0 NOT NULL,
+    keep_n integer DEFAULT 0 NOT NULL,
+    keep_regex text,
+    older_than_seconds bigint DEFAULT 0 NOT NULL,
+    dry_run boolean DEFAULT false NOT NULL,
+    last_run_at timestamp with time zone,
+    last_run_deleted integer DEFAULT 0 NOT NULL,
+    error text,
+    CONSTRAINT check_repository_retention_policies_error_length CHECK ((char_length(error) <= 1024)),
+    CONSTRAINT check_repository_retention_policies_keep_n CHECK ((keep_n >= 0)),
+    CONSTRAINT check_repository_retention_policies_keep_regex_length CHECK ((char_length(keep_regex) <= 255)),
+    CONSTRAINT check_repository_retention_policies_older_than_seconds CHECK ((older_than_seconds >= 0))
+);
+
+CREATE TABLE public.repository_statistics (
+    top_level_namespace_id bigint NOT NULL,
+    repository_id bigint NOT NULL,
+    tags_count bigint DEFAULT 0 NOT NULL,
+    manifests_count bigint DEFAULT 0 NOT NULL,
+    size bigint DEFAULT 0 NOT NULL,
+    last_published_at timestamp with time zone,
+    calculated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+CREATE TABLE public.repository_storage_moves (
+    top_level_namespace_id bigint NOT NULL,
+    repository_id bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone,
+    review_after timestamp with time zone DEFAULT now() NOT NULL,
+    review_count integer DEFAULT 0 NOT NULL,
+    state text DEFAULT 'scheduled'::text NOT NULL,
+    error text,
+    CONSTRAINT check_repository_storage_moves_error_length CHECK ((char_length(error) <= 1024)),
+    CONSTRAINT check_repository_storage_moves_state CHECK ((state = ANY (ARRAY['scheduled'::text, 'copying'::text, 'cleanup'::text, 'finished'::text])))
+);
+
 CREATE TABLE public.schema_migrations (
     id text NOT NULL,
     applied_at timestamp with time zone
@@ -10177,6 +10355,9 @@ ALTER TABLE ONLY partitions.tags_p_9
 ALTER TABLE ONLY partitions.tags_p_9
     ADD CONSTRAINT tags_p_9_top_level_namespace_id_repository_id_name_key UNIQUE (top_level_namespace_id, repository_id, name);
 
+ALTER TABLE ONLY public.blob_uploads
+    ADD CONSTRAINT pk_blob_uploads PRIMARY KEY (id);
+
 ALTER TABLE ONLY public.gc_blob_review_queue
     ADD CONSTRAINT pk_gc_blob_review_queue PRIMARY KEY (digest);
 
@@ -10192,9 +10373,24 @@ ALTER TABLE ONLY public.gc_tmp_blobs_manifests
 ALTER TABLE ONLY public.media_types
     ADD CONSTRAINT pk_media_types PRIMARY KEY (id);
 
+ALTER TABLE ONLY public.replication_queue
+    ADD CONSTRAINT pk_replication_queue PRIMARY KEY (top_level_namespace_id, repository_id, id);
+
 ALTER TABLE ONLY public.repositories
     ADD CONSTRAINT pk_repositories PRIMARY KEY (top_level_namespace_id, id);
 
+ALTER TABLE ONLY public.repository_events
+    ADD CONSTRAINT pk_repository_events PRIMARY KEY (top_level_namespace_id, repository_id, id);
+
+ALTER TABLE ONLY public.repository_retention_policies
+    ADD CONSTRAINT pk_repository_retention_policies PRIMARY KEY (top_level_namespace_id, repository_id);
+
+ALTER TABLE ONLY public.repository_statistics
+    ADD CONSTRAINT pk_repository_statistics PRIMARY KEY (top_level_namespace_id, repository_id);
+
+ALTER TABLE ONLY public.repository_storage_moves
+    ADD CONSTRAINT pk_repository_storage_moves PRIMARY KEY (top_level_namespace_id, repository_id);
+
 ALTER TABLE ONLY public.top_level_namespaces
     ADD CONSTRAINT pk_top_level_namespaces PRIMARY KEY (id);
 
@@ -11640,12 +11836,26 @@ CREATE INDEX tags_p_8_top_level_namespace_id_repository_id_manifest_id_idx ON pa
 
 CREATE INDEX tags_p_9_top_level_namespace_id_repository_id_manifest_id_idx ON partitions.tags_p_9 USING btree (top_level_namespace_id, repository_id, manifest_id);
 
+CREATE INDEX index_blob_uploads_on_repository_path ON public.blob_uploads USING btree (repository_path);
+
+CREATE INDEX index_blob_uploads_on_started_at ON public.blob_uploads USING btree (started_at);
+
 CREATE INDEX index_gc_blob_review_queue_on_review_after ON public.gc_blob_review_queue USING btree (review_after);
 
 CREATE INDEX index_gc_manifest_review_queue_on_review_after ON public.gc_manifest_review_queue USING btree (review_after);
 
+CREATE INDEX index_replication_queue_on_review_after ON public.replication_queue USING btree (review_after);
+
+CREATE INDEX index_repositories_on_path_text_pattern_ops ON public.repositories USING btree (path text_pattern_ops);
+
 CREATE INDEX index_repositories_on_top_level_namespace_id_and_parent_id ON public.repositories USING btree (top_level_namespace_id, parent_id);
 
+CREATE INDEX index_repository_events_on_top_lvl_nmspc_id_rpstry_id_created_at ON public.repository_events USING btree (top_level_namespace_id, repository_id, created_at);
+
+CREATE INDEX index_repository_retention_policies_on_review_after ON public.repository_retention_policies USING btree (review_after);
+
+CREATE INDEX index_repository_storage_moves_on_review_after ON public.repository_storage_moves USING btree (review_after) WHERE (state <> 'finished'::text);
+
 ALTER INDEX public.index_blobs_on_media_type_id ATTACH PARTITION partitions.blobs_p_0_media_type_id_idx;
 
 ALTER INDEX public.pk_blobs ATTACH PARTITION partitions.blobs_p_0_pkey;
@@ -15280,6 +15490,16 @@ CREATE TRIGGER gc_track_tmp_blobs_manifests_trigger
     FOR EACH ROW
     EXECUTE FUNCTION public.gc_track_tmp_blobs_manifests ();
 
+CREATE TRIGGER touch_repository_updated_at_manifests_trigger
+    AFTER INSERT ON public.manifests
+    FOR EACH ROW
+    EXECUTE FUNCTION public.touch_repository_updated_at ();
+
+CREATE TRIGGER touch_repository_updated_at_tags_trigger
+    AFTER INSERT OR UPDATE ON public.tags
+    FOR EACH ROW
+    EXECUTE FUNCTION public.touch_repository_updated_at ();
+
 ALTER TABLE public.blobs
     ADD CONSTRAINT fk_blobs_media_type_id_media_types FOREIGN KEY (media_type_id) REFERENCES public.media_types (id);
 
@@ -15325,6 +15545,9 @@ ALTER TABLE public.manifests
 ALTER TABLE public.manifests
     ADD CONSTRAINT fk_manifests_top_lvl_nmespace_id_and_repository_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES public.repositories (top_level_namespace_id, id) ON DELETE CASCADE;
 
+ALTER TABLE ONLY public.replication_queue
+    ADD CONSTRAINT fk_replication_queue_top_lvl_nmspc_id_and_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES public.repositories (top_level_namespace_id, id) ON DELETE CASCADE;
+
 ALTER TABLE ONLY public.repositories
     ADD CONSTRAINT fk_repositories_top_level_namespace_id_top_level_namespaces FOREIGN KEY (top_level_namespace_id) REFERENCES public.top_level_namespaces (id) ON DELETE CASCADE;
 
@@ -15337,6 +15560,18 @@ ALTER TABLE public.repository_blobs
 ALTER TABLE public.repository_blobs
     ADD CONSTRAINT fk_repository_blobs_top_lvl_nmspc_id_and_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES public.repositories (top_level_namespace_id, id) ON DELETE CASCADE;
 
+ALTER TABLE ONLY public.repository_events
+    ADD CONSTRAINT fk_repository_events_top_lvl_nmspc_id_and_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES public.repositories (top_level_namespace_id, id) ON DELETE CASCADE;
+
+ALTER TABLE ONLY public.repository_retention_policies
+    ADD CONSTRAINT fk_rpstry_rtntn_plcs_tp_lvl_nmspc_id_rpstry_id_rpstrs FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES public.repositories (top_level_namespace_id, id) ON DELETE CASCADE;
+
+ALTER TABLE ONLY public.repository_statistics
+    ADD CONSTRAINT fk_repository_statistics_tp_lvl_nmspc_id_rpstry_id_rpstrs FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES public.repositories (top_level_namespace_id, id) ON DELETE CASCADE;
+
+ALTER TABLE ONLY public.repository_storage_moves
+    ADD CONSTRAINT fk_repository_storage_moves_tp_lvl_nmspc_id_rpstry_id_rpstrs FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES public.repositories (top_level_namespace_id, id) ON DELETE CASCADE;
+
 ALTER TABLE public.tags
     ADD CONSTRAINT fk_tags_repository_id_and_manifest_id_manifests FOREIGN KEY (top_level_namespace_id, repository_id, manifest_id) REFERENCES public.manifests (top_level_namespace_id, repository_id, id) ON DELETE CASCADE;
 
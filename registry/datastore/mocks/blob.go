@@ -122,3 +122,17 @@ func (mr *MockBlobStoreMockRecorder) FindByDigest(arg0, arg1 interface{}) *gomoc
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByDigest", reflect.TypeOf((*MockBlobStore)(nil).FindByDigest), arg0, arg1)
 }
+
+// MarkVerified mocks base method.
+func (m *MockBlobStore) MarkVerified(arg0 context.Context, arg1 *models.Blob) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkVerified", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkVerified indicates an expected call of MarkVerified.
+func (mr *MockBlobStoreMockRecorder) MarkVerified(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkVerified", reflect.TypeOf((*MockBlobStore)(nil).MarkVerified), arg0, arg1)
+}
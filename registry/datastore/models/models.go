@@ -26,6 +26,19 @@ type Namespace struct {
 	UpdatedAt sql.NullTime
 }
 
+// NamespaceDeduplication represents the storage usage of a namespace, before and after the deduplication of the blobs
+// shared by its repositories.
+type NamespaceDeduplication struct {
+	RepositoriesCount int
+	// BlobsCount is the number of blob links, i.e., a blob linked to several repositories is counted once per repository.
+	BlobsCount       int
+	UniqueBlobsCount int
+	// LogicalSize is the sum of the size of the blobs linked to each repository, as if they were not shared.
+	LogicalSize int64
+	// PhysicalSize is the sum of the size of the unique blobs linked to the repositories.
+	PhysicalSize int64
+}
+
 type Repository struct {
 	ID          int64
 	NamespaceID int64
@@ -34,11 +47,21 @@ type Repository struct {
 	ParentID    sql.NullInt64
 	CreatedAt   time.Time
 	UpdatedAt   sql.NullTime
+	Archived    bool
 }
 
 // Repositories is a slice of Repository pointers.
 type Repositories []*Repository
 
+// RepositoryDetail represents a repository along with its number of tags.
+type RepositoryDetail struct {
+	Repository
+	TagsCount int
+}
+
+// RepositoryDetails is a slice of RepositoryDetail pointers.
+type RepositoryDetails []*RepositoryDetail
+
 type Configuration struct {
 	MediaType string
 	Digest    digest.Digest
@@ -73,11 +96,164 @@ type Tag struct {
 // Tags is a slice of Tag pointers.
 type Tags []*Tag
 
+// TagDetail represents a tag along with the digest and media type of the manifest it points to.
+type TagDetail struct {
+	Name      string
+	Digest    digest.Digest
+	MediaType string
+	// ConfigMediaType is the media type of the configuration referenced by the manifest, if any.
+	ConfigMediaType string
+	// ConfigPayload is the configuration payload of the manifest, only set for Helm charts.
+	ConfigPayload Payload
+	CreatedAt     time.Time
+	UpdatedAt     sql.NullTime
+}
+
+// TagDetails is a slice of TagDetail pointers.
+type TagDetails []*TagDetail
+
+// ManifestDetail represents a manifest along with the size of its payload and the names of the tags pointing to it.
+type ManifestDetail struct {
+	Digest    digest.Digest
+	MediaType string
+	// ConfigMediaType is the media type of the configuration referenced by the manifest, if any.
+	ConfigMediaType string
+	// ConfigPayload is the configuration payload of the manifest, only set for Helm charts.
+	ConfigPayload Payload
+	Size          int64
+	Tags          []string
+	CreatedAt     time.Time
+}
+
+// ManifestDetails is a slice of ManifestDetail pointers.
+type ManifestDetails []*ManifestDetail
+
+// RepositoryEvent represents a row in the repository_events table. Optional string fields are empty when not set.
+type RepositoryEvent struct {
+	ID             int64
+	NamespaceID    int64
+	RepositoryID   int64
+	Action         string
+	Actor          string
+	Digest         digest.Digest
+	Tag            string
+	PreviousDigest digest.Digest
+	UploadUUID     string
+	RequestID      string
+	CreatedAt      time.Time
+}
+
+// RepositoryEvents is a slice of RepositoryEvent pointers.
+type RepositoryEvents []*RepositoryEvent
+
+const (
+	// ReplicationArtifactBlob identifies replication tasks for blobs.
+	ReplicationArtifactBlob = "blob"
+	// ReplicationArtifactManifest identifies replication tasks for manifests.
+	ReplicationArtifactManifest = "manifest"
+)
+
+// ReplicationTask represents a row in the replication_queue table. RepositoryPath is not stored in the table, it's
+// filled with the path of the corresponding repository when reading tasks. Tag is optional and only applies to manifests.
+type ReplicationTask struct {
+	ID             int64
+	NamespaceID    int64
+	RepositoryID   int64
+	RepositoryPath string
+	Artifact       string
+	Digest         digest.Digest
+	Tag            string
+	ReviewAfter    time.Time
+	ReviewCount    int
+	CreatedAt      time.Time
+}
+
+// BlobUpload represents a row in the blob_uploads table, which tracks in-progress blob upload sessions so that stale
+// ones can be purged without walking the storage backend.
+type BlobUpload struct {
+	ID             string
+	RepositoryPath string
+	StartedAt      time.Time
+}
+
+// StorageMoveState is the state of a repository storage move.
+type StorageMoveState string
+
+const (
+	// StorageMoveStateScheduled means the move is waiting to be processed.
+	StorageMoveStateScheduled StorageMoveState = "scheduled"
+	// StorageMoveStateCopying means the repository blobs are being copied to the target storage.
+	StorageMoveStateCopying StorageMoveState = "copying"
+	// StorageMoveStateCleanup means the repository is served from the target storage, and blobs left behind in the
+	// source storage are being removed.
+	StorageMoveStateCleanup StorageMoveState = "cleanup"
+	// StorageMoveStateFinished means the move is complete.
+	StorageMoveStateFinished StorageMoveState = "finished"
+)
+
+// Routed reports whether a repository whose move is in state s must be served from the target storage.
+func (s StorageMoveState) Routed() bool {
+	return s == StorageMoveStateCleanup || s == StorageMoveStateFinished
+}
+
+// StorageMove represents a row in the repository_storage_moves table, which tracks the relocation of a repository to
+// the target storage of the data mover. RepositoryPath is not stored in the table, it's filled with the path of the
+// corresponding repository when reading moves. Error holds the reason of the last failed attempt, if any.
+type StorageMove struct {
+	NamespaceID    int64
+	RepositoryID   int64
+	RepositoryPath string
+	State          StorageMoveState
+	Error          string
+	ReviewAfter    time.Time
+	ReviewCount    int
+	CreatedAt      time.Time
+	UpdatedAt      sql.NullTime
+}
+
+// RetentionPolicy represents a row in the repository_retention_policies table, which holds the tag retention policy of
+// a repository. A tag is retained if it is among the KeepN most recently written tags, if its name fully matches
+// KeepRegex or if it was written within OlderThan. All other tags are deleted when the policy is enforced, unless
+// DryRun is set. RepositoryPath is not stored in the table, it's filled with the path of the corresponding repository
+// when reading policies. LastRunDeleted is the number of tags deleted (or that would have been deleted, for dry runs)
+// by the last successful run, and Error holds the reason of the last failed run, if any.
+type RetentionPolicy struct {
+	NamespaceID    int64
+	RepositoryID   int64
+	RepositoryPath string
+	KeepN          int
+	KeepRegex      string
+	OlderThan      time.Duration
+	DryRun         bool
+	LastRunAt      sql.NullTime
+	LastRunDeleted int
+	Error          string
+	ReviewAfter    time.Time
+	ReviewCount    int
+	CreatedAt      time.Time
+	UpdatedAt      sql.NullTime
+}
+
+// RepositoryStatistics represents a row in the repository_statistics table, which holds the aggregates of a repository
+// as of CalculatedAt. Size is the sum of the size of all blobs linked to the repository, and LastPublishedAt is the
+// last time a tag was created or updated, or a manifest was pushed, if ever.
+type RepositoryStatistics struct {
+	NamespaceID     int64
+	RepositoryID    int64
+	TagsCount       int
+	ManifestsCount  int
+	Size            int64
+	LastPublishedAt sql.NullTime
+	CalculatedAt    time.Time
+}
+
 type Blob struct {
 	MediaType string
 	Digest    digest.Digest
 	Size      int64
 	CreatedAt time.Time
+	// VerifiedAt is the time at which the blob content was last verified against its digest, if ever.
+	VerifiedAt sql.NullTime
 }
 
 // Blobs is a slice of Blob pointers.
@@ -122,3 +298,15 @@ type GCReviewAfterDefault struct {
 	Event string
 	Value time.Duration
 }
+
+// TableStats represents the number of rows and disk usage of a database table, including all of its partitions.
+type TableStats struct {
+	Name string
+	// Rows is exact only if requested, otherwise it is the estimate maintained by the database statistics.
+	Rows int64
+	// Size is the total disk space used by the table, including indexes and TOAST data, in bytes.
+	Size int64
+}
+
+// TableStatsList is a slice of TableStats pointers.
+type TableStatsList []*TableStats
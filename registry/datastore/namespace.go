@@ -12,6 +12,7 @@ import (
 // NamespaceReader is the interface that defines read operations for a namespace store.
 type NamespaceReader interface {
 	FindByName(ctx context.Context, name string) (*models.Namespace, error)
+	Deduplication(ctx context.Context, n *models.Namespace) (*models.NamespaceDeduplication, error)
 }
 
 // NamespaceWriter is the interface that defines write operations for a namespace store.
@@ -66,6 +67,47 @@ func (s *namespaceStore) FindByName(ctx context.Context, name string) (*models.N
 	return scanFullNamespace(row)
 }
 
+// Deduplication reports the storage usage of a namespace, with and without counting the blobs shared by its
+// repositories once. Only the blobs linked to repositories are taken into account, not those pending garbage
+// collection.
+func (s *namespaceStore) Deduplication(ctx context.Context, n *models.Namespace) (*models.NamespaceDeduplication, error) {
+	defer metrics.InstrumentQuery("namespace_deduplication")()
+	q := `WITH links AS (
+			SELECT
+				rb.repository_id,
+				rb.blob_digest,
+				b.size
+			FROM
+				repository_blobs AS rb
+				JOIN blobs AS b ON b.digest = rb.blob_digest
+			WHERE
+				rb.top_level_namespace_id = $1
+		)
+		SELECT
+			count(DISTINCT l.repository_id),
+			count(*),
+			count(DISTINCT l.blob_digest),
+			coalesce(sum(l.size), 0)::bigint,
+			(
+				SELECT
+					coalesce(sum(u.size), 0)::bigint
+				FROM (
+					SELECT DISTINCT ON (blob_digest)
+						size
+					FROM
+						links) AS u)
+		FROM
+			links AS l`
+
+	d := new(models.NamespaceDeduplication)
+	row := s.db.QueryRowContext(ctx, q, n.ID)
+	if err := row.Scan(&d.RepositoriesCount, &d.BlobsCount, &d.UniqueBlobsCount, &d.LogicalSize, &d.PhysicalSize); err != nil {
+		return nil, fmt.Errorf("calculating namespace deduplication: %w", err)
+	}
+
+	return d, nil
+}
+
 // CreateOrFind attempts to create a namespace. If the namespace already exists (same name) that record is loaded from
 // the database into n. This is similar to a FindByName followed by a Create, but without being prone to race conditions
 // on write operations between the corresponding read (FindByName) and write (Create) operations. Separate Find* and
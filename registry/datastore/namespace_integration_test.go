@@ -47,3 +47,37 @@ func TestNamespaceStore_FindByName_NotFound(t *testing.T) {
 	require.Nil(t, n)
 	require.NoError(t, err)
 }
+
+func TestNamespaceStore_Deduplication(t *testing.T) {
+	reloadBlobFixtures(t)
+
+	s := datastore.NewNamespaceStore(suite.db)
+	n, err := s.FindByName(suite.ctx, "gitlab-org")
+	require.NoError(t, err)
+	require.NotNil(t, n)
+
+	d, err := s.Deduplication(suite.ctx, n)
+	require.NoError(t, err)
+
+	// see testdata/fixtures/repository_blobs.sql and testdata/fixtures/blobs.sql, the 3 blobs of repository 3 are also
+	// linked to repository 4
+	require.Equal(t, &models.NamespaceDeduplication{
+		RepositoriesCount: 2,
+		BlobsCount:        10,
+		UniqueBlobsCount:  7,
+		LogicalSize:       56580736,
+		PhysicalSize:      53777562,
+	}, d)
+}
+
+func TestNamespaceStore_Deduplication_Empty(t *testing.T) {
+	reloadBlobFixtures(t)
+
+	s := datastore.NewNamespaceStore(suite.db)
+	n := &models.Namespace{Name: "empty"}
+	require.NoError(t, s.CreateOrFind(suite.ctx, n))
+
+	d, err := s.Deduplication(suite.ctx, n)
+	require.NoError(t, err)
+	require.Equal(t, &models.NamespaceDeduplication{}, d)
+}
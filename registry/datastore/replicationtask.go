@@ -0,0 +1,196 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// ReplicationTaskStore is the interface that a replication task store should conform to.
+type ReplicationTaskStore interface {
+	Create(ctx context.Context, t *models.ReplicationTask) error
+	Count(ctx context.Context) (int, error)
+	Next(ctx context.Context, lease time.Duration) (*models.ReplicationTask, error)
+	Postpone(ctx context.Context, t *models.ReplicationTask, d time.Duration) error
+	Delete(ctx context.Context, t *models.ReplicationTask) error
+}
+
+type replicationTaskStore struct {
+	db Queryer
+}
+
+// NewReplicationTaskStore builds a new replicationTaskStore.
+func NewReplicationTaskStore(db Queryer) ReplicationTaskStore {
+	return &replicationTaskStore{db: db}
+}
+
+func scanFullReplicationTask(row *sql.Row) (*models.ReplicationTask, error) {
+	t := new(models.ReplicationTask)
+	var dgst Digest
+
+	err := row.Scan(&t.ID, &t.NamespaceID, &t.RepositoryID, &t.RepositoryPath, &t.Artifact, &dgst, &t.Tag,
+		&t.ReviewAfter, &t.ReviewCount, &t.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("scanning replication task: %w", err)
+	}
+
+	d, err := dgst.Parse()
+	if err != nil {
+		return nil, err
+	}
+	t.Digest = d
+
+	return t, nil
+}
+
+// Create saves a new replication task. The task is available for processing straight away.
+func (s *replicationTaskStore) Create(ctx context.Context, t *models.ReplicationTask) error {
+	defer metrics.InstrumentQuery("replication_task_create")()
+
+	q := `INSERT INTO replication_queue (top_level_namespace_id, repository_id, artifact, digest, tag)
+			VALUES ($1, $2, $3, decode($4, 'hex'), NULLIF($5, ''))
+		RETURNING
+			id, review_after, created_at`
+
+	dgst, err := NewDigest(t.Digest)
+	if err != nil {
+		return err
+	}
+
+	row := s.db.QueryRowContext(ctx, q, t.NamespaceID, t.RepositoryID, t.Artifact, dgst, t.Tag)
+	if err := row.Scan(&t.ID, &t.ReviewAfter, &t.CreatedAt); err != nil {
+		return fmt.Errorf("creating replication task: %w", err)
+	}
+
+	return nil
+}
+
+// Count counts all replication tasks.
+func (s *replicationTaskStore) Count(ctx context.Context) (int, error) {
+	defer metrics.InstrumentQuery("replication_task_count")()
+
+	q := "SELECT COUNT(*) FROM replication_queue"
+	var count int
+
+	if err := s.db.QueryRowContext(ctx, q).Scan(&count); err != nil {
+		return count, fmt.Errorf("counting replication tasks: %w", err)
+	}
+
+	return count, nil
+}
+
+// Next claims the replication task with the oldest review_after before the current date. In case of a draw the
+// returned task is the one that was first inserted. Unlike the GC review queues, replication tasks are not kept locked
+// while being processed, as pushing content to a remote registry can take much longer than what is acceptable for a
+// database transaction. Instead, the review_after of the claimed task is moved forward by lease, making it invisible
+// to other callers in the meantime. Callers must delete or postpone the task once done with it, otherwise it's retried
+// once the lease expires. This method may be called safely from multiple concurrent goroutines or processes. No error
+// is returned if there are no tasks available, a `nil` task is returned in this situation.
+func (s *replicationTaskStore) Next(ctx context.Context, lease time.Duration) (*models.ReplicationTask, error) {
+	defer metrics.InstrumentQuery("replication_task_next")()
+
+	q := `UPDATE
+			replication_queue AS q
+		SET
+			review_after = now() + $1 * interval '1 second'
+		FROM
+			repositories AS r
+		WHERE
+			(q.top_level_namespace_id, q.repository_id, q.id) = (
+				SELECT
+					top_level_namespace_id,
+					repository_id,
+					id
+				FROM
+					replication_queue
+				WHERE
+					review_after < now()
+				ORDER BY
+					review_after,
+					id
+				FOR UPDATE
+					SKIP LOCKED
+				LIMIT 1)
+			AND r.top_level_namespace_id = q.top_level_namespace_id
+			AND r.id = q.repository_id
+		RETURNING
+			q.id,
+			q.top_level_namespace_id,
+			q.repository_id,
+			r.path,
+			q.artifact,
+			encode(q.digest, 'hex'),
+			COALESCE(q.tag, ''),
+			q.review_after,
+			q.review_count,
+			q.created_at`
+
+	row := s.db.QueryRowContext(ctx, q, lease.Seconds())
+	t, err := scanFullReplicationTask(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("fetching next replication task: %w", err)
+	}
+
+	return t, nil
+}
+
+// Postpone sets the review_after of a replication task to the current date plus a given amount of time. The
+// review_count is automatically incremented.
+func (s *replicationTaskStore) Postpone(ctx context.Context, t *models.ReplicationTask, d time.Duration) error {
+	defer metrics.InstrumentQuery("replication_task_postpone")()
+
+	q := `UPDATE
+			replication_queue
+		SET
+			review_after = now() + $1 * interval '1 second',
+			review_count = review_count + 1
+		WHERE
+			top_level_namespace_id = $2
+			AND repository_id = $3
+			AND id = $4
+		RETURNING
+			review_after,
+			review_count`
+
+	row := s.db.QueryRowContext(ctx, q, d.Seconds(), t.NamespaceID, t.RepositoryID, t.ID)
+	if err := row.Scan(&t.ReviewAfter, &t.ReviewCount); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrReplicationTaskNotFound
+		}
+		return fmt.Errorf("postponing replication task: %w", err)
+	}
+
+	return nil
+}
+
+// Delete deletes a replication task from the replication queue.
+func (s *replicationTaskStore) Delete(ctx context.Context, t *models.ReplicationTask) error {
+	defer metrics.InstrumentQuery("replication_task_delete")()
+
+	q := `DELETE FROM replication_queue
+		WHERE top_level_namespace_id = $1
+			AND repository_id = $2
+			AND id = $3`
+
+	res, err := s.db.ExecContext(ctx, q, t.NamespaceID, t.RepositoryID, t.ID)
+	if err != nil {
+		return fmt.Errorf("deleting replication task: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("deleting replication task: %w", err)
+	}
+	if count == 0 {
+		return ErrReplicationTaskNotFound
+	}
+
+	return nil
+}
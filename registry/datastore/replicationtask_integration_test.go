@@ -0,0 +1,143 @@
+// +build integration
+
+package datastore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func reloadReplicationTaskFixtures(tb testing.TB) {
+	testutil.ReloadFixtures(
+		tb, suite.db, suite.basePath,
+		// A ReplicationTask has a foreign key for a Repository, which in turn references a Namespace (insert order matters)
+		testutil.NamespacesTable, testutil.RepositoriesTable, testutil.ReplicationQueueTable,
+	)
+}
+
+func unloadReplicationTaskFixtures(tb testing.TB) {
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.ReplicationQueueTable))
+}
+
+func TestReplicationTaskStore_Count(t *testing.T) {
+	reloadReplicationTaskFixtures(t)
+
+	s := datastore.NewReplicationTaskStore(suite.db)
+	count, err := s.Count(suite.ctx)
+	require.NoError(t, err)
+
+	// see testdata/fixtures/replication_queue.sql
+	require.Equal(t, 3, count)
+}
+
+func TestReplicationTaskStore_Create(t *testing.T) {
+	reloadReplicationTaskFixtures(t)
+
+	s := datastore.NewReplicationTaskStore(suite.db)
+	task := &models.ReplicationTask{
+		NamespaceID:  1,
+		RepositoryID: 4,
+		Artifact:     models.ReplicationArtifactManifest,
+		Digest:       "sha256:ea8a54fd13889d3649d0a4e45735116474b8a650815a2cda4940f652158579b9",
+		Tag:          "latest",
+	}
+	require.NoError(t, s.Create(suite.ctx, task))
+
+	require.NotEmpty(t, task.ID)
+	require.NotEmpty(t, task.CreatedAt)
+	require.NotEmpty(t, task.ReviewAfter)
+
+	count, err := s.Count(suite.ctx)
+	require.NoError(t, err)
+	require.Equal(t, 4, count)
+}
+
+func TestReplicationTaskStore_Next(t *testing.T) {
+	reloadReplicationTaskFixtures(t)
+
+	s := datastore.NewReplicationTaskStore(suite.db)
+
+	// see testdata/fixtures/replication_queue.sql
+	task, err := s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, task)
+	require.Equal(t, int64(1), task.ID)
+	require.Equal(t, "gitlab-org/gitlab-test/backend", task.RepositoryPath)
+	require.Equal(t, models.ReplicationArtifactBlob, task.Artifact)
+	require.Equal(t, digest.Digest("sha256:c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9"), task.Digest)
+	require.Empty(t, task.Tag)
+	require.True(t, task.ReviewAfter.After(time.Now().Add(50*time.Minute)))
+
+	// the first task is leased, so the next one must be returned
+	task, err = s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, task)
+	require.Equal(t, int64(2), task.ID)
+	require.Equal(t, "1.0.0", task.Tag)
+
+	// the remaining task is not due yet
+	task, err = s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.Nil(t, task)
+}
+
+func TestReplicationTaskStore_Next_None(t *testing.T) {
+	unloadReplicationTaskFixtures(t)
+
+	s := datastore.NewReplicationTaskStore(suite.db)
+	task, err := s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.Nil(t, task)
+}
+
+func TestReplicationTaskStore_Postpone(t *testing.T) {
+	reloadReplicationTaskFixtures(t)
+
+	s := datastore.NewReplicationTaskStore(suite.db)
+	task, err := s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, task)
+
+	require.NoError(t, s.Postpone(suite.ctx, task, -time.Minute))
+	require.Equal(t, 1, task.ReviewCount)
+
+	// the postponed task is due again
+	task2, err := s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, task2)
+	require.Equal(t, task.ID, task2.ID)
+	require.Equal(t, 1, task2.ReviewCount)
+}
+
+func TestReplicationTaskStore_Postpone_NotFound(t *testing.T) {
+	unloadReplicationTaskFixtures(t)
+
+	s := datastore.NewReplicationTaskStore(suite.db)
+	err := s.Postpone(suite.ctx, &models.ReplicationTask{NamespaceID: 1, RepositoryID: 3, ID: 100}, time.Minute)
+	require.ErrorIs(t, err, datastore.ErrReplicationTaskNotFound)
+}
+
+func TestReplicationTaskStore_Delete(t *testing.T) {
+	reloadReplicationTaskFixtures(t)
+
+	s := datastore.NewReplicationTaskStore(suite.db)
+	require.NoError(t, s.Delete(suite.ctx, &models.ReplicationTask{NamespaceID: 1, RepositoryID: 3, ID: 1}))
+
+	count, err := s.Count(suite.ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
+
+func TestReplicationTaskStore_Delete_NotFound(t *testing.T) {
+	unloadReplicationTaskFixtures(t)
+
+	s := datastore.NewReplicationTaskStore(suite.db)
+	err := s.Delete(suite.ctx, &models.ReplicationTask{NamespaceID: 1, RepositoryID: 3, ID: 100})
+	require.ErrorIs(t, err, datastore.ErrReplicationTaskNotFound)
+}
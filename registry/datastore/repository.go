@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/registry/datastore/metrics"
@@ -19,25 +20,33 @@ import (
 // RepositoryReader is the interface that defines read operations for a repository store.
 type RepositoryReader interface {
 	FindAll(ctx context.Context) (models.Repositories, error)
-	FindAllPaginated(ctx context.Context, limit int, lastPath string) (models.Repositories, error)
+	FindAllPaginated(ctx context.Context, limit int, lastPath, prefix string, includeArchived bool) (models.Repositories, error)
+	FindAllDetailPaginated(ctx context.Context, limit int, lastPath, prefix string, includeArchived bool) (models.RepositoryDetails, error)
 	FindByID(ctx context.Context, id int64) (*models.Repository, error)
 	FindByPath(ctx context.Context, path string) (*models.Repository, error)
 	FindDescendantsOf(ctx context.Context, id int64) (models.Repositories, error)
+	FindDescendantsOfPaginated(ctx context.Context, r *models.Repository, limit int, lastPath string, includeArchived bool) (models.Repositories, error)
 	FindAncestorsOf(ctx context.Context, id int64) (models.Repositories, error)
 	FindSiblingsOf(ctx context.Context, id int64) (models.Repositories, error)
 	Count(ctx context.Context) (int, error)
-	CountAfterPath(ctx context.Context, path string) (int, error)
+	CountAfterPath(ctx context.Context, path, prefix string, includeArchived bool) (int, error)
 	Manifests(ctx context.Context, r *models.Repository) (models.Manifests, error)
+	ManifestsDetailPaginated(ctx context.Context, r *models.Repository, limit int, lastDigest digest.Digest, untaggedOnly bool) (models.ManifestDetails, error)
 	Tags(ctx context.Context, r *models.Repository) (models.Tags, error)
 	TagsPaginated(ctx context.Context, r *models.Repository, limit int, lastName string) (models.Tags, error)
+	TagsDetailPaginated(ctx context.Context, r *models.Repository, limit int, lastName string) (models.TagDetails, error)
 	TagsCountAfterName(ctx context.Context, r *models.Repository, lastName string) (int, error)
+	TagsCount(ctx context.Context, r *models.Repository) (int, error)
+	ManifestsCount(ctx context.Context, r *models.Repository) (int, error)
 	ManifestTags(ctx context.Context, r *models.Repository, m *models.Manifest) (models.Tags, error)
 	FindManifestByDigest(ctx context.Context, r *models.Repository, d digest.Digest) (*models.Manifest, error)
+	FindManifestByID(ctx context.Context, r *models.Repository, id int64) (*models.Manifest, error)
 	FindManifestByTagName(ctx context.Context, r *models.Repository, tagName string) (*models.Manifest, error)
 	FindTagByName(ctx context.Context, r *models.Repository, name string) (*models.Tag, error)
 	Blobs(ctx context.Context, r *models.Repository) (models.Blobs, error)
 	FindBlob(ctx context.Context, r *models.Repository, d digest.Digest) (*models.Blob, error)
 	ExistsBlob(ctx context.Context, r *models.Repository, d digest.Digest) (bool, error)
+	FindBlobs(ctx context.Context, r *models.Repository, dd []digest.Digest) (models.Blobs, error)
 }
 
 // RepositoryWriter is the interface that defines write operations for a repository store.
@@ -47,10 +56,13 @@ type RepositoryWriter interface {
 	CreateOrFind(ctx context.Context, r *models.Repository) error
 	CreateOrFindByPath(ctx context.Context, path string) (*models.Repository, error)
 	Update(ctx context.Context, r *models.Repository) error
+	SetArchived(ctx context.Context, r *models.Repository, archived bool) error
+	Rename(ctx context.Context, r *models.Repository, path string, includeDescendants bool) error
 	UntagManifest(ctx context.Context, r *models.Repository, m *models.Manifest) error
 	LinkBlob(ctx context.Context, r *models.Repository, d digest.Digest) error
 	UnlinkBlob(ctx context.Context, r *models.Repository, d digest.Digest) (bool, error)
 	DeleteTagByName(ctx context.Context, r *models.Repository, name string) (bool, error)
+	DeleteTagByNameIfNotWrittenAfter(ctx context.Context, r *models.Repository, name string, lastWrite time.Time) (bool, error)
 	DeleteManifest(ctx context.Context, r *models.Repository, d digest.Digest) (bool, error)
 	Delete(ctx context.Context, id int64) error
 }
@@ -65,11 +77,44 @@ type RepositoryStore interface {
 type repositoryStore struct {
 	// db can be either a *sql.DB or *sql.Tx
 	db Queryer
+	// cache is the repository cache of the database handle, if any. Within transactions, it is only used for
+	// invalidation, as cached repositories may not reflect uncommitted changes and vice versa.
+	cache *RepositoryCache
 }
 
-// NewRepositoryStore builds a new repositoryStore.
+// NewRepositoryStore builds a new repositoryStore. If db is a *DB or *Tx configured with a repository cache, the
+// store looks up repositories by path in the cache first.
 func NewRepositoryStore(db Queryer) *repositoryStore {
-	return &repositoryStore{db: db}
+	s := &repositoryStore{db: db}
+	switch q := db.(type) {
+	case *DB:
+		s.cache = q.repositoryCache
+	case *Tx:
+		s.cache = q.repositoryCache
+	}
+
+	return s
+}
+
+// cached returns the repository cache, if one is configured and the store does not run within a transaction.
+func (s *repositoryStore) cached() *RepositoryCache {
+	if _, ok := s.db.(*Tx); ok {
+		return nil
+	}
+	return s.cache
+}
+
+// invalidate runs fn against the repository cache, if any. Within transactions, fn is run again once the transaction
+// is committed, as the cache may have been populated with the previous state in the meantime.
+func (s *repositoryStore) invalidate(fn func(c *RepositoryCache)) {
+	if s.cache == nil {
+		return
+	}
+
+	fn(s.cache)
+	if tx, ok := s.db.(*Tx); ok {
+		tx.onCommit(func() { fn(s.cache) })
+	}
 }
 
 // RepositoryManifestService implements the validation.ManifestExister
@@ -132,7 +177,7 @@ func (rbs *RepositoryBlobService) Stat(ctx context.Context, dgst digest.Digest)
 func scanFullRepository(row *sql.Row) (*models.Repository, error) {
 	r := new(models.Repository)
 
-	if err := row.Scan(&r.ID, &r.NamespaceID, &r.Name, &r.Path, &r.ParentID, &r.CreatedAt, &r.UpdatedAt); err != nil {
+	if err := row.Scan(&r.ID, &r.NamespaceID, &r.Name, &r.Path, &r.ParentID, &r.CreatedAt, &r.UpdatedAt, &r.Archived); err != nil {
 		if err != sql.ErrNoRows {
 			return nil, fmt.Errorf("scanning repository: %w", err)
 		}
@@ -148,7 +193,7 @@ func scanFullRepositories(rows *sql.Rows) (models.Repositories, error) {
 
 	for rows.Next() {
 		r := new(models.Repository)
-		if err := rows.Scan(&r.ID, &r.NamespaceID, &r.Name, &r.Path, &r.ParentID, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		if err := rows.Scan(&r.ID, &r.NamespaceID, &r.Name, &r.Path, &r.ParentID, &r.CreatedAt, &r.UpdatedAt, &r.Archived); err != nil {
 			return nil, fmt.Errorf("scanning repository: %w", err)
 		}
 		rr = append(rr, r)
@@ -170,7 +215,8 @@ func (s *repositoryStore) FindByID(ctx context.Context, id int64) (*models.Repos
 			path,
 			parent_id,
 			created_at,
-			updated_at
+			updated_at,
+			archived
 		FROM
 			repositories
 		WHERE
@@ -180,8 +226,15 @@ func (s *repositoryStore) FindByID(ctx context.Context, id int64) (*models.Repos
 	return scanFullRepository(row)
 }
 
-// FindByPath finds a repository by path.
+// FindByPath finds a repository by path. Repositories are looked up in the repository cache first, if any.
 func (s *repositoryStore) FindByPath(ctx context.Context, path string) (*models.Repository, error) {
+	cache := s.cached()
+	if cache != nil {
+		if r, ok := cache.Get(path); ok {
+			return r, nil
+		}
+	}
+
 	defer metrics.InstrumentQuery("repository_find_by_path")()
 	q := `SELECT
 			id,
@@ -190,7 +243,8 @@ func (s *repositoryStore) FindByPath(ctx context.Context, path string) (*models.
 			path,
 			parent_id,
 			created_at,
-			updated_at
+			updated_at,
+			archived
 		FROM
 			repositories
 		WHERE
@@ -198,7 +252,12 @@ func (s *repositoryStore) FindByPath(ctx context.Context, path string) (*models.
 
 	row := s.db.QueryRowContext(ctx, q, path)
 
-	return scanFullRepository(row)
+	r, err := scanFullRepository(row)
+	if err == nil && r != nil && cache != nil {
+		cache.Add(r)
+	}
+
+	return r, err
 }
 
 // FindAll finds all repositories.
@@ -211,7 +270,8 @@ func (s *repositoryStore) FindAll(ctx context.Context) (models.Repositories, err
 			path,
 			parent_id,
 			created_at,
-			updated_at
+			updated_at,
+			archived
 		FROM
 			repositories`
 	rows, err := s.db.QueryContext(ctx, q)
@@ -222,13 +282,19 @@ func (s *repositoryStore) FindAll(ctx context.Context) (models.Repositories, err
 	return scanFullRepositories(rows)
 }
 
+// likePrefixPattern returns a LIKE pattern matching all strings starting with prefix, escaping any wildcards within it.
+func likePrefixPattern(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+}
+
 // FindAllPaginated finds up to limit repositories with path lexicographically after lastPath. This is used exclusively
 // for the GET /v2/_catalog API route, where pagination is done with a marker (lastPath). Empty repositories (which do
-// not have at least a manifest) are ignored. Also, even if there is no repository with a path of lastPath, the returned
-// repositories will always be those with a path lexicographically after lastPath. Finally, repositories are
-// lexicographically sorted. These constraints exists to preserve the existing API behavior (when doing a filesystem
-// walk based pagination).
-func (s *repositoryStore) FindAllPaginated(ctx context.Context, limit int, lastPath string) (models.Repositories, error) {
+// not have at least a manifest) are ignored, and so are archived repositories unless includeArchived is true. Also,
+// even if there is no repository with a path of lastPath, the returned repositories will always be those with a path
+// lexicographically after lastPath. Finally, repositories are lexicographically sorted. These constraints exists to
+// preserve the existing API behavior (when doing a filesystem walk based pagination). If prefix is not empty, only
+// repositories with a path starting with prefix are returned.
+func (s *repositoryStore) FindAllPaginated(ctx context.Context, limit int, lastPath, prefix string, includeArchived bool) (models.Repositories, error) {
 	defer metrics.InstrumentQuery("repository_find_all_paginated")()
 	q := `SELECT
 			r.id,
@@ -237,7 +303,8 @@ func (s *repositoryStore) FindAllPaginated(ctx context.Context, limit int, lastP
 			r.path,
 			r.parent_id,
 			r.created_at,
-			r.updated_at
+			r.updated_at,
+			r.archived
 		FROM
 			repositories AS r
 		WHERE
@@ -249,10 +316,12 @@ func (s *repositoryStore) FindAllPaginated(ctx context.Context, limit int, lastP
 					m.top_level_namespace_id = r.top_level_namespace_id
 					AND m.repository_id = r.id)
 			AND r.path > $1
+			AND r.path LIKE $4
+			AND ($3 OR NOT r.archived)
 		ORDER BY
 			r.path
 		LIMIT $2`
-	rows, err := s.db.QueryContext(ctx, q, lastPath, limit)
+	rows, err := s.db.QueryContext(ctx, q, lastPath, limit, includeArchived, likePrefixPattern(prefix))
 	if err != nil {
 		return nil, fmt.Errorf("finding repositories with pagination: %w", err)
 	}
@@ -260,6 +329,64 @@ func (s *repositoryStore) FindAllPaginated(ctx context.Context, limit int, lastP
 	return scanFullRepositories(rows)
 }
 
+// FindAllDetailPaginated is similar to FindAllPaginated, but the number of tags of each repository is returned as well.
+// Tags are counted within the same query, to avoid a database round trip per repository.
+func (s *repositoryStore) FindAllDetailPaginated(ctx context.Context, limit int, lastPath, prefix string, includeArchived bool) (models.RepositoryDetails, error) {
+	defer metrics.InstrumentQuery("repository_find_all_detail_paginated")()
+	q := `SELECT
+			r.id,
+			r.top_level_namespace_id,
+			r.name,
+			r.path,
+			r.parent_id,
+			r.created_at,
+			r.updated_at,
+			r.archived,
+			(
+				SELECT
+					COUNT(t.id)
+				FROM
+					tags AS t
+				WHERE
+					t.top_level_namespace_id = r.top_level_namespace_id
+					AND t.repository_id = r.id) AS tags_count
+		FROM
+			repositories AS r
+		WHERE
+			EXISTS (
+				SELECT
+				FROM
+					manifests AS m
+				WHERE
+					m.top_level_namespace_id = r.top_level_namespace_id
+					AND m.repository_id = r.id)
+			AND r.path > $1
+			AND r.path LIKE $4
+			AND ($3 OR NOT r.archived)
+		ORDER BY
+			r.path
+		LIMIT $2`
+	rows, err := s.db.QueryContext(ctx, q, lastPath, limit, includeArchived, likePrefixPattern(prefix))
+	if err != nil {
+		return nil, fmt.Errorf("finding repositories detail with pagination: %w", err)
+	}
+	defer rows.Close()
+
+	rr := make(models.RepositoryDetails, 0)
+	for rows.Next() {
+		r := new(models.RepositoryDetail)
+		if err := rows.Scan(&r.ID, &r.NamespaceID, &r.Name, &r.Path, &r.ParentID, &r.CreatedAt, &r.UpdatedAt, &r.Archived, &r.TagsCount); err != nil {
+			return nil, fmt.Errorf("scanning repository detail: %w", err)
+		}
+		rr = append(rr, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning repositories detail: %w", err)
+	}
+
+	return rr, nil
+}
+
 // FindDescendantsOf finds all descendants of a given repository.
 func (s *repositoryStore) FindDescendantsOf(ctx context.Context, id int64) (models.Repositories, error) {
 	defer metrics.InstrumentQuery("repository_find_descendants_of")()
@@ -271,7 +398,8 @@ func (s *repositoryStore) FindDescendantsOf(ctx context.Context, id int64) (mode
 				path,
 				parent_id,
 				created_at,
-				updated_at
+				updated_at,
+				archived
 			FROM
 				repositories
 			WHERE
@@ -284,7 +412,8 @@ func (s *repositoryStore) FindDescendantsOf(ctx context.Context, id int64) (mode
 				r.path,
 				r.parent_id,
 				r.created_at,
-				r.updated_at
+				r.updated_at,
+				r.archived
 			FROM
 				repositories AS r
 				JOIN descendants ON descendants.id = r.parent_id
@@ -304,6 +433,68 @@ func (s *repositoryStore) FindDescendantsOf(ctx context.Context, id int64) (mode
 	return scanFullRepositories(rows)
 }
 
+// FindDescendantsOfPaginated finds up to limit repositories within the tree of a given repository, including the
+// repository itself, with path lexicographically after lastPath. Like FindAllPaginated, empty repositories (which do
+// not have at least a manifest) are ignored, and so are archived repositories unless includeArchived is true.
+// Repositories are lexicographically sorted by path.
+func (s *repositoryStore) FindDescendantsOfPaginated(ctx context.Context, r *models.Repository, limit int, lastPath string, includeArchived bool) (models.Repositories, error) {
+	defer metrics.InstrumentQuery("repository_find_descendants_of_paginated")()
+	q := `WITH RECURSIVE descendants AS (
+			SELECT
+				id,
+				top_level_namespace_id,
+				name,
+				path,
+				parent_id,
+				created_at,
+				updated_at,
+				archived
+			FROM
+				repositories
+			WHERE
+				top_level_namespace_id = $1
+				AND id = $2
+			UNION ALL
+			SELECT
+				r.id,
+				r.top_level_namespace_id,
+				r.name,
+				r.path,
+				r.parent_id,
+				r.created_at,
+				r.updated_at,
+				r.archived
+			FROM
+				repositories AS r
+				JOIN descendants ON descendants.top_level_namespace_id = r.top_level_namespace_id
+					AND descendants.id = r.parent_id
+		)
+		SELECT
+			d.*
+		FROM
+			descendants AS d
+		WHERE
+			EXISTS (
+				SELECT
+				FROM
+					manifests AS m
+				WHERE
+					m.top_level_namespace_id = d.top_level_namespace_id
+					AND m.repository_id = d.id)
+			AND d.path > $3
+			AND ($5 OR NOT d.archived)
+		ORDER BY
+			d.path
+		LIMIT $4`
+
+	rows, err := s.db.QueryContext(ctx, q, r.NamespaceID, r.ID, lastPath, limit, includeArchived)
+	if err != nil {
+		return nil, fmt.Errorf("finding descendants of repository with pagination: %w", err)
+	}
+
+	return scanFullRepositories(rows)
+}
+
 // FindAncestorsOf finds all ancestors of a given repository.
 func (s *repositoryStore) FindAncestorsOf(ctx context.Context, id int64) (models.Repositories, error) {
 	defer metrics.InstrumentQuery("repository_find_ancestors_of")()
@@ -315,7 +506,8 @@ func (s *repositoryStore) FindAncestorsOf(ctx context.Context, id int64) (models
 				path,
 				parent_id,
 				created_at,
-				updated_at
+				updated_at,
+				archived
 			FROM
 				repositories
 			WHERE
@@ -328,7 +520,8 @@ func (s *repositoryStore) FindAncestorsOf(ctx context.Context, id int64) (models
 				r.path,
 				r.parent_id,
 				r.created_at,
-				r.updated_at
+				r.updated_at,
+				r.archived
 			FROM
 				repositories AS r
 				JOIN ancestors ON ancestors.parent_id = r.id
@@ -358,7 +551,8 @@ func (s *repositoryStore) FindSiblingsOf(ctx context.Context, id int64) (models.
 			siblings.path,
 			siblings.parent_id,
 			siblings.created_at,
-			siblings.updated_at
+			siblings.updated_at,
+			siblings.archived
 		FROM
 			repositories AS siblings
 			LEFT JOIN repositories AS anchor ON siblings.parent_id = anchor.parent_id
@@ -430,6 +624,53 @@ func (s *repositoryStore) TagsPaginated(ctx context.Context, r *models.Repositor
 	return scanFullTags(rows)
 }
 
+// HelmChartConfigMediaType is the media type of the configuration of Helm charts stored as OCI artifacts.
+const HelmChartConfigMediaType = "application/vnd.cncf.helm.config.v1+json"
+
+// configMediaTypeLateral is a lateral subquery that extracts the media type of the configuration referenced by a
+// manifest m from its payload. The configuration_media_type_id column can not be used for this, as it is not reliably
+// populated for all manifests. Manifests without a configuration, such as manifest lists, yield a null media type.
+const configMediaTypeLateral = `(
+				SELECT
+					convert_from(m.payload, 'UTF8')::jsonb -> 'config' ->> 'mediaType' AS media_type) AS c`
+
+// TagsDetailPaginated finds up to limit tags of a given repository with name lexicographically after lastName, along
+// with the digest, media type and configuration media type of the manifest they point to, as well as its
+// configuration payload for Helm charts. Tags are lexicographically sorted.
+func (s *repositoryStore) TagsDetailPaginated(ctx context.Context, r *models.Repository, limit int, lastName string) (models.TagDetails, error) {
+	defer metrics.InstrumentQuery("repository_tags_detail_paginated")()
+	q := `SELECT
+			t.name,
+			encode(m.digest, 'hex') as digest,
+			mt.media_type,
+			c.media_type AS configuration_media_type,
+			CASE WHEN c.media_type = $5 THEN
+				m.configuration_payload
+			END AS configuration_payload,
+			t.created_at,
+			t.updated_at
+		FROM
+			tags AS t
+			JOIN manifests AS m ON m.top_level_namespace_id = t.top_level_namespace_id
+				AND m.repository_id = t.repository_id
+				AND m.id = t.manifest_id
+			JOIN media_types AS mt ON mt.id = m.media_type_id
+			CROSS JOIN LATERAL ` + configMediaTypeLateral + `
+		WHERE
+			t.top_level_namespace_id = $1
+			AND t.repository_id = $2
+			AND t.name > $3
+		ORDER BY
+			t.name
+		LIMIT $4`
+	rows, err := s.db.QueryContext(ctx, q, r.NamespaceID, r.ID, lastName, limit, HelmChartConfigMediaType)
+	if err != nil {
+		return nil, fmt.Errorf("finding tags detail with pagination: %w", err)
+	}
+
+	return scanTagDetails(rows)
+}
+
 // TagsCountAfterName counts all tags of a given repository with name lexicographically after lastName. This is used
 // exclusively for the GET /v2/<name>/tags/list API route, where pagination is done with a marker (lastName). Even if
 // there is no tag with a name of lastName, the counted tags will always be those with a path lexicographically after
@@ -454,6 +695,44 @@ func (s *repositoryStore) TagsCountAfterName(ctx context.Context, r *models.Repo
 	return count, nil
 }
 
+// TagsCount counts all tags of a given repository.
+func (s *repositoryStore) TagsCount(ctx context.Context, r *models.Repository) (int, error) {
+	defer metrics.InstrumentQuery("repository_tags_count")()
+	q := `SELECT
+			COUNT(id)
+		FROM
+			tags
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2`
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, q, r.NamespaceID, r.ID).Scan(&count); err != nil {
+		return count, fmt.Errorf("counting tags: %w", err)
+	}
+
+	return count, nil
+}
+
+// ManifestsCount counts all manifests of a given repository.
+func (s *repositoryStore) ManifestsCount(ctx context.Context, r *models.Repository) (int, error) {
+	defer metrics.InstrumentQuery("repository_manifests_count")()
+	q := `SELECT
+			COUNT(id)
+		FROM
+			manifests
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2`
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, q, r.NamespaceID, r.ID).Scan(&count); err != nil {
+		return count, fmt.Errorf("counting manifests: %w", err)
+	}
+
+	return count, nil
+}
+
 // ManifestTags finds all tags of a given repository manifest.
 func (s *repositoryStore) ManifestTags(ctx context.Context, r *models.Repository, m *models.Manifest) (models.Tags, error) {
 	defer metrics.InstrumentQuery("repository_manifest_tags")()
@@ -495,10 +774,12 @@ func (s *repositoryStore) Count(ctx context.Context) (int, error) {
 
 // CountAfterPath counts all repositories with path lexicographically after lastPath. This is used exclusively
 // for the GET /v2/_catalog API route, where pagination is done with a marker (lastPath). Empty repositories (which do
-// not have at least a manifest) are ignored. Also, even if there is no repository with a path of lastPath, the counted
-// repositories will always be those with a path lexicographically after lastPath. These constraints exists to preserve
-// the existing API behavior (when doing a filesystem walk based pagination).
-func (s *repositoryStore) CountAfterPath(ctx context.Context, path string) (int, error) {
+// not have at least a manifest) are ignored, and so are archived repositories unless includeArchived is true. Also,
+// even if there is no repository with a path of lastPath, the counted repositories will always be those with a path
+// lexicographically after lastPath. These constraints exists to preserve the existing API behavior (when doing a
+// filesystem walk based pagination). If prefix is not empty, only repositories with a path starting with prefix are
+// counted.
+func (s *repositoryStore) CountAfterPath(ctx context.Context, path, prefix string, includeArchived bool) (int, error) {
 	defer metrics.InstrumentQuery("repository_count_after_path")()
 	q := `SELECT
 			COUNT(*)
@@ -512,10 +793,12 @@ func (s *repositoryStore) CountAfterPath(ctx context.Context, path string) (int,
 				WHERE
 					m.top_level_namespace_id = r.top_level_namespace_id -- PROBLEM - cross partition scan
 					AND m.repository_id = r.id)
-			AND r.path > $1`
+			AND r.path > $1
+			AND r.path LIKE $3
+			AND ($2 OR NOT r.archived)`
 
 	var count int
-	if err := s.db.QueryRowContext(ctx, q, path).Scan(&count); err != nil {
+	if err := s.db.QueryRowContext(ctx, q, path, includeArchived, likePrefixPattern(prefix)).Scan(&count); err != nil {
 		return count, fmt.Errorf("counting repositories lexicographically after path: %w", err)
 	}
 
@@ -554,6 +837,67 @@ func (s *repositoryStore) Manifests(ctx context.Context, r *models.Repository) (
 	return scanFullManifests(rows)
 }
 
+// ManifestsDetailPaginated finds up to limit manifests of a given repository with a digest after lastDigest, along
+// with the size of their payload, their configuration media type and the names of the tags pointing to them, sorted
+// lexicographically. The configuration payload is included for Helm charts. Untagged manifests are included, unless
+// untaggedOnly is true, in which case only untagged manifests are found. Manifests are sorted by digest.
+func (s *repositoryStore) ManifestsDetailPaginated(ctx context.Context, r *models.Repository, limit int, lastDigest digest.Digest, untaggedOnly bool) (models.ManifestDetails, error) {
+	defer metrics.InstrumentQuery("repository_manifests_detail_paginated")()
+	q := `SELECT
+			encode(m.digest, 'hex') as digest,
+			mt.media_type,
+			c.media_type AS configuration_media_type,
+			CASE WHEN c.media_type = $6 THEN
+				m.configuration_payload
+			END AS configuration_payload,
+			octet_length(m.payload) AS size,
+			(
+				SELECT
+					string_agg(t.name, ',' ORDER BY t.name)
+				FROM
+					tags AS t
+				WHERE
+					t.top_level_namespace_id = m.top_level_namespace_id
+					AND t.repository_id = m.repository_id
+					AND t.manifest_id = m.id) AS tags,
+			m.created_at
+		FROM
+			manifests AS m
+			JOIN media_types AS mt ON mt.id = m.media_type_id
+			CROSS JOIN LATERAL ` + configMediaTypeLateral + `
+		WHERE
+			m.top_level_namespace_id = $1
+			AND m.repository_id = $2
+			AND m.digest > decode($3, 'hex')
+			AND (NOT $4::boolean
+				OR NOT EXISTS (
+					SELECT
+						1
+					FROM
+						tags AS t
+					WHERE
+						t.top_level_namespace_id = m.top_level_namespace_id
+						AND t.repository_id = m.repository_id
+						AND t.manifest_id = m.id))
+		ORDER BY
+			m.digest
+		LIMIT $5`
+
+	var last Digest
+	if lastDigest != "" {
+		var err error
+		if last, err = NewDigest(lastDigest); err != nil {
+			return nil, err
+		}
+	}
+	rows, err := s.db.QueryContext(ctx, q, r.NamespaceID, r.ID, last, untaggedOnly, limit, HelmChartConfigMediaType)
+	if err != nil {
+		return nil, fmt.Errorf("finding manifests detail with pagination: %w", err)
+	}
+
+	return scanManifestDetails(rows)
+}
+
 // FindManifestByDigest finds a manifest by digest within a repository.
 func (s *repositoryStore) FindManifestByDigest(ctx context.Context, r *models.Repository, d digest.Digest) (*models.Manifest, error) {
 	defer metrics.InstrumentQuery("repository_find_manifest_by_digest")()
@@ -587,6 +931,35 @@ func (s *repositoryStore) FindManifestByDigest(ctx context.Context, r *models.Re
 	return scanFullManifest(row)
 }
 
+// FindManifestByID finds a manifest by ID within a repository.
+func (s *repositoryStore) FindManifestByID(ctx context.Context, r *models.Repository, id int64) (*models.Manifest, error) {
+	defer metrics.InstrumentQuery("repository_find_manifest_by_id")()
+	q := `SELECT
+			m.id,
+			m.top_level_namespace_id,
+			m.repository_id,
+			m.schema_version,
+			mt.media_type,
+			encode(m.digest, 'hex') as digest,
+			m.payload,
+			mtc.media_type as configuration_media_type,
+			encode(m.configuration_blob_digest, 'hex') as configuration_blob_digest,
+			m.configuration_payload,
+			m.created_at
+		FROM
+			manifests AS m
+			JOIN media_types AS mt ON mt.id = m.media_type_id
+			LEFT JOIN media_types AS mtc ON mtc.id = m.configuration_media_type_id
+		WHERE
+			m.top_level_namespace_id = $1
+			AND m.repository_id = $2
+			AND m.id = $3`
+
+	row := s.db.QueryRowContext(ctx, q, r.NamespaceID, r.ID, id)
+
+	return scanFullManifest(row)
+}
+
 // FindManifestByTagName finds a manifest by tag name within a repository.
 func (s *repositoryStore) FindManifestByTagName(ctx context.Context, r *models.Repository, tagName string) (*models.Manifest, error) {
 	defer metrics.InstrumentQuery("repository_find_manifest_by_tag_name")()
@@ -626,7 +999,8 @@ func (s *repositoryStore) Blobs(ctx context.Context, r *models.Repository) (mode
 			mt.media_type,
 			encode(b.digest, 'hex') as digest,
 			b.size,
-			b.created_at
+			b.created_at,
+			b.verified_at
 		FROM
 			blobs AS b
 			JOIN repository_blobs AS rb ON rb.blob_digest = b.digest
@@ -651,7 +1025,8 @@ func (s *repositoryStore) FindBlob(ctx context.Context, r *models.Repository, d
 			mt.media_type,
 			encode(b.digest, 'hex') as digest,
 			b.size,
-			b.created_at
+			b.created_at,
+			b.verified_at
 		FROM
 			blobs AS b
 			JOIN media_types AS mt ON mt.id = b.media_type_id
@@ -698,6 +1073,50 @@ func (s *repositoryStore) ExistsBlob(ctx context.Context, r *models.Repository,
 	return exists, nil
 }
 
+// FindBlobs finds the blobs with the given digests within a repository, using a single query. Blobs that do not exist
+// are not included in the result, whose order is undefined.
+func (s *repositoryStore) FindBlobs(ctx context.Context, r *models.Repository, dd []digest.Digest) (models.Blobs, error) {
+	if len(dd) == 0 {
+		return models.Blobs{}, nil
+	}
+
+	defer metrics.InstrumentQuery("repository_find_blobs")()
+	q := `SELECT
+			mt.media_type,
+			encode(b.digest, 'hex') as digest,
+			b.size,
+			b.created_at,
+			b.verified_at
+		FROM
+			blobs AS b
+			JOIN media_types AS mt ON mt.id = b.media_type_id
+			JOIN repository_blobs AS rb ON rb.blob_digest = b.digest
+		WHERE
+			rb.top_level_namespace_id = $1
+			AND rb.repository_id = $2
+			AND b.digest IN (%s)`
+
+	params := make([]string, 0, len(dd))
+	args := make([]interface{}, 0, len(dd)+2)
+	args = append(args, r.NamespaceID, r.ID)
+	for i, d := range dd {
+		dgst, err := NewDigest(d)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, fmt.Sprintf("decode($%d, 'hex')", i+3))
+		args = append(args, dgst)
+	}
+	q = fmt.Sprintf(q, strings.Join(params, ","))
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("finding blobs: %w", err)
+	}
+
+	return scanFullBlobs(rows)
+}
+
 // Create saves a new repository.
 func (s *repositoryStore) Create(ctx context.Context, r *models.Repository) error {
 	defer metrics.InstrumentQuery("repository_create")()
@@ -888,6 +1307,8 @@ func (s *repositoryStore) CreateOrFindByPath(ctx context.Context, path string) (
 // Update updates an existing repository.
 func (s *repositoryStore) Update(ctx context.Context, r *models.Repository) error {
 	defer metrics.InstrumentQuery("repository_update")()
+	s.invalidate(func(c *RepositoryCache) { c.RemoveByID(r.ID) })
+
 	q := `UPDATE
 			repositories
 		SET
@@ -901,7 +1322,7 @@ func (s *repositoryStore) Update(ctx context.Context, r *models.Repository) erro
 	row := s.db.QueryRowContext(ctx, q, r.Name, r.Path, r.ParentID, r.NamespaceID, r.ID)
 	if err := row.Scan(&r.UpdatedAt); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("repository not found")
+			return ErrRepositoryNotFound
 		}
 		return fmt.Errorf("updating repository: %w", err)
 	}
@@ -909,6 +1330,121 @@ func (s *repositoryStore) Update(ctx context.Context, r *models.Repository) erro
 	return nil
 }
 
+// SetArchived archives or unarchives a repository. Archived repositories are read-only, hidden from the catalog by
+// default, and their untagged manifests are retained by the online garbage collector.
+func (s *repositoryStore) SetArchived(ctx context.Context, r *models.Repository, archived bool) error {
+	defer metrics.InstrumentQuery("repository_set_archived")()
+	s.invalidate(func(c *RepositoryCache) { c.RemoveByID(r.ID) })
+
+	q := `UPDATE
+			repositories
+		SET
+			(archived, updated_at) = ($1, now())
+		WHERE
+			top_level_namespace_id = $2
+			AND id = $3
+		RETURNING
+			archived,
+			updated_at`
+
+	row := s.db.QueryRowContext(ctx, q, archived, r.NamespaceID, r.ID)
+	if err := row.Scan(&r.Archived, &r.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRepositoryNotFound
+		}
+		return fmt.Errorf("updating repository archived state: %w", err)
+	}
+
+	return nil
+}
+
+// Rename moves a repository to path, creating any missing parent repositories along the way. If includeDescendants is
+// true, all descendants of r are moved along with it. Otherwise, they are left at their current path, and an empty
+// repository is created in place of r to preserve the hierarchy. ErrRepositoryExists is returned if a repository
+// already exists at path. Repositories can only be renamed within the same top-level namespace, as all their data is
+// partitioned by it. Callers should run this within a transaction, as it spans multiple queries.
+func (s *repositoryStore) Rename(ctx context.Context, r *models.Repository, path string, includeDescendants bool) error {
+	if splitRepositoryPath(path)[0] != splitRepositoryPath(r.Path)[0] {
+		return ErrRepositoryRenameNamespace
+	}
+	if path == r.Path || strings.HasPrefix(path, r.Path+"/") {
+		return ErrRepositoryRenameDescendant
+	}
+
+	dst, err := s.FindByPath(ctx, path)
+	if err != nil {
+		return err
+	}
+	if dst != nil {
+		return ErrRepositoryExists
+	}
+
+	defer metrics.InstrumentQuery("repository_rename")()
+
+	p, err := s.createOrFindParentByPath(ctx, path, &models.Namespace{ID: r.NamespaceID})
+	if err != nil {
+		return fmt.Errorf("creating or finding parent repository: %w", err)
+	}
+
+	oldPath, oldName, oldParentID := r.Path, r.Name, r.ParentID
+	r.Path = path
+	r.Name = repositoryName(path)
+	r.ParentID = sql.NullInt64{}
+	if p != nil {
+		r.ParentID = sql.NullInt64{Int64: p.ID, Valid: true}
+	}
+	if err := s.Update(ctx, r); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			// a repository was created at path after we checked for it
+			err = ErrRepositoryExists
+		}
+		r.Path, r.Name, r.ParentID = oldPath, oldName, oldParentID
+		return err
+	}
+
+	if includeDescendants {
+		s.invalidate(func(c *RepositoryCache) { c.RemoveDescendants(oldPath) })
+		q := `UPDATE
+				repositories
+			SET
+				(path, updated_at) = ($1 || substr(path, length($2) + 1), now())
+			WHERE
+				top_level_namespace_id = $3
+				AND left(path, length($2) + 1) = $2 || '/'`
+		if _, err := s.db.ExecContext(ctx, q, path, oldPath, r.NamespaceID); err != nil {
+			return fmt.Errorf("renaming repository descendants: %w", err)
+		}
+		return nil
+	}
+
+	var hasChildren bool
+	q := "SELECT EXISTS (SELECT 1 FROM repositories WHERE top_level_namespace_id = $1 AND parent_id = $2)"
+	if err := s.db.QueryRowContext(ctx, q, r.NamespaceID, r.ID).Scan(&hasChildren); err != nil {
+		return fmt.Errorf("finding repository children: %w", err)
+	}
+	if !hasChildren {
+		return nil
+	}
+
+	placeholder := &models.Repository{NamespaceID: r.NamespaceID, Name: oldName, Path: oldPath, ParentID: oldParentID}
+	if err := s.Create(ctx, placeholder); err != nil {
+		return err
+	}
+	q = `UPDATE
+			repositories
+		SET
+			(parent_id, updated_at) = ($1, now())
+		WHERE
+			top_level_namespace_id = $2
+			AND parent_id = $3`
+	if _, err := s.db.ExecContext(ctx, q, placeholder.ID, r.NamespaceID, r.ID); err != nil {
+		return fmt.Errorf("updating repository children: %w", err)
+	}
+
+	return nil
+}
+
 // UntagManifest deletes all tags of a manifest in a repository.
 func (s *repositoryStore) UntagManifest(ctx context.Context, r *models.Repository, m *models.Manifest) error {
 	defer metrics.InstrumentQuery("repository_untag_manifest")()
@@ -983,6 +1519,30 @@ func (s *repositoryStore) DeleteTagByName(ctx context.Context, r *models.Reposit
 	return count == 1, nil
 }
 
+// DeleteTagByNameIfNotWrittenAfter deletes a tag by name within a repository, as long as it was not written to after
+// lastWrite, i.e., it was neither created nor updated since then. A boolean is returned to denote whether the tag was
+// deleted or not. This allows deleting tags based on a previous read without racing with concurrent pushes.
+func (s *repositoryStore) DeleteTagByNameIfNotWrittenAfter(ctx context.Context, r *models.Repository, name string, lastWrite time.Time) (bool, error) {
+	defer metrics.InstrumentQuery("repository_delete_tag_by_name_if_not_written_after")()
+	q := `DELETE FROM tags
+		WHERE top_level_namespace_id = $1
+			AND repository_id = $2
+			AND name = $3
+			AND COALESCE(updated_at, created_at) <= $4`
+
+	res, err := s.db.ExecContext(ctx, q, r.NamespaceID, r.ID, name, lastWrite)
+	if err != nil {
+		return false, fmt.Errorf("deleting tag: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("deleting tag: %w", err)
+	}
+
+	return count == 1, nil
+}
+
 // DeleteManifest deletes a manifest from a repository. A boolean is returned to denote whether the manifest was deleted
 // or not. This avoids the need for a separate preceding `SELECT` to find if it exists. A manifest cannot be deleted if
 // it is referenced by a manifest list.
@@ -1012,23 +1572,23 @@ func (s *repositoryStore) DeleteManifest(ctx context.Context, r *models.Reposito
 	return count == 1, nil
 }
 
-// Delete deletes a repository.
+// Delete deletes a repository. Descendant repositories are deleted along with it.
 func (s *repositoryStore) Delete(ctx context.Context, id int64) error {
 	defer metrics.InstrumentQuery("repository_delete")()
-	q := "DELETE FROM repositories WHERE id = $1"
+	q := "DELETE FROM repositories WHERE id = $1 RETURNING path"
 
-	res, err := s.db.ExecContext(ctx, q, id)
-	if err != nil {
+	var path string
+	if err := s.db.QueryRowContext(ctx, q, id).Scan(&path); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRepositoryNotFound
+		}
 		return fmt.Errorf("deleting repository: %w", err)
 	}
 
-	n, err := res.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("deleting repository: %w", err)
-	}
-	if n == 0 {
-		return fmt.Errorf("repository not found")
-	}
+	s.invalidate(func(c *RepositoryCache) {
+		c.Remove(path)
+		c.RemoveDescendants(path)
+	})
 
 	return nil
 }
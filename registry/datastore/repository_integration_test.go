@@ -298,7 +298,7 @@ func TestRepositoryStore_FindAllPaginated(t *testing.T) {
 
 	for _, test := range tt {
 		t.Run(test.name, func(t *testing.T) {
-			rr, err := s.FindAllPaginated(suite.ctx, test.limit, test.lastPath)
+			rr, err := s.FindAllPaginated(suite.ctx, test.limit, test.lastPath, "", false)
 
 			// reset created_at attributes for reproducible comparisons
 			for _, r := range rr {
@@ -317,11 +317,67 @@ func TestRepositoryStore_FindAllPaginated_NoRepositories(t *testing.T) {
 
 	s := datastore.NewRepositoryStore(suite.db)
 
-	rr, err := s.FindAllPaginated(suite.ctx, 100, "")
+	rr, err := s.FindAllPaginated(suite.ctx, 100, "", "", false)
 	require.NoError(t, err)
 	require.Empty(t, rr)
 }
 
+func TestRepositoryStore_FindAllPaginated_Prefix(t *testing.T) {
+	reloadManifestFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	rr, err := s.FindAllPaginated(suite.ctx, 100, "", "gitlab-org/gitlab-test/f", false)
+	require.NoError(t, err)
+	require.Len(t, rr, 1)
+	require.Equal(t, "gitlab-org/gitlab-test/frontend", rr[0].Path)
+
+	rr, err = s.FindAllPaginated(suite.ctx, 100, "", "gitlab-org/", false)
+	require.NoError(t, err)
+	require.Len(t, rr, 2)
+	require.Equal(t, "gitlab-org/gitlab-test/backend", rr[0].Path)
+	require.Equal(t, "gitlab-org/gitlab-test/frontend", rr[1].Path)
+
+	// wildcards are matched literally
+	rr, err = s.FindAllPaginated(suite.ctx, 100, "", "a_test", false)
+	require.NoError(t, err)
+	require.Empty(t, rr)
+	rr, err = s.FindAllPaginated(suite.ctx, 100, "", "%", false)
+	require.NoError(t, err)
+	require.Empty(t, rr)
+}
+
+func TestRepositoryStore_FindAllDetailPaginated(t *testing.T) {
+	reloadManifestFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	rr, err := s.FindAllDetailPaginated(suite.ctx, 3, "a-test-group/bar", "", false)
+	require.NoError(t, err)
+	require.Len(t, rr, 3)
+
+	// see testdata/fixtures/tags.sql
+	expected := map[string]int{
+		"a-test-group/foo":                0,
+		"gitlab-org/gitlab-test/backend":  4,
+		"gitlab-org/gitlab-test/frontend": 4,
+	}
+	paths := make([]string, 0, len(rr))
+	for _, r := range rr {
+		require.NotZero(t, r.ID)
+		require.NotEmpty(t, r.Name)
+		require.Equal(t, expected[r.Path], r.TagsCount)
+		paths = append(paths, r.Path)
+	}
+	require.Equal(t, []string{"a-test-group/foo", "gitlab-org/gitlab-test/backend", "gitlab-org/gitlab-test/frontend"}, paths)
+
+	rr, err = s.FindAllDetailPaginated(suite.ctx, 100, "", "a-test-group/", false)
+	require.NoError(t, err)
+	require.Len(t, rr, 2)
+	require.Equal(t, "a-test-group/bar", rr[0].Path)
+	require.Zero(t, rr[0].TagsCount)
+}
+
 func TestRepositoryStore_DescendantsOf(t *testing.T) {
 	reloadRepositoryFixtures(t)
 
@@ -373,6 +429,66 @@ func TestRepositoryStore_DescendantsOf_Leaf(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestRepositoryStore_DescendantsOfPaginated(t *testing.T) {
+	reloadManifestFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+	group := &models.Repository{ID: 1, NamespaceID: 1}
+
+	// see testdata/fixtures/[repositories|repository_manifests].sql, only backend and frontend have manifests
+	tt := []struct {
+		name          string
+		limit         int
+		lastPath      string
+		expectedPaths []string
+	}{
+		{
+			name:          "no limit and no last path",
+			limit:         100,
+			expectedPaths: []string{"gitlab-org/gitlab-test/backend", "gitlab-org/gitlab-test/frontend"},
+		},
+		{
+			name:          "limit",
+			limit:         1,
+			expectedPaths: []string{"gitlab-org/gitlab-test/backend"},
+		},
+		{
+			name:          "last path",
+			limit:         100,
+			lastPath:      "gitlab-org/gitlab-test/backend",
+			expectedPaths: []string{"gitlab-org/gitlab-test/frontend"},
+		},
+		{
+			name:     "last path after all",
+			limit:    100,
+			lastPath: "gitlab-org/gitlab-test/frontend",
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			rr, err := s.FindDescendantsOfPaginated(suite.ctx, group, test.limit, test.lastPath, false)
+			require.NoError(t, err)
+
+			var paths []string
+			for _, r := range rr {
+				paths = append(paths, r.Path)
+			}
+			require.Equal(t, test.expectedPaths, paths)
+		})
+	}
+}
+
+func TestRepositoryStore_DescendantsOfPaginated_IncludesSelf(t *testing.T) {
+	reloadManifestFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+	rr, err := s.FindDescendantsOfPaginated(suite.ctx, &models.Repository{ID: 3, NamespaceID: 1}, 100, "", false)
+	require.NoError(t, err)
+	require.Len(t, rr, 1)
+	require.Equal(t, "gitlab-org/gitlab-test/backend", rr[0].Path)
+}
+
 func TestRepositoryStore_DescendantsOf_NotFound(t *testing.T) {
 	s := datastore.NewRepositoryStore(suite.db)
 	rr, err := s.FindDescendantsOf(suite.ctx, 0)
@@ -717,6 +833,102 @@ func TestRepositoryStore_TagsPaginated(t *testing.T) {
 	}
 }
 
+func TestRepositoryStore_TagsDetailPaginated(t *testing.T) {
+	reloadManifestFixtures(t)
+	reloadTagFixtures(t)
+
+	// see testdata/fixtures/tags.sql (sorted):
+	// 1.0.0
+	// rc2
+	// stable-91ac07a9
+	// stable-9ede8db0
+	r := &models.Repository{NamespaceID: 1, ID: 4}
+
+	s := datastore.NewRepositoryStore(suite.db)
+	tt, err := s.TagsDetailPaginated(suite.ctx, r, 2, "")
+	require.NoError(t, err)
+
+	// reset created_at and updated_at attributes for reproducible comparisons
+	for _, tag := range tt {
+		require.False(t, tag.CreatedAt.IsZero())
+		tag.CreatedAt = time.Time{}
+		tag.UpdatedAt = sql.NullTime{}
+	}
+
+	expected := models.TagDetails{
+		{
+			Name:            "1.0.0",
+			Digest:          "sha256:bca3c0bf2ca0cde987ad9cab2dac986047a0ccff282f1b23df282ef05e3a10a6",
+			MediaType:       "application/vnd.docker.distribution.manifest.v2+json",
+			ConfigMediaType: "application/vnd.docker.container.image.v1+json",
+		},
+		{
+			Name:      "rc2",
+			Digest:    "sha256:45e85a20d32f249c323ed4085026b6b0ee264788276aa7c06cf4b5da1669067a",
+			MediaType: "application/vnd.docker.distribution.manifest.list.v2+json",
+		},
+	}
+	require.Equal(t, expected, tt)
+
+	tt, err = s.TagsDetailPaginated(suite.ctx, r, 100, "stable-91ac07a9")
+	require.NoError(t, err)
+	require.Len(t, tt, 1)
+	require.Equal(t, "stable-9ede8db0", tt[0].Name)
+}
+
+func TestRepositoryStore_ManifestsDetailPaginated(t *testing.T) {
+	reloadManifestFixtures(t)
+	reloadTagFixtures(t)
+
+	// see testdata/fixtures/manifests.sql and testdata/fixtures/tags.sql (sorted by digest)
+	r := &models.Repository{NamespaceID: 1, ID: 4}
+
+	s := datastore.NewRepositoryStore(suite.db)
+	mm, err := s.ManifestsDetailPaginated(suite.ctx, r, 3, "", false)
+	require.NoError(t, err)
+
+	// reset created_at and size attributes for reproducible comparisons
+	for _, m := range mm {
+		require.False(t, m.CreatedAt.IsZero())
+		require.Positive(t, m.Size)
+		m.CreatedAt = time.Time{}
+		m.Size = 0
+	}
+
+	expected := models.ManifestDetails{
+		{
+			Digest:    "sha256:45e85a20d32f249c323ed4085026b6b0ee264788276aa7c06cf4b5da1669067a",
+			MediaType: "application/vnd.docker.distribution.manifest.list.v2+json",
+			Tags:      []string{"rc2"},
+		},
+		{
+			Digest:          "sha256:56b4b2228127fd594c5ab2925409713bd015ae9aa27eef2e0ddd90bcb2b1533f",
+			MediaType:       "application/vnd.docker.distribution.manifest.v2+json",
+			ConfigMediaType: "application/vnd.docker.container.image.v1+json",
+			Tags:            []string{},
+		},
+		{
+			Digest:          "sha256:bca3c0bf2ca0cde987ad9cab2dac986047a0ccff282f1b23df282ef05e3a10a6",
+			MediaType:       "application/vnd.docker.distribution.manifest.v2+json",
+			ConfigMediaType: "application/vnd.docker.container.image.v1+json",
+			Tags:            []string{"1.0.0", "stable-9ede8db0"},
+		},
+	}
+	require.Equal(t, expected, mm)
+
+	mm, err = s.ManifestsDetailPaginated(suite.ctx, r, 100, "sha256:bca3c0bf2ca0cde987ad9cab2dac986047a0ccff282f1b23df282ef05e3a10a6", false)
+	require.NoError(t, err)
+	require.Len(t, mm, 1)
+	require.Equal(t, digest.Digest("sha256:ea1650093606d9e76dfc78b986d57daea6108af2d5a9114a98d7198548bfdfc7"), mm[0].Digest)
+	require.Equal(t, []string{"stable-91ac07a9"}, mm[0].Tags)
+
+	mm, err = s.ManifestsDetailPaginated(suite.ctx, r, 100, "", true)
+	require.NoError(t, err)
+	require.Len(t, mm, 1)
+	require.Equal(t, digest.Digest("sha256:56b4b2228127fd594c5ab2925409713bd015ae9aa27eef2e0ddd90bcb2b1533f"), mm[0].Digest)
+	require.Empty(t, mm[0].Tags)
+}
+
 func TestRepositoryStore_TagsCountAfterName(t *testing.T) {
 	reloadTagFixtures(t)
 
@@ -765,6 +977,46 @@ func TestRepositoryStore_TagsCountAfterName(t *testing.T) {
 	}
 }
 
+func TestRepositoryStore_TagsCount(t *testing.T) {
+	reloadTagFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+	count, err := s.TagsCount(suite.ctx, &models.Repository{NamespaceID: 1, ID: 3})
+	require.NoError(t, err)
+
+	// see testdata/fixtures/tags.sql
+	require.Equal(t, 4, count)
+}
+
+func TestRepositoryStore_TagsCount_None(t *testing.T) {
+	reloadTagFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+	count, err := s.TagsCount(suite.ctx, &models.Repository{NamespaceID: 2, ID: 6})
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+func TestRepositoryStore_ManifestsCount(t *testing.T) {
+	reloadManifestFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+	count, err := s.ManifestsCount(suite.ctx, &models.Repository{NamespaceID: 1, ID: 3})
+	require.NoError(t, err)
+
+	// see testdata/fixtures/manifests.sql
+	require.Equal(t, 3, count)
+}
+
+func TestRepositoryStore_ManifestsCount_None(t *testing.T) {
+	reloadManifestFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+	count, err := s.ManifestsCount(suite.ctx, &models.Repository{NamespaceID: 1, ID: 1})
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
 func TestRepositoryStore_ManifestTags(t *testing.T) {
 	reloadTagFixtures(t)
 
@@ -846,7 +1098,7 @@ func TestRepositoryStore_CountAfterPath(t *testing.T) {
 
 	for _, test := range tt {
 		t.Run(test.name, func(t *testing.T) {
-			c, err := s.CountAfterPath(suite.ctx, test.path)
+			c, err := s.CountAfterPath(suite.ctx, test.path, "", false)
 			require.NoError(t, err)
 			require.Equal(t, test.expectedNumRepos, c)
 		})
@@ -858,11 +1110,29 @@ func TestRepositoryStore_CountAfterPath_NoRepositories(t *testing.T) {
 
 	s := datastore.NewRepositoryStore(suite.db)
 
-	c, err := s.CountAfterPath(suite.ctx, "")
+	c, err := s.CountAfterPath(suite.ctx, "", "", false)
 	require.NoError(t, err)
 	require.Equal(t, 0, c)
 }
 
+func TestRepositoryStore_CountAfterPath_Prefix(t *testing.T) {
+	reloadManifestFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	c, err := s.CountAfterPath(suite.ctx, "", "gitlab-org/", false)
+	require.NoError(t, err)
+	require.Equal(t, 2, c)
+
+	c, err = s.CountAfterPath(suite.ctx, "gitlab-org/gitlab-test/backend", "gitlab-org/", false)
+	require.NoError(t, err)
+	require.Equal(t, 1, c)
+
+	c, err = s.CountAfterPath(suite.ctx, "", "gitlab-org/gitlab-test/backend/", false)
+	require.NoError(t, err)
+	require.Zero(t, c)
+}
+
 func TestRepositoryStore_FindManifestByDigest(t *testing.T) {
 	reloadManifestFixtures(t)
 
@@ -891,6 +1161,32 @@ func TestRepositoryStore_FindManifestByDigest(t *testing.T) {
 	require.Equal(t, expected, m)
 }
 
+func TestRepositoryStore_FindManifestByID(t *testing.T) {
+	reloadManifestFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	m, err := s.FindManifestByID(suite.ctx, &models.Repository{NamespaceID: 1, ID: 3}, 2)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+
+	// see testdata/fixtures/repository_manifests.sql
+	require.Equal(t, int64(2), m.ID)
+	require.Equal(t, int64(3), m.RepositoryID)
+	require.Equal(t, digest.Digest("sha256:56b4b2228127fd594c5ab2925409713bd015ae9aa27eef2e0ddd90bcb2b1533f"), m.Digest)
+}
+
+func TestRepositoryStore_FindManifestByID_NotFound(t *testing.T) {
+	reloadManifestFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	// manifest 2 exists, but not in repository 4
+	m, err := s.FindManifestByID(suite.ctx, &models.Repository{NamespaceID: 1, ID: 4}, 2)
+	require.NoError(t, err)
+	require.Nil(t, m)
+}
+
 func TestRepositoryStore_FindManifestByTagName(t *testing.T) {
 	reloadManifestFixtures(t)
 
@@ -1096,6 +1392,43 @@ func TestRepositoryStore_ExistsBlobByDigest_NotFound(t *testing.T) {
 	require.False(t, exists)
 }
 
+func TestRepositoryStore_FindBlobs(t *testing.T) {
+	reloadBlobFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+	r, err := s.FindByID(suite.ctx, 3)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+
+	// see testdata/fixtures/repository_blobs.sql
+	bb, err := s.FindBlobs(suite.ctx, r, []digest.Digest{
+		"sha256:c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9",
+		"sha256:6b0937e234ce911b75630b744fb12836fe01bda5f7db203927edbb1390bc7e21",
+		// linked to another repository only
+		"sha256:68ced04f60ab5c7a5f1d0b0b4e7572c5a4c8cce44866513d30d9df1a15277d6b",
+		// unknown
+		"sha256:d9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9",
+	})
+	require.NoError(t, err)
+
+	dgsts := make([]digest.Digest, 0, len(bb))
+	for _, b := range bb {
+		dgsts = append(dgsts, b.Digest)
+	}
+	require.ElementsMatch(t, []digest.Digest{
+		"sha256:c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9",
+		"sha256:6b0937e234ce911b75630b744fb12836fe01bda5f7db203927edbb1390bc7e21",
+	}, dgsts)
+}
+
+func TestRepositoryStore_FindBlobs_Empty(t *testing.T) {
+	s := datastore.NewRepositoryStore(suite.db)
+
+	bb, err := s.FindBlobs(suite.ctx, &models.Repository{ID: 1, NamespaceID: 1}, nil)
+	require.NoError(t, err)
+	require.Empty(t, bb)
+}
+
 func TestRepositoryBlobService_Stat(t *testing.T) {
 	reloadBlobFixtures(t)
 
@@ -1542,7 +1875,157 @@ func TestRepositoryStore_Update_NotFound(t *testing.T) {
 		Name: "bar",
 	}
 	err := s.Update(suite.ctx, update)
-	require.EqualError(t, err, "repository not found")
+	require.ErrorIs(t, err, datastore.ErrRepositoryNotFound)
+}
+
+func TestRepositoryStore_SetArchived(t *testing.T) {
+	reloadManifestFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	// see testdata/fixtures/repositories.sql
+	r, err := s.FindByPath(suite.ctx, "gitlab-org/gitlab-test/backend")
+	require.NoError(t, err)
+	require.False(t, r.Archived)
+
+	err = s.SetArchived(suite.ctx, r, true)
+	require.NoError(t, err)
+	require.True(t, r.Archived)
+	require.True(t, r.UpdatedAt.Valid)
+
+	r, err = s.FindByID(suite.ctx, r.ID)
+	require.NoError(t, err)
+	require.True(t, r.Archived)
+
+	// archived repositories are only listed in the catalog if requested
+	rr, err := s.FindAllPaginated(suite.ctx, 100, "", "", false)
+	require.NoError(t, err)
+	for _, repo := range rr {
+		require.NotEqual(t, r.Path, repo.Path)
+	}
+	c, err := s.CountAfterPath(suite.ctx, "", "", false)
+	require.NoError(t, err)
+	require.Equal(t, len(rr), c)
+
+	rr, err = s.FindAllPaginated(suite.ctx, 100, "", "", true)
+	require.NoError(t, err)
+	var found bool
+	for _, repo := range rr {
+		found = found || repo.Path == r.Path
+	}
+	require.True(t, found)
+	c, err = s.CountAfterPath(suite.ctx, "", "", true)
+	require.NoError(t, err)
+	require.Equal(t, len(rr), c)
+
+	err = s.SetArchived(suite.ctx, r, false)
+	require.NoError(t, err)
+	require.False(t, r.Archived)
+}
+
+func TestRepositoryStore_SetArchived_NotFound(t *testing.T) {
+	s := datastore.NewRepositoryStore(suite.db)
+
+	r := &models.Repository{NamespaceID: 1, ID: 100}
+	err := s.SetArchived(suite.ctx, r, true)
+	require.ErrorIs(t, err, datastore.ErrRepositoryNotFound)
+}
+
+func repositoryPaths(rr models.Repositories) []string {
+	paths := make([]string, 0, len(rr))
+	for _, r := range rr {
+		paths = append(paths, r.Path)
+	}
+	return paths
+}
+
+func TestRepositoryStore_Rename(t *testing.T) {
+	reloadRepositoryFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	// see testdata/fixtures/repositories.sql
+	r, err := s.FindByPath(suite.ctx, "gitlab-org/gitlab-test")
+	require.NoError(t, err)
+
+	err = s.Rename(suite.ctx, r, "gitlab-org/other/gitlab-test-renamed", true)
+	require.NoError(t, err)
+	require.Equal(t, "gitlab-org/other/gitlab-test-renamed", r.Path)
+	require.Equal(t, "gitlab-test-renamed", r.Name)
+
+	parent, err := s.FindByPath(suite.ctx, "gitlab-org/other")
+	require.NoError(t, err)
+	require.NotNil(t, parent)
+	require.Equal(t, sql.NullInt64{Int64: 1, Valid: true}, parent.ParentID)
+	require.Equal(t, sql.NullInt64{Int64: parent.ID, Valid: true}, r.ParentID)
+
+	old, err := s.FindByPath(suite.ctx, "gitlab-org/gitlab-test")
+	require.NoError(t, err)
+	require.Nil(t, old)
+
+	rr, err := s.FindDescendantsOf(suite.ctx, r.ID)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{
+		"gitlab-org/other/gitlab-test-renamed/backend",
+		"gitlab-org/other/gitlab-test-renamed/frontend",
+	}, repositoryPaths(rr))
+}
+
+func TestRepositoryStore_Rename_WithoutDescendants(t *testing.T) {
+	reloadRepositoryFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	// see testdata/fixtures/repositories.sql
+	r, err := s.FindByPath(suite.ctx, "gitlab-org/gitlab-test")
+	require.NoError(t, err)
+
+	err = s.Rename(suite.ctx, r, "gitlab-org/gitlab-test-renamed", false)
+	require.NoError(t, err)
+
+	rr, err := s.FindDescendantsOf(suite.ctx, r.ID)
+	require.NoError(t, err)
+	require.Empty(t, rr)
+
+	// the children are left behind, under a new repository created in place of the renamed one
+	old, err := s.FindByPath(suite.ctx, "gitlab-org/gitlab-test")
+	require.NoError(t, err)
+	require.NotNil(t, old)
+	require.NotEqual(t, r.ID, old.ID)
+	require.Equal(t, sql.NullInt64{Int64: 1, Valid: true}, old.ParentID)
+
+	rr, err = s.FindDescendantsOf(suite.ctx, old.ID)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"gitlab-org/gitlab-test/backend", "gitlab-org/gitlab-test/frontend"}, repositoryPaths(rr))
+}
+
+func TestRepositoryStore_Rename_Invalid(t *testing.T) {
+	reloadRepositoryFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	// see testdata/fixtures/repositories.sql
+	r, err := s.FindByPath(suite.ctx, "gitlab-org/gitlab-test/backend")
+	require.NoError(t, err)
+
+	tt := []struct {
+		name    string
+		path    string
+		wantErr error
+	}{
+		{name: "destination exists", path: "gitlab-org/gitlab-test/frontend", wantErr: datastore.ErrRepositoryExists},
+		{name: "different namespace", path: "a-test-group/backend", wantErr: datastore.ErrRepositoryRenameNamespace},
+		{name: "same path", path: "gitlab-org/gitlab-test/backend", wantErr: datastore.ErrRepositoryRenameDescendant},
+		{name: "descendant path", path: "gitlab-org/gitlab-test/backend/foo", wantErr: datastore.ErrRepositoryRenameDescendant},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			err := s.Rename(suite.ctx, r, test.path, true)
+			require.ErrorIs(t, err, test.wantErr)
+			require.Equal(t, "gitlab-org/gitlab-test/backend", r.Path)
+		})
+	}
 }
 
 func TestRepositoryStore_UntagManifest(t *testing.T) {
@@ -1668,6 +2151,34 @@ func TestRepositoryStore_DeleteTagByName_NotFoundDoesNotFail(t *testing.T) {
 	require.False(t, found)
 }
 
+func TestRepositoryStore_DeleteTagByNameIfNotWrittenAfter(t *testing.T) {
+	reloadTagFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	// see testdata/fixtures/tags.sql
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+	name := "latest"
+	updatedAt := testutil.ParseTimestamp(t, "2020-03-02 17:57:53.029514", time.UTC)
+
+	// the tag was updated after the given time
+	found, err := s.DeleteTagByNameIfNotWrittenAfter(suite.ctx, r, name, updatedAt.Add(-time.Second))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	tag, err := s.FindTagByName(suite.ctx, r, name)
+	require.NoError(t, err)
+	require.NotNil(t, tag)
+
+	found, err = s.DeleteTagByNameIfNotWrittenAfter(suite.ctx, r, name, updatedAt)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	tag, err = s.FindTagByName(suite.ctx, r, name)
+	require.NoError(t, err)
+	require.Nil(t, tag)
+}
+
 func TestRepositoryStore_DeleteManifest(t *testing.T) {
 	reloadManifestFixtures(t)
 
@@ -1732,5 +2243,103 @@ func TestRepositoryStore_Delete(t *testing.T) {
 func TestRepositoryStore_Delete_NotFound(t *testing.T) {
 	s := datastore.NewRepositoryStore(suite.db)
 	err := s.Delete(suite.ctx, 100)
-	require.EqualError(t, err, "repository not found")
+	require.ErrorIs(t, err, datastore.ErrRepositoryNotFound)
+}
+
+func newRepositoryCacheTestDB(t *testing.T) (*datastore.DB, *datastore.RepositoryCache) {
+	t.Helper()
+
+	c := datastore.NewRepositoryCache(0, time.Hour)
+	db, err := testutil.NewDBFromEnv(datastore.WithRepositoryCache(c))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return db, c
+}
+
+func TestRepositoryStore_FindByPath_Cached(t *testing.T) {
+	reloadRepositoryFixtures(t)
+	db, c := newRepositoryCacheTestDB(t)
+
+	s := datastore.NewRepositoryStore(db)
+	r, err := s.FindByPath(suite.ctx, "gitlab-org/gitlab-test")
+	require.NoError(t, err)
+	require.Equal(t, 1, c.Len())
+
+	cached, ok := c.Get("gitlab-org/gitlab-test")
+	require.True(t, ok)
+	require.Equal(t, r, cached)
+
+	// repositories that do not exist are not cached
+	r, err = s.FindByPath(suite.ctx, "gitlab-org/bar")
+	require.NoError(t, err)
+	require.Nil(t, r)
+	require.Equal(t, 1, c.Len())
+}
+
+func TestRepositoryStore_SetArchived_InvalidatesCache(t *testing.T) {
+	reloadRepositoryFixtures(t)
+	db, c := newRepositoryCacheTestDB(t)
+
+	s := datastore.NewRepositoryStore(db)
+	r, err := s.FindByPath(suite.ctx, "gitlab-org/gitlab-test/backend")
+	require.NoError(t, err)
+	require.False(t, r.Archived)
+
+	require.NoError(t, s.SetArchived(suite.ctx, r, true))
+	require.Zero(t, c.Len())
+
+	r, err = s.FindByPath(suite.ctx, "gitlab-org/gitlab-test/backend")
+	require.NoError(t, err)
+	require.True(t, r.Archived)
+}
+
+func TestRepositoryStore_Rename_InvalidatesCache(t *testing.T) {
+	reloadRepositoryFixtures(t)
+	db, c := newRepositoryCacheTestDB(t)
+
+	s := datastore.NewRepositoryStore(db)
+	r, err := s.FindByPath(suite.ctx, "gitlab-org/gitlab-test")
+	require.NoError(t, err)
+	_, err = s.FindByPath(suite.ctx, "gitlab-org/gitlab-test/backend")
+	require.NoError(t, err)
+	require.Equal(t, 2, c.Len())
+
+	tx, err := db.BeginTx(suite.ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	require.NoError(t, datastore.NewRepositoryStore(tx).Rename(suite.ctx, r, "gitlab-org/renamed", true))
+	require.Zero(t, c.Len())
+
+	// lookups made by other stores before the transaction is committed repopulate the cache with the previous state
+	_, err = s.FindByPath(suite.ctx, "gitlab-org/gitlab-test/backend")
+	require.NoError(t, err)
+	require.Equal(t, 1, c.Len())
+
+	require.NoError(t, tx.Commit())
+	require.Zero(t, c.Len())
+
+	r, err = s.FindByPath(suite.ctx, "gitlab-org/gitlab-test/backend")
+	require.NoError(t, err)
+	require.Nil(t, r)
+	r, err = s.FindByPath(suite.ctx, "gitlab-org/renamed/backend")
+	require.NoError(t, err)
+	require.NotNil(t, r)
+}
+
+func TestRepositoryStore_Delete_InvalidatesCache(t *testing.T) {
+	reloadRepositoryFixtures(t)
+	db, c := newRepositoryCacheTestDB(t)
+
+	s := datastore.NewRepositoryStore(db)
+	r, err := s.FindByPath(suite.ctx, "gitlab-org/gitlab-test")
+	require.NoError(t, err)
+	_, err = s.FindByPath(suite.ctx, "gitlab-org/gitlab-test/backend")
+	require.NoError(t, err)
+	require.Equal(t, 2, c.Len())
+
+	// descendants are deleted along with the repository
+	require.NoError(t, s.Delete(suite.ctx, r.ID))
+	require.Zero(t, c.Len())
 }
@@ -0,0 +1,172 @@
+package datastore
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+const (
+	// DefaultRepositoryCacheMaxEntries is the default maximum number of repositories held by a RepositoryCache.
+	DefaultRepositoryCacheMaxEntries = 10000
+	// DefaultRepositoryCacheTTL is the default amount of time for which a cached repository is trusted without
+	// checking the database again.
+	DefaultRepositoryCacheTTL = 30 * time.Second
+)
+
+// RepositoryCache is an in-process, size-bounded LRU cache of repositories keyed by path, used to avoid looking up the
+// same repositories in the database on every request.
+//
+// Repositories are mutable, so cached entries are invalidated whenever a repository is updated, renamed or deleted
+// through a repository store with access to the cache. Changes made by other registry instances are not visible until
+// the cached entries expire after the configured TTL.
+type RepositoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	ll         *list.List
+	items      map[string]*list.Element
+	ids        map[int64]string
+	now        func() time.Time // for test purposes only
+}
+
+type repositoryCacheEntry struct {
+	repository *models.Repository
+	addedAt    time.Time
+}
+
+// NewRepositoryCache creates a new RepositoryCache holding up to maxEntries repositories, which expire after ttl. If
+// maxEntries or ttl are not positive, DefaultRepositoryCacheMaxEntries and DefaultRepositoryCacheTTL are used,
+// respectively.
+func NewRepositoryCache(maxEntries int, ttl time.Duration) *RepositoryCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultRepositoryCacheMaxEntries
+	}
+	if ttl <= 0 {
+		ttl = DefaultRepositoryCacheTTL
+	}
+
+	return &RepositoryCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		ids:        make(map[int64]string),
+		now:        time.Now,
+	}
+}
+
+// Get returns a copy of the cached repository with the given path, as long as it has not expired.
+func (c *RepositoryCache) Get(path string) (*models.Repository, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[path]
+	if !ok {
+		metrics.RepositoryCacheMiss()
+		return nil, false
+	}
+
+	entry := el.Value.(*repositoryCacheEntry)
+	if c.now().Sub(entry.addedAt) > c.ttl {
+		c.removeElement(el)
+		metrics.RepositoryCacheMiss()
+		metrics.RepositoryCacheSize(c.ll.Len())
+		return nil, false
+	}
+
+	c.ll.MoveToFront(el)
+	metrics.RepositoryCacheHit()
+
+	r := *entry.repository
+	return &r, true
+}
+
+// Add caches a copy of repository r. Least recently used entries are evicted as needed to stay within the maximum
+// number of entries.
+func (c *RepositoryCache) Add(r *models.Repository) {
+	if r == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// a repository may have been renamed since it was cached under a different path
+	if path, ok := c.ids[r.ID]; ok && path != r.Path {
+		c.removeElement(c.items[path])
+	}
+
+	cp := *r
+	entry := &repositoryCacheEntry{repository: &cp, addedAt: c.now()}
+	if el, ok := c.items[r.Path]; ok {
+		delete(c.ids, el.Value.(*repositoryCacheEntry).repository.ID)
+		el.Value = entry
+		c.ll.MoveToFront(el)
+	} else {
+		c.items[r.Path] = c.ll.PushFront(entry)
+	}
+	c.ids[r.ID] = r.Path
+
+	for c.ll.Len() > c.maxEntries {
+		c.removeElement(c.ll.Back())
+		metrics.RepositoryCacheEviction()
+	}
+
+	metrics.RepositoryCacheSize(c.ll.Len())
+}
+
+// Remove drops the cached repository with the given path, if any.
+func (c *RepositoryCache) Remove(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[path]; ok {
+		c.removeElement(el)
+		metrics.RepositoryCacheSize(c.ll.Len())
+	}
+}
+
+// RemoveByID drops the cached repository with the given ID, if any.
+func (c *RepositoryCache) RemoveByID(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if path, ok := c.ids[id]; ok {
+		c.removeElement(c.items[path])
+		metrics.RepositoryCacheSize(c.ll.Len())
+	}
+}
+
+// RemoveDescendants drops all cached repositories under the given path, excluding the repository at path itself.
+func (c *RepositoryCache) RemoveDescendants(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := path + "/"
+	for p, el := range c.items {
+		if strings.HasPrefix(p, prefix) {
+			c.removeElement(el)
+		}
+	}
+
+	metrics.RepositoryCacheSize(c.ll.Len())
+}
+
+// Len returns the number of repositories in the cache.
+func (c *RepositoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ll.Len()
+}
+
+func (c *RepositoryCache) removeElement(el *list.Element) {
+	entry := c.ll.Remove(el).(*repositoryCacheEntry)
+	delete(c.items, entry.repository.Path)
+	delete(c.ids, entry.repository.ID)
+}
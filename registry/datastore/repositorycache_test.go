@@ -0,0 +1,122 @@
+package datastore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/stretchr/testify/require"
+)
+
+func newCacheTestRepository(id int64, path string) *models.Repository {
+	return &models.Repository{
+		ID:          id,
+		NamespaceID: 1,
+		Name:        path[len(path)-1:],
+		Path:        path,
+	}
+}
+
+func TestRepositoryCache_GetAdd(t *testing.T) {
+	c := datastore.NewRepositoryCache(0, 0)
+	r := newCacheTestRepository(1, "a/b")
+
+	_, ok := c.Get("a/b")
+	require.False(t, ok)
+
+	c.Add(r)
+	require.Equal(t, 1, c.Len())
+
+	got, ok := c.Get("a/b")
+	require.True(t, ok)
+	require.Equal(t, r, got)
+
+	// the returned repository is a copy, so changes made by callers do not affect the cache
+	got.Archived = true
+	got, ok = c.Get("a/b")
+	require.True(t, ok)
+	require.False(t, got.Archived)
+}
+
+func TestRepositoryCache_Get_Expired(t *testing.T) {
+	c := datastore.NewRepositoryCache(0, time.Millisecond)
+
+	c.Add(newCacheTestRepository(1, "a/b"))
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := c.Get("a/b")
+	require.False(t, ok)
+	require.Zero(t, c.Len())
+}
+
+func TestRepositoryCache_Add_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := datastore.NewRepositoryCache(2, 0)
+
+	c.Add(newCacheTestRepository(1, "a/b"))
+	c.Add(newCacheTestRepository(2, "a/c"))
+
+	// use a/b so that a/c becomes the least recently used
+	_, ok := c.Get("a/b")
+	require.True(t, ok)
+
+	c.Add(newCacheTestRepository(3, "a/d"))
+	require.Equal(t, 2, c.Len())
+
+	_, ok = c.Get("a/b")
+	require.True(t, ok)
+	_, ok = c.Get("a/c")
+	require.False(t, ok)
+	_, ok = c.Get("a/d")
+	require.True(t, ok)
+}
+
+func TestRepositoryCache_Add_Renamed(t *testing.T) {
+	c := datastore.NewRepositoryCache(0, 0)
+
+	c.Add(newCacheTestRepository(1, "a/b"))
+	c.Add(newCacheTestRepository(1, "a/c"))
+	require.Equal(t, 1, c.Len())
+
+	_, ok := c.Get("a/b")
+	require.False(t, ok)
+	_, ok = c.Get("a/c")
+	require.True(t, ok)
+}
+
+func TestRepositoryCache_Remove(t *testing.T) {
+	c := datastore.NewRepositoryCache(0, 0)
+
+	c.Add(newCacheTestRepository(1, "a/b"))
+	c.Add(newCacheTestRepository(2, "a/c"))
+
+	c.Remove("a/b")
+	_, ok := c.Get("a/b")
+	require.False(t, ok)
+	_, ok = c.Get("a/c")
+	require.True(t, ok)
+
+	c.RemoveByID(2)
+	require.Zero(t, c.Len())
+
+	// removing unknown entries is a no-op
+	c.Remove("a/b")
+	c.RemoveByID(2)
+}
+
+func TestRepositoryCache_RemoveDescendants(t *testing.T) {
+	c := datastore.NewRepositoryCache(0, 0)
+
+	c.Add(newCacheTestRepository(1, "a/b"))
+	c.Add(newCacheTestRepository(2, "a/b/c"))
+	c.Add(newCacheTestRepository(3, "a/b/c/d"))
+	c.Add(newCacheTestRepository(4, "a/bc"))
+
+	c.RemoveDescendants("a/b")
+	require.Equal(t, 2, c.Len())
+
+	_, ok := c.Get("a/b")
+	require.True(t, ok)
+	_, ok = c.Get("a/bc")
+	require.True(t, ok)
+}
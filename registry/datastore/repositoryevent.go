@@ -0,0 +1,114 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// RepositoryEventReader is the interface that defines read operations for a repository event store.
+type RepositoryEventReader interface {
+	FindByRepositoryPaginated(ctx context.Context, r *models.Repository, since time.Time, limit int, lastID int64) (models.RepositoryEvents, error)
+}
+
+// RepositoryEventWriter is the interface that defines write operations for a repository event store.
+type RepositoryEventWriter interface {
+	Create(ctx context.Context, e *models.RepositoryEvent) error
+}
+
+// RepositoryEventStore is the interface that a repository event store should conform to.
+type RepositoryEventStore interface {
+	RepositoryEventReader
+	RepositoryEventWriter
+}
+
+// repositoryEventStore is the concrete implementation of a RepositoryEventStore.
+type repositoryEventStore struct {
+	// db can be either a *sql.DB or *sql.Tx
+	db Queryer
+}
+
+// NewRepositoryEventStore builds a new repositoryEventStore.
+func NewRepositoryEventStore(db Queryer) *repositoryEventStore {
+	return &repositoryEventStore{db: db}
+}
+
+func scanFullRepositoryEvents(rows *sql.Rows) (models.RepositoryEvents, error) {
+	ee := make(models.RepositoryEvents, 0)
+	defer rows.Close()
+
+	for rows.Next() {
+		e := new(models.RepositoryEvent)
+		if err := rows.Scan(&e.ID, &e.NamespaceID, &e.RepositoryID, &e.Action, &e.Actor, &e.Digest, &e.Tag, &e.PreviousDigest, &e.UploadUUID, &e.RequestID, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning repository event: %w", err)
+		}
+		ee = append(ee, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning repository events: %w", err)
+	}
+
+	return ee, nil
+}
+
+// FindByRepositoryPaginated finds up to limit events of a given repository, created at or after since, ordered by ID.
+// Pagination is done with a marker (lastID), only events with an ID greater than lastID are returned.
+func (s *repositoryEventStore) FindByRepositoryPaginated(ctx context.Context, r *models.Repository, since time.Time, limit int, lastID int64) (models.RepositoryEvents, error) {
+	defer metrics.InstrumentQuery("repository_event_find_by_repository_paginated")()
+	q := `SELECT
+			id,
+			top_level_namespace_id,
+			repository_id,
+			action,
+			COALESCE(actor, ''),
+			COALESCE(digest, ''),
+			COALESCE(tag, ''),
+			COALESCE(previous_digest, ''),
+			COALESCE(upload_uuid, ''),
+			COALESCE(request_id, ''),
+			created_at
+		FROM
+			repository_events
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2
+			AND created_at >= $3
+			AND id > $4
+		ORDER BY
+			id
+		LIMIT $5`
+	rows, err := s.db.QueryContext(ctx, q, r.NamespaceID, r.ID, since, lastID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("finding repository events with pagination: %w", err)
+	}
+
+	return scanFullRepositoryEvents(rows)
+}
+
+// Create saves a new repository event. If e.CreatedAt is not set, the current time is used.
+func (s *repositoryEventStore) Create(ctx context.Context, e *models.RepositoryEvent) error {
+	defer metrics.InstrumentQuery("repository_event_create")()
+	q := `INSERT INTO repository_events (top_level_namespace_id, repository_id, action, actor, digest, tag, previous_digest,
+			upload_uuid, request_id, created_at)
+			VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''),
+				NULLIF($9, ''), COALESCE($10, now()))
+		RETURNING
+			id, created_at`
+
+	var createdAt sql.NullTime
+	if !e.CreatedAt.IsZero() {
+		createdAt = sql.NullTime{Time: e.CreatedAt, Valid: true}
+	}
+
+	row := s.db.QueryRowContext(ctx, q, e.NamespaceID, e.RepositoryID, e.Action, e.Actor, e.Digest.String(), e.Tag,
+		e.PreviousDigest.String(), e.UploadUUID, e.RequestID, createdAt)
+	if err := row.Scan(&e.ID, &e.CreatedAt); err != nil {
+		return fmt.Errorf("creating repository event: %w", err)
+	}
+
+	return nil
+}
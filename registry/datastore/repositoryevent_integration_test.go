@@ -0,0 +1,120 @@
+// +build integration
+
+package datastore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func reloadRepositoryEventFixtures(tb testing.TB) {
+	testutil.ReloadFixtures(
+		tb, suite.db, suite.basePath,
+		// A RepositoryEvent has a foreign key for a Repository, which in turn references a Namespace (insert order matters)
+		testutil.NamespacesTable, testutil.RepositoriesTable, testutil.RepositoryEventsTable,
+	)
+}
+
+func TestRepositoryEventStore_ImplementsReaderAndWriter(t *testing.T) {
+	require.Implements(t, (*datastore.RepositoryEventStore)(nil), datastore.NewRepositoryEventStore(suite.db))
+}
+
+func TestRepositoryEventStore_FindByRepositoryPaginated(t *testing.T) {
+	reloadRepositoryEventFixtures(t)
+
+	s := datastore.NewRepositoryEventStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	ee, err := s.FindByRepositoryPaginated(suite.ctx, r, time.Time{}, 100, 0)
+	require.NoError(t, err)
+
+	// see testdata/fixtures/repository_events.sql
+	require.Len(t, ee, 4)
+	require.Equal(t, &models.RepositoryEvent{
+		ID:             3,
+		NamespaceID:    1,
+		RepositoryID:   3,
+		Action:         "tag_overwrite",
+		Actor:          "jdoe",
+		Digest:         "sha256:56b4b2228127fd594c5ab2925409713bd015ae9aa27eef2e0ddd90bcb2b1533f",
+		Tag:            "1.0.0",
+		PreviousDigest: "sha256:bd165db4bd480656a539e8e00db265377d162d6b98eebbfe5805d0fbd5144155",
+		RequestID:      "req-2",
+		CreatedAt:      testutil.ParseTimestamp(t, "2020-03-02 17:58:43.283783", ee[2].CreatedAt.Location()),
+	}, ee[2])
+	require.Empty(t, ee[3].Digest)
+}
+
+func TestRepositoryEventStore_FindByRepositoryPaginated_Since(t *testing.T) {
+	reloadRepositoryEventFixtures(t)
+
+	s := datastore.NewRepositoryEventStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+	since := testutil.ParseTimestamp(t, "2020-03-02 17:58:00.000000", time.UTC)
+
+	ee, err := s.FindByRepositoryPaginated(suite.ctx, r, since, 100, 0)
+	require.NoError(t, err)
+	require.Len(t, ee, 2)
+	require.Equal(t, int64(3), ee[0].ID)
+	require.Equal(t, int64(5), ee[1].ID)
+}
+
+func TestRepositoryEventStore_FindByRepositoryPaginated_Marker(t *testing.T) {
+	reloadRepositoryEventFixtures(t)
+
+	s := datastore.NewRepositoryEventStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	ee, err := s.FindByRepositoryPaginated(suite.ctx, r, time.Time{}, 2, 0)
+	require.NoError(t, err)
+	require.Len(t, ee, 2)
+	require.Equal(t, int64(1), ee[0].ID)
+	require.Equal(t, int64(2), ee[1].ID)
+
+	ee, err = s.FindByRepositoryPaginated(suite.ctx, r, time.Time{}, 2, ee[1].ID)
+	require.NoError(t, err)
+	require.Len(t, ee, 2)
+	require.Equal(t, int64(3), ee[0].ID)
+	require.Equal(t, int64(5), ee[1].ID)
+}
+
+func TestRepositoryEventStore_FindByRepositoryPaginated_NotFound(t *testing.T) {
+	reloadRepositoryEventFixtures(t)
+
+	s := datastore.NewRepositoryEventStore(suite.db)
+	r := &models.Repository{NamespaceID: 2, ID: 6}
+
+	ee, err := s.FindByRepositoryPaginated(suite.ctx, r, time.Time{}, 100, 0)
+	require.NoError(t, err)
+	require.Empty(t, ee)
+}
+
+func TestRepositoryEventStore_Create(t *testing.T) {
+	reloadRepositoryEventFixtures(t)
+
+	s := datastore.NewRepositoryEventStore(suite.db)
+	e := &models.RepositoryEvent{
+		NamespaceID:  2,
+		RepositoryID: 6,
+		Action:       "manifest_delete",
+		Actor:        "root",
+		Digest:       digest.FromString("foo"),
+	}
+	require.NoError(t, s.Create(suite.ctx, e))
+
+	require.NotEmpty(t, e.ID)
+	require.NotEmpty(t, e.CreatedAt)
+
+	ee, err := s.FindByRepositoryPaginated(suite.ctx, &models.Repository{NamespaceID: 2, ID: 6}, time.Time{}, 100, 0)
+	require.NoError(t, err)
+	require.Len(t, ee, 1)
+	require.Equal(t, e.ID, ee[0].ID)
+	require.Equal(t, e.Digest, ee[0].Digest)
+	require.Empty(t, ee[0].Tag)
+}
@@ -0,0 +1,132 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// RepositoryStatisticsStore is the interface that a repository statistics store should conform to.
+type RepositoryStatisticsStore interface {
+	FindByRepository(ctx context.Context, r *models.Repository) (*models.RepositoryStatistics, error)
+	Recalculate(ctx context.Context, r *models.Repository) (*models.RepositoryStatistics, error)
+}
+
+type repositoryStatisticsStore struct {
+	db Queryer
+}
+
+// NewRepositoryStatisticsStore builds a new repositoryStatisticsStore.
+func NewRepositoryStatisticsStore(db Queryer) RepositoryStatisticsStore {
+	return &repositoryStatisticsStore{db: db}
+}
+
+// FindByRepository finds the statistics of a repository, as of the last time they were calculated. No error is
+// returned if they were never calculated, `nil` statistics are returned in this situation.
+func (s *repositoryStatisticsStore) FindByRepository(ctx context.Context, r *models.Repository) (*models.RepositoryStatistics, error) {
+	defer metrics.InstrumentQuery("repository_statistics_find_by_repository")()
+
+	q := `SELECT
+			tags_count,
+			manifests_count,
+			size,
+			last_published_at,
+			calculated_at
+		FROM
+			repository_statistics
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2`
+
+	st := &models.RepositoryStatistics{NamespaceID: r.NamespaceID, RepositoryID: r.ID}
+	row := s.db.QueryRowContext(ctx, q, r.NamespaceID, r.ID)
+	if err := row.Scan(&st.TagsCount, &st.ManifestsCount, &st.Size, &st.LastPublishedAt, &st.CalculatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("finding repository statistics: %w", err)
+	}
+
+	return st, nil
+}
+
+// Recalculate calculates the statistics of a repository from the tags, manifests and blobs it holds, replacing the
+// stored ones, if any. The size of a repository is the sum of the size of the blobs linked to it, so shared blobs are
+// accounted for in every repository they are linked to.
+func (s *repositoryStatisticsStore) Recalculate(ctx context.Context, r *models.Repository) (*models.RepositoryStatistics, error) {
+	defer metrics.InstrumentQuery("repository_statistics_recalculate")()
+
+	q := `INSERT INTO repository_statistics (top_level_namespace_id, repository_id, tags_count, manifests_count, size,
+			last_published_at, calculated_at)
+		SELECT
+			$1,
+			$2,
+			(
+				SELECT
+					count(*)
+				FROM
+					tags
+				WHERE
+					top_level_namespace_id = $1
+					AND repository_id = $2),
+			(
+				SELECT
+					count(*)
+				FROM
+					manifests
+				WHERE
+					top_level_namespace_id = $1
+					AND repository_id = $2),
+			(
+				SELECT
+					coalesce(sum(b.size), 0)::bigint
+				FROM
+					repository_blobs AS rb
+					JOIN blobs AS b ON b.digest = rb.blob_digest
+				WHERE
+					rb.top_level_namespace_id = $1
+					AND rb.repository_id = $2),
+			greatest (
+				(
+					SELECT
+						max(coalesce(updated_at, created_at))
+					FROM
+						tags
+					WHERE
+						top_level_namespace_id = $1
+						AND repository_id = $2),
+				(
+					SELECT
+						max(created_at)
+					FROM
+						manifests
+					WHERE
+						top_level_namespace_id = $1
+						AND repository_id = $2)),
+			now()
+		ON CONFLICT (top_level_namespace_id, repository_id)
+			DO UPDATE SET
+				tags_count = EXCLUDED.tags_count,
+				manifests_count = EXCLUDED.manifests_count,
+				size = EXCLUDED.size,
+				last_published_at = EXCLUDED.last_published_at,
+				calculated_at = EXCLUDED.calculated_at
+		RETURNING
+			tags_count,
+			manifests_count,
+			size,
+			last_published_at,
+			calculated_at`
+
+	st := &models.RepositoryStatistics{NamespaceID: r.NamespaceID, RepositoryID: r.ID}
+	row := s.db.QueryRowContext(ctx, q, r.NamespaceID, r.ID)
+	if err := row.Scan(&st.TagsCount, &st.ManifestsCount, &st.Size, &st.LastPublishedAt, &st.CalculatedAt); err != nil {
+		return nil, fmt.Errorf("recalculating repository statistics: %w", err)
+	}
+
+	return st, nil
+}
@@ -0,0 +1,96 @@
+// +build integration
+
+package datastore_test
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func reloadRepositoryStatisticsFixtures(tb testing.TB) {
+	testutil.ReloadFixtures(
+		tb, suite.db, suite.basePath,
+		// Statistics are calculated from the tags, manifests and blobs of a repository (insert order matters)
+		testutil.NamespacesTable, testutil.RepositoriesTable, testutil.BlobsTable, testutil.ManifestsTable,
+		testutil.RepositoryBlobsTable, testutil.TagsTable, testutil.RepositoryStatisticsTable,
+	)
+}
+
+func TestRepositoryStatisticsStore_FindByRepository(t *testing.T) {
+	reloadRepositoryStatisticsFixtures(t)
+
+	s := datastore.NewRepositoryStatisticsStore(suite.db)
+	st, err := s.FindByRepository(suite.ctx, &models.Repository{NamespaceID: 1, ID: 3})
+	require.NoError(t, err)
+
+	// see testdata/fixtures/repository_statistics.sql
+	expected := &models.RepositoryStatistics{
+		NamespaceID:    1,
+		RepositoryID:   3,
+		TagsCount:      0,
+		ManifestsCount: 1,
+		Size:           1024,
+		CalculatedAt:   testutil.ParseTimestamp(t, "2020-03-02 17:57:43.283783", st.CalculatedAt.Location()),
+	}
+	require.Equal(t, expected, st)
+}
+
+func TestRepositoryStatisticsStore_FindByRepository_NotFound(t *testing.T) {
+	reloadRepositoryStatisticsFixtures(t)
+
+	s := datastore.NewRepositoryStatisticsStore(suite.db)
+	st, err := s.FindByRepository(suite.ctx, &models.Repository{NamespaceID: 1, ID: 4})
+	require.NoError(t, err)
+	require.Nil(t, st)
+}
+
+func TestRepositoryStatisticsStore_Recalculate(t *testing.T) {
+	reloadRepositoryStatisticsFixtures(t)
+
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+	rStore := datastore.NewRepositoryStore(suite.db)
+	tagsCount, err := rStore.TagsCount(suite.ctx, r)
+	require.NoError(t, err)
+	manifestsCount, err := rStore.ManifestsCount(suite.ctx, r)
+	require.NoError(t, err)
+	bb, err := rStore.Blobs(suite.ctx, r)
+	require.NoError(t, err)
+	var size int64
+	for _, b := range bb {
+		size += b.Size
+	}
+
+	// the stored statistics are stale, see testdata/fixtures/repository_statistics.sql
+	s := datastore.NewRepositoryStatisticsStore(suite.db)
+	before, err := s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+
+	st, err := s.Recalculate(suite.ctx, r)
+	require.NoError(t, err)
+	require.Equal(t, tagsCount, st.TagsCount)
+	require.Equal(t, manifestsCount, st.ManifestsCount)
+	require.Equal(t, size, st.Size)
+	require.True(t, st.LastPublishedAt.Valid)
+	require.True(t, st.CalculatedAt.After(before.CalculatedAt))
+
+	after, err := s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Equal(t, st, after)
+}
+
+func TestRepositoryStatisticsStore_Recalculate_Empty(t *testing.T) {
+	reloadRepositoryStatisticsFixtures(t)
+
+	s := datastore.NewRepositoryStatisticsStore(suite.db)
+	st, err := s.Recalculate(suite.ctx, &models.Repository{NamespaceID: 1, ID: 1})
+	require.NoError(t, err)
+	require.Zero(t, st.TagsCount)
+	require.Zero(t, st.ManifestsCount)
+	require.Zero(t, st.Size)
+	require.False(t, st.LastPublishedAt.Valid)
+	require.False(t, st.CalculatedAt.IsZero())
+}
@@ -0,0 +1,257 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// RetentionPolicyStore is the interface that a repository retention policy store should conform to.
+type RetentionPolicyStore interface {
+	CreateOrUpdate(ctx context.Context, p *models.RetentionPolicy) error
+	FindByRepository(ctx context.Context, r *models.Repository) (*models.RetentionPolicy, error)
+	Delete(ctx context.Context, r *models.Repository) error
+	Next(ctx context.Context, lease time.Duration) (*models.RetentionPolicy, error)
+	Complete(ctx context.Context, p *models.RetentionPolicy, d time.Duration, deleted int) error
+	Postpone(ctx context.Context, p *models.RetentionPolicy, d time.Duration, reason string) error
+}
+
+type retentionPolicyStore struct {
+	db Queryer
+}
+
+// NewRetentionPolicyStore builds a new retentionPolicyStore.
+func NewRetentionPolicyStore(db Queryer) RetentionPolicyStore {
+	return &retentionPolicyStore{db: db}
+}
+
+const retentionPolicyColumns = `p.top_level_namespace_id,
+			p.repository_id,
+			r.path,
+			p.keep_n,
+			COALESCE(p.keep_regex, ''),
+			p.older_than_seconds,
+			p.dry_run,
+			p.last_run_at,
+			p.last_run_deleted,
+			COALESCE(p.error, ''),
+			p.review_after,
+			p.review_count,
+			p.created_at,
+			p.updated_at`
+
+func scanFullRetentionPolicy(row *sql.Row) (*models.RetentionPolicy, error) {
+	p := new(models.RetentionPolicy)
+	var olderThan int64
+
+	err := row.Scan(&p.NamespaceID, &p.RepositoryID, &p.RepositoryPath, &p.KeepN, &p.KeepRegex, &olderThan, &p.DryRun,
+		&p.LastRunAt, &p.LastRunDeleted, &p.Error, &p.ReviewAfter, &p.ReviewCount, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("scanning retention policy: %w", err)
+		}
+		return nil, err
+	}
+	p.OlderThan = time.Duration(olderThan) * time.Second
+
+	return p, nil
+}
+
+// CreateOrUpdate sets the retention policy of a repository, replacing the existing one, if any. The policy is available
+// for evaluation straight away, and the review_count and error of previous runs are reset.
+func (s *retentionPolicyStore) CreateOrUpdate(ctx context.Context, p *models.RetentionPolicy) error {
+	defer metrics.InstrumentQuery("retention_policy_create_or_update")()
+
+	q := `INSERT INTO repository_retention_policies (top_level_namespace_id, repository_id, keep_n, keep_regex,
+			older_than_seconds, dry_run)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+		ON CONFLICT (top_level_namespace_id, repository_id)
+			DO UPDATE SET
+				keep_n = EXCLUDED.keep_n,
+				keep_regex = EXCLUDED.keep_regex,
+				older_than_seconds = EXCLUDED.older_than_seconds,
+				dry_run = EXCLUDED.dry_run,
+				review_after = now(),
+				review_count = 0,
+				error = NULL,
+				updated_at = now()
+		RETURNING
+			last_run_at, last_run_deleted, review_after, review_count, created_at, updated_at`
+
+	row := s.db.QueryRowContext(ctx, q, p.NamespaceID, p.RepositoryID, p.KeepN, p.KeepRegex,
+		int64(p.OlderThan/time.Second), p.DryRun)
+	if err := row.Scan(&p.LastRunAt, &p.LastRunDeleted, &p.ReviewAfter, &p.ReviewCount, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return fmt.Errorf("creating or updating retention policy: %w", err)
+	}
+	p.Error = ""
+
+	return nil
+}
+
+// FindByRepository finds the retention policy of a repository. No error is returned if there is no policy for the
+// given repository, a `nil` policy is returned in this situation.
+func (s *retentionPolicyStore) FindByRepository(ctx context.Context, r *models.Repository) (*models.RetentionPolicy, error) {
+	defer metrics.InstrumentQuery("retention_policy_find_by_repository")()
+
+	q := `SELECT
+			` + retentionPolicyColumns + `
+		FROM
+			repository_retention_policies AS p
+			JOIN repositories AS r ON r.top_level_namespace_id = p.top_level_namespace_id
+				AND r.id = p.repository_id
+		WHERE
+			p.top_level_namespace_id = $1
+			AND p.repository_id = $2`
+
+	p, err := scanFullRetentionPolicy(s.db.QueryRowContext(ctx, q, r.NamespaceID, r.ID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("finding retention policy: %w", err)
+	}
+
+	return p, nil
+}
+
+// Delete deletes the retention policy of a repository. ErrRetentionPolicyNotFound is returned if the repository has
+// no policy.
+func (s *retentionPolicyStore) Delete(ctx context.Context, r *models.Repository) error {
+	defer metrics.InstrumentQuery("retention_policy_delete")()
+
+	q := `DELETE FROM repository_retention_policies
+		WHERE top_level_namespace_id = $1
+			AND repository_id = $2`
+
+	res, err := s.db.ExecContext(ctx, q, r.NamespaceID, r.ID)
+	if err != nil {
+		return fmt.Errorf("deleting retention policy: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("deleting retention policy: %w", err)
+	}
+	if count == 0 {
+		return ErrRetentionPolicyNotFound
+	}
+
+	return nil
+}
+
+// Next claims the retention policy with the oldest review_after before the current date. Similarly to storage moves,
+// policies are not kept locked while being enforced. Instead, the review_after of the claimed policy is moved forward
+// by lease, making it invisible to other callers in the meantime. Callers must complete or postpone the policy once
+// done with it, otherwise it's retried once the lease expires. This method may be called safely from multiple
+// concurrent goroutines or processes. No error is returned if there are no policies available, a `nil` policy is
+// returned in this situation. Policies of archived repositories are skipped, as their tags must not be deleted.
+func (s *retentionPolicyStore) Next(ctx context.Context, lease time.Duration) (*models.RetentionPolicy, error) {
+	defer metrics.InstrumentQuery("retention_policy_next")()
+
+	q := `UPDATE
+			repository_retention_policies AS p
+		SET
+			review_after = now() + $1 * interval '1 second'
+		FROM
+			repositories AS r
+		WHERE
+			(p.top_level_namespace_id, p.repository_id) = (
+				SELECT
+					rp.top_level_namespace_id,
+					rp.repository_id
+				FROM
+					repository_retention_policies AS rp
+					JOIN repositories AS rr ON rr.top_level_namespace_id = rp.top_level_namespace_id
+						AND rr.id = rp.repository_id
+				WHERE
+					rp.review_after < now()
+					AND NOT rr.archived
+				ORDER BY
+					rp.review_after
+				FOR UPDATE
+					OF rp SKIP LOCKED
+				LIMIT 1)
+			AND r.top_level_namespace_id = p.top_level_namespace_id
+			AND r.id = p.repository_id
+		RETURNING
+			` + retentionPolicyColumns
+
+	p, err := scanFullRetentionPolicy(s.db.QueryRowContext(ctx, q, lease.Seconds()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("fetching next retention policy: %w", err)
+	}
+
+	return p, nil
+}
+
+// Complete records a successful run of a retention policy, which deleted (or would have deleted, for dry runs) the
+// given number of tags, and schedules the next run after a given amount of time. The review_count and error of
+// previous runs are reset.
+func (s *retentionPolicyStore) Complete(ctx context.Context, p *models.RetentionPolicy, d time.Duration, deleted int) error {
+	defer metrics.InstrumentQuery("retention_policy_complete")()
+
+	q := `UPDATE
+			repository_retention_policies
+		SET
+			review_after = now() + $1 * interval '1 second',
+			review_count = 0,
+			last_run_at = now(),
+			last_run_deleted = $2,
+			error = NULL
+		WHERE
+			top_level_namespace_id = $3
+			AND repository_id = $4
+		RETURNING
+			review_after,
+			last_run_at`
+
+	row := s.db.QueryRowContext(ctx, q, d.Seconds(), deleted, p.NamespaceID, p.RepositoryID)
+	if err := row.Scan(&p.ReviewAfter, &p.LastRunAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRetentionPolicyNotFound
+		}
+		return fmt.Errorf("completing retention policy run: %w", err)
+	}
+	p.ReviewCount = 0
+	p.LastRunDeleted = deleted
+	p.Error = ""
+
+	return nil
+}
+
+// Postpone sets the review_after of a retention policy to the current date plus a given amount of time, recording
+// reason as the cause. The review_count is automatically incremented.
+func (s *retentionPolicyStore) Postpone(ctx context.Context, p *models.RetentionPolicy, d time.Duration, reason string) error {
+	defer metrics.InstrumentQuery("retention_policy_postpone")()
+
+	q := `UPDATE
+			repository_retention_policies
+		SET
+			review_after = now() + $1 * interval '1 second',
+			review_count = review_count + 1,
+			error = left($2, 1024)
+		WHERE
+			top_level_namespace_id = $3
+			AND repository_id = $4
+		RETURNING
+			review_after,
+			review_count`
+
+	row := s.db.QueryRowContext(ctx, q, d.Seconds(), reason, p.NamespaceID, p.RepositoryID)
+	if err := row.Scan(&p.ReviewAfter, &p.ReviewCount); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRetentionPolicyNotFound
+		}
+		return fmt.Errorf("postponing retention policy: %w", err)
+	}
+	p.Error = reason
+
+	return nil
+}
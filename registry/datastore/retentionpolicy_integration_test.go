@@ -0,0 +1,233 @@
+// +build integration
+
+package datastore_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func reloadRetentionPolicyFixtures(tb testing.TB) {
+	testutil.ReloadFixtures(
+		tb, suite.db, suite.basePath,
+		// A RetentionPolicy has a foreign key for a Repository, which in turn references a Namespace (insert order
+		// matters)
+		testutil.NamespacesTable, testutil.RepositoriesTable, testutil.RetentionPoliciesTable,
+	)
+}
+
+func unloadRetentionPolicyFixtures(tb testing.TB) {
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.RetentionPoliciesTable))
+}
+
+func TestRetentionPolicyStore_CreateOrUpdate_Create(t *testing.T) {
+	reloadRetentionPolicyFixtures(t)
+
+	s := datastore.NewRetentionPolicyStore(suite.db)
+	p := &models.RetentionPolicy{NamespaceID: 1, RepositoryID: 2, KeepN: 3, KeepRegex: "latest", OlderThan: time.Hour}
+	require.NoError(t, s.CreateOrUpdate(suite.ctx, p))
+
+	require.NotEmpty(t, p.ReviewAfter)
+	require.NotEmpty(t, p.CreatedAt)
+	require.False(t, p.UpdatedAt.Valid)
+	require.False(t, p.LastRunAt.Valid)
+	require.Zero(t, p.ReviewCount)
+
+	p2, err := s.FindByRepository(suite.ctx, &models.Repository{NamespaceID: 1, ID: 2})
+	require.NoError(t, err)
+	require.Equal(t, 3, p2.KeepN)
+	require.Equal(t, "latest", p2.KeepRegex)
+	require.Equal(t, time.Hour, p2.OlderThan)
+	require.False(t, p2.DryRun)
+}
+
+func TestRetentionPolicyStore_CreateOrUpdate_Update(t *testing.T) {
+	reloadRetentionPolicyFixtures(t)
+
+	s := datastore.NewRetentionPolicyStore(suite.db)
+	p := &models.RetentionPolicy{NamespaceID: 1, RepositoryID: 4, KeepN: 1, DryRun: false}
+	require.NoError(t, s.CreateOrUpdate(suite.ctx, p))
+
+	// see testdata/fixtures/repository_retention_policies.sql
+	require.True(t, p.UpdatedAt.Valid)
+	require.Zero(t, p.ReviewCount)
+	require.Empty(t, p.Error)
+
+	p2, err := s.FindByRepository(suite.ctx, &models.Repository{NamespaceID: 1, ID: 4})
+	require.NoError(t, err)
+	require.Equal(t, 1, p2.KeepN)
+	require.Empty(t, p2.KeepRegex)
+	require.Zero(t, p2.OlderThan)
+	require.False(t, p2.DryRun)
+	require.Empty(t, p2.Error)
+}
+
+func TestRetentionPolicyStore_FindByRepository(t *testing.T) {
+	reloadRetentionPolicyFixtures(t)
+
+	s := datastore.NewRetentionPolicyStore(suite.db)
+	p, err := s.FindByRepository(suite.ctx, &models.Repository{NamespaceID: 1, ID: 4})
+	require.NoError(t, err)
+
+	// see testdata/fixtures/repository_retention_policies.sql
+	local := p.CreatedAt.Location()
+	expected := &models.RetentionPolicy{
+		NamespaceID:    1,
+		RepositoryID:   4,
+		RepositoryPath: "gitlab-org/gitlab-test/frontend",
+		KeepRegex:      "v.*",
+		OlderThan:      30 * 24 * time.Hour,
+		DryRun:         true,
+		Error:          "listing repository tags: timeout",
+		ReviewAfter:    testutil.ParseTimestamp(t, "2020-03-02 18:12:44.283783", local),
+		ReviewCount:    1,
+		CreatedAt:      testutil.ParseTimestamp(t, "2020-03-02 17:57:44.283783", local),
+		UpdatedAt: sql.NullTime{
+			Time:  testutil.ParseTimestamp(t, "2020-03-02 18:12:44.283783", local),
+			Valid: true,
+		},
+	}
+	require.Equal(t, expected, p)
+}
+
+func TestRetentionPolicyStore_FindByRepository_NotFound(t *testing.T) {
+	reloadRetentionPolicyFixtures(t)
+
+	s := datastore.NewRetentionPolicyStore(suite.db)
+	p, err := s.FindByRepository(suite.ctx, &models.Repository{NamespaceID: 1, ID: 1})
+	require.NoError(t, err)
+	require.Nil(t, p)
+}
+
+func TestRetentionPolicyStore_Delete(t *testing.T) {
+	reloadRetentionPolicyFixtures(t)
+
+	s := datastore.NewRetentionPolicyStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+	require.NoError(t, s.Delete(suite.ctx, r))
+
+	p, err := s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Nil(t, p)
+
+	require.ErrorIs(t, s.Delete(suite.ctx, r), datastore.ErrRetentionPolicyNotFound)
+}
+
+func TestRetentionPolicyStore_Next(t *testing.T) {
+	reloadRetentionPolicyFixtures(t)
+
+	s := datastore.NewRetentionPolicyStore(suite.db)
+
+	// see testdata/fixtures/repository_retention_policies.sql
+	p, err := s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, p)
+	require.Equal(t, int64(4), p.RepositoryID)
+	require.Equal(t, "gitlab-org/gitlab-test/frontend", p.RepositoryPath)
+	require.True(t, p.ReviewAfter.After(time.Now().Add(50*time.Minute)))
+
+	// the first policy is leased, so the next one must be returned
+	p, err = s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, p)
+	require.Equal(t, int64(3), p.RepositoryID)
+
+	// the remaining policy is not due yet
+	p, err = s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.Nil(t, p)
+}
+
+func TestRetentionPolicyStore_Next_SkipsArchivedRepositories(t *testing.T) {
+	reloadRetentionPolicyFixtures(t)
+
+	rs := datastore.NewRepositoryStore(suite.db)
+	r, err := rs.FindByPath(suite.ctx, "gitlab-org/gitlab-test/frontend")
+	require.NoError(t, err)
+	require.NoError(t, rs.SetArchived(suite.ctx, r, true))
+
+	s := datastore.NewRetentionPolicyStore(suite.db)
+
+	// see testdata/fixtures/repository_retention_policies.sql, the policy of the archived repository is due first but
+	// must be skipped
+	p, err := s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, p)
+	require.Equal(t, int64(3), p.RepositoryID)
+
+	p, err = s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.Nil(t, p)
+}
+
+func TestRetentionPolicyStore_Next_None(t *testing.T) {
+	unloadRetentionPolicyFixtures(t)
+
+	s := datastore.NewRetentionPolicyStore(suite.db)
+	p, err := s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.Nil(t, p)
+}
+
+func TestRetentionPolicyStore_Complete(t *testing.T) {
+	reloadRetentionPolicyFixtures(t)
+
+	s := datastore.NewRetentionPolicyStore(suite.db)
+	p, err := s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, p)
+
+	require.NoError(t, s.Complete(suite.ctx, p, 24*time.Hour, 7))
+	require.Zero(t, p.ReviewCount)
+	require.Empty(t, p.Error)
+	require.Equal(t, 7, p.LastRunDeleted)
+	require.True(t, p.LastRunAt.Valid)
+	require.True(t, p.ReviewAfter.After(time.Now().Add(23*time.Hour)))
+
+	p2, err := s.FindByRepository(suite.ctx, &models.Repository{NamespaceID: p.NamespaceID, ID: p.RepositoryID})
+	require.NoError(t, err)
+	require.Equal(t, p, p2)
+}
+
+func TestRetentionPolicyStore_Complete_NotFound(t *testing.T) {
+	unloadRetentionPolicyFixtures(t)
+
+	s := datastore.NewRetentionPolicyStore(suite.db)
+	err := s.Complete(suite.ctx, &models.RetentionPolicy{NamespaceID: 1, RepositoryID: 4}, time.Hour, 0)
+	require.ErrorIs(t, err, datastore.ErrRetentionPolicyNotFound)
+}
+
+func TestRetentionPolicyStore_Postpone(t *testing.T) {
+	reloadRetentionPolicyFixtures(t)
+
+	s := datastore.NewRetentionPolicyStore(suite.db)
+	p, err := s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, p)
+
+	require.NoError(t, s.Postpone(suite.ctx, p, -time.Minute, "deleting tag: boom"))
+	require.Equal(t, 2, p.ReviewCount)
+	require.Equal(t, "deleting tag: boom", p.Error)
+
+	// the postponed policy is due again
+	p2, err := s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, p2)
+	require.Equal(t, p.RepositoryID, p2.RepositoryID)
+	require.Equal(t, 2, p2.ReviewCount)
+	require.Equal(t, "deleting tag: boom", p2.Error)
+}
+
+func TestRetentionPolicyStore_Postpone_NotFound(t *testing.T) {
+	unloadRetentionPolicyFixtures(t)
+
+	s := datastore.NewRetentionPolicyStore(suite.db)
+	err := s.Postpone(suite.ctx, &models.RetentionPolicy{NamespaceID: 1, RepositoryID: 4}, time.Minute, "")
+	require.ErrorIs(t, err, datastore.ErrRetentionPolicyNotFound)
+}
@@ -0,0 +1,151 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/docker/distribution/registry/internal/tracing"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+)
+
+const (
+	defaultRetryInitialInterval = 100 * time.Millisecond
+	defaultRetryMaxInterval     = 2 * time.Second
+)
+
+// RetryConfig configures the retry behavior for failed database connections and read-only queries. Retries are
+// disabled if MaxRetries is not positive.
+type RetryConfig struct {
+	// MaxRetries is the maximum number of retries for a single connection attempt or query.
+	MaxRetries int
+	// InitialInterval is the time to wait before the first retry. Defaults to 100ms.
+	InitialInterval time.Duration
+	// MaxInterval caps the exponentially growing time to wait between retries. Defaults to 2s.
+	MaxInterval time.Duration
+}
+
+func (c *RetryConfig) enabled() bool {
+	return c != nil && c.MaxRetries > 0
+}
+
+func (c *RetryConfig) backoff(ctx context.Context) backoff.BackOff {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = c.InitialInterval
+	if b.InitialInterval <= 0 {
+		b.InitialInterval = defaultRetryInitialInterval
+	}
+	b.MaxInterval = c.MaxInterval
+	if b.MaxInterval <= 0 {
+		b.MaxInterval = defaultRetryMaxInterval
+	}
+	// the number of retries is what bounds the operation, not the elapsed time
+	b.MaxElapsedTime = 0
+
+	return backoff.WithContext(backoff.WithMaxRetries(b, uint64(c.MaxRetries)), ctx)
+}
+
+// isRetryableError determines whether err is a transient error that may be resolved by retrying against a new
+// connection, such as when the database server is restarted or fails over to a standby.
+func isRetryableError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgerrcode.SerializationFailure,
+			pgerrcode.DeadlockDetected,
+			pgerrcode.AdminShutdown,
+			pgerrcode.CrashShutdown,
+			pgerrcode.CannotConnectNow:
+			return true
+		}
+		return pgerrcode.IsConnectionException(pgErr.Code)
+	}
+
+	if pgconn.SafeToRetry(err) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// isReadOnlyQuery determines whether query is a plain SELECT statement, and is therefore safe to retry.
+func isReadOnlyQuery(query string) bool {
+	q := strings.TrimSpace(query)
+	return len(q) >= len("SELECT") && strings.EqualFold(q[:len("SELECT")], "SELECT")
+}
+
+// retry runs op, retrying it with exponential backoff while it fails with a retryable error.
+func (db *DB) retry(ctx context.Context, op func() error) error {
+	return backoff.RetryNotify(func() error {
+		err := op()
+		if err != nil && !isRetryableError(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, db.retryConfig.backoff(ctx), func(err error, d time.Duration) {
+		db.logger.WithError(err).WithField("backoff_s", d.Seconds()).Warn("retrying failed database operation")
+	})
+}
+
+// QueryContext wraps sql.DB.QueryContext, transparently retrying read-only queries that fail with a retryable error
+// if retries are enabled.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuerySpan(ctx, query)
+
+	if !db.retryConfig.enabled() || !isReadOnlyQuery(query) {
+		rows, err := db.DB.QueryContext(ctx, query, args...)
+		tracing.EndSpan(span, err)
+		return rows, err
+	}
+
+	var rows *sql.Rows
+	err := db.retry(ctx, func() error {
+		var err error
+		rows, err = db.DB.QueryContext(ctx, query, args...)
+		return err
+	})
+	tracing.EndSpan(span, err)
+
+	return rows, err
+}
+
+// QueryRowContext wraps sql.DB.QueryRowContext, transparently retrying read-only queries that fail with a retryable
+// error if retries are enabled.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuerySpan(ctx, query)
+
+	if !db.retryConfig.enabled() || !isReadOnlyQuery(query) {
+		row := db.DB.QueryRowContext(ctx, query, args...)
+		tracing.EndSpan(span, row.Err())
+		return row
+	}
+
+	var row *sql.Row
+	// the error of the last attempt is deferred until the row is scanned, so we can ignore it here
+	_ = db.retry(ctx, func() error {
+		row = db.DB.QueryRowContext(ctx, query, args...)
+		return row.Err()
+	})
+	tracing.EndSpan(span, row.Err())
+
+	return row
+}
@@ -0,0 +1,104 @@
+package datastore
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "generic", err: errors.New("foo"), want: false},
+		{name: "context canceled", err: context.Canceled, want: false},
+		{name: "context deadline exceeded", err: fmt.Errorf("foo: %w", context.DeadlineExceeded), want: false},
+		{name: "bad connection", err: driver.ErrBadConn, want: true},
+		{name: "unexpected EOF", err: fmt.Errorf("foo: %w", io.ErrUnexpectedEOF), want: true},
+		{name: "connection reset", err: fmt.Errorf("foo: %w", syscall.ECONNRESET), want: true},
+		{name: "connection refused", err: syscall.ECONNREFUSED, want: true},
+		{name: "serialization failure", err: &pgconn.PgError{Code: pgerrcode.SerializationFailure}, want: true},
+		{name: "admin shutdown", err: &pgconn.PgError{Code: pgerrcode.AdminShutdown}, want: true},
+		{name: "connection exception", err: &pgconn.PgError{Code: pgerrcode.ConnectionFailure}, want: true},
+		{name: "unique violation", err: &pgconn.PgError{Code: pgerrcode.UniqueViolation}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, isRetryableError(tt.err))
+		})
+	}
+}
+
+func TestIsReadOnlyQuery(t *testing.T) {
+	require.True(t, isReadOnlyQuery("SELECT 1"))
+	require.True(t, isReadOnlyQuery("\n\t\tselect id FROM repositories"))
+	require.False(t, isReadOnlyQuery("INSERT INTO repositories (path) VALUES ($1)"))
+	require.False(t, isReadOnlyQuery("DELETE FROM repositories"))
+	require.False(t, isReadOnlyQuery("SEL"))
+	require.False(t, isReadOnlyQuery(""))
+}
+
+func newRetryTestDB(maxRetries int) *DB {
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+
+	return &DB{
+		retryConfig: &RetryConfig{MaxRetries: maxRetries, InitialInterval: time.Millisecond, MaxInterval: time.Millisecond},
+		logger:      logrus.NewEntry(log),
+	}
+}
+
+func TestDB_Retry(t *testing.T) {
+	db := newRetryTestDB(3)
+
+	var attempts int
+	err := db.retry(context.Background(), func() error {
+		attempts++
+		if attempts < 3 {
+			return driver.ErrBadConn
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+}
+
+func TestDB_Retry_MaxRetries(t *testing.T) {
+	db := newRetryTestDB(2)
+
+	var attempts int
+	err := db.retry(context.Background(), func() error {
+		attempts++
+		return driver.ErrBadConn
+	})
+	require.True(t, errors.Is(err, driver.ErrBadConn))
+	require.Equal(t, 3, attempts)
+}
+
+func TestDB_Retry_NonRetryableError(t *testing.T) {
+	db := newRetryTestDB(3)
+	fooErr := errors.New("foo")
+
+	var attempts int
+	err := db.retry(context.Background(), func() error {
+		attempts++
+		return fooErr
+	})
+	require.True(t, errors.Is(err, fooErr))
+	require.Equal(t, 1, attempts)
+}
@@ -0,0 +1,256 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/opencontainers/go-digest"
+)
+
+// StorageMoveStore is the interface that a repository storage move store should conform to.
+type StorageMoveStore interface {
+	Create(ctx context.Context, m *models.StorageMove) error
+	FindByRepository(ctx context.Context, r *models.Repository) (*models.StorageMove, error)
+	Next(ctx context.Context, lease time.Duration) (*models.StorageMove, error)
+	Advance(ctx context.Context, m *models.StorageMove, state models.StorageMoveState) error
+	Postpone(ctx context.Context, m *models.StorageMove, d time.Duration, reason string) error
+	ReferencedFromSource(ctx context.Context, d digest.Digest) (bool, error)
+}
+
+type storageMoveStore struct {
+	db Queryer
+}
+
+// NewStorageMoveStore builds a new storageMoveStore.
+func NewStorageMoveStore(db Queryer) StorageMoveStore {
+	return &storageMoveStore{db: db}
+}
+
+func scanFullStorageMove(row *sql.Row) (*models.StorageMove, error) {
+	m := new(models.StorageMove)
+
+	err := row.Scan(&m.NamespaceID, &m.RepositoryID, &m.RepositoryPath, &m.State, &m.Error, &m.ReviewAfter,
+		&m.ReviewCount, &m.CreatedAt, &m.UpdatedAt)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("scanning storage move: %w", err)
+		}
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Create schedules the storage move of a repository. The move is available for processing straight away.
+// ErrStorageMoveExists is returned if a move was already scheduled for the same repository.
+func (s *storageMoveStore) Create(ctx context.Context, m *models.StorageMove) error {
+	defer metrics.InstrumentQuery("storage_move_create")()
+
+	q := `INSERT INTO repository_storage_moves (top_level_namespace_id, repository_id)
+			VALUES ($1, $2)
+		ON CONFLICT (top_level_namespace_id, repository_id)
+			DO NOTHING
+		RETURNING
+			state, review_after, review_count, created_at`
+
+	row := s.db.QueryRowContext(ctx, q, m.NamespaceID, m.RepositoryID)
+	if err := row.Scan(&m.State, &m.ReviewAfter, &m.ReviewCount, &m.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrStorageMoveExists
+		}
+		return fmt.Errorf("creating storage move: %w", err)
+	}
+
+	return nil
+}
+
+// FindByRepository finds the storage move of a repository. No error is returned if there is no move for the given
+// repository, a `nil` move is returned in this situation.
+func (s *storageMoveStore) FindByRepository(ctx context.Context, r *models.Repository) (*models.StorageMove, error) {
+	defer metrics.InstrumentQuery("storage_move_find_by_repository")()
+
+	q := `SELECT
+			m.top_level_namespace_id,
+			m.repository_id,
+			r.path,
+			m.state,
+			COALESCE(m.error, ''),
+			m.review_after,
+			m.review_count,
+			m.created_at,
+			m.updated_at
+		FROM
+			repository_storage_moves AS m
+			JOIN repositories AS r ON r.top_level_namespace_id = m.top_level_namespace_id
+				AND r.id = m.repository_id
+		WHERE
+			m.top_level_namespace_id = $1
+			AND m.repository_id = $2`
+
+	m, err := scanFullStorageMove(s.db.QueryRowContext(ctx, q, r.NamespaceID, r.ID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("finding storage move: %w", err)
+	}
+
+	return m, nil
+}
+
+// Next claims the unfinished storage move with the oldest review_after before the current date. Similarly to
+// replication tasks, moves are not kept locked while being processed. Instead, the review_after of the claimed move is
+// moved forward by lease, making it invisible to other callers in the meantime. Callers must advance or postpone the
+// move once done with it, otherwise it's retried once the lease expires. This method may be called safely from
+// multiple concurrent goroutines or processes. No error is returned if there are no moves available, a `nil` move is
+// returned in this situation.
+func (s *storageMoveStore) Next(ctx context.Context, lease time.Duration) (*models.StorageMove, error) {
+	defer metrics.InstrumentQuery("storage_move_next")()
+
+	q := `UPDATE
+			repository_storage_moves AS m
+		SET
+			review_after = now() + $1 * interval '1 second'
+		FROM
+			repositories AS r
+		WHERE
+			(m.top_level_namespace_id, m.repository_id) = (
+				SELECT
+					top_level_namespace_id,
+					repository_id
+				FROM
+					repository_storage_moves
+				WHERE
+					state <> 'finished'
+					AND review_after < now()
+				ORDER BY
+					review_after
+				FOR UPDATE
+					SKIP LOCKED
+				LIMIT 1)
+			AND r.top_level_namespace_id = m.top_level_namespace_id
+			AND r.id = m.repository_id
+		RETURNING
+			m.top_level_namespace_id,
+			m.repository_id,
+			r.path,
+			m.state,
+			COALESCE(m.error, ''),
+			m.review_after,
+			m.review_count,
+			m.created_at,
+			m.updated_at`
+
+	m, err := scanFullStorageMove(s.db.QueryRowContext(ctx, q, lease.Seconds()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("fetching next storage move: %w", err)
+	}
+
+	return m, nil
+}
+
+// Advance moves a storage move to the given state. The move becomes available for processing straight away, unless
+// finished, and the review_count and error of previous attempts are reset.
+func (s *storageMoveStore) Advance(ctx context.Context, m *models.StorageMove, state models.StorageMoveState) error {
+	defer metrics.InstrumentQuery("storage_move_advance")()
+
+	q := `UPDATE
+			repository_storage_moves
+		SET
+			state = $1,
+			review_after = now(),
+			review_count = 0,
+			error = NULL,
+			updated_at = now()
+		WHERE
+			top_level_namespace_id = $2
+			AND repository_id = $3
+		RETURNING
+			review_after,
+			updated_at`
+
+	row := s.db.QueryRowContext(ctx, q, state, m.NamespaceID, m.RepositoryID)
+	if err := row.Scan(&m.ReviewAfter, &m.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrStorageMoveNotFound
+		}
+		return fmt.Errorf("advancing storage move: %w", err)
+	}
+	m.State = state
+	m.ReviewCount = 0
+	m.Error = ""
+
+	return nil
+}
+
+// Postpone sets the review_after of a storage move to the current date plus a given amount of time, recording reason
+// as the cause. The review_count is automatically incremented.
+func (s *storageMoveStore) Postpone(ctx context.Context, m *models.StorageMove, d time.Duration, reason string) error {
+	defer metrics.InstrumentQuery("storage_move_postpone")()
+
+	q := `UPDATE
+			repository_storage_moves
+		SET
+			review_after = now() + $1 * interval '1 second',
+			review_count = review_count + 1,
+			error = left($2, 1024),
+			updated_at = now()
+		WHERE
+			top_level_namespace_id = $3
+			AND repository_id = $4
+		RETURNING
+			review_after,
+			review_count,
+			updated_at`
+
+	row := s.db.QueryRowContext(ctx, q, d.Seconds(), reason, m.NamespaceID, m.RepositoryID)
+	if err := row.Scan(&m.ReviewAfter, &m.ReviewCount, &m.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrStorageMoveNotFound
+		}
+		return fmt.Errorf("postponing storage move: %w", err)
+	}
+	m.Error = reason
+
+	return nil
+}
+
+// ReferencedFromSource reports whether the blob with digest d is linked to at least one repository that is still
+// served from the source storage, i.e., a repository that was not moved or whose move was not routed to the target
+// storage yet.
+func (s *storageMoveStore) ReferencedFromSource(ctx context.Context, d digest.Digest) (bool, error) {
+	defer metrics.InstrumentQuery("storage_move_referenced_from_source")()
+
+	q := `SELECT
+			EXISTS (
+				SELECT
+					1
+				FROM
+					repository_blobs AS rb
+					LEFT JOIN repository_storage_moves AS m ON m.top_level_namespace_id = rb.top_level_namespace_id
+						AND m.repository_id = rb.repository_id
+				WHERE
+					rb.blob_digest = decode($1, 'hex')
+					AND (m.state IS NULL
+						OR m.state NOT IN ('cleanup', 'finished')))`
+
+	dgst, err := NewDigest(d)
+	if err != nil {
+		return false, err
+	}
+
+	var referenced bool
+	if err := s.db.QueryRowContext(ctx, q, dgst).Scan(&referenced); err != nil {
+		return false, fmt.Errorf("checking blob references from source storage: %w", err)
+	}
+
+	return referenced, nil
+}
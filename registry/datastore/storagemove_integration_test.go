@@ -0,0 +1,217 @@
+// +build integration
+
+package datastore_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func reloadStorageMoveFixtures(tb testing.TB) {
+	testutil.ReloadFixtures(
+		tb, suite.db, suite.basePath,
+		// A StorageMove has a foreign key for a Repository, which in turn references a Namespace (insert order matters)
+		testutil.NamespacesTable, testutil.RepositoriesTable, testutil.BlobsTable, testutil.RepositoryBlobsTable,
+		testutil.RepositoryStorageMovesTable,
+	)
+}
+
+func unloadStorageMoveFixtures(tb testing.TB) {
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.RepositoryStorageMovesTable))
+}
+
+func TestStorageMoveStore_Create(t *testing.T) {
+	reloadStorageMoveFixtures(t)
+
+	s := datastore.NewStorageMoveStore(suite.db)
+	m := &models.StorageMove{NamespaceID: 1, RepositoryID: 2}
+	require.NoError(t, s.Create(suite.ctx, m))
+
+	require.Equal(t, models.StorageMoveStateScheduled, m.State)
+	require.NotEmpty(t, m.ReviewAfter)
+	require.NotEmpty(t, m.CreatedAt)
+	require.Zero(t, m.ReviewCount)
+}
+
+func TestStorageMoveStore_Create_Exists(t *testing.T) {
+	reloadStorageMoveFixtures(t)
+
+	s := datastore.NewStorageMoveStore(suite.db)
+	err := s.Create(suite.ctx, &models.StorageMove{NamespaceID: 1, RepositoryID: 3})
+	require.ErrorIs(t, err, datastore.ErrStorageMoveExists)
+}
+
+func TestStorageMoveStore_FindByRepository(t *testing.T) {
+	reloadStorageMoveFixtures(t)
+
+	s := datastore.NewStorageMoveStore(suite.db)
+	m, err := s.FindByRepository(suite.ctx, &models.Repository{NamespaceID: 1, ID: 4})
+	require.NoError(t, err)
+
+	// see testdata/fixtures/repository_storage_moves.sql
+	local := m.CreatedAt.Location()
+	expected := &models.StorageMove{
+		NamespaceID:    1,
+		RepositoryID:   4,
+		RepositoryPath: "gitlab-org/gitlab-test/frontend",
+		State:          models.StorageMoveStateCleanup,
+		Error:          "deleting blob: timeout",
+		ReviewAfter:    testutil.ParseTimestamp(t, "2020-03-02 18:12:44.283783", local),
+		ReviewCount:    1,
+		CreatedAt:      testutil.ParseTimestamp(t, "2020-03-02 17:57:44.283783", local),
+		UpdatedAt: sql.NullTime{
+			Time:  testutil.ParseTimestamp(t, "2020-03-02 18:12:44.283783", local),
+			Valid: true,
+		},
+	}
+	require.Equal(t, expected, m)
+}
+
+func TestStorageMoveStore_FindByRepository_NotFound(t *testing.T) {
+	reloadStorageMoveFixtures(t)
+
+	s := datastore.NewStorageMoveStore(suite.db)
+	m, err := s.FindByRepository(suite.ctx, &models.Repository{NamespaceID: 1, ID: 1})
+	require.NoError(t, err)
+	require.Nil(t, m)
+}
+
+func TestStorageMoveStore_Next(t *testing.T) {
+	reloadStorageMoveFixtures(t)
+
+	s := datastore.NewStorageMoveStore(suite.db)
+
+	// see testdata/fixtures/repository_storage_moves.sql
+	m, err := s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	require.Equal(t, int64(6), m.RepositoryID)
+	require.Equal(t, "a-test-group/foo", m.RepositoryPath)
+	require.Equal(t, models.StorageMoveStateScheduled, m.State)
+	require.False(t, m.UpdatedAt.Valid)
+	require.True(t, m.ReviewAfter.After(time.Now().Add(50*time.Minute)))
+
+	// the first move is leased, so the next one must be returned
+	m, err = s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	require.Equal(t, int64(4), m.RepositoryID)
+
+	// the remaining moves are either finished or not due yet
+	m, err = s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.Nil(t, m)
+}
+
+func TestStorageMoveStore_Next_None(t *testing.T) {
+	unloadStorageMoveFixtures(t)
+
+	s := datastore.NewStorageMoveStore(suite.db)
+	m, err := s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.Nil(t, m)
+}
+
+func TestStorageMoveStore_Advance(t *testing.T) {
+	reloadStorageMoveFixtures(t)
+
+	s := datastore.NewStorageMoveStore(suite.db)
+	m, err := s.FindByRepository(suite.ctx, &models.Repository{NamespaceID: 1, ID: 4})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Advance(suite.ctx, m, models.StorageMoveStateFinished))
+	require.Equal(t, models.StorageMoveStateFinished, m.State)
+	require.Zero(t, m.ReviewCount)
+	require.Empty(t, m.Error)
+	require.True(t, m.UpdatedAt.Valid)
+
+	m2, err := s.FindByRepository(suite.ctx, &models.Repository{NamespaceID: 1, ID: 4})
+	require.NoError(t, err)
+	require.Equal(t, m, m2)
+}
+
+func TestStorageMoveStore_Advance_NotFound(t *testing.T) {
+	unloadStorageMoveFixtures(t)
+
+	s := datastore.NewStorageMoveStore(suite.db)
+	err := s.Advance(suite.ctx, &models.StorageMove{NamespaceID: 1, RepositoryID: 4}, models.StorageMoveStateCopying)
+	require.ErrorIs(t, err, datastore.ErrStorageMoveNotFound)
+}
+
+func TestStorageMoveStore_Postpone(t *testing.T) {
+	reloadStorageMoveFixtures(t)
+
+	s := datastore.NewStorageMoveStore(suite.db)
+	m, err := s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+
+	require.NoError(t, s.Postpone(suite.ctx, m, -time.Minute, "copying blob: boom"))
+	require.Equal(t, 1, m.ReviewCount)
+	require.Equal(t, "copying blob: boom", m.Error)
+
+	// the postponed move is due again
+	m2, err := s.Next(suite.ctx, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, m2)
+	require.Equal(t, m.RepositoryID, m2.RepositoryID)
+	require.Equal(t, 1, m2.ReviewCount)
+	require.Equal(t, "copying blob: boom", m2.Error)
+}
+
+func TestStorageMoveStore_Postpone_NotFound(t *testing.T) {
+	unloadStorageMoveFixtures(t)
+
+	s := datastore.NewStorageMoveStore(suite.db)
+	err := s.Postpone(suite.ctx, &models.StorageMove{NamespaceID: 1, RepositoryID: 4}, time.Minute, "")
+	require.ErrorIs(t, err, datastore.ErrStorageMoveNotFound)
+}
+
+func TestStorageMoveStore_ReferencedFromSource(t *testing.T) {
+	reloadStorageMoveFixtures(t)
+
+	s := datastore.NewStorageMoveStore(suite.db)
+
+	// see testdata/fixtures/repository_blobs.sql and testdata/fixtures/repository_storage_moves.sql
+	tt := []struct {
+		name   string
+		digest digest.Digest
+		want   bool
+	}{
+		{
+			name:   "linked to a repository that was not moved",
+			digest: "sha256:c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9",
+			want:   true,
+		},
+		{
+			name:   "only linked to repositories served from the target storage",
+			digest: "sha256:f01256086224ded321e042e74135d72d5f108089a1cda03ab4820dfc442807c1",
+			want:   false,
+		},
+		{
+			name:   "only linked to a repository being cleaned up",
+			digest: "sha256:68ced04f60ab5c7a5f1d0b0b4e7572c5a4c8cce44866513d30d9df1a15277d6b",
+			want:   false,
+		},
+		{
+			name:   "not linked",
+			digest: "sha256:bd165db4bd480656a539e8e00db265377d162d6b98eebbfe5805d0fbd5144155",
+			want:   false,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			referenced, err := s.ReferencedFromSource(suite.ctx, test.digest)
+			require.NoError(t, err)
+			require.Equal(t, test.want, referenced)
+		})
+	}
+}
@@ -24,6 +24,7 @@ type TagReader interface {
 // TagWriter is the interface that defines write operations for a tag store.
 type TagWriter interface {
 	CreateOrUpdate(ctx context.Context, t *models.Tag) error
+	UpdateIfManifest(ctx context.Context, t *models.Tag, currentManifestID int64) error
 }
 
 // TagStore is the interface that a tag store should conform to.
@@ -73,6 +74,36 @@ func scanFullTags(rows *sql.Rows) (models.Tags, error) {
 	return tt, nil
 }
 
+func scanTagDetails(rows *sql.Rows) (models.TagDetails, error) {
+	tt := make(models.TagDetails, 0)
+	defer rows.Close()
+
+	for rows.Next() {
+		var dgst Digest
+		var cfgMediaType sql.NullString
+		var cfgPayload *models.Payload
+		t := new(models.TagDetail)
+		if err := rows.Scan(&t.Name, &dgst, &t.MediaType, &cfgMediaType, &cfgPayload, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning tag detail: %w", err)
+		}
+		d, err := dgst.Parse()
+		if err != nil {
+			return nil, err
+		}
+		t.Digest = d
+		t.ConfigMediaType = cfgMediaType.String
+		if cfgPayload != nil {
+			t.ConfigPayload = *cfgPayload
+		}
+		tt = append(tt, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning tag details: %w", err)
+	}
+
+	return tt, nil
+}
+
 // FindByID finds a Tag by ID.
 func (s *tagStore) FindByID(ctx context.Context, id int64) (*models.Tag, error) {
 	defer metrics.InstrumentQuery("tag_find_by_id")()
@@ -137,7 +168,8 @@ func (s *tagStore) Repository(ctx context.Context, t *models.Tag) (*models.Repos
 			path,
 			parent_id,
 			created_at,
-			updated_at
+			updated_at,
+			archived
 		FROM
 			repositories
 		WHERE
@@ -177,8 +209,9 @@ func (s *tagStore) Manifest(ctx context.Context, t *models.Tag) (*models.Manifes
 }
 
 // CreateOrUpdate upsert a tag. A tag with a given name on a given repository may not exist (in which case it should be
-// inserted), already exist and point to the same manifest (in which case nothing needs to be done) or already exist but
-// points to a different manifest (in which case it should be updated).
+// inserted), already exist and point to the same manifest (in which case only its last update timestamp is refreshed)
+// or already exist but points to a different manifest (in which case it should be updated). The last update timestamp
+// therefore reflects the last time the tag was written, even if it did not change.
 func (s *tagStore) CreateOrUpdate(ctx context.Context, t *models.Tag) error {
 	defer metrics.InstrumentQuery("tag_create_or_update")()
 	q := `INSERT INTO tags (top_level_namespace_id, repository_id, manifest_id, name)
@@ -192,7 +225,13 @@ func (s *tagStore) CreateOrUpdate(ctx context.Context, t *models.Tag) error {
 		   id, created_at, updated_at`
 
 	row := s.db.QueryRowContext(ctx, q, t.NamespaceID, t.RepositoryID, t.ManifestID, t.Name)
-	if err := row.Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt); err != nil && err != sql.ErrNoRows {
+	err := row.Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt)
+	if err == sql.ErrNoRows {
+		// The tag already points to the manifest. The manifest_id column is left untouched here, as updating it would
+		// fire the trigger that queues the previous manifest for review by the online GC.
+		return s.touch(ctx, t)
+	}
+	if err != nil {
 		var pgErr *pgconn.PgError
 		// this can happen if the manifest is deleted by the online GC while attempting to tag an untagged manifest
 		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation {
@@ -203,3 +242,58 @@ func (s *tagStore) CreateOrUpdate(ctx context.Context, t *models.Tag) error {
 
 	return nil
 }
+
+// touch refreshes the last update timestamp of an existing tag.
+func (s *tagStore) touch(ctx context.Context, t *models.Tag) error {
+	defer metrics.InstrumentQuery("tag_touch")()
+	q := `UPDATE
+			tags
+		SET
+			updated_at = now()
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2
+			AND name = $3
+		RETURNING
+			id, created_at, updated_at`
+
+	row := s.db.QueryRowContext(ctx, q, t.NamespaceID, t.RepositoryID, t.Name)
+	if err := row.Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return fmt.Errorf("refreshing tag: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateIfManifest switches an existing tag to the manifest of t, but only if the tag currently points to the manifest
+// with ID currentManifestID. ErrTagManifestMismatch is returned otherwise, or if the tag does not exist. The tag row is
+// locked by the update, so concurrent conditional updates of the same tag are serialized and only one can succeed.
+func (s *tagStore) UpdateIfManifest(ctx context.Context, t *models.Tag, currentManifestID int64) error {
+	defer metrics.InstrumentQuery("tag_update_if_manifest")()
+	q := `UPDATE
+			tags
+		SET
+			manifest_id = $4,
+			updated_at = now()
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2
+			AND name = $3
+			AND manifest_id = $5
+		RETURNING
+			id, created_at, updated_at`
+
+	row := s.db.QueryRowContext(ctx, q, t.NamespaceID, t.RepositoryID, t.Name, t.ManifestID, currentManifestID)
+	if err := row.Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrTagManifestMismatch
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation {
+			return ErrManifestNotFound
+		}
+		return fmt.Errorf("updating tag: %w", err)
+	}
+
+	return nil
+}
@@ -278,11 +278,15 @@ func TestTagStore_CreateOrUpdate_Idempotent(t *testing.T) {
 	err := s.CreateOrUpdate(suite.ctx, tag)
 	require.NoError(t, err)
 	require.Empty(t, tag.UpdatedAt)
+	id, createdAt := tag.ID, tag.CreatedAt
 
-	// retry
+	// retry, only the last update timestamp should be refreshed
 	err = s.CreateOrUpdate(suite.ctx, tag)
 	require.NoError(t, err)
-	require.Empty(t, tag.UpdatedAt)
+	require.Equal(t, id, tag.ID)
+	require.Equal(t, createdAt, tag.CreatedAt)
+	require.NotEmpty(t, tag.UpdatedAt)
+	require.EqualValues(t, 1, tag.ManifestID)
 }
 
 func TestTagStore_CreateOrUpdate_ManifestNotFound(t *testing.T) {
@@ -301,3 +305,68 @@ func TestTagStore_CreateOrUpdate_ManifestNotFound(t *testing.T) {
 
 	require.EqualError(t, err, datastore.ErrManifestNotFound.Error())
 }
+
+func TestTagStore_UpdateIfManifest(t *testing.T) {
+	reloadRepositoryFixtures(t)
+	reloadManifestFixtures(t)
+	require.NoError(t, testutil.TruncateTables(suite.db, testutil.TagsTable))
+
+	s := datastore.NewTagStore(suite.db)
+	tag := &models.Tag{
+		NamespaceID:  1,
+		Name:         "1.0.0",
+		RepositoryID: 3,
+		ManifestID:   1,
+	}
+	require.NoError(t, s.CreateOrUpdate(suite.ctx, tag))
+
+	// switch tag to another manifest
+	tag.ManifestID = 2
+	err := s.UpdateIfManifest(suite.ctx, tag, 1)
+	require.NoError(t, err)
+	require.NotEmpty(t, tag.UpdatedAt)
+
+	found, err := s.FindByID(suite.ctx, tag.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), found.ManifestID)
+}
+
+func TestTagStore_UpdateIfManifest_Mismatch(t *testing.T) {
+	reloadRepositoryFixtures(t)
+	reloadManifestFixtures(t)
+	require.NoError(t, testutil.TruncateTables(suite.db, testutil.TagsTable))
+
+	s := datastore.NewTagStore(suite.db)
+	tag := &models.Tag{
+		NamespaceID:  1,
+		Name:         "1.0.0",
+		RepositoryID: 3,
+		ManifestID:   1,
+	}
+	require.NoError(t, s.CreateOrUpdate(suite.ctx, tag))
+
+	// the tag was already switched to manifest 2 by someone else
+	tag.ManifestID = 2
+	err := s.UpdateIfManifest(suite.ctx, tag, 2)
+	require.EqualError(t, err, datastore.ErrTagManifestMismatch.Error())
+
+	found, err := s.FindByID(suite.ctx, tag.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), found.ManifestID)
+}
+
+func TestTagStore_UpdateIfManifest_NotFound(t *testing.T) {
+	reloadRepositoryFixtures(t)
+	reloadManifestFixtures(t)
+	require.NoError(t, testutil.TruncateTables(suite.db, testutil.TagsTable))
+
+	s := datastore.NewTagStore(suite.db)
+	tag := &models.Tag{
+		NamespaceID:  1,
+		Name:         "1.0.0",
+		RepositoryID: 3,
+		ManifestID:   2,
+	}
+	err := s.UpdateIfManifest(suite.ctx, tag, 1)
+	require.EqualError(t, err, datastore.ErrTagManifestMismatch.Error())
+}
@@ -0,0 +1,4 @@
+INSERT INTO "blob_uploads"("id", "repository_path", "started_at")
+VALUES ('7e5b9a1c-3f0a-4d0e-9c34-1f2a6b8d0e01', 'gitlab-org/gitlab-test', '2020-03-02 17:50:26.461745+00'),
+       ('a3c1f2d4-8b6e-4a5f-b1d2-9e7c6f5a4b02', 'gitlab-org/gitlab-test/backend', '2020-03-02 17:57:43.283783+00'),
+       ('c9d8e7f6-5a4b-4c3d-8e2f-1a0b9c8d7e03', 'usage-group/sub-group-1/repository-1', '9999-12-31 23:59:59.999999+00');
@@ -0,0 +1,7 @@
+INSERT INTO "replication_queue"("id", "top_level_namespace_id", "repository_id", "created_at", "review_after", "review_count", "artifact", "digest", "tag")
+VALUES (1, 1, 3, '2020-03-02 17:57:43.283783+00', '2020-03-02 17:57:43.283783+00', 0, 'blob',
+        decode('01c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9', 'hex'), NULL),
+       (2, 1, 3, '2020-03-02 17:57:44.283783+00', '2020-03-02 17:57:44.283783+00', 0, 'manifest',
+        decode('01bd165db4bd480656a539e8e00db265377d162d6b98eebbfe5805d0fbd5144155', 'hex'), '1.0.0'),
+       (3, 1, 4, '2020-03-02 17:57:45.283783+00', '9999-12-31 23:59:59.999999+00', 2, 'manifest',
+        decode('0156b4b2228127fd594c5ab2925409713bd015ae9aa27eef2e0ddd90bcb2b1533f', 'hex'), NULL);
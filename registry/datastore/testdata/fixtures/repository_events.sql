@@ -0,0 +1,6 @@
+INSERT INTO "repository_events"("id", "top_level_namespace_id", "repository_id", "action", "actor", "digest", "tag", "previous_digest", "upload_uuid", "request_id", "created_at")
+VALUES (1, 1, 3, 'manifest_push', 'root', 'sha256:bd165db4bd480656a539e8e00db265377d162d6b98eebbfe5805d0fbd5144155', NULL, NULL, NULL, 'req-1', '2020-03-02 17:57:43.283783+00'),
+       (2, 1, 3, 'manifest_push', 'root', 'sha256:bd165db4bd480656a539e8e00db265377d162d6b98eebbfe5805d0fbd5144155', '1.0.0', NULL, NULL, 'req-1', '2020-03-02 17:57:43.283783+00'),
+       (3, 1, 3, 'tag_overwrite', 'jdoe', 'sha256:56b4b2228127fd594c5ab2925409713bd015ae9aa27eef2e0ddd90bcb2b1533f', '1.0.0', 'sha256:bd165db4bd480656a539e8e00db265377d162d6b98eebbfe5805d0fbd5144155', NULL, 'req-2', '2020-03-02 17:58:43.283783+00'),
+       (4, 1, 4, 'blob_push', 'root', 'sha256:68ced04f60ab5c7a5f1d0b0b4e7572c5a4c8cce44866513d30d9df1a15277d6b', NULL, NULL, '7cbe2a77-0f4a-4f1d-9a68-6bf4e1d3f0a2', 'req-3', '2020-03-02 17:59:43.283783+00'),
+       (5, 1, 3, 'tag_delete', 'jdoe', NULL, '1.0.0', NULL, NULL, 'req-4', '2020-04-15 09:47:26.461413+00');
@@ -0,0 +1,4 @@
+INSERT INTO "repository_retention_policies"("top_level_namespace_id", "repository_id", "created_at", "updated_at", "review_after", "review_count", "keep_n", "keep_regex", "older_than_seconds", "dry_run", "last_run_at", "last_run_deleted", "error")
+VALUES (1, 3, '2020-03-02 17:57:43.283783+00', NULL, '2020-03-03 17:57:43.283783+00', 0, 5, NULL, 0, FALSE, '2020-03-02 17:57:43.283783+00', 3, NULL),
+       (1, 4, '2020-03-02 17:57:44.283783+00', '2020-03-02 18:12:44.283783+00', '2020-03-02 18:12:44.283783+00', 1, 0, 'v.*', 2592000, TRUE, NULL, 0, 'listing repository tags: timeout'),
+       (2, 6, '2020-03-02 17:57:45.283783+00', NULL, '9999-12-31 23:59:59.999999+00', 0, 10, NULL, 0, FALSE, NULL, 0, NULL);
@@ -0,0 +1,2 @@
+INSERT INTO "repository_statistics"("top_level_namespace_id", "repository_id", "tags_count", "manifests_count", "size", "last_published_at", "calculated_at")
+VALUES (1, 3, 0, 1, 1024, NULL, '2020-03-02 17:57:43.283783+00');
@@ -0,0 +1,5 @@
+INSERT INTO "repository_storage_moves"("top_level_namespace_id", "repository_id", "created_at", "updated_at", "review_after", "review_count", "state", "error")
+VALUES (1, 3, '2020-03-02 17:57:43.283783+00', '2020-03-02 18:57:43.283783+00', '2020-03-02 18:57:43.283783+00', 0, 'finished', NULL),
+       (1, 4, '2020-03-02 17:57:44.283783+00', '2020-03-02 18:12:44.283783+00', '2020-03-02 18:12:44.283783+00', 1, 'cleanup', 'deleting blob: timeout'),
+       (2, 6, '2020-03-02 17:57:45.283783+00', NULL, '2020-03-02 17:57:45.283783+00', 0, 'scheduled', NULL),
+       (2, 7, '2020-03-02 17:57:46.283783+00', '2020-03-02 17:58:46.283783+00', '9999-12-31 23:59:59.999999+00', 0, 'cleanup', NULL);
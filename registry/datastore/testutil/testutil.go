@@ -27,21 +27,27 @@ type trigger struct {
 }
 
 const (
-	NamespacesTable            table = "top_level_namespaces"
-	RepositoriesTable          table = "repositories"
-	MediaTypesTable            table = "media_types"
-	ManifestsTable             table = "manifests"
-	ManifestReferencesTable    table = "manifest_references"
-	BlobsTable                 table = "blobs"
-	RepositoryBlobsTable       table = "repository_blobs"
-	LayersTable                table = "layers"
-	TagsTable                  table = "tags"
-	GCBlobReviewQueueTable     table = "gc_blob_review_queue"
-	GCBlobsConfigurationsTable table = "gc_blobs_configurations"
-	GCBlobsLayersTable         table = "gc_blobs_layers"
-	GCManifestReviewQueueTable table = "gc_manifest_review_queue"
-	GCTmpBlobsManifestsTable   table = "gc_tmp_blobs_manifests"
-	GCReviewAfterDefaultsTable table = "gc_review_after_defaults"
+	NamespacesTable             table = "top_level_namespaces"
+	RepositoriesTable           table = "repositories"
+	MediaTypesTable             table = "media_types"
+	ManifestsTable              table = "manifests"
+	ManifestReferencesTable     table = "manifest_references"
+	BlobsTable                  table = "blobs"
+	RepositoryBlobsTable        table = "repository_blobs"
+	LayersTable                 table = "layers"
+	TagsTable                   table = "tags"
+	GCBlobReviewQueueTable      table = "gc_blob_review_queue"
+	GCBlobsConfigurationsTable  table = "gc_blobs_configurations"
+	GCBlobsLayersTable          table = "gc_blobs_layers"
+	GCManifestReviewQueueTable  table = "gc_manifest_review_queue"
+	GCTmpBlobsManifestsTable    table = "gc_tmp_blobs_manifests"
+	GCReviewAfterDefaultsTable  table = "gc_review_after_defaults"
+	RepositoryEventsTable       table = "repository_events"
+	ReplicationQueueTable       table = "replication_queue"
+	BlobUploadsTable            table = "blob_uploads"
+	RepositoryStorageMovesTable table = "repository_storage_moves"
+	RetentionPoliciesTable      table = "repository_retention_policies"
+	RepositoryStatisticsTable   table = "repository_statistics"
 )
 
 // AllTables represents all tables in the test database.
@@ -60,6 +66,12 @@ var (
 		GCBlobsLayersTable,
 		GCManifestReviewQueueTable,
 		GCTmpBlobsManifestsTable,
+		RepositoryEventsTable,
+		ReplicationQueueTable,
+		BlobUploadsTable,
+		RepositoryStorageMovesTable,
+		RetentionPoliciesTable,
+		RepositoryStatisticsTable,
 	}
 
 	GCTrackBlobUploadsTrigger = trigger{
@@ -196,12 +208,13 @@ func NewDSNFromConfig(config configuration.Database) (*datastore.DSN, error) {
 	return dsn, nil
 }
 
-func newDB(dsn *datastore.DSN, logLevel logrus.Level, logOut io.Writer, poolConfig datastore.PoolConfig) (*datastore.DB, error) {
+func newDB(dsn *datastore.DSN, logLevel logrus.Level, logOut io.Writer, poolConfig datastore.PoolConfig, opts ...datastore.OpenOption) (*datastore.DB, error) {
 	log := logrus.New()
 	log.SetLevel(logLevel)
 	log.SetOutput(logOut)
 
-	db, err := datastore.Open(dsn, datastore.WithLogger(logrus.NewEntry(log)))
+	opts = append([]datastore.OpenOption{datastore.WithLogger(logrus.NewEntry(log))}, opts...)
+	db, err := datastore.Open(dsn, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("opening database connection: %w", err)
 	}
@@ -211,7 +224,8 @@ func newDB(dsn *datastore.DSN, logLevel logrus.Level, logOut io.Writer, poolConf
 }
 
 // NewDBFromEnv generates a new datastore.DB and opens the underlying connection based on environment variable settings.
-func NewDBFromEnv() (*datastore.DB, error) {
+// Additional options are passed to datastore.Open.
+func NewDBFromEnv(opts ...datastore.OpenOption) (*datastore.DB, error) {
 	dsn, err := NewDSNFromEnv()
 	if err != nil {
 		return nil, err
@@ -244,7 +258,7 @@ func NewDBFromEnv() (*datastore.DB, error) {
 		poolConfig.MaxOpen = poolMaxOpen
 	}
 
-	return newDB(dsn, logLevel, logOut, poolConfig)
+	return newDB(dsn, logLevel, logOut, poolConfig, opts...)
 }
 
 // NewDBFromConfig generates a new datastore.DB and opens the underlying connection based on configuration settings.
@@ -0,0 +1,125 @@
+package registry
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/docker/distribution/configuration"
+	log "github.com/sirupsen/logrus"
+)
+
+// debugAuthenticator restricts access to the sensitive endpoints of the debug server, such as pprof and expvar, to
+// clients presenting the configured basic auth credentials or a verified client certificate.
+type debugAuthenticator struct {
+	username   string
+	password   string
+	clientCert bool
+}
+
+func newDebugAuthenticator(cfg configuration.DebugAuth) *debugAuthenticator {
+	return &debugAuthenticator{
+		username:   cfg.Username,
+		password:   cfg.Password,
+		clientCert: cfg.ClientCertificate,
+	}
+}
+
+// authenticated reports whether r is allowed to access a protected endpoint. All requests are allowed if no
+// authentication method is configured.
+func (a *debugAuthenticator) authenticated(r *http.Request) bool {
+	if a.username == "" && !a.clientCert {
+		return true
+	}
+
+	if a.clientCert && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return true
+	}
+	if a.username != "" {
+		if username, password, ok := r.BasicAuth(); ok {
+			userOK := subtle.ConstantTimeCompare([]byte(username), []byte(a.username)) == 1
+			passOK := subtle.ConstantTimeCompare([]byte(password), []byte(a.password)) == 1
+			if userOK && passOK {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// wrap returns a handler that only calls h for authenticated requests.
+func (a *debugAuthenticator) wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.authenticated(r) {
+			log.WithFields(log.Fields{"path": r.URL.Path, "remote_addr": r.RemoteAddr}).Warn("unauthenticated debug server request")
+			if a.username != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="registry debug"`)
+			}
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// registerPprofHandlers registers the pprof handlers on mux, behind auth.
+func registerPprofHandlers(mux *http.ServeMux, auth *debugAuthenticator) {
+	mux.Handle("/debug/pprof/", auth.wrap(http.HandlerFunc(pprof.Index)))
+	mux.Handle("/debug/pprof/cmdline", auth.wrap(http.HandlerFunc(pprof.Cmdline)))
+	mux.Handle("/debug/pprof/profile", auth.wrap(http.HandlerFunc(pprof.Profile)))
+	mux.Handle("/debug/pprof/symbol", auth.wrap(http.HandlerFunc(pprof.Symbol)))
+	mux.Handle("/debug/pprof/trace", auth.wrap(http.HandlerFunc(pprof.Trace)))
+}
+
+// newDebugTLSListener creates a TLS listener for the debug server. If client CAs are configured, client certificates
+// are verified when presented, but not required, so that health checks keep working without one.
+func newDebugTLSListener(config *configuration.Configuration) (net.Listener, error) {
+	cfg := config.HTTP.Debug.TLS
+	cert, err := tls.LoadX509KeyPair(cfg.Certificate, cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+	tlsConf := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if len(cfg.ClientCAs) > 0 {
+		pool, err := loadCertPool(cfg.ClientCAs)
+		if err != nil {
+			return nil, err
+		}
+		tlsConf.ClientAuth = tls.VerifyClientCertIfGiven
+		tlsConf.ClientCAs = pool
+	}
+
+	ln, err := net.Listen("tcp", config.HTTP.Debug.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return tls.NewListener(ln, tlsConf), nil
+}
+
+// loadCertPool creates a certificate pool from the PEM encoded CA certificates in the given files.
+func loadCertPool(files []string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+
+	for _, ca := range files {
+		caPem, err := ioutil.ReadFile(ca)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok := pool.AppendCertsFromPEM(caPem); !ok {
+			return nil, fmt.Errorf("could not add CA to pool")
+		}
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugAuthenticator(t *testing.T) {
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+
+	tt := []struct {
+		name     string
+		cfg      configuration.DebugAuth
+		username string
+		password string
+		tls      *tls.ConnectionState
+		expected bool
+	}{
+		{
+			name:     "disabled",
+			expected: true,
+		},
+		{
+			name:     "basic auth",
+			cfg:      configuration.DebugAuth{Username: "admin", Password: "secret"},
+			username: "admin",
+			password: "secret",
+			expected: true,
+		},
+		{
+			name:     "basic auth with wrong password",
+			cfg:      configuration.DebugAuth{Username: "admin", Password: "secret"},
+			username: "admin",
+			password: "wrong",
+		},
+		{
+			name: "basic auth without credentials",
+			cfg:  configuration.DebugAuth{Username: "admin", Password: "secret"},
+		},
+		{
+			name:     "client certificate",
+			cfg:      configuration.DebugAuth{ClientCertificate: true},
+			tls:      verified,
+			expected: true,
+		},
+		{
+			name: "client certificate not presented",
+			cfg:  configuration.DebugAuth{ClientCertificate: true},
+			tls:  &tls.ConnectionState{},
+		},
+		{
+			name:     "either method with client certificate",
+			cfg:      configuration.DebugAuth{Username: "admin", Password: "secret", ClientCertificate: true},
+			tls:      verified,
+			expected: true,
+		},
+		{
+			name:     "either method with basic auth",
+			cfg:      configuration.DebugAuth{Username: "admin", Password: "secret", ClientCertificate: true},
+			username: "admin",
+			password: "secret",
+			expected: true,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			a := newDebugAuthenticator(test.cfg)
+			h := a.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			r := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+			if test.username != "" {
+				r.SetBasicAuth(test.username, test.password)
+			}
+			r.TLS = test.tls
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if test.expected {
+				require.Equal(t, http.StatusOK, w.Code)
+			} else {
+				require.Equal(t, http.StatusUnauthorized, w.Code)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/notifications"
 	"github.com/docker/distribution/registry/datastore"
 	"github.com/docker/distribution/registry/datastore/models"
 	"github.com/docker/distribution/registry/gc/internal/metrics"
@@ -33,6 +34,7 @@ type BlobWorker struct {
 	*baseWorker
 	vacuum         *storage.Vacuum
 	storageTimeout time.Duration
+	listener       notifications.GCListener
 }
 
 // BlobWorkerOption provides functional options for NewBlobWorker.
@@ -60,6 +62,13 @@ func WithBlobStorageTimeout(d time.Duration) BlobWorkerOption {
 	}
 }
 
+// WithBlobEventListener sets a listener that is notified of every blob deleted by the worker. Disabled by default.
+func WithBlobEventListener(l notifications.GCListener) BlobWorkerOption {
+	return func(w *BlobWorker) {
+		w.listener = l
+	}
+}
+
 func (w *BlobWorker) applyDefaults() {
 	w.baseWorker.applyDefaults()
 	if w.storageTimeout == 0 {
@@ -154,6 +163,13 @@ func (w *BlobWorker) processTask(ctx context.Context) (bool, error) {
 		return true, fmt.Errorf("committing database transaction: %w", err)
 	}
 
+	if dangling && w.listener != nil {
+		if err := w.listener.BlobDeleted(t.Digest); err != nil {
+			// the blob is gone for good, so there is no point in failing the task
+			log.WithError(err).Error("failed to notify blob deletion")
+		}
+	}
+
 	return true, nil
 }
 
@@ -202,7 +218,7 @@ func (w *BlobWorker) deleteBlob(ctx context.Context, tx datastore.Transactor, t
 	report = metrics.BlobDatabaseDelete()
 	if err = bs.Delete(ctx, t.Digest); err != nil {
 		switch {
-		case err == datastore.ErrNotFound:
+		case errors.Is(err, datastore.ErrNotFound):
 			// this is unexpected, but it's not a show stopper for GC
 			log.Warn("blob no longer exists on database")
 			return nil
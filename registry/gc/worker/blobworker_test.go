@@ -94,6 +94,17 @@ func Test_NewBlobWorker_WithStorageDeadline(t *testing.T) {
 	require.Equal(t, d, w.storageTimeout)
 }
 
+func Test_NewBlobWorker_WithEventListener(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	l := &fakeGCListener{}
+	dbMock := storemock.NewMockHandler(ctrl)
+	driverMock := drivermock.NewMockStorageDeleter(ctrl)
+	w := NewBlobWorker(dbMock, driverMock, WithBlobEventListener(l))
+
+	require.Equal(t, l, w.listener)
+}
+
 func fakeBlobTask() *models.GCBlobTask {
 	return &models.GCBlobTask{
 		Digest:      "sha256:c6f988f4874bb0add23a778f753c65efe992244e148a1d2ec2a8b664fb66bbd1",
@@ -133,6 +144,73 @@ func TestBlobWorker_processTask(t *testing.T) {
 	require.True(t, found)
 }
 
+func TestBlobWorker_processTask_EventListener(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockBlobStores(t, ctrl)
+	clockMock := stubClock(t, time.Now())
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	driverMock := drivermock.NewMockStorageDeleter(ctrl)
+	l := &fakeGCListener{}
+	w := NewBlobWorker(dbMock, driverMock, WithBlobEventListener(l))
+
+	dbCtx := testutil.IsContextWithDeadline{Deadline: clockMock.Now().Add(defaultTxTimeout)}
+	driverCtx := testutil.IsContextWithDeadline{Deadline: clockMock.Now().Add(defaultStorageTimeout)}
+	bt := fakeBlobTask()
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(dbCtx, nil).Return(txMock, nil).Times(1),
+		btsMock.EXPECT().Next(dbCtx).Return(bt, nil).Times(1),
+		btsMock.EXPECT().IsDangling(dbCtx, bt).Return(true, nil).Times(1),
+		driverMock.EXPECT().Delete(driverCtx, blobPath(bt.Digest)).Return(nil).Times(1),
+		bsMock.EXPECT().FindByDigest(dbCtx, bt.Digest).Return(&models.Blob{}, nil).Times(1),
+		bsMock.EXPECT().Delete(dbCtx, bt.Digest).Return(nil).Times(1),
+		btsMock.EXPECT().Delete(dbCtx, bt).Return(nil).Times(1),
+		txMock.EXPECT().Commit().Return(nil).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	found, err := w.processTask(context.Background())
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []digest.Digest{bt.Digest}, l.blobs)
+}
+
+func TestBlobWorker_processTask_EventListenerError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockBlobStores(t, ctrl)
+	clockMock := stubClock(t, time.Now())
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	driverMock := drivermock.NewMockStorageDeleter(ctrl)
+	l := &fakeGCListener{err: fakeErrorA}
+	w := NewBlobWorker(dbMock, driverMock, WithBlobEventListener(l))
+
+	dbCtx := testutil.IsContextWithDeadline{Deadline: clockMock.Now().Add(defaultTxTimeout)}
+	driverCtx := testutil.IsContextWithDeadline{Deadline: clockMock.Now().Add(defaultStorageTimeout)}
+	bt := fakeBlobTask()
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(dbCtx, nil).Return(txMock, nil).Times(1),
+		btsMock.EXPECT().Next(dbCtx).Return(bt, nil).Times(1),
+		btsMock.EXPECT().IsDangling(dbCtx, bt).Return(true, nil).Times(1),
+		driverMock.EXPECT().Delete(driverCtx, blobPath(bt.Digest)).Return(nil).Times(1),
+		bsMock.EXPECT().FindByDigest(dbCtx, bt.Digest).Return(&models.Blob{}, nil).Times(1),
+		bsMock.EXPECT().Delete(dbCtx, bt.Digest).Return(nil).Times(1),
+		btsMock.EXPECT().Delete(dbCtx, bt).Return(nil).Times(1),
+		txMock.EXPECT().Commit().Return(nil).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	// a notification failure must not fail the task
+	found, err := w.processTask(context.Background())
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Len(t, l.blobs, 1)
+}
+
 func TestBlobWorker_processTask_BeginTxError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	clockMock := stubClock(t, time.Now())
@@ -332,7 +410,7 @@ func TestBlobWorker_processTask_StoreDeleteNotFoundError(t *testing.T) {
 		btsMock.EXPECT().IsDangling(dbCtx, bt).Return(true, nil).Times(1),
 		driverMock.EXPECT().Delete(driverCtx, blobPath(bt.Digest)).Return(nil).Times(1),
 		bsMock.EXPECT().FindByDigest(dbCtx, bt.Digest).Return(&models.Blob{}, nil).Times(1),
-		bsMock.EXPECT().Delete(dbCtx, bt.Digest).Return(datastore.ErrNotFound).Times(1),
+		bsMock.EXPECT().Delete(dbCtx, bt.Digest).Return(datastore.ErrBlobNotFound).Times(1),
 		btsMock.EXPECT().Delete(dbCtx, bt).Return(nil).Times(1),
 		txMock.EXPECT().Commit().Return(nil).Times(1),
 		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
@@ -7,10 +7,12 @@ import (
 	"time"
 
 	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/notifications"
 	"github.com/docker/distribution/registry/datastore"
 	"github.com/docker/distribution/registry/datastore/models"
 	"github.com/docker/distribution/registry/gc/internal/metrics"
 	"github.com/hashicorp/go-multierror"
+	"github.com/opencontainers/go-digest"
 	"github.com/sirupsen/logrus"
 )
 
@@ -18,6 +20,7 @@ var (
 	// for test purposes (mocking)
 	manifestTaskStoreConstructor = datastore.NewGCManifestTaskStore
 	manifestStoreConstructor     = datastore.NewManifestStore
+	repositoryStoreConstructor   = func(db datastore.Queryer) datastore.RepositoryStore { return datastore.NewRepositoryStore(db) }
 )
 
 var _ Worker = (*ManifestWorker)(nil)
@@ -27,6 +30,7 @@ var _ Worker = (*ManifestWorker)(nil)
 // deletes it from the database.
 type ManifestWorker struct {
 	*baseWorker
+	listener notifications.GCListener
 }
 
 // ManifestWorkerOption provides functional options for NewManifestWorker.
@@ -46,6 +50,14 @@ func WithManifestTxTimeout(d time.Duration) ManifestWorkerOption {
 	}
 }
 
+// WithManifestEventListener sets a listener that is notified of every manifest deleted by the worker. Disabled by
+// default.
+func WithManifestEventListener(l notifications.GCListener) ManifestWorkerOption {
+	return func(w *ManifestWorker) {
+		w.listener = l
+	}
+}
+
 // NewManifestWorker creates a new BlobWorker.
 func NewManifestWorker(db datastore.Handler, opts ...ManifestWorkerOption) *ManifestWorker {
 	w := &ManifestWorker{baseWorker: &baseWorker{db: db}}
@@ -116,8 +128,17 @@ func (w *ManifestWorker) processTask(ctx context.Context) (bool, error) {
 		return true, err
 	}
 
+	var deleted *deletedManifest
 	if dangling {
 		log.Info("the manifest is dangling")
+		if w.listener != nil {
+			// the manifest details must be looked up before deleting it, as the task only holds its ID
+			deleted, err = w.findDeletedManifest(ctx, tx, t)
+			if err != nil {
+				// log and continue, the lack of a notification is not a reason to hold back GC
+				log.WithError(err).Error("failed to find manifest details for notification")
+			}
+		}
 		if err := w.deleteManifest(ctx, tx, t); err != nil {
 			return true, err
 		}
@@ -134,9 +155,42 @@ func (w *ManifestWorker) processTask(ctx context.Context) (bool, error) {
 		return true, fmt.Errorf("committing database transaction: %w", err)
 	}
 
+	if deleted != nil {
+		if err := w.listener.ManifestDeleted(deleted.repository, deleted.digest); err != nil {
+			log.WithError(err).Error("failed to notify manifest deletion")
+		}
+	}
+
 	return true, nil
 }
 
+// deletedManifest holds the details of a manifest deleted by the worker, used for notification purposes.
+type deletedManifest struct {
+	repository string
+	digest     digest.Digest
+}
+
+func (w *ManifestWorker) findDeletedManifest(ctx context.Context, tx datastore.Transactor, t *models.GCManifestTask) (*deletedManifest, error) {
+	rs := repositoryStoreConstructor(tx)
+
+	r, err := rs.FindByID(ctx, t.RepositoryID)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, fmt.Errorf("finding repository %d: %w", t.RepositoryID, datastore.ErrRepositoryNotFound)
+	}
+	m, err := rs.FindManifestByID(ctx, r, t.ManifestID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("finding manifest %d in repository %d: %w", t.ManifestID, t.RepositoryID, datastore.ErrManifestNotFound)
+	}
+
+	return &deletedManifest{repository: r.Path, digest: m.Digest}, nil
+}
+
 func (w *ManifestWorker) deleteManifest(ctx context.Context, tx datastore.Transactor, t *models.GCManifestTask) error {
 	log := dcontext.GetLogger(ctx)
 
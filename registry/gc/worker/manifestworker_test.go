@@ -40,6 +40,40 @@ func mockManifestStores(tb testing.TB, ctrl *gomock.Controller) {
 	})
 }
 
+// fakeRepositoryStore is a minimal datastore.RepositoryStore stub. Calling any method other than FindByID and
+// FindManifestByID panics.
+type fakeRepositoryStore struct {
+	datastore.RepositoryStore
+	repository *models.Repository
+	manifest   *models.Manifest
+	err        error
+}
+
+// FindByID implements datastore.RepositoryStore.
+func (s *fakeRepositoryStore) FindByID(_ context.Context, id int64) (*models.Repository, error) {
+	if s.err != nil || s.repository == nil || s.repository.ID != id {
+		return nil, s.err
+	}
+	return s.repository, nil
+}
+
+// FindManifestByID implements datastore.RepositoryStore.
+func (s *fakeRepositoryStore) FindManifestByID(_ context.Context, _ *models.Repository, id int64) (*models.Manifest, error) {
+	if s.manifest == nil || s.manifest.ID != id {
+		return nil, nil
+	}
+	return s.manifest, nil
+}
+
+func stubRepositoryStore(tb testing.TB, rs datastore.RepositoryStore) {
+	tb.Helper()
+
+	bkp := repositoryStoreConstructor
+	repositoryStoreConstructor = func(db datastore.Queryer) datastore.RepositoryStore { return rs }
+
+	tb.Cleanup(func() { repositoryStoreConstructor = bkp })
+}
+
 func Test_NewManifestWorker(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
@@ -72,6 +106,16 @@ func Test_NewManifestWorker_WithTxDeadline(t *testing.T) {
 	require.Equal(t, d, w.txTimeout)
 }
 
+func Test_NewManifestWorker_WithEventListener(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	l := &fakeGCListener{}
+	dbMock := storemock.NewMockHandler(ctrl)
+	w := NewManifestWorker(dbMock, WithManifestEventListener(l))
+
+	require.Equal(t, l, w.listener)
+}
+
 func fakeManifestTask() *models.GCManifestTask {
 	return &models.GCManifestTask{
 		RepositoryID: 1,
@@ -108,6 +152,102 @@ func TestManifestWorker_processTask(t *testing.T) {
 	require.True(t, found)
 }
 
+func TestManifestWorker_processTask_EventListener(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockManifestStores(t, ctrl)
+	clockMock := stubClock(t, time.Now())
+
+	mt := fakeManifestTask()
+	r := &models.Repository{ID: mt.RepositoryID, Path: "foo/bar"}
+	m := &models.Manifest{
+		RepositoryID: mt.RepositoryID,
+		ID:           mt.ManifestID,
+		Digest:       "sha256:c6f988f4874bb0add23a778f753c65efe992244e148a1d2ec2a8b664fb66bbd1",
+	}
+	stubRepositoryStore(t, &fakeRepositoryStore{repository: r, manifest: m})
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	l := &fakeGCListener{}
+	w := NewManifestWorker(dbMock, WithManifestEventListener(l))
+
+	ctx := testutil.IsContextWithDeadline{Deadline: clockMock.Now().Add(defaultTxTimeout)}
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(ctx, nil).Return(txMock, nil).Times(1),
+		mtsMock.EXPECT().Next(ctx).Return(mt, nil).Times(1),
+		mtsMock.EXPECT().IsDangling(ctx, mt).Return(true, nil).Times(1),
+		msMock.EXPECT().Delete(ctx, &models.Manifest{RepositoryID: mt.RepositoryID, ID: mt.ManifestID}).Return(true, nil).Times(1),
+		txMock.EXPECT().Commit().Return(nil).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	found, err := w.processTask(context.Background())
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []deletedArtifact{{repository: r.Path, digest: m.Digest}}, l.manifests)
+}
+
+func TestManifestWorker_processTask_EventListenerManifestNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockManifestStores(t, ctrl)
+	clockMock := stubClock(t, time.Now())
+
+	mt := fakeManifestTask()
+	stubRepositoryStore(t, &fakeRepositoryStore{repository: &models.Repository{ID: mt.RepositoryID, Path: "foo/bar"}})
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	l := &fakeGCListener{}
+	w := NewManifestWorker(dbMock, WithManifestEventListener(l))
+
+	ctx := testutil.IsContextWithDeadline{Deadline: clockMock.Now().Add(defaultTxTimeout)}
+	m := &models.Manifest{RepositoryID: mt.RepositoryID, ID: mt.ManifestID}
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(ctx, nil).Return(txMock, nil).Times(1),
+		mtsMock.EXPECT().Next(ctx).Return(mt, nil).Times(1),
+		mtsMock.EXPECT().IsDangling(ctx, mt).Return(true, nil).Times(1),
+		msMock.EXPECT().Delete(ctx, m).Return(true, nil).Times(1),
+		txMock.EXPECT().Commit().Return(nil).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	// the manifest is still deleted, but no notification is sent
+	found, err := w.processTask(context.Background())
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Empty(t, l.manifests)
+}
+
+func TestManifestWorker_processTask_EventListenerNotDangling(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockManifestStores(t, ctrl)
+	clockMock := stubClock(t, time.Now())
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	l := &fakeGCListener{}
+	w := NewManifestWorker(dbMock, WithManifestEventListener(l))
+
+	ctx := testutil.IsContextWithDeadline{Deadline: clockMock.Now().Add(defaultTxTimeout)}
+	mt := fakeManifestTask()
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(ctx, nil).Return(txMock, nil).Times(1),
+		mtsMock.EXPECT().Next(ctx).Return(mt, nil).Times(1),
+		mtsMock.EXPECT().IsDangling(ctx, mt).Return(false, nil).Times(1),
+		mtsMock.EXPECT().Delete(ctx, mt).Return(nil).Times(1),
+		txMock.EXPECT().Commit().Return(nil).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	found, err := w.processTask(context.Background())
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Empty(t, l.manifests)
+}
+
 func TestManifestWorker_processTask_BeginTxError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	clockMock := stubClock(t, time.Now())
@@ -11,6 +11,7 @@ import (
 	dbmock "github.com/docker/distribution/registry/datastore/mocks"
 	"github.com/docker/distribution/registry/internal/testutil"
 	"github.com/golang/mock/gomock"
+	"github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -47,6 +48,30 @@ var (
 	fakeErrorB = errors.New("error B")
 )
 
+type deletedArtifact struct {
+	repository string
+	digest     digest.Digest
+}
+
+// fakeGCListener records all deletion notifications and returns err (if any) for each.
+type fakeGCListener struct {
+	blobs     []digest.Digest
+	manifests []deletedArtifact
+	err       error
+}
+
+// BlobDeleted implements notifications.GCListener.
+func (l *fakeGCListener) BlobDeleted(dgst digest.Digest) error {
+	l.blobs = append(l.blobs, dgst)
+	return l.err
+}
+
+// ManifestDeleted implements notifications.GCListener.
+func (l *fakeGCListener) ManifestDeleted(repo string, dgst digest.Digest) error {
+	l.manifests = append(l.manifests, deletedArtifact{repository: repo, digest: dgst})
+	return l.err
+}
+
 func Test_baseWorker_Name(t *testing.T) {
 	w := &baseWorker{name: "foo"}
 	require.Equal(t, "foo", w.Name())
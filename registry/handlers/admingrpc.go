@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/docker/distribution"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/notifications"
+	"github.com/docker/distribution/reference"
+	adminv1 "github.com/docker/distribution/registry/api/admin/v1"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"gitlab.com/gitlab-org/labkit/correlation"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// adminStreamPageSize is the number of rows read from the database at once while streaming large listings.
+const adminStreamPageSize = 1000
+
+// AdminServer returns the implementation of the gRPC admin API, backed by the metadata database of app. It panics if
+// the metadata database is not enabled.
+func (app *App) AdminServer() adminv1.AdminServer {
+	if app.db == nil {
+		panic("admin gRPC API: requires the metadata database to be enabled")
+	}
+
+	return &adminServer{app: app}
+}
+
+// adminServer implements adminv1.AdminServer.
+type adminServer struct {
+	adminv1.UnimplementedAdminServer
+	app *App
+}
+
+func newTimestamp(t sql.NullTime) *timestamppb.Timestamp {
+	if !t.Valid {
+		return nil
+	}
+	return timestamppb.New(t.Time)
+}
+
+func (s *adminServer) findRepository(ctx context.Context, path string) (*models.Repository, error) {
+	if _, err := reference.ParseNormalizedNamed(path); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid repository path %q", path)
+	}
+
+	r, err := datastore.NewRepositoryStore(s.app.db).FindByPath(ctx, path)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if r == nil {
+		return nil, status.Errorf(codes.NotFound, "repository %q not found", path)
+	}
+
+	return r, nil
+}
+
+// ListRepositories streams repositories sorted by path, reading them from the database in pages.
+func (s *adminServer) ListRepositories(req *adminv1.ListRepositoriesRequest, stream adminv1.Admin_ListRepositoriesServer) error {
+	ctx := stream.Context()
+	rStore := datastore.NewRepositoryStore(s.app.db)
+
+	last := req.GetLast()
+	for {
+		rr, err := rStore.FindAllPaginated(ctx, adminStreamPageSize, last, "", req.GetIncludeArchived())
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		for _, r := range rr {
+			msg := &adminv1.Repository{
+				Path:      r.Path,
+				Name:      r.Name,
+				Archived:  r.Archived,
+				CreatedAt: timestamppb.New(r.CreatedAt),
+				UpdatedAt: newTimestamp(r.UpdatedAt),
+			}
+			if req.GetIncludeCounts() {
+				tags, err := rStore.TagsCount(ctx, r)
+				if err != nil {
+					return status.Error(codes.Internal, err.Error())
+				}
+				manifests, err := rStore.ManifestsCount(ctx, r)
+				if err != nil {
+					return status.Error(codes.Internal, err.Error())
+				}
+				msg.TagsCount = int64(tags)
+				msg.ManifestsCount = int64(manifests)
+			}
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+
+		if len(rr) < adminStreamPageSize {
+			return nil
+		}
+		last = rr[len(rr)-1].Path
+	}
+}
+
+// ListTags streams the tags of a repository sorted by name, reading them from the database in pages.
+func (s *adminServer) ListTags(req *adminv1.ListTagsRequest, stream adminv1.Admin_ListTagsServer) error {
+	ctx := stream.Context()
+
+	r, err := s.findRepository(ctx, req.GetRepository())
+	if err != nil {
+		return err
+	}
+	rStore := datastore.NewRepositoryStore(s.app.db)
+
+	last := req.GetLast()
+	for {
+		tt, err := rStore.TagsDetailPaginated(ctx, r, adminStreamPageSize, last)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		for _, t := range tt {
+			if err := stream.Send(&adminv1.Tag{
+				Name:      t.Name,
+				Digest:    t.Digest.String(),
+				MediaType: t.MediaType,
+				CreatedAt: timestamppb.New(t.CreatedAt),
+				UpdatedAt: newTimestamp(t.UpdatedAt),
+			}); err != nil {
+				return err
+			}
+		}
+
+		if len(tt) < adminStreamPageSize {
+			return nil
+		}
+		last = tt[len(tt)-1].Name
+	}
+}
+
+// DeleteTag deletes a tag from a repository in the same way as the HTTP API, recording it in the audit log, if
+// enabled. Notifications are not sent, as these are bound to HTTP requests.
+func (s *adminServer) DeleteTag(ctx context.Context, req *adminv1.DeleteTagRequest) (*adminv1.DeleteTagResponse, error) {
+	if s.app.readOnly {
+		return nil, status.Error(codes.FailedPrecondition, "registry is in read-only mode")
+	}
+	if !anchoredTagRegexp.MatchString(req.GetTag()) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid tag %q", req.GetTag())
+	}
+	if _, err := s.findRepository(ctx, req.GetRepository()); err != nil {
+		return nil, err
+	}
+
+	unlock, err := s.app.lockTag(ctx, req.GetRepository(), req.GetTag())
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	defer unlock()
+
+	desc, err := s.app.deleteTag(ctx, req.GetRepository(), req.GetTag(), func(desc distribution.Descriptor) {
+		s.app.notifyAdminTagDeleted(ctx, req.GetRepository(), req.GetTag(), desc)
+	})
+	if err != nil {
+		var tagErr distribution.ErrTagUnknown
+		var repoErr distribution.ErrRepositoryUnknown
+		switch {
+		case errors.As(err, &tagErr), errors.As(err, &repoErr):
+			return nil, status.Error(codes.NotFound, err.Error())
+		case errors.Is(err, errRepositoryArchived):
+			return nil, status.Errorf(codes.FailedPrecondition, "repository %q is archived", req.GetRepository())
+		case errors.Is(err, context.DeadlineExceeded):
+			return nil, status.Error(codes.Unavailable, err.Error())
+		default:
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+	dcontext.GetLoggerWithFields(ctx, map[interface{}]interface{}{
+		"repository": req.GetRepository(),
+		"tag":        req.GetTag(),
+		"digest":     desc.Digest,
+	}).Info("tag deleted through admin API")
+
+	if s.app.auditLogger != nil {
+		s.app.auditLogger.Log(audit.Event{
+			Action:     audit.ActionTagDelete,
+			Repository: req.GetRepository(),
+			Digest:     desc.Digest,
+			Tag:        req.GetTag(),
+			RequestID:  dcontext.GetRequestID(ctx),
+		})
+	}
+
+	return &adminv1.DeleteTagResponse{Digest: desc.Digest.String(), MediaType: desc.MediaType}, nil
+}
+
+// notifyAdminTagDeleted sends the notification of a tag deleted through the admin API. As there is no HTTP request, the
+// request record only holds the correlation ID of the call and the address of the client.
+func (app *App) notifyAdminTagDeleted(ctx context.Context, repoPath, tag string, desc distribution.Descriptor) {
+	named, err := reference.WithName(repoPath)
+	if err != nil {
+		dcontext.GetLogger(ctx).WithError(err).Error("error dispatching tag deleted to listener")
+		return
+	}
+
+	request := notifications.RequestRecord{ID: correlation.ExtractFromContext(ctx), Method: "DeleteTag"}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		request.Addr = p.Addr.String()
+	}
+	bridge := notifications.NewBridge(v2.NewURLBuilder(&app.httpHost, false), app.events.source, notifications.ActorRecord{},
+		request, app.events.sink, app.Config.Notifications.EventConfig.IncludeReferences)
+	if err := bridge.TagDeleted(named, tag, desc); err != nil {
+		dcontext.GetLogger(ctx).WithError(err).Error("error dispatching tag deleted to listener")
+	}
+}
+
+// GetGCQueues returns the size of the online garbage collection review queues.
+func (s *adminServer) GetGCQueues(ctx context.Context, _ *adminv1.GetGCQueuesRequest) (*adminv1.GCQueues, error) {
+	blobs, err := datastore.NewGCBlobTaskStore(s.app.db).Count(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	manifests, err := datastore.NewGCManifestTaskStore(s.app.db).Count(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &adminv1.GCQueues{BlobReviews: int64(blobs), ManifestReviews: int64(manifests)}, nil
+}
+
+// GetImportProgress returns the progress of the migration of repositories to the metadata database.
+func (s *adminServer) GetImportProgress(ctx context.Context, req *adminv1.GetImportProgressRequest) (*adminv1.ImportProgress, error) {
+	rStore := datastore.NewRepositoryStore(s.app.db)
+
+	count, err := rStore.Count(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &adminv1.ImportProgress{
+		MigrationEnabled:     s.app.Config.Migration.Enabled,
+		ImportedRepositories: int64(count),
+	}
+	if s.app.manifestBackfiller != nil {
+		resp.BackfillsInFlight = int64(s.app.manifestBackfiller.inFlight())
+	}
+
+	if path := req.GetRepository(); path != "" {
+		_, err := s.findRepository(ctx, path)
+		switch status.Code(err) {
+		case codes.OK:
+			resp.RepositoryImported = true
+		case codes.NotFound:
+		default:
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,181 @@
+// +build integration
+
+package handlers_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	adminv1 "github.com/docker/distribution/registry/api/admin/v1"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newAdminClient(t *testing.T, env *testEnv) (adminv1.AdminClient, func()) {
+	t.Helper()
+
+	ln := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	adminv1.RegisterAdminServer(s, env.app.AdminServer())
+	go s.Serve(ln)
+
+	dialer := func(context.Context, string) (net.Conn, error) { return ln.Dial() }
+	conn, err := grpc.DialContext(env.ctx, "bufnet", grpc.WithContextDialer(dialer), grpc.WithInsecure())
+	require.NoError(t, err)
+
+	return adminv1.NewAdminClient(conn), func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func TestAdminGRPC_ListRepositoriesAndTags(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	client, closeFn := newAdminClient(t, env)
+	defer closeFn()
+
+	m := seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("latest"))
+	seedRandomSchema2Manifest(t, env, "foo/baz", putByTag("1.0.0"))
+
+	stream, err := client.ListRepositories(env.ctx, &adminv1.ListRepositoriesRequest{IncludeCounts: true})
+	require.NoError(t, err)
+
+	var repos []*adminv1.Repository
+	for {
+		r, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		repos = append(repos, r)
+	}
+
+	// intermediate repositories (e.g. `foo`) are also listed
+	var paths []string
+	for _, r := range repos {
+		paths = append(paths, r.Path)
+	}
+	require.Contains(t, paths, "foo/bar")
+	require.Contains(t, paths, "foo/baz")
+	for _, r := range repos {
+		if r.Path == "foo/bar" {
+			require.Equal(t, int64(1), r.TagsCount)
+			require.Equal(t, int64(1), r.ManifestsCount)
+			require.NotNil(t, r.CreatedAt)
+		}
+	}
+
+	// resume after the first repository
+	stream, err = client.ListRepositories(env.ctx, &adminv1.ListRepositoriesRequest{Last: "foo/bar"})
+	require.NoError(t, err)
+	r, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "foo/baz", r.Path)
+
+	tagStream, err := client.ListTags(env.ctx, &adminv1.ListTagsRequest{Repository: "foo/bar"})
+	require.NoError(t, err)
+	tag, err := tagStream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "latest", tag.Name)
+	_, payload, err := m.Payload()
+	require.NoError(t, err)
+	require.Equal(t, digest.FromBytes(payload).String(), tag.Digest)
+	_, err = tagStream.Recv()
+	require.Equal(t, io.EOF, err)
+
+	// unknown repository
+	tagStream, err = client.ListTags(env.ctx, &adminv1.ListTagsRequest{Repository: "foo/qux"})
+	require.NoError(t, err)
+	_, err = tagStream.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestAdminGRPC_DeleteTag(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	client, closeFn := newAdminClient(t, env)
+	defer closeFn()
+
+	seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("latest"))
+
+	resp, err := client.DeleteTag(env.ctx, &adminv1.DeleteTagRequest{Repository: "foo/bar", Tag: "latest"})
+	require.NoError(t, err)
+	require.NotEmpty(t, resp.Digest)
+
+	// the manifest is untagged and queued for review by the online GC
+	queues, err := client.GetGCQueues(env.ctx, &adminv1.GetGCQueuesRequest{})
+	require.NoError(t, err)
+	require.NotZero(t, queues.ManifestReviews)
+
+	_, err = client.DeleteTag(env.ctx, &adminv1.DeleteTagRequest{Repository: "foo/bar", Tag: "latest"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.DeleteTag(env.ctx, &adminv1.DeleteTagRequest{Repository: "foo/bar", Tag: "!invalid"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestAdminGRPC_DeleteTag_ArchivedRepository(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	client, closeFn := newAdminClient(t, env)
+	defer closeFn()
+
+	seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("latest"))
+	setRepositoryArchived(t, env, "foo/bar", true)
+
+	// archived repositories are read-only, through the admin API as well
+	_, err := client.DeleteTag(env.ctx, &adminv1.DeleteTagRequest{Repository: "foo/bar", Tag: "latest"})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	tagStream, err := client.ListTags(env.ctx, &adminv1.ListTagsRequest{Repository: "foo/bar"})
+	require.NoError(t, err)
+	tag, err := tagStream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "latest", tag.Name)
+}
+
+func TestAdminGRPC_GetImportProgress(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	client, closeFn := newAdminClient(t, env)
+	defer closeFn()
+
+	seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("latest"))
+
+	p, err := client.GetImportProgress(env.ctx, &adminv1.GetImportProgressRequest{Repository: "foo/bar"})
+	require.NoError(t, err)
+	require.False(t, p.MigrationEnabled)
+	require.NotZero(t, p.ImportedRepositories)
+	require.True(t, p.RepositoryImported)
+
+	p, err = client.GetImportProgress(env.ctx, &adminv1.GetImportProgressRequest{Repository: "foo/qux"})
+	require.NoError(t, err)
+	require.False(t, p.RepositoryImported)
+}
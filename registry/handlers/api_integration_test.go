@@ -3,8 +3,11 @@
 package handlers_test
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -34,7 +37,9 @@ import (
 	"github.com/docker/distribution/manifest/schema2"
 	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/api/errcode"
+	gitlabv1 "github.com/docker/distribution/registry/api/gitlab/v1"
 	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
 	"github.com/docker/distribution/registry/datastore"
 	"github.com/docker/distribution/registry/datastore/migrations"
 	"github.com/docker/distribution/registry/datastore/models"
@@ -51,6 +56,7 @@ import (
 	"github.com/opencontainers/go-digest"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
 )
 
 func init() {
@@ -64,6 +70,14 @@ func withDelete(config *configuration.Configuration) {
 	config.Storage["delete"] = configuration.Parameters{"enabled": true}
 }
 
+func withUntag(config *configuration.Configuration) {
+	if _, ok := config.Storage["delete"]; !ok {
+		config.Storage["delete"] = configuration.Parameters{}
+	}
+
+	config.Storage["delete"]["untag"] = true
+}
+
 func withAccessLog(config *configuration.Configuration) {
 	config.Log.AccessLog.Disabled = false
 }
@@ -76,6 +90,10 @@ func withReadOnly(config *configuration.Configuration) {
 	config.Storage["maintenance"]["readonly"] = map[interface{}]interface{}{"enabled": true}
 }
 
+func withDatabaseMirror(config *configuration.Configuration) {
+	config.Database.Mirror.Enabled = true
+}
+
 func disableMirrorFS(config *configuration.Configuration) {
 	config.Migration.DisableMirrorFS = true
 }
@@ -142,7 +160,8 @@ func TestCheckAPI(t *testing.T) {
 		"Content-Type":                       []string{"application/json"},
 		"Content-Length":                     []string{"2"},
 		"Gitlab-Container-Registry-Version":  []string{strings.TrimPrefix(version.Version, "v")},
-		"Gitlab-Container-Registry-Features": []string{version.ExtFeatures},
+		"Gitlab-Container-Registry-Features": []string{version.ExtFeatures + ",manifest_payload_size_limit=4194304"},
+		"Oci-Distribution-Spec-Version":      []string{version.OCIDistributionSpec},
 	})
 
 	p, err := ioutil.ReadAll(resp.Body)
@@ -155,6 +174,326 @@ func TestCheckAPI(t *testing.T) {
 	}
 }
 
+func withCORS(origins ...string) configOpt {
+	return func(config *configuration.Configuration) {
+		config.HTTP.CORS.AllowedOrigins = origins
+		config.HTTP.CORS.AllowedHeaders = []string{"Authorization"}
+		config.HTTP.CORS.MaxAge = 5 * time.Minute
+	}
+}
+
+func TestCheckAPI_CORS(t *testing.T) {
+	env := newTestEnv(t, withCORS("https://ui.example.com"))
+	defer env.Shutdown()
+
+	baseURL, err := env.builder.BuildBaseURL()
+	require.NoError(t, err)
+
+	t.Run("preflight", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodOptions, baseURL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Origin", "https://ui.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		req.Header.Set("Access-Control-Request-Headers", "authorization")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "https://ui.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+		require.Equal(t, "Authorization", resp.Header.Get("Access-Control-Allow-Headers"))
+		require.Equal(t, "300", resp.Header.Get("Access-Control-Max-Age"))
+	})
+
+	t.Run("preflight method not allowed", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodOptions, baseURL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Origin", "https://ui.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+		require.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("allowed origin", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, baseURL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Origin", "https://ui.example.com")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		checkResponse(t, "issuing cross-origin api base check", resp, http.StatusOK)
+		require.Equal(t, "https://ui.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+		require.Contains(t, resp.Header.Get("Access-Control-Expose-Headers"), "Docker-Content-Digest")
+	})
+
+	t.Run("disallowed origin", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, baseURL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Origin", "https://evil.example.com")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		checkResponse(t, "issuing cross-origin api base check", resp, http.StatusOK)
+		require.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+	})
+}
+
+func TestCheckAPI_CORSDisabled(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	baseURL, err := env.builder.BuildBaseURL()
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, baseURL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Origin", "https://ui.example.com")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	checkResponse(t, "issuing cross-origin api base check", resp, http.StatusOK)
+	require.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+}
+
+func withClients(clients configuration.Clients) configOpt {
+	return func(config *configuration.Configuration) {
+		config.HTTP.Clients = clients
+	}
+}
+
+func TestCheckAPI_Clients(t *testing.T) {
+	env := newTestEnv(t, withClients(configuration.Clients{
+		AllowedIPs:       []string{"127.0.0.1", "10.0.0.0/8"},
+		DeniedIPs:        []string{"10.1.0.0/16"},
+		DeniedUserAgents: []string{`^docker/1\.[0-9]\.`},
+	}))
+	defer env.Shutdown()
+
+	baseURL, err := env.builder.BuildBaseURL()
+	require.NoError(t, err)
+
+	tt := []struct {
+		name          string
+		forwardedFor  string
+		userAgent     string
+		wantStatus    int
+		wantErrDetail string
+	}{
+		{
+			name:       "allowed IP",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:         "allowed IP range",
+			forwardedFor: "10.2.3.4",
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:          "denied IP range",
+			forwardedFor:  "10.1.2.3",
+			wantStatus:    http.StatusForbidden,
+			wantErrDetail: "client IP 10.1.2.3 is denied access to the registry",
+		},
+		{
+			name:          "IP not allowed",
+			forwardedFor:  "192.168.1.1",
+			wantStatus:    http.StatusForbidden,
+			wantErrDetail: "client IP 192.168.1.1 is not allowed to access the registry",
+		},
+		{
+			name:       "allowed user agent",
+			userAgent:  "docker/20.10.7 go/go1.13.15",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:          "denied user agent",
+			userAgent:     "docker/1.9.1 go/go1.4.3",
+			wantStatus:    http.StatusForbidden,
+			wantErrDetail: `client user agent "docker/1.9.1 go/go1.4.3" is denied access to the registry, please upgrade or use a different client`,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, baseURL, nil)
+			require.NoError(t, err)
+			if test.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", test.forwardedFor)
+			}
+			if test.userAgent != "" {
+				req.Header.Set("User-Agent", test.userAgent)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, test.wantStatus, resp.StatusCode)
+			if test.wantErrDetail == "" {
+				return
+			}
+
+			errs, _, _ := checkBodyHasErrorCodes(t, "issuing api base check", resp, errcode.ErrorCodeDenied)
+			require.Len(t, errs, 1)
+			require.Equal(t, test.wantErrDetail, errs[0].(errcode.Error).Detail)
+		})
+	}
+}
+
+func withLimits(limits configuration.Limits) configOpt {
+	return func(config *configuration.Configuration) {
+		config.HTTP.Limits = limits
+	}
+}
+
+func TestManifestAPI_Put_Limits_MaxBodySize(t *testing.T) {
+	env := newTestEnv(t, withLimits(configuration.Limits{MaxBodySize: 100}))
+	defer env.Shutdown()
+
+	u := buildManifestTagURL(t, env, "foo/bar", "latest")
+	req, err := http.NewRequest(http.MethodPut, u, bytes.NewReader(make([]byte, 101)))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", schema2.MediaTypeManifest)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "putting manifest over body size limit", resp, errcode.ErrorCodeRequestTooLarge)
+}
+
+func TestBlobAPI_Patch_Limits_MaxBodySizeNotApplied(t *testing.T) {
+	env := newTestEnv(t, withLimits(configuration.Limits{MaxBodySize: 100}))
+	defer env.Shutdown()
+
+	name, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	location, _ := startPushLayer(t, env, name)
+
+	resp, _, err := doPushChunk(t, location, bytes.NewReader(make([]byte, 1000)))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+}
+
+// trickleReader is an io.Reader that returns one byte per read, sleeping for delay before each one.
+type trickleReader struct {
+	delay time.Duration
+	n     int
+}
+
+func (r *trickleReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.delay)
+	r.n--
+	p[0] = 'a'
+	return 1, nil
+}
+
+func TestBlobAPI_Patch_Limits_MinUploadRate(t *testing.T) {
+	env := newTestEnv(t, withLimits(configuration.Limits{MinUploadRate: 1024, MinUploadRatePeriod: 100 * time.Millisecond}))
+	defer env.Shutdown()
+
+	name, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	// uploads above the minimum rate succeed
+	location, _ := startPushLayer(t, env, name)
+	resp, _, err := doPushChunk(t, location, bytes.NewReader(make([]byte, 1<<20)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	// uploads below the minimum rate are aborted
+	location, _ = startPushLayer(t, env, name)
+	resp, _, err = doPushChunk(t, location, &trickleReader{delay: 20 * time.Millisecond, n: 100})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusRequestTimeout, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "pushing chunk below minimum rate", resp, errcode.ErrorCodeRequestTimeout)
+}
+
+func withCompression(minSize int) configOpt {
+	return func(config *configuration.Configuration) {
+		config.HTTP.Compression.Enabled = true
+		config.HTTP.Compression.MinSize = minSize
+	}
+}
+
+func TestAPI_Get_Compression(t *testing.T) {
+	env := newTestEnv(t, withCompression(1))
+	defer env.Shutdown()
+
+	repoPath := "foo/bar"
+	dgst := createRepository(t, env, repoPath, "latest")
+
+	repoRef, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+	tagsURL, err := env.builder.BuildTagsURL(repoRef)
+	require.NoError(t, err)
+	manifestURL := buildManifestTagURL(t, env, repoPath, "latest")
+
+	get := func(u, acceptEncoding string) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", schema2.MediaTypeManifest)
+		if acceptEncoding != "" {
+			// setting the header explicitly disables the transparent decompression of the client
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+
+		var body io.Reader = resp.Body
+		if resp.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(resp.Body)
+			require.NoError(t, err)
+			body = gz
+		}
+		b, err := ioutil.ReadAll(body)
+		require.NoError(t, err)
+
+		return resp, b
+	}
+
+	// tag list
+	resp, b := get(tagsURL, "gzip")
+	require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	var tags tagsAPIResponse
+	require.NoError(t, json.Unmarshal(b, &tags))
+	require.Equal(t, []string{"latest"}, tags.Tags)
+
+	// manifest, whose digest refers to the uncompressed payload
+	resp, b = get(manifestURL, "gzip")
+	require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
+	require.Equal(t, dgst, digest.FromBytes(b))
+
+	// not compressed for clients that do not accept it
+	resp, b = get(manifestURL, "identity")
+	require.Empty(t, resp.Header.Get("Content-Encoding"))
+	require.Equal(t, dgst, digest.FromBytes(b))
+}
+
 type catalogAPIResponse struct {
 	Repositories []string `json:"repositories"`
 }
@@ -350,6 +689,74 @@ func catalog_Get_Empty(t *testing.T, opts ...configOpt) {
 	require.Empty(t, resp.Header.Get("Link"))
 }
 
+func TestCatalogAPI_PrefixAndDetails(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("1.0.0"))
+	seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("latest"))
+	seedRandomSchema2Manifest(t, env, "foo/baz", putByTag("latest"))
+	seedRandomSchema2Manifest(t, env, "foo_bar/qux", putByTag("latest"))
+
+	type repository struct {
+		Name      string `json:"name"`
+		Path      string `json:"path"`
+		TagsCount int    `json:"tags_count"`
+	}
+	type detailResponse struct {
+		Repositories []repository `json:"repositories"`
+	}
+
+	get := func(t *testing.T, q url.Values, body interface{}) *http.Response {
+		t.Helper()
+
+		catalogURL, err := env.builder.BuildCatalogURL(q)
+		require.NoError(t, err)
+		resp, err := http.Get(catalogURL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(body))
+
+		return resp
+	}
+
+	var body catalogAPIResponse
+	get(t, url.Values{"prefix": []string{"foo/"}}, &body)
+	require.Equal(t, []string{"foo/bar", "foo/baz"}, body.Repositories)
+
+	var detailBody detailResponse
+	resp := get(t, url.Values{"prefix": []string{"foo/"}, "details": []string{"true"}, "n": []string{"1"}}, &detailBody)
+	require.Equal(t, []repository{{Name: "bar", Path: "foo/bar", TagsCount: 2}}, detailBody.Repositories)
+	require.Equal(t, `</v2/_catalog?details=true&last=foo%2Fbar&n=1&prefix=foo%2F>; rel="next"`, resp.Header.Get("Link"))
+
+	detailBody = detailResponse{}
+	resp = get(t, url.Values{"prefix": []string{"foo/"}, "details": []string{"true"}, "n": []string{"1"}, "last": []string{"foo/bar"}}, &detailBody)
+	require.Equal(t, []repository{{Name: "baz", Path: "foo/baz", TagsCount: 1}}, detailBody.Repositories)
+	require.Empty(t, resp.Header.Get("Link"))
+}
+
+func TestCatalogAPI_PrefixAndDetails_NoDatabase(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is enabled")
+	}
+
+	catalogURL, err := env.builder.BuildCatalogURL(url.Values{"prefix": []string{"foo/"}})
+	require.NoError(t, err)
+	resp, err := http.Get(catalogURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	checkBodyHasErrorCodes(t, "catalog with prefix", resp, errcode.ErrorCodeUnsupported)
+}
+
 func newConfig(opts ...configOpt) configuration.Configuration {
 	config := &configuration.Configuration{
 		Storage: configuration.Storage{
@@ -498,6 +905,84 @@ func TestURLPrefix(t *testing.T) {
 	})
 }
 
+func TestURLPrefix_GeneratedURLs(t *testing.T) {
+	for _, relative := range []bool{false, true} {
+		t.Run(fmt.Sprintf("relative %t", relative), func(t *testing.T) {
+			config := newConfig()
+			config.HTTP.Prefix = "/test/"
+			config.HTTP.RelativeURLs = relative
+
+			env := newTestEnvWithConfig(t, &config)
+			defer env.Shutdown()
+
+			requirePrefixed := func(t *testing.T, rawURL string) {
+				t.Helper()
+
+				u, err := url.Parse(rawURL)
+				require.NoError(t, err)
+				require.Equal(t, relative, u.Host == "", "unexpected URL %q", rawURL)
+				require.True(t, strings.HasPrefix(u.Path, "/test/v2/"), "prefix missing from URL %q", rawURL)
+			}
+
+			// blob upload and blob locations
+			imageName, err := reference.WithName("foo/bar")
+			require.NoError(t, err)
+			uploadURL, err := env.builder.BuildBlobUploadURL(imageName)
+			require.NoError(t, err)
+			resp, err := http.Post(uploadURL, "", nil)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusAccepted, resp.StatusCode)
+			requirePrefixed(t, resp.Header.Get("Location"))
+
+			if relative {
+				// the remaining checks rely on test helpers that do not support relative URLs
+				return
+			}
+
+			// manifest locations
+			seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("latest"))
+			m := seedRandomSchema2Manifest(t, env, "foo/baz", putByTag("latest"))
+			resp = putManifest(t, "putting manifest by tag", buildManifestTagURL(t, env, "foo/baz", "stable"), schema2.MediaTypeManifest, m.Manifest)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusCreated, resp.StatusCode)
+			requirePrefixed(t, resp.Header.Get("Location"))
+
+			// pagination links
+			catalogURL, err := env.builder.BuildCatalogURL(url.Values{"n": []string{"1"}})
+			require.NoError(t, err)
+			resp, err = http.Get(catalogURL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, `</test/v2/_catalog?last=foo%2Fbar&n=1>; rel="next"`, resp.Header.Get("Link"))
+		})
+	}
+}
+
+func TestURLPrefix_HostWithoutPrefix(t *testing.T) {
+	for _, host := range []string{"https://registry.example.com", "https://registry.example.com/test/"} {
+		t.Run(host, func(t *testing.T) {
+			config := newConfig()
+			config.HTTP.Prefix = "/test/"
+			config.HTTP.Host = host
+
+			env := newTestEnvWithConfig(t, &config)
+			defer env.Shutdown()
+
+			imageName, err := reference.WithName("foo/bar")
+			require.NoError(t, err)
+			uploadURL, err := env.builder.BuildBlobUploadURL(imageName)
+			require.NoError(t, err)
+			resp, err := http.Post(uploadURL, "", nil)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusAccepted, resp.StatusCode)
+			require.True(t, strings.HasPrefix(resp.Header.Get("Location"), "https://registry.example.com/test/v2/foo/bar/blobs/uploads/"))
+		})
+	}
+}
+
 type blobArgs struct {
 	imageName   reference.Named
 	layerFile   io.ReadSeeker
@@ -1224,60 +1709,2520 @@ func TestDeleteDisabled(t *testing.T) {
 		t.Fatalf("unexpected error deleting layer: %v", err)
 	}
 
-	checkResponse(t, "deleting layer with delete disabled", resp, http.StatusMethodNotAllowed)
-}
+	checkResponse(t, "deleting layer with delete disabled", resp, http.StatusMethodNotAllowed)
+}
+
+func TestDeleteReadOnly(t *testing.T) {
+	setupEnv := newTestEnv(t, withSharedInMemoryDriver(t.Name()))
+	defer setupEnv.Shutdown()
+
+	imageName, _ := reference.WithName("foo/bar")
+	// "build" our layer file
+	layerFile, layerDigest, err := testutil.CreateRandomTarFile()
+	if err != nil {
+		t.Fatalf("error creating random layer file: %v", err)
+	}
+
+	ref, _ := reference.WithDigest(imageName, layerDigest)
+	uploadURLBase, _ := startPushLayer(t, setupEnv, imageName)
+	pushLayer(t, setupEnv.builder, imageName, layerDigest, uploadURLBase, layerFile)
+
+	// Reconfigure environment with withReadOnly enabled.
+	setupEnv.Shutdown()
+	env := newTestEnv(t, withSharedInMemoryDriver(t.Name()), withReadOnly)
+	defer env.Shutdown()
+
+	layerURL, err := env.builder.BuildBlobURL(ref)
+	if err != nil {
+		t.Fatalf("Error building blob URL")
+	}
+
+	resp, err := httpDelete(layerURL)
+	if err != nil {
+		t.Fatalf("unexpected error deleting layer: %v", err)
+	}
+
+	checkResponse(t, "deleting layer in read-only mode", resp, http.StatusMethodNotAllowed)
+}
+
+func TestStartPushReadOnly(t *testing.T) {
+	env := newTestEnv(t, withDelete, withReadOnly)
+	defer env.Shutdown()
+
+	imageName, _ := reference.WithName("foo/bar")
+
+	layerUploadURL, err := env.builder.BuildBlobUploadURL(imageName)
+	if err != nil {
+		t.Fatalf("unexpected error building layer upload url: %v", err)
+	}
+
+	resp, err := http.Post(layerUploadURL, "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error starting layer push: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "starting push in read-only mode", resp, http.StatusMethodNotAllowed)
+}
+
+func withRepositoryMaxPathComponents(n int) configOpt {
+	return func(config *configuration.Configuration) {
+		config.Validation.Repositories.MaxPathComponents = n
+	}
+}
+
+func TestStartPushRepositoryNameInvalid(t *testing.T) {
+	env := newTestEnv(t, withRepositoryMaxPathComponents(2))
+	defer env.Shutdown()
+
+	imageName, _ := reference.WithName("foo/bar/baz")
+
+	layerUploadURL, err := env.builder.BuildBlobUploadURL(imageName)
+	require.NoError(t, err)
+
+	resp, err := http.Post(layerUploadURL, "", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	checkResponse(t, "starting push to repository exceeding path components limit", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "starting push to repository exceeding path components limit", resp, v2.ErrorCodeNameInvalid)
+}
+
+func withAllowedManifestMediaTypes(mediaTypes ...string) configOpt {
+	return func(config *configuration.Configuration) {
+		config.Validation.Manifests.AllowedMediaTypes = mediaTypes
+	}
+}
+
+func TestManifestAPI_Put_MediaTypeNotAllowed(t *testing.T) {
+	env := newTestEnv(t, withAllowedManifestMediaTypes(schema2.MediaTypeManifest, schema2.MediaTypeImageConfig))
+	defer env.Shutdown()
+
+	repoPath := "foo/bar"
+	seedRandomSchema2Manifest(t, env, repoPath, putByTag("docker"))
+
+	m := seedRandomOCIManifest(t, env, repoPath)
+	resp := putManifest(t, "putting OCI manifest", buildManifestTagURL(t, env, repoPath, "oci"), v1.MediaTypeImageManifest, m.Manifest)
+	defer resp.Body.Close()
+
+	checkResponse(t, "putting manifest with disallowed media type", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "putting manifest with disallowed media type", resp, v2.ErrorCodeManifestInvalid)
+}
+
+func withStrictManifests(config *configuration.Configuration) {
+	config.Validation.Manifests.Strict = true
+}
+
+func TestManifestAPI_Put_StrictUnknownFields(t *testing.T) {
+	env := newTestEnv(t, withStrictManifests)
+	defer env.Shutdown()
+
+	repoPath := "foo/bar"
+	m := seedRandomSchema2Manifest(t, env, repoPath)
+	_, payload, err := m.Payload()
+	require.NoError(t, err)
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(payload, &fields))
+	fields["foo"] = "bar"
+	fields["Annotations"] = map[string]string{"a": "b"}
+	invalidPayload, err := json.Marshal(fields)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPut, buildManifestTagURL(t, env, repoPath, "invalid"), bytes.NewReader(invalidPayload))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", schema2.MediaTypeManifest)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	checkResponse(t, "putting manifest with unknown fields", resp, http.StatusBadRequest)
+	errs, _, _ := checkBodyHasErrorCodes(t, "putting manifest with unknown fields", resp, v2.ErrorCodeManifestInvalid)
+	require.Len(t, errs, 1)
+	require.Equal(t, "manifest has unknown top-level fields: Annotations, foo", errs[0].(errcode.Error).Message)
+	require.Equal(t, map[string]interface{}{"unknown_fields": []interface{}{"Annotations", "foo"}}, errs[0].(errcode.Error).Detail)
+
+	// the original manifest is accepted
+	resp = putManifest(t, "putting valid manifest", buildManifestTagURL(t, env, repoPath, "valid"), schema2.MediaTypeManifest, m.Manifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
+func withVerifyPlatforms(config *configuration.Configuration) {
+	config.Validation.Manifests.VerifyPlatforms = true
+}
+
+func TestManifestAPI_Put_ManifestList_VerifyPlatforms(t *testing.T) {
+	env := newTestEnv(t, withVerifyPlatforms)
+	defer env.Shutdown()
+
+	repoPath := "foo/bar"
+	m := seedRandomSchema2Manifest(t, env, repoPath, putByDigest, withConfigPayload([]byte(`{"os":"linux","architecture":"arm","variant":"v7"}`)))
+	_, payload, err := m.Payload()
+	require.NoError(t, err)
+	dgst := digest.FromBytes(payload)
+
+	tt := []struct {
+		name           string
+		platform       manifestlist.PlatformSpec
+		expectedStatus int
+	}{
+		{
+			name:           "matching platform",
+			platform:       manifestlist.PlatformSpec{OS: "linux", Architecture: "arm", Variant: "v7"},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "mismatching architecture",
+			platform:       manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "mismatching os",
+			platform:       manifestlist.PlatformSpec{OS: "windows", Architecture: "arm", Variant: "v7"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "mismatching variant",
+			platform:       manifestlist.PlatformSpec{OS: "linux", Architecture: "arm", Variant: "v6"},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			ml, err := manifestlist.FromDescriptors([]manifestlist.ManifestDescriptor{
+				{
+					Descriptor: distribution.Descriptor{Digest: dgst, MediaType: schema2.MediaTypeManifest},
+					Platform:   test.platform,
+				},
+			})
+			require.NoError(t, err)
+
+			resp := putManifest(t, "putting manifest list", buildManifestTagURL(t, env, repoPath, "latest"), manifestlist.MediaTypeManifestList, ml)
+			defer resp.Body.Close()
+
+			checkResponse(t, "putting manifest list", resp, test.expectedStatus)
+			if test.expectedStatus == http.StatusBadRequest {
+				checkBodyHasErrorCodes(t, "putting manifest list with mismatching platform", resp, v2.ErrorCodeManifestInvalid)
+			}
+		})
+	}
+}
+
+func withManifestPayloadSizeLimit(n int64) configOpt {
+	return func(config *configuration.Configuration) {
+		config.Validation.Manifests.PayloadSizeLimit = n
+	}
+}
+
+func TestManifestAPI_Put_PayloadTooLarge(t *testing.T) {
+	env := newTestEnv(t, withManifestPayloadSizeLimit(100))
+	defer env.Shutdown()
+
+	repoPath := "foo/bar"
+	m := seedRandomSchema2Manifest(t, env, repoPath)
+	_, payload, err := m.Payload()
+	require.NoError(t, err)
+	require.Greater(t, len(payload), 100)
+
+	tests := []struct {
+		name string
+		body io.Reader
+	}{
+		{name: "with content length", body: bytes.NewReader(payload)},
+		// wrapped to hide the length of the body, which is then sent with chunked encoding
+		{name: "without content length", body: struct{ io.Reader }{bytes.NewReader(payload)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPut, buildManifestTagURL(t, env, repoPath, "latest"), tt.body)
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", schema2.MediaTypeManifest)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			checkResponse(t, "putting manifest exceeding payload size limit", resp, http.StatusBadRequest)
+			errs, _, _ := checkBodyHasErrorCodes(t, "putting manifest exceeding payload size limit", resp, v2.ErrorCodeManifestInvalid)
+			require.Len(t, errs, 1)
+			require.Equal(t, "manifest payload exceeds the size limit of 100 bytes", errs[0].(errcode.Error).Detail)
+		})
+	}
+}
+
+func withAuditLogFile(path string) configOpt {
+	return func(config *configuration.Configuration) {
+		config.Audit.Enabled = true
+		config.Audit.File = path
+	}
+}
+
+func TestAuditLog_ManifestPushAndTagOverwrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	env := newTestEnv(t, withAuditLogFile(path))
+	defer env.Shutdown()
+
+	firstDgst := createRepository(t, env, "foo/bar", "latest")
+	secondDgst := createRepository(t, env, "foo/bar", "latest")
+
+	// flush all pending events
+	require.NoError(t, env.app.CloseAuditLog())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var manifestEvents []audit.Event
+	var blobPushes int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e audit.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		require.Equal(t, "foo/bar", e.Repository)
+		require.NotEmpty(t, e.RequestID)
+
+		switch e.Action {
+		case audit.ActionBlobPush:
+			blobPushes++
+		case audit.ActionManifestPush, audit.ActionTagOverwrite:
+			manifestEvents = append(manifestEvents, e)
+		}
+	}
+	require.NoError(t, scanner.Err())
+
+	require.NotZero(t, blobPushes)
+	require.Len(t, manifestEvents, 2)
+
+	require.Equal(t, audit.ActionManifestPush, manifestEvents[0].Action)
+	require.Equal(t, firstDgst, manifestEvents[0].Digest)
+	require.Equal(t, "latest", manifestEvents[0].Tag)
+	require.Empty(t, manifestEvents[0].PreviousDigest)
+
+	require.Equal(t, audit.ActionTagOverwrite, manifestEvents[1].Action)
+	require.Equal(t, secondDgst, manifestEvents[1].Digest)
+	require.Equal(t, "latest", manifestEvents[1].Tag)
+	require.Equal(t, firstDgst, manifestEvents[1].PreviousDigest)
+}
+
+func withAuditLogDatabase(config *configuration.Configuration) {
+	config.Audit.Enabled = true
+	config.Audit.Database = true
+}
+
+func TestRepositoryEventsAPI(t *testing.T) {
+	env := newTestEnv(t, withAuditLogDatabase)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	firstDgst := createRepository(t, env, "foo/bar", "latest")
+	secondDgst := createRepository(t, env, "foo/bar", "latest")
+
+	// flush all pending events
+	require.NoError(t, env.app.CloseAuditLog())
+
+	eventsURL := env.server.URL + env.config.HTTP.Prefix + "/gitlab/v1/repositories/foo/bar/events"
+
+	getEvents := func(t *testing.T, u string) (*http.Response, []byte) {
+		t.Helper()
+
+		resp, err := http.Get(u)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		p, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		return resp, p
+	}
+
+	type event struct {
+		ID             int64  `json:"id"`
+		Action         string `json:"action"`
+		Digest         string `json:"digest"`
+		Tag            string `json:"tag"`
+		PreviousDigest string `json:"previous_digest"`
+		RequestID      string `json:"request_id"`
+	}
+	type body struct {
+		Name   string  `json:"name"`
+		Events []event `json:"events"`
+	}
+
+	resp, p := getEvents(t, eventsURL)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.Empty(t, resp.Header.Get("Link"))
+
+	var b body
+	require.NoError(t, json.Unmarshal(p, &b))
+	require.Equal(t, "foo/bar", b.Name)
+
+	allEvents := len(b.Events)
+	var manifestEvents []event
+	for _, e := range b.Events {
+		require.NotEmpty(t, e.RequestID)
+		if e.Action == string(audit.ActionManifestPush) || e.Action == string(audit.ActionTagOverwrite) {
+			manifestEvents = append(manifestEvents, e)
+		}
+	}
+	require.Len(t, manifestEvents, 2)
+	require.Equal(t, string(audit.ActionManifestPush), manifestEvents[0].Action)
+	require.Equal(t, firstDgst.String(), manifestEvents[0].Digest)
+	require.Equal(t, string(audit.ActionTagOverwrite), manifestEvents[1].Action)
+	require.Equal(t, secondDgst.String(), manifestEvents[1].Digest)
+	require.Equal(t, firstDgst.String(), manifestEvents[1].PreviousDigest)
+	require.Equal(t, "latest", manifestEvents[1].Tag)
+
+	// paginated
+	resp, p = getEvents(t, eventsURL+"?n=1")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotEmpty(t, resp.Header.Get("Link"))
+	b = body{}
+	require.NoError(t, json.Unmarshal(p, &b))
+	require.Len(t, b.Events, 1)
+	require.Contains(t, resp.Header.Get("Link"), fmt.Sprintf("last=%d", b.Events[0].ID))
+
+	// events since a future date
+	resp, p = getEvents(t, eventsURL+"?since="+url.QueryEscape(time.Now().Add(time.Hour).UTC().Format(time.RFC3339)))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	b = body{}
+	require.NoError(t, json.Unmarshal(p, &b))
+	require.Empty(t, b.Events)
+
+	// csv
+	resp, p = getEvents(t, eventsURL+"?format=csv")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/csv", resp.Header.Get("Content-Type"))
+	records, err := csv.NewReader(bytes.NewReader(p)).ReadAll()
+	require.NoError(t, err)
+	// header plus one record per event
+	require.Len(t, records, allEvents+1)
+	require.Equal(t, "id", records[0][0])
+
+	// invalid query parameter
+	resp, _ = getEvents(t, eventsURL+"?since=yesterday")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// unknown repository
+	resp, _ = getEvents(t, env.server.URL+env.config.HTTP.Prefix+"/gitlab/v1/repositories/foo/baz/events")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestManifestTagsAPI(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	repoPath := "foo/bar"
+	m := seedRandomSchema2Manifest(t, env, repoPath, putByTag("latest"))
+	for _, tag := range []string{"stable", "1.0.0"} {
+		resp := putManifest(t, "putting manifest by tag", buildManifestTagURL(t, env, repoPath, tag), schema2.MediaTypeManifest, m.Manifest)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+	_, payload, err := m.Payload()
+	require.NoError(t, err)
+	dgst := digest.FromBytes(payload)
+
+	untaggedDgst := createRepository(t, env, repoPath, "untagged")
+	// move the tag to a different manifest, leaving untaggedDgst without tags
+	createRepository(t, env, repoPath, "untagged")
+
+	tagsURL := func(repoPath string, dgst digest.Digest) string {
+		return fmt.Sprintf("%s%s/gitlab/v1/repositories/%s/manifests/%s/tags", env.server.URL, env.config.HTTP.Prefix, repoPath, dgst)
+	}
+
+	type body struct {
+		Name   string   `json:"name"`
+		Digest string   `json:"digest"`
+		Tags   []string `json:"tags"`
+	}
+
+	getTags := func(t *testing.T, u string) (*http.Response, body) {
+		t.Helper()
+
+		resp, err := http.Get(u)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var b body
+		if resp.StatusCode == http.StatusOK {
+			require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&b))
+		}
+
+		return resp, b
+	}
+
+	resp, b := getTags(t, tagsURL(repoPath, dgst))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, repoPath, b.Name)
+	require.Equal(t, dgst.String(), b.Digest)
+	require.Equal(t, []string{"1.0.0", "latest", "stable"}, b.Tags)
+
+	// a manifest without tags
+	resp, b = getTags(t, tagsURL(repoPath, untaggedDgst))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, b.Tags)
+	require.NotNil(t, b.Tags)
+
+	// unknown manifest
+	resp, _ = getTags(t, tagsURL(repoPath, digest.FromString("foo")))
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// unknown repository
+	resp, _ = getTags(t, tagsURL("foo/baz", dgst))
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func repositoryArchiveURL(env *testEnv, repoPath string) string {
+	return fmt.Sprintf("%s%s/gitlab/v1/repositories/%s/archive", env.server.URL, env.config.HTTP.Prefix, repoPath)
+}
+
+func setRepositoryArchived(t *testing.T, env *testEnv, repoPath string, archived bool) *http.Response {
+	t.Helper()
+
+	method := http.MethodDelete
+	if archived {
+		method = http.MethodPut
+	}
+	req, err := http.NewRequest(method, repositoryArchiveURL(env, repoPath), nil)
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	return resp
+}
+
+func TestRepositoryArchiveAPI(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	repoPath := "foo/bar"
+	m := seedRandomSchema2Manifest(t, env, repoPath, putByTag("latest"))
+
+	listCatalog := func(t *testing.T, includeArchived bool) []string {
+		t.Helper()
+
+		catalogURL, err := env.builder.BuildCatalogURL(url.Values{"include_archived": []string{strconv.FormatBool(includeArchived)}})
+		require.NoError(t, err)
+		resp, err := http.Get(catalogURL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body catalogAPIResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+		return body.Repositories
+	}
+
+	resp := setRepositoryArchived(t, env, repoPath, true)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	// archiving is idempotent
+	resp = setRepositoryArchived(t, env, repoPath, true)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// pulls are still allowed
+	resp, err := http.Get(buildManifestTagURL(t, env, repoPath, "latest"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// but pushes and deletes are not
+	resp = putManifest(t, "putting manifest by tag", buildManifestTagURL(t, env, repoPath, "stable"), schema2.MediaTypeManifest, m.Manifest)
+	defer resp.Body.Close()
+	checkResponse(t, "putting manifest to archived repository", resp, http.StatusForbidden)
+	checkBodyHasErrorCodes(t, "putting manifest to archived repository", resp, gitlabv1.ErrorCodeRepositoryArchived)
+
+	resp, err = httpDelete(buildManifestDigestURL(t, env, repoPath, m))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "deleting manifest from archived repository", resp, http.StatusForbidden)
+	checkBodyHasErrorCodes(t, "deleting manifest from archived repository", resp, gitlabv1.ErrorCodeRepositoryArchived)
+
+	// archived repositories are only listed in the catalog if requested
+	require.NotContains(t, listCatalog(t, false), repoPath)
+	require.Contains(t, listCatalog(t, true), repoPath)
+
+	resp = setRepositoryArchived(t, env, repoPath, false)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = putManifest(t, "putting manifest by tag", buildManifestTagURL(t, env, repoPath, "stable"), schema2.MediaTypeManifest, m.Manifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Contains(t, listCatalog(t, false), repoPath)
+
+	// unknown repository
+	resp = setRepositoryArchived(t, env, "foo/baz", true)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestRepositoryArchiveAPI_NoDatabase(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is enabled")
+	}
+
+	resp := setRepositoryArchived(t, env, "foo/bar", true)
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestRepositoryArchiveAPI_ReadOnly(t *testing.T) {
+	env := newTestEnv(t, withReadOnly)
+	defer env.Shutdown()
+
+	resp := setRepositoryArchived(t, env, "foo/bar", true)
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestDatabaseMirror(t *testing.T) {
+	upstreamEnv := newTestEnv(t, withSharedInMemoryDriver(t.Name()))
+	defer upstreamEnv.Shutdown()
+
+	if !upstreamEnv.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	dgst := createRepository(t, upstreamEnv, "foo/bar", "latest")
+
+	env := newTestEnv(t, withSharedInMemoryDriver(t.Name()), withDatabaseMirror)
+	defer env.Shutdown()
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	t.Run("pull", func(t *testing.T) {
+		tagRef, err := reference.WithTag(repoRef, "latest")
+		require.NoError(t, err)
+		u, err := env.builder.BuildManifestURL(tagRef)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", schema2.MediaTypeManifest)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
+	})
+
+	t.Run("push", func(t *testing.T) {
+		u, err := env.builder.BuildBlobUploadURL(repoRef)
+		require.NoError(t, err)
+
+		resp, err := http.Post(u, "", nil)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+
+	t.Run("archive", func(t *testing.T) {
+		resp := setRepositoryArchived(t, env, "foo/bar", true)
+		require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+}
+
+func renameRepository(t *testing.T, env *testEnv, repoPath, to string, includeDescendants bool) *http.Response {
+	t.Helper()
+
+	u := fmt.Sprintf("%s%s/gitlab/v1/repositories/%s/rename", env.server.URL, env.config.HTTP.Prefix, repoPath)
+	q := url.Values{"to": []string{to}, "include_descendants": []string{strconv.FormatBool(includeDescendants)}}
+	req, err := http.NewRequest(http.MethodPut, u+"?"+q.Encode(), nil)
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	return resp
+}
+
+func TestRepositoryRenameAPI(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("latest"))
+	seedRandomSchema2Manifest(t, env, "foo/bar/baz", putByTag("latest"))
+	seedRandomSchema2Manifest(t, env, "foo/qux", putByTag("latest"))
+
+	getTag := func(t *testing.T, repoPath string) int {
+		t.Helper()
+
+		resp, err := http.Get(buildManifestTagURL(t, env, repoPath, "latest"))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	resp := renameRepository(t, env, "foo/bar", "foo/other/bar", true)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Equal(t, http.StatusOK, getTag(t, "foo/other/bar"))
+	require.Equal(t, http.StatusOK, getTag(t, "foo/other/bar/baz"))
+	require.Equal(t, http.StatusNotFound, getTag(t, "foo/bar"))
+	require.Equal(t, http.StatusNotFound, getTag(t, "foo/bar/baz"))
+
+	// destination already exists
+	resp = renameRepository(t, env, "foo/other/bar", "foo/qux", true)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	// across top-level namespaces
+	resp = renameRepository(t, env, "foo/other/bar", "bar/other", true)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// invalid destination
+	resp = renameRepository(t, env, "foo/other/bar", "Foo", true)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// unknown repository
+	resp = renameRepository(t, env, "foo/unknown", "foo/renamed", true)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestRepositoryRenameAPI_NoDatabase(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is enabled")
+	}
+
+	resp := renameRepository(t, env, "foo/bar", "foo/baz", true)
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func withDataMover(targetDriverName string) configOpt {
+	return func(config *configuration.Configuration) {
+		config.DataMover.Enabled = true
+		config.DataMover.Storage = configuration.Storage{"sharedinmemorydriver": configuration.Parameters{"name": targetDriverName}}
+		config.DataMover.Interval = 100 * time.Millisecond
+	}
+}
+
+type repositoryStorageMoveAPIResponse struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+func repositoryStorageMove(t *testing.T, env *testEnv, method, repoPath string) (*http.Response, repositoryStorageMoveAPIResponse) {
+	t.Helper()
+
+	u := fmt.Sprintf("%s%s/gitlab/v1/repositories/%s/storage-move", env.server.URL, env.config.HTTP.Prefix, repoPath)
+	req, err := http.NewRequest(method, u, nil)
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body repositoryStorageMoveAPIResponse
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusAccepted {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	}
+
+	return resp, body
+}
+
+func TestRepositoryStorageMoveAPI(t *testing.T) {
+	env := newTestEnv(t, withDataMover(t.Name()))
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	repoPath := "foo/bar"
+	m := seedRandomSchema2Manifest(t, env, repoPath, putByTag("latest"))
+
+	// not scheduled yet
+	resp, _ := repositoryStorageMove(t, env, http.MethodGet, repoPath)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, body := repositoryStorageMove(t, env, http.MethodPut, repoPath)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.Equal(t, repoPath, body.Name)
+	require.Equal(t, "scheduled", body.State)
+
+	// scheduling is not idempotent
+	resp, _ = repositoryStorageMove(t, env, http.MethodPut, repoPath)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	// wait for the move to be processed in the background
+	require.Eventually(t, func() bool {
+		resp, body := repositoryStorageMove(t, env, http.MethodGet, repoPath)
+		return resp.StatusCode == http.StatusOK && body.State == "finished"
+	}, 10*time.Second, 100*time.Millisecond)
+
+	// the repository is now served from the target storage
+	resp, err := http.Get(buildManifestTagURL(t, env, repoPath, "latest"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	repoRef, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+	for _, l := range m.Layers {
+		ref, err := reference.WithDigest(repoRef, l.Digest)
+		require.NoError(t, err)
+		blobURL, err := env.builder.BuildBlobURL(ref)
+		require.NoError(t, err)
+
+		resp, err := http.Get(blobURL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	// unknown repository
+	resp, _ = repositoryStorageMove(t, env, http.MethodPut, "foo/baz")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestRepositoryStorageMoveAPI_Disabled(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	resp, _ := repositoryStorageMove(t, env, http.MethodPut, "foo/bar")
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func withRetention(config *configuration.Configuration) {
+	config.Retention.Enabled = true
+	config.Retention.Interval = 100 * time.Millisecond
+}
+
+type repositoryRetentionPolicyAPIResponse struct {
+	Name           string `json:"name"`
+	KeepN          int    `json:"keep_n"`
+	DryRun         bool   `json:"dry_run"`
+	LastRunDeleted int    `json:"last_run_deleted"`
+	LastRunAt      string `json:"last_run_at"`
+}
+
+func repositoryRetentionPolicy(t *testing.T, env *testEnv, method, repoPath, body string) (*http.Response, repositoryRetentionPolicyAPIResponse) {
+	t.Helper()
+
+	u := fmt.Sprintf("%s%s/gitlab/v1/repositories/%s/retention-policy", env.server.URL, env.config.HTTP.Prefix, repoPath)
+	req, err := http.NewRequest(method, u, strings.NewReader(body))
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var p repositoryRetentionPolicyAPIResponse
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&p))
+	}
+
+	return resp, p
+}
+
+func TestRepositoryRetentionPolicyAPI(t *testing.T) {
+	env := newTestEnv(t, withRetention)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	repoPath := "foo/bar"
+	seedRandomSchema2Manifest(t, env, repoPath, putByTag("old"))
+	seedRandomSchema2Manifest(t, env, repoPath, putByTag("new"))
+
+	// not set yet
+	resp, _ := repositoryRetentionPolicy(t, env, http.MethodGet, repoPath, "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// a policy without rules is rejected
+	resp, _ = repositoryRetentionPolicy(t, env, http.MethodPut, repoPath, `{"dry_run": true}`)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// dry runs do not delete tags
+	resp, body := repositoryRetentionPolicy(t, env, http.MethodPut, repoPath, `{"keep_n": 1, "dry_run": true}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, repoPath, body.Name)
+	require.Equal(t, 1, body.KeepN)
+	require.True(t, body.DryRun)
+
+	require.Eventually(t, func() bool {
+		resp, body := repositoryRetentionPolicy(t, env, http.MethodGet, repoPath, "")
+		return resp.StatusCode == http.StatusOK && body.LastRunAt != ""
+	}, 10*time.Second, 100*time.Millisecond)
+
+	_, body = repositoryRetentionPolicy(t, env, http.MethodGet, repoPath, "")
+	require.Equal(t, 1, body.LastRunDeleted)
+
+	resp, err := http.Get(buildManifestTagURL(t, env, repoPath, "old"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// enforced policies delete the least recently written tags
+	resp, _ = repositoryRetentionPolicy(t, env, http.MethodPut, repoPath, `{"keep_n": 1}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(buildManifestTagURL(t, env, repoPath, "old"))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusNotFound
+	}, 10*time.Second, 100*time.Millisecond)
+
+	resp, err = http.Get(buildManifestTagURL(t, env, repoPath, "new"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, _ = repositoryRetentionPolicy(t, env, http.MethodDelete, repoPath, "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = repositoryRetentionPolicy(t, env, http.MethodDelete, repoPath, "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// unknown repository
+	resp, _ = repositoryRetentionPolicy(t, env, http.MethodPut, "foo/baz", `{"keep_n": 1}`)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestRepositoryRetentionPolicyAPI_Disabled(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	resp, _ := repositoryRetentionPolicy(t, env, http.MethodPut, "foo/bar", `{"keep_n": 1}`)
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func withoutRepositoryAutoCreate(config *configuration.Configuration) {
+	config.Policy.Repository.DisableAutoCreate = true
+}
+
+func createRepositoryExplicitly(t *testing.T, env *testEnv, repoPath string) *http.Response {
+	t.Helper()
+
+	repoRef, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+	u, err := env.builder.BuildGitLabRepositoryCreateURL(repoRef)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPut, u, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestRepositoryCreateAPI(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	resp := createRepositoryExplicitly(t, env, "foo/bar")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var body struct {
+		Name string `json:"name"`
+		Path string `json:"path"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, "bar", body.Name)
+	require.Equal(t, "foo/bar", body.Path)
+
+	// creating an existing repository is a no-op
+	resp = createRepositoryExplicitly(t, env, "foo/bar")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestRepositoryCreateAPI_NoDatabase(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is enabled")
+	}
+
+	resp := createRepositoryExplicitly(t, env, "foo/bar")
+	defer resp.Body.Close()
+	checkBodyHasErrorCodes(t, "creating repository without database", resp, errcode.ErrorCodeUnsupported)
+}
+
+func TestDisableRepositoryAutoCreate(t *testing.T) {
+	env := newTestEnv(t, withoutRepositoryAutoCreate)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	// starting a blob upload to a repository that does not exist is refused
+	u, err := env.builder.BuildBlobUploadURL(repoRef)
+	require.NoError(t, err)
+	resp, err := http.Post(u, "", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "starting blob upload to unknown repository", resp, v2.ErrorCodeNameUnknown)
+
+	// and so is pushing a manifest
+	resp = putManifest(t, "pushing manifest to unknown repository", buildManifestTagURL(t, env, "foo/bar", "latest"), schema2.MediaTypeManifest, &schema2.Manifest{Versioned: schema2.SchemaVersion})
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "pushing manifest to unknown repository", resp, v2.ErrorCodeNameUnknown)
+
+	// once created explicitly, the repository can be pushed to
+	resp = createRepositoryExplicitly(t, env, "foo/bar")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("latest"))
+}
+
+func copyManifest(t *testing.T, env *testEnv, repoPath string, q url.Values) *http.Response {
+	t.Helper()
+
+	u := fmt.Sprintf("%s%s/gitlab/v1/repositories/%s/copy", env.server.URL, env.config.HTTP.Prefix, repoPath)
+	resp, err := http.Post(u+"?"+q.Encode(), "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	return resp
+}
+
+func TestRepositoryCopyAPI(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	m := seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("latest"))
+	idx := seedRandomOCIImageIndex(t, env, "foo/bar", putByTag("index"))
+
+	// copy by tag, which is also created in the destination repository
+	resp := copyManifest(t, env, "foo/baz", url.Values{"from": []string{"foo/bar"}, "tag": []string{"latest"}})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Equal(t, buildManifestDigestURL(t, env, "foo/baz", m), resp.Header.Get("Location"))
+
+	resp, err := http.Get(buildManifestTagURL(t, env, "foo/baz", "latest"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// blobs are linked to the destination repository
+	repoRef, err := reference.WithName("foo/baz")
+	require.NoError(t, err)
+	for _, d := range m.References() {
+		ref, err := reference.WithDigest(repoRef, d.Digest)
+		require.NoError(t, err)
+		blobURL, err := env.builder.BuildBlobURL(ref)
+		require.NoError(t, err)
+
+		resp, err := http.Head(blobURL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	// copying is idempotent
+	resp = copyManifest(t, env, "foo/baz", url.Values{"from": []string{"foo/bar"}, "tag": []string{"latest"}})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// copy a manifest list by digest, along with the manifests it references
+	_, p, err := idx.Payload()
+	require.NoError(t, err)
+	resp = copyManifest(t, env, "foo/qux", url.Values{"from": []string{"foo/bar"}, "digest": []string{digest.FromBytes(p).String()}})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	req, err := http.NewRequest(http.MethodHead, buildManifestDigestURL(t, env, "foo/qux", idx), nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", v1.MediaTypeImageIndex)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	quxRef, err := reference.WithName("foo/qux")
+	require.NoError(t, err)
+	for _, d := range idx.References() {
+		ref, err := reference.WithDigest(quxRef, d.Digest)
+		require.NoError(t, err)
+		u, err := env.builder.BuildManifestURL(ref)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodHead, u, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", d.MediaType)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	// unknown source repository and manifest
+	resp = copyManifest(t, env, "foo/baz", url.Values{"from": []string{"foo/unknown"}, "tag": []string{"latest"}})
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp = copyManifest(t, env, "foo/baz", url.Values{"from": []string{"foo/bar"}, "tag": []string{"unknown"}})
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// a digest or a tag is required, but not both
+	resp = copyManifest(t, env, "foo/baz", url.Values{"from": []string{"foo/bar"}})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = copyManifest(t, env, "foo/baz", url.Values{"from": []string{"foo/bar"}, "tag": []string{"latest"}, "digest": []string{m.Config.Digest.String()}})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestRepositoryCopyAPI_QuotasExceeded(t *testing.T) {
+	env := newTestEnv(t, withRepositoryQuotas(1, 1))
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	m := seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("a"))
+	resp := putManifest(t, "putting tag", buildManifestTagURL(t, env, "foo/bar", "b"), schema2.MediaTypeManifest, m.Manifest)
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("c"))
+
+	resp = copyManifest(t, env, "foo/baz", url.Values{"from": []string{"foo/bar"}, "tag": []string{"a"}})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// copying an existing manifest and tag again does not count against the quotas
+	resp = copyManifest(t, env, "foo/baz", url.Values{"from": []string{"foo/bar"}, "tag": []string{"a"}})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// the manifest already exists in the destination repository, but the tag does not
+	resp = copyManifest(t, env, "foo/baz", url.Values{"from": []string{"foo/bar"}, "tag": []string{"b"}})
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// neither the manifest nor the tag exist in the destination repository
+	resp = copyManifest(t, env, "foo/baz", url.Values{"from": []string{"foo/bar"}, "tag": []string{"c"}})
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestRepositoryCopyAPI_NoDatabase(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is enabled")
+	}
+
+	resp := copyManifest(t, env, "foo/baz", url.Values{"from": []string{"foo/bar"}, "tag": []string{"latest"}})
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func putTag(t *testing.T, env *testEnv, repoPath, tag, body string, headers ...string) *http.Response {
+	t.Helper()
+
+	u := fmt.Sprintf("%s%s/gitlab/v1/repositories/%s/tags/%s", env.server.URL, env.config.HTTP.Prefix, repoPath, tag)
+	req, err := http.NewRequest(http.MethodPut, u, strings.NewReader(body))
+	require.NoError(t, err)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestRepositoryTagAPI(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	m1 := seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("latest"))
+	_, p1, err := m1.Payload()
+	require.NoError(t, err)
+	d1 := digest.FromBytes(p1)
+	m2 := seedRandomSchema2Manifest(t, env, "foo/bar", putByDigest)
+	_, p2, err := m2.Payload()
+	require.NoError(t, err)
+	d2 := digest.FromBytes(p2)
+
+	type response struct {
+		Name           string `json:"name"`
+		Tag            string `json:"tag"`
+		Digest         string `json:"digest"`
+		PreviousDigest string `json:"previous_digest"`
+	}
+	decode := func(t *testing.T, resp *http.Response) response {
+		t.Helper()
+		defer resp.Body.Close()
+
+		var body response
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return body
+	}
+
+	// create a new tag for an existing manifest
+	resp := putTag(t, env, "foo/bar", "stable", fmt.Sprintf(`{"digest":%q}`, d1))
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Equal(t, d1.String(), resp.Header.Get("Docker-Content-Digest"))
+	require.Equal(t, buildManifestDigestURL(t, env, "foo/bar", m1), resp.Header.Get("Location"))
+	require.Equal(t, response{Name: "foo/bar", Tag: "stable", Digest: d1.String()}, decode(t, resp))
+
+	req, err := http.NewRequest(http.MethodHead, buildManifestTagURL(t, env, "foo/bar", "stable"), nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", schema2.MediaTypeManifest)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, d1.String(), resp.Header.Get("Docker-Content-Digest"))
+
+	// move an existing tag, conditionally on the manifest it currently points to
+	resp = putTag(t, env, "foo/bar", "latest", fmt.Sprintf(`{"digest":%q}`, d2), "If-Match", d2.String())
+	resp.Body.Close()
+	require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp = putTag(t, env, "foo/bar", "latest", fmt.Sprintf(`{"digest":%q}`, d2), "If-Match", fmt.Sprintf("%q", d1))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, response{Name: "foo/bar", Tag: "latest", Digest: d2.String(), PreviousDigest: d1.String()}, decode(t, resp))
+
+	req, err = http.NewRequest(http.MethodHead, buildManifestTagURL(t, env, "foo/bar", "latest"), nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", schema2.MediaTypeManifest)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, d2.String(), resp.Header.Get("Docker-Content-Digest"))
+
+	// retagging is idempotent
+	resp = putTag(t, env, "foo/bar", "latest", fmt.Sprintf(`{"digest":%q}`, d2))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, response{Name: "foo/bar", Tag: "latest", Digest: d2.String()}, decode(t, resp))
+
+	// unknown repository and manifest
+	resp = putTag(t, env, "foo/unknown", "latest", fmt.Sprintf(`{"digest":%q}`, d1))
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp = putTag(t, env, "foo/bar", "latest", fmt.Sprintf(`{"digest":%q}`, digest.FromString("unknown")))
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// invalid digest or body
+	resp = putTag(t, env, "foo/bar", "latest", `{"digest":"sha256:foo"}`)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = putTag(t, env, "foo/bar", "latest", `{"digest":`)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestRepositoryTagAPI_TagsQuotaExceeded(t *testing.T) {
+	env := newTestEnv(t, withRepositoryQuotas(0, 1))
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	m := seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("latest"))
+	_, p, err := m.Payload()
+	require.NoError(t, err)
+	body := fmt.Sprintf(`{"digest":%q}`, digest.FromBytes(p))
+
+	// moving an existing tag does not count against the quota
+	resp := putTag(t, env, "foo/bar", "latest", body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = putTag(t, env, "foo/bar", "stable", body)
+	defer resp.Body.Close()
+	checkBodyHasErrorCodes(t, "tags quota exceeded", resp, v2.ErrorCodeQuotaExceeded)
+}
+
+func TestRepositoryTagAPI_NoDatabase(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is enabled")
+	}
+
+	resp := putTag(t, env, "foo/bar", "latest", fmt.Sprintf(`{"digest":%q}`, digest.FromString("foo")))
+	resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func checkBlobsExist(t *testing.T, env *testEnv, repoPath, body string) *http.Response {
+	t.Helper()
+
+	name, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+	u, err := env.builder.BuildGitLabRepositoryBlobsExistsURL(name)
+	require.NoError(t, err)
+
+	resp, err := http.Post(u, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestRepositoryBlobsExistsAPI(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	m := seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("latest"))
+	refs := m.References()
+	require.NotEmpty(t, refs)
+	unknown := digest.FromString("unknown")
+
+	type blob struct {
+		Digest string `json:"digest"`
+		Exists bool   `json:"exists"`
+		Size   int64  `json:"size"`
+	}
+	type response struct {
+		Name  string `json:"name"`
+		Blobs []blob `json:"blobs"`
+	}
+	decode := func(t *testing.T, resp *http.Response) response {
+		t.Helper()
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var body response
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return body
+	}
+
+	// blobs are reported in the requested order
+	body := fmt.Sprintf(`{"digests":[%q,%q,%q]}`, unknown, refs[0].Digest, refs[len(refs)-1].Digest)
+	require.Equal(t, response{
+		Name: "foo/bar",
+		Blobs: []blob{
+			{Digest: unknown.String()},
+			{Digest: refs[0].Digest.String(), Exists: true, Size: refs[0].Size},
+			{Digest: refs[len(refs)-1].Digest.String(), Exists: true, Size: refs[len(refs)-1].Size},
+		},
+	}, decode(t, checkBlobsExist(t, env, "foo/bar", body)))
+
+	// blobs of other repositories are not reported
+	require.Equal(t, response{
+		Name:  "foo/baz",
+		Blobs: []blob{{Digest: refs[0].Digest.String()}},
+	}, decode(t, checkBlobsExist(t, env, "foo/baz", fmt.Sprintf(`{"digests":[%q]}`, refs[0].Digest))))
+
+	// no digests
+	require.Equal(t, response{Name: "foo/bar", Blobs: []blob{}}, decode(t, checkBlobsExist(t, env, "foo/bar", `{"digests":[]}`)))
+
+	// invalid digest or body
+	resp := checkBlobsExist(t, env, "foo/bar", `{"digests":["sha256:foo"]}`)
+	defer resp.Body.Close()
+	checkBodyHasErrorCodes(t, "invalid digest", resp, v2.ErrorCodeDigestInvalid)
+	resp = checkBlobsExist(t, env, "foo/bar", `{"digests":`)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// too many digests
+	dd := make([]string, 0, 1001)
+	for i := 0; i < 1001; i++ {
+		dd = append(dd, fmt.Sprintf("%q", unknown))
+	}
+	resp = checkBlobsExist(t, env, "foo/bar", fmt.Sprintf(`{"digests":[%s]}`, strings.Join(dd, ",")))
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestRepositoryBlobsExistsAPI_NoDatabase(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is enabled")
+	}
+
+	resp := checkBlobsExist(t, env, "foo/bar", fmt.Sprintf(`{"digests":[%q]}`, digest.FromString("foo")))
+	resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func getNamespaceDeduplication(t *testing.T, env *testEnv, namespace string) *http.Response {
+	t.Helper()
+
+	name, err := reference.WithName(namespace)
+	require.NoError(t, err)
+	u, err := env.builder.BuildGitLabNamespaceDeduplicationURL(name)
+	require.NoError(t, err)
+
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestNamespaceDeduplicationAPI(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	m := seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("latest"))
+	seedRandomSchema2Manifest(t, env, "other/bar", putByTag("latest"))
+
+	// share the blobs of foo/bar with foo/baz
+	resp := copyManifest(t, env, "foo/baz", url.Values{"from": []string{"foo/bar"}, "tag": []string{"latest"}})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var size int64
+	for _, d := range m.References() {
+		size += d.Size
+	}
+
+	type response struct {
+		Name              string `json:"name"`
+		RepositoriesCount int    `json:"repositories_count"`
+		BlobsCount        int    `json:"blobs_count"`
+		UniqueBlobsCount  int    `json:"unique_blobs_count"`
+		LogicalSize       int64  `json:"logical_size"`
+		PhysicalSize      int64  `json:"physical_size"`
+		DeduplicatedSize  int64  `json:"deduplicated_size"`
+	}
+
+	resp = getNamespaceDeduplication(t, env, "foo")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body response
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, response{
+		Name:              "foo",
+		RepositoriesCount: 2,
+		BlobsCount:        2 * len(m.References()),
+		UniqueBlobsCount:  len(m.References()),
+		LogicalSize:       2 * size,
+		PhysicalSize:      size,
+		DeduplicatedSize:  size,
+	}, body)
+
+	t.Run("unknown namespace", func(t *testing.T) {
+		resp := getNamespaceDeduplication(t, env, "unknown")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("not a top-level namespace", func(t *testing.T) {
+		resp := getNamespaceDeduplication(t, env, "foo/bar")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestNamespaceDeduplicationAPI_NoDatabase(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is enabled")
+	}
+
+	resp := getNamespaceDeduplication(t, env, "foo")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func repositoryStatisticsRequest(t *testing.T, env *testEnv, method, repoPath string) *http.Response {
+	t.Helper()
+
+	name, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+	u, err := env.builder.BuildGitLabRepositoryStatisticsURL(name)
+	if method == http.MethodPost {
+		u, err = env.builder.BuildGitLabRepositoryStatisticsRecalculateURL(name)
+	}
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(method, u, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestRepositoryStatisticsAPI(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	type statistics struct {
+		TagsCount       int        `json:"tags_count"`
+		ManifestsCount  int        `json:"manifests_count"`
+		Size            int64      `json:"size"`
+		LastPublishedAt *time.Time `json:"last_published_at"`
+	}
+	type getResponse struct {
+		Name string `json:"name"`
+		statistics
+	}
+	type recalculateResponse struct {
+		Name   string      `json:"name"`
+		Before *statistics `json:"before"`
+		After  statistics  `json:"after"`
+	}
+
+	m := seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("latest"))
+	var size int64
+	for _, d := range m.References() {
+		size += d.Size
+	}
+
+	// statistics are calculated on first access
+	resp := repositoryStatisticsRequest(t, env, http.MethodGet, "foo/bar")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var got getResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	require.Equal(t, "foo/bar", got.Name)
+	require.Equal(t, 1, got.TagsCount)
+	require.Equal(t, 1, got.ManifestsCount)
+	require.Equal(t, size, got.Size)
+	require.NotNil(t, got.LastPublishedAt)
+
+	// stored statistics are not updated by pushes
+	seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("stable"))
+	resp = repositoryStatisticsRequest(t, env, http.MethodGet, "foo/bar")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var stale getResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stale))
+	require.Equal(t, got, stale)
+
+	resp = repositoryStatisticsRequest(t, env, http.MethodPost, "foo/bar")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var recalculated recalculateResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&recalculated))
+	require.Equal(t, "foo/bar", recalculated.Name)
+	require.Equal(t, &got.statistics, recalculated.Before)
+	require.Equal(t, 2, recalculated.After.TagsCount)
+	require.Equal(t, 2, recalculated.After.ManifestsCount)
+	require.Greater(t, recalculated.After.Size, size)
+
+	t.Run("unknown repository", func(t *testing.T) {
+		resp := repositoryStatisticsRequest(t, env, http.MethodGet, "foo/unknown")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp = repositoryStatisticsRequest(t, env, http.MethodPost, "foo/unknown")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestRepositoryStatisticsAPI_NoDatabase(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is enabled")
+	}
+
+	resp := repositoryStatisticsRequest(t, env, http.MethodPost, "foo/bar")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestGroupRepositoriesAPI(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	createRepository(t, env, "foo/bar", "latest")
+	createRepository(t, env, "foo/bar", "1.0")
+	createRepository(t, env, "foo/bar/baz", "latest")
+	createRepository(t, env, "foo/qux", "latest")
+	createRepository(t, env, "other/bar", "latest")
+
+	baseURL := env.server.URL + env.config.HTTP.Prefix + "/gitlab/v1/groups/"
+
+	type repository struct {
+		Name      string `json:"name"`
+		Path      string `json:"path"`
+		TagsCount int    `json:"tags_count"`
+	}
+	type response struct {
+		Name         string       `json:"name"`
+		Repositories []repository `json:"repositories"`
+	}
+
+	getRepositories := func(t *testing.T, u string) (*http.Response, response) {
+		t.Helper()
+
+		resp, err := http.Get(u)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var body response
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		}
+
+		return resp, body
+	}
+
+	t.Run("all", func(t *testing.T) {
+		resp, body := getRepositories(t, baseURL+"foo/repositories")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Empty(t, resp.Header.Get("Link"))
+		require.Equal(t, response{
+			Name: "foo",
+			Repositories: []repository{
+				{Name: "bar", Path: "foo/bar", TagsCount: 2},
+				{Name: "baz", Path: "foo/bar/baz", TagsCount: 1},
+				{Name: "qux", Path: "foo/qux", TagsCount: 1},
+			},
+		}, body)
+	})
+
+	t.Run("nested group includes itself", func(t *testing.T) {
+		resp, body := getRepositories(t, baseURL+"foo/bar/repositories")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Len(t, body.Repositories, 2)
+		require.Equal(t, "foo/bar", body.Repositories[0].Path)
+		require.Equal(t, "foo/bar/baz", body.Repositories[1].Path)
+	})
+
+	t.Run("paginated", func(t *testing.T) {
+		resp, body := getRepositories(t, baseURL+"foo/repositories?n=2")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Len(t, body.Repositories, 2)
+		require.Equal(t, "foo/bar/baz", body.Repositories[1].Path)
+
+		link := resp.Header.Get("Link")
+		require.Contains(t, link, "last=foo%2Fbar%2Fbaz")
+		require.Contains(t, link, "n=2")
+
+		next := strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+		resp, body = getRepositories(t, withServerURL(t, env, next))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Empty(t, resp.Header.Get("Link"))
+		require.Len(t, body.Repositories, 1)
+		require.Equal(t, "foo/qux", body.Repositories[0].Path)
+	})
+
+	t.Run("unknown group", func(t *testing.T) {
+		resp, _ := getRepositories(t, baseURL+"unknown/repositories")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("invalid n", func(t *testing.T) {
+		resp, _ := getRepositories(t, baseURL+"foo/repositories?n=0")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestGroupRepositoriesAPI_NoDatabase(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is enabled")
+	}
+
+	resp, err := http.Get(env.server.URL + env.config.HTTP.Prefix + "/gitlab/v1/groups/foo/repositories")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestRepositoryTagsAPI(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	dgst := createRepository(t, env, "foo/bar", "b")
+	createRepository(t, env, "foo/bar", "a")
+	createRepository(t, env, "foo/bar", "c")
+
+	baseURL := env.server.URL + env.config.HTTP.Prefix + "/gitlab/v1/repositories/"
+
+	type tag struct {
+		Name         string `json:"name"`
+		Digest       string `json:"digest"`
+		MediaType    string `json:"media_type"`
+		ArtifactType string `json:"artifact_type"`
+		CreatedAt    string `json:"created_at"`
+		UpdatedAt    string `json:"updated_at"`
+	}
+	type response struct {
+		Name      string `json:"name"`
+		UpdatedAt string `json:"updated_at"`
+		Tags      []tag  `json:"tags"`
+	}
+
+	getTags := func(t *testing.T, u string) (*http.Response, response) {
+		t.Helper()
+
+		resp, err := http.Get(u)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var body response
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		}
+
+		return resp, body
+	}
+
+	t.Run("all", func(t *testing.T) {
+		resp, body := getTags(t, baseURL+"foo/bar/tags/list")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Empty(t, resp.Header.Get("Link"))
+		require.Equal(t, "foo/bar", body.Name)
+		require.NotEmpty(t, body.UpdatedAt)
+		require.Len(t, body.Tags, 3)
+		require.Equal(t, "a", body.Tags[0].Name)
+		require.Equal(t, "b", body.Tags[1].Name)
+		require.Equal(t, dgst.String(), body.Tags[1].Digest)
+		require.Equal(t, schema2.MediaTypeManifest, body.Tags[1].MediaType)
+		require.Equal(t, "image", body.Tags[1].ArtifactType)
+		require.NotEmpty(t, body.Tags[1].CreatedAt)
+		require.NotEmpty(t, body.Tags[1].UpdatedAt)
+		require.Equal(t, "c", body.Tags[2].Name)
+	})
+
+	t.Run("paginated", func(t *testing.T) {
+		resp, body := getTags(t, baseURL+"foo/bar/tags/list?n=2")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Len(t, body.Tags, 2)
+		require.Equal(t, "b", body.Tags[1].Name)
+
+		link := resp.Header.Get("Link")
+		require.Contains(t, link, "last=b")
+		require.Contains(t, link, "n=2")
+
+		next := strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+		resp, body = getTags(t, withServerURL(t, env, next))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Empty(t, resp.Header.Get("Link"))
+		require.Len(t, body.Tags, 1)
+		require.Equal(t, "c", body.Tags[0].Name)
+	})
+
+	t.Run("unknown repository", func(t *testing.T) {
+		resp, _ := getTags(t, baseURL+"unknown/tags/list")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("invalid last", func(t *testing.T) {
+		resp, _ := getTags(t, baseURL+"foo/bar/tags/list?last=-invalid")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestRepositoryTagsAPI_NoDatabase(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is enabled")
+	}
+
+	resp, err := http.Get(env.server.URL + env.config.HTTP.Prefix + "/gitlab/v1/repositories/foo/bar/tags/list")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestRepositoryManifestsAPI(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	taggedDigest := createRepository(t, env, "foo/bar", "latest")
+	m := seedRandomSchema2Manifest(t, env, "foo/bar", putByDigest)
+	_, payload, err := m.Payload()
+	require.NoError(t, err)
+	untaggedDigest := digest.FromBytes(payload)
+
+	baseURL := env.server.URL + env.config.HTTP.Prefix + "/gitlab/v1/repositories/"
+
+	type manifest struct {
+		Digest       string   `json:"digest"`
+		MediaType    string   `json:"media_type"`
+		ArtifactType string   `json:"artifact_type"`
+		Size         int64    `json:"size"`
+		Tags         []string `json:"tags"`
+		CreatedAt    string   `json:"created_at"`
+	}
+	type response struct {
+		Name      string     `json:"name"`
+		Manifests []manifest `json:"manifests"`
+	}
+
+	getManifests := func(t *testing.T, u string) (*http.Response, response) {
+		t.Helper()
+
+		resp, err := http.Get(u)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var body response
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		}
+
+		return resp, body
+	}
+
+	// manifests are sorted by digest
+	sorted := []digest.Digest{taggedDigest, untaggedDigest}
+	if untaggedDigest < taggedDigest {
+		sorted = []digest.Digest{untaggedDigest, taggedDigest}
+	}
+
+	t.Run("all", func(t *testing.T) {
+		resp, body := getManifests(t, baseURL+"foo/bar/manifests")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Empty(t, resp.Header.Get("Link"))
+		require.Equal(t, "foo/bar", body.Name)
+		require.Len(t, body.Manifests, 2)
+
+		for _, m := range body.Manifests {
+			require.Equal(t, schema2.MediaTypeManifest, m.MediaType)
+			require.Equal(t, "image", m.ArtifactType)
+			require.Positive(t, m.Size)
+			require.NotEmpty(t, m.CreatedAt)
+			switch m.Digest {
+			case taggedDigest.String():
+				require.Equal(t, []string{"latest"}, m.Tags)
+			case untaggedDigest.String():
+				require.Empty(t, m.Tags)
+				require.NotNil(t, m.Tags)
+			default:
+				t.Fatalf("unexpected manifest %q", m.Digest)
+			}
+		}
+	})
+
+	t.Run("paginated", func(t *testing.T) {
+		resp, body := getManifests(t, baseURL+"foo/bar/manifests?n=1")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Len(t, body.Manifests, 1)
+		require.Equal(t, sorted[0].String(), body.Manifests[0].Digest)
+
+		link := resp.Header.Get("Link")
+		require.Contains(t, link, "last="+url.QueryEscape(sorted[0].String()))
+		require.Contains(t, link, "n=1")
+
+		next := strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+		resp, body = getManifests(t, withServerURL(t, env, next))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Empty(t, resp.Header.Get("Link"))
+		require.Len(t, body.Manifests, 1)
+		require.Equal(t, sorted[1].String(), body.Manifests[0].Digest)
+	})
+
+	t.Run("untagged only", func(t *testing.T) {
+		resp, body := getManifests(t, baseURL+"foo/bar/manifests?untagged=true")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Len(t, body.Manifests, 1)
+		require.Equal(t, untaggedDigest.String(), body.Manifests[0].Digest)
+	})
+
+	t.Run("unknown repository", func(t *testing.T) {
+		resp, _ := getManifests(t, baseURL+"unknown/manifests")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("invalid last", func(t *testing.T) {
+		resp, _ := getManifests(t, baseURL+"foo/bar/manifests?last=invalid")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestRepositoryManifestsAPI_NoDatabase(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is enabled")
+	}
+
+	resp, err := http.Get(env.server.URL + env.config.HTTP.Prefix + "/gitlab/v1/repositories/foo/bar/manifests")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestRepositoryUploadsAPI(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	type upload struct {
+		UUID           string    `json:"uuid"`
+		BytesReceived  int64     `json:"bytes_received"`
+		StartedAt      time.Time `json:"started_at"`
+		LastActivityAt time.Time `json:"last_activity_at"`
+	}
+	type response struct {
+		Name    string   `json:"name"`
+		Uploads []upload `json:"uploads"`
+	}
+
+	listUploads := func(t *testing.T) response {
+		t.Helper()
+
+		resp, err := http.Get(env.server.URL + env.config.HTTP.Prefix + "/gitlab/v1/repositories/foo/bar/uploads")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body response
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+		return body
+	}
+
+	body := listUploads(t)
+	require.Equal(t, "foo/bar", body.Name)
+	require.Empty(t, body.Uploads)
+
+	location, uploadUUID := startPushLayer(t, env, repoRef)
+	location, _ = pushChunk(t, env.builder, repoRef, location, bytes.NewReader(make([]byte, 10)), 10)
+
+	t.Run("list", func(t *testing.T) {
+		body := listUploads(t)
+		require.Len(t, body.Uploads, 1)
+		require.Equal(t, uploadUUID, body.Uploads[0].UUID)
+		require.EqualValues(t, 10, body.Uploads[0].BytesReceived)
+		require.False(t, body.Uploads[0].StartedAt.IsZero())
+		require.False(t, body.Uploads[0].LastActivityAt.Before(body.Uploads[0].StartedAt))
+	})
+
+	t.Run("status with json", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, location, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "0-9", resp.Header.Get("Range"))
+		require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		var u upload
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&u))
+		require.Equal(t, uploadUUID, u.UUID)
+		require.EqualValues(t, 10, u.BytesReceived)
+		require.False(t, u.StartedAt.IsZero())
+	})
+
+	t.Run("status without json", func(t *testing.T) {
+		resp, err := http.Get(location)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+		require.Equal(t, "0-9", resp.Header.Get("Range"))
+	})
+
+	t.Run("cancel all", func(t *testing.T) {
+		_, otherUUID := startPushLayer(t, env, repoRef)
+
+		req, err := http.NewRequest(http.MethodDelete, env.server.URL+env.config.HTTP.Prefix+"/gitlab/v1/repositories/foo/bar/uploads", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body response
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		require.Equal(t, "foo/bar", body.Name)
+		require.Len(t, body.Uploads, 2)
+		require.ElementsMatch(t, []string{uploadUUID, otherUUID}, []string{body.Uploads[0].UUID, body.Uploads[1].UUID})
+
+		require.Empty(t, listUploads(t).Uploads)
+
+		// cancelled uploads can no longer be resumed
+		resp, err = http.Get(location)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func withRedirectVerification(mode, interval string) configOpt {
+	return func(config *configuration.Configuration) {
+		config.Storage["redirect"] = configuration.Parameters{"disable": false, "verify": mode, "verifyinterval": interval}
+	}
+}
+
+func TestBlobAPI_RedirectVerification(t *testing.T) {
+	tt := []struct {
+		name                   string
+		mode                   string
+		interval               string
+		expectedReverifyStatus int
+	}{
+		{
+			name:                   "first read",
+			mode:                   "firstread",
+			interval:               "1h",
+			expectedReverifyStatus: http.StatusOK,
+		},
+		{
+			name:                   "periodic",
+			mode:                   "periodic",
+			interval:               "1ns",
+			expectedReverifyStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			env := newTestEnv(t, withSharedInMemoryDriver(t.Name()), withRedirectVerification(test.mode, test.interval))
+			defer env.Shutdown()
+
+			if !env.config.Database.Enabled {
+				t.Skip("skipping test because the metadata database is not enabled")
+			}
+
+			repoRef, err := reference.WithName("foo/bar")
+			require.NoError(t, err)
+
+			content := []byte("redirect verification")
+			dgst := digest.FromBytes(content)
+			uploadURLBase, _ := startPushLayer(t, env, repoRef)
+			pushLayer(t, env.builder, repoRef, dgst, uploadURLBase, bytes.NewReader(content))
+
+			ref, err := reference.WithDigest(repoRef, dgst)
+			require.NoError(t, err)
+			blobURL, err := env.builder.BuildBlobURL(ref)
+			require.NoError(t, err)
+
+			getBlob := func(t *testing.T) int {
+				t.Helper()
+
+				resp, err := http.Get(blobURL)
+				require.NoError(t, err)
+				defer resp.Body.Close()
+
+				return resp.StatusCode
+			}
+
+			// the first read verifies the blob
+			require.Equal(t, http.StatusOK, getBlob(t))
+
+			// corrupt the blob in storage, which is only detected if the blob is verified again
+			d, err := factory.Create("sharedinmemorydriver", map[string]interface{}{"name": t.Name()})
+			require.NoError(t, err)
+			blobDataPath := fmt.Sprintf("/docker/registry/v2/blobs/sha256/%s/%s/data", dgst.Hex()[0:2], dgst.Hex())
+			require.NoError(t, d.PutContent(env.ctx, blobDataPath, []byte("corrupted")))
+
+			require.Equal(t, test.expectedReverifyStatus, getBlob(t))
+		})
+	}
+}
+
+func withHTTPSecret(secret string) configOpt {
+	return func(config *configuration.Configuration) {
+		config.HTTP.Secret = secret
+	}
+}
+
+func withRedisUploadState(addr string) configOpt {
+	return func(config *configuration.Configuration) {
+		config.Redis.Addr = addr
+		config.Redis.UploadState.Enabled = true
+	}
+}
+
+// withServerURL rewrites rawURL to target the server of env.
+func withServerURL(t *testing.T, env *testEnv, rawURL string) string {
+	t.Helper()
+
+	u, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	base, err := url.Parse(env.server.URL)
+	require.NoError(t, err)
+	u.Scheme, u.Host = base.Scheme, base.Host
+
+	return u.String()
+}
+
+func TestBlobAPI_UploadsTrackedInDatabase(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	imageName, _ := reference.WithName("foo/bar")
+	s := datastore.NewBlobUploadStore(env.db)
+	uploadIDs := func() []string {
+		t.Helper()
+
+		uu, err := s.FindStartedBefore(env.ctx, time.Now().Add(time.Minute), 100)
+		require.NoError(t, err)
+		ids := make([]string, 0, len(uu))
+		for _, u := range uu {
+			require.Equal(t, imageName.Name(), u.RepositoryPath)
+			ids = append(ids, u.ID)
+		}
+		return ids
+	}
+
+	// a completed upload is no longer tracked
+	uploadURLBase, uploadUUID := startPushLayer(t, env, imageName)
+	require.Contains(t, uploadIDs(), uploadUUID)
+
+	payload := bytes.Repeat([]byte("a"), 64)
+	pushLayer(t, env.builder, imageName, digest.FromBytes(payload), uploadURLBase, bytes.NewReader(payload))
+	require.NotContains(t, uploadIDs(), uploadUUID)
+
+	// neither is a canceled one
+	uploadURLBase, uploadUUID = startPushLayer(t, env, imageName)
+	require.Contains(t, uploadIDs(), uploadUUID)
+
+	req, err := http.NewRequest("DELETE", uploadURLBase, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.NotContains(t, uploadIDs(), uploadUUID)
+}
+
+func TestBlobAPI_ResumeUploadAcrossInstances(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("the 'REDIS_ADDR' environment variable must be set to enable this test")
+	}
+
+	// two instances sharing the same storage, but with different HTTP secrets
+	env1 := newTestEnv(t, withSharedInMemoryDriver(t.Name()), withHTTPSecret("foo"), withRedisUploadState(addr))
+	defer env1.Shutdown()
+	env2 := newTestEnv(t, withSharedInMemoryDriver(t.Name()), withHTTPSecret("bar"), withRedisUploadState(addr))
+	defer env2.Shutdown()
+
+	imageName, _ := reference.WithName("foo/bar")
+	payload := bytes.Repeat([]byte("a"), 1024)
+	dgst := digest.FromBytes(payload)
+
+	// start the upload and send the first chunk to the first instance, and the second chunk and the completion
+	// request to the second instance
+	uploadURLBase, _ := startPushLayer(t, env1, imageName)
+	uploadURLBase, _ = pushChunk(t, env1.builder, imageName, uploadURLBase, bytes.NewReader(payload[:512]), 512)
+
+	uploadURLBase, _ = pushChunk(t, env2.builder, imageName, withServerURL(t, env2, uploadURLBase), bytes.NewReader(payload[512:]), 1024)
+	blobURL := finishUpload(t, env2.builder, imageName, withServerURL(t, env2, uploadURLBase), dgst)
+
+	resp, err := http.Get(blobURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
+}
+
+func TestBlobAPI_ResumeUploadAcrossInstances_NoUploadState(t *testing.T) {
+	env1 := newTestEnv(t, withSharedInMemoryDriver(t.Name()), withHTTPSecret("foo"))
+	defer env1.Shutdown()
+	env2 := newTestEnv(t, withSharedInMemoryDriver(t.Name()), withHTTPSecret("bar"))
+	defer env2.Shutdown()
+
+	imageName, _ := reference.WithName("foo/bar")
+
+	// without persisted upload states, the second instance is unable to validate the upload state token
+	uploadURLBase, _ := startPushLayer(t, env1, imageName)
+	resp, _, err := doPushChunk(t, withServerURL(t, env2, uploadURLBase), bytes.NewReader([]byte("foo")))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	checkResponse(t, "pushing chunk to a different instance", resp, http.StatusNotFound)
+	checkBodyHasErrorCodes(t, "pushing chunk to a different instance", resp, v2.ErrorCodeBlobUploadInvalid)
+}
+
+func TestBlobAPI_PutUploadCompleteWithContentRange(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	imageName, _ := reference.WithName("foo/bar")
+	payload := bytes.Repeat([]byte("a"), 1024)
+	dgst := digest.FromBytes(payload)
+
+	putFinalChunk := func(uploadURLBase, contentRange string, body []byte) *http.Response {
+		t.Helper()
+
+		u, err := url.Parse(uploadURLBase)
+		require.NoError(t, err)
+		u.RawQuery = url.Values{
+			"_state": u.Query()["_state"],
+			"digest": []string{dgst.String()},
+		}.Encode()
+
+		req, err := http.NewRequest("PUT", u.String(), bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("Content-Range", contentRange)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	// send the first half as a chunk and the second half along with the completion request
+	uploadURLBase, _ := startPushLayer(t, env, imageName)
+	uploadURLBase, _ = pushChunk(t, env.builder, imageName, uploadURLBase, bytes.NewReader(payload[:512]), 512)
+
+	resp := putFinalChunk(uploadURLBase, "512-1023", payload[512:])
+	defer resp.Body.Close()
+	checkResponse(t, "completing upload with final chunk", resp, http.StatusCreated)
+	checkHeaders(t, resp, http.Header{
+		"Docker-Content-Digest": []string{dgst.String()},
+	})
+
+	// a range that does not start at the current offset must be rejected
+	uploadURLBase, _ = startPushLayer(t, env, imageName)
+	uploadURLBase, _ = pushChunk(t, env.builder, imageName, uploadURLBase, bytes.NewReader(payload[:512]), 512)
+
+	resp = putFinalChunk(uploadURLBase, "500-1011", payload[512:])
+	defer resp.Body.Close()
+	checkResponse(t, "completing upload with invalid range", resp, http.StatusRequestedRangeNotSatisfiable)
+	checkBodyHasErrorCodes(t, "completing upload with invalid range", resp, v2.ErrorCodeRangeInvalid)
+}
+
+func withStrictChunkOrdering(config *configuration.Configuration) {
+	config.Validation.Uploads.StrictChunkOrdering = true
+}
+
+func TestBlobAPI_StrictChunkOrdering(t *testing.T) {
+	env := newTestEnv(t, withStrictChunkOrdering)
+	defer env.Shutdown()
+
+	imageName, _ := reference.WithName("foo/bar")
+	payload := bytes.Repeat([]byte("a"), 1024)
+	dgst := digest.FromBytes(payload)
+
+	staleURL, _ := startPushLayer(t, env, imageName)
+	uploadURLBase, _ := pushChunk(t, env.builder, imageName, staleURL, bytes.NewReader(payload[:512]), 512)
+
+	// resending the chunk through the location of the previous request is rejected, but the upload is left intact
+	resp, _, err := doPushChunk(t, staleURL, bytes.NewReader(payload[:512]))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "pushing out of order chunk", resp, http.StatusRequestedRangeNotSatisfiable)
+	checkBodyHasErrorCodes(t, "pushing out of order chunk", resp, v2.ErrorCodeRangeInvalid)
+	checkHeaders(t, resp, http.Header{
+		"Location": []string{"*"},
+		"Range":    []string{"0-511"},
+	})
+
+	// the upload can be resumed from the returned location
+	uploadURLBase, _ = pushChunk(t, env.builder, imageName, resp.Header.Get("Location"), bytes.NewReader(payload[512:]), 1024)
+	finishUpload(t, env.builder, imageName, uploadURLBase, dgst)
+}
+
+func TestBlobAPI_OutOfOrderChunk(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	imageName, _ := reference.WithName("foo/bar")
+	payload := bytes.Repeat([]byte("a"), 1024)
+
+	staleURL, _ := startPushLayer(t, env, imageName)
+	pushChunk(t, env.builder, imageName, staleURL, bytes.NewReader(payload[:512]), 512)
+
+	// without strict chunk ordering, an out of date upload location invalidates the upload
+	resp, _, err := doPushChunk(t, staleURL, bytes.NewReader(payload[:512]))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "pushing out of order chunk", resp, http.StatusNotFound)
+	checkBodyHasErrorCodes(t, "pushing out of order chunk", resp, v2.ErrorCodeBlobUploadInvalid)
+}
+
+func withParallelChunks(config *configuration.Configuration) {
+	config.Validation.Uploads.ParallelChunks = true
+}
+
+// doPushRangedChunk pushes body as the chunk of the upload starting at start, through the given upload location.
+func doPushRangedChunk(t *testing.T, uploadURLBase string, start int64, body []byte) (*http.Response, error) {
+	t.Helper()
+
+	u, err := url.Parse(uploadURLBase)
+	require.NoError(t, err)
+	u.RawQuery = url.Values{"_state": u.Query()["_state"]}.Encode()
+
+	req, err := http.NewRequest(http.MethodPatch, u.String(), bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Range", fmt.Sprintf("%d-%d", start, start+int64(len(body))-1))
+
+	return http.DefaultClient.Do(req)
+}
+
+func TestBlobAPI_ParallelChunks(t *testing.T) {
+	env := newTestEnv(t, withParallelChunks)
+	defer env.Shutdown()
+
+	imageName, _ := reference.WithName("foo/bar")
+	payload := bytes.Repeat([]byte("abcd"), 1024)
+	dgst := digest.FromBytes(payload)
+	chunkSize := int64(1024)
+
+	uploadURLBase, _ := startPushLayer(t, env, imageName)
+
+	// all chunks are sent concurrently through the location of the upload start
+	var g errgroup.Group
+	for start := int64(len(payload)) - chunkSize; start >= 0; start -= chunkSize {
+		start := start
+		g.Go(func() error {
+			resp, err := doPushRangedChunk(t, uploadURLBase, start, payload[start:start+chunkSize])
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusAccepted {
+				return fmt.Errorf("unexpected status pushing chunk at offset %d: %d", start, resp.StatusCode)
+			}
+			return nil
+		})
+	}
+	require.NoError(t, g.Wait())
+
+	finishUpload(t, env.builder, imageName, uploadURLBase, dgst)
+}
+
+func TestBlobAPI_ParallelChunks_Incomplete(t *testing.T) {
+	env := newTestEnv(t, withParallelChunks)
+	defer env.Shutdown()
+
+	imageName, _ := reference.WithName("foo/bar")
+	payload := bytes.Repeat([]byte("a"), 1024)
+
+	uploadURLBase, _ := startPushLayer(t, env, imageName)
+
+	// the first half is never sent
+	resp, err := doPushRangedChunk(t, uploadURLBase, 512, payload[512:])
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "pushing chunk ahead of upload offset", resp, http.StatusAccepted)
+
+	resp, err = doPushLayer(t, env.builder, imageName, digest.FromBytes(payload), uploadURLBase, nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "completing incomplete upload", resp, http.StatusNotFound)
+	checkBodyHasErrorCodes(t, "completing incomplete upload", resp, v2.ErrorCodeBlobUploadInvalid)
+}
+
+func TestBlobAPI_ParallelChunks_Disabled(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	imageName, _ := reference.WithName("foo/bar")
+	payload := bytes.Repeat([]byte("a"), 1024)
+
+	uploadURLBase, _ := startPushLayer(t, env, imageName)
+
+	resp, err := doPushRangedChunk(t, uploadURLBase, 512, payload[512:])
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "pushing chunk ahead of upload offset", resp, http.StatusRequestedRangeNotSatisfiable)
+	checkBodyHasErrorCodes(t, "pushing chunk ahead of upload offset", resp, v2.ErrorCodeRangeInvalid)
+}
+
+func TestBlobAPI_MinChunkLength(t *testing.T) {
+	imageName, _ := reference.WithName("foo/bar")
+
+	startUpload := func(t *testing.T, env *testEnv) *http.Response {
+		t.Helper()
+
+		layerUploadURL, err := env.builder.BuildBlobUploadURL(imageName)
+		require.NoError(t, err)
+
+		resp, err := http.Post(layerUploadURL, "", nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+		return resp
+	}
+
+	t.Run("advertised", func(t *testing.T) {
+		env := newTestEnv(t, func(config *configuration.Configuration) {
+			config.Validation.Uploads.MinChunkLength = 5 << 20
+		})
+		defer env.Shutdown()
+
+		resp := startUpload(t, env)
+		require.Equal(t, "5242880", resp.Header.Get("OCI-Chunk-Min-Length"))
+	})
+
+	t.Run("default", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.Shutdown()
+
+		resp := startUpload(t, env)
+		require.NotContains(t, resp.Header, "Oci-Chunk-Min-Length")
+	})
+}
+
+func TestReferrersAPI_Unsupported(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	// clients fall back to the referrers tag schema when the referrers API responds with 404 Not Found
+	u := env.server.URL + env.config.HTTP.Prefix + "/v2/foo/bar/referrers/" + digestSha256EmptyTar
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestBlobAPI_DigestMismatchDetail(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	imageName, _ := reference.WithName("foo/bar")
+	payload := bytes.Repeat([]byte("a"), 1024)
+	// the second chunk is corrupted in transit
+	received := append(payload[:512:512], bytes.Repeat([]byte("b"), 512)...)
+
+	patchChunk := func(uploadURLBase string, body []byte, chunkDgst digest.Digest) string {
+		t.Helper()
+
+		u, err := url.Parse(uploadURLBase)
+		require.NoError(t, err)
+		u.RawQuery = url.Values{"_state": u.Query()["_state"]}.Encode()
 
-func TestDeleteReadOnly(t *testing.T) {
-	setupEnv := newTestEnv(t, withSharedInMemoryDriver(t.Name()))
-	defer setupEnv.Shutdown()
+		req, err := http.NewRequest(http.MethodPatch, u.String(), bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/octet-stream")
+		if chunkDgst != "" {
+			req.Header.Set("Docker-Chunk-Digest", chunkDgst.String())
+		}
 
-	imageName, _ := reference.WithName("foo/bar")
-	// "build" our layer file
-	layerFile, layerDigest, err := testutil.CreateRandomTarFile()
-	if err != nil {
-		t.Fatalf("error creating random layer file: %v", err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+		return resp.Header.Get("Location")
 	}
 
-	ref, _ := reference.WithDigest(imageName, layerDigest)
-	uploadURLBase, _ := startPushLayer(t, setupEnv, imageName)
-	pushLayer(t, setupEnv.builder, imageName, layerDigest, uploadURLBase, layerFile)
+	completeUpload := func(uploadURLBase string) map[string]interface{} {
+		t.Helper()
 
-	// Reconfigure environment with withReadOnly enabled.
-	setupEnv.Shutdown()
-	env := newTestEnv(t, withSharedInMemoryDriver(t.Name()), withReadOnly)
-	defer env.Shutdown()
+		resp, err := doPushLayer(t, env.builder, imageName, digest.FromBytes(payload), uploadURLBase, nil)
+		require.NoError(t, err)
+		defer resp.Body.Close()
 
-	layerURL, err := env.builder.BuildBlobURL(ref)
-	if err != nil {
-		t.Fatalf("Error building blob URL")
-	}
+		checkResponse(t, "completing upload with corrupted content", resp, http.StatusBadRequest)
+		errs, _, _ := checkBodyHasErrorCodes(t, "completing upload with corrupted content", resp, v2.ErrorCodeDigestInvalid)
+		require.Len(t, errs, 1)
+		detail, ok := errs[0].(errcode.Error).Detail.(map[string]interface{})
+		require.True(t, ok)
 
-	resp, err := httpDelete(layerURL)
-	if err != nil {
-		t.Fatalf("unexpected error deleting layer: %v", err)
+		return detail
 	}
 
-	checkResponse(t, "deleting layer in read-only mode", resp, http.StatusMethodNotAllowed)
-}
+	// with chunk digests, the first corrupted chunk is reported
+	uploadURLBase, _ := startPushLayer(t, env, imageName)
+	uploadURLBase = patchChunk(uploadURLBase, received[:512], digest.FromBytes(payload[:512]))
+	uploadURLBase = patchChunk(uploadURLBase, received[512:], digest.FromBytes(payload[512:]))
 
-func TestStartPushReadOnly(t *testing.T) {
-	env := newTestEnv(t, withDelete, withReadOnly)
-	defer env.Shutdown()
+	detail := completeUpload(uploadURLBase)
+	require.Equal(t, digest.FromBytes(payload).String(), detail["expected_digest"])
+	require.Equal(t, digest.FromBytes(received).String(), detail["computed_digest"])
+	require.EqualValues(t, len(received), detail["received_bytes"])
+	require.EqualValues(t, 512, detail["mismatched_chunk_offset"])
 
-	imageName, _ := reference.WithName("foo/bar")
+	// without chunk digests, only the received content is described
+	uploadURLBase, _ = startPushLayer(t, env, imageName)
+	uploadURLBase = patchChunk(uploadURLBase, received[:512], "")
+	uploadURLBase = patchChunk(uploadURLBase, received[512:], "")
 
-	layerUploadURL, err := env.builder.BuildBlobUploadURL(imageName)
-	if err != nil {
-		t.Fatalf("unexpected error building layer upload url: %v", err)
-	}
+	detail = completeUpload(uploadURLBase)
+	require.Equal(t, digest.FromBytes(received).String(), detail["computed_digest"])
+	require.EqualValues(t, len(received), detail["received_bytes"])
+	require.NotContains(t, detail, "mismatched_chunk_offset")
 
-	resp, err := http.Post(layerUploadURL, "", nil)
-	if err != nil {
-		t.Fatalf("unexpected error starting layer push: %v", err)
-	}
-	defer resp.Body.Close()
+	// invalid chunk digests are rejected
+	uploadURLBase, _ = startPushLayer(t, env, imageName)
+	u, err := url.Parse(uploadURLBase)
+	require.NoError(t, err)
+	u.RawQuery = url.Values{"_state": u.Query()["_state"]}.Encode()
+	req, err := http.NewRequest(http.MethodPatch, u.String(), bytes.NewReader(payload))
+	require.NoError(t, err)
+	req.Header.Set("Docker-Chunk-Digest", "foo")
 
-	checkResponse(t, "starting push in read-only mode", resp, http.StatusMethodNotAllowed)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "pushing chunk with invalid chunk digest", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "pushing chunk with invalid chunk digest", resp, v2.ErrorCodeDigestInvalid)
 }
 
 func httpDelete(url string) (*http.Response, error) {
@@ -1465,6 +4410,8 @@ func TestAPIConformance(t *testing.T) {
 		manifest_Get_OCIIndex_NonMatchingEtag,
 
 		manifest_Get_ManifestList_FallbackToSchema2,
+		manifest_Get_ManifestList_Platform,
+		manifest_Get_ManifestList_FallbackDefaultPlatforms,
 
 		blob_Head,
 		blob_Head_BlobNotFound,
@@ -1486,6 +4433,9 @@ func TestAPIConformance(t *testing.T) {
 		tags_Delete_Unknown,
 		tags_Delete_UnknownRepository,
 		tags_Delete_WithSameImageID,
+		tags_DeleteBatch,
+		tags_DeleteBatch_InvalidTag,
+		tags_DeleteBatch_UnknownRepository,
 
 		catalog_Get,
 		catalog_Get_Empty,
@@ -1796,6 +4746,46 @@ func manifest_Get_Schema2_MatchingEtag(t *testing.T, opts ...configOpt) {
 	}
 }
 
+func TestManifestAPI_Get_ImageSizeHeader(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	repoPath := "foo/bar"
+	m := seedRandomSchema2Manifest(t, env, repoPath, putByTag("latest"))
+
+	want := m.Config.Size
+	for _, l := range m.Layers {
+		want += l.Size
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		req, err := http.NewRequest(method, buildManifestTagURL(t, env, repoPath, "latest"), nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode, method)
+		require.Equal(t, strconv.FormatInt(want, 10), resp.Header.Get("Gitlab-Image-Size-Bytes"), method)
+	}
+
+	// manifest lists do not describe a single image, so there is no size to report
+	ml := seedRandomOCIImageIndex(t, env, repoPath, putByTag("index"))
+	req, err := http.NewRequest(http.MethodGet, buildManifestDigestURL(t, env, repoPath, ml), nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", v1.MediaTypeImageIndex)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, resp.Header.Get("Gitlab-Image-Size-Bytes"))
+}
+
 func TestManifestAPI_Get_Schema2LayersAndConfigNotInDatabase(t *testing.T) {
 	env := newTestEnv(t)
 	defer env.Shutdown()
@@ -2470,7 +5460,44 @@ func manifest_Put_Schema1_ByTag(t *testing.T, opts ...configOpt) {
 	defer resp.Body.Close()
 
 	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
-	checkBodyHasErrorCodes(t, "invalid manifest", resp, v2.ErrorCodeManifestInvalid)
+	errs, _, _ := checkBodyHasErrorCodes(t, "invalid manifest", resp, v2.ErrorCodeManifestInvalid)
+	require.Len(t, errs, 1)
+	errc, ok := errs[0].(errcode.Error)
+	require.True(t, ok)
+	require.Equal(t, "Schema 1 manifests are no longer supported", errc.Message)
+	require.Contains(t, errc.Detail, "instructions")
+}
+
+func withSchema1MigrationURL(u string) configOpt {
+	return func(config *configuration.Configuration) {
+		config.Compatibility.Schema1.MigrationURL = u
+	}
+}
+
+func TestManifestAPI_Put_Schema1_MigrationURL(t *testing.T) {
+	env := newTestEnv(t, withSchema1MigrationURL("https://docs.example.com/schema1"))
+	defer env.Shutdown()
+
+	repoPath := "schema1"
+	signedManifest, err := schema1.Sign(&schema1.Manifest{
+		Versioned: manifest.Versioned{SchemaVersion: 1},
+		Name:      repoPath,
+		Tag:       "latest",
+	}, env.pk)
+	require.NoError(t, err)
+
+	resp := putManifest(t, "putting schema1 manifest", buildManifestTagURL(t, env, repoPath, "latest"), schema1.MediaTypeManifest, signedManifest)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	errs, _, _ := checkBodyHasErrorCodes(t, "invalid manifest", resp, v2.ErrorCodeManifestInvalid)
+	require.Len(t, errs, 1)
+	errc, ok := errs[0].(errcode.Error)
+	require.True(t, ok)
+	require.Equal(t, map[string]interface{}{
+		"instructions":  "rebuild the image with Docker 1.10 or later, or convert it with `skopeo copy --format v2s2`, and push it again as a schema 2 or OCI manifest",
+		"documentation": "https://docs.example.com/schema1",
+	}, errc.Detail)
 }
 
 func manifest_Put_Schema1_ByDigest(t *testing.T, opts ...configOpt) {
@@ -3048,6 +6075,26 @@ func TestManifestAPI_Delete_Schema2ManifestNotInDatabase(t *testing.T) {
 	require.Equal(t, http.StatusNotFound, resp.StatusCode)
 }
 
+func TestManifestAPI_Delete_RepositoryNotInDatabase(t *testing.T) {
+	env := newTestEnv(t, withDelete)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	// the repository only exists on the filesystem
+	repoPath := "schema2/delete"
+	deserializedManifest := seedRandomSchema2Manifest(t, env, repoPath, putByTag("latest"), writeToFilesystemOnly)
+
+	resp, err := httpDelete(buildManifestDigestURL(t, env, repoPath, deserializedManifest))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	checkResponse(t, "deleting manifest from repository not in database", resp, http.StatusNotFound)
+	checkBodyHasErrorCodes(t, "deleting manifest from repository not in database", resp, v2.ErrorCodeNameUnknown)
+}
+
 func TestManifestAPI_Delete_ManifestReferencedByList(t *testing.T) {
 	env := newTestEnv(t, withDelete)
 	defer env.Shutdown()
@@ -3075,6 +6122,62 @@ func TestManifestAPI_Delete_ManifestReferencedByList(t *testing.T) {
 	checkBodyHasErrorCodes(t, "", resp, v2.ErrorCodeManifestReferencedInList)
 }
 
+func TestManifestAPI_Delete_ByTag(t *testing.T) {
+	env := newTestEnv(t, withUntag)
+	defer env.Shutdown()
+
+	repoPath := "schema2/untag"
+	tagName := "latest"
+	deserializedManifest := seedRandomSchema2Manifest(t, env, repoPath, putByTag(tagName))
+
+	tagURL := buildManifestTagURL(t, env, repoPath, tagName)
+	digestURL := buildManifestDigestURL(t, env, repoPath, deserializedManifest)
+
+	resp, err := httpDelete(tagURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	// the tag is gone, but the manifest is still there
+	resp, err = http.Get(tagURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Get(digestURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// deleting an unknown tag
+	resp, err = httpDelete(tagURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "deleting unknown tag", resp, http.StatusNotFound)
+	checkBodyHasErrorCodes(t, "deleting unknown tag", resp, v2.ErrorCodeManifestUnknown)
+}
+
+func TestManifestAPI_Delete_ByTag_UntagDisabled(t *testing.T) {
+	env := newTestEnv(t, withDelete)
+	defer env.Shutdown()
+
+	repoPath := "schema2/untag"
+	tagName := "latest"
+	seedRandomSchema2Manifest(t, env, repoPath, putByTag(tagName))
+
+	tagURL := buildManifestTagURL(t, env, repoPath, tagName)
+
+	resp, err := httpDelete(tagURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.NotEqual(t, http.StatusAccepted, resp.StatusCode)
+
+	resp, err = http.Get(tagURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
 func manifest_Put_OCI_ByTag(t *testing.T, opts ...configOpt) {
 	env := newTestEnv(t, opts...)
 	defer env.Shutdown()
@@ -3512,26 +6615,247 @@ func manifest_Get_ManifestList_FallbackToSchema2(t *testing.T, opts ...configOpt
 	_, payload, err = deserializedManifestList.Payload()
 	require.NoError(t, err)
 
-	dgst = digest.FromBytes(payload)
-	require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
+	dgst = digest.FromBytes(payload)
+	require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
+
+	// Get manifest list with without avertising support for manifest lists.
+	req, err := http.NewRequest("GET", manifestTagURL, nil)
+	require.NoError(t, err)
+
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var fetchedManifest *schema2.DeserializedManifest
+	dec := json.NewDecoder(resp.Body)
+
+	err = dec.Decode(&fetchedManifest)
+	require.NoError(t, err)
+
+	require.EqualValues(t, deserializedManifest, fetchedManifest)
+}
+
+func manifest_Get_ManifestList_Platform(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	tagName := "manifestlistplatformtag"
+	repoPath := "manifestlist/platform"
+
+	platforms := []manifestlist.PlatformSpec{
+		{Architecture: "amd64", OS: "linux"},
+		{Architecture: "arm64", OS: "linux", Variant: "v8"},
+	}
+
+	manifests := make([]*schema2.DeserializedManifest, len(platforms))
+	descriptors := make([]manifestlist.ManifestDescriptor, len(platforms))
+	for i, p := range platforms {
+		manifests[i] = seedRandomSchema2Manifest(t, env, repoPath, putByDigest)
+
+		_, payload, err := manifests[i].Payload()
+		require.NoError(t, err)
+
+		descriptors[i] = manifestlist.ManifestDescriptor{
+			Descriptor: distribution.Descriptor{
+				Digest:    digest.FromBytes(payload),
+				MediaType: schema2.MediaTypeManifest,
+			},
+			Platform: p,
+		}
+	}
+
+	deserializedManifestList, err := manifestlist.FromDescriptors(descriptors)
+	require.NoError(t, err)
+
+	manifestDigestURL := buildManifestDigestURL(t, env, repoPath, deserializedManifestList)
+	manifestTagURL := buildManifestTagURL(t, env, repoPath, tagName)
+
+	resp := putManifest(t, "putting manifest list no error", manifestTagURL, manifestlist.MediaTypeManifestList, deserializedManifestList)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	tt := []struct {
+		name           string
+		url            string
+		platform       string
+		expectedStatus int
+		expectedIndex  int
+	}{
+		{
+			name:           "by tag",
+			url:            manifestTagURL,
+			platform:       "linux/arm64",
+			expectedStatus: http.StatusOK,
+			expectedIndex:  1,
+		},
+		{
+			name:           "by digest with variant",
+			url:            manifestDigestURL,
+			platform:       "linux/arm64/v8",
+			expectedStatus: http.StatusOK,
+			expectedIndex:  1,
+		},
+		{
+			name:           "by digest default platform",
+			url:            manifestDigestURL,
+			platform:       "linux/amd64",
+			expectedStatus: http.StatusOK,
+			expectedIndex:  0,
+		},
+		{
+			name:           "variant mismatch",
+			url:            manifestTagURL,
+			platform:       "linux/arm64/v7",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "unknown platform",
+			url:            manifestTagURL,
+			platform:       "windows/amd64",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "invalid platform",
+			url:            manifestTagURL,
+			platform:       "linux",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			u, err := url.Parse(test.url)
+			require.NoError(t, err)
+			u.RawQuery = url.Values{"platform": []string{test.platform}}.Encode()
+
+			req, err := http.NewRequest("GET", u.String(), nil)
+			require.NoError(t, err)
+			req.Header.Set("Accept", manifestlist.MediaTypeManifestList)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, test.expectedStatus, resp.StatusCode)
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+
+			expected := manifests[test.expectedIndex]
+			require.Equal(t, schema2.MediaTypeManifest, resp.Header.Get("Content-Type"))
+			require.Equal(t, descriptors[test.expectedIndex].Digest.String(), resp.Header.Get("Docker-Content-Digest"))
+
+			var fetchedManifest *schema2.DeserializedManifest
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&fetchedManifest))
+			require.EqualValues(t, expected, fetchedManifest)
+		})
+	}
+}
+
+func withDefaultPlatforms(platforms ...string) configOpt {
+	return func(config *configuration.Configuration) {
+		config.Compatibility.ManifestLists.DefaultPlatforms = platforms
+	}
+}
+
+func manifest_Get_ManifestList_FallbackDefaultPlatforms(t *testing.T, opts ...configOpt) {
+	repoPath := "manifestlist/defaultplatforms"
+
+	platforms := []manifestlist.PlatformSpec{
+		{Architecture: "arm64", OS: "linux"},
+		{Architecture: "arm", OS: "linux", Variant: "v6"},
+	}
+
+	tt := []struct {
+		name             string
+		defaultPlatforms []string
+		expectedStatus   int
+		expectedIndex    int
+	}{
+		{
+			name:           "no linux/amd64 manifest",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:             "default arm64 variant",
+			defaultPlatforms: []string{"linux/arm64/v8"},
+			expectedStatus:   http.StatusOK,
+			expectedIndex:    0,
+		},
+		{
+			name:             "fallback to less capable arm variant",
+			defaultPlatforms: []string{"linux/arm/v7"},
+			expectedStatus:   http.StatusOK,
+			expectedIndex:    1,
+		},
+		{
+			name:             "fallback to next platform",
+			defaultPlatforms: []string{"linux/amd64", "linux/arm"},
+			expectedStatus:   http.StatusOK,
+			expectedIndex:    1,
+		},
+		{
+			name:             "no matching platform",
+			defaultPlatforms: []string{"linux/amd64", "linux/arm/v5"},
+			expectedStatus:   http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			env := newTestEnv(t, append(opts, withDefaultPlatforms(test.defaultPlatforms...))...)
+			defer env.Shutdown()
+
+			manifests := make([]*schema2.DeserializedManifest, len(platforms))
+			descriptors := make([]manifestlist.ManifestDescriptor, len(platforms))
+			for i, p := range platforms {
+				manifests[i] = seedRandomSchema2Manifest(t, env, repoPath, putByDigest)
+
+				_, payload, err := manifests[i].Payload()
+				require.NoError(t, err)
+
+				descriptors[i] = manifestlist.ManifestDescriptor{
+					Descriptor: distribution.Descriptor{
+						Digest:    digest.FromBytes(payload),
+						MediaType: schema2.MediaTypeManifest,
+					},
+					Platform: p,
+				}
+			}
+
+			deserializedManifestList, err := manifestlist.FromDescriptors(descriptors)
+			require.NoError(t, err)
 
-	// Get manifest list with without avertising support for manifest lists.
-	req, err := http.NewRequest("GET", manifestTagURL, nil)
-	require.NoError(t, err)
+			manifestTagURL := buildManifestTagURL(t, env, repoPath, "latest")
+			resp := putManifest(t, "putting manifest list no error", manifestTagURL, manifestlist.MediaTypeManifestList, deserializedManifestList)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusCreated, resp.StatusCode)
 
-	resp, err = http.DefaultClient.Do(req)
-	require.NoError(t, err)
-	defer resp.Body.Close()
+			// get the manifest list without advertising support for manifest lists
+			req, err := http.NewRequest("GET", manifestTagURL, nil)
+			require.NoError(t, err)
+			req.Header.Set("Accept", schema2.MediaTypeManifest)
 
-	require.Equal(t, http.StatusOK, resp.StatusCode)
+			resp, err = http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
 
-	var fetchedManifest *schema2.DeserializedManifest
-	dec := json.NewDecoder(resp.Body)
+			require.Equal(t, test.expectedStatus, resp.StatusCode)
+			if test.expectedStatus != http.StatusOK {
+				checkBodyHasErrorCodes(t, "getting manifest list without a manifest for the default platforms", resp, v2.ErrorCodeManifestUnknown)
+				return
+			}
 
-	err = dec.Decode(&fetchedManifest)
-	require.NoError(t, err)
+			require.Equal(t, schema2.MediaTypeManifest, resp.Header.Get("Content-Type"))
+			require.Equal(t, descriptors[test.expectedIndex].Digest.String(), resp.Header.Get("Docker-Content-Digest"))
 
-	require.EqualValues(t, deserializedManifest, fetchedManifest)
+			var fetchedManifest *schema2.DeserializedManifest
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&fetchedManifest))
+			require.EqualValues(t, manifests[test.expectedIndex], fetchedManifest)
+		})
+	}
 }
 
 func TestManifestAPI_Get_OCIIndexFromFilesystemAfterDatabaseWrites(t *testing.T) {
@@ -3600,6 +6924,79 @@ func TestManifestAPI_Get_OCIIndexFromFilesystemAfterDatabaseWrites(t *testing.T)
 	}
 }
 
+func TestManifestAPI_Get_OCIEncryptedImage(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	tagName := "encrypted"
+	repoPath := "oci/encrypted"
+
+	repoRef, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+
+	cfgPayload, cfgDesc := ociConfig()
+	uploadURLBase, _ := startPushLayer(t, env, repoRef)
+	pushLayer(t, env.builder, repoRef, cfgDesc.Digest, uploadURLBase, bytes.NewReader(cfgPayload))
+
+	rs, dgst := createRandomSmallLayer()
+	uploadURLBase, _ = startPushLayer(t, env, repoRef)
+	pushLayer(t, env.builder, repoRef, dgst, uploadURLBase, rs)
+
+	m, err := ocischema.FromStruct(ocischema.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 2,
+			MediaType:     v1.MediaTypeImageManifest,
+		},
+		Config: cfgDesc,
+		Layers: []distribution.Descriptor{
+			{
+				Digest:    dgst,
+				MediaType: ocischema.MediaTypeImageLayerGzipEncrypted,
+				Annotations: map[string]string{
+					"org.opencontainers.image.enc.keys.jwe": "eyJwcm90ZWN0ZWQiOiJleUpoYkdjaU9pSlNVMEV0VDBGRlVDSjkifQ==",
+					"org.opencontainers.image.enc.pubopts":  "eyJjaXBoZXIiOiJBRVNfMjU2X0NUUl9ITUFDX1NIQTI1NiJ9",
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	tagURL := buildManifestTagURL(t, env, repoPath, tagName)
+	resp := putManifest(t, "putting encrypted manifest", tagURL, v1.MediaTypeImageManifest, m)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	_, payload, err := m.Payload()
+	require.NoError(t, err)
+
+	// The manifest, including the layer annotations, is returned as pushed to clients supporting OCI manifests.
+	req, err := http.NewRequest("GET", tagURL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", v1.MediaTypeImageManifest)
+
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, digest.FromBytes(payload).String(), resp.Header.Get("Docker-Content-Digest"))
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, payload, body)
+
+	// Other clients would need a conversion to schema 1, which is refused.
+	req, err = http.NewRequest("GET", tagURL, nil)
+	require.NoError(t, err)
+
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	errs, _, _ := checkBodyHasErrorCodes(t, "getting encrypted manifest without OCI support", resp, v2.ErrorCodeManifestUnknown)
+	require.Contains(t, errs[0].(errcode.Error).Message, "encrypted images cannot be converted to schema 1")
+}
+
 func buildManifestTagURL(t *testing.T, env *testEnv, repoPath, tagName string) string {
 	t.Helper()
 
@@ -3654,6 +7051,8 @@ type manifestOpts struct {
 	manifestURL           string
 	putManifest           bool
 	writeToFilesystemOnly bool
+	// configPayload replaces the default image configuration, if set.
+	configPayload []byte
 
 	// Non-optional values which be passed through by the testing func for ease of use.
 	repoPath string
@@ -3678,6 +7077,12 @@ func writeToFilesystemOnly(t *testing.T, env *testEnv, opts *manifestOpts) {
 	opts.writeToFilesystemOnly = true
 }
 
+func withConfigPayload(payload []byte) manifestOptsFunc {
+	return func(t *testing.T, env *testEnv, opts *manifestOpts) {
+		opts.configPayload = payload
+	}
+}
+
 func schema2Config() ([]byte, distribution.Descriptor) {
 	payload := []byte(`{
 		"architecture": "amd64",
@@ -3736,6 +7141,11 @@ func seedRandomSchema2Manifest(t *testing.T, env *testEnv, repoPath string, opts
 
 	// Create a manifest config and push up its content.
 	cfgPayload, cfgDesc := schema2Config()
+	if config.configPayload != nil {
+		cfgPayload = config.configPayload
+		cfgDesc.Size = int64(len(cfgPayload))
+		cfgDesc.Digest = digest.FromBytes(cfgPayload)
+	}
 	uploadURLBase, _ := startPushLayer(t, env, repoRef)
 	pushLayer(t, env.builder, repoRef, cfgDesc.Digest, uploadURLBase, bytes.NewReader(cfgPayload))
 	manifest.Config = cfgDesc
@@ -4358,16 +7768,14 @@ func tags_Get(t *testing.T, opts ...configOpt) {
 	createRepositoryWithMultipleIdenticalTags(t, env, imageName.Name(), shuffledTags)
 
 	tt := []struct {
-		name                string
-		runWithoutDBEnabled bool
-		queryParams         url.Values
-		expectedBody        tagsAPIResponse
-		expectedLinkHeader  string
+		name               string
+		queryParams        url.Values
+		expectedBody       tagsAPIResponse
+		expectedLinkHeader string
 	}{
 		{
-			name:                "no query parameters",
-			expectedBody:        tagsAPIResponse{Name: imageName.Name(), Tags: sortedTags},
-			runWithoutDBEnabled: true,
+			name:         "no query parameters",
+			expectedBody: tagsAPIResponse{Name: imageName.Name(), Tags: sortedTags},
 		},
 		{
 			name:         "empty last query parameter",
@@ -4454,10 +7862,6 @@ func tags_Get(t *testing.T, opts ...configOpt) {
 
 	for _, test := range tt {
 		t.Run(test.name, func(t *testing.T) {
-			if !test.runWithoutDBEnabled && !env.config.Database.Enabled {
-				t.Skip("skipping test because the metadata database is not enabled")
-			}
-
 			tagsURL, err := env.builder.BuildTagsURL(imageName, test.queryParams)
 			require.NoError(t, err)
 
@@ -4479,7 +7883,7 @@ func tags_Get(t *testing.T, opts ...configOpt) {
 
 	// If the database is enabled, disable it and rerun the tests again with the
 	// database to check that the filesystem mirroring worked correctly.
-	// All results should be the full list as the filesytem does not support pagination.
+	// Results should be the same, as the filesystem tags are paginated in the same way.
 	if env.config.Database.Enabled && !env.config.Migration.DisableMirrorFS {
 		env.config.Database.Enabled = false
 		defer func() { env.config.Database.Enabled = true }()
@@ -4500,7 +7904,8 @@ func tags_Get(t *testing.T, opts ...configOpt) {
 				err = dec.Decode(&body)
 				require.NoError(t, err)
 
-				require.Equal(t, tagsAPIResponse{Name: imageName.Name(), Tags: sortedTags}, body)
+				require.Equal(t, test.expectedBody, body)
+				require.Equal(t, test.expectedLinkHeader, resp.Header.Get("Link"))
 			})
 		}
 	}
@@ -4690,6 +8095,96 @@ func tags_Delete_UnknownRepository(t *testing.T, opts ...configOpt) {
 	checkBodyHasErrorCodes(t, "repository not found", resp, v2.ErrorCodeNameUnknown)
 }
 
+func httpDeleteTagsBatch(t *testing.T, env *testEnv, repoPath string, tags []string) *http.Response {
+	t.Helper()
+
+	imageName, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+
+	u, err := env.builder.BuildTagsBatchURL(imageName)
+	require.NoError(t, err)
+
+	body, err := json.Marshal(map[string][]string{"tags": tags})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest("DELETE", u, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	return resp
+}
+
+func tags_DeleteBatch(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	repoPath := "foo/bar"
+	createRepositoryWithMultipleIdenticalTags(t, env, repoPath, []string{"a", "b", "c"})
+
+	resp := httpDeleteTagsBatch(t, env, repoPath, []string{"a", "c", "d", "a"})
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var body struct {
+		Name    string `json:"name"`
+		Results []struct {
+			Tag    string `json:"tag"`
+			Status string `json:"status"`
+		} `json:"results"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, repoPath, body.Name)
+	require.Len(t, body.Results, 3)
+	require.Equal(t, "a", body.Results[0].Tag)
+	require.Equal(t, "deleted", body.Results[0].Status)
+	require.Equal(t, "c", body.Results[1].Tag)
+	require.Equal(t, "deleted", body.Results[1].Status)
+	require.Equal(t, "d", body.Results[2].Tag)
+	require.Equal(t, "not_found", body.Results[2].Status)
+
+	// only tag b should remain
+	imageName, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+	tagsURL, err := env.builder.BuildTagsURL(imageName)
+	require.NoError(t, err)
+
+	resp, err = http.Get(tagsURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var list tagsAPIResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	require.Equal(t, []string{"b"}, list.Tags)
+}
+
+func tags_DeleteBatch_InvalidTag(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	repoPath := "foo/bar"
+	createRepository(t, env, repoPath, "latest")
+
+	resp := httpDeleteTagsBatch(t, env, repoPath, []string{"latest", "-invalid"})
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "invalid tag", resp, v2.ErrorCodeTagInvalid)
+}
+
+func tags_DeleteBatch_UnknownRepository(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	resp := httpDeleteTagsBatch(t, env, "foo/bar", []string{"latest"})
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "repository not found", resp, v2.ErrorCodeNameUnknown)
+}
+
 func tags_Delete_ReadOnly(t *testing.T, opts ...configOpt) {
 	opts = append(opts, withSharedInMemoryDriver(t.Name()))
 	setupEnv := newTestEnv(t, opts...)
@@ -5252,6 +8747,146 @@ func createRepositoryWithMultipleIdenticalTags(t *testing.T, env *testEnv, repoP
 	}
 }
 
+func withRepositoryQuotas(maxManifests, maxTags int) configOpt {
+	return func(config *configuration.Configuration) {
+		config.Policy.Repository.MaxManifests = maxManifests
+		config.Policy.Repository.MaxTags = maxTags
+	}
+}
+
+func TestManifestAPI_Put_ManifestsQuotaExceeded(t *testing.T) {
+	env := newTestEnv(t, withRepositoryQuotas(2, 0))
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	repoPath := "foo/bar"
+	m1 := seedRandomSchema2Manifest(t, env, repoPath, putByDigest)
+	seedRandomSchema2Manifest(t, env, repoPath, putByDigest)
+
+	// pushing an existing manifest does not count against the quota
+	resp := putManifest(t, "putting existing manifest", buildManifestDigestURL(t, env, repoPath, m1), schema2.MediaTypeManifest, m1.Manifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	m3 := seedRandomSchema2Manifest(t, env, repoPath)
+	resp = putManifest(t, "putting manifest over quota", buildManifestDigestURL(t, env, repoPath, m3), schema2.MediaTypeManifest, m3.Manifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "putting manifest over quota", resp, v2.ErrorCodeQuotaExceeded)
+}
+
+func TestManifestAPI_Put_TagsQuotaExceeded(t *testing.T) {
+	env := newTestEnv(t, withRepositoryQuotas(0, 2))
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	repoPath := "foo/bar"
+	m := seedRandomSchema2Manifest(t, env, repoPath, putByDigest)
+
+	for _, tag := range []string{"a", "b"} {
+		resp := putManifest(t, "putting tag", buildManifestTagURL(t, env, repoPath, tag), schema2.MediaTypeManifest, m.Manifest)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	// overwriting an existing tag does not count against the quota
+	resp := putManifest(t, "overwriting tag", buildManifestTagURL(t, env, repoPath, "a"), schema2.MediaTypeManifest, m.Manifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp = putManifest(t, "putting tag over quota", buildManifestTagURL(t, env, repoPath, "c"), schema2.MediaTypeManifest, m.Manifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "putting tag over quota", resp, v2.ErrorCodeQuotaExceeded)
+}
+
+func withUploadLimits(maxConcurrent, maxConcurrentPerRepository int) configOpt {
+	return func(config *configuration.Configuration) {
+		config.Policy.Uploads.MaxConcurrent = maxConcurrent
+		config.Policy.Uploads.MaxConcurrentPerRepository = maxConcurrentPerRepository
+	}
+}
+
+func startBlobUpload(t *testing.T, env *testEnv, repoPath string) *http.Response {
+	t.Helper()
+
+	name, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+	u, err := env.builder.BuildBlobUploadURL(name)
+	require.NoError(t, err)
+
+	resp, err := http.Post(u, "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	return resp
+}
+
+func TestBlobAPI_StartUpload_RepositoryUploadsLimitExceeded(t *testing.T) {
+	env := newTestEnv(t, withUploadLimits(0, 2))
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	var location string
+	for i := 0; i < 2; i++ {
+		resp := startBlobUpload(t, env, "foo/bar")
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
+		location = resp.Header.Get("Location")
+	}
+
+	resp := startBlobUpload(t, env, "foo/bar")
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	// uploads of other repositories are not affected
+	resp = startBlobUpload(t, env, "foo/baz")
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	// canceling an upload frees a slot
+	req, err := http.NewRequest(http.MethodDelete, location, nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = startBlobUpload(t, env, "foo/bar")
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+}
+
+func TestBlobAPI_StartUpload_UploadsLimitExceeded(t *testing.T) {
+	env := newTestEnv(t, withUploadLimits(2, 0))
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	for _, repoPath := range []string{"foo/bar", "foo/baz"} {
+		resp := startBlobUpload(t, env, repoPath)
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	}
+
+	name, err := reference.WithName("foo/qux")
+	require.NoError(t, err)
+	u, err := env.builder.BuildBlobUploadURL(name)
+	require.NoError(t, err)
+
+	resp, err := http.Post(u, "", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "starting upload over limit", resp, v2.ErrorCodeTooManyUploads)
+}
+
 // Test mutation operations on a registry configured as a cache.  Ensure that they return
 // appropriate errors.
 func TestRegistryAsCacheMutationAPIs(t *testing.T) {
@@ -5363,3 +8998,69 @@ func TestProxyManifestGetByTag(t *testing.T) {
 		"Docker-Content-Digest": []string{newDigest.String()},
 	})
 }
+
+func putManifestIfMatch(t *testing.T, url string, m *schema2.DeserializedManifest, ifMatch string) *http.Response {
+	t.Helper()
+
+	_, payload, err := m.Payload()
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(payload))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", schema2.MediaTypeManifest)
+	req.Header.Set("If-Match", ifMatch)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestManifestAPI_Put_TagIfMatch(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	repoPath := "foo/bar"
+	tagURL := buildManifestTagURL(t, env, repoPath, "latest")
+
+	m1 := seedRandomSchema2Manifest(t, env, repoPath, putByTag("latest"))
+	_, p1, err := m1.Payload()
+	require.NoError(t, err)
+	d1 := digest.FromBytes(p1)
+
+	m2 := seedRandomSchema2Manifest(t, env, repoPath, putByDigest)
+	_, p2, err := m2.Payload()
+	require.NoError(t, err)
+	d2 := digest.FromBytes(p2)
+
+	// the tag does not point to the expected manifest
+	resp := putManifestIfMatch(t, tagURL, m2, d2.String())
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	errs, _, _ := checkBodyHasErrorCodes(t, "tag precondition failed", resp, v2.ErrorCodePreconditionFailed)
+	require.Equal(t, map[string]interface{}{"tag": "latest", "digest": d1.String()}, errs[0].(errcode.Error).Detail)
+
+	// the tag points to the expected manifest, quoted like an ETag
+	resp = putManifestIfMatch(t, tagURL, m2, fmt.Sprintf("%q", d1))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// a concurrent push with the same precondition is rejected, as the tag was already moved
+	resp = putManifestIfMatch(t, tagURL, m1, d1.String())
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "tag precondition failed", resp, v2.ErrorCodePreconditionFailed)
+
+	// the tag does not exist
+	resp = putManifestIfMatch(t, buildManifestTagURL(t, env, repoPath, "new"), m1, d1.String())
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "tag precondition failed", resp, v2.ErrorCodePreconditionFailed)
+
+	// the tag still points to the manifest it was moved to
+	resp, err = http.Head(tagURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, d2.String(), resp.Header.Get("Docker-Content-Digest"))
+}
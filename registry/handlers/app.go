@@ -13,8 +13,10 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/benbjohnson/clock"
@@ -23,31 +25,43 @@ import (
 	dcontext "github.com/docker/distribution/context"
 	"github.com/docker/distribution/health"
 	"github.com/docker/distribution/health/checks"
+	"github.com/docker/distribution/manifest/manifestlist"
 	prometheus "github.com/docker/distribution/metrics"
 	"github.com/docker/distribution/notifications"
 	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
 	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
 	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/datamover"
 	"github.com/docker/distribution/registry/datastore"
 	"github.com/docker/distribution/registry/datastore/migrations"
+	"github.com/docker/distribution/registry/datastore/models"
 	"github.com/docker/distribution/registry/gc"
 	"github.com/docker/distribution/registry/gc/worker"
 	"github.com/docker/distribution/registry/internal"
+	"github.com/docker/distribution/registry/internal/tracing"
 	registrymiddleware "github.com/docker/distribution/registry/middleware/registry"
 	repositorymiddleware "github.com/docker/distribution/registry/middleware/repository"
 	"github.com/docker/distribution/registry/proxy"
+	"github.com/docker/distribution/registry/replication"
+	"github.com/docker/distribution/registry/retention"
+	"github.com/docker/distribution/registry/scrubber"
 	"github.com/docker/distribution/registry/storage"
 	memorycache "github.com/docker/distribution/registry/storage/cache/memory"
 	rediscache "github.com/docker/distribution/registry/storage/cache/redis"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/base"
 	"github.com/docker/distribution/registry/storage/driver/factory"
+	"github.com/docker/distribution/registry/storage/driver/fallback"
 	storagemiddleware "github.com/docker/distribution/registry/storage/driver/middleware"
 	"github.com/docker/distribution/registry/storage/validation"
 	"github.com/docker/distribution/version"
 	"github.com/getsentry/sentry-go"
 	"github.com/go-redis/redis/v8"
 	"github.com/gorilla/mux"
+	"github.com/opencontainers/go-digest"
 	promclient "github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"gitlab.com/gitlab-org/labkit/errortracking"
@@ -62,6 +76,12 @@ const randomSecretSize = 32
 // defaultCheckInterval is the default time in between health checks
 const defaultCheckInterval = 10 * time.Second
 
+// defaultDBCheckTimeout is the default timeout for the database health check
+const defaultDBCheckTimeout = 2 * time.Second
+
+// defaultRedisCheckTimeout is the default timeout for the Redis health check
+const defaultRedisCheckTimeout = 2 * time.Second
+
 // App is a global registry application object. Shared resources can be placed
 // on this object that will be accessible from all requests. Any writable
 // fields should be protected.
@@ -71,22 +91,44 @@ type App struct {
 	Config *configuration.Configuration
 
 	router            *mux.Router                 // main application router, configured with dispatchers
+	gitlabRouter      *mux.Router                 // GitLab v1 API router, configured with dispatchers
+	corsHandler       http.Handler                // corsHandler wraps the routers with CORS support. Nil if CORS is disabled.
+	clientFilter      *clientFilter               // clientFilter rejects requests from denied clients. Nil if disabled.
+	requestLimits     *requestLimits              // requestLimits limits request bodies and aborts slow uploads. Nil if disabled.
+	compression       *responseCompression        // compression gzip compresses eligible responses. Nil if disabled.
 	driver            storagedriver.StorageDriver // driver maintains the app global storage driver instance.
 	db                *datastore.DB               // db is the global database handle used across the app.
+	manifestCache     *datastore.ManifestCache    // manifestCache caches manifests read from the database by digest. Optional.
 	registry          distribution.Namespace      // registry is the primary registry backend for the app instance.
 	migrationRegistry distribution.Namespace      // migrationRegistry is the secondary registry backend for migration
 	migrationDriver   storagedriver.StorageDriver // migrationDriver is the secondary storage driver for migration
 
+	// manifestBackfiller imports manifests read from the filesystem into the database during migration, if enabled.
+	manifestBackfiller *manifestBackfiller
+
+	// dataMoverRegistry is the registry backend for repositories relocated by the data mover. Nil if disabled.
+	dataMoverRegistry distribution.Namespace
+	// dataMoverDriver is the storage driver backing dataMoverRegistry. Nil if disabled.
+	dataMoverDriver storagedriver.StorageDriver
+
 	repoRemover      distribution.RepositoryRemover // repoRemover provides ability to delete repos
 	accessController auth.AccessController          // main access controller for application
 
+	// accessControllerMu guards accessController, which can be replaced on reload.
+	accessControllerMu sync.RWMutex
+
 	// httpHost is a parsed representation of the http.host parameter from
 	// the configuration. Only the Scheme and Host fields are used.
 	httpHost url.URL
 
+	// linkBuilder builds the relative URLs of pagination Link headers,
+	// including the http.prefix configured path, if any.
+	linkBuilder *v2.URLBuilder
+
 	// events contains notification related configuration.
 	events struct {
-		sink   notifications.Sink
+		// sink dispatches events to the configured endpoints, which can be replaced on reload
+		sink   *notifications.SwappableSink
 		source notifications.SourceRecord
 	}
 
@@ -98,7 +140,66 @@ type App struct {
 	// readOnly is true if the registry is in a read-only maintenance mode
 	readOnly bool
 
-	manifestURLs validation.ManifestURLs
+	// isMirror is true if this registry is a read-only mirror of another registry, serving pull requests from a read
+	// replica of its metadata database. Implies readOnly.
+	isMirror bool
+
+	manifestURLs       validation.ManifestURLs
+	manifestMediaTypes validation.ManifestMediaTypes
+	manifestFields     validation.ManifestFields
+	repositoryNames    validation.RepositoryNames
+
+	// verifyPlatforms is true if the platforms declared in pushed manifest lists must match the image configuration of
+	// the referenced manifests.
+	verifyPlatforms bool
+
+	// defaultPlatforms are the platforms, in order of preference, used to select the manifest returned in place of a
+	// manifest list to clients that do not support manifest lists.
+	defaultPlatforms []manifestlist.PlatformSpec
+
+	// manifestPayloadSizeLimit is the maximum size in bytes of pushed manifest payloads.
+	manifestPayloadSizeLimit int64
+
+	// redirectVerification is the mode in which the content of blobs is verified against their digest before clients
+	// are redirected to the storage backend to download them. Empty if verification is disabled.
+	redirectVerification string
+	// redirectVerificationInterval is the time after which blobs are verified again in the periodic verification mode.
+	redirectVerificationInterval time.Duration
+
+	// strictChunkOrdering makes blob upload chunks sent at an offset other than the current upload offset be rejected
+	// with a 416 Requested Range Not Satisfiable response, leaving the upload intact so that clients can resume it.
+	strictChunkOrdering bool
+
+	// parallelChunks makes blob upload chunks sent ahead of the current upload offset be written as parts of the
+	// upload, which are assembled once the upload is completed.
+	parallelChunks bool
+
+	// minChunkLength is the minimum length in bytes of upload chunks advertised to clients. Not advertised if zero.
+	minChunkLength int64
+
+	// auditLogger records write operations to the audit log. Nil if the audit log is disabled.
+	auditLogger *audit.Logger
+
+	// uploadStates persists the state of blob upload sessions. Nil if upload state persistence is disabled.
+	uploadStates uploadStateStore
+
+	// tagLocks serializes concurrent writes to the same tag across registry instances. Nil if tag locks are disabled.
+	tagLocks tagLocker
+
+	// sharedManifestCache caches manifests read from the database across registry instances. Nil if disabled.
+	sharedManifestCache sharedManifestCache
+
+	// asyncValidator validates manifests pushed by trusted subjects in the background. Nil if disabled.
+	asyncValidator *asyncManifestValidator
+
+	// replicationEnabled is true if pushed content should be queued for replication to a secondary registry.
+	replicationEnabled bool
+
+	// retentionEnabled is true if repository retention policies are enforced, and can therefore be managed.
+	retentionEnabled bool
+
+	// statisticsRecalculations bounds the number of repository statistics recalculations running at the same time.
+	statisticsRecalculations chan struct{}
 }
 
 // NewApp takes a configuration and returns a configured app, ready to serve
@@ -106,23 +207,45 @@ type App struct {
 // handlers accordingly.
 func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 	app := &App{
-		Config:  config,
-		Context: ctx,
-		router:  v2.RouterWithPrefix(config.HTTP.Prefix),
-		isCache: config.Proxy.RemoteURL != "",
+		Config:       config,
+		Context:      ctx,
+		router:       v2.RouterWithPrefix(config.HTTP.Prefix),
+		gitlabRouter: v1.RouterWithPrefix(config.HTTP.Prefix),
+		linkBuilder:  v2.NewURLBuilderWithPrefix(&url.URL{}, config.HTTP.Prefix, true),
+		isCache:      config.Proxy.RemoteURL != "",
+
+		statisticsRecalculations: make(chan struct{}, maxConcurrentStatisticsRecalculations),
 	}
 
 	// Register the handler dispatchers.
 	app.register(v2.RouteNameBase, func(ctx *Context, r *http.Request) http.Handler {
-		return http.HandlerFunc(apiBase)
+		return http.HandlerFunc(app.apiBase)
 	})
 	app.register(v2.RouteNameManifest, manifestDispatcher)
 	app.register(v2.RouteNameCatalog, catalogDispatcher)
 	app.register(v2.RouteNameTags, tagsDispatcher)
 	app.register(v2.RouteNameTag, tagDispatcher)
+	app.register(v2.RouteNameTagsBatch, tagsBatchDispatcher)
 	app.register(v2.RouteNameBlob, blobDispatcher)
 	app.register(v2.RouteNameBlobUpload, blobUploadDispatcher)
 	app.register(v2.RouteNameBlobUploadChunk, blobUploadDispatcher)
+	app.register(v1.RouteNameRepositoryEvents, repositoryEventsDispatcher)
+	app.register(v1.RouteNameManifestTags, manifestTagsDispatcher)
+	app.register(v1.RouteNameRepositoryArchive, repositoryArchiveDispatcher)
+	app.register(v1.RouteNameRepositoryRename, repositoryRenameDispatcher)
+	app.register(v1.RouteNameRepositoryStorageMove, repositoryStorageMoveDispatcher)
+	app.register(v1.RouteNameRepositoryCopy, repositoryCopyDispatcher)
+	app.register(v1.RouteNameGroupRepositories, groupRepositoriesDispatcher)
+	app.register(v1.RouteNameRepositoryUploads, repositoryUploadsDispatcher)
+	app.register(v1.RouteNameRepositoryTags, repositoryTagsDispatcher)
+	app.register(v1.RouteNameRepositoryTag, repositoryTagDispatcher)
+	app.register(v1.RouteNameRetentionPolicy, repositoryRetentionPolicyDispatcher)
+	app.register(v1.RouteNameRepositoryCreate, repositoryCreateDispatcher)
+	app.register(v1.RouteNameRepositoryManifests, repositoryManifestsDispatcher)
+	app.register(v1.RouteNameRepositoryBlobsExists, repositoryBlobsExistsDispatcher)
+	app.register(v1.RouteNameNamespaceDeduplication, namespaceDeduplicationDispatcher)
+	app.register(v1.RouteNameRepositoryStatistics, repositoryStatisticsDispatcher)
+	app.register(v1.RouteNameRepositoryStatisticsRecalculate, repositoryStatisticsRecalculateDispatcher)
 
 	storageParams := config.Storage.Parameters()
 	if storageParams == nil {
@@ -166,12 +289,19 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 
 	log := dcontext.GetLogger(app)
 
-	startUploadPurger(app, app.driver, log, purgeConfig)
-
-	// Also start an upload purger for the new root directory if we're migrating
-	// to a different root directory.
-	if app.Config.Migration.Enabled && distinctMigrationRootDirectory(config) {
-		startUploadPurger(app, app.migrationDriver, log, purgeConfig)
+	if config.Database.Mirror.Enabled {
+		if !config.Database.Enabled {
+			panic("database mirror: requires the metadata database to be enabled")
+		}
+		if config.Migration.Enabled {
+			panic("database mirror: not supported while migrating to the metadata database")
+		}
+		if config.Proxy.RemoteURL != "" {
+			panic("database mirror: not supported when the registry is configured as a pull-through cache")
+		}
+		app.isMirror = true
+		app.readOnly = true
+		log.Info("registry configured as a read-only database mirror")
 	}
 
 	app.driver, err = applyStorageMiddleware(app.driver, config.Middleware["storage"])
@@ -182,6 +312,13 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 	app.configureSecret(config)
 	app.configureEvents(config)
 	app.configureRedis(config)
+	app.configureUploadStates(config)
+	app.configureTagLocks(config)
+	app.configureSharedManifestCache(config)
+	app.configureCORS(config)
+	app.configureClientFilter(config)
+	app.configureRequestLimits(config)
+	app.configureCompression(config)
 
 	options := registrymiddleware.GetRegistryOptions()
 
@@ -194,6 +331,11 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 		if err != nil {
 			panic(fmt.Sprintf(`could not parse http "host" parameter: %v`, err))
 		}
+		// generated URLs must include the prefix, so append it to the host
+		// path unless it already ends with it
+		if prefix := strings.Trim(config.HTTP.Prefix, "/"); prefix != "" && !strings.HasSuffix(strings.TrimSuffix(u.Path, "/"), "/"+prefix) {
+			u.Path = path.Join("/", u.Path, prefix) + "/"
+		}
 		app.httpHost = *u
 	}
 
@@ -238,6 +380,13 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 		} else {
 			options = append(options, storage.EnableRedirect)
 		}
+		app.configureRedirectVerification(config.Storage["redirect"])
+	}
+
+	// the manifest payload size is limited regardless of validation being enabled, to bound memory usage
+	app.manifestPayloadSizeLimit = defaultManifestPayloadSizeLimit
+	if l := config.Validation.Manifests.PayloadSizeLimit; l > 0 {
+		app.manifestPayloadSizeLimit = l
 	}
 
 	if !config.Validation.Enabled {
@@ -276,6 +425,36 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 				options = append(options, storage.ManifestURLsDenyRegexp(app.manifestURLs.Deny))
 			}
 		}
+
+		app.manifestMediaTypes.Allow = config.Validation.Manifests.AllowedMediaTypes
+		app.manifestFields.Strict = config.Validation.Manifests.Strict
+		app.verifyPlatforms = config.Validation.Manifests.VerifyPlatforms
+		app.strictChunkOrdering = config.Validation.Uploads.StrictChunkOrdering
+		app.parallelChunks = config.Validation.Uploads.ParallelChunks
+		app.minChunkLength = config.Validation.Uploads.MinChunkLength
+
+		app.repositoryNames.MaxPathComponents = config.Validation.Repositories.MaxPathComponents
+		app.repositoryNames.MaxLength = config.Validation.Repositories.MaxLength
+		if s := config.Validation.Repositories.AllowedPattern; s != "" {
+			// Validate via compilation.
+			if _, err := regexp.Compile(s); err != nil {
+				panic(fmt.Sprintf("validation.repositories.allowedpattern: %s", err))
+			}
+			// Anchor and wrap with non-capturing group, names must fully match.
+			app.repositoryNames.Allow = regexp.MustCompile(fmt.Sprintf("^(?:%s)$", s))
+		}
+	}
+
+	app.defaultPlatforms = []manifestlist.PlatformSpec{{OS: defaultOS, Architecture: defaultArch}}
+	if pp := config.Compatibility.ManifestLists.DefaultPlatforms; len(pp) > 0 {
+		app.defaultPlatforms = make([]manifestlist.PlatformSpec, 0, len(pp))
+		for _, s := range pp {
+			p, err := parsePlatform(s)
+			if err != nil {
+				panic(fmt.Sprintf("compatibility.manifestlists.defaultplatforms: %s", err))
+			}
+			app.defaultPlatforms = append(app.defaultPlatforms, p)
+		}
 	}
 
 	// Connect to the metadata database, if enabled.
@@ -293,6 +472,7 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 			SSLKey:         config.Database.SSLKey,
 			SSLRootCert:    config.Database.SSLRootCert,
 			ConnectTimeout: config.Database.ConnectTimeout,
+			ReadOnly:       app.isMirror,
 		},
 			datastore.WithLogger(log.WithFields(logrus.Fields{"database": config.Database.DBName})),
 			datastore.WithLogLevel(config.Log.Level),
@@ -302,6 +482,12 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 				MaxOpen:     config.Database.Pool.MaxOpen,
 				MaxLifetime: config.Database.Pool.MaxLifetime,
 			}),
+			datastore.WithRetryConfig(&datastore.RetryConfig{
+				MaxRetries:      config.Database.Retry.MaxRetries,
+				InitialInterval: config.Database.Retry.InitialInterval,
+				MaxInterval:     config.Database.Retry.MaxInterval,
+			}),
+			datastore.WithRepositoryCache(repositoryCache(config)),
 		)
 		if err != nil {
 			panic(fmt.Sprintf("failed to construct database connection: %v", err))
@@ -322,6 +508,10 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 		app.db = db
 		options = append(options, storage.Database(app.db))
 
+		if config.Database.ManifestCache.Enabled {
+			app.manifestCache = datastore.NewManifestCache(config.Database.ManifestCache.MaxSize, config.Database.ManifestCache.TTL)
+		}
+
 		if config.HTTP.Debug.Prometheus.Enabled {
 			// Expose database metrics to prometheus.
 			collector := sqlmetrics.NewDBStatsCollector(config.Database.DBName, db)
@@ -332,27 +522,61 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 			options = append(options, storage.DisableMirrorFS)
 		}
 
-		// update online GC settings (if needed) in the background to avoid delaying the app start
-		go func() {
-			if err := updateOnlineGCSettings(app.Context, app.db, config); err != nil {
-				errortracking.Capture(err, errortracking.WithContext(app.Context))
-				log.WithError(err).Error("failed to update online GC settings")
+		// online GC is the responsibility of the upstream registry when mirroring, as it writes to the database
+		if !app.isMirror {
+			// update online GC settings (if needed) in the background to avoid delaying the app start
+			go func() {
+				if err := updateOnlineGCSettings(app.Context, app.db, config); err != nil {
+					errortracking.Capture(err, errortracking.WithContext(app.Context))
+					log.WithError(err).Error("failed to update online GC settings")
+				}
+			}()
+
+			// If we're migrating, then we'll use use the migration driver since that
+			// will contain the storage managed by the database, if not we need to use
+			// the main storage driver.
+			var gcDriver storagedriver.StorageDriver
+			if app.Config.Migration.Enabled {
+				gcDriver = app.migrationDriver
+			} else {
+				gcDriver = app.driver
 			}
-		}()
 
-		// If we're migrating, then we'll use use the migration driver since that
-		// will contain the storage managed by the database, if not we need to use
-		// the main storage driver.
-		var gcDriver storagedriver.StorageDriver
-		if app.Config.Migration.Enabled {
-			gcDriver = app.migrationDriver
-		} else {
-			gcDriver = app.driver
+			gcListener := app.gcEventListener(config)
+			if app.manifestCache != nil || app.sharedManifestCache != nil {
+				gcListener = &cacheInvalidatingGCListener{app: app, next: gcListener}
+			}
+			startOnlineGC(app.Context, app.db, gcDriver, config, gcListener)
+		}
+	}
+
+	// Upload sessions are tracked in the metadata database, if enabled, so that stale ones can be purged without
+	// walking the storage backend. This does not apply while migrating, as repositories which were not migrated yet
+	// still have their uploads tracked in the filesystem alone.
+	var purgeDB *datastore.DB
+	if !config.Migration.Enabled {
+		purgeDB = app.db
+	}
+	// mirrors do not accept uploads, and purging those of the upstream registry is its own responsibility
+	if !app.isMirror {
+		uploadMaxAge := startUploadPurger(app, app.driver, purgeDB, log, purgeConfig)
+		if uploadMaxAge > 0 {
+			options = append(options, storage.UploadMaxAge(uploadMaxAge))
 		}
+	}
 
-		startOnlineGC(app.Context, app.db, gcDriver, config)
+	// Also start an upload purger for the new root directory if we're migrating
+	// to a different root directory.
+	if app.Config.Migration.Enabled && distinctMigrationRootDirectory(config) {
+		startUploadPurger(app, app.migrationDriver, nil, log, purgeConfig)
 	}
 
+	// the audit log may be persisted to the metadata database, so it must be configured after connecting to it
+	app.configureAudit(config)
+
+	// the asynchronous validator relies on the metadata database (if enabled) and event sink
+	app.configureAsyncValidation(config)
+
 	// configure storage caches
 	// It's possible that the metadata database will fill the same original need
 	// as the blob descriptor cache (avoiding slow and/or expensive calls to
@@ -372,7 +596,11 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 			if app.redis == nil {
 				panic("redis configuration required to use for layerinfo cache")
 			}
-			cacheProvider := rediscache.NewRedisBlobDescriptorCacheProvider(app.redis)
+			var cacheOpts []rediscache.ProviderOption
+			if !config.Redis.CircuitBreaker.Disabled {
+				cacheOpts = append(cacheOpts, rediscache.WithCircuitBreaker(config.Redis.CircuitBreaker.Threshold, config.Redis.CircuitBreaker.Cooldown))
+			}
+			cacheProvider := rediscache.NewRedisBlobDescriptorCacheProvider(app.redis, cacheOpts...)
 			localOptions := append(options, storage.BlobDescriptorCacheProvider(cacheProvider))
 			app.registry, err = storage.NewRegistry(app, app.driver, localOptions...)
 			if err != nil {
@@ -387,6 +615,25 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 				panic("could not create registry: " + err.Error())
 			}
 			log.Info("using inmemory blob descriptor cache")
+		case "inmemory-lru":
+			maxEntries, err := base.GetLimitFromParameter(cc["maxentries"], 1, memorycache.DefaultLRUMaxEntries)
+			if err != nil {
+				panic(fmt.Sprintf("invalid storage.cache.maxentries: %v", err))
+			}
+			maxSize, err := base.GetLimitFromParameter(cc["maxsize"], 0, 0)
+			if err != nil {
+				panic(fmt.Sprintf("invalid storage.cache.maxsize: %v", err))
+			}
+			cacheProvider := memorycache.NewLRUBlobDescriptorCacheProvider(int(maxEntries), int64(maxSize))
+			localOptions := append(options, storage.BlobDescriptorCacheProvider(cacheProvider))
+			app.registry, err = storage.NewRegistry(app, app.driver, localOptions...)
+			if err != nil {
+				panic("could not create registry: " + err.Error())
+			}
+			log.WithFields(logrus.Fields{
+				"max_entries": maxEntries,
+				"max_size":    maxSize,
+			}).Info("using inmemory LRU blob descriptor cache")
 		default:
 			if v != "" {
 				log.WithField("type", config.Storage["cache"]).Warn("unknown cache type, caching disabled")
@@ -413,6 +660,9 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 		app.migrationRegistry = migrationRegistry(app.Context, app.migrationDriver, config, options...)
 	}
 
+	app.configureDataMover(config, options...)
+	app.configureBackfill(config)
+
 	authType := config.Auth.Type()
 
 	if authType != "" && !strings.EqualFold(authType, "none") {
@@ -433,6 +683,12 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 		app.isCache = true
 		log.WithField("remote", config.Proxy.RemoteURL).Info("registry configured as a proxy cache")
 	}
+
+	// the replicator reads blobs from the registry backed by the metadata database, so it must be configured last
+	app.configureReplication(config)
+	app.configureRetention(config)
+	app.configureScrubber(config)
+
 	var ok bool
 	app.repoRemover, ok = app.registry.(distribution.RepositoryRemover)
 	if !ok {
@@ -459,6 +715,12 @@ func migrationDriver(config *configuration.Configuration) storagedriver.StorageD
 		panic(err)
 	}
 
+	// storage middleware may alter the stored content (e.g. encryption), so it must apply to both root directories
+	driver, err = applyStorageMiddleware(driver, config.Middleware["storage"])
+	if err != nil {
+		panic(err)
+	}
+
 	return driver
 }
 
@@ -569,7 +831,7 @@ func updateOnlineGCSettings(ctx context.Context, db datastore.Queryer, config *c
 	return nil
 }
 
-func startOnlineGC(ctx context.Context, db *datastore.DB, storageDriver storagedriver.StorageDriver, config *configuration.Configuration) {
+func startOnlineGC(ctx context.Context, db *datastore.DB, storageDriver storagedriver.StorageDriver, config *configuration.Configuration, listener notifications.GCListener) {
 	if !config.Database.Enabled || config.GC.Disabled || (config.GC.Blobs.Disabled && config.GC.Manifests.Disabled) {
 		return
 	}
@@ -598,6 +860,9 @@ func startOnlineGC(ctx context.Context, db *datastore.DB, storageDriver storaged
 		if config.GC.Blobs.StorageTimeout > 0 {
 			bwOpts = append(bwOpts, worker.WithBlobStorageTimeout(config.GC.Blobs.StorageTimeout))
 		}
+		if listener != nil {
+			bwOpts = append(bwOpts, worker.WithBlobEventListener(listener))
+		}
 		bw := worker.NewBlobWorker(db, storageDriver, bwOpts...)
 
 		baOpts := aOpts
@@ -615,6 +880,9 @@ func startOnlineGC(ctx context.Context, db *datastore.DB, storageDriver storaged
 		if config.GC.TransactionTimeout > 0 {
 			mwOpts = append(mwOpts, worker.WithManifestTxTimeout(config.GC.TransactionTimeout))
 		}
+		if listener != nil {
+			mwOpts = append(mwOpts, worker.WithManifestEventListener(listener))
+		}
 		mw := worker.NewManifestWorker(db, mwOpts...)
 
 		maOpts := aOpts
@@ -689,6 +957,50 @@ func (app *App) RegisterHealthChecks(healthRegistries ...*health.Registry) {
 		}
 	}
 
+	if app.Config.Database.Enabled {
+		interval := app.Config.Health.Database.Interval
+		if interval == 0 {
+			interval = defaultCheckInterval
+		}
+		timeout := app.Config.Health.Database.Timeout
+		if timeout == 0 {
+			timeout = defaultDBCheckTimeout
+		}
+
+		checker := checks.DBChecker(app.db, timeout, app.Config.Health.Database.MaxReplicationLag)
+
+		if app.Config.Health.Database.Threshold != 0 {
+			dcontext.GetLogger(app).Infof("configuring database health check interval=%d, threshold=%d", interval/time.Second, app.Config.Health.Database.Threshold)
+			healthRegistry.Register("database", health.PeriodicThresholdChecker(checker, interval, app.Config.Health.Database.Threshold))
+		} else {
+			dcontext.GetLogger(app).Infof("configuring database health check interval=%d", interval/time.Second)
+			healthRegistry.Register("database", health.PeriodicChecker(checker, interval))
+		}
+	}
+
+	if app.Config.Health.Redis.Enabled && app.redis != nil {
+		interval := app.Config.Health.Redis.Interval
+		if interval == 0 {
+			interval = defaultCheckInterval
+		}
+		timeout := app.Config.Health.Redis.Timeout
+		if timeout == 0 {
+			timeout = defaultRedisCheckTimeout
+		}
+
+		checker := checks.RedisChecker(func(ctx context.Context) error {
+			return app.redis.Ping(ctx).Err()
+		}, timeout)
+
+		if app.Config.Health.Redis.Threshold != 0 {
+			dcontext.GetLogger(app).Infof("configuring redis health check interval=%d, threshold=%d", interval/time.Second, app.Config.Health.Redis.Threshold)
+			healthRegistry.Register("redis", health.PeriodicThresholdChecker(checker, interval, app.Config.Health.Redis.Threshold))
+		} else {
+			dcontext.GetLogger(app).Infof("configuring redis health check interval=%d", interval/time.Second)
+			healthRegistry.Register("redis", health.PeriodicChecker(checker, interval))
+		}
+	}
+
 	for _, fileChecker := range app.Config.Health.FileCheckers {
 		interval := fileChecker.Interval
 		if interval == 0 {
@@ -753,11 +1065,18 @@ var routeMetricsMiddleware = metricskit.NewHandlerFactory(
 func (app *App) register(routeName string, dispatch dispatchFunc) {
 	handler := app.dispatcher(dispatch)
 
+	routePath := v2.RoutePath(routeName)
+	route := app.router.GetRoute(routeName)
+	if route == nil {
+		routePath = v1.RoutePath(routeName)
+		route = app.gitlabRouter.GetRoute(routeName)
+	}
+
 	// Chain the handler with prometheus instrumented handler
 	if app.Config.HTTP.Debug.Prometheus.Enabled {
 		handler = routeMetricsMiddleware(
 			handler,
-			metricskit.WithLabelValues(map[string]string{"route": v2.RoutePath(routeName)}),
+			metricskit.WithLabelValues(map[string]string{"route": routePath}),
 		)
 	}
 
@@ -767,37 +1086,27 @@ func (app *App) register(routeName string, dispatch dispatchFunc) {
 	// replace it with manual routing and structure-based dispatch for better
 	// control over the request execution.
 
-	app.router.GetRoute(routeName).Handler(handler)
+	route.Handler(handler)
+}
+
+// repositoryCache returns the repository cache to use for the database connection, or nil if disabled.
+func repositoryCache(config *configuration.Configuration) *datastore.RepositoryCache {
+	if !config.Database.RepositoryCache.Enabled {
+		return nil
+	}
+	return datastore.NewRepositoryCache(config.Database.RepositoryCache.MaxEntries, config.Database.RepositoryCache.TTL)
 }
 
 // configureEvents prepares the event sink for action.
 func (app *App) configureEvents(configuration *configuration.Configuration) {
 	// Configure all of the endpoint sinks.
-	var sinks []notifications.Sink
-	for _, endpoint := range configuration.Notifications.Endpoints {
-		if endpoint.Disabled {
-			dcontext.GetLogger(app).Infof("endpoint %s disabled, skipping", endpoint.Name)
-			continue
-		}
-
-		dcontext.GetLogger(app).Infof("configuring endpoint %v (%v), timeout=%s, headers=%v", endpoint.Name, endpoint.URL, endpoint.Timeout, endpoint.Headers)
-		endpoint := notifications.NewEndpoint(endpoint.Name, endpoint.URL, notifications.EndpointConfig{
-			Timeout:           endpoint.Timeout,
-			Threshold:         endpoint.Threshold,
-			Backoff:           endpoint.Backoff,
-			Headers:           endpoint.Headers,
-			IgnoredMediaTypes: endpoint.IgnoredMediaTypes,
-			Ignore:            endpoint.Ignore,
-		})
-
-		sinks = append(sinks, endpoint)
-	}
+	sinks := notifications.NewEndpointSinks(configuration.Notifications.Endpoints)
 
 	// NOTE(stevvooe): Moving to a new queuing implementation is as easy as
 	// replacing broadcaster with a rabbitmq implementation. It's recommended
 	// that the registry instances also act as the workers to keep deployment
 	// simple.
-	app.events.sink = notifications.NewBroadcaster(sinks...)
+	app.events.sink = notifications.NewSwappableSink(notifications.NewBroadcaster(sinks...))
 
 	// Populate registry event source
 	hostname, err := os.Hostname()
@@ -817,6 +1126,288 @@ func (app *App) configureEvents(configuration *configuration.Configuration) {
 	}
 }
 
+// gcEventListener returns a listener that emits notification events for artifacts deleted by online GC, or nil if
+// GC events are disabled. Events are batched to avoid flooding endpoints during large GC runs.
+func (app *App) gcEventListener(configuration *configuration.Configuration) notifications.GCListener {
+	cfg := configuration.Notifications.GCEvents
+	if !cfg.Enabled {
+		return nil
+	}
+
+	sink := notifications.NewBatchingSink(app.events.sink, cfg.MaxBatchSize, cfg.FlushInterval)
+	return notifications.NewGCBridge(app.events.source, sink)
+}
+
+// configureAsyncValidation starts the asynchronous manifest validator, if enabled. This is not supported when the
+// registry is configured as a pull-through cache, as manifests are not pushed by clients.
+func (app *App) configureAsyncValidation(configuration *configuration.Configuration) {
+	cfg := configuration.Validation.Manifests.Async
+	if !cfg.Enabled || app.isCache {
+		return
+	}
+
+	v := newAsyncManifestValidator(app, cfg)
+	v.listener = notifications.NewQuarantineBridge(app.events.source, app.events.sink)
+	v.start(app.Context, cfg.Concurrency)
+
+	app.asyncValidator = v
+}
+
+// configureReplication starts the replicator, if enabled. Replication relies on the metadata database to persist its
+// queue and is not supported when the registry is configured as a pull-through cache.
+func (app *App) configureReplication(configuration *configuration.Configuration) {
+	cfg := configuration.Replication
+	if !cfg.Enabled {
+		return
+	}
+	if app.db == nil {
+		panic("replication: requires the metadata database to be enabled")
+	}
+	if app.isCache {
+		panic("replication: not supported when the registry is configured as a pull-through cache")
+	}
+	if app.isMirror {
+		panic("replication: not supported when the registry is configured as a database mirror")
+	}
+
+	remote, err := replication.NewRemote(cfg.RemoteURL, cfg.Username, cfg.Password)
+	if err != nil {
+		panic(fmt.Sprintf("replication: %v", err))
+	}
+
+	// if we're migrating, the content managed by the database lives in the migration registry
+	registry := app.registry
+	if configuration.Migration.Enabled {
+		registry = app.migrationRegistry
+	}
+	bp, ok := registry.Blobs().(distribution.BlobProvider)
+	if !ok {
+		panic("replication: unable to convert BlobEnumerator into BlobProvider")
+	}
+
+	log := dcontext.GetLogger(app)
+	opts := []replication.Option{replication.WithLogger(log)}
+	if cfg.Interval > 0 {
+		opts = append(opts, replication.WithInterval(cfg.Interval))
+	}
+	if cfg.Timeout > 0 {
+		opts = append(opts, replication.WithTimeout(cfg.Timeout))
+	}
+	if cfg.MaxBackoff > 0 {
+		opts = append(opts, replication.WithMaxBackoff(cfg.MaxBackoff))
+	}
+	r := replication.New(app.db, registry.BlobStatter(), bp, remote, opts...)
+
+	go func() {
+		if err := r.Start(app.Context); err != nil && !errors.Is(err, context.Canceled) {
+			errortracking.Capture(fmt.Errorf("replicator stopped with error: %w", err))
+			log.WithError(err).Error("replicator stopped")
+		}
+	}()
+
+	app.replicationEnabled = true
+	log.WithField("remote", cfg.RemoteURL).Info("replication to secondary registry enabled")
+}
+
+// configureRetention starts the enforcer of repository retention policies, if enabled. Retention policies are stored
+// in the metadata database, so this is not supported when the registry is configured as a pull-through cache.
+func (app *App) configureRetention(configuration *configuration.Configuration) {
+	cfg := configuration.Retention
+	if !cfg.Enabled {
+		return
+	}
+	if app.db == nil {
+		panic("retention: requires the metadata database to be enabled")
+	}
+	if configuration.Migration.Enabled {
+		panic("retention: not supported while migrating to the metadata database")
+	}
+	if app.isCache {
+		panic("retention: not supported when the registry is configured as a pull-through cache")
+	}
+	if app.isMirror {
+		panic("retention: not supported when the registry is configured as a database mirror")
+	}
+
+	log := dcontext.GetLogger(app)
+	opts := []retention.Option{
+		retention.WithLogger(log),
+		retention.WithTagDeleteHook(func(ctx context.Context, repoPath, tag string) {
+			app.invalidateCachedTags(ctx, repoPath, tag)
+		}),
+		retention.WithTagLocker(app.lockTag),
+	}
+	if app.auditLogger != nil {
+		opts = append(opts, retention.WithAuditLogger(app.auditLogger))
+	}
+	if cfg.Interval > 0 {
+		opts = append(opts, retention.WithInterval(cfg.Interval))
+	}
+	if cfg.Period > 0 {
+		opts = append(opts, retention.WithPeriod(cfg.Period))
+	}
+	if cfg.Timeout > 0 {
+		opts = append(opts, retention.WithTimeout(cfg.Timeout))
+	}
+	if cfg.MaxBackoff > 0 {
+		opts = append(opts, retention.WithMaxBackoff(cfg.MaxBackoff))
+	}
+	e := retention.New(app.db, opts...)
+
+	go func() {
+		if err := e.Start(app.Context); err != nil && !errors.Is(err, context.Canceled) {
+			errortracking.Capture(fmt.Errorf("retention policy enforcer stopped with error: %w", err))
+			log.WithError(err).Error("retention policy enforcer stopped")
+		}
+	}()
+
+	app.retentionEnabled = true
+	log.Info("enforcement of repository retention policies enabled")
+}
+
+// configureScrubber starts the blob scrubber, if enabled. Corrupt blobs are notified to the configured notification
+// endpoints.
+func (app *App) configureScrubber(configuration *configuration.Configuration) {
+	cfg := configuration.Scrubber
+	if !cfg.Enabled {
+		return
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		panic(fmt.Sprintf("scrubber: sample ratio must be between 0 and 1, got %v", cfg.SampleRatio))
+	}
+	if cfg.MaxBytesPerSecond < 0 {
+		panic(fmt.Sprintf("scrubber: max bytes per second must not be negative, got %d", cfg.MaxBytesPerSecond))
+	}
+	blobs, ok := app.registry.Blobs().(scrubber.BlobStore)
+	if !ok {
+		panic("scrubber: not supported by the registry storage")
+	}
+
+	log := dcontext.GetLogger(app)
+	opts := []scrubber.Option{
+		scrubber.WithLogger(log),
+		scrubber.WithListener(notifications.NewScrubBridge(app.events.source, app.events.sink)),
+	}
+	if cfg.Interval > 0 {
+		opts = append(opts, scrubber.WithInterval(cfg.Interval))
+	}
+	if cfg.SampleRatio > 0 {
+		opts = append(opts, scrubber.WithSampleRatio(cfg.SampleRatio))
+	}
+	if cfg.MaxBytesPerSecond > 0 {
+		opts = append(opts, scrubber.WithMaxBytesPerSecond(cfg.MaxBytesPerSecond))
+	}
+	s := scrubber.New(blobs, opts...)
+
+	go func() {
+		if err := s.Start(app.Context); err != nil && !errors.Is(err, context.Canceled) {
+			errortracking.Capture(fmt.Errorf("blob scrubber stopped with error: %w", err))
+			log.WithError(err).Error("blob scrubber stopped")
+		}
+	}()
+
+	log.Info("verification of blob integrity enabled")
+}
+
+// configureDataMover starts the data mover, if enabled, and prepares the registry backend for relocated repositories.
+// Relocated repositories are read from the target storage, falling back to the main storage for content that was not
+// copied yet, and written to the target storage.
+func (app *App) configureDataMover(configuration *configuration.Configuration, options ...storage.RegistryOption) {
+	cfg := configuration.DataMover
+	if !cfg.Enabled {
+		return
+	}
+	if app.db == nil {
+		panic("datamover: requires the metadata database to be enabled")
+	}
+	if configuration.Migration.Enabled {
+		panic("datamover: not supported while migrating to the metadata database")
+	}
+	if app.isMirror {
+		panic("datamover: not supported when the registry is configured as a database mirror")
+	}
+	if app.isCache {
+		panic("datamover: not supported when the registry is configured as a pull-through cache")
+	}
+
+	params := cfg.Storage.Parameters()
+	if params == nil {
+		params = make(map[string]interface{})
+	}
+	target, err := factory.Create(cfg.Storage.Type(), params)
+	if err != nil {
+		panic(fmt.Sprintf("datamover: %v", err))
+	}
+
+	app.dataMoverDriver = fallback.New(target, app.driver)
+	app.dataMoverRegistry, err = storage.NewRegistry(app.Context, app.dataMoverDriver, options...)
+	if err != nil {
+		panic(fmt.Sprintf("datamover: could not create registry: %v", err))
+	}
+
+	log := dcontext.GetLogger(app)
+	opts := []datamover.Option{datamover.WithLogger(log)}
+	if cfg.Interval > 0 {
+		opts = append(opts, datamover.WithInterval(cfg.Interval))
+	}
+	if cfg.Timeout > 0 {
+		opts = append(opts, datamover.WithTimeout(cfg.Timeout))
+	}
+	if cfg.MaxBackoff > 0 {
+		opts = append(opts, datamover.WithMaxBackoff(cfg.MaxBackoff))
+	}
+	m := datamover.New(app.db, app.driver, target, opts...)
+
+	go func() {
+		if err := m.Start(app.Context); err != nil && !errors.Is(err, context.Canceled) {
+			errortracking.Capture(fmt.Errorf("data mover stopped with error: %w", err))
+			log.WithError(err).Error("data mover stopped")
+		}
+	}()
+
+	log.WithField("storage_driver", cfg.Storage.Type()).Info("data mover enabled")
+}
+
+// configureAudit prepares the audit log sinks, if enabled.
+func (app *App) configureAudit(configuration *configuration.Configuration) {
+	if !configuration.Audit.Enabled {
+		return
+	}
+
+	var sinks []audit.Sink
+	switch {
+	case configuration.Audit.File != "" && configuration.Audit.HTTP.URL != "":
+		panic("audit log: only one of file or http sinks can be configured")
+	case configuration.Audit.File != "":
+		s, err := audit.NewFileSink(configuration.Audit.File)
+		if err != nil {
+			panic(fmt.Sprintf("audit log: %v", err))
+		}
+		sinks = append(sinks, s)
+		dcontext.GetLogger(app).Infof("configuring audit log file sink %q", configuration.Audit.File)
+	case configuration.Audit.HTTP.URL != "":
+		sinks = append(sinks, audit.NewHTTPSink(configuration.Audit.HTTP.URL, configuration.Audit.HTTP.Timeout, configuration.Audit.HTTP.Headers))
+		dcontext.GetLogger(app).Infof("configuring audit log http sink %v, timeout=%s", configuration.Audit.HTTP.URL, configuration.Audit.HTTP.Timeout)
+	}
+
+	if configuration.Audit.Database {
+		if app.db == nil {
+			panic("audit log: the database sink requires the metadata database to be enabled")
+		}
+		sinks = append(sinks, newDBAuditSink(app.db))
+		dcontext.GetLogger(app).Info("configuring audit log database sink")
+	}
+
+	switch len(sinks) {
+	case 0:
+		panic("audit log: at least one of file, http or database sinks must be configured")
+	case 1:
+		app.auditLogger = audit.NewLogger(sinks[0], configuration.Audit.QueueSize)
+	default:
+		app.auditLogger = audit.NewLogger(audit.NewMultiSink(sinks...), configuration.Audit.QueueSize)
+	}
+}
+
 func (app *App) configureRedis(configuration *configuration.Configuration) {
 	if configuration.Redis.Addr == "" {
 		dcontext.GetLogger(app).Infof("redis not configured")
@@ -861,6 +1452,47 @@ func (app *App) configureRedis(configuration *configuration.Configuration) {
 	}))
 }
 
+// configureUploadStates prepares the blob upload state store, if enabled.
+func (app *App) configureUploadStates(configuration *configuration.Configuration) {
+	if !configuration.Redis.UploadState.Enabled {
+		return
+	}
+	if app.redis == nil {
+		panic("redis configuration required to persist upload states")
+	}
+
+	app.uploadStates = newRedisUploadStateStore(app.redis, configuration.Redis.UploadState.TTL)
+	dcontext.GetLogger(app).Info("persisting blob upload states in redis")
+}
+
+// configureTagLocks prepares the distributed tag lock manager, if enabled.
+func (app *App) configureTagLocks(configuration *configuration.Configuration) {
+	cfg := configuration.Redis.TagLocks
+	if !cfg.Enabled {
+		return
+	}
+	if app.redis == nil {
+		panic("redis configuration required to use tag locks")
+	}
+
+	app.tagLocks = newRedisTagLocker(app.redis, cfg.TTL, cfg.Timeout)
+	dcontext.GetLogger(app).Info("serializing tag writes with locks in redis")
+}
+
+// configureSharedManifestCache prepares the shared manifest cache, if enabled.
+func (app *App) configureSharedManifestCache(configuration *configuration.Configuration) {
+	cfg := configuration.Redis.ManifestCache
+	if !cfg.Enabled {
+		return
+	}
+	if app.redis == nil {
+		panic("redis configuration required to use the shared manifest cache")
+	}
+
+	app.sharedManifestCache = newRedisManifestCache(app.redis, cfg.TTL)
+	dcontext.GetLogger(app).Info("caching manifests in redis")
+}
+
 // configureSecret creates a random secret if a secret wasn't included in the
 // configuration.
 func (app *App) configureSecret(configuration *configuration.Configuration) {
@@ -877,13 +1509,22 @@ func (app *App) configureSecret(configuration *configuration.Configuration) {
 func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close() // ensure that request body is always closed.
 
+	w, finishCompression := app.compressResponse(w, r)
+	defer finishCompression()
+
 	// Prepare the context with our own little decorations.
 	ctx := r.Context()
 	ctx = dcontext.WithRequest(ctx, r)
 	ctx, w = dcontext.WithResponseWriter(ctx, w)
 	ctx = dcontext.WithLogger(ctx, dcontext.GetRequestCorrelationLogger(ctx))
+	ctx, span := tracing.StartHTTPServerSpan(ctx, r)
 	r = r.WithContext(ctx)
 
+	defer func() {
+		status, _ := ctx.Value("http.response.status").(int)
+		tracing.EndHTTPServerSpan(span, status)
+	}()
+
 	if app.Config.Log.AccessLog.Disabled {
 		defer func() {
 			status, ok := ctx.Value("http.response.status").(int)
@@ -895,6 +1536,25 @@ func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Set a header with the Docker Distribution API Version for all responses.
 	w.Header().Add("Docker-Distribution-API-Version", "registry/2.0")
+
+	if app.filterClient(w, r) {
+		return
+	}
+
+	if app.corsHandler != nil {
+		app.corsHandler.ServeHTTP(w, r)
+		return
+	}
+	app.route(w, r)
+}
+
+// route dispatches a request to the GitLab API router if it matches any of its routes, or the main router otherwise.
+func (app *App) route(w http.ResponseWriter, r *http.Request) {
+	var match mux.RouteMatch
+	if app.gitlabRouter.Match(r, &match) {
+		app.gitlabRouter.ServeHTTP(w, r)
+		return
+	}
 	app.router.ServeHTTP(w, r)
 }
 
@@ -909,6 +1569,15 @@ type dispatchFunc func(ctx *Context, r *http.Request) http.Handler
 
 // dispatcher returns a handler that constructs a request specific context and
 // handler, using the dispatch factory function.
+// withMaxRequestDuration sets a deadline of d from now on ctx, if d is positive. Storage driver operations made with the
+// returned context, or any derived from it, are aborted once the deadline is exceeded.
+func withMaxRequestDuration(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
 func (app *App) dispatcher(dispatch dispatchFunc) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		for headerName, headerValues := range app.Config.HTTP.Headers {
@@ -917,7 +1586,20 @@ func (app *App) dispatcher(dispatch dispatchFunc) http.Handler {
 			}
 		}
 
+		stopLimits, rejected := app.limitRequest(w, r)
+		if rejected {
+			return
+		}
+		defer stopLimits()
+
 		context := app.context(w, r)
+		var cancel func()
+		context.Context, cancel = withMaxRequestDuration(context.Context, app.Config.HTTP.MaxRequestDuration)
+		defer cancel()
+
+		if route := mux.CurrentRoute(r); route != nil && route.GetName() != "" {
+			tracing.SetHTTPRoute(context, r.Method, route.GetName())
+		}
 
 		if err := app.authorized(w, r, context); err != nil {
 			dcontext.GetLogger(context).Warnf("error authorizing context: %v", err)
@@ -953,6 +1635,7 @@ func (app *App) dispatcher(dispatch dispatchFunc) http.Handler {
 				context.Errors = append(context.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
 			}
 			context.blobProvider = bp
+			context.storageDriver = app.driver
 
 			repository, err := app.registry.Repository(context, nameRef)
 			if err != nil {
@@ -992,6 +1675,7 @@ func (app *App) dispatcher(dispatch dispatchFunc) http.Handler {
 					context.Errors = append(context.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
 				}
 				context.blobProvider = bp
+				context.storageDriver = app.migrationDriver
 
 				repository, err = app.migrationRegistry.Repository(context, nameRef)
 				if err != nil {
@@ -1065,6 +1749,66 @@ func (app *App) dispatcher(dispatch dispatchFunc) http.Handler {
 			"migrating_repository": migrateRepo,
 		}))
 
+		// repositories relocated by the data mover are served from the target storage
+		routed, err := isStorageMoveRouted(context)
+		if err != nil {
+			err = fmt.Errorf("determining whether repository was relocated: %w", err)
+			dcontext.GetLogger(context).Error(err)
+			context.Errors = append(context.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+			if err := errcode.ServeJSON(w, context.Errors); err != nil {
+				dcontext.GetLogger(context).Errorf("error serving error json: %v (from %v)", err, context.Errors)
+			}
+			app.logError(context, r, context.Errors)
+			return
+		}
+		if routed {
+			if err := app.routeToDataMover(context, r); err != nil {
+				dcontext.GetLogger(context).Errorf("error resolving relocated repository: %v", err)
+				context.Errors = append(context.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+				if err := errcode.ServeJSON(w, context.Errors); err != nil {
+					dcontext.GetLogger(context).Errorf("error serving error json: %v (from %v)", err, context.Errors)
+				}
+				app.logError(context, r, context.Errors)
+				return
+			}
+		}
+
+		// archived repositories are read-only
+		archived, err := isArchivedRepositoryWrite(context, r)
+		if err != nil {
+			err = fmt.Errorf("determining whether repository is archived: %w", err)
+			dcontext.GetLogger(context).Error(err)
+			context.Errors = append(context.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		} else if archived {
+			context.Errors = append(context.Errors, v1.ErrorCodeRepositoryArchived.WithDetail(map[string]string{"name": getName(context)}))
+		}
+		if err != nil || archived {
+			if err := errcode.ServeJSON(w, context.Errors); err != nil {
+				dcontext.GetLogger(context).Errorf("error serving error json: %v (from %v)", err, context.Errors)
+			}
+			app.logError(context, r, context.Errors)
+			return
+		}
+
+		// repositories may have to be created explicitly
+		if app.Config.Policy.Repository.DisableAutoCreate {
+			implicit, err := isImplicitRepositoryCreation(context, r)
+			if err != nil {
+				err = fmt.Errorf("determining whether repository exists: %w", err)
+				dcontext.GetLogger(context).Error(err)
+				context.Errors = append(context.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+			} else if implicit {
+				context.Errors = append(context.Errors, v2.ErrorCodeNameUnknown.WithMessage("repository name not known to registry, it must be created before pushing").WithDetail(map[string]string{"name": getName(context)}))
+			}
+			if err != nil || implicit {
+				if err := errcode.ServeJSON(w, context.Errors); err != nil {
+					dcontext.GetLogger(context).Errorf("error serving error json: %v (from %v)", err, context.Errors)
+				}
+				app.logError(context, r, context.Errors)
+				return
+			}
+		}
+
 		dispatch(context, r).ServeHTTP(w, r)
 		// Automated error response handling here. Handlers may return their
 		// own errors if they need different behavior (such as range errors
@@ -1145,7 +1889,7 @@ func (app *App) context(w http.ResponseWriter, r *http.Request) *Context {
 		// hostname in the request.
 		context.urlBuilder = v2.NewURLBuilder(&app.httpHost, false)
 	} else {
-		context.urlBuilder = v2.NewURLBuilderFromRequest(r, app.Config.HTTP.RelativeURLs)
+		context.urlBuilder = v2.NewURLBuilderFromRequestWithPrefix(r, app.Config.HTTP.Prefix, app.Config.HTTP.RelativeURLs)
 	}
 
 	return context
@@ -1158,19 +1902,75 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 	dcontext.GetLogger(context).Debug("authorizing request")
 	repo := getName(context)
 
-	if app.accessController == nil {
+	accessController := app.currentAccessController()
+	if accessController == nil {
 		return nil // access controller is not enabled.
 	}
 
 	var accessRecords []auth.Access
 
 	if repo != "" {
-		accessRecords = appendAccessRecords(accessRecords, r.Method, repo)
-		if fromRepo := r.FormValue("from"); fromRepo != "" {
+		method := r.Method
+		if route := mux.CurrentRoute(r); route != nil && route.GetName() == v1.RouteNameRepositoryBlobsExists {
+			// checking the existence of blobs is the batch equivalent of
+			// HEAD requests, so it only requires pull access.
+			method = http.MethodHead
+		}
+		accessRecords = appendAccessRecords(accessRecords, method, repo)
+		if fromRepo := mountSourceRepository(r); fromRepo != "" && fromRepo != repo {
 			// mounting a blob from one repository to another requires pull (GET)
-			// access to the source repository.
+			// access to the source repository, on top of push access to the
+			// target repository, so that both are granted by a single token.
+			accessRecords = appendAccessRecords(accessRecords, "GET", fromRepo)
+		}
+		if fromRepo := copySourceRepository(r); fromRepo != "" && fromRepo != repo {
+			// copying a manifest reads it, along with all the blobs it
+			// references, from the source repository, so it requires pull
+			// access to it, just like a cross repository blob mount.
 			accessRecords = appendAccessRecords(accessRecords, "GET", fromRepo)
 		}
+		if route := mux.CurrentRoute(r); route != nil && route.GetName() == v1.RouteNameRepositoryStorageMove {
+			// relocating a repository is an administrative operation.
+			accessRecords = append(accessRecords, auth.Access{
+				Resource: auth.Resource{Type: "repository", Name: repo},
+				Action:   "*",
+			})
+		}
+		if route := mux.CurrentRoute(r); route != nil && route.GetName() == v1.RouteNameRepositoryStatisticsRecalculate {
+			// recalculating statistics is a repair operation for operators,
+			// which scans the whole repository.
+			accessRecords = append(accessRecords, auth.Access{
+				Resource: auth.Resource{Type: "repository", Name: repo},
+				Action:   "*",
+			})
+		}
+		if route := mux.CurrentRoute(r); route != nil && route.GetName() == v1.RouteNameRepositoryCreate {
+			// provisioning repositories is an administrative operation, so
+			// that it can be restricted when implicit creation is disabled.
+			accessRecords = append(accessRecords, auth.Access{
+				Resource: auth.Resource{Type: "repository", Name: repo},
+				Action:   "*",
+			})
+		}
+		if route := mux.CurrentRoute(r); route != nil && route.GetName() == v1.RouteNameRepositoryUploads {
+			// in-progress uploads are only of interest to those who can push
+			// to the repository. Cancelling them also requires delete access,
+			// as granted for the DELETE method above.
+			accessRecords = appendAccessRecords(accessRecords, "PUT", repo)
+		}
+		if route := mux.CurrentRoute(r); route != nil && route.GetName() == v1.RouteNameRetentionPolicy && r.Method == http.MethodPut {
+			// retention policies delete tags, so setting one requires the
+			// same access as deleting them directly.
+			accessRecords = appendAccessRecords(accessRecords, "DELETE", repo)
+		}
+		if route := mux.CurrentRoute(r); route != nil && route.GetName() == v1.RouteNameRepositoryRename {
+			// renaming a repository removes it from its current path and
+			// pushes it to the destination path.
+			accessRecords = appendAccessRecords(accessRecords, "DELETE", repo)
+			if to := r.FormValue(renameToQueryParamKey); to != "" {
+				accessRecords = appendAccessRecords(accessRecords, "PUT", to)
+			}
+		}
 	} else {
 		// Only allow the name not to be set on the base route.
 		if app.nameRequired(r) {
@@ -1188,7 +1988,7 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 		accessRecords = appendCatalogAccessRecord(accessRecords, r)
 	}
 
-	ctx, err := app.accessController.Authorized(context.Context, accessRecords...)
+	ctx, err := accessController.Authorized(context.Context, accessRecords...)
 	if err != nil {
 		switch err := err.(type) {
 		case auth.Challenge:
@@ -1218,6 +2018,93 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 	return nil
 }
 
+// routeToDataMover replaces the repository and blob provider of ctx with those of the data mover registry.
+func (app *App) routeToDataMover(ctx *Context, r *http.Request) error {
+	bp, ok := app.dataMoverRegistry.Blobs().(distribution.BlobProvider)
+	if !ok {
+		return errors.New("unable to convert BlobEnumerator into BlobProvider")
+	}
+
+	repository, err := app.dataMoverRegistry.Repository(ctx, ctx.Repository.Named())
+	if err != nil {
+		return err
+	}
+
+	ctx.blobProvider = bp
+	ctx.storageDriver = app.dataMoverDriver
+	ctx.Repository, ctx.RepositoryRemover = notifications.Listen(repository, app.repoRemover, app.eventBridge(ctx, r))
+	ctx.Repository, err = applyRepoMiddleware(app, ctx.Repository, app.Config.Middleware["repository"])
+
+	return err
+}
+
+// auditLog records a write operation in the audit log, if enabled. The actor and request ID are derived from the
+// request context.
+func (app *App) auditLog(ctx *Context, r *http.Request, event audit.Event) {
+	if app.auditLogger == nil {
+		return
+	}
+
+	event.Actor = getUserName(ctx, r)
+	event.RequestID = dcontext.GetRequestID(ctx)
+	if event.Repository == "" && ctx.Repository != nil {
+		event.Repository = ctx.Repository.Named().Name()
+	}
+
+	app.auditLogger.Log(event)
+}
+
+// queueReplication records a blob or manifest written to the repository of the current request in the replication
+// queue. Only requests served by the metadata database are considered. A non-empty tag is only meaningful for manifests. Failures are logged but do not fail the request, as the write
+// already succeeded.
+func (app *App) queueReplication(ctx *Context, artifact string, dgst digest.Digest, tag string) {
+	if !app.replicationEnabled || !ctx.useDatabase || ctx.Repository == nil {
+		return
+	}
+
+	log := dcontext.GetLogger(ctx)
+	path := ctx.Repository.Named().Name()
+
+	r, err := datastore.NewRepositoryStore(app.db).FindByPath(ctx, path)
+	if err != nil {
+		log.WithError(err).Error("failed to find repository for replication")
+		return
+	}
+	if r == nil {
+		log.WithField("repository", path).Warn("repository not found, skipping replication")
+		return
+	}
+
+	t := &models.ReplicationTask{
+		NamespaceID:  r.NamespaceID,
+		RepositoryID: r.ID,
+		Artifact:     artifact,
+		Digest:       dgst,
+		Tag:          tag,
+	}
+	if err := datastore.NewReplicationTaskStore(app.db).Create(ctx, t); err != nil {
+		log.WithError(err).Error("failed to queue replication task")
+	}
+}
+
+// CloseAuditLog flushes pending audit events and closes the audit log sink. It is a no-op if the audit log is
+// disabled.
+func (app *App) CloseAuditLog() error {
+	if app.auditLogger == nil {
+		return nil
+	}
+
+	return app.auditLogger.Close()
+}
+
+// notifyTagDeleted sends a tag delete notification for a tag deleted from the database alone. Tags deleted from the
+// filesystem are notified by the tag service decorated with the event bridge instead.
+func (app *App) notifyTagDeleted(ctx *Context, r *http.Request, tag string, desc distribution.Descriptor) {
+	if err := app.eventBridge(ctx, r).TagDeleted(ctx.Repository.Named(), tag, desc); err != nil {
+		dcontext.GetLogger(ctx).WithError(err).Error("error dispatching tag deleted to listener")
+	}
+}
+
 // eventBridge returns a bridge for the current request, configured with the
 // correct actor and source.
 func (app *App) eventBridge(ctx *Context, r *http.Request) notifications.Listener {
@@ -1241,18 +2128,25 @@ func (app *App) nameRequired(r *http.Request) bool {
 
 // apiBase implements a simple yes-man for doing overall checks against the
 // api. This can support auth roundtrips to support docker login.
-func apiBase(w http.ResponseWriter, r *http.Request) {
+func (app *App) apiBase(w http.ResponseWriter, r *http.Request) {
 	const emptyJSON = "{}"
 	// Provide a simple /v2/ 200 OK response with empty json response.
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Content-Length", fmt.Sprint(len(emptyJSON)))
 
 	w.Header().Set("Gitlab-Container-Registry-Version", strings.TrimPrefix(version.Version, "v"))
-	w.Header().Set("Gitlab-Container-Registry-Features", version.ExtFeatures)
+	w.Header().Set("Gitlab-Container-Registry-Features", extFeatures(app.manifestPayloadSizeLimit))
+	w.Header().Set("OCI-Distribution-Spec-Version", version.OCIDistributionSpec)
 
 	fmt.Fprint(w, emptyJSON)
 }
 
+// extFeatures returns the list of extensions/features supported by the registry, along with its configurable limits
+// in the form `<name>=<value>`, so that clients can adapt to them.
+func extFeatures(manifestPayloadSizeLimit int64) string {
+	return fmt.Sprintf("%s,manifest_payload_size_limit=%d", version.ExtFeatures, manifestPayloadSizeLimit)
+}
+
 // appendAccessRecords checks the method and adds the appropriate Access records to the records list.
 func appendAccessRecords(records []auth.Access, method string, repo string) []auth.Access {
 	resource := auth.Resource{
@@ -1287,6 +2181,43 @@ func appendAccessRecords(records []auth.Access, method string, repo string) []au
 	return records
 }
 
+// mountSourceRepository returns the name of the repository from which r
+// attempts to mount a blob, or an empty string if r is not a cross repository
+// blob mount request. Requests with an invalid source repository name are not
+// considered mounts, as these fall back to a regular blob upload.
+func mountSourceRepository(r *http.Request) string {
+	if r.Method != http.MethodPost || r.FormValue("mount") == "" {
+		return ""
+	}
+	if route := mux.CurrentRoute(r); route == nil || route.GetName() != v2.RouteNameBlobUpload {
+		return ""
+	}
+
+	fromRepo := r.FormValue("from")
+	if _, err := reference.WithName(fromRepo); err != nil {
+		return ""
+	}
+
+	return fromRepo
+}
+
+// copySourceRepository returns the name of the repository from which r
+// attempts to copy a manifest, or an empty string if r is not a repository
+// copy request. Requests with an invalid source repository name are rejected
+// by the copy handler.
+func copySourceRepository(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route == nil || route.GetName() != v1.RouteNameRepositoryCopy {
+		return ""
+	}
+
+	fromRepo := r.URL.Query().Get(copyFromQueryParamKey)
+	if _, err := reference.WithName(fromRepo); err != nil {
+		return ""
+	}
+
+	return fromRepo
+}
+
 // Add the access record for the catalog if it's our current route
 func appendCatalogAccessRecord(accessRecords []auth.Access, r *http.Request) []auth.Access {
 	route := mux.CurrentRoute(r)
@@ -1360,10 +2291,13 @@ func badPurgeUploadConfig(reason string) {
 }
 
 // startUploadPurger schedules a goroutine which will periodically
-// check upload directories for old files and delete them
-func startUploadPurger(ctx context.Context, storageDriver storagedriver.StorageDriver, log dcontext.Logger, config map[interface{}]interface{}) {
+// check upload directories for old files and delete them. If db is not nil,
+// stale uploads are found through the metadata database instead of walking the
+// storage backend. It returns the configured purge age, or zero if upload
+// purging is disabled.
+func startUploadPurger(ctx context.Context, storageDriver storagedriver.StorageDriver, db *datastore.DB, log dcontext.Logger, config map[interface{}]interface{}) time.Duration {
 	if config["enabled"] == false {
-		return
+		return 0
 	}
 
 	var purgeAgeDuration time.Duration
@@ -1417,11 +2351,17 @@ func startUploadPurger(ctx context.Context, storageDriver storagedriver.StorageD
 		time.Sleep(jitter)
 
 		for {
-			storage.PurgeUploads(ctx, storageDriver, time.Now().Add(-purgeAgeDuration), !dryRunBool)
+			if db != nil {
+				storage.PurgeUploadsDB(ctx, storageDriver, db, time.Now().Add(-purgeAgeDuration), !dryRunBool)
+			} else {
+				storage.PurgeUploads(ctx, storageDriver, time.Now().Add(-purgeAgeDuration), !dryRunBool)
+			}
 			log.Infof("Starting upload purge in %s", intervalDuration)
 			time.Sleep(intervalDuration)
 		}
 	}()
+
+	return purgeAgeDuration
 }
 
 // GracefulShutdown allows the app to free any resources before shutdown.
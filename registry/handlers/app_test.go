@@ -2,21 +2,30 @@ package handlers
 
 import (
 	"context"
+	"crypto"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/docker/distribution/configuration"
 	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
 	v2 "github.com/docker/distribution/registry/api/v2"
 	"github.com/docker/distribution/registry/auth"
 	_ "github.com/docker/distribution/registry/auth/silly"
+	"github.com/docker/distribution/registry/auth/token"
 	"github.com/docker/distribution/registry/datastore"
 	dbmock "github.com/docker/distribution/registry/datastore/mocks"
 	storemock "github.com/docker/distribution/registry/datastore/mocks"
@@ -25,6 +34,7 @@ import (
 	"github.com/docker/distribution/registry/storage"
 	memorycache "github.com/docker/distribution/registry/storage/cache/memory"
 	"github.com/docker/distribution/registry/storage/driver/testdriver"
+	"github.com/docker/libtrust"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 )
@@ -41,11 +51,12 @@ func TestAppDispatcher(t *testing.T) {
 		t.Fatalf("error creating registry: %v", err)
 	}
 	app := &App{
-		Config:   &configuration.Configuration{},
-		Context:  ctx,
-		router:   v2.Router(),
-		driver:   driver,
-		registry: registry,
+		Config:       &configuration.Configuration{},
+		Context:      ctx,
+		router:       v2.Router(),
+		gitlabRouter: v1.Router(),
+		driver:       driver,
+		registry:     registry,
 	}
 	server := httptest.NewServer(app)
 	defer server.Close()
@@ -222,6 +233,164 @@ func TestNewApp(t *testing.T) {
 	}
 }
 
+func TestNewApp_InmemoryLRUBlobDescriptorCache(t *testing.T) {
+	newConfig := func(cache configuration.Parameters) *configuration.Configuration {
+		return &configuration.Configuration{
+			Storage: configuration.Storage{
+				"testdriver": nil,
+				"cache":      cache,
+				"maintenance": configuration.Parameters{"uploadpurging": map[interface{}]interface{}{
+					"enabled": false,
+				}},
+			},
+		}
+	}
+
+	require.NotPanics(t, func() {
+		NewApp(context.Background(), newConfig(configuration.Parameters{
+			"blobdescriptor": "inmemory-lru",
+			"maxentries":     1000,
+			"maxsize":        "1048576",
+		}))
+	})
+	require.Panics(t, func() {
+		NewApp(context.Background(), newConfig(configuration.Parameters{
+			"blobdescriptor": "inmemory-lru",
+			"maxentries":     "lots",
+		}))
+	})
+}
+
+func TestMountSourceRepository(t *testing.T) {
+	router := v2.Router()
+	dgst := "sha256:" + strings.Repeat("a", 64)
+
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		expected string
+	}{
+		{
+			name:     "mount",
+			method:   http.MethodPost,
+			url:      "/v2/foo/target/blobs/uploads/?mount=" + dgst + "&from=foo/source",
+			expected: "foo/source",
+		},
+		{
+			name:   "from without mount",
+			method: http.MethodPost,
+			url:    "/v2/foo/target/blobs/uploads/?from=foo/source",
+		},
+		{
+			name:   "invalid source repository",
+			method: http.MethodPost,
+			url:    "/v2/foo/target/blobs/uploads/?mount=" + dgst + "&from=Foo/Source",
+		},
+		{
+			name:   "not a blob upload",
+			method: http.MethodGet,
+			url:    "/v2/foo/target/tags/list?mount=" + dgst + "&from=foo/source",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			router.NotFoundHandler = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				t.Fatal("route not found")
+			})
+			for _, route := range []string{v2.RouteNameBlobUpload, v2.RouteNameTags} {
+				router.GetRoute(route).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					got = mountSourceRepository(r)
+				}))
+			}
+
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.url, nil))
+			require.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+// signTestToken issues a bearer token for service, granting access, signed by key.
+func signTestToken(t *testing.T, key libtrust.PrivateKey, service string, access []*token.ResourceActions) string {
+	t.Helper()
+
+	jwk, err := key.PublicKey().MarshalJSON()
+	require.NoError(t, err)
+	rawJWK := json.RawMessage(jwk)
+	header, err := json.Marshal(token.Header{Type: "JWT", SigningAlg: "ES256", RawJWK: &rawJWK})
+	require.NoError(t, err)
+
+	now := time.Now()
+	claims, err := json.Marshal(token.ClaimSet{
+		Issuer:     "test-issuer",
+		Subject:    "foo",
+		Audience:   service,
+		Expiration: now.Add(5 * time.Minute).Unix(),
+		NotBefore:  now.Unix(),
+		IssuedAt:   now.Unix(),
+		JWTID:      strconv.FormatInt(rand.Int63(), 10),
+		Access:     access,
+	})
+	require.NoError(t, err)
+
+	payload := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sig, _, err := key.Sign(strings.NewReader(payload), crypto.SHA256)
+	require.NoError(t, err)
+
+	return payload + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// TestRepositoryCopyRequiresSourcePullAccess ensures that copying a manifest requires pull access to the source
+// repository on top of push access to the destination repository.
+func TestRepositoryCopyRequiresSourcePullAccess(t *testing.T) {
+	key, err := libtrust.GenerateECP256PrivateKey()
+	require.NoError(t, err)
+	cert, err := libtrust.GenerateCACert(key, key)
+	require.NoError(t, err)
+	bundle := filepath.Join(t.TempDir(), "bundle.pem")
+	require.NoError(t, ioutil.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600))
+
+	config := &configuration.Configuration{
+		Storage: configuration.Storage{
+			"testdriver": nil,
+			"maintenance": configuration.Parameters{"uploadpurging": map[interface{}]interface{}{
+				"enabled": false,
+			}},
+		},
+		Auth: configuration.Auth{
+			"token": {
+				"realm":          "https://auth.example.com/token",
+				"service":        "test-service",
+				"issuer":         "test-issuer",
+				"rootcertbundle": bundle,
+			},
+		},
+	}
+	server := httptest.NewServer(NewApp(context.Background(), config))
+	defer server.Close()
+
+	copyWithAccess := func(access ...*token.ResourceActions) int {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/gitlab/v1/repositories/foo/target/copy?from=foo/source&tag=latest", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+signTestToken(t, key, "test-service", access))
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	target := &token.ResourceActions{Type: "repository", Name: "foo/target", Actions: []string{"pull", "push"}}
+	source := &token.ResourceActions{Type: "repository", Name: "foo/source", Actions: []string{"pull"}}
+
+	require.Equal(t, http.StatusUnauthorized, copyWithAccess(target))
+	// the copy is authorized, but not supported without the metadata database
+	require.Equal(t, http.StatusMethodNotAllowed, copyWithAccess(target, source))
+}
+
 // Test the access record accumulator
 func TestAppendAccessRecords(t *testing.T) {
 	repo := "testRepo"
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Artifact types exposed by the repository detail APIs, as derived from the configuration media type of manifests.
+const (
+	artifactTypeImage     = "image"
+	artifactTypeHelmChart = "helm_chart"
+	artifactTypeOther     = "other"
+)
+
+// helmChartConfig is the subset of the configuration of a Helm chart (the contents of its Chart.yaml, serialized as
+// JSON) that is exposed as annotations by the repository detail APIs.
+type helmChartConfig struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	AppVersion string `json:"appVersion"`
+}
+
+// artifactType classifies a manifest with the given media type and configuration media type as a container image,
+// a Helm chart or another kind of artifact. Manifest lists, image indexes and schema 1 manifests have no configuration
+// and are classified as images.
+func artifactType(mediaType, configMediaType string) string {
+	switch configMediaType {
+	case schema2.MediaTypeImageConfig, v1.MediaTypeImageConfig:
+		return artifactTypeImage
+	case datastore.HelmChartConfigMediaType:
+		return artifactTypeHelmChart
+	case "":
+		switch mediaType {
+		case manifestlist.MediaTypeManifestList, v1.MediaTypeImageIndex,
+			schema1.MediaTypeSignedManifest, schema1.MediaTypeManifest:
+			return artifactTypeImage
+		}
+	}
+
+	return artifactTypeOther
+}
+
+// artifactAnnotations returns the annotations of an artifact of type typ with configuration payload cfg. Only Helm
+// charts have annotations, namely the chart name, version and application version. Returns nil if there are none.
+func artifactAnnotations(ctx context.Context, typ string, cfg models.Payload) map[string]string {
+	if typ != artifactTypeHelmChart || len(cfg) == 0 {
+		return nil
+	}
+
+	var chart helmChartConfig
+	if err := json.Unmarshal(cfg, &chart); err != nil {
+		// the configuration is not validated on push, so a malformed one should not fail the whole listing
+		dcontext.GetLogger(ctx).WithError(err).Warn("failed to parse helm chart configuration")
+		return nil
+	}
+
+	annotations := make(map[string]string)
+	for k, v := range map[string]string{
+		"chart_name":    chart.Name,
+		"chart_version": chart.Version,
+		"app_version":   chart.AppVersion,
+	} {
+		if v != "" {
+			annotations[k] = v
+		}
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	return annotations
+}
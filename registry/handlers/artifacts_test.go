@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifactType(t *testing.T) {
+	tt := []struct {
+		name            string
+		mediaType       string
+		configMediaType string
+		expected        string
+	}{
+		{
+			name:            "schema 2 image",
+			mediaType:       schema2.MediaTypeManifest,
+			configMediaType: schema2.MediaTypeImageConfig,
+			expected:        artifactTypeImage,
+		},
+		{
+			name:            "OCI image",
+			mediaType:       v1.MediaTypeImageManifest,
+			configMediaType: v1.MediaTypeImageConfig,
+			expected:        artifactTypeImage,
+		},
+		{
+			name:            "helm chart",
+			mediaType:       v1.MediaTypeImageManifest,
+			configMediaType: datastore.HelmChartConfigMediaType,
+			expected:        artifactTypeHelmChart,
+		},
+		{
+			name:            "manifest list",
+			mediaType:       manifestlist.MediaTypeManifestList,
+			configMediaType: "",
+			expected:        artifactTypeImage,
+		},
+		{
+			name:            "image index",
+			mediaType:       v1.MediaTypeImageIndex,
+			configMediaType: "",
+			expected:        artifactTypeImage,
+		},
+		{
+			name:            "schema 1",
+			mediaType:       schema1.MediaTypeSignedManifest,
+			configMediaType: "",
+			expected:        artifactTypeImage,
+		},
+		{
+			name:            "other artifact",
+			mediaType:       v1.MediaTypeImageManifest,
+			configMediaType: "application/vnd.example.config.v1+json",
+			expected:        artifactTypeOther,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, artifactType(test.mediaType, test.configMediaType))
+		})
+	}
+}
+
+func TestArtifactAnnotations(t *testing.T) {
+	ctx := context.Background()
+
+	tt := []struct {
+		name     string
+		typ      string
+		cfg      models.Payload
+		expected map[string]string
+	}{
+		{
+			name: "helm chart",
+			typ:  artifactTypeHelmChart,
+			cfg:  models.Payload(`{"name":"nginx","version":"1.2.3","appVersion":"1.21.0","apiVersion":"v2"}`),
+			expected: map[string]string{
+				"chart_name":    "nginx",
+				"chart_version": "1.2.3",
+				"app_version":   "1.21.0",
+			},
+		},
+		{
+			name: "helm chart without app version",
+			typ:  artifactTypeHelmChart,
+			cfg:  models.Payload(`{"name":"nginx","version":"1.2.3"}`),
+			expected: map[string]string{
+				"chart_name":    "nginx",
+				"chart_version": "1.2.3",
+			},
+		},
+		{
+			name: "helm chart with malformed configuration",
+			typ:  artifactTypeHelmChart,
+			cfg:  models.Payload(`{"name":`),
+		},
+		{
+			name: "helm chart without configuration",
+			typ:  artifactTypeHelmChart,
+		},
+		{
+			name: "image",
+			typ:  artifactTypeImage,
+			cfg:  models.Payload(`{"name":"nginx"}`),
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, artifactAnnotations(ctx, test.typ, test.cfg))
+		})
+	}
+}
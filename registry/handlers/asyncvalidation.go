@@ -0,0 +1,442 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/notifications"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/storage/validation"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAsyncValidationConcurrency = 5
+	defaultAsyncValidationQueueSize   = 1000
+	defaultAsyncValidationTimeout     = time.Minute
+)
+
+// errImageConfigInvalid is returned when the configuration of an image manifest is malformed or does not match the
+// platform declared by a referencing manifest list.
+var errImageConfigInvalid = errors.New("invalid image configuration")
+
+// asyncManifestValidator performs the deep validation of manifests pushed by trusted subjects in the background. Such
+// manifests are stored and tagged after structural checks only. If the deferred validation fails, the tag is removed
+// (quarantined) and a notification event is emitted.
+type asyncManifestValidator struct {
+	app             *App
+	trustedSubjects map[string]struct{}
+	timeout         time.Duration
+	listener        notifications.QuarantineListener
+
+	// slots limits the number of manifests pending validation. A slot must be reserved before deferring the
+	// validation of a manifest, which guarantees that sending to queue never blocks.
+	slots chan struct{}
+	queue chan *asyncValidationJob
+}
+
+// asyncValidationJob holds everything needed to validate a manifest after the corresponding request has completed.
+type asyncValidationJob struct {
+	repository   distribution.Repository
+	blobProvider distribution.BlobProvider
+	manifest     distribution.Manifest
+	digest       digest.Digest
+	tag          string
+
+	useDatabase     bool
+	writeFSMetadata bool
+}
+
+func newAsyncManifestValidator(app *App, config configuration.AsyncManifestValidation) *asyncManifestValidator {
+	subjects := make(map[string]struct{}, len(config.TrustedSubjects))
+	for _, s := range config.TrustedSubjects {
+		subjects[s] = struct{}{}
+	}
+
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultAsyncValidationQueueSize
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultAsyncValidationTimeout
+	}
+
+	return &asyncManifestValidator{
+		app:             app,
+		trustedSubjects: subjects,
+		timeout:         timeout,
+		slots:           make(chan struct{}, queueSize),
+		queue:           make(chan *asyncValidationJob, queueSize),
+	}
+}
+
+// start launches concurrency workers, which run until ctx is done.
+func (v *asyncManifestValidator) start(ctx context.Context, concurrency int) {
+	if concurrency <= 0 {
+		concurrency = defaultAsyncValidationConcurrency
+	}
+	for i := 0; i < concurrency; i++ {
+		go v.run(ctx)
+	}
+}
+
+func (v *asyncManifestValidator) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-v.queue:
+			v.process(ctx, job)
+			v.release()
+		}
+	}
+}
+
+// trusted determines whether the authenticated subject of the request is allowed to defer manifest validation.
+// Usernames provided with basic auth headers are not considered, as they are not verified by the registry.
+func (v *asyncManifestValidator) trusted(ctx context.Context) bool {
+	subject := dcontext.GetStringValue(ctx, auth.UserNameKey)
+	if subject == "" {
+		return false
+	}
+	_, ok := v.trustedSubjects[subject]
+	return ok
+}
+
+// reserve reserves a slot in the validation queue, returning false if the queue is full.
+func (v *asyncManifestValidator) reserve() bool {
+	select {
+	case v.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// release frees a slot previously obtained with reserve.
+func (v *asyncManifestValidator) release() {
+	<-v.slots
+}
+
+// enqueue submits a job for validation. A slot must have been reserved beforehand.
+func (v *asyncManifestValidator) enqueue(job *asyncValidationJob) {
+	v.queue <- job
+}
+
+func (v *asyncManifestValidator) process(ctx context.Context, job *asyncValidationJob) {
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	repoPath := job.repository.Named().Name()
+	log := dcontext.GetLogger(ctx).WithFields(logrus.Fields{
+		"repository":      repoPath,
+		"manifest_digest": job.digest,
+		"tag":             job.tag,
+	})
+
+	start := time.Now()
+	err := v.validate(ctx, job)
+	log = log.WithField("duration_s", time.Since(start).Seconds())
+	if err == nil {
+		log.Info("asynchronous manifest validation succeeded")
+		return
+	}
+	if !isValidationFailure(err) {
+		// the validation could not be completed (e.g. storage or database errors), so we can't tell whether the
+		// manifest is invalid and must not quarantine it
+		log.WithError(err).Error("failed to validate manifest asynchronously")
+		return
+	}
+
+	log.WithError(err).Warn("manifest failed asynchronous validation")
+	if job.tag == "" {
+		return
+	}
+
+	quarantined, err := v.quarantine(ctx, job)
+	if errors.Is(err, errRepositoryArchived) {
+		log.Warn("repository is archived, skipping quarantine")
+		return
+	}
+	if err != nil {
+		log.WithError(err).Error("failed to quarantine tag")
+		return
+	}
+	if !quarantined {
+		log.Info("tag no longer points to the invalid manifest, skipping quarantine")
+		return
+	}
+	log.Warn("tag quarantined")
+
+	if v.listener != nil {
+		if err := v.listener.TagQuarantined(repoPath, job.tag, job.digest); err != nil {
+			log.WithError(err).Error("failed to notify tag quarantine")
+		}
+	}
+}
+
+// isValidationFailure determines whether err signals an invalid manifest, as opposed to a failure to validate it.
+func isValidationFailure(err error) bool {
+	var verificationErr distribution.ErrManifestVerification
+	var blobUnknownErr distribution.ErrManifestBlobUnknown
+
+	return errors.As(err, &verificationErr) ||
+		errors.As(err, &blobUnknownErr) ||
+		errors.Is(err, distribution.ErrBlobUnknown) ||
+		errors.Is(err, errImageConfigInvalid)
+}
+
+// validationSource provides read access to the contents of a repository for validation purposes.
+type validationSource struct {
+	exister validation.ManifestExister
+	statter distribution.BlobStatter
+	// getManifest retrieves a manifest linked to the repository.
+	getManifest func(ctx context.Context, desc distribution.Descriptor) (distribution.Manifest, error)
+	// getBlob retrieves the content of a blob linked to the repository.
+	getBlob func(ctx context.Context, dgst digest.Digest) ([]byte, error)
+}
+
+// newValidationSource creates a validationSource for repo, reading from the metadata database if useDatabase is true
+// or from the storage backend otherwise.
+func (app *App) newValidationSource(ctx context.Context, repo distribution.Repository, blobProvider distribution.BlobProvider, useDatabase bool) (*validationSource, error) {
+	src := &validationSource{}
+
+	if useDatabase {
+		repoPath := repo.Named().Name()
+		rStore := datastore.NewRepositoryStore(app.db)
+		src.exister = &datastore.RepositoryManifestService{RepositoryReader: rStore, RepositoryPath: repoPath}
+		src.statter = &datastore.RepositoryBlobService{RepositoryReader: rStore, RepositoryPath: repoPath}
+		src.getManifest = func(ctx context.Context, desc distribution.Descriptor) (distribution.Manifest, error) {
+			r, err := rStore.FindByPath(ctx, repoPath)
+			if err != nil {
+				return nil, err
+			}
+			if r == nil {
+				return nil, distribution.ErrRepositoryUnknown{Name: repoPath}
+			}
+			m, err := rStore.FindManifestByDigest(ctx, r, desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			if m == nil {
+				return nil, distribution.ErrManifestBlobUnknown{Digest: desc.Digest}
+			}
+			return dbPayloadToManifest(m.Payload, m.MediaType, m.SchemaVersion)
+		}
+	} else {
+		manifests, err := repo.Manifests(ctx)
+		if err != nil {
+			return nil, err
+		}
+		src.exister = manifests
+		src.statter = repo.Blobs(ctx)
+		src.getManifest = func(ctx context.Context, desc distribution.Descriptor) (distribution.Manifest, error) {
+			exists, err := manifests.Exists(ctx, desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				return nil, distribution.ErrManifestBlobUnknown{Digest: desc.Digest}
+			}
+			// read from common storage instead of the repository manifest service to avoid emitting pull events
+			p, err := blobProvider.Get(ctx, desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			m, _, err := distribution.UnmarshalManifest(desc.MediaType, p)
+			if err != nil {
+				return nil, distribution.ErrManifestVerification{err}
+			}
+			return m, nil
+		}
+	}
+
+	src.getBlob = func(ctx context.Context, dgst digest.Digest) ([]byte, error) {
+		// make sure that the blob is linked to the repository before reading it from common storage
+		if _, err := src.statter.Stat(ctx, dgst); err != nil {
+			return nil, err
+		}
+		return blobProvider.Get(ctx, dgst)
+	}
+
+	return src, nil
+}
+
+// validate performs the validation deferred when the manifest was pushed. This includes the verification of all
+// references, which is done synchronously for other subjects, and the validation of the image configurations,
+// including whether they match the platforms declared in manifest lists.
+func (v *asyncManifestValidator) validate(ctx context.Context, job *asyncValidationJob) error {
+	src, err := v.app.newValidationSource(ctx, job.repository, job.blobProvider, job.useDatabase)
+	if err != nil {
+		return err
+	}
+
+	switch m := job.manifest.(type) {
+	case *schema2.DeserializedManifest:
+		if err := validation.NewSchema2Validator(src.exister, src.statter, false, v.app.manifestURLs).Validate(ctx, m); err != nil {
+			return err
+		}
+		return validateImageConfig(ctx, src, m.Config, nil)
+	case *ocischema.DeserializedManifest:
+		if err := validation.NewOCIValidator(src.exister, src.statter, false, v.app.manifestURLs).Validate(ctx, m); err != nil {
+			return err
+		}
+		return validateImageConfig(ctx, src, m.Config, nil)
+	case *manifestlist.DeserializedManifestList:
+		if err := validation.NewManifestListValidator(src.exister, false).Validate(ctx, m); err != nil {
+			return err
+		}
+		return validateManifestListPlatforms(ctx, src, m)
+	default:
+		return distribution.ErrManifestVerification{fmt.Errorf("unsupported manifest type %T", m)}
+	}
+}
+
+// validateManifestListPlatforms validates the image configuration of each image manifest referenced by a manifest
+// list, including whether it matches the platform declared in the list. Referenced manifest lists are not checked.
+func validateManifestListPlatforms(ctx context.Context, src *validationSource, m *manifestlist.DeserializedManifestList) error {
+	for _, desc := range m.Manifests {
+		child, err := src.getManifest(ctx, desc.Descriptor)
+		if err != nil {
+			return err
+		}
+
+		var platform *manifestlist.PlatformSpec
+		if desc.Platform.OS != "" || desc.Platform.Architecture != "" {
+			platform = &desc.Platform
+		}
+
+		switch c := child.(type) {
+		case *schema2.DeserializedManifest:
+			err = validateImageConfig(ctx, src, c.Config, platform)
+		case *ocischema.DeserializedManifest:
+			err = validateImageConfig(ctx, src, c.Config, platform)
+		}
+		if err != nil {
+			return fmt.Errorf("validating manifest %s: %w", desc.Digest, err)
+		}
+	}
+	return nil
+}
+
+// imageConfig holds the platform attributes of an image configuration.
+type imageConfig struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// validateImageConfig fetches and validates the configuration referenced by an image manifest. For configurations of
+// container images, both the OS and architecture must be set and, if declared, match the expected platform. Other
+// configuration types (e.g. for OCI artifacts) only need to be valid JSON.
+func validateImageConfig(ctx context.Context, src *validationSource, desc distribution.Descriptor, platform *manifestlist.PlatformSpec) error {
+	p, err := src.getBlob(ctx, desc.Digest)
+	if err != nil {
+		return err
+	}
+
+	var cfg imageConfig
+	if err := json.Unmarshal(p, &cfg); err != nil {
+		return fmt.Errorf("%w %s: %v", errImageConfigInvalid, desc.Digest, err)
+	}
+
+	if desc.MediaType != schema2.MediaTypeImageConfig && desc.MediaType != v1.MediaTypeImageConfig {
+		return nil
+	}
+	if cfg.OS == "" || cfg.Architecture == "" {
+		return fmt.Errorf("%w %s: missing os or architecture", errImageConfigInvalid, desc.Digest)
+	}
+
+	if platform != nil {
+		actual := manifestlist.PlatformSpec{OS: cfg.OS, Architecture: cfg.Architecture, Variant: cfg.Variant}
+		if !matchesPlatform(actual, *platform) {
+			return fmt.Errorf("%w %s: platform %s does not match the declared platform %s",
+				errImageConfigInvalid, desc.Digest, platformString(actual), platformString(*platform))
+		}
+	}
+
+	return nil
+}
+
+// quarantine removes the tag pushed along with an invalid manifest, as long as the tag still points to it. Returns
+// true if the tag was removed. The tag is deleted from the metadata database through the path shared by all tag
+// deletes, so tags of archived repositories are left untouched and errRepositoryArchived is returned.
+func (v *asyncManifestValidator) quarantine(ctx context.Context, job *asyncValidationJob) (bool, error) {
+	repoPath := job.repository.Named().Name()
+
+	// the tag must not be overwritten between the check below and its removal
+	unlock, err := v.app.lockTag(ctx, repoPath, job.tag)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	if job.useDatabase {
+		rStore := datastore.NewRepositoryStore(v.app.db)
+		r, err := rStore.FindByPath(ctx, repoPath)
+		if err != nil {
+			return false, err
+		}
+		if r == nil {
+			return false, nil
+		}
+		// checked upfront so that the tag is not removed from the filesystem metadata either
+		if r.Archived {
+			return false, errRepositoryArchived
+		}
+		t, err := rStore.FindTagByName(ctx, r, job.tag)
+		if err != nil {
+			return false, err
+		}
+		m, err := rStore.FindManifestByDigest(ctx, r, job.digest)
+		if err != nil {
+			return false, err
+		}
+		if t == nil || m == nil || t.ManifestID != m.ID {
+			return false, nil
+		}
+	}
+
+	tags := job.repository.Tags(ctx)
+	if job.writeFSMetadata {
+		desc, err := tags.Get(ctx, job.tag)
+		if err != nil {
+			var tagUnknownErr distribution.ErrTagUnknown
+			if errors.As(err, &tagUnknownErr) {
+				return false, nil
+			}
+			return false, err
+		}
+		if desc.Digest != job.digest {
+			return false, nil
+		}
+		if err := tags.Untag(ctx, job.tag); err != nil {
+			return false, err
+		}
+	}
+
+	if job.useDatabase {
+		if _, err := v.app.deleteTag(ctx, repoPath, job.tag, nil); err != nil {
+			var tagUnknownErr distribution.ErrTagUnknown
+			if errors.As(err, &tagUnknownErr) {
+				return false, nil
+			}
+			return false, err
+		}
+	}
+
+	return true, nil
+}
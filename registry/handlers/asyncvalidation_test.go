@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/docker/distribution/testutil"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+type quarantinedTag struct {
+	repo string
+	tag  string
+	dgst digest.Digest
+}
+
+type fakeQuarantineListener struct {
+	quarantined []quarantinedTag
+}
+
+// TagQuarantined implements notifications.QuarantineListener.
+func (l *fakeQuarantineListener) TagQuarantined(repo string, tag string, dgst digest.Digest) error {
+	l.quarantined = append(l.quarantined, quarantinedTag{repo: repo, tag: tag, dgst: dgst})
+	return nil
+}
+
+type asyncValidationTestEnv struct {
+	ctx          context.Context
+	repository   distribution.Repository
+	blobProvider distribution.BlobProvider
+	validator    *asyncManifestValidator
+	listener     *fakeQuarantineListener
+}
+
+func newAsyncValidationTestEnv(t *testing.T) *asyncValidationTestEnv {
+	t.Helper()
+
+	ctx := context.Background()
+	registry, err := storage.NewRegistry(ctx, inmemory.New(), storage.EnableDelete)
+	require.NoError(t, err)
+
+	named, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	repo, err := registry.Repository(ctx, named)
+	require.NoError(t, err)
+
+	bp, ok := registry.Blobs().(distribution.BlobProvider)
+	require.True(t, ok)
+
+	l := &fakeQuarantineListener{}
+	v := newAsyncManifestValidator(&App{}, configuration.AsyncManifestValidation{})
+	v.listener = l
+
+	return &asyncValidationTestEnv{
+		ctx:          ctx,
+		repository:   repo,
+		blobProvider: bp,
+		validator:    v,
+		listener:     l,
+	}
+}
+
+// pushImage pushes and tags a schema 2 manifest with the given image configuration. If missingLayer is true, the
+// manifest references a layer that does not exist.
+func (env *asyncValidationTestEnv) pushImage(t *testing.T, tag string, config string, missingLayer bool) (*schema2.DeserializedManifest, digest.Digest) {
+	t.Helper()
+
+	layers, err := testutil.CreateRandomLayers(1)
+	require.NoError(t, err)
+	require.NoError(t, testutil.UploadBlobs(env.repository, layers))
+
+	builder := schema2.NewManifestBuilder(env.repository.Blobs(env.ctx), schema2.MediaTypeImageConfig, []byte(config))
+	for dgst := range layers {
+		require.NoError(t, builder.AppendReference(distribution.Descriptor{Digest: dgst, MediaType: schema2.MediaTypeLayer}))
+	}
+	if missingLayer {
+		dgst := digest.FromString(t.Name())
+		require.NoError(t, builder.AppendReference(distribution.Descriptor{Digest: dgst, MediaType: schema2.MediaTypeLayer}))
+	}
+	m, err := builder.Build(env.ctx)
+	require.NoError(t, err)
+
+	ms, err := env.repository.Manifests(env.ctx, storage.SkipLayerVerification())
+	require.NoError(t, err)
+	dgst, err := ms.Put(env.ctx, m)
+	require.NoError(t, err)
+
+	require.NoError(t, env.repository.Tags(env.ctx).Tag(env.ctx, tag, distribution.Descriptor{Digest: dgst}))
+
+	dm, ok := m.(*schema2.DeserializedManifest)
+	require.True(t, ok)
+
+	return dm, dgst
+}
+
+func (env *asyncValidationTestEnv) job(m distribution.Manifest, dgst digest.Digest, tag string) *asyncValidationJob {
+	return &asyncValidationJob{
+		repository:      env.repository,
+		blobProvider:    env.blobProvider,
+		manifest:        m,
+		digest:          dgst,
+		tag:             tag,
+		writeFSMetadata: true,
+	}
+}
+
+func (env *asyncValidationTestEnv) tagExists(t *testing.T, tag string) bool {
+	t.Helper()
+
+	_, err := env.repository.Tags(env.ctx).Get(env.ctx, tag)
+	if err == nil {
+		return true
+	}
+	require.IsType(t, distribution.ErrTagUnknown{}, err)
+	return false
+}
+
+const validImageConfig = `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`
+
+func TestAsyncManifestValidator_Trusted(t *testing.T) {
+	v := newAsyncManifestValidator(&App{}, configuration.AsyncManifestValidation{TrustedSubjects: []string{"ci-builder"}})
+
+	require.False(t, v.trusted(context.Background()))
+	require.False(t, v.trusted(auth.WithUser(context.Background(), auth.UserInfo{Name: "john"})))
+	require.True(t, v.trusted(auth.WithUser(context.Background(), auth.UserInfo{Name: "ci-builder"})))
+}
+
+func TestAsyncManifestValidator_Reserve(t *testing.T) {
+	v := newAsyncManifestValidator(&App{}, configuration.AsyncManifestValidation{QueueSize: 1})
+
+	require.True(t, v.reserve())
+	require.False(t, v.reserve())
+	v.release()
+	require.True(t, v.reserve())
+}
+
+func TestAsyncManifestValidator_Defaults(t *testing.T) {
+	v := newAsyncManifestValidator(&App{}, configuration.AsyncManifestValidation{})
+
+	require.Equal(t, defaultAsyncValidationQueueSize, cap(v.queue))
+	require.Equal(t, defaultAsyncValidationQueueSize, cap(v.slots))
+	require.Equal(t, defaultAsyncValidationTimeout, v.timeout)
+}
+
+func TestAsyncManifestValidator_Process_Valid(t *testing.T) {
+	env := newAsyncValidationTestEnv(t)
+	m, dgst := env.pushImage(t, "latest", validImageConfig, false)
+
+	env.validator.process(env.ctx, env.job(m, dgst, "latest"))
+
+	require.True(t, env.tagExists(t, "latest"))
+	require.Empty(t, env.listener.quarantined)
+}
+
+func TestAsyncManifestValidator_Process_MissingLayer(t *testing.T) {
+	env := newAsyncValidationTestEnv(t)
+	m, dgst := env.pushImage(t, "latest", validImageConfig, true)
+
+	env.validator.process(env.ctx, env.job(m, dgst, "latest"))
+
+	require.False(t, env.tagExists(t, "latest"))
+	require.Equal(t, []quarantinedTag{{repo: "foo/bar", tag: "latest", dgst: dgst}}, env.listener.quarantined)
+}
+
+func TestAsyncManifestValidator_Process_InvalidConfig(t *testing.T) {
+	tt := []struct {
+		name   string
+		config string
+	}{
+		{name: "malformed", config: `{"architecture":`},
+		{name: "missing os", config: `{"architecture":"amd64"}`},
+		{name: "missing architecture", config: `{"os":"linux"}`},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			env := newAsyncValidationTestEnv(t)
+			m, dgst := env.pushImage(t, "latest", test.config, false)
+
+			env.validator.process(env.ctx, env.job(m, dgst, "latest"))
+
+			require.False(t, env.tagExists(t, "latest"))
+			require.Len(t, env.listener.quarantined, 1)
+		})
+	}
+}
+
+func TestAsyncManifestValidator_Process_TagMoved(t *testing.T) {
+	env := newAsyncValidationTestEnv(t)
+	m, dgst := env.pushImage(t, "latest", validImageConfig, true)
+	// the tag is overwritten with a valid manifest before the validation of the first one
+	env.pushImage(t, "latest", validImageConfig, false)
+
+	env.validator.process(env.ctx, env.job(m, dgst, "latest"))
+
+	require.True(t, env.tagExists(t, "latest"))
+	require.Empty(t, env.listener.quarantined)
+}
+
+// recordingTagLocker is a tagLocker that records the tags locked and whether they were released.
+type recordingTagLocker struct {
+	locked   []string
+	unlocked []string
+}
+
+// Lock implements tagLocker.
+func (l *recordingTagLocker) Lock(_ context.Context, repoPath, tag string) (func(), error) {
+	key := repoPath + ":" + tag
+	l.locked = append(l.locked, key)
+	return func() { l.unlocked = append(l.unlocked, key) }, nil
+}
+
+func TestAsyncManifestValidator_Process_LocksTag(t *testing.T) {
+	env := newAsyncValidationTestEnv(t)
+	locker := &recordingTagLocker{}
+	env.validator.app.tagLocks = locker
+	m, dgst := env.pushImage(t, "latest", validImageConfig, true)
+
+	env.validator.process(env.ctx, env.job(m, dgst, "latest"))
+
+	require.False(t, env.tagExists(t, "latest"))
+	require.Equal(t, []string{"foo/bar:latest"}, locker.locked)
+	require.Equal(t, []string{"foo/bar:latest"}, locker.unlocked)
+}
+
+func TestAsyncManifestValidator_Process_ManifestListPlatformMismatch(t *testing.T) {
+	env := newAsyncValidationTestEnv(t)
+	_, dgst := env.pushImage(t, "image", validImageConfig, false)
+
+	ml, err := manifestlist.FromDescriptors([]manifestlist.ManifestDescriptor{
+		{
+			Descriptor: distribution.Descriptor{Digest: dgst, MediaType: schema2.MediaTypeManifest},
+			// the image configuration declares linux/amd64
+			Platform: manifestlist.PlatformSpec{OS: "linux", Architecture: "arm64"},
+		},
+	})
+	require.NoError(t, err)
+
+	ms, err := env.repository.Manifests(env.ctx)
+	require.NoError(t, err)
+	mlDgst, err := ms.Put(env.ctx, ml)
+	require.NoError(t, err)
+	require.NoError(t, env.repository.Tags(env.ctx).Tag(env.ctx, "latest", distribution.Descriptor{Digest: mlDgst}))
+
+	env.validator.process(env.ctx, env.job(ml, mlDgst, "latest"))
+
+	require.False(t, env.tagExists(t, "latest"))
+	require.True(t, env.tagExists(t, "image"))
+	require.Equal(t, []quarantinedTag{{repo: "foo/bar", tag: "latest", dgst: mlDgst}}, env.listener.quarantined)
+}
+
+func TestAsyncManifestValidator_Process_ManifestListPlatformMatch(t *testing.T) {
+	env := newAsyncValidationTestEnv(t)
+	_, dgst := env.pushImage(t, "image", validImageConfig, false)
+
+	ml, err := manifestlist.FromDescriptors([]manifestlist.ManifestDescriptor{
+		{
+			Descriptor: distribution.Descriptor{Digest: dgst, MediaType: schema2.MediaTypeManifest},
+			Platform:   manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"},
+		},
+	})
+	require.NoError(t, err)
+
+	ms, err := env.repository.Manifests(env.ctx)
+	require.NoError(t, err)
+	mlDgst, err := ms.Put(env.ctx, ml)
+	require.NoError(t, err)
+	require.NoError(t, env.repository.Tags(env.ctx).Tag(env.ctx, "latest", distribution.Descriptor{Digest: mlDgst}))
+
+	env.validator.process(env.ctx, env.job(ml, mlDgst, "latest"))
+
+	require.True(t, env.tagExists(t, "latest"))
+	require.Empty(t, env.listener.quarantined)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/distribution/registry/audit"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// dbAuditSinkTimeout bounds the time spent persisting a single audit event.
+const dbAuditSinkTimeout = 5 * time.Second
+
+// dbAuditSink persists audit events to the metadata database, so that the history of a repository can be retrieved
+// through the repository events API.
+type dbAuditSink struct {
+	db *datastore.DB
+}
+
+func newDBAuditSink(db *datastore.DB) *dbAuditSink {
+	return &dbAuditSink{db: db}
+}
+
+// Write implements audit.Sink. Events for repositories that do not exist in the database are discarded.
+func (s *dbAuditSink) Write(event audit.Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbAuditSinkTimeout)
+	defer cancel()
+
+	r, err := datastore.NewRepositoryStore(s.db).FindByPath(ctx, event.Repository)
+	if err != nil {
+		return fmt.Errorf("%v: error finding repository: %w", s, err)
+	}
+	if r == nil {
+		return nil
+	}
+
+	e := &models.RepositoryEvent{
+		NamespaceID:    r.NamespaceID,
+		RepositoryID:   r.ID,
+		Action:         string(event.Action),
+		Actor:          event.Actor,
+		Digest:         event.Digest,
+		Tag:            event.Tag,
+		PreviousDigest: event.PreviousDigest,
+		UploadUUID:     event.UploadUUID,
+		RequestID:      event.RequestID,
+		CreatedAt:      event.Timestamp,
+	}
+	if err := datastore.NewRepositoryEventStore(s.db).Create(ctx, e); err != nil {
+		return fmt.Errorf("%v: %w", s, err)
+	}
+
+	return nil
+}
+
+// Close implements audit.Sink. The database connection is owned by the App, so it is not closed here.
+func (s *dbAuditSink) Close() error {
+	return nil
+}
+
+func (s *dbAuditSink) String() string {
+	return "auditDatabaseSink"
+}
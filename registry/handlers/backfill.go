@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	defaultBackfillMaxConcurrency = 5
+	// backfillTimeout bounds the duration of a single manifest backfill, which may include transferring blobs to the
+	// migration root directory.
+	backfillTimeout = 10 * time.Minute
+)
+
+// backfillFunc imports a single manifest of a repository from the filesystem into the database.
+type backfillFunc func(ctx context.Context, path string, dgst digest.Digest) error
+
+// manifestBackfiller asynchronously imports manifests read from the filesystem into the database during migration,
+// so that frequently pulled repositories converge to the database without waiting for a full import. Backfills are
+// best effort: reads are skipped if the same manifest is already being backfilled or if the concurrency limit was
+// reached, as any subsequent read will trigger a new attempt.
+type manifestBackfiller struct {
+	backfill backfillFunc
+	sem      chan struct{}
+
+	mu       sync.Mutex
+	inflight map[string]struct{}
+}
+
+func newManifestBackfiller(fn backfillFunc, maxConcurrency int) *manifestBackfiller {
+	if maxConcurrency < 1 {
+		maxConcurrency = defaultBackfillMaxConcurrency
+	}
+
+	return &manifestBackfiller{
+		backfill: fn,
+		sem:      make(chan struct{}, maxConcurrency),
+		inflight: make(map[string]struct{}),
+	}
+}
+
+// enqueue starts the backfill of the manifest with the given digest in the background. The returned channel is
+// closed once the backfill completes, or immediately if it was skipped.
+func (b *manifestBackfiller) enqueue(ctx context.Context, path string, dgst digest.Digest) <-chan struct{} {
+	done := make(chan struct{})
+	key := path + "@" + dgst.String()
+	log := dcontext.GetLoggerWithFields(ctx, map[interface{}]interface{}{
+		"repository": path,
+		"digest":     dgst,
+	})
+
+	b.mu.Lock()
+	if _, ok := b.inflight[key]; ok {
+		b.mu.Unlock()
+		close(done)
+		return done
+	}
+	select {
+	case b.sem <- struct{}{}:
+	default:
+		b.mu.Unlock()
+		log.Debug("skipping manifest backfill, concurrency limit reached")
+		close(done)
+		return done
+	}
+	b.inflight[key] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		defer func() {
+			b.mu.Lock()
+			delete(b.inflight, key)
+			b.mu.Unlock()
+			<-b.sem
+		}()
+
+		// the request context is canceled as soon as the response is sent
+		bctx, cancel := context.WithTimeout(dcontext.WithLogger(context.Background(), log), backfillTimeout)
+		defer cancel()
+
+		start := time.Now()
+		if err := b.backfill(bctx, path, dgst); err != nil {
+			log.WithError(err).Error("failed to backfill manifest")
+			return
+		}
+		log.WithField("duration_s", time.Since(start).Seconds()).Info("manifest backfilled")
+	}()
+
+	return done
+}
+
+// inFlight returns the number of manifests being backfilled.
+func (b *manifestBackfiller) inFlight() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.inflight)
+}
+
+func (app *App) configureBackfill(config *configuration.Configuration) {
+	cfg := config.Migration.Backfill
+	if !config.Migration.Enabled || !cfg.Enabled {
+		return
+	}
+	if app.db == nil {
+		panic("migration backfill: requires the metadata database to be enabled")
+	}
+
+	// repositories migrated to the database use a distinct root directory, so blobs have to be copied there
+	var opts []datastore.ImporterOption
+	if distinctMigrationRootDirectory(config) {
+		bts, err := storage.NewBlobTransferService(app.driver, app.migrationDriver)
+		if err != nil {
+			panic(fmt.Sprintf("migration backfill: %v", err))
+		}
+		opts = append(opts, datastore.WithBlobTransferService(bts))
+	}
+
+	registry := app.registry
+	fn := func(ctx context.Context, path string, dgst digest.Digest) error {
+		// importers are stateful, so each backfill gets its own
+		return datastore.NewImporter(app.db, registry, opts...).PreImportManifest(ctx, path, dgst)
+	}
+
+	app.manifestBackfiller = newManifestBackfiller(fn, cfg.MaxConcurrency)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestBackfiller(t *testing.T) {
+	dgst := digest.FromString("foo")
+
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	release := make(chan struct{})
+	fn := func(ctx context.Context, path string, d digest.Digest) error {
+		mu.Lock()
+		calls = append(calls, path+"@"+d.String())
+		mu.Unlock()
+		<-release
+		return nil
+	}
+
+	b := newManifestBackfiller(fn, 2)
+	ctx := context.Background()
+
+	first := b.enqueue(ctx, "foo/bar", dgst)
+	// already in flight, skipped
+	dup := b.enqueue(ctx, "foo/bar", dgst)
+	second := b.enqueue(ctx, "foo/baz", dgst)
+	// concurrency limit reached, skipped
+	limited := b.enqueue(ctx, "foo/qux", dgst)
+
+	for _, c := range []<-chan struct{}{dup, limited} {
+		select {
+		case <-c:
+		case <-time.After(time.Second):
+			t.Fatal("skipped backfill should complete immediately")
+		}
+	}
+
+	close(release)
+	<-first
+	<-second
+
+	require.ElementsMatch(t, []string{"foo/bar@" + dgst.String(), "foo/baz@" + dgst.String()}, calls)
+
+	// slots and in-flight keys are released once done, even on failure
+	b.backfill = func(ctx context.Context, path string, d digest.Digest) error {
+		mu.Lock()
+		calls = append(calls, path+"@"+d.String())
+		mu.Unlock()
+		return errors.New("failed")
+	}
+	<-b.enqueue(ctx, "foo/bar", dgst)
+	<-b.enqueue(ctx, "foo/bar", dgst)
+	require.Len(t, calls, 4)
+}
+
+func TestNewManifestBackfiller_DefaultMaxConcurrency(t *testing.T) {
+	b := newManifestBackfiller(nil, 0)
+	require.Equal(t, defaultBackfillMaxConcurrency, cap(b.sem))
+}
@@ -10,10 +10,12 @@ import (
 	dcontext "github.com/docker/distribution/context"
 
 	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/registry/api/errcode"
 	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
 	"github.com/gorilla/handlers"
 	"github.com/opencontainers/go-digest"
 )
@@ -57,38 +59,33 @@ type blobHandler struct {
 	Digest digest.Digest
 }
 
-func dbGetBlob(ctx context.Context, db datastore.Queryer, repoPath string, dgst digest.Digest) error {
+func dbGetBlob(ctx context.Context, db datastore.Queryer, repoPath string, dgst digest.Digest) (*models.Blob, error) {
 	log := dcontext.GetLoggerWithFields(ctx, map[interface{}]interface{}{"repository": repoPath, "digest": dgst})
 	log.Debug("finding blob in database")
 
 	rStore := datastore.NewRepositoryStore(db)
 	r, err := rStore.FindByPath(ctx, repoPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if r == nil {
 		log.Warn("repository not found in database")
-		return v2.ErrorCodeBlobUnknown.WithDetail(dgst)
+		return nil, v2.ErrorCodeBlobUnknown.WithDetail(dgst)
 	}
 
 	bb, err := rStore.Blobs(ctx, r)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var found bool
 	for _, b := range bb {
 		if b.Digest == dgst {
-			found = true
-			break
+			return b, nil
 		}
 	}
-	if !found {
-		log.Warn("blob link not found in database")
-		return v2.ErrorCodeBlobUnknown.WithDetail(dgst)
-	}
+	log.Warn("blob link not found in database")
 
-	return nil
+	return nil, v2.ErrorCodeBlobUnknown.WithDetail(dgst)
 }
 
 // GetBlob fetches the binary data from backend storage returns it in the
@@ -100,11 +97,21 @@ func (bh *blobHandler) GetBlob(w http.ResponseWriter, r *http.Request) {
 	blobs := bh.Repository.Blobs(bh)
 
 	if bh.useDatabase {
-		if err := dbGetBlob(bh.Context, bh.db, bh.Repository.Named().Name(), bh.Digest); err != nil {
+		b, err := dbGetBlob(bh.Context, bh.db, bh.Repository.Named().Name(), bh.Digest)
+		if err != nil {
 			bh.Errors = append(bh.Errors, errcode.FromUnknownError(err))
 			return
 		}
 
+		// only downloads are verified, as these are the requests for which the content may be served by the storage
+		// backend without going through the registry
+		if r.Method == http.MethodGet && bh.App.blobNeedsVerification(b) {
+			if err := verifyBlob(bh, bh.db, bh.storageDriver, b); err != nil {
+				bh.Errors = append(bh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+				return
+			}
+		}
+
 		dgst = bh.Digest
 	} else {
 		desc, err := blobs.Stat(bh, bh.Digest)
@@ -169,21 +176,33 @@ func deleteEnabled(config *configuration.Configuration) bool {
 	return false
 }
 
+// untagEnabled returns whether manifest deletes by tag reference should untag the manifest. This is disabled by
+// default, as deletes by tag are not part of the Docker registry API.
+func untagEnabled(config *configuration.Configuration) bool {
+	if d, ok := config.Storage["delete"]; ok {
+		if untag, ok := d["untag"].(bool); ok {
+			return untag
+		}
+	}
+	return false
+}
+
 // DeleteBlob deletes a layer blob
 func (bh *blobHandler) DeleteBlob(w http.ResponseWriter, r *http.Request) {
 	dcontext.GetLogger(bh).Debug("DeleteBlob")
 
 	err := bh.deleteBlob()
 	if err != nil {
-		switch err {
-		case distribution.ErrUnsupported:
+		var repoErr distribution.ErrRepositoryUnknown
+		switch {
+		case errors.Is(err, distribution.ErrUnsupported):
 			bh.Errors = append(bh.Errors, errcode.ErrorCodeUnsupported)
 			return
-		case distribution.ErrBlobUnknown:
-			bh.Errors = append(bh.Errors, v2.ErrorCodeBlobUnknown)
+		case errors.Is(err, distribution.ErrBlobUnknown):
+			bh.Errors = append(bh.Errors, v2.ErrorCodeBlobUnknown.WithDetail(bh.Digest))
 			return
-		case distribution.ErrRepositoryUnknown{Name: bh.Repository.Named().Name()}:
-			bh.Errors = append(bh.Errors, v2.ErrorCodeNameUnknown)
+		case errors.As(err, &repoErr):
+			bh.Errors = append(bh.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": repoErr.Name}))
 			return
 		default:
 			bh.Errors = append(bh.Errors, errcode.FromUnknownError(err))
@@ -194,6 +213,8 @@ func (bh *blobHandler) DeleteBlob(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Length", "0")
 	w.WriteHeader(http.StatusAccepted)
+
+	bh.auditLog(bh.Context, r, audit.Event{Action: audit.ActionBlobDelete, Digest: bh.Digest})
 }
 
 func (bh *blobHandler) deleteBlob() error {
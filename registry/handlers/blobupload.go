@@ -2,15 +2,21 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/docker/distribution"
 	dcontext "github.com/docker/distribution/context"
 	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/api/errcode"
 	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
 	"github.com/docker/distribution/registry/datastore"
 	"github.com/docker/distribution/registry/datastore/models"
 	"github.com/docker/distribution/registry/storage"
@@ -97,6 +103,11 @@ func dbMountBlob(ctx context.Context, db datastore.Queryer, fromRepoPath, toRepo
 // StartBlobUpload begins the blob upload process and allocates a server-side
 // blob writer session, optionally mounting the blob from a separate repository.
 func (buh *blobUploadHandler) StartBlobUpload(w http.ResponseWriter, r *http.Request) {
+	if err := buh.repositoryNames.Validate(buh.Repository.Named().Name()); err != nil {
+		buh.Errors = append(buh.Errors, v2.ErrorCodeNameInvalid.WithDetail(err))
+		return
+	}
+
 	var options []distribution.BlobCreateOption
 
 	fromRepo := r.FormValue("from")
@@ -109,6 +120,11 @@ func (buh *blobUploadHandler) StartBlobUpload(w http.ResponseWriter, r *http.Req
 		}
 	}
 
+	if err := buh.applyUploadLimits(); err != nil {
+		buh.Errors = append(buh.Errors, err)
+		return
+	}
+
 	blobs := buh.Repository.Blobs(buh)
 	upload, err := blobs.Create(buh, options...)
 
@@ -123,7 +139,10 @@ func (buh *blobUploadHandler) StartBlobUpload(w http.ResponseWriter, r *http.Req
 			}
 			if err = buh.writeBlobCreatedHeaders(w, ebm.Descriptor); err != nil {
 				buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+				return
 			}
+			buh.auditLog(buh.Context, r, audit.Event{Action: audit.ActionBlobMount, Digest: ebm.Descriptor.Digest})
+			buh.queueReplication(buh.Context, models.ReplicationArtifactBlob, ebm.Descriptor.Digest, "")
 		} else if err == distribution.ErrUnsupported {
 			buh.Errors = append(buh.Errors, errcode.ErrorCodeUnsupported)
 		} else {
@@ -134,6 +153,14 @@ func (buh *blobUploadHandler) StartBlobUpload(w http.ResponseWriter, r *http.Req
 
 	buh.Upload = upload
 
+	if buh.tracksUploadsInDatabase() {
+		if err := dbCreateBlobUpload(buh.Context, buh.db, buh.Repository.Named().Name(), upload); err != nil {
+			e := fmt.Errorf("failed to record blob upload in database: %w", err)
+			buh.Errors = append(buh.Errors, errcode.FromUnknownError(e))
+			return
+		}
+	}
+
 	if err := buh.blobUploadResponse(w, r, true); err != nil {
 		buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
 		return
@@ -143,7 +170,26 @@ func (buh *blobUploadHandler) StartBlobUpload(w http.ResponseWriter, r *http.Req
 	w.WriteHeader(http.StatusAccepted)
 }
 
-// GetUploadStatus returns the status of a given upload, identified by id.
+// blobUploadProgressAPIResponse is the JSON representation of the progress of a blob upload.
+type blobUploadProgressAPIResponse struct {
+	UUID           string    `json:"uuid"`
+	BytesReceived  int64     `json:"bytes_received"`
+	StartedAt      time.Time `json:"started_at"`
+	LastActivityAt time.Time `json:"last_activity_at"`
+}
+
+func newBlobUploadProgressAPIResponse(info *storage.UploadInfo) blobUploadProgressAPIResponse {
+	return blobUploadProgressAPIResponse{
+		UUID:           info.ID,
+		BytesReceived:  info.Size,
+		StartedAt:      info.StartedAt.UTC(),
+		LastActivityAt: info.LastActivityAt.UTC(),
+	}
+}
+
+// GetUploadStatus returns the status of a given upload, identified by id. For GET requests that accept
+// application/json, the progress of the upload is also returned in the response body, with a 200 status code instead
+// of 204.
 func (buh *blobUploadHandler) GetUploadStatus(w http.ResponseWriter, r *http.Request) {
 	if buh.Upload == nil {
 		buh.Errors = append(buh.Errors, v2.ErrorCodeBlobUploadUnknown)
@@ -159,7 +205,26 @@ func (buh *blobUploadHandler) GetUploadStatus(w http.ResponseWriter, r *http.Req
 	}
 
 	w.Header().Set("Docker-Upload-UUID", buh.UUID)
-	w.WriteHeader(http.StatusNoContent)
+
+	if r.Method != http.MethodGet || !accepts(r, "application/json") {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	info, err := storage.StatUpload(buh, buh.storageDriver, buh.Repository.Named().Name(), buh.Upload.ID())
+	if err != nil {
+		buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+	// the size reported by the upload is authoritative, as it is the one used for the Range header
+	info.Size = buh.uploadSize()
+
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(newBlobUploadProgressAPIResponse(info)); err != nil {
+		dcontext.GetLogger(buh).WithError(err).Error("error encoding upload progress")
+	}
 }
 
 // PatchBlobData writes data to an upload.
@@ -176,10 +241,13 @@ func (buh *blobUploadHandler) PatchBlobData(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// TODO(dmcgowan): support Content-Range header to seek and write range
+	if err := buh.validateContentRange(r); err != nil {
+		buh.rejectOutOfOrderChunk(w, r, err)
+		return
+	}
 
-	if err := copyFullPayload(buh, w, r, buh.Upload, -1, "blob PATCH"); err != nil {
-		buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err.Error()))
+	if err := buh.copyChunk(w, r, "blob PATCH"); err != nil {
+		buh.Errors = append(buh.Errors, err)
 		return
 	}
 
@@ -254,8 +322,14 @@ func (buh *blobUploadHandler) PutBlobUploadComplete(w http.ResponseWriter, r *ht
 		return
 	}
 
-	if err := copyFullPayload(buh, w, r, buh.Upload, -1, "blob PUT"); err != nil {
-		buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err.Error()))
+	// The final chunk may be sent along with the completion request, in which case it can include a Content-Range.
+	if err := buh.validateContentRange(r); err != nil {
+		buh.rejectOutOfOrderChunk(w, r, err)
+		return
+	}
+
+	if err := buh.copyChunk(w, r, "blob PUT"); err != nil {
+		buh.Errors = append(buh.Errors, err)
 		return
 	}
 
@@ -270,8 +344,12 @@ func (buh *blobUploadHandler) PutBlobUploadComplete(w http.ResponseWriter, r *ht
 	log := dcontext.GetLogger(buh)
 	if err != nil {
 		switch err := err.(type) {
+		case distribution.ErrBlobUploadIncomplete:
+			buh.Errors = append(buh.Errors, v2.ErrorCodeBlobUploadInvalid.WithDetail(err.Error()))
 		case distribution.ErrBlobInvalidDigest:
-			buh.Errors = append(buh.Errors, v2.ErrorCodeDigestInvalid.WithDetail(err))
+			detail := buh.digestMismatchDetail(err)
+			log.WithFields(detail).Warn("blob upload digest mismatch")
+			buh.Errors = append(buh.Errors, v2.ErrorCodeDigestInvalid.WithDetail(detail))
 		case errcode.Error:
 			buh.Errors = append(buh.Errors, err)
 		default:
@@ -316,6 +394,51 @@ func (buh *blobUploadHandler) PutBlobUploadComplete(w http.ResponseWriter, r *ht
 		"size_bytes": desc.Size,
 		"digest":     desc.Digest,
 	}).Info("blob uploaded")
+
+	buh.deleteUploadState()
+	buh.deleteDBUpload()
+	buh.auditLog(buh.Context, r, audit.Event{Action: audit.ActionBlobPush, Digest: desc.Digest, UploadUUID: buh.UUID})
+	buh.queueReplication(buh.Context, models.ReplicationArtifactBlob, desc.Digest, "")
+}
+
+// validateContentRange checks the optional Content-Range header of a chunk upload request. The range must start at
+// the current upload offset, or ahead of it if parallel chunks are enabled, and, if a Content-Length is provided, its
+// size must match the request body length.
+func (buh *blobUploadHandler) validateContentRange(r *http.Request) error {
+	cr := r.Header.Get("Content-Range")
+	if cr == "" {
+		return nil
+	}
+
+	start, end, err := parseContentRange(cr)
+	if err != nil {
+		return v2.ErrorCodeRangeInvalid.WithDetail(err.Error())
+	}
+	if start != buh.Upload.Size() && !(start > buh.Upload.Size() && buh.App.parallelChunks) {
+		return v2.ErrorCodeRangeInvalid.WithDetail(fmt.Sprintf("range start %d does not match upload offset %d", start, buh.Upload.Size()))
+	}
+	if r.ContentLength >= 0 && r.ContentLength != end-start+1 {
+		return v2.ErrorCodeSizeInvalid.WithDetail(fmt.Sprintf("range length %d does not match content length %d", end-start+1, r.ContentLength))
+	}
+
+	return nil
+}
+
+// rejectOutOfOrderChunk fails the request with err. If strict chunk ordering is enabled and err is a range error, the
+// response also includes the location and range of the upload, allowing clients to resume from the current offset.
+func (buh *blobUploadHandler) rejectOutOfOrderChunk(w http.ResponseWriter, r *http.Request, err error) {
+	buh.Errors = append(buh.Errors, err)
+
+	var ec errcode.Error
+	if !buh.App.strictChunkOrdering || !errors.As(err, &ec) || ec.Code != v2.ErrorCodeRangeInvalid {
+		return
+	}
+	if err := buh.blobUploadResponse(w, r, false); err != nil {
+		dcontext.GetLogger(buh).WithError(err).Warn("failed to build upload recovery headers")
+		return
+	}
+	// the response body holds the error
+	w.Header().Del("Content-Length")
 }
 
 // CancelBlobUpload cancels an in-progress upload of a blob.
@@ -329,13 +452,136 @@ func (buh *blobUploadHandler) CancelBlobUpload(w http.ResponseWriter, r *http.Re
 	if err := buh.Upload.Cancel(buh); err != nil {
 		dcontext.GetLogger(buh).Errorf("error encountered canceling upload: %v", err)
 		buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+	} else {
+		buh.deleteUploadState()
+		buh.deleteDBUpload()
+		buh.auditLog(buh.Context, r, audit.Event{Action: audit.ActionUploadCancel, UploadUUID: buh.UUID})
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// chunkDigestHeader is an optional request header through which clients can provide the digest of each uploaded chunk,
+// which allows pinpointing where the content got corrupted if the digest of the whole blob turns out to be invalid.
+const chunkDigestHeader = "Docker-Chunk-Digest"
+
+// copyChunk copies the chunk in the body of r to the current upload, or to a part of it if the chunk was sent ahead of
+// the upload offset. If the client provided a chunk digest and it does not match the received content, the chunk
+// offset is recorded in the upload state, unless a previous chunk already failed to match.
+func (buh *blobUploadHandler) copyChunk(w http.ResponseWriter, r *http.Request, action string) error {
+	if offset, ok := buh.partOffset(r); ok {
+		return buh.copyPart(w, r, offset, action)
+	}
+
+	return buh.copyChunkTo(w, r, buh.Upload, buh.Upload.Size(), action)
+}
+
+// partOffset returns the start of the Content-Range of r if it is ahead of the current upload offset, in which case
+// the chunk is a part of the upload sent out of order. The Content-Range must have been validated beforehand.
+func (buh *blobUploadHandler) partOffset(r *http.Request) (int64, bool) {
+	cr := r.Header.Get("Content-Range")
+	if !buh.App.parallelChunks || cr == "" {
+		return 0, false
+	}
+
+	start, _, err := parseContentRange(cr)
+	if err != nil || start <= buh.Upload.Size() {
+		return 0, false
+	}
+
+	return start, true
+}
+
+// copyPart copies the chunk in the body of r to the part of the current upload starting at offset. Parts are
+// assembled when the upload is committed.
+func (buh *blobUploadHandler) copyPart(w http.ResponseWriter, r *http.Request, offset int64, action string) error {
+	pw, ok := buh.Upload.(distribution.BlobPartWriter)
+	if !ok {
+		return errcode.ErrorCodeUnsupported.WithDetail("out of order chunks are not supported by this upload")
+	}
+
+	pr, dst := io.Pipe()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := pw.WritePart(buh, offset, pr)
+		// unblock the copy if the part could not be written
+		pr.CloseWithError(err)
+		errc <- err
+	}()
+
+	err := buh.copyChunkTo(w, r, dst, offset, action)
+	dst.CloseWithError(err)
+	if werr := <-errc; werr != nil && err == nil {
+		dcontext.GetLogger(buh).WithError(werr).Error("error writing blob upload part")
+		err = errcode.ErrorCodeUnknown.WithDetail(werr.Error())
+	}
+
+	return err
+}
+
+// copyChunkTo copies the chunk in the body of r to upload, in which the chunk starts at offset.
+func (buh *blobUploadHandler) copyChunkTo(w http.ResponseWriter, r *http.Request, upload io.Writer, offset int64, action string) error {
+	dst := upload
+
+	var verifier digest.Verifier
+	if v := r.Header.Get(chunkDigestHeader); v != "" {
+		dgst, err := digest.Parse(v)
+		if err != nil {
+			return v2.ErrorCodeDigestInvalid.WithDetail(fmt.Sprintf("invalid %s header: %v", chunkDigestHeader, err))
+		}
+		verifier = dgst.Verifier()
+		dst = io.MultiWriter(upload, verifier)
+	}
+
+	if err := copyFullPayload(buh, w, r, dst, -1, action); err != nil {
+		if errors.Is(err, errUploadTooSlow) {
+			return errcode.ErrorCodeRequestTimeout.WithDetail(err.Error())
+		}
+		return errcode.ErrorCodeUnknown.WithDetail(err.Error())
+	}
+
+	if verifier != nil && !verifier.Verified() {
+		dcontext.GetLoggerWithFields(buh, map[interface{}]interface{}{
+			"chunk_digest": r.Header.Get(chunkDigestHeader),
+			"chunk_offset": offset,
+		}).Warn("blob upload chunk does not match chunk digest")
+
+		if buh.State.MismatchedChunkOffset == nil {
+			buh.State.MismatchedChunkOffset = &offset
+		}
+	}
+
+	return nil
+}
+
+// digestMismatchDetail describes a blob upload digest mismatch, to help clients and operators distinguish between
+// truncated and corrupted uploads.
+func (buh *blobUploadHandler) digestMismatchDetail(err distribution.ErrBlobInvalidDigest) logrus.Fields {
+	detail := logrus.Fields{
+		"reason":          err.Reason.Error(),
+		"expected_digest": err.Digest.String(),
+		"computed_digest": err.Computed.String(),
+		"received_bytes":  err.Size,
+	}
+	if off := buh.State.MismatchedChunkOffset; off != nil {
+		detail["mismatched_chunk_offset"] = *off
+	}
+
+	return detail
+}
+
 func (buh *blobUploadHandler) ResumeBlobUpload(ctx *Context, r *http.Request) http.Handler {
-	state, err := hmacKey(ctx.Config.HTTP.Secret).unpackUploadState(r.FormValue("_state"))
+	token := r.FormValue("_state")
+	state, err := hmacKey(ctx.Config.HTTP.Secret).unpackUploadState(token)
+	if err != nil && ctx.uploadStates != nil {
+		// The upload may have been started by an instance configured with a different HTTP secret. If the upload
+		// state is persisted, it can be resumed regardless, but only with the token last issued for it. Nothing is
+		// read from the unverified token itself, the state is looked up by the UUID in the request path.
+		if s := buh.storedUploadState(token); s != nil {
+			dcontext.GetLogger(ctx).WithError(err).Info("invalid upload state token, resuming upload from persisted state")
+			state, err = *s, nil
+		}
+	}
 	if err != nil {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			dcontext.GetLogger(ctx).Infof("error resolving upload: %v", err)
@@ -375,6 +621,25 @@ func (buh *blobUploadHandler) ResumeBlobUpload(ctx *Context, r *http.Request) ht
 	buh.Upload = upload
 
 	if size := upload.Size(); size != buh.State.Offset {
+		if ctx.App.parallelChunks {
+			// The offset of an upload location is out of date as soon as a concurrent chunk is written, so only chunks
+			// without a Content-Range rely on it. The content is validated when the upload is completed regardless.
+			unpositioned := (r.Method == http.MethodPatch || (r.Method == http.MethodPut && r.ContentLength != 0)) &&
+				r.Header.Get("Content-Range") == ""
+			if !unpositioned {
+				return nil
+			}
+		}
+		if ctx.App.strictChunkOrdering {
+			// only requests carrying a chunk are out of order, status checks and cancellations can proceed
+			if r.Method != http.MethodPatch && r.Method != http.MethodPut {
+				return nil
+			}
+			dcontext.GetLogger(ctx).Infof("upload resumed at out of order offset: %d != %d", size, buh.State.Offset)
+			return closeResources(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				buh.rejectOutOfOrderChunk(w, r, v2.ErrorCodeRangeInvalid.WithDetail(fmt.Sprintf("chunk offset %d does not match upload offset %d", buh.State.Offset, size)))
+			}), upload)
+		}
 		defer upload.Close()
 		dcontext.GetLogger(ctx).Errorf("upload resumed at wrong offset: %d != %d", size, buh.State.Offset)
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -385,6 +650,89 @@ func (buh *blobUploadHandler) ResumeBlobUpload(ctx *Context, r *http.Request) ht
 	return nil
 }
 
+// storedUploadState returns the persisted state of the current upload, or nil if not found or if token is not the
+// upload state token last issued for it. Errors are logged and treated as if the state was not found, as persisted
+// states are only a fallback.
+func (buh *blobUploadHandler) storedUploadState(token string) *blobUploadState {
+	state, err := buh.uploadStates.Get(buh, buh.UUID, token)
+	if err != nil {
+		dcontext.GetLogger(buh).WithError(err).Warn("failed to get persisted upload state")
+		return nil
+	}
+
+	return state
+}
+
+// persistUploadState saves the current upload state along with the token issued for it, if upload state persistence
+// is enabled. Errors are logged but not returned, as clients can still resume the upload on instances that share the
+// same HTTP secret.
+func (buh *blobUploadHandler) persistUploadState(token string) {
+	if buh.uploadStates == nil {
+		return
+	}
+	if err := buh.uploadStates.Set(buh, buh.State, token); err != nil {
+		dcontext.GetLogger(buh).WithError(err).Warn("failed to persist upload state")
+	}
+}
+
+// deleteUploadState removes the persisted state of a completed or canceled upload, if upload state persistence is
+// enabled.
+func (buh *blobUploadHandler) deleteUploadState() {
+	if buh.uploadStates == nil {
+		return
+	}
+	if err := buh.uploadStates.Delete(buh, buh.UUID); err != nil {
+		dcontext.GetLogger(buh).WithError(err).Warn("failed to delete persisted upload state")
+	}
+}
+
+// tracksUploadsInDatabase reports whether upload sessions are recorded in the metadata database, which is the case
+// when using the database outside of a migration. Stale uploads are then purged based on these records.
+func (ctx *Context) tracksUploadsInDatabase() bool {
+	return ctx.useDatabase && !ctx.App.Config.Migration.Enabled
+}
+
+// dbCreateBlobUpload records the start of upload u for the repository at repoPath.
+func dbCreateBlobUpload(ctx context.Context, db datastore.Queryer, repoPath string, u distribution.BlobWriter) error {
+	return datastore.NewBlobUploadStore(db).Create(ctx, &models.BlobUpload{
+		ID:             u.ID(),
+		RepositoryPath: repoPath,
+		StartedAt:      u.StartedAt(),
+	})
+}
+
+// deleteDBUpload removes the metadata database record of a completed or canceled upload. Failures are only logged, as
+// the upload purger takes care of records left behind.
+func (buh *blobUploadHandler) deleteDBUpload() {
+	if !buh.tracksUploadsInDatabase() {
+		return
+	}
+	if err := datastore.NewBlobUploadStore(buh.db).Delete(buh, buh.UUID); err != nil && !errors.Is(err, datastore.ErrBlobUploadNotFound) {
+		dcontext.GetLogger(buh).WithError(err).Warn("failed to delete blob upload from database")
+	}
+}
+
+// uploadSize returns the size of the contiguous content received for the current upload. When parallel chunks are
+// enabled, this includes the parts received out of order that extend the sequentially written content, otherwise it is
+// the size of the latter. Failures to list the parts are logged and only the sequentially written content is reported.
+func (buh *blobUploadHandler) uploadSize() int64 {
+	if !buh.App.parallelChunks {
+		return buh.Upload.Size()
+	}
+	pw, ok := buh.Upload.(distribution.BlobPartWriter)
+	if !ok {
+		return buh.Upload.Size()
+	}
+
+	size, err := pw.ContiguousSize(buh)
+	if err != nil {
+		dcontext.GetLogger(buh).WithError(err).Warn("failed to get contiguous upload size")
+		return buh.Upload.Size()
+	}
+
+	return size
+}
+
 // blobUploadResponse provides a standard request for uploading blobs and
 // chunk responses. This sets the correct headers but the response status is
 // left to the caller. The fresh argument is used to ensure that new blob
@@ -403,6 +751,7 @@ func (buh *blobUploadHandler) blobUploadResponse(w http.ResponseWriter, r *http.
 		dcontext.GetLogger(buh).Infof("error building upload state token: %s", err)
 		return err
 	}
+	buh.persistUploadState(token)
 
 	uploadURL, err := buh.urlBuilder.BuildBlobUploadChunkURL(
 		buh.Repository.Named(), buh.Upload.ID(),
@@ -414,7 +763,7 @@ func (buh *blobUploadHandler) blobUploadResponse(w http.ResponseWriter, r *http.
 		return err
 	}
 
-	endRange := buh.Upload.Size()
+	endRange := buh.uploadSize()
 	if endRange > 0 {
 		endRange = endRange - 1
 	}
@@ -424,6 +773,9 @@ func (buh *blobUploadHandler) blobUploadResponse(w http.ResponseWriter, r *http.
 
 	w.Header().Set("Content-Length", "0")
 	w.Header().Set("Range", fmt.Sprintf("0-%d", endRange))
+	if buh.App.minChunkLength > 0 {
+		w.Header().Set("OCI-Chunk-Min-Length", strconv.FormatInt(buh.App.minChunkLength, 10))
+	}
 
 	return nil
 }
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/factory"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/stretchr/testify/require"
+)
+
+const writerTrackingDriverName = "writertracking"
+
+func init() {
+	factory.Register(writerTrackingDriverName, writerTrackingDriverFactory{})
+}
+
+// openWriters is the number of writers opened by writerTrackingDriver instances that were not closed, committed or
+// canceled yet.
+var openWriters int64
+
+type writerTrackingDriverFactory struct{}
+
+func (writerTrackingDriverFactory) Create(map[string]interface{}) (storagedriver.StorageDriver, error) {
+	return &writerTrackingDriver{StorageDriver: inmemory.New()}, nil
+}
+
+// writerTrackingDriver is an in-memory storage driver that keeps track of open writers, to detect leaks.
+type writerTrackingDriver struct {
+	storagedriver.StorageDriver
+}
+
+func (d *writerTrackingDriver) Writer(ctx context.Context, path string, append bool) (storagedriver.FileWriter, error) {
+	fw, err := d.StorageDriver.Writer(ctx, path, append)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&openWriters, 1)
+
+	return &trackedFileWriter{FileWriter: fw}, nil
+}
+
+type trackedFileWriter struct {
+	storagedriver.FileWriter
+	once sync.Once
+}
+
+func (w *trackedFileWriter) release() {
+	w.once.Do(func() { atomic.AddInt64(&openWriters, -1) })
+}
+
+func (w *trackedFileWriter) Close() error {
+	w.release()
+	return w.FileWriter.Close()
+}
+
+func (w *trackedFileWriter) Commit() error {
+	w.release()
+	return w.FileWriter.Commit()
+}
+
+func (w *trackedFileWriter) Cancel() error {
+	w.release()
+	return w.FileWriter.Cancel()
+}
+
+// memoryUploadStateStore is an in-memory uploadStateStore for tests.
+type memoryUploadStateStore struct {
+	mu     sync.Mutex
+	states map[string]storedUploadState
+}
+
+func newMemoryUploadStateStore() *memoryUploadStateStore {
+	return &memoryUploadStateStore{states: make(map[string]storedUploadState)}
+}
+
+func (s *memoryUploadStateStore) Get(_ context.Context, uuid, token string) (*blobUploadState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.states[uuid]
+	if !ok || stored.TokenHash != uploadStateTokenHash(token) {
+		return nil, nil
+	}
+	return &stored.State, nil
+}
+
+func (s *memoryUploadStateStore) Set(_ context.Context, state blobUploadState, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[state.UUID] = storedUploadState{State: state, TokenHash: uploadStateTokenHash(token)}
+	return nil
+}
+
+func (s *memoryUploadStateStore) Delete(_ context.Context, uuid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, uuid)
+	return nil
+}
+
+func newUploadTestApp(t *testing.T, configure func(*configuration.Configuration)) (*App, *httptest.Server) {
+	t.Helper()
+
+	config := &configuration.Configuration{
+		Storage: configuration.Storage{
+			writerTrackingDriverName: nil,
+			"maintenance": configuration.Parameters{"uploadpurging": map[interface{}]interface{}{
+				"enabled": false,
+			}},
+		},
+	}
+	config.HTTP.Secret = "secret-a"
+	if configure != nil {
+		configure(config)
+	}
+
+	app := NewApp(context.Background(), config)
+	server := httptest.NewServer(app)
+	t.Cleanup(server.Close)
+
+	return app, server
+}
+
+// startTestUpload starts a blob upload and returns its location.
+func startTestUpload(t *testing.T, server *httptest.Server, repo string) string {
+	t.Helper()
+
+	resp, err := http.Post(server.URL+"/v2/"+repo+"/blobs/uploads/", "", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+
+	return server.URL + loc.RequestURI()
+}
+
+func patchTestChunk(t *testing.T, location, contentRange, chunk string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPatch, location, strings.NewReader(chunk))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if contentRange != "" {
+		req.Header.Set("Content-Range", contentRange)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	return resp
+}
+
+func withUploadState(t *testing.T, location, state string) string {
+	t.Helper()
+
+	u, err := url.Parse(location)
+	require.NoError(t, err)
+	q := u.Query()
+	q.Set("_state", state)
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+func TestResumeBlobUpload_PersistedState(t *testing.T) {
+	app, server := newUploadTestApp(t, nil)
+	app.uploadStates = newMemoryUploadStateStore()
+
+	location := startTestUpload(t, server, "foo/bar")
+
+	// simulate an instance configured with a different HTTP secret, which can't verify the upload state token
+	app.Config.HTTP.Secret = "secret-b"
+
+	u, err := url.Parse(location)
+	require.NoError(t, err)
+	token := u.Query().Get("_state")
+
+	// a forged token is rejected, even though the upload state is persisted and the token matches the upload
+	forged, err := hmacKey("secret-c").packUploadState(blobUploadState{Name: "foo/bar", UUID: path.Base(u.Path), Offset: 0})
+	require.NoError(t, err)
+	resp := patchTestChunk(t, withUploadState(t, location, forged), "", "foo")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// the token last issued for the upload is accepted, and a new one is issued
+	resp = patchTestChunk(t, location, "", "foo")
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	next, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	require.NotEqual(t, token, next.Query().Get("_state"))
+
+	// the superseded token is no longer accepted
+	resp = patchTestChunk(t, location, "", "bar")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestResumeBlobUpload_StrictChunkOrdering(t *testing.T) {
+	_, server := newUploadTestApp(t, func(config *configuration.Configuration) {
+		config.Validation.Uploads.StrictChunkOrdering = true
+	})
+
+	location := startTestUpload(t, server, "foo/bar")
+
+	resp := patchTestChunk(t, location, "", "foo")
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.Equal(t, "0-2", resp.Header.Get("Range"))
+	next := resp.Header.Get("Location")
+
+	// resending a chunk with the location of the previous one is out of order, and the client is pointed to the
+	// current offset
+	open := atomic.LoadInt64(&openWriters)
+	resp = patchTestChunk(t, location, "", "bar")
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+	require.Equal(t, "0-2", resp.Header.Get("Range"))
+	require.Equal(t, next, resp.Header.Get("Location"))
+	require.Equal(t, open, atomic.LoadInt64(&openWriters), "upload writer leaked")
+
+	// the upload is left intact and can be resumed
+	resp = patchTestChunk(t, next, "", "bar")
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.Equal(t, "0-5", resp.Header.Get("Range"))
+}
+
+func TestPatchBlobData_ParallelChunksRange(t *testing.T) {
+	_, server := newUploadTestApp(t, func(config *configuration.Configuration) {
+		config.Validation.Uploads.ParallelChunks = true
+	})
+
+	location := startTestUpload(t, server, "foo/bar")
+
+	// a chunk ahead of the upload offset is stored as a part, which is not contiguous to the content received so far
+	resp := patchTestChunk(t, location, "3-5", "bar")
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.Equal(t, "0-0", resp.Header.Get("Range"))
+
+	// once the gap is filled, the range covers the part
+	resp = patchTestChunk(t, resp.Header.Get("Location"), "0-2", "foo")
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.Equal(t, "0-5", resp.Header.Get("Range"))
+}
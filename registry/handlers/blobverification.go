@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	dcontext "github.com/docker/distribution/context"
+	prometheus "github.com/docker/distribution/metrics"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/storage"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+const (
+	// redirectVerificationFirstRead verifies each blob once, on the first read served through a redirect.
+	redirectVerificationFirstRead = "firstread"
+	// redirectVerificationPeriodic verifies each blob on the first read served through a redirect, and again on the
+	// first such read after the verification interval has elapsed.
+	redirectVerificationPeriodic = "periodic"
+
+	defaultRedirectVerificationInterval = 7 * 24 * time.Hour
+
+	blobVerificationResultValid   = "valid"
+	blobVerificationResultInvalid = "invalid"
+	blobVerificationResultError   = "error"
+)
+
+var (
+	// blobVerificationCounter is the number of blob verifications performed before redirecting to the storage backend
+	blobVerificationCounter = prometheus.StorageNamespace.NewLabeledCounter("blob_redirect_verifications", "The number of blob verifications performed before redirecting to the storage backend", "result")
+)
+
+// configureRedirectVerification sets up the verification of blobs before redirecting clients to the storage backend,
+// based on the verify and verifyinterval parameters of the storage redirect section. Verification timestamps are
+// recorded in the metadata database, so verification is only performed for requests served by it.
+func (app *App) configureRedirectVerification(params configuration.Parameters) {
+	v, ok := params["verify"]
+	if !ok {
+		return
+	}
+	mode, ok := v.(string)
+	if !ok {
+		panic(fmt.Sprintf("invalid type %T for 'storage.redirect.verify' (string)", v))
+	}
+
+	switch mode = strings.ToLower(mode); mode {
+	case "":
+		return
+	case redirectVerificationFirstRead, redirectVerificationPeriodic:
+	default:
+		panic(fmt.Sprintf("invalid value %q for 'storage.redirect.verify' (%s or %s)", mode, redirectVerificationFirstRead, redirectVerificationPeriodic))
+	}
+
+	interval := defaultRedirectVerificationInterval
+	if v, ok := params["verifyinterval"]; ok && mode == redirectVerificationPeriodic {
+		s, ok := v.(string)
+		if !ok {
+			panic(fmt.Sprintf("invalid type %T for 'storage.redirect.verifyinterval' (duration string)", v))
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			panic(fmt.Sprintf("invalid value %q for 'storage.redirect.verifyinterval' (positive duration)", s))
+		}
+		interval = d
+	}
+
+	log := dcontext.GetLogger(app).WithField("mode", mode)
+	if mode == redirectVerificationPeriodic {
+		log = log.WithField("interval", interval)
+	}
+	if !app.Config.Database.Enabled {
+		log.Warn("blob verification before redirects requires the metadata database and will not be performed")
+	}
+	log.Info("blob verification before redirects enabled")
+
+	app.redirectVerification = mode
+	app.redirectVerificationInterval = interval
+}
+
+// blobNeedsVerification reports whether blob b must be verified before redirecting a client to download it.
+func (app *App) blobNeedsVerification(b *models.Blob) bool {
+	switch app.redirectVerification {
+	case redirectVerificationFirstRead:
+		return !b.VerifiedAt.Valid
+	case redirectVerificationPeriodic:
+		return !b.VerifiedAt.Valid || time.Since(b.VerifiedAt.Time) >= app.redirectVerificationInterval
+	default:
+		return false
+	}
+}
+
+// verifyBlob checks that the content of blob b stored by driver matches its digest and, if so, records the time of
+// the verification in the database. A distribution.ErrBlobInvalidDigest error is returned if the content does not
+// match. Failures to record the verification are only logged, the blob being verified again on the next read.
+func verifyBlob(ctx context.Context, db datastore.Queryer, driver storagedriver.StorageDriver, b *models.Blob) error {
+	log := dcontext.GetLoggerWithField(ctx, "digest", b.Digest)
+	log.Info("verifying blob before redirect")
+
+	start := time.Now()
+	if err := storage.VerifyBlob(ctx, driver, b.Digest); err != nil {
+		var invalid distribution.ErrBlobInvalidDigest
+		if errors.As(err, &invalid) {
+			blobVerificationCounter.WithValues(blobVerificationResultInvalid).Inc(1)
+			log.WithError(err).Error("blob content does not match its digest")
+		} else {
+			blobVerificationCounter.WithValues(blobVerificationResultError).Inc(1)
+		}
+		return err
+	}
+	blobVerificationCounter.WithValues(blobVerificationResultValid).Inc(1)
+	log.WithField("duration_s", time.Since(start).Seconds()).Info("blob verified")
+
+	if err := datastore.NewBlobStore(db).MarkVerified(ctx, b); err != nil {
+		log.WithError(err).Warn("failed to record blob verification")
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureRedirectVerification(t *testing.T) {
+	tt := []struct {
+		name             string
+		params           configuration.Parameters
+		expectedMode     string
+		expectedInterval time.Duration
+		expectedPanic    bool
+	}{
+		{
+			name:   "not configured",
+			params: configuration.Parameters{"disable": false},
+		},
+		{
+			name:   "empty",
+			params: configuration.Parameters{"verify": ""},
+		},
+		{
+			name:             "first read",
+			params:           configuration.Parameters{"verify": "firstread", "verifyinterval": "1h"},
+			expectedMode:     redirectVerificationFirstRead,
+			expectedInterval: defaultRedirectVerificationInterval,
+		},
+		{
+			name:             "periodic",
+			params:           configuration.Parameters{"verify": "Periodic"},
+			expectedMode:     redirectVerificationPeriodic,
+			expectedInterval: defaultRedirectVerificationInterval,
+		},
+		{
+			name:             "periodic with interval",
+			params:           configuration.Parameters{"verify": "periodic", "verifyinterval": "1h"},
+			expectedMode:     redirectVerificationPeriodic,
+			expectedInterval: time.Hour,
+		},
+		{
+			name:          "invalid mode",
+			params:        configuration.Parameters{"verify": "always"},
+			expectedPanic: true,
+		},
+		{
+			name:          "invalid mode type",
+			params:        configuration.Parameters{"verify": true},
+			expectedPanic: true,
+		},
+		{
+			name:          "invalid interval",
+			params:        configuration.Parameters{"verify": "periodic", "verifyinterval": "-1h"},
+			expectedPanic: true,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			app := &App{Context: context.Background(), Config: &configuration.Configuration{}}
+
+			if test.expectedPanic {
+				require.Panics(t, func() { app.configureRedirectVerification(test.params) })
+				return
+			}
+
+			app.configureRedirectVerification(test.params)
+			require.Equal(t, test.expectedMode, app.redirectVerification)
+			require.Equal(t, test.expectedInterval, app.redirectVerificationInterval)
+		})
+	}
+}
+
+func TestBlobNeedsVerification(t *testing.T) {
+	unverified := &models.Blob{}
+	recent := &models.Blob{VerifiedAt: sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true}}
+	old := &models.Blob{VerifiedAt: sql.NullTime{Time: time.Now().Add(-2 * time.Hour), Valid: true}}
+
+	tt := []struct {
+		name     string
+		mode     string
+		blob     *models.Blob
+		expected bool
+	}{
+		{name: "disabled", mode: "", blob: unverified, expected: false},
+		{name: "first read unverified", mode: redirectVerificationFirstRead, blob: unverified, expected: true},
+		{name: "first read verified", mode: redirectVerificationFirstRead, blob: old, expected: false},
+		{name: "periodic unverified", mode: redirectVerificationPeriodic, blob: unverified, expected: true},
+		{name: "periodic recently verified", mode: redirectVerificationPeriodic, blob: recent, expected: false},
+		{name: "periodic verified long ago", mode: redirectVerificationPeriodic, blob: old, expected: true},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			app := &App{redirectVerification: test.mode, redirectVerificationInterval: time.Hour}
+			require.Equal(t, test.expected, app.blobNeedsVerification(test.blob))
+		})
+	}
+}
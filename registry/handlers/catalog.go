@@ -36,9 +36,14 @@ type catalogAPIResponse struct {
 	Repositories []string `json:"repositories"`
 }
 
-func dbGetCatalog(ctx context.Context, db datastore.Queryer, n int, last string) ([]string, bool, error) {
+// catalogDetailAPIResponse is the response of the catalog in extended (details=true) mode.
+type catalogDetailAPIResponse struct {
+	Repositories []repositoryDetailAPIResponse `json:"repositories"`
+}
+
+func dbGetCatalog(ctx context.Context, db datastore.Queryer, n int, last, prefix string, includeArchived bool) ([]string, bool, error) {
 	rStore := datastore.NewRepositoryStore(db)
-	rr, err := rStore.FindAllPaginated(ctx, n, last)
+	rr, err := rStore.FindAllPaginated(ctx, n, last, prefix, includeArchived)
 	if err != nil {
 		return nil, false, err
 	}
@@ -50,7 +55,7 @@ func dbGetCatalog(ctx context.Context, db datastore.Queryer, n int, last string)
 
 	var moreEntries bool
 	if len(rr) > 0 {
-		n, err := rStore.CountAfterPath(ctx, rr[len(rr)-1].Path)
+		n, err := rStore.CountAfterPath(ctx, rr[len(rr)-1].Path, prefix, includeArchived)
 		if err != nil {
 			return nil, false, err
 		}
@@ -60,6 +65,32 @@ func dbGetCatalog(ctx context.Context, db datastore.Queryer, n int, last string)
 	return repos, moreEntries, nil
 }
 
+// dbGetCatalogDetail is similar to dbGetCatalog, but returns the tag count of each repository as well. One more
+// repository than requested is fetched to determine whether there are more entries, instead of counting them.
+func dbGetCatalogDetail(ctx context.Context, db datastore.Queryer, n int, last, prefix string, includeArchived bool) ([]repositoryDetailAPIResponse, bool, error) {
+	rr, err := datastore.NewRepositoryStore(db).FindAllDetailPaginated(ctx, n+1, last, prefix, includeArchived)
+	if err != nil {
+		return nil, false, err
+	}
+
+	moreEntries := len(rr) > n
+	if moreEntries {
+		rr = rr[:n]
+	}
+
+	repos := make([]repositoryDetailAPIResponse, 0, len(rr))
+	for _, r := range rr {
+		repos = append(repos, repositoryDetailAPIResponse{
+			Name:      r.Name,
+			Path:      r.Path,
+			TagsCount: r.TagsCount,
+			Archived:  r.Archived,
+		})
+	}
+
+	return repos, moreEntries, nil
+}
+
 func (ch *catalogHandler) GetCatalog(w http.ResponseWriter, r *http.Request) {
 	var moreEntries = true
 
@@ -69,12 +100,31 @@ func (ch *catalogHandler) GetCatalog(w http.ResponseWriter, r *http.Request) {
 	if err != nil || maxEntries <= 0 {
 		maxEntries = maximumReturnedEntries
 	}
+	// archived repositories are hidden by default, and only the metadata database is aware of them
+	includeArchived, _ := strconv.ParseBool(q.Get("include_archived"))
+	// filtering by prefix and the extended response mode are backed by indexed queries on the metadata database only
+	prefix := q.Get("prefix")
+	details, _ := strconv.ParseBool(q.Get("details"))
+	if !ch.useDatabase && (prefix != "" || details) {
+		ch.Errors = append(ch.Errors, errcode.ErrorCodeUnsupported.WithDetail("the prefix and details query parameters require the metadata database"))
+		return
+	}
 
 	var filled int
 	var repos []string
+	var detailRepos []repositoryDetailAPIResponse
 
-	if ch.useDatabase {
-		repos, moreEntries, err = dbGetCatalog(ch.Context, ch.db, maxEntries, lastEntry)
+	if details {
+		detailRepos, moreEntries, err = dbGetCatalogDetail(ch.Context, ch.db, maxEntries, lastEntry, prefix, includeArchived)
+		if err != nil {
+			ch.Errors = append(ch.Errors, errcode.FromUnknownError(err))
+			return
+		}
+		if len(detailRepos) > 0 {
+			lastEntry = detailRepos[len(detailRepos)-1].Path
+		}
+	} else if ch.useDatabase {
+		repos, moreEntries, err = dbGetCatalog(ch.Context, ch.db, maxEntries, lastEntry, prefix, includeArchived)
 		if err != nil {
 			ch.Errors = append(ch.Errors, errcode.FromUnknownError(err))
 			return
@@ -98,40 +148,48 @@ func (ch *catalogHandler) GetCatalog(w http.ResponseWriter, r *http.Request) {
 
 	// Add a link header if there are more entries to retrieve
 	if moreEntries {
-		lastEntry = repos[len(repos)-1]
-		urlStr, err := createLinkEntry(r.URL.String(), maxEntries, lastEntry)
+		if !details {
+			lastEntry = repos[len(repos)-1]
+		}
+		v := paginationValues(maxEntries, lastEntry)
+		if includeArchived {
+			v.Set("include_archived", "true")
+		}
+		if prefix != "" {
+			v.Set("prefix", prefix)
+		}
+		if details {
+			v.Set("details", "true")
+		}
+		urlStr, err := ch.App.linkBuilder.BuildCatalogURL(v)
 		if err != nil {
 			ch.Errors = append(ch.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
 			return
 		}
-		w.Header().Set("Link", urlStr)
+		w.Header().Set("Link", nextLink(urlStr))
+	}
+
+	var resp interface{} = catalogAPIResponse{Repositories: repos[0:filled]}
+	if details {
+		resp = catalogDetailAPIResponse{Repositories: detailRepos}
 	}
 
 	enc := json.NewEncoder(w)
-	if err := enc.Encode(catalogAPIResponse{
-		Repositories: repos[0:filled],
-	}); err != nil {
+	if err := enc.Encode(resp); err != nil {
 		ch.Errors = append(ch.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
 		return
 	}
 }
 
-// Use the original URL from the request to create a new URL for
-// the link header
-func createLinkEntry(origURL string, maxEntries int, lastEntry string) (string, error) {
-	calledURL, err := url.Parse(origURL)
-	if err != nil {
-		return "", err
+// paginationValues returns the query parameters of the page following lastEntry.
+func paginationValues(maxEntries int, lastEntry string) url.Values {
+	return url.Values{
+		"n":    []string{strconv.Itoa(maxEntries)},
+		"last": []string{lastEntry},
 	}
+}
 
-	v := url.Values{}
-	v.Add("n", strconv.Itoa(maxEntries))
-	v.Add("last", lastEntry)
-
-	calledURL.RawQuery = v.Encode()
-
-	calledURL.Fragment = ""
-	urlStr := fmt.Sprintf("<%s>; rel=\"next\"", calledURL.String())
-
-	return urlStr, nil
+// nextLink formats u as the value of a Link header pointing to the next page of results.
+func nextLink(u string) string {
+	return fmt.Sprintf("<%s>; rel=\"next\"", u)
 }
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/docker/distribution/configuration"
+	dcontext "github.com/docker/distribution/context"
+	prometheus "github.com/docker/distribution/metrics"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/go-metrics"
+)
+
+const (
+	clientDeniedReasonIPDenied     = "ip_denied"
+	clientDeniedReasonIPNotAllowed = "ip_not_allowed"
+	clientDeniedReasonUserAgent    = "user_agent"
+)
+
+var (
+	// clientDeniedCounter is the number of requests rejected due to the client access restrictions
+	clientDeniedCounter = prometheus.ClientsNamespace.NewLabeledCounter("denied_requests", "The number of requests rejected due to the client access restrictions", "reason")
+)
+
+func init() {
+	metrics.Register(prometheus.ClientsNamespace)
+}
+
+// clientFilter rejects requests based on the IP address and user agent of clients.
+type clientFilter struct {
+	allowedIPs       []*net.IPNet
+	deniedIPs        []*net.IPNet
+	deniedUserAgents []*regexp.Regexp
+	trustedProxies   []*net.IPNet
+}
+
+// parseIPNets parses a list of IP addresses or CIDR ranges. Plain IP addresses are converted to single address ranges.
+func parseIPNets(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, v := range values {
+		if strings.Contains(v, "/") {
+			_, n, err := net.ParseCIDR(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR range %q: %w", v, err)
+			}
+			nets = append(nets, n)
+			continue
+		}
+
+		ip := net.ParseIP(v)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", v)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+	}
+	return nets, nil
+}
+
+// newClientFilter builds a clientFilter from the given configuration. Returns nil if no restrictions are configured.
+func newClientFilter(config configuration.Clients) (*clientFilter, error) {
+	if len(config.AllowedIPs) == 0 && len(config.DeniedIPs) == 0 && len(config.DeniedUserAgents) == 0 {
+		return nil, nil
+	}
+
+	allowed, err := parseIPNets(config.AllowedIPs)
+	if err != nil {
+		return nil, fmt.Errorf("allowed IPs: %w", err)
+	}
+	denied, err := parseIPNets(config.DeniedIPs)
+	if err != nil {
+		return nil, fmt.Errorf("denied IPs: %w", err)
+	}
+
+	proxies, err := parseIPNets(config.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("trusted proxies: %w", err)
+	}
+
+	userAgents := make([]*regexp.Regexp, 0, len(config.DeniedUserAgents))
+	for _, expr := range config.DeniedUserAgents {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("denied user agents: invalid regexp %q: %w", expr, err)
+		}
+		userAgents = append(userAgents, re)
+	}
+
+	return &clientFilter{allowedIPs: allowed, deniedIPs: denied, deniedUserAgents: userAgents, trustedProxies: proxies}, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP address of the client that issued r, or nil if it can not be determined. This is the address
+// of the connection, unless it belongs to a trusted proxy. In that case, the X-Forwarded-For header is read from right
+// to left, skipping the addresses of trusted proxies, as any address to their left may have been set by the client.
+// The X-Real-Ip header is read instead in the absence of X-Forwarded-For.
+func (f *clientFilter) clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !containsIP(f.trustedProxies, ip) {
+		return ip
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			if ip = net.ParseIP(strings.TrimSpace(hops[i])); ip == nil || !containsIP(f.trustedProxies, ip) {
+				return ip
+			}
+		}
+		return ip
+	}
+	if realIP := r.Header.Get("X-Real-Ip"); realIP != "" {
+		return net.ParseIP(strings.TrimSpace(realIP))
+	}
+
+	return ip
+}
+
+// check returns an error describing why the client that issued r is not allowed to access the registry, along with
+// the reason label used for metrics. Returns nil if the client is allowed.
+func (f *clientFilter) check(r *http.Request) (string, error) {
+	if len(f.allowedIPs) > 0 || len(f.deniedIPs) > 0 {
+		ip := f.clientIP(r)
+
+		if ip != nil && containsIP(f.deniedIPs, ip) {
+			return clientDeniedReasonIPDenied, errcode.ErrorCodeDenied.WithDetail(fmt.Sprintf("client IP %s is denied access to the registry", ip))
+		}
+		if len(f.allowedIPs) > 0 && ip == nil {
+			return clientDeniedReasonIPNotAllowed, errcode.ErrorCodeDenied.WithDetail("client IP could not be determined")
+		}
+		if len(f.allowedIPs) > 0 && !containsIP(f.allowedIPs, ip) {
+			return clientDeniedReasonIPNotAllowed, errcode.ErrorCodeDenied.WithDetail(fmt.Sprintf("client IP %s is not allowed to access the registry", ip))
+		}
+	}
+
+	ua := r.UserAgent()
+	for _, re := range f.deniedUserAgents {
+		if re.MatchString(ua) {
+			return clientDeniedReasonUserAgent, errcode.ErrorCodeDenied.WithDetail(fmt.Sprintf("client user agent %q is denied access to the registry, please upgrade or use a different client", ua))
+		}
+	}
+
+	return "", nil
+}
+
+// configureClientFilter sets up the client access restrictions, if any.
+func (app *App) configureClientFilter(config *configuration.Configuration) {
+	f, err := newClientFilter(config.HTTP.Clients)
+	if err != nil {
+		panic(fmt.Sprintf("invalid http clients configuration: %v", err))
+	}
+	if f == nil {
+		return
+	}
+
+	app.clientFilter = f
+	dcontext.GetLoggerWithFields(app, map[interface{}]interface{}{
+		"allowed_ips":        config.HTTP.Clients.AllowedIPs,
+		"denied_ips":         config.HTTP.Clients.DeniedIPs,
+		"denied_user_agents": config.HTTP.Clients.DeniedUserAgents,
+		"trusted_proxies":    config.HTTP.Clients.TrustedProxies,
+	}).Info("client access restrictions enabled")
+}
+
+// filterClient rejects the request with a 403 Forbidden response if the client that issued it is not allowed to access
+// the registry. Returns true if the request was rejected.
+func (app *App) filterClient(w http.ResponseWriter, r *http.Request) bool {
+	if app.clientFilter == nil {
+		return false
+	}
+
+	reason, err := app.clientFilter.check(r)
+	if err == nil {
+		return false
+	}
+
+	clientDeniedCounter.WithValues(reason).Inc(1)
+	dcontext.GetLogger(r.Context()).WithError(err).WithField("reason", reason).Warn("client denied access")
+	if serveErr := errcode.ServeJSON(w, err); serveErr != nil {
+		dcontext.GetLogger(r.Context()).Errorf("error serving error json: %v (from %v)", serveErr, err)
+	}
+	return true
+}
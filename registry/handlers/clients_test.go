@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClientFilter(t *testing.T) {
+	f, err := newClientFilter(configuration.Clients{})
+	require.NoError(t, err)
+	require.Nil(t, f)
+
+	_, err = newClientFilter(configuration.Clients{AllowedIPs: []string{"foo"}})
+	require.EqualError(t, err, `allowed IPs: invalid IP address "foo"`)
+	_, err = newClientFilter(configuration.Clients{DeniedIPs: []string{"10.0.0.0/33"}})
+	require.EqualError(t, err, `denied IPs: invalid CIDR range "10.0.0.0/33": invalid CIDR address: 10.0.0.0/33`)
+	_, err = newClientFilter(configuration.Clients{AllowedIPs: []string{"10.0.0.1"}, TrustedProxies: []string{"foo"}})
+	require.EqualError(t, err, `trusted proxies: invalid IP address "foo"`)
+	_, err = newClientFilter(configuration.Clients{DeniedUserAgents: []string{"("}})
+	require.Error(t, err)
+}
+
+func TestClientFilter_Check(t *testing.T) {
+	config := configuration.Clients{
+		AllowedIPs:       []string{"10.0.0.0/8"},
+		DeniedIPs:        []string{"10.1.0.0/16"},
+		DeniedUserAgents: []string{`^docker/1\.[0-9]\.`},
+		TrustedProxies:   []string{"192.168.0.1", "192.168.1.0/24"},
+	}
+	f, err := newClientFilter(config)
+	require.NoError(t, err)
+
+	tcs := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		wantReason string
+	}{
+		{
+			name:       "allowed",
+			remoteAddr: "10.0.0.1:1234",
+		},
+		{
+			name:       "not allowed",
+			remoteAddr: "172.16.0.1:1234",
+			wantReason: clientDeniedReasonIPNotAllowed,
+		},
+		{
+			name:       "denied",
+			remoteAddr: "10.1.0.1:1234",
+			wantReason: clientDeniedReasonIPDenied,
+		},
+		{
+			name:       "spoofed forwarded for from untrusted peer",
+			remoteAddr: "172.16.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.1"},
+			wantReason: clientDeniedReasonIPNotAllowed,
+		},
+		{
+			name:       "spoofed real ip from untrusted peer",
+			remoteAddr: "172.16.0.1:1234",
+			headers:    map[string]string{"X-Real-Ip": "10.0.0.1"},
+			wantReason: clientDeniedReasonIPNotAllowed,
+		},
+		{
+			name:       "spoofed forwarded for from denied peer",
+			remoteAddr: "10.1.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.1"},
+			wantReason: clientDeniedReasonIPDenied,
+		},
+		{
+			name:       "forwarded for from trusted proxy",
+			remoteAddr: "192.168.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.1"},
+		},
+		{
+			name:       "forwarded for through multiple trusted proxies",
+			remoteAddr: "192.168.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.1, 192.168.1.10"},
+		},
+		{
+			name:       "spoofed forwarded for through trusted proxy",
+			remoteAddr: "192.168.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.1, 172.16.0.1"},
+			wantReason: clientDeniedReasonIPNotAllowed,
+		},
+		{
+			name:       "denied forwarded for from trusted proxy",
+			remoteAddr: "192.168.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "10.1.0.1"},
+			wantReason: clientDeniedReasonIPDenied,
+		},
+		{
+			name:       "invalid forwarded for from trusted proxy",
+			remoteAddr: "192.168.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.1, foo"},
+			wantReason: clientDeniedReasonIPNotAllowed,
+		},
+		{
+			name:       "real ip from trusted proxy",
+			remoteAddr: "192.168.0.1:1234",
+			headers:    map[string]string{"X-Real-Ip": "10.0.0.1"},
+		},
+		{
+			name:       "trusted proxy without headers",
+			remoteAddr: "192.168.0.1:1234",
+			wantReason: clientDeniedReasonIPNotAllowed,
+		},
+		{
+			name:       "denied user agent",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"User-Agent": "docker/1.9.1"},
+			wantReason: clientDeniedReasonUserAgent,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+			r.RemoteAddr = tc.remoteAddr
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+
+			reason, err := f.check(r)
+			require.Equal(t, tc.wantReason, reason)
+			if tc.wantReason == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
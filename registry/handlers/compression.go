@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/distribution/configuration"
+	dcontext "github.com/docker/distribution/context"
+)
+
+const (
+	defaultCompressionMinSize = 1024
+	defaultCompressionLevel   = gzip.DefaultCompression
+)
+
+// defaultCompressionContentTypes are the media types of the responses compressed if none are configured. The `+json`
+// suffix covers all manifest media types, as well as the GitLab API responses.
+var defaultCompressionContentTypes = []string{"application/json", "+json"}
+
+// responseCompression holds the validated response compression configuration.
+type responseCompression struct {
+	minSize      int
+	contentTypes []string
+	writers      sync.Pool
+}
+
+// newResponseCompression builds a responseCompression from the given configuration. Returns nil if compression is
+// disabled.
+func newResponseCompression(config configuration.Compression) (*responseCompression, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+	if config.MinSize < 0 {
+		return nil, fmt.Errorf("minsize must not be negative, got %d", config.MinSize)
+	}
+	if config.Level != 0 && (config.Level < gzip.BestSpeed || config.Level > gzip.BestCompression) {
+		return nil, fmt.Errorf("level must be between %d and %d, got %d", gzip.BestSpeed, gzip.BestCompression, config.Level)
+	}
+
+	c := &responseCompression{
+		minSize:      config.MinSize,
+		contentTypes: config.ContentTypes,
+	}
+	if c.minSize == 0 {
+		c.minSize = defaultCompressionMinSize
+	}
+	if len(c.contentTypes) == 0 {
+		c.contentTypes = defaultCompressionContentTypes
+	}
+	level := config.Level
+	if level == 0 {
+		level = defaultCompressionLevel
+	}
+	c.writers.New = func() interface{} {
+		// the level was validated above, so this never fails
+		gz, _ := gzip.NewWriterLevel(nil, level)
+		return gz
+	}
+
+	return c, nil
+}
+
+// configureCompression sets up the compression of responses, if enabled.
+func (app *App) configureCompression(config *configuration.Configuration) {
+	c, err := newResponseCompression(config.HTTP.Compression)
+	if err != nil {
+		panic(fmt.Sprintf("invalid http compression configuration: %v", err))
+	}
+	if c == nil {
+		return
+	}
+
+	app.compression = c
+	dcontext.GetLoggerWithFields(app, map[interface{}]interface{}{
+		"min_size":      c.minSize,
+		"content_types": c.contentTypes,
+	}).Info("response compression enabled")
+}
+
+// compressible reports whether a response with the given Content-Type header value is eligible for compression.
+func (c *responseCompression) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range c.contentTypes {
+		if strings.HasPrefix(t, "+") {
+			if strings.HasSuffix(mediaType, t) {
+				return true
+			}
+		} else if strings.EqualFold(mediaType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsGzip reports whether the client accepts gzip encoded responses, as per the Accept-Encoding header of r.
+func acceptsGzip(r *http.Request) bool {
+	for _, v := range r.Header.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(v, ",") {
+			name, params := coding, ""
+			if i := strings.Index(coding, ";"); i >= 0 {
+				name, params = coding[:i], coding[i+1:]
+			}
+			name = strings.TrimSpace(name)
+			if !strings.EqualFold(name, "gzip") && name != "*" {
+				continue
+			}
+			params = strings.TrimSpace(params)
+			if strings.HasPrefix(params, "q=") {
+				if q, err := strconv.ParseFloat(params[len("q="):], 64); err == nil && q == 0 {
+					continue
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// compressResponse wraps w to gzip compress the response to r, if compression is enabled. Only GET responses with an
+// eligible media type and a body of at least the minimum size are compressed, and only if the client accepts it.
+// Returns the response writer to use and a function that must be called once the request has been served.
+func (app *App) compressResponse(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	c := app.compression
+	if c == nil || r.Method != http.MethodGet || r.Header.Get("Range") != "" {
+		return w, func() {}
+	}
+
+	cw := &compressResponseWriter{ResponseWriter: w, ctx: r.Context(), c: c, accepted: acceptsGzip(r)}
+	return cw, cw.close
+}
+
+// compressResponseWriter buffers the body of an eligible response until the minimum size for compression is reached,
+// at which point it starts compressing. Responses that remain under the minimum size are sent uncompressed.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	ctx      context.Context
+	c        *responseCompression
+	accepted bool
+
+	status      int
+	wroteHeader bool // whether WriteHeader was called on the compressResponseWriter
+	pending     bool // whether the response is eligible and its headers have not been sent yet
+	buf         []byte
+	gz          *gzip.Writer
+}
+
+func (w *compressResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+
+	h := w.Header()
+	if status != http.StatusOK || h.Get("Content-Encoding") != "" || !w.c.compressible(h.Get("Content-Type")) {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	// the representation depends on the Accept-Encoding header, whether or not the client accepts gzip
+	h.Add("Vary", "Accept-Encoding")
+	if !w.accepted {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if cl := h.Get("Content-Length"); cl != "" {
+		if n, err := strconv.Atoi(cl); err == nil && n < w.c.minSize {
+			w.ResponseWriter.WriteHeader(status)
+			return
+		}
+	}
+	w.pending = true
+}
+
+func (w *compressResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+	if !w.pending {
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.c.minSize {
+		if err := w.startCompression(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// startCompression sends the headers of a compressed response, followed by the buffered body.
+func (w *compressResponseWriter) startCompression() error {
+	w.pending = false
+
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", "gzip")
+	w.ResponseWriter.WriteHeader(w.status)
+
+	w.gz = w.c.writers.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+	_, err := w.gz.Write(w.buf)
+	w.buf = nil
+
+	return err
+}
+
+// flushPending sends the headers of an uncompressed response, followed by the buffered body.
+func (w *compressResponseWriter) flushPending() error {
+	w.pending = false
+	w.ResponseWriter.WriteHeader(w.status)
+	_, err := w.ResponseWriter.Write(w.buf)
+	w.buf = nil
+
+	return err
+}
+
+// close completes the response, sending any buffered body and flushing the compressed stream.
+func (w *compressResponseWriter) close() {
+	if w.pending {
+		if err := w.flushPending(); err != nil {
+			dcontext.GetLogger(w.ctx).WithError(err).Error("error writing response")
+		}
+	}
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			dcontext.GetLogger(w.ctx).WithError(err).Error("error completing compressed response")
+		}
+		w.gz.Reset(nil)
+		w.c.writers.Put(w.gz)
+		w.gz = nil
+	}
+}
+
+func (w *compressResponseWriter) Flush() {
+	if w.pending {
+		// there is no point in compressing a body that is streamed before reaching the minimum size
+		_ = w.flushPending()
+	}
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewResponseCompression(t *testing.T) {
+	c, err := newResponseCompression(configuration.Compression{MinSize: 10})
+	require.NoError(t, err)
+	require.Nil(t, c)
+
+	c, err = newResponseCompression(configuration.Compression{Enabled: true})
+	require.NoError(t, err)
+	require.Equal(t, defaultCompressionMinSize, c.minSize)
+	require.Equal(t, defaultCompressionContentTypes, c.contentTypes)
+
+	c, err = newResponseCompression(configuration.Compression{Enabled: true, MinSize: 10, Level: 9, ContentTypes: []string{"text/plain"}})
+	require.NoError(t, err)
+	require.Equal(t, 10, c.minSize)
+	require.Equal(t, []string{"text/plain"}, c.contentTypes)
+
+	_, err = newResponseCompression(configuration.Compression{Enabled: true, MinSize: -1})
+	require.EqualError(t, err, "minsize must not be negative, got -1")
+	_, err = newResponseCompression(configuration.Compression{Enabled: true, Level: 10})
+	require.EqualError(t, err, "level must be between 1 and 9, got 10")
+}
+
+func TestResponseCompression_Compressible(t *testing.T) {
+	c, err := newResponseCompression(configuration.Compression{Enabled: true})
+	require.NoError(t, err)
+
+	require.True(t, c.compressible("application/json"))
+	require.True(t, c.compressible("application/json; charset=utf-8"))
+	require.True(t, c.compressible("application/vnd.docker.distribution.manifest.v2+json"))
+	require.True(t, c.compressible("application/vnd.oci.image.index.v1+json"))
+	require.False(t, c.compressible("application/octet-stream"))
+	require.False(t, c.compressible("application/vnd.docker.distribution.manifest.v1+prettyjws"))
+	require.False(t, c.compressible(""))
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tt := map[string]bool{
+		"":                       false,
+		"gzip":                   true,
+		"GZIP":                   true,
+		"deflate, gzip;q=0.8":    true,
+		"br, gzip;q=0":           false,
+		"identity":               false,
+		"*":                      true,
+		"deflate , gzip ; q=1.0": true,
+	}
+
+	for header, want := range tt {
+		r := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+		if header != "" {
+			r.Header.Set("Accept-Encoding", header)
+		}
+		require.Equal(t, want, acceptsGzip(r), header)
+	}
+}
+
+func TestCompressResponse(t *testing.T) {
+	large := `{"tags":["` + strings.Repeat("a", 2048) + `"]}`
+	small := `{"tags":["a"]}`
+
+	tt := []struct {
+		name           string
+		method         string
+		acceptEncoding string
+		contentType    string
+		status         int
+		body           string
+		contentLength  bool
+		wantCompressed bool
+		wantVary       bool
+	}{
+		{
+			name:           "large json",
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			body:           large,
+			wantCompressed: true,
+			wantVary:       true,
+		},
+		{
+			name:           "large json with content length",
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			body:           large,
+			contentLength:  true,
+			wantCompressed: true,
+			wantVary:       true,
+		},
+		{
+			name:           "large manifest",
+			acceptEncoding: "gzip",
+			contentType:    "application/vnd.oci.image.manifest.v1+json",
+			body:           large,
+			wantCompressed: true,
+			wantVary:       true,
+		},
+		{
+			name:           "small json",
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			body:           small,
+			wantVary:       true,
+		},
+		{
+			name:           "small json with content length",
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			body:           small,
+			contentLength:  true,
+			wantVary:       true,
+		},
+		{
+			name:        "not accepted",
+			contentType: "application/json",
+			body:        large,
+			wantVary:    true,
+		},
+		{
+			name:           "not eligible content type",
+			acceptEncoding: "gzip",
+			contentType:    "application/octet-stream",
+			body:           large,
+		},
+		{
+			name:           "error response",
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			status:         http.StatusNotFound,
+			body:           large,
+		},
+		{
+			name:           "not a get request",
+			method:         http.MethodPost,
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			body:           large,
+		},
+	}
+
+	c, err := newResponseCompression(configuration.Compression{Enabled: true})
+	require.NoError(t, err)
+	app := &App{Context: context.Background(), compression: c}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			method := test.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "/v2/foo/tags/list", nil)
+			if test.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", test.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+
+			w, finish := app.compressResponse(rec, r)
+			w.Header().Set("Content-Type", test.contentType)
+			if test.contentLength {
+				w.Header().Set("Content-Length", strconv.Itoa(len(test.body)))
+			}
+			status := test.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			w.WriteHeader(status)
+			// write in chunks to exercise buffering
+			for i := 0; i < len(test.body); i += 100 {
+				end := i + 100
+				if end > len(test.body) {
+					end = len(test.body)
+				}
+				_, err := w.Write([]byte(test.body[i:end]))
+				require.NoError(t, err)
+			}
+			finish()
+
+			resp := rec.Result()
+			defer resp.Body.Close()
+			require.Equal(t, status, resp.StatusCode)
+			require.Equal(t, test.wantVary, resp.Header.Get("Vary") == "Accept-Encoding")
+
+			body := resp.Body
+			if test.wantCompressed {
+				require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+				require.Empty(t, resp.Header.Get("Content-Length"))
+				gz, err := gzip.NewReader(resp.Body)
+				require.NoError(t, err)
+				body = gz
+			} else {
+				require.Empty(t, resp.Header.Get("Content-Encoding"))
+			}
+
+			b, err := ioutil.ReadAll(body)
+			require.NoError(t, err)
+			require.Equal(t, test.body, string(b))
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/docker/distribution/registry/api/errcode"
 	v2 "github.com/docker/distribution/registry/api/v2"
 	"github.com/docker/distribution/registry/auth"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/opencontainers/go-digest"
 )
 
@@ -39,6 +40,9 @@ type Context struct {
 	writeFSMetadata bool
 
 	blobProvider distribution.BlobProvider
+	// storageDriver is the storage driver backing Repository, which differs from the app global driver for repositories
+	// being migrated or relocated by the data mover.
+	storageDriver storagedriver.StorageDriver
 
 	// TODO(stevvooe): The goal is too completely factor this context and
 	// dispatching out of the web application. Ideally, we should lean on
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/docker/distribution/configuration"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/gorilla/handlers"
+)
+
+var (
+	// defaultCORSAllowedMethods are the methods allowed in cross-origin requests if none are configured. These are
+	// enough for read-only clients, such as registry UIs.
+	defaultCORSAllowedMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+
+	// defaultCORSExposedHeaders are the response headers exposed to cross-origin clients if none are configured. These
+	// are the registry API specific headers that clients need to read, as browsers only expose safelisted headers.
+	defaultCORSExposedHeaders = []string{
+		"Docker-Content-Digest",
+		"Docker-Distribution-API-Version",
+		"Docker-Upload-UUID",
+		"Link",
+		"Location",
+		"Range",
+		"WWW-Authenticate",
+	}
+)
+
+// configureCORS wraps the request routing with a Cross-Origin Resource Sharing (CORS) handler, if enabled. Preflight
+// requests are answered by the CORS handler and never reach the API handlers.
+func (app *App) configureCORS(config *configuration.Configuration) {
+	cfg := config.HTTP.CORS
+	if len(cfg.AllowedOrigins) == 0 {
+		return
+	}
+
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSAllowedMethods
+	}
+	exposedHeaders := cfg.ExposedHeaders
+	if len(exposedHeaders) == 0 {
+		exposedHeaders = defaultCORSExposedHeaders
+	}
+
+	opts := []handlers.CORSOption{
+		handlers.AllowedOrigins(cfg.AllowedOrigins),
+		handlers.AllowedMethods(methods),
+		handlers.AllowedHeaders(cfg.AllowedHeaders),
+		handlers.ExposedHeaders(exposedHeaders),
+	}
+	if cfg.MaxAge > 0 {
+		opts = append(opts, handlers.MaxAge(int(cfg.MaxAge.Seconds())))
+	}
+	if cfg.AllowCredentials {
+		opts = append(opts, handlers.AllowCredentials())
+	}
+
+	app.corsHandler = handlers.CORS(opts...)(http.HandlerFunc(app.route))
+	dcontext.GetLogger(app).WithField("origins", cfg.AllowedOrigins).Info("CORS enabled")
+}
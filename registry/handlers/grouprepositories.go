@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/gorilla/handlers"
+)
+
+const (
+	defaultGroupRepositoriesEntries = 100
+	// the tag count of each repository is queried individually, so pages are kept small
+	maxGroupRepositoriesEntries = 100
+)
+
+// groupRepositoriesDispatcher constructs the group repositories handler api endpoint.
+func groupRepositoriesDispatcher(ctx *Context, r *http.Request) http.Handler {
+	h := &groupRepositoriesHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		"GET": http.HandlerFunc(h.GetGroupRepositories),
+	}
+}
+
+// groupRepositoriesHandler handles requests for the repositories under a group path.
+type groupRepositoriesHandler struct {
+	*Context
+}
+
+type repositoryDetailAPIResponse struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	TagsCount int    `json:"tags_count"`
+	Archived  bool   `json:"archived,omitempty"`
+}
+
+type groupRepositoriesAPIResponse struct {
+	Name         string                        `json:"name"`
+	Repositories []repositoryDetailAPIResponse `json:"repositories"`
+}
+
+type groupRepositoriesQuery struct {
+	n               int
+	last            string
+	includeArchived bool
+}
+
+func parseGroupRepositoriesQuery(q url.Values) (*groupRepositoriesQuery, error) {
+	rq := &groupRepositoriesQuery{n: defaultGroupRepositoriesEntries}
+
+	if v := q.Get("n"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, invalidQueryParamErr("n", v)
+		}
+		if n > maxGroupRepositoriesEntries {
+			n = maxGroupRepositoriesEntries
+		}
+		rq.n = n
+	}
+	if v := q.Get("last"); v != "" {
+		if _, err := reference.WithName(v); err != nil {
+			return nil, invalidQueryParamErr("last", v)
+		}
+		rq.last = v
+	}
+	if v := q.Get("include_archived"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, invalidQueryParamErr("include_archived", v)
+		}
+		rq.includeArchived = b
+	}
+
+	return rq, nil
+}
+
+// groupRepositoriesNextLink builds a Link header value for the page following lastPath, preserving all other query
+// parameters of the original request.
+func groupRepositoriesNextLink(ub *v2.URLBuilder, name reference.Named, origQuery url.Values, n int, lastPath string) (string, error) {
+	q := url.Values{}
+	for k, v := range origQuery {
+		q[k] = v
+	}
+	q.Set("n", strconv.Itoa(n))
+	q.Set("last", lastPath)
+
+	u, err := ub.BuildGitLabGroupRepositoriesURL(name, q)
+	if err != nil {
+		return "", err
+	}
+
+	return nextLink(u), nil
+}
+
+// GetGroupRepositories returns the repositories under a group path, including the group path itself if it is a
+// repository, along with their tag counts. Unlike the catalog, this is scoped to a single namespace and requires pull
+// access to the group path only. Only supported by the metadata database backend.
+func (h *groupRepositoriesHandler) GetGroupRepositories(w http.ResponseWriter, r *http.Request) {
+	if h.App.db == nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported.WithDetail("group repositories require the metadata database"))
+		return
+	}
+
+	rq, err := parseGroupRepositoriesQuery(r.URL.Query())
+	if err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
+
+	groupPath := h.Repository.Named().Name()
+	log := dcontext.GetLoggerWithFields(h, map[interface{}]interface{}{"group": groupPath, "limit": rq.n, "marker": rq.last})
+	log.Debug("finding group repositories in database")
+
+	rStore := datastore.NewRepositoryStore(h.App.db)
+	group, err := rStore.FindByPath(h, groupPath)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if group == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": groupPath}))
+		return
+	}
+
+	// fetch one more repository than requested to determine whether there is a next page
+	rr, err := rStore.FindDescendantsOfPaginated(h, group, rq.n+1, rq.last, rq.includeArchived)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if len(rr) > rq.n {
+		rr = rr[:rq.n]
+		link, err := groupRepositoriesNextLink(h.App.linkBuilder, h.Repository.Named(), r.URL.Query(), rq.n, rr[len(rr)-1].Path)
+		if err != nil {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+		w.Header().Set("Link", link)
+	}
+
+	resp := groupRepositoriesAPIResponse{
+		Name:         groupPath,
+		Repositories: make([]repositoryDetailAPIResponse, 0, len(rr)),
+	}
+	for _, repo := range rr {
+		n, err := rStore.TagsCount(h, repo)
+		if err != nil {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+		resp.Repositories = append(resp.Repositories, repositoryDetailAPIResponse{
+			Name:      repo.Name,
+			Path:      repo.Path,
+			TagsCount: n,
+			Archived:  repo.Archived,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+}
@@ -3,8 +3,12 @@ package handlers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 
 	dcontext "github.com/docker/distribution/context"
 )
@@ -64,3 +68,46 @@ func copyFullPayload(ctx context.Context, responseWriter http.ResponseWriter, r
 
 	return nil
 }
+
+// parseContentRange parses a blob upload chunk Content-Range header value in the form `<start>-<end>`, where end is
+// inclusive. An optional `bytes ` unit prefix is tolerated for compatibility with RFC 7233 style clients.
+func parseContentRange(cr string) (start int64, end int64, err error) {
+	rStr := strings.TrimPrefix(strings.TrimSpace(cr), "bytes ")
+
+	parts := strings.SplitN(rStr, "-", 2)
+	if len(parts) != 2 {
+		return -1, -1, fmt.Errorf("invalid content range format, %s", cr)
+	}
+
+	start, err = strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return -1, -1, fmt.Errorf("invalid content range start, %s", cr)
+	}
+	end, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return -1, -1, fmt.Errorf("invalid content range end, %s", cr)
+	}
+	if start < 0 || start > end {
+		return -1, -1, fmt.Errorf("invalid content range, %s", cr)
+	}
+
+	return start, end, nil
+}
+
+// accepts determines whether the Accept header(s) of r explicitly list the given media type. Quality values and
+// wildcards are ignored, so that clients must opt in to alternative representations.
+func accepts(r *http.Request, mediaType string) bool {
+	for _, acceptHeader := range r.Header["Accept"] {
+		for _, rawMT := range strings.Split(acceptHeader, ",") {
+			mt, _, err := mime.ParseMediaType(rawMT)
+			if err != nil {
+				continue
+			}
+			if mt == mediaType {
+				return true
+			}
+		}
+	}
+
+	return false
+}
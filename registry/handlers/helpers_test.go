@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseContentRange(t *testing.T) {
+	tt := []struct {
+		name      string
+		value     string
+		wantStart int64
+		wantEnd   int64
+		wantErr   bool
+	}{
+		{name: "valid", value: "0-1023", wantStart: 0, wantEnd: 1023},
+		{name: "valid single byte", value: "10-10", wantStart: 10, wantEnd: 10},
+		{name: "valid with unit", value: "bytes 512-1023", wantStart: 512, wantEnd: 1023},
+		{name: "missing separator", value: "1023", wantErr: true},
+		{name: "non numeric start", value: "a-1023", wantErr: true},
+		{name: "non numeric end", value: "0-b", wantErr: true},
+		{name: "start after end", value: "10-9", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			start, end, err := parseContentRange(test.value)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.wantStart, start)
+			require.Equal(t, test.wantEnd, end)
+		})
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	tt := []struct {
+		name   string
+		values []string
+		want   bool
+	}{
+		{name: "none"},
+		{name: "exact", values: []string{"application/json"}, want: true},
+		{name: "with parameters", values: []string{"application/json; charset=utf-8"}, want: true},
+		{name: "in list", values: []string{"text/plain, application/json;q=0.9"}, want: true},
+		{name: "in repeated header", values: []string{"text/plain", "application/json"}, want: true},
+		{name: "wildcard", values: []string{"*/*"}},
+		{name: "other", values: []string{"application/xml"}},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, v := range test.values {
+				r.Header.Add("Accept", v)
+			}
+			require.Equal(t, test.want, accepts(r, "application/json"))
+		})
+	}
+}
@@ -22,6 +22,10 @@ type blobUploadState struct {
 
 	// StartedAt is the original start time of the upload.
 	StartedAt time.Time
+
+	// MismatchedChunkOffset is the offset of the first chunk whose content did not match the chunk digest provided by
+	// the client, if any. It is reported if the upload fails to complete due to a digest mismatch.
+	MismatchedChunkOffset *int64 `json:",omitempty"`
 }
 
 type hmacKey string
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	dcontext "github.com/docker/distribution/context"
+	prometheus "github.com/docker/distribution/metrics"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/gorilla/mux"
+)
+
+const (
+	limitedReasonBodyTooLarge  = "body_too_large"
+	limitedReasonUploadTooSlow = "upload_too_slow"
+
+	defaultMinUploadRatePeriod = 30 * time.Second
+)
+
+var (
+	// limitedRequestsCounter is the number of requests rejected or aborted due to the request limits
+	limitedRequestsCounter = prometheus.ClientsNamespace.NewLabeledCounter("limited_requests", "The number of requests rejected or aborted due to the request limits", "reason")
+
+	errUploadTooSlow = errors.New("upload transferred below the minimum rate")
+)
+
+type connKey struct{}
+
+// WithConn returns a copy of ctx carrying the network connection c. It's meant to be used as the http.Server
+// ConnContext hook, allowing the registry to abort reads from clients that stalled.
+func WithConn(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, c)
+}
+
+func connFromContext(ctx context.Context) net.Conn {
+	c, _ := ctx.Value(connKey{}).(net.Conn)
+	return c
+}
+
+// requestLimits holds the validated request limits configuration.
+type requestLimits struct {
+	maxBodySize         int64
+	minUploadRate       int64
+	minUploadRatePeriod time.Duration
+}
+
+// newRequestLimits builds a requestLimits from the given configuration. Returns nil if no limits are configured.
+func newRequestLimits(config configuration.Limits) (*requestLimits, error) {
+	if config.MaxBodySize < 0 {
+		return nil, fmt.Errorf("maxbodysize must not be negative, got %d", config.MaxBodySize)
+	}
+	if config.MinUploadRate < 0 {
+		return nil, fmt.Errorf("minuploadrate must not be negative, got %d", config.MinUploadRate)
+	}
+	if config.MinUploadRatePeriod < 0 {
+		return nil, fmt.Errorf("minuploadrateperiod must not be negative, got %s", config.MinUploadRatePeriod)
+	}
+	if config.MaxBodySize == 0 && config.MinUploadRate == 0 {
+		return nil, nil
+	}
+
+	l := &requestLimits{
+		maxBodySize:         config.MaxBodySize,
+		minUploadRate:       config.MinUploadRate,
+		minUploadRatePeriod: config.MinUploadRatePeriod,
+	}
+	if l.minUploadRatePeriod == 0 {
+		l.minUploadRatePeriod = defaultMinUploadRatePeriod
+	}
+
+	return l, nil
+}
+
+// configureRequestLimits sets up the request limits, if any.
+func (app *App) configureRequestLimits(config *configuration.Configuration) {
+	l, err := newRequestLimits(config.HTTP.Limits)
+	if err != nil {
+		panic(fmt.Sprintf("invalid http limits configuration: %v", err))
+	}
+	if l == nil {
+		return
+	}
+
+	app.requestLimits = l
+	dcontext.GetLoggerWithFields(app, map[interface{}]interface{}{
+		"max_body_size":          l.maxBodySize,
+		"min_upload_rate":        l.minUploadRate,
+		"min_upload_rate_period": l.minUploadRatePeriod,
+	}).Info("request limits enabled")
+}
+
+func isBlobUploadRequest(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return false
+	}
+	switch route.GetName() {
+	case v2.RouteNameBlobUpload, v2.RouteNameBlobUploadChunk:
+		return r.Method == http.MethodPost || r.Method == http.MethodPatch || r.Method == http.MethodPut
+	default:
+		return false
+	}
+}
+
+// limitRequest applies the request limits to r. Blob upload requests are aborted if the client transfers their body
+// too slowly, while the body of all other requests is limited in size. Requests with a body known to be too large are
+// rejected with a 413 Request Entity Too Large response. Returns a function that must be called once the request has
+// been served, and true if the request was rejected.
+func (app *App) limitRequest(w http.ResponseWriter, r *http.Request) (func(), bool) {
+	l := app.requestLimits
+	if l == nil {
+		return func() {}, false
+	}
+
+	if isBlobUploadRequest(r) {
+		if l.minUploadRate == 0 {
+			return func() {}, false
+		}
+		body := r.Body
+		mr := newMinRateReader(body, l.minUploadRate, l.minUploadRatePeriod, func() {
+			limitedRequestsCounter.WithValues(limitedReasonUploadTooSlow).Inc(1)
+			dcontext.GetLogger(r.Context()).WithField("min_upload_rate", l.minUploadRate).Warn("aborting upload transferred below the minimum rate")
+			abortRead(r, body)
+		})
+		r.Body = readCloser{Reader: mr, Closer: body}
+		return mr.stop, false
+	}
+
+	if l.maxBodySize == 0 {
+		return func() {}, false
+	}
+	if r.ContentLength > l.maxBodySize {
+		limitedRequestsCounter.WithValues(limitedReasonBodyTooLarge).Inc(1)
+		err := errcode.ErrorCodeRequestTooLarge.WithDetail(fmt.Sprintf("request body size exceeds the maximum of %d bytes", l.maxBodySize))
+		dcontext.GetLogger(r.Context()).WithError(err).Warn("request rejected")
+		if serveErr := errcode.ServeJSON(w, err); serveErr != nil {
+			dcontext.GetLogger(r.Context()).Errorf("error serving error json: %v (from %v)", serveErr, err)
+		}
+		return nil, true
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, l.maxBodySize)
+
+	return func() {}, false
+}
+
+// abortRead unblocks any pending read of the body of r. For HTTP/1 requests this is done by expiring the read deadline
+// of the underlying connection, which is only available if the server was configured with WithConn. HTTP/2 requests
+// share their connection with other requests, so their body is closed instead.
+func abortRead(r *http.Request, body io.Closer) {
+	if r.ProtoMajor >= 2 {
+		body.Close()
+		return
+	}
+	if c := connFromContext(r.Context()); c != nil {
+		c.SetReadDeadline(time.Now())
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// minRateReader is an io.Reader that aborts reads if less than a minimum number of bytes was read over a period. The
+// first period starts with the first read.
+type minRateReader struct {
+	r        io.Reader
+	minBytes int64
+	period   time.Duration
+	abort    func()
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	read    int64
+	aborted bool
+	stopped bool
+}
+
+// newMinRateReader wraps r in a minRateReader requiring a rate of at least minRate bytes per second over each period.
+// abort is called once the rate is not met, to unblock any pending reads.
+func newMinRateReader(r io.Reader, minRate int64, period time.Duration, abort func()) *minRateReader {
+	return &minRateReader{
+		r:        r,
+		minBytes: int64(float64(minRate) * period.Seconds()),
+		period:   period,
+		abort:    abort,
+	}
+}
+
+// Read implements io.Reader.
+func (mr *minRateReader) Read(p []byte) (int, error) {
+	mr.mu.Lock()
+	if mr.aborted {
+		mr.mu.Unlock()
+		return 0, errUploadTooSlow
+	}
+	if mr.timer == nil && !mr.stopped {
+		mr.timer = time.AfterFunc(mr.period, mr.check)
+	}
+	mr.mu.Unlock()
+
+	n, err := mr.r.Read(p)
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.read += int64(n)
+	if mr.aborted {
+		return n, errUploadTooSlow
+	}
+	if err != nil {
+		// Nothing is left to read once the body is consumed or failed, so measuring must stop here. Otherwise, reads
+		// of the connection would be aborted while a handler is still processing the body, e.g. writing it to storage.
+		mr.stopLocked()
+	}
+	return n, err
+}
+
+// check is called at the end of each period, aborting reads if the minimum rate was not met.
+func (mr *minRateReader) check() {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if mr.stopped {
+		return
+	}
+	if mr.read < mr.minBytes {
+		mr.aborted = true
+		mr.abort()
+		return
+	}
+	mr.read = 0
+	mr.timer.Reset(mr.period)
+}
+
+// stop stops measuring the transfer rate. It must be called once the reader is no longer used, although this is done
+// automatically once a read fails, including when reaching the end of the body.
+func (mr *minRateReader) stop() {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.stopLocked()
+}
+
+func (mr *minRateReader) stopLocked() {
+	mr.stopped = true
+	if mr.timer != nil {
+		mr.timer.Stop()
+	}
+}
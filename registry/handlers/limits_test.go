@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRequestLimits(t *testing.T) {
+	l, err := newRequestLimits(configuration.Limits{})
+	require.NoError(t, err)
+	require.Nil(t, l)
+
+	l, err = newRequestLimits(configuration.Limits{MaxBodySize: 10})
+	require.NoError(t, err)
+	require.Equal(t, &requestLimits{maxBodySize: 10, minUploadRatePeriod: defaultMinUploadRatePeriod}, l)
+
+	l, err = newRequestLimits(configuration.Limits{MinUploadRate: 1024, MinUploadRatePeriod: time.Minute})
+	require.NoError(t, err)
+	require.Equal(t, &requestLimits{minUploadRate: 1024, minUploadRatePeriod: time.Minute}, l)
+
+	_, err = newRequestLimits(configuration.Limits{MaxBodySize: -1})
+	require.EqualError(t, err, "maxbodysize must not be negative, got -1")
+	_, err = newRequestLimits(configuration.Limits{MinUploadRate: -1})
+	require.EqualError(t, err, "minuploadrate must not be negative, got -1")
+	_, err = newRequestLimits(configuration.Limits{MinUploadRate: 1, MinUploadRatePeriod: -time.Second})
+	require.EqualError(t, err, "minuploadrateperiod must not be negative, got -1s")
+}
+
+// trickleReader is an io.Reader that returns one byte per read, sleeping for delay before each one.
+type trickleReader struct {
+	delay time.Duration
+	n     int
+}
+
+func (r *trickleReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.delay)
+	r.n--
+	p[0] = 'a'
+	return 1, nil
+}
+
+func TestMinRateReader(t *testing.T) {
+	var aborted bool
+	mr := newMinRateReader(bytes.NewReader(make([]byte, 1<<20)), 1024, 50*time.Millisecond, func() { aborted = true })
+	defer mr.stop()
+
+	n, err := io.Copy(ioutil.Discard, mr)
+	require.NoError(t, err)
+	require.Equal(t, int64(1<<20), n)
+	require.False(t, aborted)
+}
+
+func TestMinRateReader_TooSlow(t *testing.T) {
+	var aborted bool
+	r := &trickleReader{delay: 20 * time.Millisecond, n: 100}
+	mr := newMinRateReader(r, 1024, 50*time.Millisecond, func() { aborted = true })
+	defer mr.stop()
+
+	_, err := io.Copy(ioutil.Discard, mr)
+	require.ErrorIs(t, err, errUploadTooSlow)
+	require.True(t, aborted)
+}
+
+func TestMinRateReader_Stop(t *testing.T) {
+	var aborted bool
+	r := &trickleReader{delay: 20 * time.Millisecond, n: 1}
+	mr := newMinRateReader(r, 1024, 50*time.Millisecond, func() { aborted = true })
+
+	_, err := io.Copy(ioutil.Discard, mr)
+	require.NoError(t, err)
+	mr.stop()
+
+	time.Sleep(100 * time.Millisecond)
+	require.False(t, aborted)
+}
+
+func TestMinRateReader_SlowHandlerAfterEOF(t *testing.T) {
+	var aborted int32
+	errc := make(chan error, 1)
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mr := newMinRateReader(r.Body, 1024, 50*time.Millisecond, func() {
+			atomic.StoreInt32(&aborted, 1)
+			abortRead(r, r.Body)
+		})
+		defer mr.stop()
+
+		if _, err := io.Copy(ioutil.Discard, mr); err != nil {
+			errc <- err
+			return
+		}
+		// process the body for several periods after reading it, e.g. as when writing it to a slow storage backend
+		time.Sleep(200 * time.Millisecond)
+		errc <- r.Context().Err()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	s.Config.ConnContext = WithConn
+	s.Start()
+	defer s.Close()
+
+	resp, err := http.Post(s.URL, "application/octet-stream", bytes.NewReader(make([]byte, 10)))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.NoError(t, <-errc)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Zero(t, atomic.LoadInt32(&aborted))
+}
+
+func TestAbortRead_StalledClient(t *testing.T) {
+	errc := make(chan error, 1)
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mr := newMinRateReader(r.Body, 1024, 50*time.Millisecond, func() { abortRead(r, r.Body) })
+		defer mr.stop()
+
+		_, err := io.Copy(ioutil.Discard, mr)
+		errc <- err
+	}))
+	s.Config.ConnContext = WithConn
+	s.Start()
+	defer s.Close()
+
+	c, err := net.Dial("tcp", s.Listener.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+
+	// send the request headers, but never the body
+	_, err = fmt.Fprintf(c, "PATCH / HTTP/1.1\r\nHost: %s\r\nContent-Length: 100\r\n\r\n", s.Listener.Addr())
+	require.NoError(t, err)
+
+	select {
+	case err := <-errc:
+		require.ErrorIs(t, err, errUploadTooSlow)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled read was not aborted")
+	}
+}
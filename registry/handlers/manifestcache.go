@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	prometheus "github.com/docker/distribution/metrics"
+	"github.com/docker/distribution/notifications"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/go-redis/redis/v8"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// defaultSharedManifestCacheTTL is the default time for which a manifest is cached in redis. It bounds the time for
+	// which a stale entry may be served if an invalidation is missed.
+	defaultSharedManifestCacheTTL = 10 * time.Minute
+
+	manifestCacheKeyPrefix = "registry:manifest:"
+
+	manifestCacheResultHit   = "hit"
+	manifestCacheResultMiss  = "miss"
+	manifestCacheResultError = "error"
+)
+
+var (
+	// sharedManifestCacheCounter is the number of lookups in the shared manifest cache
+	sharedManifestCacheCounter = prometheus.StorageNamespace.NewLabeledCounter("shared_manifest_cache_requests", "The number of lookups in the shared manifest cache", "result")
+)
+
+// sharedManifestCache caches manifest payloads across registry instances, keyed by repository and digest and by
+// repository and tag. Only content fields (digest, media type, schema version and payload) are cached.
+type sharedManifestCache interface {
+	// GetByTag returns the manifest tagged with tag in the repository with path repoPath, or nil if not cached.
+	GetByTag(ctx context.Context, repoPath, tag string) (*models.Manifest, error)
+	// GetByDigest returns the manifest with digest dgst in the repository with path repoPath, or nil if not cached.
+	GetByDigest(ctx context.Context, repoPath string, dgst digest.Digest) (*models.Manifest, error)
+	// Generation returns the current generation of the cached manifests of the repository with path repoPath. It must
+	// be read before looking up a manifest in the database, and then passed to Set.
+	Generation(ctx context.Context, repoPath string) (int64, error)
+	// Set caches manifest m as found in the repository with path repoPath. If tag is not empty, m is also cached as the
+	// manifest currently tagged with tag. Nothing is cached unless the generation of the repository is still gen, as
+	// otherwise m may have been read before a concurrent invalidation and be stale already.
+	Set(ctx context.Context, repoPath, tag string, m *models.Manifest, gen int64) error
+	// InvalidateTags drops the cached manifests for the given tags. This must be called whenever a tag is created, moved
+	// or deleted. All invalidations increment the generation of the repository.
+	InvalidateTags(ctx context.Context, repoPath string, tags ...string) error
+	// InvalidateManifest drops the cached manifest with digest dgst, along with all tags cached as pointing to it.
+	// This must be called whenever a manifest is deleted from a repository.
+	InvalidateManifest(ctx context.Context, repoPath string, dgst digest.Digest) error
+	// InvalidateRepository drops all cached manifests of the repository with path repoPath. This must be called
+	// whenever a repository is renamed.
+	InvalidateRepository(ctx context.Context, repoPath string) error
+}
+
+// cachedManifest is the representation of a manifest in the shared manifest cache.
+type cachedManifest struct {
+	Digest        digest.Digest `json:"digest"`
+	MediaType     string        `json:"media_type"`
+	SchemaVersion int           `json:"schema_version"`
+	Payload       []byte        `json:"payload"`
+}
+
+// redisManifestCache is a sharedManifestCache backed by redis. Each manifest is stored as a JSON string under a digest
+// key and, if found by tag, a tag key. The tags cached for each digest are tracked in a set, so that they can be
+// invalidated along with the manifest, and so are all keys of each repository. All keys expire after ttl. Each
+// repository has a generation counter, incremented by every invalidation and checked atomically when caching a
+// manifest, so that a lookup racing with a write can not cache a manifest read before the write. The repository path
+// is wrapped in a hash tag, so that all keys of a repository are stored in the same slot of a redis cluster.
+type redisManifestCache struct {
+	client redis.UniversalClient
+	ttl    time.Duration
+}
+
+func newRedisManifestCache(client redis.UniversalClient, ttl time.Duration) *redisManifestCache {
+	if ttl <= 0 {
+		ttl = defaultSharedManifestCacheTTL
+	}
+
+	return &redisManifestCache{client: client, ttl: ttl}
+}
+
+func manifestCacheDigestKey(repoPath string, dgst digest.Digest) string {
+	return manifestCacheKeyPrefix + "{" + repoPath + "}:digest:" + dgst.String()
+}
+
+func manifestCacheDigestTagsKey(repoPath string, dgst digest.Digest) string {
+	return manifestCacheDigestKey(repoPath, dgst) + ":tags"
+}
+
+func manifestCacheTagKey(repoPath, tag string) string {
+	return manifestCacheKeyPrefix + "{" + repoPath + "}:tag:" + tag
+}
+
+func manifestCacheRepositoryKeysKey(repoPath string) string {
+	return manifestCacheKeyPrefix + "{" + repoPath + "}:keys"
+}
+
+func manifestCacheGenerationKey(repoPath string) string {
+	return manifestCacheKeyPrefix + "{" + repoPath + "}:generation"
+}
+
+// manifestCacheSetScript caches a manifest if the generation of its repository did not change. KEYS are the generation,
+// repository keys, digest, digest tags and tag keys, the last two only if cached by tag. ARGV are the expected
+// generation, the cached manifest, the TTL in milliseconds and the tag.
+var manifestCacheSetScript = redis.NewScript(`
+local gen = redis.call("GET", KEYS[1]) or "0"
+if gen ~= ARGV[1] then
+	return 0
+end
+redis.call("SET", KEYS[3], ARGV[2], "PX", ARGV[3])
+redis.call("SADD", KEYS[2], KEYS[3])
+if #KEYS > 3 then
+	redis.call("SET", KEYS[5], ARGV[2], "PX", ARGV[3])
+	redis.call("SADD", KEYS[4], ARGV[4])
+	redis.call("PEXPIRE", KEYS[4], ARGV[3])
+	redis.call("SADD", KEYS[2], KEYS[5], KEYS[4])
+end
+redis.call("PEXPIRE", KEYS[2], ARGV[3])
+return 1
+`)
+
+func (c *redisManifestCache) get(ctx context.Context, key string) (*models.Manifest, error) {
+	p, err := c.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getting cached manifest: %w", err)
+	}
+
+	var cm cachedManifest
+	if err := json.Unmarshal(p, &cm); err != nil {
+		return nil, fmt.Errorf("unmarshaling cached manifest: %w", err)
+	}
+
+	return &models.Manifest{
+		Digest:        cm.Digest,
+		MediaType:     cm.MediaType,
+		SchemaVersion: cm.SchemaVersion,
+		Payload:       cm.Payload,
+	}, nil
+}
+
+// GetByTag implements sharedManifestCache.
+func (c *redisManifestCache) GetByTag(ctx context.Context, repoPath, tag string) (*models.Manifest, error) {
+	return c.get(ctx, manifestCacheTagKey(repoPath, tag))
+}
+
+// GetByDigest implements sharedManifestCache.
+func (c *redisManifestCache) GetByDigest(ctx context.Context, repoPath string, dgst digest.Digest) (*models.Manifest, error) {
+	return c.get(ctx, manifestCacheDigestKey(repoPath, dgst))
+}
+
+// Generation implements sharedManifestCache.
+func (c *redisManifestCache) Generation(ctx context.Context, repoPath string) (int64, error) {
+	gen, err := c.client.Get(ctx, manifestCacheGenerationKey(repoPath)).Int64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("getting cache generation: %w", err)
+	}
+
+	return gen, nil
+}
+
+// Set implements sharedManifestCache.
+func (c *redisManifestCache) Set(ctx context.Context, repoPath, tag string, m *models.Manifest, gen int64) error {
+	p, err := json.Marshal(cachedManifest{
+		Digest:        m.Digest,
+		MediaType:     m.MediaType,
+		SchemaVersion: m.SchemaVersion,
+		Payload:       m.Payload,
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling manifest: %w", err)
+	}
+
+	keys := []string{
+		manifestCacheGenerationKey(repoPath),
+		manifestCacheRepositoryKeysKey(repoPath),
+		manifestCacheDigestKey(repoPath, m.Digest),
+	}
+	if tag != "" {
+		keys = append(keys, manifestCacheDigestTagsKey(repoPath, m.Digest), manifestCacheTagKey(repoPath, tag))
+	}
+	args := []interface{}{strconv.FormatInt(gen, 10), p, c.ttl.Milliseconds(), tag}
+	if err := manifestCacheSetScript.Run(ctx, c.client, keys, args...).Err(); err != nil {
+		return fmt.Errorf("caching manifest: %w", err)
+	}
+
+	return nil
+}
+
+// invalidate deletes keys and increments the generation of the repository with path repoPath, atomically.
+func (c *redisManifestCache) invalidate(ctx context.Context, repoPath string, keys ...string) error {
+	genKey := manifestCacheGenerationKey(repoPath)
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, keys...)
+		pipe.Incr(ctx, genKey)
+		pipe.Expire(ctx, genKey, c.ttl)
+		return nil
+	})
+
+	return err
+}
+
+// InvalidateTags implements sharedManifestCache.
+func (c *redisManifestCache) InvalidateTags(ctx context.Context, repoPath string, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		keys = append(keys, manifestCacheTagKey(repoPath, tag))
+	}
+	if err := c.invalidate(ctx, repoPath, keys...); err != nil {
+		return fmt.Errorf("invalidating cached tags: %w", err)
+	}
+
+	return nil
+}
+
+// InvalidateManifest implements sharedManifestCache.
+func (c *redisManifestCache) InvalidateManifest(ctx context.Context, repoPath string, dgst digest.Digest) error {
+	tagsKey := manifestCacheDigestTagsKey(repoPath, dgst)
+	tags, err := c.client.SMembers(ctx, tagsKey).Result()
+	if err != nil {
+		return fmt.Errorf("finding cached tags: %w", err)
+	}
+
+	keys := []string{manifestCacheDigestKey(repoPath, dgst), tagsKey}
+	for _, tag := range tags {
+		keys = append(keys, manifestCacheTagKey(repoPath, tag))
+	}
+	if err := c.invalidate(ctx, repoPath, keys...); err != nil {
+		return fmt.Errorf("invalidating cached manifest: %w", err)
+	}
+
+	return nil
+}
+
+// InvalidateRepository implements sharedManifestCache.
+func (c *redisManifestCache) InvalidateRepository(ctx context.Context, repoPath string) error {
+	keysKey := manifestCacheRepositoryKeysKey(repoPath)
+	keys, err := c.client.SMembers(ctx, keysKey).Result()
+	if err != nil {
+		return fmt.Errorf("finding cached keys: %w", err)
+	}
+
+	keys = append(keys, keysKey)
+	if err := c.invalidate(ctx, repoPath, keys...); err != nil {
+		return fmt.Errorf("invalidating cached repository: %w", err)
+	}
+
+	return nil
+}
+
+// getSharedCachedManifest looks up a manifest in the shared manifest cache using get. Errors are logged and reported
+// as misses, so that requests are served from the database if the cache is unavailable.
+func getSharedCachedManifest(ctx context.Context, get func() (*models.Manifest, error)) *models.Manifest {
+	m, err := get()
+	switch {
+	case err != nil:
+		sharedManifestCacheCounter.WithValues(manifestCacheResultError).Inc(1)
+		dcontext.GetLogger(ctx).WithError(err).Warn("failed to get manifest from shared cache")
+		return nil
+	case m == nil:
+		sharedManifestCacheCounter.WithValues(manifestCacheResultMiss).Inc(1)
+		return nil
+	default:
+		sharedManifestCacheCounter.WithValues(manifestCacheResultHit).Inc(1)
+		return m
+	}
+}
+
+// sharedManifestCacheGeneration returns the generation of the cached manifests of the repository with path repoPath,
+// to be read before looking up a manifest in the database and passed to cacheSharedManifest. Returns false if the
+// shared manifest cache is disabled or the generation could not be read, in which case nothing should be cached.
+func (app *App) sharedManifestCacheGeneration(ctx context.Context, repoPath string) (int64, bool) {
+	if app.sharedManifestCache == nil {
+		return 0, false
+	}
+	gen, err := app.sharedManifestCache.Generation(ctx, repoPath)
+	if err != nil {
+		dcontext.GetLogger(ctx).WithError(err).Warn("failed to get shared manifest cache generation")
+		return 0, false
+	}
+
+	return gen, true
+}
+
+// cacheSharedManifest caches manifest m, as found in the repository with path repoPath and optionally by tag, unless
+// the repository was invalidated since generation gen was read. Errors are logged but otherwise ignored.
+func (app *App) cacheSharedManifest(ctx context.Context, repoPath, tag string, m *models.Manifest, gen int64) {
+	if app.sharedManifestCache == nil {
+		return
+	}
+	if err := app.sharedManifestCache.Set(ctx, repoPath, tag, m, gen); err != nil {
+		dcontext.GetLogger(ctx).WithError(err).Warn("failed to cache manifest in shared cache")
+	}
+}
+
+// invalidateCachedTags drops the given tags of the repository with path repoPath from the manifest caches. This is
+// the single invalidation point to call whenever tags are created, moved or deleted. Only the shared manifest cache
+// is keyed by tag, but the invalidation also bumps the repository generation, so that manifests cached in-process by
+// other registry instances are not served anymore. Errors are logged but otherwise ignored, stale entries then expire
+// after the cache TTL.
+func (app *App) invalidateCachedTags(ctx context.Context, repoPath string, tags ...string) {
+	if app.sharedManifestCache == nil {
+		return
+	}
+	if err := app.sharedManifestCache.InvalidateTags(ctx, repoPath, tags...); err != nil {
+		dcontext.GetLogger(ctx).WithError(err).Error("failed to invalidate tags in shared manifest cache")
+	}
+}
+
+// invalidateCachedManifest drops the manifest with digest dgst of the repository with path repoPath from the manifest
+// caches. This is the single invalidation point to call whenever a manifest is deleted from a repository. Errors are
+// logged but otherwise ignored, stale entries then expire after the cache TTL.
+func (app *App) invalidateCachedManifest(ctx context.Context, repoPath string, dgst digest.Digest) {
+	if app.manifestCache != nil {
+		app.manifestCache.Remove(repoPath, dgst)
+	}
+	if app.sharedManifestCache == nil {
+		return
+	}
+	if err := app.sharedManifestCache.InvalidateManifest(ctx, repoPath, dgst); err != nil {
+		dcontext.GetLogger(ctx).WithError(err).Error("failed to invalidate manifest in shared manifest cache")
+	}
+}
+
+// invalidateCachedRepository drops all manifests of the repository with path repoPath from the manifest caches. This
+// is the single invalidation point to call whenever a repository is renamed. Errors are logged but otherwise ignored,
+// stale entries then expire after the cache TTL.
+func (app *App) invalidateCachedRepository(ctx context.Context, repoPath string) {
+	if app.manifestCache != nil {
+		app.manifestCache.RemoveRepository(repoPath)
+	}
+	if app.sharedManifestCache == nil {
+		return
+	}
+	if err := app.sharedManifestCache.InvalidateRepository(ctx, repoPath); err != nil {
+		dcontext.GetLogger(ctx).WithError(err).Error("failed to invalidate repository in shared manifest cache")
+	}
+}
+
+// cacheInvalidatingGCListener invalidates the manifests deleted by online GC in the manifest caches, before passing
+// the event on to the next listener, if any.
+type cacheInvalidatingGCListener struct {
+	app  *App
+	next notifications.GCListener
+}
+
+// BlobDeleted implements notifications.GCListener.
+func (l *cacheInvalidatingGCListener) BlobDeleted(dgst digest.Digest) error {
+	if l.next == nil {
+		return nil
+	}
+	return l.next.BlobDeleted(dgst)
+}
+
+// ManifestDeleted implements notifications.GCListener.
+func (l *cacheInvalidatingGCListener) ManifestDeleted(repo string, dgst digest.Digest) error {
+	l.app.invalidateCachedManifest(l.app.Context, repo, dgst)
+	if l.next == nil {
+		return nil
+	}
+	return l.next.ManifestDeleted(repo, dgst)
+}
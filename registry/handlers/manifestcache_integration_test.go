@@ -0,0 +1,193 @@
+// +build integration
+
+package handlers
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/go-redis/redis/v8"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedisManifestCache(t *testing.T, ttl time.Duration) *redisManifestCache {
+	t.Helper()
+
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("the 'REDIS_ADDR' environment variable must be set to enable this test")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+
+	return newRedisManifestCache(client, ttl)
+}
+
+func testCachedManifest(payload string) *models.Manifest {
+	return &models.Manifest{
+		Digest:        digest.FromString(payload),
+		MediaType:     "application/vnd.docker.distribution.manifest.v2+json",
+		SchemaVersion: 2,
+		Payload:       []byte(payload),
+	}
+}
+
+// setCachedManifest caches m in c at the current generation of the repository.
+func setCachedManifest(t *testing.T, c *redisManifestCache, repoPath, tag string, m *models.Manifest) {
+	t.Helper()
+
+	ctx := context.Background()
+	gen, err := c.Generation(ctx, repoPath)
+	require.NoError(t, err)
+	require.NoError(t, c.Set(ctx, repoPath, tag, m, gen))
+}
+
+func TestRedisManifestCache_Set(t *testing.T) {
+	c := newTestRedisManifestCache(t, time.Minute)
+	ctx := context.Background()
+	repoPath := "foo/" + t.Name()
+	m := testCachedManifest(`{"foo":"bar"}`)
+
+	got, err := c.GetByTag(ctx, repoPath, "latest")
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	setCachedManifest(t, c, repoPath, "latest", m)
+	defer c.InvalidateRepository(ctx, repoPath)
+
+	got, err = c.GetByTag(ctx, repoPath, "latest")
+	require.NoError(t, err)
+	require.Equal(t, m, got)
+	got, err = c.GetByDigest(ctx, repoPath, m.Digest)
+	require.NoError(t, err)
+	require.Equal(t, m, got)
+
+	// entries are scoped to the repository
+	got, err = c.GetByDigest(ctx, repoPath+"/other", m.Digest)
+	require.NoError(t, err)
+	require.Nil(t, got)
+}
+
+func TestRedisManifestCache_Set_Expires(t *testing.T) {
+	c := newTestRedisManifestCache(t, 100*time.Millisecond)
+	ctx := context.Background()
+	repoPath := "foo/" + t.Name()
+	m := testCachedManifest(`{"foo":"bar"}`)
+
+	setCachedManifest(t, c, repoPath, "latest", m)
+	time.Sleep(200 * time.Millisecond)
+
+	got, err := c.GetByTag(ctx, repoPath, "latest")
+	require.NoError(t, err)
+	require.Nil(t, got)
+}
+
+func TestRedisManifestCache_InvalidateTags(t *testing.T) {
+	c := newTestRedisManifestCache(t, time.Minute)
+	ctx := context.Background()
+	repoPath := "foo/" + t.Name()
+	m := testCachedManifest(`{"foo":"bar"}`)
+
+	setCachedManifest(t, c, repoPath, "latest", m)
+	setCachedManifest(t, c, repoPath, "stable", m)
+	defer c.InvalidateRepository(ctx, repoPath)
+
+	require.NoError(t, c.InvalidateTags(ctx, repoPath, "latest"))
+
+	got, err := c.GetByTag(ctx, repoPath, "latest")
+	require.NoError(t, err)
+	require.Nil(t, got)
+	got, err = c.GetByTag(ctx, repoPath, "stable")
+	require.NoError(t, err)
+	require.Equal(t, m, got)
+	// the manifest remains cached by digest
+	got, err = c.GetByDigest(ctx, repoPath, m.Digest)
+	require.NoError(t, err)
+	require.Equal(t, m, got)
+}
+
+func TestRedisManifestCache_InvalidateManifest(t *testing.T) {
+	c := newTestRedisManifestCache(t, time.Minute)
+	ctx := context.Background()
+	repoPath := "foo/" + t.Name()
+	m := testCachedManifest(`{"foo":"bar"}`)
+	other := testCachedManifest(`{"foo":"baz"}`)
+
+	setCachedManifest(t, c, repoPath, "latest", m)
+	setCachedManifest(t, c, repoPath, "stable", m)
+	setCachedManifest(t, c, repoPath, "other", other)
+	defer c.InvalidateRepository(ctx, repoPath)
+
+	require.NoError(t, c.InvalidateManifest(ctx, repoPath, m.Digest))
+
+	// tags pointing to the manifest are invalidated along with it
+	for _, tag := range []string{"latest", "stable"} {
+		got, err := c.GetByTag(ctx, repoPath, tag)
+		require.NoError(t, err)
+		require.Nil(t, got)
+	}
+	got, err := c.GetByDigest(ctx, repoPath, m.Digest)
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	got, err = c.GetByTag(ctx, repoPath, "other")
+	require.NoError(t, err)
+	require.Equal(t, other, got)
+}
+
+func TestRedisManifestCache_InvalidateRepository(t *testing.T) {
+	c := newTestRedisManifestCache(t, time.Minute)
+	ctx := context.Background()
+	repoPath := "foo/" + t.Name()
+	m := testCachedManifest(`{"foo":"bar"}`)
+
+	setCachedManifest(t, c, repoPath, "latest", m)
+	setCachedManifest(t, c, repoPath+"/other", "latest", m)
+	defer c.InvalidateRepository(ctx, repoPath+"/other")
+
+	require.NoError(t, c.InvalidateRepository(ctx, repoPath))
+
+	got, err := c.GetByTag(ctx, repoPath, "latest")
+	require.NoError(t, err)
+	require.Nil(t, got)
+	got, err = c.GetByDigest(ctx, repoPath, m.Digest)
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	got, err = c.GetByTag(ctx, repoPath+"/other", "latest")
+	require.NoError(t, err)
+	require.Equal(t, m, got)
+}
+
+func TestRedisManifestCache_Set_StaleGeneration(t *testing.T) {
+	c := newTestRedisManifestCache(t, time.Minute)
+	ctx := context.Background()
+	repoPath := "foo/" + t.Name()
+	m := testCachedManifest(`{"foo":"bar"}`)
+	defer c.InvalidateRepository(ctx, repoPath)
+
+	// a lookup reads the generation before reading the manifest from the database, and the tag is then moved and
+	// invalidated before the lookup caches what it read
+	gen, err := c.Generation(ctx, repoPath)
+	require.NoError(t, err)
+	require.NoError(t, c.InvalidateTags(ctx, repoPath, "latest"))
+	require.NoError(t, c.Set(ctx, repoPath, "latest", m, gen))
+
+	got, err := c.GetByTag(ctx, repoPath, "latest")
+	require.NoError(t, err)
+	require.Nil(t, got)
+	got, err = c.GetByDigest(ctx, repoPath, m.Digest)
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	// lookups starting after the invalidation are cached
+	setCachedManifest(t, c, repoPath, "latest", m)
+	got, err = c.GetByTag(ctx, repoPath, "latest")
+	require.NoError(t, err)
+	require.Equal(t, m, got)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingGCListener is a notifications.GCListener that records the deleted manifests.
+type recordingGCListener struct {
+	manifests []digest.Digest
+}
+
+// BlobDeleted implements notifications.GCListener.
+func (l *recordingGCListener) BlobDeleted(digest.Digest) error {
+	return nil
+}
+
+// ManifestDeleted implements notifications.GCListener.
+func (l *recordingGCListener) ManifestDeleted(_ string, dgst digest.Digest) error {
+	l.manifests = append(l.manifests, dgst)
+	return nil
+}
+
+func newManifestCacheTestApp(t *testing.T) (*App, *models.Manifest) {
+	t.Helper()
+
+	m := &models.Manifest{SchemaVersion: 2, Digest: digest.FromString("foo"), Payload: models.Payload("foo")}
+	app := &App{Context: context.Background(), manifestCache: datastore.NewManifestCache(0, 0)}
+	app.manifestCache.Add("foo/bar", m, 0)
+	app.manifestCache.Add("foo/baz", m, 0)
+
+	return app, m
+}
+
+func TestApp_InvalidateCachedManifest(t *testing.T) {
+	app, m := newManifestCacheTestApp(t)
+
+	app.invalidateCachedManifest(app.Context, "foo/bar", m.Digest)
+
+	_, ok := app.manifestCache.Get("foo/bar", m.Digest, 0)
+	require.False(t, ok)
+	_, ok = app.manifestCache.Get("foo/baz", m.Digest, 0)
+	require.True(t, ok)
+}
+
+func TestApp_InvalidateCachedRepository(t *testing.T) {
+	app, m := newManifestCacheTestApp(t)
+
+	app.invalidateCachedRepository(app.Context, "foo/bar")
+
+	_, ok := app.manifestCache.Get("foo/bar", m.Digest, 0)
+	require.False(t, ok)
+	_, ok = app.manifestCache.Get("foo/baz", m.Digest, 0)
+	require.True(t, ok)
+}
+
+func TestCacheInvalidatingGCListener_ManifestDeleted(t *testing.T) {
+	app, m := newManifestCacheTestApp(t)
+	next := &recordingGCListener{}
+	l := &cacheInvalidatingGCListener{app: app, next: next}
+
+	require.NoError(t, l.ManifestDeleted("foo/bar", m.Digest))
+
+	_, ok := app.manifestCache.Get("foo/bar", m.Digest, 0)
+	require.False(t, ok)
+	require.Equal(t, []digest.Digest{m.Digest}, next.manifests)
+
+	// events are not required to be passed on
+	l = &cacheInvalidatingGCListener{app: app}
+	require.NoError(t, l.ManifestDeleted("foo/baz", m.Digest))
+	require.Zero(t, app.manifestCache.Len())
+}
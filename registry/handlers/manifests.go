@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"mime"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,13 +16,17 @@ import (
 	"github.com/docker/distribution/manifest"
 	"github.com/docker/distribution/manifest/manifestlist"
 	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/docker/distribution/manifest/schema1"
 	"github.com/docker/distribution/manifest/schema2"
 	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/api/errcode"
+	gitlabv1 "github.com/docker/distribution/registry/api/gitlab/v1"
 	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
 	"github.com/docker/distribution/registry/auth"
 	"github.com/docker/distribution/registry/datastore"
 	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/storage"
 	"github.com/docker/distribution/registry/storage/validation"
 	"github.com/gorilla/handlers"
 	"github.com/opencontainers/go-digest"
@@ -30,14 +35,23 @@ import (
 )
 
 // These constants determine which architecture and OS to choose from a
-// manifest list when falling back to a schema2 manifest.
+// manifest list when falling back to a schema2 manifest, unless configured
+// otherwise.
 const (
-	defaultArch         = "amd64"
-	defaultOS           = "linux"
-	maxManifestBodySize = 4 << 20
-	imageClass          = "image"
+	defaultArch = "amd64"
+	defaultOS   = "linux"
+	imageClass  = "image"
 )
 
+// defaultManifestPayloadSizeLimit is the maximum size in bytes of pushed manifest payloads, unless configured
+// otherwise.
+const defaultManifestPayloadSizeLimit = 4 << 20
+
+// manifestPayloadTooLargeDetail describes the rejection of a manifest payload larger than limit bytes.
+func manifestPayloadTooLargeDetail(limit int64) string {
+	return fmt.Sprintf("manifest payload exceeds the size limit of %d bytes", limit)
+}
+
 type storageType int
 
 const (
@@ -84,12 +98,28 @@ type manifestHandler struct {
 	// One of tag or digest gets set, depending on what is present in context.
 	Tag    string
 	Digest digest.Digest
+
+	// deferValidation is set when the verification of the references of a pushed manifest is deferred to the
+	// asynchronous validator.
+	deferValidation bool
 }
 
 // GetManifest fetches the image manifest from the storage backend, if it exists.
 func (imh *manifestHandler) GetManifest(w http.ResponseWriter, r *http.Request) {
 	dcontext.GetLogger(imh).Debug("GetImageManifest")
 
+	// The platform query parameter is a GitLab extension, allowing clients to retrieve the manifest of a given platform
+	// directly from a manifest list, instead of fetching the manifest list first.
+	var platform *manifestlist.PlatformSpec
+	if v := r.URL.Query().Get("platform"); v != "" {
+		p, err := parsePlatform(v)
+		if err != nil {
+			imh.Errors = append(imh.Errors, gitlabv1.ErrorCodeInvalidQueryParamValue.WithDetail(map[string]string{"key": "platform", "value": v}))
+			return
+		}
+		platform = &p
+	}
+
 	manifestGetter, err := imh.newManifestGetter(r)
 	if err != nil {
 		imh.Errors = append(imh.Errors, err)
@@ -116,7 +146,7 @@ func (imh *manifestHandler) GetManifest(w http.ResponseWriter, r *http.Request)
 			errors.As(getErr, &distribution.ErrTagUnknown{}):
 			imh.Errors = append(imh.Errors, v2.ErrorCodeManifestUnknown.WithDetail(getErr))
 		case errors.Is(getErr, distribution.ErrSchemaV1Unsupported):
-			imh.Errors = append(imh.Errors, v2.ErrorCodeManifestInvalid.WithMessage("Schema 1 manifest not supported"))
+			imh.Errors = append(imh.Errors, imh.schema1UnsupportedErr())
 		default:
 			imh.Errors = append(imh.Errors, errcode.FromUnknownError(getErr))
 		}
@@ -140,10 +170,50 @@ func (imh *manifestHandler) GetManifest(w http.ResponseWriter, r *http.Request)
 	}
 
 	if manifestType == manifestSchema1 {
-		imh.Errors = append(imh.Errors, v2.ErrorCodeManifestInvalid.WithMessage("Schema 1 manifest not supported"))
+		imh.Errors = append(imh.Errors, imh.schema1UnsupportedErr())
 		return
 	}
+
+	// Resolve the manifest of the requested platform, if any. This is done before checking the client support for the
+	// manifest list type, as the manifest list itself is not returned.
+	if platform != nil && isManifestList {
+		log := dcontext.GetLoggerWithFields(imh, map[interface{}]interface{}{
+			"manifest_list_digest": imh.Digest.String(),
+			"platform":             platformString(*platform)})
+		log.Debug("selecting manifest image for requested platform")
+
+		manifest, err = imh.platformManifest(manifestList, *platform)
+		if err != nil {
+			switch err := err.(type) {
+			case distribution.ErrManifestUnknownRevision:
+				imh.Errors = append(imh.Errors, v2.ErrorCodeManifestUnknown.WithDetail(err))
+			case errcode.Error:
+				imh.Errors = append(imh.Errors, err)
+			default:
+				imh.Errors = append(imh.Errors, errcode.FromUnknownError(err))
+			}
+			return
+		}
+
+		// the resolved manifest may be of any type, so we must determine it again
+		switch manifest.(type) {
+		case *schema2.DeserializedManifest:
+			manifestType = manifestSchema2
+		case *ocischema.DeserializedManifest:
+			manifestType = ociImageManifestSchema
+		default:
+			imh.Errors = append(imh.Errors, v2.ErrorCodeManifestInvalid.WithMessage("manifest list references an unsupported manifest type"))
+			return
+		}
+	}
 	if manifestType == ociImageManifestSchema && !supports(r, ociImageManifestSchema) {
+		// Encrypted images can only be pulled as OCI manifests, as converting them to schema 1 would drop the layer
+		// annotations holding the encryption metadata. Say so explicitly, as clients are likely to report this as a
+		// missing image otherwise.
+		if m, ok := manifest.(*ocischema.DeserializedManifest); ok && m.Encrypted() {
+			imh.Errors = append(imh.Errors, v2.ErrorCodeManifestUnknown.WithMessage("encrypted OCI manifest found, but accept header does not support OCI manifests and encrypted images cannot be converted to schema 1"))
+			return
+		}
 		imh.Errors = append(imh.Errors, v2.ErrorCodeManifestUnknown.WithMessage("OCI manifest found, but accept header does not support OCI manifests"))
 		return
 	}
@@ -178,7 +248,35 @@ func (imh *manifestHandler) GetManifest(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Length", fmt.Sprint(len(p)))
 	w.Header().Set("Docker-Content-Digest", imh.Digest.String())
 	w.Header().Set("Etag", fmt.Sprintf(`"%s"`, imh.Digest))
+	if imh.useDatabase {
+		if size, ok := imageSize(manifest); ok {
+			w.Header().Set("Gitlab-Image-Size-Bytes", strconv.FormatInt(size, 10))
+		}
+	}
 	w.Write(p)
+
+	// during migration, repositories that were not migrated yet are served from the filesystem
+	if !imh.useDatabase && imh.App.manifestBackfiller != nil {
+		imh.App.manifestBackfiller.enqueue(imh, imh.Repository.Named().Name(), imh.Digest)
+	}
+}
+
+// imageSize returns the total compressed size of the image described by an image manifest, i.e., the sum of the size
+// of its configuration and layers. The returned bool is false for manifests other than image manifests, such as
+// manifest lists, for which there is no single image size.
+func imageSize(m distribution.Manifest) (int64, bool) {
+	switch m.(type) {
+	case *schema2.DeserializedManifest, *ocischema.DeserializedManifest:
+	default:
+		return 0, false
+	}
+
+	var size int64
+	for _, d := range m.References() {
+		size += d.Size
+	}
+
+	return size, true
 }
 
 func supports(req *http.Request, st storageType) bool {
@@ -220,17 +318,144 @@ func supports(req *http.Request, st storageType) bool {
 	return false
 }
 
+// rewriteManifestList selects the manifest returned in place of manifestList to clients that do not support manifest
+// lists. The configured default platforms are tried in order, see selectPlatformManifest.
 func (imh *manifestHandler) rewriteManifestList(manifestList *manifestlist.DeserializedManifestList) (distribution.Manifest, error) {
+	defaults := make([]string, 0, len(imh.App.defaultPlatforms))
+	for _, p := range imh.App.defaultPlatforms {
+		defaults = append(defaults, platformString(p))
+	}
+
 	log := dcontext.GetLoggerWithFields(imh, map[interface{}]interface{}{
 		"manifest_list_digest": imh.Digest.String(),
-		"default_arch":         defaultArch,
-		"default_os":           defaultOS})
-	log.Info("client does not advertise support for manifest lists, selecting a manifest image for the default arch and os")
+		"default_platforms":    defaults})
 
-	// Find the image manifest corresponding to the default platform.
+	desc, ok := selectPlatformManifest(manifestList.Manifests, imh.App.defaultPlatforms)
+	if !ok {
+		available := make([]string, 0, len(manifestList.Manifests))
+		for _, m := range manifestList.Manifests {
+			available = append(available, platformString(m.Platform))
+		}
+		log.WithField("available_platforms", available).Info("client does not advertise support for manifest lists and no manifest image matches the default platforms")
+
+		return nil, v2.ErrorCodeManifestUnknown.WithMessage(
+			fmt.Sprintf("manifest list found, but accept header does not support manifest lists and the list does not "+
+				"contain a manifest image for any of the default platforms (%s)", strings.Join(defaults, ", "))).
+			WithDetail(map[string]interface{}{
+				"default_platforms":   defaults,
+				"available_platforms": available,
+			})
+	}
+
+	log.WithField("selected_platform", platformString(desc.Platform)).
+		Info("client does not advertise support for manifest lists, selected a manifest image for a default platform")
+
+	return imh.manifestByDigest(desc.Digest)
+}
+
+// parsePlatform parses a platform specifier in the format os/arch[/variant], such as linux/arm64/v8.
+func parsePlatform(s string) (manifestlist.PlatformSpec, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return manifestlist.PlatformSpec{}, fmt.Errorf("invalid platform %q, expected os/arch[/variant]", s)
+	}
+	for _, p := range parts {
+		if p == "" {
+			return manifestlist.PlatformSpec{}, fmt.Errorf("invalid platform %q, expected os/arch[/variant]", s)
+		}
+	}
+
+	platform := manifestlist.PlatformSpec{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		platform.Variant = parts[2]
+	}
+
+	return platform, nil
+}
+
+// platformString formats a platform as os/arch[/variant].
+func platformString(platform manifestlist.PlatformSpec) string {
+	s := platform.OS + "/" + platform.Architecture
+	if platform.Variant != "" {
+		s += "/" + platform.Variant
+	}
+	return s
+}
+
+// matchesPlatform determines whether candidate satisfies the requested platform. The variant is only compared if
+// specified in the request.
+func matchesPlatform(requested, candidate manifestlist.PlatformSpec) bool {
+	if candidate.OS != requested.OS || candidate.Architecture != requested.Architecture {
+		return false
+	}
+	return requested.Variant == "" || candidate.Variant == requested.Variant
+}
+
+// armVariants lists the variants of each ARM architecture, from the most to the least capable. A CPU of a given variant
+// can also run images built for any of the variants that follow it.
+var armVariants = map[string][]string{
+	"arm64": {"v8"},
+	"arm":   {"v8", "v7", "v6", "v5"},
+}
+
+// defaultVariants are the variants assumed for manifest images that do not specify one, as done by container runtimes.
+var defaultVariants = map[string]string{
+	"arm64": "v8",
+	"arm":   "v7",
+}
+
+// normalizeVariant returns the variant of platform, or the default variant of its architecture if not specified.
+func normalizeVariant(platform manifestlist.PlatformSpec) string {
+	if platform.Variant == "" {
+		return defaultVariants[platform.Architecture]
+	}
+	return platform.Variant
+}
+
+// selectPlatformManifest selects a manifest image from descriptors for the first of platforms for which there is a
+// match. For each platform, in order:
+//
+//  1. If no variant is specified, the first manifest image with the same OS and architecture is selected;
+//  2. Otherwise, a manifest image with the same OS, architecture and variant is selected. Manifest images without a
+//     variant are assumed to have the default variant of their architecture (v8 for arm64 and v7 for arm);
+//  3. For ARM architectures, manifest images of less capable variants are then tried, from the most to the least
+//     capable one. For example, linux/arm/v7 falls back to linux/arm/v6 and then to linux/arm/v5.
+//
+// The returned bool is false if no manifest image matches any of the platforms.
+func selectPlatformManifest(descriptors []manifestlist.ManifestDescriptor, platforms []manifestlist.PlatformSpec) (manifestlist.ManifestDescriptor, bool) {
+	for _, platform := range platforms {
+		variants := []string{platform.Variant}
+		if platform.Variant != "" {
+			vv := armVariants[platform.Architecture]
+			for i, v := range vv {
+				if v == platform.Variant {
+					variants = vv[i:]
+					break
+				}
+			}
+		}
+
+		for _, variant := range variants {
+			for _, d := range descriptors {
+				if d.Platform.OS != platform.OS || d.Platform.Architecture != platform.Architecture {
+					continue
+				}
+				if variant == "" || normalizeVariant(d.Platform) == variant {
+					return d, true
+				}
+			}
+		}
+	}
+
+	return manifestlist.ManifestDescriptor{}, false
+}
+
+// platformManifest finds and retrieves the manifest referenced by manifestList for a given platform. If found,
+// imh.Digest is set to the digest of the returned manifest.
+func (imh *manifestHandler) platformManifest(manifestList *manifestlist.DeserializedManifestList, platform manifestlist.PlatformSpec) (distribution.Manifest, error) {
 	var manifestDigest digest.Digest
 	for _, manifestDescriptor := range manifestList.Manifests {
-		if manifestDescriptor.Platform.Architecture == defaultArch && manifestDescriptor.Platform.OS == defaultOS {
+		if matchesPlatform(platform, manifestDescriptor.Platform) {
 			manifestDigest = manifestDescriptor.Digest
 			break
 		}
@@ -238,10 +463,16 @@ func (imh *manifestHandler) rewriteManifestList(manifestList *manifestlist.Deser
 
 	if manifestDigest == "" {
 		return nil, v2.ErrorCodeManifestUnknown.WithDetail(
-			fmt.Errorf("manifest list %s does not contain a manifest image for the platform %s/%s",
-				imh.Digest, defaultOS, defaultArch))
+			fmt.Errorf("manifest list %s does not contain a manifest image for the platform %s",
+				imh.Digest, platformString(platform)))
 	}
 
+	return imh.manifestByDigest(manifestDigest)
+}
+
+// manifestByDigest retrieves the manifest referenced by a manifest list. If found, imh.Digest is set to the digest of
+// the returned manifest.
+func (imh *manifestHandler) manifestByDigest(manifestDigest digest.Digest) (distribution.Manifest, error) {
 	// TODO: We're passing an empty request here to skip etag matching logic.
 	// This should be handled more cleanly.
 	manifestGetter, err := imh.newManifestGetter(&http.Request{})
@@ -276,13 +507,19 @@ type manifestGetter interface {
 
 type dbManifestGetter struct {
 	datastore.RepositoryStore
-	repoPath string
-	req      *http.Request
+	app         *App
+	cache       *datastore.ManifestCache
+	sharedCache sharedManifestCache
+	repoPath    string
+	req         *http.Request
 }
 
 func newDBManifestGetter(imh *manifestHandler, req *http.Request) (*dbManifestGetter, error) {
 	return &dbManifestGetter{
 		RepositoryStore: datastore.NewRepositoryStore(imh.App.db),
+		app:             imh.App,
+		cache:           imh.App.manifestCache,
+		sharedCache:     imh.App.sharedManifestCache,
 		repoPath:        imh.Repository.Named().Name(),
 		req:             req,
 	}, nil
@@ -290,8 +527,27 @@ func newDBManifestGetter(imh *manifestHandler, req *http.Request) (*dbManifestGe
 
 func (g *dbManifestGetter) GetByTag(ctx context.Context, tagName string) (distribution.Manifest, digest.Digest, error) {
 	log := dcontext.GetLoggerWithFields(ctx, map[interface{}]interface{}{"repository": g.repoPath, "tag": tagName})
+
+	if g.sharedCache != nil {
+		m := getSharedCachedManifest(ctx, func() (*models.Manifest, error) {
+			return g.sharedCache.GetByTag(ctx, g.repoPath, tagName)
+		})
+		if m != nil {
+			log.Debug("manifest found in shared cache")
+			if etagMatch(g.req, m.Digest.String()) {
+				return nil, m.Digest, errETagMatches
+			}
+			manifest, err := dbPayloadToManifest(m.Payload, m.MediaType, m.SchemaVersion)
+			if err != nil {
+				return nil, "", err
+			}
+			return manifest, m.Digest, nil
+		}
+	}
+
 	log.Debug("getting manifest by tag from database")
 
+	gen, cacheable := g.app.sharedManifestCacheGeneration(ctx, g.repoPath)
 	dbRepo, err := g.FindByPath(ctx, g.repoPath)
 	if err != nil {
 		return nil, "", err
@@ -313,6 +569,13 @@ func (g *dbManifestGetter) GetByTag(ctx context.Context, tagName string) (distri
 		return nil, "", distribution.ErrTagUnknown{Tag: tagName}
 	}
 
+	if g.cache != nil && (cacheable || g.sharedCache == nil) {
+		g.cache.Add(g.repoPath, dbManifest, gen)
+	}
+	if cacheable {
+		g.app.cacheSharedManifest(ctx, g.repoPath, tagName, dbManifest, gen)
+	}
+
 	if etagMatch(g.req, dbManifest.Digest.String()) {
 		return nil, dbManifest.Digest, errETagMatches
 	}
@@ -333,6 +596,29 @@ func (g *dbManifestGetter) GetByDigest(ctx context.Context, dgst digest.Digest)
 		return nil, errETagMatches
 	}
 
+	// The generation of the repository in the shared cache is bumped by every change made by any registry instance,
+	// so manifests cached in-process are only served if it did not change since. Without a shared cache, only changes
+	// made by this instance invalidate the in-process cache and the generation is always zero.
+	gen, cacheable := g.app.sharedManifestCacheGeneration(ctx, g.repoPath)
+	useCache := g.cache != nil && (cacheable || g.sharedCache == nil)
+
+	if useCache {
+		if m, ok := g.cache.Get(g.repoPath, dgst, gen); ok {
+			log.Debug("manifest found in cache")
+			return dbPayloadToManifest(m.Payload, m.MediaType, m.SchemaVersion)
+		}
+	}
+
+	if g.sharedCache != nil {
+		m := getSharedCachedManifest(ctx, func() (*models.Manifest, error) {
+			return g.sharedCache.GetByDigest(ctx, g.repoPath, dgst)
+		})
+		if m != nil {
+			log.Debug("manifest found in shared cache")
+			return dbPayloadToManifest(m.Payload, m.MediaType, m.SchemaVersion)
+		}
+	}
+
 	dbRepo, err := g.FindByPath(ctx, g.repoPath)
 	if err != nil {
 		return nil, err
@@ -358,6 +644,13 @@ func (g *dbManifestGetter) GetByDigest(ctx context.Context, dgst digest.Digest)
 		}
 	}
 
+	if useCache {
+		g.cache.Add(g.repoPath, dbManifest, gen)
+	}
+	if cacheable {
+		g.app.cacheSharedManifest(ctx, g.repoPath, "", dbManifest, gen)
+	}
+
 	return dbPayloadToManifest(dbManifest.Payload, dbManifest.MediaType, dbManifest.SchemaVersion)
 }
 
@@ -477,20 +770,53 @@ func etagMatch(r *http.Request, etag string) bool {
 	return false
 }
 
+// ifMatchDigest returns the digest of the If-Match precondition of a manifest push, if any. Quoted values, such as the
+// ETag of a manifest fetch, are accepted.
+func ifMatchDigest(r *http.Request) (digest.Digest, bool) {
+	v := strings.Trim(strings.TrimSpace(r.Header.Get("If-Match")), `"`)
+	if v == "" {
+		return "", false
+	}
+	return digest.Digest(v), true
+}
+
+// tagPreconditionFailedErr builds the error returned when the If-Match precondition of a manifest push by tag does not
+// hold. currentDigest is empty if the tag does not exist.
+func tagPreconditionFailedErr(tagName string, currentDigest digest.Digest) errcode.Error {
+	return v2.ErrorCodePreconditionFailed.WithDetail(map[string]string{"tag": tagName, "digest": currentDigest.String()})
+}
+
 // PutManifest validates and stores a manifest in the registry.
 func (imh *manifestHandler) PutManifest(w http.ResponseWriter, r *http.Request) {
 	log := dcontext.GetLogger(imh)
 	log.Debug("PutImageManifest")
+
+	if err := imh.repositoryNames.Validate(imh.Repository.Named().Name()); err != nil {
+		imh.Errors = append(imh.Errors, v2.ErrorCodeNameInvalid.WithDetail(err))
+		return
+	}
+
 	manifests, err := imh.Repository.Manifests(imh)
 	if err != nil {
 		imh.Errors = append(imh.Errors, err)
 		return
 	}
 
+	limit := imh.manifestPayloadSizeLimit
+	if r.ContentLength > limit {
+		imh.Errors = append(imh.Errors, v2.ErrorCodeManifestInvalid.WithDetail(manifestPayloadTooLargeDetail(limit)))
+		return
+	}
+
 	var jsonBuf bytes.Buffer
-	if err := copyFullPayload(imh, w, r, &jsonBuf, maxManifestBodySize, "image manifest PUT"); err != nil {
+	if err := copyFullPayload(imh, w, r, &jsonBuf, limit, "image manifest PUT"); err != nil {
 		// copyFullPayload reports the error if necessary
-		imh.Errors = append(imh.Errors, v2.ErrorCodeManifestInvalid.WithDetail(err.Error()))
+		detail := err.Error()
+		if int64(jsonBuf.Len()) >= limit {
+			// the body was cut off at the limit, which is only checked upfront if a Content-Length was provided
+			detail = manifestPayloadTooLargeDetail(limit)
+		}
+		imh.Errors = append(imh.Errors, v2.ErrorCodeManifestInvalid.WithDetail(detail))
 		return
 	}
 
@@ -500,6 +826,23 @@ func (imh *manifestHandler) PutManifest(w http.ResponseWriter, r *http.Request)
 		imh.Errors = append(imh.Errors, v2.ErrorCodeManifestInvalid.WithDetail(err))
 		return
 	}
+	if _, ok := manifest.(*schema1.SignedManifest); ok {
+		imh.Errors = append(imh.Errors, imh.schema1UnsupportedErr())
+		return
+	}
+	if err := imh.manifestMediaTypes.Validate(manifest); err != nil {
+		imh.Errors = append(imh.Errors, v2.ErrorCodeManifestInvalid.WithDetail(err.Error()))
+		return
+	}
+	if err := imh.manifestFields.Validate(manifest); err != nil {
+		var fieldsErr validation.ErrUnknownManifestFields
+		if errors.As(err, &fieldsErr) {
+			imh.Errors = append(imh.Errors, v2.ErrorCodeManifestInvalid.WithMessage(err.Error()).WithDetail(map[string][]string{"unknown_fields": fieldsErr.Fields}))
+		} else {
+			imh.Errors = append(imh.Errors, v2.ErrorCodeManifestInvalid.WithDetail(err.Error()))
+		}
+		return
+	}
 
 	if imh.Digest != "" {
 		if desc.Digest != imh.Digest {
@@ -532,6 +875,86 @@ func (imh *manifestHandler) PutManifest(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if err := imh.applyRepositoryQuotas(); err != nil {
+		imh.Errors = append(imh.Errors, err)
+		return
+	}
+
+	// Manifests pushed by trusted subjects are accepted after the structural checks above, deferring the verification of
+	// their references, configuration and platforms to the asynchronous validator. If its queue is full, the manifest
+	// is validated synchronously as usual.
+	var validationQueued bool
+	if v := imh.App.asyncValidator; v != nil && v.trusted(imh) && v.reserve() {
+		defer func() {
+			// once queued, the slot is released by the validator
+			if !validationQueued {
+				v.release()
+			}
+		}()
+
+		skipManifests, err := imh.Repository.Manifests(imh, storage.SkipLayerVerification())
+		if err != nil {
+			log.WithError(err).Error("failed to defer manifest validation, validating synchronously")
+		} else {
+			manifests = skipManifests
+			imh.deferValidation = true
+		}
+	}
+
+	// The platforms of manifest lists whose validation was deferred are verified by the asynchronous validator.
+	if ml, ok := manifest.(*manifestlist.DeserializedManifestList); ok && imh.verifyPlatforms && !imh.deferValidation && !imh.App.isCache {
+		if err := imh.verifyManifestListPlatforms(ml); err != nil {
+			var blobUnknownErr distribution.ErrManifestBlobUnknown
+			switch {
+			case errors.Is(err, errImageConfigInvalid):
+				imh.Errors = append(imh.Errors, v2.ErrorCodeManifestInvalid.WithDetail(err.Error()))
+			case errors.As(err, &blobUnknownErr):
+				imh.Errors = append(imh.Errors, v2.ErrorCodeManifestBlobUnknown.WithDetail(blobUnknownErr.Digest))
+			case errors.Is(err, distribution.ErrBlobUnknown):
+				imh.Errors = append(imh.Errors, v2.ErrorCodeManifestBlobUnknown.WithDetail(err.Error()))
+			default:
+				imh.appendPutError(err)
+			}
+			return
+		}
+	}
+
+	// Serialize concurrent pushes of the same tag across instances, so that the precondition check, the tag write and
+	// the detection of tag overwrites below are not interleaved with those of another push.
+	if imh.Tag != "" {
+		unlock, err := imh.App.lockTag(imh, imh.Repository.Named().Name(), imh.Tag)
+		if err != nil {
+			imh.Errors = append(imh.Errors, tagLockErr(err))
+			return
+		}
+		defer unlock()
+	}
+
+	// The digest a tag pointed to before this push is only needed to detect tag overwrites for the audit log.
+	var previousDigest digest.Digest
+	if imh.Tag != "" && imh.auditLogger != nil {
+		previousDigest, err = imh.currentTagDigest(imh.Tag)
+		if err != nil {
+			log.WithError(err).Error("failed to find current tag digest for audit log")
+		}
+	}
+
+	// Fail early if the If-Match precondition does not hold, before storing anything. For the database this check is
+	// repeated atomically when switching the tag, as it may have been moved by a concurrent push in the meantime.
+	var ifMatch digest.Digest
+	if d, ok := ifMatchDigest(r); ok && imh.Tag != "" {
+		currentDigest, err := imh.currentTagDigest(imh.Tag)
+		if err != nil {
+			imh.Errors = append(imh.Errors, errcode.FromUnknownError(err))
+			return
+		}
+		if currentDigest != d {
+			imh.Errors = append(imh.Errors, tagPreconditionFailedErr(imh.Tag, currentDigest))
+			return
+		}
+		ifMatch = d
+	}
+
 	if imh.writeFSMetadata {
 		_, err = manifests.Put(imh, manifest, options...)
 		if err != nil {
@@ -561,7 +984,15 @@ func (imh *manifestHandler) PutManifest(w http.ResponseWriter, r *http.Request)
 		// Associate tag with manifest in database.
 		if imh.useDatabase {
 			repoName := imh.Repository.Named().Name()
-			if err := dbTagManifest(imh, imh.db, imh.Digest, imh.Tag, repoName); err != nil {
+			if err := dbTagManifest(imh, imh.db, imh.Digest, imh.Tag, repoName, ifMatch); err != nil {
+				if errors.Is(err, datastore.ErrTagManifestMismatch) {
+					currentDigest, err := imh.currentTagDigest(imh.Tag)
+					if err != nil {
+						log.WithError(err).Error("failed to find current tag digest")
+					}
+					imh.Errors = append(imh.Errors, tagPreconditionFailedErr(imh.Tag, currentDigest))
+					return
+				}
 				if errors.Is(err, datastore.ErrManifestNotFound) {
 					// If online GC was already reviewing the manifest that we want to tag, and that manifest had no
 					// tags before the review start, the API is unable to stop the GC from deleting the manifest (as
@@ -576,7 +1007,11 @@ func (imh *manifestHandler) PutManifest(w http.ResponseWriter, r *http.Request)
 						imh.appendPutError(e)
 						return
 					}
-					if err := dbTagManifest(imh, imh.db, imh.Digest, imh.Tag, repoName); err != nil {
+					if err := dbTagManifest(imh, imh.db, imh.Digest, imh.Tag, repoName, ifMatch); err != nil {
+						if errors.Is(err, datastore.ErrTagManifestMismatch) {
+							imh.Errors = append(imh.Errors, tagPreconditionFailedErr(imh.Tag, ""))
+							return
+						}
 						e := fmt.Errorf("failed to create tag in database after manifest recreate: %w", err)
 						imh.Errors = append(imh.Errors, errcode.FromUnknownError(e))
 						return
@@ -590,6 +1025,24 @@ func (imh *manifestHandler) PutManifest(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	// the tag may have pointed to another manifest
+	if imh.Tag != "" && imh.useDatabase {
+		imh.App.invalidateCachedTags(imh, imh.Repository.Named().Name(), imh.Tag)
+	}
+
+	if imh.deferValidation {
+		imh.App.asyncValidator.enqueue(&asyncValidationJob{
+			repository:      imh.Repository,
+			blobProvider:    imh.blobProvider,
+			manifest:        manifest,
+			digest:          imh.Digest,
+			tag:             imh.Tag,
+			useDatabase:     imh.useDatabase,
+			writeFSMetadata: imh.writeFSMetadata,
+		})
+		validationQueued = true
+	}
+
 	// Construct a canonical url for the uploaded manifest.
 	ref, err := reference.WithDigest(imh.Repository.Named(), imh.Digest)
 	if err != nil {
@@ -615,6 +1068,55 @@ func (imh *manifestHandler) PutManifest(w http.ResponseWriter, r *http.Request)
 		"digest":     desc.Digest,
 		"tag":        imh.Tag,
 	}).Info("manifest uploaded")
+
+	event := audit.Event{Action: audit.ActionManifestPush, Digest: imh.Digest, Tag: imh.Tag}
+	if previousDigest != "" && previousDigest != imh.Digest {
+		event.Action = audit.ActionTagOverwrite
+		event.PreviousDigest = previousDigest
+	}
+	imh.auditLog(imh.Context, r, event)
+	imh.queueReplication(imh.Context, models.ReplicationArtifactManifest, imh.Digest, imh.Tag)
+}
+
+// currentTagDigest returns the digest of the manifest currently tagged with tagName in the request repository, or an
+// empty digest if the tag does not exist.
+func (imh *manifestHandler) currentTagDigest(tagName string) (digest.Digest, error) {
+	if imh.useDatabase {
+		rStore := datastore.NewRepositoryStore(imh.db)
+		dbRepo, err := rStore.FindByPath(imh, imh.Repository.Named().Name())
+		if err != nil || dbRepo == nil {
+			return "", err
+		}
+		dbManifest, err := rStore.FindManifestByTagName(imh, dbRepo, tagName)
+		if err != nil || dbManifest == nil {
+			return "", err
+		}
+		return dbManifest.Digest, nil
+	}
+
+	desc, err := imh.Repository.Tags(imh).Get(imh, tagName)
+	if err != nil {
+		if _, ok := err.(distribution.ErrTagUnknown); ok {
+			return "", nil
+		}
+		return "", err
+	}
+	return desc.Digest, nil
+}
+
+// schema1MigrationInstructions explains clients how to replace schema 1 images, which are no longer supported.
+const schema1MigrationInstructions = "rebuild the image with Docker 1.10 or later, or convert it with " +
+	"`skopeo copy --format v2s2`, and push it again as a schema 2 or OCI manifest"
+
+// schema1UnsupportedErr builds the error returned when pushing or pulling a schema 1 manifest. It includes migration
+// instructions and, if configured, a link to further documentation.
+func (imh *manifestHandler) schema1UnsupportedErr() errcode.Error {
+	detail := map[string]string{"instructions": schema1MigrationInstructions}
+	if u := imh.App.Config.Compatibility.Schema1.MigrationURL; u != "" {
+		detail["documentation"] = u
+	}
+
+	return v2.ErrorCodeManifestInvalid.WithMessage("Schema 1 manifests are no longer supported").WithDetail(detail)
 }
 
 func (imh *manifestHandler) appendPutError(err error) {
@@ -627,7 +1129,7 @@ func (imh *manifestHandler) appendPutError(err error) {
 		return
 	}
 	if errors.Is(err, distribution.ErrSchemaV1Unsupported) {
-		imh.Errors = append(imh.Errors, v2.ErrorCodeManifestInvalid.WithDetail("manifest type unsupported"))
+		imh.Errors = append(imh.Errors, imh.schema1UnsupportedErr())
 		return
 	}
 
@@ -656,6 +1158,18 @@ func (imh *manifestHandler) appendPutError(err error) {
 	}
 }
 
+// verifyManifestListPlatforms checks that the platform declared for each image manifest referenced by ml matches the
+// OS, architecture and variant of its image configuration. Clients such as `docker pull` select the manifest to pull
+// based on the declared platform, so a mismatch results in pulling an image that can not run on the client platform.
+func (imh *manifestHandler) verifyManifestListPlatforms(ml *manifestlist.DeserializedManifestList) error {
+	src, err := imh.App.newValidationSource(imh, imh.Repository, imh.blobProvider, imh.useDatabase)
+	if err != nil {
+		return err
+	}
+
+	return validateManifestListPlatforms(imh, src, ml)
+}
+
 func dbPutManifest(imh *manifestHandler, manifest distribution.Manifest, payload []byte) error {
 	switch reqManifest := manifest.(type) {
 	case *schema2.DeserializedManifest:
@@ -674,7 +1188,9 @@ const (
 	manifestTagGCLockTimeout  = 5 * time.Second
 )
 
-func dbTagManifest(ctx context.Context, db datastore.Handler, dgst digest.Digest, tagName, path string) error {
+// dbTagManifest points tagName to the manifest with digest dgst. If ifMatch is not empty, the tag is only switched if it
+// currently points to the manifest with that digest, otherwise datastore.ErrTagManifestMismatch is returned.
+func dbTagManifest(ctx context.Context, db datastore.Handler, dgst digest.Digest, tagName, path string, ifMatch digest.Digest) error {
 	log := dcontext.GetLoggerWithFields(ctx, map[interface{}]interface{}{"repository": path, "manifest_digest": dgst, "tag": tagName})
 	log.Debug("tagging manifest")
 
@@ -691,7 +1207,7 @@ func dbTagManifest(ctx context.Context, db datastore.Handler, dgst digest.Digest
 		return err
 	}
 	if dbManifest == nil {
-		return fmt.Errorf("manifest %s not found in database", dgst)
+		return fmt.Errorf("finding manifest %s: %w", dgst, datastore.ErrManifestNotFound)
 	}
 
 	log.Debug("creating tag")
@@ -718,13 +1234,27 @@ func dbTagManifest(ctx context.Context, db datastore.Handler, dgst digest.Digest
 	}
 
 	tagStore := datastore.NewTagStore(tx)
-	if err := tagStore.CreateOrUpdate(ctx, &models.Tag{
+	tag := &models.Tag{
 		Name:         tagName,
 		NamespaceID:  dbRepo.NamespaceID,
 		RepositoryID: dbRepo.ID,
 		ManifestID:   dbManifest.ID,
-	}); err != nil {
-		return err
+	}
+	if ifMatch == "" {
+		if err := tagStore.CreateOrUpdate(ctx, tag); err != nil {
+			return err
+		}
+	} else {
+		expected, err := repositoryStore.FindManifestByDigest(ctx, dbRepo, ifMatch)
+		if err != nil {
+			return err
+		}
+		if expected == nil {
+			return datastore.ErrTagManifestMismatch
+		}
+		if err := tagStore.UpdateIfManifest(ctx, tag, expected.ID); err != nil {
+			return err
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -740,7 +1270,7 @@ func dbPutManifestOCI(imh *manifestHandler, manifest *ocischema.DeserializedMani
 	v := validation.NewOCIValidator(
 		&datastore.RepositoryManifestService{RepositoryReader: repoReader, RepositoryPath: repoPath},
 		&datastore.RepositoryBlobService{RepositoryReader: repoReader, RepositoryPath: repoPath},
-		imh.App.isCache,
+		imh.App.isCache || imh.deferValidation,
 		imh.App.manifestURLs,
 	)
 
@@ -758,7 +1288,7 @@ func dbPutManifestSchema2(imh *manifestHandler, manifest *schema2.DeserializedMa
 	v := validation.NewSchema2Validator(
 		&datastore.RepositoryManifestService{RepositoryReader: repoReader, RepositoryPath: repoPath},
 		&datastore.RepositoryBlobService{RepositoryReader: repoReader, RepositoryPath: repoPath},
-		imh.App.isCache,
+		imh.App.isCache || imh.deferValidation,
 		imh.App.manifestURLs,
 	)
 
@@ -855,7 +1385,7 @@ func dbFindRepositoryBlob(ctx context.Context, db datastore.Queryer, desc distri
 		return nil, err
 	}
 	if r == nil {
-		return nil, errors.New("source repository not found in database")
+		return nil, fmt.Errorf("finding source repository %q: %w", repoPath, datastore.ErrRepositoryNotFound)
 	}
 
 	b, err := rStore.FindBlob(ctx, r, desc.Digest)
@@ -863,7 +1393,7 @@ func dbFindRepositoryBlob(ctx context.Context, db datastore.Queryer, desc distri
 		return nil, err
 	}
 	if b == nil {
-		return nil, fmt.Errorf("blob not found in database")
+		return nil, fmt.Errorf("finding blob %s: %w", desc.Digest, datastore.ErrBlobNotFound)
 	}
 
 	return b, nil
@@ -887,7 +1417,7 @@ func dbFindManifestListManifest(
 		return nil, err
 	}
 	if dbManifest == nil {
-		return nil, fmt.Errorf("manifest %s not found", dgst)
+		return nil, fmt.Errorf("finding manifest %s: %w", dgst, datastore.ErrManifestNotFound)
 	}
 
 	return dbManifest, nil
@@ -910,7 +1440,7 @@ func dbPutManifestList(imh *manifestHandler, manifestList *manifestlist.Deserial
 	v := validation.NewManifestListValidator(&datastore.RepositoryManifestService{
 		RepositoryReader: rStore,
 		RepositoryPath:   repoPath,
-	}, imh.App.isCache)
+	}, imh.App.isCache || imh.deferValidation)
 
 	if err := v.Validate(imh, manifestList); err != nil {
 		return err
@@ -1094,7 +1624,7 @@ func dbDeleteManifest(ctx context.Context, db datastore.Handler, repoPath string
 		return err
 	}
 	if r == nil {
-		return fmt.Errorf("repository not found in database: %w", err)
+		return datastore.ErrRepositoryNotFound
 	}
 
 	// We need to find the manifest first and then lookup for any manifest it references (if it's a manifest list). This
@@ -1167,6 +1697,14 @@ func dbDeleteManifest(ctx context.Context, db datastore.Handler, repoPath string
 func (imh *manifestHandler) DeleteManifest(w http.ResponseWriter, r *http.Request) {
 	dcontext.GetLogger(imh).Debug("DeleteImageManifest")
 
+	if imh.Tag != "" && untagEnabled(imh.App.Config) {
+		// As per the OCI distribution spec 1.1, deleting a manifest by tag removes the tag only, leaving the tagged
+		// manifest in place. This is equivalent to deleting the tag through the tags API.
+		th := &tagHandler{Context: imh.Context, Tag: imh.Tag}
+		th.DeleteTag(w, r)
+		return
+	}
+
 	if imh.writeFSMetadata {
 		manifests, err := imh.Repository.Manifests(imh)
 		if err != nil {
@@ -1205,9 +1743,13 @@ func (imh *manifestHandler) DeleteManifest(w http.ResponseWriter, r *http.Reques
 			imh.appendManifestDeleteError(err)
 			return
 		}
+
+		imh.App.invalidateCachedManifest(imh, imh.Repository.Named().Name(), imh.Digest)
 	}
 
 	w.WriteHeader(http.StatusAccepted)
+
+	imh.auditLog(imh.Context, r, audit.Event{Action: audit.ActionManifestDelete, Digest: imh.Digest})
 }
 
 func (imh *manifestHandler) appendManifestDeleteError(err error) {
@@ -1216,6 +1758,8 @@ func (imh *manifestHandler) appendManifestDeleteError(err error) {
 		imh.Errors = append(imh.Errors, v2.ErrorCodeDigestInvalid)
 	case errors.Is(err, distribution.ErrBlobUnknown), errors.Is(err, datastore.ErrManifestNotFound):
 		imh.Errors = append(imh.Errors, v2.ErrorCodeManifestUnknown)
+	case errors.Is(err, datastore.ErrRepositoryNotFound):
+		imh.Errors = append(imh.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": imh.Repository.Named().Name()}))
 	case errors.Is(err, distribution.ErrUnsupported):
 		imh.Errors = append(imh.Errors, errcode.ErrorCodeUnsupported)
 	case errors.Is(err, datastore.ErrManifestReferencedInList):
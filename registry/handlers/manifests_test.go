@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectPlatformManifest(t *testing.T) {
+	descriptors := []manifestlist.ManifestDescriptor{
+		{Platform: manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}},
+		{Platform: manifestlist.PlatformSpec{OS: "linux", Architecture: "arm64"}},
+		{Platform: manifestlist.PlatformSpec{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		{Platform: manifestlist.PlatformSpec{OS: "linux", Architecture: "arm", Variant: "v5"}},
+		{Platform: manifestlist.PlatformSpec{OS: "windows", Architecture: "amd64"}},
+	}
+
+	tt := []struct {
+		name          string
+		platforms     []string
+		expectedIndex int
+		expectedFound bool
+	}{
+		{
+			name:          "exact match",
+			platforms:     []string{"linux/amd64"},
+			expectedIndex: 0,
+			expectedFound: true,
+		},
+		{
+			name:          "any variant",
+			platforms:     []string{"linux/arm"},
+			expectedIndex: 2,
+			expectedFound: true,
+		},
+		{
+			name:          "default variant",
+			platforms:     []string{"linux/arm64/v8"},
+			expectedIndex: 1,
+			expectedFound: true,
+		},
+		{
+			name:          "exact variant",
+			platforms:     []string{"linux/arm/v7"},
+			expectedIndex: 2,
+			expectedFound: true,
+		},
+		{
+			name:          "less capable variant",
+			platforms:     []string{"linux/arm/v8"},
+			expectedIndex: 2,
+			expectedFound: true,
+		},
+		{
+			name:          "skips missing variants",
+			platforms:     []string{"linux/arm/v6"},
+			expectedIndex: 3,
+			expectedFound: true,
+		},
+		{
+			name:          "unknown variant",
+			platforms:     []string{"linux/amd64/v3"},
+			expectedFound: false,
+		},
+		{
+			name:          "next platform",
+			platforms:     []string{"linux/ppc64le", "windows/amd64", "linux/amd64"},
+			expectedIndex: 4,
+			expectedFound: true,
+		},
+		{
+			name:          "no match",
+			platforms:     []string{"linux/ppc64le", "darwin/arm64"},
+			expectedFound: false,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			platforms := make([]manifestlist.PlatformSpec, 0, len(test.platforms))
+			for _, s := range test.platforms {
+				p, err := parsePlatform(s)
+				require.NoError(t, err)
+				platforms = append(platforms, p)
+			}
+
+			desc, found := selectPlatformManifest(descriptors, platforms)
+			require.Equal(t, test.expectedFound, found)
+			if found {
+				require.Equal(t, descriptors[test.expectedIndex], desc)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/gorilla/handlers"
+)
+
+// manifestTagsDispatcher constructs the manifest tags handler api endpoint.
+func manifestTagsDispatcher(ctx *Context, r *http.Request) http.Handler {
+	h := &manifestTagsHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		"GET": http.HandlerFunc(h.GetManifestTags),
+	}
+}
+
+// manifestTagsHandler handles requests for the tags that reference a given manifest.
+type manifestTagsHandler struct {
+	*Context
+}
+
+type manifestTagsAPIResponse struct {
+	Name   string   `json:"name"`
+	Digest string   `json:"digest"`
+	Tags   []string `json:"tags"`
+}
+
+// GetManifestTags returns the name of all tags that currently reference a manifest, sorted lexicographically. Only
+// supported by the metadata database backend.
+func (h *manifestTagsHandler) GetManifestTags(w http.ResponseWriter, r *http.Request) {
+	if h.App.db == nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported.WithDetail("manifest tags require the metadata database"))
+		return
+	}
+
+	dgst, err := getDigest(h)
+	if err != nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeDigestInvalid.WithDetail(err))
+		return
+	}
+
+	repoPath := h.Repository.Named().Name()
+	log := dcontext.GetLoggerWithFields(h, map[interface{}]interface{}{"repository": repoPath, "digest": dgst})
+	log.Debug("finding manifest tags in database")
+
+	rStore := datastore.NewRepositoryStore(h.App.db)
+	repo, err := rStore.FindByPath(h, repoPath)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": repoPath}))
+		return
+	}
+
+	m, err := rStore.FindManifestByDigest(h, repo, dgst)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if m == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeManifestUnknown.WithDetail(map[string]string{"digest": dgst.String()}))
+		return
+	}
+
+	tt, err := rStore.ManifestTags(h, repo, m)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	resp := manifestTagsAPIResponse{
+		Name:   repoPath,
+		Digest: dgst.String(),
+		Tags:   make([]string, 0, len(tt)),
+	}
+	for _, t := range tt {
+		resp.Tags = append(resp.Tags, t.Name)
+	}
+	sort.Strings(resp.Tags)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+}
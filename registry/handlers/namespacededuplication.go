@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/gorilla/handlers"
+)
+
+// namespaceDeduplicationDispatcher constructs the namespace deduplication handler api endpoint.
+func namespaceDeduplicationDispatcher(ctx *Context, r *http.Request) http.Handler {
+	h := &namespaceDeduplicationHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		"GET": http.HandlerFunc(h.GetNamespaceDeduplication),
+	}
+}
+
+// namespaceDeduplicationHandler handles requests for the storage deduplication report of a namespace.
+type namespaceDeduplicationHandler struct {
+	*Context
+}
+
+type namespaceDeduplicationAPIResponse struct {
+	Name              string `json:"name"`
+	RepositoriesCount int    `json:"repositories_count"`
+	BlobsCount        int    `json:"blobs_count"`
+	UniqueBlobsCount  int    `json:"unique_blobs_count"`
+	LogicalSize       int64  `json:"logical_size"`
+	PhysicalSize      int64  `json:"physical_size"`
+	DeduplicatedSize  int64  `json:"deduplicated_size"`
+}
+
+// GetNamespaceDeduplication reports the storage usage of a top-level namespace, both as the sum of the size of its
+// repositories (logical size) and as the size of the unique blobs they reference (physical size), which is what the
+// namespace actually occupies. The difference between the two is the space saved by deduplicating the blobs shared
+// across repositories. Only supported by the metadata database backend.
+func (h *namespaceDeduplicationHandler) GetNamespaceDeduplication(w http.ResponseWriter, r *http.Request) {
+	if h.App.db == nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported.WithDetail("namespace deduplication reports require the metadata database"))
+		return
+	}
+
+	name := h.Repository.Named().Name()
+	if strings.Contains(name, "/") {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameInvalid.WithDetail("deduplication is only reported for top-level namespaces"))
+		return
+	}
+
+	log := dcontext.GetLoggerWithField(h, "namespace", name)
+	log.Debug("calculating namespace deduplication in database")
+
+	nStore := datastore.NewNamespaceStore(h.App.db)
+	n, err := nStore.FindByName(h, name)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if n == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": name}))
+		return
+	}
+
+	d, err := nStore.Deduplication(h, n)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	resp := namespaceDeduplicationAPIResponse{
+		Name:              n.Name,
+		RepositoriesCount: d.RepositoriesCount,
+		BlobsCount:        d.BlobsCount,
+		UniqueBlobsCount:  d.UniqueBlobsCount,
+		LogicalSize:       d.LogicalSize,
+		PhysicalSize:      d.PhysicalSize,
+		DeduplicatedSize:  d.LogicalSize - d.PhysicalSize,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+}
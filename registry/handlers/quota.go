@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	prometheus "github.com/docker/distribution/metrics"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/go-metrics"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	quotaTags              = "tags"
+	quotaManifests         = "manifests"
+	quotaUploads           = "uploads"
+	quotaRepositoryUploads = "repository_uploads"
+)
+
+var (
+	// quotaExceededCounter is the number of manifest pushes rejected due to a repository quota
+	quotaExceededCounter = prometheus.QuotaNamespace.NewLabeledCounter("exceeded", "The number of pushes rejected due to a repository quota", "quota")
+)
+
+func init() {
+	metrics.Register(prometheus.QuotaNamespace)
+}
+
+func quotaExceededErr(quota string, limit int) error {
+	quotaExceededCounter.WithValues(quota).Inc(1)
+	return v2.ErrorCodeQuotaExceeded.WithDetail(map[string]interface{}{"quota": quota, "limit": limit})
+}
+
+// applyRepositoryQuotas checks whether the manifest being pushed would exceed the maximum number of manifests or tags
+// allowed per repository. Pushing an existing manifest or overwriting an existing tag never counts against a quota.
+// Quotas are only enforced when using the metadata database. Concurrent pushes are not serialized, so a repository can
+// briefly exceed a quota by the number of simultaneous pushes.
+func (imh *manifestHandler) applyRepositoryQuotas() error {
+	maxManifests := imh.App.Config.Policy.Repository.MaxManifests
+	maxTags := imh.App.Config.Policy.Repository.MaxTags
+	if !imh.useDatabase || (maxManifests <= 0 && maxTags <= 0) {
+		return nil
+	}
+
+	rStore := datastore.NewRepositoryStore(imh.db)
+	r, err := rStore.FindByPath(imh, imh.Repository.Named().Name())
+	if err != nil {
+		return errcode.FromUnknownError(err)
+	}
+	if r == nil {
+		// the repository is created on the first push, there can't be anything counting against a quota yet
+		return nil
+	}
+
+	if err := imh.App.applyManifestsQuota(imh, rStore, r, imh.Digest); err != nil {
+		return err
+	}
+
+	if imh.Tag != "" {
+		return imh.App.applyTagsQuota(imh, rStore, r, imh.Tag)
+	}
+
+	return nil
+}
+
+// applyManifestsQuota checks whether creating the manifest with digest dgst in repository r would exceed the maximum
+// number of manifests allowed per repository. A manifest that already exists in the repository never counts against
+// the quota.
+func (app *App) applyManifestsQuota(ctx context.Context, rStore datastore.RepositoryStore, r *models.Repository, dgst digest.Digest) error {
+	maxManifests := app.Config.Policy.Repository.MaxManifests
+	if maxManifests <= 0 {
+		return nil
+	}
+
+	m, err := rStore.FindManifestByDigest(ctx, r, dgst)
+	if err != nil {
+		return errcode.FromUnknownError(err)
+	}
+	if m != nil {
+		return nil
+	}
+
+	count, err := rStore.ManifestsCount(ctx, r)
+	if err != nil {
+		return errcode.FromUnknownError(fmt.Errorf("checking manifests quota: %w", err))
+	}
+	if count >= maxManifests {
+		return quotaExceededErr(quotaManifests, maxManifests)
+	}
+
+	return nil
+}
+
+// applyTagsQuota checks whether creating tagName in repository r would exceed the maximum number of tags allowed per
+// repository. Overwriting an existing tag never counts against the quota.
+func (app *App) applyTagsQuota(ctx context.Context, rStore datastore.RepositoryStore, r *models.Repository, tagName string) error {
+	maxTags := app.Config.Policy.Repository.MaxTags
+	if maxTags <= 0 {
+		return nil
+	}
+
+	t, err := rStore.FindTagByName(ctx, r, tagName)
+	if err != nil {
+		return errcode.FromUnknownError(err)
+	}
+	if t != nil {
+		return nil
+	}
+
+	count, err := rStore.TagsCount(ctx, r)
+	if err != nil {
+		return errcode.FromUnknownError(fmt.Errorf("checking tags quota: %w", err))
+	}
+	if count >= maxTags {
+		return quotaExceededErr(quotaTags, maxTags)
+	}
+
+	return nil
+}
+
+func tooManyUploadsErr(quota string, limit int) error {
+	quotaExceededCounter.WithValues(quota).Inc(1)
+	return v2.ErrorCodeTooManyUploads.WithDetail(map[string]interface{}{"quota": quota, "limit": limit})
+}
+
+// applyUploadLimits checks whether starting a new blob upload would exceed the maximum number of in-flight upload
+// sessions allowed per repository or across all repositories. Limits are only enforced when upload sessions are tracked
+// in the metadata database. As with repository quotas, concurrent requests are not serialized, so a limit can briefly
+// be exceeded by the number of simultaneous upload starts.
+func (buh *blobUploadHandler) applyUploadLimits() error {
+	maxTotal := buh.App.Config.Policy.Uploads.MaxConcurrent
+	maxPerRepo := buh.App.Config.Policy.Uploads.MaxConcurrentPerRepository
+	if !buh.tracksUploadsInDatabase() || (maxTotal <= 0 && maxPerRepo <= 0) {
+		return nil
+	}
+
+	s := datastore.NewBlobUploadStore(buh.db)
+	if maxPerRepo > 0 {
+		count, err := s.CountByRepositoryPath(buh, buh.Repository.Named().Name())
+		if err != nil {
+			return errcode.FromUnknownError(fmt.Errorf("checking repository uploads limit: %w", err))
+		}
+		if count >= maxPerRepo {
+			return tooManyUploadsErr(quotaRepositoryUploads, maxPerRepo)
+		}
+	}
+
+	if maxTotal > 0 {
+		count, err := s.Count(buh)
+		if err != nil {
+			return errcode.FromUnknownError(fmt.Errorf("checking uploads limit: %w", err))
+		}
+		if count >= maxTotal {
+			return tooManyUploadsErr(quotaUploads, maxTotal)
+		}
+	}
+
+	return nil
+}
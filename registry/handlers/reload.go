@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/distribution/configuration"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/notifications"
+	"github.com/docker/distribution/registry/auth"
+)
+
+// currentAccessController returns the access controller of the application, or nil if authentication is disabled.
+func (app *App) currentAccessController() auth.AccessController {
+	app.accessControllerMu.RLock()
+	defer app.accessControllerMu.RUnlock()
+
+	return app.accessController
+}
+
+// Reload applies the settings of config that can be changed without a restart: the notification endpoints and the
+// parameters of the configured access controller, such as the root certificate bundle used to verify tokens. Changing
+// the type of authentication requires a restart. All other settings are ignored.
+//
+// The access controller is only replaced if it can be built from the new parameters, in which case an error is
+// returned and the previous one remains in use. Notification endpoints are always replaced.
+func (app *App) Reload(config *configuration.Configuration) error {
+	log := dcontext.GetLogger(app)
+
+	sinks := notifications.NewEndpointSinks(config.Notifications.Endpoints)
+	old := app.events.sink.Swap(notifications.NewBroadcaster(sinks...))
+	// flushing the events queued for the previous endpoints may take a while if they are unreachable
+	go func() {
+		if err := old.Close(); err != nil {
+			log.WithError(err).Warn("error closing previous notification endpoints")
+		}
+	}()
+	log.WithField("endpoints", len(sinks)).Info("reloaded notification endpoints")
+
+	authType := config.Auth.Type()
+	if !strings.EqualFold(authType, app.Config.Auth.Type()) {
+		log.WithField("auth_type", authType).Warn("changing the type of authentication requires a restart, ignoring")
+		return nil
+	}
+	if authType == "" || strings.EqualFold(authType, "none") {
+		return nil
+	}
+
+	accessController, err := auth.GetAccessController(authType, config.Auth.Parameters())
+	if err != nil {
+		return fmt.Errorf("reloading authorization (%s): %w", authType, err)
+	}
+
+	app.accessControllerMu.Lock()
+	app.accessController = accessController
+	app.accessControllerMu.Unlock()
+	log.WithField("auth_type", authType).Info("reloaded access controller")
+
+	return nil
+}
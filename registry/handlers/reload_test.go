@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/notifications"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	_ "github.com/docker/distribution/registry/auth/silly"
+	"github.com/stretchr/testify/require"
+)
+
+func reloadTestConfig(realm string) *configuration.Configuration {
+	return &configuration.Configuration{
+		Storage: configuration.Storage{
+			"testdriver": nil,
+			"maintenance": configuration.Parameters{"uploadpurging": map[interface{}]interface{}{
+				"enabled": false,
+			}},
+		},
+		Auth: configuration.Auth{
+			"silly": {
+				"realm":   realm,
+				"service": "service-test",
+			},
+		},
+	}
+}
+
+func authChallenge(t *testing.T, app *App) string {
+	t.Helper()
+
+	server := httptest.NewServer(app)
+	defer server.Close()
+	builder, err := v2.NewURLBuilderFromString(server.URL, false)
+	require.NoError(t, err)
+	baseURL, err := builder.BuildBaseURL()
+	require.NoError(t, err)
+
+	resp, err := http.Get(baseURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	return resp.Header.Get("WWW-Authenticate")
+}
+
+func TestAppReload_AccessController(t *testing.T) {
+	app := NewApp(context.Background(), reloadTestConfig("realm-test"))
+	require.Equal(t, `Bearer realm="realm-test",service="service-test"`, authChallenge(t, app))
+
+	require.NoError(t, app.Reload(reloadTestConfig("realm-reloaded")))
+	require.Equal(t, `Bearer realm="realm-reloaded",service="service-test"`, authChallenge(t, app))
+
+	// invalid parameters are rejected and the previous access controller remains in use
+	invalid := reloadTestConfig("")
+	delete(invalid.Auth["silly"], "realm")
+	require.Error(t, app.Reload(invalid))
+	require.Equal(t, `Bearer realm="realm-reloaded",service="service-test"`, authChallenge(t, app))
+
+	// changing the type of authentication requires a restart
+	disabled := reloadTestConfig("")
+	disabled.Auth = nil
+	require.NoError(t, app.Reload(disabled))
+	require.Equal(t, `Bearer realm="realm-reloaded",service="service-test"`, authChallenge(t, app))
+}
+
+func TestAppReload_NotificationEndpoints(t *testing.T) {
+	app := NewApp(context.Background(), reloadTestConfig("realm-test"))
+
+	received := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := reloadTestConfig("realm-test")
+	config.Notifications.Endpoints = []configuration.Endpoint{
+		{
+			Name:      "reloaded",
+			URL:       server.URL,
+			Timeout:   time.Second,
+			Threshold: 1,
+			Backoff:   time.Second,
+		},
+	}
+	require.NoError(t, app.Reload(config))
+
+	require.NoError(t, app.events.sink.Write(notifications.Event{ID: "foo", Action: notifications.EventActionPush}))
+
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not delivered to the reloaded endpoint")
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+// repositoryArchiveDispatcher constructs the repository archive handler api endpoint.
+func repositoryArchiveDispatcher(ctx *Context, r *http.Request) http.Handler {
+	h := &repositoryArchiveHandler{
+		Context: ctx,
+	}
+
+	handler := handlers.MethodHandler{}
+	if !ctx.readOnly {
+		handler["PUT"] = http.HandlerFunc(h.ArchiveRepository)
+		handler["DELETE"] = http.HandlerFunc(h.UnarchiveRepository)
+	}
+
+	return handler
+}
+
+// repositoryArchiveHandler handles requests to archive and unarchive repositories.
+type repositoryArchiveHandler struct {
+	*Context
+}
+
+// ArchiveRepository archives a repository, making it read-only. Only supported by the metadata database backend.
+func (h *repositoryArchiveHandler) ArchiveRepository(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, true)
+}
+
+// UnarchiveRepository unarchives a repository, making it writable again. Only supported by the metadata database
+// backend.
+func (h *repositoryArchiveHandler) UnarchiveRepository(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, false)
+}
+
+func (h *repositoryArchiveHandler) setArchived(w http.ResponseWriter, archived bool) {
+	if h.App.db == nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported.WithDetail("repository archiving requires the metadata database"))
+		return
+	}
+
+	repoPath := h.Repository.Named().Name()
+	log := dcontext.GetLoggerWithFields(h, map[interface{}]interface{}{"repository": repoPath, "archived": archived})
+
+	rStore := datastore.NewRepositoryStore(h.App.db)
+	repo, err := rStore.FindByPath(h, repoPath)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": repoPath}))
+		return
+	}
+
+	if repo.Archived != archived {
+		log.Info("updating repository archived state in database")
+		if err := rStore.SetArchived(h, repo, archived); err != nil {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isArchivedRepositoryWrite determines whether r is a write request targeting an archived repository, in which case it
+// must be rejected. Requests to (un)archive a repository are never rejected, as otherwise it would not be possible to
+// unarchive it, and neither are requests to recalculate its statistics, which leave its content untouched.
+func isArchivedRepositoryWrite(ctx *Context, r *http.Request) (bool, error) {
+	if !ctx.useDatabase || ctx.Repository == nil {
+		return false, nil
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return false, nil
+	}
+	if route := mux.CurrentRoute(r); route != nil {
+		switch route.GetName() {
+		case v1.RouteNameRepositoryArchive, v1.RouteNameRepositoryStatisticsRecalculate:
+			return false, nil
+		}
+	}
+
+	repo, err := datastore.NewRepositoryStore(ctx.App.db).FindByPath(ctx, ctx.Repository.Named().Name())
+	if err != nil {
+		return false, err
+	}
+
+	return repo != nil && repo.Archived, nil
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/gorilla/handlers"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// maxBlobsExistsDigests is the maximum number of digests that can be checked in a single request, so that the
+	// query stays bounded.
+	maxBlobsExistsDigests = 1000
+	// maxBlobsExistsBodySize is the maximum size of a request body, which comfortably fits the maximum number of
+	// digests.
+	maxBlobsExistsBodySize = 256 << 10
+)
+
+// repositoryBlobsExistsDispatcher constructs the repository blobs exists handler api endpoint.
+func repositoryBlobsExistsDispatcher(ctx *Context, r *http.Request) http.Handler {
+	h := &repositoryBlobsExistsHandler{
+		Context: ctx,
+	}
+
+	// this is a read only operation, so it remains available in read-only mode
+	return handlers.MethodHandler{
+		"POST": http.HandlerFunc(h.CheckBlobsExist),
+	}
+}
+
+// repositoryBlobsExistsHandler handles requests to check the existence of multiple blobs in a repository.
+type repositoryBlobsExistsHandler struct {
+	*Context
+}
+
+type blobsExistsAPIRequest struct {
+	Digests []string `json:"digests"`
+}
+
+type blobExistsAPIResponse struct {
+	Digest string `json:"digest"`
+	Exists bool   `json:"exists"`
+	Size   int64  `json:"size"`
+}
+
+type blobsExistsAPIResponse struct {
+	Name  string                  `json:"name"`
+	Blobs []blobExistsAPIResponse `json:"blobs"`
+}
+
+// CheckBlobsExist reports which of the given blobs exist in the repository, along with their size, using a single
+// database query. This saves clients such as build tools from issuing a HEAD request per blob before pushing. Blobs
+// are reported in the order they were requested, and all of them are reported as missing if the repository does not
+// exist yet. Only supported by the metadata database backend.
+func (h *repositoryBlobsExistsHandler) CheckBlobsExist(w http.ResponseWriter, r *http.Request) {
+	if h.App.db == nil || !h.useDatabase {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported.WithDetail("checking the existence of multiple blobs requires the metadata database"))
+		return
+	}
+
+	var req blobsExistsAPIRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBlobsExistsBodySize)).Decode(&req); err != nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeDigestInvalid.WithDetail(fmt.Sprintf("invalid request body: %v", err)))
+		return
+	}
+	if len(req.Digests) > maxBlobsExistsDigests {
+		h.Errors = append(h.Errors, v2.ErrorCodeDigestInvalid.WithDetail(fmt.Sprintf("at most %d digests can be checked at once, got %d", maxBlobsExistsDigests, len(req.Digests))))
+		return
+	}
+
+	dgsts := make([]digest.Digest, 0, len(req.Digests))
+	for _, v := range req.Digests {
+		dgst, err := digest.Parse(v)
+		if err != nil {
+			h.Errors = append(h.Errors, v2.ErrorCodeDigestInvalid.WithDetail(map[string]string{"digest": v}))
+			return
+		}
+		dgsts = append(dgsts, dgst)
+	}
+
+	repoPath := h.Repository.Named().Name()
+	log := dcontext.GetLoggerWithField(h, "repository", repoPath)
+	log.WithField("count", len(dgsts)).Debug("checking blobs existence in database")
+
+	rStore := datastore.NewRepositoryStore(h.App.db)
+	repo, err := rStore.FindByPath(h, repoPath)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	sizes := make(map[digest.Digest]int64)
+	if repo != nil && len(dgsts) > 0 {
+		bb, err := rStore.FindBlobs(h, repo, dgsts)
+		if err != nil {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+		for _, b := range bb {
+			sizes[b.Digest] = b.Size
+		}
+	}
+
+	resp := blobsExistsAPIResponse{
+		Name:  repoPath,
+		Blobs: make([]blobExistsAPIResponse, 0, len(dgsts)),
+	}
+	for _, dgst := range dgsts {
+		size, ok := sizes[dgst]
+		resp.Blobs = append(resp.Blobs, blobExistsAPIResponse{Digest: dgst.String(), Exists: ok, Size: size})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+}
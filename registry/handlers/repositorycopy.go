@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/docker/distribution"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/gorilla/handlers"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// copyFromQueryParamKey is the query parameter holding the path of the source repository of a copy. Pull access
+	// to the source repository is required on top of push access to the destination, see App.authorized.
+	copyFromQueryParamKey = "from"
+	// copyDigestQueryParamKey is the query parameter holding the digest of the manifest to copy.
+	copyDigestQueryParamKey = "digest"
+	// copyTagQueryParamKey is the query parameter holding the tag of the manifest to copy. The tag is also created in
+	// the destination repository.
+	copyTagQueryParamKey = "tag"
+
+	manifestCopyGCReviewWindow = 1 * time.Hour
+	manifestCopyGCLockTimeout  = 5 * time.Second
+)
+
+// repositoryCopyDispatcher constructs the repository copy handler api endpoint.
+func repositoryCopyDispatcher(ctx *Context, r *http.Request) http.Handler {
+	h := &repositoryCopyHandler{
+		Context: ctx,
+	}
+
+	handler := handlers.MethodHandler{}
+	if !ctx.readOnly {
+		handler["POST"] = http.HandlerFunc(h.CopyManifest)
+	}
+
+	return handler
+}
+
+// repositoryCopyHandler handles requests to copy manifests across repositories.
+type repositoryCopyHandler struct {
+	*Context
+}
+
+// CopyManifest copies a manifest, along with all the manifests and blobs it references, from another repository. No
+// content is transferred, the copy only creates the corresponding metadata and links in the destination repository.
+// Only supported by the metadata database backend.
+func (h *repositoryCopyHandler) CopyManifest(w http.ResponseWriter, r *http.Request) {
+	if h.App.db == nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported.WithDetail("repository copying requires the metadata database"))
+		return
+	}
+
+	q := r.URL.Query()
+	from := q.Get(copyFromQueryParamKey)
+	src, err := reference.WithName(from)
+	if err != nil {
+		h.Errors = append(h.Errors, invalidQueryParamErr(copyFromQueryParamKey, from))
+		return
+	}
+
+	tagName := q.Get(copyTagQueryParamKey)
+	var dgst digest.Digest
+	if v := q.Get(copyDigestQueryParamKey); v != "" {
+		if dgst, err = digest.Parse(v); err != nil || tagName != "" {
+			h.Errors = append(h.Errors, invalidQueryParamErr(copyDigestQueryParamKey, v))
+			return
+		}
+	} else if tagName != "" {
+		if _, err := reference.WithTag(src, tagName); err != nil {
+			h.Errors = append(h.Errors, invalidQueryParamErr(copyTagQueryParamKey, tagName))
+			return
+		}
+	} else {
+		h.Errors = append(h.Errors, invalidQueryParamErr(copyDigestQueryParamKey, ""))
+		return
+	}
+
+	dstPath := h.Repository.Named().Name()
+	if err := h.repositoryNames.Validate(dstPath); err != nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameInvalid.WithDetail(err))
+		return
+	}
+
+	log := dcontext.GetLoggerWithFields(h, map[interface{}]interface{}{
+		"source":      src.Name(),
+		"destination": dstPath,
+		"digest":      dgst,
+		"tag":         tagName,
+	})
+
+	// the destination tag is locked until its cache entry is invalidated, as done when pushing a tagged manifest
+	if tagName != "" {
+		unlock, err := h.App.lockTag(h, dstPath, tagName)
+		if err != nil {
+			h.Errors = append(h.Errors, tagLockErr(err))
+			return
+		}
+		defer unlock()
+	}
+
+	m, blobs, err := dbCopyManifest(h, h.App, src.Name(), dstPath, dgst, tagName)
+	if err != nil {
+		switch {
+		case errors.Is(err, datastore.ErrRepositoryNotFound):
+			h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": src.Name()}))
+		case errors.Is(err, datastore.ErrManifestNotFound):
+			h.Errors = append(h.Errors, v2.ErrorCodeManifestUnknown.WithDetail(err))
+		default:
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		}
+		return
+	}
+	log.WithField("blob_count", len(blobs)).Info("manifest copied in database")
+	if tagName != "" {
+		h.App.invalidateCachedTags(h, dstPath, tagName)
+	}
+
+	manifest, err := dbPayloadToManifest(m.Payload, m.MediaType, m.SchemaVersion)
+	if err != nil {
+		log.WithError(err).Error("error parsing copied manifest")
+	} else {
+		var opts []distribution.ManifestServiceOption
+		if tagName != "" {
+			opts = append(opts, distribution.WithTagOption{Tag: tagName})
+		}
+		if err := h.App.eventBridge(h.Context, r).ManifestPushed(h.Repository.Named(), manifest, opts...); err != nil {
+			log.WithError(err).Error("error dispatching manifest push to listener")
+		}
+	}
+
+	h.App.auditLog(h.Context, r, audit.Event{Action: audit.ActionManifestPush, Digest: m.Digest, Tag: tagName})
+	for _, d := range blobs {
+		h.App.queueReplication(h.Context, models.ReplicationArtifactBlob, d, "")
+	}
+	h.App.queueReplication(h.Context, models.ReplicationArtifactManifest, m.Digest, tagName)
+
+	ref, err := reference.WithDigest(h.Repository.Named(), m.Digest)
+	if err == nil {
+		location, err := h.urlBuilder.BuildManifestURL(ref)
+		if err != nil {
+			log.WithError(err).Error("error building manifest url from digest")
+		}
+		w.Header().Set("Location", location)
+	}
+	w.Header().Set("Docker-Content-Digest", m.Digest.String())
+	w.WriteHeader(http.StatusCreated)
+}
+
+// dbCopyManifest copies the manifest identified by dgst, or tagged with tagName, from the repository at srcPath to the
+// repository at dstPath within a single transaction, creating the latter if needed. If tagName is not empty the tag is
+// also created in the destination repository. The repository quotas of the destination repository are enforced as for
+// manifest pushes. It returns the copied manifest and the digests of all blobs linked to the destination repository in
+// the process. Callers must hold the lock of the destination tag, if any.
+func dbCopyManifest(ctx context.Context, app *App, srcPath, dstPath string, dgst digest.Digest, tagName string) (*models.Manifest, []digest.Digest, error) {
+	db := app.db
+	rStore := datastore.NewRepositoryStore(db)
+	src, err := rStore.FindByPath(ctx, srcPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if src == nil {
+		return nil, nil, datastore.ErrRepositoryNotFound
+	}
+
+	var m *models.Manifest
+	if tagName != "" {
+		m, err = rStore.FindManifestByTagName(ctx, src, tagName)
+	} else {
+		m, err = rStore.FindManifestByDigest(ctx, src, dgst)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if m == nil {
+		return nil, nil, datastore.ErrManifestNotFound
+	}
+
+	dst, err := rStore.CreateOrFindByPath(ctx, dstPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// As with manifest pushes, quotas are checked outside of the transaction, so concurrent copies can briefly exceed
+	// them. Only the top-level manifest counts against the manifests quota, the manifests it references are not
+	// accounted for, as when pushing a manifest list.
+	if err := app.applyManifestsQuota(ctx, rStore, dst, m.Digest); err != nil {
+		return nil, nil, err
+	}
+	if tagName != "" {
+		if err := app.applyTagsQuota(ctx, rStore, dst, tagName); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Prevent long running transactions by setting an upper limit of manifestCopyGCLockTimeout, as done when tagging
+	// manifests or creating manifest lists. This will bubble up and lead to a 503 Service Unavailable response.
+	ctx, cancel := context.WithTimeout(ctx, manifestCopyGCLockTimeout)
+	defer cancel()
+
+	c := &manifestCopier{tx: tx, dst: dst, linked: make(map[digest.Digest]struct{})}
+	copied, err := c.copy(ctx, m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if tagName != "" {
+		if err := datastore.NewTagStore(tx).CreateOrUpdate(ctx, &models.Tag{
+			Name:         tagName,
+			NamespaceID:  dst.NamespaceID,
+			RepositoryID: dst.ID,
+			ManifestID:   copied.ID,
+		}); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("committing database transaction: %w", err)
+	}
+
+	blobs := make([]digest.Digest, 0, len(c.linked))
+	for d := range c.linked {
+		blobs = append(blobs, d)
+	}
+
+	return copied, blobs, nil
+}
+
+// manifestCopier copies manifests between two repositories within a transaction.
+type manifestCopier struct {
+	tx     datastore.Transactor
+	dst    *models.Repository
+	linked map[digest.Digest]struct{}
+}
+
+// copy copies m and all manifests and blobs it references from the source to the destination repository, returning
+// the manifest of the destination repository. Manifests that already exist in the destination repository are not
+// copied again. Instead, any related online GC task is locked to prevent the manifest from being deleted before the
+// transaction is committed.
+func (c *manifestCopier) copy(ctx context.Context, m *models.Manifest) (*models.Manifest, error) {
+	existing, err := datastore.NewRepositoryStore(c.tx).FindManifestByDigest(ctx, c.dst, m.Digest)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		mts := datastore.NewGCManifestTaskStore(c.tx)
+		if _, err := mts.FindAndLockBefore(ctx, c.dst.NamespaceID, c.dst.ID, existing.ID, time.Now().Add(manifestCopyGCReviewWindow)); err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+
+	mStore := datastore.NewManifestStore(c.tx)
+	refs, err := mStore.References(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	copiedRefs := make([]*models.Manifest, 0, len(refs))
+	for _, ref := range refs {
+		copied, err := c.copy(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		copiedRefs = append(copiedRefs, copied)
+	}
+
+	layers, err := mStore.LayerBlobs(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	copied := &models.Manifest{
+		NamespaceID:   c.dst.NamespaceID,
+		RepositoryID:  c.dst.ID,
+		SchemaVersion: m.SchemaVersion,
+		MediaType:     m.MediaType,
+		Digest:        m.Digest,
+		Payload:       m.Payload,
+		Configuration: m.Configuration,
+	}
+	if m.Configuration != nil {
+		if err := c.link(ctx, m.Configuration.Digest); err != nil {
+			return nil, err
+		}
+	}
+	if err := mStore.Create(ctx, copied); err != nil {
+		return nil, err
+	}
+
+	for _, b := range layers {
+		if err := c.link(ctx, b.Digest); err != nil {
+			return nil, err
+		}
+		if err := mStore.AssociateLayerBlob(ctx, copied, b); err != nil {
+			return nil, err
+		}
+	}
+	for _, ref := range copiedRefs {
+		if err := mStore.AssociateManifest(ctx, copied, ref); err != nil {
+			return nil, err
+		}
+	}
+
+	return copied, nil
+}
+
+// link links the blob with digest d to the destination repository.
+func (c *manifestCopier) link(ctx context.Context, d digest.Digest) error {
+	if _, ok := c.linked[d]; ok {
+		return nil
+	}
+	if err := datastore.NewRepositoryStore(c.tx).LinkBlob(ctx, c.dst, d); err != nil {
+		return err
+	}
+	c.linked[d] = struct{}{}
+
+	return nil
+}
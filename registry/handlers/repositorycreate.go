@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+// repositoryCreateDispatcher constructs the repository create handler api endpoint.
+func repositoryCreateDispatcher(ctx *Context, r *http.Request) http.Handler {
+	h := &repositoryCreateHandler{
+		Context: ctx,
+	}
+
+	handler := handlers.MethodHandler{}
+	if !ctx.readOnly {
+		handler["PUT"] = http.HandlerFunc(h.CreateRepository)
+	}
+
+	return handler
+}
+
+// repositoryCreateHandler handles requests to create repositories.
+type repositoryCreateHandler struct {
+	*Context
+}
+
+type repositoryCreateAPIResponse struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	CreatedAt string `json:"created_at"`
+}
+
+// CreateRepository creates an empty repository. This is idempotent, responding with `201 Created` if the repository
+// was created and `200 OK` if it already existed. Only supported by the metadata database backend.
+func (h *repositoryCreateHandler) CreateRepository(w http.ResponseWriter, r *http.Request) {
+	if h.App.db == nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported.WithDetail("repository creation requires the metadata database"))
+		return
+	}
+
+	repoPath := h.Repository.Named().Name()
+	if err := h.repositoryNames.Validate(repoPath); err != nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameInvalid.WithDetail(err))
+		return
+	}
+
+	rStore := datastore.NewRepositoryStore(h.App.db)
+	repo, err := rStore.FindByPath(h, repoPath)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	status := http.StatusOK
+	if repo == nil {
+		dcontext.GetLoggerWithField(h, "repository", repoPath).Info("creating repository in database")
+		// a concurrent request may have created the repository in the meantime, which is fine
+		repo, err = rStore.CreateOrFindByPath(h, repoPath)
+		if err != nil {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+		status = http.StatusCreated
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(repositoryCreateAPIResponse{
+		Name:      repo.Name,
+		Path:      repo.Path,
+		CreatedAt: repo.CreatedAt.UTC().Format(time.RFC3339),
+	}); err != nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+	}
+}
+
+// isImplicitRepositoryCreation determines whether r would implicitly create a repository that does not exist yet, by
+// starting a blob upload or pushing a manifest to it. Only applicable to repositories served from the metadata
+// database.
+func isImplicitRepositoryCreation(ctx *Context, r *http.Request) (bool, error) {
+	if !ctx.useDatabase || ctx.Repository == nil {
+		return false, nil
+	}
+
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return false, nil
+	}
+	switch {
+	case route.GetName() == v2.RouteNameBlobUpload && r.Method == http.MethodPost:
+	case route.GetName() == v2.RouteNameManifest && r.Method == http.MethodPut:
+	default:
+		return false, nil
+	}
+
+	repo, err := datastore.NewRepositoryStore(ctx.App.db).FindByPath(ctx, ctx.Repository.Named().Name())
+	if err != nil {
+		return false, err
+	}
+
+	return repo == nil, nil
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/gorilla/handlers"
+)
+
+const (
+	defaultRepositoryEventsEntries = 100
+	maxRepositoryEventsEntries     = 1000
+
+	repositoryEventsFormatJSON = "json"
+	repositoryEventsFormatCSV  = "csv"
+)
+
+// repositoryEventsDispatcher constructs the repository events handler api endpoint.
+func repositoryEventsDispatcher(ctx *Context, r *http.Request) http.Handler {
+	h := &repositoryEventsHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		"GET": http.HandlerFunc(h.GetRepositoryEvents),
+	}
+}
+
+// repositoryEventsHandler handles requests for the event history of a repository.
+type repositoryEventsHandler struct {
+	*Context
+}
+
+type repositoryEventAPIResponse struct {
+	ID             int64     `json:"id"`
+	Timestamp      time.Time `json:"timestamp"`
+	Action         string    `json:"action"`
+	Actor          string    `json:"actor,omitempty"`
+	Digest         string    `json:"digest,omitempty"`
+	Tag            string    `json:"tag,omitempty"`
+	PreviousDigest string    `json:"previous_digest,omitempty"`
+	UploadUUID     string    `json:"upload_uuid,omitempty"`
+	RequestID      string    `json:"request_id,omitempty"`
+}
+
+type repositoryEventsAPIResponse struct {
+	Name   string                       `json:"name"`
+	Events []repositoryEventAPIResponse `json:"events"`
+}
+
+var repositoryEventsCSVHeader = []string{"id", "timestamp", "action", "actor", "digest", "tag", "previous_digest", "upload_uuid", "request_id"}
+
+type repositoryEventsQuery struct {
+	since  time.Time
+	n      int
+	last   int64
+	format string
+}
+
+func invalidQueryParamErr(key, value string) error {
+	return v1.ErrorCodeInvalidQueryParamValue.WithDetail(map[string]string{"key": key, "value": value})
+}
+
+func parseRepositoryEventsQuery(q url.Values) (*repositoryEventsQuery, error) {
+	rq := &repositoryEventsQuery{
+		n:      defaultRepositoryEventsEntries,
+		format: repositoryEventsFormatJSON,
+	}
+
+	if v := q.Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, invalidQueryParamErr("since", v)
+		}
+		rq.since = t
+	}
+	if v := q.Get("n"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, invalidQueryParamErr("n", v)
+		}
+		if n > maxRepositoryEventsEntries {
+			n = maxRepositoryEventsEntries
+		}
+		rq.n = n
+	}
+	if v := q.Get("last"); v != "" {
+		last, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || last < 0 {
+			return nil, invalidQueryParamErr("last", v)
+		}
+		rq.last = last
+	}
+	if v := q.Get("format"); v != "" {
+		if v != repositoryEventsFormatJSON && v != repositoryEventsFormatCSV {
+			return nil, invalidQueryParamErr("format", v)
+		}
+		rq.format = v
+	}
+
+	return rq, nil
+}
+
+// repositoryEventsNextLink builds a Link header value for the page following lastID, preserving all other query
+// parameters of the original request.
+func repositoryEventsNextLink(ub *v2.URLBuilder, name reference.Named, origQuery url.Values, n int, lastID int64) (string, error) {
+	q := url.Values{}
+	for k, v := range origQuery {
+		q[k] = v
+	}
+	q.Set("n", strconv.Itoa(n))
+	q.Set("last", strconv.FormatInt(lastID, 10))
+
+	u, err := ub.BuildGitLabRepositoryEventsURL(name, q)
+	if err != nil {
+		return "", err
+	}
+
+	return nextLink(u), nil
+}
+
+// GetRepositoryEvents returns the audit event history of a repository, in JSON or CSV format. Only supported by the
+// metadata database backend, with the audit log database sink enabled.
+func (h *repositoryEventsHandler) GetRepositoryEvents(w http.ResponseWriter, r *http.Request) {
+	if h.App.db == nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported.WithDetail("repository events require the metadata database"))
+		return
+	}
+
+	rq, err := parseRepositoryEventsQuery(r.URL.Query())
+	if err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
+
+	repoPath := h.Repository.Named().Name()
+	log := dcontext.GetLoggerWithFields(h, map[interface{}]interface{}{"repository": repoPath, "since": rq.since, "limit": rq.n, "marker": rq.last})
+	log.Debug("finding repository events in database")
+
+	repo, err := datastore.NewRepositoryStore(h.App.db).FindByPath(h, repoPath)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": repoPath}))
+		return
+	}
+
+	// fetch one more event than requested to determine whether there is a next page
+	ee, err := datastore.NewRepositoryEventStore(h.App.db).FindByRepositoryPaginated(h, repo, rq.since, rq.n+1, rq.last)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if len(ee) > rq.n {
+		ee = ee[:rq.n]
+		link, err := repositoryEventsNextLink(h.App.linkBuilder, h.Repository.Named(), r.URL.Query(), rq.n, ee[len(ee)-1].ID)
+		if err != nil {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+		w.Header().Set("Link", link)
+	}
+
+	if rq.format == repositoryEventsFormatCSV {
+		if err := writeRepositoryEventsCSV(w, ee); err != nil {
+			log.WithError(err).Error("error writing repository events csv")
+		}
+		return
+	}
+
+	resp := repositoryEventsAPIResponse{
+		Name:   repoPath,
+		Events: make([]repositoryEventAPIResponse, 0, len(ee)),
+	}
+	for _, e := range ee {
+		resp.Events = append(resp.Events, repositoryEventAPIResponse{
+			ID:             e.ID,
+			Timestamp:      e.CreatedAt.UTC(),
+			Action:         e.Action,
+			Actor:          e.Actor,
+			Digest:         e.Digest.String(),
+			Tag:            e.Tag,
+			PreviousDigest: e.PreviousDigest.String(),
+			UploadUUID:     e.UploadUUID,
+			RequestID:      e.RequestID,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+}
+
+func writeRepositoryEventsCSV(w http.ResponseWriter, ee models.RepositoryEvents) error {
+	w.Header().Set("Content-Type", "text/csv")
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(repositoryEventsCSVHeader); err != nil {
+		return err
+	}
+	for _, e := range ee {
+		if err := cw.Write([]string{
+			strconv.FormatInt(e.ID, 10),
+			e.CreatedAt.UTC().Format(time.RFC3339Nano),
+			e.Action,
+			e.Actor,
+			e.Digest.String(),
+			e.Tag,
+			e.PreviousDigest.String(),
+			e.UploadUUID,
+			e.RequestID,
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
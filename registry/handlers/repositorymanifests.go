@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/gorilla/handlers"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	defaultRepositoryManifestsEntries = 100
+	maxRepositoryManifestsEntries     = 100
+)
+
+// repositoryManifestsDispatcher constructs the repository manifests handler api endpoint.
+func repositoryManifestsDispatcher(ctx *Context, r *http.Request) http.Handler {
+	h := &repositoryManifestsHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		"GET": http.HandlerFunc(h.GetRepositoryManifests),
+	}
+}
+
+// repositoryManifestsHandler handles requests for the manifests of a repository, along with their details.
+type repositoryManifestsHandler struct {
+	*Context
+}
+
+type repositoryManifestAPIResponse struct {
+	Digest       string            `json:"digest"`
+	MediaType    string            `json:"media_type"`
+	ArtifactType string            `json:"artifact_type"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	Size         int64             `json:"size"`
+	Tags         []string          `json:"tags"`
+	CreatedAt    string            `json:"created_at"`
+}
+
+type repositoryManifestsAPIResponse struct {
+	Name      string                          `json:"name"`
+	Manifests []repositoryManifestAPIResponse `json:"manifests"`
+}
+
+type repositoryManifestsQuery struct {
+	n            int
+	last         digest.Digest
+	untaggedOnly bool
+}
+
+func parseRepositoryManifestsQuery(q url.Values) (*repositoryManifestsQuery, error) {
+	rq := &repositoryManifestsQuery{n: defaultRepositoryManifestsEntries}
+
+	if v := q.Get("n"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, invalidQueryParamErr("n", v)
+		}
+		if n > maxRepositoryManifestsEntries {
+			n = maxRepositoryManifestsEntries
+		}
+		rq.n = n
+	}
+	if v := q.Get("last"); v != "" {
+		d, err := digest.Parse(v)
+		if err != nil {
+			return nil, invalidQueryParamErr("last", v)
+		}
+		rq.last = d
+	}
+	if v := q.Get("untagged"); v != "" {
+		untagged, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, invalidQueryParamErr("untagged", v)
+		}
+		rq.untaggedOnly = untagged
+	}
+
+	return rq, nil
+}
+
+func newRepositoryManifestAPIResponse(ctx context.Context, m *models.ManifestDetail) repositoryManifestAPIResponse {
+	typ := artifactType(m.MediaType, m.ConfigMediaType)
+
+	return repositoryManifestAPIResponse{
+		Digest:       m.Digest.String(),
+		MediaType:    m.MediaType,
+		ArtifactType: typ,
+		Annotations:  artifactAnnotations(ctx, typ, m.ConfigPayload),
+		Size:         m.Size,
+		Tags:         m.Tags,
+		CreatedAt:    m.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// GetRepositoryManifests returns the manifests of a repository sorted by digest, including untagged ones, along with
+// their media type, artifact type, payload size, creation time and the tags pointing to them. This allows clients to find untagged
+// manifests that are eligible for cleanup. Only supported by the metadata database backend.
+func (h *repositoryManifestsHandler) GetRepositoryManifests(w http.ResponseWriter, r *http.Request) {
+	if h.App.db == nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported.WithDetail("repository manifest details require the metadata database"))
+		return
+	}
+
+	rq, err := parseRepositoryManifestsQuery(r.URL.Query())
+	if err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
+
+	repoPath := h.Repository.Named().Name()
+	log := dcontext.GetLoggerWithFields(h, map[interface{}]interface{}{"repository": repoPath, "limit": rq.n, "marker": rq.last})
+	log.Debug("finding repository manifest details in database")
+
+	rStore := datastore.NewRepositoryStore(h.App.db)
+	repo, err := rStore.FindByPath(h, repoPath)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": repoPath}))
+		return
+	}
+
+	// fetch one more manifest than requested to determine whether there is a next page
+	mm, err := rStore.ManifestsDetailPaginated(h, repo, rq.n+1, rq.last, rq.untaggedOnly)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if len(mm) > rq.n {
+		mm = mm[:rq.n]
+		values := paginationValues(rq.n, mm[len(mm)-1].Digest.String())
+		if rq.untaggedOnly {
+			values.Set("untagged", "true")
+		}
+		u, err := h.App.linkBuilder.BuildGitLabRepositoryManifestsURL(h.Repository.Named(), values)
+		if err != nil {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+		w.Header().Set("Link", nextLink(u))
+	}
+
+	resp := repositoryManifestsAPIResponse{
+		Name:      repoPath,
+		Manifests: make([]repositoryManifestAPIResponse, 0, len(mm)),
+	}
+	for _, m := range mm {
+		resp.Manifests = append(resp.Manifests, newRepositoryManifestAPIResponse(h, m))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/gorilla/handlers"
+)
+
+const (
+	// renameToQueryParamKey is the query parameter holding the destination path of a repository rename.
+	renameToQueryParamKey = "to"
+	// renameIncludeDescendantsQueryParamKey is the query parameter that toggles whether descendant repositories are
+	// renamed along with the target repository.
+	renameIncludeDescendantsQueryParamKey = "include_descendants"
+)
+
+// repositoryRenameDispatcher constructs the repository rename handler api endpoint.
+func repositoryRenameDispatcher(ctx *Context, r *http.Request) http.Handler {
+	h := &repositoryRenameHandler{
+		Context: ctx,
+	}
+
+	handler := handlers.MethodHandler{}
+	if !ctx.readOnly {
+		handler["PUT"] = http.HandlerFunc(h.RenameRepository)
+	}
+
+	return handler
+}
+
+// repositoryRenameHandler handles requests to rename repositories.
+type repositoryRenameHandler struct {
+	*Context
+}
+
+// RenameRepository moves a repository, and optionally its descendants, to a new path within the same top-level
+// namespace. Only supported by the metadata database backend.
+func (h *repositoryRenameHandler) RenameRepository(w http.ResponseWriter, r *http.Request) {
+	if h.App.db == nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported.WithDetail("repository renaming requires the metadata database"))
+		return
+	}
+
+	q := r.URL.Query()
+	to := q.Get(renameToQueryParamKey)
+	dst, err := reference.WithName(to)
+	if err != nil {
+		h.Errors = append(h.Errors, invalidQueryParamErr(renameToQueryParamKey, to))
+		return
+	}
+	var includeDescendants bool
+	if v := q.Get(renameIncludeDescendantsQueryParamKey); v != "" {
+		includeDescendants, err = strconv.ParseBool(v)
+		if err != nil {
+			h.Errors = append(h.Errors, invalidQueryParamErr(renameIncludeDescendantsQueryParamKey, v))
+			return
+		}
+	}
+
+	repoPath := h.Repository.Named().Name()
+	log := dcontext.GetLoggerWithFields(h, map[interface{}]interface{}{
+		"repository":          repoPath,
+		"destination":         dst.Name(),
+		"include_descendants": includeDescendants,
+	})
+
+	renamed, err := dbRenameRepository(h.Context, repoPath, dst.Name(), includeDescendants)
+	if err != nil {
+		switch {
+		case errors.Is(err, datastore.ErrRepositoryNotFound):
+			h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": repoPath}))
+		case errors.Is(err, datastore.ErrRepositoryExists):
+			h.Errors = append(h.Errors, v1.ErrorCodeRepositoryExists.WithDetail(map[string]string{"name": dst.Name()}))
+		case errors.Is(err, datastore.ErrRepositoryRenameNamespace), errors.Is(err, datastore.ErrRepositoryRenameDescendant):
+			h.Errors = append(h.Errors, v1.ErrorCodeInvalidQueryParamValue.WithDetail(map[string]string{
+				"key":    renameToQueryParamKey,
+				"value":  to,
+				"reason": err.Error(),
+			}))
+		default:
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		}
+		return
+	}
+	log.WithField("renamed_count", len(renamed)).Info("repository renamed in database")
+
+	bridge := h.App.eventBridge(h.Context, r)
+	for fromPath, toPath := range renamed {
+		h.App.invalidateCachedRepository(h, fromPath)
+		// entries of a repository previously deleted from the destination path may still be cached
+		h.App.invalidateCachedRepository(h, toPath)
+
+		fromRef, err := reference.WithName(fromPath)
+		if err != nil {
+			log.WithError(err).Error("error parsing renamed repository path")
+			continue
+		}
+		toRef, err := reference.WithName(toPath)
+		if err != nil {
+			log.WithError(err).Error("error parsing renamed repository path")
+			continue
+		}
+		if err := bridge.RepoRenamed(toRef, fromRef); err != nil {
+			log.WithError(err).Error("error dispatching repository renamed to listener")
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// dbRenameRepository renames the repository at path to dst within a single transaction. It returns a map of the old to
+// the new paths of all repositories that were renamed, which includes descendants if includeDescendants is true.
+func dbRenameRepository(ctx *Context, path, dst string, includeDescendants bool) (map[string]string, error) {
+	tx, err := ctx.App.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rStore := datastore.NewRepositoryStore(tx)
+	repo, err := rStore.FindByPath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if repo == nil {
+		return nil, datastore.ErrRepositoryNotFound
+	}
+
+	renamed := map[string]string{path: dst}
+	if includeDescendants {
+		rr, err := rStore.FindDescendantsOf(ctx, repo.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rr {
+			renamed[r.Path] = dst + strings.TrimPrefix(r.Path, path)
+		}
+	}
+
+	if err := rStore.Rename(ctx, repo, dst, includeDescendants); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing database transaction: %w", err)
+	}
+
+	return renamed, nil
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/retention"
+	"github.com/gorilla/handlers"
+)
+
+// maxRetentionKeepRegexLength is the maximum length of the keep regex of a retention policy, as enforced by the
+// database.
+const maxRetentionKeepRegexLength = 255
+
+// repositoryRetentionPolicyDispatcher constructs the repository retention policy handler api endpoint.
+func repositoryRetentionPolicyDispatcher(ctx *Context, r *http.Request) http.Handler {
+	h := &repositoryRetentionPolicyHandler{
+		Context: ctx,
+	}
+
+	handler := handlers.MethodHandler{
+		"GET": http.HandlerFunc(h.GetRetentionPolicy),
+	}
+	if !ctx.readOnly {
+		handler["PUT"] = http.HandlerFunc(h.PutRetentionPolicy)
+		handler["DELETE"] = http.HandlerFunc(h.DeleteRetentionPolicy)
+	}
+
+	return handler
+}
+
+// repositoryRetentionPolicyHandler handles requests to manage the tag retention policy of a repository.
+type repositoryRetentionPolicyHandler struct {
+	*Context
+}
+
+type retentionPolicyAPIRequest struct {
+	KeepN     int    `json:"keep_n"`
+	KeepRegex string `json:"keep_regex"`
+	OlderThan string `json:"older_than"`
+	DryRun    bool   `json:"dry_run"`
+}
+
+type retentionPolicyAPIResponse struct {
+	Name           string     `json:"name"`
+	KeepN          int        `json:"keep_n"`
+	KeepRegex      string     `json:"keep_regex,omitempty"`
+	OlderThan      string     `json:"older_than,omitempty"`
+	DryRun         bool       `json:"dry_run"`
+	NextRunAt      time.Time  `json:"next_run_at"`
+	LastRunAt      *time.Time `json:"last_run_at,omitempty"`
+	LastRunDeleted int        `json:"last_run_deleted"`
+	Error          string     `json:"error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      *time.Time `json:"updated_at,omitempty"`
+}
+
+func newRetentionPolicyAPIResponse(path string, p *models.RetentionPolicy) retentionPolicyAPIResponse {
+	resp := retentionPolicyAPIResponse{
+		Name:           path,
+		KeepN:          p.KeepN,
+		KeepRegex:      p.KeepRegex,
+		DryRun:         p.DryRun,
+		NextRunAt:      p.ReviewAfter,
+		LastRunDeleted: p.LastRunDeleted,
+		Error:          p.Error,
+		CreatedAt:      p.CreatedAt,
+	}
+	if p.OlderThan > 0 {
+		resp.OlderThan = p.OlderThan.String()
+	}
+	if p.LastRunAt.Valid {
+		resp.LastRunAt = &p.LastRunAt.Time
+	}
+	if p.UpdatedAt.Valid {
+		resp.UpdatedAt = &p.UpdatedAt.Time
+	}
+
+	return resp
+}
+
+// parseRetentionPolicy validates a retention policy request. At least one rule must be set, as a policy without rules
+// would delete all tags.
+func parseRetentionPolicy(req *retentionPolicyAPIRequest) (*models.RetentionPolicy, error) {
+	p := &models.RetentionPolicy{KeepN: req.KeepN, KeepRegex: req.KeepRegex, DryRun: req.DryRun}
+
+	if req.KeepN < 0 {
+		return nil, v1.ErrorCodeRetentionPolicyInvalid.WithDetail("keep_n must not be negative")
+	}
+	if len(req.KeepRegex) > maxRetentionKeepRegexLength {
+		return nil, v1.ErrorCodeRetentionPolicyInvalid.WithDetail(fmt.Sprintf("keep_regex must not exceed %d characters", maxRetentionKeepRegexLength))
+	}
+	if _, err := retention.CompileKeepRegex(req.KeepRegex); err != nil {
+		return nil, v1.ErrorCodeRetentionPolicyInvalid.WithDetail(err.Error())
+	}
+	if req.OlderThan != "" {
+		d, err := time.ParseDuration(req.OlderThan)
+		if err != nil || d < 0 {
+			return nil, v1.ErrorCodeRetentionPolicyInvalid.WithDetail(fmt.Sprintf("invalid older_than duration %q", req.OlderThan))
+		}
+		p.OlderThan = d.Truncate(time.Second)
+	}
+	if p.KeepN == 0 && p.KeepRegex == "" && p.OlderThan == 0 {
+		return nil, v1.ErrorCodeRetentionPolicyInvalid.WithDetail("at least one of keep_n, keep_regex or older_than must be set")
+	}
+
+	return p, nil
+}
+
+// findRepository returns the repository targeted by the request, appending the corresponding error to the context and
+// returning nil if the enforcement of retention policies is disabled or the repository does not exist.
+func (h *repositoryRetentionPolicyHandler) findRepository() *models.Repository {
+	if !h.App.retentionEnabled {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported.WithDetail("retention policies require the enforcement of retention policies to be enabled"))
+		return nil
+	}
+
+	repoPath := h.Repository.Named().Name()
+	repo, err := datastore.NewRepositoryStore(h.App.db).FindByPath(h, repoPath)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return nil
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": repoPath}))
+		return nil
+	}
+
+	return repo
+}
+
+func (h *repositoryRetentionPolicyHandler) writeRetentionPolicy(w http.ResponseWriter, path string, p *models.RetentionPolicy) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(newRetentionPolicyAPIResponse(path, p)); err != nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+	}
+}
+
+// GetRetentionPolicy returns the retention policy of a repository, along with the outcome of its last run.
+func (h *repositoryRetentionPolicyHandler) GetRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	repo := h.findRepository()
+	if repo == nil {
+		return
+	}
+
+	p, err := datastore.NewRetentionPolicyStore(h.App.db).FindByRepository(h, repo)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if p == nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeRetentionPolicyUnknown.WithDetail(map[string]string{"name": repo.Path}))
+		return
+	}
+
+	h.writeRetentionPolicy(w, repo.Path, p)
+}
+
+// PutRetentionPolicy sets the retention policy of a repository, replacing the existing one, if any. The policy is
+// evaluated in the background shortly after, and periodically from then on. Only supported by the metadata database
+// backend.
+func (h *repositoryRetentionPolicyHandler) PutRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	repo := h.findRepository()
+	if repo == nil {
+		return
+	}
+
+	var req retentionPolicyAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeRetentionPolicyInvalid.WithDetail(fmt.Sprintf("invalid request body: %v", err)))
+		return
+	}
+	p, err := parseRetentionPolicy(&req)
+	if err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
+	p.NamespaceID = repo.NamespaceID
+	p.RepositoryID = repo.ID
+
+	if err := datastore.NewRetentionPolicyStore(h.App.db).CreateOrUpdate(h, p); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	dcontext.GetLoggerWithFields(h, map[interface{}]interface{}{
+		"repository":   repo.Path,
+		"keep_n":       p.KeepN,
+		"keep_regex":   p.KeepRegex,
+		"older_than_s": p.OlderThan.Seconds(),
+		"dry_run":      p.DryRun,
+	}).Info("repository retention policy set")
+
+	h.writeRetentionPolicy(w, repo.Path, p)
+}
+
+// DeleteRetentionPolicy removes the retention policy of a repository. Tags deleted by previous runs are not restored.
+func (h *repositoryRetentionPolicyHandler) DeleteRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	repo := h.findRepository()
+	if repo == nil {
+		return
+	}
+
+	if err := datastore.NewRetentionPolicyStore(h.App.db).Delete(h, repo); err != nil {
+		if errors.Is(err, datastore.ErrRetentionPolicyNotFound) {
+			h.Errors = append(h.Errors, v1.ErrorCodeRetentionPolicyUnknown.WithDetail(map[string]string{"name": repo.Path}))
+			return
+		}
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	dcontext.GetLoggerWithField(h, "repository", repo.Path).Info("repository retention policy deleted")
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/gorilla/handlers"
+)
+
+const (
+	// maxConcurrentStatisticsRecalculations is the maximum number of repository statistics recalculations that can run
+	// at the same time on a registry instance. Recalculating statistics scans all tags, manifests and blobs of a
+	// repository, so requests over the limit are rejected instead of piling up on the database.
+	maxConcurrentStatisticsRecalculations = 2
+	// statisticsRecalculationTimeout is the maximum amount of time allowed to recalculate the statistics of a
+	// repository. This will bubble up and lead to a 503 Service Unavailable response.
+	statisticsRecalculationTimeout = 1 * time.Minute
+)
+
+// repositoryStatisticsDispatcher constructs the repository statistics handler api endpoint.
+func repositoryStatisticsDispatcher(ctx *Context, r *http.Request) http.Handler {
+	h := &repositoryStatisticsHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		"GET": http.HandlerFunc(h.GetRepositoryStatistics),
+	}
+}
+
+// repositoryStatisticsRecalculateDispatcher constructs the repository statistics recalculation handler api endpoint.
+func repositoryStatisticsRecalculateDispatcher(ctx *Context, r *http.Request) http.Handler {
+	h := &repositoryStatisticsHandler{
+		Context: ctx,
+	}
+
+	handler := handlers.MethodHandler{}
+	if !ctx.readOnly {
+		handler["POST"] = http.HandlerFunc(h.RecalculateRepositoryStatistics)
+	}
+
+	return handler
+}
+
+// repositoryStatisticsHandler handles requests for the statistics of a repository.
+type repositoryStatisticsHandler struct {
+	*Context
+}
+
+type repositoryStatisticsAPIResponse struct {
+	TagsCount       int        `json:"tags_count"`
+	ManifestsCount  int        `json:"manifests_count"`
+	Size            int64      `json:"size"`
+	LastPublishedAt *time.Time `json:"last_published_at,omitempty"`
+	CalculatedAt    time.Time  `json:"calculated_at"`
+}
+
+type getRepositoryStatisticsAPIResponse struct {
+	Name string `json:"name"`
+	repositoryStatisticsAPIResponse
+}
+
+type recalculateRepositoryStatisticsAPIResponse struct {
+	Name   string                           `json:"name"`
+	Before *repositoryStatisticsAPIResponse `json:"before"`
+	After  repositoryStatisticsAPIResponse  `json:"after"`
+}
+
+func newRepositoryStatisticsAPIResponse(st *models.RepositoryStatistics) repositoryStatisticsAPIResponse {
+	resp := repositoryStatisticsAPIResponse{
+		TagsCount:      st.TagsCount,
+		ManifestsCount: st.ManifestsCount,
+		Size:           st.Size,
+		CalculatedAt:   st.CalculatedAt,
+	}
+	if st.LastPublishedAt.Valid {
+		resp.LastPublishedAt = &st.LastPublishedAt.Time
+	}
+
+	return resp
+}
+
+// findRepository returns the repository targeted by the request, appending the corresponding error to the context and
+// returning nil if the metadata database is disabled or the repository does not exist.
+func (h *repositoryStatisticsHandler) findRepository() *models.Repository {
+	if h.App.db == nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported.WithDetail("repository statistics require the metadata database"))
+		return nil
+	}
+
+	repoPath := h.Repository.Named().Name()
+	repo, err := datastore.NewRepositoryStore(h.App.db).FindByPath(h, repoPath)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return nil
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": repoPath}))
+		return nil
+	}
+
+	return repo
+}
+
+// recalculate recalculates the statistics of repo, unless the maximum number of concurrent recalculations was reached,
+// in which case the corresponding error is appended to the context and nil is returned.
+func (h *repositoryStatisticsHandler) recalculate(repo *models.Repository) *models.RepositoryStatistics {
+	select {
+	case h.App.statisticsRecalculations <- struct{}{}:
+		defer func() { <-h.App.statisticsRecalculations }()
+	default:
+		h.Errors = append(h.Errors, errcode.ErrorCodeTooManyRequests.WithDetail("too many concurrent repository statistics recalculations, try again later"))
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(h, statisticsRecalculationTimeout)
+	defer cancel()
+
+	st, err := datastore.NewRepositoryStatisticsStore(h.App.db).Recalculate(ctx, repo)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return nil
+	}
+
+	return st
+}
+
+func (h *repositoryStatisticsHandler) writeResponse(w http.ResponseWriter, resp interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+	}
+}
+
+// GetRepositoryStatistics returns the statistics of a repository, as of the last time they were calculated. Statistics
+// are calculated on first access, unless the registry is read-only. Only supported by the metadata database backend.
+func (h *repositoryStatisticsHandler) GetRepositoryStatistics(w http.ResponseWriter, r *http.Request) {
+	repo := h.findRepository()
+	if repo == nil {
+		return
+	}
+
+	st, err := datastore.NewRepositoryStatisticsStore(h.App.db).FindByRepository(h, repo)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if st == nil {
+		if h.readOnly {
+			h.Errors = append(h.Errors, v1.ErrorCodeRepositoryStatisticsUnknown.WithDetail(map[string]string{"name": repo.Path}))
+			return
+		}
+		if st = h.recalculate(repo); st == nil {
+			return
+		}
+	}
+
+	h.writeResponse(w, getRepositoryStatisticsAPIResponse{
+		Name:                            repo.Path,
+		repositoryStatisticsAPIResponse: newRepositoryStatisticsAPIResponse(st),
+	})
+}
+
+// RecalculateRepositoryStatistics recalculates the statistics of a repository from the tags, manifests and blobs it
+// holds, replacing the stored ones, which may have drifted. Both the previous statistics, if any, and the recalculated
+// ones are returned. Only supported by the metadata database backend.
+func (h *repositoryStatisticsHandler) RecalculateRepositoryStatistics(w http.ResponseWriter, r *http.Request) {
+	repo := h.findRepository()
+	if repo == nil {
+		return
+	}
+
+	before, err := datastore.NewRepositoryStatisticsStore(h.App.db).FindByRepository(h, repo)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	after := h.recalculate(repo)
+	if after == nil {
+		return
+	}
+
+	log := dcontext.GetLoggerWithFields(h, map[interface{}]interface{}{
+		"repository":      repo.Path,
+		"tags_count":      after.TagsCount,
+		"manifests_count": after.ManifestsCount,
+		"size_bytes":      after.Size,
+	})
+	resp := recalculateRepositoryStatisticsAPIResponse{
+		Name:  repo.Path,
+		After: newRepositoryStatisticsAPIResponse(after),
+	}
+	if before != nil {
+		b := newRepositoryStatisticsAPIResponse(before)
+		resp.Before = &b
+		log = log.WithFields(map[string]interface{}{
+			"previous_tags_count":      before.TagsCount,
+			"previous_manifests_count": before.ManifestsCount,
+			"previous_size_bytes":      before.Size,
+		})
+	}
+	log.Info("repository statistics recalculated")
+
+	h.writeResponse(w, resp)
+}
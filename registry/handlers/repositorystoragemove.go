@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/gorilla/handlers"
+)
+
+// repositoryStorageMoveDispatcher constructs the repository storage move handler api endpoint.
+func repositoryStorageMoveDispatcher(ctx *Context, r *http.Request) http.Handler {
+	h := &repositoryStorageMoveHandler{
+		Context: ctx,
+	}
+
+	handler := handlers.MethodHandler{
+		"GET": http.HandlerFunc(h.GetStorageMove),
+	}
+	if !ctx.readOnly {
+		handler["PUT"] = http.HandlerFunc(h.ScheduleStorageMove)
+	}
+
+	return handler
+}
+
+// repositoryStorageMoveHandler handles requests to relocate repositories to the target storage of the data mover.
+type repositoryStorageMoveHandler struct {
+	*Context
+}
+
+type repositoryStorageMoveAPIResponse struct {
+	Name      string     `json:"name"`
+	State     string     `json:"state"`
+	Error     string     `json:"error,omitempty"`
+	Attempts  int        `json:"attempts"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+func newRepositoryStorageMoveAPIResponse(path string, m *models.StorageMove) repositoryStorageMoveAPIResponse {
+	resp := repositoryStorageMoveAPIResponse{
+		Name:      path,
+		State:     string(m.State),
+		Error:     m.Error,
+		Attempts:  m.ReviewCount,
+		CreatedAt: m.CreatedAt,
+	}
+	if m.UpdatedAt.Valid {
+		resp.UpdatedAt = &m.UpdatedAt.Time
+	}
+
+	return resp
+}
+
+// findRepository returns the repository targeted by the request, appending the corresponding error to the context and
+// returning nil if the data mover is disabled or the repository does not exist.
+func (h *repositoryStorageMoveHandler) findRepository() *models.Repository {
+	if h.App.dataMoverRegistry == nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported.WithDetail("repository storage moves require the data mover to be enabled"))
+		return nil
+	}
+
+	repoPath := h.Repository.Named().Name()
+	repo, err := datastore.NewRepositoryStore(h.App.db).FindByPath(h, repoPath)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return nil
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": repoPath}))
+		return nil
+	}
+
+	return repo
+}
+
+func (h *repositoryStorageMoveHandler) writeStorageMove(w http.ResponseWriter, status int, path string, m *models.StorageMove) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(newRepositoryStorageMoveAPIResponse(path, m)); err != nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+	}
+}
+
+// ScheduleStorageMove schedules the relocation of a repository to the target storage of the data mover. The move is
+// processed in the background. Only supported by the metadata database backend.
+func (h *repositoryStorageMoveHandler) ScheduleStorageMove(w http.ResponseWriter, r *http.Request) {
+	repo := h.findRepository()
+	if repo == nil {
+		return
+	}
+
+	m := &models.StorageMove{NamespaceID: repo.NamespaceID, RepositoryID: repo.ID}
+	if err := datastore.NewStorageMoveStore(h.App.db).Create(h, m); err != nil {
+		if errors.Is(err, datastore.ErrStorageMoveExists) {
+			h.Errors = append(h.Errors, v1.ErrorCodeStorageMoveExists.WithDetail(map[string]string{"name": repo.Path}))
+			return
+		}
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	dcontext.GetLoggerWithField(h, "repository", repo.Path).Info("repository storage move scheduled")
+
+	h.writeStorageMove(w, http.StatusAccepted, repo.Path, m)
+}
+
+// GetStorageMove returns the status of the storage move of a repository.
+func (h *repositoryStorageMoveHandler) GetStorageMove(w http.ResponseWriter, r *http.Request) {
+	repo := h.findRepository()
+	if repo == nil {
+		return
+	}
+
+	m, err := datastore.NewStorageMoveStore(h.App.db).FindByRepository(h, repo)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if m == nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeStorageMoveUnknown.WithDetail(map[string]string{"name": repo.Path}))
+		return
+	}
+
+	h.writeStorageMove(w, http.StatusOK, repo.Path, m)
+}
+
+// isStorageMoveRouted determines whether the repository targeted by the request was relocated to the target storage
+// of the data mover, in which case it must be served from there.
+func isStorageMoveRouted(ctx *Context) (bool, error) {
+	if ctx.App.dataMoverRegistry == nil || !ctx.useDatabase || ctx.Repository == nil {
+		return false, nil
+	}
+
+	repo, err := datastore.NewRepositoryStore(ctx.App.db).FindByPath(ctx, ctx.Repository.Named().Name())
+	if err != nil || repo == nil {
+		return false, err
+	}
+
+	m, err := datastore.NewStorageMoveStore(ctx.App.db).FindByRepository(ctx, repo)
+	if err != nil {
+		return false, err
+	}
+
+	return m != nil && m.State.Routed(), nil
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/docker/distribution"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/gorilla/handlers"
+	"github.com/opencontainers/go-digest"
+)
+
+// repositoryTagDispatcher constructs the repository tag handler api endpoint.
+func repositoryTagDispatcher(ctx *Context, r *http.Request) http.Handler {
+	h := &repositoryTagHandler{
+		Context: ctx,
+		Tag:     getTag(ctx),
+	}
+
+	handler := handlers.MethodHandler{}
+	if !ctx.readOnly {
+		handler["PUT"] = http.HandlerFunc(h.PutTag)
+	}
+
+	return handler
+}
+
+// repositoryTagHandler handles requests to tag existing manifests of a repository.
+type repositoryTagHandler struct {
+	*Context
+
+	Tag string
+}
+
+type putTagAPIRequest struct {
+	Digest string `json:"digest"`
+}
+
+type putTagAPIResponse struct {
+	Name           string `json:"name"`
+	Tag            string `json:"tag"`
+	Digest         string `json:"digest"`
+	PreviousDigest string `json:"previous_digest,omitempty"`
+}
+
+// PutTag points a tag to a manifest that already exists in the repository, creating the tag or moving it from the
+// manifest it currently points to. Unlike a manifest push by tag, the manifest payload is not uploaded again, making
+// this a metadata only operation. As with manifest pushes, an If-Match header makes the request conditional on the
+// digest the tag currently points to. Only supported by the metadata database backend.
+func (h *repositoryTagHandler) PutTag(w http.ResponseWriter, r *http.Request) {
+	if h.App.db == nil || !h.useDatabase {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported.WithDetail("retagging requires the metadata database"))
+		return
+	}
+
+	var req putTagAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeDigestInvalid.WithDetail(fmt.Sprintf("invalid request body: %v", err)))
+		return
+	}
+	dgst, err := digest.Parse(req.Digest)
+	if err != nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeDigestInvalid.WithDetail(err))
+		return
+	}
+
+	repoPath := h.Repository.Named().Name()
+	log := dcontext.GetLoggerWithFields(h, map[interface{}]interface{}{
+		"repository": repoPath,
+		"tag":        h.Tag,
+		"digest":     dgst,
+	})
+
+	// Serialize with concurrent pushes and retags of the same tag, as done for manifest pushes by tag.
+	unlock, err := h.App.lockTag(h, repoPath, h.Tag)
+	if err != nil {
+		h.Errors = append(h.Errors, tagLockErr(err))
+		return
+	}
+	defer unlock()
+
+	rStore := datastore.NewRepositoryStore(h.App.db)
+	repo, err := rStore.FindByPath(h, repoPath)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": repoPath}))
+		return
+	}
+
+	m, err := rStore.FindManifestByDigest(h, repo, dgst)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if m == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeManifestUnknown.WithDetail(map[string]string{"digest": dgst.String()}))
+		return
+	}
+
+	var previousDigest digest.Digest
+	previous, err := rStore.FindManifestByTagName(h, repo, h.Tag)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if previous != nil {
+		previousDigest = previous.Digest
+	}
+
+	// Fail early if the If-Match precondition does not hold. The check is repeated atomically when switching the tag.
+	ifMatch, ok := ifMatchDigest(r)
+	if ok && ifMatch != previousDigest {
+		h.Errors = append(h.Errors, tagPreconditionFailedErr(h.Tag, previousDigest))
+		return
+	}
+
+	if previous == nil {
+		if err := h.App.applyTagsQuota(h, rStore, repo, h.Tag); err != nil {
+			h.Errors = append(h.Errors, err)
+			return
+		}
+	}
+
+	if err := dbTagManifest(h, h.App.db, dgst, h.Tag, repoPath, ifMatch); err != nil {
+		switch {
+		case errors.Is(err, datastore.ErrTagManifestMismatch):
+			// the tag was moved by a request that did not honor the tag lock, e.g. when tag locks are disabled
+			h.Errors = append(h.Errors, tagPreconditionFailedErr(h.Tag, ""))
+		case errors.Is(err, datastore.ErrManifestNotFound):
+			// the manifest was deleted by the online garbage collector in the meantime
+			h.Errors = append(h.Errors, v2.ErrorCodeManifestUnknown.WithDetail(map[string]string{"digest": dgst.String()}))
+		default:
+			h.Errors = append(h.Errors, errcode.FromUnknownError(fmt.Errorf("failed to create tag in database: %w", err)))
+		}
+		return
+	}
+	h.App.invalidateCachedTags(h, repoPath, h.Tag)
+
+	if h.writeFSMetadata {
+		desc := distribution.Descriptor{Digest: m.Digest, MediaType: m.MediaType, Size: int64(len(m.Payload))}
+		if err := h.Repository.Tags(h).Tag(h, h.Tag, desc); err != nil {
+			log.WithError(err).Error("failed to mirror tag to filesystem metadata")
+		}
+	}
+	log.WithField("previous_digest", previousDigest).Info("manifest tagged")
+
+	manifest, err := dbPayloadToManifest(m.Payload, m.MediaType, m.SchemaVersion)
+	if err != nil {
+		log.WithError(err).Error("error parsing tagged manifest")
+	} else if err := h.App.eventBridge(h.Context, r).ManifestPushed(h.Repository.Named(), manifest, distribution.WithTagOption{Tag: h.Tag}); err != nil {
+		log.WithError(err).Error("error dispatching manifest push to listener")
+	}
+
+	event := audit.Event{Action: audit.ActionManifestPush, Digest: dgst, Tag: h.Tag}
+	if previousDigest != "" && previousDigest != dgst {
+		event.Action = audit.ActionTagOverwrite
+		event.PreviousDigest = previousDigest
+	}
+	h.App.auditLog(h.Context, r, event)
+	h.App.queueReplication(h.Context, models.ReplicationArtifactManifest, dgst, h.Tag)
+
+	ref, err := reference.WithDigest(h.Repository.Named(), dgst)
+	if err == nil {
+		location, err := h.urlBuilder.BuildManifestURL(ref)
+		if err != nil {
+			log.WithError(err).Error("error building manifest url from digest")
+		}
+		w.Header().Set("Location", location)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	if previous == nil {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	resp := putTagAPIResponse{
+		Name:   repoPath,
+		Tag:    h.Tag,
+		Digest: dgst.String(),
+	}
+	if previousDigest != dgst {
+		resp.PreviousDigest = previousDigest.String()
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.WithError(err).Error("error encoding repository tag response")
+	}
+}
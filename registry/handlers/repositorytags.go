@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/gorilla/handlers"
+)
+
+const (
+	defaultRepositoryTagsEntries = 100
+	maxRepositoryTagsEntries     = 100
+)
+
+// repositoryTagsDispatcher constructs the repository tags handler api endpoint.
+func repositoryTagsDispatcher(ctx *Context, r *http.Request) http.Handler {
+	h := &repositoryTagsHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		"GET": http.HandlerFunc(h.GetRepositoryTags),
+	}
+}
+
+// repositoryTagsHandler handles requests for the tags of a repository, along with their details.
+type repositoryTagsHandler struct {
+	*Context
+}
+
+type repositoryTagAPIResponse struct {
+	Name         string            `json:"name"`
+	Digest       string            `json:"digest"`
+	MediaType    string            `json:"media_type"`
+	ArtifactType string            `json:"artifact_type"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	CreatedAt    string            `json:"created_at"`
+	UpdatedAt    string            `json:"updated_at"`
+}
+
+type repositoryTagsAPIResponse struct {
+	Name      string                     `json:"name"`
+	UpdatedAt string                     `json:"updated_at"`
+	Tags      []repositoryTagAPIResponse `json:"tags"`
+}
+
+type repositoryTagsQuery struct {
+	n    int
+	last string
+}
+
+func parseRepositoryTagsQuery(q url.Values) (*repositoryTagsQuery, error) {
+	rq := &repositoryTagsQuery{n: defaultRepositoryTagsEntries}
+
+	if v := q.Get("n"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, invalidQueryParamErr("n", v)
+		}
+		if n > maxRepositoryTagsEntries {
+			n = maxRepositoryTagsEntries
+		}
+		rq.n = n
+	}
+	if v := q.Get("last"); v != "" {
+		if !anchoredTagRegexp.MatchString(v) {
+			return nil, invalidQueryParamErr("last", v)
+		}
+		rq.last = v
+	}
+
+	return rq, nil
+}
+
+// lastWriteTime returns the time of the last write to an entity, which is the time of its last update or, if it was
+// never updated, its creation.
+func lastWriteTime(createdAt time.Time, updatedAt sql.NullTime) string {
+	if updatedAt.Valid {
+		return updatedAt.Time.UTC().Format(time.RFC3339)
+	}
+	return createdAt.UTC().Format(time.RFC3339)
+}
+
+func newRepositoryTagAPIResponse(ctx context.Context, t *models.TagDetail) repositoryTagAPIResponse {
+	typ := artifactType(t.MediaType, t.ConfigMediaType)
+
+	return repositoryTagAPIResponse{
+		Name:         t.Name,
+		Digest:       t.Digest.String(),
+		MediaType:    t.MediaType,
+		ArtifactType: typ,
+		Annotations:  artifactAnnotations(ctx, typ, t.ConfigPayload),
+		CreatedAt:    t.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:    lastWriteTime(t.CreatedAt, t.UpdatedAt),
+	}
+}
+
+// GetRepositoryTags returns the tags of a repository in lexicographical order, along with the digest, media type and
+// artifact type of the tagged manifests and the time of the last write to each tag and to the repository. This allows
+// clients to find tags that were not written to for a given period, and to tell Helm charts apart from container
+// images, without inspecting manifests. Only supported by the metadata database backend.
+func (h *repositoryTagsHandler) GetRepositoryTags(w http.ResponseWriter, r *http.Request) {
+	if h.App.db == nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported.WithDetail("repository tag details require the metadata database"))
+		return
+	}
+
+	rq, err := parseRepositoryTagsQuery(r.URL.Query())
+	if err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
+
+	repoPath := h.Repository.Named().Name()
+	log := dcontext.GetLoggerWithFields(h, map[interface{}]interface{}{"repository": repoPath, "limit": rq.n, "marker": rq.last})
+	log.Debug("finding repository tag details in database")
+
+	rStore := datastore.NewRepositoryStore(h.App.db)
+	repo, err := rStore.FindByPath(h, repoPath)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": repoPath}))
+		return
+	}
+
+	// fetch one more tag than requested to determine whether there is a next page
+	tt, err := rStore.TagsDetailPaginated(h, repo, rq.n+1, rq.last)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if len(tt) > rq.n {
+		tt = tt[:rq.n]
+		u, err := h.App.linkBuilder.BuildGitLabRepositoryTagsURL(h.Repository.Named(), paginationValues(rq.n, tt[len(tt)-1].Name))
+		if err != nil {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+		w.Header().Set("Link", nextLink(u))
+	}
+
+	resp := repositoryTagsAPIResponse{
+		Name:      repoPath,
+		UpdatedAt: lastWriteTime(repo.CreatedAt, repo.UpdatedAt),
+		Tags:      make([]repositoryTagAPIResponse, 0, len(tt)),
+	}
+	for _, t := range tt {
+		resp.Tags = append(resp.Tags, newRepositoryTagAPIResponse(h, t))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+}
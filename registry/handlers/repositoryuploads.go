@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/docker/distribution"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/distribution/registry/audit"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/gorilla/handlers"
+)
+
+// repositoryUploadsDispatcher constructs the repository uploads handler api endpoint.
+func repositoryUploadsDispatcher(ctx *Context, r *http.Request) http.Handler {
+	h := &repositoryUploadsHandler{
+		Context: ctx,
+	}
+
+	handler := handlers.MethodHandler{
+		"GET": http.HandlerFunc(h.GetRepositoryUploads),
+	}
+	if !ctx.readOnly {
+		handler["DELETE"] = http.HandlerFunc(h.DeleteRepositoryUploads)
+	}
+
+	return handler
+}
+
+// repositoryUploadsHandler handles requests for the in-progress blob uploads of a repository.
+type repositoryUploadsHandler struct {
+	*Context
+}
+
+type repositoryUploadsAPIResponse struct {
+	Name    string                          `json:"name"`
+	Uploads []blobUploadProgressAPIResponse `json:"uploads"`
+}
+
+// GetRepositoryUploads returns the progress of all in-progress blob uploads of a repository, oldest first, to help
+// debugging stuck pushes. Uploads are read from the storage backend, so this is supported regardless of whether the
+// metadata database is enabled.
+func (h *repositoryUploadsHandler) GetRepositoryUploads(w http.ResponseWriter, r *http.Request) {
+	repoPath := h.Repository.Named().Name()
+	log := dcontext.GetLoggerWithField(h, "repository", repoPath)
+	log.Debug("listing repository uploads in storage")
+
+	uu, err := storage.ListUploads(h, h.storageDriver, repoPath)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	resp := repositoryUploadsAPIResponse{
+		Name:    repoPath,
+		Uploads: make([]blobUploadProgressAPIResponse, 0, len(uu)),
+	}
+	for _, u := range uu {
+		resp.Uploads = append(resp.Uploads, newBlobUploadProgressAPIResponse(u))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+}
+
+// DeleteRepositoryUploads cancels all in-progress blob uploads of a repository, removing their partial data from the
+// storage backend, and returns the uploads that were cancelled. This allows cleaning up the sessions left behind by
+// interrupted pushes without waiting for them to be purged. Uploads are read from the storage backend, so this is
+// supported regardless of whether the metadata database is enabled.
+func (h *repositoryUploadsHandler) DeleteRepositoryUploads(w http.ResponseWriter, r *http.Request) {
+	repoPath := h.Repository.Named().Name()
+	log := dcontext.GetLoggerWithField(h, "repository", repoPath)
+	log.Info("cancelling repository uploads")
+
+	uu, err := storage.ListUploads(h, h.storageDriver, repoPath)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	resp := repositoryUploadsAPIResponse{
+		Name:    repoPath,
+		Uploads: make([]blobUploadProgressAPIResponse, 0, len(uu)),
+	}
+	blobs := h.Repository.Blobs(h)
+	for _, u := range uu {
+		// Uploads are cancelled through the blob store rather than by deleting their directory, so that any resources
+		// held by the storage backend, such as pending multipart uploads, are released as well.
+		bw, err := blobs.Resume(h, u.ID)
+		if err != nil {
+			if errors.Is(err, distribution.ErrBlobUploadUnknown) {
+				// completed or cancelled in the meantime
+				continue
+			}
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+		if err := bw.Cancel(h); err != nil {
+			log.WithError(err).WithField("upload_uuid", u.ID).Error("error cancelling upload")
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+
+		if h.uploadStates != nil {
+			if err := h.uploadStates.Delete(h, u.ID); err != nil {
+				log.WithError(err).Warn("failed to delete persisted upload state")
+			}
+		}
+		if h.tracksUploadsInDatabase() {
+			if err := datastore.NewBlobUploadStore(h.db).Delete(h, u.ID); err != nil && !errors.Is(err, datastore.ErrBlobUploadNotFound) {
+				log.WithError(err).Warn("failed to delete blob upload from database")
+			}
+		}
+		h.auditLog(h.Context, r, audit.Event{Action: audit.ActionUploadCancel, UploadUUID: u.ID})
+
+		resp.Uploads = append(resp.Uploads, newBlobUploadProgressAPIResponse(u))
+	}
+	log.WithField("count", len(resp.Uploads)).Info("repository uploads cancelled")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+}
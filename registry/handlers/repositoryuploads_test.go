@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryUploads_ReadOnly(t *testing.T) {
+	_, server := newUploadTestApp(t, func(config *configuration.Configuration) {
+		config.Storage["maintenance"]["readonly"] = map[interface{}]interface{}{"enabled": true}
+	})
+	u := server.URL + "/gitlab/v1/repositories/foo/bar/uploads"
+
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	req, err := http.NewRequest(http.MethodDelete, u, nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
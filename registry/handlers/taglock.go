@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/distribution/uuid"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// defaultTagLockTTL is the default lease of tag locks, which are refreshed while held. It bounds the time for
+	// which a tag remains locked if the instance holding the lock dies.
+	defaultTagLockTTL = 30 * time.Second
+	// defaultTagLockTimeout is the default time to wait for a tag lock held by a concurrent request.
+	defaultTagLockTimeout = 10 * time.Second
+
+	tagLockKeyPrefix = "registry:lock:tag:"
+
+	tagLockMinBackoff = 10 * time.Millisecond
+	tagLockMaxBackoff = 200 * time.Millisecond
+)
+
+// errTagLockTimeout is returned when a tag lock could not be acquired in time.
+var errTagLockTimeout = errors.New("timed out waiting for a concurrent write of the same tag")
+
+// tagLocker serializes concurrent writes to the same tag across registry instances.
+type tagLocker interface {
+	// Lock blocks until the lock of tag in repository repoPath is acquired, or returns errTagLockTimeout if the lock is
+	// held by someone else for too long. The returned function releases the lock.
+	Lock(ctx context.Context, repoPath, tag string) (func(), error)
+}
+
+var (
+	// tagUnlockScript deletes a lock only if still held with the given token, so that a lock that expired and was
+	// acquired by someone else is never released by the previous holder.
+	tagUnlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+	// tagRefreshScript extends the lease of a lock only if still held with the given token.
+	tagRefreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+// redisTagLocker is a tagLocker backed by redis. Locks are keys holding a random token, set only if they do not exist
+// and expiring after ttl unless refreshed by their holder.
+type redisTagLocker struct {
+	client  redis.UniversalClient
+	ttl     time.Duration
+	timeout time.Duration
+}
+
+func newRedisTagLocker(client redis.UniversalClient, ttl, timeout time.Duration) *redisTagLocker {
+	if ttl <= 0 {
+		ttl = defaultTagLockTTL
+	}
+	if timeout <= 0 {
+		timeout = defaultTagLockTimeout
+	}
+
+	return &redisTagLocker{client: client, ttl: ttl, timeout: timeout}
+}
+
+func tagLockKey(repoPath, tag string) string {
+	return tagLockKeyPrefix + repoPath + ":" + tag
+}
+
+// Lock implements tagLocker.
+func (l *redisTagLocker) Lock(ctx context.Context, repoPath, tag string) (func(), error) {
+	key := tagLockKey(repoPath, tag)
+	token := uuid.Generate().String()
+
+	deadline := time.NewTimer(l.timeout)
+	defer deadline.Stop()
+
+	backoff := tagLockMinBackoff
+	for {
+		ok, err := l.client.SetNX(ctx, key, token, l.ttl).Result()
+		if err != nil {
+			return nil, fmt.Errorf("acquiring tag lock: %w", err)
+		}
+		if ok {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline.C:
+			return nil, errTagLockTimeout
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > tagLockMaxBackoff {
+			backoff = tagLockMaxBackoff
+		}
+	}
+
+	done := make(chan struct{})
+	go l.refresh(ctx, key, token, done)
+
+	return func() {
+		close(done)
+		// release the lock even if the request was canceled in the meantime
+		if err := tagUnlockScript.Run(context.Background(), l.client, []string{key}, token).Err(); err != nil {
+			dcontext.GetLogger(ctx).WithError(err).WithField("tag", tag).Warn("failed to release tag lock")
+		}
+	}, nil
+}
+
+// refresh extends the lease of the lock held with token until done is closed.
+func (l *redisTagLocker) refresh(ctx context.Context, key, token string, done <-chan struct{}) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := tagRefreshScript.Run(context.Background(), l.client, []string{key}, token, l.ttl.Milliseconds()).Err(); err != nil {
+				dcontext.GetLogger(ctx).WithError(err).Warn("failed to refresh tag lock")
+			}
+		}
+	}
+}
+
+// lockTag acquires the lock of tag in repoPath, if tag locks are enabled. On success, the returned function releases
+// the lock and must always be called.
+func (app *App) lockTag(ctx context.Context, repoPath, tag string) (func(), error) {
+	if app.tagLocks == nil {
+		return func() {}, nil
+	}
+
+	unlock, err := app.tagLocks.Lock(ctx, repoPath, tag)
+	if err != nil {
+		dcontext.GetLoggerWithFields(ctx, map[interface{}]interface{}{"repository": repoPath, "tag": tag}).
+			WithError(err).Warn("failed to acquire tag lock")
+		return nil, err
+	}
+
+	return unlock, nil
+}
+
+// lockTags acquires the locks of multiple tags in repoPath, if tag locks are enabled. Locks are acquired in the
+// lexical order of tag names, so that concurrent requests locking overlapping sets of tags can not deadlock. If any
+// lock can not be acquired, those already acquired are released. On success, the returned function releases all locks
+// and must always be called.
+func (app *App) lockTags(ctx context.Context, repoPath string, tags []string) (func(), error) {
+	sorted := make([]string, len(tags))
+	copy(sorted, tags)
+	sort.Strings(sorted)
+
+	unlocks := make([]func(), 0, len(sorted))
+	unlockAll := func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+	for _, tag := range sorted {
+		unlock, err := app.lockTag(ctx, repoPath, tag)
+		if err != nil {
+			unlockAll()
+			return nil, err
+		}
+		unlocks = append(unlocks, unlock)
+	}
+
+	return unlockAll, nil
+}
+
+// tagLockErr converts a failure to acquire a tag lock into an API error. The cause is only disclosed to clients if
+// the lock was held by a concurrent request for too long.
+func tagLockErr(err error) errcode.Error {
+	if errors.Is(err, errTagLockTimeout) {
+		return errcode.ErrorCodeUnavailable.WithDetail(err.Error())
+	}
+	return errcode.ErrorCodeUnavailable.WithDetail("failed to acquire tag lock")
+}
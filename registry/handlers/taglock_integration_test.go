@@ -0,0 +1,125 @@
+// +build integration
+
+package handlers
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTagLocker(t *testing.T, ttl, timeout time.Duration) *redisTagLocker {
+	t.Helper()
+
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("the 'REDIS_ADDR' environment variable must be set to enable this test")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+
+	return newRedisTagLocker(client, ttl, timeout)
+}
+
+func TestRedisTagLocker_Lock(t *testing.T) {
+	l := newTestTagLocker(t, time.Second, 5*time.Second)
+	ctx := context.Background()
+	repoPath := "foo/" + t.Name()
+
+	var mu sync.Mutex
+	var holders, maxHolders int
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			unlock, err := l.Lock(ctx, repoPath, "latest")
+			require.NoError(t, err)
+			defer unlock()
+
+			mu.Lock()
+			holders++
+			if holders > maxHolders {
+				maxHolders = holders
+			}
+			mu.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mu.Lock()
+			holders--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 1, maxHolders)
+
+	// locks of other tags are independent
+	unlock, err := l.Lock(ctx, repoPath, "latest")
+	require.NoError(t, err)
+	defer unlock()
+	unlockOther, err := l.Lock(ctx, repoPath, "stable")
+	require.NoError(t, err)
+	unlockOther()
+}
+
+func TestRedisTagLocker_Lock_Timeout(t *testing.T) {
+	l := newTestTagLocker(t, time.Second, 100*time.Millisecond)
+	ctx := context.Background()
+	repoPath := "foo/" + t.Name()
+
+	unlock, err := l.Lock(ctx, repoPath, "latest")
+	require.NoError(t, err)
+	defer unlock()
+
+	_, err = l.Lock(ctx, repoPath, "latest")
+	require.ErrorIs(t, err, errTagLockTimeout)
+}
+
+func TestRedisTagLocker_Lock_RefreshedWhileHeld(t *testing.T) {
+	l := newTestTagLocker(t, 300*time.Millisecond, 100*time.Millisecond)
+	ctx := context.Background()
+	repoPath := "foo/" + t.Name()
+
+	unlock, err := l.Lock(ctx, repoPath, "latest")
+	require.NoError(t, err)
+
+	// the lock outlives its lease while held
+	time.Sleep(time.Second)
+	_, err = l.Lock(ctx, repoPath, "latest")
+	require.ErrorIs(t, err, errTagLockTimeout)
+
+	unlock()
+	unlock, err = l.Lock(ctx, repoPath, "latest")
+	require.NoError(t, err)
+	unlock()
+}
+
+func TestRedisTagLocker_Unlock_ExpiredLock(t *testing.T) {
+	l := newTestTagLocker(t, time.Second, time.Second)
+	ctx := context.Background()
+	repoPath := "foo/" + t.Name()
+
+	unlock, err := l.Lock(ctx, repoPath, "latest")
+	require.NoError(t, err)
+
+	// simulate the expiry of the lock and its acquisition by someone else
+	key := tagLockKey(repoPath, "latest")
+	require.NoError(t, l.client.Set(ctx, key, "other", time.Minute).Err())
+	defer l.client.Del(ctx, key)
+
+	// releasing the expired lock must not release the one held by someone else
+	unlock()
+	v, err := l.client.Get(ctx, key).Result()
+	require.NoError(t, err)
+	require.Equal(t, "other", v)
+}
@@ -3,15 +3,21 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/docker/distribution"
 	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
 	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
 	"github.com/docker/distribution/registry/datastore"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/gorilla/handlers"
@@ -74,14 +80,32 @@ func dbGetTags(ctx context.Context, db datastore.Queryer, repoPath string, n int
 	return tags, moreEntries, nil
 }
 
+// paginateTags returns up to n of the tags that sort lexically after last, and whether there are more. Used to
+// paginate the tags read from the filesystem in the same way as in the metadata database.
+func paginateTags(tags []string, n int, last string) ([]string, bool) {
+	sort.Strings(tags)
+	i := sort.Search(len(tags), func(i int) bool { return tags[i] > last })
+	tags = tags[i:]
+	if len(tags) <= n {
+		if len(tags) == 0 {
+			// keep the response consistent with that of an empty repository
+			return nil, false
+		}
+		return tags, false
+	}
+
+	return tags[:n], true
+}
+
 // GetTags returns a json list of tags for a specific image name.
 func (th *tagsHandler) GetTags(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
-	// Pagination headers are currently only supported by the metadata database backend
 	q := r.URL.Query()
 	lastEntry := q.Get("last")
 	maxEntries, err := strconv.Atoi(q.Get("n"))
+	// the filesystem backend returns all tags at once unless the client explicitly asked for a page
+	paginate := lastEntry != "" || (err == nil && maxEntries > 0)
 	if err != nil || maxEntries <= 0 {
 		maxEntries = maximumReturnedEntries
 	}
@@ -114,19 +138,22 @@ func (th *tagsHandler) GetTags(w http.ResponseWriter, r *http.Request) {
 			}
 			return
 		}
+		if paginate {
+			tags, moreEntries = paginateTags(tags, maxEntries, lastEntry)
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 
-	// Add a link header if there are more entries to retrieve (only supported by the metadata database backend)
+	// Add a link header if there are more entries to retrieve
 	if moreEntries {
 		lastEntry = tags[len(tags)-1]
-		urlStr, err := createLinkEntry(r.URL.String(), maxEntries, lastEntry)
+		urlStr, err := th.App.linkBuilder.BuildTagsURL(th.Repository.Named(), paginationValues(maxEntries, lastEntry))
 		if err != nil {
 			th.Errors = append(th.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
 			return
 		}
-		w.Header().Set("Link", urlStr)
+		w.Header().Set("Link", nextLink(urlStr))
 	}
 
 	enc := json.NewEncoder(w)
@@ -166,17 +193,41 @@ const (
 	tagDeleteGCLockTimeout  = 5 * time.Second
 )
 
-func dbDeleteTag(ctx context.Context, db datastore.Handler, repoPath string, tagName string) error {
+// errRepositoryArchived is returned when attempting to delete a tag from an archived repository.
+var errRepositoryArchived = errors.New("repository is archived")
+
+// deleteTag deletes a tag from the metadata database. This is the path shared by all single tag deletes, regardless
+// of where they come from: archived repositories are refused and the cached tag is invalidated. If notify is not nil,
+// it's called with the descriptor of the manifest that the deleted tag pointed to. Callers must hold the tag lock.
+func (app *App) deleteTag(ctx context.Context, repoPath, tagName string, notify func(distribution.Descriptor)) (distribution.Descriptor, error) {
+	desc, err := dbDeleteTag(ctx, app.db, repoPath, tagName)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+	app.invalidateCachedTags(ctx, repoPath, tagName)
+	if notify != nil {
+		notify(desc)
+	}
+
+	return desc, nil
+}
+
+// dbDeleteTag deletes a tag from a repository. The descriptor of the manifest that the tag pointed to is returned.
+// Tags of archived repositories are not deleted, errRepositoryArchived is returned instead.
+func dbDeleteTag(ctx context.Context, db datastore.Handler, repoPath string, tagName string) (distribution.Descriptor, error) {
 	log := dcontext.GetLoggerWithFields(ctx, map[interface{}]interface{}{"repository": repoPath, "tag": tagName})
 	log.Debug("deleting tag from repository in database")
 
 	rStore := datastore.NewRepositoryStore(db)
 	r, err := rStore.FindByPath(ctx, repoPath)
 	if err != nil {
-		return err
+		return distribution.Descriptor{}, err
 	}
 	if r == nil {
-		return distribution.ErrRepositoryUnknown{Name: repoPath}
+		return distribution.Descriptor{}, distribution.ErrRepositoryUnknown{Name: repoPath}
+	}
+	if r.Archived {
+		return distribution.Descriptor{}, errRepositoryArchived
 	}
 
 	// We first check if the tag exists and grab the corresponding manifest ID, then we find and lock a related online
@@ -185,10 +236,20 @@ func dbDeleteTag(ctx context.Context, db datastore.Handler, repoPath string, tag
 
 	t, err := rStore.FindTagByName(ctx, r, tagName)
 	if err != nil {
-		return err
+		return distribution.Descriptor{}, err
 	}
 	if t == nil {
-		return distribution.ErrTagUnknown{Tag: tagName}
+		return distribution.Descriptor{}, distribution.ErrTagUnknown{Tag: tagName}
+	}
+
+	// resolve the tagged manifest, so that it can be included in the tag delete notification
+	m, err := rStore.FindManifestByID(ctx, r, t.ManifestID)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+	var desc distribution.Descriptor
+	if m != nil {
+		desc = distribution.Descriptor{Digest: m.Digest, MediaType: m.MediaType}
 	}
 
 	// Prevent long running transactions by setting an upper limit of tagDeleteGCLockTimeout. If the GC is holding
@@ -200,13 +261,13 @@ func dbDeleteTag(ctx context.Context, db datastore.Handler, repoPath string, tag
 
 	tx, err := db.BeginTx(txCtx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create database transaction: %w", err)
+		return distribution.Descriptor{}, fmt.Errorf("failed to create database transaction: %w", err)
 	}
 	defer tx.Rollback()
 
 	mts := datastore.NewGCManifestTaskStore(tx)
 	if _, err := mts.FindAndLockBefore(txCtx, r.NamespaceID, r.ID, t.ManifestID, time.Now().Add(tagDeleteGCReviewWindow)); err != nil {
-		return err
+		return distribution.Descriptor{}, err
 	}
 
 	// The `SELECT FOR UPDATE` on the review queue and the subsequent tag delete must be executed within the same
@@ -216,17 +277,17 @@ func dbDeleteTag(ctx context.Context, db datastore.Handler, repoPath string, tag
 	rStore = datastore.NewRepositoryStore(tx)
 	found, err := rStore.DeleteTagByName(txCtx, r, tagName)
 	if err != nil {
-		return err
+		return distribution.Descriptor{}, err
 	}
 	if !found {
-		return distribution.ErrTagUnknown{Tag: tagName}
+		return distribution.Descriptor{}, distribution.ErrTagUnknown{Tag: tagName}
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit database transaction: %w", err)
+		return distribution.Descriptor{}, fmt.Errorf("failed to commit database transaction: %w", err)
 	}
 
-	return nil
+	return desc, nil
 }
 
 // DeleteTag deletes a tag for a specific image name.
@@ -238,6 +299,13 @@ func (th *tagHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	unlock, err := th.App.lockTag(th, th.Repository.Named().Name(), th.Tag)
+	if err != nil {
+		th.Errors = append(th.Errors, tagLockErr(err))
+		return
+	}
+	defer unlock()
+
 	if th.writeFSMetadata {
 		tagService := th.Repository.Tags(th)
 		if err := tagService.Untag(th.Context, th.Tag); err != nil {
@@ -247,16 +315,27 @@ func (th *tagHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if th.useDatabase {
-		if err := dbDeleteTag(th.Context, th.db, th.Repository.Named().Name(), th.Tag); err != nil {
+		var notify func(distribution.Descriptor)
+		// when writing filesystem metadata, the notification is sent by the decorated tag service above
+		if !th.writeFSMetadata {
+			notify = func(desc distribution.Descriptor) { th.App.notifyTagDeleted(th.Context, r, th.Tag, desc) }
+		}
+		if _, err := th.App.deleteTag(th, th.Repository.Named().Name(), th.Tag, notify); err != nil {
 			th.appendDeleteTagError(err)
 			return
 		}
 	}
 
 	w.WriteHeader(http.StatusAccepted)
+
+	th.auditLog(th.Context, r, audit.Event{Action: audit.ActionTagDelete, Tag: th.Tag})
 }
 
 func (th *tagHandler) appendDeleteTagError(err error) {
+	if errors.Is(err, errRepositoryArchived) {
+		th.Errors = append(th.Errors, v1.ErrorCodeRepositoryArchived.WithDetail(map[string]string{"name": th.Repository.Named().Name()}))
+		return
+	}
 	switch err.(type) {
 	case distribution.ErrRepositoryUnknown:
 		th.Errors = append(th.Errors, v2.ErrorCodeNameUnknown)
@@ -267,3 +346,233 @@ func (th *tagHandler) appendDeleteTagError(err error) {
 	}
 	return
 }
+
+// maxTagsPerBatchDelete is the maximum number of tags that can be deleted with a single batch request.
+const maxTagsPerBatchDelete = 1000
+
+var anchoredTagRegexp = regexp.MustCompile(`^` + reference.TagRegexp.String() + `$`)
+
+// tagsBatchDispatcher constructs the tags batch handler api endpoint.
+func tagsBatchDispatcher(ctx *Context, r *http.Request) http.Handler {
+	thandler := handlers.MethodHandler{}
+
+	tagsBatchHandler := &tagsBatchHandler{
+		Context: ctx,
+	}
+
+	if !ctx.readOnly {
+		thandler["DELETE"] = http.HandlerFunc(tagsBatchHandler.DeleteTags)
+	}
+
+	return thandler
+}
+
+// tagsBatchHandler handles requests for multiple tags under a repository name.
+type tagsBatchHandler struct {
+	*Context
+}
+
+const (
+	tagsBatchStatusDeleted  = "deleted"
+	tagsBatchStatusNotFound = "not_found"
+)
+
+type tagsBatchDeleteRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type tagsBatchDeleteResult struct {
+	Tag    string `json:"tag"`
+	Status string `json:"status"`
+}
+
+type tagsBatchDeleteResponse struct {
+	Name    string                  `json:"name"`
+	Results []tagsBatchDeleteResult `json:"results"`
+}
+
+// dbDeleteTags deletes multiple tags from a repository within a single transaction. The tags that were found and
+// deleted are returned, mapped to the descriptor of the manifest that they pointed to. Tags that do not exist are
+// ignored. Callers must hold the locks of all tags.
+func dbDeleteTags(ctx context.Context, db datastore.Handler, repoPath string, tagNames []string) (map[string]distribution.Descriptor, error) {
+	log := dcontext.GetLoggerWithFields(ctx, map[interface{}]interface{}{"repository": repoPath, "tags_count": len(tagNames)})
+	log.Debug("deleting tags from repository in database")
+
+	rStore := datastore.NewRepositoryStore(db)
+	r, err := rStore.FindByPath(ctx, repoPath)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, distribution.ErrRepositoryUnknown{Name: repoPath}
+	}
+
+	// Same as for single tag deletes (see dbDeleteTag), we need to lock any related online GC manifest review records
+	// before deleting each tag. The timeout is shared by the whole batch to prevent long running transactions.
+	txCtx, cancel := context.WithTimeout(ctx, tagDeleteGCLockTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(txCtx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rStore = datastore.NewRepositoryStore(tx)
+	mts := datastore.NewGCManifestTaskStore(tx)
+	// manifests are resolved once per ID, which also tracks the review records that were already locked
+	manifests := make(map[int64]distribution.Descriptor)
+	deleted := make(map[string]distribution.Descriptor, len(tagNames))
+
+	for _, name := range tagNames {
+		t, err := rStore.FindTagByName(txCtx, r, name)
+		if err != nil {
+			return nil, err
+		}
+		if t == nil {
+			continue
+		}
+
+		desc, ok := manifests[t.ManifestID]
+		if !ok {
+			if _, err := mts.FindAndLockBefore(txCtx, r.NamespaceID, r.ID, t.ManifestID, time.Now().Add(tagDeleteGCReviewWindow)); err != nil {
+				return nil, err
+			}
+			m, err := rStore.FindManifestByID(txCtx, r, t.ManifestID)
+			if err != nil {
+				return nil, err
+			}
+			if m != nil {
+				desc = distribution.Descriptor{Digest: m.Digest, MediaType: m.MediaType}
+			}
+			manifests[t.ManifestID] = desc
+		}
+
+		found, err := rStore.DeleteTagByName(txCtx, r, name)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			deleted[name] = desc
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit database transaction: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// DeleteTags deletes multiple tags for a specific image name.
+func (th *tagsBatchHandler) DeleteTags(w http.ResponseWriter, r *http.Request) {
+	dcontext.GetLogger(th).Debug("DeleteTags")
+
+	if th.App.isCache {
+		th.Errors = append(th.Errors, errcode.ErrorCodeUnsupported)
+		return
+	}
+
+	var req tagsBatchDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		th.Errors = append(th.Errors, v2.ErrorCodeTagInvalid.WithDetail(fmt.Sprintf("invalid request body: %v", err)))
+		return
+	}
+	if len(req.Tags) == 0 {
+		th.Errors = append(th.Errors, v2.ErrorCodeTagInvalid.WithDetail("no tags specified"))
+		return
+	}
+	if len(req.Tags) > maxTagsPerBatchDelete {
+		th.Errors = append(th.Errors, v2.ErrorCodeTagInvalid.WithDetail(fmt.Sprintf("number of tags exceeds limit of %d", maxTagsPerBatchDelete)))
+		return
+	}
+
+	// validate and deduplicate tag names, preserving the request order
+	seen := make(map[string]struct{}, len(req.Tags))
+	tagNames := make([]string, 0, len(req.Tags))
+	for _, tag := range req.Tags {
+		if !anchoredTagRegexp.MatchString(tag) {
+			th.Errors = append(th.Errors, v2.ErrorCodeTagInvalid.WithDetail(map[string]string{"tag": tag}))
+			return
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		tagNames = append(tagNames, tag)
+	}
+
+	// all tags are locked up front and only released once deleted, so that the whole batch is serialized with
+	// concurrent writes of any of the tags
+	unlock, err := th.App.lockTags(th, th.Repository.Named().Name(), tagNames)
+	if err != nil {
+		th.Errors = append(th.Errors, tagLockErr(err))
+		return
+	}
+	defer unlock()
+
+	deleted := make(map[string]bool, len(tagNames))
+
+	if th.writeFSMetadata {
+		tagService := th.Repository.Tags(th)
+		for _, tag := range tagNames {
+			err := tagService.Untag(th.Context, tag)
+			switch err.(type) {
+			case nil:
+				deleted[tag] = true
+			case distribution.ErrTagUnknown, storagedriver.PathNotFoundError:
+				deleted[tag] = false
+			default:
+				th.appendDeleteTagsError(err)
+				return
+			}
+		}
+	}
+
+	if th.useDatabase {
+		descs, err := dbDeleteTags(th.Context, th.db, th.Repository.Named().Name(), tagNames)
+		if err != nil {
+			th.appendDeleteTagsError(err)
+			return
+		}
+		th.App.invalidateCachedTags(th, th.Repository.Named().Name(), tagNames...)
+
+		deleted = make(map[string]bool, len(descs))
+		for _, tag := range tagNames {
+			desc, ok := descs[tag]
+			deleted[tag] = ok
+			// when writing filesystem metadata, notifications are sent by the decorated tag service above
+			if ok && !th.writeFSMetadata {
+				th.App.notifyTagDeleted(th.Context, r, tag, desc)
+			}
+		}
+	}
+
+	resp := tagsBatchDeleteResponse{
+		Name:    th.Repository.Named().Name(),
+		Results: make([]tagsBatchDeleteResult, 0, len(tagNames)),
+	}
+	for _, tag := range tagNames {
+		status := tagsBatchStatusNotFound
+		if deleted[tag] {
+			status = tagsBatchStatusDeleted
+			th.auditLog(th.Context, r, audit.Event{Action: audit.ActionTagDelete, Tag: tag})
+		}
+		resp.Results = append(resp.Results, tagsBatchDeleteResult{Tag: tag, Status: status})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		th.Errors = append(th.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+}
+
+func (th *tagsBatchHandler) appendDeleteTagsError(err error) {
+	switch err.(type) {
+	case distribution.ErrRepositoryUnknown:
+		th.Errors = append(th.Errors, v2.ErrorCodeNameUnknown)
+	default:
+		th.Errors = append(th.Errors, errcode.FromUnknownError(err))
+	}
+}
@@ -5,6 +5,7 @@ package handlers
 import (
 	"testing"
 
+	"github.com/docker/distribution"
 	"github.com/docker/distribution/manifest/schema2"
 
 	"github.com/docker/distribution/registry/datastore"
@@ -50,8 +51,9 @@ func TestDeleteTagDB(t *testing.T) {
 
 	// Test
 
-	err = dbDeleteTag(env.ctx, env.db, r.Path, tag.Name)
+	desc, err := dbDeleteTag(env.ctx, env.db, r.Path, tag.Name)
 	require.NoError(t, err)
+	require.Equal(t, distribution.Descriptor{Digest: m.Digest, MediaType: m.MediaType}, desc)
 
 	// the tag shouldn't be there
 	tag, err = tStore.FindByID(env.ctx, tag.ID)
@@ -63,7 +65,7 @@ func TestDeleteTagDB_RepositoryNotFound(t *testing.T) {
 	env := newEnv(t)
 	defer env.shutdown(t)
 
-	err := dbDeleteTag(env.ctx, env.db, "foo", "bar")
+	_, err := dbDeleteTag(env.ctx, env.db, "foo", "bar")
 	require.Error(t, err, "repository not found in database")
 
 }
@@ -78,6 +80,6 @@ func TestDeleteTagDB_TagNotFound(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, r)
 
-	err = dbDeleteTag(env.ctx, env.db, r.Path, "bar")
+	_, err = dbDeleteTag(env.ctx, env.db, r.Path, "bar")
 	require.Error(t, err, "repository not found in database")
 }
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingTagLocker is a tagLocker that records the tags locked and released, blocking the lock of the tags in block
+// until the corresponding channel is closed.
+type blockingTagLocker struct {
+	mu       sync.Mutex
+	locked   []string
+	unlocked []string
+	block    map[string]chan struct{}
+	err      error
+}
+
+// Lock implements tagLocker.
+func (l *blockingTagLocker) Lock(ctx context.Context, repoPath, tag string) (func(), error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	if ch, ok := l.block[tag]; ok {
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	key := repoPath + ":" + tag
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.locked = append(l.locked, key)
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.unlocked = append(l.unlocked, key)
+	}, nil
+}
+
+func (l *blockingTagLocker) state() ([]string, []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]string(nil), l.locked...), append([]string(nil), l.unlocked...)
+}
+
+func newTagsTestApp(t *testing.T, locker tagLocker) *httptest.Server {
+	t.Helper()
+
+	config := &configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": nil,
+			"maintenance": configuration.Parameters{"uploadpurging": map[interface{}]interface{}{
+				"enabled": false,
+			}},
+		},
+	}
+	app := NewApp(context.Background(), config)
+	app.tagLocks = locker
+
+	server := httptest.NewServer(app)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func deleteTestTags(server *httptest.Server, repo, body string) (int, error) {
+	req, err := http.NewRequest(http.MethodDelete, server.URL+"/v2/"+repo+"/tags/reference", strings.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+func TestDeleteTags_BlocksOnTagLock(t *testing.T) {
+	release := make(chan struct{})
+	locker := &blockingTagLocker{block: map[string]chan struct{}{"b": release}}
+	server := newTagsTestApp(t, locker)
+
+	var status int
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		status, err = deleteTestTags(server, "foo/bar", `{"tags": ["c", "b", "a"]}`)
+	}()
+
+	// the lock of "b" is held by someone else, so the batch delete must wait for it, having only locked "a"
+	select {
+	case <-done:
+		t.Fatal("batch delete completed while a tag lock was held")
+	case <-time.After(200 * time.Millisecond):
+	}
+	locked, unlocked := locker.state()
+	require.Equal(t, []string{"foo/bar:a"}, locked)
+	require.Empty(t, unlocked)
+
+	close(release)
+	<-done
+	require.NoError(t, err)
+	// the repository does not exist, which is only found out once all tags are locked
+	require.Equal(t, http.StatusNotFound, status)
+
+	// tags are locked in lexical order and all released once the request is done
+	locked, unlocked = locker.state()
+	require.Equal(t, []string{"foo/bar:a", "foo/bar:b", "foo/bar:c"}, locked)
+	require.ElementsMatch(t, locked, unlocked)
+}
+
+func TestDeleteTags_TagLockError(t *testing.T) {
+	server := newTagsTestApp(t, &blockingTagLocker{err: errTagLockTimeout})
+
+	status, err := deleteTestTags(server, "foo/bar", `{"tags": ["a"]}`)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, status)
+}
+
+func TestLockTags_ReleasesOnError(t *testing.T) {
+	fail := errors.New("foo")
+	locker := &failingTagLocker{fail: "c", err: fail}
+	app := &App{tagLocks: locker}
+
+	_, err := app.lockTags(context.Background(), "foo/bar", []string{"c", "b", "a"})
+	require.ErrorIs(t, err, fail)
+	require.Equal(t, []string{"foo/bar:a", "foo/bar:b"}, locker.locked)
+	require.Equal(t, []string{"foo/bar:b", "foo/bar:a"}, locker.unlocked)
+}
+
+// failingTagLocker is a tagLocker that fails to lock a given tag.
+type failingTagLocker struct {
+	recordingTagLocker
+	fail string
+	err  error
+}
+
+// Lock implements tagLocker.
+func (l *failingTagLocker) Lock(ctx context.Context, repoPath, tag string) (func(), error) {
+	if tag == l.fail {
+		return nil, l.err
+	}
+	return l.recordingTagLocker.Lock(ctx, repoPath, tag)
+}
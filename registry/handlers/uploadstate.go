@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// defaultUploadStateTTL is the default time for which the state of an idle blob upload session is retained.
+	defaultUploadStateTTL = 24 * time.Hour
+
+	uploadStateKeyPrefix = "registry:upload:state:"
+)
+
+// uploadStateStore persists the state of blob upload sessions, so that uploads can be resumed by any registry
+// instance, regardless of which one handled the previous request or how its HTTP secret is configured. Each state is
+// saved along with the upload state token last issued for it, which a client must present to resume the upload. This
+// makes up for the token signature, which instances with a different HTTP secret are unable to verify.
+type uploadStateStore interface {
+	// Get returns the state of the upload identified by uuid, or nil if not found or if token is not the upload state
+	// token last issued for it.
+	Get(ctx context.Context, uuid, token string) (*blobUploadState, error)
+	// Set saves the state of an upload along with the upload state token issued for it, replacing any existing one.
+	Set(ctx context.Context, state blobUploadState, token string) error
+	// Delete removes the state of the upload identified by uuid. It is not an error if the state does not exist.
+	Delete(ctx context.Context, uuid string) error
+}
+
+// redisUploadStateStore is an uploadStateStore backed by redis. Each state is stored as a JSON string, which expires
+// after ttl without being updated. Only a hash of the token is stored.
+type redisUploadStateStore struct {
+	client redis.UniversalClient
+	ttl    time.Duration
+}
+
+func newRedisUploadStateStore(client redis.UniversalClient, ttl time.Duration) *redisUploadStateStore {
+	if ttl <= 0 {
+		ttl = defaultUploadStateTTL
+	}
+
+	return &redisUploadStateStore{client: client, ttl: ttl}
+}
+
+func uploadStateKey(uuid string) string {
+	return uploadStateKeyPrefix + uuid
+}
+
+// storedUploadState is the format in which upload states are persisted.
+type storedUploadState struct {
+	State     blobUploadState
+	TokenHash string
+}
+
+func uploadStateTokenHash(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// Get implements uploadStateStore.
+func (s *redisUploadStateStore) Get(ctx context.Context, uuid, token string) (*blobUploadState, error) {
+	p, err := s.client.Get(ctx, uploadStateKey(uuid)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getting upload state: %w", err)
+	}
+
+	var stored storedUploadState
+	if err := json.Unmarshal(p, &stored); err != nil {
+		return nil, fmt.Errorf("unmarshaling upload state: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(stored.TokenHash), []byte(uploadStateTokenHash(token))) != 1 {
+		return nil, nil
+	}
+
+	return &stored.State, nil
+}
+
+// Set implements uploadStateStore.
+func (s *redisUploadStateStore) Set(ctx context.Context, state blobUploadState, token string) error {
+	p, err := json.Marshal(storedUploadState{State: state, TokenHash: uploadStateTokenHash(token)})
+	if err != nil {
+		return fmt.Errorf("marshaling upload state: %w", err)
+	}
+
+	if err := s.client.Set(ctx, uploadStateKey(state.UUID), p, s.ttl).Err(); err != nil {
+		return fmt.Errorf("setting upload state: %w", err)
+	}
+
+	return nil
+}
+
+// Delete implements uploadStateStore.
+func (s *redisUploadStateStore) Delete(ctx context.Context, uuid string) error {
+	if err := s.client.Del(ctx, uploadStateKey(uuid)).Err(); err != nil {
+		return fmt.Errorf("deleting upload state: %w", err)
+	}
+
+	return nil
+}
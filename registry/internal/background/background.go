@@ -0,0 +1,106 @@
+// Package background provides the logic shared by the registry background workers that claim and process database
+// records in a loop, such as the retention policy enforcer, the data mover and the replicator.
+package background
+
+import (
+	"context"
+	"io/ioutil"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/sirupsen/logrus"
+	"gitlab.com/gitlab-org/labkit/correlation"
+)
+
+// BaseRetryBackoff is the delay before the first retry of a failed run.
+const BaseRetryBackoff = 30 * time.Second
+
+// Config holds the settings common to all background workers.
+type Config struct {
+	// Logger is the logger of the worker. Defaults to a logger that discards everything.
+	Logger dcontext.Logger
+	// Interval is the amount of time to sleep between runs when there is nothing to process or the last run failed.
+	Interval time.Duration
+	// Timeout is the maximum amount of time allowed to process a single record.
+	Timeout time.Duration
+	// MaxBackoff is the maximum exponential back off duration used to postpone the retry of failed runs.
+	MaxBackoff time.Duration
+}
+
+// ApplyDefaults sets the zero-valued settings of c to those of defaults.
+func (c *Config) ApplyDefaults(defaults Config) {
+	if c.Logger == nil {
+		c.Logger = defaults.Logger
+	}
+	if c.Logger == nil {
+		defaultLogger := logrus.New()
+		defaultLogger.SetOutput(ioutil.Discard)
+		c.Logger = defaultLogger
+	}
+	if c.Interval == 0 {
+		c.Interval = defaults.Interval
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaults.Timeout
+	}
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = defaults.MaxBackoff
+	}
+}
+
+// RetryBackoff returns the delay before the next attempt of a record that failed i times, doubling from
+// BaseRetryBackoff up to the configured maximum.
+func (c *Config) RetryBackoff(i int) time.Duration {
+	// this should never happen, but just in case...
+	if i < 0 {
+		return BaseRetryBackoff
+	}
+	// avoid int64 overflow
+	if i > 20 {
+		return c.MaxBackoff
+	}
+
+	d := BaseRetryBackoff * time.Duration(1<<uint(i))
+	if d > c.MaxBackoff {
+		d = c.MaxBackoff
+	}
+
+	return d
+}
+
+// InjectCorrelationID returns a copy of ctx with a new random correlation ID, and a logger derived from the configured
+// one that includes it.
+func (c *Config) InjectCorrelationID(ctx context.Context) context.Context {
+	id := correlation.SafeRandomID()
+	ctx = correlation.ContextWithCorrelation(ctx, id)
+
+	log := c.Logger.WithField("correlation_id", id)
+	return dcontext.WithLogger(ctx, log)
+}
+
+// Loop calls run until ctx is canceled. Runs happen back to back while run reports that it found something to process
+// and did not fail, otherwise the loop sleeps for the configured interval before trying again. This is a blocking
+// call, which always returns the error of the canceled context.
+func (c *Config) Loop(ctx context.Context, run func(context.Context) (bool, error)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			c.Logger.Warn("context cancelled, exiting")
+			return ctx.Err()
+		default:
+		}
+
+		found, err := run(ctx)
+		if err != nil {
+			c.Logger.WithError(err).Error("failed run")
+		}
+		if found && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(c.Interval):
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/gitlab-org/labkit/correlation"
+)
+
+func TestConfig_ApplyDefaults(t *testing.T) {
+	c := Config{Interval: time.Second}
+	c.ApplyDefaults(Config{Interval: time.Minute, Timeout: time.Hour, MaxBackoff: 24 * time.Hour})
+
+	require.NotNil(t, c.Logger)
+	require.Equal(t, time.Second, c.Interval)
+	require.Equal(t, time.Hour, c.Timeout)
+	require.Equal(t, 24*time.Hour, c.MaxBackoff)
+}
+
+func TestConfig_RetryBackoff(t *testing.T) {
+	c := Config{MaxBackoff: time.Hour}
+
+	tt := []struct {
+		count int
+		want  time.Duration
+	}{
+		{count: -1, want: 30 * time.Second},
+		{count: 0, want: 30 * time.Second},
+		{count: 1, want: time.Minute},
+		{count: 5, want: 16 * time.Minute},
+		{count: 7, want: time.Hour},
+		{count: 100, want: time.Hour},
+	}
+
+	for _, test := range tt {
+		require.Equal(t, test.want, c.RetryBackoff(test.count), "count %d", test.count)
+	}
+}
+
+func TestConfig_InjectCorrelationID(t *testing.T) {
+	c := Config{}
+	c.ApplyDefaults(Config{})
+
+	ctx := c.InjectCorrelationID(context.Background())
+	require.NotEmpty(t, correlation.ExtractFromContext(ctx))
+	require.NotNil(t, dcontext.GetLogger(ctx))
+}
+
+func TestConfig_Loop(t *testing.T) {
+	c := Config{Interval: time.Hour}
+	c.ApplyDefaults(Config{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// runs happen back to back while there is something to process, but the loop must sleep after a failed run
+	var runs int
+	run := func(context.Context) (bool, error) {
+		runs++
+		if runs == 3 {
+			cancel()
+			return true, errors.New("foo")
+		}
+		return true, nil
+	}
+
+	done := make(chan error)
+	go func() { done <- c.Loop(ctx, run) }()
+
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("loop did not exit after the context was canceled")
+	}
+	require.Equal(t, 3, runs)
+}
@@ -0,0 +1,122 @@
+// Package tracing provides the OpenTelemetry distributed tracing instrumentation of the registry. Spans are recorded
+// with the global tracer provider, so all helpers in this package are no-ops until Init is called.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/version"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName         = "github.com/docker/distribution"
+	defaultServiceName = "container-registry"
+)
+
+// Init configures the global tracer provider to export spans to the OTLP collector described by config, and enables
+// the propagation of W3C Trace Context headers. The returned function flushes all pending spans and closes the
+// connection with the collector. It should be called before the process exits.
+func Init(ctx context.Context, config configuration.Tracing) (func(context.Context) error, error) {
+	var opts []otlp.ExporterOption
+	if config.Endpoint != "" {
+		opts = append(opts, otlp.WithAddress(config.Endpoint))
+	}
+	if config.Insecure {
+		opts = append(opts, otlp.WithInsecure())
+	}
+
+	exp, err := otlp.NewExporter(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	ratio := config.SamplingRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))}),
+		sdktrace.WithResource(sdkresource.NewWithAttributes(
+			semconv.ServiceNameKey.String(serviceName),
+			semconv.ServiceVersionKey.String(version.Version),
+		)),
+		sdktrace.WithBatcher(exp),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return func(ctx context.Context) error {
+		if err := tp.Shutdown(ctx); err != nil {
+			return fmt.Errorf("shutting down tracer provider: %w", err)
+		}
+		if err := exp.Shutdown(ctx); err != nil {
+			return fmt.Errorf("shutting down OTLP exporter: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// StartSpan starts a span with the given name and attributes as a child of the span in ctx, if any. The returned span
+// must be ended with EndSpan.
+func StartSpan(ctx context.Context, name string, attrs ...label.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan ends a span, recording err and flagging the span as failed if err is not nil.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// StartHTTPServerSpan starts a server span for an incoming HTTP request. The trace context propagated by the caller in
+// the request headers, if any, is used as parent. The returned span must be ended with EndHTTPServerSpan.
+func StartHTTPServerSpan(ctx context.Context, r *http.Request) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, r.Header)
+
+	return otel.Tracer(tracerName).Start(ctx, "HTTP "+r.Method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("", "", r)...),
+	)
+}
+
+// SetHTTPRoute names the HTTP server span in ctx after the matched route, which has a much lower cardinality than the
+// request path.
+func SetHTTPRoute(ctx context.Context, method, route string) {
+	span := trace.SpanFromContext(ctx)
+	span.SetName(method + " " + route)
+	span.SetAttributes(semconv.HTTPRouteKey.String(route))
+}
+
+// EndHTTPServerSpan ends an HTTP server span, recording the response status code. A status of zero means that no
+// response status was recorded. Only server errors flag the span as failed, client errors such as a 404 for a missing
+// blob are part of the normal operation of the registry.
+func EndHTTPServerSpan(span trace.Span, status int) {
+	if status != 0 {
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+	}
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
@@ -0,0 +1,92 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution/registry/internal/tracing"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/export/trace/tracetest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+)
+
+// recordSpans installs a global tracer provider that records all ended spans in memory.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exp := tracetest.NewInMemoryExporter()
+	bkpProvider := otel.GetTracerProvider()
+	bkpPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(bkpProvider)
+		otel.SetTextMapPropagator(bkpPropagator)
+	})
+
+	return exp
+}
+
+func TestHTTPServerSpan_PropagatesTraceContext(t *testing.T) {
+	exp := recordSpans(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/v2/foo/bar/manifests/latest", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx, span := tracing.StartHTTPServerSpan(context.Background(), r)
+	tracing.SetHTTPRoute(ctx, r.Method, "manifest")
+	_, child := tracing.StartSpan(ctx, "storage.Stat")
+	tracing.EndSpan(child, errors.New("foo"))
+	tracing.EndHTTPServerSpan(span, http.StatusOK)
+
+	spans := exp.GetSpans()
+	require.Len(t, spans, 2)
+
+	storageSpan, serverSpan := spans[0], spans[1]
+	require.Equal(t, "GET manifest", serverSpan.Name)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", serverSpan.SpanContext.TraceID.String())
+	require.Equal(t, "00f067aa0ba902b7", serverSpan.ParentSpanID.String())
+	require.True(t, serverSpan.HasRemoteParent)
+	require.Contains(t, serverSpan.Attributes, semconv.HTTPRouteKey.String("manifest"))
+	require.Contains(t, serverSpan.Attributes, semconv.HTTPStatusCodeKey.Int(http.StatusOK))
+	require.Equal(t, codes.Unset, serverSpan.StatusCode)
+
+	require.Equal(t, serverSpan.SpanContext.TraceID, storageSpan.SpanContext.TraceID)
+	require.Equal(t, serverSpan.SpanContext.SpanID, storageSpan.ParentSpanID)
+	require.Equal(t, codes.Error, storageSpan.StatusCode)
+	require.Equal(t, "foo", storageSpan.StatusMessage)
+}
+
+func TestHTTPServerSpan_ServerError(t *testing.T) {
+	exp := recordSpans(t)
+
+	r := httptest.NewRequest(http.MethodPut, "/v2/foo/bar/manifests/latest", nil)
+	_, span := tracing.StartHTTPServerSpan(context.Background(), r)
+	tracing.EndHTTPServerSpan(span, http.StatusServiceUnavailable)
+
+	spans := exp.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "HTTP PUT", spans[0].Name)
+	require.False(t, spans[0].ParentSpanID.IsValid())
+	require.Equal(t, codes.Error, spans[0].StatusCode)
+}
+
+func TestHTTPServerSpan_ClientError(t *testing.T) {
+	exp := recordSpans(t)
+
+	r := httptest.NewRequest(http.MethodHead, "/v2/foo/bar/blobs/sha256:123", nil)
+	_, span := tracing.StartHTTPServerSpan(context.Background(), r)
+	tracing.EndHTTPServerSpan(span, http.StatusNotFound)
+
+	spans := exp.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, codes.Unset, spans[0].StatusCode)
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/registry/client/auth"
@@ -19,23 +20,44 @@ type userpass struct {
 
 type credentials struct {
 	creds map[string]userpass
+
+	// refreshToken is the configured refresh token, used for all token servers unless one was obtained through a
+	// credentials exchange.
+	refreshToken string
+
+	mu            sync.RWMutex
+	refreshTokens map[string]string
+}
+
+func refreshTokenKey(u *url.URL, service string) string {
+	return u.String() + " " + service
 }
 
-func (c credentials) Basic(u *url.URL) (string, string) {
+func (c *credentials) Basic(u *url.URL) (string, string) {
 	up := c.creds[u.String()]
 
 	return up.username, up.password
 }
 
-func (c credentials) RefreshToken(u *url.URL, service string) string {
-	return ""
+func (c *credentials) RefreshToken(u *url.URL, service string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if token, ok := c.refreshTokens[refreshTokenKey(u, service)]; ok {
+		return token
+	}
+	return c.refreshToken
 }
 
-func (c credentials) SetRefreshToken(u *url.URL, service, token string) {
+func (c *credentials) SetRefreshToken(u *url.URL, service, token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.refreshTokens[refreshTokenKey(u, service)] = token
 }
 
 // configureAuth stores credentials for challenge responses
-func configureAuth(username, password, remoteURL string) (auth.CredentialStore, error) {
+func configureAuth(username, password, refreshToken, remoteURL string) (auth.CredentialStore, error) {
 	creds := map[string]userpass{}
 
 	authURLs, err := getAuthURLs(remoteURL)
@@ -51,7 +73,11 @@ func configureAuth(username, password, remoteURL string) (auth.CredentialStore,
 		}
 	}
 
-	return credentials{creds: creds}, nil
+	return &credentials{
+		creds:         creds,
+		refreshToken:  refreshToken,
+		refreshTokens: make(map[string]string),
+	}, nil
 }
 
 func getAuthURLs(remoteURL string) ([]string, error) {
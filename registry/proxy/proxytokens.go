@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/registry/client/auth"
+	"github.com/docker/distribution/registry/client/auth/challenge"
+	"github.com/docker/distribution/registry/client/transport"
+)
+
+// tokenCacheIdleTimeout is how long the token handler of a repository is kept after its last use. Upstream bearer
+// tokens are usually short-lived, so idle handlers are unlikely to hold a token that is still valid.
+const tokenCacheIdleTimeout = 30 * time.Minute
+
+type tokenCacheEntry struct {
+	handler  auth.AuthenticationHandler
+	lastUsed time.Time
+}
+
+// tokenCache keeps a token handler per repository, so that the bearer tokens obtained from the upstream token server
+// for a repository scope are reused across requests until they expire, instead of being exchanged on every request.
+type tokenCache struct {
+	newHandler func(repo string) auth.AuthenticationHandler
+	now        func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*tokenCacheEntry
+	lastPrune time.Time
+}
+
+// newTokenCache creates a tokenCache whose token handlers obtain tokens with the credentials in cs. If OAuth2 is
+// enabled in config, the username and password are exchanged for a refresh token, which is then used to obtain the
+// tokens of each repository scope, as is a configured refresh token.
+func newTokenCache(cs auth.CredentialStore, config configuration.Proxy, logger auth.Logger) *tokenCache {
+	return &tokenCache{
+		newHandler: func(repo string) auth.AuthenticationHandler {
+			return auth.NewTokenHandlerWithOptions(auth.TokenHandlerOptions{
+				Transport:     http.DefaultTransport,
+				Credentials:   cs,
+				OfflineAccess: config.OAuth2,
+				ForceOAuth:    config.OAuth2,
+				ClientID:      config.ClientID,
+				Scopes: []auth.Scope{
+					auth.RepositoryScope{
+						Repository: repo,
+						Actions:    []string{"pull"},
+					},
+				},
+				Logger: logger,
+			})
+		},
+		now:     time.Now,
+		entries: make(map[string]*tokenCacheEntry),
+	}
+}
+
+// handler returns the token handler of repo, creating it if needed.
+func (c *tokenCache) handler(repo string) auth.AuthenticationHandler {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if now.Sub(c.lastPrune) >= tokenCacheIdleTimeout {
+		for k, e := range c.entries {
+			if now.Sub(e.lastUsed) >= tokenCacheIdleTimeout {
+				delete(c.entries, k)
+			}
+		}
+		c.lastPrune = now
+	}
+
+	e, ok := c.entries[repo]
+	if !ok {
+		e = &tokenCacheEntry{handler: c.newHandler(repo)}
+		c.entries[repo] = e
+	}
+	e.lastUsed = now
+
+	return e.handler
+}
+
+// invalidate discards the token handler of repo, and with it any cached token, so that a new token is obtained for
+// the next request.
+func (c *tokenCache) invalidate(repo string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, repo)
+}
+
+// transport returns a transport which authenticates requests to the upstream for repo, retrying requests once with a
+// new token if the upstream rejects the cached one.
+func (c *tokenCache) transport(base http.RoundTripper, cm challenge.Manager, repo string) http.RoundTripper {
+	return &unauthorizedRetryTransport{
+		base:       transport.NewTransport(base, auth.NewAuthorizer(cm, &scopedTokenHandler{cache: c, repo: repo})),
+		invalidate: func() { c.invalidate(repo) },
+	}
+}
+
+// scopedTokenHandler authorizes requests with the cached token handler of a repository. The handler is looked up
+// on every request, so that invalidating it takes effect for existing transports.
+type scopedTokenHandler struct {
+	cache *tokenCache
+	repo  string
+}
+
+func (h *scopedTokenHandler) Scheme() string {
+	return "bearer"
+}
+
+func (h *scopedTokenHandler) AuthorizeRequest(req *http.Request, params map[string]string) error {
+	return h.cache.handler(h.repo).AuthorizeRequest(req, params)
+}
+
+// unauthorizedRetryTransport retries a request once if it was sent with a bearer token and rejected with a 401 by the
+// upstream, after invalidating the cached token. This covers tokens revoked or expired ahead of the advertised
+// expiration, which would otherwise be used until then.
+type unauthorizedRetryTransport struct {
+	base       http.RoundTripper
+	invalidate func()
+}
+
+func (t *unauthorizedRetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !sentBearerToken(resp) {
+		return resp, err
+	}
+	if req.Body != nil && req.GetBody == nil {
+		// the body was consumed and can not be sent again
+		return resp, nil
+	}
+
+	t.invalidate()
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	resp.Body.Close()
+
+	return t.base.RoundTrip(retry)
+}
+
+// sentBearerToken reports whether the request that resulted in resp was authorized with a bearer token.
+func sentBearerToken(resp *http.Response) bool {
+	if resp.Request == nil {
+		return false
+	}
+	authz := resp.Request.Header.Get("Authorization")
+	return len(authz) > len("Bearer ") && strings.EqualFold(authz[:len("Bearer ")], "Bearer ")
+}
@@ -0,0 +1,207 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/registry/client/auth/challenge"
+	"github.com/stretchr/testify/require"
+)
+
+// upstreamTokenServer is an upstream registry which is also its own token server. Manifest requests are only
+// accepted with a valid token for the scope of the repository.
+type upstreamTokenServer struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	issued        int
+	grants        []string
+	tokens        map[string]string // token -> scope
+	refreshTokens map[string]bool
+}
+
+func newUpstreamTokenServer(t *testing.T) *upstreamTokenServer {
+	s := &upstreamTokenServer{
+		tokens:        make(map[string]string),
+		refreshTokens: map[string]bool{"configured-refresh-token": true},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", s.serveToken)
+	mux.HandleFunc("/v2/", s.serveRegistry)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *upstreamTokenServer) serveToken(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var grant, scope, refreshToken string
+	switch r.Method {
+	case http.MethodGet:
+		if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		grant, scope = "basic", r.URL.Query().Get("scope")
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		grant, scope = r.PostForm.Get("grant_type"), r.PostForm.Get("scope")
+		switch grant {
+		case "password":
+			if r.PostForm.Get("username") != "user" || r.PostForm.Get("password") != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			refreshToken = "exchanged-refresh-token"
+			s.refreshTokens[refreshToken] = true
+		case "refresh_token":
+			if !s.refreshTokens[r.PostForm.Get("refresh_token")] {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+	}
+
+	s.issued++
+	s.grants = append(s.grants, grant)
+	token := fmt.Sprintf("token-%d", s.issued)
+	s.tokens[token] = scope
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":         token,
+		"access_token":  token,
+		"refresh_token": refreshToken,
+		"expires_in":    300,
+	})
+}
+
+func (s *upstreamTokenServer) serveRegistry(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set(challengeHeader, "registry/2.0")
+
+	var scope string
+	if r.URL.Path != "/v2/" {
+		repo := strings.TrimPrefix(r.URL.Path, "/v2/")
+		repo = repo[:strings.Index(repo, "/manifests/")]
+		scope = fmt.Sprintf("repository:%s:pull", repo)
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if granted, ok := s.tokens[token]; !ok || (scope != "" && granted != scope) {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="upstream"`, s.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// revoke invalidates all issued tokens.
+func (s *upstreamTokenServer) revoke() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = make(map[string]string)
+}
+
+func (s *upstreamTokenServer) issuedGrants() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.grants...)
+}
+
+func newTestTokenCache(t *testing.T, s *upstreamTokenServer, config configuration.Proxy) (*tokenCache, challenge.Manager) {
+	cs, err := configureAuth(config.Username, config.Password, config.RefreshToken, s.URL)
+	require.NoError(t, err)
+
+	cm := challenge.NewSimpleManager()
+	require.NoError(t, ping(cm, s.URL+"/v2/", challengeHeader))
+
+	return newTokenCache(cs, config, nil), cm
+}
+
+func getManifest(t *testing.T, c *tokenCache, cm challenge.Manager, baseURL, repo string) int {
+	client := &http.Client{Transport: c.transport(http.DefaultTransport, cm, repo)}
+
+	resp, err := client.Get(fmt.Sprintf("%s/v2/%s/manifests/latest", baseURL, repo))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func TestTokenCache_ReusesTokensPerScope(t *testing.T) {
+	s := newUpstreamTokenServer(t)
+	c, cm := newTestTokenCache(t, s, configuration.Proxy{Username: "user", Password: "pass"})
+
+	require.Equal(t, http.StatusOK, getManifest(t, c, cm, s.URL, "foo/bar"))
+	require.Equal(t, http.StatusOK, getManifest(t, c, cm, s.URL, "foo/bar"))
+	require.Equal(t, []string{"basic"}, s.issuedGrants())
+
+	require.Equal(t, http.StatusOK, getManifest(t, c, cm, s.URL, "foo/baz"))
+	require.Equal(t, http.StatusOK, getManifest(t, c, cm, s.URL, "foo/bar"))
+	require.Equal(t, []string{"basic", "basic"}, s.issuedGrants())
+}
+
+func TestTokenCache_RefreshesOnUnauthorized(t *testing.T) {
+	s := newUpstreamTokenServer(t)
+	c, cm := newTestTokenCache(t, s, configuration.Proxy{Username: "user", Password: "pass"})
+
+	require.Equal(t, http.StatusOK, getManifest(t, c, cm, s.URL, "foo/bar"))
+
+	s.revoke()
+	require.Equal(t, http.StatusOK, getManifest(t, c, cm, s.URL, "foo/bar"))
+	require.Equal(t, []string{"basic", "basic"}, s.issuedGrants())
+}
+
+func TestTokenCache_OAuth2Exchange(t *testing.T) {
+	s := newUpstreamTokenServer(t)
+	c, cm := newTestTokenCache(t, s, configuration.Proxy{Username: "user", Password: "pass", OAuth2: true})
+
+	require.Equal(t, http.StatusOK, getManifest(t, c, cm, s.URL, "foo/bar"))
+	require.Equal(t, http.StatusOK, getManifest(t, c, cm, s.URL, "foo/baz"))
+	require.Equal(t, []string{"password", "refresh_token"}, s.issuedGrants())
+}
+
+func TestTokenCache_RefreshToken(t *testing.T) {
+	s := newUpstreamTokenServer(t)
+	c, cm := newTestTokenCache(t, s, configuration.Proxy{RefreshToken: "configured-refresh-token"})
+
+	require.Equal(t, http.StatusOK, getManifest(t, c, cm, s.URL, "foo/bar"))
+	require.Equal(t, http.StatusOK, getManifest(t, c, cm, s.URL, "foo/baz"))
+	require.Equal(t, []string{"refresh_token", "refresh_token"}, s.issuedGrants())
+}
+
+func TestTokenCache_PrunesIdleHandlers(t *testing.T) {
+	s := newUpstreamTokenServer(t)
+	c, _ := newTestTokenCache(t, s, configuration.Proxy{})
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	h := c.handler("foo/bar")
+	c.handler("foo/baz")
+	require.Same(t, h, c.handler("foo/bar"))
+
+	now = now.Add(tokenCacheIdleTimeout)
+	c.handler("foo/bar")
+	require.Len(t, c.entries, 1)
+	require.Contains(t, c.entries, "foo/bar")
+}
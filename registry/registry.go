@@ -3,20 +3,25 @@ package registry
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
+	"errors"
+	"expvar"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"runtime"
+	"runtime/debug"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/docker/distribution/configuration"
 	dcontext "github.com/docker/distribution/context"
 	"github.com/docker/distribution/health"
 	"github.com/docker/distribution/registry/datastore"
 	"github.com/docker/distribution/registry/handlers"
+	"github.com/docker/distribution/registry/internal/tracing"
 	"github.com/docker/distribution/registry/listener"
 	"github.com/docker/distribution/uuid"
 	"github.com/docker/distribution/version"
@@ -29,6 +34,7 @@ import (
 	"gitlab.com/gitlab-org/labkit/monitoring"
 	"golang.org/x/crypto/acme"
 	"golang.org/x/crypto/acme/autocert"
+	"google.golang.org/grpc"
 )
 
 var tlsLookup = map[string]uint16{
@@ -37,6 +43,23 @@ var tlsLookup = map[string]uint16{
 	"tls1.3": tls.VersionTLS13,
 }
 
+// defaultCipherSuites are the cipher suites allowed for TLS 1.2 connections when http.tls.ciphersuites is not set.
+var defaultCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+}
+
+var curveLookup = map[string]tls.CurveID{
+	"x25519": tls.X25519,
+	"p256":   tls.CurveP256,
+	"p384":   tls.CurveP384,
+	"p521":   tls.CurveP521,
+}
+
 // ServeCmd is a cobra command for running the registry.
 var ServeCmd = &cobra.Command{
 	Use:   "serve <config>",
@@ -58,9 +81,16 @@ var ServeCmd = &cobra.Command{
 		if err != nil {
 			log.Fatalln(err)
 		}
+		registry.reloadConfig = func() (*configuration.Configuration, error) {
+			return resolveConfiguration(args)
+		}
 
 		go func() {
-			opts := configureMonitoring(config)
+			opts, err := configureMonitoring(config)
+			if err != nil {
+				log.WithError(err).Error("unable to configure monitoring service")
+				return
+			}
 			if err := monitoring.Start(opts...); err != nil {
 				log.WithError(err).Error("unable to start monitoring service")
 			}
@@ -78,6 +108,12 @@ type Registry struct {
 	config *configuration.Configuration
 	app    *handlers.App
 	server *http.Server
+	// shutdownTracing flushes pending tracing spans, if tracing is enabled
+	shutdownTracing func(context.Context) error
+	// reloadConfig reads the configuration again, to be applied on SIGHUP. Nil if reloading is not supported.
+	reloadConfig func() (*configuration.Configuration, error)
+	// adminServer serves the gRPC admin API. Nil if disabled.
+	adminServer *grpc.Server
 }
 
 // NewRegistry creates a new registry from a context and configuration struct.
@@ -92,6 +128,15 @@ func NewRegistry(ctx context.Context, config *configuration.Configuration) (*Reg
 	// with uuid generation under low entropy.
 	uuid.Loggerf = dcontext.GetLogger(ctx).Warnf
 
+	if err := configureRuntime(ctx, config); err != nil {
+		return nil, fmt.Errorf("configuring runtime: %w", err)
+	}
+
+	shutdownTracing, err := configureTracing(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("configuring tracing: %w", err)
+	}
+
 	app := handlers.NewApp(ctx, config)
 	// TODO(aaronl): The global scope of the health checks means NewRegistry
 	// can only be called once per process.
@@ -108,13 +153,23 @@ func NewRegistry(ctx context.Context, config *configuration.Configuration) (*Reg
 	handler = correlation.InjectCorrelationID(handler, correlation.WithPropagation())
 
 	server := &http.Server{
-		Handler: handler,
+		Handler:     handler,
+		ConnContext: handlers.WithConn,
+	}
+
+	var adminServer *grpc.Server
+	if config.Admin.GRPC.Addr != "" {
+		if adminServer, err = newAdminServer(ctx, config, app); err != nil {
+			return nil, fmt.Errorf("configuring admin gRPC server: %w", err)
+		}
 	}
 
 	return &Registry{
-		app:    app,
-		config: config,
-		server: server,
+		app:             app,
+		config:          config,
+		server:          server,
+		shutdownTracing: shutdownTracing,
+		adminServer:     adminServer,
 	}, nil
 }
 
@@ -122,6 +177,10 @@ func NewRegistry(ctx context.Context, config *configuration.Configuration) (*Reg
 // It is global to ease unit testing
 var quit = make(chan os.Signal, 1)
 
+// Channel to capture signals used to reload the configuration of the registry.
+// It is global to ease unit testing
+var reload = make(chan os.Signal, 1)
+
 // ListenAndServe runs the registry's HTTP server.
 func (registry *Registry) ListenAndServe() error {
 	config := registry.config
@@ -141,19 +200,22 @@ func (registry *Registry) ListenAndServe() error {
 			dcontext.GetLogger(registry.app).Infof("restricting TLS to %s or higher", config.HTTP.TLS.MinimumTLS)
 		}
 
+		cipherSuites, err := tlsCipherSuites(config.HTTP.TLS.CipherSuites)
+		if err != nil {
+			return fmt.Errorf("invalid http.tls.ciphersuites: %w", err)
+		}
+		curves, err := tlsCurvePreferences(config.HTTP.TLS.CurvePreferences)
+		if err != nil {
+			return fmt.Errorf("invalid http.tls.curvepreferences: %w", err)
+		}
+
 		tlsConf := &tls.Config{
 			ClientAuth:               tls.NoClientCert,
 			NextProtos:               nextProtos(config),
 			MinVersion:               tlsMinVersion,
 			PreferServerCipherSuites: true,
-			CipherSuites: []uint16{
-				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-				tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
-				tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
-				tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
-				tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-			},
+			CipherSuites:             cipherSuites,
+			CurvePreferences:         curves,
 		}
 
 		if config.HTTP.TLS.LetsEncrypt.CacheFile != "" {
@@ -177,17 +239,9 @@ func (registry *Registry) ListenAndServe() error {
 		}
 
 		if len(config.HTTP.TLS.ClientCAs) != 0 {
-			pool := x509.NewCertPool()
-
-			for _, ca := range config.HTTP.TLS.ClientCAs {
-				caPem, err := ioutil.ReadFile(ca)
-				if err != nil {
-					return err
-				}
-
-				if ok := pool.AppendCertsFromPEM(caPem); !ok {
-					return fmt.Errorf("could not add CA to pool")
-				}
+			pool, err := loadCertPool(config.HTTP.TLS.ClientCAs)
+			if err != nil {
+				return err
 			}
 
 			for _, subj := range pool.Subjects() {
@@ -206,54 +260,173 @@ func (registry *Registry) ListenAndServe() error {
 
 	// Setup channel to get notified on SIGTERM and interrupt signals.
 	signal.Notify(quit, syscall.SIGTERM, os.Interrupt)
+	if registry.reloadConfig != nil {
+		signal.Notify(reload, syscall.SIGHUP)
+	}
 	serveErr := make(chan error)
 
+	if registry.adminServer != nil {
+		adminLn, err := listener.NewListener("tcp", config.Admin.GRPC.Addr)
+		if err != nil {
+			return fmt.Errorf("listening for admin gRPC API: %w", err)
+		}
+		dcontext.GetLogger(registry.app).WithField("address", adminLn.Addr().String()).Info("serving admin gRPC API")
+
+		go func() {
+			serveErr <- registry.adminServer.Serve(adminLn)
+		}()
+	}
+
 	// Start serving in goroutine and listen for stop signal in main thread
 	go func() {
 		serveErr <- registry.server.Serve(ln)
 	}()
 
-	select {
-	case err := <-serveErr:
-		return err
-	case s := <-quit:
-		log := log.WithFields(log.Fields{
-			"quit_signal":            s.String(),
-			"http_drain_timeout":     registry.config.HTTP.DrainTimeout,
-			"database_drain_timeout": registry.config.Database.DrainTimeout,
-		})
-		log.Info("attempting to stop server gracefully...")
-
-		// shutdown the server with a grace period of configured timeout
-		if registry.config.HTTP.DrainTimeout != 0 {
-			log.Info("draining http connections")
-			ctx, cancel := context.WithTimeout(context.Background(), registry.config.HTTP.DrainTimeout)
-			defer cancel()
-			if err := registry.server.Shutdown(ctx); err != nil {
-				return err
-			}
+	for {
+		select {
+		case err := <-serveErr:
+			return err
+		case <-reload:
+			registry.handleReload()
+		case s := <-quit:
+			return registry.shutdown(s)
 		}
+	}
+}
 
-		if registry.config.Database.Enabled {
-			log.Info("closing database connections")
+// handleReload reads the configuration again and applies the settings that can be changed without a restart. Errors
+// are logged, the registry keeps running with the previous settings.
+func (registry *Registry) handleReload() {
+	log.Info("reloading configuration")
 
-			ctx := context.Background()
-			var cancel context.CancelFunc
+	if registry.reloadConfig == nil {
+		log.Warn("configuration reloading is not supported, ignoring")
+		return
+	}
+	config, err := registry.reloadConfig()
+	if err != nil {
+		log.WithError(err).Error("error reading configuration, keeping current settings")
+		return
+	}
+	if err := registry.Reload(config); err != nil {
+		log.WithError(err).Error("error reloading configuration")
+		return
+	}
 
-			// Drain database with grace period, rather than waiting indefinitely.
-			if registry.config.Database.DrainTimeout != 0 {
-				ctx, cancel = context.WithTimeout(ctx, registry.config.Database.DrainTimeout)
-				defer cancel()
-			}
+	log.Info("configuration reloaded")
+}
 
-			if err := registry.app.GracefulShutdown(ctx); err != nil {
-				return err
-			}
+// Reload applies the settings of config that can be changed without a restart: the log level, the notification
+// endpoints and the parameters of the access controller. All other settings are ignored.
+func (registry *Registry) Reload(config *configuration.Configuration) error {
+	level, err := log.ParseLevel(config.Log.Level.String())
+	if err != nil {
+		return fmt.Errorf("parsing log level: %w", err)
+	}
+	log.SetLevel(level)
+	log.WithField("level", level).Info("reloaded log level")
+
+	return registry.app.Reload(config)
+}
+
+// shutdown stops the registry gracefully after receiving the quit signal s.
+func (registry *Registry) shutdown(s os.Signal) error {
+	log := log.WithFields(log.Fields{
+		"quit_signal":            s.String(),
+		"http_drain_timeout":     registry.config.HTTP.DrainTimeout,
+		"database_drain_timeout": registry.config.Database.DrainTimeout,
+	})
+	log.Info("attempting to stop server gracefully...")
+
+	// shutdown the server with a grace period of configured timeout
+	if registry.config.HTTP.DrainTimeout != 0 {
+		log.Info("draining http connections")
+		ctx, cancel := context.WithTimeout(context.Background(), registry.config.HTTP.DrainTimeout)
+		defer cancel()
+		if err := registry.server.Shutdown(ctx); err != nil {
+			return err
 		}
+	}
 
-		log.Info("graceful shutdown successful")
-		return nil
+	if registry.adminServer != nil {
+		log.Info("stopping admin gRPC server")
+		registry.stopAdminServer()
 	}
+
+	if registry.config.Database.Enabled {
+		log.Info("closing database connections")
+
+		ctx := context.Background()
+		var cancel context.CancelFunc
+
+		// Drain database with grace period, rather than waiting indefinitely.
+		if registry.config.Database.DrainTimeout != 0 {
+			ctx, cancel = context.WithTimeout(ctx, registry.config.Database.DrainTimeout)
+			defer cancel()
+		}
+
+		if err := registry.app.GracefulShutdown(ctx); err != nil {
+			return err
+		}
+	}
+
+	if registry.config.Audit.Enabled {
+		log.Info("flushing audit log")
+		if err := registry.app.CloseAuditLog(); err != nil {
+			return err
+		}
+	}
+
+	if registry.shutdownTracing != nil {
+		log.Info("flushing tracing spans")
+		// don't hold the shutdown for long if the collector is unreachable
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := registry.shutdownTracing(ctx); err != nil {
+			return err
+		}
+	}
+
+	log.Info("graceful shutdown successful")
+	return nil
+}
+
+// stopAdminServer stops the admin gRPC server gracefully, waiting for pending calls to complete for up to the HTTP
+// drain timeout, if any, after which they are canceled.
+func (registry *Registry) stopAdminServer() {
+	d := registry.config.HTTP.DrainTimeout
+	if d == 0 {
+		registry.adminServer.Stop()
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		registry.adminServer.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(d):
+		registry.adminServer.Stop()
+	}
+}
+
+// configureTracing enables the OpenTelemetry instrumentation if configured to do so. The returned function, if not nil,
+// flushes pending spans and must be called on shutdown.
+func configureTracing(ctx context.Context, config *configuration.Configuration) (func(context.Context) error, error) {
+	if !config.Tracing.Enabled {
+		return nil, nil
+	}
+
+	shutdown, err := tracing.Init(ctx, config.Tracing)
+	if err != nil {
+		return nil, err
+	}
+	log.WithField("endpoint", config.Tracing.Endpoint).Info("exporting tracing spans")
+
+	return shutdown, nil
 }
 
 func configureReporting(config *configuration.Configuration, h http.Handler) (http.Handler, error) {
@@ -300,6 +473,26 @@ func configureLogging(ctx context.Context, config *configuration.Configuration)
 	return ctx, nil
 }
 
+// configureRuntime applies the Go runtime settings of the configuration, if any.
+func configureRuntime(ctx context.Context, config *configuration.Configuration) error {
+	cfg := config.Runtime
+	if cfg.MaxProcs < 0 {
+		return fmt.Errorf("invalid runtime maxprocs %d, must be positive", cfg.MaxProcs)
+	}
+
+	logger := dcontext.GetLogger(ctx)
+	if cfg.MaxProcs > 0 {
+		previous := runtime.GOMAXPROCS(cfg.MaxProcs)
+		logger.WithFields(log.Fields{"previous": previous, "maxprocs": cfg.MaxProcs}).Info("runtime maxprocs set")
+	}
+	if cfg.GCPercent != 0 {
+		previous := debug.SetGCPercent(cfg.GCPercent)
+		logger.WithFields(log.Fields{"previous": previous, "gc_percent": cfg.GCPercent}).Info("runtime gc percent set")
+	}
+
+	return nil
+}
+
 func configureAccessLogging(config *configuration.Configuration, h http.Handler) (http.Handler, error) {
 	if config.Log.AccessLog.Disabled {
 		return h, nil
@@ -319,7 +512,7 @@ func configureAccessLogging(config *configuration.Configuration, h http.Handler)
 	return logkit.AccessLogger(h, logkit.WithAccessLogger(logger)), nil
 }
 
-func configureMonitoring(config *configuration.Configuration) []monitoring.Option {
+func configureMonitoring(config *configuration.Configuration) ([]monitoring.Option, error) {
 	var opts []monitoring.Option
 	addr := config.HTTP.Debug.Addr
 
@@ -327,10 +520,26 @@ func configureMonitoring(config *configuration.Configuration) []monitoring.Optio
 		mux := http.NewServeMux()
 		mux.HandleFunc("/debug/health", health.StatusHandler)
 		log.WithFields(log.Fields{"address": addr, "path": "/debug/health"}).Info("starting health checker")
+		mux.HandleFunc("/healthz", health.LivenessHandler)
+		mux.HandleFunc("/readyz", health.ReadinessHandler)
+		log.WithFields(log.Fields{"address": addr, "liveness_path": "/healthz", "readiness_path": "/readyz"}).Info("starting liveness and readiness probes")
 
 		opts = []monitoring.Option{
 			monitoring.WithServeMux(mux),
-			monitoring.WithListenerAddress(addr),
+		}
+
+		if config.HTTP.Debug.Auth.ClientCertificate && (config.HTTP.Debug.TLS.Certificate == "" || len(config.HTTP.Debug.TLS.ClientCAs) == 0) {
+			return nil, errors.New("debug server client certificate authentication requires a TLS certificate and client CAs")
+		}
+		if config.HTTP.Debug.TLS.Certificate != "" {
+			ln, err := newDebugTLSListener(config)
+			if err != nil {
+				return nil, fmt.Errorf("configuring debug server TLS: %w", err)
+			}
+			opts = append(opts, monitoring.WithListener(ln))
+			log.WithField("address", addr).Info("debug server using tls")
+		} else {
+			opts = append(opts, monitoring.WithListenerAddress(addr))
 		}
 
 		if config.HTTP.Debug.Prometheus.Enabled {
@@ -345,10 +554,16 @@ func configureMonitoring(config *configuration.Configuration) []monitoring.Optio
 			opts = append(opts, monitoring.WithoutMetrics())
 		}
 
+		// pprof handlers are registered here rather than by LabKit, so that they are subject to authentication
+		opts = append(opts, monitoring.WithoutPprof())
+		auth := newDebugAuthenticator(config.HTTP.Debug.Auth)
 		if config.HTTP.Debug.Pprof.Enabled {
+			registerPprofHandlers(mux, auth)
 			log.WithFields(log.Fields{"address": addr, "path": "/debug/pprof/"}).Info("starting pprof listener")
-		} else {
-			opts = append(opts, monitoring.WithoutPprof())
+		}
+		if config.HTTP.Debug.Expvar.Enabled {
+			mux.Handle("/debug/vars", auth.wrap(expvar.Handler()))
+			log.WithFields(log.Fields{"address": addr, "path": "/debug/vars"}).Info("starting expvar listener")
 		}
 	} else {
 		opts = []monitoring.Option{
@@ -361,14 +576,14 @@ func configureMonitoring(config *configuration.Configuration) []monitoring.Optio
 		opts = append(opts, monitoring.WithProfilerCredentialsFile(config.Profiling.Stackdriver.KeyFile))
 		if err := configureStackdriver(config); err != nil {
 			log.WithError(err).Error("failed to configure Stackdriver profiler")
-			return opts
+			return opts, nil
 		}
 		log.Info("starting Stackdriver profiler")
 	} else {
 		opts = append(opts, monitoring.WithoutContinuousProfiling())
 	}
 
-	return opts
+	return opts, nil
 }
 
 func configureStackdriver(config *configuration.Configuration) error {
@@ -519,6 +734,49 @@ func validate(config *configuration.Configuration) error {
 	return errs.ErrorOrNil()
 }
 
+// tlsCipherSuites resolves the IDs of the named cipher suites, or returns defaultCipherSuites if names is empty. Only
+// cipher suites without known security issues are accepted.
+func tlsCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return defaultCipherSuites, nil
+	}
+
+	supported := make(map[string]uint16)
+	for _, cs := range tls.CipherSuites() {
+		supported[cs.Name] = cs.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := supported[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// tlsCurvePreferences resolves the IDs of the named elliptic curves, in the same order. A nil slice is returned if
+// names is empty, in which case the Go defaults apply.
+func tlsCurvePreferences(names []string) ([]tls.CurveID, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]tls.CurveID, 0, len(names))
+	for _, name := range names {
+		id, ok := curveLookup[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown curve %q", name)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
 func nextProtos(config *configuration.Configuration) []string {
 	switch config.HTTP.HTTP2.Disabled {
 	case true:
@@ -547,5 +805,10 @@ func dbFromConfig(config *configuration.Configuration) (*datastore.DB, error) {
 			MaxOpen:     config.Database.Pool.MaxOpen,
 			MaxLifetime: config.Database.Pool.MaxLifetime,
 		}),
+		datastore.WithRetryConfig(&datastore.RetryConfig{
+			MaxRetries:      config.Database.Retry.MaxRetries,
+			InitialInterval: config.Database.Retry.InitialInterval,
+			MaxInterval:     config.Database.Retry.MaxInterval,
+		}),
 	)
 }
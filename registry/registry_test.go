@@ -3,6 +3,7 @@ package registry
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -10,6 +11,8 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"runtime"
+	"runtime/debug"
 	"syscall"
 	"testing"
 	"time"
@@ -17,6 +20,7 @@ import (
 	"github.com/docker/distribution/configuration"
 	_ "github.com/docker/distribution/registry/storage/driver/inmemory"
 	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 	"gitlab.com/gitlab-org/labkit/monitoring"
 )
@@ -43,6 +47,35 @@ func TestNextProtos(t *testing.T) {
 	}
 }
 
+func TestTLSCipherSuites(t *testing.T) {
+	ids, err := tlsCipherSuites(nil)
+	require.NoError(t, err)
+	require.Equal(t, defaultCipherSuites, ids)
+
+	ids, err = tlsCipherSuites([]string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384", "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"})
+	require.NoError(t, err)
+	require.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256}, ids)
+
+	_, err = tlsCipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA"})
+	require.EqualError(t, err, `unknown or insecure cipher suite "TLS_RSA_WITH_RC4_128_SHA"`)
+
+	_, err = tlsCipherSuites([]string{"foo"})
+	require.Error(t, err)
+}
+
+func TestTLSCurvePreferences(t *testing.T) {
+	ids, err := tlsCurvePreferences(nil)
+	require.NoError(t, err)
+	require.Nil(t, ids)
+
+	ids, err = tlsCurvePreferences([]string{"P384", "x25519"})
+	require.NoError(t, err)
+	require.Equal(t, []tls.CurveID{tls.CurveP384, tls.X25519}, ids)
+
+	_, err = tlsCurvePreferences([]string{"p224"})
+	require.EqualError(t, err, `unknown curve "p224"`)
+}
+
 func setupRegistry() (*Registry, error) {
 	config := &configuration.Configuration{}
 	configuration.ApplyDefaults(config)
@@ -182,6 +215,55 @@ func requireEnvSet(t *testing.T, name, value string) {
 	require.Equal(t, value, os.Getenv(name))
 }
 
+func TestRegistry_Reload(t *testing.T) {
+	registry, err := setupRegistry()
+	require.NoError(t, err)
+
+	level := log.GetLevel()
+	defer log.SetLevel(level)
+
+	config := &configuration.Configuration{}
+	configuration.ApplyDefaults(config)
+	config.Log.Level = "debug"
+	require.NoError(t, registry.Reload(config))
+	require.Equal(t, log.DebugLevel, log.GetLevel())
+
+	config.Log.Level = "foo"
+	require.Error(t, registry.Reload(config))
+	require.Equal(t, log.DebugLevel, log.GetLevel())
+}
+
+func TestRegistry_HandleReload(t *testing.T) {
+	registry, err := setupRegistry()
+	require.NoError(t, err)
+
+	level := log.GetLevel()
+	defer log.SetLevel(level)
+	log.SetLevel(log.InfoLevel)
+
+	// errors reading the configuration leave the current settings untouched
+	registry.reloadConfig = func() (*configuration.Configuration, error) {
+		return nil, fmt.Errorf("foo")
+	}
+	registry.handleReload()
+	require.Equal(t, log.InfoLevel, log.GetLevel())
+
+	registry.reloadConfig = func() (*configuration.Configuration, error) {
+		config := &configuration.Configuration{}
+		configuration.ApplyDefaults(config)
+		config.Log.Level = "warn"
+		return config, nil
+	}
+	registry.handleReload()
+	require.Equal(t, log.WarnLevel, log.GetLevel())
+}
+
+func TestConfigureTracing_Disabled(t *testing.T) {
+	shutdown, err := configureTracing(context.Background(), &configuration.Configuration{})
+	require.NoError(t, err)
+	require.Nil(t, shutdown)
+}
+
 func TestConfigureStackDriver_Disabled(t *testing.T) {
 	config := &configuration.Configuration{}
 
@@ -290,14 +372,17 @@ func TestConfigureMonitoring_HealthHandler(t *testing.T) {
 	config.HTTP.Debug.Addr = addr
 
 	go func() {
-		opts := configureMonitoring(config)
-		err := monitoring.Start(opts...)
+		opts, err := configureMonitoring(config)
+		require.NoError(t, err)
+		err = monitoring.Start(opts...)
 		require.NoError(t, err)
 	}()
 	// give the monitoring service some time to start
 	time.Sleep(5 * time.Millisecond)
 
 	assertMonitoringResponse(t, addr, "/debug/health", http.StatusOK)
+	assertMonitoringResponse(t, addr, "/healthz", http.StatusOK)
+	assertMonitoringResponse(t, addr, "/readyz", http.StatusOK)
 	assertMonitoringResponse(t, addr, "/debug/pprof", http.StatusNotFound)
 	assertMonitoringResponse(t, addr, "/metrics", http.StatusNotFound)
 }
@@ -310,8 +395,9 @@ func TestConfigureMonitoring_PprofHandler(t *testing.T) {
 	config.HTTP.Debug.Pprof.Enabled = true
 
 	go func() {
-		opts := configureMonitoring(config)
-		err := monitoring.Start(opts...)
+		opts, err := configureMonitoring(config)
+		require.NoError(t, err)
+		err = monitoring.Start(opts...)
 		require.NoError(t, err)
 	}()
 	time.Sleep(5 * time.Millisecond)
@@ -330,11 +416,12 @@ func TestConfigureMonitoring_MetricsHandler(t *testing.T) {
 	config.HTTP.Debug.Prometheus.Path = "/metrics"
 
 	go func() {
-		opts := configureMonitoring(config)
+		opts, err := configureMonitoring(config)
+		require.NoError(t, err)
 		// Use local Prometheus registry for each test, otherwise different tests may attempt to register the same
 		// metrics in the default Prometheus registry, causing a panic.
 		opts = append(opts, monitoring.WithPrometheusRegisterer(prometheus.NewRegistry()))
-		err := monitoring.Start(opts...)
+		err = monitoring.Start(opts...)
 		require.NoError(t, err)
 	}()
 	time.Sleep(5 * time.Millisecond)
@@ -354,9 +441,10 @@ func TestConfigureMonitoring_All(t *testing.T) {
 	config.HTTP.Debug.Prometheus.Path = "/metrics"
 
 	go func() {
-		opts := configureMonitoring(config)
+		opts, err := configureMonitoring(config)
+		require.NoError(t, err)
 		opts = append(opts, monitoring.WithPrometheusRegisterer(prometheus.NewRegistry()))
-		err := monitoring.Start(opts...)
+		err = monitoring.Start(opts...)
 		require.NoError(t, err)
 	}()
 	time.Sleep(5 * time.Millisecond)
@@ -365,3 +453,188 @@ func TestConfigureMonitoring_All(t *testing.T) {
 	assertMonitoringResponse(t, addr, "/debug/pprof", http.StatusOK)
 	assertMonitoringResponse(t, addr, "/metrics", http.StatusOK)
 }
+
+func TestConfigureMonitoring_ExpvarHandler(t *testing.T) {
+	addr := freeLnAddr(t).String()
+
+	config := &configuration.Configuration{}
+	config.HTTP.Debug.Addr = addr
+	config.HTTP.Debug.Expvar.Enabled = true
+
+	go func() {
+		opts, err := configureMonitoring(config)
+		require.NoError(t, err)
+		err = monitoring.Start(opts...)
+		require.NoError(t, err)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	assertMonitoringResponse(t, addr, "/debug/health", http.StatusOK)
+	assertMonitoringResponse(t, addr, "/debug/vars", http.StatusOK)
+	assertMonitoringResponse(t, addr, "/debug/pprof", http.StatusNotFound)
+}
+
+func TestConfigureMonitoring_Auth(t *testing.T) {
+	addr := freeLnAddr(t).String()
+
+	config := &configuration.Configuration{}
+	config.HTTP.Debug.Addr = addr
+	config.HTTP.Debug.Pprof.Enabled = true
+	config.HTTP.Debug.Expvar.Enabled = true
+	config.HTTP.Debug.Auth.Username = "admin"
+	config.HTTP.Debug.Auth.Password = "secret"
+
+	go func() {
+		opts, err := configureMonitoring(config)
+		require.NoError(t, err)
+		err = monitoring.Start(opts...)
+		require.NoError(t, err)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	// health checks are not authenticated
+	assertMonitoringResponse(t, addr, "/debug/health", http.StatusOK)
+	assertMonitoringResponse(t, addr, "/debug/pprof/", http.StatusUnauthorized)
+	assertMonitoringResponse(t, addr, "/debug/vars", http.StatusUnauthorized)
+
+	for _, path := range []string{"/debug/pprof/", "/debug/vars"} {
+		u := url.URL{Scheme: "http", Host: addr, Path: path}
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("admin", "secret")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestConfigureMonitoring_ClientCertificateWithoutTLS(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.HTTP.Debug.Addr = freeLnAddr(t).String()
+	config.HTTP.Debug.Pprof.Enabled = true
+	config.HTTP.Debug.Auth.ClientCertificate = true
+
+	_, err := configureMonitoring(config)
+	require.Error(t, err)
+}
+
+func TestConfigureRuntime(t *testing.T) {
+	ctx := context.Background()
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+	defer debug.SetGCPercent(debug.SetGCPercent(100))
+
+	config := &configuration.Configuration{}
+	config.Runtime.MaxProcs = 1
+	config.Runtime.GCPercent = 50
+	require.NoError(t, configureRuntime(ctx, config))
+	require.Equal(t, 1, runtime.GOMAXPROCS(0))
+	require.Equal(t, 50, debug.SetGCPercent(50))
+
+	config.Runtime.MaxProcs = -1
+	require.Error(t, configureRuntime(ctx, config))
+}
+
+func TestMaintenanceStorageParameters_NoWalkConfig(t *testing.T) {
+	config := &configuration.Configuration{
+		Storage: configuration.Storage{
+			"s3": configuration.Parameters{"bucket": "foo", "parallelwalk": true},
+		},
+	}
+
+	params, err := maintenanceStorageParameters(config)
+	require.NoError(t, err)
+	require.Equal(t, configuration.Parameters{"bucket": "foo", "parallelwalk": true}, params)
+}
+
+func TestMaintenanceStorageParameters_WalkConfig(t *testing.T) {
+	config := &configuration.Configuration{
+		Storage: configuration.Storage{
+			"gcs": configuration.Parameters{"bucket": "foo"},
+			"maintenance": configuration.Parameters{
+				"walk": map[interface{}]interface{}{
+					"parallel":       true,
+					"maxconcurrency": 200,
+				},
+			},
+		},
+	}
+
+	params, err := maintenanceStorageParameters(config)
+	require.NoError(t, err)
+	require.Equal(t, configuration.Parameters{
+		"bucket":             "foo",
+		"parallelwalk":       true,
+		"maxwalkconcurrency": 200,
+	}, params)
+
+	// the API serving parameters must remain untouched
+	require.Equal(t, configuration.Parameters{"bucket": "foo"}, config.Storage.Parameters())
+}
+
+func TestMaintenanceStorageParameters_WalkConfigDisablesParallelWalk(t *testing.T) {
+	config := &configuration.Configuration{
+		Storage: configuration.Storage{
+			"s3": configuration.Parameters{"bucket": "foo", "parallelwalk": true},
+			"maintenance": configuration.Parameters{
+				"walk": map[interface{}]interface{}{"parallel": false},
+			},
+		},
+	}
+
+	params, err := maintenanceStorageParameters(config)
+	require.NoError(t, err)
+	require.Equal(t, false, params["parallelwalk"])
+}
+
+func TestMaintenanceStorageParameters_InvalidWalkConfig(t *testing.T) {
+	tt := map[string]interface{}{
+		"not a map":               "foo",
+		"invalid parallel":        map[interface{}]interface{}{"parallel": "yes"},
+		"invalid maxconcurrency":  map[interface{}]interface{}{"maxconcurrency": "10"},
+		"negative maxconcurrency": map[interface{}]interface{}{"maxconcurrency": -1},
+	}
+
+	for name, walk := range tt {
+		t.Run(name, func(t *testing.T) {
+			config := &configuration.Configuration{
+				Storage: configuration.Storage{
+					"s3":          configuration.Parameters{"bucket": "foo"},
+					"maintenance": configuration.Parameters{"walk": walk},
+				},
+			}
+
+			_, err := maintenanceStorageParameters(config)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestMaintenanceStorageParameters_MaxConcurrencyUnsupported(t *testing.T) {
+	config := &configuration.Configuration{
+		Storage: configuration.Storage{
+			"azure": configuration.Parameters{"container": "foo"},
+			"maintenance": configuration.Parameters{
+				"walk": map[interface{}]interface{}{"maxconcurrency": 10},
+			},
+		},
+	}
+
+	_, err := maintenanceStorageParameters(config)
+	require.EqualError(t, err, "walk's maxconcurrency config key is not supported by the azure storage driver")
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1024:            "1.0 KiB",
+		1536:            "1.5 KiB",
+		10 << 20:        "10.0 MiB",
+		3 << 30:         "3.0 GiB",
+		5<<40 + 512<<30: "5.5 TiB",
+	}
+	for n, want := range tests {
+		require.Equal(t, want, formatBytes(n), n)
+	}
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/docker/distribution/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	replicationDurationHist *prometheus.HistogramVec
+	replicationCounter      *prometheus.CounterVec
+	replicatedBytesCounter  prometheus.Counter
+	postponeCounter         *prometheus.CounterVec
+	lagHist                 *prometheus.HistogramVec
+	queueSizeGauge          prometheus.Gauge
+
+	timeSince = time.Since // for test purposes only
+)
+
+const (
+	subsystem = "replication"
+
+	artifactLabel = "artifact"
+	errorLabel    = "error"
+
+	replicationDurationName = "duration_seconds"
+	replicationDurationDesc = "A histogram of latencies for the replication of artifacts to the secondary registry."
+	replicationTotalName    = "replications_total"
+	replicationTotalDesc    = "A counter of replication attempts of artifacts to the secondary registry."
+
+	replicatedBytesTotalName = "replicated_bytes_total"
+	replicatedBytesTotalDesc = "A counter for blob bytes pushed to the secondary registry."
+
+	postponeTotalName = "postpones_total"
+	postponeTotalDesc = "A counter for replication task postpones."
+
+	lagName = "lag_seconds"
+	lagDesc = "A histogram of the time elapsed between the push of an artifact and its replication."
+
+	queueSizeName = "queue_size"
+	queueSizeDesc = "The size of the replication queue."
+)
+
+func init() {
+	replicationDurationHist = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      replicationDurationName,
+			Help:      replicationDurationDesc,
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{artifactLabel, errorLabel},
+	)
+
+	replicationCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      replicationTotalName,
+			Help:      replicationTotalDesc,
+		},
+		[]string{artifactLabel, errorLabel},
+	)
+
+	replicatedBytesCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      replicatedBytesTotalName,
+			Help:      replicatedBytesTotalDesc,
+		},
+	)
+
+	postponeCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      postponeTotalName,
+			Help:      postponeTotalDesc,
+		},
+		[]string{artifactLabel},
+	)
+
+	lagHist = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      lagName,
+			Help:      lagDesc,
+			// 1s to 24h
+			Buckets: []float64{1, 5, 15, 30, 60, 300, 600, 900, 1800, 3600, 7200, 10800, 21600, 43200, 86400},
+		},
+		[]string{artifactLabel},
+	)
+
+	queueSizeGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      queueSizeName,
+			Help:      queueSizeDesc,
+		},
+	)
+
+	prometheus.MustRegister(replicationDurationHist)
+	prometheus.MustRegister(replicationCounter)
+	prometheus.MustRegister(replicatedBytesCounter)
+	prometheus.MustRegister(postponeCounter)
+	prometheus.MustRegister(lagHist)
+	prometheus.MustRegister(queueSizeGauge)
+}
+
+func Replication(artifact string) func(err error) {
+	start := time.Now()
+	return func(err error) {
+		failed := strconv.FormatBool(err != nil)
+
+		replicationCounter.WithLabelValues(artifact, failed).Inc()
+		replicationDurationHist.WithLabelValues(artifact, failed).Observe(timeSince(start).Seconds())
+	}
+}
+
+func ReplicatedBytes(bytes int64) {
+	replicatedBytesCounter.Add(float64(bytes))
+}
+
+func Postpone(artifact string) {
+	postponeCounter.WithLabelValues(artifact).Inc()
+}
+
+func Lag(artifact string, createdAt time.Time) {
+	lagHist.WithLabelValues(artifact).Observe(timeSince(createdAt).Seconds())
+}
+
+func QueueSize(size int) {
+	queueSizeGauge.Set(float64(size))
+}
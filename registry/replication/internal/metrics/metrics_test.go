@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func mockTimeSince(d time.Duration) func() {
+	bkp := timeSince
+	timeSince = func(_ time.Time) time.Duration { return d }
+	return func() { timeSince = bkp }
+}
+
+func TestReplication(t *testing.T) {
+	restore := mockTimeSince(10 * time.Millisecond)
+	defer restore()
+
+	report := Replication("blob")
+	report(errors.New("foo"))
+	report(errors.New("foo")) // to see the aggregated counter increase to 2
+	report(nil)
+
+	report = Replication("manifest")
+	report(nil)
+
+	var expected bytes.Buffer
+	expected.WriteString(`
+# HELP registry_replication_replications_total A counter of replication attempts of artifacts to the secondary registry.
+# TYPE registry_replication_replications_total counter
+registry_replication_replications_total{artifact="blob",error="false"} 1
+registry_replication_replications_total{artifact="blob",error="true"} 2
+registry_replication_replications_total{artifact="manifest",error="false"} 1
+`)
+	totalFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, replicationTotalName)
+
+	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, &expected, totalFullName)
+	require.NoError(t, err)
+}
+
+func TestPostpone(t *testing.T) {
+	Postpone("blob")
+	Postpone("blob")
+	Postpone("manifest")
+
+	var expected bytes.Buffer
+	expected.WriteString(`
+# HELP registry_replication_postpones_total A counter for replication task postpones.
+# TYPE registry_replication_postpones_total counter
+registry_replication_postpones_total{artifact="blob"} 2
+registry_replication_postpones_total{artifact="manifest"} 1
+`)
+	fullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, postponeTotalName)
+
+	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, &expected, fullName)
+	require.NoError(t, err)
+}
+
+func TestQueueSize(t *testing.T) {
+	QueueSize(10)
+	QueueSize(5)
+
+	var expected bytes.Buffer
+	expected.WriteString(`
+# HELP registry_replication_queue_size The size of the replication queue.
+# TYPE registry_replication_queue_size gauge
+registry_replication_queue_size 5
+`)
+	fullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, queueSizeName)
+
+	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, &expected, fullName)
+	require.NoError(t, err)
+}
@@ -0,0 +1,181 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/docker/distribution"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/client"
+	"github.com/docker/distribution/registry/client/auth"
+	"github.com/docker/distribution/registry/client/auth/challenge"
+	"github.com/docker/distribution/registry/client/transport"
+)
+
+// Remote represents the secondary registry to which content is replicated.
+type Remote interface {
+	// PushBlob pushes a blob to the given repository, reading its content from r. This is a no-op if the blob
+	// already exists in the remote repository.
+	PushBlob(ctx context.Context, repo reference.Named, desc distribution.Descriptor, r io.Reader) error
+	// PushManifest pushes a manifest to the given repository. If tag is not empty, the manifest is tagged with it.
+	PushManifest(ctx context.Context, repo reference.Named, mediaType string, payload []byte, tag string) error
+}
+
+// credentials is a static auth.CredentialStore that presents the same username and password to all endpoints
+// challenging a request, namely the remote registry and its token server, if any.
+type credentials struct {
+	username string
+	password string
+}
+
+// Basic implements auth.CredentialStore.
+func (c credentials) Basic(*url.URL) (string, string) {
+	return c.username, c.password
+}
+
+// RefreshToken implements auth.CredentialStore.
+func (c credentials) RefreshToken(*url.URL, string) string {
+	return ""
+}
+
+// SetRefreshToken implements auth.CredentialStore.
+func (c credentials) SetRefreshToken(*url.URL, string, string) {}
+
+type registryRemote struct {
+	url       url.URL
+	transport http.RoundTripper
+	creds     auth.CredentialStore
+
+	// protects the establishment of auth challenges with the remote registry
+	sync.Mutex
+	cm challenge.Manager
+}
+
+// NewRemote creates a Remote for the registry available at remoteURL, authenticating with the given username and
+// password when challenged.
+func NewRemote(remoteURL, username, password string) (Remote, error) {
+	u, err := url.Parse(remoteURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing remote URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid remote URL %q: scheme must be http or https", remoteURL)
+	}
+
+	return &registryRemote{
+		url:       *u,
+		transport: http.DefaultTransport,
+		creds:     credentials{username: username, password: password},
+		cm:        challenge.NewSimpleManager(),
+	}, nil
+}
+
+// establishChallenges pings the remote registry to find out how it challenges requests, unless already known.
+func (r *registryRemote) establishChallenges() error {
+	r.Lock()
+	defer r.Unlock()
+
+	u := r.url
+	u.Path = "/v2/"
+	challenges, err := r.cm.GetChallenges(u)
+	if err != nil {
+		return err
+	}
+	if len(challenges) > 0 {
+		return nil
+	}
+
+	resp, err := (&http.Client{Transport: r.transport}).Get(u.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return r.cm.AddResponse(resp)
+}
+
+func (r *registryRemote) repository(ctx context.Context, name reference.Named) (distribution.Repository, error) {
+	if err := r.establishChallenges(); err != nil {
+		return nil, fmt.Errorf("establishing auth challenges with remote registry: %w", err)
+	}
+
+	tkopts := auth.TokenHandlerOptions{
+		Transport:   r.transport,
+		Credentials: r.creds,
+		Scopes: []auth.Scope{
+			auth.RepositoryScope{
+				Repository: name.Name(),
+				Actions:    []string{"pull", "push"},
+			},
+		},
+		Logger: dcontext.GetLogger(ctx),
+	}
+	tr := transport.NewTransport(r.transport,
+		auth.NewAuthorizer(r.cm, auth.NewTokenHandlerWithOptions(tkopts), auth.NewBasicHandler(r.creds)))
+
+	return client.NewRepository(name, r.url.String(), tr)
+}
+
+// PushBlob implements Remote.
+func (r *registryRemote) PushBlob(ctx context.Context, repo reference.Named, desc distribution.Descriptor, rd io.Reader) error {
+	remoteRepo, err := r.repository(ctx, repo)
+	if err != nil {
+		return err
+	}
+	bs := remoteRepo.Blobs(ctx)
+
+	_, err = bs.Stat(ctx, desc.Digest)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, distribution.ErrBlobUnknown) {
+		return fmt.Errorf("checking blob existence on remote registry: %w", err)
+	}
+
+	w, err := bs.Create(ctx)
+	if err != nil {
+		return fmt.Errorf("creating blob upload on remote registry: %w", err)
+	}
+	if _, err := io.Copy(w, rd); err != nil {
+		_ = w.Cancel(ctx)
+		return fmt.Errorf("uploading blob to remote registry: %w", err)
+	}
+	if _, err := w.Commit(ctx, distribution.Descriptor{Digest: desc.Digest, Size: desc.Size}); err != nil {
+		return fmt.Errorf("committing blob upload on remote registry: %w", err)
+	}
+
+	return nil
+}
+
+// PushManifest implements Remote.
+func (r *registryRemote) PushManifest(ctx context.Context, repo reference.Named, mediaType string, payload []byte, tag string) error {
+	m, _, err := distribution.UnmarshalManifest(mediaType, payload)
+	if err != nil {
+		return fmt.Errorf("unmarshaling manifest: %w", err)
+	}
+
+	remoteRepo, err := r.repository(ctx, repo)
+	if err != nil {
+		return err
+	}
+	ms, err := remoteRepo.Manifests(ctx)
+	if err != nil {
+		return err
+	}
+
+	var opts []distribution.ManifestServiceOption
+	if tag != "" {
+		opts = append(opts, distribution.WithTag(tag))
+	}
+	if _, err := ms.Put(ctx, m, opts...); err != nil {
+		return fmt.Errorf("pushing manifest to remote registry: %w", err)
+	}
+
+	return nil
+}
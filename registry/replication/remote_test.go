@@ -0,0 +1,109 @@
+package replication_test
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/client"
+	"github.com/docker/distribution/registry/handlers"
+	"github.com/docker/distribution/registry/replication"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func newRemoteRegistry(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	config := &configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+			"maintenance": configuration.Parameters{"uploadpurging": map[interface{}]interface{}{
+				"enabled": false,
+			}},
+		},
+	}
+	app := handlers.NewApp(context.Background(), config)
+
+	s := httptest.NewServer(app)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestRemote_Push(t *testing.T) {
+	ctx := context.Background()
+	s := newRemoteRegistry(t)
+
+	remote, err := replication.NewRemote(s.URL, "", "")
+	require.NoError(t, err)
+
+	repo, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	// push the image configuration and layer
+	config := []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`)
+	configDesc := distribution.Descriptor{MediaType: schema2.MediaTypeImageConfig, Digest: digest.FromBytes(config), Size: int64(len(config))}
+	require.NoError(t, remote.PushBlob(ctx, repo, configDesc, bytes.NewReader(config)))
+
+	layer := []byte("layer")
+	layerDesc := distribution.Descriptor{MediaType: schema2.MediaTypeLayer, Digest: digest.FromBytes(layer), Size: int64(len(layer))}
+	require.NoError(t, remote.PushBlob(ctx, repo, layerDesc, bytes.NewReader(layer)))
+	// pushing an existing blob is a no-op
+	require.NoError(t, remote.PushBlob(ctx, repo, layerDesc, bytes.NewReader(layer)))
+
+	// push the manifest
+	m, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    configDesc,
+		Layers:    []distribution.Descriptor{layerDesc},
+	})
+	require.NoError(t, err)
+	mediaType, payload, err := m.Payload()
+	require.NoError(t, err)
+	require.NoError(t, remote.PushManifest(ctx, repo, mediaType, payload, "latest"))
+
+	// check the content on the remote registry
+	cr, err := client.NewRepository(repo, s.URL, nil)
+	require.NoError(t, err)
+
+	p, err := cr.Blobs(ctx).Get(ctx, layerDesc.Digest)
+	require.NoError(t, err)
+	require.Equal(t, layer, p)
+
+	desc, err := cr.Tags(ctx).Get(ctx, "latest")
+	require.NoError(t, err)
+	require.Equal(t, digest.FromBytes(payload), desc.Digest)
+}
+
+func TestRemote_PushManifest_UnknownBlob(t *testing.T) {
+	ctx := context.Background()
+	s := newRemoteRegistry(t)
+
+	remote, err := replication.NewRemote(s.URL, "", "")
+	require.NoError(t, err)
+
+	repo, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	m, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    distribution.Descriptor{MediaType: schema2.MediaTypeImageConfig, Digest: digest.FromString("config"), Size: 6},
+	})
+	require.NoError(t, err)
+	mediaType, payload, err := m.Payload()
+	require.NoError(t, err)
+
+	// the referenced blobs were not replicated yet, so the push must fail and be retried later
+	require.Error(t, remote.PushManifest(ctx, repo, mediaType, payload, "latest"))
+}
+
+func TestNewRemote_InvalidURL(t *testing.T) {
+	_, err := replication.NewRemote("registry.example.com", "", "")
+	require.EqualError(t, err, `invalid remote URL "registry.example.com": scheme must be http or https`)
+}
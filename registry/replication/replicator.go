@@ -0,0 +1,236 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/distribution"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/internal/background"
+	"github.com/docker/distribution/registry/replication/internal/metrics"
+	"github.com/hashicorp/go-multierror"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	componentKey = "component"
+	name         = "registry.replication.Replicator"
+)
+
+var (
+	defaultInterval   = 5 * time.Second
+	defaultTimeout    = 10 * time.Minute
+	defaultMaxBackoff = 24 * time.Hour
+	// leaseMargin is added to the replication timeout when claiming tasks, so that a task is not handed out to another
+	// replicator while still being processed.
+	leaseMargin = time.Minute
+	// queueSizeMonitorInterval is the minimum interval between measurements of the replication queue size.
+	queueSizeMonitorInterval = time.Minute
+
+	// for test purposes (mocking)
+	taskStoreConstructor       = datastore.NewReplicationTaskStore
+	repositoryStoreConstructor = func(db datastore.Queryer) datastore.RepositoryStore { return datastore.NewRepositoryStore(db) }
+)
+
+// Replicator consumes tasks from the replication queue and pushes the corresponding blobs and manifests to a
+// secondary registry. Failed tasks are retried with an exponential back off.
+type Replicator struct {
+	db      datastore.Handler
+	statter distribution.BlobStatter
+	blobs   distribution.BlobProvider
+	remote  Remote
+	cfg     background.Config
+
+	lastQueueSizeCheck time.Time
+}
+
+// Option provides functional options for New.
+type Option func(*Replicator)
+
+// WithLogger sets the logger.
+func WithLogger(l dcontext.Logger) Option {
+	return func(r *Replicator) {
+		r.cfg.Logger = l
+	}
+}
+
+// WithInterval sets the interval between runs when there are no tasks to be processed or the last run failed.
+// Defaults to 5 seconds.
+func WithInterval(d time.Duration) Option {
+	return func(r *Replicator) {
+		r.cfg.Interval = d
+	}
+}
+
+// WithTimeout sets the maximum amount of time allowed to replicate a single artifact. Defaults to 10 minutes.
+func WithTimeout(d time.Duration) Option {
+	return func(r *Replicator) {
+		r.cfg.Timeout = d
+	}
+}
+
+// WithMaxBackoff sets the maximum exponential back off duration used to postpone the retry of failed tasks. Defaults
+// to 24 hours.
+func WithMaxBackoff(d time.Duration) Option {
+	return func(r *Replicator) {
+		r.cfg.MaxBackoff = d
+	}
+}
+
+// New creates a new Replicator. Blobs are read from the given statter and provider, while manifests are read from the
+// database.
+func New(db datastore.Handler, statter distribution.BlobStatter, blobs distribution.BlobProvider, remote Remote, opts ...Option) *Replicator {
+	r := &Replicator{
+		db:      db,
+		statter: statter,
+		blobs:   blobs,
+		remote:  remote,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	r.cfg.ApplyDefaults(background.Config{
+		Interval:   defaultInterval,
+		Timeout:    defaultTimeout,
+		MaxBackoff: defaultMaxBackoff,
+	})
+	r.cfg.Logger = r.cfg.Logger.WithField(componentKey, name)
+
+	return r
+}
+
+// Start starts the Replicator. This is a blocking call that processes tasks in a loop until the provided context is
+// canceled. Tasks are processed back to back while available, otherwise the loop sleeps for the configured interval
+// before trying again. The same applies after a failed run.
+func (r *Replicator) Start(ctx context.Context) error {
+	r.cfg.Logger.WithField("interval_s", r.cfg.Interval.Seconds()).Info("starting replicator")
+
+	return r.cfg.Loop(ctx, func(ctx context.Context) (bool, error) {
+		r.measureQueueSize(ctx)
+		return r.Run(ctx)
+	})
+}
+
+// measureQueueSize reports the size of the replication queue, at most once per queueSizeMonitorInterval.
+func (r *Replicator) measureQueueSize(ctx context.Context) {
+	if time.Since(r.lastQueueSizeCheck) < queueSizeMonitorInterval {
+		return
+	}
+	r.lastQueueSizeCheck = time.Now()
+
+	count, err := taskStoreConstructor(r.db).Count(ctx)
+	if err != nil {
+		r.cfg.Logger.WithError(err).Error("failed to measure replication queue size")
+		return
+	}
+	metrics.QueueSize(count)
+}
+
+// Run processes the next available replication task. A bool is returned to indicate whether there was a task
+// available or not, regardless if processing it succeeded or not. Tasks are deleted once successfully processed,
+// otherwise they are postponed for a later retry.
+func (r *Replicator) Run(ctx context.Context) (bool, error) {
+	ctx = r.cfg.InjectCorrelationID(ctx)
+	log := dcontext.GetLogger(ctx)
+
+	s := taskStoreConstructor(r.db)
+	t, err := s.Next(ctx, r.cfg.Timeout+leaseMargin)
+	if err != nil {
+		return false, err
+	}
+	if t == nil {
+		log.Debug("no task available")
+		return false, nil
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"repository":   t.RepositoryPath,
+		"artifact":     t.Artifact,
+		"digest":       t.Digest,
+		"tag":          t.Tag,
+		"review_count": t.ReviewCount,
+	})
+	log.Info("processing task")
+
+	report := metrics.Replication(t.Artifact)
+	ctx2, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	err = r.replicate(ctx2, t)
+	cancel()
+	report(err)
+
+	if err != nil {
+		d := r.cfg.RetryBackoff(t.ReviewCount)
+		log.WithError(err).WithField("backoff_duration", d.String()).Warn("failed to replicate, postponing task")
+		if innerErr := s.Postpone(ctx, t, d); innerErr != nil {
+			return true, multierror.Append(err, innerErr)
+		}
+		metrics.Postpone(t.Artifact)
+		return true, err
+	}
+
+	metrics.Lag(t.Artifact, t.CreatedAt)
+	log.Info("deleting task")
+	if err := s.Delete(ctx, t); err != nil {
+		return true, err
+	}
+
+	return true, nil
+}
+
+func (r *Replicator) replicate(ctx context.Context, t *models.ReplicationTask) error {
+	log := dcontext.GetLogger(ctx)
+
+	repo, err := reference.WithName(t.RepositoryPath)
+	if err != nil {
+		return fmt.Errorf("parsing repository name: %w", err)
+	}
+
+	switch t.Artifact {
+	case models.ReplicationArtifactBlob:
+		desc, err := r.statter.Stat(ctx, t.Digest)
+		if err != nil {
+			if errors.Is(err, distribution.ErrBlobUnknown) {
+				// the blob was garbage collected in the meantime, there is nothing left to replicate
+				log.Warn("blob no longer exists, skipping")
+				return nil
+			}
+			return fmt.Errorf("checking blob existence: %w", err)
+		}
+
+		rd, err := r.blobs.Open(ctx, t.Digest)
+		if err != nil {
+			return fmt.Errorf("opening blob: %w", err)
+		}
+		defer rd.Close()
+
+		if err := r.remote.PushBlob(ctx, repo, desc, rd); err != nil {
+			return err
+		}
+		metrics.ReplicatedBytes(desc.Size)
+	case models.ReplicationArtifactManifest:
+		dbRepo := &models.Repository{ID: t.RepositoryID, NamespaceID: t.NamespaceID, Path: t.RepositoryPath}
+		m, err := repositoryStoreConstructor(r.db).FindManifestByDigest(ctx, dbRepo, t.Digest)
+		if err != nil {
+			return fmt.Errorf("finding manifest: %w", err)
+		}
+		if m == nil {
+			// the manifest was deleted in the meantime, there is nothing left to replicate
+			log.Warn("manifest no longer exists, skipping")
+			return nil
+		}
+
+		return r.remote.PushManifest(ctx, repo, m.MediaType, m.Payload, t.Tag)
+	default:
+		// retrying would not help, so just drop the task
+		log.Error("unknown artifact type, skipping")
+	}
+
+	return nil
+}
@@ -0,0 +1,301 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTaskStore is an in-memory datastore.ReplicationTaskStore.
+type fakeTaskStore struct {
+	tasks     []*models.ReplicationTask
+	postponed map[int64]time.Duration
+	deleted   []int64
+	err       error
+}
+
+func (s *fakeTaskStore) Create(_ context.Context, t *models.ReplicationTask) error {
+	t.ID = int64(len(s.tasks) + 1)
+	s.tasks = append(s.tasks, t)
+	return nil
+}
+
+func (s *fakeTaskStore) Count(_ context.Context) (int, error) {
+	return len(s.tasks), s.err
+}
+
+func (s *fakeTaskStore) Next(_ context.Context, _ time.Duration) (*models.ReplicationTask, error) {
+	if s.err != nil || len(s.tasks) == 0 {
+		return nil, s.err
+	}
+	return s.tasks[0], nil
+}
+
+func (s *fakeTaskStore) Postpone(_ context.Context, t *models.ReplicationTask, d time.Duration) error {
+	if s.postponed == nil {
+		s.postponed = make(map[int64]time.Duration)
+	}
+	s.postponed[t.ID] = d
+	t.ReviewCount++
+	return nil
+}
+
+func (s *fakeTaskStore) Delete(_ context.Context, t *models.ReplicationTask) error {
+	s.deleted = append(s.deleted, t.ID)
+	s.tasks = s.tasks[1:]
+	return nil
+}
+
+func stubTaskStore(tb testing.TB, s datastore.ReplicationTaskStore) {
+	tb.Helper()
+
+	bkp := taskStoreConstructor
+	taskStoreConstructor = func(db datastore.Queryer) datastore.ReplicationTaskStore { return s }
+
+	tb.Cleanup(func() { taskStoreConstructor = bkp })
+}
+
+// fakeRepositoryStore is a minimal datastore.RepositoryStore stub. Calling any method other than
+// FindManifestByDigest panics.
+type fakeRepositoryStore struct {
+	datastore.RepositoryStore
+	manifest *models.Manifest
+}
+
+// FindManifestByDigest implements datastore.RepositoryStore.
+func (s *fakeRepositoryStore) FindManifestByDigest(_ context.Context, _ *models.Repository, dgst digest.Digest) (*models.Manifest, error) {
+	if s.manifest == nil || s.manifest.Digest != dgst {
+		return nil, nil
+	}
+	return s.manifest, nil
+}
+
+func stubRepositoryStore(tb testing.TB, rs datastore.RepositoryStore) {
+	tb.Helper()
+
+	bkp := repositoryStoreConstructor
+	repositoryStoreConstructor = func(db datastore.Queryer) datastore.RepositoryStore { return rs }
+
+	tb.Cleanup(func() { repositoryStoreConstructor = bkp })
+}
+
+type pushedManifest struct {
+	repo      string
+	mediaType string
+	payload   []byte
+	tag       string
+}
+
+// fakeRemote records pushed content, failing all pushes if err is set.
+type fakeRemote struct {
+	blobs     map[digest.Digest][]byte
+	manifests []pushedManifest
+	err       error
+}
+
+// PushBlob implements Remote.
+func (r *fakeRemote) PushBlob(_ context.Context, _ reference.Named, desc distribution.Descriptor, rd io.Reader) error {
+	if r.err != nil {
+		return r.err
+	}
+	p, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return err
+	}
+	if r.blobs == nil {
+		r.blobs = make(map[digest.Digest][]byte)
+	}
+	r.blobs[desc.Digest] = p
+	return nil
+}
+
+// PushManifest implements Remote.
+func (r *fakeRemote) PushManifest(_ context.Context, repo reference.Named, mediaType string, payload []byte, tag string) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.manifests = append(r.manifests, pushedManifest{repo: repo.Name(), mediaType: mediaType, payload: payload, tag: tag})
+	return nil
+}
+
+func newTestReplicator(t *testing.T, remote Remote) (*Replicator, distribution.BlobIngester) {
+	t.Helper()
+
+	ctx := context.Background()
+	registry, err := storage.NewRegistry(ctx, inmemory.New())
+	require.NoError(t, err)
+
+	named, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	repo, err := registry.Repository(ctx, named)
+	require.NoError(t, err)
+
+	bp, ok := registry.Blobs().(distribution.BlobProvider)
+	require.True(t, ok)
+
+	return New(nil, registry.BlobStatter(), bp, remote), repo.Blobs(ctx)
+}
+
+func TestReplicator_Run_NoTask(t *testing.T) {
+	stubTaskStore(t, &fakeTaskStore{})
+
+	r, _ := newTestReplicator(t, &fakeRemote{})
+	found, err := r.Run(context.Background())
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestReplicator_Run_NextError(t *testing.T) {
+	stubTaskStore(t, &fakeTaskStore{err: errors.New("foo")})
+
+	r, _ := newTestReplicator(t, &fakeRemote{})
+	found, err := r.Run(context.Background())
+	require.EqualError(t, err, "foo")
+	require.False(t, found)
+}
+
+func TestReplicator_Run_Blob(t *testing.T) {
+	remote := &fakeRemote{}
+	r, blobs := newTestReplicator(t, remote)
+
+	content := []byte("foo")
+	desc, err := blobs.Put(context.Background(), "application/octet-stream", content)
+	require.NoError(t, err)
+
+	s := &fakeTaskStore{}
+	require.NoError(t, s.Create(context.Background(), &models.ReplicationTask{
+		RepositoryPath: "foo/bar",
+		Artifact:       models.ReplicationArtifactBlob,
+		Digest:         desc.Digest,
+	}))
+	stubTaskStore(t, s)
+
+	found, err := r.Run(context.Background())
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, content, remote.blobs[desc.Digest])
+	require.Equal(t, []int64{1}, s.deleted)
+}
+
+func TestReplicator_Run_BlobUnknown(t *testing.T) {
+	remote := &fakeRemote{}
+	r, _ := newTestReplicator(t, remote)
+
+	s := &fakeTaskStore{}
+	require.NoError(t, s.Create(context.Background(), &models.ReplicationTask{
+		RepositoryPath: "foo/bar",
+		Artifact:       models.ReplicationArtifactBlob,
+		Digest:         digest.FromString("foo"),
+	}))
+	stubTaskStore(t, s)
+
+	found, err := r.Run(context.Background())
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Empty(t, remote.blobs)
+	require.Equal(t, []int64{1}, s.deleted)
+}
+
+func TestReplicator_Run_Manifest(t *testing.T) {
+	payload := []byte(`{"schemaVersion":2}`)
+	m := &models.Manifest{
+		MediaType: "application/vnd.docker.distribution.manifest.v2+json",
+		Digest:    digest.FromBytes(payload),
+		Payload:   payload,
+	}
+	stubRepositoryStore(t, &fakeRepositoryStore{manifest: m})
+
+	s := &fakeTaskStore{}
+	require.NoError(t, s.Create(context.Background(), &models.ReplicationTask{
+		RepositoryPath: "foo/bar",
+		Artifact:       models.ReplicationArtifactManifest,
+		Digest:         m.Digest,
+		Tag:            "latest",
+	}))
+	stubTaskStore(t, s)
+
+	remote := &fakeRemote{}
+	r, _ := newTestReplicator(t, remote)
+
+	found, err := r.Run(context.Background())
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []pushedManifest{{repo: "foo/bar", mediaType: m.MediaType, payload: payload, tag: "latest"}}, remote.manifests)
+	require.Equal(t, []int64{1}, s.deleted)
+}
+
+func TestReplicator_Run_ManifestUnknown(t *testing.T) {
+	stubRepositoryStore(t, &fakeRepositoryStore{})
+
+	s := &fakeTaskStore{}
+	require.NoError(t, s.Create(context.Background(), &models.ReplicationTask{
+		RepositoryPath: "foo/bar",
+		Artifact:       models.ReplicationArtifactManifest,
+		Digest:         digest.FromString("foo"),
+	}))
+	stubTaskStore(t, s)
+
+	remote := &fakeRemote{}
+	r, _ := newTestReplicator(t, remote)
+
+	found, err := r.Run(context.Background())
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Empty(t, remote.manifests)
+	require.Equal(t, []int64{1}, s.deleted)
+}
+
+func TestReplicator_Run_RemoteError(t *testing.T) {
+	remote := &fakeRemote{err: errors.New("remote unavailable")}
+	r, blobs := newTestReplicator(t, remote)
+
+	desc, err := blobs.Put(context.Background(), "application/octet-stream", []byte("foo"))
+	require.NoError(t, err)
+
+	s := &fakeTaskStore{}
+	require.NoError(t, s.Create(context.Background(), &models.ReplicationTask{
+		RepositoryPath: "foo/bar",
+		Artifact:       models.ReplicationArtifactBlob,
+		Digest:         desc.Digest,
+		ReviewCount:    2,
+	}))
+	stubTaskStore(t, s)
+
+	found, err := r.Run(context.Background())
+	require.EqualError(t, err, "remote unavailable")
+	require.True(t, found)
+	require.Empty(t, s.deleted)
+	require.Equal(t, map[int64]time.Duration{1: 2 * time.Minute}, s.postponed)
+}
+
+func TestReplicator_RetryBackoff(t *testing.T) {
+	r := New(nil, nil, nil, &fakeRemote{}, WithMaxBackoff(time.Hour))
+
+	tt := []struct {
+		count int
+		want  time.Duration
+	}{
+		{count: -1, want: 30 * time.Second},
+		{count: 0, want: 30 * time.Second},
+		{count: 1, want: time.Minute},
+		{count: 5, want: 16 * time.Minute},
+		{count: 7, want: time.Hour},
+		{count: 100, want: time.Hour},
+	}
+
+	for _, test := range tt {
+		require.Equal(t, test.want, r.cfg.RetryBackoff(test.count), "review count %d", test.count)
+	}
+}
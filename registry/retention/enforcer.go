@@ -0,0 +1,314 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/audit"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/internal/background"
+	"github.com/hashicorp/go-multierror"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	componentKey = "component"
+	name         = "registry.retention.Enforcer"
+)
+
+var (
+	defaultInterval   = 5 * time.Second
+	defaultPeriod     = 24 * time.Hour
+	defaultTimeout    = 10 * time.Minute
+	defaultMaxBackoff = 24 * time.Hour
+	// leaseMargin is added to the timeout when claiming policies, so that a policy is not handed out to another
+	// enforcer while still being processed.
+	leaseMargin = time.Minute
+	// tagsPageSize is the number of tags read from the database at once while listing the tags of a repository.
+	tagsPageSize = 1000
+	// tagDeleteGCReviewWindow and tagDeleteGCLockTimeout match those used by the tag delete API, see dbDeleteTag in
+	// registry/handlers/tags.go.
+	tagDeleteGCReviewWindow = 1 * time.Hour
+	tagDeleteGCLockTimeout  = 5 * time.Second
+
+	// for test purposes (mocking)
+	policyStoreConstructor       = datastore.NewRetentionPolicyStore
+	repositoryStoreConstructor   = func(db datastore.Queryer) datastore.RepositoryStore { return datastore.NewRepositoryStore(db) }
+	manifestTaskStoreConstructor = datastore.NewGCManifestTaskStore
+	timeNow                      = time.Now
+)
+
+// Enforcer periodically evaluates the retention policies of repositories, deleting the tags that they do not retain.
+// Tags are only deleted if they were not written to since their evaluation, and the same locks as the tag delete API
+// are held while doing so. Policies in dry run mode are evaluated but nothing is deleted. Each deleted tag (or tag that
+// would have been deleted, for dry runs) is recorded in the audit log, if enabled. Failed runs are retried with an exponential back off.
+type Enforcer struct {
+	db          datastore.Handler
+	auditLogger *audit.Logger
+	cfg         background.Config
+	period      time.Duration
+	onDelete    func(ctx context.Context, repoPath, tag string)
+	lockTag     func(ctx context.Context, repoPath, tag string) (func(), error)
+}
+
+// Option provides functional options for New.
+type Option func(*Enforcer)
+
+// WithLogger sets the logger.
+func WithLogger(l dcontext.Logger) Option {
+	return func(e *Enforcer) {
+		e.cfg.Logger = l
+	}
+}
+
+// WithAuditLogger sets the audit logger to which deleted tags are recorded. Nothing is recorded by default.
+func WithAuditLogger(l *audit.Logger) Option {
+	return func(e *Enforcer) {
+		e.auditLogger = l
+	}
+}
+
+// WithTagDeleteHook sets a function called after each tag deleted by a retention policy, e.g. to invalidate caches.
+func WithTagDeleteHook(fn func(ctx context.Context, repoPath, tag string)) Option {
+	return func(e *Enforcer) {
+		e.onDelete = fn
+	}
+}
+
+// WithTagLocker sets a function used to acquire the lock of each tag before deleting it, so that deletes do not race
+// concurrent writes of the same tag. The function returned on success must release the lock. Tags are not locked by
+// default.
+func WithTagLocker(fn func(ctx context.Context, repoPath, tag string) (func(), error)) Option {
+	return func(e *Enforcer) {
+		e.lockTag = fn
+	}
+}
+
+// WithInterval sets the interval between runs when there are no policies to be evaluated or the last run failed.
+// Defaults to 5 seconds.
+func WithInterval(d time.Duration) Option {
+	return func(e *Enforcer) {
+		e.cfg.Interval = d
+	}
+}
+
+// WithPeriod sets the amount of time between consecutive evaluations of each policy. Defaults to 24 hours.
+func WithPeriod(d time.Duration) Option {
+	return func(e *Enforcer) {
+		e.period = d
+	}
+}
+
+// WithTimeout sets the maximum amount of time allowed to evaluate and enforce a single policy. Defaults to 10
+// minutes.
+func WithTimeout(d time.Duration) Option {
+	return func(e *Enforcer) {
+		e.cfg.Timeout = d
+	}
+}
+
+// WithMaxBackoff sets the maximum exponential back off duration used to postpone the retry of failed runs. Defaults
+// to 24 hours.
+func WithMaxBackoff(d time.Duration) Option {
+	return func(e *Enforcer) {
+		e.cfg.MaxBackoff = d
+	}
+}
+
+// New creates a new Enforcer.
+func New(db datastore.Handler, opts ...Option) *Enforcer {
+	e := &Enforcer{db: db, period: defaultPeriod}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	e.cfg.ApplyDefaults(background.Config{
+		Interval:   defaultInterval,
+		Timeout:    defaultTimeout,
+		MaxBackoff: defaultMaxBackoff,
+	})
+	e.cfg.Logger = e.cfg.Logger.WithField(componentKey, name)
+
+	return e
+}
+
+// Start starts the Enforcer. This is a blocking call that evaluates policies in a loop until the provided context is
+// canceled. Policies are evaluated back to back while available, otherwise the loop sleeps for the configured interval
+// before trying again. The same applies after a failed run.
+func (e *Enforcer) Start(ctx context.Context) error {
+	e.cfg.Logger.WithFields(logrus.Fields{
+		"interval_s": e.cfg.Interval.Seconds(),
+		"period_s":   e.period.Seconds(),
+	}).Info("starting retention policy enforcer")
+
+	return e.cfg.Loop(ctx, e.Run)
+}
+
+// Run evaluates and enforces the next available retention policy. A bool is returned to indicate whether there was a
+// policy available or not, regardless if enforcing it succeeded or not. Once enforced, the next evaluation of a policy
+// is scheduled after the configured period, otherwise it's postponed for a later retry.
+func (e *Enforcer) Run(ctx context.Context) (bool, error) {
+	ctx = e.cfg.InjectCorrelationID(ctx)
+	log := dcontext.GetLogger(ctx)
+
+	s := policyStoreConstructor(e.db)
+	p, err := s.Next(ctx, e.cfg.Timeout+leaseMargin)
+	if err != nil {
+		return false, err
+	}
+	if p == nil {
+		log.Debug("no policy available")
+		return false, nil
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"repository":   p.RepositoryPath,
+		"keep_n":       p.KeepN,
+		"keep_regex":   p.KeepRegex,
+		"older_than_s": p.OlderThan.Seconds(),
+		"dry_run":      p.DryRun,
+		"review_count": p.ReviewCount,
+	})
+	log.Info("enforcing retention policy")
+
+	ctx2, cancel := context.WithTimeout(ctx, e.cfg.Timeout)
+	deleted, err := e.enforce(ctx2, p)
+	cancel()
+
+	if err != nil {
+		d := e.cfg.RetryBackoff(p.ReviewCount)
+		log.WithError(err).WithField("backoff_duration", d.String()).Warn("failed to enforce retention policy, postponing")
+		if innerErr := s.Postpone(ctx, p, d, err.Error()); innerErr != nil {
+			return true, multierror.Append(err, innerErr)
+		}
+		return true, err
+	}
+
+	log.WithField("deleted_tags", deleted).Info("retention policy enforced")
+	if err := s.Complete(ctx, p, e.period, deleted); err != nil {
+		return true, err
+	}
+
+	return true, nil
+}
+
+// enforce deletes the tags of the repository of p that the policy does not retain, returning the number of deleted
+// tags. For dry runs, nothing is deleted and the number of tags that would have been deleted is returned instead.
+func (e *Enforcer) enforce(ctx context.Context, p *models.RetentionPolicy) (int, error) {
+	log := dcontext.GetLogger(ctx)
+	repo := &models.Repository{ID: p.RepositoryID, NamespaceID: p.NamespaceID, Path: p.RepositoryPath}
+	rStore := repositoryStoreConstructor(e.db)
+
+	var tags models.TagDetails
+	var last string
+	for {
+		tt, err := rStore.TagsDetailPaginated(ctx, repo, tagsPageSize, last)
+		if err != nil {
+			return 0, fmt.Errorf("listing repository tags: %w", err)
+		}
+		tags = append(tags, tt...)
+		if len(tt) < tagsPageSize {
+			break
+		}
+		last = tt[len(tt)-1].Name
+	}
+
+	candidates, err := Evaluate(p, tags, timeNow())
+	if err != nil {
+		return 0, err
+	}
+
+	if p.DryRun {
+		for _, t := range candidates {
+			log.WithField("tag", t.Name).Info("tag would be deleted by retention policy (dry run)")
+			e.auditLog(repo, audit.ActionRetentionTagDeleteDryRun, t)
+		}
+		return len(candidates), nil
+	}
+
+	var deleted int
+	for _, t := range candidates {
+		ok, err := e.deleteTag(ctx, repo, t)
+		if err != nil {
+			return deleted, fmt.Errorf("deleting tag %q: %w", t.Name, err)
+		}
+		if !ok {
+			// the tag was deleted or written to since it was listed
+			log.WithField("tag", t.Name).Info("tag changed since evaluation, skipping")
+			continue
+		}
+		log.WithField("tag", t.Name).Info("tag deleted by retention policy")
+		e.auditLog(repo, audit.ActionRetentionTagDelete, t)
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// deleteTag deletes tag t from repo, unless it was deleted or written to since it was listed. A bool is returned to
+// indicate whether the tag was deleted or not. The tag delete hook, if any, is called before releasing the tag lock.
+func (e *Enforcer) deleteTag(ctx context.Context, repo *models.Repository, t *models.TagDetail) (bool, error) {
+	if e.lockTag != nil {
+		unlock, err := e.lockTag(ctx, repo.Path, t.Name)
+		if err != nil {
+			return false, fmt.Errorf("acquiring tag lock: %w", err)
+		}
+		defer unlock()
+	}
+
+	// grab the ID of the tagged manifest, required to find and lock a related online GC manifest review record (if
+	// any) before deleting the tag, to prevent conflicting online GC reviews
+	tag, err := repositoryStoreConstructor(e.db).FindTagByName(ctx, repo, t.Name)
+	if err != nil {
+		return false, err
+	}
+	if tag == nil {
+		return false, nil
+	}
+
+	txCtx, cancel := context.WithTimeout(ctx, tagDeleteGCLockTimeout)
+	defer cancel()
+
+	tx, err := e.db.BeginTx(txCtx, nil)
+	if err != nil {
+		return false, fmt.Errorf("creating database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	mts := manifestTaskStoreConstructor(tx)
+	if _, err := mts.FindAndLockBefore(txCtx, repo.NamespaceID, repo.ID, tag.ManifestID, timeNow().Add(tagDeleteGCReviewWindow)); err != nil {
+		return false, err
+	}
+
+	// the review record lock and the tag delete must be executed within the same transaction, otherwise the
+	// `gc_track_deleted_tags` trigger would deadlock trying to acquire the same lock
+	ok, err := repositoryStoreConstructor(tx).DeleteTagByNameIfNotWrittenAfter(txCtx, repo, t.Name, LastWrite(t))
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("committing database transaction: %w", err)
+	}
+	if e.onDelete != nil {
+		e.onDelete(ctx, repo.Path, t.Name)
+	}
+
+	return true, nil
+}
+
+func (e *Enforcer) auditLog(repo *models.Repository, action audit.Action, t *models.TagDetail) {
+	if e.auditLogger == nil {
+		return
+	}
+
+	e.auditLogger.Log(audit.Event{
+		Action:     action,
+		Repository: repo.Path,
+		Digest:     t.Digest,
+		Tag:        t.Name,
+	})
+}
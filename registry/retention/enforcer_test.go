@@ -0,0 +1,431 @@
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/audit"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/internal/background"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePolicyStore is an in-memory datastore.RetentionPolicyStore.
+type fakePolicyStore struct {
+	policies  []*models.RetentionPolicy
+	completed map[int64]int
+	postponed []time.Duration
+	err       error
+}
+
+func (s *fakePolicyStore) CreateOrUpdate(_ context.Context, p *models.RetentionPolicy) error {
+	s.policies = append(s.policies, p)
+	return nil
+}
+
+func (s *fakePolicyStore) FindByRepository(_ context.Context, r *models.Repository) (*models.RetentionPolicy, error) {
+	for _, p := range s.policies {
+		if p.RepositoryID == r.ID {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakePolicyStore) Delete(_ context.Context, r *models.Repository) error {
+	return errors.New("not implemented")
+}
+
+func (s *fakePolicyStore) Next(_ context.Context, _ time.Duration) (*models.RetentionPolicy, error) {
+	if s.err != nil || len(s.policies) == 0 {
+		return nil, s.err
+	}
+	return s.policies[0], nil
+}
+
+func (s *fakePolicyStore) Complete(_ context.Context, p *models.RetentionPolicy, _ time.Duration, deleted int) error {
+	if s.completed == nil {
+		s.completed = make(map[int64]int)
+	}
+	s.completed[p.RepositoryID] = deleted
+	p.LastRunDeleted = deleted
+	p.Error = ""
+	return nil
+}
+
+func (s *fakePolicyStore) Postpone(_ context.Context, p *models.RetentionPolicy, d time.Duration, reason string) error {
+	s.postponed = append(s.postponed, d)
+	p.ReviewCount++
+	p.Error = reason
+	return nil
+}
+
+func stubPolicyStore(tb testing.TB, s datastore.RetentionPolicyStore) {
+	tb.Helper()
+
+	bkp := policyStoreConstructor
+	policyStoreConstructor = func(db datastore.Queryer) datastore.RetentionPolicyStore { return s }
+
+	tb.Cleanup(func() { policyStoreConstructor = bkp })
+}
+
+// fakeHandler is a minimal datastore.Handler stub whose transactions are no-ops. Calling any method other than
+// BeginTx panics.
+type fakeHandler struct {
+	datastore.Handler
+	committed int
+}
+
+// BeginTx implements datastore.Handler.
+func (h *fakeHandler) BeginTx(_ context.Context, _ *sql.TxOptions) (datastore.Transactor, error) {
+	return &fakeTx{h: h}, nil
+}
+
+// fakeTx is a no-op datastore.Transactor.
+type fakeTx struct {
+	datastore.Queryer
+	h    *fakeHandler
+	done bool
+}
+
+// Commit implements datastore.Transactor.
+func (tx *fakeTx) Commit() error {
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+	tx.h.committed++
+	return nil
+}
+
+// Rollback implements datastore.Transactor.
+func (tx *fakeTx) Rollback() error {
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+	return nil
+}
+
+// fakeManifestTaskStore is a minimal datastore.GCManifestTaskStore stub. Calling any method other than
+// FindAndLockBefore panics.
+type fakeManifestTaskStore struct {
+	datastore.GCManifestTaskStore
+	locked []int64
+}
+
+// FindAndLockBefore implements datastore.GCManifestTaskStore.
+func (s *fakeManifestTaskStore) FindAndLockBefore(_ context.Context, _, _, manifestID int64, _ time.Time) (*models.GCManifestTask, error) {
+	s.locked = append(s.locked, manifestID)
+	return nil, nil
+}
+
+func stubManifestTaskStore(tb testing.TB, s datastore.GCManifestTaskStore) {
+	tb.Helper()
+
+	bkp := manifestTaskStoreConstructor
+	manifestTaskStoreConstructor = func(db datastore.Queryer) datastore.GCManifestTaskStore { return s }
+
+	tb.Cleanup(func() { manifestTaskStoreConstructor = bkp })
+}
+
+// fakeRepositoryStore is a minimal datastore.RepositoryStore stub. Calling any method other than TagsDetailPaginated,
+// FindTagByName and DeleteTagByNameIfNotWrittenAfter panics.
+type fakeRepositoryStore struct {
+	datastore.RepositoryStore
+	tags    models.TagDetails
+	changed map[string]bool
+	deleted []string
+	err     error
+}
+
+// TagsDetailPaginated implements datastore.RepositoryStore.
+func (s *fakeRepositoryStore) TagsDetailPaginated(_ context.Context, _ *models.Repository, limit int, lastName string) (models.TagDetails, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	var tt models.TagDetails
+	for _, t := range s.tags {
+		if t.Name > lastName && len(tt) < limit {
+			tt = append(tt, t)
+		}
+	}
+	return tt, nil
+}
+
+// FindTagByName implements datastore.RepositoryStore. The ID of the tagged manifest is the position of the tag in the
+// list of tags, starting at 1.
+func (s *fakeRepositoryStore) FindTagByName(_ context.Context, _ *models.Repository, name string) (*models.Tag, error) {
+	for i, t := range s.tags {
+		if t.Name == name {
+			return &models.Tag{Name: t.Name, ManifestID: int64(i + 1)}, nil
+		}
+	}
+	return nil, nil
+}
+
+// DeleteTagByNameIfNotWrittenAfter implements datastore.RepositoryStore.
+func (s *fakeRepositoryStore) DeleteTagByNameIfNotWrittenAfter(_ context.Context, _ *models.Repository, name string, _ time.Time) (bool, error) {
+	if s.changed[name] {
+		return false, nil
+	}
+	s.deleted = append(s.deleted, name)
+	return true, nil
+}
+
+func stubRepositoryStore(tb testing.TB, rs datastore.RepositoryStore) {
+	tb.Helper()
+
+	bkp := repositoryStoreConstructor
+	repositoryStoreConstructor = func(db datastore.Queryer) datastore.RepositoryStore { return rs }
+
+	tb.Cleanup(func() { repositoryStoreConstructor = bkp })
+}
+
+func stubTimeNow(tb testing.TB, now time.Time) {
+	tb.Helper()
+
+	bkp := timeNow
+	timeNow = func() time.Time { return now }
+
+	tb.Cleanup(func() { timeNow = bkp })
+}
+
+// memorySink is an audit.Sink that keeps events in memory.
+type memorySink struct {
+	events []audit.Event
+}
+
+func (s *memorySink) Write(event audit.Event) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	return nil
+}
+
+func testTags(now time.Time) models.TagDetails {
+	// sorted by name, like the database returns them
+	return models.TagDetails{
+		{Name: "a", Digest: "sha256:aaaa", CreatedAt: now.Add(-3 * time.Hour)},
+		{Name: "b", Digest: "sha256:bbbb", CreatedAt: now.Add(-2 * time.Hour)},
+		{Name: "c", Digest: "sha256:cccc", CreatedAt: now.Add(-1 * time.Hour)},
+	}
+}
+
+func TestEnforcer_Run_NoPolicy(t *testing.T) {
+	stubPolicyStore(t, &fakePolicyStore{})
+
+	e := New(nil)
+	found, err := e.Run(context.Background())
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestEnforcer_Run_NextError(t *testing.T) {
+	stubPolicyStore(t, &fakePolicyStore{err: errors.New("foo")})
+
+	e := New(nil)
+	found, err := e.Run(context.Background())
+	require.EqualError(t, err, "foo")
+	require.False(t, found)
+}
+
+func TestEnforcer_Run(t *testing.T) {
+	now := time.Now()
+	stubTimeNow(t, now)
+
+	ps := &fakePolicyStore{policies: []*models.RetentionPolicy{{RepositoryID: 1, RepositoryPath: "foo/bar", KeepN: 1}}}
+	stubPolicyStore(t, ps)
+	rs := &fakeRepositoryStore{tags: testTags(now), changed: map[string]bool{"a": true}}
+	stubRepositoryStore(t, rs)
+
+	sink := &memorySink{}
+	al := audit.NewLogger(sink, 0)
+
+	mts := &fakeManifestTaskStore{}
+	stubManifestTaskStore(t, mts)
+	db := &fakeHandler{}
+
+	e := New(db, WithAuditLogger(al))
+	found, err := e.Run(context.Background())
+	require.NoError(t, err)
+	require.True(t, found)
+
+	// the review records of the manifests tagged by "a" and "b" are locked before attempting to delete them, but only
+	// the delete of "b" is committed
+	require.Equal(t, []int64{1, 2}, mts.locked)
+	require.Equal(t, 1, db.committed)
+
+	// "a" was written to since it was listed, so it's skipped
+	require.Equal(t, []string{"b"}, rs.deleted)
+	require.Equal(t, map[int64]int{1: 1}, ps.completed)
+	require.Empty(t, ps.postponed)
+
+	require.NoError(t, al.Close())
+	require.Len(t, sink.events, 1)
+	require.Equal(t, audit.ActionRetentionTagDelete, sink.events[0].Action)
+	require.Equal(t, "foo/bar", sink.events[0].Repository)
+	require.Equal(t, "b", sink.events[0].Tag)
+	require.EqualValues(t, "sha256:bbbb", sink.events[0].Digest)
+}
+
+func TestEnforcer_Run_TagDeleteHook(t *testing.T) {
+	now := time.Now()
+	stubTimeNow(t, now)
+
+	ps := &fakePolicyStore{policies: []*models.RetentionPolicy{{RepositoryID: 1, RepositoryPath: "foo/bar", KeepN: 1}}}
+	stubPolicyStore(t, ps)
+	rs := &fakeRepositoryStore{tags: testTags(now), changed: map[string]bool{"a": true}}
+	stubRepositoryStore(t, rs)
+
+	stubManifestTaskStore(t, &fakeManifestTaskStore{})
+
+	var deleted []string
+	e := New(&fakeHandler{}, WithTagDeleteHook(func(_ context.Context, repoPath, tag string) {
+		deleted = append(deleted, repoPath+":"+tag)
+	}))
+	found, err := e.Run(context.Background())
+	require.NoError(t, err)
+	require.True(t, found)
+
+	// only called for tags actually deleted
+	require.Equal(t, []string{"foo/bar:b"}, deleted)
+}
+
+func TestEnforcer_Run_TagLocker(t *testing.T) {
+	now := time.Now()
+	stubTimeNow(t, now)
+
+	ps := &fakePolicyStore{policies: []*models.RetentionPolicy{{RepositoryID: 1, RepositoryPath: "foo/bar", KeepN: 1}}}
+	stubPolicyStore(t, ps)
+	rs := &fakeRepositoryStore{tags: testTags(now)}
+	stubRepositoryStore(t, rs)
+	stubManifestTaskStore(t, &fakeManifestTaskStore{})
+
+	var held []string
+	var events []string
+	lock := func(_ context.Context, repoPath, tag string) (func(), error) {
+		key := repoPath + ":" + tag
+		require.Empty(t, held, "tags must be locked one at a time")
+		held = append(held, key)
+		events = append(events, "lock "+key)
+		return func() {
+			held = held[:0]
+			events = append(events, "unlock "+key)
+		}, nil
+	}
+	hook := func(_ context.Context, repoPath, tag string) {
+		events = append(events, "delete "+repoPath+":"+tag)
+	}
+
+	found, err := New(&fakeHandler{}, WithTagLocker(lock), WithTagDeleteHook(hook)).Run(context.Background())
+	require.NoError(t, err)
+	require.True(t, found)
+
+	// each tag is deleted, and the hook called, while its lock is held
+	require.Equal(t, []string{"a", "b"}, rs.deleted)
+	require.Equal(t, []string{
+		"lock foo/bar:a", "delete foo/bar:a", "unlock foo/bar:a",
+		"lock foo/bar:b", "delete foo/bar:b", "unlock foo/bar:b",
+	}, events)
+}
+
+func TestEnforcer_Run_TagLockerError(t *testing.T) {
+	now := time.Now()
+	stubTimeNow(t, now)
+
+	ps := &fakePolicyStore{policies: []*models.RetentionPolicy{{RepositoryID: 1, RepositoryPath: "foo/bar", KeepN: 1}}}
+	stubPolicyStore(t, ps)
+	rs := &fakeRepositoryStore{tags: testTags(now)}
+	stubRepositoryStore(t, rs)
+
+	lock := func(_ context.Context, _, _ string) (func(), error) {
+		return nil, errors.New("foo")
+	}
+
+	found, err := New(&fakeHandler{}, WithTagLocker(lock)).Run(context.Background())
+	require.EqualError(t, err, `deleting tag "a": acquiring tag lock: foo`)
+	require.True(t, found)
+	require.Empty(t, rs.deleted)
+	require.Len(t, ps.postponed, 1)
+}
+
+func TestEnforcer_Run_DryRun(t *testing.T) {
+	now := time.Now()
+	stubTimeNow(t, now)
+
+	ps := &fakePolicyStore{policies: []*models.RetentionPolicy{{RepositoryID: 1, RepositoryPath: "foo/bar", KeepN: 1, DryRun: true}}}
+	stubPolicyStore(t, ps)
+	rs := &fakeRepositoryStore{tags: testTags(now)}
+	stubRepositoryStore(t, rs)
+
+	sink := &memorySink{}
+	al := audit.NewLogger(sink, 0)
+
+	e := New(nil, WithAuditLogger(al))
+	found, err := e.Run(context.Background())
+	require.NoError(t, err)
+	require.True(t, found)
+
+	require.Empty(t, rs.deleted)
+	require.Equal(t, map[int64]int{1: 2}, ps.completed)
+
+	require.NoError(t, al.Close())
+	require.Len(t, sink.events, 2)
+	for _, event := range sink.events {
+		require.Equal(t, audit.ActionRetentionTagDeleteDryRun, event.Action)
+	}
+	require.Equal(t, "a", sink.events[0].Tag)
+	require.Equal(t, "b", sink.events[1].Tag)
+}
+
+func TestEnforcer_Run_Paginated(t *testing.T) {
+	bkp := tagsPageSize
+	tagsPageSize = 2
+	t.Cleanup(func() { tagsPageSize = bkp })
+
+	now := time.Now()
+	stubTimeNow(t, now)
+
+	ps := &fakePolicyStore{policies: []*models.RetentionPolicy{{RepositoryID: 1, RepositoryPath: "foo/bar"}}}
+	stubPolicyStore(t, ps)
+	rs := &fakeRepositoryStore{tags: testTags(now)}
+	stubRepositoryStore(t, rs)
+	stubManifestTaskStore(t, &fakeManifestTaskStore{})
+
+	found, err := New(&fakeHandler{}).Run(context.Background())
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []string{"a", "b", "c"}, rs.deleted)
+}
+
+func TestEnforcer_Run_Error(t *testing.T) {
+	ps := &fakePolicyStore{policies: []*models.RetentionPolicy{{RepositoryID: 1, RepositoryPath: "foo/bar"}}}
+	stubPolicyStore(t, ps)
+	stubRepositoryStore(t, &fakeRepositoryStore{err: errors.New("foo")})
+
+	found, err := New(nil).Run(context.Background())
+	require.EqualError(t, err, "listing repository tags: foo")
+	require.True(t, found)
+	require.Equal(t, []time.Duration{background.BaseRetryBackoff}, ps.postponed)
+	require.Equal(t, 1, ps.policies[0].ReviewCount)
+	require.Equal(t, "listing repository tags: foo", ps.policies[0].Error)
+	require.Empty(t, ps.completed)
+}
+
+func TestEnforcer_RetryBackoff(t *testing.T) {
+	e := New(nil, WithMaxBackoff(5*time.Minute))
+
+	require.Equal(t, 30*time.Second, e.cfg.RetryBackoff(0))
+	require.Equal(t, time.Minute, e.cfg.RetryBackoff(1))
+	require.Equal(t, 4*time.Minute, e.cfg.RetryBackoff(3))
+	require.Equal(t, 5*time.Minute, e.cfg.RetryBackoff(4))
+	require.Equal(t, 5*time.Minute, e.cfg.RetryBackoff(100))
+}
@@ -0,0 +1,75 @@
+// Package retention implements the enforcement of per-repository tag retention policies. Policies are stored in the
+// metadata database and evaluated periodically in the background, deleting the tags that they do not retain. The
+// manifests of deleted tags are then picked up by the online garbage collector.
+package retention
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// CompileKeepRegex compiles the KeepRegex of a retention policy. Expressions must match tag names in full, so they are
+// anchored at both ends. A nil regexp is returned if expr is empty.
+func CompileKeepRegex(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	re, err := regexp.Compile(`^(?:` + expr + `)$`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keep regex %q: %w", expr, err)
+	}
+
+	return re, nil
+}
+
+// LastWrite returns the time at which a tag was last written to, i.e., the time of its last update or, if it was never
+// updated, of its creation.
+func LastWrite(t *models.TagDetail) time.Time {
+	if t.UpdatedAt.Valid {
+		return t.UpdatedAt.Time
+	}
+	return t.CreatedAt
+}
+
+// Evaluate returns the tags that policy p does not retain at time now, and that must therefore be deleted. A tag is
+// retained if it is among the KeepN most recently written tags, if its name fully matches KeepRegex or if it was
+// written within OlderThan. A zero KeepN or OlderThan disables the corresponding rule. Tags to delete are returned
+// from the least to the most recently written.
+func Evaluate(p *models.RetentionPolicy, tags models.TagDetails, now time.Time) (models.TagDetails, error) {
+	re, err := CompileKeepRegex(p.KeepRegex)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := make(models.TagDetails, len(tags))
+	copy(sorted, tags)
+	// most recently written first, tag name as tiebreaker for a stable order
+	sort.Slice(sorted, func(i, j int) bool {
+		wi, wj := LastWrite(sorted[i]), LastWrite(sorted[j])
+		if !wi.Equal(wj) {
+			return wi.After(wj)
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	var deleted models.TagDetails
+	for i := len(sorted) - 1; i >= 0; i-- {
+		t := sorted[i]
+		switch {
+		case i < p.KeepN:
+			continue
+		case re != nil && re.MatchString(t.Name):
+			continue
+		case p.OlderThan > 0 && now.Sub(LastWrite(t)) < p.OlderThan:
+			continue
+		}
+		deleted = append(deleted, t)
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,104 @@
+package retention
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/stretchr/testify/require"
+)
+
+func tagNames(tt models.TagDetails) []string {
+	names := make([]string, 0, len(tt))
+	for _, t := range tt {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2021, 7, 26, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	// written 1, 2, 3, 10 and 30 days ago
+	tags := models.TagDetails{
+		{Name: "latest", CreatedAt: now.Add(-30 * day), UpdatedAt: sql.NullTime{Time: now.Add(-1 * day), Valid: true}},
+		{Name: "v1.0.0", CreatedAt: now.Add(-30 * day)},
+		{Name: "v1.1.0", CreatedAt: now.Add(-10 * day)},
+		{Name: "dev-b", CreatedAt: now.Add(-3 * day)},
+		{Name: "dev-a", CreatedAt: now.Add(-2 * day)},
+	}
+
+	tests := []struct {
+		name     string
+		policy   *models.RetentionPolicy
+		expected []string
+	}{
+		{
+			name:     "no rules",
+			policy:   &models.RetentionPolicy{},
+			expected: []string{"v1.0.0", "v1.1.0", "dev-b", "dev-a", "latest"},
+		},
+		{
+			name:     "keep n",
+			policy:   &models.RetentionPolicy{KeepN: 2},
+			expected: []string{"v1.0.0", "v1.1.0", "dev-b"},
+		},
+		{
+			name:     "keep n greater than number of tags",
+			policy:   &models.RetentionPolicy{KeepN: 10},
+			expected: nil,
+		},
+		{
+			name:     "keep regex",
+			policy:   &models.RetentionPolicy{KeepRegex: `v\d+\.\d+\.\d+`},
+			expected: []string{"dev-b", "dev-a", "latest"},
+		},
+		{
+			name:     "keep regex is anchored",
+			policy:   &models.RetentionPolicy{KeepRegex: `dev`},
+			expected: []string{"v1.0.0", "v1.1.0", "dev-b", "dev-a", "latest"},
+		},
+		{
+			name:     "older than",
+			policy:   &models.RetentionPolicy{OlderThan: 7 * day},
+			expected: []string{"v1.0.0", "v1.1.0"},
+		},
+		{
+			name:     "combined",
+			policy:   &models.RetentionPolicy{KeepN: 1, KeepRegex: `v1\.0\..*`, OlderThan: 2*day + time.Hour},
+			expected: []string{"v1.1.0", "dev-b"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deleted, err := Evaluate(test.policy, tags, now)
+			require.NoError(t, err)
+			if test.expected == nil {
+				require.Empty(t, deleted)
+				return
+			}
+			require.Equal(t, test.expected, tagNames(deleted))
+		})
+	}
+}
+
+func TestEvaluate_InvalidRegex(t *testing.T) {
+	_, err := Evaluate(&models.RetentionPolicy{KeepRegex: "("}, nil, time.Now())
+	require.Error(t, err)
+}
+
+func TestEvaluate_TieBreaker(t *testing.T) {
+	now := time.Now()
+	tags := models.TagDetails{
+		{Name: "b", CreatedAt: now},
+		{Name: "a", CreatedAt: now},
+		{Name: "c", CreatedAt: now},
+	}
+
+	deleted, err := Evaluate(&models.RetentionPolicy{KeepN: 1}, tags, now)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c", "b"}, tagNames(deleted))
+}
@@ -1,6 +1,7 @@
 package registry
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,16 +15,23 @@ import (
 
 	"github.com/jszwec/csvutil"
 
+	"github.com/docker/distribution"
 	"github.com/docker/distribution/configuration"
 	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/notifications"
+	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/datastore"
 	"github.com/docker/distribution/registry/datastore/migrations"
 	"github.com/docker/distribution/registry/storage"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/registry/storage/driver/factory"
+	storagemiddleware "github.com/docker/distribution/registry/storage/driver/middleware"
 	"github.com/docker/distribution/registry/storage/inventory"
+	"github.com/docker/distribution/registry/storage/ocilayout"
 	"github.com/docker/distribution/version"
 	"github.com/docker/libtrust"
 	"github.com/olekukonko/tablewriter"
+	"github.com/opencontainers/go-digest"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -33,11 +41,15 @@ func init() {
 	RootCmd.AddCommand(GCCmd)
 	RootCmd.AddCommand(DBCmd)
 	RootCmd.AddCommand(InventoryCmd)
+	RootCmd.AddCommand(RepositoryCmd)
 	RootCmd.Flags().BoolVarP(&showVersion, "version", "v", false, "show the version and exit")
 
 	GCCmd.Flags().BoolVarP(&dryRun, "dry-run", "d", false, "do everything except remove the blobs")
 	GCCmd.Flags().BoolVarP(&removeUntagged, "delete-untagged", "m", false, "delete manifests that are not currently referenced via tag")
 	GCCmd.Flags().StringVarP(&debugAddr, "debug-server", "s", "", "run a pprof debug server at <address:port>")
+	GCCmd.Flags().IntVarP(&maxParallelBlobDeletes, "max-parallel-blob-deletes", "b", 1, "maximum number of blob delete batches to process concurrently during the sweep stage")
+	GCCmd.Flags().IntVarP(&markWorkers, "workers", "w", 1, "maximum number of repositories to mark concurrently during the mark stage")
+	GCCmd.Flags().StringVarP(&reportPath, "report", "r", "", "write a JSON report of the blobs and manifests eligible for deletion to <path>, use - for stdout")
 
 	MigrateCmd.AddCommand(MigrateVersionCmd)
 	MigrateStatusCmd.Flags().BoolVarP(&upToDateCheck, "up-to-date", "u", false, "check if all known migrations are applied")
@@ -47,6 +59,9 @@ func init() {
 	MigrateUpCmd.Flags().VarP(nullableInt{&maxNumMigrations}, "limit", "n", "limit the number of migrations (all by default)")
 	MigrateUpCmd.Flags().BoolVarP(&skipPostDeployment, "skip-post-deployment", "s", false, "do not apply post deployment migrations")
 	MigrateCmd.AddCommand(MigrateUpCmd)
+	MigrateUpPostCmd.Flags().BoolVarP(&dryRun, "dry-run", "d", false, "do not commit changes to the database")
+	MigrateUpPostCmd.Flags().VarP(nullableInt{&maxNumMigrations}, "limit", "n", "limit the number of migrations (all by default)")
+	MigrateCmd.AddCommand(MigrateUpPostCmd)
 	MigrateDownCmd.Flags().BoolVarP(&force, "force", "f", false, "no confirmation message")
 	MigrateDownCmd.Flags().BoolVarP(&dryRun, "dry-run", "d", false, "do not commit changes to the database")
 	MigrateDownCmd.Flags().VarP(nullableInt{&maxNumMigrations}, "limit", "n", "limit the number of migrations (all by default)")
@@ -62,8 +77,22 @@ func init() {
 	ImportCmd.Flags().BoolVarP(&requireEmptyDatabase, "require-empty-database", "e", false, "abort import if the database is not empty")
 	ImportCmd.Flags().BoolVarP(&preImport, "pre-import", "p", false, "import immutable data to speed up a following full import, may only be used in conjunction with the `--repository` option")
 
+	DBCmd.AddCommand(DBStatsCmd)
+	DBStatsCmd.Flags().BoolVarP(&exactCount, "exact", "e", false, "count the rows of each table instead of using estimates, this may be slow for large tables")
+	DBCmd.AddCommand(DBCheckOrphansCmd)
+	DBCheckOrphansCmd.Flags().IntVarP(&orphansLimit, "limit", "n", 100, "maximum number of orphaned rows of each kind to list")
+	DBCmd.AddCommand(DBLookupCmd)
+
 	InventoryCmd.Flags().StringVarP(&format, "format", "f", "text", "which format to write output to, text output produces an additional summary for convenience, options: text, json, csv")
 	InventoryCmd.Flags().BoolVarP(&countTags, "tag-count", "t", true, "count repository tags, set this to false to increase inventory speed")
+
+	RepositoryCmd.AddCommand(RepositoryExportCmd)
+	RepositoryExportCmd.Flags().StringVarP(&repoPath, "repository", "r", "", "path of the repository to export (required)")
+	RepositoryExportCmd.Flags().StringSliceVarP(&exportTags, "tag", "t", nil, "export a specific tag, may be repeated (all by default)")
+	RepositoryExportCmd.Flags().StringVarP(&layoutPath, "file", "f", "-", "write the OCI image layout tarball to <path>, use - for stdout")
+	RepositoryCmd.AddCommand(RepositoryImportCmd)
+	RepositoryImportCmd.Flags().StringVarP(&repoPath, "repository", "r", "", "path of the repository to import into (required)")
+	RepositoryImportCmd.Flags().StringVarP(&layoutPath, "file", "f", "-", "read the OCI image layout tarball from <path>, use - for stdin")
 }
 
 // Command flag vars
@@ -76,7 +105,10 @@ var (
 	importDanglingBlobs     bool
 	importDanglingManifests bool
 	maxNumMigrations        *int
+	maxParallelBlobDeletes  int
+	markWorkers             int
 	removeUntagged          bool
+	reportPath              string
 	repoPath                string
 	showVersion             bool
 	skipPostDeployment      bool
@@ -84,9 +116,67 @@ var (
 	preImport               bool
 	format                  string
 	countTags               bool
+	exportTags              []string
+	layoutPath              string
+	exactCount              bool
+	orphansLimit            int
+)
+
+var (
+	parallelwalkKey       = "parallelwalk"
+	maxwalkconcurrencyKey = "maxwalkconcurrency"
 )
 
-var parallelwalkKey = "parallelwalk"
+// boundedWalkDrivers are the storage drivers that honor the maxwalkconcurrency parameter during parallel walks.
+var boundedWalkDrivers = map[string]bool{
+	"s3":         true,
+	"s3aws":      true,
+	"gcs":        true,
+	"filesystem": true,
+}
+
+// maintenanceStorageParameters returns a copy of the storage driver parameters with the settings of the optional
+// `storage.maintenance.walk` section applied on top. This allows tuning the walk parallelism of offline commands, such
+// as garbage-collect and inventory, without affecting the behavior of the registry API.
+func maintenanceStorageParameters(config *configuration.Configuration) (configuration.Parameters, error) {
+	parameters := make(configuration.Parameters)
+	for k, v := range config.Storage.Parameters() {
+		parameters[k] = v
+	}
+
+	mc, ok := config.Storage["maintenance"]
+	if !ok {
+		return parameters, nil
+	}
+	v, ok := mc["walk"]
+	if !ok {
+		return parameters, nil
+	}
+	walk, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("walk config key must contain additional keys")
+	}
+
+	if v, ok := walk["parallel"]; ok {
+		parallel, ok := v.(bool)
+		if !ok {
+			return nil, errors.New("walk's parallel config key must have a boolean value")
+		}
+		parameters[parallelwalkKey] = parallel
+	}
+	if v, ok := walk["maxconcurrency"]; ok {
+		maxConcurrency, ok := v.(int)
+		if !ok || maxConcurrency < 1 {
+			return nil, errors.New("walk's maxconcurrency config key must have a positive integer value")
+		}
+		if !boundedWalkDrivers[config.Storage.Type()] {
+			return nil, fmt.Errorf("walk's maxconcurrency config key is not supported by the %s storage driver", config.Storage.Type())
+		}
+		parameters[maxwalkconcurrencyKey] = maxConcurrency
+	}
+
+	return parameters, nil
+}
 
 // nullableInt implements spf13/pflag#Value as a custom nullable integer to capture spf13/cobra command flags.
 // https://pkg.go.dev/github.com/spf13/pflag?tab=doc#Value
@@ -141,13 +231,18 @@ var GCCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
+		parameters, err := maintenanceStorageParameters(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configuration error: %v\n", err)
+			os.Exit(1)
+		}
+
 		maxParallelManifestGets := 1
-		parameters := config.Storage.Parameters()
 		if parameters[parallelwalkKey] == true {
 			maxParallelManifestGets = 10
 		}
 
-		driver, err := factory.Create(config.Storage.Type(), parameters)
+		driver, err := newStorageDriver(config, parameters)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "failed to construct %s driver: %v", config.Storage.Type(), err)
 			os.Exit(1)
@@ -160,6 +255,7 @@ var GCCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
+		logrus.Debugf("marking a maximum of %d repositories in parallel during the mark phase", markWorkers)
 		logrus.Debugf("getting a maximum of %d manifests in parallel per repository during the mark phase", maxParallelManifestGets)
 
 		k, err := libtrust.GenerateECP256PrivateKey()
@@ -183,11 +279,47 @@ var GCCmd = &cobra.Command{
 			}()
 		}
 
-		err = storage.MarkAndSweep(ctx, driver, registry, storage.GCOpts{
-			DryRun:                  dryRun,
-			RemoveUntagged:          removeUntagged,
-			MaxParallelManifestGets: maxParallelManifestGets,
-		})
+		opts := storage.GCOpts{
+			DryRun:                     dryRun,
+			RemoveUntagged:             removeUntagged,
+			MaxParallelManifestGets:    maxParallelManifestGets,
+			MaxParallelRepositoryMarks: markWorkers,
+			MaxParallelBlobDeletes:     maxParallelBlobDeletes,
+		}
+
+		switch reportPath {
+		case "":
+		case "-":
+			opts.Report = os.Stdout
+		default:
+			f, err := os.Create(reportPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to create report file: %v", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			opts.Report = f
+		}
+
+		var eventSink *notifications.BatchingSink
+		if cfg := config.Notifications.GCEvents; cfg.Enabled && !dryRun {
+			sinks := notifications.NewEndpointSinks(config.Notifications.Endpoints)
+			eventSink = notifications.NewBatchingSink(notifications.NewBroadcaster(sinks...), cfg.MaxBatchSize, cfg.FlushInterval)
+
+			hostname, err := os.Hostname()
+			if err != nil {
+				logrus.WithError(err).Warn("unable to determine hostname for GC events source")
+			}
+			opts.EventListener = notifications.NewGCBridge(notifications.SourceRecord{Addr: hostname}, eventSink)
+		}
+
+		err = storage.MarkAndSweep(ctx, driver, registry, opts)
+		if eventSink != nil {
+			// flush pending events and wait for them to be delivered to all endpoints, even if GC failed midway
+			if err := eventSink.Close(); err != nil {
+				logrus.WithError(err).Error("failed to close GC events sink")
+			}
+		}
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "failed to garbage collect: %v", err)
 			os.Exit(1)
@@ -220,46 +352,66 @@ var MigrateUpCmd = &cobra.Command{
 	Short: "Apply up migrations",
 	Long:  "Apply up migrations",
 	Run: func(cmd *cobra.Command, args []string) {
-		config, err := resolveConfiguration(args, configuration.WithoutStorageValidation())
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "configuration error: %v\n", err)
-			cmd.Usage()
-			os.Exit(1)
+		var opts []migrations.MigratorOption
+		if skipPostDeployment {
+			opts = append(opts, migrations.SkipPostDeployment)
 		}
+		migrateUp(cmd, args, opts...)
+	},
+}
 
-		if maxNumMigrations == nil {
-			var all int
-			maxNumMigrations = &all
-		} else if *maxNumMigrations < 1 {
-			fmt.Fprintf(os.Stderr, "limit must be greater than or equal to 1")
-			os.Exit(1)
-		}
+// MigrateUpPostCmd is the `up-post` sub-command of `database migrate` that applies post deployment migrations only.
+var MigrateUpPostCmd = &cobra.Command{
+	Use:   "up-post",
+	Short: "Apply post deployment up migrations",
+	Long: "Apply post deployment up migrations. Fails if there are pending pre deployment migrations, which must be " +
+		"applied first with `up --skip-post-deployment`.",
+	Run: func(cmd *cobra.Command, args []string) {
+		migrateUp(cmd, args, migrations.PostDeploymentOnly)
+	},
+}
 
-		db, err := dbFromConfig(config)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to construct database connection: %v", err)
-			os.Exit(1)
-		}
+func migrateUp(cmd *cobra.Command, args []string, opts ...migrations.MigratorOption) {
+	config, err := resolveConfiguration(args, configuration.WithoutStorageValidation())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "configuration error: %v\n", err)
+		cmd.Usage()
+		os.Exit(1)
+	}
 
-		m := migrations.NewMigrator(db.DB)
-		if skipPostDeployment {
-			migrations.SkipPostDeployment(m)
-		}
+	if maxNumMigrations == nil {
+		var all int
+		maxNumMigrations = &all
+	} else if *maxNumMigrations < 1 {
+		fmt.Fprintf(os.Stderr, "limit must be greater than or equal to 1")
+		os.Exit(1)
+	}
 
-		plan, err := m.UpNPlan(*maxNumMigrations)
-		if len(plan) > 0 {
-			fmt.Println(strings.Join(plan, "\n"))
-		}
+	db, err := dbFromConfig(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to construct database connection: %v", err)
+		os.Exit(1)
+	}
 
-		if !dryRun {
-			n, err := m.UpN(*maxNumMigrations)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "failed to run database migrations: %v", err)
-				os.Exit(1)
-			}
-			fmt.Printf("OK: applied %d migrations\n", n)
+	m := migrations.NewMigrator(db.DB, opts...)
+
+	plan, err := m.UpNPlan(*maxNumMigrations)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to plan database migrations: %v\n", err)
+		os.Exit(1)
+	}
+	if len(plan) > 0 {
+		fmt.Println(strings.Join(plan, "\n"))
+	}
+
+	if !dryRun {
+		n, err := m.UpN(*maxNumMigrations)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run database migrations: %v", err)
+			os.Exit(1)
 		}
-	},
+		fmt.Printf("OK: applied %d migrations\n", n)
+	}
 }
 
 var MigrateDownCmd = &cobra.Command{
@@ -449,7 +601,7 @@ var ImportCmd = &cobra.Command{
 			logrus.Info("the 'parallelwalk' configuration parameter has been disabled")
 		}
 
-		driver, err := factory.Create(config.Storage.Type(), parameters)
+		driver, err := newStorageDriver(config, parameters)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "failed to construct %s driver: %v", config.Storage.Type(), err)
 			os.Exit(1)
@@ -520,7 +672,7 @@ var ImportCmd = &cobra.Command{
 				os.Exit(1)
 			}
 
-			destDriver, err := factory.Create(config.Storage.Type(), destParameters)
+			destDriver, err := newStorageDriver(config, destParameters)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "failed to construct %s driver for blob transfer: %v", config.Storage.Type(), err)
 				os.Exit(1)
@@ -554,6 +706,268 @@ var ImportCmd = &cobra.Command{
 	},
 }
 
+// DBStatsCmd is the `stats` sub-command of `database` that shows the number of rows and disk usage of each table.
+var DBStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show table statistics",
+	Long: "Show the number of rows and disk usage of each table.\n" +
+		"Row counts are estimated from the database statistics unless the --exact option is used.",
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := resolveConfiguration(args, configuration.WithoutStorageValidation())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configuration error: %v\n", err)
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		db, err := dbFromConfig(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to construct database connection: %v", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+
+		tt, err := datastore.NewInspector(db).TableStats(dcontext.Background(), exactCount)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to collect table statistics: %v", err)
+			os.Exit(1)
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Table", "Rows", "Size"})
+		table.SetColWidth(80)
+
+		var totalSize int64
+		for _, t := range tt {
+			table.Append([]string{t.Name, strconv.FormatInt(t.Rows, 10), formatBytes(t.Size)})
+			totalSize += t.Size
+		}
+		table.SetFooter([]string{"", "Total", formatBytes(totalSize)})
+
+		table.Render()
+	},
+}
+
+// DBCheckOrphansCmd is the `check-orphans` sub-command of `database` that finds rows that are not expected to exist.
+var DBCheckOrphansCmd = &cobra.Command{
+	Use:   "check-orphans",
+	Short: "Find orphaned rows",
+	Long: "Find blobs that are not linked to any repository and are not queued for review by the online garbage collector,\n" +
+		"as well as manifests whose repository does not exist. Exits with a non-zero status if any is found.",
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := resolveConfiguration(args, configuration.WithoutStorageValidation())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configuration error: %v\n", err)
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		db, err := dbFromConfig(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to construct database connection: %v", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+
+		ctx := dcontext.Background()
+		s := datastore.NewInspector(db)
+
+		blobsCount, err := s.CountUnlinkedBlobs(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to count unlinked blobs: %v", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Unlinked blobs: %d\n", blobsCount)
+		if blobsCount > 0 {
+			bb, err := s.FindUnlinkedBlobs(ctx, orphansLimit)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to find unlinked blobs: %v", err)
+				os.Exit(1)
+			}
+
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Digest", "Media Type", "Size", "Created At"})
+			table.SetColWidth(80)
+			for _, b := range bb {
+				table.Append([]string{b.Digest.String(), b.MediaType, formatBytes(b.Size), b.CreatedAt.UTC().String()})
+			}
+			table.Render()
+		}
+
+		manifestsCount, err := s.CountManifestsWithoutRepository(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to count manifests without repository: %v", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Manifests without repository: %d\n", manifestsCount)
+		if manifestsCount > 0 {
+			mm, err := s.FindManifestsWithoutRepository(ctx, orphansLimit)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to find manifests without repository: %v", err)
+				os.Exit(1)
+			}
+
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Repository ID", "Digest", "Media Type", "Created At"})
+			table.SetColWidth(80)
+			for _, m := range mm {
+				table.Append([]string{strconv.FormatInt(m.RepositoryID, 10), m.Digest.String(), m.MediaType, m.CreatedAt.UTC().String()})
+			}
+			table.Render()
+		}
+
+		if blobsCount > 0 || manifestsCount > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// DBLookupCmd is the `lookup` sub-command of `database` that shows the details of a repository or digest.
+var DBLookupCmd = &cobra.Command{
+	Use:   "lookup <path|digest> [config]",
+	Short: "Look up a repository or digest",
+	Long: "Look up a repository by path, showing its details, or a digest, showing the corresponding blob, the repositories\n" +
+		"to which it is linked and the manifests with that digest.",
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := resolveConfiguration(args[1:], configuration.WithoutStorageValidation())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configuration error: %v\n", err)
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		db, err := dbFromConfig(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to construct database connection: %v", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+
+		ctx := dcontext.Background()
+		if d, err := digest.Parse(args[0]); err == nil {
+			err = lookupDigest(ctx, db, d)
+		} else {
+			err = lookupRepository(ctx, db, args[0])
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to look up %q: %v", args[0], err)
+			os.Exit(1)
+		}
+	},
+}
+
+func lookupRepository(ctx context.Context, db datastore.Queryer, path string) error {
+	rStore := datastore.NewRepositoryStore(db)
+	r, err := rStore.FindByPath(ctx, path)
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		return errors.New("repository not found")
+	}
+
+	tagsCount, err := rStore.TagsCount(ctx, r)
+	if err != nil {
+		return err
+	}
+	manifestsCount, err := rStore.ManifestsCount(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetColWidth(80)
+	table.AppendBulk([][]string{
+		{"ID", strconv.FormatInt(r.ID, 10)},
+		{"Namespace ID", strconv.FormatInt(r.NamespaceID, 10)},
+		{"Path", r.Path},
+		{"Created At", r.CreatedAt.UTC().String()},
+		{"Archived", strconv.FormatBool(r.Archived)},
+		{"Tags", strconv.Itoa(tagsCount)},
+		{"Manifests", strconv.Itoa(manifestsCount)},
+	})
+	if r.UpdatedAt.Valid {
+		table.Append([]string{"Updated At", r.UpdatedAt.Time.UTC().String()})
+	}
+	table.Render()
+
+	return nil
+}
+
+func lookupDigest(ctx context.Context, db datastore.Queryer, d digest.Digest) error {
+	b, err := datastore.NewBlobStore(db).FindByDigest(ctx, d)
+	if err != nil {
+		return err
+	}
+	if b == nil {
+		fmt.Println("Blob: not found")
+	} else {
+		fmt.Println("Blob:")
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Digest", "Media Type", "Size", "Created At"})
+		table.SetColWidth(80)
+		table.Append([]string{b.Digest.String(), b.MediaType, formatBytes(b.Size), b.CreatedAt.UTC().String()})
+		table.Render()
+	}
+
+	s := datastore.NewInspector(db)
+	rr, err := s.FindRepositoriesLinkingBlob(ctx, d)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Repositories linking blob: %d\n", len(rr))
+	if len(rr) > 0 {
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Repository", "ID"})
+		table.SetColWidth(80)
+		for _, r := range rr {
+			table.Append([]string{r.Path, strconv.FormatInt(r.ID, 10)})
+		}
+		table.Render()
+	}
+
+	mm, err := s.FindManifestsByDigest(ctx, d)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Manifests: %d\n", len(mm))
+	if len(mm) > 0 {
+		rStore := datastore.NewRepositoryStore(db)
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Repository", "Media Type", "Created At"})
+		table.SetColWidth(80)
+		for _, m := range mm {
+			path := fmt.Sprintf("<unknown repository %d>", m.RepositoryID)
+			r, err := rStore.FindByID(ctx, m.RepositoryID)
+			if err != nil {
+				return err
+			}
+			if r != nil {
+				path = r.Path
+			}
+			table.Append([]string{path, m.MediaType, m.CreatedAt.UTC().String()})
+		}
+		table.Render()
+	}
+
+	return nil
+}
+
+// formatBytes formats a number of bytes in a human-readable way using binary units (e.g. 1.5 MiB).
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
 // InventoryCmd is a registry subcommand that collects registry data.
 var InventoryCmd = &cobra.Command{
 	Use:   "inventory <config>",
@@ -567,8 +981,13 @@ var InventoryCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		parameters := config.Storage.Parameters()
-		driver, err := factory.Create(config.Storage.Type(), parameters)
+		parameters, err := maintenanceStorageParameters(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configuration error: %v\n", err)
+			os.Exit(1)
+		}
+
+		driver, err := newStorageDriver(config, parameters)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "failed to construct %s driver: %v", config.Storage.Type(), err)
 			os.Exit(1)
@@ -657,3 +1076,203 @@ var InventoryCmd = &cobra.Command{
 		}
 	},
 }
+
+// RepositoryCmd is the `repository` sub-command of `registry` that manages individual repositories.
+var RepositoryCmd = &cobra.Command{
+	Use:   "repository",
+	Short: "Manages individual repositories",
+	Long:  "Manages individual repositories",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+// RepositoryExportCmd is the `export` sub-command of `repository` that exports a repository to an OCI image layout
+// tarball.
+var RepositoryExportCmd = &cobra.Command{
+	Use:   "export <config>",
+	Short: "Export a repository to an OCI image layout tarball",
+	Long: "Export a repository to an OCI image layout tarball.\n" +
+		"By default, all tags are exported. Individual tags may be exported via the --tag option.\n" +
+		"The repository is read from the filesystem metadata in the storage backend.",
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := resolveConfiguration(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configuration error: %v\n", err)
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		ctx, registry, repo := repositoryCmdSetup(cmd, config)
+
+		w := os.Stdout
+		if layoutPath != "-" {
+			w, err = os.Create(layoutPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to create %s: %v", layoutPath, err)
+				os.Exit(1)
+			}
+		}
+
+		named, err := reference.WithName(repo)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid repository path %q: %v", repo, err)
+			os.Exit(1)
+		}
+		r, err := registry.Repository(ctx, named)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to construct repository: %v", err)
+			os.Exit(1)
+		}
+
+		if err := ocilayout.Export(ctx, r, exportTags, w); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to export repository: %v", err)
+			os.Exit(1)
+		}
+		if err := w.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write %s: %v", layoutPath, err)
+			os.Exit(1)
+		}
+	},
+}
+
+// RepositoryImportCmd is the `import` sub-command of `repository` that imports a repository from an OCI image layout
+// tarball.
+var RepositoryImportCmd = &cobra.Command{
+	Use:   "import <config>",
+	Short: "Import a repository from an OCI image layout tarball",
+	Long: "Import a repository from an OCI image layout tarball.\n" +
+		"Manifests are tagged as per their org.opencontainers.image.ref.name annotation in the layout index.\n" +
+		"If the metadata database is enabled, the imported repository is also recorded in the database.",
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := resolveConfiguration(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configuration error: %v\n", err)
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		ctx, registry, repo := repositoryCmdSetup(cmd, config)
+
+		var db *datastore.DB
+		if config.Database.Enabled {
+			db, err = dbFromConfig(config)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to construct database connection: %v", err)
+				os.Exit(1)
+			}
+
+			m := migrations.NewMigrator(db.DB, migrations.SkipPostDeployment)
+			pending, err := m.HasPending()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to check database migrations status: %v", err)
+				os.Exit(1)
+			}
+			if pending {
+				fmt.Fprintf(os.Stderr, "there are pending database migrations, use the 'registry database migrate' CLI "+
+					"command to check and apply them")
+				os.Exit(1)
+			}
+		}
+
+		var rd io.Reader = os.Stdin
+		if layoutPath != "-" {
+			f, err := os.Open(layoutPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to open %s: %v", layoutPath, err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			rd = f
+		}
+
+		named, err := reference.WithName(repo)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid repository path %q: %v", repo, err)
+			os.Exit(1)
+		}
+		r, err := registry.Repository(ctx, named)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to construct repository: %v", err)
+			os.Exit(1)
+		}
+
+		tags, err := ocilayout.Import(ctx, r, rd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to import repository: %v", err)
+			os.Exit(1)
+		}
+
+		if db != nil {
+			if err := datastore.NewImporter(db, registry).Import(ctx, repo); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to import repository metadata into the database: %v", err)
+				os.Exit(1)
+			}
+		}
+
+		for _, tag := range tags {
+			fmt.Fprintln(os.Stdout, tag)
+		}
+	},
+}
+
+// repositoryCmdSetup configures logging and constructs the registry for the sub-commands of `repository`, returning
+// the path of the target repository.
+func repositoryCmdSetup(cmd *cobra.Command, config *configuration.Configuration) (context.Context, distribution.Namespace, string) {
+	if repoPath == "" {
+		fmt.Fprintln(os.Stderr, "the --repository option is required")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	parameters := config.Storage.Parameters()
+	if parameters[parallelwalkKey] == true {
+		parameters[parallelwalkKey] = false
+		logrus.Info("the 'parallelwalk' configuration parameter has been disabled")
+	}
+
+	driver, err := newStorageDriver(config, parameters)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to construct %s driver: %v", config.Storage.Type(), err)
+		os.Exit(1)
+	}
+
+	ctx := dcontext.Background()
+	ctx, err = configureLogging(ctx, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to configure logging with config: %s", err)
+		os.Exit(1)
+	}
+
+	k, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		fmt.Fprint(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	registry, err := storage.NewRegistry(ctx, driver, storage.Schema1SigningKey(k))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to construct registry: %v", err)
+		os.Exit(1)
+	}
+
+	return ctx, registry, repoPath
+}
+
+// newStorageDriver constructs the configured storage driver, wrapped with the configured storage middleware. Offline
+// commands must see stored content the same way the registry does, which matters for middleware such as encryption.
+func newStorageDriver(config *configuration.Configuration, parameters map[string]interface{}) (storagedriver.StorageDriver, error) {
+	driver, err := factory.Create(config.Storage.Type(), parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mw := range config.Middleware["storage"] {
+		driver, err = storagemiddleware.Get(mw.Name, mw.Options, driver)
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure storage middleware (%s): %v", mw.Name, err)
+		}
+	}
+
+	return driver, nil
+}
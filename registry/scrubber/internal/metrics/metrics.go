@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/docker/distribution/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	verificationCounter  *prometheus.CounterVec
+	verifiedBytesCounter prometheus.Counter
+	runDurationHist      prometheus.Histogram
+
+	timeSince = time.Since // for test purposes only
+)
+
+const (
+	subsystem = "scrubber"
+
+	resultLabel = "result"
+
+	// ResultOK is the result of a verification for which the recomputed digest matched.
+	ResultOK = "ok"
+	// ResultCorrupt is the result of a verification for which the recomputed digest did not match.
+	ResultCorrupt = "corrupt"
+	// ResultError is the result of a verification that failed to complete.
+	ResultError = "error"
+
+	verificationsTotalName = "verifications_total"
+	verificationsTotalDesc = "A counter of blob integrity verifications."
+
+	verifiedBytesTotalName = "verified_bytes_total"
+	verifiedBytesTotalDesc = "A counter for blob bytes read from the storage backend to verify their integrity."
+
+	runDurationName = "run_duration_seconds"
+	runDurationDesc = "A histogram of durations of full scrubber runs."
+)
+
+func init() {
+	verificationCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      verificationsTotalName,
+			Help:      verificationsTotalDesc,
+		},
+		[]string{resultLabel},
+	)
+
+	verifiedBytesCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      verifiedBytesTotalName,
+			Help:      verifiedBytesTotalDesc,
+		},
+	)
+
+	runDurationHist = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      runDurationName,
+			Help:      runDurationDesc,
+			// 1m to 24h
+			Buckets: []float64{60, 300, 900, 1800, 3600, 7200, 10800, 21600, 43200, 86400},
+		},
+	)
+
+	prometheus.MustRegister(verificationCounter)
+	prometheus.MustRegister(verifiedBytesCounter)
+	prometheus.MustRegister(runDurationHist)
+}
+
+func Verification(result string) {
+	verificationCounter.WithLabelValues(result).Inc()
+}
+
+func VerifiedBytes(bytes int64) {
+	verifiedBytesCounter.Add(float64(bytes))
+}
+
+func Run() func() {
+	start := time.Now()
+	return func() {
+		runDurationHist.Observe(timeSince(start).Seconds())
+	}
+}
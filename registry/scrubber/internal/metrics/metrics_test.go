@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func mockTimeSince(d time.Duration) func() {
+	bkp := timeSince
+	timeSince = func(_ time.Time) time.Duration { return d }
+	return func() { timeSince = bkp }
+}
+
+func TestVerification(t *testing.T) {
+	Verification(ResultOK)
+	Verification(ResultOK)
+	Verification(ResultCorrupt)
+	Verification(ResultError)
+
+	var expected bytes.Buffer
+	expected.WriteString(`
+# HELP registry_scrubber_verifications_total A counter of blob integrity verifications.
+# TYPE registry_scrubber_verifications_total counter
+registry_scrubber_verifications_total{result="corrupt"} 1
+registry_scrubber_verifications_total{result="error"} 1
+registry_scrubber_verifications_total{result="ok"} 2
+`)
+	fullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, verificationsTotalName)
+
+	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, &expected, fullName)
+	require.NoError(t, err)
+}
+
+func TestVerifiedBytes(t *testing.T) {
+	VerifiedBytes(512)
+	VerifiedBytes(1024)
+
+	var expected bytes.Buffer
+	expected.WriteString(`
+# HELP registry_scrubber_verified_bytes_total A counter for blob bytes read from the storage backend to verify their integrity.
+# TYPE registry_scrubber_verified_bytes_total counter
+registry_scrubber_verified_bytes_total 1536
+`)
+	fullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, verifiedBytesTotalName)
+
+	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, &expected, fullName)
+	require.NoError(t, err)
+}
+
+func TestRun(t *testing.T) {
+	restore := mockTimeSince(90 * time.Second)
+	defer restore()
+
+	report := Run()
+	report()
+
+	var expected bytes.Buffer
+	expected.WriteString(`
+# HELP registry_scrubber_run_duration_seconds A histogram of durations of full scrubber runs.
+# TYPE registry_scrubber_run_duration_seconds histogram
+registry_scrubber_run_duration_seconds_bucket{le="60"} 0
+registry_scrubber_run_duration_seconds_bucket{le="300"} 1
+registry_scrubber_run_duration_seconds_bucket{le="900"} 1
+registry_scrubber_run_duration_seconds_bucket{le="1800"} 1
+registry_scrubber_run_duration_seconds_bucket{le="3600"} 1
+registry_scrubber_run_duration_seconds_bucket{le="7200"} 1
+registry_scrubber_run_duration_seconds_bucket{le="10800"} 1
+registry_scrubber_run_duration_seconds_bucket{le="21600"} 1
+registry_scrubber_run_duration_seconds_bucket{le="43200"} 1
+registry_scrubber_run_duration_seconds_bucket{le="86400"} 1
+registry_scrubber_run_duration_seconds_bucket{le="+Inf"} 1
+registry_scrubber_run_duration_seconds_sum 90
+registry_scrubber_run_duration_seconds_count 1
+`)
+	fullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, runDurationName)
+
+	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, &expected, fullName)
+	require.NoError(t, err)
+}
@@ -0,0 +1,307 @@
+package scrubber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/notifications"
+	"github.com/docker/distribution/registry/scrubber/internal/metrics"
+	"github.com/docker/distribution/registry/storage/driver"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+	"gitlab.com/gitlab-org/labkit/correlation"
+	"golang.org/x/time/rate"
+)
+
+const (
+	componentKey = "component"
+	name         = "registry.scrubber.Scrubber"
+
+	// readChunkSize is the size of the chunks in which blobs are read from the storage backend. It's also the minimum
+	// burst allowed by the rate limiter.
+	readChunkSize = 32 << 10
+)
+
+var (
+	defaultInterval          = 24 * time.Hour
+	defaultSampleRatio       = 0.1
+	defaultMaxBytesPerSecond = int64(10 << 20)
+
+	// for test purposes (mocking)
+	randFloat64 = rand.Float64
+)
+
+// BlobStore provides access to all blobs in storage, regardless of the repositories they belong to.
+type BlobStore interface {
+	distribution.BlobEnumerator
+	Open(ctx context.Context, dgst digest.Digest) (distribution.ReadSeekCloser, error)
+}
+
+// Scrubber periodically verifies the integrity of a random sample of the blobs in storage, detecting corruption (such
+// as bit rot) before clients attempt to pull them. Each sampled blob is streamed from the storage backend and its
+// digest recomputed. Reads are rate limited to avoid saturating the storage backend. Mismatches are reported through
+// metrics, logs and notifications, if a listener is set. Corrupt blobs are not modified or deleted.
+type Scrubber struct {
+	blobs             BlobStore
+	listener          notifications.ScrubListener
+	logger            dcontext.Logger
+	interval          time.Duration
+	sampleRatio       float64
+	maxBytesPerSecond int64
+	limiter           *rate.Limiter
+}
+
+// Option provides functional options for New.
+type Option func(*Scrubber)
+
+// WithLogger sets the logger.
+func WithLogger(l dcontext.Logger) Option {
+	return func(s *Scrubber) {
+		s.logger = l
+	}
+}
+
+// WithListener sets the listener notified of corrupt blobs. Nothing is notified by default.
+func WithListener(l notifications.ScrubListener) Option {
+	return func(s *Scrubber) {
+		s.listener = l
+	}
+}
+
+// WithInterval sets the amount of time to wait after each run before starting the next one. Defaults to 24 hours.
+func WithInterval(d time.Duration) Option {
+	return func(s *Scrubber) {
+		s.interval = d
+	}
+}
+
+// WithSampleRatio sets the ratio, between 0 and 1, of blobs verified on each run. Defaults to 0.1.
+func WithSampleRatio(r float64) Option {
+	return func(s *Scrubber) {
+		s.sampleRatio = r
+	}
+}
+
+// WithMaxBytesPerSecond sets the maximum rate at which blobs are read from the storage backend. Defaults to 10 MiB/s.
+func WithMaxBytesPerSecond(n int64) Option {
+	return func(s *Scrubber) {
+		s.maxBytesPerSecond = n
+	}
+}
+
+func (s *Scrubber) applyDefaults() {
+	if s.logger == nil {
+		defaultLogger := logrus.New()
+		defaultLogger.SetOutput(ioutil.Discard)
+		s.logger = defaultLogger
+	}
+	if s.interval == 0 {
+		s.interval = defaultInterval
+	}
+	if s.sampleRatio == 0 {
+		s.sampleRatio = defaultSampleRatio
+	}
+	if s.maxBytesPerSecond == 0 {
+		s.maxBytesPerSecond = defaultMaxBytesPerSecond
+	}
+}
+
+// New creates a new Scrubber.
+func New(blobs BlobStore, opts ...Option) *Scrubber {
+	s := &Scrubber{blobs: blobs}
+	s.applyDefaults()
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	burst := int(s.maxBytesPerSecond)
+	if burst < readChunkSize {
+		burst = readChunkSize
+	}
+	s.limiter = rate.NewLimiter(rate.Limit(s.maxBytesPerSecond), burst)
+	s.logger = s.logger.WithField(componentKey, name)
+
+	return s
+}
+
+// Start starts the Scrubber. This is a blocking call that runs in a loop until the provided context is canceled. Each
+// run is followed by a sleep of the configured interval, regardless of whether it succeeded or not.
+func (s *Scrubber) Start(ctx context.Context) error {
+	s.logger.WithFields(logrus.Fields{
+		"interval_s":           s.interval.Seconds(),
+		"sample_ratio":         s.sampleRatio,
+		"max_bytes_per_second": s.maxBytesPerSecond,
+	}).Info("starting blob scrubber")
+
+	for {
+		if _, err := s.Run(ctx); err != nil {
+			if errors.Is(err, context.Canceled) {
+				s.logger.Warn("context cancelled, exiting")
+				return err
+			}
+			s.logger.WithError(err).Error("failed run")
+		}
+
+		select {
+		case <-ctx.Done():
+			s.logger.Warn("context cancelled, exiting")
+			return ctx.Err()
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// Result is the outcome of a Run.
+type Result struct {
+	// Verified is the number of blobs whose digest matched.
+	Verified int
+	// Corrupt is the digests of the blobs whose digest did not match.
+	Corrupt []digest.Digest
+	// Failed is the number of blobs that could not be verified.
+	Failed int
+}
+
+// Run samples blobs from storage and verifies their integrity. Blobs deleted in the meantime are skipped and blobs that
+// failed to be verified are counted as such. An error is only returned if sampling blobs failed or the context was
+// canceled.
+func (s *Scrubber) Run(ctx context.Context) (*Result, error) {
+	ctx = s.injectCorrelationID(ctx)
+	log := dcontext.GetLogger(ctx)
+	defer metrics.Run()()
+
+	log.Info("sampling blobs")
+	sample, err := s.sample(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("sampling blobs: %w", err)
+	}
+	log.WithField("sampled_blobs", len(sample)).Info("verifying blobs")
+
+	res := &Result{}
+	for _, desc := range sample {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+
+		l := log.WithFields(logrus.Fields{"digest": desc.Digest, "size": desc.Size})
+
+		ok, err := s.verify(ctx, desc)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return res, ctx.Err()
+		case err != nil:
+			var pnfErr driver.PathNotFoundError
+			if errors.As(err, &pnfErr) || errors.Is(err, distribution.ErrBlobUnknown) {
+				l.Debug("blob no longer exists, skipping")
+				continue
+			}
+			res.Failed++
+			metrics.Verification(metrics.ResultError)
+			l.WithError(err).Warn("failed to verify blob")
+		case ok:
+			res.Verified++
+			metrics.Verification(metrics.ResultOK)
+		default:
+			res.Corrupt = append(res.Corrupt, desc.Digest)
+			metrics.Verification(metrics.ResultCorrupt)
+			l.Error("blob is corrupt, digest mismatch")
+			if s.listener != nil {
+				if err := s.listener.BlobCorrupted(desc); err != nil {
+					l.WithError(err).Error("failed to notify corrupt blob")
+				}
+			}
+		}
+	}
+
+	log.WithFields(logrus.Fields{
+		"verified_blobs": res.Verified,
+		"corrupt_blobs":  len(res.Corrupt),
+		"failed_blobs":   res.Failed,
+	}).Info("blob verification completed")
+
+	return res, nil
+}
+
+// sample enumerates all blobs in storage, selecting each one with a probability equal to the sample ratio.
+func (s *Scrubber) sample(ctx context.Context) ([]distribution.Descriptor, error) {
+	var (
+		mu     sync.Mutex
+		sample []distribution.Descriptor
+	)
+
+	// the ingester may be called concurrently
+	err := s.blobs.Enumerate(ctx, func(desc distribution.Descriptor) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if s.sampleRatio >= 1 || randFloat64() < s.sampleRatio {
+			sample = append(sample, desc)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sample, nil
+}
+
+// verify streams the blob described by desc from storage, returning whether its content matches its digest.
+func (s *Scrubber) verify(ctx context.Context, desc distribution.Descriptor) (bool, error) {
+	if err := desc.Digest.Validate(); err != nil {
+		return false, err
+	}
+
+	rc, err := s.blobs.Open(ctx, desc.Digest)
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close()
+
+	verifier := desc.Digest.Verifier()
+	n, err := io.CopyBuffer(verifier, &rateLimitedReader{ctx: ctx, r: rc, limiter: s.limiter}, make([]byte, readChunkSize))
+	metrics.VerifiedBytes(n)
+	if err != nil {
+		return false, err
+	}
+
+	return verifier.Verified(), nil
+}
+
+func (s *Scrubber) injectCorrelationID(ctx context.Context) context.Context {
+	id := correlation.SafeRandomID()
+	ctx = correlation.ContextWithCorrelation(ctx, id)
+
+	log := s.logger.WithField("correlation_id", id)
+	return dcontext.WithLogger(ctx, log)
+}
+
+// rateLimitedReader is an io.Reader that waits for the limiter to allow each read, in bytes, before returning.
+type rateLimitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+// Read implements io.Reader.
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > r.limiter.Burst() {
+		p = p[:r.limiter.Burst()]
+	}
+
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,164 @@
+package scrubber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+type testListener struct {
+	corrupted []distribution.Descriptor
+	err       error
+}
+
+func (l *testListener) BlobCorrupted(desc distribution.Descriptor) error {
+	l.corrupted = append(l.corrupted, desc)
+	return l.err
+}
+
+type testEnv struct {
+	ctx    context.Context
+	driver *inmemory.Driver
+	blobs  BlobStore
+	repo   distribution.Repository
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	ctx := context.Background()
+	d := inmemory.New()
+	reg, err := storage.NewRegistry(ctx, d)
+	require.NoError(t, err)
+
+	name, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	repo, err := reg.Repository(ctx, name)
+	require.NoError(t, err)
+
+	blobs, ok := reg.Blobs().(BlobStore)
+	require.True(t, ok)
+
+	return &testEnv{ctx: ctx, driver: d, blobs: blobs, repo: repo}
+}
+
+func (env *testEnv) putBlob(t *testing.T, content string) distribution.Descriptor {
+	t.Helper()
+
+	desc, err := env.repo.Blobs(env.ctx).Put(env.ctx, "application/octet-stream", []byte(content))
+	require.NoError(t, err)
+	return desc
+}
+
+func (env *testEnv) corruptBlob(t *testing.T, dgst digest.Digest) {
+	t.Helper()
+
+	p := fmt.Sprintf("/docker/registry/v2/blobs/%s/%s/%s/data", dgst.Algorithm(), dgst.Hex()[:2], dgst.Hex())
+	require.NoError(t, env.driver.PutContent(env.ctx, p, []byte("bit rot")))
+}
+
+func TestScrubber_Run(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.putBlob(t, "a")
+	b := env.putBlob(t, "b")
+	env.putBlob(t, "c")
+	env.corruptBlob(t, b.Digest)
+
+	l := &testListener{}
+	s := New(env.blobs, WithSampleRatio(1), WithListener(l))
+
+	res, err := s.Run(env.ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, res.Verified)
+	require.Equal(t, []digest.Digest{b.Digest}, res.Corrupt)
+	require.Zero(t, res.Failed)
+
+	require.Len(t, l.corrupted, 1)
+	require.Equal(t, b.Digest, l.corrupted[0].Digest)
+}
+
+func TestScrubber_Run_ListenerError(t *testing.T) {
+	env := newTestEnv(t)
+
+	a := env.putBlob(t, "a")
+	env.corruptBlob(t, a.Digest)
+
+	// failing to notify must not fail the run
+	l := &testListener{err: errors.New("foo")}
+	s := New(env.blobs, WithSampleRatio(1), WithListener(l))
+
+	res, err := s.Run(env.ctx)
+	require.NoError(t, err)
+	require.Equal(t, []digest.Digest{a.Digest}, res.Corrupt)
+	require.Len(t, l.corrupted, 1)
+}
+
+func TestScrubber_Run_Sample(t *testing.T) {
+	env := newTestEnv(t)
+
+	for i := 0; i < 4; i++ {
+		env.putBlob(t, fmt.Sprintf("blob-%d", i))
+	}
+
+	// select every other blob
+	bkp := randFloat64
+	defer func() { randFloat64 = bkp }()
+	var calls int
+	randFloat64 = func() float64 {
+		calls++
+		if calls%2 == 0 {
+			return 0
+		}
+		return 0.9
+	}
+
+	s := New(env.blobs, WithSampleRatio(0.5))
+
+	res, err := s.Run(env.ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, res.Verified)
+	require.Empty(t, res.Corrupt)
+}
+
+func TestScrubber_Run_RateLimited(t *testing.T) {
+	env := newTestEnv(t)
+
+	// the limiter allows an initial burst of readChunkSize bytes, so reading two chunks at one chunk per second must
+	// take about one second
+	content := make([]byte, 2*readChunkSize)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	env.putBlob(t, string(content))
+
+	s := New(env.blobs, WithSampleRatio(1), WithMaxBytesPerSecond(readChunkSize))
+
+	start := time.Now()
+	res, err := s.Run(env.ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Verified)
+	require.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond)
+}
+
+func TestScrubber_Run_Canceled(t *testing.T) {
+	env := newTestEnv(t)
+	env.putBlob(t, "a")
+
+	ctx, cancel := context.WithCancel(env.ctx)
+	cancel()
+
+	s := New(env.blobs, WithSampleRatio(1))
+
+	_, err := s.Run(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -10,6 +10,7 @@ import (
 	"path"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/reference"
@@ -531,6 +532,48 @@ func TestLayerUploadZeroLength(t *testing.T) {
 	simpleUpload(t, bs, []byte{}, digestSha256Empty)
 }
 
+func TestLayerUploadResumeExpired(t *testing.T) {
+	ctx := context.Background()
+	imageName, _ := reference.WithName("foo/bar")
+	driver := testdriver.New()
+	registry, err := NewRegistry(ctx, driver, BlobDescriptorCacheProvider(memory.NewInMemoryBlobDescriptorCacheProvider()), UploadMaxAge(time.Hour))
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+	repository, err := registry.Repository(ctx, imageName)
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+	bs := repository.Blobs(ctx)
+
+	fresh, err := bs.Create(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error starting layer upload: %s", err)
+	}
+	if _, err := bs.Resume(ctx, fresh.ID()); err != nil {
+		t.Fatalf("unexpected error resuming fresh upload: %v", err)
+	}
+
+	stale, err := bs.Create(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error starting layer upload: %s", err)
+	}
+
+	// backdate the upload start time, the modification time of the upload files is irrelevant
+	startedAtPath, err := pathFor(uploadStartedAtPathSpec{name: imageName.Name(), id: stale.ID()})
+	if err != nil {
+		t.Fatalf("unexpected error building startedat path: %v", err)
+	}
+	startedAt := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	if err := driver.PutContent(ctx, startedAtPath, []byte(startedAt)); err != nil {
+		t.Fatalf("unexpected error writing startedat file: %v", err)
+	}
+
+	if _, err := bs.Resume(ctx, stale.ID()); err != distribution.ErrBlobUploadUnknown {
+		t.Fatalf("expected %v resuming expired upload, got %v", distribution.ErrBlobUploadUnknown, err)
+	}
+}
+
 func simpleUpload(t *testing.T, bs distribution.BlobIngester, blob []byte, expectedDigest digest.Digest) {
 	ctx := context.Background()
 	wr, err := bs.Create(ctx)
@@ -3,8 +3,10 @@ package storage
 import (
 	"context"
 	"errors"
+	"io"
 
 	"github.com/docker/distribution"
+	dcontext "github.com/docker/distribution/context"
 	"github.com/docker/distribution/registry/storage/driver"
 	"github.com/opencontainers/go-digest"
 )
@@ -45,7 +47,7 @@ func (s *BlobTransferService) Transfer(ctx context.Context, dgst digest.Digest)
 		return nil
 	}
 
-	if err = s.src.TransferTo(ctx, s.dest, blobDataPath, blobDataPath); err != nil {
+	if err = s.transfer(ctx, blobDataPath); err != nil {
 		tErr := distribution.ErrBlobTransferFailed{Digest: dgst, Reason: err}
 
 		// Blob transfer encountered a problem after modifying destination, attempt to cleanup.
@@ -65,3 +67,94 @@ func (s *BlobTransferService) Transfer(ctx context.Context, dgst digest.Digest)
 
 	return nil
 }
+
+// transfer copies the content at path from the source to the destination driver. The transfer is delegated to the
+// source driver, which may be able to copy the content server side, unless the drivers are of different kinds or the
+// source driver does not support transfers, in which case the content is streamed through the registry.
+func (s *BlobTransferService) transfer(ctx context.Context, path string) error {
+	if s.src.Name() == s.dest.Name() {
+		err := s.src.TransferTo(ctx, s.dest, path, path)
+		if !errors.As(err, &driver.ErrUnsupportedMethod{}) {
+			return err
+		}
+	}
+
+	return s.stream(ctx, path)
+}
+
+// stream copies the content at path from the source to the destination driver through the registry.
+func (s *BlobTransferService) stream(ctx context.Context, path string) error {
+	src, err := s.src.Reader(ctx, path, 0)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := s.dest.Writer(ctx, path, false)
+	if err != nil {
+		return driver.PartialTransferError{SourcePath: path, DestinationPath: path, Cause: err}
+	}
+
+	if _, err = io.Copy(dest, src); err != nil {
+		dest.Cancel()
+		return driver.PartialTransferError{SourcePath: path, DestinationPath: path, Cause: err}
+	}
+
+	if err = dest.Commit(); err != nil {
+		return driver.PartialTransferError{SourcePath: path, DestinationPath: path, Cause: err}
+	}
+
+	return nil
+}
+
+// Verify checks that the content of the blob with digest dgst in the destination driver matches its digest. See
+// VerifyBlob for details.
+func (s *BlobTransferService) Verify(ctx context.Context, dgst digest.Digest) error {
+	return VerifyBlob(ctx, s.dest, dgst)
+}
+
+// VerifyBlob checks that the content of the blob with digest dgst stored by driver d matches its digest. The checksum
+// computed by the storage backend is used whenever available, otherwise the content is read back from the storage
+// backend. A distribution.ErrBlobInvalidDigest error is returned if the content does not match.
+func VerifyBlob(ctx context.Context, d driver.StorageDriver, dgst digest.Digest) error {
+	blobDataPath, err := pathFor(blobDataPathSpec{digest: dgst})
+	if err != nil {
+		return err
+	}
+
+	if dgst.Algorithm() == digest.Canonical {
+		if c, ok := d.(driver.Checksummer); ok {
+			actual, err := c.Checksum(ctx, blobDataPath)
+			switch err.(type) {
+			case nil:
+				if actual != dgst {
+					return distribution.ErrBlobInvalidDigest{
+						Digest:   dgst,
+						Reason:   errors.New("content checksum does not match digest"),
+						Computed: actual,
+					}
+				}
+				return nil
+			case driver.ErrUnsupportedMethod, driver.ChecksumUnavailableError:
+			default:
+				dcontext.GetLogger(ctx).WithError(err).Warn("failed to obtain backend checksum, reading data back for verification")
+			}
+		}
+	}
+
+	rc, err := d.Reader(ctx, blobDataPath, 0)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	verifier := dgst.Verifier()
+	if _, err := io.Copy(verifier, rc); err != nil {
+		return err
+	}
+	if !verifier.Verified() {
+		return distribution.ErrBlobInvalidDigest{Digest: dgst, Reason: errors.New("content does not match digest")}
+	}
+
+	return nil
+}
@@ -163,6 +163,66 @@ func TestTransferBlobPartialTransferError(t *testing.T) {
 	require.True(t, errors.As(e.Reason, &driver.PartialTransferError{}))
 }
 
+// namedDriver overrides the name of a storage driver, to simulate drivers of different kinds.
+type namedDriver struct {
+	driver.StorageDriver
+	name string
+}
+
+func (d *namedDriver) Name() string {
+	return d.name
+}
+
+func TestTransferBlobAcrossDriverKinds(t *testing.T) {
+	source := newEnv(t, "src/image-a")
+	target := newEnv(t, "dest/image-a")
+
+	srcDesc := uploadRandomLayer(t, source)
+
+	// drivers of different kinds can not transfer content natively, so it must be streamed instead
+	bts, err := storage.NewBlobTransferService(source.driver, &namedDriver{target.driver, "other"})
+	require.NoError(t, err)
+	require.NoError(t, bts.Transfer(source.ctx, srcDesc.Digest))
+
+	p, err := target.driver.GetContent(target.ctx, blobDataPathFromDigest(srcDesc.Digest))
+	require.NoError(t, err)
+	require.Equal(t, srcDesc.Digest, digest.FromBytes(p))
+
+	require.NoError(t, bts.Verify(target.ctx, srcDesc.Digest))
+}
+
+func TestVerifyBlob(t *testing.T) {
+	source := newEnv(t, "src/image-a")
+	target := newEnv(t, "dest/image-a")
+
+	srcDesc := uploadRandomLayer(t, source)
+
+	bts, err := storage.NewBlobTransferService(source.driver, target.driver)
+	require.NoError(t, err)
+	require.NoError(t, bts.Transfer(source.ctx, srcDesc.Digest))
+	require.NoError(t, bts.Verify(target.ctx, srcDesc.Digest))
+
+	// corrupt the blob on the target
+	err = target.driver.PutContent(target.ctx, blobDataPathFromDigest(srcDesc.Digest), []byte("corrupted"))
+	require.NoError(t, err)
+
+	err = bts.Verify(target.ctx, srcDesc.Digest)
+	e := &distribution.ErrBlobInvalidDigest{}
+	require.True(t, errors.As(err, e))
+	require.Equal(t, srcDesc.Digest, e.Digest)
+}
+
+func TestVerifyBlobMissing(t *testing.T) {
+	source := newEnv(t, "src/image-a")
+	target := newEnv(t, "dest/image-a")
+
+	bts, err := storage.NewBlobTransferService(source.driver, target.driver)
+	require.NoError(t, err)
+
+	err = bts.Verify(target.ctx, digest.FromString("fake-digest"))
+	require.True(t, errors.As(err, &driver.PathNotFoundError{}))
+}
+
 func uploadLayer(t *testing.T, e *env, layer io.ReadSeeker, dgst digest.Digest) distribution.Descriptor {
 	t.Helper()
 
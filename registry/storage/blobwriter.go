@@ -67,12 +67,29 @@ func (bw *blobWriter) Commit(ctx context.Context, desc distribution.Descriptor)
 	}
 
 	bw.Close()
-	desc.Size = bw.Size()
 
-	canonical, err := bw.validateBlob(ctx, desc)
+	parts, err := bw.getStoredParts(ctx)
 	if err != nil {
 		return distribution.Descriptor{}, err
 	}
+	dataPath := bw.path
+	if len(parts) > 0 {
+		if dataPath, err = bw.assembleParts(ctx, parts); err != nil {
+			return distribution.Descriptor{}, err
+		}
+	}
+
+	canonical, err := bw.validateBlob(ctx, desc, dataPath)
+	if err != nil {
+		if len(parts) > 0 {
+			if err := bw.discardParts(ctx); err != nil {
+				dcontext.GetLogger(ctx).WithError(err).Error("error discarding upload parts")
+			}
+		}
+		return distribution.Descriptor{}, err
+	}
+	// the assembled data replaces the sequentially written data only once validated
+	bw.path = dataPath
 
 	if err := bw.moveBlob(ctx, canonical); err != nil {
 		return distribution.Descriptor{}, err
@@ -165,9 +182,9 @@ func (bw *blobWriter) Close() error {
 	return bw.fileWriter.Close()
 }
 
-// validateBlob checks the data against the digest, returning an error if it
-// does not match. The canonical descriptor is returned.
-func (bw *blobWriter) validateBlob(ctx context.Context, desc distribution.Descriptor) (distribution.Descriptor, error) {
+// validateBlob checks the data at dataPath against the digest, returning an
+// error if it does not match. The canonical descriptor is returned.
+func (bw *blobWriter) validateBlob(ctx context.Context, desc distribution.Descriptor, dataPath string) (distribution.Descriptor, error) {
 	var (
 		verified, fullHash bool
 		canonical          digest.Digest
@@ -184,7 +201,7 @@ func (bw *blobWriter) validateBlob(ctx context.Context, desc distribution.Descri
 	var size int64
 
 	// Stat the on disk file
-	if fi, err := bw.driver.Stat(ctx, bw.path); err != nil {
+	if fi, err := bw.driver.Stat(ctx, dataPath); err != nil {
 		switch err := err.(type) {
 		case storagedriver.PathNotFoundError:
 			// NOTE(stevvooe): We really don't care if the file is
@@ -197,7 +214,7 @@ func (bw *blobWriter) validateBlob(ctx context.Context, desc distribution.Descri
 		}
 	} else {
 		if fi.IsDir() {
-			return distribution.Descriptor{}, fmt.Errorf("unexpected directory at upload location %q", bw.path)
+			return distribution.Descriptor{}, fmt.Errorf("unexpected directory at upload location %q", dataPath)
 		}
 
 		size = fi.Size()
@@ -216,7 +233,10 @@ func (bw *blobWriter) validateBlob(ctx context.Context, desc distribution.Descri
 	// TODO(stevvooe): This section is very meandering. Need to be broken down
 	// to be a lot more clear.
 
-	if err := bw.resumeDigest(ctx); err == nil {
+	if dataPath != bw.path {
+		// The data was assembled from parts, the digester only saw the sequentially written data.
+		fullHash = true
+	} else if err := bw.resumeDigest(ctx); err == nil {
 		canonical = bw.digester.Digest()
 
 		if canonical.Algorithm() == desc.Digest.Algorithm() {
@@ -246,15 +266,27 @@ func (bw *blobWriter) validateBlob(ctx context.Context, desc distribution.Descri
 			verified = desc.Digest == canonical
 		}
 
-		// If the check based on size fails, we fall back to the slowest of
-		// paths. We may be able to make the size-based check a stronger
-		// guarantee, so this may be defensive.
-		if !verified {
+		// If the check based on size fails, try to rely on the checksum
+		// computed by the storage backend, if supported, which avoids reading
+		// the data back.
+		var backendChecked bool
+		if !verified && digest.Canonical == desc.Digest.Algorithm() {
+			if dgst, ok := bw.backendChecksum(ctx, dataPath); ok {
+				canonical = dgst
+				verified = desc.Digest == canonical
+				backendChecked = true
+			}
+		}
+
+		// Otherwise, we fall back to the slowest of paths. We may be able to
+		// make the size-based check a stronger guarantee, so this may be
+		// defensive.
+		if !verified && !backendChecked {
 			digester := digest.Canonical.Digester()
 			verifier := desc.Digest.Verifier()
 
 			// Read the file from the backend driver and validate it.
-			fr, err := newFileReader(ctx, bw.driver, bw.path, desc.Size)
+			fr, err := newFileReader(ctx, bw.driver, dataPath, desc.Size)
 			if err != nil {
 				return distribution.Descriptor{}, err
 			}
@@ -276,11 +308,14 @@ func (bw *blobWriter) validateBlob(ctx context.Context, desc distribution.Descri
 			map[interface{}]interface{}{
 				"canonical": canonical,
 				"provided":  desc.Digest,
-			}, "canonical", "provided").
+				"size":      size,
+			}, "canonical", "provided", "size").
 			Errorf("canonical digest does match provided digest")
 		return distribution.Descriptor{}, distribution.ErrBlobInvalidDigest{
-			Digest: desc.Digest,
-			Reason: fmt.Errorf("content does not match digest"),
+			Digest:   desc.Digest,
+			Reason:   fmt.Errorf("content does not match digest"),
+			Computed: canonical,
+			Size:     size,
 		}
 	}
 
@@ -294,6 +329,28 @@ func (bw *blobWriter) validateBlob(ctx context.Context, desc distribution.Descri
 	return desc, nil
 }
 
+// backendChecksum returns the SHA-256 digest of the data at dataPath as
+// computed by the storage backend. The returned bool is false if the storage
+// driver does not support backend checksums or was unable to provide one.
+func (bw *blobWriter) backendChecksum(ctx context.Context, dataPath string) (digest.Digest, bool) {
+	c, ok := bw.driver.(storagedriver.Checksummer)
+	if !ok {
+		return "", false
+	}
+
+	dgst, err := c.Checksum(ctx, dataPath)
+	if err != nil {
+		switch err.(type) {
+		case storagedriver.ErrUnsupportedMethod, storagedriver.ChecksumUnavailableError:
+		default:
+			dcontext.GetLogger(ctx).WithError(err).Warn("failed to obtain backend checksum, reading data back for verification")
+		}
+		return "", false
+	}
+
+	return dgst, true
+}
+
 // moveBlob moves the data into its final, hash-qualified destination,
 // identified by dgst. The layer should be validated before commencing the
 // move.
@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+
+	"github.com/docker/distribution"
+	dcontext "github.com/docker/distribution/context"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+var _ distribution.BlobPartWriter = &blobWriter{}
+
+// WritePart writes the content of r as the part of the blob starting at offset. Parts are stored next to the upload
+// data and are only appended to it on Commit, so they can be written out of order and concurrently.
+func (bw *blobWriter) WritePart(ctx context.Context, offset int64, r io.Reader) (int64, error) {
+	dcontext.GetLogger(ctx).Debug("(*blobWriter).WritePart")
+
+	if offset < 0 {
+		return 0, fmt.Errorf("invalid part offset %d", offset)
+	}
+
+	partPath, err := pathFor(uploadPartPathSpec{
+		name:   bw.blobStore.repository.Named().Name(),
+		id:     bw.id,
+		offset: offset,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	fw, err := bw.driver.Writer(ctx, partPath, false)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(fw, r)
+	if err == nil {
+		err = fw.Commit()
+	}
+	if err != nil {
+		if cancelErr := fw.Cancel(); cancelErr != nil {
+			dcontext.GetLogger(ctx).WithError(cancelErr).Error("error canceling upload part")
+		}
+		return n, err
+	}
+
+	return n, fw.Close()
+}
+
+type uploadPart struct {
+	offset int64
+	size   int64
+	path   string
+}
+
+// getStoredParts returns the parts written out of order for this upload, sorted by offset.
+func (bw *blobWriter) getStoredParts(ctx context.Context) ([]uploadPart, error) {
+	partsPathPrefix, err := pathFor(uploadPartPathSpec{
+		name: bw.blobStore.repository.Named().Name(),
+		id:   bw.id,
+		list: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := bw.driver.List(ctx, partsPathPrefix)
+	if err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+			return nil, err
+		}
+		// Treat PathNotFoundError as no parts.
+		paths = nil
+	}
+
+	parts := make([]uploadPart, 0, len(paths))
+	for _, p := range paths {
+		// The suffix should be the offset.
+		offset, err := strconv.ParseInt(path.Base(p), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse offset from upload part path %q: %w", p, err)
+		}
+
+		fi, err := bw.driver.Stat(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+
+		parts = append(parts, uploadPart{offset: offset, size: fi.Size(), path: p})
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].offset < parts[j].offset })
+
+	return parts, nil
+}
+
+// ContiguousSize implements distribution.BlobPartWriter.
+func (bw *blobWriter) ContiguousSize(ctx context.Context) (int64, error) {
+	parts, err := bw.getStoredParts(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	size := bw.Size()
+	for _, p := range parts {
+		if p.offset != size {
+			break
+		}
+		size += p.size
+	}
+
+	return size, nil
+}
+
+// assembledDataPath returns the path of the temporary object in which the upload data and its parts are assembled.
+func (bw *blobWriter) assembledDataPath() string {
+	return path.Join(path.Dir(bw.path), "assembled")
+}
+
+// assembleParts assembles the sequentially written upload data followed by the given parts, written out of order,
+// into a temporary object whose path is returned. The upload data and the parts are left untouched, so the assembled
+// object can be validated before replacing them. The parts must follow the upload data and each other without gaps or
+// overlaps. The storage backend assembles the parts server side if supported, otherwise their content is copied.
+func (bw *blobWriter) assembleParts(ctx context.Context, parts []uploadPart) (string, error) {
+	size := bw.Size()
+	sourcePaths := make([]string, 0, len(parts)+1)
+	for _, p := range parts {
+		switch {
+		case p.offset > size:
+			return "", distribution.ErrBlobUploadIncomplete{
+				Offset: size,
+				Reason: fmt.Errorf("missing %d bytes before part at offset %d", p.offset-size, p.offset),
+			}
+		case p.offset < size:
+			return "", distribution.ErrBlobUploadIncomplete{
+				Offset: p.offset,
+				Reason: fmt.Errorf("part overlaps %d bytes of previous content", size-p.offset),
+			}
+		}
+		size += p.size
+		sourcePaths = append(sourcePaths, p.path)
+	}
+
+	// no data file exists if nothing was written sequentially
+	if fi, err := bw.driver.Stat(ctx, bw.path); err == nil {
+		if fi.Size() > 0 {
+			sourcePaths = append([]string{bw.path}, sourcePaths...)
+		}
+	} else if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+		return "", err
+	}
+
+	// leftovers of a previous attempt must not be appended to
+	assembledPath := bw.assembledDataPath()
+	if err := bw.driver.Delete(ctx, assembledPath); err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+			return "", err
+		}
+	}
+
+	if c, ok := bw.driver.(storagedriver.Concatenator); ok {
+		err := c.Concat(ctx, assembledPath, sourcePaths)
+		if err == nil {
+			return assembledPath, nil
+		}
+		if _, ok := err.(storagedriver.ErrUnsupportedMethod); !ok {
+			return "", err
+		}
+	}
+
+	return assembledPath, bw.copyParts(ctx, assembledPath, sourcePaths)
+}
+
+// copyParts copies the content at sourcePaths, in order, into a new file at assembledPath.
+func (bw *blobWriter) copyParts(ctx context.Context, assembledPath string, sourcePaths []string) error {
+	fw, err := bw.driver.Writer(ctx, assembledPath, false)
+	if err != nil {
+		return err
+	}
+
+	copyFrom := func(p string) error {
+		rc, err := bw.driver.Reader(ctx, p, 0)
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		_, err = io.Copy(fw, rc)
+		return err
+	}
+
+	for _, p := range sourcePaths {
+		if err = copyFrom(p); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = fw.Commit()
+	}
+	if err != nil {
+		if cancelErr := fw.Cancel(); cancelErr != nil {
+			dcontext.GetLogger(ctx).WithError(cancelErr).Error("error canceling assembled upload data")
+		}
+		return err
+	}
+
+	return fw.Close()
+}
+
+// discardParts deletes the parts of the upload and the object in which they were assembled, if any. This is done when
+// the assembled content fails validation, so that a retried commit never assembles the same parts twice. Otherwise,
+// they are removed along with the rest of the upload resources.
+func (bw *blobWriter) discardParts(ctx context.Context) error {
+	partsPath, err := pathFor(uploadPartPathSpec{
+		name: bw.blobStore.repository.Named().Name(),
+		id:   bw.id,
+		list: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, p := range []string{partsPath, bw.assembledDataPath()} {
+		if err := bw.driver.Delete(ctx, p); err != nil {
+			if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -21,6 +21,7 @@ import (
 	digest "github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
 )
 
 type env struct {
@@ -205,7 +206,12 @@ func testInvalidLayerUpload(t *testing.T, env *env) {
 
 	_, err = wr.Commit(env.ctx, distribution.Descriptor{Digest: dgst})
 	if assert.Error(t, err) {
-		assert.Equal(t, distribution.ErrBlobInvalidDigest{Digest: dgst, Reason: errors.New("content does not match digest")}, err)
+		assert.Equal(t, distribution.ErrBlobInvalidDigest{
+			Digest:   dgst,
+			Reason:   errors.New("content does not match digest"),
+			Computed: digest.FromString("test layer"),
+			Size:     int64(len("test layer")),
+		}, err)
 	}
 
 	_, err = blobService.Stat(env.ctx, dgst)
@@ -213,3 +219,261 @@ func testInvalidLayerUpload(t *testing.T, env *env) {
 		assert.Equal(t, distribution.ErrBlobUnknown, err)
 	}
 }
+
+// checksumDriver wraps a storage driver, reporting a fixed checksum as if computed by the storage backend and counting
+// the number of times uploaded data is read back.
+type checksumDriver struct {
+	driver.StorageDriver
+	checksum  digest.Digest
+	err       error
+	dataReads int
+}
+
+// Checksum implements driver.Checksummer.
+func (d *checksumDriver) Checksum(_ context.Context, _ string) (digest.Digest, error) {
+	return d.checksum, d.err
+}
+
+func (d *checksumDriver) Reader(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
+	if strings.Contains(path, "/_uploads/") {
+		d.dataReads++
+	}
+	return d.StorageDriver.Reader(ctx, path, offset)
+}
+
+// commitResumedUpload uploads content and commits the upload after resuming it. With digest resumption disabled,
+// this requires a full verification of the uploaded data.
+func commitResumedUpload(t *testing.T, d driver.StorageDriver, content []byte) error {
+	t.Helper()
+
+	ctx := context.Background()
+	reg, err := storage.NewRegistry(ctx, d, storage.DisableDigestResumption)
+	require.NoError(t, err)
+	n, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	repo, err := reg.Repository(ctx, n)
+	require.NoError(t, err)
+	bs := repo.Blobs(ctx)
+
+	wr, err := bs.Create(ctx)
+	require.NoError(t, err)
+	_, err = wr.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, wr.Close())
+
+	wr, err = bs.Resume(ctx, wr.ID())
+	require.NoError(t, err)
+	_, err = wr.Commit(ctx, distribution.Descriptor{Digest: digest.FromBytes(content)})
+
+	return err
+}
+
+func TestBlobWriter_Commit_BackendChecksum(t *testing.T) {
+	content := []byte("foo")
+	d := &checksumDriver{StorageDriver: inmemory.New(), checksum: digest.FromBytes(content)}
+
+	require.NoError(t, commitResumedUpload(t, d, content))
+	require.Zero(t, d.dataReads)
+}
+
+func TestBlobWriter_Commit_BackendChecksumMismatch(t *testing.T) {
+	content := []byte("foo")
+	d := &checksumDriver{StorageDriver: inmemory.New(), checksum: digest.FromString("bar")}
+
+	err := commitResumedUpload(t, d, content)
+	require.Equal(t, distribution.ErrBlobInvalidDigest{
+		Digest:   digest.FromBytes(content),
+		Reason:   errors.New("content does not match digest"),
+		Computed: digest.FromString("bar"),
+		Size:     int64(len(content)),
+	}, err)
+	require.Zero(t, d.dataReads)
+}
+
+func TestBlobWriter_Commit_BackendChecksumFallback(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "unsupported", err: driver.ErrUnsupportedMethod{}},
+		{name: "unavailable", err: driver.ChecksumUnavailableError{}},
+		{name: "unknown error", err: errors.New("foo")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := []byte("foo")
+			d := &checksumDriver{StorageDriver: inmemory.New(), err: tt.err}
+
+			require.NoError(t, commitResumedUpload(t, d, content))
+			require.NotZero(t, d.dataReads)
+		})
+	}
+}
+
+// writeParts writes content sequentially followed by parts, in the given order and concurrently, and commits the
+// upload against the digest of want.
+func writeParts(t *testing.T, bs distribution.BlobStore, content string, parts map[int64]string, want string) (distribution.Descriptor, error) {
+	t.Helper()
+
+	ctx := context.Background()
+	wr, err := bs.Create(ctx)
+	require.NoError(t, err)
+	_, err = wr.Write([]byte(content))
+	require.NoError(t, err)
+
+	pw, ok := wr.(distribution.BlobPartWriter)
+	require.True(t, ok)
+
+	var g errgroup.Group
+	for offset, p := range parts {
+		offset, p := offset, p
+		g.Go(func() error {
+			_, err := pw.WritePart(ctx, offset, strings.NewReader(p))
+			return err
+		})
+	}
+	require.NoError(t, g.Wait())
+
+	return wr.Commit(ctx, distribution.Descriptor{Digest: digest.FromString(want)})
+}
+
+func TestBlobWriter_WritePart(t *testing.T) {
+	env := newEnv(t, "foo/bar")
+	bs := env.repo.Blobs(env.ctx)
+
+	desc, err := writeParts(t, bs, "foo", map[int64]string{6: "baz", 3: "bar"}, "foobarbaz")
+	require.NoError(t, err)
+	require.Equal(t, digest.FromString("foobarbaz"), desc.Digest)
+	require.EqualValues(t, 9, desc.Size)
+
+	p, err := bs.Get(env.ctx, desc.Digest)
+	require.NoError(t, err)
+	require.Equal(t, "foobarbaz", string(p))
+}
+
+func TestBlobWriter_WritePart_PartsOnly(t *testing.T) {
+	env := newEnv(t, "foo/bar")
+	bs := env.repo.Blobs(env.ctx)
+
+	desc, err := writeParts(t, bs, "", map[int64]string{0: "foo", 3: "bar"}, "foobar")
+	require.NoError(t, err)
+	require.EqualValues(t, 6, desc.Size)
+}
+
+func TestBlobWriter_WritePart_Incomplete(t *testing.T) {
+	tests := []struct {
+		name   string
+		parts  map[int64]string
+		offset int64
+	}{
+		{name: "gap", parts: map[int64]string{4: "bar"}, offset: 3},
+		{name: "overlap", parts: map[int64]string{2: "bar"}, offset: 2},
+		{name: "overlapping parts", parts: map[int64]string{3: "bar", 5: "baz"}, offset: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newEnv(t, "foo/bar")
+
+			_, err := writeParts(t, env.repo.Blobs(env.ctx), "foo", tt.parts, "foobarbaz")
+			var incomplete distribution.ErrBlobUploadIncomplete
+			require.ErrorAs(t, err, &incomplete)
+			require.Equal(t, tt.offset, incomplete.Offset)
+		})
+	}
+}
+
+func TestBlobWriter_WritePart_DigestMismatch(t *testing.T) {
+	env := newEnv(t, "foo/bar")
+	bs := env.repo.Blobs(env.ctx)
+
+	wr, err := bs.Create(env.ctx)
+	require.NoError(t, err)
+	id := wr.ID()
+	_, err = wr.Write([]byte("foo"))
+	require.NoError(t, err)
+	_, err = wr.(distribution.BlobPartWriter).WritePart(env.ctx, 3, strings.NewReader("bar"))
+	require.NoError(t, err)
+
+	_, err = wr.Commit(env.ctx, distribution.Descriptor{Digest: digest.FromString("foobaz")})
+	var mismatch distribution.ErrBlobInvalidDigest
+	require.ErrorAs(t, err, &mismatch)
+
+	// the parts were discarded and the sequentially written content was left untouched
+	wr, err = bs.Resume(env.ctx, id)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, wr.Size())
+	pw := wr.(distribution.BlobPartWriter)
+	size, err := pw.ContiguousSize(env.ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, size)
+
+	// resending the part does not duplicate it
+	_, err = pw.WritePart(env.ctx, 3, strings.NewReader("bar"))
+	require.NoError(t, err)
+	desc, err := wr.Commit(env.ctx, distribution.Descriptor{Digest: digest.FromString("foobar")})
+	require.NoError(t, err)
+	require.EqualValues(t, 6, desc.Size)
+}
+
+func TestBlobWriter_ContiguousSize(t *testing.T) {
+	env := newEnv(t, "foo/bar")
+
+	wr, err := env.repo.Blobs(env.ctx).Create(env.ctx)
+	require.NoError(t, err)
+	defer wr.Cancel(env.ctx)
+	_, err = wr.Write([]byte("foo"))
+	require.NoError(t, err)
+
+	pw := wr.(distribution.BlobPartWriter)
+	for offset, p := range map[int64]string{3: "bar", 9: "qux"} {
+		_, err := pw.WritePart(env.ctx, offset, strings.NewReader(p))
+		require.NoError(t, err)
+	}
+
+	// the part at offset 9 is not contiguous to the rest of the content
+	size, err := pw.ContiguousSize(env.ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 6, size)
+	require.EqualValues(t, 3, wr.Size())
+}
+
+// concatDriver wraps a storage driver, concatenating content as if done by the storage backend.
+type concatDriver struct {
+	driver.StorageDriver
+	concats int
+}
+
+// Concat implements driver.Concatenator.
+func (d *concatDriver) Concat(ctx context.Context, path string, sourcePaths []string) error {
+	content, err := d.GetContent(ctx, path)
+	if err != nil && !errors.As(err, &driver.PathNotFoundError{}) {
+		return err
+	}
+	for _, p := range sourcePaths {
+		b, err := d.GetContent(ctx, p)
+		if err != nil {
+			return err
+		}
+		content = append(content, b...)
+	}
+	d.concats++
+
+	return d.PutContent(ctx, path, content)
+}
+
+func TestBlobWriter_WritePart_Concat(t *testing.T) {
+	d := &concatDriver{StorageDriver: inmemory.New()}
+	reg, err := storage.NewRegistry(context.Background(), d)
+	require.NoError(t, err)
+	n, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	repo, err := reg.Repository(context.Background(), n)
+	require.NoError(t, err)
+
+	desc, err := writeParts(t, repo.Blobs(context.Background()), "foo", map[int64]string{3: "bar"}, "foobar")
+	require.NoError(t, err)
+	require.EqualValues(t, 6, desc.Size)
+	require.Equal(t, 1, d.concats)
+}
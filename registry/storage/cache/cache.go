@@ -3,11 +3,16 @@
 package cache
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/docker/distribution"
 )
 
+// ErrUnavailable is returned by caches that are known to be unavailable and
+// decided to fail fast instead of attempting the operation.
+var ErrUnavailable = errors.New("cache: unavailable")
+
 // BlobDescriptorCacheProvider provides repository scoped
 // BlobDescriptorService cache instances and a global descriptor cache.
 type BlobDescriptorCacheProvider interface {
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"errors"
 
 	"github.com/docker/distribution"
 	dcontext "github.com/docker/distribution/context"
@@ -79,7 +80,7 @@ func (cbds *cachedBlobStatter) Stat(ctx context.Context, dgst digest.Digest) (di
 		if cbds.tracker != nil {
 			cbds.tracker.Miss()
 		}
-		if err := cbds.cache.SetDescriptor(ctx, dgst, desc); err != nil {
+		if err := cbds.cache.SetDescriptor(ctx, dgst, desc); err != nil && !errors.Is(err, ErrUnavailable) {
 			dcontext.GetLoggerWithField(ctx, "blob", dgst).WithError(err).Error("error from cache setting desc")
 		}
 		// we don't need to return cache error upstream if any. continue returning value from backend
@@ -87,7 +88,13 @@ func (cbds *cachedBlobStatter) Stat(ctx context.Context, dgst digest.Digest) (di
 	}
 
 	// unknown error from cache. just log and error. do not store cache as it may be trigger many set calls
-	dcontext.GetLoggerWithField(ctx, "blob", dgst).WithError(cacheErr).Error("error from cache stat(ing) blob")
+	log := dcontext.GetLoggerWithField(ctx, "blob", dgst).WithError(cacheErr)
+	if errors.Is(cacheErr, ErrUnavailable) {
+		// the cache is known to be down, avoid flooding the logs
+		log.Debug("cache unavailable, skipped stat(ing) blob")
+	} else {
+		log.Error("error from cache stat(ing) blob")
+	}
 	cacheCount.WithValues("Error").Inc(1)
 
 	return desc, nil
@@ -107,7 +114,7 @@ func (cbds *cachedBlobStatter) Clear(ctx context.Context, dgst digest.Digest) er
 }
 
 func (cbds *cachedBlobStatter) SetDescriptor(ctx context.Context, dgst digest.Digest, desc distribution.Descriptor) error {
-	if err := cbds.cache.SetDescriptor(ctx, dgst, desc); err != nil {
+	if err := cbds.cache.SetDescriptor(ctx, dgst, desc); err != nil && !errors.Is(err, ErrUnavailable) {
 		dcontext.GetLoggerWithField(ctx, "blob", dgst).WithError(err).Error("error from cache setting desc")
 	}
 	return nil
@@ -0,0 +1,212 @@
+package memory
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/docker/distribution"
+	prometheus "github.com/docker/distribution/metrics"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/storage/cache"
+	"github.com/docker/go-metrics"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// DefaultLRUMaxEntries is the default maximum number of descriptors held by an LRU blob descriptor cache.
+	DefaultLRUMaxEntries = 100000
+
+	// lruEntryOverhead is a rough estimate of the memory used by each cache entry on top of its variable length
+	// fields, accounting for the list element, map bucket and descriptor struct.
+	lruEntryOverhead = 256
+)
+
+var (
+	// lruEvictionsCounter is the number of descriptors evicted from LRU caches to stay within their bounds
+	lruEvictionsCounter = prometheus.StorageNamespace.NewCounter("cache_inmemory_lru_evictions", "The number of blob descriptors evicted from the in-memory LRU cache")
+	// lruEntriesGauge is the number of descriptors held by LRU caches
+	lruEntriesGauge = prometheus.StorageNamespace.NewGauge("cache_inmemory_lru_entries", "The number of blob descriptors held by the in-memory LRU cache", metrics.Unit(""))
+	// lruSizeGauge is the estimated memory used by descriptors held by LRU caches
+	lruSizeGauge = prometheus.StorageNamespace.NewGauge("cache_inmemory_lru_size", "The estimated memory used by blob descriptors held by the in-memory LRU cache", metrics.Bytes)
+)
+
+// lruKey identifies a cached descriptor. Global descriptors have an empty repo.
+type lruKey struct {
+	repo string
+	dgst digest.Digest
+}
+
+type lruEntry struct {
+	key  lruKey
+	desc distribution.Descriptor
+	size int64
+}
+
+type lruBlobDescriptorCacheProvider struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxSize    int64
+	size       int64
+	ll         *list.List
+	items      map[lruKey]*list.Element
+}
+
+// NewLRUBlobDescriptorCacheProvider returns a new in-memory cache for storing blob descriptor data, holding up to
+// maxEntries descriptors and up to an estimated maxSize bytes, whichever limit is reached first. The least recently
+// used descriptors, either global or repository scoped, are evicted once a limit is reached. If maxEntries is not
+// positive, DefaultLRUMaxEntries is used. If maxSize is not positive, the cache size in bytes is unbounded.
+func NewLRUBlobDescriptorCacheProvider(maxEntries int, maxSize int64) cache.BlobDescriptorCacheProvider {
+	if maxEntries <= 0 {
+		maxEntries = DefaultLRUMaxEntries
+	}
+	if maxSize < 0 {
+		maxSize = 0
+	}
+
+	return &lruBlobDescriptorCacheProvider{
+		maxEntries: maxEntries,
+		maxSize:    maxSize,
+		ll:         list.New(),
+		items:      make(map[lruKey]*list.Element),
+	}
+}
+
+func (c *lruBlobDescriptorCacheProvider) RepositoryScoped(repo string) (distribution.BlobDescriptorService, error) {
+	if _, err := reference.ParseNormalizedNamed(repo); err != nil {
+		return nil, err
+	}
+
+	return &repositoryScopedLRUBlobDescriptorCache{repo: repo, parent: c}, nil
+}
+
+func (c *lruBlobDescriptorCacheProvider) Stat(ctx context.Context, dgst digest.Digest) (distribution.Descriptor, error) {
+	return c.stat(lruKey{dgst: dgst})
+}
+
+func (c *lruBlobDescriptorCacheProvider) Clear(ctx context.Context, dgst digest.Digest) error {
+	c.remove(lruKey{dgst: dgst})
+	return nil
+}
+
+func (c *lruBlobDescriptorCacheProvider) SetDescriptor(ctx context.Context, dgst digest.Digest, desc distribution.Descriptor) error {
+	_, err := c.Stat(ctx, dgst)
+	if err == distribution.ErrBlobUnknown {
+		if dgst.Algorithm() != desc.Digest.Algorithm() && dgst != desc.Digest {
+			// if the digests differ, set the other canonical mapping
+			if err := c.set(lruKey{dgst: desc.Digest}, desc); err != nil {
+				return err
+			}
+		}
+
+		// unknown, just set it
+		return c.set(lruKey{dgst: dgst}, desc)
+	}
+
+	// we already know it, do nothing
+	return err
+}
+
+func (c *lruBlobDescriptorCacheProvider) stat(key lruKey) (distribution.Descriptor, error) {
+	if err := key.dgst.Validate(); err != nil {
+		return distribution.Descriptor{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return distribution.Descriptor{}, distribution.ErrBlobUnknown
+	}
+	c.ll.MoveToFront(el)
+
+	return el.Value.(*lruEntry).desc, nil
+}
+
+func (c *lruBlobDescriptorCacheProvider) set(key lruKey, desc distribution.Descriptor) error {
+	if err := key.dgst.Validate(); err != nil {
+		return err
+	}
+	if err := cache.ValidateDescriptor(desc); err != nil {
+		return err
+	}
+
+	entry := &lruEntry{key: key, desc: desc, size: lruEntrySize(key, desc)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.size += entry.size - el.Value.(*lruEntry).size
+		el.Value = entry
+		c.ll.MoveToFront(el)
+	} else {
+		c.items[key] = c.ll.PushFront(entry)
+		c.size += entry.size
+		lruEntriesGauge.Inc(1)
+		lruSizeGauge.Inc(float64(entry.size))
+	}
+
+	for c.ll.Len() > c.maxEntries || (c.maxSize > 0 && c.size > c.maxSize && c.ll.Len() > 1) {
+		c.removeElement(c.ll.Back())
+		lruEvictionsCounter.Inc(1)
+	}
+
+	return nil
+}
+
+func (c *lruBlobDescriptorCacheProvider) remove(key lruKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// removeElement removes el from the cache. Must be called with c.mu held.
+func (c *lruBlobDescriptorCacheProvider) removeElement(el *list.Element) {
+	entry := c.ll.Remove(el).(*lruEntry)
+	delete(c.items, entry.key)
+	c.size -= entry.size
+	lruEntriesGauge.Dec(1)
+	lruSizeGauge.Dec(float64(entry.size))
+}
+
+// lruEntrySize returns an estimate of the memory used by a cache entry.
+func lruEntrySize(key lruKey, desc distribution.Descriptor) int64 {
+	n := lruEntryOverhead + len(key.repo) + len(key.dgst) + len(desc.Digest) + len(desc.MediaType)
+	for _, u := range desc.URLs {
+		n += len(u)
+	}
+	for k, v := range desc.Annotations {
+		n += len(k) + len(v)
+	}
+
+	return int64(n)
+}
+
+// repositoryScopedLRUBlobDescriptorCache provides the request scoped repository cache, backed by the LRU of its
+// parent.
+type repositoryScopedLRUBlobDescriptorCache struct {
+	repo   string
+	parent *lruBlobDescriptorCacheProvider
+}
+
+func (rslbdc *repositoryScopedLRUBlobDescriptorCache) Stat(ctx context.Context, dgst digest.Digest) (distribution.Descriptor, error) {
+	return rslbdc.parent.stat(lruKey{repo: rslbdc.repo, dgst: dgst})
+}
+
+func (rslbdc *repositoryScopedLRUBlobDescriptorCache) Clear(ctx context.Context, dgst digest.Digest) error {
+	rslbdc.parent.remove(lruKey{repo: rslbdc.repo, dgst: dgst})
+	return nil
+}
+
+func (rslbdc *repositoryScopedLRUBlobDescriptorCache) SetDescriptor(ctx context.Context, dgst digest.Digest, desc distribution.Descriptor) error {
+	if err := rslbdc.parent.set(lruKey{repo: rslbdc.repo, dgst: dgst}, desc); err != nil {
+		return err
+	}
+
+	return rslbdc.parent.SetDescriptor(ctx, dgst, desc)
+}
@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/registry/storage/cache/cachecheck"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLRUBlobInfoCache checks the in memory LRU implementation is working correctly.
+func TestLRUBlobInfoCache(t *testing.T) {
+	cachecheck.CheckBlobDescriptorCache(t, NewLRUBlobDescriptorCacheProvider(0, 0))
+}
+
+func newLRUTestDescriptor(s string) distribution.Descriptor {
+	return distribution.Descriptor{
+		Digest:    digest.FromString(s),
+		Size:      int64(len(s)),
+		MediaType: "application/octet-stream",
+	}
+}
+
+func TestLRUBlobDescriptorCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRUBlobDescriptorCacheProvider(2, 0).(*lruBlobDescriptorCacheProvider)
+
+	a, b, d := newLRUTestDescriptor("a"), newLRUTestDescriptor("b"), newLRUTestDescriptor("d")
+	require.NoError(t, c.SetDescriptor(ctx, a.Digest, a))
+	require.NoError(t, c.SetDescriptor(ctx, b.Digest, b))
+
+	// use a so that b becomes the least recently used
+	_, err := c.Stat(ctx, a.Digest)
+	require.NoError(t, err)
+
+	require.NoError(t, c.SetDescriptor(ctx, d.Digest, d))
+	require.Equal(t, 2, c.ll.Len())
+
+	_, err = c.Stat(ctx, a.Digest)
+	require.NoError(t, err)
+	_, err = c.Stat(ctx, b.Digest)
+	require.Equal(t, distribution.ErrBlobUnknown, err)
+	_, err = c.Stat(ctx, d.Digest)
+	require.NoError(t, err)
+}
+
+func TestLRUBlobDescriptorCache_EvictsOverMaxSize(t *testing.T) {
+	ctx := context.Background()
+	a, b := newLRUTestDescriptor("a"), newLRUTestDescriptor("b")
+	size := lruEntrySize(lruKey{dgst: a.Digest}, a)
+
+	// room for one and a half global entries
+	c := NewLRUBlobDescriptorCacheProvider(0, size+size/2).(*lruBlobDescriptorCacheProvider)
+
+	require.NoError(t, c.SetDescriptor(ctx, a.Digest, a))
+	require.EqualValues(t, size, c.size)
+
+	require.NoError(t, c.SetDescriptor(ctx, b.Digest, b))
+	require.Equal(t, 1, c.ll.Len())
+	require.EqualValues(t, size, c.size)
+
+	_, err := c.Stat(ctx, a.Digest)
+	require.Equal(t, distribution.ErrBlobUnknown, err)
+	_, err = c.Stat(ctx, b.Digest)
+	require.NoError(t, err)
+}
+
+func TestLRUBlobDescriptorCache_RepositoryScoped(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRUBlobDescriptorCacheProvider(0, 0).(*lruBlobDescriptorCacheProvider)
+
+	repo, err := c.RepositoryScoped("foo/bar")
+	require.NoError(t, err)
+
+	a := newLRUTestDescriptor("a")
+	require.NoError(t, repo.SetDescriptor(ctx, a.Digest, a))
+	// one repository scoped and one global entry
+	require.Equal(t, 2, c.ll.Len())
+
+	require.NoError(t, repo.Clear(ctx, a.Digest))
+	require.Equal(t, 1, c.ll.Len())
+	_, err = repo.Stat(ctx, a.Digest)
+	require.Equal(t, distribution.ErrBlobUnknown, err)
+	_, err = c.Stat(ctx, a.Digest)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Clear(ctx, a.Digest))
+	require.Zero(t, c.ll.Len())
+	require.Zero(t, c.size)
+}
@@ -0,0 +1,220 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/docker/distribution"
+	prometheus "github.com/docker/distribution/metrics"
+	"github.com/docker/distribution/registry/storage/cache"
+	"github.com/docker/go-metrics"
+	"github.com/go-redis/redis/v8"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultCircuitBreakerThreshold is the default number of consecutive failures after which the cache is skipped.
+	DefaultCircuitBreakerThreshold = 5
+	// DefaultCircuitBreakerCooldown is the default time in between recovery probes while the cache is skipped.
+	DefaultCircuitBreakerCooldown = 30 * time.Second
+)
+
+var (
+	// availableGauge reports whether the redis cache is available (1) or being skipped (0)
+	availableGauge = prometheus.StorageNamespace.NewGauge("cache_redis_available", "Whether the redis cache is available (1) or skipped due to consecutive failures (0)", metrics.Unit(""))
+	// shortCircuitedCounter is the number of cache operations skipped because redis was unavailable
+	shortCircuitedCounter = prometheus.StorageNamespace.NewCounter("cache_redis_short_circuited", "The number of redis cache operations skipped because redis was unavailable")
+	// tripsCounter is the number of times the redis cache was marked as unavailable
+	tripsCounter = prometheus.StorageNamespace.NewCounter("cache_redis_circuit_breaker_trips", "The number of times the redis cache was marked as unavailable")
+)
+
+func init() {
+	availableGauge.Set(1)
+}
+
+// circuitBreaker tracks consecutive redis connectivity failures. Once threshold consecutive failures are observed, the
+// circuit opens and all operations fail fast with cache.ErrUnavailable, instead of waiting for redis timeouts on every
+// lookup. While open, redis is probed in the background every cooldown, and the circuit closes once a probe succeeds.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	probe     func(context.Context) error
+
+	mu       sync.Mutex
+	failures int
+	open     bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration, probe func(context.Context) error) *circuitBreaker {
+	if threshold <= 0 {
+		threshold = DefaultCircuitBreakerThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultCircuitBreakerCooldown
+	}
+
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		probe:     probe,
+	}
+}
+
+// do runs op unless the circuit is open, in which case cache.ErrUnavailable is returned immediately.
+func (cb *circuitBreaker) do(op func() error) error {
+	cb.mu.Lock()
+	open := cb.open
+	cb.mu.Unlock()
+
+	if open {
+		shortCircuitedCounter.Inc(1)
+		return cache.ErrUnavailable
+	}
+
+	err := op()
+	cb.record(err)
+
+	return err
+}
+
+func (cb *circuitBreaker) record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if !isUnavailableError(err) {
+		cb.failures = 0
+		return
+	}
+
+	cb.failures++
+	if cb.open || cb.failures < cb.threshold {
+		return
+	}
+
+	cb.open = true
+	availableGauge.Set(0)
+	tripsCounter.Inc(1)
+	logrus.WithError(err).WithFields(logrus.Fields{
+		"failures":   cb.failures,
+		"cooldown_s": cb.cooldown.Seconds(),
+	}).Warn("redis cache unavailable, skipping cache until it recovers")
+
+	go cb.probeUntilRecovered()
+}
+
+func (cb *circuitBreaker) probeUntilRecovered() {
+	for {
+		time.Sleep(cb.cooldown)
+
+		ctx, cancel := context.WithTimeout(context.Background(), cb.cooldown)
+		err := cb.probe(ctx)
+		cancel()
+
+		if err != nil {
+			logrus.WithError(err).Debug("redis cache recovery probe failed")
+			continue
+		}
+
+		cb.mu.Lock()
+		cb.open = false
+		cb.failures = 0
+		cb.mu.Unlock()
+
+		availableGauge.Set(1)
+		logrus.Info("redis cache recovered")
+		return
+	}
+}
+
+// isUnavailableError determines whether err denotes a redis connectivity problem, as opposed to a cache miss or an
+// invalid request.
+func isUnavailableError(err error) bool {
+	if err == nil || errors.Is(err, distribution.ErrBlobUnknown) || errors.Is(err, redis.Nil) {
+		return false
+	}
+
+	if errors.Is(err, redis.ErrClosed) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		// go-redis does not export its pool timeout error
+		strings.Contains(err.Error(), "connection pool timeout") {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// circuitBreakerCacheProvider guards a BlobDescriptorCacheProvider with a circuitBreaker.
+type circuitBreakerCacheProvider struct {
+	cache.BlobDescriptorCacheProvider
+	cb *circuitBreaker
+}
+
+func (p *circuitBreakerCacheProvider) Stat(ctx context.Context, dgst digest.Digest) (distribution.Descriptor, error) {
+	var desc distribution.Descriptor
+	err := p.cb.do(func() error {
+		var err error
+		desc, err = p.BlobDescriptorCacheProvider.Stat(ctx, dgst)
+		return err
+	})
+	return desc, err
+}
+
+func (p *circuitBreakerCacheProvider) Clear(ctx context.Context, dgst digest.Digest) error {
+	return p.cb.do(func() error {
+		return p.BlobDescriptorCacheProvider.Clear(ctx, dgst)
+	})
+}
+
+func (p *circuitBreakerCacheProvider) SetDescriptor(ctx context.Context, dgst digest.Digest, desc distribution.Descriptor) error {
+	return p.cb.do(func() error {
+		return p.BlobDescriptorCacheProvider.SetDescriptor(ctx, dgst, desc)
+	})
+}
+
+func (p *circuitBreakerCacheProvider) RepositoryScoped(repo string) (distribution.BlobDescriptorService, error) {
+	s, err := p.BlobDescriptorCacheProvider.RepositoryScoped(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &circuitBreakerBlobDescriptorService{BlobDescriptorService: s, cb: p.cb}, nil
+}
+
+// circuitBreakerBlobDescriptorService guards a repository scoped BlobDescriptorService with a circuitBreaker.
+type circuitBreakerBlobDescriptorService struct {
+	distribution.BlobDescriptorService
+	cb *circuitBreaker
+}
+
+func (s *circuitBreakerBlobDescriptorService) Stat(ctx context.Context, dgst digest.Digest) (distribution.Descriptor, error) {
+	var desc distribution.Descriptor
+	err := s.cb.do(func() error {
+		var err error
+		desc, err = s.BlobDescriptorService.Stat(ctx, dgst)
+		return err
+	})
+	return desc, err
+}
+
+func (s *circuitBreakerBlobDescriptorService) Clear(ctx context.Context, dgst digest.Digest) error {
+	return s.cb.do(func() error {
+		return s.BlobDescriptorService.Clear(ctx, dgst)
+	})
+}
+
+func (s *circuitBreakerBlobDescriptorService) SetDescriptor(ctx context.Context, dgst digest.Digest, desc distribution.Descriptor) error {
+	return s.cb.do(func() error {
+		return s.BlobDescriptorService.SetDescriptor(ctx, dgst, desc)
+	})
+}
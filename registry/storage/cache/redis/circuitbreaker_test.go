@@ -0,0 +1,105 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/registry/storage/cache"
+	"github.com/go-redis/redis/v8"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsUnavailableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "blob unknown", err: distribution.ErrBlobUnknown, want: false},
+		{name: "redis nil", err: redis.Nil, want: false},
+		{name: "generic", err: errors.New("foo"), want: false},
+		{name: "client closed", err: redis.ErrClosed, want: true},
+		{name: "EOF", err: io.EOF, want: true},
+		{name: "connection refused", err: fmt.Errorf("dial: %w", syscall.ECONNREFUSED), want: true},
+		{name: "pool timeout", err: errors.New("redis: connection pool timeout"), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, isUnavailableError(tt.err))
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var healthy int32
+	probe := func(context.Context) error {
+		if atomic.LoadInt32(&healthy) == 1 {
+			return nil
+		}
+		return io.EOF
+	}
+	cb := newCircuitBreaker(2, 10*time.Millisecond, probe)
+
+	var calls int32
+	failing := func() error {
+		atomic.AddInt32(&calls, 1)
+		return io.EOF
+	}
+
+	// cache misses and other errors unrelated to connectivity do not count as failures
+	require.Equal(t, distribution.ErrBlobUnknown, cb.do(func() error { return distribution.ErrBlobUnknown }))
+	require.Equal(t, io.EOF, cb.do(failing))
+	require.Equal(t, distribution.ErrBlobUnknown, cb.do(func() error { return distribution.ErrBlobUnknown }))
+
+	// consecutive failures open the circuit
+	require.Equal(t, io.EOF, cb.do(failing))
+	require.Equal(t, io.EOF, cb.do(failing))
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// operations are skipped while open
+	require.Equal(t, cache.ErrUnavailable, cb.do(failing))
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// the circuit remains open while the probe fails
+	time.Sleep(30 * time.Millisecond)
+	require.Equal(t, cache.ErrUnavailable, cb.do(failing))
+
+	// and closes once the probe succeeds
+	atomic.StoreInt32(&healthy, 1)
+	require.Eventually(t, func() bool {
+		return cb.do(func() error { return nil }) == nil
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestNewRedisBlobDescriptorCacheProvider_WithCircuitBreaker(t *testing.T) {
+	// nothing is listening on this address, so all operations fail
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	defer client.Close()
+
+	provider := NewRedisBlobDescriptorCacheProvider(client, WithCircuitBreaker(2, time.Hour))
+	dgst := digest.FromString("foo")
+
+	for i := 0; i < 2; i++ {
+		_, err := provider.Stat(context.Background(), dgst)
+		require.Error(t, err)
+		require.False(t, errors.Is(err, cache.ErrUnavailable))
+	}
+
+	_, err := provider.Stat(context.Background(), dgst)
+	require.True(t, errors.Is(err, cache.ErrUnavailable))
+
+	scoped, err := provider.RepositoryScoped("foo/bar")
+	require.NoError(t, err)
+	_, err = scoped.Stat(context.Background(), dgst)
+	require.True(t, errors.Is(err, cache.ErrUnavailable))
+}
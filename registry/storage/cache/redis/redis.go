@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/reference"
@@ -28,13 +29,52 @@ type redisBlobDescriptorService struct {
 	client redis.UniversalClient
 }
 
+type providerOpts struct {
+	circuitBreaker          bool
+	circuitBreakerThreshold int
+	circuitBreakerCooldown  time.Duration
+}
+
+// ProviderOption is used to pass options to NewRedisBlobDescriptorCacheProvider.
+type ProviderOption func(*providerOpts)
+
+// WithCircuitBreaker makes the cache fail fast with cache.ErrUnavailable after
+// threshold consecutive redis connectivity failures, instead of waiting for
+// redis timeouts on every lookup. While failing fast, redis is probed every
+// cooldown and the cache is used again as soon as it recovers. Defaults of
+// DefaultCircuitBreakerThreshold and DefaultCircuitBreakerCooldown are used
+// for non positive values.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) ProviderOption {
+	return func(opts *providerOpts) {
+		opts.circuitBreaker = true
+		opts.circuitBreakerThreshold = threshold
+		opts.circuitBreakerCooldown = cooldown
+	}
+}
+
 // NewRedisBlobDescriptorCacheProvider returns a new redis-based
 // BlobDescriptorCacheProvider using the provided redis connection pool.
-func NewRedisBlobDescriptorCacheProvider(client redis.UniversalClient) cache.BlobDescriptorCacheProvider {
+func NewRedisBlobDescriptorCacheProvider(client redis.UniversalClient, opts ...ProviderOption) cache.BlobDescriptorCacheProvider {
+	var config providerOpts
+	for _, o := range opts {
+		o(&config)
+	}
+
+	var provider cache.BlobDescriptorCacheProvider = &redisBlobDescriptorService{
+		client: client,
+	}
+
+	if config.circuitBreaker {
+		provider = &circuitBreakerCacheProvider{
+			BlobDescriptorCacheProvider: provider,
+			cb: newCircuitBreaker(config.circuitBreakerThreshold, config.circuitBreakerCooldown, func(ctx context.Context) error {
+				return client.Ping(ctx).Err()
+			}),
+		}
+	}
+
 	return metrics.NewPrometheusCacheProvider(
-		&redisBlobDescriptorService{
-			client: client,
-		},
+		provider,
 		"cache_redis",
 		"Number of seconds taken by redis",
 	)
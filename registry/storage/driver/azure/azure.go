@@ -6,6 +6,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -19,6 +20,7 @@ import (
 	"github.com/docker/distribution/registry/storage/driver/factory"
 
 	azure "github.com/Azure/azure-sdk-for-go/storage"
+	"github.com/Azure/go-autorest/autorest/adal"
 )
 
 const driverName = "azure"
@@ -26,6 +28,9 @@ const driverName = "azure"
 const (
 	paramAccountName          = "accountname"
 	paramAccountKey           = "accountkey"
+	paramCredentialsType      = "credentialstype"
+	paramSASToken             = "sastoken"
+	paramClientID             = "clientid"
 	paramContainer            = "container"
 	paramRealm                = "realm"
 	paramRootDirectory        = "rootdirectory"
@@ -33,6 +38,16 @@ const (
 	maxChunkSize              = 4 * 1024 * 1024
 )
 
+// Supported values of the credentialstype parameter.
+const (
+	credentialsTypeSharedKey       = "shared_key"
+	credentialsTypeSASToken        = "sas_token"
+	credentialsTypeManagedIdentity = "managed_identity"
+)
+
+// storageResource is the Azure AD resource for which tokens are requested when using a managed identity.
+const storageResource = "https://storage.azure.com/"
+
 type driver struct {
 	client        azure.BlobStorageClient
 	container     string
@@ -44,6 +59,10 @@ type driver struct {
 	// `//docker/registry/v2`. We need to preserve this behavior by default to
 	// support historical deployments of the registry using azure.
 	legacyPath bool
+
+	// signURLs is set when the driver holds the storage account key, which is required to sign URLs with a service
+	// SAS. Otherwise, URLFor is not supported and blobs are served by the registry.
+	signURLs bool
 }
 
 type baseEmbed struct{ base.Base }
@@ -69,11 +88,6 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 		return nil, fmt.Errorf("no %s parameter provided", paramAccountName)
 	}
 
-	accountKey, ok := parameters[paramAccountKey]
-	if !ok || fmt.Sprint(accountKey) == "" {
-		return nil, fmt.Errorf("no %s parameter provided", paramAccountKey)
-	}
-
 	container, ok := parameters[paramContainer]
 	if !ok || fmt.Sprint(container) == "" {
 		return nil, fmt.Errorf("no %s parameter provided", paramContainer)
@@ -99,7 +113,34 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 	}
 	legacyPath := !trimlegacyrootprefix
 
-	return New(fmt.Sprint(accountName), fmt.Sprint(accountKey), fmt.Sprint(container), fmt.Sprint(realm), fmt.Sprint(root), legacyPath)
+	credentialsType, ok := parameters[paramCredentialsType]
+	if !ok || fmt.Sprint(credentialsType) == "" {
+		credentialsType = credentialsTypeSharedKey
+	}
+
+	switch fmt.Sprint(credentialsType) {
+	case credentialsTypeSharedKey:
+		accountKey, ok := parameters[paramAccountKey]
+		if !ok || fmt.Sprint(accountKey) == "" {
+			return nil, fmt.Errorf("no %s parameter provided", paramAccountKey)
+		}
+		return New(fmt.Sprint(accountName), fmt.Sprint(accountKey), fmt.Sprint(container), fmt.Sprint(realm), fmt.Sprint(root), legacyPath)
+	case credentialsTypeSASToken:
+		sasToken, ok := parameters[paramSASToken]
+		if !ok || fmt.Sprint(sasToken) == "" {
+			return nil, fmt.Errorf("no %s parameter provided", paramSASToken)
+		}
+		return NewWithSASToken(fmt.Sprint(accountName), fmt.Sprint(sasToken), fmt.Sprint(container), fmt.Sprint(realm), fmt.Sprint(root), legacyPath)
+	case credentialsTypeManagedIdentity:
+		clientID, ok := parameters[paramClientID]
+		if !ok || clientID == nil {
+			clientID = ""
+		}
+		return NewWithManagedIdentity(fmt.Sprint(accountName), fmt.Sprint(clientID), fmt.Sprint(container), fmt.Sprint(realm), fmt.Sprint(root), legacyPath)
+	default:
+		return nil, fmt.Errorf("the %s parameter must be one of %q, %q or %q, got %q", paramCredentialsType,
+			credentialsTypeSharedKey, credentialsTypeSASToken, credentialsTypeManagedIdentity, credentialsType)
+	}
 }
 
 // New constructs a new Driver with the given Azure Storage Account credentials
@@ -117,19 +158,96 @@ func New(accountName, accountKey, container, realm, rootDirectory string, legacy
 		return nil, err
 	}
 
+	return newDriver(blobClient, container, rootDirectory, legacyPath, true), nil
+}
+
+// NewWithSASToken constructs a new Driver authenticated with a SAS token, which can either be an account SAS or a
+// user delegation SAS. The container must already exist, as SAS tokens are usually not granted permission to create
+// it.
+func NewWithSASToken(accountName, sasToken, container, realm, rootDirectory string, legacyPath bool) (*Driver, error) {
+	endpoint := fmt.Sprintf("https://%s.blob.%s", accountName, realm)
+	api, err := azure.NewAccountSASClientFromEndpointToken(endpoint, strings.TrimPrefix(sasToken, "?"))
+	if err != nil {
+		return nil, fmt.Errorf("parsing SAS token: %w", err)
+	}
+
+	return newDriver(api.GetBlobService(), container, rootDirectory, legacyPath, false), nil
+}
+
+// NewWithManagedIdentity constructs a new Driver authenticated with an Azure AD token obtained for the managed
+// identity of the host. If clientID is empty the system-assigned identity is used, otherwise the user-assigned
+// identity with the given client ID. The container must already exist.
+func NewWithManagedIdentity(accountName, clientID, container, realm, rootDirectory string, legacyPath bool) (*Driver, error) {
+	msiEndpoint, err := adal.GetMSIVMEndpoint()
+	if err != nil {
+		return nil, fmt.Errorf("getting managed identity endpoint: %w", err)
+	}
+
+	var spt *adal.ServicePrincipalToken
+	if clientID == "" {
+		spt, err = adal.NewServicePrincipalTokenFromMSI(msiEndpoint, storageResource)
+	} else {
+		spt, err = adal.NewServicePrincipalTokenFromMSIWithUserAssignedID(msiEndpoint, storageResource, clientID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating managed identity token: %w", err)
+	}
+
+	// The storage client only knows how to sign requests with an account key. A placeholder key is used to build it
+	// and the resulting Authorization header is replaced with a bearer token before each request is sent.
+	api, err := azure.NewClient(accountName, placeholderAccountKey, realm, azure.DefaultAPIVersion, true)
+	if err != nil {
+		return nil, err
+	}
+	api.HTTPClient = &http.Client{Transport: &bearerTransport{token: spt, next: http.DefaultTransport}}
+
+	return newDriver(api.GetBlobService(), container, rootDirectory, legacyPath, false), nil
+}
+
+func newDriver(client azure.BlobStorageClient, container, rootDirectory string, legacyPath, signURLs bool) *Driver {
 	rootDirectory = strings.Trim(rootDirectory, "/")
 	if rootDirectory != "" {
 		rootDirectory += "/"
 	}
 
 	d := &driver{
-		client:        blobClient,
+		client:        client,
 		rootDirectory: rootDirectory,
 		legacyPath:    legacyPath,
 		container:     container,
+		signURLs:      signURLs,
 	}
 
-	return &Driver{baseEmbed: baseEmbed{Base: base.Base{StorageDriver: d}}}, nil
+	return &Driver{baseEmbed: baseEmbed{Base: base.Base{StorageDriver: d}}}
+}
+
+// placeholderAccountKey is a valid base64 encoded value used to construct storage clients that authenticate with
+// something other than the account key.
+var placeholderAccountKey = base64.StdEncoding.EncodeToString([]byte("placeholder"))
+
+// tokenSource provides OAuth tokens, refreshing them when needed. It is satisfied by *adal.ServicePrincipalToken.
+type tokenSource interface {
+	EnsureFresh() error
+	OAuthToken() string
+}
+
+// bearerTransport is a http.RoundTripper that authenticates requests with an OAuth bearer token.
+type bearerTransport struct {
+	token tokenSource
+	next  http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.token.EnsureFresh(); err != nil {
+		return nil, fmt.Errorf("refreshing managed identity token: %w", err)
+	}
+
+	// requests must not be modified by a RoundTripper
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+t.token.OAuthToken())
+
+	return t.next.RoundTrip(r)
 }
 
 // Implement the storagedriver.StorageDriver interface.
@@ -395,6 +513,10 @@ func (d *driver) DeleteFiles(ctx context.Context, paths []string) (int, error) {
 // for specified duration by making use of Azure Storage Shared Access Signatures (SAS).
 // See https://msdn.microsoft.com/en-us/library/azure/ee395415.aspx for more info.
 func (d *driver) URLFor(ctx context.Context, path string, options map[string]interface{}) (string, error) {
+	if !d.signURLs {
+		return "", storagedriver.ErrUnsupportedMethod{DriverName: driverName}
+	}
+
 	expiresTime := time.Now().UTC().Add(20 * time.Minute) // default expiration
 	expires, ok := options["expiry"]
 	if ok {
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
@@ -235,3 +237,101 @@ func TestStatRootPath(t *testing.T) {
 		})
 	}
 }
+
+func TestFromParametersCredentials(t *testing.T) {
+	var tests = []struct {
+		name        string
+		parameters  map[string]interface{}
+		expectedErr string
+	}{
+		{
+			name:        "shared key without account key",
+			parameters:  map[string]interface{}{paramCredentialsType: credentialsTypeSharedKey},
+			expectedErr: "no accountkey parameter provided",
+		},
+		{
+			name:        "default credentials type without account key",
+			parameters:  map[string]interface{}{},
+			expectedErr: "no accountkey parameter provided",
+		},
+		{
+			name:        "sas token without token",
+			parameters:  map[string]interface{}{paramCredentialsType: credentialsTypeSASToken},
+			expectedErr: "no sastoken parameter provided",
+		},
+		{
+			name:        "sas token with invalid token",
+			parameters:  map[string]interface{}{paramCredentialsType: credentialsTypeSASToken, paramSASToken: "sv=%zz"},
+			expectedErr: "parsing SAS token",
+		},
+		{
+			name:        "unknown credentials type",
+			parameters:  map[string]interface{}{paramCredentialsType: "password"},
+			expectedErr: `the credentialstype parameter must be one of "shared_key", "sas_token" or "managed_identity", got "password"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]interface{}{
+				paramAccountName: "account",
+				paramContainer:   "container",
+			}
+			for k, v := range tt.parameters {
+				params[k] = v
+			}
+
+			_, err := FromParameters(params)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+func TestSASTokenURLForUnsupported(t *testing.T) {
+	d, err := FromParameters(map[string]interface{}{
+		paramAccountName:     "account",
+		paramContainer:       "container",
+		paramCredentialsType: credentialsTypeSASToken,
+		paramSASToken:        "?sv=2018-03-28&ss=b&srt=sco&sp=rwdlac&se=2030-01-01T00:00:00Z&sig=c2lnbmF0dXJl",
+	})
+	require.NoError(t, err)
+
+	_, err = d.URLFor(context.Background(), "/foo", nil)
+	require.Equal(t, storagedriver.ErrUnsupportedMethod{DriverName: driverName}, err)
+}
+
+type staticTokenSource struct {
+	token string
+	err   error
+}
+
+func (s *staticTokenSource) EnsureFresh() error { return s.err }
+func (s *staticTokenSource) OAuthToken() string { return s.token }
+
+func TestBearerTransport(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	c := &http.Client{Transport: &bearerTransport{token: &staticTokenSource{token: "abc"}, next: http.DefaultTransport}}
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "SharedKey account:signature")
+
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	require.Equal(t, "Bearer abc", got)
+	// the original request must not be modified
+	require.Equal(t, "SharedKey account:signature", req.Header.Get("Authorization"))
+
+	c.Transport.(*bearerTransport).token = &staticTokenSource{err: errors.New("unavailable")}
+	_, err = c.Get(srv.URL)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "refreshing managed identity token: unavailable")
+}
@@ -44,8 +44,12 @@ import (
 
 	dcontext "github.com/docker/distribution/context"
 	prometheus "github.com/docker/distribution/metrics"
+	"github.com/docker/distribution/registry/internal/tracing"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/go-metrics"
+	"github.com/opencontainers/go-digest"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -80,6 +84,9 @@ func (base *Base) setDriverName(e error) error {
 	case storagedriver.InvalidOffsetError:
 		actual.DriverName = base.StorageDriver.Name()
 		return actual
+	case storagedriver.ChecksumUnavailableError:
+		actual.DriverName = base.StorageDriver.Name()
+		return actual
 	default:
 		storageError := storagedriver.Error{
 			DriverName: base.StorageDriver.Name(),
@@ -90,6 +97,15 @@ func (base *Base) setDriverName(e error) error {
 	}
 }
 
+// startSpan starts a tracing span for a storage driver operation on path. The span must be ended with
+// tracing.EndSpan.
+func (base *Base) startSpan(ctx context.Context, action, path string) (context.Context, trace.Span) {
+	return tracing.StartSpan(ctx, "storage."+action,
+		label.String("storage.driver", base.Name()),
+		label.String("storage.path", path),
+	)
+}
+
 // GetContent wraps GetContent of underlying storage driver.
 func (base *Base) GetContent(ctx context.Context, path string) ([]byte, error) {
 	ctx, done := dcontext.WithTrace(ctx)
@@ -99,9 +115,11 @@ func (base *Base) GetContent(ctx context.Context, path string) ([]byte, error) {
 		return nil, storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
 	}
 
+	ctx, span := base.startSpan(ctx, "GetContent", path)
 	start := time.Now()
 	b, e := base.StorageDriver.GetContent(ctx, path)
 	storageAction.WithValues(base.Name(), "GetContent").UpdateSince(start)
+	tracing.EndSpan(span, e)
 	return b, base.setDriverName(e)
 }
 
@@ -114,9 +132,11 @@ func (base *Base) PutContent(ctx context.Context, path string, content []byte) e
 		return storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
 	}
 
+	ctx, span := base.startSpan(ctx, "PutContent", path)
 	start := time.Now()
 	err := base.setDriverName(base.StorageDriver.PutContent(ctx, path, content))
 	storageAction.WithValues(base.Name(), "PutContent").UpdateSince(start)
+	tracing.EndSpan(span, err)
 	return err
 }
 
@@ -133,8 +153,28 @@ func (base *Base) Reader(ctx context.Context, path string, offset int64) (io.Rea
 		return nil, storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
 	}
 
+	ctx, span := base.startSpan(ctx, "Reader", path)
 	rc, e := base.StorageDriver.Reader(ctx, path, offset)
-	return rc, base.setDriverName(e)
+	tracing.EndSpan(span, e)
+	if e != nil {
+		return nil, base.setDriverName(e)
+	}
+	return &contextReader{ctx: ctx, ReadCloser: rc}, nil
+}
+
+// contextReader fails reads once ctx is done. This makes streaming content from the storage backend stop as soon as
+// the request it serves is canceled or exceeds its deadline, even with drivers that do not honor the context of
+// Reader calls once these return.
+type contextReader struct {
+	ctx context.Context
+	io.ReadCloser
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.ReadCloser.Read(p)
 }
 
 // Writer wraps Writer of underlying storage driver.
@@ -146,7 +186,9 @@ func (base *Base) Writer(ctx context.Context, path string, append bool) (storage
 		return nil, storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
 	}
 
+	ctx, span := base.startSpan(ctx, "Writer", path)
 	writer, e := base.StorageDriver.Writer(ctx, path, append)
+	tracing.EndSpan(span, e)
 	return writer, base.setDriverName(e)
 }
 
@@ -159,9 +201,11 @@ func (base *Base) Stat(ctx context.Context, path string) (storagedriver.FileInfo
 		return nil, storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
 	}
 
+	ctx, span := base.startSpan(ctx, "Stat", path)
 	start := time.Now()
 	fi, e := base.StorageDriver.Stat(ctx, path)
 	storageAction.WithValues(base.Name(), "Stat").UpdateSince(start)
+	tracing.EndSpan(span, e)
 	return fi, base.setDriverName(e)
 }
 
@@ -174,9 +218,11 @@ func (base *Base) List(ctx context.Context, path string) ([]string, error) {
 		return nil, storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
 	}
 
+	ctx, span := base.startSpan(ctx, "List", path)
 	start := time.Now()
 	str, e := base.StorageDriver.List(ctx, path)
 	storageAction.WithValues(base.Name(), "List").UpdateSince(start)
+	tracing.EndSpan(span, e)
 	return str, base.setDriverName(e)
 }
 
@@ -191,9 +237,12 @@ func (base *Base) Move(ctx context.Context, sourcePath string, destPath string)
 		return storagedriver.InvalidPathError{Path: destPath, DriverName: base.StorageDriver.Name()}
 	}
 
+	ctx, span := base.startSpan(ctx, "Move", sourcePath)
+	span.SetAttributes(label.String("storage.dest_path", destPath))
 	start := time.Now()
 	err := base.setDriverName(base.StorageDriver.Move(ctx, sourcePath, destPath))
 	storageAction.WithValues(base.Name(), "Move").UpdateSince(start)
+	tracing.EndSpan(span, err)
 	return err
 }
 
@@ -206,9 +255,11 @@ func (base *Base) Delete(ctx context.Context, path string) error {
 		return storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
 	}
 
+	ctx, span := base.startSpan(ctx, "Delete", path)
 	start := time.Now()
 	err := base.setDriverName(base.StorageDriver.Delete(ctx, path))
 	storageAction.WithValues(base.Name(), "Delete").UpdateSince(start)
+	tracing.EndSpan(span, err)
 	return err
 }
 
@@ -221,9 +272,11 @@ func (base *Base) URLFor(ctx context.Context, path string, options map[string]in
 		return "", storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
 	}
 
+	ctx, span := base.startSpan(ctx, "URLFor", path)
 	start := time.Now()
 	str, e := base.StorageDriver.URLFor(ctx, path, options)
 	storageAction.WithValues(base.Name(), "URLFor").UpdateSince(start)
+	tracing.EndSpan(span, e)
 	return str, base.setDriverName(e)
 }
 
@@ -236,5 +289,58 @@ func (base *Base) Walk(ctx context.Context, path string, f storagedriver.WalkFn)
 		return storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
 	}
 
-	return base.setDriverName(base.StorageDriver.Walk(ctx, path, f))
+	ctx, span := base.startSpan(ctx, "Walk", path)
+	err := base.setDriverName(base.StorageDriver.Walk(ctx, path, f))
+	tracing.EndSpan(span, err)
+	return err
+}
+
+// Checksum wraps Checksum of underlying storage driver. Returns
+// ErrUnsupportedMethod if the underlying storage driver does not implement
+// storagedriver.Checksummer.
+func (base *Base) Checksum(ctx context.Context, path string) (digest.Digest, error) {
+	ctx, done := dcontext.WithTrace(ctx)
+	defer done("%s.Checksum(%q)", base.Name(), path)
+
+	if !storagedriver.PathRegexp.MatchString(path) {
+		return "", storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
+	}
+
+	c, ok := base.StorageDriver.(storagedriver.Checksummer)
+	if !ok {
+		return "", storagedriver.ErrUnsupportedMethod{DriverName: base.StorageDriver.Name()}
+	}
+
+	ctx, span := base.startSpan(ctx, "Checksum", path)
+	start := time.Now()
+	dgst, err := c.Checksum(ctx, path)
+	storageAction.WithValues(base.Name(), "Checksum").UpdateSince(start)
+	tracing.EndSpan(span, err)
+	return dgst, base.setDriverName(err)
+}
+
+// Concat wraps Concat of underlying storage driver. Returns
+// ErrUnsupportedMethod if the underlying storage driver does not implement
+// storagedriver.Concatenator.
+func (base *Base) Concat(ctx context.Context, path string, sourcePaths []string) error {
+	ctx, done := dcontext.WithTrace(ctx)
+	defer done("%s.Concat(%q, %q)", base.Name(), path, sourcePaths)
+
+	for _, p := range append([]string{path}, sourcePaths...) {
+		if !storagedriver.PathRegexp.MatchString(p) {
+			return storagedriver.InvalidPathError{Path: p, DriverName: base.StorageDriver.Name()}
+		}
+	}
+
+	c, ok := base.StorageDriver.(storagedriver.Concatenator)
+	if !ok {
+		return storagedriver.ErrUnsupportedMethod{DriverName: base.StorageDriver.Name()}
+	}
+
+	ctx, span := base.startSpan(ctx, "Concat", path)
+	start := time.Now()
+	err := base.setDriverName(c.Concat(ctx, path, sourcePaths))
+	storageAction.WithValues(base.Name(), "Concat").UpdateSince(start)
+	tracing.EndSpan(span, err)
+	return err
 }
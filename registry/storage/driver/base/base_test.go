@@ -0,0 +1,36 @@
+package base_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+)
+
+func TestReaderContextCanceled(t *testing.T) {
+	d := inmemory.New()
+	if err := d.PutContent(context.Background(), "/foo", []byte("bar")); err != nil {
+		t.Fatalf("unexpected error putting content: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rc, err := d.Reader(ctx, "/foo", 0)
+	if err != nil {
+		t.Fatalf("unexpected error opening reader: %v", err)
+	}
+	defer rc.Close()
+
+	p := make([]byte, 1)
+	if _, err := rc.Read(p); err != nil {
+		t.Fatalf("unexpected error reading: %v", err)
+	}
+
+	cancel()
+	if _, err := ioutil.ReadAll(rc); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled error after cancellation, got %v", err)
+	}
+}
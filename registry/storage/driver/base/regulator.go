@@ -9,6 +9,7 @@ import (
 	"sync"
 
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/opencontainers/go-digest"
 )
 
 // Regulator wraps the given driver and is used to regulate concurrent calls
@@ -183,3 +184,33 @@ func (r *Regulator) URLFor(ctx context.Context, path string, options map[string]
 
 	return r.StorageDriver.URLFor(ctx, path, options)
 }
+
+// Checksum returns the SHA-256 digest of the content stored at path, as
+// computed by the storage backend. Returns ErrUnsupportedMethod if the
+// regulated driver does not implement storagedriver.Checksummer.
+func (r *Regulator) Checksum(ctx context.Context, path string) (digest.Digest, error) {
+	c, ok := r.StorageDriver.(storagedriver.Checksummer)
+	if !ok {
+		return "", storagedriver.ErrUnsupportedMethod{}
+	}
+
+	r.enter()
+	defer r.exit()
+
+	return c.Checksum(ctx, path)
+}
+
+// Concat appends the content stored at sourcePaths to the content stored at
+// path, server side. Returns ErrUnsupportedMethod if the regulated driver does
+// not implement storagedriver.Concatenator.
+func (r *Regulator) Concat(ctx context.Context, path string, sourcePaths []string) error {
+	c, ok := r.StorageDriver.(storagedriver.Concatenator)
+	if !ok {
+		return storagedriver.ErrUnsupportedMethod{}
+	}
+
+	r.enter()
+	defer r.exit()
+
+	return c.Concat(ctx, path, sourcePaths)
+}
@@ -0,0 +1,125 @@
+// Package fallback provides a storage driver that combines two storage backends, reading from a secondary backend
+// whatever is not found in the primary one. It allows serving content while it's being relocated between backends.
+package fallback
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/opencontainers/go-digest"
+)
+
+// Driver is a storagedriver.StorageDriver that writes to a primary storage driver and reads from it, falling back to a
+// secondary storage driver for paths that do not exist in the primary one. Deletions apply to both drivers. Listing
+// and walking only cover the primary driver.
+type Driver struct {
+	storagedriver.StorageDriver
+	secondary storagedriver.StorageDriver
+}
+
+var _ storagedriver.StorageDriver = &Driver{}
+
+// New constructs a new Driver.
+func New(primary, secondary storagedriver.StorageDriver) *Driver {
+	return &Driver{StorageDriver: primary, secondary: secondary}
+}
+
+func isPathNotFound(err error) bool {
+	return errors.As(err, &storagedriver.PathNotFoundError{})
+}
+
+// GetContent retrieves the content stored at path from the primary driver, or from the secondary driver if not found.
+func (d *Driver) GetContent(ctx context.Context, path string) ([]byte, error) {
+	content, err := d.StorageDriver.GetContent(ctx, path)
+	if isPathNotFound(err) {
+		return d.secondary.GetContent(ctx, path)
+	}
+	return content, err
+}
+
+// Reader retrieves an io.ReadCloser for the content stored at path from the primary driver, or from the secondary
+// driver if not found.
+func (d *Driver) Reader(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
+	rc, err := d.StorageDriver.Reader(ctx, path, offset)
+	if isPathNotFound(err) {
+		return d.secondary.Reader(ctx, path, offset)
+	}
+	return rc, err
+}
+
+// Stat retrieves the FileInfo for the given path from the primary driver, or from the secondary driver if not found.
+func (d *Driver) Stat(ctx context.Context, path string) (storagedriver.FileInfo, error) {
+	fi, err := d.StorageDriver.Stat(ctx, path)
+	if isPathNotFound(err) {
+		return d.secondary.Stat(ctx, path)
+	}
+	return fi, err
+}
+
+// URLFor returns a URL for the content stored at path in the primary driver, or in the secondary driver if not found.
+// As drivers are not required to check whether a path exists before generating a URL for it, the path is looked up in
+// the primary driver first.
+func (d *Driver) URLFor(ctx context.Context, path string, options map[string]interface{}) (string, error) {
+	if _, err := d.StorageDriver.Stat(ctx, path); err != nil {
+		if !isPathNotFound(err) {
+			return "", err
+		}
+		return d.secondary.URLFor(ctx, path, options)
+	}
+	return d.StorageDriver.URLFor(ctx, path, options)
+}
+
+// Delete recursively deletes all objects stored at path from both drivers. A storagedriver.PathNotFoundError is only
+// returned if the path does not exist in either of them.
+func (d *Driver) Delete(ctx context.Context, path string) error {
+	err := d.StorageDriver.Delete(ctx, path)
+	if err != nil && !isPathNotFound(err) {
+		return err
+	}
+	err2 := d.secondary.Delete(ctx, path)
+	if err2 != nil && !isPathNotFound(err2) {
+		return err2
+	}
+	if err != nil && err2 != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteFiles deletes a set of files from both drivers. The returned count is the lowest of the two, as a file is only
+// deleted once removed from both drivers.
+func (d *Driver) DeleteFiles(ctx context.Context, paths []string) (int, error) {
+	count, err := d.StorageDriver.DeleteFiles(ctx, paths)
+	if err != nil {
+		return count, err
+	}
+	count2, err := d.secondary.DeleteFiles(ctx, paths)
+	if count2 < count {
+		count = count2
+	}
+	return count, err
+}
+
+// Checksum returns the checksum of the content stored at path, as computed by the primary driver, which is where
+// content is written to. Returns storagedriver.ErrUnsupportedMethod if the primary driver does not implement
+// storagedriver.Checksummer.
+func (d *Driver) Checksum(ctx context.Context, path string) (digest.Digest, error) {
+	c, ok := d.StorageDriver.(storagedriver.Checksummer)
+	if !ok {
+		return "", storagedriver.ErrUnsupportedMethod{DriverName: d.Name()}
+	}
+	return c.Checksum(ctx, path)
+}
+
+// Concat appends the content stored at sourcePaths to the content stored at path using the primary driver, which is
+// where content is written to. Returns storagedriver.ErrUnsupportedMethod if the primary driver does not implement
+// storagedriver.Concatenator.
+func (d *Driver) Concat(ctx context.Context, path string, sourcePaths []string) error {
+	c, ok := d.StorageDriver.(storagedriver.Concatenator)
+	if !ok {
+		return storagedriver.ErrUnsupportedMethod{DriverName: d.Name()}
+	}
+	return c.Concat(ctx, path, sourcePaths)
+}
@@ -0,0 +1,128 @@
+package fallback
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDriver(t *testing.T) (*Driver, storagedriver.StorageDriver, storagedriver.StorageDriver) {
+	t.Helper()
+
+	primary, secondary := inmemory.New(), inmemory.New()
+	ctx := context.Background()
+	require.NoError(t, primary.PutContent(ctx, "/a", []byte("primary a")))
+	require.NoError(t, primary.PutContent(ctx, "/b", []byte("primary b")))
+	require.NoError(t, secondary.PutContent(ctx, "/b", []byte("secondary b")))
+	require.NoError(t, secondary.PutContent(ctx, "/c", []byte("secondary c")))
+
+	return New(primary, secondary), primary, secondary
+}
+
+func TestDriver_Reads(t *testing.T) {
+	d, _, _ := newTestDriver(t)
+	ctx := context.Background()
+
+	tt := []struct {
+		path string
+		want string
+	}{
+		{path: "/a", want: "primary a"},
+		{path: "/b", want: "primary b"},
+		{path: "/c", want: "secondary c"},
+	}
+
+	for _, test := range tt {
+		t.Run(test.path, func(t *testing.T) {
+			content, err := d.GetContent(ctx, test.path)
+			require.NoError(t, err)
+			require.Equal(t, test.want, string(content))
+
+			rc, err := d.Reader(ctx, test.path, 0)
+			require.NoError(t, err)
+			defer rc.Close()
+			content, err = ioutil.ReadAll(rc)
+			require.NoError(t, err)
+			require.Equal(t, test.want, string(content))
+
+			fi, err := d.Stat(ctx, test.path)
+			require.NoError(t, err)
+			require.Equal(t, int64(len(test.want)), fi.Size())
+		})
+	}
+
+	_, err := d.GetContent(ctx, "/d")
+	require.ErrorAs(t, err, &storagedriver.PathNotFoundError{})
+	_, err = d.Reader(ctx, "/d", 0)
+	require.ErrorAs(t, err, &storagedriver.PathNotFoundError{})
+	_, err = d.Stat(ctx, "/d")
+	require.ErrorAs(t, err, &storagedriver.PathNotFoundError{})
+}
+
+func TestDriver_WritesToPrimary(t *testing.T) {
+	d, primary, secondary := newTestDriver(t)
+	ctx := context.Background()
+
+	require.NoError(t, d.PutContent(ctx, "/c", []byte("new c")))
+
+	content, err := primary.GetContent(ctx, "/c")
+	require.NoError(t, err)
+	require.Equal(t, "new c", string(content))
+	content, err = secondary.GetContent(ctx, "/c")
+	require.NoError(t, err)
+	require.Equal(t, "secondary c", string(content))
+}
+
+func TestDriver_Delete(t *testing.T) {
+	d, primary, secondary := newTestDriver(t)
+	ctx := context.Background()
+
+	// deleted from both drivers
+	require.NoError(t, d.Delete(ctx, "/b"))
+	_, err := primary.Stat(ctx, "/b")
+	require.ErrorAs(t, err, &storagedriver.PathNotFoundError{})
+	_, err = secondary.Stat(ctx, "/b")
+	require.ErrorAs(t, err, &storagedriver.PathNotFoundError{})
+
+	// found in one of the drivers only
+	require.NoError(t, d.Delete(ctx, "/a"))
+	require.NoError(t, d.Delete(ctx, "/c"))
+
+	// not found in either
+	err = d.Delete(ctx, "/d")
+	require.ErrorAs(t, err, &storagedriver.PathNotFoundError{})
+}
+
+func TestDriver_DeleteFiles(t *testing.T) {
+	d, primary, secondary := newTestDriver(t)
+	ctx := context.Background()
+
+	count, err := d.DeleteFiles(ctx, []string{"/a", "/b", "/c"})
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+
+	for _, p := range []string{"/a", "/b", "/c"} {
+		_, err = primary.Stat(ctx, p)
+		require.ErrorAs(t, err, &storagedriver.PathNotFoundError{})
+		_, err = secondary.Stat(ctx, p)
+		require.ErrorAs(t, err, &storagedriver.PathNotFoundError{})
+	}
+}
+
+func TestDriver_Checksum_Unsupported(t *testing.T) {
+	d, _, _ := newTestDriver(t)
+
+	_, err := d.Checksum(context.Background(), "/a")
+	require.ErrorAs(t, err, &storagedriver.ErrUnsupportedMethod{})
+}
+
+func TestDriver_Concat_Unsupported(t *testing.T) {
+	d, _, _ := newTestDriver(t)
+
+	err := d.Concat(context.Background(), "/a", []string{"/b"})
+	require.ErrorAs(t, err, &storagedriver.ErrUnsupportedMethod{})
+}
@@ -12,6 +12,7 @@ import (
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"time"
 
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
@@ -24,6 +25,9 @@ const (
 	defaultRootDirectory = "/var/lib/registry"
 	defaultMaxThreads    = uint64(100)
 
+	defaultMaxWalkConcurrency = uint64(100)
+	minWalkConcurrency        = uint64(1)
+
 	// minThreads is the minimum value for the maxthreads configuration
 	// parameter. If the driver's parameters are less than this we set
 	// the parameters to minThreads
@@ -33,8 +37,10 @@ const (
 // DriverParameters represents all configuration options available for the
 // filesystem driver
 type DriverParameters struct {
-	RootDirectory string
-	MaxThreads    uint64
+	RootDirectory      string
+	MaxThreads         uint64
+	ParallelWalk       bool
+	MaxWalkConcurrency uint64
 }
 
 func init() {
@@ -50,6 +56,13 @@ func (factory *filesystemDriverFactory) Create(parameters map[string]interface{}
 
 type driver struct {
 	rootDirectory string
+
+	// parallelWalk enables or disables concurrently walking the filesystem.
+	parallelWalk bool
+
+	// maxWalkConcurrency limits the number of concurrent goroutines used
+	// while walking the filesystem in parallel.
+	maxWalkConcurrency uint64
 }
 
 type baseEmbed struct {
@@ -66,6 +79,8 @@ type Driver struct {
 // Optional Parameters:
 // - rootdirectory
 // - maxthreads
+// - parallelwalk
+// - maxwalkconcurrency
 func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 	params, err := fromParametersImpl(parameters)
 	if err != nil || params == nil {
@@ -76,9 +91,11 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 
 func fromParametersImpl(parameters map[string]interface{}) (*DriverParameters, error) {
 	var (
-		err           error
-		maxThreads    = defaultMaxThreads
-		rootDirectory = defaultRootDirectory
+		err                error
+		maxThreads         = defaultMaxThreads
+		rootDirectory      = defaultRootDirectory
+		parallelWalk       bool
+		maxWalkConcurrency = defaultMaxWalkConcurrency
 	)
 
 	if parameters != nil {
@@ -90,18 +107,46 @@ func fromParametersImpl(parameters map[string]interface{}) (*DriverParameters, e
 		if err != nil {
 			return nil, fmt.Errorf("maxthreads config error: %s", err.Error())
 		}
+
+		switch v := parameters["parallelwalk"].(type) {
+		case string:
+			parallelWalk, err = strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("the parallelwalk parameter should be a boolean")
+			}
+		case bool:
+			parallelWalk = v
+		case nil:
+			// do nothing
+		default:
+			return nil, fmt.Errorf("the parallelwalk parameter should be a boolean")
+		}
+
+		maxWalkConcurrency, err = base.GetLimitFromParameter(parameters["maxwalkconcurrency"], minWalkConcurrency, defaultMaxWalkConcurrency)
+		if err != nil {
+			return nil, fmt.Errorf("maxwalkconcurrency config error: %s", err)
+		}
 	}
 
 	params := &DriverParameters{
-		RootDirectory: rootDirectory,
-		MaxThreads:    maxThreads,
+		RootDirectory:      rootDirectory,
+		MaxThreads:         maxThreads,
+		ParallelWalk:       parallelWalk,
+		MaxWalkConcurrency: maxWalkConcurrency,
 	}
 	return params, nil
 }
 
 // New constructs a new Driver with a given rootDirectory
 func New(params DriverParameters) *Driver {
-	fsDriver := &driver{rootDirectory: params.RootDirectory}
+	if params.MaxWalkConcurrency == 0 {
+		params.MaxWalkConcurrency = defaultMaxWalkConcurrency
+	}
+	fsDriver := &driver{
+		rootDirectory:      params.RootDirectory,
+		parallelWalk:       params.ParallelWalk,
+		maxWalkConcurrency: params.MaxWalkConcurrency,
+	}
 
 	return &Driver{
 		baseEmbed: baseEmbed{
@@ -341,9 +386,12 @@ func (d *driver) Walk(ctx context.Context, path string, f storagedriver.WalkFn)
 // WalkParallel traverses a filesystem defined within driver in parallel, starting
 // from the given path, calling f on each file.
 func (d *driver) WalkParallel(ctx context.Context, path string, f storagedriver.WalkFn) error {
-	// TODO: Verify that this driver can reliably handle parallel workloads before
-	// using storagedriver.WalkFallbackParallel
-	return d.Walk(ctx, path, f)
+	// If the ParallelWalk feature flag is not set, fall back to standard sequential walk.
+	if !d.parallelWalk {
+		return d.Walk(ctx, path, f)
+	}
+
+	return storagedriver.WalkFallbackParallel(ctx, d, d.maxWalkConcurrency, path, f)
 }
 
 // TransferTo writes the content from the source driver and the source path, to
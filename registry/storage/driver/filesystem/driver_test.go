@@ -3,14 +3,18 @@ package filesystem
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"os"
 	"path"
+	"sort"
+	"sync"
 	"testing"
 
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/registry/storage/driver/testsuites"
+	"github.com/hashicorp/go-multierror"
 	"github.com/stretchr/testify/require"
 	. "gopkg.in/check.v1"
 )
@@ -47,8 +51,9 @@ func TestFromParametersImpl(t *testing.T) {
 		{
 			params: map[string]interface{}{},
 			expected: DriverParameters{
-				RootDirectory: defaultRootDirectory,
-				MaxThreads:    defaultMaxThreads,
+				RootDirectory:      defaultRootDirectory,
+				MaxThreads:         defaultMaxThreads,
+				MaxWalkConcurrency: defaultMaxWalkConcurrency,
 			},
 			pass: true,
 		},
@@ -65,8 +70,9 @@ func TestFromParametersImpl(t *testing.T) {
 				"maxthreads": "100",
 			},
 			expected: DriverParameters{
-				RootDirectory: defaultRootDirectory,
-				MaxThreads:    uint64(100),
+				RootDirectory:      defaultRootDirectory,
+				MaxThreads:         uint64(100),
+				MaxWalkConcurrency: defaultMaxWalkConcurrency,
 			},
 			pass: true,
 		},
@@ -75,8 +81,9 @@ func TestFromParametersImpl(t *testing.T) {
 				"maxthreads": 100,
 			},
 			expected: DriverParameters{
-				RootDirectory: defaultRootDirectory,
-				MaxThreads:    uint64(100),
+				RootDirectory:      defaultRootDirectory,
+				MaxThreads:         uint64(100),
+				MaxWalkConcurrency: defaultMaxWalkConcurrency,
 			},
 			pass: true,
 		},
@@ -86,11 +93,51 @@ func TestFromParametersImpl(t *testing.T) {
 				"maxthreads": 1,
 			},
 			expected: DriverParameters{
-				RootDirectory: defaultRootDirectory,
-				MaxThreads:    minThreads,
+				RootDirectory:      defaultRootDirectory,
+				MaxThreads:         minThreads,
+				MaxWalkConcurrency: defaultMaxWalkConcurrency,
 			},
 			pass: true,
 		},
+		{
+			params: map[string]interface{}{
+				"parallelwalk":       "true",
+				"maxwalkconcurrency": 10,
+			},
+			expected: DriverParameters{
+				RootDirectory:      defaultRootDirectory,
+				MaxThreads:         defaultMaxThreads,
+				ParallelWalk:       true,
+				MaxWalkConcurrency: uint64(10),
+			},
+			pass: true,
+		},
+		{
+			params: map[string]interface{}{
+				"parallelwalk": true,
+			},
+			expected: DriverParameters{
+				RootDirectory:      defaultRootDirectory,
+				MaxThreads:         defaultMaxThreads,
+				ParallelWalk:       true,
+				MaxWalkConcurrency: defaultMaxWalkConcurrency,
+			},
+			pass: true,
+		},
+		{
+			params: map[string]interface{}{
+				"parallelwalk": "fail",
+			},
+			expected: DriverParameters{},
+			pass:     false,
+		},
+		{
+			params: map[string]interface{}{
+				"maxwalkconcurrency": "fail",
+			},
+			expected: DriverParameters{},
+			pass:     false,
+		},
 	}
 
 	for _, item := range tests {
@@ -272,16 +319,70 @@ func TestTransferToSameRootDir(t *testing.T) {
 		"unable to begin transfer: srcDriver and destDriver must not have the same root directory")
 }
 
-func newTempDirDriver(t *testing.T) (*Driver, func()) {
+func TestWalkParallel(t *testing.T) {
+	d, cleanup := newTempDirDriver(t, map[string]interface{}{"parallelwalk": true, "maxwalkconcurrency": 4})
+	defer cleanup()
+
+	ctx := context.Background()
+	wantedPaths := []string{"/a", "/a/1", "/a/2", "/b", "/b/c", "/b/c/1", "/d"}
+	for _, p := range []string{"/a/1", "/a/2", "/b/c/1", "/d"} {
+		require.NoError(t, d.PutContent(ctx, p, []byte("contents")))
+	}
+
+	var mu sync.Mutex
+	var paths []string
+	err := d.WalkParallel(ctx, "/", func(fInfo storagedriver.FileInfo) error {
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, fInfo.Path())
+		return nil
+	})
+	require.NoError(t, err)
+	require.ElementsMatch(t, wantedPaths, paths)
+}
+
+func TestWalkParallelErrorsAreSorted(t *testing.T) {
+	d, cleanup := newTempDirDriver(t, map[string]interface{}{"parallelwalk": true})
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, p := range []string{"/c", "/a", "/b"} {
+		require.NoError(t, d.PutContent(ctx, p, []byte("contents")))
+	}
+
+	for i := 0; i < 10; i++ {
+		err := d.WalkParallel(ctx, "/", func(fInfo storagedriver.FileInfo) error {
+			return fmt.Errorf("error walking %s", fInfo.Path())
+		})
+		require.Error(t, err)
+
+		var merr *multierror.Error
+		require.True(t, errors.As(err, &merr))
+		// errors for files that were already being processed when the walk was canceled may or may not be reported,
+		// but they must always be sorted
+		require.True(t, sort.SliceIsSorted(merr.Errors, func(i, j int) bool {
+			return merr.Errors[i].Error() < merr.Errors[j].Error()
+		}))
+	}
+}
+
+func newTempDirDriver(t *testing.T, params ...map[string]interface{}) (*Driver, func()) {
 	t.Helper()
 
 	rootDir, err := ioutil.TempDir("", "driver-")
 	require.NoError(t, err)
 
-	d, err := FromParameters(map[string]interface{}{
+	parameters := map[string]interface{}{
 		"rootdirectory": rootDir,
-	})
+	}
+	for _, p := range params {
+		for k, v := range p {
+			parameters[k] = v
+		}
+	}
+
+	d, err := FromParameters(parameters)
 	require.NoError(t, err)
 
-	return d, func() { os.Remove(rootDir) }
+	return d, func() { os.RemoveAll(rootDir) }
 }
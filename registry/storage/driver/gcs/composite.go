@@ -0,0 +1,341 @@
+// +build include_gcs
+
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	defaultCompositeChunkSize   = 64 * minChunkSize
+	defaultCompositeConcurrency = 4
+	minCompositeConcurrency     = 1
+
+	// maxComposeSources is the maximum number of objects that can be composed
+	// into a new one with a single request.
+	maxComposeSources = 32
+
+	// componentsHeader is the header holding the number of components of an
+	// in progress composite upload.
+	componentsHeader = "X-Goog-Meta-Components"
+)
+
+// compositeWriter is a storagedriver.FileWriter which splits the content
+// written to it into component objects of a fixed size, uploaded
+// concurrently, and composes them into the final object on Commit. Components
+// are stored next to the target object, under the componentsPrefix.
+type compositeWriter struct {
+	storageClient *storage.Client
+	bucket        string
+	name          string
+	chunkSize     int
+	components    int
+	offset        int64
+	closed        bool
+	buffer        []byte
+	buffSize      int
+
+	// sem limits the number of components being uploaded concurrently.
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	errMu sync.Mutex
+	err   error
+}
+
+func (d *driver) newCompositeWriter(name string) *compositeWriter {
+	return &compositeWriter{
+		storageClient: d.storageClient,
+		bucket:        d.bucket,
+		name:          name,
+		chunkSize:     d.compositeChunkSize,
+		sem:           make(chan struct{}, d.compositeConcurrency),
+	}
+}
+
+// init restores the state of the composite upload stored in the object
+// returned by res.
+func (w *compositeWriter) init(res *http.Response) error {
+	components, err := strconv.Atoi(res.Header.Get(componentsHeader))
+	if err != nil {
+		return err
+	}
+	offset, err := strconv.ParseInt(res.Header.Get("X-Goog-Meta-Offset"), 10, 64)
+	if err != nil {
+		return err
+	}
+	w.components = components
+	w.offset = offset
+	return nil
+}
+
+func (w *compositeWriter) componentsPrefix() string {
+	return w.name + ".components/"
+}
+
+func (w *compositeWriter) componentName(n int) string {
+	return fmt.Sprintf("%s%06d", w.componentsPrefix(), n)
+}
+
+func (w *compositeWriter) setErr(err error) {
+	w.errMu.Lock()
+	defer w.errMu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *compositeWriter) getErr() error {
+	w.errMu.Lock()
+	defer w.errMu.Unlock()
+	return w.err
+}
+
+func (w *compositeWriter) checkClosed() error {
+	if w.closed {
+		return fmt.Errorf("Writer already closed")
+	}
+	return nil
+}
+
+// flush uploads the buffered content as the next component, in the
+// background. It blocks while the maximum number of components are being
+// uploaded already.
+func (w *compositeWriter) flush() error {
+	if err := w.getErr(); err != nil {
+		return err
+	}
+	if w.buffSize == 0 {
+		return nil
+	}
+
+	contents := w.buffer[:w.buffSize]
+	name := w.componentName(w.components)
+	w.components++
+	w.offset += int64(w.buffSize)
+	// the buffer is handed over to the upload, a new one is allocated on the
+	// next write
+	w.buffer = nil
+	w.buffSize = 0
+
+	w.sem <- struct{}{}
+	w.wg.Add(1)
+	go func() {
+		defer func() {
+			<-w.sem
+			w.wg.Done()
+		}()
+		err := retry(func() error {
+			wc := w.storageClient.Bucket(w.bucket).Object(name).NewWriter(context.Background())
+			wc.ContentType = "application/octet-stream"
+			return putContentsClose(wc, contents)
+		})
+		if err != nil {
+			w.setErr(fmt.Errorf("uploading component %q: %w", name, err))
+		}
+	}()
+
+	return nil
+}
+
+// wait flushes the buffered content and waits for all components to be
+// uploaded.
+func (w *compositeWriter) wait() error {
+	err := w.flush()
+	w.wg.Wait()
+	if err != nil {
+		return err
+	}
+	return w.getErr()
+}
+
+func (w *compositeWriter) Write(p []byte) (int, error) {
+	if err := w.checkClosed(); err != nil {
+		return 0, err
+	}
+
+	var nn int
+	for nn < len(p) {
+		if w.buffer == nil {
+			w.buffer = make([]byte, w.chunkSize)
+		}
+		n := copy(w.buffer[w.buffSize:], p[nn:])
+		w.buffSize += n
+		nn += n
+		if w.buffSize == len(w.buffer) {
+			if err := w.flush(); err != nil {
+				return nn, err
+			}
+		}
+	}
+	return nn, nil
+}
+
+// Size returns the number of bytes written to this FileWriter.
+func (w *compositeWriter) Size() int64 {
+	return w.offset + int64(w.buffSize)
+}
+
+// Close uploads any buffered content and persists the state of the upload, so
+// that it can be resumed.
+func (w *compositeWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if err := w.wait(); err != nil {
+		return err
+	}
+
+	return retry(func() error {
+		wc := w.storageClient.Bucket(w.bucket).Object(w.name).NewWriter(context.Background())
+		wc.ContentType = uploadSessionContentType
+		wc.Metadata = map[string]string{
+			"Components": strconv.Itoa(w.components),
+			"Offset":     strconv.FormatInt(w.offset, 10),
+		}
+		return putContentsClose(wc, nil)
+	})
+}
+
+// Cancel removes any written content from this FileWriter.
+func (w *compositeWriter) Cancel() error {
+	w.closed = true
+	w.wg.Wait()
+
+	err := storageDeleteObject(context.Background(), w.storageClient, w.bucket, w.name)
+	if err == storage.ErrObjectNotExist {
+		err = nil
+	}
+	w.deleteComponents()
+	return err
+}
+
+// Commit composes the uploaded components into the target object and makes it
+// available for future calls to StorageDriver.GetContent and
+// StorageDriver.Reader.
+func (w *compositeWriter) Commit() error {
+	if err := w.checkClosed(); err != nil {
+		return err
+	}
+	w.closed = true
+
+	if err := w.wait(); err != nil {
+		return err
+	}
+
+	if w.components == 0 {
+		return retry(func() error {
+			wc := w.storageClient.Bucket(w.bucket).Object(w.name).NewWriter(context.Background())
+			wc.ContentType = "application/octet-stream"
+			return putContentsClose(wc, nil)
+		})
+	}
+
+	sources := make([]string, w.components)
+	for i := range sources {
+		sources[i] = w.componentName(i)
+	}
+	if err := w.compose(sources); err != nil {
+		return err
+	}
+
+	w.deleteComponents()
+	return nil
+}
+
+// compose composes the sources objects, in order, into the target object. As
+// the number of sources of a single compose request is limited, sources are
+// composed into intermediate objects first, concurrently, as many times as
+// required.
+func (w *compositeWriter) compose(sources []string) error {
+	for level := 0; len(sources) > maxComposeSources; level++ {
+		groups := composeGroups(len(sources), maxComposeSources)
+		intermediates := make([]string, len(groups))
+		errs := make([]error, len(groups))
+
+		var wg sync.WaitGroup
+		for i, g := range groups {
+			intermediates[i] = fmt.Sprintf("%scomposed-%d-%06d", w.componentsPrefix(), level, i)
+
+			wg.Add(1)
+			w.sem <- struct{}{}
+			go func(i int, srcs []string) {
+				defer func() {
+					<-w.sem
+					wg.Done()
+				}()
+				errs[i] = w.composeObject(intermediates[i], srcs, "application/octet-stream")
+			}(i, sources[g[0]:g[1]])
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+		sources = intermediates
+	}
+
+	return w.composeObject(w.name, sources, "application/octet-stream")
+}
+
+func (w *compositeWriter) composeObject(dst string, sources []string, contentType string) error {
+	bkt := w.storageClient.Bucket(w.bucket)
+	srcs := make([]*storage.ObjectHandle, len(sources))
+	for i, src := range sources {
+		srcs[i] = bkt.Object(src)
+	}
+
+	return retry(func() error {
+		c := bkt.Object(dst).ComposerFrom(srcs...)
+		c.ContentType = contentType
+		_, err := c.Run(context.Background())
+		return err
+	})
+}
+
+// deleteComponents removes the component and intermediate objects of this
+// upload. This is done on a best effort basis, errors are only logged.
+func (w *compositeWriter) deleteComponents() {
+	ctx := context.Background()
+
+	it := w.storageClient.Bucket(w.bucket).Objects(ctx, &storage.Query{Prefix: w.componentsPrefix()})
+	for {
+		attrs, err := it.Next()
+		if err != nil {
+			if err != iterator.Done {
+				logrus.WithError(err).WithField("prefix", w.componentsPrefix()).Error("error listing upload components")
+			}
+			return
+		}
+		err = storageDeleteObject(ctx, w.storageClient, w.bucket, attrs.Name)
+		if err != nil && err != storage.ErrObjectNotExist {
+			logrus.WithError(err).WithField("name", attrs.Name).Error("error deleting upload component")
+		}
+	}
+}
+
+// composeGroups splits n sources into consecutive groups of at most max
+// sources each, returned as [start, end) index pairs.
+func composeGroups(n, max int) [][2]int {
+	groups := make([][2]int, 0, (n+max-1)/max)
+	for start := 0; start < n; start += max {
+		end := start + max
+		if end > n {
+			end = n
+		}
+		groups = append(groups, [2]int{start, end})
+	}
+	return groups
+}
@@ -52,14 +52,15 @@ import (
 const (
 	driverName = "gcs"
 
-	uploadSessionContentType = "application/x-docker-upload-session"
-	minChunkSize             = 256 * 1024
-	defaultChunkSize         = 20 * minChunkSize
-	defaultMaxConcurrency    = 50
-	minConcurrency           = 25
-	maxDeleteConcurrency     = 150
-	maxWalkConcurrency       = 100
-	maxTries                 = 5
+	uploadSessionContentType  = "application/x-docker-upload-session"
+	minChunkSize              = 256 * 1024
+	defaultChunkSize          = 20 * minChunkSize
+	defaultMaxConcurrency     = 50
+	minConcurrency            = 25
+	maxDeleteConcurrency      = 150
+	defaultMaxWalkConcurrency = 100
+	minWalkConcurrency        = 1
+	maxTries                  = 5
 )
 
 var rangeHeader = regexp.MustCompile(`^bytes=([0-9])+-([0-9]+)$`)
@@ -75,6 +76,10 @@ type driverParameters struct {
 	rootDirectory string
 	chunkSize     int
 
+	// signer signs URLs through the IAM Credentials API when no private key
+	// is available.
+	signer *iamSigner
+
 	// maxConcurrency limits the number of concurrent driver operations
 	// to GCS, which ultimately increases reliability of many simultaneous
 	// pushes by ensuring we aren't DoSing our own server with many
@@ -83,6 +88,21 @@ type driverParameters struct {
 
 	// parallelWalk enables or disables concurrently walking the filesystem.
 	parallelWalk bool
+
+	// maxWalkConcurrency limits the number of concurrent goroutines used
+	// while walking the filesystem in parallel.
+	maxWalkConcurrency uint64
+
+	// parallelCompositeUpload enables uploading written content as separate
+	// component objects, concurrently, which are composed on commit.
+	parallelCompositeUpload bool
+
+	// compositeChunkSize is the size of each component object.
+	compositeChunkSize int
+
+	// compositeConcurrency limits the number of component objects of a
+	// single writer uploaded concurrently.
+	compositeConcurrency int
 }
 
 func init() {
@@ -105,9 +125,16 @@ type driver struct {
 	bucket        string
 	email         string
 	privateKey    []byte
+	signer        *iamSigner
 	rootDirectory string
 	chunkSize     int
 	parallelWalk  bool
+
+	maxWalkConcurrency uint64
+
+	parallelCompositeUpload bool
+	compositeChunkSize      int
+	compositeConcurrency    int
 }
 
 // Wrapper wraps `driver` with a throttler, ensuring that no more than N
@@ -214,22 +241,47 @@ func FromParameters(parameters map[string]interface{}) (storagedriver.StorageDri
 		return nil, fmt.Errorf("storage client error: %s", err)
 	}
 
-	var parallelWalkBool bool
+	parallelWalkBool, err := getBoolParameter(parameters, "parallelwalk")
+	if err != nil {
+		return nil, err
+	}
 
-	parallelWalk := parameters["parallelwalk"]
-	switch parallelWalk := parallelWalk.(type) {
-	case string:
-		b, err := strconv.ParseBool(parallelWalk)
+	maxWalkConcurrency, err := base.GetLimitFromParameter(parameters["maxwalkconcurrency"], minWalkConcurrency, defaultMaxWalkConcurrency)
+	if err != nil {
+		return nil, fmt.Errorf("maxwalkconcurrency config error: %s", err)
+	}
+
+	parallelCompositeUpload, err := getBoolParameter(parameters, "parallelcompositeupload")
+	if err != nil {
+		return nil, err
+	}
+
+	compositeChunkSize, err := base.GetLimitFromParameter(parameters["compositechunksize"], minChunkSize, defaultCompositeChunkSize)
+	if err != nil {
+		return nil, fmt.Errorf("compositechunksize config error: %s", err)
+	}
+
+	compositeConcurrency, err := base.GetLimitFromParameter(parameters["compositeconcurrency"], minCompositeConcurrency, defaultCompositeConcurrency)
+	if err != nil {
+		return nil, fmt.Errorf("compositeconcurrency config error: %s", err)
+	}
+
+	signBlob, err := getBoolParameter(parameters, "signblob")
+	if err != nil {
+		return nil, err
+	}
+
+	// Signing URLs with a private key is preferred, as it does not require a request to the IAM Credentials API.
+	var signer *iamSigner
+	if signBlob && len(jwtConf.PrivateKey) == 0 {
+		var email string
+		if v, ok := parameters["serviceaccount"]; ok && v != nil {
+			email = fmt.Sprint(v)
+		}
+		signer, err = newIAMSigner(context.Background(), email)
 		if err != nil {
-			return nil, fmt.Errorf("the parallelwalk parameter should be a boolean")
+			return nil, err
 		}
-		parallelWalkBool = b
-	case bool:
-		parallelWalkBool = parallelWalk
-	case nil:
-		// do nothing
-	default:
-		return nil, fmt.Errorf("the parallelwalk parameter should be a boolean")
 	}
 
 	params := driverParameters{
@@ -237,16 +289,42 @@ func FromParameters(parameters map[string]interface{}) (storagedriver.StorageDri
 		rootDirectory:  fmt.Sprint(rootDirectory),
 		email:          jwtConf.Email,
 		privateKey:     jwtConf.PrivateKey,
+		signer:         signer,
 		client:         oauth2.NewClient(context.Background(), ts),
 		storageClient:  storageClient,
 		chunkSize:      chunkSize,
 		maxConcurrency: maxConcurrency,
 		parallelWalk:   parallelWalkBool,
+
+		maxWalkConcurrency: maxWalkConcurrency,
+
+		parallelCompositeUpload: parallelCompositeUpload,
+		compositeChunkSize:      int(compositeChunkSize),
+		compositeConcurrency:    int(compositeConcurrency),
 	}
 
 	return New(params)
 }
 
+// getBoolParameter returns the value of the boolean parameter name, or false
+// if not set.
+func getBoolParameter(parameters map[string]interface{}, name string) (bool, error) {
+	switch v := parameters[name].(type) {
+	case string:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, fmt.Errorf("the %s parameter should be a boolean", name)
+		}
+		return b, nil
+	case bool:
+		return v, nil
+	case nil:
+		return false, nil
+	default:
+		return false, fmt.Errorf("the %s parameter should be a boolean", name)
+	}
+}
+
 // New constructs a new driver
 func New(params driverParameters) (storagedriver.StorageDriver, error) {
 	rootDirectory := strings.Trim(params.rootDirectory, "/")
@@ -256,15 +334,31 @@ func New(params driverParameters) (storagedriver.StorageDriver, error) {
 	if params.chunkSize <= 0 || params.chunkSize%minChunkSize != 0 {
 		return nil, fmt.Errorf("Invalid chunksize: %d is not a positive multiple of %d", params.chunkSize, minChunkSize)
 	}
+	if params.maxWalkConcurrency == 0 {
+		params.maxWalkConcurrency = defaultMaxWalkConcurrency
+	}
+	if params.compositeChunkSize <= 0 {
+		params.compositeChunkSize = defaultCompositeChunkSize
+	}
+	if params.compositeConcurrency <= 0 {
+		params.compositeConcurrency = defaultCompositeConcurrency
+	}
 	d := &driver{
 		bucket:        params.bucket,
 		rootDirectory: rootDirectory,
 		email:         params.email,
 		privateKey:    params.privateKey,
+		signer:        params.signer,
 		client:        params.client,
 		storageClient: params.storageClient,
 		chunkSize:     params.chunkSize,
 		parallelWalk:  params.parallelWalk,
+
+		maxWalkConcurrency: params.maxWalkConcurrency,
+
+		parallelCompositeUpload: params.parallelCompositeUpload,
+		compositeChunkSize:      params.compositeChunkSize,
+		compositeConcurrency:    params.compositeConcurrency,
 	}
 
 	return &Wrapper{
@@ -390,23 +484,52 @@ func getObject(client *http.Client, bucket string, name string, offset int64) (*
 }
 
 // Writer returns a FileWriter which will store the content written to it
-// at the location designated by "path" after the call to Commit.
+// at the location designated by "path" after the call to Commit. If parallel
+// composite uploads are enabled, the content is uploaded as separate component
+// objects. Appending to existing content resumes the upload as it was started,
+// regardless of the current configuration.
 func (d *driver) Writer(ctx context.Context, path string, append bool) (storagedriver.FileWriter, error) {
-	writer := &writer{
-		client:        d.client,
-		storageClient: d.storageClient,
-		bucket:        d.bucket,
-		name:          d.pathToKey(path),
-		buffer:        make([]byte, d.chunkSize),
+	name := d.pathToKey(path)
+
+	if !append {
+		if d.parallelCompositeUpload {
+			return d.newCompositeWriter(name), nil
+		}
+		return d.newWriter(name), nil
 	}
 
-	if append {
-		err := writer.init(path)
-		if err != nil {
+	res, err := getObject(d.client, d.bucket, name, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.Header.Get("Content-Type") != uploadSessionContentType {
+		return nil, storagedriver.PathNotFoundError{Path: path}
+	}
+
+	if res.Header.Get(componentsHeader) != "" {
+		cw := d.newCompositeWriter(name)
+		if err := cw.init(res); err != nil {
 			return nil, err
 		}
+		return cw, nil
+	}
+
+	w := d.newWriter(name)
+	if err := w.init(res); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (d *driver) newWriter(name string) *writer {
+	return &writer{
+		client:        d.client,
+		storageClient: d.storageClient,
+		bucket:        d.bucket,
+		name:          name,
+		buffer:        make([]byte, d.chunkSize),
 	}
-	return writer, nil
 }
 
 type writer struct {
@@ -597,15 +720,9 @@ func (w *writer) Size() int64 {
 	return w.size
 }
 
-func (w *writer) init(path string) error {
-	res, err := getObject(w.client, w.bucket, w.name, 0)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-	if res.Header.Get("Content-Type") != uploadSessionContentType {
-		return storagedriver.PathNotFoundError{Path: path}
-	}
+// init restores the state of the upload session stored in the object returned
+// by res.
+func (w *writer) init(res *http.Response) error {
 	offset, err := strconv.ParseInt(res.Header.Get("X-Goog-Meta-Offset"), 10, 64)
 	if err != nil {
 		return err
@@ -925,9 +1042,10 @@ func storageCopyObject(ctx context.Context, client *storage.Client, srcBucket, s
 
 // URLFor returns a URL which may be used to retrieve the content stored at
 // the given path, possibly using the given options.
-// Returns ErrUnsupportedMethod if this driver has no privateKey
+// Returns ErrUnsupportedMethod if this driver has neither a privateKey nor an
+// IAM signer
 func (d *driver) URLFor(ctx context.Context, path string, options map[string]interface{}) (string, error) {
-	if d.privateKey == nil {
+	if d.privateKey == nil && d.signer == nil {
 		return "", storagedriver.ErrUnsupportedMethod{}
 	}
 
@@ -956,6 +1074,12 @@ func (d *driver) URLFor(ctx context.Context, path string, options map[string]int
 		Method:         methodString,
 		Expires:        expiresTime,
 	}
+	if d.privateKey == nil {
+		opts.GoogleAccessID = d.signer.email
+		opts.SignBytes = func(b []byte) ([]byte, error) {
+			return d.signer.signBlob(ctx, b)
+		}
+	}
 	return storage.SignedURL(d.bucket, name, opts)
 }
 
@@ -973,7 +1097,7 @@ func (d *driver) WalkParallel(ctx context.Context, path string, f storagedriver.
 		return d.Walk(ctx, path, f)
 	}
 
-	return storagedriver.WalkFallbackParallel(ctx, d, maxWalkConcurrency, path, f)
+	return storagedriver.WalkFallbackParallel(ctx, d, d.maxWalkConcurrency, path, f)
 }
 
 // TransferTo writes the content from the source driver and the source path, to
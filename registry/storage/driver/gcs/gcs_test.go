@@ -4,10 +4,15 @@ package gcs
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"reflect"
 	"sort"
@@ -19,6 +24,7 @@ import (
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iamcredentials/v1"
 	"google.golang.org/api/option"
 	"gopkg.in/check.v1"
 
@@ -32,6 +38,7 @@ func Test(t *testing.T) { check.TestingT(t) }
 
 var gcsDriverConstructor func(rootDirectory string) (storagedriver.StorageDriver, error)
 var gcsTargetDriverConstructor func(rootDirectory string) (storagedriver.StorageDriver, error)
+var gcsCompositeDriverConstructor func(rootDirectory string, chunkSize int) (storagedriver.StorageDriver, error)
 var skipGCS func() string
 var skipGCSTransferTo func() string
 
@@ -42,6 +49,7 @@ func init() {
 	migrationBucket := os.Getenv("REGISTRY_STORAGE_GCS_TARGET_BUCKET")
 	credentials := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
 	parallelWalk := os.Getenv("GCS_PARALLEL_WALK")
+	parallelCompositeUpload := os.Getenv("GCS_PARALLEL_COMPOSITE_UPLOAD")
 
 	// Skip GCS storage driver tests if environment variable parameters are not provided
 	skipGCS = func() string {
@@ -105,6 +113,16 @@ func init() {
 		}
 	}
 
+	var parallelCompositeUploadBool bool
+
+	if parallelCompositeUpload != "" {
+		parallelCompositeUploadBool, err = strconv.ParseBool(parallelCompositeUpload)
+
+		if err != nil {
+			panic(fmt.Sprintf("Error parsing parallelcompositeupload: %v", err))
+		}
+	}
+
 	gcsDriverConstructor = func(rootDirectory string) (storagedriver.StorageDriver, error) {
 		parameters := driverParameters{
 			bucket:         bucket,
@@ -116,6 +134,26 @@ func init() {
 			chunkSize:      defaultChunkSize,
 			maxConcurrency: maxConcurrency,
 			parallelWalk:   parallelWalkBool,
+
+			parallelCompositeUpload: parallelCompositeUploadBool,
+		}
+
+		return New(parameters)
+	}
+
+	gcsCompositeDriverConstructor = func(rootDirectory string, chunkSize int) (storagedriver.StorageDriver, error) {
+		parameters := driverParameters{
+			bucket:         bucket,
+			rootDirectory:  root,
+			email:          email,
+			privateKey:     privateKey,
+			client:         oauth2.NewClient(dcontext.Background(), ts),
+			storageClient:  storageClient,
+			chunkSize:      defaultChunkSize,
+			maxConcurrency: maxConcurrency,
+
+			parallelCompositeUpload: true,
+			compositeChunkSize:      chunkSize,
 		}
 
 		return New(parameters)
@@ -605,3 +643,109 @@ func TestTransferToExistingDest(t *testing.T) {
 	require.NoError(t, err)
 	require.EqualValues(t, srcContent, c)
 }
+
+func TestComposeGroups(t *testing.T) {
+	tests := []struct {
+		n        int
+		expected [][2]int
+	}{
+		{n: 0, expected: [][2]int{}},
+		{n: 1, expected: [][2]int{{0, 1}}},
+		{n: 32, expected: [][2]int{{0, 32}}},
+		{n: 33, expected: [][2]int{{0, 32}, {32, 33}}},
+		{n: 70, expected: [][2]int{{0, 32}, {32, 64}, {64, 70}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.n), func(t *testing.T) {
+			require.Equal(t, tt.expected, composeGroups(tt.n, maxComposeSources))
+		})
+	}
+}
+
+// Test writing, resuming and committing a composite upload with enough
+// components to require intermediate composes.
+func TestCompositeWriter(t *testing.T) {
+	if skipGCS() != "" {
+		t.Skip(skipGCS())
+	}
+
+	validRoot, err := ioutil.TempDir("", "driver-")
+	require.NoError(t, err)
+	defer os.Remove(validRoot)
+
+	driver, err := gcsCompositeDriverConstructor(validRoot, minChunkSize)
+	require.NoError(t, err)
+
+	filename := "/test-composite"
+	ctx := context.Background()
+	defer driver.Delete(ctx, filename)
+
+	contents := make([]byte, (maxComposeSources+2)*minChunkSize+10)
+	rand.Read(contents)
+	split := len(contents) / 2
+
+	writer, err := driver.Writer(ctx, filename, false)
+	require.NoError(t, err)
+	_, err = writer.Write(contents[:split])
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.EqualValues(t, split, writer.Size())
+
+	writer, err = driver.Writer(ctx, filename, true)
+	require.NoError(t, err)
+	require.EqualValues(t, split, writer.Size())
+	_, err = writer.Write(contents[split:])
+	require.NoError(t, err)
+	require.NoError(t, writer.Commit())
+	require.NoError(t, writer.Close())
+	require.EqualValues(t, len(contents), writer.Size())
+
+	readContents, err := driver.GetContent(ctx, filename)
+	require.NoError(t, err)
+	require.Equal(t, contents, readContents)
+
+	// components must have been removed
+	_, err = driver.List(ctx, filename+".components")
+	require.IsType(t, storagedriver.PathNotFoundError{}, err)
+}
+
+func TestURLForWithIAMSigner(t *testing.T) {
+	const email = "registry@example.iam.gserviceaccount.com"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/projects/-/serviceAccounts/"+email+":signBlob", r.URL.Path)
+
+		var req iamcredentials.SignBlobRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		payload, err := base64.StdEncoding.DecodeString(req.Payload)
+		require.NoError(t, err)
+
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(&iamcredentials.SignBlobResponse{
+			SignedBlob: base64.StdEncoding.EncodeToString(append([]byte("signed:"), payload...)),
+		}))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	signer, err := newIAMSigner(ctx, email, option.WithEndpoint(srv.URL+"/"), option.WithoutAuthentication())
+	require.NoError(t, err)
+
+	sig, err := signer.signBlob(ctx, []byte("foo"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("signed:foo"), sig)
+
+	d := &driver{bucket: "bucket", signer: signer}
+	u, err := d.URLFor(ctx, "/foo/bar", nil)
+	require.NoError(t, err)
+
+	parsed, err := url.Parse(u)
+	require.NoError(t, err)
+	require.Equal(t, email, parsed.Query().Get("GoogleAccessId"))
+	require.NotEmpty(t, parsed.Query().Get("Signature"))
+
+	// without a private key or signer, URLs are not supported
+	_, err = (&driver{bucket: "bucket"}).URLFor(ctx, "/foo/bar", nil)
+	require.IsType(t, storagedriver.ErrUnsupportedMethod{}, err)
+}
@@ -0,0 +1,58 @@
+// +build include_gcs
+
+package gcs
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"cloud.google.com/go/compute/metadata"
+	"google.golang.org/api/iamcredentials/v1"
+	"google.golang.org/api/option"
+)
+
+// iamSigner signs URLs on behalf of a service account using the IAM Credentials signBlob API. This allows generating
+// signed URLs when no private key is available to sign them locally, such as when running with workload identity on
+// GKE. The credentials of the registry must be granted the iam.serviceAccounts.signBlob permission on the service
+// account, e.g. through the roles/iam.serviceAccountTokenCreator role.
+type iamSigner struct {
+	service *iamcredentials.Service
+	email   string
+}
+
+// newIAMSigner creates an iamSigner for the service account with the given email, authenticating with the
+// application default credentials unless otherwise specified by opts. If email is empty, the service account of the
+// instance (or of the workload, with workload identity) is obtained from the metadata server.
+func newIAMSigner(ctx context.Context, email string, opts ...option.ClientOption) (*iamSigner, error) {
+	if email == "" {
+		if !metadata.OnGCE() {
+			return nil, fmt.Errorf("the serviceaccount parameter is required to use signblob outside of Google Cloud")
+		}
+		var err error
+		if email, err = metadata.Email("default"); err != nil {
+			return nil, fmt.Errorf("obtaining service account email from metadata server: %w", err)
+		}
+	}
+
+	opts = append([]option.ClientOption{option.WithScopes(iamcredentials.CloudPlatformScope)}, opts...)
+	service, err := iamcredentials.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating IAM credentials client: %w", err)
+	}
+
+	return &iamSigner{service: service, email: email}, nil
+}
+
+// signBlob signs b with the system-managed private key of the service account.
+func (s *iamSigner) signBlob(ctx context.Context, b []byte) ([]byte, error) {
+	name := "projects/-/serviceAccounts/" + s.email
+	resp, err := s.service.Projects.ServiceAccounts.SignBlob(name, &iamcredentials.SignBlobRequest{
+		Payload: base64.StdEncoding.EncodeToString(b),
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("signing blob with IAM credentials API: %w", err)
+	}
+
+	return base64.StdEncoding.DecodeString(resp.SignedBlob)
+}
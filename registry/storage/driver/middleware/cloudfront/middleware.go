@@ -9,7 +9,10 @@ import (
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,10 +27,14 @@ import (
 // then issues HTTP Temporary Redirects to this CloudFront content URL.
 type cloudFrontStorageMiddleware struct {
 	storagedriver.StorageDriver
-	awsIPs    *awsIPs
-	urlSigner *sign.URLSigner
-	baseURL   string
-	duration  time.Duration
+	awsIPs       *awsIPs
+	urlSigner    *sign.URLSigner
+	cookieSigner *sign.CookieSigner
+	baseURL      string
+	duration     time.Duration
+	customPolicy bool
+	sourceIP     string
+	cookieDomain string
 }
 
 var _ storagedriver.StorageDriver = &cloudFrontStorageMiddleware{}
@@ -36,10 +43,14 @@ var _ storagedriver.StorageDriver = &cloudFrontStorageMiddleware{}
 // LayerHandler implementation.
 // Required options: baseurl, privatekey, keypairid
 
-// Optional options: ipFilteredBy, awsregion
+// Optional options: ipFilteredBy, awsregion, policy, sourceip, signedcookies, cookiedomain
 // ipfilteredby: valid value "none|aws|awsregion". "none", do not filter any IP, default value. "aws", only aws IP goes
 //               to S3 directly. "awsregion", only regions listed in awsregion options goes to S3 directly
 // awsregion: a comma separated string of AWS regions.
+// policy: valid value "canned|custom". "canned", the default, only restricts the resource and expiration time.
+// sourceip: a CIDR from which signed URLs may be used. Requires the custom policy, which is the default if set.
+// signedcookies: issue signed cookies instead of signing URLs. Requires the custom policy, which is the default if set.
+// cookiedomain: the domain of signed cookies, which must include the CloudFront domain.
 func newCloudFrontStorageMiddleware(storageDriver storagedriver.StorageDriver, options map[string]interface{}) (storagedriver.StorageDriver, error) {
 	// parse baseurl
 	base, ok := options["baseurl"]
@@ -167,12 +178,80 @@ func newCloudFrontStorageMiddleware(storageDriver storagedriver.StorageDriver, o
 		}
 	}
 
+	// parse sourceip
+	var sourceIP string
+	if i, ok := options["sourceip"]; ok {
+		if sourceIP, ok = i.(string); !ok {
+			return nil, fmt.Errorf("sourceip must be a string")
+		}
+		if _, _, err := net.ParseCIDR(sourceIP); err != nil {
+			return nil, fmt.Errorf("invalid sourceip: %s", err)
+		}
+	}
+
+	// parse signedcookies
+	var signedCookies bool
+	if i, ok := options["signedcookies"]; ok {
+		switch v := i.(type) {
+		case bool:
+			signedCookies = v
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("signedcookies must be a boolean")
+			}
+			signedCookies = b
+		default:
+			return nil, fmt.Errorf("signedcookies must be a boolean")
+		}
+	}
+
+	// parse cookiedomain
+	var cookieDomain string
+	if i, ok := options["cookiedomain"]; ok {
+		if cookieDomain, ok = i.(string); !ok {
+			return nil, fmt.Errorf("cookiedomain must be a string")
+		}
+	}
+
+	// parse policy, signed cookies are always issued with a custom policy
+	customPolicy := sourceIP != "" || signedCookies
+	if i, ok := options["policy"]; ok {
+		policy, ok := i.(string)
+		if !ok {
+			return nil, fmt.Errorf("policy must be a string with the following value: canned|custom")
+		}
+		switch strings.ToLower(strings.TrimSpace(policy)) {
+		case "", "canned":
+			if customPolicy {
+				return nil, fmt.Errorf("sourceip and signedcookies require the custom policy")
+			}
+		case "custom":
+			customPolicy = true
+		default:
+			return nil, fmt.Errorf("policy only allows a string with the following value: canned|custom")
+		}
+	}
+
+	var cookieSigner *sign.CookieSigner
+	if signedCookies {
+		cookieSigner = sign.NewCookieSigner(keypairID, privateKey, func(o *sign.CookieOptions) {
+			o.Path = "/"
+			o.Domain = cookieDomain
+			o.Secure = strings.HasPrefix(baseURL, "https://")
+		})
+	}
+
 	return &cloudFrontStorageMiddleware{
 		StorageDriver: storageDriver,
 		urlSigner:     urlSigner,
+		cookieSigner:  cookieSigner,
 		baseURL:       baseURL,
 		duration:      duration,
 		awsIPs:        awsIPs,
+		customPolicy:  customPolicy,
+		sourceIP:      sourceIP,
+		cookieDomain:  cookieDomain,
 	}, nil
 }
 
@@ -196,12 +275,50 @@ func (lh *cloudFrontStorageMiddleware) URLFor(ctx context.Context, path string,
 		return lh.StorageDriver.URLFor(ctx, path, options)
 	}
 
-	// Get signed cloudfront url.
-	cfURL, err := lh.urlSigner.Sign(lh.baseURL+keyer.S3BucketKey(path), time.Now().Add(lh.duration))
-	if err != nil {
-		return "", err
+	// the expiration time can be overridden per request
+	expires := time.Now().Add(lh.duration)
+	if e, ok := options["expiry"]; ok {
+		if et, ok := e.(time.Time); ok {
+			expires = et
+		}
+	}
+
+	cfURL := lh.baseURL + keyer.S3BucketKey(path)
+	if !lh.customPolicy {
+		// Get signed cloudfront url.
+		return lh.urlSigner.Sign(cfURL, expires)
+	}
+
+	policy := &sign.Policy{
+		Statements: []sign.Statement{
+			{
+				Resource: cfURL,
+				Condition: sign.Condition{
+					DateLessThan: sign.NewAWSEpochTime(expires),
+				},
+			},
+		},
 	}
-	return cfURL, nil
+	if lh.sourceIP != "" {
+		policy.Statements[0].Condition.IPAddress = &sign.IPAddress{SourceIP: lh.sourceIP}
+	}
+
+	if lh.cookieSigner != nil {
+		w, err := dcontext.GetResponseWriter(ctx)
+		if err == nil {
+			cookies, err := lh.cookieSigner.SignWithPolicy(policy)
+			if err != nil {
+				return "", err
+			}
+			for _, c := range cookies {
+				http.SetCookie(w, c)
+			}
+			return cfURL, nil
+		}
+		dcontext.GetLogger(ctx).WithError(err).Warn("the CloudFront middleware cannot set signed cookies, falling back to a signed URL")
+	}
+
+	return lh.urlSigner.SignWithPolicy(cfURL, policy)
 }
 
 // init registers the cloudfront layerHandler backend.
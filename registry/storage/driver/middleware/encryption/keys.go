@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Every encrypted object starts with a fixed size header, followed by the content encrypted with AES-256 in CTR mode.
+// The header holds a random data key, unique to each object, wrapped (AES-256-GCM) with one of the configured key
+// encryption keys:
+//
+//	| magic (4) | version (1) | key fingerprint (8) | nonce (12) | wrapped data key (48) | IV (16) |
+//
+// CTR mode allows decrypting from any offset and resuming writes, which are both required by the registry. Content
+// integrity is not covered by the encryption, as the registry already verifies blobs against their digest.
+const (
+	headerMagic       = "DREN"
+	headerMagicSize   = 4
+	headerVersion     = 1
+	fingerprintSize   = 8
+	nonceSize         = 12
+	dataKeySize       = 32
+	wrappedDataKeyLen = dataKeySize + 16
+	headerSize        = headerMagicSize + 1 + fingerprintSize + nonceSize + wrappedDataKeyLen + aes.BlockSize
+)
+
+var errKeyUnknown = errors.New("object was encrypted with an unknown key")
+
+type fingerprint [fingerprintSize]byte
+
+// keyEncryptionKey is a key used to wrap data keys.
+type keyEncryptionKey struct {
+	fingerprint fingerprint
+	aead        cipher.AEAD
+}
+
+func newKeyEncryptionKey(encoded string) (*keyEncryptionKey, error) {
+	k, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decoding key: %w", err)
+	}
+	if len(k) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes long, got %d", len(k))
+	}
+
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	kek := &keyEncryptionKey{aead: aead}
+	sum := sha256.Sum256(k)
+	copy(kek.fingerprint[:], sum[:])
+
+	return kek, nil
+}
+
+// keyring holds the key encryption keys. The primary key wraps the data keys of new objects, while all keys can be
+// used to unwrap the data keys of existing objects, allowing keys to be rotated.
+type keyring struct {
+	primary *keyEncryptionKey
+	keys    map[fingerprint]*keyEncryptionKey
+}
+
+func newKeyring(encoded []string) (*keyring, error) {
+	if len(encoded) == 0 {
+		return nil, errors.New("at least one key is required")
+	}
+
+	kr := &keyring{keys: make(map[fingerprint]*keyEncryptionKey, len(encoded))}
+	for i, e := range encoded {
+		kek, err := newKeyEncryptionKey(e)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key at index %d: %w", i, err)
+		}
+		if _, ok := kr.keys[kek.fingerprint]; ok {
+			return nil, fmt.Errorf("duplicate key at index %d", i)
+		}
+		if kr.primary == nil {
+			kr.primary = kek
+		}
+		kr.keys[kek.fingerprint] = kek
+	}
+
+	return kr, nil
+}
+
+// envelope holds the data key and IV used to encrypt the content of an object.
+type envelope struct {
+	block cipher.Block
+	iv    []byte
+}
+
+// stream returns a CTR key stream positioned at the given content offset.
+func (e *envelope) stream(offset int64) cipher.Stream {
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, e.iv)
+
+	// the IV is a 128-bit big-endian counter, incremented once per block
+	hi := binary.BigEndian.Uint64(iv[:8])
+	lo := binary.BigEndian.Uint64(iv[8:])
+	blocks := uint64(offset / aes.BlockSize)
+	if lo+blocks < lo {
+		hi++
+	}
+	lo += blocks
+	binary.BigEndian.PutUint64(iv[:8], hi)
+	binary.BigEndian.PutUint64(iv[8:], lo)
+
+	s := cipher.NewCTR(e.block, iv)
+	if skip := offset % aes.BlockSize; skip > 0 {
+		discard := make([]byte, skip)
+		s.XORKeyStream(discard, discard)
+	}
+
+	return s
+}
+
+// newEnvelope generates a new data key and IV, returning them along with the encoded header.
+func (kr *keyring) newEnvelope() (*envelope, []byte, error) {
+	dataKey := make([]byte, dataKeySize)
+	nonce := make([]byte, nonceSize)
+	iv := make([]byte, aes.BlockSize)
+	for _, b := range [][]byte{dataKey, nonce, iv} {
+		if _, err := io.ReadFull(rand.Reader, b); err != nil {
+			return nil, nil, fmt.Errorf("generating random data: %w", err)
+		}
+	}
+
+	header := make([]byte, 0, headerSize)
+	header = append(header, headerMagic...)
+	header = append(header, headerVersion)
+	header = append(header, kr.primary.fingerprint[:]...)
+	// the preceding header fields are authenticated along with the data key
+	aad := header
+	header = append(header, nonce...)
+	header = kr.primary.aead.Seal(header, nonce, dataKey, aad)
+	header = append(header, iv...)
+
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &envelope{block: block, iv: iv}, header, nil
+}
+
+// isEncrypted reports whether b starts with an encryption header. Objects written before enabling encryption do not.
+func isEncrypted(b []byte) bool {
+	return len(b) >= headerSize && bytes.HasPrefix(b, []byte(headerMagic)) && b[headerMagicSize] == headerVersion
+}
+
+// openEnvelope unwraps the data key from the given header.
+func (kr *keyring) openEnvelope(header []byte) (*envelope, error) {
+	if !isEncrypted(header) {
+		return nil, errors.New("invalid encryption header")
+	}
+
+	pos := headerMagicSize + 1
+	var fp fingerprint
+	copy(fp[:], header[pos:pos+fingerprintSize])
+	kek, ok := kr.keys[fp]
+	if !ok {
+		return nil, errKeyUnknown
+	}
+
+	aad := header[:pos+fingerprintSize]
+	pos += fingerprintSize
+	nonce := header[pos : pos+nonceSize]
+	pos += nonceSize
+	dataKey, err := kek.aead.Open(nil, nonce, header[pos:pos+wrappedDataKeyLen], aad)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping data key: %w", err)
+	}
+	pos += wrappedDataKeyLen
+
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, header[pos:pos+aes.BlockSize])
+
+	return &envelope{block: block, iv: iv}, nil
+}
@@ -0,0 +1,289 @@
+// Package middleware - encryption wrapper for storage drivers
+//
+// Content is encrypted before being written to the storage backend and decrypted when read back, for backends that
+// can't provide adequate server side encryption. Objects written before enabling this middleware are read as is.
+package middleware
+
+import (
+	"context"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	storagemiddleware "github.com/docker/distribution/registry/storage/driver/middleware"
+)
+
+// envelopeSuffix is appended to the path of objects being written to store a copy of their encryption header. This is
+// required to resume writes, as most backends do not allow reading uncommitted content.
+const envelopeSuffix = ".envelope"
+
+type encryptionStorageMiddleware struct {
+	storagedriver.StorageDriver
+	keys *keyring
+}
+
+var _ storagedriver.StorageDriver = &encryptionStorageMiddleware{}
+
+// newEncryptionStorageMiddleware constructs a new encryption storage middleware.
+// Required options: keys, a list of base64 encoded 32 bytes keys. The first key is used to encrypt new content, all
+// keys are used to decrypt existing content.
+func newEncryptionStorageMiddleware(sd storagedriver.StorageDriver, options map[string]interface{}) (storagedriver.StorageDriver, error) {
+	o, ok := options["keys"]
+	if !ok {
+		return nil, fmt.Errorf("no keys provided")
+	}
+	list, ok := o.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("keys must be a list of strings")
+	}
+	encoded := make([]string, 0, len(list))
+	for _, v := range list {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("keys must be a list of strings")
+		}
+		encoded = append(encoded, s)
+	}
+
+	kr, err := newKeyring(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptionStorageMiddleware{StorageDriver: sd, keys: kr}, nil
+}
+
+// GetContent retrieves and decrypts the content stored at path.
+func (m *encryptionStorageMiddleware) GetContent(ctx context.Context, path string) ([]byte, error) {
+	b, err := m.StorageDriver.GetContent(ctx, path)
+	if err != nil || !isEncrypted(b) {
+		return b, err
+	}
+
+	env, err := m.keys.openEnvelope(b[:headerSize])
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %s: %w", path, err)
+	}
+	content := make([]byte, len(b)-headerSize)
+	env.stream(0).XORKeyStream(content, b[headerSize:])
+
+	return content, nil
+}
+
+// PutContent encrypts and stores content at path.
+func (m *encryptionStorageMiddleware) PutContent(ctx context.Context, path string, content []byte) error {
+	env, header, err := m.keys.newEnvelope()
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, headerSize+len(content))
+	copy(b, header)
+	env.stream(0).XORKeyStream(b[headerSize:], content)
+
+	return m.StorageDriver.PutContent(ctx, path, b)
+}
+
+// readEnvelope returns the envelope of the object stored at path, or nil if the object is not encrypted.
+func (m *encryptionStorageMiddleware) readEnvelope(ctx context.Context, path string) (*envelope, error) {
+	rc, err := m.StorageDriver.Reader(ctx, path, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(rc, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// too small to be encrypted
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !isEncrypted(header) {
+		return nil, nil
+	}
+
+	env, err := m.keys.openEnvelope(header)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %s: %w", path, err)
+	}
+
+	return env, nil
+}
+
+// Reader returns a reader for the decrypted content stored at path, starting at the given offset.
+func (m *encryptionStorageMiddleware) Reader(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
+	env, err := m.readEnvelope(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if env == nil {
+		return m.StorageDriver.Reader(ctx, path, offset)
+	}
+
+	rc, err := m.StorageDriver.Reader(ctx, path, headerSize+offset)
+	if err != nil {
+		if errors.As(err, &storagedriver.InvalidOffsetError{}) {
+			return nil, storagedriver.InvalidOffsetError{Path: path, Offset: offset, DriverName: m.Name()}
+		}
+		return nil, err
+	}
+
+	return &decryptingReader{ReadCloser: rc, stream: env.stream(offset)}, nil
+}
+
+// Stat returns the file info of the object stored at path. The size of encrypted objects excludes the header.
+func (m *encryptionStorageMiddleware) Stat(ctx context.Context, path string) (storagedriver.FileInfo, error) {
+	fi, err := m.StorageDriver.Stat(ctx, path)
+	if err != nil || fi.IsDir() || fi.Size() < headerSize {
+		return fi, err
+	}
+
+	env, err := m.readEnvelope(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if env == nil {
+		return fi, nil
+	}
+
+	return storagedriver.FileInfoInternal{FileInfoFields: storagedriver.FileInfoFields{
+		Path:    fi.Path(),
+		Size:    fi.Size() - headerSize,
+		ModTime: fi.ModTime(),
+		IsDir:   false,
+	}}, nil
+}
+
+// Writer returns a FileWriter that encrypts the content written to it. Appending to an object requires its envelope
+// to be available, which is the case for all objects written through this middleware.
+func (m *encryptionStorageMiddleware) Writer(ctx context.Context, path string, append bool) (storagedriver.FileWriter, error) {
+	envelopePath := path + envelopeSuffix
+
+	if !append {
+		env, header, err := m.keys.newEnvelope()
+		if err != nil {
+			return nil, err
+		}
+		if err := m.StorageDriver.PutContent(ctx, envelopePath, header); err != nil {
+			return nil, fmt.Errorf("storing envelope: %w", err)
+		}
+		fw, err := m.StorageDriver.Writer(ctx, path, false)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fw.Write(header); err != nil {
+			fw.Cancel()
+			return nil, fmt.Errorf("writing header: %w", err)
+		}
+
+		return m.newEncryptingWriter(ctx, fw, env, envelopePath), nil
+	}
+
+	header, err := m.StorageDriver.GetContent(ctx, envelopePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading envelope: %w", err)
+	}
+	env, err := m.keys.openEnvelope(header)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %s: %w", path, err)
+	}
+	fw, err := m.StorageDriver.Writer(ctx, path, true)
+	if err != nil {
+		return nil, err
+	}
+	if fw.Size() < headerSize {
+		fw.Close()
+		return nil, fmt.Errorf("resuming write of %s: missing encryption header", path)
+	}
+
+	return m.newEncryptingWriter(ctx, fw, env, envelopePath), nil
+}
+
+// URLFor is not supported, as clients would not be able to decrypt the content. Content is served by the registry.
+func (m *encryptionStorageMiddleware) URLFor(ctx context.Context, path string, options map[string]interface{}) (string, error) {
+	return "", storagedriver.ErrUnsupportedMethod{DriverName: m.Name()}
+}
+
+// TransferTo copies the stored content as is, without decrypting it. Backends require the destination driver to be of
+// their own type, so the destination is unwrapped if it uses this middleware too, in which case it must be configured
+// with the same keys to be able to read the transferred content.
+func (m *encryptionStorageMiddleware) TransferTo(ctx context.Context, destDriver storagedriver.StorageDriver, src, dest string) error {
+	if d, ok := destDriver.(*encryptionStorageMiddleware); ok {
+		destDriver = d.StorageDriver
+	}
+	return m.StorageDriver.TransferTo(ctx, destDriver, src, dest)
+}
+
+type decryptingReader struct {
+	io.ReadCloser
+	stream cipher.Stream
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.stream.XORKeyStream(p[:n], p[:n])
+	return n, err
+}
+
+type encryptingWriter struct {
+	storagedriver.FileWriter
+	ctx          context.Context
+	driver       storagedriver.StorageDriver
+	envelopePath string
+	stream       cipher.Stream
+	buf          []byte
+}
+
+func (m *encryptionStorageMiddleware) newEncryptingWriter(ctx context.Context, fw storagedriver.FileWriter, env *envelope, envelopePath string) *encryptingWriter {
+	return &encryptingWriter{
+		FileWriter:   fw,
+		ctx:          ctx,
+		driver:       m.StorageDriver,
+		envelopePath: envelopePath,
+		stream:       env.stream(fw.Size() - headerSize),
+	}
+}
+
+func (w *encryptingWriter) Write(p []byte) (int, error) {
+	if cap(w.buf) < len(p) {
+		w.buf = make([]byte, len(p))
+	}
+	b := w.buf[:len(p)]
+	w.stream.XORKeyStream(b, p)
+
+	return w.FileWriter.Write(b)
+}
+
+// Size returns the number of content bytes written, excluding the header.
+func (w *encryptingWriter) Size() int64 {
+	return w.FileWriter.Size() - headerSize
+}
+
+func (w *encryptingWriter) Commit() error {
+	if err := w.FileWriter.Commit(); err != nil {
+		return err
+	}
+	return w.deleteEnvelope()
+}
+
+func (w *encryptingWriter) Cancel() error {
+	if err := w.FileWriter.Cancel(); err != nil {
+		return err
+	}
+	return w.deleteEnvelope()
+}
+
+func (w *encryptingWriter) deleteEnvelope() error {
+	if err := w.driver.Delete(w.ctx, w.envelopePath); err != nil && !errors.As(err, &storagedriver.PathNotFoundError{}) {
+		return fmt.Errorf("deleting envelope: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	storagemiddleware.Register("encryption", storagemiddleware.InitFunc(newEncryptionStorageMiddleware))
+}
@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"io/ioutil"
+	"testing"
+
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/stretchr/testify/require"
+)
+
+func newKey() string {
+	k := make([]byte, 32)
+	if _, err := rand.Read(k); err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(k)
+}
+
+func newMiddleware(t *testing.T, sd storagedriver.StorageDriver, keys ...string) storagedriver.StorageDriver {
+	t.Helper()
+
+	opts := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		opts = append(opts, k)
+	}
+	m, err := newEncryptionStorageMiddleware(sd, map[string]interface{}{"keys": opts})
+	require.NoError(t, err)
+
+	return m
+}
+
+func TestOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     map[string]interface{}
+		expectedErr string
+	}{
+		{
+			name:        "no keys",
+			options:     map[string]interface{}{},
+			expectedErr: "no keys provided",
+		},
+		{
+			name:        "keys not a list",
+			options:     map[string]interface{}{"keys": "foo"},
+			expectedErr: "keys must be a list of strings",
+		},
+		{
+			name:        "empty list",
+			options:     map[string]interface{}{"keys": []interface{}{}},
+			expectedErr: "at least one key is required",
+		},
+		{
+			name:        "invalid encoding",
+			options:     map[string]interface{}{"keys": []interface{}{"!"}},
+			expectedErr: "invalid key at index 0: decoding key",
+		},
+		{
+			name:        "invalid length",
+			options:     map[string]interface{}{"keys": []interface{}{base64.StdEncoding.EncodeToString([]byte("short"))}},
+			expectedErr: "invalid key at index 0: key must be 32 bytes long, got 5",
+		},
+		{
+			name:        "duplicate key",
+			options:     map[string]interface{}{"keys": []interface{}{"MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE=", "MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE="}},
+			expectedErr: "duplicate key at index 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newEncryptionStorageMiddleware(inmemory.New(), tt.options)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+func TestContentIsEncrypted(t *testing.T) {
+	ctx := context.Background()
+	backend := inmemory.New()
+	m := newMiddleware(t, backend, newKey())
+
+	content := bytes.Repeat([]byte("plaintext"), 100)
+	require.NoError(t, m.PutContent(ctx, "/a", content))
+
+	stored, err := backend.GetContent(ctx, "/a")
+	require.NoError(t, err)
+	require.Len(t, stored, headerSize+len(content))
+	require.False(t, bytes.Contains(stored, []byte("plaintext")))
+
+	fi, err := m.Stat(ctx, "/a")
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)), fi.Size())
+
+	got, err := m.GetContent(ctx, "/a")
+	require.NoError(t, err)
+	require.Equal(t, content, got)
+}
+
+func TestReaderOffsets(t *testing.T) {
+	ctx := context.Background()
+	m := newMiddleware(t, inmemory.New(), newKey())
+
+	content := make([]byte, 1000)
+	_, err := rand.Read(content)
+	require.NoError(t, err)
+	require.NoError(t, m.PutContent(ctx, "/a", content))
+
+	for _, offset := range []int64{0, 1, 15, 16, 17, 500, 999, 1000} {
+		rc, err := m.Reader(ctx, "/a", offset)
+		require.NoError(t, err)
+		got, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		require.Equal(t, content[offset:], got, "offset %d", offset)
+	}
+}
+
+func TestResumedWriter(t *testing.T) {
+	ctx := context.Background()
+	backend := inmemory.New()
+	m := newMiddleware(t, backend, newKey())
+
+	w, err := m.Writer(ctx, "/upload/data", false)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("hello "))
+	require.NoError(t, err)
+	require.Equal(t, int64(6), w.Size())
+	require.NoError(t, w.Close())
+
+	w, err = m.Writer(ctx, "/upload/data", true)
+	require.NoError(t, err)
+	require.Equal(t, int64(6), w.Size())
+	_, err = w.Write([]byte("world"))
+	require.NoError(t, err)
+	require.NoError(t, w.Commit())
+	require.NoError(t, w.Close())
+
+	// the envelope copy is removed on commit
+	_, err = backend.Stat(ctx, "/upload/data"+envelopeSuffix)
+	require.ErrorAs(t, err, &storagedriver.PathNotFoundError{})
+
+	got, err := m.GetContent(ctx, "/upload/data")
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(got))
+}
+
+func TestPlaintextPassthrough(t *testing.T) {
+	ctx := context.Background()
+	backend := inmemory.New()
+	content := bytes.Repeat([]byte("a"), 200)
+	require.NoError(t, backend.PutContent(ctx, "/legacy", content))
+	require.NoError(t, backend.PutContent(ctx, "/small", []byte("b")))
+
+	m := newMiddleware(t, backend, newKey())
+
+	got, err := m.GetContent(ctx, "/legacy")
+	require.NoError(t, err)
+	require.Equal(t, content, got)
+
+	fi, err := m.Stat(ctx, "/legacy")
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)), fi.Size())
+
+	rc, err := m.Reader(ctx, "/legacy", 100)
+	require.NoError(t, err)
+	got, err = ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	rc.Close()
+	require.Equal(t, content[100:], got)
+
+	got, err = m.GetContent(ctx, "/small")
+	require.NoError(t, err)
+	require.Equal(t, "b", string(got))
+}
+
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	backend := inmemory.New()
+	oldKey, newKey, otherKey := newKey(), newKey(), newKey()
+
+	require.NoError(t, newMiddleware(t, backend, oldKey).PutContent(ctx, "/a", []byte("old")))
+
+	// content encrypted with a previous key can still be read
+	m := newMiddleware(t, backend, newKey, oldKey)
+	got, err := m.GetContent(ctx, "/a")
+	require.NoError(t, err)
+	require.Equal(t, "old", string(got))
+
+	// new content is encrypted with the primary key only
+	require.NoError(t, m.PutContent(ctx, "/b", []byte("new")))
+	got, err = newMiddleware(t, backend, newKey).GetContent(ctx, "/b")
+	require.NoError(t, err)
+	require.Equal(t, "new", string(got))
+
+	_, err = newMiddleware(t, backend, otherKey).GetContent(ctx, "/a")
+	require.ErrorIs(t, err, errKeyUnknown)
+}
+
+func TestURLForUnsupported(t *testing.T) {
+	m := newMiddleware(t, inmemory.New(), newKey())
+
+	_, err := m.URLFor(context.Background(), "/a", nil)
+	require.ErrorAs(t, err, &storagedriver.ErrUnsupportedMethod{})
+}
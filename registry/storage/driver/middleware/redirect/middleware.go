@@ -3,8 +3,11 @@ package middleware
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/url"
+	"strings"
 
+	dcontext "github.com/docker/distribution/context"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	storagemiddleware "github.com/docker/distribution/registry/storage/driver/middleware"
 )
@@ -13,13 +16,28 @@ type redirectStorageMiddleware struct {
 	storagedriver.StorageDriver
 	scheme string
 	host   string
+	// proxyNets holds the networks of clients for which redirects are disabled, the content being served by the
+	// registry instead.
+	proxyNets []*net.IPNet
 }
 
 var _ storagedriver.StorageDriver = &redirectStorageMiddleware{}
 
+// newRedirectStorageMiddleware constructs a new redirect storage middleware.
+// Options: baseurl, proxycidrs. At least one is required.
+// baseurl: the URL used for redirects instead of the URL provided by the storage driver.
+// proxycidrs: a list, or a comma separated string, of CIDRs from which requests are not redirected.
 func newRedirectStorageMiddleware(sd storagedriver.StorageDriver, options map[string]interface{}) (storagedriver.StorageDriver, error) {
+	proxyNets, err := parseProxyCIDRs(options)
+	if err != nil {
+		return nil, err
+	}
+
 	o, ok := options["baseurl"]
 	if !ok {
+		if len(proxyNets) > 0 {
+			return &redirectStorageMiddleware{StorageDriver: sd, proxyNets: proxyNets}, nil
+		}
 		return nil, fmt.Errorf("no baseurl provided")
 	}
 	b, ok := o.(string)
@@ -37,10 +55,82 @@ func newRedirectStorageMiddleware(sd storagedriver.StorageDriver, options map[st
 		return nil, fmt.Errorf("no host specified for redirect baseurl")
 	}
 
-	return &redirectStorageMiddleware{StorageDriver: sd, scheme: u.Scheme, host: u.Host}, nil
+	return &redirectStorageMiddleware{StorageDriver: sd, scheme: u.Scheme, host: u.Host, proxyNets: proxyNets}, nil
+}
+
+func parseProxyCIDRs(options map[string]interface{}) ([]*net.IPNet, error) {
+	o, ok := options["proxycidrs"]
+	if !ok {
+		return nil, nil
+	}
+
+	var cidrs []string
+	switch v := o.(type) {
+	case string:
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				cidrs = append(cidrs, s)
+			}
+		}
+	case []interface{}:
+		for _, i := range v {
+			s, ok := i.(string)
+			if !ok {
+				return nil, fmt.Errorf("proxycidrs must be a list of strings")
+			}
+			cidrs = append(cidrs, strings.TrimSpace(s))
+		}
+	default:
+		return nil, fmt.Errorf("proxycidrs must be a list or a comma separated string of CIDRs")
+	}
+
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxycidrs: %s", err)
+		}
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+// shouldProxy checks whether the client of the request in ctx belongs to one of the proxied networks. Clients are
+// redirected if their address can't be determined.
+func (r *redirectStorageMiddleware) shouldProxy(ctx context.Context) bool {
+	if len(r.proxyNets) == 0 {
+		return false
+	}
+
+	req, err := dcontext.GetRequest(ctx)
+	if err != nil {
+		dcontext.GetLogger(ctx).WithError(err).Warn("the redirect middleware cannot parse the request, redirecting")
+		return false
+	}
+	ip := net.ParseIP(dcontext.RemoteIP(req))
+	if ip == nil {
+		dcontext.GetLogger(ctx).Warnf("the redirect middleware cannot parse the client address %q, redirecting", dcontext.RemoteIP(req))
+		return false
+	}
+
+	for _, n := range r.proxyNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
 }
 
 func (r *redirectStorageMiddleware) URLFor(ctx context.Context, path string, options map[string]interface{}) (string, error) {
+	if r.shouldProxy(ctx) {
+		// the content is served by the registry instead
+		return "", storagedriver.ErrUnsupportedMethod{DriverName: r.Name()}
+	}
+	if r.host == "" {
+		return r.StorageDriver.URLFor(ctx, path, options)
+	}
+
 	u := &url.URL{Scheme: r.scheme, Host: r.host, Path: path}
 	return u.String(), nil
 }
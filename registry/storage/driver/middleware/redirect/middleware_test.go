@@ -2,8 +2,13 @@ package middleware
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	dcontext "github.com/docker/distribution/context"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
 	check "gopkg.in/check.v1"
 )
 
@@ -57,3 +62,75 @@ func (s *MiddlewareSuite) TestHTTP(c *check.C) {
 	c.Assert(err, check.Equals, nil)
 	c.Assert(url, check.Equals, "http://example.com/morty/data")
 }
+
+func (s *MiddlewareSuite) TestInvalidProxyCIDRs(c *check.C) {
+	options := make(map[string]interface{})
+	options["baseurl"] = "https://example.com"
+	options["proxycidrs"] = []interface{}{"10.0.0.1"}
+	_, err := newRedirectStorageMiddleware(nil, options)
+	c.Assert(err, check.ErrorMatches, "invalid proxycidrs: invalid CIDR address: 10.0.0.1")
+
+	options["proxycidrs"] = 1
+	_, err = newRedirectStorageMiddleware(nil, options)
+	c.Assert(err, check.ErrorMatches, "proxycidrs must be a list or a comma separated string of CIDRs")
+}
+
+func (s *MiddlewareSuite) TestProxyCIDRs(c *check.C) {
+	options := make(map[string]interface{})
+	options["baseurl"] = "https://example.com"
+	options["proxycidrs"] = "10.0.0.0/8, 192.168.1.0/24"
+	middleware, err := newRedirectStorageMiddleware(inmemory.New(), options)
+	c.Assert(err, check.Equals, nil)
+
+	for ip, proxied := range map[string]bool{
+		"10.1.2.3":    true,
+		"192.168.1.1": true,
+		"192.168.2.1": false,
+		"8.8.8.8":     false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/v2/foo/blobs/sha256:abc", nil)
+		req.RemoteAddr = ip + ":1234"
+		ctx := dcontext.WithRequest(context.Background(), req)
+
+		url, err := middleware.URLFor(ctx, "/rick/data", nil)
+		if proxied {
+			c.Assert(err, check.FitsTypeOf, storagedriver.ErrUnsupportedMethod{})
+		} else {
+			c.Assert(err, check.Equals, nil)
+			c.Assert(url, check.Equals, "https://example.com/rick/data")
+		}
+	}
+
+	// clients are redirected if their address is unknown
+	url, err := middleware.URLFor(context.Background(), "/rick/data", nil)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(url, check.Equals, "https://example.com/rick/data")
+}
+
+func (s *MiddlewareSuite) TestProxyCIDRsWithoutBaseURL(c *check.C) {
+	options := make(map[string]interface{})
+	options["proxycidrs"] = []interface{}{"10.0.0.0/8"}
+	middleware, err := newRedirectStorageMiddleware(&signingDriver{inmemory.New()}, options)
+	c.Assert(err, check.Equals, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/v2/foo/blobs/sha256:abc", nil)
+	req.RemoteAddr = "8.8.8.8:1234"
+	ctx := dcontext.WithRequest(context.Background(), req)
+
+	url, err := middleware.URLFor(ctx, "/rick/data", nil)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(url, check.Equals, "https://bucket.example.com/rick/data?signature=foo")
+
+	req.RemoteAddr = "10.1.2.3:1234"
+	_, err = middleware.URLFor(dcontext.WithRequest(context.Background(), req), "/rick/data", nil)
+	c.Assert(err, check.FitsTypeOf, storagedriver.ErrUnsupportedMethod{})
+}
+
+// signingDriver is a storage driver which returns signed URLs.
+type signingDriver struct {
+	storagedriver.StorageDriver
+}
+
+func (d *signingDriver) URLFor(ctx context.Context, path string, options map[string]interface{}) (string, error) {
+	return "https://bucket.example.com" + path + "?signature=foo", nil
+}
@@ -1,8 +1,8 @@
 // Package oss provides a storagedriver.StorageDriver implementation to
 // store blobs in Aliyun OSS cloud storage.
 //
-// This package leverages the denverdino/aliyungo client library for interfacing with
-// oss.
+// This package leverages the official aliyun-oss-go-sdk client library for interfacing
+// with oss. Requests are signed with V4 signatures by default.
 //
 // Because OSS is a key, value store the Stat call does not support last modification
 // time for directories (directories are an abstraction for key, value stores)
@@ -23,7 +23,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/denverdino/aliyungo/oss"
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/registry/storage/driver/base"
 	"github.com/docker/distribution/registry/storage/driver/factory"
@@ -42,13 +42,25 @@ const defaultTimeout = 2 * time.Minute // 2 minute timeout per chunk
 // listMax is the largest amount of objects you can request from OSS in a list call
 const listMax = 1000
 
+// maxCopyObjectSize is the largest object that can be copied with a single request.
+// Larger objects are copied with a multipart copy.
+const maxCopyObjectSize = 1 << 30
+
+// maxParts is the largest number of parts of a multipart upload
+const maxParts = 10000
+
+// accelerateEndpoint is the global endpoint of OSS transfer acceleration
+const accelerateEndpoint = "oss-accelerate.aliyuncs.com"
+
 //DriverParameters A struct that encapsulates all of the driver parameters after all values have been set
 type DriverParameters struct {
 	AccessKeyID     string
 	AccessKeySecret string
 	Bucket          string
-	Region          oss.Region
+	Region          string
 	Internal        bool
+	Accelerate      bool
+	V4Auth          bool
 	Encrypt         bool
 	Secure          bool
 	ChunkSize       int64
@@ -125,6 +137,24 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 		}
 	}
 
+	accelerateBool := false
+	accelerate, ok := parameters["accelerate"]
+	if ok {
+		accelerateBool, ok = accelerate.(bool)
+		if !ok {
+			return nil, fmt.Errorf("The accelerate parameter should be a boolean")
+		}
+	}
+
+	v4Bool := true
+	v4auth, ok := parameters["v4auth"]
+	if ok {
+		v4Bool, ok = v4auth.(bool)
+		if !ok {
+			return nil, fmt.Errorf("The v4auth parameter should be a boolean")
+		}
+	}
+
 	encryptBool := false
 	encrypt, ok := parameters["encrypt"]
 	if ok {
@@ -181,16 +211,22 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 		endpoint = ""
 	}
 
+	if accelerateBool && (internalBool || fmt.Sprint(endpoint) != "") {
+		return nil, fmt.Errorf("The accelerate parameter can not be combined with the internal or endpoint parameters")
+	}
+
 	params := DriverParameters{
 		AccessKeyID:     fmt.Sprint(accessKey),
 		AccessKeySecret: fmt.Sprint(secretKey),
 		Bucket:          fmt.Sprint(bucket),
-		Region:          oss.Region(fmt.Sprint(regionName)),
+		Region:          fmt.Sprint(regionName),
 		ChunkSize:       chunkSize,
 		RootDirectory:   fmt.Sprint(rootDirectory),
 		Encrypt:         encryptBool,
 		Secure:          secureBool,
 		Internal:        internalBool,
+		Accelerate:      accelerateBool,
+		V4Auth:          v4Bool,
 		Endpoint:        fmt.Sprint(endpoint),
 		EncryptionKeyID: fmt.Sprint(encryptionKeyID),
 	}
@@ -201,15 +237,24 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 // New constructs a new Driver with the given Aliyun credentials, region, encryption flag, and
 // bucketName
 func New(params DriverParameters) (*Driver, error) {
+	endpoint, cname := getEndpoint(params)
+	options := []oss.ClientOption{oss.UseCname(cname)}
+	if params.V4Auth {
+		options = append(options, oss.AuthVersion(oss.AuthV4), oss.Region(regionID(params.Region)))
+	}
 
-	client := oss.NewOSSClient(params.Region, params.Internal, params.AccessKeyID, params.AccessKeySecret, params.Secure)
-	client.SetEndpoint(params.Endpoint)
-	bucket := client.Bucket(params.Bucket)
-	client.SetDebug(false)
+	client, err := oss.New(endpoint, params.AccessKeyID, params.AccessKeySecret, options...)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := client.Bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
 
 	// Validate that the given credentials have at least read permissions in the
 	// given bucket scope.
-	if _, err := bucket.List(strings.TrimRight(params.RootDirectory, "/"), "", "", 1); err != nil {
+	if _, err := bucket.ListObjects(oss.Prefix(strings.TrimRight(params.RootDirectory, "/")), oss.MaxKeys(1)); err != nil {
 		return nil, err
 	}
 
@@ -234,6 +279,33 @@ func New(params DriverParameters) (*Driver, error) {
 	}, nil
 }
 
+// getEndpoint returns the endpoint of the OSS service for the given parameters, and
+// whether it is the domain of the bucket rather than a region endpoint. A configured
+// endpoint is always used as the domain of the bucket, e.g. a custom domain.
+func getEndpoint(params DriverParameters) (string, bool) {
+	protocol := "http"
+	if params.Secure {
+		protocol = "https"
+	}
+
+	switch {
+	case params.Endpoint != "":
+		return fmt.Sprintf("%s://%s", protocol, params.Endpoint), true
+	case params.Accelerate:
+		return fmt.Sprintf("%s://%s", protocol, accelerateEndpoint), false
+	case params.Internal:
+		return fmt.Sprintf("%s://oss-%s-internal.aliyuncs.com", protocol, regionID(params.Region)), false
+	default:
+		return fmt.Sprintf("%s://oss-%s.aliyuncs.com", protocol, regionID(params.Region)), false
+	}
+}
+
+// regionID returns the ID of an OSS region, as used in V4 signatures. Regions can be
+// configured either by name or ID, e.g. `oss-cn-hangzhou` or `cn-hangzhou`.
+func regionID(region string) string {
+	return strings.TrimPrefix(region, "oss-")
+}
+
 // Implement the storagedriver.StorageDriver interface
 
 func (d *driver) Name() string {
@@ -242,28 +314,29 @@ func (d *driver) Name() string {
 
 // GetContent retrieves the content stored at "path" as a []byte.
 func (d *driver) GetContent(ctx context.Context, path string) ([]byte, error) {
-	content, err := d.Bucket.Get(d.ossPath(path))
+	body, err := d.Bucket.GetObject(d.ossPath(path))
 	if err != nil {
 		return nil, parseError(path, err)
 	}
-	return content, nil
+	defer body.Close()
+
+	return ioutil.ReadAll(body)
 }
 
 // PutContent stores the []byte content at a location designated by "path".
 func (d *driver) PutContent(ctx context.Context, path string, contents []byte) error {
-	return parseError(path, d.Bucket.Put(d.ossPath(path), contents, d.getContentType(), getPermissions(), d.getOptions()))
+	return parseError(path, d.Bucket.PutObject(d.ossPath(path), bytes.NewReader(contents), d.getOptions()...))
 }
 
 // Reader retrieves an io.ReadCloser for the content stored at "path" with a
 // given byte offset.
 func (d *driver) Reader(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
-	headers := make(http.Header)
-	headers.Add("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
-
-	resp, err := d.Bucket.GetResponseWithHeaders(d.ossPath(path), headers)
+	req := &oss.GetObjectRequest{ObjectKey: d.ossPath(path)}
+	result, err := d.Bucket.DoGetObject(req, []oss.Option{oss.NormalizedRange(strconv.FormatInt(offset, 10) + "-")})
 	if err != nil {
 		return nil, parseError(path, err)
 	}
+	resp := result.Response
 
 	// Due to Aliyun OSS API, status 200 and whole object will be return instead of an
 	// InvalidRange error when range is invalid.
@@ -283,37 +356,53 @@ func (d *driver) Writer(ctx context.Context, path string, append bool) (storaged
 	key := d.ossPath(path)
 	if !append {
 		// TODO (brianbland): cancel other uploads at this path
-		multi, err := d.Bucket.InitMulti(key, d.getContentType(), getPermissions(), d.getOptions())
+		imur, err := d.Bucket.InitiateMultipartUpload(key, d.getOptions()...)
 		if err != nil {
 			return nil, err
 		}
-		return d.newWriter(key, multi, nil), nil
+		return d.newWriter(key, imur, nil), nil
 	}
-	multis, _, err := d.Bucket.ListMulti(key, "")
+	lmur, err := d.Bucket.ListMultipartUploads(oss.Prefix(key))
 	if err != nil {
 		return nil, parseError(path, err)
 	}
-	for _, multi := range multis {
-		if key != multi.Key {
+	for _, upload := range lmur.Uploads {
+		if key != upload.Key {
 			continue
 		}
-		parts, err := multi.ListParts()
+		imur := oss.InitiateMultipartUploadResult{Bucket: d.Bucket.BucketName, Key: upload.Key, UploadID: upload.UploadID}
+		parts, err := d.listParts(imur)
 		if err != nil {
 			return nil, parseError(path, err)
 		}
-		var multiSize int64
-		for _, part := range parts {
-			multiSize += part.Size
-		}
-		return d.newWriter(key, multi, parts), nil
+		return d.newWriter(key, imur, parts), nil
 	}
 	return nil, storagedriver.PathNotFoundError{Path: path}
 }
 
+// listParts returns all the uploaded parts of a multipart upload.
+func (d *driver) listParts(imur oss.InitiateMultipartUploadResult) ([]oss.UploadedPart, error) {
+	var parts []oss.UploadedPart
+	marker := 0
+	for {
+		lupr, err := d.Bucket.ListUploadedParts(imur, oss.PartNumberMarker(marker))
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, lupr.UploadedParts...)
+		if !lupr.IsTruncated {
+			return parts, nil
+		}
+		if marker, err = strconv.Atoi(lupr.NextPartNumberMarker); err != nil {
+			return nil, err
+		}
+	}
+}
+
 // Stat retrieves the FileInfo for the given path, including the current size
 // in bytes and the creation time.
 func (d *driver) Stat(ctx context.Context, path string) (storagedriver.FileInfo, error) {
-	listResponse, err := d.Bucket.List(d.ossPath(path), "", "", 1)
+	listResponse, err := d.Bucket.ListObjects(oss.Prefix(d.ossPath(path)), oss.MaxKeys(1))
 	if err != nil {
 		return nil, err
 	}
@@ -322,18 +411,13 @@ func (d *driver) Stat(ctx context.Context, path string) (storagedriver.FileInfo,
 		Path: path,
 	}
 
-	if len(listResponse.Contents) == 1 {
-		if listResponse.Contents[0].Key != d.ossPath(path) {
+	if len(listResponse.Objects) == 1 {
+		if listResponse.Objects[0].Key != d.ossPath(path) {
 			fi.IsDir = true
 		} else {
 			fi.IsDir = false
-			fi.Size = listResponse.Contents[0].Size
-
-			timestamp, err := time.Parse(time.RFC3339Nano, listResponse.Contents[0].LastModified)
-			if err != nil {
-				return nil, err
-			}
-			fi.ModTime = timestamp
+			fi.Size = listResponse.Objects[0].Size
+			fi.ModTime = listResponse.Objects[0].LastModified
 		}
 	} else if len(listResponse.CommonPrefixes) == 1 {
 		fi.IsDir = true
@@ -360,7 +444,7 @@ func (d *driver) List(ctx context.Context, opath string) ([]string, error) {
 	}
 
 	ossPath := d.ossPath(path)
-	listResponse, err := d.Bucket.List(ossPath, "/", "", listMax)
+	listResponse, err := d.Bucket.ListObjects(oss.Prefix(ossPath), oss.Delimiter("/"), oss.MaxKeys(listMax))
 	if err != nil {
 		return nil, parseError(opath, err)
 	}
@@ -369,7 +453,7 @@ func (d *driver) List(ctx context.Context, opath string) ([]string, error) {
 	directories := []string{}
 
 	for {
-		for _, key := range listResponse.Contents {
+		for _, key := range listResponse.Objects {
 			files = append(files, strings.Replace(key.Key, d.ossPath(""), prefix, 1))
 		}
 
@@ -378,7 +462,7 @@ func (d *driver) List(ctx context.Context, opath string) ([]string, error) {
 		}
 
 		if listResponse.IsTruncated {
-			listResponse, err = d.Bucket.List(ossPath, "/", listResponse.NextMarker, listMax)
+			listResponse, err = d.Bucket.ListObjects(oss.Prefix(ossPath), oss.Delimiter("/"), oss.Marker(listResponse.NextMarker), oss.MaxKeys(listMax))
 			if err != nil {
 				return nil, err
 			}
@@ -409,12 +493,7 @@ const maxConcurrency = 10
 // object.
 func (d *driver) Move(ctx context.Context, sourcePath string, destPath string) error {
 	logrus.Infof("Move from %s to %s", d.ossPath(sourcePath), d.ossPath(destPath))
-	err := d.Bucket.CopyLargeFileInParallel(d.ossPath(sourcePath), d.ossPath(destPath),
-		d.getContentType(),
-		getPermissions(),
-		d.getOptions(),
-		maxConcurrency)
-	if err != nil {
+	if err := d.copy(sourcePath, destPath); err != nil {
 		logrus.Errorf("Failed for move from %s to %s: %v", d.ossPath(sourcePath), d.ossPath(destPath), err)
 		return parseError(sourcePath, err)
 	}
@@ -422,37 +501,62 @@ func (d *driver) Move(ctx context.Context, sourcePath string, destPath string) e
 	return d.Delete(ctx, sourcePath)
 }
 
+// copy copies an object stored at sourcePath to destPath. Objects larger than
+// maxCopyObjectSize are copied in parallel with a multipart copy.
+func (d *driver) copy(sourcePath string, destPath string) error {
+	meta, err := d.Bucket.GetObjectMeta(d.ossPath(sourcePath))
+	if err != nil {
+		return err
+	}
+	size, err := strconv.ParseInt(meta.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return err
+	}
+
+	if size <= maxCopyObjectSize {
+		_, err := d.Bucket.CopyObject(d.ossPath(sourcePath), d.ossPath(destPath), d.getOptions()...)
+		return err
+	}
+
+	partSize := d.ChunkSize
+	if minPartSize := (size + maxParts - 1) / maxParts; partSize < minPartSize {
+		partSize = minPartSize
+	}
+	options := append(d.getOptions(), oss.Routines(maxConcurrency))
+	return d.Bucket.CopyFile(d.Bucket.BucketName, d.ossPath(sourcePath), d.ossPath(destPath), partSize, options...)
+}
+
 // Delete recursively deletes all objects stored at "path" and its subpaths.
 func (d *driver) Delete(ctx context.Context, path string) error {
 	ossPath := d.ossPath(path)
-	listResponse, err := d.Bucket.List(ossPath, "", "", listMax)
-	if err != nil || len(listResponse.Contents) == 0 {
+	listResponse, err := d.Bucket.ListObjects(oss.Prefix(ossPath), oss.MaxKeys(listMax))
+	if err != nil || len(listResponse.Objects) == 0 {
 		return storagedriver.PathNotFoundError{Path: path}
 	}
 
-	ossObjects := make([]oss.Object, listMax)
+	ossObjects := make([]string, listMax)
 
-	for len(listResponse.Contents) > 0 {
-		numOssObjects := len(listResponse.Contents)
-		for index, key := range listResponse.Contents {
+	for len(listResponse.Objects) > 0 {
+		numOssObjects := len(listResponse.Objects)
+		for index, key := range listResponse.Objects {
 			// Stop if we encounter a key that is not a subpath (so that deleting "/a" does not delete "/ab").
 			if len(key.Key) > len(ossPath) && (key.Key)[len(ossPath)] != '/' {
 				numOssObjects = index
 				break
 			}
-			ossObjects[index].Key = key.Key
+			ossObjects[index] = key.Key
 		}
 
-		err := d.Bucket.DelMulti(oss.Delete{Quiet: false, Objects: ossObjects[0:numOssObjects]})
+		_, err := d.Bucket.DeleteObjects(ossObjects[0:numOssObjects], oss.DeleteObjectsQuiet(true))
 		if err != nil {
 			return nil
 		}
 
-		if numOssObjects < len(listResponse.Contents) {
+		if numOssObjects < len(listResponse.Objects) {
 			return nil
 		}
 
-		listResponse, err = d.Bucket.List(d.ossPath(path), "", "", listMax)
+		listResponse, err = d.Bucket.ListObjects(oss.Prefix(ossPath), oss.MaxKeys(listMax))
 		if err != nil {
 			return err
 		}
@@ -499,7 +603,10 @@ func (d *driver) URLFor(ctx context.Context, path string, options map[string]int
 		}
 	}
 	logrus.Infof("methodString: %s, expiresTime: %v", methodString, expiresTime)
-	signedURL := d.Bucket.SignedURLWithMethod(methodString, d.ossPath(path), expiresTime, nil, nil)
+	signedURL, err := d.Bucket.SignURL(d.ossPath(path), oss.HTTPMethod(methodString), int64(time.Until(expiresTime).Seconds()))
+	if err != nil {
+		return "", err
+	}
 	logrus.Infof("signed URL: %s", signedURL)
 	return signedURL, nil
 }
@@ -527,7 +634,7 @@ func (d *driver) ossPath(path string) string {
 }
 
 func parseError(path string, err error) error {
-	if ossErr, ok := err.(*oss.Error); ok && ossErr.StatusCode == http.StatusNotFound && (ossErr.Code == "NoSuchKey" || ossErr.Code == "") {
+	if ossErr, ok := err.(oss.ServiceError); ok && ossErr.StatusCode == http.StatusNotFound && (ossErr.Code == "NoSuchKey" || ossErr.Code == "") {
 		return storagedriver.PathNotFoundError{Path: path}
 	}
 
@@ -535,26 +642,25 @@ func parseError(path string, err error) error {
 }
 
 func hasCode(err error, code string) bool {
-	ossErr, ok := err.(*oss.Error)
+	ossErr, ok := err.(oss.ServiceError)
 	return ok && ossErr.Code == code
 }
 
-func (d *driver) getOptions() oss.Options {
-	return oss.Options{
-		ServerSideEncryption:      d.Encrypt,
-		ServerSideEncryptionKeyID: d.EncryptionKeyID,
+func (d *driver) getOptions() []oss.Option {
+	options := []oss.Option{
+		oss.ContentType(d.getContentType()),
+		oss.ObjectACL(getPermissions()),
 	}
-}
-
-func (d *driver) getCopyOptions() oss.CopyOptions {
-	return oss.CopyOptions{
-		ServerSideEncryption:      d.Encrypt,
-		ServerSideEncryptionKeyID: d.EncryptionKeyID,
+	if len(d.EncryptionKeyID) != 0 {
+		options = append(options, oss.ServerSideEncryption("KMS"), oss.ServerSideEncryptionKeyID(d.EncryptionKeyID))
+	} else if d.Encrypt {
+		options = append(options, oss.ServerSideEncryption("AES256"))
 	}
+	return options
 }
 
-func getPermissions() oss.ACL {
-	return oss.Private
+func getPermissions() oss.ACLType {
+	return oss.ACLPrivate
 }
 
 func (d *driver) getContentType() string {
@@ -568,8 +674,8 @@ func (d *driver) getContentType() string {
 type writer struct {
 	driver      *driver
 	key         string
-	multi       *oss.Multi
-	parts       []oss.Part
+	imur        oss.InitiateMultipartUploadResult
+	parts       []oss.UploadedPart
 	size        int64
 	readyPart   []byte
 	pendingPart []byte
@@ -578,15 +684,15 @@ type writer struct {
 	cancelled   bool
 }
 
-func (d *driver) newWriter(key string, multi *oss.Multi, parts []oss.Part) storagedriver.FileWriter {
+func (d *driver) newWriter(key string, imur oss.InitiateMultipartUploadResult, parts []oss.UploadedPart) storagedriver.FileWriter {
 	var size int64
 	for _, part := range parts {
-		size += part.Size
+		size += int64(part.Size)
 	}
 	return &writer{
 		driver: d,
 		key:    key,
-		multi:  multi,
+		imur:   imur,
 		parts:  parts,
 		size:   size,
 	}
@@ -603,23 +709,28 @@ func (w *writer) Write(p []byte) (int, error) {
 
 	// If the last written part is smaller than minChunkSize, we need to make a
 	// new multipart upload :sadface:
-	if len(w.parts) > 0 && int(w.parts[len(w.parts)-1].Size) < minChunkSize {
-		err := w.multi.Complete(w.parts)
+	if len(w.parts) > 0 && w.parts[len(w.parts)-1].Size < minChunkSize {
+		err := w.complete()
 		if err != nil {
-			w.multi.Abort()
+			w.driver.Bucket.AbortMultipartUpload(w.imur)
 			return 0, err
 		}
 
-		multi, err := w.driver.Bucket.InitMulti(w.key, w.driver.getContentType(), getPermissions(), w.driver.getOptions())
+		imur, err := w.driver.Bucket.InitiateMultipartUpload(w.key, w.driver.getOptions()...)
 		if err != nil {
 			return 0, err
 		}
-		w.multi = multi
+		w.imur = imur
 
 		// If the entire written file is smaller than minChunkSize, we need to make
 		// a new part from scratch :double sad face:
 		if w.size < minChunkSize {
-			contents, err := w.driver.Bucket.Get(w.key)
+			body, err := w.driver.Bucket.GetObject(w.key)
+			if err != nil {
+				return 0, err
+			}
+			contents, err := ioutil.ReadAll(body)
+			body.Close()
 			if err != nil {
 				return 0, err
 			}
@@ -627,11 +738,11 @@ func (w *writer) Write(p []byte) (int, error) {
 			w.readyPart = contents
 		} else {
 			// Otherwise we can use the old file as the new first part
-			_, part, err := multi.PutPartCopy(1, w.driver.getCopyOptions(), w.driver.Bucket.Name+"/"+w.key)
+			part, err := w.driver.Bucket.UploadPartCopy(imur, w.driver.Bucket.BucketName, w.key, 0, w.size, 1)
 			if err != nil {
 				return 0, err
 			}
-			w.parts = []oss.Part{part}
+			w.parts = []oss.UploadedPart{{PartNumber: part.PartNumber, ETag: part.ETag, Size: int(w.size)}}
 		}
 	}
 
@@ -691,7 +802,7 @@ func (w *writer) Cancel() error {
 		return fmt.Errorf("already committed")
 	}
 	w.cancelled = true
-	err := w.multi.Abort()
+	err := w.driver.Bucket.AbortMultipartUpload(w.imur)
 	return err
 }
 
@@ -708,14 +819,24 @@ func (w *writer) Commit() error {
 		return err
 	}
 	w.committed = true
-	err = w.multi.Complete(w.parts)
+	err = w.complete()
 	if err != nil {
-		w.multi.Abort()
+		w.driver.Bucket.AbortMultipartUpload(w.imur)
 		return err
 	}
 	return nil
 }
 
+// complete completes the multipart upload with the parts written so far.
+func (w *writer) complete() error {
+	parts := make([]oss.UploadPart, 0, len(w.parts))
+	for _, part := range w.parts {
+		parts = append(parts, oss.UploadPart{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+	_, err := w.driver.Bucket.CompleteMultipartUpload(w.imur, parts)
+	return err
+}
+
 // flushPart flushes buffers to write a part to S3.
 // Only called by Write (with both buffers full) and Close/Commit (always)
 func (w *writer) flushPart() error {
@@ -730,11 +851,11 @@ func (w *writer) flushPart() error {
 		w.pendingPart = nil
 	}
 
-	part, err := w.multi.PutPart(len(w.parts)+1, bytes.NewReader(w.readyPart))
+	part, err := w.driver.Bucket.UploadPart(w.imur, bytes.NewReader(w.readyPart), int64(len(w.readyPart)), len(w.parts)+1)
 	if err != nil {
 		return err
 	}
-	w.parts = append(w.parts, part)
+	w.parts = append(w.parts, oss.UploadedPart{PartNumber: part.PartNumber, ETag: part.ETag, Size: len(w.readyPart)})
 	w.readyPart = w.pendingPart
 	w.pendingPart = nil
 	return nil
@@ -8,7 +8,6 @@ import (
 	"strconv"
 	"testing"
 
-	alioss "github.com/denverdino/aliyungo/oss"
 	"github.com/docker/distribution/context"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/registry/storage/driver/testsuites"
@@ -28,6 +27,8 @@ func init() {
 	bucket := os.Getenv("OSS_BUCKET")
 	region := os.Getenv("OSS_REGION")
 	internal := os.Getenv("OSS_INTERNAL")
+	accelerate := os.Getenv("OSS_ACCELERATE")
+	v4auth := os.Getenv("OSS_V4AUTH")
 	encrypt := os.Getenv("OSS_ENCRYPT")
 	secure := os.Getenv("OSS_SECURE")
 	endpoint := os.Getenv("OSS_ENDPOINT")
@@ -63,12 +64,30 @@ func init() {
 			}
 		}
 
+		accelerateBool := false
+		if accelerate != "" {
+			accelerateBool, err = strconv.ParseBool(accelerate)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		v4Bool := true
+		if v4auth != "" {
+			v4Bool, err = strconv.ParseBool(v4auth)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		parameters := DriverParameters{
 			AccessKeyID:     accessKey,
 			AccessKeySecret: secretKey,
 			Bucket:          bucket,
-			Region:          alioss.Region(region),
+			Region:          region,
 			Internal:        internalBool,
+			Accelerate:      accelerateBool,
+			V4Auth:          v4Bool,
 			ChunkSize:       minChunkSize,
 			RootDirectory:   rootDirectory,
 			Encrypt:         encryptBool,
@@ -142,3 +161,63 @@ func TestEmptyRootList(t *testing.T) {
 		}
 	}
 }
+
+func TestGetEndpoint(t *testing.T) {
+	tests := []struct {
+		params   DriverParameters
+		endpoint string
+		cname    bool
+	}{
+		{
+			params:   DriverParameters{Region: "oss-cn-hangzhou", Secure: true},
+			endpoint: "https://oss-cn-hangzhou.aliyuncs.com",
+		},
+		{
+			params:   DriverParameters{Region: "cn-hangzhou"},
+			endpoint: "http://oss-cn-hangzhou.aliyuncs.com",
+		},
+		{
+			params:   DriverParameters{Region: "oss-cn-hangzhou", Internal: true, Secure: true},
+			endpoint: "https://oss-cn-hangzhou-internal.aliyuncs.com",
+		},
+		{
+			params:   DriverParameters{Region: "oss-cn-hangzhou", Accelerate: true, Secure: true},
+			endpoint: "https://oss-accelerate.aliyuncs.com",
+		},
+		{
+			params:   DriverParameters{Region: "oss-cn-hangzhou", Endpoint: "registry.example.com", Internal: true, Secure: true},
+			endpoint: "https://registry.example.com",
+			cname:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		endpoint, cname := getEndpoint(tt.params)
+		if endpoint != tt.endpoint || cname != tt.cname {
+			t.Errorf("expected endpoint %q (cname: %t), got %q (cname: %t)", tt.endpoint, tt.cname, endpoint, cname)
+		}
+	}
+}
+
+func TestFromParametersAccelerateConflicts(t *testing.T) {
+	base := map[string]interface{}{
+		"accesskeyid":     "id",
+		"accesskeysecret": "secret",
+		"region":          "oss-cn-hangzhou",
+		"bucket":          "bucket",
+		"accelerate":      true,
+	}
+
+	for _, conflict := range []map[string]interface{}{{"internal": true}, {"endpoint": "registry.example.com"}} {
+		params := make(map[string]interface{})
+		for k, v := range base {
+			params[k] = v
+		}
+		for k, v := range conflict {
+			params[k] = v
+		}
+		if _, err := FromParameters(params); err == nil {
+			t.Errorf("expected an error for accelerate with %v", conflict)
+		}
+	}
+}
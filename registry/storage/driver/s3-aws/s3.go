@@ -14,12 +14,16 @@ package s3
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math"
+	"net"
 	"net/http"
     "os"
 	"reflect"
@@ -52,6 +56,7 @@ import (
 	"github.com/docker/distribution/registry/storage/driver/base"
 	"github.com/docker/distribution/registry/storage/driver/factory"
 	"github.com/docker/distribution/version"
+	"github.com/opencontainers/go-digest"
 )
 
 const driverName = "s3aws"
@@ -84,6 +89,10 @@ const (
 // listMax is the largest amount of objects you can request from S3 in a list call
 const listMax = 1000
 
+// defaultMaxWalkConcurrency defines the default maximum number of concurrent
+// goroutines used to walk the bucket when the parallel walk is enabled.
+const defaultMaxWalkConcurrency = 100
+
 // deleteMax is the largest amount of objects you can request to be deleted in S3 using a DeleteObjects call. This is
 // currently set to 1000 as per the S3 specification https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteObjects.html
 const deleteMax = 1000
@@ -98,9 +107,41 @@ const defaultMaxRequestsPerSecond = 350
 // we only reserve one at a time via Limiter.Wait()
 const defaultBurst = 1
 
+// maxCopyObjectSize is the maximum size of an object that S3 can copy with a
+// single PUT Object - Copy operation.
+const maxCopyObjectSize = 5 << 30
+
+// S3 headers used to request and report SHA-256 checksums of objects. These
+// are not supported by the version of the AWS SDK in use, so they are set and
+// read directly.
+const (
+	checksumModeHeader      = "x-amz-checksum-mode"
+	checksumAlgorithmHeader = "x-amz-checksum-algorithm"
+	checksumSHA256Header    = "x-amz-checksum-sha256"
+)
+
+// assumeRoleExpiryWindow is how long before expiring the credentials obtained by assuming a role are refreshed
+const assumeRoleExpiryWindow = 5 * time.Minute
+
 // noStorageClass defines the value to be used if storage class is not supported by the S3 endpoint
 const noStorageClass = "NONE"
 
+// blobDataPathPrefix is the storage path prefix under which the registry stores the content of blobs. Object Lock
+// settings are only applied to objects written under this prefix, as blob content is immutable once written, while
+// other objects, such as upload state and links, are overwritten and deleted as part of the normal operation.
+const blobDataPathPrefix = "/docker/registry/v2/blobs/"
+
+// keyPrefixTenantPlaceholder is replaced by the value of the tenant parameter when expanding the keyprefix parameter
+const keyPrefixTenantPlaceholder = "{tenant}"
+
+// defaults related to the HTTP client, matching the ones of http.DefaultTransport, which the AWS SDK uses otherwise
+const (
+	defaultDialTimeout         = 30 * time.Second
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = http.DefaultMaxIdleConnsPerHost
+)
+
 // defaults related to exponential backoff
 const (
 	// defaultMaxRetries is how many times the driver will retry failed requests.
@@ -142,7 +183,22 @@ type DriverParameters struct {
 	MaxRequestsPerSecond        int64
 	MaxRetries                  int64
 	ParallelWalk                bool
+	MaxWalkConcurrency          int64
 	LogLevel                    aws.LogLevelType
+	KeyPrefix                   string
+	RoleARN                     string
+	ExternalID                  string
+	ChecksumOffload             bool
+	DialTimeout                 time.Duration
+	ResponseHeaderTimeout       time.Duration
+	IdleConnTimeout             time.Duration
+	MaxIdleConnsPerHost         int64
+	RequestTimeout              time.Duration
+	ForceListObjectsV1          bool
+	DisableBulkDelete           bool
+	ObjectLockMode              string
+	ObjectLockRetention         time.Duration
+	ObjectLockLegalHold         bool
 }
 
 func init() {
@@ -190,6 +246,12 @@ type driver struct {
 	StorageClass                string
 	ObjectACL                   string
 	ParallelWalk                bool
+	MaxWalkConcurrency          int64
+	KeyPrefix                   string
+	ChecksumOffload             bool
+	ObjectLockMode              string
+	ObjectLockRetention         time.Duration
+	ObjectLockLegalHold         bool
 }
 
 type baseEmbed struct {
@@ -387,6 +449,11 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 		rootDirectory = ""
 	}
 
+	keyPrefix, err := parseKeyPrefixParams(parameters["keyprefix"], parameters["tenant"])
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+
 	storageClass := s3.StorageClassStandard
 	storageClassParam := parameters["storageclass"]
 	if storageClassParam != nil {
@@ -473,6 +540,12 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 		result = multierror.Append(result, err)
 	}
 
+	maxWalkConcurrency, err := getParameterAsInt64(parameters, "maxwalkconcurrency", defaultMaxWalkConcurrency, 1, math.MaxInt64)
+	if err != nil {
+		err = fmt.Errorf("converting maxwalkconcurrency to valid int64: %w", err)
+		result = multierror.Append(result, err)
+	}
+
 	maxRequestsPerSecond, err := getParameterAsInt64(parameters, "maxrequestspersecond", defaultMaxRequestsPerSecond, 0, math.MaxInt64)
 	if err != nil {
 		err = fmt.Errorf("converting maxrequestspersecond to valid int64: %w", err)
@@ -485,6 +558,93 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 		result = multierror.Append(result, err)
 	}
 
+	checksumOffloadBool := false
+	checksumOffload := parameters["checksumoffload"]
+	switch checksumOffload := checksumOffload.(type) {
+	case string:
+		b, err := strconv.ParseBool(checksumOffload)
+		if err != nil {
+			err := errors.New("the checksumoffload parameter should be a boolean")
+			result = multierror.Append(result, err)
+		}
+		checksumOffloadBool = b
+	case bool:
+		checksumOffloadBool = checksumOffload
+	case nil:
+		// do nothing
+	default:
+		err := errors.New("the checksumoffload parameter should be a boolean")
+		result = multierror.Append(result, err)
+	}
+
+	roleARN, externalID, err := parseAssumeRoleParams(parameters["rolearn"], parameters["externalid"])
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	dialTimeout, err := getParameterAsDuration(parameters, "dialtimeout", defaultDialTimeout)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	responseHeaderTimeout, err := getParameterAsDuration(parameters, "responseheadertimeout", 0)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	idleConnTimeout, err := getParameterAsDuration(parameters, "idleconntimeout", defaultIdleConnTimeout)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	maxIdleConnsPerHost, err := getParameterAsInt64(parameters, "maxidleconnsperhost", defaultMaxIdleConnsPerHost, 1, math.MaxInt32)
+	if err != nil {
+		err = fmt.Errorf("converting maxidleconnsperhost to valid int64: %w", err)
+		result = multierror.Append(result, err)
+	}
+
+	requestTimeout, err := getParameterAsDuration(parameters, "requesttimeout", 0)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	forceListObjectsV1, err := getParameterAsBool(parameters, "forcelistobjectsv1", false)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	disableBulkDelete, err := getParameterAsBool(parameters, "disablebulkdelete", false)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	objectLockModes := []string{s3.ObjectLockModeGovernance, s3.ObjectLockModeCompliance}
+	objectLockMode := ""
+	if objectLockModeParam := parameters["objectlockmode"]; objectLockModeParam != nil {
+		objectLockModeString, ok := objectLockModeParam.(string)
+		// All valid modes are UPPERCASE, so be a bit more flexible here
+		objectLockModeString = strings.ToUpper(objectLockModeString)
+		if !ok || (objectLockModeString != s3.ObjectLockModeGovernance && objectLockModeString != s3.ObjectLockModeCompliance) {
+			err := fmt.Errorf("the objectlockmode parameter must be one of %v, %v invalid", objectLockModes, objectLockModeParam)
+			result = multierror.Append(result, err)
+		}
+		objectLockMode = objectLockModeString
+	}
+
+	objectLockRetention, err := getParameterAsDuration(parameters, "objectlockretention", 0)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+	if (objectLockMode == "") != (objectLockRetention == 0) {
+		err := errors.New("the objectlockmode and objectlockretention parameters must be set together")
+		result = multierror.Append(result, err)
+	}
+
+	objectLockLegalHold, err := getParameterAsBool(parameters, "objectlocklegalhold", false)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+
 	// multierror return
 	if err := result.ErrorOrNil(); err != nil {
 		return nil, err
@@ -517,12 +677,87 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 		maxRequestsPerSecond,
 		maxRetries,
 		parallelWalkBool,
+		maxWalkConcurrency,
 		logLevel,
+		keyPrefix,
+		roleARN,
+		externalID,
+		checksumOffloadBool,
+		dialTimeout,
+		responseHeaderTimeout,
+		idleConnTimeout,
+		maxIdleConnsPerHost,
+		requestTimeout,
+		forceListObjectsV1,
+		disableBulkDelete,
+		objectLockMode,
+		objectLockRetention,
+		objectLockLegalHold,
 	}
 
 	return New(params)
 }
 
+// parseAssumeRoleParams validates the rolearn and externalid parameters. The external ID is only meaningful when
+// assuming a role, so it requires the role ARN to be set.
+func parseAssumeRoleParams(roleARNParam, externalIDParam interface{}) (string, string, error) {
+	var roleARN, externalID string
+	var ok bool
+
+	if roleARNParam != nil {
+		if roleARN, ok = roleARNParam.(string); !ok {
+			return "", "", errors.New("the rolearn parameter should be a string")
+		}
+	}
+	if externalIDParam != nil {
+		if externalID, ok = externalIDParam.(string); !ok {
+			return "", "", errors.New("the externalid parameter should be a string")
+		}
+	}
+	if externalID != "" && roleARN == "" {
+		return "", "", errors.New("the externalid parameter requires the rolearn parameter to be set")
+	}
+
+	return roleARN, externalID, nil
+}
+
+// parseKeyPrefixParams expands the keyprefix template, replacing all occurrences of keyPrefixTenantPlaceholder
+// with the value of the tenant parameter. This allows multiple registry instances, each configured with a different
+// tenant, to share the same bucket without key collisions.
+func parseKeyPrefixParams(keyPrefixParam, tenantParam interface{}) (string, error) {
+	if keyPrefixParam == nil {
+		if tenantParam != nil {
+			return "", errors.New("the tenant parameter requires the keyprefix parameter to be set")
+		}
+		return "", nil
+	}
+
+	keyPrefix, ok := keyPrefixParam.(string)
+	if !ok {
+		return "", errors.New("the keyprefix parameter should be a string")
+	}
+
+	var tenant string
+	if tenantParam != nil {
+		tenant, ok = tenantParam.(string)
+		if !ok {
+			return "", errors.New("the tenant parameter should be a string")
+		}
+		if strings.Contains(tenant, "/") {
+			return "", fmt.Errorf("the tenant parameter must not contain slashes, %q invalid", tenant)
+		}
+	}
+
+	if strings.Contains(keyPrefix, keyPrefixTenantPlaceholder) {
+		if tenant == "" {
+			return "", fmt.Errorf("the keyprefix parameter contains the %s placeholder but no tenant parameter was set", keyPrefixTenantPlaceholder)
+		}
+		keyPrefix = strings.ReplaceAll(keyPrefix, keyPrefixTenantPlaceholder, tenant)
+	}
+
+	return strings.Trim(keyPrefix, "/"), nil
+}
+
 // getParameterAsInt64 converts parameters[name] to an int64 value (using
 // default if nil), verifies it is no smaller than min, and returns it.
 func getParameterAsInt64(parameters map[string]interface{}, name string, defaultt int64, min int64, max int64) (int64, error) {
@@ -552,6 +787,101 @@ func getParameterAsInt64(parameters map[string]interface{}, name string, default
 	return rv, nil
 }
 
+// getParameterAsDuration converts parameters[name] to a non-negative time.Duration value (using default if nil).
+func getParameterAsDuration(parameters map[string]interface{}, name string, defaultt time.Duration) (time.Duration, error) {
+	rv := defaultt
+	param := parameters[name]
+	switch v := param.(type) {
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("the %s parameter should be a duration, %v invalid", name, param)
+		}
+		rv = d
+	case time.Duration:
+		rv = v
+	case nil:
+		// do nothing
+	default:
+		return 0, fmt.Errorf("the %s parameter should be a duration, %v invalid", name, param)
+	}
+
+	if rv < 0 {
+		return 0, fmt.Errorf("the %s parameter should not be negative, %v invalid", name, param)
+	}
+
+	return rv, nil
+}
+
+// getParameterAsBool returns the boolean value of the named parameter, or defaultt if it is not set.
+func getParameterAsBool(parameters map[string]interface{}, name string, defaultt bool) (bool, error) {
+	rv := defaultt
+	param := parameters[name]
+	switch v := param.(type) {
+	case string:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, fmt.Errorf("the %s parameter should be a boolean, %v invalid", name, param)
+		}
+		rv = b
+	case bool:
+		rv = v
+	case nil:
+		// do nothing
+	default:
+		return false, fmt.Errorf("the %s parameter should be a boolean, %v invalid", name, param)
+	}
+
+	return rv, nil
+}
+
+// newTransport returns the HTTP transport used to talk to S3. It is based on http.DefaultTransport, with the
+// timeouts and connection pool size set from params. Zero values keep the defaults of http.DefaultTransport, except
+// for ResponseHeaderTimeout, which is then disabled.
+func newTransport(params DriverParameters) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if params.DialTimeout > 0 {
+		t.DialContext = (&net.Dialer{
+			Timeout:   params.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	t.ResponseHeaderTimeout = params.ResponseHeaderTimeout
+	if params.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = params.IdleConnTimeout
+	}
+	if params.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = int(params.MaxIdleConnsPerHost)
+		if t.MaxIdleConnsPerHost > defaultMaxIdleConns {
+			t.MaxIdleConns = t.MaxIdleConnsPerHost
+		}
+	}
+	if params.SkipVerify {
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return t
+}
+
+// requestTimeoutHandler bounds the duration of each S3 request, including the retries made by the AWS SDK, to
+// timeout. Object downloads are not bounded, as their body is only read once the request is complete, which may
+// take arbitrarily long for large blobs. Pre-signed requests are not sent, so they are not bounded either.
+func requestTimeoutHandler(timeout time.Duration) request.NamedHandler {
+	return request.NamedHandler{
+		Name: "docker-distribution.RequestTimeout",
+		Fn: func(r *request.Request) {
+			if r.Operation.Name == "GetObject" || r.ExpireTime > 0 {
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			r.SetContext(ctx)
+			r.Handlers.Complete.PushBack(func(*request.Request) { cancel() })
+		},
+	}
+}
+
 // New constructs a new Driver with the given AWS credentials, region, encryption flag, and
 // bucketName
 func New(params DriverParameters) (*Driver, error) {
@@ -560,6 +890,9 @@ func New(params DriverParameters) (*Driver, error) {
 			strings.Contains(params.RegionEndpoint, "s3.amazonaws.com")) {
 		return nil, fmt.Errorf("on Amazon S3 this storage driver can only be used with v4 authentication")
 	}
+	if params.MaxWalkConcurrency <= 0 {
+		params.MaxWalkConcurrency = defaultMaxWalkConcurrency
+	}
 
 	awsConfig := aws.NewConfig().WithLogLevel(params.LogLevel)
 	sess, err := session.NewSession()
@@ -580,6 +913,18 @@ func New(params DriverParameters) (*Driver, error) {
         webIdentityProvider(sess),
 	})
 
+	if params.RoleARN != "" {
+		// the credentials found by the chain above are only used to assume the configured role
+		stsSess, err := session.NewSession(aws.NewConfig().
+			WithLogLevel(params.LogLevel).
+			WithRegion(params.Region).
+			WithCredentials(creds))
+		if err != nil {
+			return nil, fmt.Errorf("creating a new session for STS: %w", err)
+		}
+		creds = assumeRoleCredentials(sts.New(stsSess), params.RoleARN, params.ExternalID)
+	}
+
 	if params.RegionEndpoint != "" {
 		awsConfig.WithEndpoint(params.RegionEndpoint)
 	}
@@ -589,13 +934,7 @@ func New(params DriverParameters) (*Driver, error) {
 	awsConfig.WithRegion(params.Region)
 	awsConfig.WithDisableSSL(!params.Secure)
 
-	if params.SkipVerify {
-		awsConfig.WithHTTPClient(&http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-			},
-		})
-	}
+	awsConfig.WithHTTPClient(&http.Client{Transport: newTransport(params)})
 
 	sess, err = session.NewSession(awsConfig)
 	if err != nil {
@@ -607,6 +946,9 @@ func New(params DriverParameters) (*Driver, error) {
 		Fn:   request.MakeAddToUserAgentHandler("docker-distribution", version.Version, runtime.Version()),
 	}
 	sess.Handlers.Build.PushFrontNamed(userAgentHandler)
+	if params.RequestTimeout > 0 {
+		sess.Handlers.Validate.PushFrontNamed(requestTimeoutHandler(params.RequestTimeout))
+	}
 
 	s3obj := s3.New(sess)
 
@@ -630,14 +972,20 @@ func New(params DriverParameters) (*Driver, error) {
 	// 	}
 	// }
 
-	w := newS3Wrapper(
-		s3obj,
+	wrapperOpts := []wrapperOpt{
 		withRateLimit(params.MaxRequestsPerSecond, defaultBurst),
 		withExponentialBackoff(params.MaxRetries),
 		withBackoffNotify(func(err error, t time.Duration) {
 			log.WithFields(log.Fields{"error": err, "delay_s": t.Seconds()}).Info("S3: retrying after error")
 		}),
-	)
+	}
+	if params.ForceListObjectsV1 {
+		wrapperOpts = append(wrapperOpts, withListObjectsV1())
+	}
+	if params.DisableBulkDelete {
+		wrapperOpts = append(wrapperOpts, withoutBulkDelete())
+	}
+	w := newS3Wrapper(s3obj, wrapperOpts...)
 
 	d := &driver{
 		S3:                          w,
@@ -652,6 +1000,12 @@ func New(params DriverParameters) (*Driver, error) {
 		StorageClass:                params.StorageClass,
 		ObjectACL:                   params.ObjectACL,
 		ParallelWalk:                params.ParallelWalk,
+		MaxWalkConcurrency:          params.MaxWalkConcurrency,
+		KeyPrefix:                   strings.Trim(params.KeyPrefix, "/"),
+		ChecksumOffload:             params.ChecksumOffload,
+		ObjectLockMode:              params.ObjectLockMode,
+		ObjectLockRetention:         params.ObjectLockRetention,
+		ObjectLockLegalHold:         params.ObjectLockLegalHold,
 	}
 
 	return &Driver{
@@ -672,6 +1026,17 @@ func webIdentityProvider(sess client.ConfigProvider) credentials.Provider {
 	return stscreds.NewWebIdentityRoleProvider(svc, roleARN, roleSessionName, tokenFilepath)
 }
 
+// assumeRoleCredentials returns credentials obtained by assuming roleARN through STS, optionally presenting an
+// external ID. The temporary credentials are refreshed automatically shortly before they expire.
+func assumeRoleCredentials(svc stscreds.AssumeRoler, roleARN, externalID string) *credentials.Credentials {
+	return stscreds.NewCredentialsWithClient(svc, roleARN, func(p *stscreds.AssumeRoleProvider) {
+		if externalID != "" {
+			p.ExternalID = aws.String(externalID)
+		}
+		p.ExpiryWindow = assumeRoleExpiryWindow
+	})
+}
+
 // Implement the storagedriver.StorageDriver interface
 
 func (d *driver) Name() string {
@@ -689,18 +1054,25 @@ func (d *driver) GetContent(ctx context.Context, path string) ([]byte, error) {
 
 // PutContent stores the []byte content at a location designated by "path".
 func (d *driver) PutContent(ctx context.Context, path string, contents []byte) error {
-	_, err := d.S3.PutObjectWithContext(
-		ctx,
-		&s3.PutObjectInput{
-			Bucket:               aws.String(d.Bucket),
-			Key:                  aws.String(d.s3Path(path)),
-			ContentType:          d.getContentType(),
-			ACL:                  d.getACL(),
-			ServerSideEncryption: d.getEncryptionMode(),
-			SSEKMSKeyId:          d.getSSEKMSKeyID(),
-			StorageClass:         d.getStorageClass(),
-			Body:                 bytes.NewReader(contents),
-		})
+	lock := d.getObjectLock(path)
+	input := &s3.PutObjectInput{
+		Bucket:                    aws.String(d.Bucket),
+		Key:                       aws.String(d.s3Path(path)),
+		ContentType:               d.getContentType(),
+		ACL:                       d.getACL(),
+		ServerSideEncryption:      d.getEncryptionMode(),
+		SSEKMSKeyId:               d.getSSEKMSKeyID(),
+		StorageClass:              d.getStorageClass(),
+		ObjectLockMode:            lock.mode,
+		ObjectLockRetainUntilDate: lock.retainUntil,
+		ObjectLockLegalHoldStatus: lock.legalHold,
+		Body:                      bytes.NewReader(contents),
+	}
+	if lock.enabled() {
+		// S3 requires the Content-MD5 header to be set when writing objects with Object Lock settings
+		input.ContentMD5 = contentMD5(contents)
+	}
+	_, err := d.S3.PutObjectWithContext(ctx, input)
 	return parseError(path, err)
 }
 
@@ -734,7 +1106,7 @@ func (d *driver) Writer(ctx context.Context, path string, append bool) (storaged
 
 		resp, err := d.S3.CreateMultipartUploadWithContext(
 			ctx,
-			&s3.CreateMultipartUploadInput{
+			d.getObjectLock(path).applyToMultipartUpload(&s3.CreateMultipartUploadInput{
 				Bucket:               aws.String(d.Bucket),
 				Key:                  aws.String(key),
 				ContentType:          d.getContentType(),
@@ -742,11 +1114,11 @@ func (d *driver) Writer(ctx context.Context, path string, append bool) (storaged
 				ServerSideEncryption: d.getEncryptionMode(),
 				SSEKMSKeyId:          d.getSSEKMSKeyID(),
 				StorageClass:         d.getStorageClass(),
-			})
+			}))
 		if err != nil {
 			return nil, err
 		}
-		return d.newWriter(key, *resp.UploadId, nil), nil
+		return d.newWriter(path, key, *resp.UploadId, nil), nil
 	}
 
 	resp, err := d.S3.ListMultipartUploadsWithContext(
@@ -778,7 +1150,7 @@ func (d *driver) Writer(ctx context.Context, path string, append bool) (storaged
 		for _, part := range resp.Parts {
 			multiSize += *part.Size
 		}
-		return d.newWriter(key, *multi.UploadId, resp.Parts), nil
+		return d.newWriter(path, key, *multi.UploadId, resp.Parts), nil
 	}
 	return nil, storagedriver.PathNotFoundError{Path: path}
 }
@@ -818,6 +1190,104 @@ func (d *driver) Stat(ctx context.Context, path string) (storagedriver.FileInfo,
 	return storagedriver.FileInfoInternal{FileInfoFields: fi}, nil
 }
 
+// Checksum returns the SHA-256 digest of the object stored at path, as computed
+// by S3. Objects without a full object SHA-256 checksum, such as those assembled
+// from a multipart upload, are copied in place asking S3 to compute one, which
+// does not require transferring their content through the registry. Returns
+// storagedriver.ErrUnsupportedMethod unless the checksumoffload parameter is
+// enabled.
+func (d *driver) Checksum(ctx context.Context, path string) (digest.Digest, error) {
+	if !d.ChecksumOffload {
+		return "", storagedriver.ErrUnsupportedMethod{}
+	}
+
+	dgst, size, err := d.headChecksum(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	if dgst != "" {
+		return dgst, nil
+	}
+	// Multipart copies only produce checksums of the checksums of each part,
+	// which are of no use to verify the object content.
+	if size > maxCopyObjectSize {
+		return "", storagedriver.ChecksumUnavailableError{Path: path}
+	}
+
+	key := d.s3Path(path)
+	_, err = d.S3.CopyObjectWithContext(
+		ctx,
+		&s3.CopyObjectInput{
+			Bucket:               aws.String(d.Bucket),
+			Key:                  aws.String(key),
+			ContentType:          d.getContentType(),
+			ACL:                  d.getACL(),
+			ServerSideEncryption: d.getEncryptionMode(),
+			SSEKMSKeyId:          d.getSSEKMSKeyID(),
+			StorageClass:         d.getStorageClass(),
+			CopySource:           aws.String(d.Bucket + "/" + key),
+			// copying an object onto itself is only allowed when replacing its metadata
+			MetadataDirective: aws.String(s3.MetadataDirectiveReplace),
+		},
+		request.WithSetRequestHeaders(map[string]string{checksumAlgorithmHeader: "SHA256"}))
+	if err != nil {
+		return "", parseError(path, err)
+	}
+
+	dgst, _, err = d.headChecksum(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	if dgst == "" {
+		// S3 compatible backends may silently ignore the checksum algorithm header
+		return "", storagedriver.ChecksumUnavailableError{Path: path}
+	}
+
+	return dgst, nil
+}
+
+// headChecksum returns the SHA-256 checksum and the size of the object stored
+// at path. The returned digest is empty if the object has no full object
+// SHA-256 checksum.
+func (d *driver) headChecksum(ctx context.Context, path string) (digest.Digest, int64, error) {
+	var checksum string
+
+	resp, err := d.S3.HeadObjectWithContext(
+		ctx,
+		&s3.HeadObjectInput{
+			Bucket: aws.String(d.Bucket),
+			Key:    aws.String(d.s3Path(path)),
+		},
+		request.WithSetRequestHeaders(map[string]string{checksumModeHeader: "ENABLED"}),
+		request.WithGetResponseHeader(checksumSHA256Header, &checksum))
+	if err != nil {
+		if s3Err, ok := err.(awserr.Error); ok && s3Err.Code() == "NotFound" {
+			return "", 0, storagedriver.PathNotFoundError{Path: path}
+		}
+		return "", 0, err
+	}
+
+	return parseChecksumSHA256(checksum), aws.Int64Value(resp.ContentLength), nil
+}
+
+// parseChecksumSHA256 converts a base64 encoded SHA-256 checksum reported by
+// S3 to a digest. Composite checksums of multipart objects, suffixed with the
+// number of parts, are checksums of the checksums of each part and are
+// therefore ignored. An empty digest is returned if the checksum is missing or
+// can not be converted.
+func parseChecksumSHA256(checksum string) digest.Digest {
+	if checksum == "" || strings.Contains(checksum, "-") {
+		return ""
+	}
+
+	b, err := base64.StdEncoding.DecodeString(checksum)
+	if err != nil || len(b) != sha256.Size {
+		return ""
+	}
+
+	return digest.NewDigestFromBytes(digest.SHA256, b)
+}
+
 // List returns a list of the objects that are direct descendants of the given path.
 func (d *driver) List(ctx context.Context, opath string) ([]string, error) {
 	path := opath
@@ -899,6 +1369,13 @@ func (d *driver) Move(ctx context.Context, sourcePath string, destPath string) e
 
 // copy copies an object stored at sourcePath to destPath.
 func (d *driver) copy(ctx context.Context, sourcePath string, destPath string) error {
+	return d.copyTo(ctx, d, sourcePath, destPath)
+}
+
+// copyTo copies an object stored at sourcePath to destPath in the bucket of
+// dest, which may be the same driver. The copy is performed server side using
+// the client of d, whose credentials must have access to both buckets.
+func (d *driver) copyTo(ctx context.Context, dest *driver, sourcePath string, destPath string) error {
 	// S3 can copy objects up to 5 GB in size with a single PUT Object - Copy
 	// operation. For larger objects, the multipart upload API must be used.
 	//
@@ -913,16 +1390,16 @@ func (d *driver) copy(ctx context.Context, sourcePath string, destPath string) e
 	if fileInfo.Size() <= d.MultipartCopyThresholdSize {
 		_, err = d.S3.CopyObjectWithContext(
 			ctx,
-			&s3.CopyObjectInput{
-				Bucket:               aws.String(d.Bucket),
-				Key:                  aws.String(d.s3Path(destPath)),
-				ContentType:          d.getContentType(),
-				ACL:                  d.getACL(),
-				ServerSideEncryption: d.getEncryptionMode(),
-				SSEKMSKeyId:          d.getSSEKMSKeyID(),
-				StorageClass:         d.getStorageClass(),
+			dest.getObjectLock(destPath).applyToCopy(&s3.CopyObjectInput{
+				Bucket:               aws.String(dest.Bucket),
+				Key:                  aws.String(dest.s3Path(destPath)),
+				ContentType:          dest.getContentType(),
+				ACL:                  dest.getACL(),
+				ServerSideEncryption: dest.getEncryptionMode(),
+				SSEKMSKeyId:          dest.getSSEKMSKeyID(),
+				StorageClass:         dest.getStorageClass(),
 				CopySource:           aws.String(d.Bucket + "/" + d.s3Path(sourcePath)),
-			})
+			}))
 		if err != nil {
 			return parseError(sourcePath, err)
 		}
@@ -931,15 +1408,15 @@ func (d *driver) copy(ctx context.Context, sourcePath string, destPath string) e
 
 	createResp, err := d.S3.CreateMultipartUploadWithContext(
 		ctx,
-		&s3.CreateMultipartUploadInput{
-			Bucket:               aws.String(d.Bucket),
-			Key:                  aws.String(d.s3Path(destPath)),
-			ContentType:          d.getContentType(),
-			ACL:                  d.getACL(),
-			SSEKMSKeyId:          d.getSSEKMSKeyID(),
-			ServerSideEncryption: d.getEncryptionMode(),
-			StorageClass:         d.getStorageClass(),
-		})
+		dest.getObjectLock(destPath).applyToMultipartUpload(&s3.CreateMultipartUploadInput{
+			Bucket:               aws.String(dest.Bucket),
+			Key:                  aws.String(dest.s3Path(destPath)),
+			ContentType:          dest.getContentType(),
+			ACL:                  dest.getACL(),
+			SSEKMSKeyId:          dest.getSSEKMSKeyID(),
+			ServerSideEncryption: dest.getEncryptionMode(),
+			StorageClass:         dest.getStorageClass(),
+		}))
 	if err != nil {
 		return err
 	}
@@ -966,9 +1443,9 @@ func (d *driver) copy(ctx context.Context, sourcePath string, destPath string) e
 			uploadResp, err := d.S3.UploadPartCopyWithContext(
 				ctx,
 				&s3.UploadPartCopyInput{
-					Bucket:          aws.String(d.Bucket),
+					Bucket:          aws.String(dest.Bucket),
 					CopySource:      aws.String(d.Bucket + "/" + d.s3Path(sourcePath)),
-					Key:             aws.String(d.s3Path(destPath)),
+					Key:             aws.String(dest.s3Path(destPath)),
 					PartNumber:      aws.Int64(i + 1),
 					UploadId:        createResp.UploadId,
 					CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", firstByte, lastByte)),
@@ -994,14 +1471,131 @@ func (d *driver) copy(ctx context.Context, sourcePath string, destPath string) e
 	_, err = d.S3.CompleteMultipartUploadWithContext(
 		ctx,
 		&s3.CompleteMultipartUploadInput{
-			Bucket:          aws.String(d.Bucket),
-			Key:             aws.String(d.s3Path(destPath)),
+			Bucket:          aws.String(dest.Bucket),
+			Key:             aws.String(dest.s3Path(destPath)),
 			UploadId:        createResp.UploadId,
 			MultipartUpload: &s3.CompletedMultipartUpload{Parts: completedParts},
 		})
 	return err
 }
 
+// Concat appends the objects stored at sourcePaths, in order, to the object
+// stored at path. The objects are assembled server side, as the parts of a
+// multipart upload, so they must satisfy the multipart upload constraints: up
+// to 10000 parts of at most 5 GB each, all but the last one being at least 5
+// MB. ErrUnsupportedMethod is returned otherwise, leaving the objects untouched.
+func (d *driver) Concat(ctx context.Context, path string, sourcePaths []string) error {
+	var paths []string
+	var sizes []int64
+
+	// S3 does not allow empty parts, so an empty or missing destination
+	// object is simply replaced.
+	fi, err := d.Stat(ctx, path)
+	if err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+			return err
+		}
+	} else if fi.Size() > 0 {
+		paths = append(paths, path)
+		sizes = append(sizes, fi.Size())
+	}
+	for _, p := range sourcePaths {
+		fi, err := d.Stat(ctx, p)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, p)
+		sizes = append(sizes, fi.Size())
+	}
+
+	if len(paths) == 0 || len(paths) > 10000 {
+		return storagedriver.ErrUnsupportedMethod{DriverName: driverName}
+	}
+	for i, size := range sizes {
+		if size == 0 || size > maxCopyObjectSize || (i < len(sizes)-1 && size < minChunkSize) {
+			return storagedriver.ErrUnsupportedMethod{DriverName: driverName}
+		}
+	}
+
+	key := d.s3Path(path)
+	createResp, err := d.S3.CreateMultipartUploadWithContext(
+		ctx,
+		d.getObjectLock(path).applyToMultipartUpload(&s3.CreateMultipartUploadInput{
+			Bucket:               aws.String(d.Bucket),
+			Key:                  aws.String(key),
+			ContentType:          d.getContentType(),
+			ACL:                  d.getACL(),
+			SSEKMSKeyId:          d.getSSEKMSKeyID(),
+			ServerSideEncryption: d.getEncryptionMode(),
+			StorageClass:         d.getStorageClass(),
+		}))
+	if err != nil {
+		return parseError(path, err)
+	}
+
+	completedParts := make([]*s3.CompletedPart, len(paths))
+	errChan := make(chan error, len(paths))
+
+	// Reduce the client/server exposure to long lived connections regardless of
+	// how many requests per second are allowed.
+	limiter := make(chan struct{}, d.MultipartCopyMaxConcurrency)
+
+	for i, p := range paths {
+		i, p := i, p
+		go func() {
+			limiter <- struct{}{}
+			defer func() { <-limiter }()
+
+			resp, err := d.S3.UploadPartCopyWithContext(
+				ctx,
+				&s3.UploadPartCopyInput{
+					Bucket:     aws.String(d.Bucket),
+					CopySource: aws.String(d.Bucket + "/" + d.s3Path(p)),
+					Key:        aws.String(key),
+					PartNumber: aws.Int64(int64(i + 1)),
+					UploadId:   createResp.UploadId,
+				})
+			if err == nil {
+				completedParts[i] = &s3.CompletedPart{
+					ETag:       resp.CopyPartResult.ETag,
+					PartNumber: aws.Int64(int64(i + 1)),
+				}
+			}
+			errChan <- err
+		}()
+	}
+
+	for range completedParts {
+		if e := <-errChan; e != nil && err == nil {
+			err = e
+		}
+	}
+	if err == nil {
+		_, err = d.S3.CompleteMultipartUploadWithContext(
+			ctx,
+			&s3.CompleteMultipartUploadInput{
+				Bucket:          aws.String(d.Bucket),
+				Key:             aws.String(key),
+				UploadId:        createResp.UploadId,
+				MultipartUpload: &s3.CompletedMultipartUpload{Parts: completedParts},
+			})
+	}
+	if err != nil {
+		if _, abortErr := d.S3.AbortMultipartUploadWithContext(
+			ctx,
+			&s3.AbortMultipartUploadInput{
+				Bucket:   aws.String(d.Bucket),
+				Key:      aws.String(key),
+				UploadId: createResp.UploadId,
+			}); abortErr != nil {
+			dcontext.GetLogger(ctx).WithError(abortErr).Warn("failed to abort multipart upload")
+		}
+		return parseError(path, err)
+	}
+
+	return nil
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -1053,7 +1647,7 @@ ListLoop:
 	// need to chunk objects into groups of deleteMax per s3 restrictions
 	total := len(s3Objects)
 	for i := 0; i < total; i += deleteMax {
-		_, err := d.S3.DeleteObjectsWithContext(
+		resp, err := d.S3.DeleteObjectsWithContext(
 			ctx,
 			&s3.DeleteObjectsInput{
 				Bucket: aws.String(d.Bucket),
@@ -1065,6 +1659,12 @@ ListLoop:
 		if err != nil {
 			return err
 		}
+		// objects protected by Object Lock are reported as per object errors
+		for _, s3e := range resp.Errors {
+			if isObjectLockedError(aws.StringValue(s3e.Code), aws.StringValue(s3e.Message)) {
+				return storagedriver.ObjectLockedError{Path: path, DriverName: driverName}
+			}
+		}
 	}
 	return nil
 }
@@ -1076,9 +1676,11 @@ ListLoop:
 // files and any errors. This method is idempotent, no error is returned if a file does not exist.
 func (d *driver) DeleteFiles(ctx context.Context, paths []string) (int, error) {
 	s3Objects := make([]*s3.ObjectIdentifier, 0, len(paths))
+	keyToPath := make(map[string]string, len(paths))
 	for i := range paths {
 		p := d.s3Path(paths[i])
 		s3Objects = append(s3Objects, &s3.ObjectIdentifier{Key: &p})
+		keyToPath[p] = paths[i]
 	}
 
 	// collect errors from concurrent DeleteObjects requests
@@ -1134,7 +1736,12 @@ func (d *driver) DeleteFiles(ctx context.Context, paths []string) (int, error) {
 				// parse s3.Error errors and return a single storagedriver.MultiError
 				var errs error
 				for _, s3e := range resp.Errors {
-					err := fmt.Errorf("deleting file '%s': '%s'", *s3e.Key, *s3e.Message)
+					var err error
+					if isObjectLockedError(aws.StringValue(s3e.Code), aws.StringValue(s3e.Message)) {
+						err = storagedriver.ObjectLockedError{Path: keyToPath[*s3e.Key], DriverName: driverName}
+					} else {
+						err = fmt.Errorf("deleting file '%s': '%s'", *s3e.Key, *s3e.Message)
+					}
 					errs = multierror.Append(errs, err)
 				}
 				errCh <- errs
@@ -1238,6 +1845,8 @@ func (d *driver) WalkParallel(ctx context.Context, from string, f storagedriver.
 
 	var objectCount int64
 	var retError error
+	// bounds the number of goroutines calling f concurrently
+	semaphore := make(chan struct{}, d.MaxWalkConcurrency)
 	countChan := make(chan int64)
 	countDone := make(chan struct{})
 	errors := make(chan error)
@@ -1277,7 +1886,7 @@ func (d *driver) WalkParallel(ctx context.Context, from string, f storagedriver.
 	// entire walk to complete without blocking on each doWalkParallel call.
 	var wg sync.WaitGroup
 
-	d.doWalkParallel(ctx, &wg, countChan, quit, errors, d.s3Path(path), prefix, f)
+	d.doWalkParallel(ctx, &wg, semaphore, countChan, quit, errors, d.s3Path(path), prefix, f)
 
 	wg.Wait()
 
@@ -1295,8 +1904,41 @@ func (d *driver) WalkParallel(ctx context.Context, from string, f storagedriver.
 	return retError
 }
 
+// TransferTo copies the object stored at src to dest in the bucket of
+// destDriver, which must be another S3 driver, server side. The credentials of
+// the source driver are used, so they must grant write access to the
+// destination bucket.
 func (d *driver) TransferTo(ctx context.Context, destDriver storagedriver.StorageDriver, src, dest string) error {
-	return storagedriver.ErrUnsupportedMethod{}
+	targetDriver, err := convertToS3(destDriver)
+	if err != nil {
+		return fmt.Errorf("unable to begin transfer: %w", err)
+	}
+
+	// compare the resulting key prefixes, as the root directory alone does not identify the location of the objects
+	// when a key prefix is configured
+	if targetDriver.Bucket == d.Bucket && targetDriver.s3Path("") == d.s3Path("") {
+		return errors.New("srcDriver and destDriver must not have the same bucket, key prefix and root directory")
+	}
+
+	if err := d.copyTo(ctx, targetDriver, src, dest); err != nil {
+		return storagedriver.PartialTransferError{SourcePath: src, DestinationPath: dest, Cause: err}
+	}
+
+	return nil
+}
+
+func convertToS3(destDriver storagedriver.StorageDriver) (*driver, error) {
+	dd, ok := destDriver.(*Driver)
+	if !ok {
+		return nil, errors.New("destDriver must be an s3 Driver")
+	}
+
+	innerDriver, ok := dd.Base.StorageDriver.(*driver)
+	if !ok {
+		return nil, errors.New("destDriver base driver must be an s3 driver")
+	}
+
+	return innerDriver, nil
 }
 
 type walkInfoContainer struct {
@@ -1414,7 +2056,7 @@ func (d *driver) doWalk(parentCtx context.Context, objectCount *int64, path, pre
 	return nil
 }
 
-func (d *driver) doWalkParallel(parentCtx context.Context, wg *sync.WaitGroup, countChan chan<- int64, quit <-chan struct{}, errors chan<- error, path, prefix string, f storagedriver.WalkFn) {
+func (d *driver) doWalkParallel(parentCtx context.Context, wg *sync.WaitGroup, semaphore chan struct{}, countChan chan<- int64, quit <-chan struct{}, errors chan<- error, path, prefix string, f storagedriver.WalkFn) {
 	listObjectsInput := &s3.ListObjectsV2Input{
 		Bucket:    aws.String(d.Bucket),
 		Prefix:    aws.String(path),
@@ -1470,10 +2112,18 @@ func (d *driver) doWalkParallel(parentCtx context.Context, wg *sync.WaitGroup, c
 			for _, walkInfo := range walkInfos {
 				wg.Add(1)
 				wInfo := walkInfo
+
+				// Wait for a free slot before launching a new goroutine, so that
+				// goroutines are not allocated only to wait. The slot is released
+				// once f returns, before descending into directories, as the
+				// recursive call needs slots of its own.
+				semaphore <- struct{}{}
+
 				go func() {
 					defer wg.Done()
 
 					err := f(wInfo)
+					<-semaphore
 
 					if err == storagedriver.ErrSkipDir && wInfo.IsDir() {
 						return
@@ -1484,7 +2134,7 @@ func (d *driver) doWalkParallel(parentCtx context.Context, wg *sync.WaitGroup, c
 					}
 
 					if wInfo.IsDir() {
-						d.doWalkParallel(ctx, wg, countChan, quit, errors, *wInfo.prefix, prefix, f)
+						d.doWalkParallel(ctx, wg, semaphore, countChan, quit, errors, *wInfo.prefix, prefix, f)
 					}
 				}()
 			}
@@ -1497,8 +2147,14 @@ func (d *driver) doWalkParallel(parentCtx context.Context, wg *sync.WaitGroup, c
 	}
 }
 
+// s3Path returns the key of the object stored at path, which is prefixed by the (expanded) key prefix, if any,
+// followed by the root directory.
 func (d *driver) s3Path(path string) string {
-	return strings.TrimLeft(strings.TrimRight(d.RootDirectory, "/")+path, "/")
+	root := strings.TrimRight(d.RootDirectory, "/")
+	if d.KeyPrefix != "" {
+		root = strings.TrimRight("/"+d.KeyPrefix+"/"+strings.TrimLeft(root, "/"), "/")
+	}
+	return strings.TrimLeft(root+path, "/")
 }
 
 // S3BucketKey returns the s3 bucket key for the given storage driver path.
@@ -1507,13 +2163,81 @@ func (d *Driver) S3BucketKey(path string) string {
 }
 
 func parseError(path string, err error) error {
-	if s3Err, ok := err.(awserr.Error); ok && s3Err.Code() == "NoSuchKey" {
-		return storagedriver.PathNotFoundError{Path: path}
+	if s3Err, ok := err.(awserr.Error); ok {
+		if s3Err.Code() == "NoSuchKey" {
+			return storagedriver.PathNotFoundError{Path: path}
+		}
+		if isObjectLockedError(s3Err.Code(), s3Err.Message()) {
+			return storagedriver.ObjectLockedError{Path: path, DriverName: driverName}
+		}
 	}
 
 	return err
 }
 
+// isObjectLockedError returns whether an S3 error with the given code and message was caused by a retention period
+// or legal hold protecting the target object. S3 reports these as generic AccessDenied errors, so the message must be
+// inspected to tell them apart from permission errors. Some S3 compatible backends use a dedicated code instead.
+func isObjectLockedError(code, message string) bool {
+	switch code {
+	case "ObjectLocked":
+		return true
+	case "AccessDenied":
+		message = strings.ToLower(message)
+		return strings.Contains(message, "object lock") || strings.Contains(message, "worm protected")
+	default:
+		return false
+	}
+}
+
+// objectLock holds the Object Lock settings to apply to a newly written object. All fields are nil if none apply.
+type objectLock struct {
+	mode        *string
+	retainUntil *time.Time
+	legalHold   *string
+}
+
+func (l objectLock) enabled() bool {
+	return l.mode != nil || l.legalHold != nil
+}
+
+func (l objectLock) applyToMultipartUpload(input *s3.CreateMultipartUploadInput) *s3.CreateMultipartUploadInput {
+	input.ObjectLockMode = l.mode
+	input.ObjectLockRetainUntilDate = l.retainUntil
+	input.ObjectLockLegalHoldStatus = l.legalHold
+	return input
+}
+
+func (l objectLock) applyToCopy(input *s3.CopyObjectInput) *s3.CopyObjectInput {
+	input.ObjectLockMode = l.mode
+	input.ObjectLockRetainUntilDate = l.retainUntil
+	input.ObjectLockLegalHoldStatus = l.legalHold
+	return input
+}
+
+// getObjectLock returns the Object Lock settings to apply to an object written at path. These only apply to blob
+// data, see blobDataPathPrefix. The retention period is counted from now.
+func (d *driver) getObjectLock(path string) objectLock {
+	var l objectLock
+	if !strings.HasPrefix(path, blobDataPathPrefix) {
+		return l
+	}
+	if d.ObjectLockMode != "" {
+		l.mode = aws.String(d.ObjectLockMode)
+		l.retainUntil = aws.Time(time.Now().Add(d.ObjectLockRetention).UTC())
+	}
+	if d.ObjectLockLegalHold {
+		l.legalHold = aws.String(s3.ObjectLockLegalHoldStatusOn)
+	}
+	return l
+}
+
+// contentMD5 returns the base64 encoded MD5 checksum of b, as expected by the Content-MD5 header.
+func contentMD5(b []byte) *string {
+	sum := md5.Sum(b)
+	return aws.String(base64.StdEncoding.EncodeToString(sum[:]))
+}
+
 func (d *driver) getEncryptionMode() *string {
 	if !d.Encrypt {
 		return nil
@@ -1552,6 +2276,7 @@ func (d *driver) getStorageClass() *string {
 // than a full chunk is written.
 type writer struct {
 	driver      *driver
+	path        string
 	key         string
 	uploadID    string
 	parts       []*s3.Part
@@ -1563,13 +2288,14 @@ type writer struct {
 	canceled    bool
 }
 
-func (d *driver) newWriter(key, uploadID string, parts []*s3.Part) storagedriver.FileWriter {
+func (d *driver) newWriter(path, key, uploadID string, parts []*s3.Part) storagedriver.FileWriter {
 	var size int64
 	for _, part := range parts {
 		size += *part.Size
 	}
 	return &writer{
 		driver:   d,
+		path:     path,
 		key:      key,
 		uploadID: uploadID,
 		parts:    parts,
@@ -1630,14 +2356,14 @@ func (w *writer) Write(p []byte) (int, error) {
 
 		resp, err := w.driver.S3.CreateMultipartUploadWithContext(
 			ctx,
-			&s3.CreateMultipartUploadInput{
+			w.driver.getObjectLock(w.path).applyToMultipartUpload(&s3.CreateMultipartUploadInput{
 				Bucket:               aws.String(w.driver.Bucket),
 				Key:                  aws.String(w.key),
 				ContentType:          w.driver.getContentType(),
 				ACL:                  w.driver.getACL(),
 				ServerSideEncryption: w.driver.getEncryptionMode(),
 				StorageClass:         w.driver.getStorageClass(),
-			})
+			}))
 		if err != nil {
 			return 0, err
 		}
@@ -1815,15 +2541,18 @@ func (w *writer) flushPart() error {
 	}
 
 	partNumber := aws.Int64(int64(len(w.parts) + 1))
-	resp, err := w.driver.S3.UploadPartWithContext(
-		context.Background(),
-		&s3.UploadPartInput{
-			Bucket:     aws.String(w.driver.Bucket),
-			Key:        aws.String(w.key),
-			PartNumber: partNumber,
-			UploadId:   aws.String(w.uploadID),
-			Body:       bytes.NewReader(w.readyPart),
-		})
+	input := &s3.UploadPartInput{
+		Bucket:     aws.String(w.driver.Bucket),
+		Key:        aws.String(w.key),
+		PartNumber: partNumber,
+		UploadId:   aws.String(w.uploadID),
+		Body:       bytes.NewReader(w.readyPart),
+	}
+	if w.driver.getObjectLock(w.path).enabled() {
+		// S3 requires the Content-MD5 header to be set when uploading parts of objects with Object Lock settings
+		input.ContentMD5 = contentMD5(w.readyPart)
+	}
+	resp, err := w.driver.S3.UploadPartWithContext(context.Background(), input)
 	if err != nil {
 		return err
 	}
@@ -2,12 +2,16 @@ package s3
 
 import (
 	"bytes"
+	"errors"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -24,6 +28,7 @@ import (
 	"github.com/docker/distribution/context"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/registry/storage/driver/testsuites"
+	"github.com/opencontainers/go-digest"
 )
 
 // Hook up gocheck into the "go test" runner.
@@ -50,6 +55,10 @@ func init() {
 	maxRequestsPerSecond := os.Getenv("S3_MAX_REQUESTS_PER_SEC")
 	maxRetries := os.Getenv("S3_MAX_RETRIES")
 	logLevel := os.Getenv("S3_LOG_LEVEL")
+	keyPrefix := os.Getenv("S3_KEY_PREFIX")
+	roleARN := os.Getenv("S3_ROLE_ARN")
+	externalID := os.Getenv("S3_EXTERNAL_ID")
+	checksumOffload := os.Getenv("S3_CHECKSUM_OFFLOAD")
 
 	if err != nil {
 		panic(err)
@@ -121,6 +130,14 @@ func init() {
 
 		parallelWalkBool := true
 
+		checksumOffloadBool := false
+		if checksumOffload != "" {
+			checksumOffloadBool, err = strconv.ParseBool(checksumOffload)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		logLevelType := parseLogLevelParam(logLevel)
 
 		parameters := DriverParameters{
@@ -146,7 +163,22 @@ func init() {
 			maxRequestsPerSecondInt64,
 			maxRetriesInt64,
 			parallelWalkBool,
+			defaultMaxWalkConcurrency,
 			logLevelType,
+			keyPrefix,
+			roleARN,
+			externalID,
+			checksumOffloadBool,
+			defaultDialTimeout,
+			0,
+			defaultIdleConnTimeout,
+			defaultMaxIdleConnsPerHost,
+			0,
+			false,
+			false,
+			"",
+			0,
+			false,
 		}
 
 		return New(parameters)
@@ -233,6 +265,491 @@ func TestFromParameters(t *testing.T) {
 	}
 }
 
+func TestFromParameters_KeyPrefix(t *testing.T) {
+	baseParams := map[string]interface{}{
+		"region": "us-west-2",
+		"bucket": "test",
+		"v4auth": "true",
+	}
+
+	tests := []struct {
+		name            string
+		params          map[string]interface{}
+		wantedKeyPrefix string
+		wantErr         bool
+	}{
+		{
+			name:            "none",
+			params:          map[string]interface{}{},
+			wantedKeyPrefix: "",
+		},
+		{
+			name:            "static",
+			params:          map[string]interface{}{"keyprefix": "/registry/"},
+			wantedKeyPrefix: "registry",
+		},
+		{
+			name:            "tenant",
+			params:          map[string]interface{}{"keyprefix": "tenants/{tenant}/registry", "tenant": "acme"},
+			wantedKeyPrefix: "tenants/acme/registry",
+		},
+		{
+			name:    "tenant placeholder without tenant",
+			params:  map[string]interface{}{"keyprefix": "tenants/{tenant}"},
+			wantErr: true,
+		},
+		{
+			name:    "tenant without key prefix",
+			params:  map[string]interface{}{"tenant": "acme"},
+			wantErr: true,
+		},
+		{
+			name:    "tenant with slashes",
+			params:  map[string]interface{}{"keyprefix": "{tenant}", "tenant": "acme/foo"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid key prefix type",
+			params:  map[string]interface{}{"keyprefix": 1},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range baseParams {
+				tt.params[k] = v
+			}
+
+			d, err := FromParameters(tt.params)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error with params %#v", tt.params)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unable to create a new S3 driver: %v", err)
+			}
+
+			keyPrefix := d.baseEmbed.Base.StorageDriver.(*driver).KeyPrefix
+			if keyPrefix != tt.wantedKeyPrefix {
+				t.Fatalf("expected KeyPrefix to be %q, got %q", tt.wantedKeyPrefix, keyPrefix)
+			}
+		})
+	}
+}
+
+func TestFromParameters_AssumeRole(t *testing.T) {
+	baseParams := map[string]interface{}{
+		"region": "us-west-2",
+		"bucket": "test",
+		"v4auth": "true",
+	}
+
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:   "none",
+			params: map[string]interface{}{},
+		},
+		{
+			name:   "role ARN",
+			params: map[string]interface{}{"rolearn": "arn:aws:iam::123456789012:role/registry"},
+		},
+		{
+			name: "role ARN and external ID",
+			params: map[string]interface{}{
+				"rolearn":    "arn:aws:iam::123456789012:role/registry",
+				"externalid": "foo",
+			},
+		},
+		{
+			name:    "external ID without role ARN",
+			params:  map[string]interface{}{"externalid": "foo"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid role ARN type",
+			params:  map[string]interface{}{"rolearn": 1},
+			wantErr: true,
+		},
+		{
+			name:    "invalid external ID type",
+			params:  map[string]interface{}{"rolearn": "arn:aws:iam::123456789012:role/registry", "externalid": 1},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range baseParams {
+				tt.params[k] = v
+			}
+
+			_, err := FromParameters(tt.params)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error with params %#v", tt.params)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unable to create a new S3 driver: %v", err)
+			}
+		})
+	}
+}
+
+func TestFromParameters_ChecksumOffload(t *testing.T) {
+	baseParams := map[string]interface{}{
+		"region": "us-west-2",
+		"bucket": "test",
+		"v4auth": "true",
+	}
+
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:   "default",
+			params: map[string]interface{}{},
+			want:   false,
+		},
+		{
+			name:   "bool",
+			params: map[string]interface{}{"checksumoffload": true},
+			want:   true,
+		},
+		{
+			name:   "string",
+			params: map[string]interface{}{"checksumoffload": "true"},
+			want:   true,
+		},
+		{
+			name:    "invalid string",
+			params:  map[string]interface{}{"checksumoffload": "foo"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid type",
+			params:  map[string]interface{}{"checksumoffload": 1},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range baseParams {
+				tt.params[k] = v
+			}
+
+			d, err := FromParameters(tt.params)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error with params %#v", tt.params)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unable to create a new S3 driver: %v", err)
+			}
+
+			got := d.baseEmbed.Base.StorageDriver.(*driver).ChecksumOffload
+			if got != tt.want {
+				t.Fatalf("expected ChecksumOffload to be %t, got %t", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFromParameters_HTTPClient(t *testing.T) {
+	baseParams := map[string]interface{}{
+		"region": "us-west-2",
+		"bucket": "test",
+		"v4auth": "true",
+	}
+
+	tests := []struct {
+		name                      string
+		params                    map[string]interface{}
+		wantResponseHeaderTimeout time.Duration
+		wantIdleConnTimeout       time.Duration
+		wantMaxIdleConnsPerHost   int
+		wantErr                   bool
+	}{
+		{
+			name:                    "default",
+			params:                  map[string]interface{}{},
+			wantIdleConnTimeout:     defaultIdleConnTimeout,
+			wantMaxIdleConnsPerHost: defaultMaxIdleConnsPerHost,
+		},
+		{
+			name: "custom",
+			params: map[string]interface{}{
+				"dialtimeout":           "5s",
+				"responseheadertimeout": "10s",
+				"idleconntimeout":       time.Minute,
+				"maxidleconnsperhost":   "50",
+			},
+			wantResponseHeaderTimeout: 10 * time.Second,
+			wantIdleConnTimeout:       time.Minute,
+			wantMaxIdleConnsPerHost:   50,
+		},
+		{
+			name:    "invalid duration",
+			params:  map[string]interface{}{"responseheadertimeout": "foo"},
+			wantErr: true,
+		},
+		{
+			name:    "negative duration",
+			params:  map[string]interface{}{"requesttimeout": "-1s"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid duration type",
+			params:  map[string]interface{}{"dialtimeout": 5},
+			wantErr: true,
+		},
+		{
+			name:    "zero connections",
+			params:  map[string]interface{}{"maxidleconnsperhost": 0},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range baseParams {
+				tt.params[k] = v
+			}
+
+			d, err := FromParameters(tt.params)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			client := d.baseEmbed.Base.StorageDriver.(*driver).S3.s3.(*s3.S3).Client.Config.HTTPClient
+			transport, ok := client.Transport.(*http.Transport)
+			require.True(t, ok)
+			require.Equal(t, tt.wantResponseHeaderTimeout, transport.ResponseHeaderTimeout)
+			require.Equal(t, tt.wantIdleConnTimeout, transport.IdleConnTimeout)
+			require.Equal(t, tt.wantMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+		})
+	}
+}
+
+// newHangingDriver returns a driver talking to a fake S3 endpoint that never responds until the test ends.
+func newHangingDriver(t *testing.T, params map[string]interface{}) *Driver {
+	t.Helper()
+
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(done) })
+
+	for k, v := range map[string]interface{}{
+		"region":         "us-west-2",
+		"bucket":         "test",
+		"regionendpoint": srv.URL,
+		"secure":         false,
+		"accesskey":      "key",
+		"secretkey":      "secret",
+		"maxretries":     0,
+	} {
+		params[k] = v
+	}
+
+	d, err := FromParameters(params)
+	require.NoError(t, err)
+
+	return d
+}
+
+func TestResponseHeaderTimeout(t *testing.T) {
+	d := newHangingDriver(t, map[string]interface{}{"responseheadertimeout": "100ms"})
+
+	start := time.Now()
+	_, err := d.Stat(context.Background(), "/foo")
+	require.Error(t, err)
+	require.WithinDuration(t, start, time.Now(), 5*time.Second)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	d := newHangingDriver(t, map[string]interface{}{"requesttimeout": "100ms"})
+
+	start := time.Now()
+	err := d.PutContent(context.Background(), "/foo", []byte("bar"))
+	require.Error(t, err)
+	require.WithinDuration(t, start, time.Now(), 5*time.Second)
+}
+
+func TestChecksum_Disabled(t *testing.T) {
+	d, err := FromParameters(map[string]interface{}{
+		"region": "us-west-2",
+		"bucket": "test",
+		"v4auth": "true",
+	})
+	if err != nil {
+		t.Fatalf("unable to create a new S3 driver: %v", err)
+	}
+
+	// no requests must be sent to S3 when checksum offloading is disabled
+	_, err = d.Checksum(context.Background(), "/foo")
+	if _, ok := err.(storagedriver.ErrUnsupportedMethod); !ok {
+		t.Fatalf("expected an ErrUnsupportedMethod error, got %v", err)
+	}
+}
+
+func TestParseChecksumSHA256(t *testing.T) {
+	tests := []struct {
+		name     string
+		checksum string
+		want     digest.Digest
+	}{
+		{
+			name:     "empty",
+			checksum: "",
+			want:     "",
+		},
+		{
+			name:     "full object",
+			checksum: "LCa0a2j/xo/5m0U8HTBBNBNCLXBkg7+g+YpeiGJm564=",
+			want:     digest.FromString("foo"),
+		},
+		{
+			name:     "composite",
+			checksum: "LCa0a2j/xo/5m0U8HTBBNBNCLXBkg7+g+YpeiGJm564=-2",
+			want:     "",
+		},
+		{
+			name:     "invalid encoding",
+			checksum: "foo!",
+			want:     "",
+		},
+		{
+			name:     "invalid length",
+			checksum: "Zm9v",
+			want:     "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseChecksumSHA256(tt.checksum); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestS3Path(t *testing.T) {
+	tests := []struct {
+		rootDirectory string
+		keyPrefix     string
+		path          string
+		want          string
+	}{
+		{"", "", "/", ""},
+		{"", "", "/docker/registry/v2", "docker/registry/v2"},
+		{"/", "", "/docker/registry/v2", "docker/registry/v2"},
+		{"/root/", "", "/docker/registry/v2", "root/docker/registry/v2"},
+		{"", "tenants/acme", "", "tenants/acme"},
+		{"", "tenants/acme", "/docker/registry/v2", "tenants/acme/docker/registry/v2"},
+		{"/", "tenants/acme", "/docker/registry/v2", "tenants/acme/docker/registry/v2"},
+		{"/root/", "tenants/acme", "", "tenants/acme/root"},
+		{"/root/", "tenants/acme", "/docker/registry/v2", "tenants/acme/root/docker/registry/v2"},
+	}
+
+	for _, tt := range tests {
+		d := &driver{RootDirectory: tt.rootDirectory, KeyPrefix: tt.keyPrefix}
+		if got := d.s3Path(tt.path); got != tt.want {
+			t.Errorf("s3Path(%q) with root %q and key prefix %q: expected %q, got %q", tt.path, tt.rootDirectory, tt.keyPrefix, tt.want, got)
+		}
+	}
+}
+
+func TestURLForKeyPrefix(t *testing.T) {
+	d, err := FromParameters(map[string]interface{}{
+		"region":         "us-east-1",
+		"bucket":         "registry",
+		"accesskey":      "key",
+		"secretkey":      "secret",
+		"regionendpoint": "http://minio.example.com:9000",
+		"keyprefix":      "tenants/{tenant}",
+		"tenant":         "acme",
+		"rootdirectory":  "/root",
+	})
+	if err != nil {
+		t.Fatalf("unable to create a new S3 driver: %v", err)
+	}
+
+	u, err := d.URLFor(context.Background(), "/docker/registry/v2/blobs/sha256/ab/abcd/data", nil)
+	if err != nil {
+		t.Fatalf("unexpected error generating URL: %v", err)
+	}
+
+	// regionendpoint defaults to path style addressing, so the bucket is part of the path
+	want := "http://minio.example.com:9000/registry/tenants/acme/root/docker/registry/v2/blobs/sha256/ab/abcd/data?"
+	if !strings.HasPrefix(u, want) {
+		t.Fatalf("expected URL to start with %q, got %q", want, u)
+	}
+}
+
+func TestTransferTo_SameLocation(t *testing.T) {
+	newDriver := func(keyPrefix, rootDirectory string) *Driver {
+		d, err := FromParameters(map[string]interface{}{
+			"region":         "us-east-1",
+			"bucket":         "registry",
+			"accesskey":      "key",
+			"secretkey":      "secret",
+			"regionendpoint": "http://127.0.0.1:1",
+			"maxretries":     0,
+			"keyprefix":      keyPrefix,
+			"rootdirectory":  rootDirectory,
+		})
+		if err != nil {
+			t.Fatalf("unable to create a new S3 driver: %v", err)
+		}
+		return d
+	}
+
+	tests := []struct {
+		name     string
+		src      *Driver
+		dest     *Driver
+		wantSame bool
+	}{
+		{"same root directory", newDriver("", "/root"), newDriver("", "/root/"), true},
+		{"same key prefix and root directory", newDriver("tenants/acme", "/root"), newDriver("tenants/acme", "root"), true},
+		{"different root directory", newDriver("", "/root"), newDriver("", "/other"), false},
+		{"different key prefix", newDriver("tenants/acme", "/root"), newDriver("tenants/other", "/root"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.src.TransferTo(context.Background(), tt.dest, "/foo", "/foo")
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			var partial storagedriver.PartialTransferError
+			if same := !errors.As(err, &partial); same != tt.wantSame {
+				t.Fatalf("expected same location to be %t, got error: %v", tt.wantSame, err)
+			}
+		})
+	}
+}
+
 func TestEmptyRootList(t *testing.T) {
 	if skipS3() != "" {
 		t.Skip(skipS3())
@@ -718,3 +1235,393 @@ func newTempDirDriver(t *testing.T) *Driver {
 
 	return d
 }
+
+func TestFromParameters_S3CompatibilityFallbacks(t *testing.T) {
+	params := map[string]interface{}{
+		"region":             "us-west-2",
+		"bucket":             "test",
+		"forcelistobjectsv1": "true",
+		"disablebulkdelete":  true,
+	}
+
+	d, err := FromParameters(params)
+	require.NoError(t, err)
+
+	w := d.baseEmbed.Base.StorageDriver.(*driver).S3
+	require.EqualValues(t, 1, w.listObjectsV1)
+	require.EqualValues(t, 1, w.noBulkDelete)
+
+	params["forcelistobjectsv1"] = "foo"
+	_, err = FromParameters(params)
+	require.EqualError(t, err, "1 error occurred:\n\t* the forcelistobjectsv1 parameter should be a boolean, foo invalid\n\n")
+}
+
+var errNotImplemented = awserr.NewRequestFailure(awserr.New("NotImplemented", "not implemented", nil), http.StatusNotImplemented, "")
+
+// mockListObjectsV1Only mocks an S3-compatible backend that does not implement ListObjectsV2. ListObjects (v1)
+// requests return up to MaxKeys keys after the marker and, like S3 does without a delimiter, no NextMarker.
+type mockListObjectsV1Only struct {
+	s3iface.S3API
+	keys    []string
+	v2Calls int
+}
+
+func (m *mockListObjectsV1Only) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+	m.v2Calls++
+	return nil, errNotImplemented
+}
+
+func (m *mockListObjectsV1Only) ListObjectsV2PagesWithContext(ctx aws.Context, input *s3.ListObjectsV2Input, f func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
+	m.v2Calls++
+	return errNotImplemented
+}
+
+func (m *mockListObjectsV1Only) ListObjectsWithContext(ctx aws.Context, input *s3.ListObjectsInput, opts ...request.Option) (*s3.ListObjectsOutput, error) {
+	out := &s3.ListObjectsOutput{Marker: input.Marker, IsTruncated: aws.Bool(false)}
+	for _, k := range m.keys {
+		if k <= aws.StringValue(input.Marker) {
+			continue
+		}
+		if int64(len(out.Contents)) == aws.Int64Value(input.MaxKeys) {
+			out.IsTruncated = aws.Bool(true)
+			break
+		}
+		out.Contents = append(out.Contents, &s3.Object{Key: aws.String(k)})
+	}
+
+	return out, nil
+}
+
+func objectKeys(objects []*s3.Object) []string {
+	kk := make([]string, 0, len(objects))
+	for _, o := range objects {
+		kk = append(kk, *o.Key)
+	}
+	return kk
+}
+
+func TestListObjectsV1Fallback(t *testing.T) {
+	m := &mockListObjectsV1Only{keys: []string{"a", "b", "c"}}
+	w := newS3Wrapper(m)
+
+	out, err := w.ListObjectsV2WithContext(context.Background(), &s3.ListObjectsV2Input{MaxKeys: aws.Int64(2)})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, objectKeys(out.Contents))
+	require.True(t, *out.IsTruncated)
+	require.Equal(t, "b", *out.NextContinuationToken)
+
+	out, err = w.ListObjectsV2WithContext(context.Background(), &s3.ListObjectsV2Input{MaxKeys: aws.Int64(2), ContinuationToken: out.NextContinuationToken})
+	require.NoError(t, err)
+	require.Equal(t, []string{"c"}, objectKeys(out.Contents))
+	require.False(t, *out.IsTruncated)
+
+	out, err = w.ListObjectsV2WithContext(context.Background(), &s3.ListObjectsV2Input{MaxKeys: aws.Int64(2), StartAfter: aws.String("a")})
+	require.NoError(t, err)
+	require.Equal(t, []string{"b", "c"}, objectKeys(out.Contents))
+
+	var all []string
+	err = w.ListObjectsV2PagesWithContext(context.Background(), &s3.ListObjectsV2Input{MaxKeys: aws.Int64(1)}, func(out *s3.ListObjectsV2Output, lastPage bool) bool {
+		all = append(all, objectKeys(out.Contents)...)
+		return true
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c"}, all)
+
+	// ListObjectsV2 is only attempted once
+	require.Equal(t, 1, m.v2Calls)
+}
+
+func TestListObjectsV1Forced(t *testing.T) {
+	m := &mockListObjectsV1Only{keys: []string{"a"}}
+	w := newS3Wrapper(m, withListObjectsV1())
+
+	out, err := w.ListObjectsV2WithContext(context.Background(), &s3.ListObjectsV2Input{MaxKeys: aws.Int64(2)})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a"}, objectKeys(out.Contents))
+	require.Zero(t, m.v2Calls)
+}
+
+// mockDeleteObjectNoBulk mocks an S3-compatible backend that does not implement DeleteObjects. Deleting the object with
+// key "denied" fails.
+type mockDeleteObjectNoBulk struct {
+	s3iface.S3API
+	bulkCalls int
+	deleted   []string
+}
+
+func (m *mockDeleteObjectNoBulk) DeleteObjectsWithContext(ctx aws.Context, input *s3.DeleteObjectsInput, opts ...request.Option) (*s3.DeleteObjectsOutput, error) {
+	m.bulkCalls++
+	return nil, errNotImplemented
+}
+
+func (m *mockDeleteObjectNoBulk) DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	if *input.Key == "denied" {
+		return nil, awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), http.StatusForbidden, "")
+	}
+	m.deleted = append(m.deleted, *input.Key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestDeleteObjectsFallback(t *testing.T) {
+	m := &mockDeleteObjectNoBulk{}
+	w := newS3Wrapper(m)
+
+	for i := 0; i < 2; i++ {
+		m.deleted = nil
+		out, err := w.DeleteObjectsWithContext(context.Background(), &s3.DeleteObjectsInput{
+			Bucket: aws.String("test"),
+			Delete: &s3.Delete{
+				Objects: []*s3.ObjectIdentifier{{Key: aws.String("a")}, {Key: aws.String("denied")}, {Key: aws.String("b")}},
+				Quiet:   aws.Bool(false),
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"a", "b"}, m.deleted)
+		require.Len(t, out.Deleted, 2)
+		require.Len(t, out.Errors, 1)
+		require.Equal(t, "denied", *out.Errors[0].Key)
+		require.Equal(t, "AccessDenied", *out.Errors[0].Code)
+	}
+
+	// DeleteObjects is only attempted once
+	require.Equal(t, 1, m.bulkCalls)
+}
+
+func TestFromParameters_ObjectLock(t *testing.T) {
+	baseParams := map[string]interface{}{
+		"region": "us-west-2",
+		"bucket": "test",
+	}
+
+	tests := []struct {
+		name          string
+		params        map[string]interface{}
+		wantMode      string
+		wantRetention time.Duration
+		wantLegalHold bool
+		wantErr       string
+	}{
+		{
+			name:   "default",
+			params: map[string]interface{}{},
+		},
+		{
+			name:          "retention",
+			params:        map[string]interface{}{"objectlockmode": "compliance", "objectlockretention": "720h"},
+			wantMode:      s3.ObjectLockModeCompliance,
+			wantRetention: 720 * time.Hour,
+		},
+		{
+			name:          "legal hold",
+			params:        map[string]interface{}{"objectlocklegalhold": true},
+			wantLegalHold: true,
+		},
+		{
+			name:    "invalid mode",
+			params:  map[string]interface{}{"objectlockmode": "foo", "objectlockretention": "1h"},
+			wantErr: "the objectlockmode parameter must be one of [GOVERNANCE COMPLIANCE], foo invalid",
+		},
+		{
+			name:    "mode without retention",
+			params:  map[string]interface{}{"objectlockmode": "GOVERNANCE"},
+			wantErr: "the objectlockmode and objectlockretention parameters must be set together",
+		},
+		{
+			name:    "retention without mode",
+			params:  map[string]interface{}{"objectlockretention": "1h"},
+			wantErr: "the objectlockmode and objectlockretention parameters must be set together",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range baseParams {
+				tt.params[k] = v
+			}
+
+			d, err := FromParameters(tt.params)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			dd := d.baseEmbed.Base.StorageDriver.(*driver)
+			require.Equal(t, tt.wantMode, dd.ObjectLockMode)
+			require.Equal(t, tt.wantRetention, dd.ObjectLockRetention)
+			require.Equal(t, tt.wantLegalHold, dd.ObjectLockLegalHold)
+		})
+	}
+}
+
+func TestGetObjectLock(t *testing.T) {
+	blobPath := "/docker/registry/v2/blobs/sha256/ab/abcd/data"
+
+	d := &driver{}
+	require.False(t, d.getObjectLock(blobPath).enabled())
+
+	d = &driver{ObjectLockMode: s3.ObjectLockModeGovernance, ObjectLockRetention: time.Hour, ObjectLockLegalHold: true}
+	before := time.Now()
+	l := d.getObjectLock(blobPath)
+	require.True(t, l.enabled())
+	require.Equal(t, s3.ObjectLockModeGovernance, aws.StringValue(l.mode))
+	require.WithinDuration(t, before.Add(time.Hour), aws.TimeValue(l.retainUntil), time.Minute)
+	require.Equal(t, s3.ObjectLockLegalHoldStatusOn, aws.StringValue(l.legalHold))
+
+	// uploads and links are not locked
+	require.False(t, d.getObjectLock("/docker/registry/v2/repositories/foo/_uploads/123/data").enabled())
+	require.False(t, d.getObjectLock("/docker/registry/v2/repositories/foo/_layers/sha256/abcd/link").enabled())
+}
+
+// mockObjectLock mocks a bucket with Object Lock enabled, where all objects are protected by a retention period.
+type mockObjectLock struct {
+	s3iface.S3API
+	putInput *s3.PutObjectInput
+}
+
+func (m *mockObjectLock) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	m.putInput = input
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (m *mockObjectLock) DeleteObjectsWithContext(ctx aws.Context, input *s3.DeleteObjectsInput, opts ...request.Option) (*s3.DeleteObjectsOutput, error) {
+	out := &s3.DeleteObjectsOutput{}
+	for _, o := range input.Delete.Objects {
+		out.Errors = append(out.Errors, &s3.Error{
+			Key:     o.Key,
+			Code:    aws.String("AccessDenied"),
+			Message: aws.String("Access Denied because object protected by object lock."),
+		})
+	}
+	return out, nil
+}
+
+func (m *mockObjectLock) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+	return &s3.ListObjectsV2Output{
+		Contents:    []*s3.Object{{Key: aws.String(*input.Prefix + "/data")}},
+		IsTruncated: aws.Bool(false),
+	}, nil
+}
+
+func TestPutContent_ObjectLock(t *testing.T) {
+	m := &mockObjectLock{}
+	d := &driver{
+		S3:                  newS3Wrapper(m),
+		Bucket:              "test",
+		ObjectLockMode:      s3.ObjectLockModeCompliance,
+		ObjectLockRetention: time.Hour,
+	}
+
+	content := []byte("foo")
+	require.NoError(t, d.PutContent(context.Background(), "/docker/registry/v2/blobs/sha256/ab/abcd/data", content))
+	require.Equal(t, s3.ObjectLockModeCompliance, aws.StringValue(m.putInput.ObjectLockMode))
+	require.NotNil(t, m.putInput.ObjectLockRetainUntilDate)
+	require.Nil(t, m.putInput.ObjectLockLegalHoldStatus)
+	// base64 encoded MD5 checksum of "foo"
+	require.Equal(t, "rL0Y20zC+Fzt72VPzMSk2A==", aws.StringValue(m.putInput.ContentMD5))
+
+	require.NoError(t, d.PutContent(context.Background(), "/docker/registry/v2/repositories/foo/_layers/sha256/abcd/link", content))
+	require.Nil(t, m.putInput.ObjectLockMode)
+	require.Nil(t, m.putInput.ContentMD5)
+}
+
+func TestDelete_ObjectLocked(t *testing.T) {
+	d := &driver{S3: newS3Wrapper(&mockObjectLock{}), Bucket: "test"}
+
+	p := "/docker/registry/v2/blobs/sha256/ab/abcd"
+	err := d.Delete(context.Background(), p)
+	require.Equal(t, storagedriver.ObjectLockedError{Path: p, DriverName: driverName}, err)
+}
+
+func TestDeleteFiles_ObjectLocked(t *testing.T) {
+	d := &driver{S3: newS3Wrapper(&mockObjectLock{}), Bucket: "test"}
+
+	p := "/docker/registry/v2/blobs/sha256/ab/abcd/data"
+	count, err := d.DeleteFiles(context.Background(), []string{p})
+	require.Zero(t, count)
+
+	var errs *multierror.Error
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs.Errors, 1)
+	require.Equal(t, storagedriver.ObjectLockedError{Path: p, DriverName: driverName}, errs.Errors[0])
+}
+
+func TestParseError_ObjectLocked(t *testing.T) {
+	err := awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied because object protected by object lock.", nil), http.StatusForbidden, "")
+	require.Equal(t, storagedriver.ObjectLockedError{Path: "/a", DriverName: driverName}, parseError("/a", err))
+
+	err = awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), http.StatusForbidden, "")
+	require.Equal(t, err, parseError("/a", err))
+}
+
+func TestFromParameters_MaxWalkConcurrency(t *testing.T) {
+	params := map[string]interface{}{
+		"region": "us-west-2",
+		"bucket": "test",
+	}
+
+	d, err := FromParameters(params)
+	require.NoError(t, err)
+	require.EqualValues(t, defaultMaxWalkConcurrency, d.baseEmbed.Base.StorageDriver.(*driver).MaxWalkConcurrency)
+
+	params["maxwalkconcurrency"] = 5
+	d, err = FromParameters(params)
+	require.NoError(t, err)
+	require.EqualValues(t, 5, d.baseEmbed.Base.StorageDriver.(*driver).MaxWalkConcurrency)
+
+	params["maxwalkconcurrency"] = 0
+	_, err = FromParameters(params)
+	require.Error(t, err)
+}
+
+// mockListObjectsTree mocks a bucket listing, in which each prefix contains the given directories and files.
+type mockListObjectsTree struct {
+	s3iface.S3API
+	dirs  map[string][]string
+	files map[string][]string
+}
+
+func (m *mockListObjectsTree) ListObjectsV2PagesWithContext(ctx aws.Context, input *s3.ListObjectsV2Input, f func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
+	prefix := aws.StringValue(input.Prefix)
+	out := &s3.ListObjectsV2Output{}
+	for _, d := range m.dirs[prefix] {
+		out.CommonPrefixes = append(out.CommonPrefixes, &s3.CommonPrefix{Prefix: aws.String(prefix + d + "/")})
+	}
+	for _, k := range m.files[prefix] {
+		out.Contents = append(out.Contents, &s3.Object{Key: aws.String(prefix + k), Size: aws.Int64(0), LastModified: aws.Time(time.Now())})
+	}
+	f(out, true)
+
+	return nil
+}
+
+func TestWalkParallel_MaxWalkConcurrency(t *testing.T) {
+	m := &mockListObjectsTree{
+		dirs: map[string][]string{"": {"a", "b", "c"}},
+		files: map[string][]string{
+			"a/": {"1", "2", "3", "4", "5"},
+			"b/": {"1", "2", "3", "4", "5"},
+			"c/": {"1", "2", "3", "4", "5"},
+		},
+	}
+	d := &driver{S3: newS3Wrapper(m), Bucket: "test", ParallelWalk: true, MaxWalkConcurrency: 2}
+
+	var running, maxRunning, visited int64
+	err := d.WalkParallel(context.Background(), "/", func(fi storagedriver.FileInfo) error {
+		n := atomic.AddInt64(&running, 1)
+		defer atomic.AddInt64(&running, -1)
+		for {
+			max := atomic.LoadInt64(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt64(&maxRunning, max, n) {
+				break
+			}
+		}
+		atomic.AddInt64(&visited, 1)
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	require.NoError(t, err)
+	require.EqualValues(t, 18, visited)
+	require.LessOrEqual(t, maxRunning, int64(2))
+}
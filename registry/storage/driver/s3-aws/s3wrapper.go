@@ -3,6 +3,7 @@ package s3
 import (
 	"errors"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -11,6 +12,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/cenkalti/backoff/v4"
+	log "github.com/sirupsen/logrus"
 	"golang.org/x/time/rate"
 )
 
@@ -57,6 +59,10 @@ type s3wrapper struct {
 	*rate.Limiter
 	backoff backoffConstructor
 	notify  backoff.Notify
+	// listObjectsV1 and noBulkDelete are set to 1, either through configuration or once the backend responds that the
+	// corresponding API operation is not implemented, as it's the case for some S3-compatible storage backends.
+	listObjectsV1 int32
+	noBulkDelete  int32
 }
 
 type wrapperOpt func(*s3wrapper)
@@ -73,6 +79,20 @@ func withBackoffNotify(n backoff.Notify) wrapperOpt {
 	}
 }
 
+// withListObjectsV1 makes the wrapper serve ListObjectsV2 calls with ListObjects (v1) requests.
+func withListObjectsV1() wrapperOpt {
+	return func(w *s3wrapper) {
+		w.listObjectsV1 = 1
+	}
+}
+
+// withoutBulkDelete makes the wrapper serve DeleteObjects calls with a DeleteObject request per object.
+func withoutBulkDelete() wrapperOpt {
+	return func(w *s3wrapper) {
+		w.noBulkDelete = 1
+	}
+}
+
 func newS3Wrapper(s3 s3iface.S3API, opts ...wrapperOpt) *s3wrapper {
 	w := &s3wrapper{
 		s3:      s3,
@@ -148,6 +168,10 @@ func (w *s3wrapper) ListPartsWithContext(ctx aws.Context, input *s3.ListPartsInp
 }
 
 func (w *s3wrapper) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+	if atomic.LoadInt32(&w.listObjectsV1) == 1 {
+		return w.listObjectsV1WithContext(ctx, input, opts...)
+	}
+
 	var out *s3.ListObjectsV2Output
 
 	err := w.waitRetryNotify(ctx, func() error {
@@ -155,10 +179,86 @@ func (w *s3wrapper) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObje
 		out, err = w.s3.ListObjectsV2WithContext(ctx, input, opts...)
 		return err
 	})
+	if isNotImplemented(err) {
+		w.fallbackToListObjectsV1(err)
+		return w.listObjectsV1WithContext(ctx, input, opts...)
+	}
 
 	return out, err
 }
 
+func (w *s3wrapper) fallbackToListObjectsV1(err error) {
+	if atomic.CompareAndSwapInt32(&w.listObjectsV1, 0, 1) {
+		log.WithError(err).Warn("S3: ListObjectsV2 is not implemented by the storage backend, falling back to ListObjects")
+	}
+}
+
+// listObjectsV1WithContext serves a ListObjectsV2 call with a ListObjects (v1) request. Continuation tokens are mapped
+// to markers, which are the key after which listing begins.
+func (w *s3wrapper) listObjectsV1WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+	in := &s3.ListObjectsInput{
+		Bucket:       input.Bucket,
+		Delimiter:    input.Delimiter,
+		EncodingType: input.EncodingType,
+		MaxKeys:      input.MaxKeys,
+		Prefix:       input.Prefix,
+		RequestPayer: input.RequestPayer,
+		Marker:       input.StartAfter,
+	}
+	if input.ContinuationToken != nil {
+		in.Marker = input.ContinuationToken
+	}
+
+	var out *s3.ListObjectsOutput
+
+	err := w.waitRetryNotify(ctx, func() error {
+		var err error
+		out, err = w.s3.ListObjectsWithContext(ctx, in, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toListObjectsV2Output(out), nil
+}
+
+// toListObjectsV2Output converts the output of a ListObjects (v1) request to that of a ListObjectsV2 request.
+func toListObjectsV2Output(out *s3.ListObjectsOutput) *s3.ListObjectsV2Output {
+	o := &s3.ListObjectsV2Output{
+		CommonPrefixes: out.CommonPrefixes,
+		Contents:       out.Contents,
+		Delimiter:      out.Delimiter,
+		EncodingType:   out.EncodingType,
+		IsTruncated:    aws.Bool(aws.BoolValue(out.IsTruncated)),
+		KeyCount:       aws.Int64(int64(len(out.Contents) + len(out.CommonPrefixes))),
+		MaxKeys:        out.MaxKeys,
+		Name:           out.Name,
+		Prefix:         out.Prefix,
+		StartAfter:     out.Marker,
+	}
+
+	if *o.IsTruncated {
+		// NextMarker is only returned when using a delimiter, otherwise the last key should be used as marker
+		next := aws.StringValue(out.NextMarker)
+		if next == "" {
+			for _, c := range out.Contents {
+				if k := aws.StringValue(c.Key); k > next {
+					next = k
+				}
+			}
+			for _, p := range out.CommonPrefixes {
+				if k := aws.StringValue(p.Prefix); k > next {
+					next = k
+				}
+			}
+		}
+		o.NextContinuationToken = aws.String(next)
+	}
+
+	return o
+}
+
 func (w *s3wrapper) CopyObjectWithContext(ctx aws.Context, input *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
 	var out *s3.CopyObjectOutput
 
@@ -196,6 +296,10 @@ func (w *s3wrapper) CompleteMultipartUploadWithContext(ctx aws.Context, input *s
 }
 
 func (w *s3wrapper) DeleteObjectsWithContext(ctx aws.Context, input *s3.DeleteObjectsInput, opts ...request.Option) (*s3.DeleteObjectsOutput, error) {
+	if atomic.LoadInt32(&w.noBulkDelete) == 1 {
+		return w.deleteObjectsOneByOne(ctx, input, opts...)
+	}
+
 	var out *s3.DeleteObjectsOutput
 
 	err := w.waitRetryNotify(ctx, func() error {
@@ -203,6 +307,65 @@ func (w *s3wrapper) DeleteObjectsWithContext(ctx aws.Context, input *s3.DeleteOb
 		out, err = w.s3.DeleteObjectsWithContext(ctx, input, opts...)
 		return err
 	})
+	if isNotImplemented(err) {
+		if atomic.CompareAndSwapInt32(&w.noBulkDelete, 0, 1) {
+			log.WithError(err).Warn("S3: DeleteObjects is not implemented by the storage backend, falling back to DeleteObject")
+		}
+		return w.deleteObjectsOneByOne(ctx, input, opts...)
+	}
+
+	return out, err
+}
+
+// deleteObjectsOneByOne serves a DeleteObjects call with a DeleteObject request per object. Failures to delete
+// individual objects are reported in the output, like S3 does for DeleteObjects requests.
+func (w *s3wrapper) deleteObjectsOneByOne(ctx aws.Context, input *s3.DeleteObjectsInput, opts ...request.Option) (*s3.DeleteObjectsOutput, error) {
+	out := &s3.DeleteObjectsOutput{}
+
+	for _, obj := range input.Delete.Objects {
+		err := w.waitRetryNotify(ctx, func() error {
+			_, err := w.s3.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+				Bucket:    input.Bucket,
+				Key:       obj.Key,
+				VersionId: obj.VersionId,
+			}, opts...)
+			return err
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+
+			code := "InternalError"
+			var awsErr awserr.Error
+			if errors.As(err, &awsErr) {
+				code = awsErr.Code()
+			}
+			out.Errors = append(out.Errors, &s3.Error{
+				Key:       obj.Key,
+				VersionId: obj.VersionId,
+				Code:      aws.String(code),
+				Message:   aws.String(err.Error()),
+			})
+			continue
+		}
+
+		if !aws.BoolValue(input.Delete.Quiet) {
+			out.Deleted = append(out.Deleted, &s3.DeletedObject{Key: obj.Key, VersionId: obj.VersionId})
+		}
+	}
+
+	return out, nil
+}
+
+func (w *s3wrapper) HeadObjectWithContext(ctx aws.Context, input *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	var out *s3.HeadObjectOutput
+
+	err := w.waitRetryNotify(ctx, func() error {
+		var err error
+		out, err = w.s3.HeadObjectWithContext(ctx, input, opts...)
+		return err
+	})
 
 	return out, err
 }
@@ -218,9 +381,36 @@ func (w *s3wrapper) HeadObjectRequest(input *s3.HeadObjectInput) (*request.Reque
 }
 
 func (w *s3wrapper) ListObjectsV2PagesWithContext(ctx aws.Context, input *s3.ListObjectsV2Input, f func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
-	return w.waitRetryNotify(ctx, func() error {
+	if atomic.LoadInt32(&w.listObjectsV1) == 1 {
+		return w.listObjectsV1PagesWithContext(ctx, input, f, opts...)
+	}
+
+	err := w.waitRetryNotify(ctx, func() error {
 		return w.s3.ListObjectsV2PagesWithContext(ctx, input, f, opts...)
 	})
+	if isNotImplemented(err) {
+		w.fallbackToListObjectsV1(err)
+		return w.listObjectsV1PagesWithContext(ctx, input, f, opts...)
+	}
+
+	return err
+}
+
+// listObjectsV1PagesWithContext iterates over the pages of a ListObjectsV2 call served with ListObjects (v1) requests.
+func (w *s3wrapper) listObjectsV1PagesWithContext(ctx aws.Context, input *s3.ListObjectsV2Input, f func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
+	in := *input
+	for {
+		out, err := w.listObjectsV1WithContext(ctx, &in, opts...)
+		if err != nil {
+			return err
+		}
+
+		lastPage := !*out.IsTruncated
+		if !f(out, lastPage) || lastPage {
+			return nil
+		}
+		in.ContinuationToken = out.NextContinuationToken
+	}
 }
 
 func (w *s3wrapper) AbortMultipartUploadWithContext(ctx aws.Context, input *s3.AbortMultipartUploadInput, opts ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
@@ -270,12 +460,15 @@ func wrapAWSerr(e error) error {
 		return nil
 	}
 
-	// Retry any request failures that are server errors.
+	// Retry any request failures that are server errors, except for operations that are not implemented.
 	var reqErr awserr.RequestFailure
 	if errors.As(e, &reqErr) {
 		if reqErr.StatusCode() != http.StatusTooManyRequests && reqErr.StatusCode() < http.StatusInternalServerError {
 			return backoff.Permanent(e)
 		}
+		if reqErr.StatusCode() == http.StatusNotImplemented {
+			return backoff.Permanent(e)
+		}
 
 		return e
 	}
@@ -293,3 +486,15 @@ func wrapAWSerr(e error) error {
 
 	return e
 }
+
+// isNotImplemented reports whether e signals that the requested S3 API operation is not implemented by the storage
+// backend.
+func isNotImplemented(e error) bool {
+	var reqErr awserr.RequestFailure
+	if errors.As(e, &reqErr) && reqErr.StatusCode() == http.StatusNotImplemented {
+		return true
+	}
+
+	var awsErr awserr.Error
+	return errors.As(e, &awsErr) && awsErr.Code() == "NotImplemented"
+}
@@ -9,6 +9,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/opencontainers/go-digest"
 )
 
 // Version is a string representing the storage driver version, of the form
@@ -119,6 +121,26 @@ type StorageDeleter interface {
 	DeleteFiles(ctx context.Context, paths []string) (int, error)
 }
 
+// Checksummer is an optional interface implemented by storage drivers whose backend is able to compute and verify
+// content checksums server side. This allows the registry to verify stored content without reading it back from the
+// storage backend.
+type Checksummer interface {
+	// Checksum returns the SHA-256 digest of the content stored at path, as computed by the storage backend. Returns
+	// ErrUnsupportedMethod if the storage driver does not support backend checksums, or ChecksumUnavailableError if
+	// the backend is unable to provide a checksum for this specific path.
+	Checksum(ctx context.Context, path string) (digest.Digest, error)
+}
+
+// Concatenator is an optional interface implemented by storage drivers whose backend is able to assemble objects
+// server side. This allows the registry to join content uploaded in several parts without transferring it through
+// the registry.
+type Concatenator interface {
+	// Concat appends the content stored at sourcePaths, in order, to the content stored at path, creating it if it
+	// does not exist. The content at sourcePaths is left untouched. Returns ErrUnsupportedMethod if the storage
+	// driver does not support concatenation, or if the backend is unable to concatenate these specific paths.
+	Concat(ctx context.Context, path string, sourcePaths []string) error
+}
+
 // FileWriter provides an abstraction for an opened writable file-like object in
 // the storage backend. The FileWriter must flush all content written to it on
 // the call to Close, but is only required to make its content readable on a
@@ -186,6 +208,29 @@ func (err InvalidOffsetError) Error() string {
 	return fmt.Sprintf("%s: invalid offset: %d for path: %s", err.DriverName, err.Offset, err.Path)
 }
 
+// ChecksumUnavailableError is returned when the storage backend is unable to
+// provide a checksum for the content stored at a given path.
+type ChecksumUnavailableError struct {
+	Path       string
+	DriverName string
+}
+
+func (err ChecksumUnavailableError) Error() string {
+	return fmt.Sprintf("%s: checksum unavailable for path: %s", err.DriverName, err.Path)
+}
+
+// ObjectLockedError is returned when the content stored at a given path can
+// not be deleted or overwritten because it is protected by a retention period
+// or legal hold (WORM storage).
+type ObjectLockedError struct {
+	Path       string
+	DriverName string
+}
+
+func (err ObjectLockedError) Error() string {
+	return fmt.Sprintf("%s: object is locked by retention policy or legal hold: %s", err.DriverName, err.Path)
+}
+
 // Error is a catch-all error type which captures an error string and
 // the driver type on which it occurred.
 type Error struct {
@@ -81,6 +81,12 @@ type Parameters struct {
 	AccessKey           string
 	TempURLContainerKey bool
 	TempURLMethods      []string
+	// ApplicationCredentialID, ApplicationCredentialName and ApplicationCredentialSecret configure authentication with a
+	// Keystone v3 application credential, used instead of Username and Password. When identifying the credential by
+	// name, Username (and Domain or DomainID) must be set to the credential owner.
+	ApplicationCredentialID     string
+	ApplicationCredentialName   string
+	ApplicationCredentialSecret string
 }
 
 // swiftInfo maps the JSON structure returned by Swift /info endpoint
@@ -132,8 +138,8 @@ type Driver struct {
 
 // FromParameters constructs a new Driver with a given parameters map
 // Required parameters:
-// - username
-// - password
+// - username and password, or applicationcredentialid (or applicationcredentialname and username) and
+// applicationcredentialsecret
 // - authurl
 // - container
 func FromParameters(parameters map[string]interface{}) (*Driver, error) {
@@ -159,12 +165,25 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 		return nil, err
 	}
 
-	if params.Username == "" {
-		return nil, fmt.Errorf("no username parameter provided")
-	}
+	if params.ApplicationCredentialSecret != "" {
+		if params.ApplicationCredentialID == "" && params.ApplicationCredentialName == "" {
+			return nil, fmt.Errorf("no applicationcredentialid or applicationcredentialname parameter provided")
+		}
+		if params.ApplicationCredentialID == "" && params.Username == "" {
+			return nil, fmt.Errorf("no username parameter provided, required with applicationcredentialname")
+		}
+	} else {
+		if params.ApplicationCredentialID != "" || params.ApplicationCredentialName != "" {
+			return nil, fmt.Errorf("no applicationcredentialsecret parameter provided")
+		}
 
-	if params.Password == "" {
-		return nil, fmt.Errorf("no password parameter provided")
+		if params.Username == "" {
+			return nil, fmt.Errorf("no username parameter provided")
+		}
+
+		if params.Password == "" {
+			return nil, fmt.Errorf("no password parameter provided")
+		}
 	}
 
 	if params.AuthURL == "" {
@@ -208,6 +227,10 @@ func New(params Parameters) (*Driver, error) {
 		Transport:      transport,
 		ConnectTimeout: 60 * time.Second,
 		Timeout:        15 * 60 * time.Second,
+
+		ApplicationCredentialId:     params.ApplicationCredentialID,
+		ApplicationCredentialName:   params.ApplicationCredentialName,
+		ApplicationCredentialSecret: params.ApplicationCredentialSecret,
 	}
 	err := ct.Authenticate()
 	if err != nil {
@@ -24,25 +24,28 @@ var swiftDriverConstructor func(prefix string) (*Driver, error)
 
 func init() {
 	var (
-		username           string
-		password           string
-		authURL            string
-		tenant             string
-		tenantID           string
-		domain             string
-		domainID           string
-		tenantDomain       string
-		tenantDomainID     string
-		trustID            string
-		container          string
-		region             string
-		AuthVersion        int
-		endpointType       string
-		insecureSkipVerify bool
-		secretKey          string
-		accessKey          string
-		containerKey       bool
-		tempURLMethods     []string
+		username            string
+		password            string
+		authURL             string
+		tenant              string
+		tenantID            string
+		domain              string
+		domainID            string
+		tenantDomain        string
+		tenantDomainID      string
+		trustID             string
+		container           string
+		region              string
+		AuthVersion         int
+		endpointType        string
+		insecureSkipVerify  bool
+		secretKey           string
+		accessKey           string
+		containerKey        bool
+		tempURLMethods      []string
+		appCredentialID     string
+		appCredentialName   string
+		appCredentialSecret string
 
 		swiftServer *swifttest.SwiftServer
 		err         error
@@ -66,8 +69,11 @@ func init() {
 	accessKey = os.Getenv("SWIFT_ACCESS_KEY")
 	containerKey, _ = strconv.ParseBool(os.Getenv("SWIFT_TEMPURL_CONTAINERKEY"))
 	tempURLMethods = strings.Split(os.Getenv("SWIFT_TEMPURL_METHODS"), ",")
+	appCredentialID = os.Getenv("SWIFT_APPLICATION_CREDENTIAL_ID")
+	appCredentialName = os.Getenv("SWIFT_APPLICATION_CREDENTIAL_NAME")
+	appCredentialSecret = os.Getenv("SWIFT_APPLICATION_CREDENTIAL_SECRET")
 
-	if username == "" || password == "" || authURL == "" || container == "" {
+	if ((username == "" || password == "") && appCredentialSecret == "") || authURL == "" || container == "" {
 		if swiftServer, err = swifttest.NewSwiftServer("localhost"); err != nil {
 			panic(err)
 		}
@@ -106,6 +112,9 @@ func init() {
 			accessKey,
 			containerKey,
 			tempURLMethods,
+			appCredentialID,
+			appCredentialName,
+			appCredentialSecret,
 		}
 
 		return New(parameters)
@@ -279,3 +288,56 @@ func TestSwiftSegmentPath(t *testing.T) {
 		t.Fatalf("expected segment paths to differ, %s == %s", s1, s2)
 	}
 }
+
+func TestFromParametersCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:    "no username",
+			params:  map[string]interface{}{"password": "foo"},
+			wantErr: "no username parameter provided",
+		},
+		{
+			name:    "no password",
+			params:  map[string]interface{}{"username": "foo"},
+			wantErr: "no password parameter provided",
+		},
+		{
+			name:    "application credential without secret",
+			params:  map[string]interface{}{"applicationcredentialid": "foo"},
+			wantErr: "no applicationcredentialsecret parameter provided",
+		},
+		{
+			name:    "application credential secret without id or name",
+			params:  map[string]interface{}{"applicationcredentialsecret": "foo"},
+			wantErr: "no applicationcredentialid or applicationcredentialname parameter provided",
+		},
+		{
+			name:    "application credential name without username",
+			params:  map[string]interface{}{"applicationcredentialname": "foo", "applicationcredentialsecret": "bar"},
+			wantErr: "no username parameter provided, required with applicationcredentialname",
+		},
+		{
+			name:    "application credential id",
+			params:  map[string]interface{}{"applicationcredentialid": "foo", "applicationcredentialsecret": "bar"},
+			wantErr: "no authurl parameter provided",
+		},
+		{
+			name:    "application credential name",
+			params:  map[string]interface{}{"applicationcredentialname": "foo", "applicationcredentialsecret": "bar", "username": "baz"},
+			wantErr: "no authurl parameter provided",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := FromParameters(test.params)
+			if err == nil || err.Error() != test.wantErr {
+				t.Fatalf("expected error %q, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
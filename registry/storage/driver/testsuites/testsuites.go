@@ -1713,6 +1713,41 @@ func (suite *DriverSuite) TestRemoveBlobs(c *check.C) {
 	}
 }
 
+// TestRemoveBlobsInBatches checks that storage.Vacuum is able to delete a set of blobs in concurrent batches.
+func (suite *DriverSuite) TestRemoveBlobsInBatches(c *check.C) {
+	defer suite.deletePath(c, firstPart("docker/"))
+
+	registry := suite.createRegistry(c)
+	repo := suite.makeRepository(c, registry, randomFilename(5))
+	v := storage.NewVacuum(suite.StorageDriver)
+
+	// build some blobs and remove most of them, otherwise there will be no /docker/registry/v2/blobs path to look at
+	// for validation if there are no blobs left
+	blobs := suite.buildBlobs(c, repo, 8)
+	blobs = blobs[:6]
+
+	err := v.RemoveBlobsInBatches(suite.ctx, blobs, 2, 2)
+	c.Assert(err, check.IsNil)
+
+	// assert that blobs were deleted
+	blobService := registry.Blobs()
+	blobsLeft := newSyncDigestSet()
+	err = blobService.Enumerate(suite.ctx, func(desc distribution.Descriptor) error {
+		blobsLeft.add(desc.Digest)
+		return nil
+	})
+	if err != nil {
+		c.Fatalf("error getting all blobs: %v", err)
+	}
+
+	c.Assert(blobsLeft.len(), check.Equals, 2)
+	for _, b := range blobs {
+		if blobsLeft.contains(b) {
+			c.Errorf("blob %q was not deleted", b.String())
+		}
+	}
+}
+
 func (suite *DriverSuite) benchmarkRemoveBlobs(c *check.C, numBlobs int) {
 	defer suite.deletePath(c, firstPart("docker/"))
 
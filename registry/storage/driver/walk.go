@@ -104,6 +104,14 @@ func WalkFallbackParallel(ctx context.Context, driver StorageDriver, maxConcurre
 	close(errors)
 	<-errDone
 
+	// Errors are appended in the order in which goroutines report them, sort
+	// them so that the aggregated error is deterministic.
+	if merr, ok := retError.(*multierror.Error); ok {
+		sort.SliceStable(merr.Errors, func(i, j int) bool {
+			return merr.Errors[i].Error() < merr.Errors[j].Error()
+		})
+	}
+
 	return retError
 }
 
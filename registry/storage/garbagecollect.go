@@ -2,7 +2,9 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
@@ -18,11 +20,36 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+// defaultBlobDeleteBatchSize is the default maximum number of blobs deleted with a single driver.DeleteFiles request
+// during the sweep stage. This matches the maximum number of objects that can be deleted per request on S3.
+const defaultBlobDeleteBatchSize = 1000
+
 // GCOpts contains options for garbage collector
 type GCOpts struct {
 	DryRun                  bool
 	RemoveUntagged          bool
 	MaxParallelManifestGets int
+	// MaxParallelRepositoryMarks is the maximum number of repositories marked concurrently during the mark stage. Up to
+	// MaxParallelManifestGets manifests are retrieved concurrently for each repository being marked. Defaults to 1.
+	MaxParallelRepositoryMarks int
+	// MaxParallelBlobDeletes is the maximum number of blob delete batches processed concurrently during the sweep stage.
+	// Defaults to 1.
+	MaxParallelBlobDeletes int
+	// BlobDeleteBatchSize is the maximum number of blobs deleted per batch during the sweep stage. Defaults to
+	// defaultBlobDeleteBatchSize.
+	BlobDeleteBatchSize int
+	// EventListener, if set, is notified of every manifest and blob deleted during the sweep stage.
+	EventListener GCEventListener
+	// Report, if set, receives a JSON encoded GCReport of all blobs and manifests eligible for deletion once the mark
+	// stage completes. The report is written regardless of DryRun.
+	Report io.Writer
+}
+
+// GCEventListener is notified of artifacts removed by the garbage collector. This mirrors notifications.GCListener, which
+// can't be referenced here without creating an import cycle.
+type GCEventListener interface {
+	BlobDeleted(dgst digest.Digest) error
+	ManifestDeleted(repo string, dgst digest.Digest) error
 }
 
 // ManifestDel contains manifest structure which will be deleted
@@ -81,11 +108,82 @@ func (s *syncDigestSet) len() int {
 	return len(s.members)
 }
 
+// errStopMarking is returned to the repository enumerator to stop the mark stage once a repository failed to be marked.
+var errStopMarking = errors.New("stop marking")
+
+// markRepositories applies mark to every repository of enumerator, with up to maxParallel repositories being marked
+// concurrently. Once a repository fails to be marked no further repositories are dispatched, but those already being
+// marked are allowed to complete, so that all errors encountered are returned together.
+func markRepositories(ctx context.Context, enumerator distribution.RepositoryEnumerator, maxParallel int, mark func(repoName string) error) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs *multierror.Error
+	)
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return errs.ErrorOrNil() != nil
+	}
+
+	semaphore := make(chan struct{}, maxParallel)
+
+	err := enumerator.Enumerate(ctx, func(repoName string) error {
+		semaphore <- struct{}{}
+		if failed() {
+			<-semaphore
+			return errStopMarking
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			if err := mark(repoName); err != nil {
+				mu.Lock()
+				errs = multierror.Append(errs, fmt.Errorf("repository %s: %w", repoName, err))
+				mu.Unlock()
+			}
+		}()
+
+		return nil
+	})
+
+	wg.Wait()
+
+	// parallel walks aggregate the errors returned by each walker, so errStopMarking may be returned multiple times
+	var walkErrs []error
+	if merr, ok := err.(*multierror.Error); ok {
+		walkErrs = merr.Errors
+	} else if err != nil {
+		walkErrs = []error{err}
+	}
+	for _, err := range walkErrs {
+		if !errors.Is(err, errStopMarking) {
+			errs = multierror.Append(errs, err)
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
 // MarkAndSweep performs a mark and sweep of registry data
 func MarkAndSweep(ctx context.Context, storageDriver driver.StorageDriver, registry distribution.Namespace, opts GCOpts) error {
 	if opts.MaxParallelManifestGets < 1 {
 		opts.MaxParallelManifestGets = 1
 	}
+	if opts.MaxParallelRepositoryMarks < 1 {
+		opts.MaxParallelRepositoryMarks = 1
+	}
+	if opts.MaxParallelBlobDeletes < 1 {
+		opts.MaxParallelBlobDeletes = 1
+	}
+	if opts.BlobDeleteBatchSize < 1 {
+		opts.BlobDeleteBatchSize = defaultBlobDeleteBatchSize
+	}
 
 	repositoryEnumerator, ok := registry.(distribution.RepositoryEnumerator)
 	if !ok {
@@ -99,7 +197,7 @@ func MarkAndSweep(ctx context.Context, storageDriver driver.StorageDriver, regis
 	markSet := newSyncDigestSet()
 	manifestArr := syncManifestDelContainer{sync.Mutex{}, make([]ManifestDel, 0)}
 
-	err := repositoryEnumerator.Enumerate(ctx, func(repoName string) error {
+	markRepository := func(repoName string) error {
 		dcontext.GetLoggerWithField(ctx, "repo", repoName).Info("marking repository")
 
 		taggedManifests := newSyncDigestSet()
@@ -322,8 +420,9 @@ func MarkAndSweep(ctx context.Context, storageDriver driver.StorageDriver, regis
 		}
 
 		return nil
-	})
+	}
 
+	err := markRepositories(ctx, repositoryEnumerator, opts.MaxParallelRepositoryMarks, markRepository)
 	if err != nil {
 		return fmt.Errorf("marking blobs: %w", err)
 	}
@@ -343,7 +442,13 @@ func MarkAndSweep(ctx context.Context, storageDriver driver.StorageDriver, regis
 		sizeDone <- struct{}{}
 	}()
 
+	// blob sizes are only retained if a report was requested, as there may be many of them
+	sizes := &syncSizeMap{sizes: make(map[digest.Digest]int64)}
+
 	err = blobService.Enumerate(ctx, func(desc distribution.Descriptor) error {
+		if opts.Report != nil {
+			sizes.set(desc.Digest, desc.Size)
+		}
 		// check if digest is in markSet. If not, delete it!
 		if !markSet.contains(desc.Digest) {
 			dcontext.GetLoggerWithFields(ctx, map[interface{}]interface{}{
@@ -371,6 +476,13 @@ func MarkAndSweep(ctx context.Context, storageDriver driver.StorageDriver, regis
 		"duration_s":                 time.Since(markStart).Seconds(),
 	}).Info("mark stage complete")
 
+	if opts.Report != nil {
+		report := newGCReport(opts.DryRun, &deleteSet, manifestArr.manifestDels, sizes)
+		if err := report.write(opts.Report); err != nil {
+			return fmt.Errorf("writing report: %w", err)
+		}
+	}
+
 	// sweep
 	if opts.DryRun {
 		return nil
@@ -384,6 +496,13 @@ func MarkAndSweep(ctx context.Context, storageDriver driver.StorageDriver, regis
 		if err := vacuum.RemoveManifests(ctx, manifestArr.manifestDels); err != nil {
 			return fmt.Errorf("deleting manifests: %w", err)
 		}
+		if opts.EventListener != nil {
+			for _, m := range manifestArr.manifestDels {
+				if err := opts.EventListener.ManifestDeleted(m.Name, m.Digest); err != nil {
+					dcontext.GetLogger(ctx).WithError(err).Error("failed to notify manifest deletion")
+				}
+			}
+		}
 	}
 
 	// Lock and unlock manually and access members directly to reduce lock operations.
@@ -395,9 +514,16 @@ func MarkAndSweep(ctx context.Context, storageDriver driver.StorageDriver, regis
 		dgsts = append(dgsts, dgst)
 	}
 	if len(dgsts) > 0 {
-		if err := vacuum.RemoveBlobs(ctx, dgsts); err != nil {
+		if err := vacuum.RemoveBlobsInBatches(ctx, dgsts, opts.BlobDeleteBatchSize, opts.MaxParallelBlobDeletes); err != nil {
 			return fmt.Errorf("deleting blobs: %w", err)
 		}
+		if opts.EventListener != nil {
+			for _, dgst := range dgsts {
+				if err := opts.EventListener.BlobDeleted(dgst); err != nil {
+					dcontext.GetLogger(ctx).WithError(err).Error("failed to notify blob deletion")
+				}
+			}
+		}
 	}
 	dcontext.GetLoggerWithField(ctx, "duration_s", time.Since(sweepStart).Seconds()).Info("sweep stage complete")
 
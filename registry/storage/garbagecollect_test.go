@@ -1,9 +1,14 @@
 package storage
 
 import (
+	"bytes"
+	stdcontext "context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"path"
+	"sync"
 	"testing"
 
 	"github.com/docker/distribution"
@@ -13,6 +18,7 @@ import (
 	"github.com/docker/distribution/registry/storage/driver/inmemory"
 	"github.com/docker/distribution/testutil"
 	"github.com/docker/libtrust"
+	"github.com/hashicorp/go-multierror"
 	"github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/require"
 )
@@ -341,6 +347,249 @@ func TestOrphanBlobDeleted(t *testing.T) {
 	}
 }
 
+// recordingGCListener is a notifications.GCListener that records all deletions.
+type recordingGCListener struct {
+	blobs     map[digest.Digest]struct{}
+	manifests map[string][]digest.Digest
+}
+
+func (l *recordingGCListener) BlobDeleted(dgst digest.Digest) error {
+	l.blobs[dgst] = struct{}{}
+	return nil
+}
+
+func (l *recordingGCListener) ManifestDeleted(repo string, dgst digest.Digest) error {
+	l.manifests[repo] = append(l.manifests[repo], dgst)
+	return nil
+}
+
+func TestGCEventListener(t *testing.T) {
+	inmemoryDriver := inmemory.New()
+
+	registry := createRegistry(t, inmemoryDriver)
+	repo := makeRepository(t, registry, "gcevents")
+
+	// an orphan blob and an untagged manifest, both eligible for deletion
+	orphans, err := testutil.CreateRandomLayers(1)
+	require.NoError(t, err)
+	err = testutil.UploadBlobs(repo, orphans)
+	require.NoError(t, err)
+
+	image, err := testutil.UploadRandomSchema2Image(repo)
+	require.NoError(t, err)
+
+	before := allBlobs(t, registry)
+
+	l := &recordingGCListener{
+		blobs:     make(map[digest.Digest]struct{}),
+		manifests: make(map[string][]digest.Digest),
+	}
+
+	// dry-run must not emit any event
+	err = MarkAndSweep(context.Background(), inmemoryDriver, registry, GCOpts{
+		DryRun:         true,
+		RemoveUntagged: true,
+		EventListener:  l,
+	})
+	require.NoError(t, err)
+	require.Empty(t, l.blobs)
+	require.Empty(t, l.manifests)
+
+	err = MarkAndSweep(context.Background(), inmemoryDriver, registry, GCOpts{
+		DryRun:         false,
+		RemoveUntagged: true,
+		EventListener:  l,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, map[string][]digest.Digest{"gcevents": {image.ManifestDigest}}, l.manifests)
+
+	after := allBlobs(t, registry)
+	for dgst := range after {
+		delete(before, dgst)
+	}
+	require.Equal(t, before, l.blobs)
+	for dgst := range orphans {
+		require.Contains(t, l.blobs, dgst)
+	}
+}
+
+func TestGCReport(t *testing.T) {
+	inmemoryDriver := inmemory.New()
+
+	registry := createRegistry(t, inmemoryDriver)
+	repo := makeRepository(t, registry, "gcreport")
+
+	orphans, err := testutil.CreateRandomLayers(1)
+	require.NoError(t, err)
+	err = testutil.UploadBlobs(repo, orphans)
+	require.NoError(t, err)
+
+	image, err := testutil.UploadRandomSchema2Image(repo)
+	require.NoError(t, err)
+
+	before := allBlobs(t, registry)
+
+	buf := new(bytes.Buffer)
+	err = MarkAndSweep(context.Background(), inmemoryDriver, registry, GCOpts{
+		DryRun:         true,
+		RemoveUntagged: true,
+		Report:         buf,
+	})
+	require.NoError(t, err)
+
+	// nothing must have been deleted
+	require.Equal(t, before, allBlobs(t, registry))
+
+	var report GCReport
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+
+	require.True(t, report.DryRun)
+	require.Equal(t, 1, report.ManifestsCount)
+	require.Len(t, report.Manifests, 1)
+	require.Equal(t, "gcreport", report.Manifests[0].Repository)
+	require.Equal(t, image.ManifestDigest, report.Manifests[0].Digest)
+	require.NotZero(t, report.Manifests[0].SizeBytes)
+
+	// the untagged image is no longer referenced, so all its blobs are eligible for deletion along with the orphan
+	require.Equal(t, len(before), report.BlobsCount)
+	require.Len(t, report.Blobs, len(before))
+
+	var total int64
+	for _, b := range report.Blobs {
+		require.Contains(t, before, b.Digest)
+		total += b.SizeBytes
+	}
+	require.Equal(t, total, report.TotalSizeBytes)
+	for dgst := range orphans {
+		require.Contains(t, buf.String(), dgst.String())
+	}
+}
+
+func TestOrphanBlobsDeletedInParallelBatches(t *testing.T) {
+	inmemoryDriver := inmemory.New()
+
+	registry := createRegistry(t, inmemoryDriver)
+	repo := makeRepository(t, registry, "anna_komnene")
+
+	digests, err := testutil.CreateRandomLayers(10)
+	require.NoError(t, err)
+
+	err = testutil.UploadBlobs(repo, digests)
+	require.NoError(t, err)
+
+	image, err := testutil.UploadRandomSchema2Image(repo)
+	require.NoError(t, err)
+
+	// Run GC
+	err = MarkAndSweep(context.Background(), inmemoryDriver, registry, GCOpts{
+		DryRun:                 false,
+		RemoveUntagged:         false,
+		MaxParallelBlobDeletes: 3,
+		BlobDeleteBatchSize:    2,
+	})
+	require.NoError(t, err)
+
+	blobs := allBlobs(t, registry)
+
+	// check that orphan blob layers are not still around
+	for dgst := range digests {
+		require.NotContains(t, blobs, dgst)
+	}
+
+	// check that the image manifest and all the layers are still around
+	require.Contains(t, blobs, image.ManifestDigest)
+	for layer := range image.Layers {
+		require.Contains(t, blobs, layer)
+	}
+}
+
+func TestMarkRepositoriesInParallel(t *testing.T) {
+	inmemoryDriver := inmemory.New()
+	registry := createRegistry(t, inmemoryDriver)
+
+	var images []testutil.Image
+	var orphans []digest.Digest
+	for i := 0; i < 4; i++ {
+		repo := makeRepository(t, registry, fmt.Sprintf("foo/bar%d", i))
+
+		image, err := testutil.UploadRandomSchema2Image(repo)
+		require.NoError(t, err)
+		images = append(images, image)
+
+		digests, err := testutil.CreateRandomLayers(1)
+		require.NoError(t, err)
+		require.NoError(t, testutil.UploadBlobs(repo, digests))
+		for dgst := range digests {
+			orphans = append(orphans, dgst)
+		}
+	}
+
+	err := MarkAndSweep(context.Background(), inmemoryDriver, registry, GCOpts{
+		MaxParallelRepositoryMarks: 2,
+	})
+	require.NoError(t, err)
+
+	blobs := allBlobs(t, registry)
+	for _, dgst := range orphans {
+		require.NotContains(t, blobs, dgst)
+	}
+	for _, image := range images {
+		require.Contains(t, blobs, image.ManifestDigest)
+		for layer := range image.Layers {
+			require.Contains(t, blobs, layer)
+		}
+	}
+}
+
+type sliceRepositoryEnumerator []string
+
+func (e sliceRepositoryEnumerator) Enumerate(ctx stdcontext.Context, ingester func(string) error) error {
+	for _, name := range e {
+		if err := ingester(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestMarkRepositories_AggregatesErrors(t *testing.T) {
+	repos := sliceRepositoryEnumerator{"a", "b", "c"}
+
+	// wait for all repositories to be dispatched before failing, so that all errors are collected
+	var started sync.WaitGroup
+	started.Add(len(repos))
+
+	err := markRepositories(context.Background(), repos, len(repos), func(repoName string) error {
+		started.Done()
+		started.Wait()
+		if repoName == "c" {
+			return nil
+		}
+		return fmt.Errorf("failed %s", repoName)
+	})
+
+	var merr *multierror.Error
+	require.True(t, errors.As(err, &merr))
+	require.Len(t, merr.Errors, 2)
+	require.Contains(t, err.Error(), "repository a: failed a")
+	require.Contains(t, err.Error(), "repository b: failed b")
+}
+
+func TestMarkRepositories_StopsOnError(t *testing.T) {
+	var marked []string
+
+	err := markRepositories(context.Background(), sliceRepositoryEnumerator{"a", "b", "c"}, 1, func(repoName string) error {
+		marked = append(marked, repoName)
+		if repoName == "b" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	require.EqualError(t, err, multierror.Append(nil, errors.New("repository b: failed")).Error())
+	require.Equal(t, []string{"a", "b"}, marked)
+}
+
 // TestGarbageCollectAfterLastTagRemoved was added to validate the scenario in which the last tag from the repository
 // is removed which in turn removes the <repository>/_manifests/tags folder. This was throwing a distribution.ErrRepositoryUnknown
 // error that is now being captured in garbagecollect.MarkAndSweep.
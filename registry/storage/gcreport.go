@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// GCReport is a machine-readable summary of the artifacts found eligible for deletion during the mark stage.
+type GCReport struct {
+	DryRun         bool               `json:"dry_run"`
+	BlobsCount     int                `json:"blobs_count"`
+	ManifestsCount int                `json:"manifests_count"`
+	TotalSizeBytes int64              `json:"total_size_bytes"`
+	Blobs          []GCReportBlob     `json:"blobs"`
+	Manifests      []GCReportManifest `json:"manifests"`
+}
+
+// GCReportBlob is a blob eligible for deletion.
+type GCReportBlob struct {
+	Digest    digest.Digest `json:"digest"`
+	SizeBytes int64         `json:"size_bytes"`
+}
+
+// GCReportManifest is a manifest eligible for deletion. SizeBytes is the size of the manifest payload.
+type GCReportManifest struct {
+	Repository string        `json:"repository"`
+	Digest     digest.Digest `json:"digest"`
+	Tags       []string      `json:"tags,omitempty"`
+	SizeBytes  int64         `json:"size_bytes"`
+}
+
+// syncSizeMap provides thread-safe recording of blob sizes.
+type syncSizeMap struct {
+	sync.Mutex
+	sizes map[digest.Digest]int64
+}
+
+func (m *syncSizeMap) set(d digest.Digest, size int64) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.sizes[d] = size
+}
+
+// newGCReport builds a report from the outcome of the mark stage. Entries are sorted so that the output is stable
+// across runs.
+func newGCReport(dryRun bool, deleteSet *syncDigestSet, manifestDels []ManifestDel, sizes *syncSizeMap) *GCReport {
+	deleteSet.Lock()
+	defer deleteSet.Unlock()
+	sizes.Lock()
+	defer sizes.Unlock()
+
+	r := &GCReport{
+		DryRun:    dryRun,
+		Blobs:     make([]GCReportBlob, 0, len(deleteSet.members)),
+		Manifests: make([]GCReportManifest, 0, len(manifestDels)),
+	}
+
+	for dgst := range deleteSet.members {
+		size := sizes.sizes[dgst]
+		r.Blobs = append(r.Blobs, GCReportBlob{Digest: dgst, SizeBytes: size})
+		r.TotalSizeBytes += size
+	}
+	sort.Slice(r.Blobs, func(i, j int) bool { return r.Blobs[i].Digest < r.Blobs[j].Digest })
+
+	for _, m := range manifestDels {
+		r.Manifests = append(r.Manifests, GCReportManifest{
+			Repository: m.Name,
+			Digest:     m.Digest,
+			Tags:       m.Tags,
+			SizeBytes:  sizes.sizes[m.Digest],
+		})
+	}
+	sort.Slice(r.Manifests, func(i, j int) bool {
+		if r.Manifests[i].Repository != r.Manifests[j].Repository {
+			return r.Manifests[i].Repository < r.Manifests[j].Repository
+		}
+		return r.Manifests[i].Digest < r.Manifests[j].Digest
+	})
+
+	r.BlobsCount = len(r.Blobs)
+	r.ManifestsCount = len(r.Manifests)
+
+	return r
+}
+
+// write encodes the report as indented JSON.
+func (r *GCReport) write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(r)
+}
@@ -209,6 +209,16 @@ func (lbs *linkedBlobStore) Resume(ctx context.Context, id string) (distribution
 		return nil, err
 	}
 
+	// uploads past their maximum age are eligible for purging, so they must not be resumed, otherwise they could be
+	// deleted halfway through
+	if lbs.registry != nil && lbs.registry.uploadMaxAge > 0 && time.Since(startedAt) > lbs.registry.uploadMaxAge {
+		dcontext.GetLoggerWithFields(ctx, map[interface{}]interface{}{
+			"upload_uuid": id,
+			"started_at":  startedAt,
+		}).Info("upload expired")
+		return nil, distribution.ErrBlobUploadUnknown
+	}
+
 	path, err := pathFor(uploadDataPathSpec{
 		name: lbs.repository.Named().Name(),
 		id:   id,
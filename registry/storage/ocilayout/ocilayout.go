@@ -0,0 +1,443 @@
+// Package ocilayout provides tools to export repositories to and import
+// repositories from OCI image layout tarballs, enabling the transfer of
+// images between registries without network connectivity between them.
+//
+// See https://github.com/opencontainers/image-spec/blob/main/image-layout.md
+package ocilayout
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/docker/distribution"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	indexFile = "index.json"
+	blobsDir  = "blobs"
+
+	// maxManifestSize is the maximum size of the manifests which can be imported, matching the maximum size of
+	// manifests which can be pushed through the API.
+	maxManifestSize = 4 << 20
+)
+
+var anchoredTagRegexp = regexp.MustCompile(`^` + reference.TagRegexp.String() + `$`)
+
+// Export writes the manifests tagged with the given tags in repo, along with all the manifests and blobs they
+// reference, to w as an OCI image layout tarball. All tags are exported if none are given. Blobs are streamed from the
+// storage backend, so that arbitrarily large repositories can be exported. Foreign layers which are not stored in the
+// registry are skipped.
+func Export(ctx context.Context, repo distribution.Repository, tags []string, w io.Writer) error {
+	tagService := repo.Tags(ctx)
+	if len(tags) == 0 {
+		var err error
+		tags, err = tagService.All(ctx)
+		if err != nil {
+			return fmt.Errorf("listing tags: %w", err)
+		}
+		sort.Strings(tags)
+	}
+
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return err
+	}
+
+	e := &exporter{
+		manifests: manifests,
+		blobs:     repo.Blobs(ctx),
+		tw:        tar.NewWriter(w),
+		written:   make(map[digest.Digest]struct{}),
+	}
+
+	layout, err := json.Marshal(v1.ImageLayout{Version: v1.ImageLayoutVersion})
+	if err != nil {
+		return err
+	}
+	if err := e.writeFile(v1.ImageLayoutFile, layout); err != nil {
+		return err
+	}
+
+	index := v1.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		Manifests: make([]v1.Descriptor, 0, len(tags)),
+	}
+	for _, tag := range tags {
+		log := dcontext.GetLoggerWithField(ctx, "tag", tag)
+		log.Info("exporting tag")
+
+		desc, err := tagService.Get(ctx, tag)
+		if err != nil {
+			return fmt.Errorf("resolving tag %q: %w", tag, err)
+		}
+
+		mediaType, size, err := e.exportManifest(ctx, desc.Digest)
+		if err != nil {
+			return fmt.Errorf("exporting tag %q: %w", tag, err)
+		}
+
+		index.Manifests = append(index.Manifests, v1.Descriptor{
+			MediaType:   mediaType,
+			Digest:      desc.Digest,
+			Size:        size,
+			Annotations: map[string]string{v1.AnnotationRefName: tag},
+		})
+	}
+
+	p, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	if err := e.writeFile(indexFile, p); err != nil {
+		return err
+	}
+
+	return e.tw.Close()
+}
+
+type exporter struct {
+	manifests distribution.ManifestService
+	blobs     distribution.BlobStore
+	tw        *tar.Writer
+	written   map[digest.Digest]struct{}
+}
+
+// exportManifest writes the manifest identified by dgst, and all the manifests and blobs it references, returning its
+// media type and size.
+func (e *exporter) exportManifest(ctx context.Context, dgst digest.Digest) (string, int64, error) {
+	m, err := e.manifests.Get(ctx, dgst)
+	if err != nil {
+		return "", 0, fmt.Errorf("getting manifest %s: %w", dgst, err)
+	}
+	mediaType, payload, err := m.Payload()
+	if err != nil {
+		return "", 0, err
+	}
+
+	if _, ok := e.written[dgst]; ok {
+		return mediaType, int64(len(payload)), nil
+	}
+
+	_, isList := m.(*manifestlist.DeserializedManifestList)
+	for _, ref := range m.References() {
+		if isList {
+			_, _, err = e.exportManifest(ctx, ref.Digest)
+		} else {
+			err = e.exportBlob(ctx, ref)
+		}
+		if err != nil {
+			return "", 0, err
+		}
+	}
+
+	if err := e.writeFile(blobPath(dgst), payload); err != nil {
+		return "", 0, err
+	}
+	e.written[dgst] = struct{}{}
+
+	return mediaType, int64(len(payload)), nil
+}
+
+func (e *exporter) exportBlob(ctx context.Context, desc distribution.Descriptor) error {
+	if _, ok := e.written[desc.Digest]; ok {
+		return nil
+	}
+
+	stat, err := e.blobs.Stat(ctx, desc.Digest)
+	if err != nil {
+		if errors.Is(err, distribution.ErrBlobUnknown) && len(desc.URLs) > 0 {
+			dcontext.GetLoggerWithField(ctx, "digest", desc.Digest).Info("skipping foreign layer")
+			return nil
+		}
+		return fmt.Errorf("getting blob %s: %w", desc.Digest, err)
+	}
+
+	rc, err := e.blobs.Open(ctx, desc.Digest)
+	if err != nil {
+		return fmt.Errorf("opening blob %s: %w", desc.Digest, err)
+	}
+	defer rc.Close()
+
+	if err := e.tw.WriteHeader(&tar.Header{Name: blobPath(desc.Digest), Mode: 0644, Size: stat.Size}); err != nil {
+		return err
+	}
+	if _, err := io.Copy(e.tw, rc); err != nil {
+		return fmt.Errorf("copying blob %s: %w", desc.Digest, err)
+	}
+	e.written[desc.Digest] = struct{}{}
+
+	return nil
+}
+
+func (e *exporter) writeFile(name string, p []byte) error {
+	if err := e.tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(p))}); err != nil {
+		return err
+	}
+	_, err := e.tw.Write(p)
+	return err
+}
+
+func blobPath(dgst digest.Digest) string {
+	return path.Join(blobsDir, dgst.Algorithm().String(), dgst.Encoded())
+}
+
+// Import reads an OCI image layout tarball from r into repo, returning the imported tags. Manifests referenced by
+// the layout index are tagged as per their org.opencontainers.image.ref.name annotation, if any.
+//
+// The tarball is read in a single pass, so it can be streamed. Blobs which look like manifests, that is, small JSON
+// documents, are buffered until the layout index has been read. All others are streamed to the storage backend.
+func Import(ctx context.Context, repo distribution.Repository, r io.Reader) ([]string, error) {
+	blobs := repo.Blobs(ctx)
+	buffered := make(map[digest.Digest][]byte)
+
+	var index *v1.Index
+	var layoutFound bool
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading layout: %w", err)
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		switch {
+		case name == v1.ImageLayoutFile:
+			var layout v1.ImageLayout
+			if err := json.NewDecoder(tr).Decode(&layout); err != nil {
+				return nil, fmt.Errorf("decoding %s: %w", v1.ImageLayoutFile, err)
+			}
+			if layout.Version != v1.ImageLayoutVersion {
+				return nil, fmt.Errorf("unsupported image layout version %q", layout.Version)
+			}
+			layoutFound = true
+		case name == indexFile:
+			index = &v1.Index{}
+			if err := json.NewDecoder(io.LimitReader(tr, maxManifestSize)).Decode(index); err != nil {
+				return nil, fmt.Errorf("decoding %s: %w", indexFile, err)
+			}
+		case strings.HasPrefix(name, blobsDir+"/"):
+			parts := strings.Split(name, "/")
+			if len(parts) != 3 {
+				continue
+			}
+			dgst := digest.NewDigestFromEncoded(digest.Algorithm(parts[1]), parts[2])
+			if err := dgst.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid blob %s: %w", name, err)
+			}
+
+			br := bufio.NewReader(tr)
+			if hdr.Size <= maxManifestSize && looksLikeJSON(br) {
+				p, err := ioutil.ReadAll(br)
+				if err != nil {
+					return nil, fmt.Errorf("reading blob %s: %w", dgst, err)
+				}
+				buffered[dgst] = p
+				continue
+			}
+
+			dcontext.GetLoggerWithField(ctx, "digest", dgst).Info("importing blob")
+			if err := importBlob(ctx, blobs, dgst, hdr.Size, br); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !layoutFound {
+		return nil, fmt.Errorf("%s not found, not an OCI image layout", v1.ImageLayoutFile)
+	}
+	if index == nil {
+		return nil, fmt.Errorf("%s not found, not an OCI image layout", indexFile)
+	}
+
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &importer{
+		manifests: manifests,
+		buffered:  buffered,
+		parsed:    make(map[digest.Digest]distribution.Manifest),
+	}
+	for _, desc := range index.Manifests {
+		if err := i.resolveManifest(desc.Digest, desc.MediaType); err != nil {
+			return nil, err
+		}
+	}
+
+	// buffered blobs which are not manifests, such as image configurations, are imported as regular blobs
+	for dgst, p := range buffered {
+		if _, ok := i.parsed[dgst]; ok {
+			continue
+		}
+		if err := importBlob(ctx, blobs, dgst, int64(len(p)), bytes.NewReader(p)); err != nil {
+			return nil, err
+		}
+	}
+
+	// manifests must be put after the manifests and blobs they reference
+	for _, dgst := range i.order {
+		dcontext.GetLoggerWithField(ctx, "digest", dgst).Info("importing manifest")
+		got, err := manifests.Put(ctx, i.parsed[dgst])
+		if err != nil {
+			return nil, fmt.Errorf("putting manifest %s: %w", dgst, err)
+		}
+		if got != dgst {
+			return nil, fmt.Errorf("manifest %s was stored with digest %s", dgst, got)
+		}
+	}
+
+	tagService := repo.Tags(ctx)
+	var tags []string
+	for _, desc := range index.Manifests {
+		tag, ok := desc.Annotations[v1.AnnotationRefName]
+		if !ok {
+			continue
+		}
+		if !anchoredTagRegexp.MatchString(tag) {
+			return nil, fmt.Errorf("invalid tag %q for manifest %s", tag, desc.Digest)
+		}
+
+		dcontext.GetLoggerWithField(ctx, "tag", tag).Info("importing tag")
+		if err := tagService.Tag(ctx, tag, distribution.Descriptor{Digest: desc.Digest, MediaType: desc.MediaType}); err != nil {
+			return nil, fmt.Errorf("tagging manifest %s with %q: %w", desc.Digest, tag, err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+type importer struct {
+	manifests distribution.ManifestService
+	buffered  map[digest.Digest][]byte
+	parsed    map[digest.Digest]distribution.Manifest
+	// order lists manifests in the order they must be put in.
+	order []digest.Digest
+}
+
+// resolveManifest parses the buffered manifest identified by dgst, and all the manifests it references.
+func (i *importer) resolveManifest(dgst digest.Digest, mediaType string) error {
+	if _, ok := i.parsed[dgst]; ok {
+		return nil
+	}
+
+	p, ok := i.buffered[dgst]
+	if !ok {
+		return fmt.Errorf("manifest %s not found in layout", dgst)
+	}
+	if dgst.Algorithm().FromBytes(p) != dgst {
+		return fmt.Errorf("manifest %s does not match its digest", dgst)
+	}
+
+	m, _, err := distribution.UnmarshalManifest(manifestMediaType(mediaType, p), p)
+	if err != nil {
+		return fmt.Errorf("parsing manifest %s: %w", dgst, err)
+	}
+	i.parsed[dgst] = m
+
+	if _, ok := m.(*manifestlist.DeserializedManifestList); ok {
+		for _, ref := range m.References() {
+			if err := i.resolveManifest(ref.Digest, ref.MediaType); err != nil {
+				return err
+			}
+		}
+	}
+	i.order = append(i.order, dgst)
+
+	return nil
+}
+
+// manifestMediaType determines the media type of a manifest payload. The media type embedded in the payload takes
+// precedence over the one of the referencing descriptor, as the latter may be inaccurate for manifests pushed by old
+// clients. OCI manifests are not required to embed their media type, so these are detected by their structure.
+func manifestMediaType(descMediaType string, p []byte) string {
+	var v struct {
+		MediaType string          `json:"mediaType"`
+		Manifests json.RawMessage `json:"manifests"`
+	}
+	if err := json.Unmarshal(p, &v); err == nil && v.MediaType != "" {
+		return v.MediaType
+	}
+
+	for _, mt := range distribution.ManifestMediaTypes() {
+		if mt != "" && mt == descMediaType {
+			return descMediaType
+		}
+	}
+
+	if v.Manifests != nil {
+		return v1.MediaTypeImageIndex
+	}
+	return v1.MediaTypeImageManifest
+}
+
+// looksLikeJSON returns whether the content of br starts with a JSON object, without consuming it.
+func looksLikeJSON(br *bufio.Reader) bool {
+	for n := 1; n <= 64; n++ {
+		p, err := br.Peek(n)
+		if len(p) < n {
+			return false
+		}
+		switch p[n-1] {
+		case ' ', '\t', '\r', '\n':
+			if err != nil {
+				return false
+			}
+			continue
+		case '{':
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// importBlob streams the blob identified by dgst from r to the repository blob store, unless already present.
+func importBlob(ctx context.Context, blobs distribution.BlobStore, dgst digest.Digest, size int64, r io.Reader) error {
+	if _, err := blobs.Stat(ctx, dgst); err == nil {
+		return nil
+	} else if !errors.Is(err, distribution.ErrBlobUnknown) {
+		return fmt.Errorf("getting blob %s: %w", dgst, err)
+	}
+
+	bw, err := blobs.Create(ctx)
+	if err != nil {
+		return fmt.Errorf("creating blob %s: %w", dgst, err)
+	}
+	if _, err := io.Copy(bw, r); err != nil {
+		bw.Cancel(ctx)
+		return fmt.Errorf("writing blob %s: %w", dgst, err)
+	}
+	if _, err := bw.Commit(ctx, distribution.Descriptor{Digest: dgst, Size: size}); err != nil {
+		bw.Cancel(ctx)
+		return fmt.Errorf("committing blob %s: %w", dgst, err)
+	}
+
+	return nil
+}
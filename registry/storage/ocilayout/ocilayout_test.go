@@ -0,0 +1,142 @@
+package ocilayout
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/docker/distribution/testutil"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func newRepository(t *testing.T, registry distribution.Namespace, name string) distribution.Repository {
+	t.Helper()
+
+	named, err := reference.WithName(name)
+	require.NoError(t, err)
+	repo, err := registry.Repository(context.Background(), named)
+	require.NoError(t, err)
+
+	return repo
+}
+
+func newRegistry(t *testing.T) distribution.Namespace {
+	t.Helper()
+
+	registry, err := storage.NewRegistry(context.Background(), inmemory.New())
+	require.NoError(t, err)
+
+	return registry
+}
+
+func requireBlobContent(t *testing.T, repo distribution.Repository, dgst digest.Digest) {
+	t.Helper()
+
+	rc, err := repo.Blobs(context.Background()).Open(context.Background(), dgst)
+	require.NoError(t, err)
+	defer rc.Close()
+
+	verifier := dgst.Verifier()
+	_, err = io.Copy(verifier, rc)
+	require.NoError(t, err)
+	require.True(t, verifier.Verified())
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+
+	srcRegistry := newRegistry(t)
+	src := newRepository(t, srcRegistry, "foo/src")
+
+	image, err := testutil.UploadRandomSchema2Image(src)
+	require.NoError(t, err)
+	require.NoError(t, src.Tags(ctx).Tag(ctx, "image", distribution.Descriptor{Digest: image.ManifestDigest}))
+
+	list := testutil.UploadRandomImageList(t, srcRegistry, src)
+	require.NoError(t, src.Tags(ctx).Tag(ctx, "list", distribution.Descriptor{Digest: list.ManifestDigest}))
+
+	// not exported
+	other, err := testutil.UploadRandomSchema2Image(src)
+	require.NoError(t, err)
+	require.NoError(t, src.Tags(ctx).Tag(ctx, "other", distribution.Descriptor{Digest: other.ManifestDigest}))
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, Export(ctx, src, []string{"image", "list"}, buf))
+
+	dst := newRepository(t, newRegistry(t), "bar/dst")
+	tags, err := Import(ctx, dst, buf)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"image", "list"}, tags)
+
+	desc, err := dst.Tags(ctx).Get(ctx, "image")
+	require.NoError(t, err)
+	require.Equal(t, image.ManifestDigest, desc.Digest)
+	desc, err = dst.Tags(ctx).Get(ctx, "list")
+	require.NoError(t, err)
+	require.Equal(t, list.ManifestDigest, desc.Digest)
+	_, err = dst.Tags(ctx).Get(ctx, "other")
+	require.Error(t, err)
+
+	manifests, err := dst.Manifests(ctx)
+	require.NoError(t, err)
+	for _, img := range append(list.Images, image) {
+		m, err := manifests.Get(ctx, img.ManifestDigest)
+		require.NoError(t, err)
+		// configuration and layers
+		for _, ref := range m.References() {
+			_, err := dst.Blobs(ctx).Stat(ctx, ref.Digest)
+			require.NoError(t, err)
+		}
+		for dgst := range img.Layers {
+			requireBlobContent(t, dst, dgst)
+		}
+	}
+}
+
+func TestExport_AllTags(t *testing.T) {
+	ctx := context.Background()
+
+	src := newRepository(t, newRegistry(t), "foo/src")
+	for _, tag := range []string{"b", "a"} {
+		image, err := testutil.UploadRandomSchema2Image(src)
+		require.NoError(t, err)
+		require.NoError(t, src.Tags(ctx).Tag(ctx, tag, distribution.Descriptor{Digest: image.ManifestDigest}))
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, Export(ctx, src, nil, buf))
+
+	tags, err := Import(ctx, newRepository(t, newRegistry(t), "bar/dst"), buf)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, tags)
+}
+
+func TestImport_NotALayout(t *testing.T) {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "foo", Mode: 0644, Size: 3}))
+	_, err := tw.Write([]byte("bar"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	_, err = Import(context.Background(), newRepository(t, newRegistry(t), "bar/dst"), buf)
+	require.EqualError(t, err, v1.ImageLayoutFile+" not found, not an OCI image layout")
+}
+
+func TestLooksLikeJSON(t *testing.T) {
+	require.True(t, looksLikeJSON(bufio.NewReader(strings.NewReader(`{"a":1}`))))
+	require.True(t, looksLikeJSON(bufio.NewReader(strings.NewReader("\n  {}"))))
+	require.False(t, looksLikeJSON(bufio.NewReader(strings.NewReader(""))))
+	require.False(t, looksLikeJSON(bufio.NewReader(strings.NewReader("   "))))
+	require.False(t, looksLikeJSON(bufio.NewReader(strings.NewReader("\x1f\x8b\x08"))))
+}
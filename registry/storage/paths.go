@@ -90,9 +90,11 @@ const (
 //
 //	Uploads:
 //
+// 	uploadsPathSpec:                <root>/v2/repositories/<name>/_uploads
 // 	uploadDataPathSpec:             <root>/v2/repositories/<name>/_uploads/<id>/data
 // 	uploadStartedAtPathSpec:        <root>/v2/repositories/<name>/_uploads/<id>/startedat
 // 	uploadHashStatePathSpec:        <root>/v2/repositories/<name>/_uploads/<id>/hashstates/<algorithm>/<offset>
+// 	uploadPartPathSpec:             <root>/v2/repositories/<name>/_uploads/<id>/parts/<offset>
 //
 //	Blob Store:
 //
@@ -238,6 +240,8 @@ func pathFor(spec pathSpec) (string, error) {
 		blobPathPrefix := append(rootPrefix, "blobs")
 		return path.Join(append(blobPathPrefix, components...)...), nil
 
+	case uploadsPathSpec:
+		return path.Join(append(repoPrefix, v.name, "_uploads")...), nil
 	case uploadDataPathSpec:
 		return path.Join(append(repoPrefix, v.name, "_uploads", v.id, "data")...), nil
 	case uploadStartedAtPathSpec:
@@ -248,6 +252,12 @@ func pathFor(spec pathSpec) (string, error) {
 			offset = "" // Limit to the prefix for listing offsets.
 		}
 		return path.Join(append(repoPrefix, v.name, "_uploads", v.id, "hashstates", string(v.alg), offset)...), nil
+	case uploadPartPathSpec:
+		offset := fmt.Sprintf("%d", v.offset)
+		if v.list {
+			offset = "" // Limit to the prefix for listing offsets.
+		}
+		return path.Join(append(repoPrefix, v.name, "_uploads", v.id, "parts", offset)...), nil
 	case repositoriesRootPathSpec:
 		return path.Join(repoPrefix...), nil
 	case repositoryRootPathSpec:
@@ -396,6 +406,14 @@ type blobDataPathSpec struct {
 
 func (blobDataPathSpec) pathSpec() {}
 
+// uploadsPathSpec defines the path parameters of the directory holding all
+// uploads of a repository.
+type uploadsPathSpec struct {
+	name string
+}
+
+func (uploadsPathSpec) pathSpec() {}
+
 // uploadDataPathSpec defines the path parameters of the data file for
 // uploads.
 type uploadDataPathSpec struct {
@@ -433,6 +451,19 @@ type uploadHashStatePathSpec struct {
 
 func (uploadHashStatePathSpec) pathSpec() {}
 
+// uploadPartPathSpec defines the path parameters for the file that stores a
+// part of an upload written out of order, starting at a specific byte offset.
+// If `list` is set, then the path mapper will generate a list prefix for all
+// parts of the upload identified by the name and id.
+type uploadPartPathSpec struct {
+	name   string
+	id     string
+	offset int64
+	list   bool
+}
+
+func (uploadPartPathSpec) pathSpec() {}
+
 // repositoriesRootPathSpec returns the root of repositories
 type repositoriesRootPathSpec struct {
 }
@@ -70,6 +70,12 @@ func TestPathMapper(t *testing.T) {
 			expected: "/docker/registry/v2/repositories/foo/bar/_manifests/tags/thetag/index/sha256/abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789/link",
 		},
 
+		{
+			spec: uploadsPathSpec{
+				name: "foo/bar",
+			},
+			expected: "/docker/registry/v2/repositories/foo/bar/_uploads",
+		},
 		{
 			spec: uploadDataPathSpec{
 				name: "foo/bar",
@@ -84,6 +90,22 @@ func TestPathMapper(t *testing.T) {
 			},
 			expected: "/docker/registry/v2/repositories/foo/bar/_uploads/asdf-asdf-asdf-adsf/startedat",
 		},
+		{
+			spec: uploadPartPathSpec{
+				name:   "foo/bar",
+				id:     "asdf-asdf-asdf-adsf",
+				offset: 1024,
+			},
+			expected: "/docker/registry/v2/repositories/foo/bar/_uploads/asdf-asdf-asdf-adsf/parts/1024",
+		},
+		{
+			spec: uploadPartPathSpec{
+				name: "foo/bar",
+				id:   "asdf-asdf-asdf-adsf",
+				list: true,
+			},
+			expected: "/docker/registry/v2/repositories/foo/bar/_uploads/asdf-asdf-asdf-adsf/parts",
+		},
 		{
 			spec: repositoryRootPathSpec{
 				name: "foo/bar",
@@ -8,6 +8,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
 	storageDriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/uuid"
 	"github.com/sirupsen/logrus"
@@ -79,6 +81,117 @@ func PurgeUploads(ctx context.Context, driver storageDriver.StorageDriver, older
 	return deleted, errors
 }
 
+// purgeUploadsDBBatchSize is the maximum number of uploads that PurgeUploadsDB deletes at once.
+const purgeUploadsDBBatchSize = 500
+
+// PurgeUploadsDB is the metadata database counterpart of PurgeUploads. Instead of walking the storage backend, uploads
+// created before olderThan are found through the blob_uploads table, and their data and startedat files are deleted in
+// bulk with DeleteFiles. Hash states (only saved for resumable digests) and parts written out of order can not be located without listing, so the
+// remainder of each upload directory is then deleted separately. Uploads started before the metadata database was enabled are not
+// tracked in it, and therefore not purged. The list of upload directories deleted and errors encountered are returned.
+func PurgeUploadsDB(ctx context.Context, driver storageDriver.StorageDriver, db datastore.Queryer, olderThan time.Time, actuallyDelete bool) ([]string, []error) {
+	logrus.Infof("PurgeUploadsDB starting: olderThan=%s, actuallyDelete=%t", olderThan, actuallyDelete)
+
+	var deleted []string
+	var errors []error
+	s := datastore.NewBlobUploadStore(db)
+	before := olderThan
+
+	for {
+		uu, err := s.FindStartedBefore(ctx, before, purgeUploadsDBBatchSize)
+		if err != nil {
+			errors = append(errors, err)
+			break
+		}
+		if len(uu) == 0 {
+			break
+		}
+
+		dirs, err := uploadDirs(uu)
+		if err != nil {
+			errors = append(errors, err)
+			break
+		}
+		if !actuallyDelete {
+			deleted = append(deleted, dirs...)
+			// nothing is deleted, so move the cutoff to find the next batch
+			before = uu[len(uu)-1].StartedAt
+			continue
+		}
+
+		errs := deleteUploads(ctx, driver, uu)
+		if len(errs) > 0 {
+			// leave the uploads in the database so that they can be retried on the next run
+			errors = append(errors, errs...)
+			break
+		}
+
+		ids := make([]string, 0, len(uu))
+		for _, u := range uu {
+			ids = append(ids, u.ID)
+		}
+		if err := s.DeleteMany(ctx, ids); err != nil {
+			errors = append(errors, err)
+			break
+		}
+		deleted = append(deleted, dirs...)
+	}
+
+	logrus.Infof("Purge uploads finished.  Num deleted=%d, num errors=%d", len(deleted), len(errors))
+	return deleted, errors
+}
+
+// uploadDirs returns the upload directory of each upload in uu.
+func uploadDirs(uu []*models.BlobUpload) ([]string, error) {
+	dirs := make([]string, 0, len(uu))
+	for _, u := range uu {
+		p, err := pathFor(uploadDataPathSpec{name: u.RepositoryPath, id: u.ID})
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, path.Dir(p))
+	}
+
+	return dirs, nil
+}
+
+// deleteUploads deletes the files of all uploads in uu from the storage backend.
+func deleteUploads(ctx context.Context, driver storageDriver.StorageDriver, uu []*models.BlobUpload) []error {
+	var errors []error
+
+	files := make([]string, 0, len(uu)*2)
+	for _, u := range uu {
+		dataPath, err := pathFor(uploadDataPathSpec{name: u.RepositoryPath, id: u.ID})
+		if err != nil {
+			return []error{err}
+		}
+		startedAtPath, err := pathFor(uploadStartedAtPathSpec{name: u.RepositoryPath, id: u.ID})
+		if err != nil {
+			return []error{err}
+		}
+		files = append(files, dataPath, startedAtPath)
+	}
+	if _, err := driver.DeleteFiles(ctx, files); err != nil {
+		return []error{err}
+	}
+
+	// remove whatever is left, such as hash states and, for drivers backed by a hierarchical filesystem, the now empty
+	// upload directory itself
+	dirs, err := uploadDirs(uu)
+	if err != nil {
+		return []error{err}
+	}
+	for _, dir := range dirs {
+		if err := driver.Delete(ctx, dir); err != nil {
+			if _, ok := err.(storageDriver.PathNotFoundError); !ok {
+				errors = append(errors, fmt.Errorf("%s: %w", dir, err))
+			}
+		}
+	}
+
+	return errors
+}
+
 // getOutstandingUploads walks the upload directory, collecting files
 // which could be eligible for deletion.  The only reliable way to
 // classify the age of a file is with the date stored in the startedAt
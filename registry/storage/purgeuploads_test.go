@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/docker/distribution/registry/datastore/models"
 	"github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/registry/storage/driver/inmemory"
 	"github.com/docker/distribution/uuid"
@@ -163,3 +164,53 @@ func TestPurgeMissingStartedAt(t *testing.T) {
 		t.Errorf("Files unexpectedly deleted: %s", deleted)
 	}
 }
+
+func TestPurgeDeleteUploads(t *testing.T) {
+	oneHourAgo := time.Now().Add(-1 * time.Hour)
+	fs, ctx := testUploadFS(t, 0, "test-repo", oneHourAgo)
+
+	uu := []*models.BlobUpload{
+		{ID: uuid.Generate().String(), RepositoryPath: "test-repo", StartedAt: oneHourAgo},
+		{ID: uuid.Generate().String(), RepositoryPath: "library/test-repo", StartedAt: oneHourAgo},
+	}
+	for _, u := range uu {
+		addUploads(ctx, t, fs, u.ID, u.RepositoryPath, u.StartedAt)
+	}
+
+	// only the first upload has saved hash states
+	hashStatePath, err := pathFor(uploadHashStatePathSpec{name: uu[0].RepositoryPath, id: uu[0].ID, alg: "sha256", offset: 0})
+	if err != nil {
+		t.Fatalf("Unable to resolve path")
+	}
+	if err := fs.PutContent(ctx, hashStatePath, []byte("")); err != nil {
+		t.Fatalf("Unable to write hash state file")
+	}
+
+	// a different upload in the same repository must be left alone
+	keepID := uuid.Generate().String()
+	addUploads(ctx, t, fs, keepID, "test-repo", oneHourAgo)
+
+	if errs := deleteUploads(ctx, fs, uu); len(errs) != 0 {
+		t.Fatal("Unexpected errors:", errs)
+	}
+
+	dirs, err := uploadDirs(uu)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, dir := range dirs {
+		if _, err := fs.Stat(ctx, dir); err == nil {
+			t.Errorf("Upload directory not deleted: %s", dir)
+		} else if _, ok := err.(driver.PathNotFoundError); !ok {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+
+	uploadData, errs := getOutstandingUploads(ctx, fs)
+	if len(errs) != 0 {
+		t.Errorf("Unexpected errors: %q", errs)
+	}
+	if _, ok := uploadData[keepID]; !ok || len(uploadData) != 1 {
+		t.Errorf("Unexpected remaining uploads: %v", uploadData)
+	}
+}
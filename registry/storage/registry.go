@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"time"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/reference"
@@ -33,6 +34,7 @@ type registry struct {
 	driver                       storagedriver.StorageDriver
 	db                           *datastore.DB
 	redirectExceptions           []*regexp.Regexp
+	uploadMaxAge                 time.Duration
 }
 
 // RegistryOption is the type used for functional options for NewRegistry.
@@ -91,6 +93,17 @@ func DisableDigestResumption(registry *registry) error {
 	return nil
 }
 
+// UploadMaxAge is a functional option for NewRegistry. Blob uploads started longer than maxAge ago can no longer be
+// resumed and are reported as unknown, regardless of whether they were already purged from the storage backend. The
+// age of an upload is determined by the start time recorded in its startedat file, not by the modification time of
+// the upload files, which is not consistent across storage drivers.
+func UploadMaxAge(maxAge time.Duration) RegistryOption {
+	return func(registry *registry) error {
+		registry.uploadMaxAge = maxAge
+		return nil
+	}
+}
+
 // ManifestURLsAllowRegexp is a functional option for NewRegistry.
 func ManifestURLsAllowRegexp(r *regexp.Regexp) RegistryOption {
 	return func(registry *registry) error {
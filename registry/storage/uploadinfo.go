@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	storageDriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/uuid"
+)
+
+// UploadInfo describes the progress of an in-progress blob upload.
+type UploadInfo struct {
+	// ID is the UUID of the upload.
+	ID string
+	// Size is the number of bytes received so far.
+	Size int64
+	// StartedAt is the time at which the upload was started.
+	StartedAt time.Time
+	// LastActivityAt is the time at which data was last written to the upload, or StartedAt if none was written yet.
+	LastActivityAt time.Time
+}
+
+// StatUpload returns the progress of the upload identified by id in the repository with the given name. Some storage
+// backends only account for data once each chunk has been completed, so Size and LastActivityAt may lag behind the
+// data being received. A storagedriver.PathNotFoundError is returned if the upload does not exist.
+func StatUpload(ctx context.Context, driver storageDriver.StorageDriver, name, id string) (*UploadInfo, error) {
+	startedAtPath, err := pathFor(uploadStartedAtPathSpec{name: name, id: id})
+	if err != nil {
+		return nil, err
+	}
+	p, err := driver.GetContent(ctx, startedAtPath)
+	if err != nil {
+		return nil, err
+	}
+	startedAt, err := time.Parse(time.RFC3339, string(p))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", startedAtPath, err)
+	}
+
+	info := &UploadInfo{ID: id, StartedAt: startedAt, LastActivityAt: startedAt}
+
+	dataPath, err := pathFor(uploadDataPathSpec{name: name, id: id})
+	if err != nil {
+		return nil, err
+	}
+	fi, err := driver.Stat(ctx, dataPath)
+	if err != nil {
+		if errors.As(err, &storageDriver.PathNotFoundError{}) {
+			// no data received yet
+			return info, nil
+		}
+		return nil, err
+	}
+
+	info.Size = fi.Size()
+	if fi.ModTime().After(startedAt) {
+		info.LastActivityAt = fi.ModTime()
+	}
+
+	return info, nil
+}
+
+// ListUploads returns the progress of all in-progress uploads of the repository with the given name, sorted by start
+// time. Upload directories without a start time are skipped, as they can not be resumed.
+func ListUploads(ctx context.Context, driver storageDriver.StorageDriver, name string) ([]*UploadInfo, error) {
+	uploadsPath, err := pathFor(uploadsPathSpec{name: name})
+	if err != nil {
+		return nil, err
+	}
+
+	dirs, err := driver.List(ctx, uploadsPath)
+	if err != nil {
+		if errors.As(err, &storageDriver.PathNotFoundError{}) {
+			return []*UploadInfo{}, nil
+		}
+		return nil, err
+	}
+
+	uu := make([]*UploadInfo, 0, len(dirs))
+	for _, dir := range dirs {
+		id := path.Base(dir)
+		if _, err := uuid.Parse(id); err != nil {
+			continue
+		}
+
+		info, err := StatUpload(ctx, driver, name, id)
+		if err != nil {
+			// the upload may have completed or been cancelled in the meantime
+			if errors.As(err, &storageDriver.PathNotFoundError{}) {
+				continue
+			}
+			return nil, err
+		}
+		uu = append(uu, info)
+	}
+
+	sort.Slice(uu, func(i, j int) bool {
+		if uu[i].StartedAt.Equal(uu[j].StartedAt) {
+			return uu[i].ID < uu[j].ID
+		}
+		return uu[i].StartedAt.Before(uu[j].StartedAt)
+	})
+
+	return uu, nil
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/docker/distribution/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatUpload(t *testing.T) {
+	d := inmemory.New()
+	ctx := context.Background()
+	startedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+
+	id := uuid.Generate().String()
+	addUploads(ctx, t, d, id, "foo/bar", startedAt)
+
+	dataPath, err := pathFor(uploadDataPathSpec{name: "foo/bar", id: id})
+	require.NoError(t, err)
+	require.NoError(t, d.PutContent(ctx, dataPath, []byte("1234")))
+
+	info, err := StatUpload(ctx, d, "foo/bar", id)
+	require.NoError(t, err)
+	require.Equal(t, id, info.ID)
+	require.EqualValues(t, 4, info.Size)
+	require.True(t, startedAt.Equal(info.StartedAt))
+	require.True(t, info.LastActivityAt.After(startedAt))
+}
+
+func TestStatUpload_NoData(t *testing.T) {
+	d := inmemory.New()
+	ctx := context.Background()
+	startedAt := time.Now().UTC().Truncate(time.Second)
+
+	id := uuid.Generate().String()
+	startedAtPath, err := pathFor(uploadStartedAtPathSpec{name: "foo/bar", id: id})
+	require.NoError(t, err)
+	require.NoError(t, d.PutContent(ctx, startedAtPath, []byte(startedAt.Format(time.RFC3339))))
+
+	info, err := StatUpload(ctx, d, "foo/bar", id)
+	require.NoError(t, err)
+	require.Zero(t, info.Size)
+	require.True(t, startedAt.Equal(info.LastActivityAt))
+}
+
+func TestStatUpload_Unknown(t *testing.T) {
+	_, err := StatUpload(context.Background(), inmemory.New(), "foo/bar", uuid.Generate().String())
+	require.True(t, errors.As(err, &driver.PathNotFoundError{}))
+}
+
+func TestListUploads(t *testing.T) {
+	d := inmemory.New()
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	older := uuid.Generate().String()
+	newer := uuid.Generate().String()
+	addUploads(ctx, t, d, newer, "foo/bar", now)
+	addUploads(ctx, t, d, older, "foo/bar", now.Add(-time.Hour))
+	// uploads of other repositories, including nested ones, are not listed
+	addUploads(ctx, t, d, uuid.Generate().String(), "foo/bar/baz", now)
+	addUploads(ctx, t, d, uuid.Generate().String(), "foo", now)
+
+	uu, err := ListUploads(ctx, d, "foo/bar")
+	require.NoError(t, err)
+	require.Len(t, uu, 2)
+	require.Equal(t, older, uu[0].ID)
+	require.Equal(t, newer, uu[1].ID)
+}
+
+func TestListUploads_SkipsMissingStartedAt(t *testing.T) {
+	d := inmemory.New()
+	ctx := context.Background()
+
+	dataPath, err := pathFor(uploadDataPathSpec{name: "foo/bar", id: uuid.Generate().String()})
+	require.NoError(t, err)
+	require.NoError(t, d.PutContent(ctx, dataPath, []byte("")))
+
+	uu, err := ListUploads(ctx, d, "foo/bar")
+	require.NoError(t, err)
+	require.Empty(t, uu)
+}
+
+func TestListUploads_None(t *testing.T) {
+	uu, err := ListUploads(context.Background(), inmemory.New(), "foo/bar")
+	require.NoError(t, err)
+	require.NotNil(t, uu)
+	require.Empty(t, uu)
+}
@@ -6,12 +6,14 @@ import (
 	"context"
 	"math"
 	"path"
+	"sync/atomic"
 	"time"
 
 	dcontext "github.com/docker/distribution/context"
 	"github.com/docker/distribution/registry/storage/driver"
 	"github.com/opencontainers/go-digest"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
 )
 
 // vacuum contains functions for cleaning up repositories and blobs on the storage backend.
@@ -55,18 +57,14 @@ func (v Vacuum) RemoveBlob(ctx context.Context, dgst digest.Digest) error {
 	return nil
 }
 
-// RemoveBlobs removes a list of blobs from the filesystem. This is used exclusively by the garbage collector and
-// the intention is to leverage on bulk delete requests whenever supported by the storage backend.
-func (v Vacuum) RemoveBlobs(ctx context.Context, dgsts []digest.Digest) error {
-	start := time.Now()
+// blobDataPaths returns the full path of the data file of each blob in dgsts.
+func blobDataPaths(ctx context.Context, dgsts []digest.Digest) ([]string, error) {
 	blobPaths := make([]string, 0, len(dgsts))
 
-	log := dcontext.GetLogger(ctx)
 	for _, d := range dgsts {
-		// get the full path of the blob's data file
 		p, err := pathFor(blobDataPathSpec{digest: d})
 		if err != nil {
-			return err
+			return nil, err
 		}
 		dcontext.GetLogger(ctx).WithFields(logrus.Fields{
 			"digest": d,
@@ -75,6 +73,20 @@ func (v Vacuum) RemoveBlobs(ctx context.Context, dgsts []digest.Digest) error {
 		blobPaths = append(blobPaths, p)
 	}
 
+	return blobPaths, nil
+}
+
+// RemoveBlobs removes a list of blobs from the filesystem. This is used exclusively by the garbage collector and
+// the intention is to leverage on bulk delete requests whenever supported by the storage backend.
+func (v Vacuum) RemoveBlobs(ctx context.Context, dgsts []digest.Digest) error {
+	start := time.Now()
+	log := dcontext.GetLogger(ctx)
+
+	blobPaths, err := blobDataPaths(ctx, dgsts)
+	if err != nil {
+		return err
+	}
+
 	total := len(blobPaths)
 	log.WithField("count", total).Info("deleting blobs")
 
@@ -93,6 +105,94 @@ func (v Vacuum) RemoveBlobs(ctx context.Context, dgsts []digest.Digest) error {
 	return err
 }
 
+// RemoveBlobsInBatches removes a list of blobs from the filesystem, splitting them in batches of up to batchSize blobs,
+// each deleted with a single driver.DeleteFiles request. Up to maxParallel batches are deleted concurrently, which
+// considerably speeds up the removal of large amounts of blobs on high latency storage backends, such as S3. The
+// deletion progress and throughput are logged after each batch. Removal stops on the first batch that fails.
+func (v Vacuum) RemoveBlobsInBatches(ctx context.Context, dgsts []digest.Digest, batchSize, maxParallel int) error {
+	if batchSize < 1 {
+		batchSize = len(dgsts)
+	}
+	if maxParallel < 1 {
+		maxParallel = 1
+	}
+
+	start := time.Now()
+	total := len(dgsts)
+	totalBatches := int(math.Ceil(float64(total) / float64(batchSize)))
+
+	log := dcontext.GetLogger(ctx)
+	log.WithFields(logrus.Fields{
+		"count":          total,
+		"batch_count":    totalBatches,
+		"batch_max_size": batchSize,
+		"max_parallel":   maxParallel,
+	}).Info("deleting blobs in batches")
+
+	var deleted int64
+	semaphore := make(chan struct{}, maxParallel)
+	g, gctx := errgroup.WithContext(ctx)
+
+schedule:
+	for i, batchNo := 0, 1; i < total; i, batchNo = i+batchSize, batchNo+1 {
+		batch := dgsts[i:min(i+batchSize, total)]
+		n := batchNo
+
+		select {
+		case semaphore <- struct{}{}:
+		case <-gctx.Done():
+			// a previous batch failed, stop scheduling new ones
+			break schedule
+		}
+
+		g.Go(func() error {
+			defer func() { <-semaphore }()
+
+			blobPaths, err := blobDataPaths(gctx, batch)
+			if err != nil {
+				return err
+			}
+
+			batchStart := time.Now()
+			count, err := v.driver.DeleteFiles(gctx, blobPaths)
+			sofar := atomic.AddInt64(&deleted, int64(count))
+
+			l := log.WithFields(logrus.Fields{
+				"batch_number": n,
+				"batch_total":  totalBatches,
+				"count":        count,
+				"deleted":      sofar,
+				"total":        total,
+				"duration_s":   time.Since(batchStart).Seconds(),
+				"blobs_per_s":  float64(sofar) / time.Since(start).Seconds(),
+			})
+			if count < len(blobPaths) {
+				l.Warn("blobs batch partially deleted")
+			} else {
+				l.Info("blobs batch deleted")
+			}
+
+			return err
+		})
+	}
+
+	err := g.Wait()
+
+	elapsed := time.Since(start).Seconds()
+	l := log.WithFields(logrus.Fields{
+		"count":       deleted,
+		"duration_s":  elapsed,
+		"blobs_per_s": float64(deleted) / elapsed,
+	})
+	if int(deleted) < total {
+		l.Warn("blobs partially deleted")
+	} else {
+		l.Info("blobs deleted")
+	}
+
+	return err
+}
+
 func (v Vacuum) removeManifestsBatch(ctx context.Context, batchNo int, mm []ManifestDel) error {
 	log := dcontext.GetLogger(ctx)
 	defer func() {
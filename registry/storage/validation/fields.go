@@ -0,0 +1,79 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// knownManifestFields maps manifest media types to the top-level fields defined by their specification.
+var knownManifestFields = map[string][]string{
+	schema2.MediaTypeManifest:          {"schemaVersion", "mediaType", "config", "layers"},
+	manifestlist.MediaTypeManifestList: {"schemaVersion", "mediaType", "manifests"},
+	v1.MediaTypeImageManifest:          {"schemaVersion", "mediaType", "config", "layers", "annotations"},
+	v1.MediaTypeImageIndex:             {"schemaVersion", "mediaType", "manifests", "annotations"},
+}
+
+// ErrUnknownManifestFields is returned when a manifest has top-level fields not defined by the specification of its
+// media type.
+type ErrUnknownManifestFields struct {
+	// Fields are the names of the unknown fields, sorted lexicographically.
+	Fields []string
+}
+
+func (err ErrUnknownManifestFields) Error() string {
+	return fmt.Sprintf("manifest has unknown top-level fields: %s", strings.Join(err.Fields, ", "))
+}
+
+// ManifestFields holds the restrictions on the top-level fields of pushed manifests. A zero value ManifestFields allows
+// every field.
+type ManifestFields struct {
+	// Strict rejects manifests with top-level fields not defined by the specification of their media type. Field names
+	// are case sensitive.
+	Strict bool
+}
+
+// Validate checks the top-level fields of the payload of manifest. An ErrUnknownManifestFields error is returned if
+// any of them is unknown. Manifests of media types other than Docker schema 2 and OCI are not checked.
+func (v ManifestFields) Validate(manifest distribution.Manifest) error {
+	if !v.Strict {
+		return nil
+	}
+
+	mediaType, payload, err := manifest.Payload()
+	if err != nil {
+		return err
+	}
+	known, ok := knownManifestFields[mediaType]
+	if !ok {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return err
+	}
+
+	var unknown []string
+FieldsLoop:
+	for f := range fields {
+		for _, k := range known {
+			if f == k {
+				continue FieldsLoop
+			}
+		}
+		unknown = append(unknown, f)
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+
+	return ErrUnknownManifestFields{Fields: unknown}
+}
@@ -0,0 +1,82 @@
+package validation_test
+
+import (
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	_ "github.com/docker/distribution/manifest/ocischema"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/registry/storage/validation"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestFields_Validate(t *testing.T) {
+	tt := []struct {
+		name      string
+		validator validation.ManifestFields
+		mediaType string
+		payload   string
+		wantErr   error
+	}{
+		{
+			name:      "not strict",
+			mediaType: schema2.MediaTypeManifest,
+			payload:   `{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json","config":{},"layers":[],"foo":"bar"}`,
+		},
+		{
+			name:      "docker image",
+			validator: validation.ManifestFields{Strict: true},
+			mediaType: schema2.MediaTypeManifest,
+			payload:   `{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json","config":{},"layers":[]}`,
+		},
+		{
+			name:      "docker image with unknown fields",
+			validator: validation.ManifestFields{Strict: true},
+			mediaType: schema2.MediaTypeManifest,
+			payload:   `{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json","config":{},"layers":[],"foo":"bar","annotations":{}}`,
+			wantErr:   validation.ErrUnknownManifestFields{Fields: []string{"annotations", "foo"}},
+		},
+		{
+			name:      "oci image",
+			validator: validation.ManifestFields{Strict: true},
+			mediaType: v1.MediaTypeImageManifest,
+			payload:   `{"schemaVersion":2,"config":{},"layers":[],"annotations":{"foo":"bar"}}`,
+		},
+		{
+			name:      "oci image with misspelled field",
+			validator: validation.ManifestFields{Strict: true},
+			mediaType: v1.MediaTypeImageManifest,
+			payload:   `{"schemaVersion":2,"config":{},"Layers":[]}`,
+			wantErr:   validation.ErrUnknownManifestFields{Fields: []string{"Layers"}},
+		},
+		{
+			name:      "docker manifest list with unknown fields",
+			validator: validation.ManifestFields{Strict: true},
+			mediaType: manifestlist.MediaTypeManifestList,
+			payload:   `{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.list.v2+json","manifests":[],"annotations":{}}`,
+			wantErr:   validation.ErrUnknownManifestFields{Fields: []string{"annotations"}},
+		},
+		{
+			name:      "oci index",
+			validator: validation.ManifestFields{Strict: true},
+			mediaType: v1.MediaTypeImageIndex,
+			payload:   `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[],"annotations":{}}`,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			m, _, err := distribution.UnmarshalManifest(test.mediaType, []byte(test.payload))
+			require.NoError(t, err)
+
+			err = test.validator.Validate(m)
+			if test.wantErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.Equal(t, test.wantErr, err)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/docker/distribution/manifest/schema2"
+)
+
+// ManifestMediaTypes holds the media types allowed for pushed manifests. A zero value ManifestMediaTypes allows every
+// media type.
+type ManifestMediaTypes struct {
+	// Allow is the list of allowed media types. Empty means unrestricted.
+	Allow []string
+}
+
+// Validate checks the media type of manifest against the allowed media types. Image manifests are only allowed if
+// the media type of their configuration is allowed as well, as artifacts such as Helm charts are stored as regular
+// OCI image manifests and can only be told apart by their configuration media type.
+func (v ManifestMediaTypes) Validate(manifest distribution.Manifest) error {
+	if len(v.Allow) == 0 {
+		return nil
+	}
+
+	mediaType, _, err := manifest.Payload()
+	if err != nil {
+		return err
+	}
+	if !v.allowed(mediaType) {
+		return fmt.Errorf("manifest media type %q is not allowed", mediaType)
+	}
+
+	var configMediaType string
+	switch m := manifest.(type) {
+	case *schema2.DeserializedManifest:
+		configMediaType = m.Config.MediaType
+	case *ocischema.DeserializedManifest:
+		configMediaType = m.Config.MediaType
+	default:
+		return nil
+	}
+	if !v.allowed(configMediaType) {
+		return fmt.Errorf("configuration media type %q is not allowed", configMediaType)
+	}
+
+	return nil
+}
+
+func (v ManifestMediaTypes) allowed(mediaType string) bool {
+	for _, mt := range v.Allow {
+		if mt == mediaType {
+			return true
+		}
+	}
+
+	return false
+}
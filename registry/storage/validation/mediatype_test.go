@@ -0,0 +1,99 @@
+package validation_test
+
+import (
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/registry/storage/validation"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestMediaTypes_Validate(t *testing.T) {
+	configDesc := func(mediaType string) distribution.Descriptor {
+		return distribution.Descriptor{MediaType: mediaType, Digest: digest.FromString(mediaType), Size: 1}
+	}
+
+	dockerImage, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    configDesc(schema2.MediaTypeImageConfig),
+	})
+	require.NoError(t, err)
+
+	ociImage, err := ocischema.FromStruct(ocischema.Manifest{
+		Versioned: ocischema.SchemaVersion,
+		Config:    configDesc(v1.MediaTypeImageConfig),
+	})
+	require.NoError(t, err)
+
+	helmChart, err := ocischema.FromStruct(ocischema.Manifest{
+		Versioned: ocischema.SchemaVersion,
+		Config:    configDesc("application/vnd.cncf.helm.config.v1+json"),
+	})
+	require.NoError(t, err)
+
+	index, err := manifestlist.FromDescriptorsWithMediaType(nil, v1.MediaTypeImageIndex)
+	require.NoError(t, err)
+
+	standard := []string{
+		schema2.MediaTypeManifest,
+		schema2.MediaTypeImageConfig,
+		v1.MediaTypeImageManifest,
+		v1.MediaTypeImageConfig,
+		manifestlist.MediaTypeManifestList,
+	}
+
+	tt := []struct {
+		name      string
+		validator validation.ManifestMediaTypes
+		manifest  distribution.Manifest
+		wantErr   string
+	}{
+		{
+			name:     "no restrictions",
+			manifest: helmChart,
+		},
+		{
+			name:      "docker image allowed",
+			validator: validation.ManifestMediaTypes{Allow: standard},
+			manifest:  dockerImage,
+		},
+		{
+			name:      "oci image allowed",
+			validator: validation.ManifestMediaTypes{Allow: standard},
+			manifest:  ociImage,
+		},
+		{
+			name:      "configuration media type not allowed",
+			validator: validation.ManifestMediaTypes{Allow: standard},
+			manifest:  helmChart,
+			wantErr:   `configuration media type "application/vnd.cncf.helm.config.v1+json" is not allowed`,
+		},
+		{
+			name:      "manifest media type not allowed",
+			validator: validation.ManifestMediaTypes{Allow: standard},
+			manifest:  index,
+			wantErr:   `manifest media type "application/vnd.oci.image.index.v1+json" is not allowed`,
+		},
+		{
+			name:      "manifest list allowed",
+			validator: validation.ManifestMediaTypes{Allow: append(standard, v1.MediaTypeImageIndex)},
+			manifest:  index,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.validator.Validate(test.manifest)
+			if test.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, test.wantErr)
+		})
+	}
+}
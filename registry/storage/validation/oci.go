@@ -46,7 +46,9 @@ func (v *OCIValidator) Validate(ctx context.Context, mnfst *ocischema.Deserializ
 		var err error
 
 		switch descriptor.MediaType {
-		case v1.MediaTypeImageLayer, v1.MediaTypeImageLayerGzip, v1.MediaTypeImageLayerNonDistributable, v1.MediaTypeImageLayerNonDistributableGzip:
+		case v1.MediaTypeImageLayer, v1.MediaTypeImageLayerGzip, v1.MediaTypeImageLayerNonDistributable, v1.MediaTypeImageLayerNonDistributableGzip,
+			ocischema.MediaTypeImageLayerEncrypted, ocischema.MediaTypeImageLayerGzipEncrypted, ocischema.MediaTypeImageLayerZstdEncrypted,
+			ocischema.MediaTypeImageLayerNonDistributableEncrypted, ocischema.MediaTypeImageLayerNonDistributableGzipEncrypted:
 			for _, u := range descriptor.URLs {
 				if !validURL(u, v.manifestURLs) {
 					err = errInvalidURL
@@ -272,3 +272,47 @@ func TestVerifyManifest_OCI_MultipleErrors(t *testing.T) {
 	require.NotContains(t, err.Error(), m.Layers[1].Digest.String())
 	require.Contains(t, err.Error(), m.Layers[2].Digest.String())
 }
+
+func TestVerifyManifest_OCI_EncryptedLayer(t *testing.T) {
+	ctx := context.Background()
+
+	registry := createRegistry(t)
+	repo := makeRepository(t, registry, "test")
+
+	manifestService, err := testutil.MakeManifestService(repo)
+	require.NoError(t, err)
+
+	v := validation.NewOCIValidator(manifestService, repo.Blobs(ctx), false, validation.ManifestURLs{})
+
+	for _, mt := range []string{
+		ocischema.MediaTypeImageLayerEncrypted,
+		ocischema.MediaTypeImageLayerGzipEncrypted,
+		ocischema.MediaTypeImageLayerZstdEncrypted,
+		ocischema.MediaTypeImageLayerNonDistributableEncrypted,
+		ocischema.MediaTypeImageLayerNonDistributableGzipEncrypted,
+	} {
+		layer, err := repo.Blobs(ctx).Put(ctx, mt, []byte(mt))
+		require.NoError(t, err)
+		layer.MediaType = mt
+		// the encryption metadata is held in the layer annotations
+		layer.Annotations = map[string]string{
+			"org.opencontainers.image.enc.keys.jwe": "eyJwcm90ZWN0ZWQiOiJleUpoYkdjaU9pSlNVMEV0VDBGRlVDSjkifQ==",
+			"org.opencontainers.image.enc.pubopts":  "eyJjaXBoZXIiOiJBRVNfMjU2X0NUUl9ITUFDX1NIQTI1NiJ9",
+		}
+
+		m := makeOCIManifestTemplate(t, repo)
+		m.Layers = []distribution.Descriptor{layer}
+
+		dm, err := ocischema.FromStruct(m)
+		require.NoError(t, err)
+		require.NoError(t, v.Validate(ctx, dm), mt)
+
+		// missing encrypted layers are reported as unknown blobs
+		missing := distribution.Descriptor{MediaType: mt, Digest: digest.FromString("missing"), Size: 7}
+		m.Layers = []distribution.Descriptor{layer, missing}
+
+		dm, err = ocischema.FromStruct(m)
+		require.NoError(t, err)
+		require.Equal(t, distribution.ErrManifestVerification{distribution.ErrManifestBlobUnknown{Digest: missing.Digest}}, v.Validate(ctx, dm))
+	}
+}
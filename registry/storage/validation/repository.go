@@ -0,0 +1,39 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/docker/distribution"
+)
+
+// RepositoryNames holds restrictions for repository names used on write operations. A zero value RepositoryNames
+// allows every name.
+type RepositoryNames struct {
+	// MaxPathComponents is the maximum number of path components. Zero means unlimited.
+	MaxPathComponents int
+	// MaxLength is the maximum name length. Zero means unlimited.
+	MaxLength int
+	// Allow is a regular expression that names must match, if not nil.
+	Allow *regexp.Regexp
+}
+
+// Validate checks name against the configured restrictions. A distribution.ErrRepositoryNameInvalid error is returned
+// if any of them is violated.
+func (v RepositoryNames) Validate(name string) error {
+	var reason error
+
+	switch {
+	case v.MaxLength > 0 && len(name) > v.MaxLength:
+		reason = fmt.Errorf("name length %d exceeds limit of %d characters", len(name), v.MaxLength)
+	case v.MaxPathComponents > 0 && len(strings.Split(name, "/")) > v.MaxPathComponents:
+		reason = fmt.Errorf("name has %d path components, exceeding limit of %d", len(strings.Split(name, "/")), v.MaxPathComponents)
+	case v.Allow != nil && !v.Allow.MatchString(name):
+		reason = fmt.Errorf("name does not match allowed pattern %q", v.Allow.String())
+	default:
+		return nil
+	}
+
+	return distribution.ErrRepositoryNameInvalid{Name: name, Reason: reason}
+}
@@ -0,0 +1,70 @@
+package validation_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/registry/storage/validation"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryNames_Validate(t *testing.T) {
+	tt := []struct {
+		name      string
+		validator validation.RepositoryNames
+		repoName  string
+		wantErr   bool
+	}{
+		{
+			name:     "no restrictions",
+			repoName: "a/b/c/d/e/f/g",
+		},
+		{
+			name:      "max length within limit",
+			validator: validation.RepositoryNames{MaxLength: 5},
+			repoName:  "a/bcd",
+		},
+		{
+			name:      "max length exceeded",
+			validator: validation.RepositoryNames{MaxLength: 5},
+			repoName:  "a/bcde",
+			wantErr:   true,
+		},
+		{
+			name:      "max path components within limit",
+			validator: validation.RepositoryNames{MaxPathComponents: 3},
+			repoName:  "a/b/c",
+		},
+		{
+			name:      "max path components exceeded",
+			validator: validation.RepositoryNames{MaxPathComponents: 3},
+			repoName:  "a/b/c/d",
+			wantErr:   true,
+		},
+		{
+			name:      "allowed pattern match",
+			validator: validation.RepositoryNames{Allow: regexp.MustCompile(`^(?:[a-z]+(?:/[a-z]+)*)$`)},
+			repoName:  "foo/bar",
+		},
+		{
+			name:      "allowed pattern mismatch",
+			validator: validation.RepositoryNames{Allow: regexp.MustCompile(`^(?:[a-z]+(?:/[a-z]+)*)$`)},
+			repoName:  "foo/bar-1",
+			wantErr:   true,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.validator.Validate(test.repoName)
+			if !test.wantErr {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.IsType(t, distribution.ErrRepositoryNameInvalid{}, err)
+			require.Equal(t, test.repoName, err.(distribution.ErrRepositoryNameInvalid).Name)
+		})
+	}
+}
@@ -17,6 +17,9 @@ var Revision = ""
 // BuildTime is filled with the UTC datetime when the binary was built.
 var BuildTime = ""
 
+// OCIDistributionSpec is the version of the OCI Distribution specification implemented by the registry.
+const OCIDistributionSpec = "v1.1.0"
+
 // ExtFeatures is a comma separated list of extensions/features supported by the GitLab Container Registry that are
 // not part of the Docker Distribution spec.
-const ExtFeatures = "tag_delete"
+const ExtFeatures = "tag_delete,manifest_platform_filter,chunk_digest"